@@ -0,0 +1,123 @@
+package cfg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize 字节数，配置文件中可以写为带单位的字符串或整数（字节）
+//
+// 单位不区分大小写：B；KB、MB、GB、TB 为 1000 进制；KiB、MiB、GiB、TiB 为 1024 进制；
+// 数值可以带小数（"1.5GiB"），数值与单位之间可以有空格。写回配置文件（UpdateCfg）时
+// 编码为能精确表示的最大单位（10485760 → "10MiB"），解析后得到相同的值
+//
+// 示例
+//
+//	type UploadConfig struct {
+//	    MaxFileSize cfg.ByteSize `toml:"maxFileSize" default:"10MiB"`
+//	}
+//
+//	// maxFileSize = "10MiB" / "512KiB" / "1.5GB" / 10485760
+//	if size > config.MaxFileSize.Bytes() { ... }
+type ByteSize int64
+
+// ByteSize 常用单位
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000
+	MB          = 1000 * KB
+	GB          = 1000 * MB
+	TB          = 1000 * GB
+
+	KiB ByteSize = 1024
+	MiB          = 1024 * KiB
+	GiB          = 1024 * MiB
+	TiB          = 1024 * GiB
+)
+
+// byteUnits 编码时按顺序尝试（大单位优先，同一量级 1024 进制优先）
+var byteUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"TiB", TiB}, {"TB", TB}, {"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB}, {"KiB", KiB}, {"KB", KB},
+}
+
+// ParseByteSize 解析带单位的字节数（"10MB"、"512KiB"、"1.5 GiB"、"2048"）
+func ParseByteSize(s string) (ByteSize, error) {
+	text := strings.TrimSpace(s)
+	i := 0
+	for i < len(text) && (text[i] >= '0' && text[i] <= '9' || text[i] == '.') {
+		i++
+	}
+	number, unit := text[:i], strings.TrimSpace(text[i:])
+	if number == "" {
+		return 0, fmt.Errorf("无效的字节数 %q", s)
+	}
+
+	multiplier := Byte
+	if unit != "" && !strings.EqualFold(unit, "B") {
+		found := false
+		for _, u := range byteUnits {
+			if strings.EqualFold(unit, u.name) {
+				multiplier, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("无效的字节数 %q: 未知单位 %q", s, unit)
+		}
+	}
+
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		if n > math.MaxInt64/int64(multiplier) {
+			return 0, fmt.Errorf("无效的字节数 %q: 超出范围", s)
+		}
+		return ByteSize(n) * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的字节数 %q", s)
+	}
+	v := math.Round(f * float64(multiplier))
+	if v >= math.MaxInt64 {
+		return 0, fmt.Errorf("无效的字节数 %q: 超出范围", s)
+	}
+	return ByteSize(v), nil
+}
+
+// Bytes 字节数
+func (b ByteSize) Bytes() int64 {
+	return int64(b)
+}
+
+// String 能精确表示的最大单位（"10MiB"、"1500KB"），不能整除时为字节数
+func (b ByteSize) String() string {
+	if b != 0 {
+		for _, u := range byteUnits {
+			if b%u.size == 0 {
+				return strconv.FormatInt(int64(b/u.size), 10) + u.name
+			}
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// MarshalText 实现 encoding.TextMarshaler（写回配置文件时使用）
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler（配置文件中的字符串与整数、default 标签、命令行参数都经过这里）
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	v, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
@@ -0,0 +1,68 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]ByteSize{
+		"0":        0,
+		"2048":     2048,
+		"512B":     512,
+		"10MB":     10_000_000,
+		"10mb":     10_000_000,
+		"10MiB":    10 * 1024 * 1024,
+		"512KiB":   512 * 1024,
+		"1.5 GiB":  1536 * MiB,
+		" 2 TB ":   2 * TB,
+		"0.5kib":   512,
+		"10485760": 10 * MiB,
+	}
+	for in, want := range cases {
+		got, err := ParseByteSize(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "MB", "-1MB", "10XB", "1.2.3MB", "99999999999TiB"} {
+		_, err := ParseByteSize(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestByteSize_StringRoundTrip(t *testing.T) {
+	for _, size := range []ByteSize{0, 1, 1000, 1024, 1500 * KB, 10 * MiB, 10 * MB, 3 * GiB, 12345} {
+		text, err := size.MarshalText()
+		require.NoError(t, err)
+		var back ByteSize
+		require.NoError(t, back.UnmarshalText(text), string(text))
+		assert.Equal(t, size, back, string(text))
+	}
+	assert.Equal(t, "10MiB", (10 * MiB).String())
+	assert.Equal(t, "1500KB", (1500 * KB).String())
+	assert.Equal(t, "12345B", ByteSize(12345).String())
+}
+
+type sizesConfig struct {
+	MaxFileSize ByteSize `toml:"maxFileSize" default:"1MiB"`
+	Limits      struct {
+		Body ByteSize `toml:"body"`
+	} `toml:"limits"`
+}
+
+func TestByteSize_DecodeStringsIntegersAndDefaults(t *testing.T) {
+	var c sizesConfig
+	require.NoError(t, unmarshal([]byte("[limits]\nbody = \"64KiB\"\n"), &c))
+	assert.Equal(t, MiB, c.MaxFileSize, "default 标签")
+	assert.Equal(t, 64*KiB, c.Limits.Body)
+
+	require.NoError(t, unmarshal([]byte("maxFileSize = 10485760\n"), &c), "兼容原来的整数写法")
+	assert.Equal(t, 10*MiB, c.MaxFileSize)
+
+	err := unmarshal([]byte("maxFileSize = \"10 parsecs\"\n"), &c)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	assert.Equal(t, "maxFileSize", de.Key)
+}
@@ -0,0 +1,177 @@
+package cfg
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// durationType time.Duration 的默认值支持 "30s"、"5m" 形式
+var durationType = reflect.TypeFor[time.Duration]()
+
+// unmarshal 解析 TOML 到配置结构体并应用 default 标签（替代 toml.Unmarshal）
+func unmarshal(data []byte, v any) error {
+	_, err := decode(string(data), v)
+	return err
+}
+
+// decode 解析 TOML 到配置结构体并应用 default 标签
+//
+// 加载、热更新、目录与多文件合并、Update 与试运行都经过这里，default 标签与 ENC(...) 解密在所有路径上行为一致。
+// 优先级：文件中的值 > default 标签 > 零值。文件中出现的键（即使写的是零值，如 port = 0）不会被默认值覆盖
+//
+// 示例：
+//
+//	type ServerConfig struct {
+//	    Port    int           `toml:"port" default:"8080"`
+//	    Host    string        `toml:"host" default:"0.0.0.0"`
+//	    Debug   bool          `toml:"debug" default:"true"`
+//	    Timeout time.Duration `toml:"timeout" default:"30s"`
+//	    Origins []string      `toml:"origins" default:"https://a.example,https://b.example"`
+//	}
+func decode(data string, v any) (toml.MetaData, error) {
+	// ENC(...) 加密值在解析到结构体之前解密（热更新时同样重新解密）
+	data, err := decryptValues(data)
+	if err != nil {
+		return toml.MetaData{}, err
+	}
+	md, err := toml.Decode(data, v)
+	if err != nil {
+		return md, decodeError(err)
+	}
+	if err := applyDefaults(reflect.ValueOf(v), nil, md.IsDefined); err != nil {
+		return md, err
+	}
+	return md, nil
+}
+
+// applyDefaults 递归为文件中没有出现且仍为零值的字段设置 default 标签的值
+func applyDefaults(v reflect.Value, path []string, defined func(...string) bool) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var err error
+		walkFields(v.Type(), func(f configField) {
+			field, ok := existingField(v, f.Index)
+			if err != nil || !ok {
+				return // 内嵌的 nil 指针没有可设置的字段
+			}
+			fieldPath := append(append([]string(nil), path...), f.Key)
+			if def, ok := f.Field.Tag.Lookup("default"); ok && field.IsZero() && !defined(fieldPath...) {
+				if err = setDefault(field, def); err != nil {
+					err = fmt.Errorf("%s 的默认值 %q 无效: %w", strings.Join(fieldPath, "."), def, err)
+					return
+				}
+			}
+			err = applyDefaults(field, fieldPath, defined)
+		})
+		return err
+
+	case reflect.Map:
+		// map 的值不可寻址：复制后应用默认值再写回
+		if v.Type().Key().Kind() != reflect.String || !hasDefaults(v.Type().Elem()) {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := applyDefaults(elem.Addr(), append(append([]string(nil), path...), iter.Key().String()), defined); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return nil
+}
+
+// hasDefaults 类型中是否有 default 标签（避免无意义地遍历 map）
+func hasDefaults(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if _, ok := t.Field(i).Tag.Lookup("default"); ok || hasDefaults(t.Field(i).Type) {
+				return true
+			}
+		}
+	case reflect.Map:
+		return hasDefaults(t.Elem())
+	}
+	return false
+}
+
+// setDefault 按字段类型解析默认值：字符串、数值、布尔、time.Duration、实现 encoding.TextUnmarshaler 的类型，
+// 以及元素为上述类型的切片（逗号分隔）
+func setDefault(field reflect.Value, def string) error {
+	if field.CanAddr() {
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(def))
+		}
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(def, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		if def != "" {
+			items = strings.Split(def, ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setDefault(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("不支持的类型 %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type defaultsServer struct {
+	Port    int           `toml:"port" default:"8080"`
+	Host    string        `toml:"host" default:"0.0.0.0"`
+	Debug   bool          `toml:"debug" default:"true"`
+	Timeout time.Duration `toml:"timeout" default:"30s"`
+	Ratio   float64       `toml:"ratio" default:"0.5"`
+	Origins []string      `toml:"origins" default:"https://a.example, https://b.example"`
+}
+
+type defaultsWorker struct {
+	Concurrency int `toml:"concurrency" default:"4"`
+}
+
+type defaultsConfig struct {
+	AppName string                    `toml:"appName" default:"app"`
+	Server  defaultsServer            `toml:"server"`
+	Workers map[string]defaultsWorker `toml:"workers"`
+	Retries []int                     `toml:"retries" default:"1,2,4"`
+}
+
+func TestDecode_DefaultTags(t *testing.T) {
+	var c defaultsConfig
+	_, err := decode(`
+[server]
+port = 0          # 文件中写了零值：不被默认值覆盖
+debug = false
+host = "127.0.0.1"
+
+[workers.mail]
+[workers.report]
+concurrency = 1
+`, &c)
+	require.NoError(t, err)
+
+	assert.Equal(t, "app", c.AppName)
+	assert.Equal(t, 0, c.Server.Port)
+	assert.False(t, c.Server.Debug)
+	assert.Equal(t, "127.0.0.1", c.Server.Host)
+	assert.Equal(t, 30*time.Second, c.Server.Timeout)
+	assert.Equal(t, 0.5, c.Server.Ratio)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.Server.Origins)
+	assert.Equal(t, []int{1, 2, 4}, c.Retries)
+	assert.Equal(t, 4, c.Workers["mail"].Concurrency)
+	assert.Equal(t, 1, c.Workers["report"].Concurrency)
+
+	var empty defaultsConfig
+	_, err = decode("", &empty)
+	require.NoError(t, err)
+	assert.Equal(t, 8080, empty.Server.Port)
+	assert.True(t, empty.Server.Debug)
+
+	var bad struct {
+		Port int `toml:"port" default:"http"`
+	}
+	_, err = decode("", &bad)
+	assert.ErrorContains(t, err, "port 的默认值")
+}
@@ -0,0 +1,326 @@
+package cfg
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SourceKind 配置项取值的来源类别
+type SourceKind string
+
+const (
+	SourceZero    SourceKind = "zero"    // 没有任何来源设置，为类型零值
+	SourceDefault SourceKind = "default" // default 标签，或 InitConfig 在配置文件不可用时使用的内置默认配置
+	SourceFile    SourceKind = "file"    // 配置文件（多文件合并时为第一个文件或片段）
+	SourceOverlay SourceKind = "overlay" // 覆盖文件：LoadConfigs 后面的文件、include 引用的文件、LoadConfigDir 中排在后面的片段
+	SourceFlag    SourceKind = "flag"    // 命令行参数（见 BindFlags）
+	SourceUpdate  SourceKind = "update"  // 运行时通过 Update / UpdateCfg 修改（之后文件被外部修改并热更新时按文件重新计算）
+)
+
+// sourceRank 同一配置项（map、表数组）的子键来自不同来源时，展示优先级最高的来源
+var sourceRank = map[SourceKind]int{SourceZero: 0, SourceDefault: 1, SourceFile: 2, SourceOverlay: 3, SourceUpdate: 4, SourceFlag: 5}
+
+// Source 配置项的来源
+type Source struct {
+	Kind SourceKind `json:"kind"`
+	Name string     `json:"name,omitempty"` // 文件路径、命令行参数名等
+}
+
+// String 如 "overlay (config.local.toml)"
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return fmt.Sprintf("%s (%s)", s.Kind, s.Name)
+}
+
+// currentSources 当前配置每个叶子键（toml 路径）的来源，与 currentConfig 一起更新
+var currentSources atomic.Pointer[map[string]Source]
+
+// fileSources 单个配置文件提供的键（kind 为 SourceFile 或 SourceDefault）
+func fileSources(name string, data []byte, kind SourceKind) map[string]Source {
+	tree := make(map[string]any)
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return map[string]Source{}
+	}
+	m := newMergedConfig()
+	m.Merge(name, tree)
+	sources := make(map[string]Source, len(m.Provenance))
+	for path := range m.Provenance {
+		sources[path] = Source{Kind: kind, Name: name}
+	}
+	return sources
+}
+
+// mergedSources 多文件合并结果中每个键的来源：第一个文件为 SourceFile，之后合并的为 SourceOverlay
+func mergedSources(m *MergedConfig) map[string]Source {
+	sources := make(map[string]Source, len(m.Provenance))
+	for path, name := range m.Provenance {
+		kind := SourceOverlay
+		if len(m.Sources) > 0 && name == m.Sources[0] {
+			kind = SourceFile
+		}
+		sources[path] = Source{Kind: kind, Name: name}
+	}
+	return sources
+}
+
+// storeSources 记录配置的来源（显式传入的命令行参数覆盖文件中的来源），在替换当前配置之前调用
+func storeSources(cfg any, sources map[string]Source) {
+	sources = withFlagSources(cfg, sources)
+	currentSources.Store(&sources)
+}
+
+func withFlagSources(cfg any, sources map[string]Source) map[string]Source {
+	_, set := flagOverrides(cfg)
+	for name := range set {
+		clearSources(sources, name)
+		sources[name] = Source{Kind: SourceFlag, Name: "--" + name}
+	}
+	return sources
+}
+
+// markUpdated Update / UpdateCfg 写回后，值发生变化的配置项来源改为 SourceUpdate
+func markUpdated(oldCfg, newCfg any, path string) {
+	sources := make(map[string]Source)
+	if p := currentSources.Load(); p != nil {
+		maps.Copy(sources, *p)
+	}
+	if oldCfg != nil && reflect.TypeOf(oldCfg) == reflect.TypeOf(newCfg) {
+		for _, c := range Diff(oldCfg, newCfg) {
+			clearSources(sources, c.Path)
+			sources[c.Path] = Source{Kind: SourceUpdate, Name: path}
+		}
+	}
+	storeSources(newCfg, sources)
+}
+
+// clearSources 删除路径及其子键的来源（map 整体替换时子键的来源随之失效）
+func clearSources(sources map[string]Source, path string) {
+	delete(sources, path)
+	for k := range sources {
+		if strings.HasPrefix(k, path+".") || strings.HasPrefix(k, path+"[") {
+			delete(sources, k)
+		}
+	}
+}
+
+// FieldDoc 一个配置项的说明
+type FieldDoc struct {
+	Path            string `json:"path"`                      // toml 路径，如 web.port
+	Type            string `json:"type"`                      // Go 类型
+	Comment         string `json:"comment,omitempty"`         // comment 标签
+	Default         string `json:"default,omitempty"`         // default 标签
+	Validate        string `json:"validate,omitempty"`        // validate 标签中的校验规则
+	Secret          bool   `json:"secret,omitempty"`          // sensitive:"true"，取值已脱敏
+	RestartRequired bool   `json:"restartRequired,omitempty"` // reload:"restart"，修改后需要重启才生效
+	Value           any    `json:"value"`                     // 当前生效的值
+	Source          Source `json:"source"`                    // 当前值的来源
+}
+
+// ConfigDoc 配置说明文档
+type ConfigDoc struct {
+	Fields []FieldDoc `json:"fields"`
+}
+
+// Describe 生成当前配置的说明文档：每个配置项的路径、类型、说明、默认值、校验规则、是否敏感、
+// 是否需要重启，以及当前生效的值与来源（default 标签 / 配置文件 / 覆盖文件 / 命令行参数 / 运行时修改）
+//
+// 字段标签：
+//
+//	comment:"..."        配置项说明
+//	default / validate / sensitive / reload 与加载、校验、Diff 使用的标签相同
+//
+// 敏感配置项的值替换为 RedactedValue。未加载 T 类型的配置时值为零值、来源为 zero / default
+//
+// 使用方式：
+//
+//	type ServerConfig struct {
+//	    Port int `toml:"port" default:"8080" validate:"min=1,max=65535" reload:"restart" comment:"监听端口"`
+//	}
+//
+//	doc := cfg.Describe[AppConfig]()
+//	fmt.Print(doc.Table())
+func Describe[T any]() ConfigDoc {
+	cur, _ := currentAs[T]()
+	if cur == nil {
+		cur = new(T)
+	}
+	return describe(cur, loadSources())
+}
+
+// DescribeCurrent 当前配置的说明文档（不知道配置类型的场景，如管理接口；未加载配置时为空）
+func DescribeCurrent() ConfigDoc {
+	cur := Current()
+	if cur == nil {
+		return ConfigDoc{}
+	}
+	return describe(cur, loadSources())
+}
+
+// DescribeFiles 按 LoadConfigs 的规则读取并合并配置文件后生成说明文档，不替换当前配置、不启动监听
+//
+// 用于命令行查看配置（见 RunConfigCommand）
+func DescribeFiles[T any](paths ...string) (ConfigDoc, error) {
+	if len(paths) == 0 {
+		return ConfigDoc{}, fmt.Errorf("%w: 没有指定配置文件", ErrConfigNotFound)
+	}
+	cfg, merged, _, err := mergeConfigFiles[T](paths)
+	if err != nil {
+		return ConfigDoc{}, err
+	}
+	return describe(cfg, withFlagSources(cfg, mergedSources(merged))), nil
+}
+
+func loadSources() map[string]Source {
+	if p := currentSources.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func describe(cfg any, sources map[string]Source) ConfigDoc {
+	var doc ConfigDoc
+	describeStruct(&doc, "", reflect.ValueOf(cfg).Elem(), false, sources)
+	return doc
+}
+
+func describeStruct(doc *ConfigDoc, path string, v reflect.Value, restart bool, sources map[string]Source) {
+	walkFields(v.Type(), func(f configField) {
+		fieldRestart := f.restartRequired(restart)
+		fv := indirect(fieldByIndex(v, f.Index))
+		fieldPath := joinPath(path, f.Key)
+		secret := f.Field.Tag.Get("sensitive") == "true"
+		if !secret && isSection(fv.Type()) {
+			describeStruct(doc, fieldPath, fv, fieldRestart, sources)
+			return
+		}
+
+		field := FieldDoc{
+			Path:            fieldPath,
+			Type:            f.Field.Type.String(),
+			Comment:         f.Field.Tag.Get("comment"),
+			Default:         f.Field.Tag.Get("default"),
+			Validate:        f.Field.Tag.Get("validate"),
+			Secret:          secret,
+			RestartRequired: fieldRestart,
+			Value:           redactValue(fv),
+			Source:          sourceOf(sources, fieldPath),
+		}
+		if secret && !fv.IsZero() {
+			field.Value = RedactedValue
+		}
+		if field.Source.Kind == SourceZero && hasDefaultTag(f.Field) {
+			field.Source = Source{Kind: SourceDefault}
+		}
+		doc.Fields = append(doc.Fields, field)
+	})
+}
+
+func hasDefaultTag(f reflect.StructField) bool {
+	_, ok := f.Tag.Lookup("default")
+	return ok
+}
+
+// isSection 作为配置表展开的结构体（自定义文本编解码的类型作为单个值）
+func isSection(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || !hasExportedFields(t) {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType) && !t.Implements(reflect.TypeFor[encoding.TextMarshaler]())
+}
+
+// sourceOf 配置项的来源；map、表数组等由多个子键组成的配置项取子键中优先级最高的来源
+func sourceOf(sources map[string]Source, path string) Source {
+	if s, ok := sources[path]; ok {
+		return s
+	}
+	best := Source{Kind: SourceZero}
+	for k, s := range sources {
+		if (strings.HasPrefix(k, path+".") || strings.HasPrefix(k, path+"[")) && sourceRank[s.Kind] > sourceRank[best.Kind] {
+			best = s
+		}
+	}
+	return best
+}
+
+// Table 渲染为纯文本表格（路径、当前值、来源、默认值、标记、说明）
+//
+// 输出示例：
+//
+//	PATH          VALUE        SOURCE                        DEFAULT  FLAGS    COMMENT
+//	web.port      9090         flag (--web.port)             8080     restart  监听端口
+//	web.db.pass   "******"     file (config.toml)                     secret   数据库密码
+func (d ConfigDoc) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVALUE\tSOURCE\tDEFAULT\tFLAGS\tCOMMENT")
+	for _, f := range d.Fields {
+		var flags []string
+		if f.RestartRequired {
+			flags = append(flags, "restart")
+		}
+		if f.Secret {
+			flags = append(flags, "secret")
+		}
+		source := f.Source
+		if source.Kind == SourceFile || source.Kind == SourceOverlay || source.Kind == SourceUpdate {
+			source.Name = filepath.Base(source.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Path, formatValue(f.Value), source, f.Default, strings.Join(flags, ","), f.Comment)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// RunConfigCommand 处理 config 子命令，不启动服务
+//
+//	config describe          以表格输出配置说明（当前值、来源、默认值等）
+//	config describe --json   以 JSON 输出
+//
+// args 不是 config 子命令时返回 false，由调用方继续正常启动；是时输出到 out 并返回 true（err 为执行错误）。
+// paths 为配置文件，规则与 LoadConfigs 相同；BindFlags 绑定的参数需要在调用前解析
+//
+// 使用方式：
+//
+//	cfg.BindFlags[AppConfig](flag.CommandLine)
+//	flag.Parse()
+//	if ok, err := cfg.RunConfigCommand[AppConfig](flag.Args(), os.Stdout, "app.toml"); ok {
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    return
+//	}
+//
+//	// ./app config describe
+//	// ./app --web.port=9999 config describe --json
+func RunConfigCommand[T any](args []string, out io.Writer, paths ...string) (bool, error) {
+	if len(args) == 0 || args[0] != "config" {
+		return false, nil
+	}
+	if len(args) < 2 || args[1] != "describe" {
+		return true, errors.New("用法: config describe [--json]")
+	}
+	doc, err := DescribeFiles[T](paths...)
+	if err != nil {
+		return true, err
+	}
+	if len(args) > 2 && (args[2] == "--json" || args[2] == "-json") {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return true, enc.Encode(doc)
+	}
+	_, err = io.WriteString(out, doc.Table())
+	return true, err
+}
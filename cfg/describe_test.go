@@ -0,0 +1,164 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type describeDatabase struct {
+	Host     string `toml:"host" default:"localhost" comment:"数据库地址"`
+	Password string `toml:"password" sensitive:"true" comment:"数据库密码"`
+	Pool     int    `toml:"pool" default:"5" validate:"min=1,max=100" reload:"hot"`
+}
+
+type DescribeCommon struct {
+	Region string `toml:"region" default:"cn" comment:"部署区域"`
+}
+
+type describeConfig struct {
+	AppName  string            `toml:"appName" comment:"应用名称"`
+	Port     int               `toml:"port" default:"8080" reload:"restart" comment:"监听端口"`
+	Timeout  time.Duration     `toml:"timeout" default:"5s"`
+	Database describeDatabase  `toml:"database" reload:"restart"`
+	Features map[string]bool   `toml:"features"`
+	Labels   []string          `toml:"labels"`
+	Extra    map[string]string `toml:"-"`
+	DescribeCommon
+}
+
+func findField(t *testing.T, doc ConfigDoc, path string) FieldDoc {
+	for _, f := range doc.Fields {
+		if f.Path == path {
+			return f
+		}
+	}
+	t.Fatalf("配置项 %s 不存在", path)
+	return FieldDoc{}
+}
+
+func TestDescribe_FieldMetadata(t *testing.T) {
+	c := &describeConfig{AppName: "shop", Port: 9090, Timeout: 5 * time.Second, Features: map[string]bool{"beta": true}}
+	c.Database = describeDatabase{Host: "db.internal", Password: "s3cret", Pool: 5}
+	c.Region = "cn"
+	doc := describe(c, map[string]Source{
+		"appName":       {Kind: SourceFile, Name: "/etc/app/config.toml"},
+		"port":          {Kind: SourceFlag, Name: "--port"},
+		"database.host": {Kind: SourceOverlay, Name: "config.local.toml"},
+		"features.beta": {Kind: SourceFile, Name: "/etc/app/config.toml"},
+	})
+
+	var paths []string
+	for _, f := range doc.Fields {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"appName", "port", "timeout", "database.host", "database.password", "database.pool", "features", "labels", "region"}, paths,
+		"嵌套表展开，内嵌结构体的字段提升到当前层级，toml:\"-\" 跳过")
+
+	port := findField(t, doc, "port")
+	assert.Equal(t, FieldDoc{Path: "port", Type: "int", Comment: "监听端口", Default: "8080", RestartRequired: true, Value: 9090,
+		Source: Source{Kind: SourceFlag, Name: "--port"}}, port)
+
+	password := findField(t, doc, "database.password")
+	assert.True(t, password.Secret)
+	assert.Equal(t, RedactedValue, password.Value)
+	assert.True(t, password.RestartRequired, "继承外层的 reload:\"restart\"")
+	assert.Equal(t, SourceZero, password.Source.Kind)
+
+	pool := findField(t, doc, "database.pool")
+	assert.False(t, pool.RestartRequired, "reload:\"hot\" 覆盖外层")
+	assert.Equal(t, "min=1,max=100", pool.Validate)
+	assert.Equal(t, Source{Kind: SourceDefault}, pool.Source, "文件中没有出现、有 default 标签")
+
+	assert.Equal(t, SourceOverlay, findField(t, doc, "database.host").Source.Kind)
+	assert.Equal(t, SourceFile, findField(t, doc, "features").Source.Kind, "map 取子键的来源")
+	assert.Equal(t, "time.Duration", findField(t, doc, "timeout").Type)
+
+	table := doc.Table()
+	assert.Contains(t, table, "PATH")
+	assert.Regexp(t, `port\s+9090\s+flag \(--port\)\s+8080\s+restart\s+监听端口`, table)
+	assert.Regexp(t, `appName\s+"shop"\s+file \(config\.toml\)`, table, "文件来源只显示文件名")
+	assert.NotContains(t, table, "s3cret")
+
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+}
+
+func TestDescribe_ProvenanceAcrossLayers(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	BindFlags[describeConfig](fs)
+	t.Cleanup(func() { boundFlags.Store(nil) })
+	require.NoError(t, fs.Parse([]string{"--port=9999"}))
+
+	dir := t.TempDir()
+	writeFragment(t, dir, "config.toml", "appName = \"shop\"\nport = 8080\n[database]\nhost = \"db.internal\"\n[features]\nbeta = true\n")
+	writeFragment(t, dir, "config.local.toml", "[database]\nhost = \"localhost\"\n[features]\nnew = true\n")
+	base, local := filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.local.toml")
+	require.NoError(t, LoadConfigs[describeConfig](base, local))
+
+	doc := Describe[describeConfig]()
+	assert.Equal(t, Source{Kind: SourceFile, Name: base}, findField(t, doc, "appName").Source)
+	assert.Equal(t, Source{Kind: SourceOverlay, Name: local}, findField(t, doc, "database.host").Source)
+	assert.Equal(t, Source{Kind: SourceFlag, Name: "--port"}, findField(t, doc, "port").Source)
+	assert.Equal(t, 9999, findField(t, doc, "port").Value)
+	assert.Equal(t, SourceDefault, findField(t, doc, "timeout").Source.Kind)
+	assert.Equal(t, SourceZero, findField(t, doc, "labels").Source.Kind)
+	assert.Equal(t, SourceOverlay, findField(t, doc, "features").Source.Kind, "子键来自多个文件时取后合并的")
+
+	// 热更新：覆盖文件撤销了 database.host，来源回到基础文件
+	writeFragment(t, dir, "config.local.toml", "labels = [\"canary\"]\n")
+	require.Eventually(t, func() bool { return GetCfg[describeConfig]().Database.Host == "db.internal" }, 2*time.Second, 20*time.Millisecond)
+	doc = Describe[describeConfig]()
+	assert.Equal(t, Source{Kind: SourceFile, Name: base}, findField(t, doc, "database.host").Source)
+	assert.Equal(t, Source{Kind: SourceOverlay, Name: local}, findField(t, doc, "labels").Source)
+	assert.Equal(t, Source{Kind: SourceFile, Name: base}, findField(t, doc, "features").Source)
+
+	assert.Equal(t, doc, DescribeCurrent())
+}
+
+func TestRunConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFragment(t, dir, "app.toml", "appName = \"shop\"\n[database]\npassword = \"s3cret\"\n")
+	path := filepath.Join(dir, "app.toml")
+
+	ok, err := RunConfigCommand[describeConfig]([]string{"--port=1"}, io.Discard, path)
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	before := currentConfig.Load()
+	var out bytes.Buffer
+	ok, err = RunConfigCommand[describeConfig]([]string{"config", "describe"}, &out, path)
+	assert.True(t, ok)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out.String(), "PATH"))
+	assert.Regexp(t, `database\.password\s+"\*\*\*\*\*\*"\s+file \(app\.toml\)`, out.String())
+	assert.Same(t, before, currentConfig.Load(), "不替换当前配置")
+
+	out.Reset()
+	ok, err = RunConfigCommand[describeConfig]([]string{"config", "describe", "--json"}, &out, path)
+	assert.True(t, ok)
+	require.NoError(t, err)
+	var doc ConfigDoc
+	require.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, "shop", findField(t, doc, "appName").Value)
+
+	ok, err = RunConfigCommand[describeConfig]([]string{"config", "dump"}, io.Discard, path)
+	assert.True(t, ok)
+	assert.Error(t, err)
+	_, err = RunConfigCommand[describeConfig]([]string{"config", "describe"}, io.Discard, filepath.Join(dir, "missing.toml"))
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+}
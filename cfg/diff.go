@@ -0,0 +1,147 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change 一个配置项的变更
+//
+// Path 为 TOML 键路径（database.host、canary.routes.checkout.percent），
+// map 的新增/删除记录为整个值，Old 或 New 为 nil。
+// 敏感配置项只记录发生了变更（Sensitive 为 true），不记录 Old / New
+type Change struct {
+	Path            string `json:"path"`
+	Old             any    `json:"old,omitempty"`
+	New             any    `json:"new,omitempty"`
+	Sensitive       bool   `json:"sensitive,omitempty"`
+	RestartRequired bool   `json:"restartRequired"` // 只在启动时读取，修改后需要重启才生效
+}
+
+// String 便于日志展示，如 "port: 8080 → 9090（需要重启）"
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %v → %v", c.Path, c.Old, c.New)
+	if c.Sensitive {
+		s = c.Path + ": (已修改)"
+	}
+	if c.RestartRequired {
+		s += "（需要重启）"
+	}
+	return s
+}
+
+// Diff 计算两份配置之间的配置项级差异
+//
+// old / new 为同一类型的配置结构体或其指针（nil 按零值比较）。字段标签：
+//
+//	toml:"name"          路径中的键名（未设置时使用 Go 字段名，toml:"-" 的字段不比较）
+//	reload:"restart"     该配置项（含嵌套的全部配置项）只在启动时读取，修改需要重启
+//	reload:"hot"         覆盖外层的 reload:"restart"，该配置项支持热更新
+//	sensitive:"true"     只记录变更，不记录新旧值（嵌套结构体不再展开）
+//
+// 使用方式：
+//
+//	for _, c := range cfg.Diff(cfg.GetCfg[AppConfig](), candidate) {
+//	    fmt.Println(c)
+//	}
+func Diff(old, new any) []Change {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	switch {
+	case !ov.IsValid() && !nv.IsValid():
+		return nil
+	case !ov.IsValid():
+		ov = reflect.Zero(nv.Type())
+	case !nv.IsValid():
+		nv = reflect.Zero(ov.Type())
+	}
+	if ov.Type() != nv.Type() {
+		panic(fmt.Sprintf("cfg.Diff: 类型不一致 %s / %s", ov.Type(), nv.Type()))
+	}
+	var changes []Change
+	diffValue(&changes, "", indirect(ov), indirect(nv), false)
+	return changes
+}
+
+// indirect 解引用指针（nil 指针取零值）
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Zero(v.Type().Elem())
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func diffValue(changes *[]Change, path string, ov, nv reflect.Value, restart bool) {
+	switch {
+	case ov.Kind() == reflect.Struct && hasExportedFields(ov.Type()):
+		diffStruct(changes, path, ov, nv, restart)
+	case ov.Kind() == reflect.Map && ov.Type().Key().Kind() == reflect.String:
+		diffMap(changes, path, ov, nv, restart)
+	default:
+		if !reflect.DeepEqual(ov.Interface(), nv.Interface()) {
+			*changes = append(*changes, Change{Path: path, Old: ov.Interface(), New: nv.Interface(), RestartRequired: restart})
+		}
+	}
+}
+
+func diffStruct(changes *[]Change, path string, ov, nv reflect.Value, restart bool) {
+	walkFields(ov.Type(), func(f configField) {
+		fieldRestart := f.restartRequired(restart)
+		fo, fn := indirect(fieldByIndex(ov, f.Index)), indirect(fieldByIndex(nv, f.Index))
+		fieldPath := joinPath(path, f.Key)
+		if f.Field.Tag.Get("sensitive") == "true" {
+			if !reflect.DeepEqual(fo.Interface(), fn.Interface()) {
+				*changes = append(*changes, Change{Path: fieldPath, Sensitive: true, RestartRequired: fieldRestart})
+			}
+			return
+		}
+		diffValue(changes, fieldPath, fo, fn, fieldRestart)
+	})
+}
+
+func diffMap(changes *[]Change, path string, ov, nv reflect.Value, restart bool) {
+	keys := make(map[string]reflect.Value)
+	for _, k := range ov.MapKeys() {
+		keys[k.String()] = k
+	}
+	for _, k := range nv.MapKeys() {
+		keys[k.String()] = k
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		k := keys[name]
+		o, n := ov.MapIndex(k), nv.MapIndex(k)
+		switch {
+		case !o.IsValid():
+			*changes = append(*changes, Change{Path: joinPath(path, name), New: n.Interface(), RestartRequired: restart})
+		case !n.IsValid():
+			*changes = append(*changes, Change{Path: joinPath(path, name), Old: o.Interface(), RestartRequired: restart})
+		default:
+			diffValue(changes, joinPath(path, name), indirect(o), indirect(n), restart)
+		}
+	}
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
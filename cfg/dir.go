@@ -0,0 +1,257 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FragmentError 某个配置片段解析失败
+type FragmentError struct {
+	File string
+	Err  error
+}
+
+func (e *FragmentError) Error() string {
+	return fmt.Sprintf("配置片段 %s 解析失败: %v", e.File, e.Err)
+}
+
+func (e *FragmentError) Unwrap() error { return e.Err }
+
+// Validator 配置校验接口（配置结构体实现后，加载和热更新前都会调用，在 validate 标签校验之后执行）
+type Validator interface {
+	Validate() error
+}
+
+var currentMerged atomic.Pointer[MergedConfig]
+
+// LoadConfigDir 从目录加载多个配置片段（conf.d 风格）
+//
+// 按文件名字典序读取目录下所有 *.toml / *.json 片段并深度合并（后面的片段覆盖前面的），
+// 然后监听整个目录：片段新增、删除、修改时从头重新合并（删除片段即撤销它提供的键），
+// 校验通过后原子替换当前配置并触发 OnConfigChange 回调。
+// 任一片段解析失败或校验失败时保留之前的配置，错误中包含出错的片段名
+//
+// 以 . 开头的文件（编辑器临时文件等）会被忽略
+//
+// 使用方式：
+//
+//	// conf.d/10-database.toml, conf.d/20-web.toml, conf.d/30-features.toml
+//	if err := cfg.LoadConfigDir[AppConfig]("conf.d"); err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Print(cfg.EffectiveConfig().Dump())   // 查看每个键来自哪个片段
+func LoadConfigDir[T any](dir string) error {
+	_, err := openConfigDir[T](dir)
+	return err
+}
+
+// EffectiveConfig 当前生效的合并配置（未通过 LoadConfigDir / LoadConfigs 加载时返回 nil）
+func EffectiveConfig() *MergedConfig {
+	return currentMerged.Load()
+}
+
+// LastConfigDirError 最近一次重新合并的错误（LoadConfigDir / LoadConfigs，成功时为 nil）
+//
+// 与 LastError 相同，保留用于兼容
+func LastConfigDirError() error {
+	return LastError()
+}
+
+// configDir 配置目录监听
+type configDir[T any] struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	done    chan struct{} // 监听协程退出
+	mu      sync.Mutex    // 串行化重新合并
+	closed  bool
+
+	debounce debouncer // 去抖
+}
+
+func openConfigDir[T any](dir string) (*configDir[T], error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, dir)
+	}
+	d := &configDir[T]{dir: dir, done: make(chan struct{})}
+	cfg, merged, err := mergeConfigDir[T](dir)
+	if err != nil {
+		return nil, err
+	}
+	// 停止之前的监听，避免它的热更新覆盖新加载的配置
+	_ = Close()
+	storeSources(cfg, mergedSources(merged))
+	var anyCfg any = cfg
+	currentConfig.Store(&anyCfg)
+	currentMerged.Store(merged)
+	recordReload(nil)
+	configFile.Store(nil)
+	setConfigPath(dir)
+
+	d.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	if err := d.watcher.Add(dir); err != nil {
+		d.watcher.Close()
+		return nil, fmt.Errorf("添加目录监听失败: %w", err)
+	}
+	go d.watch()
+	setWatcher(d)
+	return d, nil
+}
+
+// Close 停止监听并等待监听协程退出（可重复调用）
+func (d *configDir[T]) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+	err := d.watcher.Close()
+	<-d.done
+	d.debounce.stop()
+	return err
+}
+
+// mergeConfigDir 读取并合并目录下的全部片段，解码为配置结构体并校验
+func mergeConfigDir[T any](dir string) (*T, *MergedConfig, error) {
+	files, err := configFragments(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged := newMergedConfig()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, &FragmentError{File: filepath.Base(file), Err: err}
+		}
+		tree, err := parseFragment(file, data)
+		if err != nil {
+			return nil, nil, &FragmentError{File: filepath.Base(file), Err: fmt.Errorf("%w: %w", ErrConfigInvalid, err)}
+		}
+		merged.Merge(filepath.Base(file), tree)
+	}
+
+	cfg, err := decodeMerged[T](merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, merged, nil
+}
+
+// decodeMerged 将合并结果解码为配置结构体并校验
+func decodeMerged[T any](merged *MergedConfig) (*T, error) {
+	var cfg T
+	if err := merged.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := applyFlagOverrides(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validate(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// configFragments 目录下按文件名排序的配置片段
+func configFragments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置目录失败: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !isFragment(e.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isFragment(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml", ".json":
+		return true
+	}
+	return false
+}
+
+// reload 去抖定时器触发的重新合并（错误只记录日志）
+func (d *configDir[T]) reload() {
+	err := d.load()
+	var fe *FragmentError
+	switch {
+	case err == nil || errors.Is(err, ErrReloadUnsupported):
+	case errors.As(err, &fe):
+		cfgLogger().Errorf("配置热更新失败（连续 %d 次），保留之前的配置（片段 %s）: %v", ReloadFailures(), fe.File, fe.Err)
+	default:
+		cfgLogger().Errorf("配置热更新失败（连续 %d 次），保留之前的配置: %v", ReloadFailures(), err)
+	}
+}
+
+// reloadNow 手动重新合并（见 Reload）：取消未触发的去抖定时器后立即执行
+func (d *configDir[T]) reloadNow() error {
+	d.debounce.stop()
+	return d.load()
+}
+
+// load 从头重新合并；失败时保留之前的配置
+func (d *configDir[T]) load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrReloadUnsupported
+	}
+
+	cfg, merged, err := mergeConfigDir[T](d.dir)
+	if err != nil {
+		recordReload(err)
+		return err
+	}
+	recordReload(nil)
+	currentMerged.Store(merged)
+	storeSources(cfg, mergedSources(merged))
+	applyConfig(cfg)
+	cfgLogger().Infof("配置已热更新（%d 个片段）", len(merged.Sources))
+	return nil
+}
+
+func (d *configDir[T]) watch() {
+	defer close(d.done)
+	for {
+		select {
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			if !isFragment(filepath.Base(event.Name)) || event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			d.debounce.trigger(d.reload)
+
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			cfgLogger().Errorf("配置目录监听错误: %s", err.Error())
+		}
+	}
+}
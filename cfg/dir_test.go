@@ -0,0 +1,155 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dirTestConfig struct {
+	Web struct {
+		Host string `toml:"host"`
+		Port int    `toml:"port"`
+	} `toml:"web"`
+	Database struct {
+		Host string `toml:"host"`
+		Pool int    `toml:"pool"`
+	} `toml:"database"`
+	Features map[string]bool `toml:"features"`
+}
+
+func (c *dirTestConfig) Validate() error {
+	if c.Web.Port < 0 {
+		return errors.New("web.port 不能为负数")
+	}
+	return nil
+}
+
+func writeFragment(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func newFragmentDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeFragment(t, dir, "20-web.toml", "[web]\nport = 8080\n[database]\npool = 20\n")
+	writeFragment(t, dir, "10-database.toml", "[database]\nhost = \"db.internal\"\npool = 5\n[web]\nhost = \"0.0.0.0\"\n")
+	writeFragment(t, dir, "30-features.json", `{"features": {"beta": true}, "web": {"port": 9090}}`)
+	writeFragment(t, dir, ".30-features.json.swp", "garbage")
+	writeFragment(t, dir, "notes.txt", "ignored")
+	return dir
+}
+
+func TestMergeConfigDir_OrderAndProvenance(t *testing.T) {
+	dir := newFragmentDir(t)
+	cfg, merged, err := mergeConfigDir[dirTestConfig](dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"10-database.toml", "20-web.toml", "30-features.json"}, merged.Sources)
+	assert.Equal(t, "db.internal", cfg.Database.Host)
+	assert.Equal(t, 20, cfg.Database.Pool) // 20-web.toml 覆盖 10-database.toml
+	assert.Equal(t, "0.0.0.0", cfg.Web.Host)
+	assert.Equal(t, 9090, cfg.Web.Port) // JSON 片段的数字按整数解码
+	assert.True(t, cfg.Features["beta"])
+
+	assert.Equal(t, map[string]string{
+		"database.host": "10-database.toml",
+		"database.pool": "20-web.toml",
+		"web.host":      "10-database.toml",
+		"web.port":      "30-features.json",
+		"features.beta": "30-features.json",
+	}, merged.Provenance)
+
+	dump := merged.Dump()
+	assert.Regexp(t, `database\.pool = 20\s+# 20-web\.toml`, dump)
+	assert.Regexp(t, `web\.host = "0\.0\.0\.0"\s+# 10-database\.toml`, dump)
+}
+
+func TestMergedConfig_TableReplacesScalar(t *testing.T) {
+	m := newMergedConfig()
+	m.Merge("a.toml", map[string]any{"log": "info", "db": map[string]any{"host": "x"}})
+	m.Merge("b.toml", map[string]any{"log": map[string]any{"level": "debug"}, "db": "disabled"})
+	assert.Equal(t, map[string]string{"log.level": "b.toml", "db": "b.toml"}, m.Provenance)
+}
+
+func TestConfigDir_HotReloadAndBrokenFragment(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+
+	dir := newFragmentDir(t)
+	d, err := openConfigDir[dirTestConfig](dir)
+	require.NoError(t, err)
+	defer d.Close()
+	assert.Equal(t, 9090, GetCfg[dirTestConfig]().Web.Port)
+
+	// 一个片段损坏：保留之前的配置，并指出是哪个片段
+	writeFragment(t, dir, "40-broken.toml", "[web\nport = ")
+	require.Eventually(t, func() bool { return LastConfigDirError() != nil }, 2*time.Second, 20*time.Millisecond)
+	var fe *FragmentError
+	require.ErrorAs(t, LastConfigDirError(), &fe)
+	assert.Equal(t, "40-broken.toml", fe.File)
+	assert.ErrorIs(t, LastConfigDirError(), ErrConfigInvalid)
+	assert.Equal(t, 9090, GetCfg[dirTestConfig]().Web.Port)
+	assert.Equal(t, "30-features.json", EffectiveConfig().Provenance["web.port"])
+
+	// 修复后重新合并
+	writeFragment(t, dir, "40-broken.toml", "[web]\nport = 7070\n")
+	require.Eventually(t, func() bool { return GetCfg[dirTestConfig]().Web.Port == 7070 }, 2*time.Second, 20*time.Millisecond)
+	assert.NoError(t, LastConfigDirError())
+
+	// 校验失败同样保留之前的配置
+	writeFragment(t, dir, "40-broken.toml", "[web]\nport = -1\n")
+	require.Eventually(t, func() bool { return LastConfigDirError() != nil }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 7070, GetCfg[dirTestConfig]().Web.Port)
+
+	// 删除片段：从头合并，撤销它提供的全部键
+	require.NoError(t, os.Remove(filepath.Join(dir, "40-broken.toml")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "30-features.json")))
+	require.Eventually(t, func() bool { return GetCfg[dirTestConfig]().Web.Port == 8080 }, 2*time.Second, 20*time.Millisecond)
+	assert.Nil(t, GetCfg[dirTestConfig]().Features)
+	assert.NotContains(t, EffectiveConfig().Provenance, "features.beta")
+	assert.Equal(t, "20-web.toml", EffectiveConfig().Provenance["web.port"])
+}
+
+func TestLoadConfigDir_InitialErrors(t *testing.T) {
+	err := LoadConfigDir[dirTestConfig](filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+
+	dir := t.TempDir()
+	writeFragment(t, dir, "10-ok.toml", "[web]\nport = 1\n")
+	writeFragment(t, dir, "20-bad.json", "{")
+	err = LoadConfigDir[dirTestConfig](dir)
+	var fe *FragmentError
+	require.ErrorAs(t, err, &fe)
+	assert.Equal(t, "20-bad.json", fe.File)
+}
+
+func TestConfigDir_ManualReload(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	dir := newFragmentDir(t)
+	require.NoError(t, LoadConfigDir[dirTestConfig](dir))
+	assert.Equal(t, dir, GetConfigPath())
+
+	writeFragment(t, dir, "40-broken.toml", "[web\nport = ")
+	err := Reload[dirTestConfig]()
+	var fe *FragmentError
+	require.ErrorAs(t, err, &fe)
+	assert.Equal(t, "40-broken.toml", fe.File)
+	assert.Equal(t, err, LastConfigDirError())
+	assert.Equal(t, 9090, GetCfg[dirTestConfig]().Web.Port)
+
+	writeFragment(t, dir, "40-broken.toml", "[web]\nport = 7070\n")
+	require.NoError(t, Reload[dirTestConfig]())
+	assert.Equal(t, 7070, GetCfg[dirTestConfig]().Web.Port)
+	assert.NoError(t, LastConfigDirError())
+}
@@ -0,0 +1,253 @@
+package cfg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvDecryptionKey 未调用 SetDecryptionKey 时读取密钥的环境变量（base64 编码的 16/24/32 字节 AES 密钥）
+const EnvDecryptionKey = "CONFIG_DECRYPTION_KEY"
+
+// encPrefix 加密值的格式：ENC(base64(nonce + 密文))
+const (
+	encPrefix = "ENC("
+	encSuffix = ")"
+)
+
+var (
+	// ErrDecryptionKeyMissing 配置中有加密值但没有设置解密密钥
+	ErrDecryptionKeyMissing = errors.New("config decryption key not set")
+	// ErrDecryptFailed 加密值格式错误或密钥不匹配
+	ErrDecryptFailed = errors.New("config value decryption failed")
+)
+
+var decryptionKey atomic.Pointer[[]byte]
+
+// SetDecryptionKey 设置配置加密值的 AES-GCM 密钥（16、24 或 32 字节），优先于环境变量 CONFIG_DECRYPTION_KEY
+//
+// 需要在加载配置之前调用；之后的热更新、Update 等同样使用该密钥解密。传入 nil 时恢复为读取环境变量
+//
+// 使用方式：
+//
+//	if err := cfg.SetDecryptionKey(key); err != nil {
+//	    log.Fatal(err)
+//	}
+//	err := cfg.LoadConfig[AppConfig]("config.toml")
+func SetDecryptionKey(key []byte) error {
+	if key == nil {
+		decryptionKey.Store(nil)
+		return nil
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("无效的配置解密密钥: %w", err)
+	}
+	k := bytes.Clone(key)
+	decryptionKey.Store(&k)
+	return nil
+}
+
+// currentKey 当前的解密密钥（SetDecryptionKey > 环境变量）
+func currentKey() ([]byte, error) {
+	if k := decryptionKey.Load(); k != nil {
+		return *k, nil
+	}
+	env := os.Getenv(EnvDecryptionKey)
+	if env == "" {
+		return nil, ErrDecryptionKeyMissing
+	}
+	key, err := base64.StdEncoding.DecodeString(env)
+	if err != nil {
+		return nil, fmt.Errorf("环境变量 %s 不是有效的 base64: %w", EnvDecryptionKey, err)
+	}
+	return key, nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := currentKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("无效的配置解密密钥: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt 用当前密钥加密一个配置值，返回可以直接写入配置文件的 ENC(...) 字符串
+//
+// 每次加密使用随机 nonce，同一明文的结果不同
+//
+// 使用方式：
+//
+//	_ = cfg.SetDecryptionKey(key)
+//	v, _ := cfg.Encrypt("db-password")
+//	fmt.Printf("password = %q\n", v)   // password = "ENC(3q2+7w...)"
+func Encrypt(plaintext string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed) + encSuffix, nil
+}
+
+// Decrypt 解密 Encrypt 生成的 ENC(...) 值，不是 ENC(...) 形式时原样返回
+//
+// 使用方式：
+//
+//	plain, err := cfg.Decrypt(v)
+func Decrypt(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	return decryptValue(value)
+}
+
+// isEncrypted 是否为 ENC(...) 形式的值
+func isEncrypted(s string) bool {
+	return strings.HasPrefix(s, encPrefix) && strings.HasSuffix(s, encSuffix)
+}
+
+// decryptValue 解密 ENC(...) 形式的值
+func decryptValue(s string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(s, encPrefix), encSuffix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("%w: 不是有效的密文", ErrDecryptFailed)
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: 密钥不匹配或密文被修改", ErrDecryptFailed)
+	}
+	return string(plain), nil
+}
+
+// decryptValues 在解析到结构体之前解密 TOML 中全部 ENC(...) 值，错误中包含配置项路径
+//
+// 不包含加密值时原样返回
+func decryptValues(data string) (string, error) {
+	if !strings.Contains(data, encPrefix) {
+		return data, nil
+	}
+	tree := make(map[string]any)
+	if _, err := toml.Decode(data, &tree); err != nil {
+		return "", err
+	}
+	changed := false
+	err := walkStrings(tree, "", func(path, s string) (string, error) {
+		if !isEncrypted(s) {
+			return s, nil
+		}
+		plain, err := decryptValue(s)
+		if err != nil {
+			return "", fmt.Errorf("配置项 %s 解密失败: %w", path, err)
+		}
+		changed = true
+		return plain, nil
+	})
+	if err != nil || !changed {
+		return data, err
+	}
+	out, err := encodeConfig(tree)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// keepEncrypted 重新编码配置时（UpdateCfg）保留原文件中的加密值：明文没有变化的配置项写回原来的 ENC(...)，
+// 避免解密后的密钥以明文写入文件
+func keepEncrypted(data, original []byte) ([]byte, error) {
+	if !bytes.Contains(original, []byte(encPrefix)) {
+		return data, nil
+	}
+	old := make(map[string]any)
+	if _, err := toml.Decode(string(original), &old); err != nil {
+		return data, nil
+	}
+	encrypted := make(map[string]string)
+	_ = walkStrings(old, "", func(path, s string) (string, error) {
+		if isEncrypted(s) {
+			encrypted[path] = s
+		}
+		return s, nil
+	})
+	if len(encrypted) == 0 {
+		return data, nil
+	}
+
+	tree := make(map[string]any)
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return nil, err
+	}
+	err := walkStrings(tree, "", func(path, s string) (string, error) {
+		enc, ok := encrypted[path]
+		if !ok {
+			return s, nil
+		}
+		if plain, err := decryptValue(enc); err == nil && plain == s {
+			return enc, nil
+		}
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return encodeConfig(tree)
+}
+
+// walkStrings 遍历 TOML 树中的字符串值（包括数组和表数组中的），用 fn 的返回值替换
+func walkStrings(tree map[string]any, prefix string, fn func(path, s string) (string, error)) error {
+	for k, v := range tree {
+		replaced, err := walkValue(v, joinPath(prefix, k), fn)
+		if err != nil {
+			return err
+		}
+		tree[k] = replaced
+	}
+	return nil
+}
+
+func walkValue(v any, path string, fn func(path, s string) (string, error)) (any, error) {
+	switch x := v.(type) {
+	case string:
+		return fn(path, x)
+	case map[string]any:
+		return x, walkStrings(x, path, fn)
+	case []map[string]any:
+		for i, m := range x {
+			if err := walkStrings(m, fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return nil, err
+			}
+		}
+		return x, nil
+	case []any:
+		for i, item := range x {
+			replaced, err := walkValue(item, fmt.Sprintf("%s[%d]", path, i), fn)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = replaced
+		}
+		return x, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,102 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encryptedConfig struct {
+	JWTSecret string `toml:"jwtSecret"`
+	Database  struct {
+		User     string `toml:"user"`
+		Password string `toml:"password"`
+	} `toml:"database"`
+	Replicas []struct {
+		Password string `toml:"password"`
+	} `toml:"replicas"`
+	Tokens []string `toml:"tokens"`
+}
+
+func setTestKey(t *testing.T, key []byte) {
+	require.NoError(t, SetDecryptionKey(key))
+	t.Cleanup(func() { _ = SetDecryptionKey(nil) })
+}
+
+func mustEncrypt(t *testing.T, plaintext string) string {
+	v, err := Encrypt(plaintext)
+	require.NoError(t, err)
+	return v
+}
+
+func TestEncrypt_DecryptedBeforeUnmarshal(t *testing.T) {
+	setTestKey(t, bytes.Repeat([]byte{7}, 32))
+
+	secret := mustEncrypt(t, "jwt-signing-secret")
+	assert.Regexp(t, `^ENC\([A-Za-z0-9+/=]+\)$`, secret)
+	assert.NotEqual(t, secret, mustEncrypt(t, "jwt-signing-secret"), "随机 nonce")
+
+	data := `
+jwtSecret = "` + secret + `"
+tokens = ["plain", "` + mustEncrypt(t, "t-2") + `"]
+[database]
+user = "app"
+password = "` + mustEncrypt(t, "db-password") + `"
+[[replicas]]
+password = "` + mustEncrypt(t, "replica-password") + `"
+`
+	var c encryptedConfig
+	require.NoError(t, unmarshal([]byte(data), &c))
+	assert.Equal(t, "jwt-signing-secret", c.JWTSecret)
+	assert.Equal(t, "app", c.Database.User)
+	assert.Equal(t, "db-password", c.Database.Password)
+	assert.Equal(t, "replica-password", c.Replicas[0].Password)
+	assert.Equal(t, []string{"plain", "t-2"}, c.Tokens)
+
+	// 严格解析：解密后重新编码不会引入未知键
+	strict, err := ParseStrict[encryptedConfig]([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, c, *strict)
+
+	plain, err := Decrypt(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt-signing-secret", plain)
+	plain, err = Decrypt("not-encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "not-encrypted", plain)
+}
+
+func TestEncrypt_FailuresReportKeyPath(t *testing.T) {
+	setTestKey(t, bytes.Repeat([]byte{1}, 32))
+	good := mustEncrypt(t, "db-password")
+	var c encryptedConfig
+
+	// 没有密钥
+	require.NoError(t, SetDecryptionKey(nil))
+	t.Setenv(EnvDecryptionKey, "")
+	err := unmarshal([]byte(`[database]
+password = "`+good+`"`), &c)
+	assert.ErrorIs(t, err, ErrDecryptionKeyMissing)
+	assert.ErrorContains(t, err, "database.password")
+
+	// 环境变量中的密钥不匹配
+	t.Setenv(EnvDecryptionKey, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))
+	err = unmarshal([]byte(`[[replicas]]
+password = "`+good+`"`), &c)
+	assert.ErrorIs(t, err, ErrDecryptFailed)
+	assert.ErrorContains(t, err, "replicas[0].password")
+
+	// 环境变量中的正确密钥
+	t.Setenv(EnvDecryptionKey, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	require.NoError(t, unmarshal([]byte(`jwtSecret = "`+good+`"`), &c))
+	assert.Equal(t, "db-password", c.JWTSecret)
+
+	err = unmarshal([]byte(`jwtSecret = "ENC(not base64!)"`), &c)
+	assert.ErrorIs(t, err, ErrDecryptFailed)
+	assert.ErrorContains(t, err, "jwtSecret")
+
+	assert.Error(t, SetDecryptionKey([]byte("short")))
+}
@@ -0,0 +1,77 @@
+package cfg
+
+import (
+	"reflect"
+	"strings"
+)
+
+// configField 配置结构体中的一个配置项
+//
+// 默认值、命令行参数、校验、差异、说明文档与脱敏都按同一套规则从结构体字段得到配置项：
+// 跳过未导出字段与 toml:"-"，键名取 toml 标签（未设置时为 Go 字段名），
+// 未设置键名的内嵌结构体（含指针，如内嵌的 web.Config）的字段提升到当前层级
+type configField struct {
+	Field  reflect.StructField
+	Key    string   // toml 键名
+	Index  []int    // 从所遍历的结构体到该字段的索引路径（经过内嵌结构体时多于一级）
+	reload []string // 内嵌链路上与字段自身的 reload 标签（外层在前）
+}
+
+// restartRequired 按 reload 标签（见 Diff）调整从外层继承的「需要重启」标记
+func (f configField) restartRequired(inherited bool) bool {
+	for _, tag := range f.reload {
+		switch tag {
+		case "restart":
+			inherited = true
+		case "hot":
+			inherited = false
+		}
+	}
+	return inherited
+}
+
+// walkFields 依次访问结构体类型 t 的配置项（t 为指针时访问其指向的结构体）
+func walkFields(t reflect.Type, visit func(f configField)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	walkStruct(t, nil, nil, visit)
+}
+
+func walkStruct(t reflect.Type, index []int, reload []string, visit func(f configField)) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(sf.Tag.Get("toml"), ",")
+		if key == "-" {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		tags := reload
+		if tag := sf.Tag.Get("reload"); tag != "" {
+			tags = append(append([]string(nil), reload...), tag)
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && key == "" && ft.Kind() == reflect.Struct {
+			walkStruct(ft, idx, tags, visit)
+			continue
+		}
+		if key == "" {
+			key = sf.Name
+		}
+		visit(configField{Field: sf, Key: key, Index: idx, reload: tags})
+	}
+}
+
+// fieldByIndex 按索引路径取字段（只读；途经的 nil 内嵌指针按零值处理）
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		v = indirect(v).Field(i)
+	}
+	return v
+}
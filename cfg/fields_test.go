@@ -0,0 +1,48 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type FieldsEmbedded struct {
+	Port  int `toml:"port"`
+	Debug bool
+}
+
+type FieldsRestart struct {
+	Workers int `toml:"workers"`
+	Level   int `toml:"level" reload:"hot"`
+}
+
+type fieldsTestConfig struct {
+	*FieldsEmbedded
+	FieldsRestart `reload:"restart"`
+	Named         FieldsEmbedded `toml:"named,omitempty"`
+	Skipped       string         `toml:"-"`
+	hidden        string
+}
+
+func TestWalkFields_KeysAndPromotion(t *testing.T) {
+	var keys []string
+	restart := map[string]bool{}
+	walkFields(reflect.TypeFor[*fieldsTestConfig](), func(f configField) {
+		keys = append(keys, f.Key)
+		restart[f.Key] = f.restartRequired(false)
+	})
+	assert.Equal(t, []string{"port", "Debug", "workers", "level", "named"}, keys,
+		"内嵌结构体（含指针）的字段提升，键名取 toml 标签，跳过 toml:\"-\" 与未导出字段")
+	assert.True(t, restart["workers"], "内嵌字段上的 reload 标签作用于提升的字段")
+	assert.False(t, restart["level"], "字段自身的 reload:\"hot\" 覆盖内嵌字段上的标签")
+
+	// 途经 nil 内嵌指针时按零值读取
+	v := reflect.ValueOf(fieldsTestConfig{})
+	walkFields(v.Type(), func(f configField) {
+		if f.Key == "port" {
+			assert.Equal(t, []int{0, 0}, f.Index)
+			assert.Equal(t, 0, fieldByIndex(v, f.Index).Interface())
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// includeKey 顶层的 include 键：引用其他配置文件（相对路径按声明它的文件所在目录解析）
+const includeKey = "include"
+
+// LoadConfigs 按顺序加载并合并多个配置文件（后面的文件覆盖前面的键）
+//
+// 表逐键深度合并，[web.database] 中只覆盖出现的键，同一张表的其他键保持不变。
+// 文件顶层可以声明 include = ["extra.toml"] 引用其他文件：被引用的文件紧接在声明它的文件之后合并
+// （覆盖它的键，再被后面的文件覆盖），相对路径按声明它的文件所在目录解析，可以嵌套，不能循环引用。
+// 参与合并的全部文件（包括 include 引用的）都会被监听，任一文件变化时从头重新合并，
+// 校验通过后原子替换当前配置并触发 OnConfigChange 回调；失败时保留之前的配置，错误见 LastConfigDirError
+//
+// 多文件配置不支持 Update 写回
+//
+// 使用方式：
+//
+//	// config.toml 为基础配置，config.local.toml 只写需要覆盖的键
+//	if err := cfg.LoadConfigs[AppConfig]("config.toml", "config.local.toml"); err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Print(cfg.EffectiveConfig().Dump())   // 查看每个键来自哪个文件
+func LoadConfigs[T any](paths ...string) error {
+	_, err := openConfigFiles[T](paths)
+	return err
+}
+
+// configFiles 多文件配置监听
+//
+// 监听的是文件所在的目录（编辑器保存时常见的「写临时文件再 rename」会让文件本身的监听失效），
+// 只处理参与合并的文件的事件
+type configFiles[T any] struct {
+	paths   []string
+	watcher *fsnotify.Watcher
+	done    chan struct{}   // 监听协程退出
+	mu      sync.Mutex      // 串行化重新合并，保护 files / dirs
+	files   map[string]bool // 参与合并的文件（绝对路径）
+	dirs    map[string]bool // 已监听的目录
+	closed  bool
+
+	debounce debouncer // 去抖
+}
+
+func openConfigFiles[T any](paths []string) (*configFiles[T], error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: 没有指定配置文件", ErrConfigNotFound)
+	}
+	cfg, merged, files, err := mergeConfigFiles[T](paths)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &configFiles[T]{paths: paths, dirs: make(map[string]bool), done: make(chan struct{})}
+	f.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	if err := f.watchFiles(files); err != nil {
+		f.watcher.Close()
+		return nil, err
+	}
+
+	// 停止之前的监听，避免它的热更新覆盖新加载的配置
+	_ = Close()
+	storeSources(cfg, mergedSources(merged))
+	var anyCfg any = cfg
+	currentConfig.Store(&anyCfg)
+	currentMerged.Store(merged)
+	recordReload(nil)
+	configFile.Store(nil)
+	setConfigPath(f.paths[0])
+
+	go f.watch()
+	setWatcher(f)
+	return f, nil
+}
+
+// Close 停止监听并等待监听协程退出（可重复调用）
+func (f *configFiles[T]) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+	err := f.watcher.Close()
+	<-f.done
+	f.debounce.stop()
+	return err
+}
+
+// watchFiles 更新参与合并的文件集合，并监听新出现的目录（已监听的目录不会移除）
+func (f *configFiles[T]) watchFiles(files []string) error {
+	f.files = make(map[string]bool, len(files))
+	for _, file := range files {
+		f.files[file] = true
+		dir := filepath.Dir(file)
+		if f.dirs[dir] {
+			continue
+		}
+		if err := f.watcher.Add(dir); err != nil {
+			return fmt.Errorf("添加目录监听失败: %w", err)
+		}
+		f.dirs[dir] = true
+	}
+	return nil
+}
+
+// mergeConfigFiles 按顺序读取并合并配置文件（展开 include），解码为配置结构体并校验
+//
+// 返回参与合并的全部文件的绝对路径
+func mergeConfigFiles[T any](paths []string) (*T, *MergedConfig, []string, error) {
+	merged := newMergedConfig()
+	var files []string
+	including := make(map[string]bool) // 当前 include 链上的文件（检测循环引用）
+
+	var load func(path string) error
+	load = func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return &FragmentError{File: path, Err: err}
+		}
+		if including[abs] {
+			return &FragmentError{File: path, Err: fmt.Errorf("%w: 循环 include", ErrConfigInvalid)}
+		}
+		data, err := os.ReadFile(abs)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrConfigNotFound, path)
+		}
+		if err != nil {
+			return &FragmentError{File: path, Err: err}
+		}
+		tree, err := parseFragment(abs, data)
+		if err != nil {
+			return &FragmentError{File: path, Err: fmt.Errorf("%w: %w", ErrConfigInvalid, err)}
+		}
+		includes, err := takeIncludes(tree)
+		if err != nil {
+			return &FragmentError{File: path, Err: fmt.Errorf("%w: %w", ErrConfigInvalid, err)}
+		}
+		merged.Merge(path, tree)
+		files = append(files, abs)
+
+		including[abs] = true
+		defer delete(including, abs)
+		for _, inc := range includes {
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(path), inc)
+			}
+			if err := load(inc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, path := range paths {
+		if err := load(path); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	cfg, err := decodeMerged[T](merged)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, merged, files, nil
+}
+
+// takeIncludes 取出并删除顶层的 include 键（必须是字符串数组）
+func takeIncludes(tree map[string]any) ([]string, error) {
+	raw, ok := tree[includeKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(tree, includeKey)
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, errors.New("include 必须是字符串数组")
+	}
+	includes := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, errors.New("include 必须是字符串数组")
+		}
+		includes[i] = s
+	}
+	return includes, nil
+}
+
+// hasInclude 配置文件顶层是否声明了 include（解析失败时返回 false，交给正常的加载流程报错）
+func hasInclude(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	tree, err := parseFragment(path, data)
+	if err != nil {
+		return false
+	}
+	_, ok := tree[includeKey]
+	return ok
+}
+
+// reload 去抖定时器触发的重新合并（错误只记录日志）
+func (f *configFiles[T]) reload() {
+	if err := f.load(); err != nil && !errors.Is(err, ErrReloadUnsupported) {
+		cfgLogger().Errorf("配置热更新失败（连续 %d 次），保留之前的配置: %v", ReloadFailures(), err)
+	}
+}
+
+// reloadNow 手动重新合并（见 Reload）：取消未触发的去抖定时器后立即执行
+func (f *configFiles[T]) reloadNow() error {
+	f.debounce.stop()
+	return f.load()
+}
+
+// load 从头重新合并；失败时保留之前的配置
+func (f *configFiles[T]) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrReloadUnsupported
+	}
+
+	cfg, merged, files, err := mergeConfigFiles[T](f.paths)
+	if err != nil {
+		recordReload(err)
+		return err
+	}
+	// include 可能发生变化：监听新引用的文件
+	if err := f.watchFiles(files); err != nil {
+		cfgLogger().Errorf("配置文件监听更新失败: %v", err)
+	}
+	recordReload(nil)
+	currentMerged.Store(merged)
+	storeSources(cfg, mergedSources(merged))
+	applyConfig(cfg)
+	cfgLogger().Infof("配置已热更新（%d 个文件）", len(merged.Sources))
+	return nil
+}
+
+func (f *configFiles[T]) watch() {
+	defer close(f.done)
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			f.mu.Lock()
+			tracked := f.files[filepath.Clean(event.Name)]
+			f.mu.Unlock()
+			if !tracked {
+				continue
+			}
+
+			f.debounce.trigger(f.reload)
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			cfgLogger().Errorf("配置文件监听错误: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type filesTestConfig struct {
+	AppName string `toml:"appName"`
+	Web     struct {
+		Port     int `toml:"port"`
+		Database struct {
+			Host string `toml:"host"`
+			User string `toml:"user"`
+			Pool int    `toml:"pool"`
+		} `toml:"database"`
+	} `toml:"web"`
+}
+
+func TestMergeConfigFiles_LaterFilesOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeFragment(t, dir, "config.toml", "appName = \"shop\"\n[web]\nport = 8080\n[web.database]\nhost = \"db.internal\"\nuser = \"app\"\npool = 5\n")
+	writeFragment(t, dir, "config.local.toml", "[web.database]\nhost = \"localhost\"\n")
+
+	cfg, merged, files, err := mergeConfigFiles[filesTestConfig]([]string{
+		filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.local.toml"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", cfg.Web.Database.Host)
+	// 嵌套表只覆盖出现的键
+	assert.Equal(t, "app", cfg.Web.Database.User)
+	assert.Equal(t, 5, cfg.Web.Database.Pool)
+	assert.Equal(t, 8080, cfg.Web.Port)
+	assert.Equal(t, "shop", cfg.AppName)
+	assert.Equal(t, filepath.Join(dir, "config.local.toml"), merged.Provenance["web.database.host"])
+	assert.Equal(t, filepath.Join(dir, "config.toml"), merged.Provenance["web.database.user"])
+	assert.Len(t, files, 2)
+}
+
+func TestMergeConfigFiles_Include(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf"), 0755))
+	writeFragment(t, dir, "config.toml", "include = [\"conf/db.toml\"]\n[web]\nport = 8080\n[web.database]\nhost = \"main\"\npool = 5\n")
+	writeFragment(t, dir, "conf/db.toml", "include = [\"pool.toml\"]\n[web.database]\nhost = \"included\"\n")
+	writeFragment(t, dir, "conf/pool.toml", "[web.database]\npool = 50\n")
+	writeFragment(t, dir, "config.local.toml", "[web]\nport = 9090\n")
+
+	cfg, merged, files, err := mergeConfigFiles[filesTestConfig]([]string{
+		filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.local.toml"),
+	})
+	require.NoError(t, err)
+	// include 的文件紧接在声明它的文件之后合并，相对路径按声明它的文件所在目录解析
+	assert.Equal(t, "included", cfg.Web.Database.Host)
+	assert.Equal(t, 50, cfg.Web.Database.Pool)
+	assert.Equal(t, 9090, cfg.Web.Port)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "config.toml"), filepath.Join(dir, "conf/db.toml"),
+		filepath.Join(dir, "conf/pool.toml"), filepath.Join(dir, "config.local.toml"),
+	}, files)
+	assert.NotContains(t, merged.Values, "include")
+}
+
+func TestMergeConfigFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.toml")
+
+	writeFragment(t, dir, "config.toml", "include = [\"missing.toml\"]\n")
+	_, _, _, err := mergeConfigFiles[filesTestConfig]([]string{main})
+	assert.ErrorIs(t, err, ErrConfigNotFound)
+
+	writeFragment(t, dir, "config.toml", "include = [\"a.toml\"]\n")
+	writeFragment(t, dir, "a.toml", "include = [\"config.toml\"]\n")
+	_, _, _, err = mergeConfigFiles[filesTestConfig]([]string{main})
+	assert.ErrorIs(t, err, ErrConfigInvalid)
+	assert.ErrorContains(t, err, "循环 include")
+
+	writeFragment(t, dir, "config.toml", "include = \"a.toml\"\n")
+	_, _, _, err = mergeConfigFiles[filesTestConfig]([]string{main})
+	var fe *FragmentError
+	require.ErrorAs(t, err, &fe)
+	assert.Equal(t, main, fe.File)
+}
+
+func TestLoadConfigs_HotReloadIncludedFile(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+
+	dir := t.TempDir()
+	writeFragment(t, dir, "config.toml", "include = [\"db.toml\"]\n[web]\nport = 8080\n")
+	writeFragment(t, dir, "db.toml", "[web.database]\nhost = \"a\"\nuser = \"app\"\n")
+	writeFragment(t, dir, "config.local.toml", "[web.database]\npool = 7\n")
+
+	f, err := openConfigFiles[filesTestConfig]([]string{filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.local.toml")})
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, "a", GetCfg[filesTestConfig]().Web.Database.Host)
+
+	changes := make(chan *filesTestConfig, 4)
+	OnConfigChange(func(c *filesTestConfig) { changes <- c })
+
+	// 修改被 include 的文件：从头重新合并后才触发回调
+	writeFragment(t, dir, "db.toml", "[web.database]\nhost = \"b\"\nuser = \"app\"\n")
+	select {
+	case c := <-changes:
+		assert.Equal(t, "b", c.Web.Database.Host)
+		assert.Equal(t, 7, c.Web.Database.Pool)
+		assert.Equal(t, 8080, c.Web.Port)
+	case <-time.After(2 * time.Second):
+		t.Fatal("修改 include 的文件后没有重新合并")
+	}
+
+	// 主文件新增 include：新引用的文件同样被监听
+	writeFragment(t, dir, "extra.toml", "appName = \"v1\"\n")
+	writeFragment(t, dir, "config.toml", "include = [\"db.toml\", \"extra.toml\"]\n[web]\nport = 8080\n")
+	require.Eventually(t, func() bool { return GetCfg[filesTestConfig]().AppName == "v1" }, 2*time.Second, 20*time.Millisecond)
+	writeFragment(t, dir, "extra.toml", "appName = \"v2\"\n")
+	require.Eventually(t, func() bool { return GetCfg[filesTestConfig]().AppName == "v2" }, 2*time.Second, 20*time.Millisecond)
+
+	// 解析失败时保留之前的配置
+	writeFragment(t, dir, "config.local.toml", "[web.database\n")
+	require.Eventually(t, func() bool { return LastConfigDirError() != nil }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 7, GetCfg[filesTestConfig]().Web.Database.Pool)
+}
+
+func TestLoadConfig_WithIncludeMerges(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+
+	dir := t.TempDir()
+	writeFragment(t, dir, "app.toml", "include = [\"app.db.toml\"]\n[web]\nport = 8080\n")
+	writeFragment(t, dir, "app.db.toml", "[web.database]\nhost = \"db.internal\"\n")
+
+	require.NoError(t, LoadConfig[filesTestConfig](filepath.Join(dir, "app.toml")))
+	assert.Equal(t, "db.internal", GetCfg[filesTestConfig]().Web.Database.Host)
+	assert.ErrorIs(t, Update[filesTestConfig]([]byte("[web]\nport = 1\n")), ErrUpdateUnsupported)
+}
@@ -0,0 +1,206 @@
+package cfg
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sync/atomic"
+)
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// flagBinding BindFlags 绑定的命令行参数（flag 名 -> 配置结构体中的字段路径）
+type flagBinding struct {
+	fs     *flag.FlagSet
+	typ    reflect.Type
+	fields map[string][]int
+}
+
+var boundFlags atomic.Pointer[flagBinding]
+
+// configFlag 自动注册的配置项参数：保存原始字符串，Set 时按字段类型校验
+type configFlag struct {
+	typ   reflect.Type
+	value string
+}
+
+func (f *configFlag) String() string { return f.value }
+
+func (f *configFlag) Set(s string) error {
+	if err := setDefault(reflect.New(f.typ).Elem(), s); err != nil {
+		return err
+	}
+	f.value = s
+	return nil
+}
+
+// IsBoolFlag 布尔配置项支持 --debug 简写
+func (f *configFlag) IsBoolFlag() bool { return f.typ.Kind() == reflect.Bool }
+
+// BindFlags 按配置结构体的 toml 标签注册命令行参数（嵌套表用点号连接，如 --web.port=9999）
+//
+// 在解析命令行之前调用；显式传入的参数在加载配置（InitConfig / LoadConfig 等）以及之后每次热更新时
+// 覆盖文件中的值，优先级：命令行参数 > 配置文件 > default 标签。
+// 支持字符串、数值、布尔、time.Duration、实现 encoding.TextUnmarshaler 的类型及其切片（逗号分隔）；
+// map 中的配置项不注册。已经手动声明的同名参数不会重复注册，显式传入时同样覆盖配置。
+// UpdateCfg 写回文件时，被参数覆盖且没有修改的配置项写回文件中原来的值
+//
+// 示例
+//
+//	cfg.BindFlags[AppConfig](flag.CommandLine)
+//	flag.Parse()
+//	cfg.InitConfig[AppConfig](defaultConfig)
+//
+//	// ./app --web.port=9999 --debug=false
+func BindFlags[T any](fs *flag.FlagSet) {
+	t := reflect.TypeFor[T]()
+	binding := &flagBinding{fs: fs, typ: t, fields: make(map[string][]int)}
+	collectFlagFields(t, "", nil, binding.fields)
+	for name, index := range binding.fields {
+		if fs.Lookup(name) != nil {
+			continue
+		}
+		ft := fieldType(t, index)
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fs.Var(&configFlag{typ: ft}, name, "覆盖配置项 "+name)
+	}
+	boundFlags.Store(binding)
+}
+
+// collectFlagFields 列出可以用命令行参数覆盖的字段
+func collectFlagFields(t reflect.Type, prefix string, index []int, out map[string][]int) {
+	walkFields(t, func(f configField) {
+		idx := append(append([]int(nil), index...), f.Index...)
+		ft := f.Field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		path := joinPath(prefix, f.Key)
+		switch {
+		case isFlagLeaf(ft):
+			out[path] = idx
+		case ft.Kind() == reflect.Struct:
+			collectFlagFields(ft, path, idx, out)
+		}
+	})
+}
+
+// isFlagLeaf setDefault 能够解析的字段类型
+func isFlagLeaf(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) || t == durationType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && t.Elem().Kind() != reflect.Struct && isFlagLeaf(t.Elem())
+	}
+	return false
+}
+
+func fieldType(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// flagField 按字段路径取得可设置的字段，沿途的 nil 指针按需分配
+func flagField(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// flagOverrides 显式传入的配置项参数（参数名 -> 值）；没有绑定或配置类型不同时为空
+func flagOverrides(cfg any) (*flagBinding, map[string]string) {
+	b := boundFlags.Load()
+	if b == nil || !b.fs.Parsed() || reflect.TypeOf(cfg) != reflect.PointerTo(b.typ) {
+		return nil, nil
+	}
+	set := make(map[string]string)
+	b.fs.Visit(func(f *flag.Flag) {
+		if _, ok := b.fields[f.Name]; ok {
+			set[f.Name] = f.Value.String()
+		}
+	})
+	return b, set
+}
+
+// applyFlagOverrides 用显式传入的命令行参数覆盖配置（在校验之前调用，加载与热更新都经过这里）
+func applyFlagOverrides(cfg any) error {
+	b, set := flagOverrides(cfg)
+	if len(set) == 0 {
+		return nil
+	}
+	root := reflect.ValueOf(cfg).Elem()
+	for name, value := range set {
+		if err := setDefault(flagField(root, b.fields[name]), value); err != nil {
+			return fmt.Errorf("%w: 命令行参数 --%s=%q 无效: %w", ErrConfigInvalid, name, value, err)
+		}
+	}
+	return nil
+}
+
+// restoreFlagged UpdateCfg 写回前把被命令行参数覆盖、且没有被修改的配置项恢复为文件中的值，
+// 避免一次性的参数被持久化到配置文件
+func restoreFlagged[T any](next, cur *T, path string) {
+	b, set := flagOverrides(next)
+	if len(set) == 0 {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var file T
+	if err := unmarshal(data, &file); err != nil {
+		return
+	}
+	nv, cv, fv := reflect.ValueOf(next).Elem(), reflect.ValueOf(cur).Elem(), reflect.ValueOf(&file).Elem()
+	for name := range set {
+		index := b.fields[name]
+		nf, ok1 := existingField(nv, index)
+		cf, ok2 := existingField(cv, index)
+		ff, ok3 := existingField(fv, index)
+		if ok1 && ok2 && ok3 && reflect.DeepEqual(nf.Interface(), cf.Interface()) {
+			nf.Set(ff)
+		}
+	}
+}
+
+// existingField 按字段路径取得字段，经过 nil 指针时返回 false
+func existingField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
@@ -0,0 +1,76 @@
+package cfg
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flagsWeb struct {
+	Port    int           `toml:"port"`
+	Origins []string      `toml:"origins"`
+	Timeout time.Duration `toml:"timeout" default:"5s"`
+	Secure  *bool         `toml:"secure"`
+	Labels  map[string]string
+}
+
+type flagsConfig struct {
+	AppName string   `toml:"appName"`
+	Debug   bool     `toml:"debug"`
+	Web     flagsWeb `toml:"web"`
+	Ignored string   `toml:"-"`
+}
+
+func bindTestFlags(t *testing.T, args ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Int("web.port", 8080, "手动声明的端口")
+	BindFlags[flagsConfig](fs)
+	t.Cleanup(func() { boundFlags.Store(nil) })
+	require.NoError(t, fs.Parse(args))
+	return fs
+}
+
+func TestBindFlags_RegistersDottedTomlPaths(t *testing.T) {
+	fs := bindTestFlags(t)
+	for _, name := range []string{"appName", "debug", "web.port", "web.origins", "web.timeout", "web.secure"} {
+		assert.NotNil(t, fs.Lookup(name), name)
+	}
+	assert.Nil(t, fs.Lookup("Ignored"))
+	assert.Nil(t, fs.Lookup("web.Labels"), "map 不注册")
+	assert.Equal(t, "手动声明的端口", fs.Lookup("web.port").Usage, "已声明的参数不重复注册")
+
+	fs = flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	BindFlags[flagsConfig](fs)
+	assert.Error(t, fs.Parse([]string{"--web.timeout=soon"}), "按字段类型校验")
+}
+
+func TestBindFlags_FlagsWinOverFileAndDefaults(t *testing.T) {
+	bindTestFlags(t, "--web.port=9999", "--debug=false", "--web.origins=https://a.example,https://b.example", "--web.timeout=1m", "--web.secure")
+
+	c, err := ParseStrict[flagsConfig]([]byte(`
+appName = "orders"
+debug = true
+[web]
+port = 8080
+origins = ["*"]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "orders", c.AppName, "没有传入的参数不覆盖文件")
+	assert.False(t, c.Debug)
+	assert.Equal(t, 9999, c.Web.Port, "手动声明的参数显式传入时同样生效")
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.Web.Origins)
+	assert.Equal(t, time.Minute, c.Web.Timeout, "覆盖 default 标签")
+	require.NotNil(t, c.Web.Secure)
+	assert.True(t, *c.Web.Secure)
+
+	// 其他配置类型不受影响
+	other, err := ParseStrict[TestConfig]([]byte("debug = true\n"))
+	require.NoError(t, err)
+	assert.True(t, other.Debug)
+}
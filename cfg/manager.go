@@ -5,41 +5,50 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
-	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/CenJIl/base/common"
-	"github.com/fsnotify/fsnotify"
 )
 
 const (
 	configFileName = "config.toml"
+
+	// EnvConfigPath 指定 InitConfig / InitConfigWithLogger 配置文件路径的环境变量
+	EnvConfigPath = "CONFIG_PATH"
 )
 
 var (
-	ErrConfigNotFound = errors.New("config file not found")
-	ErrConfigInvalid  = errors.New("config file is invalid")
+	ErrConfigNotFound     = errors.New("config file not found")
+	ErrConfigInvalid      = errors.New("config file is invalid")
+	ErrConfigNotLoaded    = errors.New("config not loaded")
+	ErrConfigTypeMismatch = errors.New("config type mismatch")
 )
 
 var (
 	initOnce       sync.Once
 	currentConfig  atomic.Pointer[any]
-	changeHandlers []func(any)
+	changeHandlers []changeHandler
+	handlerSeq     uint64
 	handlerMutex   sync.Mutex
-	cfgLog         common.Logger
+	cfgLog         atomic.Pointer[common.Logger] // SetLogger 设置，未设置时使用 common.DefaultLog
+
+	configFile atomic.Pointer[string] // 单文件模式的配置文件路径（Update 写回）
+	configPath atomic.Pointer[string] // 当前配置所在的路径（GetConfigPath）
 )
 
 // InitConfigWithLogger 使用指定的 Logger 初始化配置管理器
 //
 // 此函数会：
-// 1. 在可执行文件所在目录查找 config.toml
-// 2. 如果配置文件不存在，创建并写入默认配置
+// 1. 查找配置文件：环境变量 CONFIG_PATH 指定的路径，未设置时为可执行文件所在目录的 config.toml
+// 2. 如果配置文件不存在，创建所在目录并写入默认配置
 // 3. 如果配置文件存在，读取并解析
 // 4. 解析失败时使用内存中的默认值并记录错误
-// 5. 启动文件监听器，支持配置热更新
-// 6. 使用 sync.Once 确保只初始化一次
+// 5. 执行配置校验（validate 标签、RegisterValidator、Validator 接口），不通过时 panic 并列出全部错误
+// 6. 启动文件监听器，支持配置热更新（校验不通过的新配置被拒绝，保留之前的配置）
+// 7. 使用 sync.Once 确保只初始化一次（log 每次调用都会设置，等同于 SetLogger）
 //
 // 参数
 //
@@ -48,7 +57,7 @@ var (
 //
 // 注意事项
 //   - 初始化失败会直接 panic，确保配置正确后再调用
-//   - 配置文件名固定为 config.toml
+//   - 未设置 CONFIG_PATH 时配置文件名固定为 config.toml（go run 时可执行文件在临时目录，应设置 CONFIG_PATH 或使用 InitConfigAt）
 //   - 热更新失败不会影响程序运行，仅记录错误
 //   - 多次调用此函数，只有第一次生效（sync.Once 保证）
 //
@@ -66,57 +75,131 @@ var (
 //
 //	cfg.InitConfigWithLogger[AppConfig](defaultConfig, logger.GetLogger())
 func InitConfigWithLogger[T any](defaultConfigRaw []byte, log common.Logger) {
+	SetLogger(log)
+	InitConfig[T](defaultConfigRaw)
+}
+
+// SetLogger 设置配置管理器的日志记录器（热更新失败、监听错误等），可以在任何时候调用
+//
+// 与加载配置相互独立：LoadConfig / InitConfig 之前或之后调用都生效，之后的日志立即使用新的记录器。
+// 传入 nil 恢复为 common.DefaultLog
+//
+// 示例
+//
+//	cfg.SetLogger(logger.GetLogger())
+//	if err := cfg.LoadConfig[AppConfig]("config/config.toml"); err != nil {
+//	    log.Fatal(err)
+//	}
+func SetLogger(l common.Logger) {
+	if l == nil {
+		cfgLog.Store(nil)
+		return
+	}
+	cfgLog.Store(&l)
+}
+
+// cfgLogger 当前的日志记录器
+func cfgLogger() common.Logger {
+	if l := cfgLog.Load(); l != nil {
+		return *l
+	}
+	return defaultLog
+}
+
+// defaultLog 未调用 SetLogger 时使用的日志记录器
+var defaultLog common.Logger = &common.DefaultLog{}
+
+// InitConfigAt 使用指定路径的配置文件初始化配置管理器
+//
+// 与 InitConfig 相同（文件不存在时创建所在目录并写入默认配置、热更新、只初始化一次），
+// 只是配置文件路径由调用方指定，不依赖可执行文件所在目录。适用于 go run、
+// 镜像层只读的容器（把配置放在挂载的可写目录）和测试
+//
+// 参数
+//
+//	path - 配置文件路径
+//	defaultConfigRaw - 默认配置的 TOML 格式字节数组
+//
+// 示例
+//
+//	cfg.InitConfigAt[AppConfig]("/data/config/app.toml", defaultConfig)
+//	fmt.Println(cfg.GetConfigPath())   // /data/config/app.toml
+func InitConfigAt[T any](path string, defaultConfigRaw []byte) {
 	initOnce.Do(func() {
-		cfgLog = log
-		var cfg T
+		initConfigFile[T](path, defaultConfigRaw)
+	})
+}
 
-		exePath, err := os.Executable()
-		if err != nil {
-			panic("获取可执行文件路径失败: " + err.Error())
-		}
-		configFilePath := filepath.Join(filepath.Dir(exePath), configFileName)
+// defaultConfigPath InitConfig 使用的配置文件路径（环境变量 CONFIG_PATH > 可执行文件所在目录的 config.toml）
+func defaultConfigPath() (string, error) {
+	if p := os.Getenv(EnvConfigPath); p != "" {
+		return p, nil
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+	return filepath.Join(filepath.Dir(exePath), configFileName), nil
+}
 
-		if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
-			cfgLog.Infof("配置文件不存在，写入默认配置: %s", configFilePath)
-			if err := os.WriteFile(configFilePath, defaultConfigRaw, 0644); err != nil {
-				panic("创建配置文件失败: " + err.Error())
-			}
-			if err := toml.Unmarshal(defaultConfigRaw, &cfg); err != nil {
+// initConfigFile 加载配置文件（不存在时写入默认配置）并启动监听；失败时 panic
+func initConfigFile[T any](configFilePath string, defaultConfigRaw []byte) {
+	var cfg T
+	var sources map[string]Source
+
+	if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
+		cfgLogger().Infof("配置文件不存在，写入默认配置: %s", configFilePath)
+		if err := os.MkdirAll(filepath.Dir(configFilePath), 0755); err != nil {
+			panic("创建配置目录失败: " + err.Error())
+		}
+		if err := os.WriteFile(configFilePath, defaultConfigRaw, 0644); err != nil {
+			panic("创建配置文件失败: " + err.Error())
+		}
+		if err := unmarshal(defaultConfigRaw, &cfg); err != nil {
+			panic("配置初始化失败: " + err.Error())
+		}
+		sources = fileSources(configFilePath, defaultConfigRaw, SourceFile)
+	} else {
+		data, err := os.ReadFile(configFilePath)
+		if err != nil {
+			cfgLogger().Errorf("读取配置文件失败，使用内存默认值")
+			if err := unmarshal(defaultConfigRaw, &cfg); err != nil {
 				panic("配置初始化失败: " + err.Error())
 			}
+			sources = fileSources("", defaultConfigRaw, SourceDefault)
+		} else if err := unmarshal(data, &cfg); err != nil {
+			cfgLogger().Errorf("配置解析失败，使用内存默认值")
+			_ = unmarshal(defaultConfigRaw, &cfg)
+			sources = fileSources("", defaultConfigRaw, SourceDefault)
 		} else {
-			data, err := os.ReadFile(configFilePath)
-			if err != nil {
-				cfgLog.Errorf("读取配置文件失败，使用内存默认值")
-				if err := toml.Unmarshal(defaultConfigRaw, &cfg); err != nil {
-					panic("配置初始化失败: " + err.Error())
-				}
-			} else if err := toml.Unmarshal(data, &cfg); err != nil {
-				cfgLog.Errorf("配置解析失败，使用内存默认值")
-				_ = toml.Unmarshal(defaultConfigRaw, &cfg)
-			}
-		}
-
-		var anyCfg any = &cfg
-		currentConfig.Store(&anyCfg)
-
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			panic("创建文件监听失败: " + err.Error())
+			sources = fileSources(configFilePath, data, SourceFile)
 		}
+	}
+	if err := applyFlagOverrides(&cfg); err != nil {
+		panic("配置校验失败: " + err.Error())
+	}
+	if err := validate(&cfg); err != nil {
+		panic("配置校验失败: " + err.Error())
+	}
 
-		if err := watcher.Add(configFilePath); err != nil {
-			watcher.Close()
-			panic("添加文件监听失败: " + err.Error())
-		}
+	_ = Close()
+	storeSources(&cfg, sources)
+	var anyCfg any = &cfg
+	currentConfig.Store(&anyCfg)
+	recordReload(nil)
 
-		go watchConfig[T](watcher, configFilePath)
-	})
+	w, err := watchConfigFile[T](configFilePath)
+	if err != nil {
+		panic("启动文件监听失败: " + err.Error())
+	}
+	configFile.Store(&configFilePath)
+	setConfigPath(configFilePath)
+	setWatcher(w)
 }
 
 // InitConfig 使用默认日志记录器初始化配置管理器
 //
-// 这是 InitConfigWithLogger 的简化版本，内部使用 common.DefaultLog 作为日志记录器
+// 这是 InitConfigWithLogger 的简化版本，不设置日志记录器（使用 SetLogger 设置的，默认 common.DefaultLog）
 // 适用于不需要自定义日志记录器的场景
 //
 // 参数
@@ -127,13 +210,20 @@ func InitConfigWithLogger[T any](defaultConfigRaw []byte, log common.Logger) {
 //
 //	cfg.InitConfig[AppConfig](defaultConfig)
 func InitConfig[T any](defaultConfigRaw []byte) {
-	InitConfigWithLogger[T](defaultConfigRaw, &common.DefaultLog{})
+	initOnce.Do(func() {
+		configFilePath, err := defaultConfigPath()
+		if err != nil {
+			panic(err.Error())
+		}
+		initConfigFile[T](configFilePath, defaultConfigRaw)
+	})
 }
 
 // LoadConfig 从指定路径加载配置（Web 脚手架模式）
 //
 // 直接读取文件，如果文件不存在则返回错误
-// 不会创建任何文件
+// 不会创建任何文件。校验不通过时返回包在 ErrConfigInvalid 中的 *ValidationError，列出全部不合法的配置项。
+// 文件顶层声明了 include 时按 LoadConfigs 合并加载（此时不支持 Update 写回）
 //
 // 适用场景：
 //   - Web 应用
@@ -146,7 +236,7 @@ func InitConfig[T any](defaultConfigRaw []byte) {
 //
 // 返回值
 //
-//	error - 文件不存在或解析失败时返回错误
+//	error - 文件不存在、解析失败或校验不通过时返回错误
 //
 // 示例
 //
@@ -159,6 +249,9 @@ func LoadConfig[T any](configPath string) error {
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return fmt.Errorf("%w: %s", ErrConfigNotFound, configPath)
 	}
+	if hasInclude(configPath) {
+		return LoadConfigs[T](configPath)
+	}
 
 	return loadConfig[T](configPath)
 }
@@ -172,35 +265,56 @@ func loadConfig[T any](configPath string) error {
 	}
 
 	var cfg T
-	if err := toml.Unmarshal(data, &cfg); err != nil {
-		return fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	if err := unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrConfigInvalid, configPath, err)
+	}
+	if err := applyFlagOverrides(&cfg); err != nil {
+		return err
+	}
+	if err := validate(&cfg); err != nil {
+		return err
 	}
 
+	// 停止之前的监听，避免它的热更新覆盖新加载的配置
+	_ = Close()
+	storeSources(&cfg, fileSources(configPath, data, SourceFile))
 	var anyCfg any = &cfg
 	currentConfig.Store(&anyCfg)
+	recordReload(nil)
 
-	// 启动文件监听（支持热更新）
-	watcher, err := fsnotify.NewWatcher()
+	// 启动文件监听（支持热更新，Close 停止）
+	w, err := watchConfigFile[T](configPath)
 	if err != nil {
-		return fmt.Errorf("创建文件监听失败: %w", err)
+		return fmt.Errorf("启动文件监听失败: %w", err)
 	}
+	configFile.Store(&configPath)
+	setConfigPath(configPath)
+	setWatcher(w)
+	return nil
+}
 
-	if err := watcher.Add(configPath); err != nil {
-		watcher.Close()
-		return fmt.Errorf("添加文件监听失败: %w", err)
+// GetConfigPath 当前配置实际所在的路径（绝对路径）
+//
+// InitConfig / InitConfigAt / LoadConfig 为配置文件，LoadConfigs 为第一个（基础）配置文件，
+// LoadConfigDir 为配置目录；未加载配置时返回空字符串
+//
+// 示例
+//
+//	cfg.InitConfig[AppConfig](defaultConfig)
+//	logger.Infof("配置文件: %s", cfg.GetConfigPath())
+func GetConfigPath() string {
+	if p := configPath.Load(); p != nil {
+		return *p
 	}
+	return ""
+}
 
-	// 使用 InitConfig 的 initOnce，确保只初始化一次
-	initOnce.Do(func() {
-		// 设置默认日志器（如果用户没有通过 InitConfigWithLogger 设置）
-		if cfgLog == nil {
-			cfgLog = &common.DefaultLog{}
-		}
-	})
-
-	go watchConfig[T](watcher, configPath)
-
-	return nil
+// setConfigPath 记录当前配置所在的路径（转换为绝对路径）
+func setConfigPath(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	configPath.Store(&path)
 }
 
 // GetCfg 获取当前配置的指针
@@ -214,7 +328,8 @@ func loadConfig[T any](configPath string) error {
 //
 // 注意事项
 //   - 返回的指针指向配置的当前状态，配置热更新后需要重新调用获取最新值
-//   - 如果未调用 InitConfig/LoadConfig 初始化，返回零值指针
+//   - 如果未调用 InitConfig/LoadConfig 初始化，返回零值指针（需要及早发现初始化顺序问题时使用 MustGetCfg）
+//   - T 与加载时的类型不同时 panic，错误信息给出两个类型
 //   - 此方法不复制配置，直接返回内部指针，不要修改返回值的内容
 //
 // 示例
@@ -226,7 +341,60 @@ func GetCfg[T any]() *T {
 	if p == nil {
 		return new(T)
 	}
-	return (*p).(*T)
+	cfg, ok := (*p).(*T)
+	if !ok {
+		panic(typeMismatch[T](*p))
+	}
+	return cfg
+}
+
+// GetCfgE 获取当前配置的指针，未加载或类型不符时返回错误
+//
+// 未调用 InitConfig / LoadConfig 等加载配置时返回 ErrConfigNotLoaded，
+// T 与加载时的类型不同时返回 ErrConfigTypeMismatch，错误信息说明如何修正
+//
+// 示例
+//
+//	config, err := cfg.GetCfgE[AppConfig]()
+//	if err != nil {
+//	    return err
+//	}
+func GetCfgE[T any]() (*T, error) {
+	p := currentConfig.Load()
+	if p == nil {
+		return nil, fmt.Errorf("%w: 读取 %T 之前需要先调用 cfg.InitConfig / cfg.LoadConfig（或 web.NewServer）加载配置", ErrConfigNotLoaded, new(T))
+	}
+	cfg, ok := (*p).(*T)
+	if !ok {
+		return nil, typeMismatch[T](*p)
+	}
+	return cfg, nil
+}
+
+// MustGetCfg 获取当前配置的指针，未加载或类型不符时 panic
+//
+// 与 GetCfg 不同，不会在未加载时返回零值（零值会让问题在之后以「端口为 0」等形式出现，难以定位），
+// panic 信息指出缺少的加载调用或两个不同的类型
+//
+// 示例
+//
+//	config := cfg.MustGetCfg[AppConfig]()
+func MustGetCfg[T any]() *T {
+	cfg, err := GetCfgE[T]()
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// IsInitialized 是否已经加载了配置（InitConfig / LoadConfig / LoadConfigs / LoadConfigDir）
+func IsInitialized() bool {
+	return currentConfig.Load() != nil
+}
+
+// typeMismatch 读取的类型与加载的类型不同
+func typeMismatch[T any](loaded any) error {
+	return fmt.Errorf("%w: 读取的是 %T，加载的是 %T（GetCfg 的类型参数需与 LoadConfig / InitConfig 一致）", ErrConfigTypeMismatch, new(T), loaded)
 }
 
 // OnConfigChange 注册配置变更回调函数
@@ -242,8 +410,10 @@ func GetCfg[T any]() *T {
 // 注意事项
 //   - 回调函数在独立 goroutine 中执行，需要自行处理并发安全
 //   - 回调函数中不应执行耗时操作，避免阻塞
-//   - 回调函数执行失败不会影响其他回调
-//   - 配置解析失败时，回调函数不会被调用
+//   - 回调函数执行失败（包括 panic）不会影响其他回调和文件监听
+//   - 需要对比变更前的配置时使用 OnConfigDiff
+//   - 配置解析失败或校验不通过时，回调函数不会被调用
+//   - 返回的 stop 用于注销回调（组件重启时避免回调累积），注销前已经开始执行的回调不会被中断
 //
 // 示例
 //
@@ -251,63 +421,131 @@ func GetCfg[T any]() *T {
 //	    logger.Infof("配置已更新: %+v", newCfg)
 //	    // 执行配置变更后的逻辑
 //	})
-func OnConfigChange[T any](h func(cfg *T)) {
+//
+//	// 组件停止时注销（返回的函数可重复调用）
+//	stop := cfg.OnConfigChange(func(newCfg *AppConfig) { pool.Resize(newCfg.Pool.Size) })
+//	defer stop()
+func OnConfigChange[T any](h func(cfg *T)) (stop func()) {
+	return OnConfigDiff(func(_, newCfg *T) { h(newCfg) })
+}
+
+// OnConfigDiff 注册配置变更回调函数，同时传入变更前后的配置
+//
+// oldCfg 为替换前的配置快照，回调据此判断哪些配置项真正发生了变化（如端口变化才重启监听、
+// 地址变化才重连 Redis）。执行方式与 OnConfigChange 相同：每个回调在独立的 goroutine 中执行，
+// 回调 panic 只记录错误，不影响其他回调和文件监听。返回的函数用于注销回调
+//
+// 参数
+//
+//	h - 配置变更时的回调函数，oldCfg 为变更前的配置（之前没有同类型的配置时为 nil），newCfg 为新配置
+//
+// 示例
+//
+//	cfg.OnConfigDiff(func(oldCfg, newCfg *AppConfig) {
+//	    if oldCfg.Redis.Addr != newCfg.Redis.Addr {
+//	        reconnectRedis(newCfg.Redis)
+//	    }
+//	})
+func OnConfigDiff[T any](h func(oldCfg, newCfg *T)) (stop func()) {
+	return addChangeHandler(func(oldRaw, newRaw any) {
+		// 只处理同类型的配置（同一进程内可能先后加载不同类型的配置）
+		newCfg, ok := newRaw.(*T)
+		if !ok {
+			return
+		}
+		oldCfg, _ := oldRaw.(*T)
+		h(oldCfg, newCfg)
+	})
+}
+
+// OnFieldChange 注册配置变更回调函数，只有 selector 选出的值变化时才触发
+//
+// 每次重新加载成功后，分别对变更前后的配置调用 selector，结果用 reflect.DeepEqual 比较，
+// 不同时才调用 h。可以多次注册，各个 selector 独立判断；同时关心多个字段时让 selector
+// 返回一个组合值（如 [2]any{c.Host, c.Port}）。执行方式与 OnConfigDiff 相同；
+// 之前没有同类型的配置时没有可比较的旧值，不触发。返回的函数用于注销回调
+//
+// 示例
+//
+//	cfg.OnFieldChange(func(c *AppConfig) any { return c.Web.Port }, func(oldCfg, newCfg *AppConfig) {
+//	    restartListener(newCfg.Web.Port)
+//	})
+func OnFieldChange[T any](selector func(cfg *T) any, h func(oldCfg, newCfg *T)) (stop func()) {
+	return OnConfigDiff(func(oldCfg, newCfg *T) {
+		if oldCfg == nil || reflect.DeepEqual(selector(oldCfg), selector(newCfg)) {
+			return
+		}
+		h(oldCfg, newCfg)
+	})
+}
+
+// changeHandler 已注册的变更回调（id 用于注销）
+type changeHandler struct {
+	id uint64
+	fn func(oldCfg, newCfg any)
+}
+
+// addChangeHandler 注册变更回调，返回注销函数
+//
+// 注销时复制列表后再删除，正在分发的回调快照不受影响；已经开始执行的回调不会被中断
+func addChangeHandler(fn func(oldCfg, newCfg any)) func() {
 	handlerMutex.Lock()
 	defer handlerMutex.Unlock()
-	changeHandlers = append(changeHandlers, func(raw any) {
-		h(raw.(*T))
-	})
+	handlerSeq++
+	id := handlerSeq
+	changeHandlers = append(changeHandlers, changeHandler{id: id, fn: fn})
+	return func() {
+		handlerMutex.Lock()
+		defer handlerMutex.Unlock()
+		changeHandlers = slices.DeleteFunc(slices.Clone(changeHandlers), func(h changeHandler) bool { return h.id == id })
+	}
 }
 
-func watchConfig[T any](watcher *fsnotify.Watcher, configFilePath string) {
-	var (
-		timer    *time.Timer
-		timerMu  sync.Mutex
-		debounce = 100 * time.Millisecond
-	)
-
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Write != fsnotify.Write {
-				continue
-			}
+// ResetForTest 清空变更回调、当前配置与重新加载状态，供单元测试相互隔离
+//
+// 只用于测试：不会停止已启动的文件监听，InitConfig 系列函数仍然只初始化一次
+//
+// 示例
+//
+//	func TestReload(t *testing.T) {
+//	    t.Cleanup(cfg.ResetForTest)
+//	    stop := cfg.OnConfigChange(func(c *AppConfig) { ... })
+//	    defer stop()
+//	}
+func ResetForTest() {
+	handlerMutex.Lock()
+	changeHandlers = nil
+	handlerMutex.Unlock()
 
-			timerMu.Lock()
-			if timer != nil {
-				timer.Stop()
-			}
-			timer = time.AfterFunc(debounce, func() {
-				data, err := os.ReadFile(configFilePath)
-				if err != nil {
-					cfgLog.Errorf("配置热更新读取失败: %v", err)
-					return
-				}
-				var cfg T
-				if err := toml.Unmarshal(data, &cfg); err != nil {
-					cfgLog.Errorf("配置热更新解析失败: %v", err)
-					return
-				}
-				var anyCfg any = &cfg
-				currentConfig.Store(&anyCfg)
-
-				handlerMutex.Lock()
-				for _, h := range changeHandlers {
-					go h(anyCfg)
-				}
-				handlerMutex.Unlock()
-				cfgLog.Infof("配置已热更新")
-			})
-			timerMu.Unlock()
-
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			cfgLog.Errorf("配置监听错误: %s", err.Error())
-		}
+	currentConfig.Store(nil)
+	configFile.Store(nil)
+	configPath.Store(nil)
+	lastReloadErr.Store(nil)
+	reloadFailures.Store(0)
+}
+
+// applyConfig 替换当前配置并异步触发变更回调（回调收到替换前的配置快照）
+func applyConfig(cfg any) {
+	var old any
+	if p := currentConfig.Swap(&cfg); p != nil {
+		old = *p
 	}
+
+	// 在锁内取快照，回调执行期间注册或注销不影响本次分发
+	handlerMutex.Lock()
+	handlers := changeHandlers
+	handlerMutex.Unlock()
+	for _, h := range handlers {
+		go runChangeHandler(h.fn, old, cfg)
+	}
+}
+
+// runChangeHandler 执行一个变更回调（panic 只记录错误）
+func runChangeHandler(h func(oldCfg, newCfg any), oldCfg, newCfg any) {
+	defer func() {
+		if r := recover(); r != nil {
+			cfgLogger().Errorf("配置变更回调 panic: %v", r)
+		}
+	}()
+	h(oldCfg, newCfg)
 }
@@ -1,10 +1,18 @@
 package cfg
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestConfig struct {
@@ -229,3 +237,293 @@ debug = true`)
 		})
 	}
 }
+
+type diffTestConfig struct {
+	Port  int    `toml:"port"`
+	Redis string `toml:"redis"`
+}
+
+func TestOnConfigDiff_PassesOldAndNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("port = 8080\nredis = \"r1\"\n"), 0644))
+	require.NoError(t, LoadConfig[diffTestConfig](path))
+	first := GetCfg[diffTestConfig]()
+
+	type change struct{ old, new *diffTestConfig }
+	changes := make(chan change, 4)
+	OnConfigDiff(func(oldCfg, newCfg *diffTestConfig) {
+		if oldCfg.Port == 8080 && newCfg.Port == 9090 {
+			panic("boom") // 回调 panic 不影响文件监听和其他回调
+		}
+	})
+	OnConfigDiff(func(oldCfg, newCfg *diffTestConfig) { changes <- change{oldCfg, newCfg} })
+	var single atomic.Int32
+	OnConfigChange(func(c *diffTestConfig) { single.Add(1) })
+
+	require.NoError(t, os.WriteFile(path, []byte("port = 9090\nredis = \"r1\"\n"), 0644))
+	var c change
+	select {
+	case c = <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有收到变更回调")
+	}
+	assert.Same(t, first, c.old, "old 为替换前 currentConfig 中的配置")
+	assert.Equal(t, 8080, c.old.Port)
+	assert.Equal(t, 9090, c.new.Port)
+	assert.Same(t, GetCfg[diffTestConfig](), c.new)
+
+	require.NoError(t, os.WriteFile(path, []byte("port = 9090\nredis = \"r2\"\n"), 0644))
+	select {
+	case c = <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("回调 panic 后文件监听不应停止")
+	}
+	assert.Equal(t, "r1", c.old.Redis)
+	assert.Equal(t, "r2", c.new.Redis)
+	assert.Eventually(t, func() bool { return single.Load() == 2 }, time.Second, 10*time.Millisecond, "单参数回调保持兼容")
+}
+
+func TestDefaultConfigPath_Env(t *testing.T) {
+	t.Setenv(EnvConfigPath, "")
+	path, err := defaultConfigPath()
+	require.NoError(t, err)
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(exe), "config.toml"), path)
+
+	t.Setenv(EnvConfigPath, "/etc/app/app.toml")
+	path, err = defaultConfigPath()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/app/app.toml", path)
+}
+
+func TestInitConfigFile_CreatesDirectoryAndWatchesResolvedPath(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "data", "config", "app.toml")
+	initConfigFile[TestConfig](path, []byte("appName = \"Defaults\"\nport = 8080\n"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "不存在的目录被创建，写入默认配置")
+	assert.Contains(t, string(data), "Defaults")
+	assert.Equal(t, path, GetConfigPath())
+	assert.Equal(t, "Defaults", GetCfg[TestConfig]().AppName)
+
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"Edited\"\nport = 8080\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "Edited" }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestGetCfgE_NotLoadedAndTypeMismatch(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+
+	currentConfig.Store(nil)
+	assert.False(t, IsInitialized())
+	_, err := GetCfgE[TestConfig]()
+	assert.ErrorIs(t, err, ErrConfigNotLoaded)
+	assert.Contains(t, err.Error(), "LoadConfig")
+	assert.Equal(t, &TestConfig{}, GetCfg[TestConfig](), "GetCfg 保持返回零值")
+	assert.PanicsWithError(t, err.Error(), func() { MustGetCfg[TestConfig]() })
+
+	var loaded any = &TestConfig{AppName: "app"}
+	currentConfig.Store(&loaded)
+	assert.True(t, IsInitialized())
+	c, err := GetCfgE[TestConfig]()
+	require.NoError(t, err)
+	assert.Equal(t, "app", c.AppName)
+	assert.Same(t, c, MustGetCfg[TestConfig]())
+
+	_, err = GetCfgE[diffTestConfig]()
+	assert.ErrorIs(t, err, ErrConfigTypeMismatch)
+	assert.Contains(t, err.Error(), "*cfg.diffTestConfig")
+	assert.Contains(t, err.Error(), "*cfg.TestConfig")
+	assert.PanicsWithError(t, err.Error(), func() { GetCfg[diffTestConfig]() })
+	assert.PanicsWithError(t, err.Error(), func() { MustGetCfg[diffTestConfig]() })
+}
+
+type fieldTestConfig struct {
+	Host string   `toml:"host"`
+	Port int      `toml:"port"`
+	Tags []string `toml:"tags"`
+}
+
+func TestOnFieldChange_OnlyWhenSelectedValueDiffers(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+	currentConfig.Store(nil)
+
+	ports := make(chan [2]int, 4)
+	OnFieldChange(func(c *fieldTestConfig) any { return c.Port }, func(oldCfg, newCfg *fieldTestConfig) {
+		ports <- [2]int{oldCfg.Port, newCfg.Port}
+	})
+	tags := make(chan []string, 4)
+	OnFieldChange(func(c *fieldTestConfig) any { return c.Tags }, func(_, newCfg *fieldTestConfig) {
+		tags <- newCfg.Tags
+	})
+	expectNone := func(msg string) {
+		select {
+		case <-ports:
+			t.Fatal(msg)
+		case <-tags:
+			t.Fatal(msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	applyConfig(&fieldTestConfig{Host: "a", Port: 8080, Tags: []string{"x"}})
+	expectNone("首次加载没有旧值，不触发")
+
+	applyConfig(&fieldTestConfig{Host: "b", Port: 8080, Tags: []string{"x"}})
+	expectNone("只有未选中的字段变化，不触发")
+
+	applyConfig(&fieldTestConfig{Host: "b", Port: 9090, Tags: []string{"x"}})
+	select {
+	case p := <-ports:
+		assert.Equal(t, [2]int{8080, 9090}, p)
+	case <-time.After(2 * time.Second):
+		t.Fatal("端口变化后应触发")
+	}
+	expectNone("切片内容相同（不同底层数组）不触发")
+
+	applyConfig(&fieldTestConfig{Host: "b", Port: 9090, Tags: []string{"x", "y"}})
+	select {
+	case v := <-tags:
+		assert.Equal(t, []string{"x", "y"}, v)
+	case <-time.After(2 * time.Second):
+		t.Fatal("切片内容变化后应触发")
+	}
+	expectNone("端口未变化")
+}
+
+func TestOnConfigChange_StopUnregisters(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+	currentConfig.Store(nil)
+
+	kept := make(chan int, 4)
+	stopped := make(chan int, 4)
+	stopKept := OnConfigChange(func(c *fieldTestConfig) { kept <- c.Port })
+	t.Cleanup(stopKept)
+	stop := OnConfigChange(func(c *fieldTestConfig) { stopped <- c.Port })
+
+	applyConfig(&fieldTestConfig{Port: 1})
+	assert.Equal(t, 1, <-kept)
+	assert.Equal(t, 1, <-stopped)
+
+	stop()
+	stop() // 重复调用无影响
+	applyConfig(&fieldTestConfig{Port: 2})
+	assert.Equal(t, 2, <-kept)
+	select {
+	case <-stopped:
+		t.Fatal("注销后不应再触发")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestOnConfigChange_StopDuringDispatch(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+	currentConfig.Store(nil)
+
+	calls := make(chan int, 8)
+	var stop func()
+	var once sync.Once
+	stop = OnConfigChange(func(c *fieldTestConfig) {
+		once.Do(stop) // 回调内注销自己，与并发的分发不冲突
+		calls <- c.Port
+	})
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applyConfig(&fieldTestConfig{Port: i})
+		}()
+	}
+	wg.Wait()
+	select {
+	case <-calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("注销前的分发应执行")
+	}
+	time.Sleep(100 * time.Millisecond)
+	for len(calls) > 0 {
+		<-calls
+	}
+
+	applyConfig(&fieldTestConfig{Port: 99})
+	select {
+	case p := <-calls:
+		t.Fatalf("注销后不应再触发: %d", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestResetForTest(t *testing.T) {
+	handlerMutex.Lock()
+	savedHandlers := changeHandlers
+	handlerMutex.Unlock()
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		handlerMutex.Lock()
+		changeHandlers = savedHandlers
+		handlerMutex.Unlock()
+		currentConfig.Store(previous)
+	})
+
+	fired := make(chan struct{}, 1)
+	OnConfigChange(func(c *fieldTestConfig) { fired <- struct{}{} })
+	applyConfig(&fieldTestConfig{Port: 1})
+	<-fired
+
+	ResetForTest()
+	assert.Nil(t, Current())
+	assert.Nil(t, LastError())
+	applyConfig(&fieldTestConfig{Port: 2})
+	select {
+	case <-fired:
+		t.Fatal("重置后回调已清空")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// watchGoroutines 正在运行的单文件监听协程数
+func watchGoroutines() int {
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	return strings.Count(string(buf[:n]), "created by github.com/CenJIl/base/cfg.watchConfigFile[")
+}
+
+func TestLoadConfig_RepeatedCallsKeepOneWatcherAndSetLogger(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		SetLogger(nil)
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("port = 8080\n"), 0644))
+	for range 3 {
+		require.NoError(t, LoadConfig[diffTestConfig](path))
+	}
+	assert.Equal(t, 1, watchGoroutines(), "重复加载替换之前的监听")
+
+	// 加载之后再设置日志记录器，热更新的日志立即使用它
+	log := &MockLogger{}
+	SetLogger(log)
+	require.NoError(t, os.WriteFile(path, []byte("port = \"bad\"\n"), 0644))
+	assert.Eventually(t, func() bool {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		return slices.ContainsFunc(log.logs, func(s string) bool { return strings.Contains(s, "配置热更新失败") })
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 8080, GetCfg[diffTestConfig]().Port)
+
+	require.NoError(t, Close())
+	assert.Zero(t, watchGoroutines())
+}
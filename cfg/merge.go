@@ -0,0 +1,169 @@
+package cfg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// MergedConfig 多个配置片段深度合并后的结果
+//
+// Provenance 记录每个叶子键（以 . 连接的路径）最终由哪个片段提供，用于排查「这个值是从哪来的」
+type MergedConfig struct {
+	Sources    []string          // 按合并顺序排列的片段
+	Values     map[string]any    // 合并后的配置树
+	Provenance map[string]string // 键路径 -> 片段
+}
+
+// newMergedConfig 创建空的合并结果
+func newMergedConfig() *MergedConfig {
+	return &MergedConfig{Values: make(map[string]any), Provenance: make(map[string]string)}
+}
+
+// Merge 将一个片段深度合并到结果中
+//
+// 表（map）逐键递归合并；其余值（包括数组）整体替换，后合并的片段优先
+func (m *MergedConfig) Merge(source string, tree map[string]any) {
+	m.Sources = append(m.Sources, source)
+	mergeTree(m.Values, tree, "", source, m.Provenance)
+}
+
+func mergeTree(dst, src map[string]any, prefix, source string, prov map[string]string) {
+	for k, v := range src {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			existing, ok := dst[k].(map[string]any)
+			if !ok {
+				// 原来是标量的键被表替换：清除旧的来源
+				clearProvenance(prov, path)
+				existing = make(map[string]any)
+				dst[k] = existing
+			}
+			mergeTree(existing, sub, path, source, prov)
+			continue
+		}
+		// 原来是表的键被标量替换：清除子键的来源
+		clearProvenance(prov, path)
+		dst[k] = v
+		prov[path] = source
+	}
+}
+
+func clearProvenance(prov map[string]string, path string) {
+	delete(prov, path)
+	for k := range prov {
+		if strings.HasPrefix(k, path+".") {
+			delete(prov, k)
+		}
+	}
+}
+
+// Decode 将合并结果解码到配置结构体
+func (m *MergedConfig) Decode(v any) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(m.Values); err != nil {
+		return fmt.Errorf("编码合并配置失败: %w", err)
+	}
+	if _, err := decode(buf.String(), v); err != nil {
+		return fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	return nil
+}
+
+// Dump 输出合并后的全部叶子键、值和来源（按键排序）
+//
+// 输出示例：
+//
+//	database.host = "db.internal"    # 10-database.toml
+//	web.port = 8080                  # 20-web.toml
+func (m *MergedConfig) Dump() string {
+	keys := make([]string, 0, len(m.Provenance))
+	for k := range m.Provenance {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		line := fmt.Sprintf("%s = %s", k, formatValue(lookupPath(m.Values, k)))
+		fmt.Fprintf(&b, "%-48s # %s\n", line, filepath.Base(m.Provenance[k]))
+	}
+	return b.String()
+}
+
+func lookupPath(tree map[string]any, path string) any {
+	parts := strings.Split(path, ".")
+	var cur any = tree
+	for _, p := range parts {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[p]
+	}
+	return cur
+}
+
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// parseFragment 按扩展名解析配置片段为配置树
+func parseFragment(name string, data []byte) (map[string]any, error) {
+	tree := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &tree); err != nil {
+			return nil, decodeError(err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+		tree = normalizeJSON(tree).(map[string]any)
+	default:
+		return nil, fmt.Errorf("不支持的配置格式: %s", filepath.Ext(name))
+	}
+	return tree, nil
+}
+
+// normalizeJSON 将 json.Number 转为 int64/float64，与 TOML 解析结果保持一致
+func normalizeJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, sub := range t {
+			t[k] = normalizeJSON(sub)
+		}
+		return t
+	case []any:
+		for i, sub := range t {
+			t[i] = normalizeJSON(sub)
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	default:
+		return v
+	}
+}
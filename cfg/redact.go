@@ -0,0 +1,77 @@
+package cfg
+
+import "reflect"
+
+// RedactedValue 脱敏后的敏感配置项取值
+const RedactedValue = "******"
+
+// Current 当前配置（未加载时为 nil），用于不知道配置类型的场景（如诊断输出），已知类型时使用 GetCfg
+func Current() any {
+	if p := currentConfig.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// Redact 把配置结构体转换为以 toml 键名组织的配置树，sensitive:"true" 的配置项替换为 RedactedValue
+//
+// 未设置的敏感配置项保留零值（便于看出「没有配置」），嵌套结构体不再展开。
+// 键名规则与 Diff 相同：toml:"-" 的字段跳过，未设置键名的内嵌结构体的字段提升到当前层级
+//
+// 使用方式：
+//
+//	data, _ := json.MarshalIndent(cfg.Redact(cfg.Current()), "", "  ")
+//	// {"web": {"database": {"host": "db.internal", "password": "******"}}}
+func Redact(cfg any) map[string]any {
+	tree, _ := redactValue(reflect.ValueOf(cfg)).(map[string]any)
+	return tree
+}
+
+func redactValue(v reflect.Value) any {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	v = indirect(v)
+	if !v.IsValid() || v.Kind() == reflect.Interface {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if !hasExportedFields(v.Type()) {
+			return v.Interface()
+		}
+		tree := make(map[string]any)
+		redactStruct(tree, v)
+		return tree
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		tree := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			tree[iter.Key().String()] = redactValue(iter.Value())
+		}
+		return tree
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = redactValue(v.Index(i))
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+func redactStruct(tree map[string]any, v reflect.Value) {
+	walkFields(v.Type(), func(f configField) {
+		fv := indirect(fieldByIndex(v, f.Index))
+		if f.Field.Tag.Get("sensitive") == "true" && !fv.IsZero() {
+			tree[f.Key] = RedactedValue
+			return
+		}
+		tree[f.Key] = redactValue(fv)
+	})
+}
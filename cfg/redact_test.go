@@ -0,0 +1,61 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type RedactDatabaseConfig struct {
+	Host     string `toml:"host"`
+	Password string `toml:"password" sensitive:"true"`
+}
+
+type redactTestConfig struct {
+	AppName string `toml:"appName"`
+	RedactServerConfig
+	Database RedactDatabaseConfig            `toml:"database"`
+	Replicas []RedactDatabaseConfig          `toml:"replicas"`
+	Peers    map[string][]string             `toml:"peers" sensitive:"true"`
+	Limits   map[string]*RedactLimit         `toml:"limits"`
+	Token    string                          `toml:"token" sensitive:"true"`
+	Internal string                          `toml:"-"`
+	Timeout  time.Duration                   `toml:"timeout"`
+	Extra    map[string]RedactDatabaseConfig `toml:"extra"`
+	secret   string
+}
+
+type RedactServerConfig struct {
+	Port int `toml:"port"`
+}
+
+type RedactLimit struct {
+	Rate int `toml:"rate"`
+}
+
+func TestRedact(t *testing.T) {
+	c := &redactTestConfig{
+		AppName:            "shop",
+		RedactServerConfig: RedactServerConfig{Port: 8080},
+		Database:           RedactDatabaseConfig{Host: "db", Password: "p@ss"},
+		Replicas:           []RedactDatabaseConfig{{Host: "r1", Password: "x"}},
+		Peers:              map[string][]string{"billing": {"k1"}},
+		Limits:             map[string]*RedactLimit{"partner": {Rate: 10}},
+		Internal:           "hidden",
+		Timeout:            time.Second,
+		secret:             "s",
+	}
+	assert.Equal(t, map[string]any{
+		"appName":  "shop",
+		"port":     8080,
+		"database": map[string]any{"host": "db", "password": RedactedValue},
+		"replicas": []any{map[string]any{"host": "r1", "password": RedactedValue}},
+		"peers":    RedactedValue,
+		"limits":   map[string]any{"partner": map[string]any{"rate": 10}},
+		"token":    "", // 未设置的敏感配置项保留零值
+		"timeout":  time.Second,
+		"extra":    map[string]any{},
+	}, Redact(c))
+	assert.Nil(t, Redact(nil))
+}
@@ -0,0 +1,112 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrSectionNotFound 配置中没有该路径的表
+var ErrSectionNotFound = errors.New("config section not found")
+
+// sectionTree 当前配置的 TOML 树（按配置指针缓存，配置替换后重新生成）
+type sectionTree struct {
+	cfg  any
+	tree map[string]any
+}
+
+var sectionCache atomic.Pointer[sectionTree]
+
+// GetSection 按点分路径读取当前配置中的一个表，解析为 T
+//
+// 不需要知道应用的配置类型，适合只关心某一段配置的可复用模块。每次调用都从当前配置重新解析，
+// 热更新后再次调用即得到新值；需要在变化时收到通知时使用 OnSectionChange。
+// 路径不存在或不是表时返回 ErrSectionNotFound。T 上的 default 标签对文件中缺失的键同样生效
+//
+// 注意：配置树由当前配置结构体生成，应用配置类型中没有声明的键读不到
+//
+// 示例
+//
+//	type RedisConfig struct {
+//	    Addr string `toml:"addr" default:"127.0.0.1:6379"`
+//	    DB   int    `toml:"db"`
+//	}
+//
+//	redisCfg, err := cfg.GetSection[RedisConfig]("web.redis")
+func GetSection[T any](path string) (*T, error) {
+	return sectionOf[T](Current(), path)
+}
+
+// OnSectionChange 注册某个表的变更回调，只有该表的内容变化时才触发
+//
+// 执行方式与 OnConfigDiff 相同（独立 goroutine，panic 只记录错误）；
+// oldCfg 为变更前该表的值（之前不存在时为 nil），变更后该表不存在时不触发。返回的函数用于注销回调
+//
+// 示例
+//
+//	cfg.OnSectionChange("web.redis", func(oldCfg, newCfg *RedisConfig) {
+//	    reconnect(newCfg)
+//	})
+func OnSectionChange[T any](path string, h func(oldCfg, newCfg *T)) (stop func()) {
+	return addChangeHandler(func(oldRaw, newRaw any) {
+		newCfg, err := sectionOf[T](newRaw, path)
+		if err != nil {
+			return
+		}
+		oldCfg, err := sectionOf[T](oldRaw, path)
+		if err != nil {
+			oldCfg = nil
+		}
+		if oldCfg != nil && reflect.DeepEqual(oldCfg, newCfg) {
+			return
+		}
+		h(oldCfg, newCfg)
+	})
+}
+
+// sectionOf 从配置结构体中取出 path 对应的表并解析为 T
+func sectionOf[T any](cfg any, path string) (*T, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("%w: %s（配置未加载）", ErrSectionNotFound, path)
+	}
+	tree, err := treeOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	section, ok := lookupPath(tree, path).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, path)
+	}
+
+	data, err := encodeConfig(section)
+	if err != nil {
+		return nil, err
+	}
+	var out T
+	if err := unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("解析配置 %s 失败: %w", path, err)
+	}
+	return &out, nil
+}
+
+// treeOf 把配置结构体转换为 TOML 树（当前配置的结果会被缓存）
+func treeOf(cfg any) (map[string]any, error) {
+	if c := sectionCache.Load(); c != nil && c.cfg == cfg {
+		return c.tree, nil
+	}
+	data, err := encodeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]any)
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return nil, fmt.Errorf("解析配置树失败: %w", err)
+	}
+	if cfg == Current() {
+		sectionCache.Store(&sectionTree{cfg: cfg, tree: tree})
+	}
+	return tree, nil
+}
@@ -0,0 +1,88 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sectionRedis struct {
+	Addr     string        `toml:"addr"`
+	DB       int           `toml:"db"`
+	Timeout  time.Duration `toml:"timeout" default:"3s"`
+	PoolSize int           `toml:"poolSize"`
+}
+
+type sectionApp struct {
+	AppName string `toml:"appName"`
+	Web     struct {
+		Port  int          `toml:"port"`
+		Redis sectionRedis `toml:"redis"`
+	} `toml:"web"`
+}
+
+// redisView 只关心 web.redis 的模块自己的类型（字段是应用配置的子集）
+type redisView struct {
+	Addr    string        `toml:"addr"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+func TestGetSection_NavigatesAndFollowsReload(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() { currentConfig.Store(previous) })
+
+	var app sectionApp
+	require.NoError(t, unmarshal([]byte(`
+appName = "demo"
+[web]
+port = 8080
+[web.redis]
+addr = "redis:6379"
+db = 2
+`), &app))
+	var anyCfg any = &app
+	currentConfig.Store(&anyCfg)
+
+	view, err := GetSection[redisView]("web.redis")
+	require.NoError(t, err)
+	assert.Equal(t, "redis:6379", view.Addr)
+	assert.Equal(t, 3*time.Second, view.Timeout)
+
+	_, err = GetSection[redisView]("web.mysql")
+	assert.ErrorIs(t, err, ErrSectionNotFound)
+	_, err = GetSection[redisView]("appName")
+	assert.ErrorIs(t, err, ErrSectionNotFound, "不是表")
+
+	changed := make(chan [2]*redisView, 4)
+	OnSectionChange("web.redis", func(oldCfg, newCfg *redisView) {
+		changed <- [2]*redisView{oldCfg, newCfg}
+	})
+
+	// 其他表变化不触发
+	next := app
+	next.Web.Port = 9090
+	applyConfig(&next)
+	select {
+	case <-changed:
+		t.Fatal("web.redis 没有变化，不应触发")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	next2 := next
+	next2.Web.Redis.Addr = "redis-2:6379"
+	applyConfig(&next2)
+	select {
+	case c := <-changed:
+		assert.Equal(t, "redis:6379", c[0].Addr)
+		assert.Equal(t, "redis-2:6379", c[1].Addr)
+	case <-time.After(2 * time.Second):
+		t.Fatal("web.redis 变化后应触发回调")
+	}
+
+	// 每次调用都解析当前配置
+	view, err = GetSection[redisView]("web.redis")
+	require.NoError(t, err)
+	assert.Equal(t, "redis-2:6379", view.Addr)
+}
@@ -0,0 +1,250 @@
+package cfg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+)
+
+// 分阶段应用的错误
+var (
+	ErrTokenInvalid  = errors.New("确认令牌无效或已使用")
+	ErrTokenExpired  = errors.New("确认令牌已过期")
+	ErrTokenOperator = errors.New("确认令牌属于其他操作人")
+	ErrConfigChanged = errors.New("试运行之后配置已被修改，请重新试运行")
+)
+
+// DryRunCheck 子系统的试运行校验
+//
+// candidate 为候选配置结构体的指针。只做检查（文件是否存在、连接能否建立等），不能产生副作用，
+// 需要遵守 ctx 的超时
+type DryRunCheck func(ctx context.Context, candidate any) error
+
+var (
+	dryRunMu     sync.Mutex
+	dryRunChecks = map[string]DryRunCheck{}
+)
+
+// RegisterDryRunCheck 注册一个试运行校验（同名覆盖）
+//
+// 使用方式：
+//
+//	cfg.RegisterDryRunCheck("tls", func(ctx context.Context, candidate any) error {
+//	    c := candidate.(*AppConfig)
+//	    return web.CheckTLSFiles(c.TLS.CertFile, c.TLS.KeyFile)
+//	})
+func RegisterDryRunCheck(name string, check DryRunCheck) {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	dryRunChecks[name] = check
+}
+
+// StageConfig 分阶段应用配置
+type StageConfig struct {
+	TokenTTL     time.Duration // 确认令牌有效期，默认 5 分钟
+	CheckTimeout time.Duration // 全部试运行校验的总超时，默认 5 秒
+	Clock        common.Clock  // 默认系统时钟
+}
+
+func (c StageConfig) withDefaults() StageConfig {
+	if c.TokenTTL <= 0 {
+		c.TokenTTL = 5 * time.Minute
+	}
+	if c.CheckTimeout <= 0 {
+		c.CheckTimeout = 5 * time.Second
+	}
+	if c.Clock == nil {
+		c.Clock = common.SystemClock{}
+	}
+	return c
+}
+
+// StageError 试运行发现的一个问题
+type StageError struct {
+	Check   string `json:"check"` // parse、validate 或试运行校验的名称
+	Message string `json:"message"`
+}
+
+// StageResult 试运行结果
+type StageResult struct {
+	Valid           bool         `json:"valid"`
+	Errors          []StageError `json:"errors,omitempty"`
+	Changes         []Change     `json:"changes"`
+	RestartRequired bool         `json:"restartRequired"` // 任一变更需要重启
+	Token           string       `json:"token,omitempty"` // 校验通过时返回，用于确认应用
+	ExpiresAt       time.Time    `json:"expiresAt,omitzero"`
+}
+
+// staged 一次试运行通过、等待确认的候选配置
+type staged struct {
+	data     []byte
+	operator string
+	base     *any // 试运行时的当前配置（确认时必须未变）
+	expires  time.Time
+	changes  []Change
+}
+
+// Stager 配置分阶段应用：先试运行，再凭确认令牌写回
+//
+// DryRun 严格解析候选配置（拒绝未知键）、执行 Validate 与全部试运行校验、计算与当前配置的差异，
+// 什么都不应用；校验通过时返回短期有效、一次性的确认令牌。Apply 凭令牌通过 Update 原子写回。
+// 每次试运行的令牌相互独立；试运行之后当前配置被修改过（其他会话已应用、文件被编辑）时
+// 确认返回 ErrConfigChanged，避免基于过期差异覆盖别人的修改
+//
+// 使用方式：
+//
+//	stager := cfg.NewStager[AppConfig](cfg.StageConfig{})
+//	result := stager.DryRun(ctx, data, "alice")
+//	if result.Valid {
+//	    changes, err := stager.Apply(result.Token, "alice")
+//	}
+type Stager[T any] struct {
+	config StageConfig
+
+	mu       sync.Mutex
+	sessions map[string]*staged
+}
+
+// NewStager 创建分阶段应用器
+func NewStager[T any](config StageConfig) *Stager[T] {
+	return &Stager[T]{config: config.withDefaults(), sessions: make(map[string]*staged)}
+}
+
+// DryRun 试运行候选配置（不应用任何修改）
+func (s *Stager[T]) DryRun(ctx context.Context, data []byte, operator string) StageResult {
+	base := currentConfig.Load()
+	result := StageResult{Changes: []Change{}}
+
+	candidate, err := decodeStrict[T](data)
+	if err != nil {
+		result.Errors = append(result.Errors, StageError{Check: "parse", Message: err.Error()})
+		return result
+	}
+	if err := applyFlagOverrides(candidate); err != nil {
+		result.Errors = append(result.Errors, StageError{Check: "flags", Message: err.Error()})
+	} else if err := validate(candidate); err != nil {
+		result.Errors = append(result.Errors, StageError{Check: "validate", Message: err.Error()})
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, s.config.CheckTimeout)
+		result.Errors = runDryRunChecks(ctx, candidate)
+		cancel()
+	}
+
+	var current any = new(T)
+	if base != nil {
+		if c, ok := (*base).(*T); ok {
+			current = c
+		}
+	}
+	result.Changes = Diff(current, candidate)
+	if result.Changes == nil {
+		result.Changes = []Change{}
+	}
+	for _, c := range result.Changes {
+		result.RestartRequired = result.RestartRequired || c.RestartRequired
+	}
+	if len(result.Errors) > 0 {
+		return result
+	}
+
+	result.Valid = true
+	result.Token = newStageToken()
+	result.ExpiresAt = s.config.Clock.Now().Add(s.config.TokenTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	s.sessions[result.Token] = &staged{
+		data:     data,
+		operator: operator,
+		base:     base,
+		expires:  result.ExpiresAt,
+		changes:  result.Changes,
+	}
+	return result
+}
+
+// Apply 凭试运行返回的令牌写回候选配置，返回应用的变更
+//
+// 令牌只能由试运行的操作人确认（其他人提交时令牌不失效），确认后无论成功与否都不能再次使用
+func (s *Stager[T]) Apply(token, operator string) ([]Change, error) {
+	s.mu.Lock()
+	st, ok := s.sessions[token]
+	if ok && st.operator != operator {
+		s.mu.Unlock()
+		return nil, ErrTokenOperator
+	}
+	delete(s.sessions, token)
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrTokenInvalid
+	}
+	if !s.config.Clock.Now().Before(st.expires) {
+		return nil, ErrTokenExpired
+	}
+	err := update[T](st.data, func() error {
+		if currentConfig.Load() != st.base {
+			return ErrConfigChanged
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st.changes, nil
+}
+
+// prune 清理过期的会话（调用方持有锁）
+func (s *Stager[T]) prune() {
+	now := s.config.Clock.Now()
+	for token, st := range s.sessions {
+		if !now.Before(st.expires) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// runDryRunChecks 按名称顺序执行全部试运行校验
+func runDryRunChecks(ctx context.Context, candidate any) []StageError {
+	dryRunMu.Lock()
+	names := make([]string, 0, len(dryRunChecks))
+	checks := make(map[string]DryRunCheck, len(dryRunChecks))
+	for name, check := range dryRunChecks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	dryRunMu.Unlock()
+	sort.Strings(names)
+
+	var errs []StageError
+	for _, name := range names {
+		if err := runCheck(ctx, checks[name], candidate); err != nil {
+			errs = append(errs, StageError{Check: name, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// runCheck 执行一个校验（panic 视为校验失败）
+func runCheck(ctx context.Context, check DryRunCheck, candidate any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("校验异常: %v", r)
+		}
+	}()
+	return check(ctx, candidate)
+}
+
+func newStageToken() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,215 @@
+package cfg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stageTestConfig struct {
+	Name     string `toml:"name"`
+	Port     int    `toml:"port" reload:"restart"`
+	Database struct {
+		Host     string `toml:"host"`
+		Password string `toml:"password" sensitive:"true"`
+	} `toml:"database" reload:"restart"`
+	Limits struct {
+		Disabled bool `toml:"disabled" reload:"hot"`
+		Capacity int  `toml:"capacity"`
+	} `toml:"limits" reload:"restart"`
+	Features map[string]bool `toml:"features"`
+}
+
+func (c *stageTestConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port 必须大于 0")
+	}
+	return nil
+}
+
+const stageBase = `name = "orders"
+port = 8080
+[database]
+host = "db1"
+password = "old"
+[limits]
+capacity = 100
+[features]
+beta = true
+`
+
+func loadStageConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte(stageBase), 0600))
+	require.NoError(t, LoadConfig[stageTestConfig](path))
+	return path
+}
+
+func TestParseStrict_UnknownKeys(t *testing.T) {
+	_, err := ParseStrict[stageTestConfig]([]byte("port = 1\nprot = 2\n[database]\nhots = \"x\"\n"))
+	require.ErrorIs(t, err, ErrConfigInvalid)
+	var unknown *UnknownKeysError
+	require.ErrorAs(t, err, &unknown)
+	assert.Equal(t, []string{"database.hots", "prot"}, unknown.Keys)
+
+	unknown = nil
+	_, err = ParseStrict[stageTestConfig]([]byte("port = 0\n"))
+	assert.ErrorIs(t, err, ErrConfigInvalid)
+	assert.False(t, errors.As(err, &unknown), "校验失败不是未知键")
+}
+
+func TestDiff_RestartAndSensitive(t *testing.T) {
+	old, err := ParseStrict[stageTestConfig]([]byte(stageBase))
+	require.NoError(t, err)
+	candidate := *old
+	candidate.Name = "orders-v2"
+	candidate.Port = 9090
+	candidate.Database.Password = "new"
+	candidate.Limits.Disabled = true
+	candidate.Features = map[string]bool{"beta": false, "search": true}
+
+	changes := Diff(old, &candidate)
+	assert.Equal(t, []Change{
+		{Path: "name", Old: "orders", New: "orders-v2"},
+		{Path: "port", Old: 8080, New: 9090, RestartRequired: true},
+		{Path: "database.password", Sensitive: true, RestartRequired: true},
+		{Path: "limits.disabled", Old: false, New: true},
+		{Path: "features.beta", Old: true, New: false},
+		{Path: "features.search", New: true},
+	}, changes)
+	assert.Equal(t, "port: 8080 → 9090（需要重启）", changes[1].String())
+	assert.Equal(t, "database.password: (已修改)（需要重启）", changes[2].String())
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestStager_InvalidCandidate(t *testing.T) {
+	path := loadStageConfig(t)
+	RegisterDryRunCheck("test.database", func(ctx context.Context, candidate any) error {
+		if candidate.(*stageTestConfig).Database.Host == "unreachable" {
+			return errors.New("连接失败")
+		}
+		return nil
+	})
+	t.Cleanup(func() { delete(dryRunChecks, "test.database") })
+	stager := NewStager[stageTestConfig](StageConfig{})
+
+	result := stager.DryRun(context.Background(), []byte("port = 8080\nprot = 1\n"), "alice")
+	assert.False(t, result.Valid)
+	assert.Empty(t, result.Token)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "parse", result.Errors[0].Check)
+	assert.Contains(t, result.Errors[0].Message, "prot")
+
+	result = stager.DryRun(context.Background(), []byte("port = -1\n"), "alice")
+	assert.False(t, result.Valid)
+	assert.Equal(t, "validate", result.Errors[0].Check)
+
+	result = stager.DryRun(context.Background(), []byte("port = 8080\n[database]\nhost = \"unreachable\"\n"), "alice")
+	assert.False(t, result.Valid)
+	assert.Equal(t, []StageError{{Check: "test.database", Message: "连接失败"}}, result.Errors)
+	assert.NotEmpty(t, result.Changes, "校验失败时仍返回差异")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, stageBase, string(data), "试运行不修改配置文件")
+	assert.Equal(t, "db1", GetCfg[stageTestConfig]().Database.Host)
+}
+
+func TestStager_ApplyAndHotReloadAfterwards(t *testing.T) {
+	path := loadStageConfig(t)
+	stager := NewStager[stageTestConfig](StageConfig{})
+	candidate := []byte("name = \"orders\"\nport = 9090\n[database]\nhost = \"db2\"\npassword = \"old\"\n[limits]\ncapacity = 100\n[features]\nbeta = true\n")
+
+	result := stager.DryRun(context.Background(), candidate, "alice")
+	require.True(t, result.Valid, result.Errors)
+	assert.True(t, result.RestartRequired)
+	assert.Len(t, result.Changes, 2)
+	assert.Equal(t, 8080, GetCfg[stageTestConfig]().Port, "试运行不应用")
+
+	_, err := stager.Apply(result.Token, "bob")
+	assert.ErrorIs(t, err, ErrTokenOperator)
+
+	changes, err := stager.Apply(result.Token, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, result.Changes, changes)
+	assert.Equal(t, 9090, GetCfg[stageTestConfig]().Port)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(candidate), string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "保留原文件权限")
+
+	_, err = stager.Apply(result.Token, "alice")
+	assert.ErrorIs(t, err, ErrTokenInvalid, "令牌只能使用一次")
+
+	// 写回后手动编辑文件仍能热更新
+	require.NoError(t, os.WriteFile(path, []byte("port = 7070\n"), 0600))
+	assert.Eventually(t, func() bool { return GetCfg[stageTestConfig]().Port == 7070 }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestStager_TokenExpiry(t *testing.T) {
+	loadStageConfig(t)
+	clock := common.NewFakeClock(time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC))
+	stager := NewStager[stageTestConfig](StageConfig{TokenTTL: time.Minute, Clock: clock})
+
+	result := stager.DryRun(context.Background(), []byte("port = 9090\n"), "alice")
+	require.True(t, result.Valid)
+	assert.Equal(t, clock.Now().Add(time.Minute), result.ExpiresAt)
+
+	clock.Advance(time.Minute)
+	_, err := stager.Apply(result.Token, "alice")
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	assert.Equal(t, 8080, GetCfg[stageTestConfig]().Port)
+}
+
+func TestStager_ConcurrentSessions(t *testing.T) {
+	loadStageConfig(t)
+	stager := NewStager[stageTestConfig](StageConfig{})
+
+	var wg sync.WaitGroup
+	results := make([]StageResult, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = stager.DryRun(context.Background(), []byte("port = 9090\n"), "alice")
+		}(i)
+	}
+	wg.Wait()
+
+	tokens := map[string]bool{}
+	for _, r := range results {
+		require.True(t, r.Valid)
+		tokens[r.Token] = true
+	}
+	assert.Len(t, tokens, len(results), "每个会话有独立的令牌")
+
+	_, err := stager.Apply(results[0].Token, "alice")
+	require.NoError(t, err)
+	// 其他会话基于旧配置试运行，确认时拒绝，需要重新试运行
+	_, err = stager.Apply(results[1].Token, "alice")
+	assert.ErrorIs(t, err, ErrConfigChanged)
+
+	retry := stager.DryRun(context.Background(), []byte("port = 9191\n"), "alice")
+	_, err = stager.Apply(retry.Token, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 9191, GetCfg[stageTestConfig]().Port)
+}
+
+func TestUpdate_Unsupported(t *testing.T) {
+	dir := newFragmentDir(t)
+	d, err := openConfigDir[dirTestConfig](dir)
+	require.NoError(t, err)
+	defer d.Close()
+	assert.ErrorIs(t, Update[dirTestConfig]([]byte("[web]\nport = 1\n")), ErrUpdateUnsupported)
+}
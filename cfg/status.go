@@ -0,0 +1,81 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DecodeError TOML 语法错误（行号、列号与出错的键）
+//
+// 加载与热更新返回的错误链中包含它，可以用 errors.As 取出位置信息：
+//
+//	var de *cfg.DecodeError
+//	if errors.As(err, &de) {
+//	    fmt.Printf("第 %d 行第 %d 列: %s\n", de.Line, de.Column, de.Message)
+//	}
+type DecodeError struct {
+	Line    int    // 从 1 开始
+	Column  int    // 从 1 开始
+	Key     string // 出错前最后解析的键，可能为空
+	Message string // 解析器给出的原因
+	Err     error  // toml.ParseError
+}
+
+func (e *DecodeError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("第 %d 行第 %d 列: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("第 %d 行第 %d 列（键 %s）: %s", e.Line, e.Column, e.Key, e.Message)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeError 把 toml.ParseError 转换为 *DecodeError，其他错误原样返回
+// （类型不匹配等错误的信息中已包含行号与键）
+func decodeError(err error) error {
+	var pe toml.ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	return &DecodeError{Line: pe.Position.Line, Column: pe.Position.Col, Key: pe.LastKey, Message: pe.Message, Err: err}
+}
+
+var (
+	lastReloadErr  atomic.Pointer[error]
+	reloadFailures atomic.Int64
+)
+
+// LastError 最近一次重新加载的错误（热更新或 Reload；成功或重新调用 LoadConfig 等之后为 nil）
+//
+// 不为 nil 时磁盘上的配置当前无法使用，进程仍在使用之前的配置，适合在健康检查中报告
+//
+// 示例
+//
+//	if err := cfg.LastError(); err != nil {
+//	    status["config"] = fmt.Sprintf("配置文件有误（连续 %d 次）: %v", cfg.ReloadFailures(), err)
+//	}
+func LastError() error {
+	if p := lastReloadErr.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// ReloadFailures 连续失败的重新加载次数（成功后归零）
+func ReloadFailures() int {
+	return int(reloadFailures.Load())
+}
+
+// recordReload 记录重新加载的结果，返回连续失败次数
+func recordReload(err error) int {
+	if err == nil {
+		lastReloadErr.Store(nil)
+		reloadFailures.Store(0)
+		return 0
+	}
+	lastReloadErr.Store(&err)
+	return int(reloadFailures.Add(1))
+}
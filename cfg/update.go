@@ -0,0 +1,223 @@
+package cfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ErrUpdateUnsupported 当前配置不是从单个文件加载的（目录模式或未加载），无法写回
+var ErrUpdateUnsupported = errors.New("当前配置不支持写回")
+
+// UnknownKeysError 配置中存在结构体没有定义的键（通常是拼写错误）
+type UnknownKeysError struct {
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return "未知的配置项: " + strings.Join(e.Keys, ", ")
+}
+
+// updateMu 串行化 Update（写文件 + 替换配置）
+var updateMu sync.Mutex
+
+// ParseStrict 严格解析 TOML 配置
+//
+// 与加载时的解析不同，存在结构体没有定义的键时返回 UnknownKeysError（包在 ErrConfigInvalid 中），
+// 解析成功后执行配置校验（validate 标签、RegisterValidator、Validator 接口）。不影响当前配置
+//
+// 使用方式：
+//
+//	candidate, err := cfg.ParseStrict[AppConfig](data)
+//	var unknown *cfg.UnknownKeysError
+//	if errors.As(err, &unknown) {
+//	    fmt.Println(unknown.Keys)   // [database.hots]
+//	}
+func ParseStrict[T any](data []byte) (*T, error) {
+	cfg, err := decodeStrict[T](data)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyFlagOverrides(cfg); err != nil {
+		return nil, err
+	}
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeStrict 解析 TOML，拒绝未知键（不执行校验）
+func decodeStrict[T any](data []byte) (*T, error) {
+	var cfg T
+	md, err := decode(string(data), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, &UnknownKeysError{Keys: keys})
+	}
+	return &cfg, nil
+}
+
+// Update 严格校验新的配置文档，原子写回配置文件并立即生效
+//
+// 先写入同目录的临时文件并 fsync，再 rename 覆盖原文件，任何时刻读到的配置文件都是完整的；
+// 写回后替换当前配置并触发 OnConfigChange 回调（写文件触发的热更新内容相同，不会再次触发回调）。
+// 校验失败时不写文件。只支持 LoadConfig / InitConfig 加载的单文件配置
+//
+// 使用方式：
+//
+//	if err := cfg.Update[AppConfig](data); err != nil {
+//	    return err
+//	}
+func Update[T any](data []byte) error {
+	return update[T](data, nil)
+}
+
+// UpdateCfg 修改当前配置并写回配置文件
+//
+// 在 Update 的写锁内复制当前配置、调用 mutator 修改副本，编码为 TOML 后校验并原子写回，然后立即生效；
+// 并发调用按顺序执行，每次都基于上一次写回后的配置。写回的文件由配置结构体重新编码，原文件中的注释与格式不会保留，
+// 值没有变化的 ENC(...) 加密项保留原来的密文。
+// 校验失败时不写文件、不改变当前配置。只支持 LoadConfig / InitConfig 加载的单文件配置
+//
+// 使用方式：
+//
+//	err := cfg.UpdateCfg(func(c *AppConfig) {
+//	    c.Web.Maintenance.Enabled = true
+//	})
+func UpdateCfg[T any](mutator func(*T)) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	p := configFile.Load()
+	if p == nil {
+		return ErrUpdateUnsupported
+	}
+	cur, ok := currentAs[T]()
+	if !ok {
+		return fmt.Errorf("%w: 当前配置类型不是 %T", ErrUpdateUnsupported, cur)
+	}
+
+	// TOML 编解码一次得到与当前配置不共享切片、map 的副本
+	data, err := encodeConfig(cur)
+	if err != nil {
+		return err
+	}
+	var next T
+	if _, err := toml.Decode(string(data), &next); err != nil {
+		return fmt.Errorf("复制当前配置失败: %w", err)
+	}
+	mutator(&next)
+	restoreFlagged(&next, cur, *p)
+	if data, err = encodeConfig(&next); err != nil {
+		return err
+	}
+	// 没有修改的加密配置项按原来的密文写回
+	if original, readErr := os.ReadFile(*p); readErr == nil {
+		if data, err = keepEncrypted(data, original); err != nil {
+			return err
+		}
+	}
+	// 生效的是从写回内容解析出的配置，与之后文件监听读到的完全一致
+	cfg, err := ParseStrict[T](data)
+	if err != nil {
+		return err
+	}
+	return writeBack(*p, data, cfg)
+}
+
+// currentAs 当前配置（类型不是 *T 或未加载时 ok 为 false）
+func currentAs[T any]() (*T, bool) {
+	p := currentConfig.Load()
+	if p == nil {
+		return nil, false
+	}
+	cfg, ok := (*p).(*T)
+	return cfg, ok
+}
+
+// encodeConfig 把配置结构体编码为 TOML
+func encodeConfig(cfg any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return nil, fmt.Errorf("编码配置失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// update 在写锁内执行 precheck（如检查配置自试运行以来未被修改）后写回
+func update[T any](data []byte, precheck func() error) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	p := configFile.Load()
+	if p == nil {
+		return ErrUpdateUnsupported
+	}
+	if precheck != nil {
+		if err := precheck(); err != nil {
+			return err
+		}
+	}
+	cfg, err := ParseStrict[T](data)
+	if err != nil {
+		return err
+	}
+	return writeBack(*p, data, cfg)
+}
+
+// writeBack 原子写回配置文件并替换当前配置（调用方持有 updateMu）
+func writeBack(path string, data []byte, cfg any) error {
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("写回配置文件失败: %w", err)
+	}
+	markUpdated(Current(), cfg, path)
+	applyConfig(cfg)
+	cfgLogger().Infof("配置已写回并生效: %s", path)
+	return nil
+}
+
+// writeFileAtomic 写临时文件后 rename 覆盖目标文件（保留原文件权限）
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // rename 成功后为空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
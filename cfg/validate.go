@@ -0,0 +1,183 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError 一个配置项的校验失败（Field 为空表示来自 Validator / RegisterValidator 的整体校验）
+type FieldError struct {
+	Field   string // 配置路径（toml 键名，如 web.port）
+	Rule    string // 未通过的规则（如 min=1）
+	Message string
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError 配置校验失败，列出全部不合法的配置项
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	items := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		items[i] = f.String()
+	}
+	return strings.Join(items, "; ")
+}
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[reflect.Type][]func(any) error{}
+)
+
+// RegisterValidator 为配置类型 T 注册校验函数（可注册多个），在标签校验之后执行
+//
+// 与 Validator 接口作用相同，适用于无法给配置结构体添加方法的场景（如校验第三方定义的配置类型）
+//
+// 使用方式：
+//
+//	cfg.RegisterValidator(func(c *AppConfig) error {
+//	    if c.Web.Upload.MaxSize > c.Web.Bandwidth.Limit {
+//	        return errors.New("upload.maxSize 不能超过带宽限制")
+//	    }
+//	    return nil
+//	})
+func RegisterValidator[T any](fn func(*T) error) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	t := reflect.TypeFor[T]()
+	validators[t] = append(validators[t], func(cfg any) error {
+		return fn(cfg.(*T))
+	})
+}
+
+// validate 校验配置（cfg 为配置结构体指针）
+//
+// 依次执行 validate 标签、RegisterValidator 注册的函数和 Validator 接口，汇总全部错误后
+// 返回包在 ErrConfigInvalid 中的 *ValidationError。支持的标签规则（逗号分隔）：
+//
+//	required      不能为零值
+//	omitempty     为零值时跳过其余规则
+//	min=N, max=N  数值的取值范围；字符串、切片、map 的长度范围
+//	oneof=a b c   取值必须是列出的值之一
+//
+// 示例：
+//
+//	Port int `toml:"port" validate:"required,min=1,max=65535"`
+func validate(cfg any) error {
+	var fields []FieldError
+	v := reflect.ValueOf(cfg)
+	validateValue(&fields, "", v)
+
+	validatorsMu.RLock()
+	fns := validators[reflect.TypeOf(cfg).Elem()]
+	validatorsMu.RUnlock()
+	for _, fn := range fns {
+		if err := fn(cfg); err != nil {
+			fields = append(fields, FieldError{Message: err.Error()})
+		}
+	}
+	if vv, ok := cfg.(Validator); ok {
+		if err := vv.Validate(); err != nil {
+			fields = append(fields, FieldError{Message: err.Error()})
+		}
+	}
+
+	if len(fields) > 0 {
+		return fmt.Errorf("%w: %w", ErrConfigInvalid, &ValidationError{Fields: fields})
+	}
+	return nil
+}
+
+// validateValue 递归校验结构体字段的 validate 标签
+func validateValue(fields *[]FieldError, path string, v reflect.Value) {
+	v = indirect(v)
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return
+	}
+	walkFields(v.Type(), func(f configField) {
+		fv := fieldByIndex(v, f.Index)
+		fieldPath := joinPath(path, f.Key)
+		if tag := f.Field.Tag.Get("validate"); tag != "" {
+			checkRules(fields, fieldPath, fv, tag)
+		}
+		validateValue(fields, fieldPath, fv)
+	})
+}
+
+// checkRules 对单个字段执行标签规则
+func checkRules(fields *[]FieldError, path string, v reflect.Value, tag string) {
+	fail := func(rule, format string, args ...any) {
+		*fields = append(*fields, FieldError{Field: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	iv := indirect(v) // 取值类规则作用于指针指向的值
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "omitempty":
+			if v.IsZero() {
+				return
+			}
+		case "required":
+			if v.IsZero() {
+				fail(rule, "不能为空")
+				return
+			}
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				fail(rule, "规则参数无效")
+				continue
+			}
+			n, isLen, ok := measure(iv)
+			if !ok {
+				fail(rule, "类型 %s 不支持该规则", iv.Type())
+				continue
+			}
+			what := "值"
+			if isLen {
+				what = "长度"
+			}
+			if name == "min" && n < bound {
+				fail(rule, "%s不能小于 %s（当前为 %v）", what, arg, n)
+			}
+			if name == "max" && n > bound {
+				fail(rule, "%s不能大于 %s（当前为 %v）", what, arg, n)
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !slices.Contains(allowed, fmt.Sprint(iv.Interface())) {
+				fail(rule, "必须是 %s 之一（当前为 %v）", strings.Join(allowed, "、"), iv.Interface())
+			}
+		default:
+			fail(rule, "未知的校验规则 %q", name)
+		}
+	}
+}
+
+// measure 数值取值本身，字符串、切片、map 取长度
+func measure(v reflect.Value) (n float64, isLen, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,113 @@
+package cfg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ValidateServerConfig struct {
+	Port    int      `toml:"port" validate:"required,min=1,max=65535"`
+	Mode    string   `toml:"mode" validate:"oneof=debug release"`
+	Hosts   []string `toml:"hosts" validate:"omitempty,max=2"`
+	Timeout *int     `toml:"timeout" validate:"omitempty,min=1"`
+}
+
+type validateTestConfig struct {
+	ValidateServerConfig
+	Name     string `toml:"name" validate:"required"`
+	Database struct {
+		Pool int `toml:"pool" validate:"min=1"`
+	} `toml:"database"`
+}
+
+func TestValidate_ListsEveryInvalidField(t *testing.T) {
+	timeout := 0
+	c := &validateTestConfig{ValidateServerConfig: ValidateServerConfig{
+		Port: 70000, Mode: "prod", Hosts: []string{"a", "b", "c"}, Timeout: &timeout,
+	}}
+	err := validate(c)
+	require.ErrorIs(t, err, ErrConfigInvalid)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field+" "+f.Rule)
+	}
+	assert.Equal(t, []string{
+		"port max=65535", // 内嵌结构体的字段提升到当前层级
+		"mode oneof=debug release",
+		"hosts max=2",
+		"timeout min=1", // 指针取指向的值
+		"name required",
+		"database.pool min=1",
+	}, fields)
+	assert.Contains(t, err.Error(), "port: 值不能大于 65535（当前为 70000）")
+	assert.Contains(t, err.Error(), "database.pool: 值不能小于 1（当前为 0）")
+
+	c = &validateTestConfig{ValidateServerConfig: ValidateServerConfig{Port: 8080, Mode: "debug"}, Name: "orders"}
+	c.Database.Pool = 5
+	assert.NoError(t, validate(c), "omitempty 跳过零值")
+
+	c.Port = 0
+	require.ErrorAs(t, validate(c), &verr)
+	assert.Equal(t, []FieldError{{Field: "port", Rule: "required", Message: "不能为空"}}, verr.Fields, "required 不通过时跳过其余规则")
+}
+
+type validateRegisteredConfig struct {
+	Min int `toml:"min"`
+	Max int `toml:"max" validate:"min=1"`
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator(func(c *validateRegisteredConfig) error {
+		if c.Min > c.Max {
+			return errors.New("min 不能大于 max")
+		}
+		return nil
+	})
+	assert.NoError(t, validate(&validateRegisteredConfig{Min: 1, Max: 2}))
+
+	err := validate(&validateRegisteredConfig{Min: 3, Max: 0})
+	assert.EqualError(t, err, "config file is invalid: max: 值不能小于 1（当前为 0）; min 不能大于 max", "标签错误与注册的校验一并列出")
+}
+
+func TestLoadConfig_RejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("port = 0\nmode = \"debug\"\n"), 0644))
+	err := LoadConfig[validateTestConfig](path)
+	require.ErrorIs(t, err, ErrConfigInvalid)
+	assert.Contains(t, err.Error(), "port: 不能为空")
+	assert.Contains(t, err.Error(), "name: 不能为空")
+}
+
+type validateReloadConfig struct {
+	Name string `toml:"name" validate:"required"`
+	Port int    `toml:"port" validate:"min=1,max=65535"`
+}
+
+func TestWatchConfig_RejectsInvalidReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("name = \"orders\"\nport = 8080\n"), 0644))
+	require.NoError(t, LoadConfig[validateReloadConfig](path))
+
+	var changes atomic.Int32
+	OnConfigChange(func(c *validateReloadConfig) { changes.Add(1) })
+
+	require.NoError(t, os.WriteFile(path, []byte("name = \"\"\nport = 0\n"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Zero(t, changes.Load(), "校验不通过时不触发回调")
+	assert.Equal(t, "orders", GetCfg[validateReloadConfig]().Name, "保留之前的配置")
+	assert.Equal(t, 8080, GetCfg[validateReloadConfig]().Port)
+
+	require.NoError(t, os.WriteFile(path, []byte("name = \"orders\"\nport = 9090\n"), 0644))
+	require.Eventually(t, func() bool { return changes.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 9090, GetCfg[validateReloadConfig]().Port)
+}
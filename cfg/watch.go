@@ -0,0 +1,256 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// activeWatcher 当前的配置监听（InitConfig、LoadConfig、LoadConfigs、LoadConfigDir 启动，Close 停止）
+var activeWatcher struct {
+	sync.Mutex
+	closer io.Closer
+}
+
+// Close 停止配置文件监听
+//
+// 关闭 fsnotify 监听、取消未触发的去抖定时器，并等待监听协程退出；返回后不会再有热更新或变更回调。
+// GetCfg 继续返回最后加载的配置，之后可以再次调用 LoadConfig 等加载配置并启动新的监听。
+// 未启动监听时为空操作
+//
+// 示例
+//
+//	if err := cfg.LoadConfig[AppConfig]("config.toml"); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer cfg.Close()
+func Close() error {
+	activeWatcher.Lock()
+	c := activeWatcher.closer
+	activeWatcher.closer = nil
+	activeWatcher.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.Close()
+}
+
+// setWatcher 记录新启动的监听（调用方在替换当前配置之前已经通过 Close 停止了之前的监听）
+func setWatcher(c io.Closer) {
+	activeWatcher.Lock()
+	prev := activeWatcher.closer
+	activeWatcher.closer = c
+	activeWatcher.Unlock()
+	if prev != nil && prev != c {
+		_ = prev.Close()
+	}
+}
+
+// ErrReloadUnsupported 当前没有 T 类型配置的文件监听（未加载、已 Close 或加载的是其他类型），无法重新加载
+var ErrReloadUnsupported = errors.New("当前配置不支持重新加载")
+
+// Reload 立即重新读取配置文件（文件监听收不到事件时使用，如 NFS 等网络文件系统）
+//
+// 与文件变化触发的热更新执行完全相同的流程：读取、解析、命令行参数覆盖、校验，
+// 内容有变化时替换当前配置并触发变更回调；失败时保留之前的配置并返回错误（热更新只记录日志）。
+// 未触发的去抖热更新被取消；与文件监听、Update 并发调用是安全的。
+// 支持 InitConfig / LoadConfig / LoadConfigs / LoadConfigDir 加载的配置
+//
+// 使用方式：
+//
+//	// SIGHUP 重新加载配置
+//	sig := make(chan os.Signal, 1)
+//	signal.Notify(sig, syscall.SIGHUP)
+//	go func() {
+//	    for range sig {
+//	        if err := cfg.Reload[AppConfig](); err != nil {
+//	            logger.Errorf("重新加载配置失败: %v", err)
+//	        }
+//	    }
+//	}()
+func Reload[T any]() error {
+	activeWatcher.Lock()
+	c := activeWatcher.closer
+	activeWatcher.Unlock()
+	switch w := c.(type) {
+	case *configFileWatch[T]:
+		return w.reloadNow()
+	case *configFiles[T]:
+		return w.reloadNow()
+	case *configDir[T]:
+		return w.reloadNow()
+	}
+	return ErrReloadUnsupported
+}
+
+// reloadDebounce 文件事件的去抖间隔（编辑器保存、ConfigMap 更新通常产生一连串事件）
+const reloadDebounce = 100 * time.Millisecond
+
+// debouncer 文件事件去抖：连续的事件只在最后一次之后触发一次重新加载
+type debouncer struct {
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// trigger 重新计时，到期后在独立协程中执行 fn
+func (d *debouncer) trigger(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(reloadDebounce, fn)
+}
+
+// stop 取消未触发的重新加载
+func (d *debouncer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
+// configFileWatch 单文件配置监听（InitConfig / LoadConfig）
+//
+// 监听文件所在的目录而不是文件本身：vim、VS Code 与 Kubernetes ConfigMap 通过 rename 或删除后重建替换文件，
+// 对文件本身的监听会随旧文件一起失效
+type configFileWatch[T any] struct {
+	path    string
+	target  string // path 解析符号链接后的实际文件（只在监听协程中访问）
+	watcher *fsnotify.Watcher
+	done    chan struct{} // 监听协程退出
+	mu      sync.Mutex    // 串行化重新加载
+	closed  bool
+
+	debounce debouncer // 去抖
+}
+
+// watchConfigFile 启动单文件监听
+func watchConfigFile[T any](path string) (*configFileWatch[T], error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	w := &configFileWatch[T]{path: path, watcher: watcher, done: make(chan struct{})}
+	w.target, _ = filepath.EvalSymlinks(path)
+	go w.watch()
+	return w, nil
+}
+
+// Close 停止监听并等待监听协程退出（可重复调用）
+func (w *configFileWatch[T]) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	err := w.watcher.Close()
+	<-w.done
+	// 监听协程已退出，不会再创建新的定时器
+	w.debounce.stop()
+	return err
+}
+
+// reload 去抖定时器触发的重新加载（错误只记录日志）
+func (w *configFileWatch[T]) reload() {
+	if err := w.load(); err != nil && !errors.Is(err, ErrReloadUnsupported) {
+		cfgLogger().Errorf("配置热更新失败（连续 %d 次），保留之前的配置: %v", ReloadFailures(), err)
+	}
+}
+
+// reloadNow 手动重新加载（见 Reload）：取消未触发的去抖定时器后立即执行
+func (w *configFileWatch[T]) reloadNow() error {
+	w.debounce.stop()
+	return w.load()
+}
+
+// load 重新读取配置文件，校验通过且内容有变化时替换当前配置并触发变更回调；失败时保留之前的配置
+func (w *configFileWatch[T]) load() (err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrReloadUnsupported
+	}
+	defer func() { recordReload(err) }()
+	// 与 Update / UpdateCfg 串行：不会在写回文件与替换配置之间读到新内容而提前生效
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	var cfg T
+	if err := unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrConfigInvalid, w.path, err)
+	}
+	if err := applyFlagOverrides(&cfg); err != nil {
+		return err
+	}
+	if err := validate(&cfg); err != nil {
+		return err
+	}
+	// 内容没有变化（如 Update 写回、编辑器保存未修改的文件）时不触发变更回调，运行时修改的来源保持不变
+	if p := currentConfig.Load(); p != nil {
+		if cur, ok := (*p).(*T); ok && reflect.DeepEqual(*cur, cfg) {
+			return nil
+		}
+	}
+	storeSources(&cfg, fileSources(w.path, data, SourceFile))
+	applyConfig(&cfg)
+	cfgLogger().Infof("配置已热更新")
+	return nil
+}
+
+// targetChanged 目录中的其他变化是否替换了配置文件实际指向的文件
+// （Kubernetes ConfigMap 挂载通过替换 ..data 符号链接更新，配置文件本身没有事件）
+func (w *configFileWatch[T]) targetChanged() bool {
+	target, _ := filepath.EvalSymlinks(w.path)
+	if target == w.target {
+		return false
+	}
+	w.target = target
+	return true
+}
+
+func (w *configFileWatch[T]) watch() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// 写入、创建、rename 与删除都重新读取（删除后读取失败时保留之前的配置，文件重新出现时再加载）
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if filepath.Clean(event.Name) != w.path && !w.targetChanged() {
+				continue
+			}
+
+			w.debounce.trigger(w.reload)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			cfgLogger().Errorf("配置监听错误: %s", err.Error())
+		}
+	}
+}
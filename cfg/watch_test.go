@@ -0,0 +1,414 @@
+package cfg
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose_StopsWatcherAndAllowsReload(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+	first, ok := activeWatcher.closer.(*configFileWatch[TestConfig])
+	require.True(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v2\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "v2" }, 2*time.Second, 20*time.Millisecond)
+
+	// 修改后立即关闭：未触发的去抖定时器被取消，监听协程已经退出
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v3\"\n"), 0644))
+	require.NoError(t, Close())
+	select {
+	case <-first.done:
+	default:
+		t.Fatal("Close 返回时监听协程应已退出")
+	}
+	assert.NoError(t, Close(), "重复关闭为空操作")
+	time.Sleep(300 * time.Millisecond)
+	assert.Contains(t, []string{"v2", "v3"}, GetCfg[TestConfig]().AppName)
+	closedAt := GetCfg[TestConfig]().AppName
+
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v4\"\n"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, closedAt, GetCfg[TestConfig]().AppName, "关闭后不再热更新")
+
+	// 再次加载启动新的监听
+	require.NoError(t, LoadConfig[TestConfig](path))
+	assert.Equal(t, "v4", GetCfg[TestConfig]().AppName)
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v5\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "v5" }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestLoadConfig_ReplacesPreviousWatcher(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.toml"), filepath.Join(dir, "b.toml")
+	require.NoError(t, os.WriteFile(a, []byte("appName = \"a\"\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("appName = \"b\"\n"), 0644))
+
+	require.NoError(t, LoadConfig[TestConfig](a))
+	old := activeWatcher.closer.(*configFileWatch[TestConfig])
+	require.NoError(t, LoadConfigs[TestConfig](b))
+	<-old.done
+
+	// 之前的文件变化不再覆盖新加载的配置
+	require.NoError(t, os.WriteFile(a, []byte("appName = \"a2\"\n"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, "b", GetCfg[TestConfig]().AppName)
+}
+
+func TestWatch_AtomicReplace(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+
+	// 编辑器保存：写临时文件后 rename 覆盖
+	tmp := filepath.Join(dir, "config.toml.swp")
+	require.NoError(t, os.WriteFile(tmp, []byte("appName = \"renamed\"\n"), 0644))
+	require.NoError(t, os.Rename(tmp, path))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "renamed" }, 2*time.Second, 20*time.Millisecond)
+
+	// 删除后重建：替换之后监听仍然有效
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"recreated\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "recreated" }, 2*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"written\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "written" }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestWatch_ConfigMapSymlinkSwap(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	// Kubernetes ConfigMap 挂载的布局：config.toml -> ..data/config.toml，..data -> ..v1
+	dir := t.TempDir()
+	writeVersion := func(version, name string) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, version, "config.toml"), []byte("appName = \""+name+"\"\n"), 0644))
+	}
+	writeVersion("..v1", "v1")
+	require.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.toml"), filepath.Join(dir, "config.toml")))
+	require.NoError(t, LoadConfig[TestConfig](filepath.Join(dir, "config.toml")))
+
+	var changes atomic.Int32
+	OnConfigChange(func(*TestConfig) { changes.Add(1) })
+
+	writeVersion("..v2", "v2")
+	require.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "v2" }, 2*time.Second, 20*time.Millisecond)
+
+	// 突发的多个事件合并为一次重新加载
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), changes.Load())
+}
+
+func TestUpdateCfg_PersistsAndSerializes(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"orders\"\nport = 0\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+
+	var changes atomic.Int32
+	OnConfigChange(func(*TestConfig) { changes.Add(1) })
+
+	require.NoError(t, UpdateCfg(func(c *TestConfig) { c.Debug = true }))
+	assert.True(t, GetCfg[TestConfig]().Debug)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	persisted, err := ParseStrict[TestConfig](data)
+	require.NoError(t, err)
+	assert.Equal(t, TestConfig{AppName: "orders", Debug: true}, *persisted)
+
+	// 写回文件触发的热更新不会再次触发回调
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), changes.Load())
+
+	// 并发调用串行执行，每次都基于上一次写回的配置
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, UpdateCfg(func(c *TestConfig) { c.Port++ }))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 20, GetCfg[TestConfig]().Port)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "port = 20")
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(21), changes.Load())
+	assert.Equal(t, 20, GetCfg[TestConfig]().Port)
+}
+
+func TestLoadConfig_DefaultTagsOnLoadAndReload(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[server]\nport = 9090\n"), 0644))
+	require.NoError(t, LoadConfig[defaultsConfig](path))
+	assert.Equal(t, 9090, GetCfg[defaultsConfig]().Server.Port)
+	assert.Equal(t, "0.0.0.0", GetCfg[defaultsConfig]().Server.Host)
+
+	// 热更新时删掉的键恢复为默认值
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"orders\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[defaultsConfig]().AppName == "orders" }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 8080, GetCfg[defaultsConfig]().Server.Port)
+}
+
+func TestLoadConfig_EncryptedValuesOnReloadAndUpdate(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+	setTestKey(t, []byte("0123456789abcdef0123456789abcdef"))
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	v1 := mustEncrypt(t, "secret-v1")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \""+v1+"\"\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+	assert.Equal(t, "secret-v1", GetCfg[TestConfig]().AppName)
+
+	// 热更新重新解密
+	require.NoError(t, os.WriteFile(path, []byte("appName = \""+mustEncrypt(t, "secret-v2")+"\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "secret-v2" }, 2*time.Second, 20*time.Millisecond)
+
+	// 解密失败时保留之前的配置
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"ENC(AAAA)\"\nport = 1\n"), 0644))
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, "secret-v2", GetCfg[TestConfig]().AppName)
+
+	// UpdateCfg 写回时未修改的加密项保持密文
+	require.NoError(t, os.WriteFile(path, []byte("appName = \""+v1+"\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "secret-v1" }, 2*time.Second, 20*time.Millisecond)
+	require.NoError(t, UpdateCfg(func(c *TestConfig) { c.Port = 8080 }))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), v1)
+	assert.NotContains(t, string(data), "secret-v1")
+	assert.Equal(t, "secret-v1", GetCfg[TestConfig]().AppName)
+	assert.Equal(t, 8080, GetCfg[TestConfig]().Port)
+}
+
+func TestLoadConfig_FlagOverridesSurviveReloadAndUpdate(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	BindFlags[TestConfig](fs)
+	t.Cleanup(func() { boundFlags.Store(nil) })
+	require.NoError(t, fs.Parse([]string{"--port=9999"}))
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\nport = 8080\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+	assert.Equal(t, 9999, GetCfg[TestConfig]().Port)
+
+	// 热更新后参数仍然优先
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v2\"\nport = 8081\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[TestConfig]().AppName == "v2" }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 9999, GetCfg[TestConfig]().Port)
+
+	// UpdateCfg 不把一次性的参数写入文件
+	require.NoError(t, UpdateCfg(func(c *TestConfig) { c.Debug = true }))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "port = 8081")
+	assert.Equal(t, 9999, GetCfg[TestConfig]().Port)
+	assert.True(t, GetCfg[TestConfig]().Debug)
+}
+
+func TestDescribe_ProvenanceRuntimeUpdate(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\nport = 8080\n"), 0644))
+	require.NoError(t, LoadConfig[describeConfig](path))
+	assert.Equal(t, Source{Kind: SourceFile, Name: path}, findField(t, Describe[describeConfig](), "port").Source)
+
+	require.NoError(t, UpdateCfg(func(c *describeConfig) { c.Port = 9090 }))
+	doc := Describe[describeConfig]()
+	assert.Equal(t, Source{Kind: SourceUpdate, Name: path}, findField(t, doc, "port").Source)
+	assert.Equal(t, SourceFile, findField(t, doc, "appName").Source.Kind, "没有修改的配置项来源不变")
+
+	// 写回触发的热更新内容相同，仍显示为运行时修改
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, SourceUpdate, findField(t, Describe[describeConfig](), "port").Source.Kind)
+
+	// 文件被外部修改后按文件重新计算
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v2\"\nport = 9090\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[describeConfig]().AppName == "v2" }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, Source{Kind: SourceFile, Name: path}, findField(t, Describe[describeConfig](), "port").Source)
+}
+
+func TestReload_SingleFile(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	assert.ErrorIs(t, Reload[TestConfig](), ErrReloadUnsupported, "没有加载配置")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\nport = 1\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+	assert.ErrorIs(t, Reload[dirTestConfig](), ErrReloadUnsupported, "类型不同")
+
+	// 不等待文件事件：Reload 返回时新配置已经生效
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v2\"\nport = 1\n"), 0644))
+	require.NoError(t, Reload[TestConfig]())
+	assert.Equal(t, "v2", GetCfg[TestConfig]().AppName)
+
+	// 解析失败返回错误并保留之前的配置
+	require.NoError(t, os.WriteFile(path, []byte("appName = \n"), 0644))
+	assert.ErrorIs(t, Reload[TestConfig](), ErrConfigInvalid)
+	assert.Equal(t, "v2", GetCfg[TestConfig]().AppName)
+
+	// 与文件监听并发执行
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = os.WriteFile(path, []byte("appName = \"v3\"\nport = "+strconv.Itoa(i+1)+"\n"), 0644)
+			_ = Reload[TestConfig]()
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, Reload[TestConfig]())
+	assert.Equal(t, "v3", GetCfg[TestConfig]().AppName)
+
+	require.NoError(t, Close())
+	assert.ErrorIs(t, Reload[TestConfig](), ErrReloadUnsupported, "Close 之后")
+}
+
+func TestLastError_ReportsPositionAndConsecutiveFailures(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v1\"\nport = 1\n"), 0644))
+	require.NoError(t, LoadConfig[TestConfig](path))
+	assert.NoError(t, LastError())
+	assert.Equal(t, 0, ReloadFailures())
+
+	// 文件监听触发的热更新失败：错误中包含文件、行列号与键，之前的配置继续生效
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v2\"\nport = 1\ndebug = tru\n"), 0644))
+	require.Eventually(t, func() bool { return LastError() != nil }, 2*time.Second, 20*time.Millisecond)
+	err := LastError()
+	assert.ErrorIs(t, err, ErrConfigInvalid)
+	var de *DecodeError
+	require.ErrorAs(t, err, &de)
+	assert.Equal(t, 3, de.Line)
+	assert.Equal(t, 9, de.Column)
+	assert.Equal(t, "debug", de.Key)
+	assert.Contains(t, err.Error(), path)
+	assert.Contains(t, err.Error(), "第 3 行第 9 列（键 debug）")
+	assert.Equal(t, "v1", GetCfg[TestConfig]().AppName)
+
+	// 手动 Reload 同样计入连续失败次数
+	failures := ReloadFailures()
+	require.GreaterOrEqual(t, failures, 1)
+	assert.Error(t, Reload[TestConfig]())
+	assert.Equal(t, failures+1, ReloadFailures())
+
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"v3\"\nport = 1\n"), 0644))
+	require.NoError(t, Reload[TestConfig]())
+	assert.NoError(t, LastError())
+	assert.Equal(t, 0, ReloadFailures())
+
+	// 首次加载的错误同样带有位置
+	broken := filepath.Join(t.TempDir(), "broken.toml")
+	require.NoError(t, os.WriteFile(broken, []byte("[web\nport = 1\n"), 0644))
+	err = LoadConfig[TestConfig](broken)
+	require.ErrorAs(t, err, &de)
+	assert.Positive(t, de.Line)
+	assert.Contains(t, err.Error(), broken)
+}
+
+type unitsConfig struct {
+	Timeout     time.Duration `toml:"timeout"`
+	MaxFileSize ByteSize      `toml:"maxFileSize"`
+}
+
+func TestLoadConfig_DurationsAndByteSizesOnReloadAndUpdate(t *testing.T) {
+	previous := currentConfig.Load()
+	t.Cleanup(func() {
+		_ = Close()
+		currentConfig.Store(previous)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("timeout = \"30s\"\nmaxFileSize = \"10MB\"\n"), 0644))
+	require.NoError(t, LoadConfig[unitsConfig](path))
+	assert.Equal(t, unitsConfig{Timeout: 30 * time.Second, MaxFileSize: 10 * MB}, *GetCfg[unitsConfig]())
+
+	require.NoError(t, os.WriteFile(path, []byte("timeout = \"5m\"\nmaxFileSize = \"512KiB\"\n"), 0644))
+	require.Eventually(t, func() bool { return GetCfg[unitsConfig]().Timeout == 5*time.Minute }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 512*KiB, GetCfg[unitsConfig]().MaxFileSize)
+
+	// 写回时编码为带单位的字符串，重新解析得到相同的值
+	require.NoError(t, UpdateCfg(func(c *unitsConfig) { c.MaxFileSize = 10 * MiB }))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `timeout = "5m0s"`)
+	assert.Contains(t, string(data), `maxFileSize = "10MiB"`)
+	persisted, err := ParseStrict[unitsConfig](data)
+	require.NoError(t, err)
+	assert.Equal(t, *GetCfg[unitsConfig](), *persisted)
+}
@@ -0,0 +1,16 @@
+package common
+
+import "time"
+
+// Backoff 第 attempt 次失败后的重试间隔：首次为 base，之后逐次翻倍，不超过 max
+//
+// 使用方式：
+//
+//	next := clock.Now().Add(common.Backoff(30*time.Second, time.Hour, attempts))
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
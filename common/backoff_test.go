@@ -0,0 +1,17 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 30*time.Second, 100*time.Second
+	assert.Equal(t, base, Backoff(base, max, 0))
+	assert.Equal(t, base, Backoff(base, max, 1))
+	assert.Equal(t, 60*time.Second, Backoff(base, max, 2))
+	assert.Equal(t, max, Backoff(base, max, 3))
+	assert.Equal(t, max, Backoff(base, max, 1000), "翻倍到上限后停止，不溢出")
+}
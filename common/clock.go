@@ -0,0 +1,59 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 可注入的时钟接口
+//
+// 需要依赖当前时间的模块（维护窗口、限速等）通过此接口取时间，
+// 测试中注入 FakeClock 即可快进，无需真实等待
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 系统时钟
+type SystemClock struct{}
+
+// Now 返回当前系统时间
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock 手动推进的时钟（用于测试）
+//
+// 使用方式：
+//
+//	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+//	clock.Advance(time.Hour)
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock 创建停在 t 的时钟
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now 返回当前时钟时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 时钟前进 d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set 将时钟设置为 t
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
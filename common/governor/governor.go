@@ -0,0 +1,419 @@
+// Package governor 出站调用限速：按第三方服务商的配额（邮件 100 封/分钟、合作方 API 10 次/秒等）
+// 统一控制邮件、Webhook、HTTP 客户端等出站路径的速率
+//
+// 每个限速器有一个名字，按名字取令牌：阻塞路径用 Wait（随 ctx 取消），非阻塞路径用 Allow。
+// 配置 Store（如 Redis 令牌桶）后多个实例共享同一个桶，整个集群的速率不超过配额。
+// 对端返回 429 时调用 Throttled：按 Retry-After 暂停，adaptive 限速器同时把速率减半，
+// 之后每个恢复周期没有再被限流就翻倍，直到恢复配置的速率。未配置的名字不限速
+//
+// 使用方式：
+//
+//	// [outbound.limits]
+//	// sendgrid = "100/m"
+//	// partnerX = { rate = "10/s", burst = 20, adaptive = true }
+//	governor.Init(config.Outbound)
+//
+//	if err := governor.Wait(ctx, "partnerX"); err != nil {
+//	    return err // ctx 已取消
+//	}
+//	if !governor.Allow("sendgrid") {
+//	    return errRetryLater
+//	}
+package governor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+)
+
+// Limit 一个限速器的速率
+type Limit struct {
+	Rate     float64 // 每秒令牌数
+	Burst    int     // 桶容量（允许的突发量）
+	Adaptive bool    // 收到 429 时自动收紧速率
+}
+
+// ParseLimit 解析 "100/m"、"10/s"、"5000/h" 形式的速率（桶容量默认为一秒的量，至少 1）
+func ParseLimit(s string) (Limit, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("无效的速率: %q（格式如 100/m）", s)
+	}
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("无效的速率单位: %q（支持 s、m、h）", s)
+	}
+	rate := n / per.Seconds()
+	return Limit{Rate: rate, Burst: max(1, int(rate))}, nil
+}
+
+// UnmarshalTOML 支持字符串 "10/s" 或表 { rate = "10/s", burst = 20, adaptive = true }
+func (l *Limit) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		parsed, err := ParseLimit(v)
+		if err != nil {
+			return err
+		}
+		*l = parsed
+		return nil
+	case map[string]any:
+		s, _ := v["rate"].(string)
+		parsed, err := ParseLimit(s)
+		if err != nil {
+			return err
+		}
+		if burst, ok := v["burst"].(int64); ok && burst > 0 {
+			parsed.Burst = int(burst)
+		}
+		parsed.Adaptive, _ = v["adaptive"].(bool)
+		*l = parsed
+		return nil
+	}
+	return fmt.Errorf("无效的限速配置: %v", v)
+}
+
+// Config 出站限速配置
+//
+// Example:
+//
+//	[outbound]
+//	shared = true          # 通过 Redis 在实例之间共享令牌桶（需要配置 redis）
+//	recoverAfter = 30      # adaptive 收紧后的恢复周期（秒），默认 30
+//	minFactor = 0.1        # adaptive 最多收紧到配置速率的比例，默认 0.1
+//
+//	[outbound.limits]
+//	sendgrid = "100/m"
+//	partnerX = { rate = "10/s", burst = 20, adaptive = true }
+type Config struct {
+	Limits       map[string]Limit `toml:"limits"`
+	Shared       bool             `toml:"shared"`
+	Prefix       string           `toml:"prefix"` // 共享令牌桶的键前缀，默认 "governor:"
+	RecoverAfter int              `toml:"recoverAfter"`
+	MinFactor    float64          `toml:"minFactor"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Prefix == "" {
+		c.Prefix = "governor:"
+	}
+	if c.RecoverAfter <= 0 {
+		c.RecoverAfter = 30
+	}
+	if c.MinFactor <= 0 || c.MinFactor > 1 {
+		c.MinFactor = 0.1
+	}
+	return c
+}
+
+// Store 令牌桶存储（多个实例共享时使用 Redis 实现）
+type Store interface {
+	// Take 从 key 的令牌桶取一个令牌：取到时返回 0；否则不消耗令牌，返回还需要等待的时间
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error)
+}
+
+// 事件类型
+const (
+	EventAcquired  = "acquired"  // 取得令牌（Wait 为等待的时间）
+	EventThrottled = "throttled" // 没有令牌（Allow 被拒绝或 Wait 需要等待）
+	EventPenalized = "penalized" // 对端返回 429（Factor 为收紧后的速率比例）
+)
+
+// Event 限速事件（用于指标）
+type Event struct {
+	Limiter string
+	Type    string
+	Wait    time.Duration
+	Factor  float64
+}
+
+// Governor 一组命名限速器
+type Governor struct {
+	config   Config
+	clock    common.Clock
+	store    Store
+	local    *MemoryStore // 未配置共享存储或共享存储出错时使用
+	observer func(Event)
+	log      common.Logger
+	storeErr atomic.Bool         // 共享存储出错只记一次日志
+	limiters map[string]*limiter // 创建后只读
+}
+
+// limiter 一个限速器的自适应状态
+type limiter struct {
+	name  string
+	limit Limit
+
+	mu          sync.Mutex
+	factor      float64   // 当前速率 / 配置速率
+	adjusted    time.Time // 上次收紧或恢复的时间
+	pausedUntil time.Time // Retry-After 要求的暂停截止时间
+}
+
+// Option Governor 选项
+type Option func(*Governor)
+
+// WithStore 使用共享令牌桶存储（nil 表示只在本实例内限速）
+func WithStore(s Store) Option {
+	return func(g *Governor) { g.store = s }
+}
+
+// WithClock 使用指定的时钟（测试用）
+func WithClock(c common.Clock) Option {
+	return func(g *Governor) { g.clock = c }
+}
+
+// WithObserver 接收限速事件（用于指标）
+func WithObserver(fn func(Event)) Option {
+	return func(g *Governor) { g.observer = fn }
+}
+
+// WithLogger 使用指定的日志记录器（共享存储出错时记录）
+func WithLogger(log common.Logger) Option {
+	return func(g *Governor) { g.log = log }
+}
+
+// New 创建限速器组
+func New(config Config, opts ...Option) *Governor {
+	g := &Governor{
+		config:   config.withDefaults(),
+		clock:    common.SystemClock{},
+		local:    NewMemoryStore(),
+		log:      &common.DefaultLog{},
+		limiters: make(map[string]*limiter),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	for name, limit := range g.config.Limits {
+		if limit.Rate > 0 {
+			g.limiters[name] = &limiter{name: name, limit: limit, factor: 1}
+		}
+	}
+	return g
+}
+
+var (
+	defaultGovernor atomic.Pointer[Governor]
+	noLimits        = New(Config{})
+)
+
+// Init 用配置替换全局限速器组
+func Init(config Config, opts ...Option) {
+	defaultGovernor.Store(New(config, opts...))
+}
+
+// Default 全局限速器组（未初始化时不限速）
+func Default() *Governor {
+	if g := defaultGovernor.Load(); g != nil {
+		return g
+	}
+	return noLimits
+}
+
+// Wait 在全局限速器组中等待令牌
+func Wait(ctx context.Context, name string) error {
+	return Default().Wait(ctx, name)
+}
+
+// Allow 在全局限速器组中尝试取令牌（不等待）
+func Allow(name string) bool {
+	return Default().Allow(name)
+}
+
+// Throttled 向全局限速器组反馈对端的 429
+func Throttled(name string, retryAfter time.Duration) {
+	Default().Throttled(name, retryAfter)
+}
+
+// Wait 等待 name 的令牌（未配置的名字立即返回）；ctx 取消时返回 ctx.Err()
+func (g *Governor) Wait(ctx context.Context, name string) error {
+	l := g.get(name)
+	if l == nil {
+		return nil
+	}
+	start := g.clock.Now()
+	for attempt := 0; ; attempt++ {
+		wait := g.take(ctx, l)
+		if wait <= 0 {
+			g.observe(Event{Limiter: name, Type: EventAcquired, Wait: g.clock.Now().Sub(start)})
+			return nil
+		}
+		if attempt == 0 {
+			g.observe(Event{Limiter: name, Type: EventThrottled})
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Allow 尝试取 name 的令牌，没有令牌时立即返回 false（未配置的名字总是 true）
+func (g *Governor) Allow(name string) bool {
+	l := g.get(name)
+	if l == nil {
+		return true
+	}
+	if g.take(context.Background(), l) > 0 {
+		g.observe(Event{Limiter: name, Type: EventThrottled})
+		return false
+	}
+	g.observe(Event{Limiter: name, Type: EventAcquired})
+	return true
+}
+
+// Throttled 对端返回 429：在 retryAfter 内暂停该限速器，adaptive 限速器同时把速率减半
+func (g *Governor) Throttled(name string, retryAfter time.Duration) {
+	l := g.get(name)
+	if l == nil {
+		return
+	}
+	now := g.clock.Now()
+	l.mu.Lock()
+	if until := now.Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	if l.limit.Adaptive {
+		l.factor = max(g.config.MinFactor, l.factor/2)
+		l.adjusted = now
+	}
+	factor := l.factor
+	l.mu.Unlock()
+	g.observe(Event{Limiter: name, Type: EventPenalized, Factor: factor})
+}
+
+// Factor 当前速率占配置速率的比例（未收紧为 1，未配置的名字为 0）
+func (g *Governor) Factor(name string) float64 {
+	l := g.get(name)
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recover(g.clock.Now(), g.recoverAfter())
+	return l.factor
+}
+
+func (g *Governor) get(name string) *limiter {
+	if g == nil {
+		return nil
+	}
+	return g.limiters[name]
+}
+
+// take 取一个令牌，返回还需要等待的时间（0 表示已取得）
+func (g *Governor) take(ctx context.Context, l *limiter) time.Duration {
+	now := g.clock.Now()
+	l.mu.Lock()
+	if now.Before(l.pausedUntil) {
+		wait := l.pausedUntil.Sub(now)
+		l.mu.Unlock()
+		return wait
+	}
+	l.recover(now, g.recoverAfter())
+	limit := Limit{Rate: l.limit.Rate * l.factor, Burst: max(1, int(float64(l.limit.Burst)*l.factor))}
+	l.mu.Unlock()
+
+	if g.store != nil {
+		wait, err := g.store.Take(ctx, g.config.Prefix+l.name, limit, now)
+		if err == nil {
+			g.storeErr.Store(false)
+			return wait
+		}
+		if !g.storeErr.Swap(true) {
+			g.log.Errorf("[Governor] 共享令牌桶不可用，改为本实例限速: %v", err)
+		}
+	}
+	wait, _ := g.local.Take(ctx, l.name, limit, now)
+	return wait
+}
+
+func (g *Governor) recoverAfter() time.Duration {
+	return time.Duration(g.config.RecoverAfter) * time.Second
+}
+
+// recover 每个恢复周期没有再被限流，速率翻倍（调用方持有锁）
+func (l *limiter) recover(now time.Time, every time.Duration) {
+	for l.factor < 1 && now.Sub(l.adjusted) >= every {
+		l.factor = min(1, l.factor*2)
+		l.adjusted = l.adjusted.Add(every)
+	}
+}
+
+func (g *Governor) observe(e Event) {
+	if g.observer != nil {
+		g.observer(e)
+	}
+}
+
+// ParseRetryAfter 解析 Retry-After 响应头（秒数或 HTTP 日期），无法解析时返回 0
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(0, time.Duration(secs)*time.Second)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(0, t.Sub(now))
+	}
+	return 0
+}
+
+// MemoryStore 进程内令牌桶（同一进程内的多个 Governor 共享同一个 MemoryStore 时共享速率）
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryStore 创建进程内令牌桶
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take 实现 Store 接口
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.Rate
+		b.last = now
+	}
+	b.tokens = min(float64(limit.Burst), b.tokens) // 收紧后桶容量也随之缩小
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), nil
+}
@@ -0,0 +1,185 @@
+package governor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("100/m")
+	require.NoError(t, err)
+	assert.InDelta(t, 100.0/60, l.Rate, 1e-9)
+	assert.Equal(t, 1, l.Burst)
+
+	l, err = ParseLimit(" 10 / s ")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 10, Burst: 10}, l)
+
+	for _, bad := range []string{"", "10", "0/s", "-1/s", "10/d", "x/s"} {
+		_, err := ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+
+	var config Config
+	_, err = toml.Decode(`
+[limits]
+sendgrid = "100/m"
+partnerX = { rate = "10/s", burst = 20, adaptive = true }
+`, &config)
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 10, Burst: 20, Adaptive: true}, config.Limits["partnerX"])
+	assert.Equal(t, 1, config.Limits["sendgrid"].Burst)
+
+	_, err = toml.Decode("[limits]\nx = \"10/week\"\n", &config)
+	assert.ErrorContains(t, err, "无效的速率单位")
+}
+
+func TestGovernor_ClusterWideEnforcement(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC))
+	shared := NewMemoryStore()
+	config := Config{Limits: map[string]Limit{"partnerX": {Rate: 10, Burst: 10}}}
+	replicas := []*Governor{
+		New(config, WithStore(shared), WithClock(clock)),
+		New(config, WithStore(shared), WithClock(clock)),
+	}
+
+	allowed := func() int {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		n := 0
+		for _, g := range replicas {
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 10 {
+						if g.Allow("partnerX") {
+							mu.Lock()
+							n++
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+		}
+		wg.Wait()
+		return n
+	}
+
+	assert.Equal(t, 10, allowed(), "两个实例合计不超过桶容量")
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, 5, allowed(), "合计速率 10/s")
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 10, allowed(), "空闲后最多恢复到桶容量")
+
+	// 不共享存储时每个实例各自限速
+	alone := New(config, WithClock(clock))
+	other := New(config, WithClock(clock))
+	n := 0
+	for range 20 {
+		if alone.Allow("partnerX") {
+			n++
+		}
+		if other.Allow("partnerX") {
+			n++
+		}
+	}
+	assert.Equal(t, 20, n)
+	assert.True(t, alone.Allow("unconfigured"), "未配置的名字不限速")
+}
+
+func TestGovernor_AdaptiveTightening(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC))
+	var events []Event
+	g := New(Config{
+		Limits:       map[string]Limit{"partnerX": {Rate: 8, Burst: 8, Adaptive: true}, "sendgrid": {Rate: 8, Burst: 8}},
+		RecoverAfter: 60,
+		MinFactor:    0.25,
+	}, WithClock(clock), WithObserver(func(e Event) { events = append(events, e) }))
+
+	drain := func(name string) int {
+		n := 0
+		for g.Allow(name) {
+			n++
+		}
+		return n
+	}
+	assert.Equal(t, 8, drain("partnerX"))
+
+	// 模拟连续三次 429：按 Retry-After 暂停，速率减半直到下限
+	for range 3 {
+		g.Throttled("partnerX", 2*time.Second)
+	}
+	assert.Equal(t, 0.25, g.Factor("partnerX"))
+	clock.Advance(1900 * time.Millisecond)
+	assert.Equal(t, 0, drain("partnerX"), "Retry-After 期间暂停")
+
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, 2, drain("partnerX"), "桶容量随速率收紧")
+	clock.Advance(time.Second)
+	assert.Equal(t, 2, drain("partnerX"), "速率收紧为 2/s")
+
+	// 每个恢复周期没有再被限流，速率翻倍
+	clock.Advance(58 * time.Second)
+	assert.Equal(t, 0.5, g.Factor("partnerX"))
+	clock.Advance(60 * time.Second)
+	assert.Equal(t, 1.0, g.Factor("partnerX"))
+	assert.Equal(t, 8, drain("partnerX"))
+
+	// 非 adaptive 限速器只按 Retry-After 暂停
+	g.Throttled("sendgrid", time.Second)
+	assert.Equal(t, 1.0, g.Factor("sendgrid"))
+	assert.Equal(t, 0, drain("sendgrid"))
+	clock.Advance(time.Second)
+	assert.Equal(t, 8, drain("sendgrid"))
+
+	var penalized []float64
+	for _, e := range events {
+		if e.Type == EventPenalized && e.Limiter == "partnerX" {
+			penalized = append(penalized, e.Factor)
+		}
+	}
+	assert.Equal(t, []float64{0.5, 0.25, 0.25}, penalized)
+}
+
+func TestGovernor_WaitHonorsContext(t *testing.T) {
+	var mu sync.Mutex
+	var waits []time.Duration
+	g := New(Config{Limits: map[string]Limit{"slow": {Rate: 20, Burst: 1}}}, WithObserver(func(e Event) {
+		if e.Type == EventAcquired {
+			mu.Lock()
+			waits = append(waits, e.Wait)
+			mu.Unlock()
+		}
+	}))
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, g.Wait(context.Background(), "slow"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "第二、三个令牌各等待约 50ms")
+	require.Len(t, waits, 3)
+	assert.Greater(t, waits[2], 30*time.Millisecond)
+
+	g.Throttled("slow", time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, g.Wait(ctx, "slow"), context.DeadlineExceeded)
+	assert.NoError(t, g.Wait(ctx, "unconfigured"))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, ParseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, ParseRetryAfter("Fri, 01 May 2026 10:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter("-5", now))
+}
@@ -0,0 +1,29 @@
+package common
+
+import "errors"
+
+// ControlFlowPanic 有意用于控制流程的 panic 值（如 panic(web.BadRequestHTTP(...))）
+//
+// 恢复层据此区分业务异常与真正的 bug：控制流程 panic 按错误响应直接渲染，
+// 不采集堆栈、不触发 OnPanic 上报、不计入错误指纹
+type ControlFlowPanic interface {
+	ControlFlowPanic()
+}
+
+// IsControlFlow recover 到的值是否为控制流程 panic（error 值沿 Unwrap 链查找）
+//
+// 使用方式：
+//
+//	if r := recover(); r != nil && !common.IsControlFlow(r) {
+//	    reportCrash(r, debug.Stack())
+//	}
+func IsControlFlow(recovered any) bool {
+	if _, ok := recovered.(ControlFlowPanic); ok {
+		return true
+	}
+	if err, ok := recovered.(error); ok {
+		var cf ControlFlowPanic
+		return errors.As(err, &cf)
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package common
+
+import "golang.org/x/crypto/bcrypt"
+
+// PasswordCost HashPassword 使用的 bcrypt 代价
+var PasswordCost = bcrypt.DefaultCost
+
+// HashPassword 使用 bcrypt 计算密码哈希
+//
+// 使用方式：
+//
+//	hash, err := common.HashPassword(req.Password)
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithCost(password, PasswordCost)
+}
+
+// HashPasswordWithCost 使用指定代价计算密码哈希（测试数据可用 bcrypt.MinCost 加快速度）
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验密码与哈希是否匹配
+//
+// 使用方式：
+//
+//	if !common.CheckPassword(user.PasswordHash, req.Password) {
+//	    panic(web.UnauthorizedHTTP("用户名或密码错误"))
+//	}
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
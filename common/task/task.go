@@ -0,0 +1,627 @@
+// Package task 周期任务调度：多个实例（副本）同时运行时，每个计划时间点只由一个实例执行
+//
+// 每次执行前以 "任务名 + 计划时间" 为键取锁（Store.Acquire），取到锁的实例执行，其他实例记录
+// "skipped: not leader" 后跳过。执行期间按锁 TTL 的 1/3 续期，续期失败（锁已过期被其他实例取得，
+// 或共享存储长时间不可用）时取消任务的 ctx，context.Cause(ctx) 为 ErrLockLost。
+// 执行完成后锁保留 retain 时长，时钟稍慢的实例不会再次执行同一个计划时间点。
+//
+// 未配置共享存储时使用进程内存储，单实例部署行为不变；多实例部署使用 cache.NewTaskStore（Redis）。
+// 每个任务最近一次执行的状态（计划时间、执行实例、耗时、结果）写入共享存储，供健康检查与调试接口汇总
+//
+// 使用方式：
+//
+//	// [task]
+//	// lockTtl = 30
+//	// [task.tasks.nightlyReport]
+//	// catchUp = "once"
+//	scheduler := task.New(config.Task, task.WithStore(cache.NewTaskStore(cache.Client)))
+//	scheduler.Register("nightlyReport", task.Daily(2, 0, time.Local), generateReport)
+//	scheduler.Register("cleanup", task.Every(10*time.Minute), cleanup, task.WithCatchUp(task.CatchUpSkip))
+//	scheduler.Start()
+//	defer scheduler.Stop(context.Background())
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+)
+
+// ErrLockLost 执行期间失去了锁（任务 ctx 的取消原因）
+var ErrLockLost = errors.New("task: 执行锁已失去")
+
+// 执行结果（Status.Outcome）
+const (
+	OutcomeRunning     = "running"
+	OutcomeOK          = "ok"
+	OutcomeFailed      = "failed"
+	OutcomeLockLost    = "lock lost"
+	OutcomeNotLeader   = "skipped: not leader"
+	OutcomeOverlap     = "skipped: previous run still running"
+	OutcomeUnavailable = "skipped: lock unavailable" // 共享存储出错，无法确认其他实例没有执行
+)
+
+// CatchUp 所有实例都停止期间错过的计划时间点的处理策略（实例启动时检查）
+type CatchUp string
+
+const (
+	CatchUpSkip CatchUp = "skip" // 跳过，等待下一个计划时间点（默认）
+	CatchUpOnce CatchUp = "once" // 立即补执行一次（只补最近一个错过的时间点）
+)
+
+// Schedule 计划时间
+type Schedule interface {
+	// Next 严格晚于 t 的下一个计划时间点
+	Next(t time.Time) time.Time
+}
+
+// Every 每隔 d 执行一次，计划时间点按 Unix 纪元对齐（各实例算出的时间点相同）
+func Every(d time.Duration) Schedule {
+	if d <= 0 {
+		panic("task: Every 的间隔必须大于 0")
+	}
+	return every(d)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// Daily 每天在 loc 时区的 hour:minute 执行一次
+func Daily(hour, minute int, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.Local
+	}
+	return daily{hour: hour, minute: minute, loc: loc}
+}
+
+type daily struct {
+	hour, minute int
+	loc          *time.Location
+}
+
+func (d daily) Next(t time.Time) time.Time {
+	t = t.In(d.loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.hour, d.minute, 0, 0, d.loc)
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, d.hour, d.minute, 0, 0, d.loc)
+	}
+	return next
+}
+
+// Func 任务函数；ctx 在调度器停止或失去执行锁时取消
+type Func func(ctx context.Context) error
+
+// Status 任务一次执行（或跳过）的状态
+type Status struct {
+	Name       string        `json:"name"`
+	Occurrence time.Time     `json:"occurrence"` // 计划时间点
+	Instance   string        `json:"instance"`
+	StartedAt  time.Time     `json:"startedAt"`
+	Duration   time.Duration `json:"duration"`
+	Outcome    string        `json:"outcome"`
+	Error      string        `json:"error,omitempty"`
+	Late       bool          `json:"late,omitempty"` // 启动时补执行的错过时间点
+}
+
+// Store 执行锁与状态存储（多个实例共享时使用 Redis 实现）
+type Store interface {
+	// Acquire 以 owner 身份取锁，锁已被持有时返回 false
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration, now time.Time) (bool, error)
+	// Renew 续期 owner 持有的锁，锁已过期或被其他实例持有时返回 false
+	Renew(ctx context.Context, key, owner string, ttl time.Duration, now time.Time) (bool, error)
+	// Release 执行完成：锁再保留 retain 时长（期间其他实例无法取得），之后自动删除
+	Release(ctx context.Context, key, owner string, retain time.Duration, now time.Time) error
+	// SaveStatus 保存任务最近一次的状态（key 为状态集合，按任务名覆盖）
+	SaveStatus(ctx context.Context, key string, status Status) error
+	// Statuses 状态集合中全部任务的状态
+	Statuses(ctx context.Context, key string) (map[string]Status, error)
+}
+
+// TaskConfig 单个任务的配置（覆盖 Register 时的选项）
+type TaskConfig struct {
+	CatchUp CatchUp `toml:"catchUp"`
+}
+
+// Config 调度配置
+//
+// Example:
+//
+//	[task]
+//	prefix = "task:"          # 共享存储的键前缀，默认 "task:"
+//	lockTtl = 30              # 执行锁 TTL（秒），每 1/3 TTL 续期一次，默认 30
+//	retain = 86400            # 执行完成后锁的保留时长（秒），默认 86400
+//	instance = "web-1"        # 实例名，默认 主机名-进程号
+//
+//	[task.tasks.nightlyReport]
+//	catchUp = "once"          # skip | once
+type Config struct {
+	Prefix   string                `toml:"prefix"`
+	LockTTL  int                   `toml:"lockTtl"`
+	Retain   int                   `toml:"retain"`
+	Instance string                `toml:"instance"`
+	Tasks    map[string]TaskConfig `toml:"tasks"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Prefix == "" {
+		c.Prefix = "task:"
+	}
+	if c.LockTTL <= 0 {
+		c.LockTTL = 30
+	}
+	if c.Retain <= 0 {
+		c.Retain = 86400
+	}
+	if c.Instance == "" {
+		hostname, _ := os.Hostname()
+		c.Instance = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return c
+}
+
+// Scheduler 周期任务调度器
+type Scheduler struct {
+	config Config
+	store  Store
+	clock  common.Clock
+	tick   time.Duration
+	log    common.Logger
+
+	mu        sync.Mutex
+	tasks     []*task
+	names     map[string]bool
+	running   map[*execution]struct{}
+	local     map[string]Status // 本实例记录的最近状态（含跳过）
+	storeErr  atomic.Bool       // 共享存储出错只记一次日志
+	started   bool
+	stop      chan struct{}
+	loopDone  chan struct{}
+	ctx       context.Context
+	cancelAll context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// task 注册的任务
+type task struct {
+	name     string
+	schedule Schedule
+	fn       Func
+	catchUp  CatchUp
+	next     time.Time   // 下一个计划时间点（零值表示尚未初始化）
+	busy     atomic.Bool // 本实例正在执行
+}
+
+// execution 本实例正在执行、需要续期的锁
+type execution struct {
+	key     string
+	renewed time.Time
+	cancel  context.CancelCauseFunc
+}
+
+// Option Scheduler 选项
+type Option func(*Scheduler)
+
+// WithStore 使用共享存储（nil 表示进程内存储，只在本实例内去重）
+func WithStore(s Store) Option {
+	return func(sc *Scheduler) {
+		if s != nil {
+			sc.store = s
+		}
+	}
+}
+
+// WithClock 使用指定的时钟（测试用）
+func WithClock(c common.Clock) Option {
+	return func(s *Scheduler) { s.clock = c }
+}
+
+// WithTick 检查计划时间与续期的间隔，默认 1 秒
+func WithTick(d time.Duration) Option {
+	return func(s *Scheduler) { s.tick = d }
+}
+
+// WithLogger 使用指定的日志记录器
+func WithLogger(log common.Logger) Option {
+	return func(s *Scheduler) { s.log = log }
+}
+
+// TaskOption 任务选项
+type TaskOption func(*task)
+
+// WithCatchUp 错过时间点的处理策略（配置文件中的 catchUp 优先）
+func WithCatchUp(policy CatchUp) TaskOption {
+	return func(t *task) { t.catchUp = policy }
+}
+
+// New 创建调度器
+func New(config Config, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		config:  config.withDefaults(),
+		store:   NewMemoryStore(),
+		clock:   common.SystemClock{},
+		tick:    time.Second,
+		log:     &common.DefaultLog{},
+		names:   make(map[string]bool),
+		running: make(map[*execution]struct{}),
+		local:   make(map[string]Status),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancelAll = context.WithCancel(context.Background())
+	return s
+}
+
+// Register 注册任务（任务名在所有实例中相同，用于取锁与汇总状态）；重复的任务名返回错误
+func (s *Scheduler) Register(name string, schedule Schedule, fn Func, opts ...TaskOption) error {
+	t := &task{name: name, schedule: schedule, fn: fn, catchUp: CatchUpSkip}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if tc, ok := s.config.Tasks[name]; ok && tc.CatchUp != "" {
+		t.catchUp = tc.CatchUp
+	}
+	if t.catchUp != CatchUpSkip && t.catchUp != CatchUpOnce {
+		return fmt.Errorf("task: 任务 %s 的 catchUp 无效: %q（支持 skip、once）", name, t.catchUp)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names[name] {
+		return fmt.Errorf("task: 任务 %s 已注册", name)
+	}
+	s.names[name] = true
+	s.tasks = append(s.tasks, t)
+	return nil
+}
+
+// Start 启动调度（重复调用为空操作）
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.stop = make(chan struct{})
+	s.loopDone = make(chan struct{})
+	go s.loop()
+}
+
+// Stop 停止调度并等待执行中的任务结束；ctx 到期时取消它们的 ctx 后返回 ctx.Err()
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	started := s.started
+	s.started = false
+	s.mu.Unlock()
+	if started {
+		close(s.stop)
+		<-s.loopDone
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelAll()
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.loopDone)
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	s.runDue()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.runDue()
+		}
+	}
+}
+
+// runDue 续期执行中的锁，启动到期的任务
+func (s *Scheduler) runDue() {
+	now := s.clock.Now()
+	s.renew(now)
+
+	s.mu.Lock()
+	tasks := append([]*task(nil), s.tasks...)
+	s.mu.Unlock()
+	for _, t := range tasks {
+		if t.next.IsZero() {
+			t.next = t.schedule.Next(now)
+			if t.catchUp == CatchUpOnce {
+				if missed, ok := s.missed(t, now); ok {
+					s.launch(t, missed, true)
+				}
+			}
+			continue
+		}
+		if now.Before(t.next) {
+			continue
+		}
+		// 调度停顿（如进程被挂起）期间的多个时间点只执行最近一个
+		occurrence := t.next
+		for next := t.schedule.Next(occurrence); !next.After(now); next = t.schedule.Next(next) {
+			occurrence = next
+		}
+		t.next = t.schedule.Next(occurrence)
+		s.launch(t, occurrence, false)
+	}
+}
+
+// missed 所有实例停止期间错过的最近一个计划时间点（从未执行过的任务不补执行）
+func (s *Scheduler) missed(t *task, now time.Time) (time.Time, bool) {
+	statuses, err := s.store.Statuses(s.ctx, s.statusKey())
+	if err != nil {
+		s.log.Errorf("[Task] 读取任务 %s 的状态失败，不补执行: %v", t.name, err)
+		return time.Time{}, false
+	}
+	last, ok := statuses[t.name]
+	if !ok {
+		return time.Time{}, false
+	}
+	var missed time.Time
+	for next := t.schedule.Next(last.Occurrence); !next.After(now); next = t.schedule.Next(next) {
+		missed = next
+	}
+	return missed, !missed.IsZero()
+}
+
+// launch 在新的协程中争取并执行一个计划时间点
+func (s *Scheduler) launch(t *task, occurrence time.Time, late bool) {
+	if !t.busy.CompareAndSwap(false, true) {
+		s.record(Status{Name: t.name, Occurrence: occurrence, Instance: s.config.Instance, Outcome: OutcomeOverlap, Late: late}, false)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer t.busy.Store(false)
+		s.execute(t, occurrence, late)
+	}()
+}
+
+func (s *Scheduler) execute(t *task, occurrence time.Time, late bool) {
+	status := Status{Name: t.name, Occurrence: occurrence, Instance: s.config.Instance, Late: late}
+	key := fmt.Sprintf("%slock:%s:%d", s.config.Prefix, t.name, occurrence.Unix())
+	ttl := time.Duration(s.config.LockTTL) * time.Second
+
+	now := s.clock.Now()
+	acquired, err := s.store.Acquire(s.ctx, key, s.config.Instance, ttl, now)
+	if err != nil {
+		s.storeFailed(err)
+		status.Outcome, status.Error = OutcomeUnavailable, err.Error()
+		s.record(status, false)
+		return
+	}
+	if !acquired {
+		status.Outcome = OutcomeNotLeader
+		s.record(status, false)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	defer cancel(nil)
+	exec := &execution{key: key, renewed: now, cancel: cancel}
+	s.mu.Lock()
+	s.running[exec] = struct{}{}
+	s.mu.Unlock()
+
+	status.StartedAt = now
+	status.Outcome = OutcomeRunning
+	s.record(status, true)
+
+	err = run(ctx, t.fn)
+
+	s.mu.Lock()
+	delete(s.running, exec)
+	s.mu.Unlock()
+	status.Duration = s.clock.Now().Sub(status.StartedAt)
+	switch {
+	case errors.Is(context.Cause(ctx), ErrLockLost):
+		status.Outcome = OutcomeLockLost
+		if err != nil {
+			status.Error = err.Error()
+		}
+	case err != nil:
+		status.Outcome, status.Error = OutcomeFailed, err.Error()
+		s.log.Errorf("[Task] 任务 %s（%s）执行失败: %v", t.name, occurrence.Format(time.RFC3339), err)
+	default:
+		status.Outcome = OutcomeOK
+	}
+	if status.Outcome != OutcomeLockLost {
+		retain := time.Duration(s.config.Retain) * time.Second
+		if err := s.store.Release(context.WithoutCancel(ctx), key, s.config.Instance, retain, s.clock.Now()); err != nil {
+			s.storeFailed(err)
+		}
+	}
+	// 失去锁时其他实例可能已经在执行，状态只记在本实例，不覆盖共享存储中的记录
+	s.record(status, status.Outcome != OutcomeLockLost)
+}
+
+// run 执行任务函数（panic 视为失败）
+func run(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx)
+}
+
+// renew 续期执行中的锁：每 1/3 TTL 一次；锁被其他实例取得，或连续一个 TTL 无法续期时取消任务
+func (s *Scheduler) renew(now time.Time) {
+	ttl := time.Duration(s.config.LockTTL) * time.Second
+	s.mu.Lock()
+	var due []*execution
+	for exec := range s.running {
+		if now.Sub(exec.renewed) >= ttl/3 {
+			due = append(due, exec)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, exec := range due {
+		ok, err := s.store.Renew(s.ctx, exec.key, s.config.Instance, ttl, now)
+		switch {
+		case err != nil:
+			s.storeFailed(err)
+			if now.Sub(exec.renewed) >= ttl {
+				exec.cancel(ErrLockLost)
+			}
+		case !ok:
+			exec.cancel(ErrLockLost)
+		default:
+			exec.renewed = now
+		}
+	}
+}
+
+// record 记录状态：本实例的最近状态总是更新，执行过的状态同时写入共享存储
+func (s *Scheduler) record(status Status, shared bool) {
+	s.mu.Lock()
+	s.local[status.Name] = status
+	s.mu.Unlock()
+	if !shared {
+		return
+	}
+	if err := s.store.SaveStatus(context.WithoutCancel(s.ctx), s.statusKey(), status); err != nil {
+		s.storeFailed(err)
+	}
+}
+
+func (s *Scheduler) statusKey() string {
+	return s.config.Prefix + "status"
+}
+
+// storeFailed 记录共享存储错误（连续出错只记一次）
+func (s *Scheduler) storeFailed(err error) {
+	if s.storeErr.CompareAndSwap(false, true) {
+		s.log.Errorf("[Task] 共享存储出错: %v", err)
+	}
+}
+
+// Statuses 所有实例汇总的各任务最近一次执行状态（按任务名排序）
+func (s *Scheduler) Statuses(ctx context.Context) ([]Status, error) {
+	statuses, err := s.store.Statuses(ctx, s.statusKey())
+	if err != nil {
+		return nil, err
+	}
+	s.storeErr.Store(false)
+	return sortedStatuses(statuses), nil
+}
+
+// LocalStatuses 本实例记录的各任务最近状态（包含跳过的时间点，按任务名排序）
+func (s *Scheduler) LocalStatuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedStatuses(s.local)
+}
+
+func sortedStatuses(m map[string]Status) []Status {
+	list := make([]Status, 0, len(m))
+	for _, st := range m {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// MemoryStore 进程内存储（单实例部署）
+type MemoryStore struct {
+	mu       sync.Mutex
+	locks    map[string]memoryLock
+	statuses map[string]map[string]Status
+}
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{locks: make(map[string]memoryLock), statuses: make(map[string]map[string]Status)}
+}
+
+// Acquire 实现 Store 接口
+func (m *MemoryStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.locks[key]; ok && now.Before(l.expires) {
+		return false, nil
+	}
+	m.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Renew 实现 Store 接口
+func (m *MemoryStore) Renew(ctx context.Context, key, owner string, ttl time.Duration, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.locks[key]
+	if !ok || l.owner != owner || !now.Before(l.expires) {
+		return false, nil
+	}
+	m.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release 实现 Store 接口
+func (m *MemoryStore) Release(ctx context.Context, key, owner string, retain time.Duration, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.locks[key]; ok && l.owner == owner {
+		m.locks[key] = memoryLock{owner: owner, expires: now.Add(retain)}
+	}
+	// 顺带清理过期的锁
+	for k, l := range m.locks {
+		if !now.Before(l.expires) {
+			delete(m.locks, k)
+		}
+	}
+	return nil
+}
+
+// SaveStatus 实现 Store 接口
+func (m *MemoryStore) SaveStatus(ctx context.Context, key string, status Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statuses[key] == nil {
+		m.statuses[key] = make(map[string]Status)
+	}
+	m.statuses[key][status.Name] = status
+	return nil
+}
+
+// Statuses 实现 Store 接口
+func (m *MemoryStore) Statuses(ctx context.Context, key string) (map[string]Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]Status, len(m.statuses[key]))
+	for name, st := range m.statuses[key] {
+		out[name] = st
+	}
+	return out, nil
+}
@@ -0,0 +1,242 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runs 按计划时间点记录执行的实例
+type runs struct {
+	mu sync.Mutex
+	by map[time.Time][]string
+}
+
+func (r *runs) add(occurrence time.Time, instance string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.by == nil {
+		r.by = make(map[time.Time][]string)
+	}
+	r.by[occurrence] = append(r.by[occurrence], instance)
+}
+
+func (r *runs) get(occurrence time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.by[occurrence]
+}
+
+// newReplica 共享存储与时钟的一个实例（测试中时钟停在计划时间点上，执行的时间点按时钟推算）
+func newReplica(t *testing.T, name string, store Store, clock *common.FakeClock, config Config) *Scheduler {
+	config.Instance = name
+	s := New(config, WithStore(store), WithClock(clock))
+	t.Cleanup(func() { _ = s.Stop(context.Background()) })
+	return s
+}
+
+func TestScheduler_ExactlyOncePerOccurrence(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC))
+	store := NewMemoryStore()
+	var executed runs
+	replicas := []*Scheduler{
+		newReplica(t, "a", store, clock, Config{}),
+		newReplica(t, "b", store, clock, Config{}),
+		newReplica(t, "c", store, clock, Config{}),
+	}
+	for _, s := range replicas {
+		require.NoError(t, s.Register("report", Every(time.Minute), func(ctx context.Context) error {
+			executed.add(clock.Now().Truncate(time.Minute), s.config.Instance)
+			return nil
+		}))
+		s.runDue()
+	}
+
+	for range 10 {
+		clock.Advance(time.Minute)
+		var wg sync.WaitGroup
+		for _, s := range replicas {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.runDue()
+			}()
+		}
+		wg.Wait()
+		for _, s := range replicas {
+			s.wg.Wait()
+		}
+	}
+
+	start := time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC)
+	for i := range 10 {
+		occurrence := start.Add(time.Duration(i) * time.Minute)
+		assert.Len(t, executed.get(occurrence), 1, "%s", occurrence)
+	}
+
+	// 其他实例记录跳过
+	var skipped int
+	for _, s := range replicas {
+		if s.LocalStatuses()[0].Outcome == OutcomeNotLeader {
+			skipped++
+		}
+	}
+	assert.Equal(t, 2, skipped)
+
+	statuses, err := replicas[0].Statuses(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, OutcomeOK, statuses[0].Outcome)
+	assert.Equal(t, start.Add(9*time.Minute), statuses[0].Occurrence)
+}
+
+func TestScheduler_FailoverAndLockLoss(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC))
+	store := NewMemoryStore()
+	var executed runs
+	started := make(chan struct{})
+	lost := make(chan error, 1)
+
+	leader := newReplica(t, "a", store, clock, Config{LockTTL: 30})
+	follower := newReplica(t, "b", store, clock, Config{LockTTL: 30})
+	require.NoError(t, leader.Register("cleanup", Every(time.Minute), func(ctx context.Context) error {
+		executed.add(clock.Now().Truncate(time.Minute), "a")
+		close(started)
+		<-ctx.Done() // 卡住，直到失去锁
+		lost <- context.Cause(ctx)
+		return ctx.Err()
+	}))
+	require.NoError(t, follower.Register("cleanup", Every(time.Minute), func(ctx context.Context) error {
+		executed.add(clock.Now().Truncate(time.Minute), "b")
+		return nil
+	}))
+	leader.runDue()
+	follower.runDue()
+
+	first := time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC)
+	clock.Set(first)
+	leader.runDue()
+	<-started
+	follower.runDue()
+	follower.wg.Wait()
+	assert.Equal(t, OutcomeNotLeader, follower.LocalStatuses()[0].Outcome)
+
+	// leader 停止响应（不再续期），下一个时间点由 follower 执行
+	clock.Set(first.Add(time.Minute))
+	follower.runDue()
+	follower.wg.Wait()
+	assert.Equal(t, []string{"a"}, executed.get(first))
+	assert.Equal(t, []string{"b"}, executed.get(first.Add(time.Minute)))
+
+	// leader 恢复后续期失败：任务的 ctx 被取消，原因为 ErrLockLost
+	clock.Advance(10 * time.Second)
+	leader.runDue()
+	select {
+	case cause := <-lost:
+		assert.ErrorIs(t, cause, ErrLockLost)
+	case <-time.After(2 * time.Second):
+		t.Fatal("失去锁后任务的 ctx 应被取消")
+	}
+	leader.wg.Wait()
+	assert.Equal(t, []string{"b"}, executed.get(first.Add(time.Minute)), "已执行的时间点不会再执行")
+
+	// 失去锁的结果不覆盖共享状态
+	statuses, err := leader.Statuses(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "b", statuses[0].Instance)
+	assert.Equal(t, OutcomeOK, statuses[0].Outcome)
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	for _, policy := range []CatchUp{CatchUpOnce, CatchUpSkip} {
+		t.Run(string(policy), func(t *testing.T) {
+			store := NewMemoryStore()
+			last := time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC)
+			require.NoError(t, store.SaveStatus(context.Background(), "task:status", Status{Name: "report", Occurrence: last, Outcome: OutcomeOK}))
+
+			// 所有实例停止期间错过了 00:02 到 00:05，两个实例同时启动
+			clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 5, 30, 0, time.UTC))
+			var executed runs
+			var replicas []*Scheduler
+			for _, name := range []string{"a", "b"} {
+				s := newReplica(t, name, store, clock, Config{Tasks: map[string]TaskConfig{"report": {CatchUp: policy}}})
+				require.NoError(t, s.Register("report", Every(time.Minute), func(ctx context.Context) error {
+					executed.add(time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC), name)
+					return nil
+				}))
+				replicas = append(replicas, s)
+			}
+			for _, s := range replicas {
+				s.runDue()
+				s.wg.Wait()
+			}
+
+			missed := executed.get(time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC))
+			if policy == CatchUpSkip {
+				assert.Empty(t, missed)
+				return
+			}
+			assert.Len(t, missed, 1, "只补执行一次")
+			statuses, err := replicas[0].Statuses(context.Background())
+			require.NoError(t, err)
+			assert.True(t, statuses[0].Late)
+			assert.Equal(t, time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC), statuses[0].Occurrence)
+		})
+	}
+}
+
+func TestScheduler_SingleInstanceLoop(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC))
+	s := New(Config{}, WithClock(clock), WithTick(5*time.Millisecond))
+	var mu sync.Mutex
+	calls := 0
+	require.NoError(t, s.Register("report", Every(time.Minute), func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 2 {
+			return errors.New("上游不可用")
+		}
+		if calls == 3 {
+			panic("boom")
+		}
+		return nil
+	}))
+	assert.Error(t, s.Register("report", Every(time.Minute), nil), "重复的任务名")
+	assert.Error(t, s.Register("bad", Every(time.Minute), nil, WithCatchUp("always")))
+
+	s.Start()
+	outcomes := []string{OutcomeOK, OutcomeFailed, OutcomeFailed}
+	for i, want := range outcomes {
+		time.Sleep(20 * time.Millisecond)
+		clock.Advance(time.Minute)
+		require.Eventually(t, func() bool {
+			st := s.LocalStatuses()
+			return len(st) == 1 && st[0].Occurrence.Minute() == i+1 && st[0].Outcome != OutcomeRunning
+		}, 2*time.Second, 5*time.Millisecond, fmt.Sprintf("第 %d 次", i+1))
+		assert.Equal(t, want, s.LocalStatuses()[0].Outcome)
+	}
+	assert.Contains(t, s.LocalStatuses()[0].Error, "panic: boom")
+	require.NoError(t, s.Stop(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, calls)
+}
+
+func TestSchedules(t *testing.T) {
+	at := time.Date(2026, 3, 1, 10, 7, 12, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 10, 10, 0, 0, time.UTC), Every(5*time.Minute).Next(at))
+	assert.Equal(t, time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC), Every(5*time.Minute).Next(time.Date(2026, 3, 1, 10, 10, 0, 0, time.UTC)))
+
+	shanghai := time.FixedZone("CST", 8*3600)
+	assert.Equal(t, time.Date(2026, 3, 2, 2, 0, 0, 0, shanghai), Daily(2, 0, shanghai).Next(at)) // 当地 18:07，已过 02:00
+	assert.Equal(t, time.Date(2026, 3, 1, 20, 0, 0, 0, shanghai), Daily(20, 0, shanghai).Next(at))
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+var (
+	consoleColor  atomic.Bool // 控制台输出的级别是否带颜色
+	colorExplicit atomic.Bool // SetColor 设置过，Init 不再自动检测
+)
+
+// SetColor 开关控制台输出的颜色，运行时生效
+//
+// 默认自动检测：标准输出是终端且未设置 NO_COLOR 环境变量时带颜色，重定向到文件、journald
+// 或 CI 时输出纯文本（与日志文件格式相同）。调用后不再自动检测，Init 也不会覆盖
+//
+// 使用方式：
+//
+//	logger.SetColor(false) // 容器日志采集不需要颜色
+func SetColor(enabled bool) {
+	colorExplicit.Store(true)
+	consoleColor.Store(enabled)
+}
+
+// detectColor 输出是否支持颜色（Windows 控制台同时开启 VT 处理，开启失败时不带颜色）
+//
+// 遵循 https://no-color.org：NO_COLOR 非空时不带颜色
+func detectColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	return enableVirtualTerminal(f)
+}
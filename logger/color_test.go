@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer 并发安全的内存输出（非终端）
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func useStdout(t *testing.T, w *syncBuffer) {
+	prev := stdout
+	stdout = w
+	t.Cleanup(func() {
+		stdout = prev
+		colorExplicit.Store(false)
+		require.NoError(t, Init(Options{}))
+	})
+	require.NoError(t, Init(Options{}))
+}
+
+func TestColor_DisabledForNonTTY(t *testing.T) {
+	out := &syncBuffer{}
+	useStdout(t, out)
+
+	Warn("磁盘空间不足")
+	assert.Contains(t, out.String(), "WARN  磁盘空间不足")
+	assert.NotContains(t, out.String(), "\033[")
+
+	SetColor(true)
+	Warn("强制开启颜色")
+	assert.Contains(t, out.String(), colorYellow+"WARN "+colorReset)
+	require.NoError(t, Init(Options{}))
+	Error("Init 不覆盖 SetColor")
+	assert.Contains(t, out.String(), colorRed+"ERROR"+colorReset)
+
+	SetColor(false)
+	Error("关闭颜色")
+	assert.Contains(t, out.String(), "ERROR 关闭颜色")
+}
+
+func TestDetectColor(t *testing.T) {
+	assert.False(t, detectColor(&syncBuffer{}))
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.log"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.False(t, detectColor(f), "重定向到文件")
+
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("没有终端")
+	}
+	defer tty.Close()
+	assert.True(t, detectColor(tty))
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, detectColor(tty))
+}
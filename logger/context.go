@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// RequestIDKey context.Context 中存储请求 ID 的键（middleware.RequestIDMiddleware 写入，middleware.RequestIDKey 是它的别名）
+type RequestIDKey struct{}
+
+// TraceIDKey context.Context 中存储链路追踪 ID 的键（接入链路追踪时写入）
+type TraceIDKey struct{}
+
+// Ctx 返回带 ctx 中请求 ID（reqId 字段）与链路追踪 ID（traceId 字段）的子日志记录器
+//
+// ctx 中都没有时返回不带字段的记录器，与 Named / With 共用输出与日志级别
+//
+// 使用方式：
+//
+//	func (s *OrderService) Create(ctx context.Context, req CreateOrderReq) error {
+//	    logger.Ctx(ctx).Infof("创建订单: %s", req.SKU)
+//	}
+func Ctx(ctx context.Context) *zap.SugaredLogger {
+	return withContext(childRoot, ctx)
+}
+
+func withContext(l *zap.SugaredLogger, ctx context.Context) *zap.SugaredLogger {
+	if ctx == nil {
+		return l
+	}
+	var fields []any
+	if id, ok := ctx.Value(RequestIDKey{}).(string); ok && id != "" {
+		fields = append(fields, "reqId", id)
+	}
+	if id, ok := ctx.Value(TraceIDKey{}).(string); ok && id != "" {
+		fields = append(fields, "traceId", id)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
+
+// InfoCtx 带请求 ID 的 Info 日志（格式化）
+func InfoCtx(ctx context.Context, format string, args ...any) {
+	withContext(ctxRoot, ctx).Infof(format, args...)
+}
+
+// WarnCtx 带请求 ID 的 Warn 日志（格式化）
+func WarnCtx(ctx context.Context, format string, args ...any) {
+	withContext(ctxRoot, ctx).Warnf(format, args...)
+}
+
+// ErrorCtx 带请求 ID 的 Error 日志（格式化），按调用位置计入错误指纹统计
+func ErrorCtx(ctx context.Context, format string, args ...any) {
+	withContext(ctxRoot, ctx).Errorf(format, args...)
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCtx_RequestAndTraceID(t *testing.T) {
+	UpdateLogLevel("info")
+	ctx := context.WithValue(context.Background(), RequestIDKey{}, "req-1")
+	Ctx(ctx).Info("ctx-test request")
+	Ctx(context.WithValue(ctx, TraceIDKey{}, "trace-1")).Info("ctx-test trace")
+	Ctx(context.Background()).Info("ctx-test empty")
+
+	records := RecentLogs(3)
+	require.Len(t, records, 3)
+	assert.Equal(t, map[string]any{"reqId": "req-1"}, records[0].Fields)
+	assert.Equal(t, map[string]any{"reqId": "req-1", "traceId": "trace-1"}, records[1].Fields)
+	assert.Empty(t, records[2].Fields)
+}
+
+func TestErrorCtx_FingerprintedAtCaller(t *testing.T) {
+	useTracker(t, ErrorTrackingConfig{}, nil)
+	ctx := context.WithValue(context.Background(), RequestIDKey{}, "req-2")
+
+	InfoCtx(ctx, "ctx-test %d", 1)
+	assert.Equal(t, "req-2", RecentLogs(1)[0].Fields["reqId"])
+	ErrorCtx(ctx, "扣款失败: %s", "timeout")
+	record := RecentLogs(1)[0]
+	assert.Equal(t, "扣款失败: timeout", record.Message)
+	assert.Equal(t, "req-2", record.Fields["reqId"])
+
+	fps := ErrorFingerprints()
+	require.Len(t, fps, 1)
+	assert.Contains(t, fps[0].Frame, "TestErrorCtx_FingerprintedAtCaller context_test.go:", "调用位置跳过包装函数")
+}
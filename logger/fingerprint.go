@@ -0,0 +1,398 @@
+package logger
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+)
+
+// 错误告警类型
+const (
+	ErrorAlertNew   = "new"   // 首次出现的指纹（常见于发布引入的回归）
+	ErrorAlertSpike = "spike" // 窗口内次数达到阈值
+)
+
+// ErrorTrackingConfig 错误指纹统计配置
+//
+// Error/Errorf 按「格式串 + 调用位置」计算指纹（与插值参数无关），Named / With 返回的子记录器
+// 按调用位置计算，按时间桶统计次数，定期输出 Top N 汇总日志；Debug/Info/Warn 不受影响
+//
+// Example:
+//
+//	[web.errors]
+//	capacity = 500          # 最多跟踪的指纹数，超出时淘汰最久未出现的
+//	bucketSeconds = 60      # 时间桶宽度（秒）
+//	buckets = 15            # 时间桶数量，统计窗口 = 宽度 × 数量
+//	summaryInterval = 300   # 汇总日志间隔（秒），0 表示不输出
+//	topN = 10               # 汇总日志与告警中的指纹数
+//	spikeThreshold = 100    # 窗口内次数达到阈值时触发告警，0 表示关闭
+//	ignoreNew = false       # true 时新指纹不触发告警
+type ErrorTrackingConfig struct {
+	Capacity        int   `toml:"capacity"`
+	BucketSeconds   int   `toml:"bucketSeconds"`
+	Buckets         int   `toml:"buckets"`
+	SummaryInterval int   `toml:"summaryInterval"`
+	TopN            int   `toml:"topN"`
+	SpikeThreshold  int64 `toml:"spikeThreshold"`
+	IgnoreNew       bool  `toml:"ignoreNew"`
+}
+
+func (c ErrorTrackingConfig) withDefaults() ErrorTrackingConfig {
+	if c.Capacity <= 0 {
+		c.Capacity = 500
+	}
+	if c.BucketSeconds <= 0 {
+		c.BucketSeconds = 60
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 15
+	}
+	if c.TopN <= 0 {
+		c.TopN = 10
+	}
+	return c
+}
+
+// ErrorFingerprint 一类错误的统计
+type ErrorFingerprint struct {
+	ID        string    `json:"id"`        // 指纹（16 位十六进制）
+	Format    string    `json:"format"`    // 格式串（未插值；Named / With 的子记录器为首次出现的消息）
+	Frame     string    `json:"frame"`     // 调用位置：函数 文件:行
+	Sample    string    `json:"sample"`    // 最近一次的完整消息
+	Count     int64     `json:"count"`     // 统计窗口内的次数
+	Total     int64     `json:"total"`     // 开始跟踪以来的次数
+	FirstSeen time.Time `json:"firstSeen"` // 首次出现
+	LastSeen  time.Time `json:"lastSeen"`  // 最近出现
+}
+
+// ErrorAlert 错误告警
+type ErrorAlert struct {
+	Kind        string           // ErrorAlertNew / ErrorAlertSpike
+	Fingerprint ErrorFingerprint // 触发告警的指纹
+	Window      time.Duration    // 统计窗口
+}
+
+// ErrorAlertHook 错误告警回调（在独立协程中调用）
+type ErrorAlertHook func(alert ErrorAlert)
+
+// fingerprintEntry 单个指纹的时间桶
+type fingerprintEntry struct {
+	fp       ErrorFingerprint
+	counts   []int64 // 环形时间桶
+	epochs   []int64 // 每个桶对应的时间段编号
+	spiking  bool    // 已触发 spike，回落到阈值一半以下后重新启用
+	position *list.Element
+}
+
+// errorTracker 错误指纹统计（LRU 限制内存）
+type errorTracker struct {
+	config ErrorTrackingConfig
+	clock  common.Clock
+
+	mu      sync.Mutex
+	entries map[string]*fingerprintEntry
+	lru     *list.List // 前端为最近出现
+
+	hooksMu sync.RWMutex
+	hooks   []ErrorAlertHook
+}
+
+func newErrorTracker(config ErrorTrackingConfig, clock common.Clock) *errorTracker {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &errorTracker{
+		config:  config.withDefaults(),
+		clock:   clock,
+		entries: make(map[string]*fingerprintEntry),
+		lru:     list.New(),
+	}
+}
+
+// window 统计窗口
+func (t *errorTracker) window() time.Duration {
+	return time.Duration(t.config.BucketSeconds*t.config.Buckets) * time.Second
+}
+
+func (t *errorTracker) epoch(now time.Time) int64 {
+	return now.Unix() / int64(t.config.BucketSeconds)
+}
+
+// record 记录一次错误
+func (t *errorTracker) record(format, frame, message string) {
+	id := fingerprintID(format, frame)
+	now := t.clock.Now()
+	epoch := t.epoch(now)
+
+	t.mu.Lock()
+	e, ok := t.entries[id]
+	if !ok {
+		e = &fingerprintEntry{
+			fp:     ErrorFingerprint{ID: id, Format: cmp.Or(format, message), Frame: frame, FirstSeen: now},
+			counts: make([]int64, t.config.Buckets),
+			epochs: make([]int64, t.config.Buckets),
+		}
+		e.position = t.lru.PushFront(e)
+		t.entries[id] = e
+		if t.lru.Len() > t.config.Capacity {
+			oldest := t.lru.Remove(t.lru.Back()).(*fingerprintEntry)
+			delete(t.entries, oldest.fp.ID)
+		}
+	} else {
+		t.lru.MoveToFront(e.position)
+	}
+
+	i := epoch % int64(len(e.counts))
+	if e.epochs[i] != epoch {
+		e.epochs[i], e.counts[i] = epoch, 0
+	}
+	e.counts[i]++
+	e.fp.Total++
+	e.fp.LastSeen = now
+	e.fp.Sample = message
+	count := e.windowCount(epoch)
+
+	var alerts []ErrorAlert
+	if !ok && !t.config.IgnoreNew {
+		alerts = append(alerts, ErrorAlert{Kind: ErrorAlertNew, Fingerprint: e.snapshot(count), Window: t.window()})
+	}
+	if threshold := t.config.SpikeThreshold; threshold > 0 {
+		if !e.spiking && count >= threshold {
+			e.spiking = true
+			alerts = append(alerts, ErrorAlert{Kind: ErrorAlertSpike, Fingerprint: e.snapshot(count), Window: t.window()})
+		} else if e.spiking && count < threshold/2 {
+			e.spiking = false
+		}
+	}
+	t.mu.Unlock()
+
+	if len(alerts) > 0 {
+		t.fire(alerts)
+	}
+}
+
+// windowCount 窗口内的次数
+func (e *fingerprintEntry) windowCount(epoch int64) int64 {
+	var total int64
+	oldest := epoch - int64(len(e.counts))
+	for i, ep := range e.epochs {
+		if ep > oldest && ep <= epoch {
+			total += e.counts[i]
+		}
+	}
+	return total
+}
+
+func (e *fingerprintEntry) snapshot(count int64) ErrorFingerprint {
+	fp := e.fp
+	fp.Count = count
+	return fp
+}
+
+func (t *errorTracker) fire(alerts []ErrorAlert) {
+	t.hooksMu.RLock()
+	hooks := t.hooks
+	t.hooksMu.RUnlock()
+	for _, hook := range hooks {
+		for _, alert := range alerts {
+			go hook(alert)
+		}
+	}
+}
+
+func (t *errorTracker) onAlert(hook ErrorAlertHook) {
+	t.hooksMu.Lock()
+	t.hooks = append(t.hooks, hook)
+	t.hooksMu.Unlock()
+}
+
+// fingerprints 窗口内出现过的指纹，按窗口内次数降序
+func (t *errorTracker) fingerprints() []ErrorFingerprint {
+	epoch := t.epoch(t.clock.Now())
+	t.mu.Lock()
+	out := make([]ErrorFingerprint, 0, len(t.entries))
+	for _, e := range t.entries {
+		if count := e.windowCount(epoch); count > 0 {
+			out = append(out, e.snapshot(count))
+		}
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// summary 汇总日志（窗口内没有错误时返回空）
+func (t *errorTracker) summary() string {
+	fps := t.fingerprints()
+	if len(fps) == 0 {
+		return ""
+	}
+	var total int64
+	for _, fp := range fps {
+		total += fp.Count
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Errors] 最近 %v 共 %d 次错误，%d 类", t.window(), total, len(fps))
+	if len(fps) > t.config.TopN {
+		fps = fps[:t.config.TopN]
+	}
+	for _, fp := range fps {
+		fmt.Fprintf(&b, " | %s x%d (首次 %s, 最近 %s) %q @ %s", fp.ID, fp.Count,
+			fp.FirstSeen.Format("01-02 15:04:05"), fp.LastSeen.Format("15:04:05"), fp.Format, fp.Frame)
+	}
+	return b.String()
+}
+
+// fingerprintID 格式串 + 调用位置的 FNV-1a 哈希
+func fingerprintID(format, frame string) string {
+	h := fnv.New64a()
+	h.Write([]byte(format))
+	h.Write([]byte{0})
+	h.Write([]byte(frame))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// callerFrame 调用位置描述（skip 从 callerFrame 的调用方算起）
+func callerFrame(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	return describeFrame(runtime.FuncForPC(pc), file, line)
+}
+
+// panicFrame 触发 panic 的位置（在 defer/recover 中调用）；找不到时返回调用方位置
+func panicFrame(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	afterPanic := false
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime.gopanic" {
+			afterPanic = true
+		} else if afterPanic && !strings.HasPrefix(f.Function, "runtime.") {
+			return describeFrame(runtime.FuncForPC(f.PC), f.File, f.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return callerFrame(skip + 1)
+}
+
+func describeFrame(fn *runtime.Func, file string, line int) string {
+	name := "unknown"
+	if fn != nil {
+		name = fn.Name()
+	}
+	return fmt.Sprintf("%s %s:%d", name, filepath.Base(file), line)
+}
+
+var (
+	tracker       atomic.Pointer[errorTracker]
+	summaryCancel context.CancelFunc
+	summaryMu     sync.Mutex
+)
+
+func init() {
+	tracker.Store(newErrorTracker(ErrorTrackingConfig{}, nil))
+}
+
+// InitErrorTracking 设置错误指纹统计配置并启动汇总日志（重复调用时替换之前的配置，已注册的告警钩子保留）
+//
+// 使用方式：
+//
+//	logger.InitErrorTracking(logger.ErrorTrackingConfig{SummaryInterval: 300, SpikeThreshold: 100})
+func InitErrorTracking(config ErrorTrackingConfig) {
+	next := newErrorTracker(config, nil)
+	if prev := tracker.Load(); prev != nil {
+		prev.hooksMu.RLock()
+		next.hooks = prev.hooks
+		prev.hooksMu.RUnlock()
+	}
+	tracker.Store(next)
+
+	summaryMu.Lock()
+	defer summaryMu.Unlock()
+	if summaryCancel != nil {
+		summaryCancel()
+		summaryCancel = nil
+	}
+	if config.SummaryInterval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	summaryCancel = cancel
+	go func() {
+		ticker := time.NewTicker(time.Duration(config.SummaryInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if line := tracker.Load().summary(); line != "" {
+					zapSugarLogger.Warn(line)
+				}
+			}
+		}
+	}()
+}
+
+// OnErrorAlert 注册错误告警钩子：新指纹出现或窗口内次数达到 spikeThreshold 时调用
+//
+// 使用方式：
+//
+//	logger.OnErrorAlert(func(a logger.ErrorAlert) {
+//	    notifyOnCall(a.Kind, a.Fingerprint.Format, a.Fingerprint.Count)
+//	})
+func OnErrorAlert(hook ErrorAlertHook) {
+	tracker.Load().onAlert(hook)
+}
+
+// ErrorFingerprints 统计窗口内出现过的错误指纹，按次数降序（供调试接口输出）
+func ErrorFingerprints() []ErrorFingerprint {
+	return tracker.Load().fingerprints()
+}
+
+// ErrorSummary 当前的汇总日志内容（窗口内没有错误时返回空）
+func ErrorSummary() string {
+	return tracker.Load().summary()
+}
+
+// ErrorPanic 记录 recover 到的 panic（指纹取 panic 值的类型与触发 panic 的位置）
+//
+// 在 defer 的 recover 分支中调用
+//
+// 使用方式：
+//
+//	defer func() {
+//	    if r := recover(); r != nil {
+//	        logger.ErrorPanic(r, "[PANIC] %v", r)
+//	    }
+//	}()
+func ErrorPanic(recovered any, format string, args ...any) {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	tracker.Load().record(fmt.Sprintf("panic %T", recovered), panicFrame(1), message)
+	zapSugarLogger.Error(message)
+}
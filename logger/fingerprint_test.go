@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []ErrorAlert
+}
+
+func (r *alertRecorder) hook(a ErrorAlert) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, a)
+}
+
+func (r *alertRecorder) kinds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, a := range r.alerts {
+		out = append(out, a.Kind)
+	}
+	return out
+}
+
+// useTracker 替换全局统计（测试结束后恢复）
+func useTracker(t *testing.T, config ErrorTrackingConfig, clock common.Clock) (*errorTracker, *alertRecorder) {
+	prev := tracker.Load()
+	tr := newErrorTracker(config, clock)
+	rec := &alertRecorder{}
+	tr.onAlert(rec.hook)
+	tracker.Store(tr)
+	t.Cleanup(func() { tracker.Store(prev) })
+	return tr, rec
+}
+
+func logQueryFailure(table string, err error) {
+	Errorf("[DB] 查询 %s 失败: %v", table, err)
+}
+
+func TestErrorFingerprint_StableAcrossArgs(t *testing.T) {
+	useTracker(t, ErrorTrackingConfig{}, nil)
+
+	logQueryFailure("users", errors.New("timeout"))
+	logQueryFailure("orders", errors.New("connection reset"))
+	logQueryFailure("posts", errors.New("deadlock"))
+	// 相同格式串、不同调用位置是不同的错误
+	Errorf("[DB] 查询 %s 失败: %v", "users", errors.New("timeout"))
+
+	fps := ErrorFingerprints()
+	require.Len(t, fps, 2)
+	assert.Equal(t, int64(3), fps[0].Count)
+	assert.Equal(t, "[DB] 查询 %s 失败: %v", fps[0].Format)
+	assert.Contains(t, fps[0].Frame, "logQueryFailure fingerprint_test.go:")
+	assert.Equal(t, "[DB] 查询 posts 失败: deadlock", fps[0].Sample)
+	assert.Equal(t, int64(1), fps[1].Count)
+	assert.NotEqual(t, fps[0].ID, fps[1].ID)
+	assert.Len(t, fps[0].ID, 16)
+
+	// 指纹只取决于格式串与位置
+	assert.Equal(t, fps[0].ID, fingerprintID(fps[0].Format, fps[0].Frame))
+}
+
+func TestErrorFingerprint_NewHookFiresOnce(t *testing.T) {
+	_, rec := useTracker(t, ErrorTrackingConfig{}, nil)
+
+	for i := 0; i < 20; i++ {
+		logQueryFailure("users", errors.New(strings.Repeat("x", i)))
+	}
+	require.Eventually(t, func() bool { return len(rec.kinds()) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{ErrorAlertNew}, rec.kinds())
+	rec.mu.Lock()
+	assert.Equal(t, int64(1), rec.alerts[0].Fingerprint.Count)
+	rec.mu.Unlock()
+
+	// 非错误级别不参与统计
+	Warnf("[DB] 查询 %s 失败: %v", "users", "slow")
+	Infof("info %d", 1)
+	assert.Len(t, ErrorFingerprints(), 1)
+}
+
+func TestErrorFingerprint_SpikeAndWindow(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	tr, rec := useTracker(t, ErrorTrackingConfig{BucketSeconds: 60, Buckets: 5, SpikeThreshold: 4, IgnoreNew: true}, clock)
+
+	for i := 0; i < 3; i++ {
+		tr.record("boom %d", "f a.go:1", "boom")
+		clock.Advance(30 * time.Second)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, rec.kinds())
+
+	// 达到阈值触发一次，持续超过阈值不再重复触发
+	for i := 0; i < 5; i++ {
+		tr.record("boom %d", "f a.go:1", "boom")
+	}
+	require.Eventually(t, func() bool { return len(rec.kinds()) == 1 }, time.Second, 5*time.Millisecond)
+	rec.mu.Lock()
+	assert.Equal(t, ErrorAlertSpike, rec.alerts[0].Kind)
+	assert.Equal(t, int64(4), rec.alerts[0].Fingerprint.Count)
+	assert.Equal(t, 5*time.Minute, rec.alerts[0].Window)
+	rec.mu.Unlock()
+
+	fps := tr.fingerprints()
+	require.Len(t, fps, 1)
+	assert.Equal(t, int64(8), fps[0].Count)
+	assert.Equal(t, int64(8), fps[0].Total)
+
+	// 窗口过去后计数清零，回落后再次超过阈值重新告警
+	clock.Advance(6 * time.Minute)
+	assert.Empty(t, tr.fingerprints())
+	assert.Empty(t, tr.summary())
+	tr.record("boom %d", "f a.go:1", "boom")
+	for i := 0; i < 3; i++ {
+		tr.record("boom %d", "f a.go:1", "boom")
+	}
+	require.Eventually(t, func() bool { return len(rec.kinds()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(12), tr.fingerprints()[0].Total)
+}
+
+func TestErrorFingerprint_LRUBounded(t *testing.T) {
+	tr, _ := useTracker(t, ErrorTrackingConfig{Capacity: 2, IgnoreNew: true}, nil)
+
+	tr.record("a", "f a.go:1", "a")
+	tr.record("b", "f a.go:2", "b")
+	tr.record("a", "f a.go:1", "a") // a 变为最近出现
+	tr.record("c", "f a.go:3", "c") // 淘汰 b
+
+	var formats []string
+	for _, fp := range tr.fingerprints() {
+		formats = append(formats, fp.Format)
+	}
+	assert.ElementsMatch(t, []string{"a", "c"}, formats)
+	assert.Equal(t, 2, tr.lru.Len())
+}
+
+func panicIndex(i int) {
+	var s []int
+	_ = s[i]
+}
+
+func recoverPanic(i int) {
+	defer func() {
+		if r := recover(); r != nil {
+			ErrorPanic(r, "[PANIC] %v", r)
+		}
+	}()
+	panicIndex(i)
+}
+
+func TestErrorFingerprint_Panic(t *testing.T) {
+	useTracker(t, ErrorTrackingConfig{}, nil)
+
+	recoverPanic(1)
+	recoverPanic(7)
+
+	fps := ErrorFingerprints()
+	require.Len(t, fps, 1)
+	assert.Equal(t, int64(2), fps[0].Count)
+	assert.Equal(t, "panic runtime.boundsError", fps[0].Format)
+	// 位置为触发 panic 的函数，而不是 recover 所在的函数
+	assert.Contains(t, fps[0].Frame, "panicIndex fingerprint_test.go:")
+	assert.Contains(t, fps[0].Sample, "index out of range [7]")
+}
+
+func TestErrorFingerprint_Summary(t *testing.T) {
+	tr, _ := useTracker(t, ErrorTrackingConfig{TopN: 1, IgnoreNew: true}, nil)
+	tr.record("a %d", "f a.go:1", "a 1")
+	tr.record("a %d", "f a.go:1", "a 2")
+	tr.record("b", "f a.go:2", "b")
+
+	line := tr.summary()
+	assert.Contains(t, line, "共 3 次错误，2 类")
+	assert.Contains(t, line, fingerprintID("a %d", "f a.go:1")+" x2")
+	assert.Contains(t, line, `"a %d" @ f a.go:1`)
+	assert.NotContains(t, line, `"b"`)
+}
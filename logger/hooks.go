@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// errorHookQueueSize 每个错误钩子的队列长度，队列满时丢弃新的日志（计入 ErrorHookStats.Dropped）
+const errorHookQueueSize = 1024
+
+// LogEntry 错误钩子收到的一条日志（时间、级别、模块名、消息与字段，与 LogRecord 相同）
+type LogEntry = LogRecord
+
+// ErrorHookStats 错误钩子的丢弃与 panic 计数（进程启动以来的累计值）
+type ErrorHookStats struct {
+	Dropped uint64 `json:"dropped"` // 队列已满而丢弃的日志
+	Panics  uint64 `json:"panics"`  // 钩子中发生并被吞掉的 panic
+}
+
+// errorHook OnError 注册的钩子：独立的队列与处理协程，慢钩子只会让自己的队列变满
+type errorHook struct {
+	fn    func(LogEntry)
+	queue chan LogEntry
+}
+
+var (
+	errorHooks  atomic.Pointer[[]*errorHook] // 注册与移除持有 outputsMu 写锁，写日志时无锁读取
+	hookDropped atomic.Uint64
+	hookPanics  atomic.Uint64
+)
+
+// OnError 注册错误钩子：Error 及以上级别的日志写入后异步调用 hook（如发送告警邮件、调用 Webhook），
+// 返回的 remove 移除该钩子
+//
+// 每个钩子有自己的有界队列与处理协程，写日志只做一次非阻塞入队，慢钩子不会阻塞日志，也不影响其他钩子；
+// 队列满时丢弃新的日志。钩子中的 panic 被吞掉，记录一条 Warn 日志；丢弃与 panic 次数见 ReadErrorHookStats。
+// 钩子不受全局日志级别影响（级别设为 fatal 时 Error 日志不输出，但仍会调用钩子）。
+// 采样（SetSampling）只作用于 Debug / Info，Error 日志从不被采样丢弃，因此每条 Error 日志都会调用钩子；
+// 钩子只收到实际写入的日志，被采样丢弃的不会调用。
+// 钩子中不要再记录 Error 级别的日志（会再次触发钩子）；Fatal 之后进程立即退出，排队中的日志可能来不及处理
+//
+// 使用方式：
+//
+//	remove := logger.OnError(func(e logger.LogEntry) {
+//	    _ = email.Send(ops, "[告警] "+e.Message, fmt.Sprintf("%s %v", e.Time.Format(time.RFC3339), e.Fields))
+//	})
+//	defer remove()
+func OnError(hook func(entry LogEntry)) (remove func()) {
+	h := &errorHook{fn: hook, queue: make(chan LogEntry, errorHookQueueSize)}
+	go h.run()
+
+	outputsMu.Lock()
+	var hooks []*errorHook
+	if cur := errorHooks.Load(); cur != nil {
+		hooks = slices.Clone(*cur)
+	}
+	hooks = append(hooks, h)
+	errorHooks.Store(&hooks)
+	outputsMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			outputsMu.Lock()
+			defer outputsMu.Unlock()
+			hooks := slices.DeleteFunc(slices.Clone(*errorHooks.Load()), func(x *errorHook) bool { return x == h })
+			errorHooks.Store(&hooks)
+			close(h.queue)
+		})
+	}
+}
+
+// ReadErrorHookStats 错误钩子的丢弃与 panic 计数
+func ReadErrorHookStats() ErrorHookStats {
+	return ErrorHookStats{Dropped: hookDropped.Load(), Panics: hookPanics.Load()}
+}
+
+// run 处理队列中的日志，直到钩子被移除
+func (h *errorHook) run() {
+	for entry := range h.queue {
+		h.call(entry)
+	}
+}
+
+func (h *errorHook) call(entry LogEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			hookPanics.Add(1)
+			pkgRoot.Warnf("[Logger] 错误钩子 panic（已忽略）: %v", r)
+		}
+	}()
+	h.fn(entry)
+}
+
+// errorHooksEnabled 是否有错误钩子接收该级别的日志
+func errorHooksEnabled(l zapcore.Level) bool {
+	if l < zapcore.ErrorLevel {
+		return false
+	}
+	hooks := errorHooks.Load()
+	return hooks != nil && len(*hooks) > 0
+}
+
+// fireErrorHooks 把日志放入每个错误钩子的队列，不阻塞，队列满时丢弃
+// （调用方持有 outputsMu 读锁，移除钩子需要写锁，因此不会向已关闭的队列发送）
+func fireErrorHooks(entry zapcore.Entry, fieldSets ...[]zapcore.Field) {
+	if !errorHooksEnabled(entry.Level) {
+		return
+	}
+	rec := newLogRecord(entry, fieldSets...)
+	for _, h := range *errorHooks.Load() {
+		select {
+		case h.queue <- rec:
+		default:
+			hookDropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnError_DeliversEntries(t *testing.T) {
+	UpdateLogLevel("info")
+	entries := make(chan LogEntry, 10)
+	remove := OnError(func(e LogEntry) { entries <- e })
+	defer remove()
+
+	Info("hook-info")
+	Named("billing").Errorw("hook-error", "order", 42)
+
+	select {
+	case e := <-entries:
+		assert.Equal(t, "error", e.Level)
+		assert.Equal(t, "billing", e.Logger)
+		assert.Equal(t, "hook-error", e.Message)
+		assert.Equal(t, map[string]any{"order": int64(42)}, e.Fields)
+		assert.False(t, e.Time.IsZero())
+	case <-time.After(time.Second):
+		t.Fatal("钩子未被调用")
+	}
+
+	// 全局级别高于 Error 时日志不输出，钩子仍然收到
+	UpdateLogLevel("fatal")
+	Error("hook-above-level")
+	UpdateLogLevel("info")
+	select {
+	case e := <-entries:
+		assert.Equal(t, "hook-above-level", e.Message)
+	case <-time.After(time.Second):
+		t.Fatal("钩子不应受全局级别影响")
+	}
+
+	remove()
+	remove()
+	Error("hook-removed")
+	select {
+	case e := <-entries:
+		t.Fatalf("移除后仍收到: %s", e.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOnError_SlowHookAndPanics(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	removeSlow := OnError(func(LogEntry) { <-release })
+	defer removeSlow()
+	defer once.Do(func() { close(release) })
+
+	var mu sync.Mutex
+	var got []string
+	removePanic := OnError(func(e LogEntry) {
+		mu.Lock()
+		got = append(got, e.Message)
+		mu.Unlock()
+		panic("boom")
+	})
+	defer removePanic()
+
+	before := ReadErrorHookStats()
+	start := time.Now()
+	for range errorHookQueueSize + 10 {
+		Error("hook-flood")
+	}
+	assert.Less(t, time.Since(start), 2*time.Second, "慢钩子不阻塞日志")
+
+	assert.GreaterOrEqual(t, ReadErrorHookStats().Dropped-before.Dropped, uint64(9), "慢钩子的队列满时丢弃")
+	require.Eventually(t, func() bool {
+		return ReadErrorHookStats().Panics-before.Panics >= 2
+	}, 5*time.Second, 10*time.Millisecond, "其他钩子不受慢钩子影响，panic 被吞掉并计数")
+	mu.Lock()
+	assert.GreaterOrEqual(t, len(got), 2, "panic 之后钩子继续处理")
+	mu.Unlock()
+	once.Do(func() { close(release) })
+}
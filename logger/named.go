@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Named 返回带模块名的子日志记录器（输出为 "时间 级别 模块 消息"）
+//
+// 子记录器与全局记录器共用输出（Init 替换输出后同样生效）与日志级别（UpdateLogLevel 立即生效），
+// 多级名称用 "." 连接（logger.Named("web").Named("ws") 输出 web.ws）。
+// Error 及以上级别按调用位置计入错误指纹统计，消息中不插值、把变化的部分放在字段里（Errorw）时汇总更清晰
+//
+// 使用方式：
+//
+//	var log = logger.Named("billing")
+//
+//	log.Infof("账单已生成: %d", id)
+//	log.Errorw("扣款失败", "orderId", orderID, "error", err)
+func Named(name string) *zap.SugaredLogger {
+	return childRoot.Named(name)
+}
+
+// With 返回带固定字段的子日志记录器（如请求 ID、用户 ID），与 Named 共用输出与日志级别
+//
+// 使用方式：
+//
+//	log := logger.With("userId", userID, "traceId", traceID)
+//	log.Info("下单")
+func With(keysAndValues ...any) *zap.SugaredLogger {
+	return childRoot.With(keysAndValues...)
+}
+
+// trackEntry 子记录器的 Error 及以上级别日志计入错误指纹（按调用位置，格式串在插值后不可得）
+func trackEntry(entry zapcore.Entry) error {
+	if entry.Level < zapcore.ErrorLevel {
+		return nil
+	}
+	frame := "unknown"
+	if entry.Caller.Defined {
+		frame = fmt.Sprintf("%s %s:%d", entry.Caller.Function, filepath.Base(entry.Caller.File), entry.Caller.Line)
+	}
+	tracker.Load().record("", frame, entry.Message)
+	return nil
+}
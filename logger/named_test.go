@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamed_NameAndFields(t *testing.T) {
+	UpdateLogLevel("info")
+	Named("billing").Infof("named-test %d", 1)
+	Named("web").Named("ws").With("userId", 42).Warn("named-test fields")
+	With("traceId", "abc").Info("with-test")
+
+	records := RecentLogs(3)
+	require.Len(t, records, 3)
+	assert.Equal(t, "billing", records[0].Logger)
+	assert.Equal(t, "named-test 1", records[0].Message)
+	assert.Equal(t, "web.ws", records[1].Logger)
+	assert.EqualValues(t, 42, records[1].Fields["userId"])
+	assert.Empty(t, records[2].Logger)
+	assert.Equal(t, "abc", records[2].Fields["traceId"])
+}
+
+func TestNamed_FollowsLogLevel(t *testing.T) {
+	log := Named("level-test")
+	UpdateLogLevel("warn")
+	t.Cleanup(func() { UpdateLogLevel("info") })
+
+	log.Info("named-level hidden")
+	assert.NotEqual(t, "named-level hidden", RecentLogs(1)[0].Message)
+
+	// 已创建的子记录器立即使用新级别
+	UpdateLogLevel("debug")
+	log.Debug("named-level shown")
+	assert.Equal(t, "named-level shown", RecentLogs(1)[0].Message)
+}
+
+func TestNamed_ErrorsAreFingerprinted(t *testing.T) {
+	useTracker(t, ErrorTrackingConfig{}, nil)
+	log := Named("db")
+
+	for _, table := range []string{"users", "orders"} {
+		log.Errorf("查询 %s 失败: %v", table, errors.New("timeout"))
+	}
+	log.Warn("not tracked")
+
+	fps := ErrorFingerprints()
+	require.Len(t, fps, 1)
+	assert.Equal(t, int64(2), fps[0].Count, "同一调用位置是同一类错误")
+	assert.Equal(t, "查询 users 失败: timeout", fps[0].Format)
+	assert.Equal(t, "查询 orders 失败: timeout", fps[0].Sample)
+	assert.Contains(t, fps[0].Frame, "TestNamed_ErrorsAreFingerprinted named_test.go:")
+}
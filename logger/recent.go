@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultRecentLogSize 内存中默认保留的最近日志条数
+const DefaultRecentLogSize = 1000
+
+// LogRecord 内存中保留的一条日志（诊断包等排障场景使用）
+type LogRecord struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Logger  string         `json:"logger,omitempty"` // 模块名（logger.Named）
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// logRing 固定容量的环形缓冲，写满后覆盖最旧的记录
+type logRing struct {
+	mu      sync.Mutex
+	records []LogRecord
+	next    int
+	full    bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{records: make([]LogRecord, size)}
+}
+
+func (r *logRing) add(rec LogRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return
+	}
+	r.records[r.next] = rec
+	r.next++
+	if r.next == len(r.records) {
+		r.next, r.full = 0, true
+	}
+}
+
+// last 最近的 n 条记录（按时间从旧到新）
+func (r *logRing) last(n int) []LogRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	size := r.next
+	if r.full {
+		size = len(r.records)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+	out := make([]LogRecord, n)
+	for i := range n {
+		out[i] = r.records[(r.next-n+i+len(r.records))%len(r.records)]
+	}
+	return out
+}
+
+// resize 调整容量，保留最近的记录
+func (r *logRing) resize(size int) {
+	kept := r.last(size)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = make([]LogRecord, size)
+	r.next, r.full = copy(r.records, kept), len(kept) == size
+	if r.next == size {
+		r.next = 0
+	}
+}
+
+// ringCore 把日志写入环形缓冲的 zapcore.Core（与控制台、文件输出并列）
+type ringCore struct {
+	zapcore.LevelEnabler
+	ring   *logRing
+	fields []zapcore.Field
+}
+
+func (c *ringCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ringCore{LevelEnabler: c.LevelEnabler, ring: c.ring, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *ringCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *ringCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.ring.add(newLogRecord(entry, c.fields, fields))
+	return nil
+}
+
+// newLogRecord 把 zap 的日志条目与字段转换为 LogRecord
+func newLogRecord(entry zapcore.Entry, fieldSets ...[]zapcore.Field) LogRecord {
+	rec := LogRecord{Time: entry.Time, Level: entry.Level.String(), Logger: entry.LoggerName, Message: entry.Message}
+	var enc *zapcore.MapObjectEncoder
+	for _, fields := range fieldSets {
+		for _, f := range fields {
+			if enc == nil {
+				enc = zapcore.NewMapObjectEncoder()
+			}
+			f.AddTo(enc)
+		}
+	}
+	if enc != nil {
+		rec.Fields = enc.Fields
+	}
+	return rec
+}
+
+func (c *ringCore) Sync() error { return nil }
+
+var recentLogs = newLogRing(DefaultRecentLogSize)
+
+// RecentLogs 内存中最近的 n 条日志（按时间从旧到新，n <= 0 时返回全部保留的记录）
+//
+// 只保留达到当前日志级别的记录，进程重启后清空
+//
+// 示例
+//
+//	for _, r := range logger.RecentLogs(100) {
+//	    fmt.Println(r.Time, r.Level, r.Message)
+//	}
+func RecentLogs(n int) []LogRecord {
+	return recentLogs.last(n)
+}
+
+// SetRecentLogSize 调整内存中保留的日志条数（默认 DefaultRecentLogSize，0 表示不保留）
+func SetRecentLogSize(size int) {
+	recentLogs.resize(max(size, 0))
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRing_KeepsMostRecent(t *testing.T) {
+	r := newLogRing(3)
+	assert.Empty(t, r.last(10))
+	for i := range 5 {
+		r.add(LogRecord{Message: fmt.Sprint(i)})
+	}
+	messages := func(records []LogRecord) []string {
+		var out []string
+		for _, rec := range records {
+			out = append(out, rec.Message)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, messages(r.last(0)))
+	assert.Equal(t, []string{"3", "4"}, messages(r.last(2)))
+
+	r.resize(5)
+	r.add(LogRecord{Message: "5"})
+	assert.Equal(t, []string{"2", "3", "4", "5"}, messages(r.last(0)))
+	r.resize(2)
+	assert.Equal(t, []string{"4", "5"}, messages(r.last(0)))
+	r.resize(0)
+	r.add(LogRecord{Message: "6"})
+	assert.Empty(t, r.last(0))
+}
+
+func TestRecentLogs_CapturesLoggerOutput(t *testing.T) {
+	UpdateLogLevel("info")
+	Warnf("recent-log-test %d", 42)
+	GetLogger().Infow("recent-log-fields", "orderId", 7)
+
+	records := RecentLogs(2)
+	require.Len(t, records, 2)
+	assert.Equal(t, "recent-log-test 42", records[0].Message)
+	assert.Equal(t, "warn", records[0].Level)
+	assert.Equal(t, "recent-log-fields", records[1].Message)
+	assert.EqualValues(t, 7, records[1].Fields["orderId"])
+}
@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// samplerSlots 每个级别的计数槽数（按消息哈希分配，不同消息偶尔共用一个槽）
+const samplerSlots = 4096
+
+// SamplingOptions 高频日志采样配置（只作用于 Debug / Info，Warn 及以上级别始终输出）
+//
+// 同一级别、同一消息每秒前 Initial 条全部输出，之后每 Thereafter 条输出 1 条；
+// Initial 为 0 时不采样，Thereafter 为 0 时每秒超出 Initial 的部分全部丢弃
+type SamplingOptions struct {
+	Initial    int `toml:"initial"`
+	Thereafter int `toml:"thereafter"`
+}
+
+// sampler 按秒计数的采样器（与 zap 的 sampler 规则一致，可在运行时整体替换）
+type sampler struct {
+	initial    uint64
+	thereafter uint64
+	counts     [2][samplerSlots]samplerCounter // Debug、Info
+}
+
+type samplerCounter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+// inc 计数加一，计数窗口（1 秒）过期后从 1 重新开始
+func (c *samplerCounter) inc(now int64) uint64 {
+	if c.resetAt.Load() > now {
+		return c.n.Add(1)
+	}
+	c.n.Store(1)
+	c.resetAt.Store(now + int64(time.Second))
+	return 1
+}
+
+// allow 这条日志是否输出
+func (s *sampler) allow(entry zapcore.Entry) bool {
+	if entry.Level < zapcore.DebugLevel || entry.Level > zapcore.InfoLevel {
+		return true
+	}
+	n := s.counts[entry.Level-zapcore.DebugLevel][messageHash(entry.Message)%samplerSlots].inc(entry.Time.UnixNano())
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
+
+// messageHash FNV-1a（热路径上不分配内存）
+func messageHash(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// activeSampler 当前的采样器（nil 表示不采样）
+var activeSampler atomic.Pointer[sampler]
+
+// SetSampling 设置高频日志采样，运行时生效（initial <= 0 时关闭采样）
+//
+// 同一级别、同一消息每秒前 initial 条全部输出，之后每 thereafter 条输出 1 条。
+// 只作用于 Debug / Info，Warn 及以上级别从不丢弃；对控制台、文件与 AddSink 附加的输出同时生效。
+// 消息按插值后的文本计数（Infof 的参数不同即为不同消息），固定的文本配合 Infow 字段采样效果更好。
+// Init 按 Options.Sampling 重新设置
+//
+// 使用方式：
+//
+//	logger.SetSampling(100, 100) // 每条消息每秒前 100 条全部输出，之后每 100 条输出 1 条
+//	logger.SetSampling(0, 0)     // 关闭采样
+func SetSampling(initial, thereafter int) {
+	if initial <= 0 {
+		activeSampler.Store(nil)
+		return
+	}
+	activeSampler.Store(&sampler{initial: uint64(initial), thereafter: uint64(max(thereafter, 0))})
+}
+
+// sampled 采样器是否允许这条日志输出
+func sampled(entry zapcore.Entry) bool {
+	s := activeSampler.Load()
+	return s == nil || s.allow(entry)
+}
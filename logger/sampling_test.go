@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSampling(t *testing.T) {
+	t.Cleanup(func() { SetSampling(0, 0) })
+	var buf bytes.Buffer
+	defer AddSink(&buf, "debug", "console")()
+	count := func(msg string) int { return strings.Count(buf.String(), msg+"\n") }
+
+	SetSampling(2, 3)
+	for i := 0; i < 10; i++ {
+		Info("sampled-info")
+		Named("sampling").Warn("sampled-warn")
+		Error("sampled-error")
+	}
+	assert.Equal(t, 4, count("sampled-info"), "前 2 条全部输出，之后每 3 条输出 1 条（第 5、8 条）")
+	assert.Equal(t, 10, count("sampled-warn"), "Warn 不采样")
+	assert.Equal(t, 10, count("sampled-error"), "Error 不采样")
+
+	SetSampling(1, 0)
+	for i := 0; i < 5; i++ {
+		Info("sampled-once")
+	}
+	assert.Equal(t, 1, count("sampled-once"), "thereafter 为 0 时超出部分全部丢弃")
+
+	SetSampling(0, 0)
+	for i := 0; i < 5; i++ {
+		Info("sampled-off")
+	}
+	assert.Equal(t, 5, count("sampled-off"), "设置为 0 关闭采样")
+
+	require.NoError(t, Init(Options{Sampling: SamplingOptions{Initial: 1}}))
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	assert.NotNil(t, activeSampler.Load(), "Init 按配置开启采样")
+}
+
+// BenchmarkSampling 高频请求日志写入文件：不采样与采样（每秒前 100 条，之后每 100 条 1 条）的吞吐对比
+func BenchmarkSampling(b *testing.B) {
+	if err := Init(Options{File: true, Dir: b.TempDir(), DisableConsole: true}); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = Init(Options{}) })
+	log := Named("access")
+
+	for _, bc := range []struct {
+		name                string
+		initial, thereafter int
+	}{{"off", 0, 0}, {"on", 100, 100}} {
+		b.Run(bc.name, func(b *testing.B) {
+			SetSampling(bc.initial, bc.thereafter)
+			defer SetSampling(0, 0)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					log.Infow("request", "method", "GET", "path", "/api/orders", "status", 200)
+				}
+			})
+		})
+	}
+}
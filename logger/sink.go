@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// sink AddSink 附加的输出（级别独立于全局级别）
+type sink struct {
+	core  zapcore.Core
+	level zapcore.Level
+}
+
+var (
+	sinks   []*sink      // 受 outputsMu 保护，Init 替换输出时保留
+	sinkMin atomic.Int32 // 附加输出中最低的级别（没有附加输出时为 InvalidLevel）
+)
+
+func init() {
+	sinkMin.Store(int32(zapcore.InvalidLevel))
+}
+
+// AddSink 附加一个日志输出（如 WebSocket 管理控制台、内存缓冲），返回的 remove 移除该输出
+//
+// minLevel 为该输出自己的级别阈值（debug / info / warn / error，不区分大小写），与 UpdateLogLevel
+// 设置的全局级别互不影响：全局级别为 info 时 debug 输出仍能收到 Debug 日志，控制台与文件不受影响。
+// format 为 "console"（默认，与日志文件相同的文本格式）或 "json"。
+// w 的写入已加锁串行化；Init 替换控制台与文件输出时附加的输出保留；
+// 参数非法属于编码错误，直接 panic
+//
+// 使用方式：
+//
+//	var buf bytes.Buffer
+//	remove := logger.AddSink(&buf, "warn", "json")
+//	defer remove()
+//
+//	logger.Info("不会写入 buf")
+//	logger.Warn("写入 buf") // {"l":"warn","t":"10:04:05.123","m":"写入 buf"}
+func AddSink(w io.Writer, minLevel string, format string) (remove func()) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(strings.TrimSpace(minLevel)))); err != nil {
+		panic(fmt.Sprintf("logger: 无法解析日志级别: %s", minLevel))
+	}
+	var enc zapcore.Encoder
+	switch strings.ToLower(format) {
+	case "", "console":
+		enc = fileEncoder()
+	case "json":
+		encoderConfig := baseEncoderConfig
+		encoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+		enc = zapcore.NewJSONEncoder(encoderConfig)
+	default:
+		panic(fmt.Sprintf("logger: 未知的日志格式: %s", format))
+	}
+
+	s := &sink{core: zapcore.NewCore(enc, zapcore.Lock(zapcore.AddSync(w)), level), level: level}
+	outputsMu.Lock()
+	sinks = append(sinks, s)
+	updateSinkMin()
+	outputsMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			outputsMu.Lock()
+			defer outputsMu.Unlock()
+			sinks = slices.DeleteFunc(slices.Clone(sinks), func(x *sink) bool { return x == s })
+			updateSinkMin()
+		})
+	}
+}
+
+// updateSinkMin 重新计算附加输出的最低级别（调用方持有 outputsMu 写锁）
+func updateSinkMin() {
+	minLevel := zapcore.InvalidLevel
+	for _, s := range sinks {
+		minLevel = min(minLevel, s.level)
+	}
+	sinkMin.Store(int32(minLevel))
+}
+
+// sinkEnabled 是否有附加输出接收该级别的日志
+func sinkEnabled(l zapcore.Level) bool {
+	return int32(l) >= sinkMin.Load()
+}
+
+// writeSinks 写入级别满足的附加输出（调用方持有 outputsMu 读锁）
+func writeSinks(entry zapcore.Entry, fields []zapcore.Field) error {
+	var errs []error
+	for _, s := range sinks {
+		if entry.Level >= s.level {
+			errs = append(errs, s.core.Write(entry, fields))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// syncSinks 同步全部附加输出（调用方持有 outputsMu 读锁）
+func syncSinks() error {
+	var errs []error
+	for _, s := range sinks {
+		errs = append(errs, s.core.Sync())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddSink_LevelFiltering(t *testing.T) {
+	UpdateLogLevel("info")
+	var warn, debug bytes.Buffer
+	removeWarn := AddSink(&warn, "warn", "console")
+	removeDebug := AddSink(&debug, "DEBUG", "json")
+	defer removeDebug()
+
+	Info("sink-info")
+	Named("sinktest").Warnw("sink-warn", "k", 1)
+	Debug("sink-debug")
+
+	assert.NotContains(t, warn.String(), "sink-info")
+	assert.Contains(t, warn.String(), "WARN  sinktest sink-warn")
+	assert.NotContains(t, warn.String(), "sink-debug")
+	assert.Contains(t, debug.String(), "sink-debug", "附加输出的级别独立于全局级别")
+	assert.False(t, atomicLevel.Enabled(-1), "全局级别不受影响")
+
+	lines := strings.Split(strings.TrimSpace(debug.String()), "\n")
+	require.Len(t, lines, 3)
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+	assert.Equal(t, "warn", rec["l"])
+	assert.Equal(t, "sink-warn", rec["m"])
+	assert.Equal(t, float64(1), rec["k"])
+
+	// 移除后不再写入，其他附加输出不受影响
+	removeWarn()
+	removeWarn()
+	Error("sink-after-remove")
+	assert.NotContains(t, warn.String(), "sink-after-remove")
+	assert.Contains(t, debug.String(), "sink-after-remove")
+
+	removeDebug()
+	Debug("sink-gone")
+	assert.NotContains(t, debug.String(), "sink-gone")
+	assert.False(t, zapSugarLogger.Desugar().Core().Enabled(-1), "没有附加输出时恢复全局级别")
+}
+
+func TestAddSink_InvalidArgs(t *testing.T) {
+	assert.Panics(t, func() { AddSink(&bytes.Buffer{}, "verbose", "json") })
+	assert.Panics(t, func() { AddSink(&bytes.Buffer{}, "info", "xml") })
+}
@@ -1,9 +1,15 @@
 package logger
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -13,7 +19,13 @@ import (
 
 var (
 	zapSugarLogger *zap.SugaredLogger
+	pkgRoot        *zap.SugaredLogger // Info / Errorf 等包级函数使用的记录器（调用位置跳过包装函数本身）
+	childRoot      *zap.SugaredLogger // Named / With 的父记录器（记录调用位置，错误计入指纹统计）
+	ctxRoot        *zap.SugaredLogger // InfoCtx 等包装函数使用的记录器（调用位置跳过包装函数本身）
 	atomicLevel    zap.AtomicLevel
+
+	callerEnabled     atomic.Bool // 输出调用位置（EnableCaller）
+	stacktraceEnabled atomic.Bool // Error 及以上级别输出堆栈（EnableStacktrace）
 )
 
 const (
@@ -24,27 +36,32 @@ const (
 	colorReset  = "\033[0m"
 )
 
-func init() {
-	atomicLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
-	baseEncoderConfig := zapcore.EncoderConfig{
-		TimeKey:       "t",
-		LevelKey:      "l",
-		NameKey:       "",
-		CallerKey:     "",
-		FunctionKey:   "",
-		MessageKey:    "m",
-		StacktraceKey: "",
-		LineEnding:    zapcore.DefaultLineEnding,
-		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString(t.Format("15:04:05.000"))
-		},
-		EncodeDuration:   zapcore.StringDurationEncoder,
-		EncodeCaller:     zapcore.ShortCallerEncoder,
-		ConsoleSeparator: " ",
-	}
-
-	consoleEncoderConfig := baseEncoderConfig
-	consoleEncoderConfig.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+// baseEncoderConfig 控制台与文件共用的编码配置
+var baseEncoderConfig = zapcore.EncoderConfig{
+	TimeKey:       "t",
+	LevelKey:      "l",
+	NameKey:       "n",
+	CallerKey:     "c",
+	FunctionKey:   "",
+	MessageKey:    "m",
+	StacktraceKey: "s",
+	LineEnding:    zapcore.DefaultLineEnding,
+	EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.Format("15:04:05.000"))
+	},
+	EncodeDuration:   zapcore.StringDurationEncoder,
+	EncodeCaller:     zapcore.ShortCallerEncoder,
+	ConsoleSeparator: " ",
+}
+
+// consoleEncoder 控制台编码：级别带颜色（SetColor 关闭或自动检测为非终端时与文件格式相同）
+func consoleEncoder() zapcore.Encoder {
+	encoderConfig := baseEncoderConfig
+	encoderConfig.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		if !consoleColor.Load() {
+			plainLevelEncoder(l, enc)
+			return
+		}
 		switch l {
 		case zapcore.DebugLevel:
 			enc.AppendString(colorBlue + "DEBUG" + colorReset)
@@ -54,48 +71,262 @@ func init() {
 			enc.AppendString(colorYellow + "WARN " + colorReset)
 		case zapcore.ErrorLevel:
 			enc.AppendString(colorRed + "ERROR" + colorReset)
+		case zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel:
+			enc.AppendString(colorRed + l.CapitalString() + colorReset)
 		default:
 			enc.AppendString(l.CapitalString())
 		}
 	}
+	return zapcore.NewConsoleEncoder(encoderConfig)
+}
 
-	fileEncoderConfig := baseEncoderConfig
-	fileEncoderConfig.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
-		switch l {
-		case zapcore.InfoLevel:
-			enc.AppendString("INFO ")
-		case zapcore.WarnLevel:
-			enc.AppendString("WARN ")
-		default:
-			enc.AppendString(l.CapitalString())
-		}
+func fileEncoder() zapcore.Encoder {
+	encoderConfig := baseEncoderConfig
+	encoderConfig.EncodeLevel = plainLevelEncoder
+	return zapcore.NewConsoleEncoder(encoderConfig)
+}
+
+// plainLevelEncoder 不带颜色的级别（与带颜色时同样对齐到 5 个字符）
+func plainLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO ")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARN ")
+	default:
+		enc.AppendString(l.CapitalString())
 	}
+}
+
+// Options 日志输出配置
+//
+// 零值与包初始化时的默认行为一致：输出到控制台，作为 Windows 服务运行时同时输出到
+// 可执行文件所在目录的 logs/app.log。文件按大小轮转（lumberjack）
+//
+// Example:
+//
+//	[web.log]
+//	file = true               # 输出到文件（作为 Windows 服务运行时始终输出，服务没有控制台）
+//	dir = "/var/log/myapp"    # 日志目录（默认可执行文件所在目录下的 logs）
+//	filename = "app.log"      # 文件名（默认 app.log）
+//	maxSize = 20              # 单个文件大小上限（MB，默认 20），超出时轮转
+//	maxBackups = 10           # 保留的轮转文件数（默认 10）
+//	maxAge = 30               # 轮转文件保留天数（默认 30）
+//	disableCompress = false   # 不压缩轮转文件
+//	disableConsole = false    # 不输出到控制台（只在输出到文件时生效）
+//	caller = false            # 输出调用位置（文件名:行号），见 EnableCaller
+//	stacktrace = false        # Error 及以上级别输出堆栈，见 EnableStacktrace
+//
+//	[web.log.sampling]        # 高频日志采样（只作用于 Debug / Info），见 SetSampling
+//	initial = 100             # 同一消息每秒前 100 条全部输出（0 表示不采样）
+//	thereafter = 100          # 之后每 100 条输出 1 条
+type Options struct {
+	File            bool   `toml:"file"`
+	Dir             string `toml:"dir"`
+	Filename        string `toml:"filename"`
+	MaxSize         int    `toml:"maxSize"`
+	MaxBackups      int    `toml:"maxBackups"`
+	MaxAge          int    `toml:"maxAge"`
+	DisableCompress bool   `toml:"disableCompress"`
+	DisableConsole  bool   `toml:"disableConsole"`
+	Caller          bool   `toml:"caller"`
+	Stacktrace      bool   `toml:"stacktrace"`
+
+	Sampling SamplingOptions `toml:"sampling"`
+}
+
+// outputs 当前的输出（控制台、文件与内存中最近的日志），file 在被替换后关闭
+type outputs struct {
+	core zapcore.Core
+	file *lumberjack.Logger
+}
 
-	coreConfigs := []zapcore.Core{
-		zapcore.NewCore(zapcore.NewConsoleEncoder(consoleEncoderConfig), zapcore.AddSync(os.Stdout), atomicLevel),
+var (
+	outputsMu sync.RWMutex
+	current   outputs
+	stdout    io.Writer = os.Stdout // 控制台输出（测试中替换）
+)
+
+// buildOutputs 按配置创建输出（不修改当前输出）
+func buildOutputs(opts Options) (outputs, error) {
+	var out outputs
+	file := opts.File || isWindowsService()
+	var cores []zapcore.Core
+	if !file || !opts.DisableConsole {
+		cores = append(cores, zapcore.NewCore(consoleEncoder(), zapcore.AddSync(stdout), atomicLevel))
 	}
 
-	if isWindowsService() {
-		exePath, err := os.Executable()
-		if err != nil {
-			panic("获取可执行文件路径失败: " + err.Error())
+	if file {
+		logDir := opts.Dir
+		if logDir == "" {
+			exePath, err := os.Executable()
+			if err != nil {
+				return out, fmt.Errorf("获取可执行文件路径失败: %w", err)
+			}
+			logDir = filepath.Join(filepath.Dir(exePath), "logs")
 		}
-		logDir := filepath.Join(filepath.Dir(exePath), "logs")
 		if err := os.MkdirAll(logDir, 0755); err != nil {
-			panic("创建日志目录失败: " + err.Error())
+			return out, fmt.Errorf("创建日志目录失败: %w", err)
 		}
-		lumberjackLogger := &lumberjack.Logger{
-			Filename:   filepath.Join(logDir, "app.log"),
-			MaxSize:    20,
-			MaxBackups: 10,
-			MaxAge:     30,
+		out.file = &lumberjack.Logger{
+			Filename:   filepath.Join(logDir, cmp.Or(opts.Filename, "app.log")),
+			MaxSize:    cmp.Or(opts.MaxSize, 20),
+			MaxBackups: cmp.Or(opts.MaxBackups, 10),
+			MaxAge:     cmp.Or(opts.MaxAge, 30),
 			LocalTime:  true,
-			Compress:   true,
+			Compress:   !opts.DisableCompress,
 		}
-		coreConfigs = append(coreConfigs, zapcore.NewCore(zapcore.NewConsoleEncoder(fileEncoderConfig), zapcore.AddSync(lumberjackLogger), atomicLevel))
+		cores = append(cores, zapcore.NewCore(fileEncoder(), fileSyncer{out.file}, atomicLevel))
+	}
+
+	// 最近的日志保留在内存中（logger.RecentLogs）
+	cores = append(cores, &ringCore{LevelEnabler: atomicLevel, ring: recentLogs})
+
+	out.core = zapcore.NewTee(cores...)
+	return out, nil
+}
+
+// fileSyncer 文件输出：lumberjack 没有 Sync，同步时关闭当前文件（下一次写入时重新打开），
+// Fatal / Panic 之后进程退出前日志已经写入文件
+type fileSyncer struct {
+	*lumberjack.Logger
+}
+
+func (f fileSyncer) Sync() error {
+	return f.Close()
+}
+
+// Init 按配置替换日志输出（可在运行时重复调用）
+//
+// 新的输出创建成功后才替换，替换等待正在写入的日志完成，之后的日志写入新的输出，
+// 旧的日志文件随后关闭，替换过程中不会丢失日志。GetLogger 返回的实例保持不变，
+// 日志级别仍由 UpdateLogLevel 控制；调用位置与堆栈按 Caller / Stacktrace 开关，采样按 Sampling 设置；
+// 未调用 SetColor 时重新检测控制台是否支持颜色。
+// 创建失败（如目录无法创建）时返回错误，保留当前输出
+//
+// 使用方式：
+//
+//	if err := logger.Init(logger.Options{File: true, Dir: "/var/log/myapp", DisableConsole: true}); err != nil {
+//	    panic(err)
+//	}
+func Init(opts Options) error {
+	next, err := buildOutputs(opts)
+	if err != nil {
+		return err
+	}
+	if !colorExplicit.Load() {
+		consoleColor.Store(detectColor(stdout))
+	}
+	outputsMu.Lock()
+	prev := current
+	current = next
+	outputsMu.Unlock()
+	EnableCaller(opts.Caller)
+	EnableStacktrace(opts.Stacktrace)
+	SetSampling(opts.Sampling.Initial, opts.Sampling.Thereafter)
+	if prev.file != nil {
+		return prev.file.Close()
 	}
+	return nil
+}
+
+// swapCore 转发到当前输出的 zapcore.Core，Init 替换输出时 zapSugarLogger 无需重建
+type swapCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+}
 
-	zapSugarLogger = zap.New(zapcore.NewTee(coreConfigs...)).Sugar()
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	return &swapCore{LevelEnabler: c.LevelEnabler, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+// Enabled 全局级别或任一附加输出（AddSink）的级别满足即记录，有错误钩子（OnError）时 Error 及以上级别始终记录
+func (c *swapCore) Enabled(l zapcore.Level) bool {
+	return c.LevelEnabler.Enabled(l) || sinkEnabled(l) || errorHooksEnabled(l)
+}
+
+func (c *swapCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) && sampled(entry) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *swapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !callerEnabled.Load() {
+		entry.Caller = zapcore.EntryCaller{}
+	}
+	if !stacktraceEnabled.Load() {
+		entry.Stack = ""
+	}
+	if len(c.fields) > 0 {
+		fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	}
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+	var err error
+	if c.LevelEnabler.Enabled(entry.Level) {
+		err = current.core.Write(entry, fields)
+	}
+	fireErrorHooks(entry, fields)
+	return errors.Join(err, writeSinks(entry, fields))
+}
+
+func (c *swapCore) Sync() error {
+	return Sync()
+}
+
+func init() {
+	atomicLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	if err := Init(Options{}); err != nil {
+		panic(err.Error())
+	}
+	annotate := []zap.Option{zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.WithFatalHook(fatalHook{})}
+	zapSugarLogger = zap.New(&swapCore{LevelEnabler: atomicLevel}, annotate...).Sugar()
+	pkgRoot = zapSugarLogger.WithOptions(zap.AddCallerSkip(1))
+	childRoot = zap.New(&swapCore{LevelEnabler: atomicLevel}, append(annotate, zap.Hooks(trackEntry))...).Sugar()
+	ctxRoot = childRoot.WithOptions(zap.AddCallerSkip(1))
+}
+
+// EnableCaller 开关调用位置输出（文件名:行号，指向调用 Info / Errorf 等的业务代码），运行时生效
+//
+// 默认关闭；Init 按 Options.Caller 重新设置
+//
+// 使用方式：
+//
+//	logger.EnableCaller(true)
+//	logger.Errorf("下单失败: %v", err) // 10:04:05.123 ERROR order/service.go:42 下单失败: ...
+func EnableCaller(enabled bool) {
+	callerEnabled.Store(enabled)
+}
+
+// EnableStacktrace 开关 Error 及以上级别的堆栈输出，运行时生效
+//
+// 默认关闭；Init 按 Options.Stacktrace 重新设置
+func EnableStacktrace(enabled bool) {
+	stacktraceEnabled.Store(enabled)
+}
+
+// exit 进程退出（测试中替换）
+var exit = os.Exit
+
+// fatalHook Fatal 写入日志之后同步全部输出再退出进程
+type fatalHook struct{}
+
+func (fatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	_ = Sync()
+	exit(1)
+}
+
+// Sync 把缓冲的日志写入输出（控制台与日志文件），进程退出前调用
+//
+// 使用方式：
+//
+//	defer logger.Sync()
+func Sync() error {
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+	return errors.Join(current.core.Sync(), syncSinks())
 }
 
 // GetLogger 返回全局日志记录器实例
@@ -105,7 +336,7 @@ func init() {
 //
 // 输出目标
 //   - 控制台：始终输出到标准输出，带彩色级别标识
-//   - 文件：当作为 Windows 服务运行时，自动输出到 logs/app.log
+//   - 文件：当作为 Windows 服务运行时，自动输出到 logs/app.log（其他情况见 Init）
 //
 // 返回值
 //
@@ -114,8 +345,8 @@ func init() {
 // 注意事项
 //   - 日志记录器在包导入时自动初始化，无需手动调用
 //   - 返回的日志记录器是全局单例，所有调用共享同一个实例
-//   - 文件日志仅在 Windows 服务模式下启用
-//   - 日志文件会自动轮转（最大 20MB，保留 30 天，最多 10 个备份）
+//   - 默认文件日志仅在 Windows 服务模式下启用，Init 可以开启文件日志或关闭控制台输出
+//   - 日志文件会自动轮转（默认最大 20MB，保留 30 天，最多 10 个备份）
 //
 // 示例
 //
@@ -129,11 +360,11 @@ func GetLogger() *zap.SugaredLogger {
 // UpdateLogLevel 动态更新日志级别
 //
 // 允许在运行时动态调整日志级别，无需重启程序。
-// 支持的级别：debug, info, warn, error（不区分大小写）
+// 支持的级别：debug, info, warn, error, panic, fatal（不区分大小写）
 //
 // 参数
 //
-//	level - 目标日志级别字符串，支持 "debug", "info", "warn", "error"（大小写不敏感）
+//	level - 目标日志级别字符串，支持 "debug", "info", "warn", "error", "panic", "fatal"（大小写不敏感）
 //
 // 注意事项
 //   - 级别字符串会自动 trim 空白和转换为小写
@@ -159,12 +390,66 @@ func UpdateLogLevel(level string) {
 	}
 }
 
-func Debug(msg string) { zapSugarLogger.Debug(msg) }
-func Info(msg string)  { zapSugarLogger.Info(msg) }
-func Warn(msg string)  { zapSugarLogger.Warn(msg) }
-func Error(msg string) { zapSugarLogger.Error(msg) }
+func Debug(msg string) { pkgRoot.Debug(msg) }
+func Info(msg string)  { pkgRoot.Info(msg) }
+func Warn(msg string)  { pkgRoot.Warn(msg) }
+func Error(msg string) {
+	tracker.Load().record(msg, callerFrame(1), msg)
+	pkgRoot.Error(msg)
+}
+
+func Debugf(format string, args ...any) { pkgRoot.Debugf(format, args...) }
+func Infof(format string, args ...any)  { pkgRoot.Infof(format, args...) }
+func Warnf(format string, args ...any)  { pkgRoot.Warnf(format, args...) }
+func Errorf(format string, args ...any) {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	tracker.Load().record(format, callerFrame(1), message)
+	pkgRoot.Error(message)
+}
+
+// Panic 记录日志后 panic（panic 的值为 msg），计入错误指纹统计
+//
+// 使用方式：
+//
+//	if err := os.MkdirAll(dir, 0755); err != nil {
+//	    logger.Panicf("创建目录失败: %v", err)
+//	}
+func Panic(msg string) {
+	tracker.Load().record(msg, callerFrame(1), msg)
+	pkgRoot.Panic(msg)
+}
+
+// Panicf 格式化后记录日志并 panic
+func Panicf(format string, args ...any) {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	tracker.Load().record(format, callerFrame(1), message)
+	pkgRoot.Panic(message)
+}
 
-func Debugf(format string, args ...any) { zapSugarLogger.Debugf(format, args...) }
-func Infof(format string, args ...any)  { zapSugarLogger.Infof(format, args...) }
-func Warnf(format string, args ...any)  { zapSugarLogger.Warnf(format, args...) }
-func Errorf(format string, args ...any) { zapSugarLogger.Errorf(format, args...) }
+// Fatal 记录日志、同步全部输出（包括日志文件）后以状态码 1 退出进程，defer 不会执行
+func Fatal(msg string) {
+	tracker.Load().record(msg, callerFrame(1), msg)
+	pkgRoot.Fatal(msg)
+}
+
+// Fatalf 格式化后记录日志、同步全部输出后以状态码 1 退出进程
+//
+// 使用方式：
+//
+//	if err := h.Run(); err != nil {
+//	    logger.Fatalf("服务启动失败: %v", err)
+//	}
+func Fatalf(format string, args ...any) {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	tracker.Load().record(format, callerFrame(1), message)
+	pkgRoot.Fatal(message)
+}
@@ -2,6 +2,13 @@
 
 package logger
 
+import "os"
+
 func isWindowsService() bool {
 	return false
 }
+
+// enableVirtualTerminal 终端默认支持 ANSI 转义序列
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
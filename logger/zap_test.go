@@ -1,10 +1,16 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetLogger(t *testing.T) {
@@ -355,3 +361,174 @@ func TestLoggerManyLogs(t *testing.T) {
 		}
 	})
 }
+
+func TestInit_FileOutput(t *testing.T) {
+	UpdateLogLevel("info")
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dir := t.TempDir()
+	log := GetLogger()
+
+	require.NoError(t, Init(Options{File: true, Dir: dir, Filename: "svc.log", DisableConsole: true}))
+	assert.Same(t, log, GetLogger(), "替换输出不重建日志记录器")
+	log.With("order", 42).Info("写入文件")
+	data, err := os.ReadFile(filepath.Join(dir, "svc.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "INFO  写入文件")
+	assert.Contains(t, string(data), `{"order": 42}`)
+
+	// 切换到另一个目录：之后的日志只写入新文件
+	next := t.TempDir()
+	require.NoError(t, Init(Options{File: true, Dir: next}))
+	Info("切换之后")
+	data, _ = os.ReadFile(filepath.Join(dir, "svc.log"))
+	assert.NotContains(t, string(data), "切换之后")
+	data, err = os.ReadFile(filepath.Join(next, "app.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "切换之后")
+}
+
+func TestInit_InvalidDirKeepsCurrentOutputs(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dir := t.TempDir()
+	require.NoError(t, Init(Options{File: true, Dir: dir, DisableConsole: true}))
+
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0644))
+	assert.Error(t, Init(Options{File: true, Dir: filepath.Join(blocker, "logs")}))
+
+	Warn("仍然写入原来的文件")
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "仍然写入原来的文件")
+}
+
+func TestInit_SwapDuringConcurrentLogging(t *testing.T) {
+	UpdateLogLevel("info")
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dirs := []string{t.TempDir()}
+	require.NoError(t, Init(Options{File: true, Dir: dirs[0], DisableConsole: true}))
+
+	const writers, lines = 8, 200
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range lines {
+				Infof("line %d-%d", w, i)
+			}
+		}()
+	}
+	for range 20 {
+		dir := t.TempDir()
+		require.NoError(t, Init(Options{File: true, Dir: dir, DisableConsole: true}))
+		dirs = append(dirs, dir)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if _, msg, ok := strings.Cut(line, "line "); ok {
+				seen[msg] = true
+			}
+		}
+	}
+	for w := range writers {
+		for i := range lines {
+			assert.True(t, seen[fmt.Sprintf("%d-%d", w, i)], "替换输出时日志不丢失: %d-%d", w, i)
+		}
+	}
+}
+
+func TestUpdateLogLevel_PanicAndFatal(t *testing.T) {
+	t.Cleanup(func() { UpdateLogLevel("info") })
+	UpdateLogLevel("panic")
+	assert.Equal(t, "panic", atomicLevel.Level().String())
+	UpdateLogLevel("FATAL")
+	assert.Equal(t, "fatal", atomicLevel.Level().String())
+}
+
+func TestPanicf(t *testing.T) {
+	UpdateLogLevel("info")
+	assert.PanicsWithValue(t, "创建目录失败: denied", func() { Panicf("创建目录失败: %s", "denied") })
+	assert.PanicsWithValue(t, "boom", func() { Panic("boom") })
+}
+
+func TestFatalf_SyncsFileBeforeExit(t *testing.T) {
+	UpdateLogLevel("info")
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dir := t.TempDir()
+	require.NoError(t, Init(Options{File: true, Dir: dir, DisableConsole: true}))
+
+	code := -1
+	exit = func(c int) {
+		code = c
+		// 退出时日志已经在文件中
+		data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "FATAL 服务启动失败: address in use")
+	}
+	t.Cleanup(func() { exit = os.Exit })
+
+	Fatalf("服务启动失败: %s", "address in use")
+	assert.Equal(t, 1, code)
+
+	code = -1
+	Named("web").Fatal("服务启动失败: address in use")
+	assert.Equal(t, 1, code, "子记录器同样同步后退出")
+}
+
+func TestEnableCaller(t *testing.T) {
+	UpdateLogLevel("info")
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dir := t.TempDir()
+	require.NoError(t, Init(Options{File: true, Dir: dir, DisableConsole: true, Caller: true}))
+	read := func() string {
+		data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	Infof("包级函数 %d", 1)
+	GetLogger().Info("全局记录器")
+	Named("order").Warn("子记录器")
+	InfoCtx(context.Background(), "带 context")
+	for _, line := range strings.Split(strings.TrimSpace(read()), "\n") {
+		assert.Contains(t, line, "logger/zap_test.go:", "调用位置指向调用方而不是包装函数")
+	}
+
+	EnableCaller(false)
+	Info("关闭之后")
+	lines := strings.Split(strings.TrimSpace(read()), "\n")
+	assert.Equal(t, "INFO  关闭之后", lines[len(lines)-1][13:], "运行时关闭")
+}
+
+func TestEnableStacktrace(t *testing.T) {
+	UpdateLogLevel("info")
+	t.Cleanup(func() { require.NoError(t, Init(Options{})) })
+	dir := t.TempDir()
+	require.NoError(t, Init(Options{File: true, Dir: dir, DisableConsole: true}))
+	read := func() string {
+		data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	Errorf("没有堆栈")
+	assert.NotContains(t, read(), "TestEnableStacktrace")
+
+	EnableStacktrace(true)
+	Warn("警告不带堆栈")
+	assert.NotContains(t, read(), "TestEnableStacktrace")
+	Errorf("带堆栈")
+	out := read()
+	assert.Contains(t, out, "logger.TestEnableStacktrace")
+	assert.Contains(t, out, "logger/zap_test.go:")
+	assert.NotContains(t, out[strings.Index(out, "带堆栈"):], "logger.Errorf", "堆栈从调用方开始")
+}
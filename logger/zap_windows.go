@@ -2,9 +2,27 @@
 
 package logger
 
-import "golang.org/x/sys/windows/svc"
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+)
 
 func isWindowsService() bool {
 	isService, err := svc.IsWindowsService()
 	return err == nil && isService
 }
+
+// enableVirtualTerminal 开启控制台的 VT 处理（Windows 10 1511 之前的控制台不支持，返回 false）
+func enableVirtualTerminal(f *os.File) bool {
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
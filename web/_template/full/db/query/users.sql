@@ -0,0 +1,8 @@
+-- name: GetUser :one
+-- 返回的 version 通过 web.SetVersionETag 作为 ETag 下发
+SELECT id, name, email, version, created_at FROM users WHERE id = ?;
+
+-- name: UpdateUserName :execresult
+-- 乐观锁更新：version 取自 If-Match（web.RequireIfMatchVersion），
+-- 结果交给 database.CheckOptimisticUpdate，影响 0 行即 ErrVersionConflict（409）
+UPDATE users SET name = ?, version = version + 1 WHERE id = ? AND version = ?;
@@ -0,0 +1,17 @@
+CREATE TABLE users (
+    id         BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(64)  NOT NULL,
+    email      VARCHAR(255) NOT NULL UNIQUE,
+    password   VARCHAR(255) NOT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1, -- 乐观锁版本号，每次更新 +1
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE announcements (
+    id         BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    lang       VARCHAR(16)  NOT NULL, -- 语言（与 locales 中的文件名一致）
+    title      VARCHAR(255) NOT NULL,
+    body       TEXT         NOT NULL,
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_announcements_lang (lang)
+);
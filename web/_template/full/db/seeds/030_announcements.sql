@@ -0,0 +1,3 @@
+-- 演示公告：每种语言一份（./app seed --only=announcements）
+INSERT INTO announcements (lang, title, body) VALUES ('zh-CN', '欢迎使用 My App', '这是一条演示公告，执行 ./app seed 时写入。');
+INSERT INTO announcements (lang, title, body) VALUES ('en-US', 'Welcome to My App', 'This is a demo announcement written by ./app seed.');
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"os"
+	"testing"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/fixtures
+var fixtures embed.FS
+
+// 数据库集成测试示例：每个测试在事务中加载测试数据，结束时回滚
+//
+//	TEST_MYSQL_DSN="root:root@tcp(127.0.0.1:3306)/app_test?parseTime=true" task test
+func TestUsers_WithFixtures(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未设置 TEST_MYSQL_DSN，跳过数据库集成测试")
+	}
+	db, err := sql.Open(database.DriverMySQL, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := database.LoadFixturesTx(t, db, fixtures, "testdata/fixtures")
+
+	var name, hash string
+	err = database.Conn(ctx).QueryRowContext(ctx, "SELECT name, password FROM users WHERE id = ?", 1).Scan(&name, &hash)
+	require.NoError(t, err)
+	assert.Equal(t, "张三", name)
+	assert.True(t, common.CheckPassword(hash, "secret"))
+}
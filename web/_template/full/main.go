@@ -11,8 +11,11 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"strconv"
 
+	"github.com/CenJIl/base/cfg"
 	"github.com/CenJIl/base/web"
 	"github.com/CenJIl/base/web/jwt"
 	"github.com/cloudwego/hertz/pkg/app"
@@ -37,6 +40,23 @@ var users = map[int]User{
 }
 
 func main() {
+	// ./app config describe：查看每个配置项的当前值与来源，不启动服务
+	if ok, err := cfg.RunConfigCommand[AppConfig](os.Args[1:], os.Stdout, "app.toml"); ok {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	// ./app seed：写入演示数据（见 seeds.go），不启动服务
+	if ok, err := web.RunSeedCommand[AppConfig](os.Args[1:], os.Stdout, seeds, "db/seeds", "app.toml"); ok {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := jwt.Init(jwt.Config{
 		Secret:      "your-secret-key-change-in-production",
 		Realm:       "jwt",
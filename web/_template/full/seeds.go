@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/database"
+)
+
+// 演示数据：db/seeds 下的 SQL 文件与这里注册的 Go 种子数据按名称顺序执行
+//
+//	./app seed                  写入全部尚未写入的演示数据（生产环境拒绝执行）
+//	./app seed --only=users     只写入演示用户
+//
+//go:embed db/seeds
+var seeds embed.FS
+
+func init() {
+	database.RegisterSeeder("010_users", seedUsers)
+	database.RegisterSeeder("020_uploads", seedUploads)
+}
+
+// seedUsers 演示用户（密码均为 demo123，需要计算哈希，所以用 Go 编写）
+func seedUsers(ctx context.Context, db database.DBTX) error {
+	hash, err := common.HashPassword("demo123")
+	if err != nil {
+		return err
+	}
+	for _, u := range []User{
+		{Name: "张三", Email: "zhangsan@example.com"},
+		{Name: "李四", Email: "lisi@example.com"},
+	} {
+		if _, err := db.ExecContext(ctx, "INSERT INTO users (name, email, password) VALUES (?, ?, ?)", u.Name, u.Email, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedUploads 在上传目录中放一个示例文件
+func seedUploads(ctx context.Context, db database.DBTX) error {
+	dir := cfg.MustGetCfg[AppConfig]().Upload.UploadPath
+	if dir == "" {
+		dir = "./uploads"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "demo.txt")
+	if err := os.WriteFile(path, []byte("demo upload\n"), 0o644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	return nil
+}
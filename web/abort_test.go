@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startAbortServer 启动开启断开感知的真实服务（客户端断开需要真实连接才能触发）
+func startAbortServer(t *testing.T, register func(h *server.Hertz), opts ...config.Option) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	opts = append([]config.Option{server.WithListener(ln), server.WithSenseClientDisconnection(true), server.WithExitWaitTime(0)}, opts...)
+	h := server.New(opts...)
+	register(h)
+	go h.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		h.Shutdown(ctx)
+	})
+	return ln.Addr().String()
+}
+
+// dropMidRequest 发送请求，等处理器开始执行后断开连接
+func dropMidRequest(t *testing.T, addr, path string, started <-chan struct{}) {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: test\r\n\r\n", path)
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("处理器没有开始执行")
+	}
+	conn.Close()
+}
+
+func TestClientAbort_ClassifiedAs499(t *testing.T) {
+	var panics atomic.Int32
+	OnPanic(func(ctx context.Context, r PanicReport) { panics.Add(1) })
+	t.Cleanup(func() { OnPanic(nil) })
+
+	started := make(chan struct{}, 1)
+	statuses := make(chan int, 1)
+	addr := startAbortServer(t, func(h *server.Hertz) {
+		h.Use(middleware.ClientAbortMiddleware())
+		h.Use(func(ctx context.Context, c *app.RequestContext) {
+			c.Next(ctx)
+			statuses <- middleware.ResponseStatus(c)
+		})
+		h.Use(ExceptionHandler())
+		// 处理器等待下游调用时客户端断开，下游返回 context.Canceled
+		h.GET("/panic", func(ctx context.Context, c *app.RequestContext) {
+			started <- struct{}{}
+			<-ctx.Done()
+			panic(ctx.Err())
+		})
+		h.GET("/wrapped", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return fmt.Errorf("query users: %w", ctx.Err())
+		}))
+	})
+
+	for _, path := range []string{"/panic", "/wrapped"} {
+		before := metrics.GetCounter("web_client_aborts_total", "route", path).Value()
+		dropMidRequest(t, addr, path, started)
+		select {
+		case status := <-statuses:
+			assert.Equal(t, middleware.StatusClientClosedRequest, status, path)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: 请求没有结束", path)
+		}
+		assert.Equal(t, before+1, metrics.GetCounter("web_client_aborts_total", "route", path).Value(), path)
+	}
+	assert.Zero(t, panics.Load(), "客户端断开不触发 OnPanic")
+}
+
+func TestClientAbort_CompletedRequestNotAborted(t *testing.T) {
+	statuses := make(chan int, 1)
+	addr := startAbortServer(t, func(h *server.Hertz) {
+		h.Use(middleware.ClientAbortMiddleware())
+		h.Use(func(ctx context.Context, c *app.RequestContext) {
+			c.Next(ctx)
+			statuses <- middleware.ResponseStatus(c)
+		})
+		h.GET("/ok", func(ctx context.Context, c *app.RequestContext) {
+			c.String(200, "ok")
+		})
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "GET /ok HTTP/1.1\r\nHost: test\r\n\r\n")
+	assert.Equal(t, 200, <-statuses)
+	conn.Close()
+}
+
+func TestClientAbort_ServerCancelIsStillError(t *testing.T) {
+	var panics atomic.Int32
+	OnPanic(func(ctx context.Context, r PanicReport) { panics.Add(1) })
+	t.Cleanup(func() { OnPanic(nil) })
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(middleware.ClientAbortMiddleware())
+	engine.Use(ExceptionHandler())
+	// 客户端仍在线，服务端自己取消的上下文（如超时后的 cancel）仍按错误处理
+	engine.GET("/cancel", func(ctx context.Context, c *app.RequestContext) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		panic(ctx.Err())
+	})
+	w := ut.PerformRequest(engine, "GET", "/cancel", nil)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, int32(1), panics.Load())
+}
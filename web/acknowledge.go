@@ -0,0 +1,454 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/redis/go-redis/v9"
+)
+
+// CodeAcknowledgementRequired 用户尚未确认最新条款时的业务码
+const CodeAcknowledgementRequired = 451
+
+// AckConfig 条款确认门禁配置（支持热更新，修改 version 后所有用户需要重新确认）
+//
+// 每个 requirement 是一项需要用户确认的文档（服务条款、隐私政策、公告等），可同时配置多项。
+// 登录用户未确认某项的当前版本时，受保护的请求被拒绝，响应 data 中列出待确认的条款，
+// 客户端据此展示确认页面。未登录的请求、endpoint、PriorityCritical 路由（健康检查/指标）
+// 以及 exempt 中的路由不受影响
+//
+// Example:
+//
+//	[web.acknowledgements]
+//	endpoint = "/api/acknowledgements"      # 确认接口（自动豁免，写入拒绝响应）
+//	status = 451                            # 未确认时的 HTTP 状态码，默认 451
+//
+//	[web.acknowledgements.requirements.terms]
+//	version = "terms-2024-06"
+//	documentURL = "https://example.com/terms/2024-06"
+//	exempt = ["/api/login", "/api/account/*"]   # 结尾 * 为前缀匹配
+type AckConfig struct {
+	Endpoint     string                    `toml:"endpoint"`     // 确认接口路径
+	Status       int                       `toml:"status"`       // 未确认时的 HTTP 状态码
+	Requirements map[string]AckRequirement `toml:"requirements"` // 条款名 -> 当前版本
+}
+
+// AckRequirement 一项需要确认的条款
+type AckRequirement struct {
+	Version     string   `toml:"version"`     // 当前版本（变更后需要重新确认）
+	DocumentURL string   `toml:"documentURL"` // 条款文档地址
+	Exempt      []string `toml:"exempt"`      // 不检查该条款的路由（路由模式或路径，结尾 * 为前缀匹配）
+}
+
+// AckPending 待确认的条款（拒绝响应和确认接口的 data）
+type AckPending struct {
+	Requirement    string `json:"requirement"`
+	Version        string `json:"version"`
+	DocumentURL    string `json:"documentUrl,omitempty"`
+	AcknowledgeURL string `json:"acknowledgeUrl,omitempty"`
+	Acknowledged   bool   `json:"acknowledged"`
+}
+
+// Acknowledgement 一次确认记录
+type Acknowledgement struct {
+	UserID      string    `json:"userId"`
+	Requirement string    `json:"requirement"`
+	Version     string    `json:"version"`
+	IP          string    `json:"ip,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// AckStore 确认记录存储
+//
+// 默认使用 Redis（外加进程内缓存，见 NewCachedAckStore）；保存到数据库时实现此接口并调用 SetAckStore
+type AckStore interface {
+	// Acknowledged 用户最近确认的版本（未确认时返回空字符串）
+	Acknowledged(ctx context.Context, userID, requirement string) (string, error)
+	// Record 保存确认记录（覆盖该用户该条款之前的记录）
+	Record(ctx context.Context, ack Acknowledgement) error
+}
+
+// ackRedisPrefix Redis 中确认记录的 key 前缀（每个用户一个 hash，字段为条款名）
+const ackRedisPrefix = "web:ack:"
+
+// RedisAckStore 使用全局 Redis 客户端的确认记录存储
+type RedisAckStore struct{}
+
+// Acknowledged 实现 AckStore 接口
+func (RedisAckStore) Acknowledged(ctx context.Context, userID, requirement string) (string, error) {
+	if cache.Client == nil {
+		return "", errors.New("Redis 未初始化")
+	}
+	data, err := cache.Client.HGet(ctx, ackRedisPrefix+userID, requirement).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var ack Acknowledgement
+	if err := json.Unmarshal(data, &ack); err != nil {
+		return "", fmt.Errorf("确认记录格式错误: %w", err)
+	}
+	return ack.Version, nil
+}
+
+// Record 实现 AckStore 接口
+func (RedisAckStore) Record(ctx context.Context, ack Acknowledgement) error {
+	if cache.Client == nil {
+		return errors.New("Redis 未初始化")
+	}
+	data, _ := json.Marshal(ack)
+	return cache.Client.HSet(ctx, ackRedisPrefix+ack.UserID, ack.Requirement, data).Err()
+}
+
+// MemoryAckStore 进程内确认记录存储（测试和单实例使用）
+type MemoryAckStore struct {
+	mu   sync.Mutex
+	acks map[string]Acknowledgement
+}
+
+// NewMemoryAckStore 创建进程内确认记录存储
+func NewMemoryAckStore() *MemoryAckStore {
+	return &MemoryAckStore{acks: make(map[string]Acknowledgement)}
+}
+
+// Acknowledged 实现 AckStore 接口
+func (s *MemoryAckStore) Acknowledged(ctx context.Context, userID, requirement string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acks[userID+"\x00"+requirement].Version, nil
+}
+
+// Record 实现 AckStore 接口
+func (s *MemoryAckStore) Record(ctx context.Context, ack Acknowledgement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acks[ack.UserID+"\x00"+ack.Requirement] = ack
+	return nil
+}
+
+// Get 读取确认记录
+func (s *MemoryAckStore) Get(userID, requirement string) (Acknowledgement, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ack, ok := s.acks[userID+"\x00"+requirement]
+	return ack, ok
+}
+
+// cachedAckStore 缓存已确认的版本，避免每个请求都查询存储
+type cachedAckStore struct {
+	store AckStore
+	ttl   time.Duration
+	clock common.Clock
+
+	mu      sync.Mutex
+	entries map[string]ackCacheEntry
+}
+
+type ackCacheEntry struct {
+	version string
+	expires time.Time
+}
+
+// NewCachedAckStore 为确认记录存储加上进程内缓存（clock 为 nil 时使用系统时钟）
+//
+// 只缓存「已确认」的结果：门禁比较缓存的版本与当前版本，版本升级后自动回源；
+// 未确认的结果不缓存，用户在其他实例上确认后立即生效
+func NewCachedAckStore(store AckStore, ttl time.Duration, clock common.Clock) AckStore {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &cachedAckStore{store: store, ttl: ttl, clock: clock, entries: make(map[string]ackCacheEntry)}
+}
+
+// Acknowledged 实现 AckStore 接口
+func (s *cachedAckStore) Acknowledged(ctx context.Context, userID, requirement string) (string, error) {
+	key := userID + "\x00" + requirement
+	now := s.clock.Now()
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.version, nil
+	}
+	version, err := s.store.Acknowledged(ctx, userID, requirement)
+	if err != nil || version == "" {
+		return version, err
+	}
+	s.put(key, version, now)
+	return version, nil
+}
+
+// Record 实现 AckStore 接口
+func (s *cachedAckStore) Record(ctx context.Context, ack Acknowledgement) error {
+	if err := s.store.Record(ctx, ack); err != nil {
+		return err
+	}
+	s.put(ack.UserID+"\x00"+ack.Requirement, ack.Version, s.clock.Now())
+	return nil
+}
+
+func (s *cachedAckStore) put(key, version string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= 100000 {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		if len(s.entries) >= 100000 {
+			s.entries = make(map[string]ackCacheEntry)
+		}
+	}
+	s.entries[key] = ackCacheEntry{version: version, expires: now.Add(s.ttl)}
+}
+
+// compiledAckConfig 预处理后的配置（热路径只读）
+type compiledAckConfig struct {
+	config AckConfig
+	names  []string // 按名称排序，响应中的顺序稳定
+}
+
+var (
+	ackConfig     atomic.Pointer[compiledAckConfig]
+	ackStoreMu    sync.RWMutex
+	ackStore      AckStore
+	ackCheckError = metrics.GetCounter("web_ack_check_errors_total")
+)
+
+// SetAckConfig 应用条款确认配置（启动时和配置热更新时调用）
+func SetAckConfig(config AckConfig) {
+	if config.Status == 0 {
+		config.Status = CodeAcknowledgementRequired
+	}
+	compiled := &compiledAckConfig{config: config}
+	for name, req := range config.Requirements {
+		if req.Version == "" {
+			log.Warnf("[Ack] 条款 %s 未配置 version，忽略", name)
+			continue
+		}
+		compiled.names = append(compiled.names, name)
+	}
+	sort.Strings(compiled.names)
+	if old := ackConfig.Load(); old != nil {
+		for _, name := range compiled.names {
+			if prev, ok := old.config.Requirements[name]; ok && prev.Version != config.Requirements[name].Version {
+				log.Infof("[Ack] 条款 %s 版本更新: %s -> %s", name, prev.Version, config.Requirements[name].Version)
+			}
+		}
+	}
+	ackConfig.Store(compiled)
+}
+
+// SetAckStore 设置确认记录存储（nil 恢复默认的 Redis 存储）
+//
+// 使用方式：
+//
+//	web.SetAckStore(web.NewCachedAckStore(dbAckStore{}, 30*time.Second, nil))
+func SetAckStore(store AckStore) {
+	ackStoreMu.Lock()
+	defer ackStoreMu.Unlock()
+	ackStore = store
+}
+
+// currentAckStore 当前的确认记录存储（默认 Redis + 30 秒缓存）
+func currentAckStore() AckStore {
+	ackStoreMu.RLock()
+	store := ackStore
+	ackStoreMu.RUnlock()
+	if store != nil {
+		return store
+	}
+	ackStoreMu.Lock()
+	defer ackStoreMu.Unlock()
+	if ackStore == nil {
+		ackStore = NewCachedAckStore(RedisAckStore{}, 30*time.Second, nil)
+	}
+	return ackStore
+}
+
+// ackExempt 路由是否在豁免列表中
+func ackExempt(patterns []string, c *app.RequestContext) bool {
+	full, path := c.FullPath(), string(c.Path())
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(full, prefix) || strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if p == full || p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingAcknowledgements 当前请求需要、但用户尚未确认的条款
+//
+// 存储出错时放行该条款（只记录日志和 web_ack_check_errors_total），避免存储故障导致整个 API 不可用
+func pendingAcknowledgements(ctx context.Context, c *app.RequestContext, cfg *compiledAckConfig, userID string) []AckPending {
+	var pending []AckPending
+	store := currentAckStore()
+	for _, name := range cfg.names {
+		req := cfg.config.Requirements[name]
+		if ackExempt(req.Exempt, c) {
+			continue
+		}
+		version, err := store.Acknowledged(ctx, userID, name)
+		if err != nil {
+			ackCheckError.Inc()
+			log.Errorf("[Ack] 查询用户 %s 的条款 %s 确认记录失败，本次放行: %v", userID, name, err)
+			continue
+		}
+		if version != req.Version {
+			pending = append(pending, AckPending{
+				Requirement:    name,
+				Version:        req.Version,
+				DocumentURL:    req.DocumentURL,
+				AcknowledgeURL: cfg.config.Endpoint,
+			})
+		}
+	}
+	return pending
+}
+
+// AcknowledgementGate 条款确认门禁中间件（放在 JWT 中间件之后）
+//
+// 登录用户未确认当前版本的条款时返回 status（默认 451），业务码 CodeAcknowledgementRequired，
+// data 为 {"requirements": [待确认的条款]}。未登录、代理登录（管理员不能代替用户确认）、
+// 确认接口和豁免路由直接放行。未配置任何条款时不做检查
+//
+// 使用方式：
+//
+//	api := r.Group("/api", jwt.Middleware(), web.AcknowledgementGate()).RequireAuth()
+//	api.Any("/acknowledgements", web.AcknowledgeHandler())
+func AcknowledgementGate() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		cfg := ackConfig.Load()
+		if cfg == nil || len(cfg.names) == 0 {
+			c.Next(ctx)
+			return
+		}
+		userID := jwt.GetUserID(c)
+		if userID == "" || jwt.IsImpersonating(c) || routePriority(c) == PriorityCritical {
+			c.Next(ctx)
+			return
+		}
+		if cfg.config.Endpoint != "" && (c.FullPath() == cfg.config.Endpoint || string(c.Path()) == cfg.config.Endpoint) {
+			c.Next(ctx)
+			return
+		}
+		pending := pendingAcknowledgements(ctx, c, cfg, userID)
+		if len(pending) == 0 {
+			c.Next(ctx)
+			return
+		}
+		result := FailWithData(CodeAcknowledgementRequired, MsgAcknowledgementRequired, map[string]any{"requirements": pending})
+		result.TraceID = middleware.GetRequestID(c)
+		c.AbortWithStatusJSON(cfg.config.Status, result)
+	}
+}
+
+// ackRequest 确认请求体
+type ackRequest struct {
+	Requirement string `json:"requirement"`
+	Version     string `json:"version"`
+}
+
+// AcknowledgeHandler 条款确认接口（挂在 AckConfig.Endpoint 上）
+//
+// GET 返回当前用户对每项条款的确认状态；POST 提交 {"requirement": "terms", "version": "terms-2024-06"}
+// 记录确认（用户、版本、时间、IP），同时写入审计事件 acknowledgement.accept。
+// version 必须等于当前版本（期间版本已更新时返回 409，客户端应重新展示最新条款）；
+// 代理登录时不能代替用户确认（403）
+//
+// 使用方式：
+//
+//	api.Any("/acknowledgements", web.AcknowledgeHandler())
+func AcknowledgeHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		userID := jwt.GetUserID(c)
+		if userID == "" {
+			panic(UnauthorizedHTTP(MsgUnauthorized))
+		}
+		cfg := ackConfig.Load()
+		if cfg == nil {
+			cfg = &compiledAckConfig{}
+		}
+		store := currentAckStore()
+
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			if jwt.IsImpersonating(c) {
+				panic(ForbiddenHTTP("代理登录时不能代替用户确认条款"))
+			}
+			var body ackRequest
+			if err := json.Unmarshal(c.Request.Body(), &body); err != nil {
+				panic(BadRequestHTTP("确认请求格式错误"))
+			}
+			req, ok := cfg.config.Requirements[body.Requirement]
+			if !ok || req.Version == "" {
+				panic(BadRequestHTTP("未知的条款: " + body.Requirement))
+			}
+			if body.Version != req.Version {
+				panic(ConflictHTTP(fmt.Sprintf("条款 %s 已更新为 %s，请重新阅读", body.Requirement, req.Version)))
+			}
+			ack := Acknowledgement{
+				UserID:      userID,
+				Requirement: body.Requirement,
+				Version:     req.Version,
+				IP:          c.ClientIP(),
+				Time:        time.Now(),
+			}
+			if err := store.Record(ctx, ack); err != nil {
+				log.Errorf("[Ack] 保存用户 %s 的条款 %s 确认记录失败: %v", userID, body.Requirement, err)
+				panic(InternalHTTP(MsgInternalError))
+			}
+			audit.Emit(ctx, audit.Event{
+				Time:      ack.Time,
+				Type:      "acknowledgement.accept",
+				Actor:     userID,
+				Subject:   userID,
+				RequestID: middleware.GetRequestID(c),
+				Method:    string(c.Method()),
+				Path:      string(c.Path()),
+				Data:      map[string]any{"requirement": ack.Requirement, "version": ack.Version, "ip": ack.IP},
+			})
+			c.JSON(consts.StatusOK, Success(ack))
+		default:
+			status := make([]AckPending, 0, len(cfg.names))
+			for _, name := range cfg.names {
+				req := cfg.config.Requirements[name]
+				version, err := store.Acknowledged(ctx, userID, name)
+				if err != nil {
+					log.Errorf("[Ack] 查询用户 %s 的条款 %s 确认记录失败: %v", userID, name, err)
+					panic(InternalHTTP(MsgInternalError))
+				}
+				status = append(status, AckPending{
+					Requirement:    name,
+					Version:        req.Version,
+					DocumentURL:    req.DocumentURL,
+					AcknowledgeURL: cfg.config.Endpoint,
+					Acknowledged:   version == req.Version,
+				})
+			}
+			c.JSON(consts.StatusOK, Success(status))
+		}
+	}
+}
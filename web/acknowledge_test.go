@@ -0,0 +1,194 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAckEngine(t *testing.T) (*route.Engine, *MemoryAckStore, *ownershipAudit) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "ack-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	store := NewMemoryAckStore()
+	SetAckStore(store)
+	SetAckConfig(AckConfig{
+		Endpoint: "/api/acknowledgements",
+		Requirements: map[string]AckRequirement{
+			"terms":   {Version: "terms-2024-06", DocumentURL: "https://example.com/terms", Exempt: []string{"/api/account/*"}},
+			"privacy": {Version: "privacy-1", DocumentURL: "https://example.com/privacy"},
+		},
+	})
+	t.Cleanup(func() {
+		audit.SetSink(nil)
+		SetAckStore(nil)
+		SetAckConfig(AckConfig{})
+	})
+
+	ok := func(ctx context.Context, c *app.RequestContext) { c.JSON(200, Success(nil)) }
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	r := NewRouter(engine)
+	r.Public().GET("/public/news", ok)
+	api := r.Group("/api", jwt.Middleware(), AcknowledgementGate()).RequireAuth()
+	api.GET("/orders", ok)
+	api.GET("/account/profile", ok)
+	api.GET("/acknowledgements", AcknowledgeHandler())
+	api.POST("/acknowledgements", AcknowledgeHandler())
+	return engine, store, rec
+}
+
+type ackResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Requirements []AckPending `json:"requirements"`
+	} `json:"data"`
+}
+
+func acknowledge(t *testing.T, engine *route.Engine, user, requirement, version string) *ut.ResponseRecorder {
+	body, _ := json.Marshal(ackRequest{Requirement: requirement, Version: version})
+	return ut.PerformRequest(engine, "POST", "/api/acknowledgements", &ut.Body{Body: bytes.NewReader(body), Len: len(body)},
+		ownershipToken(t, user), ut.Header{Key: "Content-Type", Value: "application/json"})
+}
+
+func TestAcknowledgementGate_RejectsUntilAcknowledged(t *testing.T) {
+	engine, store, rec := newAckEngine(t)
+
+	w := ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice"))
+	assert.Equal(t, 451, w.Code)
+	var resp ackResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, CodeAcknowledgementRequired, resp.Code)
+	require.Len(t, resp.Data.Requirements, 2, "同时列出所有待确认的条款")
+	assert.Equal(t, AckPending{Requirement: "privacy", Version: "privacy-1", DocumentURL: "https://example.com/privacy",
+		AcknowledgeURL: "/api/acknowledgements"}, resp.Data.Requirements[0])
+	assert.Equal(t, "terms", resp.Data.Requirements[1].Requirement)
+
+	// 确认接口本身不受门禁限制
+	w = acknowledge(t, engine, "alice", "terms", "terms-2024-06")
+	require.Equal(t, 200, w.Code, w.Body.String())
+	ack, ok := store.Get("alice", "terms")
+	require.True(t, ok)
+	assert.Equal(t, "terms-2024-06", ack.Version)
+	assert.NotEmpty(t, ack.IP)
+	assert.False(t, ack.Time.IsZero())
+	assert.Equal(t, []string{"acknowledgement.accept"}, rec.types())
+
+	w = ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Requirements, 1, "只剩未确认的条款")
+	assert.Equal(t, "privacy", resp.Data.Requirements[0].Requirement)
+
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "privacy", "privacy-1").Code)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice")).Code)
+	assert.Equal(t, 451, ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "bob")).Code, "按用户记录")
+}
+
+func TestAcknowledgementGate_Status(t *testing.T) {
+	engine, _, _ := newAckEngine(t)
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "terms", "terms-2024-06").Code)
+
+	w := ut.PerformRequest(engine, "GET", "/api/acknowledgements", nil, ownershipToken(t, "alice"))
+	require.Equal(t, 200, w.Code)
+	var resp struct {
+		Data []AckPending `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	assert.False(t, resp.Data[0].Acknowledged)
+	assert.True(t, resp.Data[1].Acknowledged)
+
+	assert.Equal(t, 409, acknowledge(t, engine, "alice", "privacy", "privacy-0").Code, "确认的不是当前版本")
+	assert.Equal(t, 400, acknowledge(t, engine, "alice", "cookies", "v1").Code)
+}
+
+func TestAcknowledgementGate_ExemptAndAnonymous(t *testing.T) {
+	engine, _, _ := newAckEngine(t)
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "privacy", "privacy-1").Code)
+
+	// terms 豁免了 /api/account/*，privacy 已确认
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/account/profile", nil, ownershipToken(t, "alice")).Code)
+	// 豁免只针对配置它的条款
+	assert.Equal(t, 451, ut.PerformRequest(engine, "GET", "/api/account/profile", nil, ownershipToken(t, "bob")).Code)
+	// 未登录的公开路由不受影响
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/public/news", nil).Code)
+}
+
+func TestAcknowledgementGate_VersionBump(t *testing.T) {
+	engine, _, _ := newAckEngine(t)
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "terms", "terms-2024-06").Code)
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "privacy", "privacy-1").Code)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice")).Code)
+
+	// 配置热更新：新版本立即生效
+	SetAckConfig(AckConfig{
+		Endpoint: "/api/acknowledgements",
+		Requirements: map[string]AckRequirement{
+			"terms":   {Version: "terms-2025-01"},
+			"privacy": {Version: "privacy-1"},
+		},
+	})
+	w := ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice"))
+	assert.Equal(t, 451, w.Code)
+	assert.Contains(t, w.Body.String(), "terms-2025-01")
+	require.Equal(t, 200, acknowledge(t, engine, "alice", "terms", "terms-2025-01").Code)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "alice")).Code)
+
+	// 撤下全部条款
+	SetAckConfig(AckConfig{})
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/orders", nil, ownershipToken(t, "bob")).Code)
+}
+
+// countingAckStore 统计回源次数
+type countingAckStore struct {
+	*MemoryAckStore
+	lookups int
+}
+
+func (s *countingAckStore) Acknowledged(ctx context.Context, userID, requirement string) (string, error) {
+	s.lookups++
+	return s.MemoryAckStore.Acknowledged(ctx, userID, requirement)
+}
+
+func TestCachedAckStore(t *testing.T) {
+	ctx := context.Background()
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	backend := &countingAckStore{MemoryAckStore: NewMemoryAckStore()}
+	store := NewCachedAckStore(backend, 30*time.Second, clock)
+
+	v, err := store.Acknowledged(ctx, "alice", "terms")
+	require.NoError(t, err)
+	assert.Empty(t, v)
+	_, _ = store.Acknowledged(ctx, "alice", "terms")
+	assert.Equal(t, 2, backend.lookups, "未确认的结果不缓存")
+
+	// 在其他实例上确认
+	require.NoError(t, backend.Record(ctx, Acknowledgement{UserID: "alice", Requirement: "terms", Version: "v1"}))
+	v, _ = store.Acknowledged(ctx, "alice", "terms")
+	assert.Equal(t, "v1", v)
+	_, _ = store.Acknowledged(ctx, "alice", "terms")
+	assert.Equal(t, 3, backend.lookups, "已确认的结果缓存")
+
+	clock.Advance(31 * time.Second)
+	_, _ = store.Acknowledged(ctx, "alice", "terms")
+	assert.Equal(t, 4, backend.lookups, "过期后回源")
+
+	require.NoError(t, store.Record(ctx, Acknowledgement{UserID: "bob", Requirement: "terms", Version: "v1"}))
+	v, _ = store.Acknowledged(ctx, "bob", "terms")
+	assert.Equal(t, "v1", v)
+	assert.Equal(t, 4, backend.lookups, "本实例的确认直接写入缓存")
+}
@@ -0,0 +1,340 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// adaptiveHistorySize 每个优先级保留的上限调整记录条数
+const adaptiveHistorySize = 64
+
+// AdaptiveLimitConfig 自适应并发上限配置（过载保护的一部分，[web.shedding.adaptive]）
+//
+// 静态的 capacity 总是不准：太低浪费容量，太高在下游变慢时让延迟失控。启用后按观测到的延迟调整在途请求上限（AIMD）：
+// 每收集 window 个请求的延迟计算一次窗口平均延迟，不超过目标延迟且上限确实被用到（窗口内在途请求数达到上限的一半）时
+// 上限 +1（缓慢增加），超过目标延迟时上限乘以 backoff（成倍减少），始终限制在 [minLimit, maxLimit] 之间。
+//
+// 目标延迟为 latencyTargetMs；未配置时为 tolerance × 空载延迟（观测到的最低窗口平均延迟）。
+// normal 与 background 各自维护独立的上限和延迟样本，background 的上限收缩不会限制 normal；critical 路由不受限制。
+// 超出上限的请求返回 503 + Retry-After。管理接口可以固定上限（故障处理时人工接管），见 AdaptiveLimitAdminHandler
+//
+// Example:
+//
+//	[web.shedding.adaptive]
+//	enabled = true
+//	minLimit = 4
+//	maxLimit = 500
+//	initialLimit = 20
+//	latencyTargetMs = 200   # 可选，默认 tolerance × 空载延迟
+//	tolerance = 1.5
+//	backoff = 0.9
+//	window = 100            # 每次调整需要的延迟样本数
+type AdaptiveLimitConfig struct {
+	Enabled         bool    `toml:"enabled"`
+	MinLimit        int     `toml:"minLimit"`        // 上限的下界，默认 1
+	MaxLimit        int     `toml:"maxLimit"`        // 上限的上界，默认 shedding.capacity，未配置时 1000
+	InitialLimit    int     `toml:"initialLimit"`    // 初始上限，默认 minLimit 与 20 中较大的一个
+	LatencyTargetMs int     `toml:"latencyTargetMs"` // 目标延迟（毫秒），0 表示按空载延迟推算
+	Tolerance       float64 `toml:"tolerance"`       // 未配置目标延迟时允许的延迟膨胀倍数，默认 1.5
+	Backoff         float64 `toml:"backoff"`         // 延迟超过目标时上限的乘数（0-1），默认 0.9
+	Window          int     `toml:"window"`          // 每个调整窗口的延迟样本数，默认 100
+}
+
+// LimitChange 一次并发上限调整
+type LimitChange struct {
+	Time     time.Time `json:"time"`
+	From     int       `json:"from"`
+	To       int       `json:"to"`
+	Reason   string    `json:"reason"`             // increase / decrease / pin / unpin
+	RTTMs    float64   `json:"rttMs,omitempty"`    // 窗口平均延迟
+	TargetMs float64   `json:"targetMs,omitempty"` // 当时的目标延迟
+}
+
+// AdaptiveLimitStatus 某个优先级的自适应并发上限状态（诊断与管理接口输出）
+type AdaptiveLimitStatus struct {
+	Class    string        `json:"class"`
+	Limit    int           `json:"limit"`             // 当前生效的上限
+	Pinned   bool          `json:"pinned"`            // 是否由管理接口固定
+	Learned  int           `json:"learned"`           // 算法给出的上限（固定期间继续记录，解除后从这里继续）
+	Inflight int64         `json:"inflight"`          // 在途请求数
+	RTTMs    float64       `json:"rttMs"`             // 最近窗口的平均延迟
+	MinRTTMs float64       `json:"minRttMs"`          // 空载延迟
+	TargetMs float64       `json:"targetMs"`          // 目标延迟
+	History  []LimitChange `json:"history,omitempty"` // 最近的调整记录（旧的在前）
+}
+
+// adaptiveLimiter 单个优先级的自适应并发上限
+//
+// 准入只有原子操作；请求结束时在锁内记录延迟样本，窗口满时调整上限
+type adaptiveLimiter struct {
+	class    PriorityClass
+	config   AdaptiveLimitConfig
+	limit    atomic.Int64 // 生效的上限（固定时为固定值）
+	inflight atomic.Int64
+
+	mu          sync.Mutex
+	learned     int           // 算法给出的上限
+	pinned      bool          // 管理接口固定了上限
+	sum         time.Duration // 当前窗口的延迟之和
+	count       int           // 当前窗口的样本数
+	maxInflight int64         // 当前窗口内的最大在途请求数
+	rtt         float64       // 最近窗口的平均延迟（毫秒）
+	minRTT      float64       // 空载延迟（毫秒，0 表示尚未观测）
+	history     []LimitChange // 环形记录
+	next        int
+
+	limitGauge *metrics.Gauge
+	rttGauge   *metrics.Gauge
+	rejected   *metrics.Counter
+	decreases  *metrics.Counter
+}
+
+// withAdaptiveDefaults 零值字段使用默认值（capacity 为过载保护的静态容量）
+func withAdaptiveDefaults(config AdaptiveLimitConfig, capacity int) AdaptiveLimitConfig {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = capacity
+		if config.MaxLimit <= 0 {
+			config.MaxLimit = 1000
+		}
+	}
+	config.MaxLimit = max(config.MaxLimit, config.MinLimit)
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = max(config.MinLimit, 20)
+	}
+	config.InitialLimit = min(max(config.InitialLimit, config.MinLimit), config.MaxLimit)
+	if config.Tolerance <= 1 {
+		config.Tolerance = 1.5
+	}
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		config.Backoff = 0.9
+	}
+	if config.Window <= 0 {
+		config.Window = 100
+	}
+	return config
+}
+
+func newAdaptiveLimiter(class PriorityClass, config AdaptiveLimitConfig) *adaptiveLimiter {
+	l := &adaptiveLimiter{
+		class:      class,
+		config:     config,
+		learned:    config.InitialLimit,
+		history:    make([]LimitChange, 0, adaptiveHistorySize),
+		limitGauge: metrics.GetGauge("web_adaptive_limit", "class", class.String()),
+		rttGauge:   metrics.GetGauge("web_adaptive_rtt_ms", "class", class.String()),
+		rejected:   metrics.GetCounter("web_adaptive_rejected_total", "class", class.String()),
+		decreases:  metrics.GetCounter("web_adaptive_decrease_total", "class", class.String()),
+	}
+	l.limit.Store(int64(config.InitialLimit))
+	l.limitGauge.Set(float64(config.InitialLimit))
+	return l
+}
+
+// acquire 在途请求数未达到上限时占用一个名额
+func (l *adaptiveLimiter) acquire() bool {
+	for {
+		n := l.inflight.Load()
+		if n >= l.limit.Load() {
+			l.rejected.Inc()
+			return false
+		}
+		if l.inflight.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// release 释放名额并记录延迟（inflight 为请求开始时的在途请求数，含自身）
+func (l *adaptiveLimiter) release(d time.Duration, inflight int64) {
+	l.inflight.Add(-1)
+	l.observe(d, inflight)
+}
+
+// observe 记录一个延迟样本，窗口满时调整上限
+func (l *adaptiveLimiter) observe(d time.Duration, inflight int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sum += d
+	l.count++
+	l.maxInflight = max(l.maxInflight, inflight)
+	if l.count < l.config.Window {
+		return
+	}
+
+	l.rtt = float64(l.sum) / float64(l.count) / float64(time.Millisecond)
+	utilized := l.maxInflight*2 >= int64(l.learned)
+	l.sum, l.count, l.maxInflight = 0, 0, 0
+	l.rttGauge.Set(l.rtt)
+	if l.minRTT == 0 || l.rtt < l.minRTT {
+		l.minRTT = l.rtt
+	}
+
+	target := l.target()
+	next, reason := l.learned, ""
+	switch {
+	case l.rtt > target:
+		next, reason = max(int(math.Floor(float64(l.learned)*l.config.Backoff)), l.config.MinLimit), "decrease"
+	case utilized:
+		next, reason = min(l.learned+1, l.config.MaxLimit), "increase"
+	}
+	if next == l.learned {
+		return
+	}
+	if reason == "decrease" {
+		l.decreases.Inc()
+	}
+	if !l.pinned {
+		l.record(LimitChange{From: l.learned, To: next, Reason: reason, RTTMs: l.rtt, TargetMs: target})
+		l.limit.Store(int64(next))
+		l.limitGauge.Set(float64(next))
+	}
+	l.learned = next
+}
+
+// target 目标延迟（毫秒，调用方持有 mu）
+func (l *adaptiveLimiter) target() float64 {
+	if l.config.LatencyTargetMs > 0 {
+		return float64(l.config.LatencyTargetMs)
+	}
+	return l.minRTT * l.config.Tolerance
+}
+
+// record 追加一条调整记录（调用方持有 mu）
+func (l *adaptiveLimiter) record(change LimitChange) {
+	change.Time = time.Now()
+	if len(l.history) < adaptiveHistorySize {
+		l.history = append(l.history, change)
+		return
+	}
+	l.history[l.next] = change
+	l.next = (l.next + 1) % adaptiveHistorySize
+}
+
+// pin 固定上限（limit <= 0 时解除固定，恢复算法给出的上限）
+func (l *adaptiveLimiter) pin(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	from := int(l.limit.Load())
+	if limit <= 0 {
+		if !l.pinned {
+			return
+		}
+		l.pinned = false
+		limit = l.learned
+		l.record(LimitChange{From: from, To: limit, Reason: "unpin"})
+	} else {
+		l.pinned = true
+		l.record(LimitChange{From: from, To: limit, Reason: "pin"})
+	}
+	l.limit.Store(int64(limit))
+	l.limitGauge.Set(float64(limit))
+}
+
+// Limit 当前生效的上限
+func (l *adaptiveLimiter) Limit() int {
+	return int(l.limit.Load())
+}
+
+// status 当前状态
+func (l *adaptiveLimiter) status() AdaptiveLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	history := make([]LimitChange, 0, len(l.history))
+	history = append(history, l.history[l.next:]...)
+	history = append(history, l.history[:l.next]...)
+	return AdaptiveLimitStatus{
+		Class:    l.class.String(),
+		Limit:    l.Limit(),
+		Pinned:   l.pinned,
+		Learned:  l.learned,
+		Inflight: l.inflight.Load(),
+		RTTMs:    l.rtt,
+		MinRTTMs: l.minRTT,
+		TargetMs: l.target(),
+		History:  history,
+	}
+}
+
+// AdaptiveStatus 各优先级的自适应并发上限状态（未启用时返回 nil）
+func (s *Shedder) AdaptiveStatus() []AdaptiveLimitStatus {
+	var out []AdaptiveLimitStatus
+	for _, l := range s.adaptive {
+		if l != nil {
+			out = append(out, l.status())
+		}
+	}
+	return out
+}
+
+// PinAdaptiveLimit 固定某个优先级的并发上限（limit <= 0 时解除固定）；该优先级没有自适应上限时返回 false
+func (s *Shedder) PinAdaptiveLimit(class PriorityClass, limit int) bool {
+	if class < 0 || int(class) >= len(s.adaptive) || s.adaptive[class] == nil {
+		return false
+	}
+	l := s.adaptive[class]
+	l.pin(limit)
+	return true
+}
+
+// parsePriorityClass 优先级名称（PriorityClass.String 的逆）
+func parsePriorityClass(name string) (PriorityClass, bool) {
+	for _, class := range []PriorityClass{PriorityNormal, PriorityCritical, PriorityBackground} {
+		if class.String() == name {
+			return class, true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// AdaptiveLimitAdminHandler 自适应并发上限管理接口
+//
+// GET 返回各优先级的上限、延迟与最近的调整记录；POST 提交 {"class": "background", "limit": 10} 固定上限
+// （故障处理时人工接管，算法继续学习但不生效）；DELETE ?class=background 解除固定，省略 class 时解除全部。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/adaptive-limit", web.AdaptiveLimitAdminHandler())
+func AdaptiveLimitAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		s := globalShedder
+		if s == nil || s.AdaptiveStatus() == nil {
+			panic(NewHTTPException(404, 404, "Adaptive limit not enabled"))
+		}
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			var req struct {
+				Class string `json:"class"`
+				Limit int    `json:"limit"`
+			}
+			if err := json.Unmarshal(c.Request.Body(), &req); err != nil || req.Limit <= 0 {
+				panic(BadRequestHTTP("需要 class 与正整数 limit"))
+			}
+			class, ok := parsePriorityClass(req.Class)
+			if !ok || !s.PinAdaptiveLimit(class, req.Limit) {
+				panic(BadRequestHTTP("优先级没有自适应并发上限: " + req.Class))
+			}
+			log.Warnf("[Shedding] 管理接口固定 %s 并发上限: %d", req.Class, req.Limit)
+		case consts.MethodDelete:
+			name := c.Query("class")
+			for _, class := range []PriorityClass{PriorityNormal, PriorityBackground} {
+				if name == "" || name == class.String() {
+					s.PinAdaptiveLimit(class, 0)
+				}
+			}
+			log.Warnf("[Shedding] 管理接口解除并发上限固定: %s", name)
+		}
+		c.JSON(consts.StatusOK, Success(s.AdaptiveStatus()))
+	}
+}
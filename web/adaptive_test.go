@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDownstream 并发不超过 knee 时延迟为 base，超过后排队，延迟随并发线性增长（吞吐量保持 knee/base）
+func fakeDownstream(base time.Duration, knee int) func(n int) time.Duration {
+	return func(n int) time.Duration {
+		if n <= knee {
+			return base
+		}
+		return base * time.Duration(n) / time.Duration(knee)
+	}
+}
+
+// simulate 需求始终超过上限（并发 = 上限），每个窗口按当前上限的延迟喂入样本，返回每个窗口后的上限
+func simulate(l *adaptiveLimiter, downstream func(n int) time.Duration, windows int) []int {
+	limits := make([]int, 0, windows)
+	for range windows {
+		n := l.Limit()
+		for range l.config.Window {
+			l.observe(downstream(n), int64(n))
+		}
+		limits = append(limits, l.Limit())
+	}
+	return limits
+}
+
+func bounds(limits []int) (lo, hi int) {
+	lo, hi = limits[0], limits[0]
+	for _, n := range limits {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	return lo, hi
+}
+
+func TestAdaptiveLimiter_ConvergesNearKnee(t *testing.T) {
+	l := newAdaptiveLimiter(PriorityNormal, withAdaptiveDefaults(AdaptiveLimitConfig{
+		MinLimit: 2, MaxLimit: 200, InitialLimit: 5, Window: 50,
+	}, 0))
+
+	// 从 5 爬升，在膝点（20）与 tolerance × 膝点之间小幅波动，不会冲到 maxLimit 也不会塌到 minLimit
+	limits := simulate(l, fakeDownstream(10*time.Millisecond, 20), 300)
+	lo, hi := bounds(limits[100:])
+	assert.GreaterOrEqual(t, lo, 20, "稳定后并发不低于膝点，吞吐量不损失")
+	assert.LessOrEqual(t, hi, 31, "延迟不超过 1.5 倍空载延迟")
+	assert.LessOrEqual(t, hi-lo, 5, "稳定后只在小范围内调整")
+
+	status := l.status()
+	assert.Equal(t, 10.0, status.MinRTTMs)
+	assert.Equal(t, 15.0, status.TargetMs)
+	assert.Len(t, status.History, adaptiveHistorySize)
+	assert.Equal(t, status.Limit, status.History[len(status.History)-1].To, "最新的记录在最后")
+
+	// 下游变慢（膝点降到 10）：成倍收缩到新的膝点附近
+	limits = simulate(l, fakeDownstream(10*time.Millisecond, 10), 100)
+	lo, hi = bounds(limits[50:])
+	assert.GreaterOrEqual(t, lo, 10)
+	assert.LessOrEqual(t, hi, 16)
+}
+
+func TestAdaptiveLimiter_LatencyTargetAndIdle(t *testing.T) {
+	l := newAdaptiveLimiter(PriorityBackground, withAdaptiveDefaults(AdaptiveLimitConfig{
+		MinLimit: 1, MaxLimit: 100, InitialLimit: 50, LatencyTargetMs: 20, Window: 10,
+	}, 0))
+	limits := simulate(l, fakeDownstream(10*time.Millisecond, 5), 100)
+	lo, hi := bounds(limits[60:])
+	assert.GreaterOrEqual(t, lo, 9)
+	assert.LessOrEqual(t, hi, 11, "在延迟目标 20ms 对应的并发 10 附近")
+
+	// 上限没有被用到时不增加
+	before := l.Limit()
+	for range 10 * l.config.Window {
+		l.observe(time.Millisecond, 1)
+	}
+	assert.Equal(t, before, l.Limit())
+}
+
+func TestShedder_AdaptiveIsolatedPerClass(t *testing.T) {
+	s := NewShedder(SheddingConfig{Adaptive: AdaptiveLimitConfig{Enabled: true, InitialLimit: 10}})
+	require.Nil(t, s.adaptive[PriorityCritical])
+	require.True(t, s.PinAdaptiveLimit(PriorityBackground, 1))
+	rejectedBefore := s.adaptive[PriorityBackground].rejected.Value()
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(s.Middleware())
+	release := make(chan struct{})
+	entered := make(chan struct{}, 1)
+	engine.GET("/batch", Priority(PriorityBackground), func(ctx context.Context, c *app.RequestContext) {
+		if c.Query("block") != "" {
+			entered <- struct{}{}
+			<-release
+		}
+		c.JSON(200, Success(nil))
+	})
+	ok := func(ctx context.Context, c *app.RequestContext) { c.JSON(200, Success(nil)) }
+	engine.GET("/api", ok)
+	engine.GET("/health", Priority(PriorityCritical), ok)
+
+	done := make(chan int)
+	go func() { done <- ut.PerformRequest(engine, "GET", "/batch?block=1", nil).Result().StatusCode() }()
+	<-entered
+
+	w := ut.PerformRequest(engine, "GET", "/batch", nil)
+	assert.Equal(t, 503, w.Result().StatusCode(), "background 的上限已满")
+	assert.Equal(t, "1", string(w.Result().Header.Peek("Retry-After")))
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api", nil).Result().StatusCode(), "normal 有自己的上限")
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/health", nil).Result().StatusCode())
+	assert.Equal(t, rejectedBefore+1, s.adaptive[PriorityBackground].rejected.Value())
+
+	s.SetDisabled(true)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/batch", nil).Result().StatusCode(), "紧急开关同样关闭自适应上限")
+	s.SetDisabled(false)
+
+	close(release)
+	assert.Equal(t, 200, <-done)
+	assert.Equal(t, int64(0), s.adaptive[PriorityBackground].inflight.Load())
+	assert.Equal(t, int64(0), s.adaptive[PriorityNormal].inflight.Load())
+}
+
+func TestAdaptiveLimitAdminHandler(t *testing.T) {
+	previous := globalShedder
+	t.Cleanup(func() { globalShedder = previous })
+	globalShedder = NewShedder(SheddingConfig{Capacity: 100, Adaptive: AdaptiveLimitConfig{Enabled: true}})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.Any("/admin/adaptive-limit", AdaptiveLimitAdminHandler())
+	perform := func(method, path, body string) (int, []AdaptiveLimitStatus) {
+		var b *ut.Body
+		if body != "" {
+			b = &ut.Body{Body: strings.NewReader(body), Len: len(body)}
+		}
+		w := ut.PerformRequest(engine, method, path, b)
+		var resp struct {
+			Data []AdaptiveLimitStatus `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	code, statuses := perform("GET", "/admin/adaptive-limit", "")
+	require.Equal(t, 200, code)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "normal", statuses[0].Class)
+	assert.Equal(t, 20, statuses[0].Limit)
+
+	code, statuses = perform("POST", "/admin/adaptive-limit", `{"class":"background","limit":3}`)
+	require.Equal(t, 200, code)
+	assert.True(t, statuses[1].Pinned)
+	assert.Equal(t, 3, statuses[1].Limit)
+	assert.Equal(t, 20, statuses[1].Learned)
+	assert.Equal(t, "pin", statuses[1].History[0].Reason)
+
+	code, _ = perform("POST", "/admin/adaptive-limit", `{"class":"critical","limit":3}`)
+	assert.Equal(t, 400, code, "critical 没有自适应上限")
+	code, _ = perform("POST", "/admin/adaptive-limit", `{"class":"normal"}`)
+	assert.Equal(t, 400, code)
+
+	code, statuses = perform("DELETE", "/admin/adaptive-limit?class=background", "")
+	require.Equal(t, 200, code)
+	assert.False(t, statuses[1].Pinned)
+	assert.Equal(t, 20, statuses[1].Limit)
+
+	globalShedder = NewShedder(SheddingConfig{Capacity: 100})
+	code, _ = perform("GET", "/admin/adaptive-limit", "")
+	assert.Equal(t, 404, code)
+}
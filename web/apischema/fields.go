@@ -0,0 +1,218 @@
+// Package apischema 按 encoding/json 规则展开请求/响应类型，供 TypeScript 客户端生成（web/tsclient）
+// 与路由清单（web.ExportRouteManifest）共用
+//
+// Fields 给出结构体编码为 JSON 时的字段，SchemaOf 把类型展开为 JSON Schema 风格的结构，
+// RegisterEnum 登记的枚举在两者中都表现为取值列表
+package apischema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	TimeType          = reflect.TypeFor[time.Time]()
+	FileHeaderType    = reflect.TypeFor[multipart.FileHeader]()
+	RawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	enumMu            sync.RWMutex
+	enums             = map[reflect.Type][]string{}
+)
+
+// RegisterEnum 登记枚举类型的全部取值
+//
+// 取值按 JSON 编码（字符串枚举为 "a"，数值枚举为 1），顺序与登记顺序一致
+//
+// 使用方式：
+//
+//	type OrderStatus string
+//
+//	const (
+//	    OrderPending OrderStatus = "pending"
+//	    OrderPaid    OrderStatus = "paid"
+//	)
+//
+//	func init() {
+//	    apischema.RegisterEnum(OrderPending, OrderPaid)
+//	}
+func RegisterEnum[T comparable](values ...T) {
+	literals := make([]string, 0, len(values))
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("apischema.RegisterEnum: %v 无法编码为 JSON: %v", v, err))
+		}
+		literals = append(literals, string(data))
+	}
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enums[reflect.TypeFor[T]()] = literals
+}
+
+// EnumValues 登记过的枚举取值（JSON 编码）
+func EnumValues(t reflect.Type) ([]string, bool) {
+	enumMu.RLock()
+	defer enumMu.RUnlock()
+	v, ok := enums[t]
+	return v, ok
+}
+
+// Opaque 按特殊规则映射、不展开字段的类型（time.Time、上传文件、json.RawMessage、自定义编码）
+func Opaque(t reflect.Type) bool {
+	switch t {
+	case TimeType, FileHeaderType, RawMessageType:
+		return true
+	}
+	return Marshals(t, jsonMarshalerType) || Marshals(t, textMarshalerType)
+}
+
+// JSONMarshaler 是否自定义了 JSON 编码（结构未知）
+func JSONMarshaler(t reflect.Type) bool {
+	return Marshals(t, jsonMarshalerType)
+}
+
+// TextMarshaler 是否编码为文本（JSON 字符串）
+func TextMarshaler(t reflect.Type) bool {
+	return Marshals(t, textMarshalerType)
+}
+
+// Marshals t 或 *t 是否实现了 iface
+func Marshals(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+// Field 按 encoding/json 规则展开后的字段
+type Field struct {
+	Name      string       // JSON 名称
+	GoName    string       // Go 字段名
+	Type      reflect.Type // 字段类型
+	OmitEmpty bool         // omitempty / omitzero
+	AsString  bool         // ,string 选项（标量编码为字符串）
+	depth     int
+	tagged    bool
+}
+
+// Fields 结构体编码为 JSON 时的字段（展开匿名嵌入，较浅的字段遮蔽较深的同名字段）
+func Fields(t reflect.Type) []Field {
+	var all []Field
+	var walk func(t reflect.Type, depth int, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, depth int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				inner := ft
+				if inner.Kind() == reflect.Pointer {
+					inner = inner.Elem()
+				}
+				if inner.Kind() == reflect.Struct && !Opaque(inner) {
+					walk(inner, depth+1, visited)
+					continue
+				}
+			}
+			// 只有 path 标签的字段来自路径参数，不在请求体或查询参数中
+			if !sf.IsExported() || tag == "" && sf.Tag.Get("path") != "" {
+				continue
+			}
+			if name == "" {
+				name = wireName(sf)
+			}
+			all = append(all, Field{
+				Name:      name,
+				GoName:    sf.Name,
+				Type:      ft,
+				OmitEmpty: hasOption(opts, "omitempty") || hasOption(opts, "omitzero"),
+				AsString:  hasOption(opts, "string") && scalar(ft),
+				depth:     depth,
+				tagged:    tag != "",
+			})
+		}
+	}
+	walk(t, 0, map[reflect.Type]bool{})
+
+	// 同名字段取最浅的一个；同一深度有多个时取有标签的，仍有歧义时省略（与 encoding/json 一致）
+	var fields []Field
+	for i, f := range all {
+		keep := true
+		for j, other := range all {
+			if i == j || other.Name != f.Name {
+				continue
+			}
+			if other.depth < f.depth || other.depth == f.depth && (other.tagged && !f.tagged || other.tagged == f.tagged) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// wireName 没有 json 标签的字段名：请求结构体上的 query / form 标签优先（Hertz 绑定使用），否则为 Go 字段名
+func wireName(sf reflect.StructField) string {
+	for _, key := range []string{"query", "form"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func scalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// HasFile 请求类型是否包含上传文件字段（按 multipart/form-data 发送）
+func HasFile(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, f := range Fields(t) {
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft == FileHeaderType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package apischema
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Schema JSON Schema 风格的类型描述（字段按 JSON 名称排序编码，同一类型的结果完全一致）
+//
+// type 取值：string / integer / number / boolean / array / object / any；
+// format 补充说明 string 的格式：date-time（time.Time）、binary（上传文件）、byte（[]byte 的 base64）
+type Schema struct {
+	Type                 string             `json:"type"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`                 // 登记过的枚举取值（JSON 编码）
+	Items                *Schema            `json:"items,omitempty"`                // 数组元素
+	Properties           map[string]*Schema `json:"properties,omitempty"`           // 对象字段
+	Required             []string           `json:"required,omitempty"`             // 总会出现的字段（没有 omitempty、不是指针）
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"` // map 的值
+	Nullable             bool               `json:"nullable,omitempty"`             // 指针，可以为 null
+	Ref                  string             `json:"ref,omitempty"`                  // 递归引用的 Go 类型（不再展开）
+	Closed               bool               `json:"-"`                              // 不接受未声明的字段，编码为 "additionalProperties": false
+}
+
+// SchemaOf 把 Go 类型展开为 Schema（不能编码为 JSON 的类型为 any）
+func SchemaOf(t reflect.Type) *Schema {
+	return schemaOf(t, map[reflect.Type]bool{})
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := typeSchema(t, visiting)
+	s.Nullable = s.Nullable || nullable
+	return s
+}
+
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	if values, ok := EnumValues(t); ok {
+		s := &Schema{Type: "string", Enum: values}
+		if kind := kindType(t.Kind()); kind != "" {
+			s.Type = kind
+		}
+		return s
+	}
+	switch t {
+	case TimeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case FileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case RawMessageType:
+		return &Schema{Type: "any"}
+	}
+	if JSONMarshaler(t) {
+		return &Schema{Type: "any"}
+	}
+	if TextMarshaler(t) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return &Schema{Type: "object", Ref: t.String()}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, f := range Fields(t) {
+			fs := schemaOf(f.Type, visiting)
+			if f.AsString {
+				fs = &Schema{Type: "string", Nullable: fs.Nullable}
+			}
+			s.Properties[f.Name] = fs
+			if !f.OmitEmpty && f.Type.Kind() != reflect.Pointer {
+				s.Required = append(s.Required, f.Name)
+			}
+		}
+		sort.Strings(s.Required)
+		return s
+	case reflect.Interface:
+		return &Schema{Type: "any"}
+	}
+	if kind := kindType(t.Kind()); kind != "" {
+		return &Schema{Type: kind}
+	}
+	return &Schema{Type: "any"}
+}
+
+// kindType 标量类型
+func kindType(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return ""
+}
+
+// Close 把 s 及其中嵌套的结构体对象标记为不接受未声明的字段（map 不受影响），返回 s
+func Close(s *Schema) *Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Type == "object" && s.Properties != nil {
+		s.Closed = true
+		for _, p := range s.Properties {
+			Close(p)
+		}
+	}
+	Close(s.Items)
+	Close(s.AdditionalProperties)
+	return s
+}
+
+// MarshalJSON Closed 的对象编码为 "additionalProperties": false
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed || s.AdditionalProperties != nil {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain: (*plain)(s)})
+}
+
+// UnmarshalJSON 解析 MarshalJSON 的结果（"additionalProperties": false 还原为 Closed）
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	aux := struct {
+		*plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	switch string(aux.AdditionalProperties) {
+	case "", "null", "true":
+	case "false":
+		s.Closed = true
+	default:
+		s.AdditionalProperties = &Schema{}
+		return json.Unmarshal(aux.AdditionalProperties, s.AdditionalProperties)
+	}
+	return nil
+}
+
+// String 紧凑的 JSON 表示
+func (s *Schema) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,335 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// App 一个服务的资源容器：数据库连接池（含只读副本）、Redis 客户端、JWT 认证、IP 限流、翻译与组件
+//
+// 包级函数（RateLimitMiddleware、RegisterMessages、RegisterComponent 等）转发到默认 App（DefaultApp）；
+// 默认 App 的数据库、Redis 与 JWT 是子包的全局实例（database.DB、cache.Client、jwt.Init），
+// 由 InitDB / InitRedis / jwt.Init / InitRateLimiter 初始化，行为与之前相同。
+// 同一进程中运行多个相互独立的服务（如对外 API 与连接不同数据库的内部管理服务）时，
+// 每个服务通过 NewApp 创建自己的 App，并在自己的引擎上使用 App 的中间件，互不影响。
+//
+// 使用方式：
+//
+//	public, err := web.NewApp(publicCfg.Config, web.WithJWT(publicCfg.JWT))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer public.Close()
+//
+//	h.Use(public.Middleware(), public.RateLimitMiddleware(), public.JWTAuthMiddleware(), public.DBMiddleware())
+//	h.GET("/orders", func(ctx context.Context, c *app.RequestContext) {
+//	    a := web.AppOf(c)                          // 当前请求所属的 App
+//	    rows, err := a.DB().QueryContext(ctx, ...) // jwt.GetUserID(c) 等同样按请求所属的 App 解析
+//	})
+type App struct {
+	name   string
+	lang   string // 翻译的默认语言（空值时使用 DefaultLocale）
+	config atomic.Pointer[Config]
+	res    appResources
+	log    *zap.SugaredLogger // nil 时使用全局日志记录器
+
+	limiter   *IPRateLimiter // IP 限流器（未启用时为 nil）
+	messages  *messageStore  // 翻译（以内置翻译为初始内容，其他 App 不可见）
+	lifecycle *Lifecycle     // 组件注册表
+}
+
+// appResources App 的数据库、Redis 与 JWT
+//
+// 默认 App 的这些资源属于各自的子包（database.DB、cache.Client、jwt.Init，子包不能依赖 web），
+// 由 globalResources 在使用时读取；NewApp 创建的 App 持有自己的 ownResources
+type appResources interface {
+	db() *sql.DB
+	replica() database.DBTX
+	redis() *redis.Client
+	auth() *jwt.Auth
+	txMiddleware(config Config) app.HandlerFunc
+	close() error
+}
+
+// globalResources 默认 App 的资源：InitDB、InitRedis、jwt.Init 初始化的全局实例
+type globalResources struct{}
+
+func (globalResources) db() *sql.DB                         { return database.DB }
+func (globalResources) replica() database.DBTX              { return database.Replica() }
+func (globalResources) redis() *redis.Client                { return cache.Client }
+func (globalResources) auth() *jwt.Auth                     { return jwt.Default() }
+func (globalResources) txMiddleware(Config) app.HandlerFunc { return database.DBMiddleware() }
+func (globalResources) close() error {
+	return errors.Join(database.Close(), cache.Close())
+}
+
+// ownResources NewApp 打开的资源（Close 时释放，只释放一次）
+type ownResources struct {
+	pool        *sql.DB
+	replicaPool *sql.DB
+	client      *redis.Client
+	jwtAuth     *jwt.Auth
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (r *ownResources) db() *sql.DB          { return r.pool }
+func (r *ownResources) redis() *redis.Client { return r.client }
+func (r *ownResources) auth() *jwt.Auth      { return r.jwtAuth }
+
+func (r *ownResources) replica() database.DBTX {
+	if r.replicaPool != nil {
+		return database.ReadOnly(r.replicaPool)
+	}
+	return database.ReadOnly(r.pool)
+}
+
+func (r *ownResources) txMiddleware(config Config) app.HandlerFunc {
+	return database.TxMiddleware(r.pool, config.Database.OnClientAbort)
+}
+
+func (r *ownResources) close() error {
+	r.closeOnce.Do(func() {
+		var errs []error
+		for _, db := range []*sql.DB{r.replicaPool, r.pool} {
+			if db != nil {
+				errs = append(errs, db.Close())
+			}
+		}
+		if r.client != nil {
+			errs = append(errs, r.client.Close())
+		}
+		r.closeErr = errors.Join(errs...)
+	})
+	return r.closeErr
+}
+
+// newApp 创建 App 的状态（翻译与组件注册表），资源由调用方设置
+func newApp(name string, config Config, res appResources) *App {
+	a := &App{name: name, lang: config.DefaultLang, res: res, messages: newMessageStore(), lifecycle: NewLifecycle()}
+	a.config.Store(&config)
+	return a
+}
+
+// defaultApp 包级函数使用的默认 App
+var defaultApp = newApp("default", Config{}, globalResources{})
+
+// DefaultApp 默认 App（资源为 InitDB、InitRedis、jwt.Init、InitRateLimiter 初始化的全局资源）
+func DefaultApp() *App {
+	return defaultApp
+}
+
+// AppOption NewApp 的可选配置
+type AppOption func(*appOptions)
+
+type appOptions struct {
+	name  string
+	jwt   *jwt.Config
+	rps   float64
+	burst int
+}
+
+// WithAppName 设置 App 名称（日志中的 app 字段），默认 "app"
+func WithAppName(name string) AppOption {
+	return func(o *appOptions) { o.name = name }
+}
+
+// WithJWT 为 App 启用独立的 JWT 认证（密钥与默认实例无关）
+func WithJWT(config jwt.Config) AppOption {
+	return func(o *appOptions) { o.jwt = &config }
+}
+
+// WithRateLimit 为 App 启用独立的 IP 限流
+func WithRateLimit(rps float64, burst int) AppOption {
+	return func(o *appOptions) { o.rps, o.burst = rps, burst }
+}
+
+// NewApp 按配置创建 App，打开自己的数据库连接池与 Redis 客户端（未配置的资源跳过）
+//
+// 任一资源初始化失败时关闭已经打开的资源并返回错误；使用完毕后调用 Close 释放
+func NewApp(config Config, opts ...AppOption) (*App, error) {
+	o := appOptions{name: "app"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res := &ownResources{}
+	a := newApp(o.name, config, res)
+	a.log = logger.With("app", o.name)
+	var err error
+	if res.pool, err = database.Open(config.Database); err != nil {
+		return nil, fmt.Errorf("app %s: %w", o.name, err)
+	}
+	if res.replicaPool, err = database.OpenReplica(config.Database); err != nil {
+		_ = a.Close()
+		return nil, fmt.Errorf("app %s: %w", o.name, err)
+	}
+	if res.client, err = cache.NewClient(config.Redis); err != nil {
+		_ = a.Close()
+		return nil, fmt.Errorf("app %s: %w", o.name, err)
+	}
+	if o.jwt != nil {
+		if res.jwtAuth, err = jwt.New(*o.jwt); err != nil {
+			_ = a.Close()
+			return nil, fmt.Errorf("app %s: %w", o.name, err)
+		}
+	}
+	if o.rps > 0 {
+		a.limiter = NewIPRateLimiter(o.rps, o.burst)
+		a.limiter.Cleanup()
+	}
+	return a, nil
+}
+
+// Name App 名称
+func (a *App) Name() string {
+	return a.name
+}
+
+// Config App 的配置（默认 App 为 NewServer 加载的 web 配置，NewServer 之前为空配置）
+func (a *App) Config() Config {
+	return *a.config.Load()
+}
+
+// DB 数据库连接池（未配置时为 nil）
+func (a *App) DB() *sql.DB {
+	return a.res.db()
+}
+
+// Replica App 的只读副本（未配置副本时使用主库连接池，未配置数据库时为 nil），写语句返回 database.ErrReplicaWrite
+//
+// 默认 App 等同于 database.Replica()
+//
+// 使用方式：
+//
+//	q := sqlc.New(web.AppOf(c).Replica())
+func (a *App) Replica() database.DBTX {
+	return a.res.replica()
+}
+
+// Cache Redis 客户端（未配置时为 nil）
+func (a *App) Cache() *redis.Client {
+	return a.res.redis()
+}
+
+// Auth JWT 认证实例（未启用时为 nil）
+func (a *App) Auth() *jwt.Auth {
+	return a.res.auth()
+}
+
+// Logger 带 app 字段的日志记录器（默认 App 为全局日志记录器）
+func (a *App) Logger() *zap.SugaredLogger {
+	if a.log == nil {
+		return logger.GetLogger()
+	}
+	return a.log
+}
+
+// appKey 请求上所属的 App
+const appKey = "web_app"
+
+type appCtxKey struct{}
+
+// Middleware 把请求绑定到 App（AppOf、AppFrom、LocalizeMessage 等据此找到 App），注册在最前面
+func (a *App) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Set(appKey, a)
+		c.Next(context.WithValue(ctx, appCtxKey{}, a))
+	}
+}
+
+// AppOf 请求所属的 App（没有经过 App.Middleware 时为默认 App）
+func AppOf(c *app.RequestContext) *App {
+	if v, ok := c.Get(appKey); ok {
+		return v.(*App)
+	}
+	return defaultApp
+}
+
+// AppFrom ctx 所属的 App（请求之外或没有经过 App.Middleware 时为默认 App）
+func AppFrom(ctx context.Context) *App {
+	if a, ok := ctx.Value(appCtxKey{}).(*App); ok {
+		return a
+	}
+	return defaultApp
+}
+
+// JWTAuthMiddleware App 的 JWT 认证中间件（未启用 JWT 时放行）
+func (a *App) JWTAuthMiddleware() app.HandlerFunc {
+	auth := a.Auth()
+	if auth == nil {
+		return func(ctx context.Context, c *app.RequestContext) { c.Next(ctx) }
+	}
+	return auth.Middleware()
+}
+
+// DBMiddleware App 的数据库事务中间件（未配置数据库时放行）
+func (a *App) DBMiddleware() app.HandlerFunc {
+	return a.res.txMiddleware(a.Config())
+}
+
+// RegisterMessages 注册 App 自己的翻译，与已有翻译合并（其他 App 不可见）
+//
+// 每个 App 的翻译以内置消息的翻译为初始内容；包级的 RegisterMessages 注册到默认 App
+func (a *App) RegisterMessages(lang string, translations map[string]string) {
+	a.messages.register(lang, translations)
+}
+
+// defaultLanguage 翻译的默认语言（NewApp 的 DefaultLang，未配置时为 DefaultLocale 的语言）
+func (a *App) defaultLanguage() string {
+	if a.lang != "" {
+		return a.lang
+	}
+	return defaultLocale.Load().Language
+}
+
+// translate 查找 App 的翻译：请求语言 → 同一主语言 → App 的默认语言；ok 表示 key 是已注册的消息键
+func (a *App) translate(lang, key string) (string, bool) {
+	return a.messages.translate(lang, a.defaultLanguage(), key)
+}
+
+// isRegisteredKey App 的代码中注册的消息键
+func (a *App) isRegisteredKey(key string) bool {
+	return a.messages.isRegisteredKey(key)
+}
+
+// RegisterComponent 在 App 上注册组件（名称重复时 panic），StartComponents 按依赖顺序启动，Close 时逆序停止
+func (a *App) RegisterComponent(c Component) {
+	if err := a.lifecycle.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// StartComponents 按依赖顺序启动 App 中尚未启动的组件（任一失败时停止已启动的组件并返回错误）
+//
+// 默认 App 的组件由 NewServer 与 MustRun 启动
+func (a *App) StartComponents(ctx context.Context) error {
+	reports, err := a.lifecycle.Start(ctx)
+	logStartupReport(reports)
+	return err
+}
+
+// Close 按启动的逆序停止 App 的组件，然后释放 App 持有的全部资源：限流器的定期清理、
+// 数据库连接池（含只读副本）与 Redis 客户端（NewApp 创建的 App 可重复调用）
+//
+// 默认 App 释放的是全局资源（database.Close、cache.Close）
+func (a *App) Close() error {
+	a.lifecycle.Stop(context.Background())
+	if a.limiter != nil {
+		a.limiter.Stop()
+	}
+	return a.res.close()
+}
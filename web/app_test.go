@@ -0,0 +1,57 @@
+package web
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_CloseStopsComponentsAndReleasesPools(t *testing.T) {
+	a, err := NewApp(Config{Database: database.DatabaseConfig{Driver: registerMemSQL(&memSQL{})}})
+	require.NoError(t, err)
+
+	var events []string
+	a.RegisterComponent(Component{
+		Name:  "worker",
+		Start: func(ctx context.Context) error { events = append(events, "start"); return nil },
+		Stop:  func(ctx context.Context) error { events = append(events, "stop"); return nil },
+	})
+	require.NoError(t, a.StartComponents(context.Background()))
+
+	require.NoError(t, a.Close())
+	require.NoError(t, a.Close(), "重复关闭")
+	assert.Equal(t, []string{"start", "stop"}, events)
+	assert.Error(t, a.DB().Ping(), "连接池已关闭")
+}
+
+func TestRegisterBuiltinComponents_UseConfigAtStart(t *testing.T) {
+	a := newApp("test", Config{}, globalResources{})
+	registerBuiltinComponents(a)
+	registerBuiltinComponents(a) // 重复调用 NewServer
+
+	var got []string
+	build := a.configuredComponent("probe", func(c Config) Component {
+		got = append(got, c.DefaultLang)
+		return Component{}
+	})
+	require.NoError(t, a.lifecycle.Register(build))
+
+	first := Config{DefaultLang: "zh-CN"}
+	a.config.Store(&first)
+	require.NoError(t, callWithTimeout(context.Background(), build.Start, defaultStartTimeout))
+	second := Config{DefaultLang: "en-US"}
+	a.config.Store(&second)
+	require.NoError(t, callWithTimeout(context.Background(), build.Start, defaultStartTimeout))
+	assert.Equal(t, []string{"zh-CN", "en-US"}, got, "启动时读取 App 当前的配置，不沿用首次注册时的配置")
+
+	order, err := a.lifecycle.Order()
+	require.NoError(t, err)
+	names := make([]string, len(order))
+	for i, c := range order {
+		names[i] = c.Name
+	}
+	assert.Equal(t, []string{ComponentDatabase, ComponentRedis, ComponentSLO, ComponentErrorPages, ComponentBandwidth, "probe"}, names)
+}
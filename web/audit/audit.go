@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/logger"
+)
+
+// Event 审计事件
+type Event struct {
+	Time         time.Time      `json:"time"`                   // 发生时间
+	Type         string         `json:"type"`                   // 事件类型，如 impersonation.request
+	Actor        string         `json:"actor,omitempty"`        // 实际操作人
+	Subject      string         `json:"subject,omitempty"`      // 被操作/被代理的用户
+	Impersonated bool           `json:"impersonated,omitempty"` // 是否在代理登录状态下发生
+	RequestID    string         `json:"requestId,omitempty"`    // 请求 ID
+	Method       string         `json:"method,omitempty"`       // HTTP 方法
+	Path         string         `json:"path,omitempty"`         // 请求路径
+	Status       int            `json:"status,omitempty"`       // 响应状态码
+	Data         map[string]any `json:"data,omitempty"`         // 附加数据
+}
+
+// Sink 审计事件输出目标
+//
+// 实现方可以写数据库、消息队列或外部审计系统
+type Sink interface {
+	Write(ctx context.Context, event Event) error
+}
+
+// SinkFunc 函数适配器
+type SinkFunc func(ctx context.Context, event Event) error
+
+// Write 实现 Sink 接口
+func (f SinkFunc) Write(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LogSink 默认输出：以 JSON 写入日志
+type LogSink struct{}
+
+// Write 实现 Sink 接口
+func (LogSink) Write(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	logger.Infof("[AUDIT] %s", data)
+	return nil
+}
+
+var sink atomic.Pointer[Sink]
+
+// SetSink 设置全局审计输出（nil 恢复默认的日志输出）
+//
+// 使用方式：
+//
+//	audit.SetSink(audit.SinkFunc(func(ctx context.Context, e audit.Event) error {
+//	    return db.InsertAudit(ctx, e)
+//	}))
+func SetSink(s Sink) {
+	if s == nil {
+		sink.Store(nil)
+		return
+	}
+	sink.Store(&s)
+}
+
+// Emit 写入审计事件
+//
+// 未设置 Time 时自动填充；写入失败只记录错误日志，不影响业务流程
+//
+// 使用方式：
+//
+//	audit.Emit(ctx, audit.Event{Type: "user.delete", Actor: adminID, Subject: userID})
+func Emit(ctx context.Context, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	var s Sink = LogSink{}
+	if p := sink.Load(); p != nil {
+		s = *p
+	}
+	if err := s.Write(ctx, event); err != nil {
+		logger.Errorf("[AUDIT] 写入审计事件失败: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package web
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// ComponentBandwidth 吞吐量统计组件名
+const ComponentBandwidth = "bandwidth"
+
+// BandwidthConfig 带宽限制配置（字节/秒，0 表示不限制）
+//
+// 优先级：用户解析器（SetBandwidthResolver）> 路由（BandwidthLimit）> 全局配置
+//
+// Example:
+//
+//	[web.bandwidth]
+//	downloadPerConn = 1048576   # 每个下载连接 1MB/s
+//	uploadPerConn = 524288      # 每个上传连接 512KB/s
+//	burst = 0                   # 突发量（字节），默认等于 1 秒的速率
+type BandwidthConfig struct {
+	DownloadPerConn int64 `toml:"downloadPerConn"` // 单连接下载速率
+	UploadPerConn   int64 `toml:"uploadPerConn"`   // 单连接上传速率
+	Burst           int64 `toml:"burst"`           // 突发量
+}
+
+// BandwidthLimits 单个请求的速率限制
+type BandwidthLimits struct {
+	Download int64 // 下载速率（字节/秒），0 表示不限制
+	Upload   int64 // 上传速率（字节/秒），0 表示不限制
+}
+
+// BandwidthResolver 按用户返回速率限制（ok = false 时使用路由/全局配置）
+//
+// 使用方式：
+//
+//	web.SetBandwidthResolver(func(c *app.RequestContext, userID string) (web.BandwidthLimits, bool) {
+//	    if isPremium(userID) {
+//	        return web.BandwidthLimits{Download: 10 << 20}, true
+//	    }
+//	    return web.BandwidthLimits{}, false
+//	})
+type BandwidthResolver func(c *app.RequestContext, userID string) (BandwidthLimits, bool)
+
+// TokenBucket 令牌桶（单位：字节）
+//
+// 令牌不足时按欠缺量休眠，不忙等；休眠可被 context 取消
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  common.Clock
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewTokenBucket 创建令牌桶（burst <= 0 时等于 rate；clock 为 nil 时使用系统时钟）
+func NewTokenBucket(rate, burst int64, clock common.Clock) *TokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &TokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+		clock:  clock,
+		sleep:  sleepContext,
+	}
+}
+
+// sleepContext 可取消的休眠
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WaitN 消耗 n 个令牌，不足时休眠到补足为止
+func (b *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	now := b.clock.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	return b.sleep(ctx, time.Duration(deficit/b.rate*float64(time.Second)))
+}
+
+// chunk 单次读写的最大字节数，保证每次休眠不超过约 100ms，断开连接能及时停止
+func (b *TokenBucket) chunk() int {
+	n := int(b.rate / 10)
+	if n > int(b.burst) {
+		n = int(b.burst)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+var bandwidthBytes = map[string]*metrics.Counter{
+	"download": metrics.GetCounter("web_bandwidth_bytes_total", "direction", "download"),
+	"upload":   metrics.GetCounter("web_bandwidth_bytes_total", "direction", "upload"),
+}
+
+// ThrottledReader 限速读取
+type ThrottledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	bucket  *TokenBucket
+	counter *metrics.Counter
+}
+
+// NewThrottledReader 创建限速读取器（bucket 为 nil 时不限速，只计数）
+func NewThrottledReader(ctx context.Context, r io.Reader, bucket *TokenBucket) *ThrottledReader {
+	return &ThrottledReader{ctx: ctx, r: r, bucket: bucket}
+}
+
+// Read 实现 io.Reader：按实际读取的字节数消耗令牌
+func (t *ThrottledReader) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if t.bucket != nil && len(p) > t.bucket.chunk() {
+		p = p[:t.bucket.chunk()]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if t.counter != nil {
+			t.counter.Add(int64(n))
+		}
+		if t.bucket != nil {
+			if werr := t.bucket.WaitN(t.ctx, n); werr != nil {
+				return n, werr
+			}
+		}
+	}
+	return n, err
+}
+
+// Close 关闭底层读取器（如果支持）
+func (t *ThrottledReader) Close() error {
+	if c, ok := t.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ThrottledWriter 限速写入
+type ThrottledWriter struct {
+	ctx    context.Context
+	w      io.Writer
+	bucket *TokenBucket
+}
+
+// NewThrottledWriter 创建限速写入器（bucket 为 nil 时不限速）
+func NewThrottledWriter(ctx context.Context, w io.Writer, bucket *TokenBucket) *ThrottledWriter {
+	return &ThrottledWriter{ctx: ctx, w: w, bucket: bucket}
+}
+
+// Write 实现 io.Writer：分块写入，每块写完后消耗令牌
+func (t *ThrottledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if err := t.ctx.Err(); err != nil {
+			return written, err
+		}
+		chunk := p
+		if t.bucket != nil && len(chunk) > t.bucket.chunk() {
+			chunk = chunk[:t.bucket.chunk()]
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if t.bucket != nil {
+			if err := t.bucket.WaitN(t.ctx, n); err != nil {
+				return written, err
+			}
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+var (
+	bandwidthConfig   atomic.Pointer[BandwidthConfig]
+	bandwidthResolver atomic.Pointer[BandwidthResolver]
+)
+
+// InitBandwidth 设置全局带宽限制（吞吐量指标由 ComponentBandwidth 组件统计）
+func InitBandwidth(config BandwidthConfig) {
+	bandwidthConfig.Store(&config)
+}
+
+// SetBandwidthResolver 设置按用户的带宽解析器（nil 取消）
+func SetBandwidthResolver(resolver BandwidthResolver) {
+	if resolver == nil {
+		bandwidthResolver.Store(nil)
+		return
+	}
+	bandwidthResolver.Store(&resolver)
+}
+
+// BandwidthLimit 声明路由或路由组的带宽限制
+//
+// 使用方式：
+//
+//	h.GET("/exports/:id", web.BandwidthLimit(web.BandwidthLimits{Download: 256 << 10}), exportHandler)
+func BandwidthLimit(limits BandwidthLimits) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Set("bandwidth_limits", limits)
+		c.Next(ctx)
+	}
+}
+
+// resolveBandwidth 解析当前请求的速率限制
+func resolveBandwidth(c *app.RequestContext) BandwidthLimits {
+	if p := bandwidthResolver.Load(); p != nil {
+		if limits, ok := (*p)(c, jwt.GetUserID(c)); ok {
+			return limits
+		}
+	}
+	if v, ok := c.Get("bandwidth_limits"); ok {
+		if limits, ok := v.(BandwidthLimits); ok {
+			return limits
+		}
+	}
+	if cfg := bandwidthConfig.Load(); cfg != nil {
+		return BandwidthLimits{Download: cfg.DownloadPerConn, Upload: cfg.UploadPerConn}
+	}
+	return BandwidthLimits{}
+}
+
+// newBucket 按速率创建令牌桶（rate <= 0 返回 nil）
+func newBucket(rate int64) *TokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	var burst int64
+	if cfg := bandwidthConfig.Load(); cfg != nil {
+		burst = cfg.Burst
+	}
+	return NewTokenBucket(rate, burst, nil)
+}
+
+// throttleDownload 包装下载数据流
+func throttleDownload(ctx context.Context, c *app.RequestContext, r io.Reader) *ThrottledReader {
+	t := NewThrottledReader(ctx, r, newBucket(resolveBandwidth(c).Download))
+	t.counter = bandwidthBytes["download"]
+	return t
+}
+
+// throttleUpload 包装上传数据流
+func throttleUpload(ctx context.Context, c *app.RequestContext, r io.Reader) *ThrottledReader {
+	t := NewThrottledReader(ctx, r, newBucket(resolveBandwidth(c).Upload))
+	t.counter = bandwidthBytes["upload"]
+	return t
+}
+
+// bandwidthComponent 吞吐量统计组件（每秒更新 web_bandwidth_throughput_bytes）
+func bandwidthComponent() Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return Component{
+		Name: ComponentBandwidth,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				reportThroughput(runCtx, time.Second)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// reportThroughput 每个周期把字节计数的增量换算为吞吐量指标（字节/秒），直到 ctx 取消
+func reportThroughput(ctx context.Context, interval time.Duration) {
+	gauges := map[string]*metrics.Gauge{
+		"download": metrics.GetGauge("web_bandwidth_throughput_bytes", "direction", "download"),
+		"upload":   metrics.GetGauge("web_bandwidth_throughput_bytes", "direction", "upload"),
+	}
+	last := map[string]int64{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		for dir, counter := range bandwidthBytes {
+			v := counter.Value()
+			gauges[dir].Set(float64(v-last[dir]) / interval.Seconds())
+			last[dir] = v
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSleepBucket 休眠时推进假时钟，并记录总休眠时间
+func fakeSleepBucket(rate, burst int64) (*TokenBucket, *time.Duration) {
+	clock := common.NewFakeClock(time.Unix(0, 0))
+	bucket := NewTokenBucket(rate, burst, clock)
+	var slept time.Duration
+	bucket.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		clock.Advance(d)
+		return nil
+	}
+	return bucket, &slept
+}
+
+func TestThrottledReader_Duration(t *testing.T) {
+	const size = 1 << 20
+	bucket, slept := fakeSleepBucket(100<<10, 100<<10)
+
+	n, err := io.Copy(io.Discard, NewThrottledReader(context.Background(), bytes.NewReader(make([]byte, size)), bucket))
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+	// 首个 burst 立即发送，剩余 924KB 以 100KB/s 发送
+	assert.InDelta(t, 9.24, slept.Seconds(), 0.05)
+}
+
+func TestThrottledWriter_Duration(t *testing.T) {
+	bucket, slept := fakeSleepBucket(100<<10, 100<<10)
+
+	var out bytes.Buffer
+	n, err := NewThrottledWriter(context.Background(), &out, bucket).Write(make([]byte, 1<<20))
+	require.NoError(t, err)
+	assert.Equal(t, 1<<20, n)
+	assert.Equal(t, 1<<20, out.Len())
+	assert.InDelta(t, 9.24, slept.Seconds(), 0.05)
+}
+
+func TestThrottledReader_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewThrottledReader(ctx, bytes.NewReader(make([]byte, 1<<20)), NewTokenBucket(1024, 1024, nil))
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDownloadWithRange_Throttled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.GET("/download", BandwidthLimit(BandwidthLimits{Download: 1 << 20}), func(ctx context.Context, c *app.RequestContext) {
+		DownloadWithRange(c, path, "data.bin")
+	})
+
+	w := ut.PerformRequest(engine, "GET", "/download", nil, ut.Header{Key: "Range", Value: "bytes=10-19"})
+	assert.Equal(t, 206, w.Result().StatusCode())
+	assert.Equal(t, "abcdefghij", string(w.Result().Body()))
+	assert.Equal(t, "bytes 10-19/36", string(w.Result().Header.Peek("Content-Range")))
+
+	w = ut.PerformRequest(engine, "GET", "/download", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Equal(t, content, w.Result().Body())
+}
+
+func TestBandwidthComponent_StopEndsThroughputReporting(t *testing.T) {
+	c := bandwidthComponent()
+	require.NoError(t, c.Stop(context.Background()), "未启动时停止为空操作")
+	require.NoError(t, c.Start(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, c.Stop(ctx), "停止时等待统计协程退出")
+}
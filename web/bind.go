@@ -0,0 +1,197 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderContentSanitized 响应头：列出被清洗过的字段，客户端可据此提示用户
+const HeaderContentSanitized = "X-Content-Sanitized"
+
+// Bind 绑定并校验请求参数，校验通过后按 sanitize 标签清洗富文本字段
+//
+// 支持 string、*string、[]string 字段以及嵌套结构体；标签值为已注册的策略名（strict/basic/relaxed 或自定义）。
+// 有字段被清洗时设置 X-Content-Sanitized 响应头，并可通过 GetSanitizedFields 获取。
+//
+// 使用方式：
+//
+//	type CreateCommentReq struct {
+//	    PostID  int64  `json:"postId" vd:"$>0"`
+//	    Content string `json:"content" vd:"len($)>0" sanitize:"basic"`
+//	}
+//
+//	func createComment(ctx context.Context, c *app.RequestContext) error {
+//	    var req CreateCommentReq
+//	    if err := web.Bind(c, &req); err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func Bind(c *app.RequestContext, obj any) error {
+	return BindContext(context.Background(), c, obj)
+}
+
+// BindContext 与 Bind 相同，数据库规则（validate:"unique=..." / validate:"exists=..."）通过 ctx 绑定的 DBTX 执行
+//
+// ctx 没有绑定 DBTX 时使用 DBMiddleware 开启的请求事务，都没有时使用全局连接池。
+// 表与列需要先通过 AllowDBTable 登记；同一请求内相同的检查只查询一次。
+// vd 规则与数据库规则未通过时返回 *ValidationError（400，data.fields 为字段错误列表），vd 未通过时不再查询数据库。
+// 严格绑定的路由（Router.Strict / SetStrictBinding）先检查未声明的 JSON 字段，有未知字段时不再绑定
+//
+// 使用方式：
+//
+//	type UpdateUserReq struct {
+//	    ID         int64  `path:"id"`
+//	    Email      string `json:"email" vd:"email($)" validate:"unique=users.email,self=ID"`
+//	    CategoryID int64  `json:"categoryId" validate:"exists=categories.id"`
+//	}
+//
+//	func updateUser(ctx context.Context, c *app.RequestContext) error {
+//	    var req UpdateUserReq
+//	    if err := web.BindContext(ctx, c, &req); err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func BindContext(ctx context.Context, c *app.RequestContext, obj any) error {
+	if unknown := unknownFieldErrors(c, obj); len(unknown) > 0 {
+		return &ValidationError{Fields: unknown}
+	}
+	if err := c.BindAndValidate(obj); err != nil {
+		if fe, ok := vdFieldError(err); ok {
+			return &ValidationError{Fields: []FieldError{fe}}
+		}
+		return BadRequestHTTP(err.Error())
+	}
+	fields, err := SanitizeStruct(obj)
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		c.Set("sanitized_fields", fields)
+		c.Response.Header.Set(HeaderContentSanitized, strings.Join(fields, ","))
+	}
+	failed, err := validateDBRules(ctx, requestConn(ctx, c), obj, dbRuleCache(c))
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &ValidationError{Fields: failed}
+	}
+	return nil
+}
+
+// vdFieldError 把 vd 规则的校验错误转换为字段错误（Hertz 的错误类型未导出，按 FailPath 字段读取）
+func vdFieldError(err error) (FieldError, bool) {
+	v := reflect.ValueOf(err)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return FieldError{}, false
+	}
+	path := v.Elem().FieldByName("FailPath")
+	if !path.IsValid() || path.Kind() != reflect.String {
+		return FieldError{}, false
+	}
+	return FieldError{Field: path.String(), Rule: "vd", Message: err.Error()}, true
+}
+
+// GetSanitizedFields 获取本次请求中被清洗过的字段（json 名，嵌套字段用 . 连接）
+func GetSanitizedFields(c *app.RequestContext) []string {
+	if v, ok := c.Get("sanitized_fields"); ok {
+		if fields, ok := v.([]string); ok {
+			return fields
+		}
+	}
+	return nil
+}
+
+// SanitizeStruct 按 sanitize 标签原地清洗结构体字段，返回内容被改动的字段
+//
+// 标签引用未注册的策略时返回错误（属于编码错误，不应静默放行）
+func SanitizeStruct(obj any) ([]string, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, fmt.Errorf("sanitize: 需要结构体指针，实际为 %T", obj)
+	}
+	var changed []string
+	if err := sanitizeValue(v.Elem(), "", &changed); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func sanitizeValue(v reflect.Value, prefix string, changed *[]string) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		name := prefix + fieldName(field)
+
+		tag := field.Tag.Get("sanitize")
+		if tag == "" || tag == "-" {
+			if err := sanitizeValue(fv, name+".", changed); err != nil {
+				return err
+			}
+			continue
+		}
+
+		policy, ok := lookupSanitizePolicy(tag)
+		if !ok {
+			return fmt.Errorf("sanitize: 字段 %s 引用了未注册的策略 %q", name, tag)
+		}
+		if sanitizeField(fv, policy) {
+			*changed = append(*changed, name)
+		}
+	}
+	return nil
+}
+
+// sanitizeField 清洗单个字段，返回是否移除了内容
+func sanitizeField(v reflect.Value, policy SanitizePolicy) bool {
+	switch {
+	case v.Kind() == reflect.String:
+		out, report := SanitizeHTMLReport(v.String(), policy)
+		v.SetString(out)
+		return report.Stripped
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.String:
+		if v.IsNil() {
+			return false
+		}
+		return sanitizeField(v.Elem(), policy)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		stripped := false
+		for i := 0; i < v.Len(); i++ {
+			if sanitizeField(v.Index(i), policy) {
+				stripped = true
+			}
+		}
+		return stripped
+	}
+	return false
+}
+
+// fieldName 字段的 json 名（没有 json 标签时使用字段名）
+func fieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("json"); tag != "" {
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,338 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`   // 字段名（json 名，嵌套字段用 . 连接；vd 规则为结构体字段路径）
+	Rule    string `json:"rule"`    // 未通过的规则：vd / unique / exists / unknown（严格绑定下未声明的字段）
+	Message string `json:"message"` // 错误说明
+}
+
+// ValidationError 请求参数校验失败（Bind 返回）
+//
+// web.WrapHandler / web.ExceptionHandler 把它渲染为 400 + ValidationFailed，data.fields 为字段错误列表
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// DBCheck 一次数据库规则检查
+type DBCheck struct {
+	Value any // 字段值（切片字段逐个元素检查）
+	Self  any // 需要排除的当前记录主键（标签中 self= 指定的字段值；创建时为 nil）
+}
+
+// DBRuleFunc 自定义数据库规则：返回满足条件的记录是否存在（unique 要求不存在，exists 要求存在）
+//
+// db 为请求绑定的连接（DBMiddleware 开启的事务中可以看到尚未提交的数据）
+type DBRuleFunc func(ctx context.Context, db database.DBTX, check DBCheck) (bool, error)
+
+// dbTable 登记的表：主键列与允许校验的列
+type dbTable struct {
+	key     string
+	columns map[string]bool
+}
+
+var (
+	dbTablesMu sync.RWMutex
+	dbTables   = map[string]dbTable{}
+	dbRules    = sync.Map{}
+)
+
+// AllowDBTable 登记 unique / exists 标签可以引用的表与列（标识符白名单）
+//
+// 标签中的 table.column 必须先登记，未登记时 Bind 返回错误（属于编码错误，不应静默放行）。
+// key 为主键列，本身也可以被引用；self= 排除当前记录时按主键比较
+//
+// 使用方式：
+//
+//	web.AllowDBTable("users", "id", "email", "username")
+//	web.AllowDBTable("categories", "id")
+func AllowDBTable(table, key string, columns ...string) {
+	dbTablesMu.Lock()
+	defer dbTablesMu.Unlock()
+	t := dbTable{key: key, columns: map[string]bool{key: true}}
+	for _, c := range columns {
+		t.columns[c] = true
+	}
+	dbTables[table] = t
+}
+
+// RegisterDBRule 注册自定义数据库规则，之后可在标签中按名称引用（unique=name / exists=name）
+//
+// 用于 table.column 表达不了的检查，如多租户下「租户内唯一」。自定义规则不参与批量查询，但同样按请求缓存
+//
+// 使用方式：
+//
+//	web.RegisterDBRule("tenant_email", web.DBRuleSQL(
+//	    "SELECT 1 FROM users WHERE tenant_id = ? AND email = ? AND id <> COALESCE(?, 0)",
+//	    func(ctx context.Context, check web.DBCheck) []any {
+//	        return []any{tenant.FromContext(ctx), check.Value, check.Self}
+//	    },
+//	))
+//
+//	type UpdateUserReq struct {
+//	    ID    int64  `path:"id"`
+//	    Email string `json:"email" validate:"unique=tenant_email,self=ID"`
+//	}
+func RegisterDBRule(name string, fn DBRuleFunc) {
+	if name == "" || strings.Contains(name, ".") {
+		panic("validate: rule name must be non-empty and must not contain '.'")
+	}
+	dbRules.Store(name, fn)
+}
+
+// DBRuleSQL 用自定义 SQL 实现规则：查询返回任意行即视为存在
+//
+// args 返回查询参数（占位符按数据库方言书写），值全部参数化；创建时 check.Self 为 nil，SQL 需自行处理
+func DBRuleSQL(query string, args func(ctx context.Context, check DBCheck) []any) DBRuleFunc {
+	return func(ctx context.Context, db database.DBTX, check DBCheck) (bool, error) {
+		rows, err := db.QueryContext(ctx, query, args(ctx, check)...)
+		if err != nil {
+			return false, err
+		}
+		defer rows.Close()
+		found := rows.Next()
+		return found, rows.Err()
+	}
+}
+
+// ValidateDBRules 按 validate 标签执行数据库规则，返回未通过的字段（不缓存，Bind 之外使用）
+//
+// 标签格式：validate:"unique=users.email" / validate:"exists=categories.id"，多条规则用逗号分隔；
+// self=ID 表示按同一结构体中 ID 字段的值排除当前记录（更新时使用，ID 为零值时不排除）。
+// 零值与 nil 指针跳过（必填由 vd 负责），切片字段逐个元素检查。
+// 所有 table.column 规则合并为一条查询（UNION），通过 db 执行
+func ValidateDBRules(ctx context.Context, db database.DBTX, obj any) ([]FieldError, error) {
+	return validateDBRules(ctx, db, obj, map[string]bool{})
+}
+
+// dbRuleCheck 待执行的一次检查
+type dbRuleCheck struct {
+	field  string
+	rule   string // unique / exists
+	target string // table.column 或自定义规则名
+	check  DBCheck
+}
+
+func (r dbRuleCheck) cacheKey() string {
+	return fmt.Sprintf("%s|%T:%v|%T:%v", r.target, r.check.Value, r.check.Value, r.check.Self, r.check.Self)
+}
+
+func validateDBRules(ctx context.Context, db database.DBTX, obj any, cache map[string]bool) ([]FieldError, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, fmt.Errorf("validate: 需要结构体指针，实际为 %T", obj)
+	}
+	var checks []dbRuleCheck
+	if err := collectDBRules(v.Elem(), "", &checks); err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("validate: 数据库未初始化")
+	}
+
+	// table.column 规则合并为一次查询，自定义规则逐个执行
+	var queries []database.ExistsQuery
+	var batched []string
+	for _, r := range checks {
+		key := r.cacheKey()
+		if _, ok := cache[key]; ok {
+			continue
+		}
+		table, column, ok := strings.Cut(r.target, ".")
+		if !ok {
+			fn, _ := dbRules.Load(r.target)
+			found, err := fn.(DBRuleFunc)(ctx, db, r.check)
+			if err != nil {
+				return nil, err
+			}
+			cache[key] = found
+			continue
+		}
+		q := database.ExistsQuery{Table: table, Column: column, Value: r.check.Value}
+		if r.check.Self != nil {
+			q.ExcludeColumn, q.Exclude = lookupDBTable(table).key, r.check.Self
+		}
+		cache[key] = false
+		queries = append(queries, q)
+		batched = append(batched, key)
+	}
+	found, err := database.Exists(ctx, db, queries)
+	if err != nil {
+		for _, key := range batched {
+			delete(cache, key)
+		}
+		return nil, err
+	}
+	for i, key := range batched {
+		cache[key] = found[i]
+	}
+
+	var fields []FieldError
+	for _, r := range checks {
+		switch found := cache[r.cacheKey()]; {
+		case r.rule == "unique" && found:
+			fields = append(fields, FieldError{Field: r.field, Rule: r.rule, Message: fmt.Sprintf("%v 已被使用", r.check.Value)})
+		case r.rule == "exists" && !found:
+			fields = append(fields, FieldError{Field: r.field, Rule: r.rule, Message: fmt.Sprintf("%v 不存在", r.check.Value)})
+		}
+	}
+	return fields, nil
+}
+
+func lookupDBTable(table string) dbTable {
+	dbTablesMu.RLock()
+	defer dbTablesMu.RUnlock()
+	return dbTables[table]
+}
+
+// collectDBRules 遍历结构体字段，按 validate 标签收集检查（引用未登记的列或规则时返回错误）
+func collectDBRules(v reflect.Value, prefix string, checks *[]dbRuleCheck) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			if err := collectDBRules(v.Field(i), name+".", checks); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var rules [][2]string
+		var self any
+		for _, item := range strings.Split(tag, ",") {
+			key, val, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "unique", "exists":
+				if err := checkDBTarget(val); err != nil {
+					return fmt.Errorf("validate: 字段 %s: %w", name, err)
+				}
+				rules = append(rules, [2]string{key, val})
+			case "self":
+				sf := v.FieldByName(val)
+				if !sf.IsValid() {
+					return fmt.Errorf("validate: 字段 %s 的 self 引用了不存在的字段 %q", name, val)
+				}
+				self = dbValue(sf)
+			default:
+				return fmt.Errorf("validate: 字段 %s 使用了未知规则 %q", name, key)
+			}
+		}
+		for _, value := range dbValues(v.Field(i)) {
+			for _, r := range rules {
+				*checks = append(*checks, dbRuleCheck{field: name, rule: r[0], target: r[1], check: DBCheck{Value: value, Self: self}})
+			}
+		}
+	}
+	return nil
+}
+
+// checkDBTarget 检查规则目标：table.column 必须已登记，其他必须是已注册的自定义规则
+func checkDBTarget(target string) error {
+	table, column, ok := strings.Cut(target, ".")
+	if !ok {
+		if _, ok := dbRules.Load(target); !ok {
+			return fmt.Errorf("未注册的规则 %q", target)
+		}
+		return nil
+	}
+	if !lookupDBTable(table).columns[column] {
+		return fmt.Errorf("未登记的列 %q（见 web.AllowDBTable）", target)
+	}
+	return nil
+}
+
+// dbValues 字段需要检查的值：切片逐个元素，零值与 nil 指针跳过
+func dbValues(v reflect.Value) []any {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		var out []any
+		for i := 0; i < v.Len(); i++ {
+			if value := dbValue(v.Index(i)); value != nil {
+				out = append(out, value)
+			}
+		}
+		return out
+	}
+	if value := dbValue(v); value != nil {
+		return []any{value}
+	}
+	return nil
+}
+
+// dbValue 解引用后的字段值（零值与 nil 指针返回 nil）
+func dbValue(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// requestConn 请求使用的连接：context 绑定的 DBTX，context 没有绑定时使用 DBMiddleware 开启的事务
+func requestConn(ctx context.Context, c *app.RequestContext) database.DBTX {
+	conn := database.Conn(ctx)
+	if conn != nil && conn != database.DBTX(database.DB) {
+		return conn
+	}
+	if v, ok := c.Get("tx"); ok {
+		if tx, ok := v.(*sql.Tx); ok {
+			return tx
+		}
+	}
+	return conn
+}
+
+// dbRuleCache 请求内的检查结果缓存（同一请求多次 Bind 时相同的值不重复查询）
+func dbRuleCache(c *app.RequestContext) map[string]bool {
+	if v, ok := c.Get("db_rule_cache"); ok {
+		if cache, ok := v.(map[string]bool); ok {
+			return cache
+		}
+	}
+	cache := map[string]bool{}
+	c.Set("db_rule_cache", cache)
+	return cache
+}
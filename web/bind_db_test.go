@@ -0,0 +1,250 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowsDB 内存数据库：支持 INSERT 与 database.Exists 生成的 UNION 查询，事务内插入的行提交前只对本事务可见
+type rowsDB struct{ *memSQL }
+
+func newRowsDB(t *testing.T) (*rowsDB, *sql.DB) {
+	f := &rowsDB{&memSQL{}}
+	f.exec, f.query = f.execInsert, f.queryExists
+	return f, newMemSQL(t, f.memSQL)
+}
+
+var insertPattern = regexp.MustCompile(`^INSERT INTO (\w+) \(([\w, ]+)\)`)
+
+func (f *rowsDB) execInsert(c *memConn, query string, args []any) (driver.Result, error) {
+	m := insertPattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected exec: %s", query)
+	}
+	row := map[string]any{}
+	for i, col := range strings.Split(m[2], ", ") {
+		row[col] = fmt.Sprint(args[i])
+	}
+	c.insert(m[1], row)
+	return driver.RowsAffected(1), nil
+}
+
+var existsPartPattern = regexp.MustCompile(`^SELECT (\d+) AS k FROM "(\w+)" WHERE "(\w+)" = \?(?: AND "(\w+)" <> \?)?$`)
+
+func (f *rowsDB) queryExists(c *memConn, query string, args []any) ([]string, [][]driver.Value, error) {
+	var out [][]driver.Value
+	for _, part := range strings.Split(query, " UNION ") {
+		m := existsPartPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, nil, fmt.Errorf("unexpected query: %s", part)
+		}
+		value := fmt.Sprint(args[0])
+		exclude, excluded := "", m[4] != ""
+		args = args[1:]
+		if excluded {
+			exclude, args = fmt.Sprint(args[0]), args[1:]
+		}
+		for _, row := range c.rows(m[2]) {
+			if row[m[3]] == value && (!excluded || row[m[4]] != exclude) {
+				out = append(out, []driver.Value{m[1]})
+				break
+			}
+		}
+	}
+	return []string{"k"}, out, nil
+}
+
+// useRowsDB 把内存数据库设为全局连接池并登记 users / categories 表
+func useRowsDB(t *testing.T) (*rowsDB, *sql.DB) {
+	f, db := newRowsDB(t)
+	old := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = old })
+	AllowDBTable("users", "id", "email")
+	AllowDBTable("categories", "id")
+	f.insert("users", map[string]any{"id": "1", "email": "alice@example.com"})
+	f.insert("users", map[string]any{"id": "2", "email": "bob@example.com"})
+	f.insert("categories", map[string]any{"id": "1"})
+	return f, db
+}
+
+// bindFields 解析 400 响应中的字段错误
+func bindFields(t *testing.T, body []byte) []FieldError {
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			Fields []FieldError `json:"fields"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp), string(body))
+	assert.Equal(t, int(ValidationFailed), resp.Code)
+	return resp.Data.Fields
+}
+
+func jsonBody(s string) (*ut.Body, ut.Header) {
+	return &ut.Body{Body: strings.NewReader(s), Len: len(s)}, ut.Header{Key: "Content-Type", Value: "application/json"}
+}
+
+func TestBind_UniqueCreateAndUpdateExcludesSelf(t *testing.T) {
+	useRowsDB(t)
+	type createUserReq struct {
+		Email string `json:"email" vd:"len($)>0" validate:"unique=users.email"`
+	}
+	type updateUserReq struct {
+		ID    int64  `path:"id"`
+		Email string `json:"email" validate:"unique=users.email,self=ID"`
+	}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/users", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var req createUserReq
+		return BindContext(ctx, c, &req)
+	}))
+	engine.PUT("/users/:id", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var req updateUserReq
+		return BindContext(ctx, c, &req)
+	}))
+	perform := func(method, path, body string) *ut.ResponseRecorder {
+		b, h := jsonBody(body)
+		return ut.PerformRequest(engine, method, path, b, h)
+	}
+
+	w := perform("POST", "/users", `{"email":"alice@example.com"}`)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	assert.Equal(t, []FieldError{{Field: "email", Rule: "unique", Message: "alice@example.com 已被使用"}}, bindFields(t, w.Body.Bytes()))
+	w = perform("POST", "/users", `{"email":"carol@example.com"}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	w = perform("POST", "/users", `{"email":""}`)
+	require.Equal(t, 400, w.Code)
+	fields := bindFields(t, w.Body.Bytes())
+	require.Len(t, fields, 1, "vd 规则与数据库规则共用字段错误列表")
+	assert.Equal(t, "vd", fields[0].Rule)
+
+	w = perform("PUT", "/users/1", `{"email":"alice@example.com"}`)
+	assert.Equal(t, 200, w.Code, "更新时排除当前记录: "+w.Body.String())
+	w = perform("PUT", "/users/1", `{"email":"bob@example.com"}`)
+	require.Equal(t, 400, w.Code)
+	assert.Equal(t, "unique", bindFields(t, w.Body.Bytes())[0].Rule)
+}
+
+func TestBind_ExistsWithAndWithoutTransaction(t *testing.T) {
+	f, db := useRowsDB(t)
+	type createPostReq struct {
+		CategoryID int64 `json:"categoryId" validate:"exists=categories.id"`
+	}
+	handler := WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		if c.Query("seed") != "" {
+			// 在请求事务中插入分类，随后回滚
+			if _, err := database.Conn(ctx).ExecContext(ctx, "INSERT INTO categories (id) VALUES (?)", 5); err != nil {
+				return err
+			}
+			defer c.Set("tx_error", errors.New("rollback"))
+		}
+		var req createPostReq
+		return BindContext(ctx, c, &req)
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/posts", handler)
+	engine.POST("/tx/posts", database.TxMiddleware(db, database.AbortCommitIfFinished), handler)
+	perform := func(path, body string) *ut.ResponseRecorder {
+		b, h := jsonBody(body)
+		return ut.PerformRequest(engine, "POST", path, b, h)
+	}
+
+	assert.Equal(t, 200, perform("/posts", `{"categoryId":1}`).Code)
+	w := perform("/posts", `{"categoryId":5}`)
+	require.Equal(t, 400, w.Code)
+	assert.Equal(t, []FieldError{{Field: "categoryId", Rule: "exists", Message: "5 不存在"}}, bindFields(t, w.Body.Bytes()))
+
+	assert.Equal(t, 200, perform("/tx/posts?seed=1", `{"categoryId":5}`).Code, "事务内未提交的数据可见")
+	assert.Equal(t, 400, perform("/tx/posts", `{"categoryId":5}`).Code, "回滚后不可见")
+	assert.Equal(t, 200, perform("/tx/posts", `{"categoryId":1}`).Code)
+	assert.Equal(t, 400, perform("/posts", `{"categoryId":5}`).Code)
+	assert.Len(t, f.tables["categories"], 1)
+}
+
+func TestBind_DBRulesBatchedAndCached(t *testing.T) {
+	f, _ := useRowsDB(t)
+	type req struct {
+		Email  string  `json:"email" validate:"unique=users.email"`
+		TagIDs []int64 `json:"tagIds" validate:"exists=categories.id"`
+		Extra  *struct {
+			CategoryID int64 `json:"categoryId" validate:"exists=categories.id"`
+		} `json:"extra"`
+	}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	var fields []FieldError
+	engine.POST("/batch", func(ctx context.Context, c *app.RequestContext) {
+		for range 2 {
+			var r req
+			var invalid *ValidationError
+			if err := BindContext(ctx, c, &r); errors.As(err, &invalid) {
+				fields = invalid.Fields
+			}
+		}
+	})
+	b, h := jsonBody(`{"email":"bob@example.com","tagIds":[1,9],"extra":{"categoryId":1}}`)
+	before := f.queryCount()
+	ut.PerformRequest(engine, "POST", "/batch", b, h)
+
+	assert.Equal(t, 1, f.queryCount()-before, "所有规则一次查询，同一请求内第二次绑定命中缓存")
+	assert.Equal(t, []FieldError{
+		{Field: "email", Rule: "unique", Message: "bob@example.com 已被使用"},
+		{Field: "tagIds", Rule: "exists", Message: "9 不存在"},
+	}, fields)
+}
+
+func TestValidateDBRules_WhitelistAndCustomRule(t *testing.T) {
+	f, db := useRowsDB(t)
+	ctx := context.Background()
+
+	var unlisted struct {
+		Name string `validate:"unique=users.name"`
+	}
+	unlisted.Name = "x"
+	_, err := ValidateDBRules(ctx, db, &unlisted)
+	assert.ErrorContains(t, err, "未登记的列")
+	var injected struct {
+		Email string `validate:"unique=users.email;DROP TABLE users"`
+	}
+	injected.Email = "x"
+	_, err = ValidateDBRules(ctx, db, &injected)
+	assert.Error(t, err)
+	assert.Zero(t, f.queryCount(), "未登记的标识符不会执行")
+
+	type tenantKey struct{}
+	f.insert("users", map[string]any{"id": "3", "tenant_id": "acme", "email": "dave@example.com"})
+	var got []any
+	RegisterDBRule("tenant_email", DBRuleSQL(`SELECT 0 AS k FROM "users" WHERE "email" = ? AND "id" <> ?`,
+		func(ctx context.Context, check DBCheck) []any {
+			got = append(got, ctx.Value(tenantKey{}), check.Value, check.Self)
+			return []any{check.Value, check.Self}
+		}))
+	var scoped struct {
+		ID    int64  `json:"id"`
+		Email string `json:"email" validate:"unique=tenant_email,self=ID"`
+	}
+	scoped.ID, scoped.Email = 4, "dave@example.com"
+	fields, err := ValidateDBRules(context.WithValue(ctx, tenantKey{}, "acme"), db, &scoped)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"acme", "dave@example.com", int64(4)}, got)
+	assert.Equal(t, []FieldError{{Field: "email", Rule: "unique", Message: "dave@example.com 已被使用"}}, fields)
+}
@@ -0,0 +1,221 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// 请求绑定模式
+const (
+	BindingStrict  = "strict"  // 拒绝未声明的 JSON 字段
+	BindingLenient = "lenient" // 忽略未声明的 JSON 字段（Hertz 默认行为）
+)
+
+// maxUnknownFields 一次最多报告的未知字段数（避免构造的请求体放大错误响应）
+const maxUnknownFields = 20
+
+// strictBindingKey 路由声明的绑定模式（bindingMode 写入）
+const strictBindingKey = "strict_binding"
+
+var (
+	strictBindingDefault atomic.Bool
+	jsonFieldCache       sync.Map // reflect.Type -> map[string]reflect.Type
+	jsonUnmarshalerType  = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType  = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// SetStrictBinding 设置未声明绑定模式的路由是否使用严格绑定（默认关闭）
+//
+// 严格绑定时，JSON 请求体中有请求结构体未声明的字段（包括嵌套对象与数组元素中的字段）时，
+// Bind 返回 *ValidationError（400，data.fields 逐个列出未知字段的路径，rule 为 unknown）。
+// 路由上的 Router.Strict / Router.Lenient 优先于全局默认
+//
+// 使用方式：
+//
+//	web.SetStrictBinding(true)
+//	r.Lenient().POST("/webhooks/github", web.WrapHandler(githubWebhook)) // 第三方载荷字段不固定
+func SetStrictBinding(enabled bool) {
+	strictBindingDefault.Store(enabled)
+}
+
+// bindingMode 记录路由声明的绑定模式（Router 注册时插入到处理链开头）
+func bindingMode(strict bool) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Set(strictBindingKey, strict)
+	}
+}
+
+// isStrictBinding 本次请求是否使用严格绑定
+func isStrictBinding(c *app.RequestContext) bool {
+	if v, ok := c.Get(strictBindingKey); ok {
+		return v.(bool)
+	}
+	return strictBindingDefault.Load()
+}
+
+// strictBinding 路由生效的绑定模式是否为严格绑定
+func (r RouteInfo) strictBinding() bool {
+	return r.Binding == BindingStrict || r.Binding == "" && strictBindingDefault.Load()
+}
+
+// unknownFieldErrors 严格绑定时检查 JSON 请求体中未声明的字段
+func unknownFieldErrors(c *app.RequestContext, obj any) []FieldError {
+	if !isStrictBinding(c) || !bytes.HasPrefix(c.Request.Header.ContentType(), []byte("application/json")) {
+		return nil
+	}
+	paths := unknownJSONFields(c.Request.Body(), reflect.TypeOf(obj))
+	if len(paths) == 0 {
+		return nil
+	}
+	msg, _ := AppOf(c).translate(requestLanguage(c), MsgUnknownField)
+	fields := make([]FieldError, len(paths))
+	for i, p := range paths {
+		fields[i] = FieldError{Field: p, Rule: "unknown", Message: msg}
+	}
+	return fields
+}
+
+// unknownJSONFields 按类型逐个 token 扫描请求体，返回未声明字段的路径（嵌套字段用 . 连接，数组元素为 [i]）
+//
+// 只扫描一遍，不构造值；encoding/json 的 DisallowUnknownFields 只报告第一个字段且不带路径。
+// 不是合法 JSON 时返回 nil，由绑定报告解析错误
+func unknownJSONFields(body []byte, t reflect.Type) []string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	s := &jsonScanner{dec: json.NewDecoder(bytes.NewReader(body))}
+	if err := s.value(t, ""); err != nil {
+		return nil
+	}
+	return s.unknown
+}
+
+type jsonScanner struct {
+	dec     *json.Decoder
+	unknown []string
+}
+
+// value 扫描一个值；t 为 nil 时只跳过（未知字段的值、any、自定义解码的类型）
+func (s *jsonScanner) value(t reflect.Type, path string) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Interface || customDecoded(t)) {
+		t = nil
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+		for s.dec.More() {
+			key, err := s.dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			var child reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				child = t.Elem()
+			case fields != nil:
+				ft, ok := fields[strings.ToLower(name)]
+				if !ok && len(s.unknown) < maxUnknownFields {
+					s.unknown = append(s.unknown, joinFieldPath(path, name))
+				}
+				child = ft
+			}
+			if err := s.value(child, joinFieldPath(path, name)); err != nil {
+				return err
+			}
+		}
+		_, err = s.dec.Token()
+		return err
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; s.dec.More(); i++ {
+			if err := s.value(elem, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		_, err = s.dec.Token()
+		return err
+	}
+	return nil
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// customDecoded 自定义解码的类型（结构由类型自己决定，不检查字段）
+func customDecoded(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType)
+}
+
+// jsonFields 结构体在 encoding/json 解码时接受的字段（小写名称 -> 类型，匹配不区分大小写）
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if v, ok := jsonFieldCache.Load(t); ok {
+		return v.(map[string]reflect.Type)
+	}
+	fields := map[string]reflect.Type{}
+	var walk func(t reflect.Type, visited map[reflect.Type]bool)
+	walk = func(t reflect.Type, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if sf.Anonymous && name == "" {
+				inner := sf.Type
+				if inner.Kind() == reflect.Pointer {
+					inner = inner.Elem()
+				}
+				if inner.Kind() == reflect.Struct {
+					walk(inner, visited)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if _, ok := fields[strings.ToLower(name)]; !ok {
+				fields[strings.ToLower(name)] = sf.Type
+			}
+		}
+	}
+	walk(t, map[reflect.Type]bool{})
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
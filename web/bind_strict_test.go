@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type strictAddress struct {
+	City string `json:"city"`
+}
+
+type strictItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type strictOrderReq struct {
+	ID       int64             `path:"id"`
+	Address  *strictAddress    `json:"address"`
+	Items    []strictItem      `json:"items"`
+	Labels   map[string]string `json:"labels"`
+	Metadata any               `json:"metadata"`
+	Raw      json.RawMessage   `json:"raw"`
+}
+
+func strictEngine(t *testing.T) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	handler := WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var req strictOrderReq
+		if err := BindContext(ctx, c, &req); err != nil {
+			return err
+		}
+		c.JSON(200, Success(req))
+		return nil
+	})
+	r := NewRouter(engine)
+	r.Strict().POST("/strict/:id", handler)
+	r.Lenient().POST("/lenient/:id", handler)
+	r.POST("/default/:id", handler)
+	return engine
+}
+
+func TestBind_StrictRejectsUnknownFields(t *testing.T) {
+	engine := strictEngine(t)
+	perform := func(path, body string) *ut.ResponseRecorder {
+		b, h := jsonBody(body)
+		return ut.PerformRequest(engine, "POST", path, b, h)
+	}
+	body := `{
+		"isAdmin": true,
+		"address": {"city": "Hangzhou", "zip": "310000"},
+		"items": [{"sku": "A", "Quantity": 1}, {"sku": "B", "discount": 0.5, "extra": {"x": 1}}],
+		"labels": {"anything": "goes"},
+		"metadata": {"free": {"form": [1, 2]}},
+		"raw": {"opaque": true}
+	}`
+
+	w := perform("/strict/1", body)
+	require.Equal(t, 400, w.Code, w.Body.String())
+	fields := bindFields(t, w.Body.Bytes())
+	var paths []string
+	for _, f := range fields {
+		paths = append(paths, f.Field)
+		assert.Equal(t, "unknown", f.Rule)
+		assert.Equal(t, "不支持的字段", f.Message)
+	}
+	assert.Equal(t, []string{"isAdmin", "address.zip", "items[1].discount", "items[1].extra"}, paths,
+		"顶层、嵌套对象与数组元素中的未知字段逐个列出，map、any 与自定义解码的字段不检查")
+
+	w = perform("/strict/1?lang=en-US", `{"isAdmin":true}`)
+	require.Equal(t, 400, w.Code)
+	assert.Equal(t, []FieldError{{Field: "isAdmin", Rule: "unknown", Message: "Unknown field"}}, bindFields(t, w.Body.Bytes()))
+
+	w = perform("/strict/1", `{"address":{"city":"Hangzhou"},"items":[{"sku":"A","quantity":2}]}`)
+	assert.Equal(t, 200, w.Code, w.Body.String())
+
+	// 非严格路由保持宽松：未知字段被忽略
+	for _, path := range []string{"/lenient/1", "/default/1"} {
+		w = perform(path, body)
+		assert.Equal(t, 200, w.Code, path+": "+w.Body.String())
+	}
+}
+
+func TestBind_StrictBindingDefault(t *testing.T) {
+	SetStrictBinding(true)
+	t.Cleanup(func() { SetStrictBinding(false) })
+	engine := strictEngine(t)
+	perform := func(path string) int {
+		b, h := jsonBody(`{"isAdmin":true}`)
+		return ut.PerformRequest(engine, "POST", path, b, h).Code
+	}
+
+	assert.Equal(t, 400, perform("/default/1"), "全局默认开启")
+	assert.Equal(t, 200, perform("/lenient/1"), "路由豁免优先")
+	assert.Equal(t, 400, perform("/strict/1"))
+}
+
+func TestExportRouteManifest_StrictRequest(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := NewRouter(engine)
+	example := Example{Request: strictOrderReq{}}
+	r.Strict().WithExamples(example).POST("/orders", noopHandler)
+	r.WithExamples(example).PUT("/orders", noopHandler)
+
+	routes, _ := ValidateRoutes(engine)
+	m := ExportRouteManifest(routes, "1.0.0")
+	strict, lenient := m.Routes[0].Request, m.Routes[1].Request
+	require.Equal(t, "POST", m.Routes[0].Method)
+
+	assert.True(t, strict.Closed)
+	assert.True(t, strict.Properties["address"].Closed)
+	assert.True(t, strict.Properties["items"].Items.Closed)
+	assert.False(t, strict.Properties["labels"].Closed, "map 仍接受任意键")
+	assert.False(t, lenient.Closed)
+	assert.Contains(t, strict.String(), `"additionalProperties":false`)
+	assert.NotContains(t, lenient.String(), `"additionalProperties":false`)
+
+	parsed, err := ParseRouteManifest(m.JSON())
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/CenJIl/base/common/governor"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript 令牌桶：按 Redis 服务器时间补充令牌，有令牌时取一个返回 0，否则返回需要等待的毫秒数
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = tokens + (now - ts) * rate / 1000
+  ts = now
+end
+tokens = math.min(burst, tokens)
+
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// GovernorStore 基于 Redis 的共享令牌桶（governor.Store 实现）
+//
+// 多个实例共用同一个桶，集群整体速率不超过配额。补充令牌使用 Redis 服务器时间，不受实例时钟偏差影响
+//
+// 使用方式：
+//
+//	governor.Init(config.Outbound, governor.WithStore(cache.NewGovernorStore(cache.Client)))
+type GovernorStore struct {
+	client redis.Scripter
+}
+
+// NewGovernorStore 创建共享令牌桶
+func NewGovernorStore(client redis.Scripter) *GovernorStore {
+	return &GovernorStore{client: client}
+}
+
+// Take 实现 governor.Store 接口（now 被忽略，使用 Redis 服务器时间）
+func (s *GovernorStore) Take(ctx context.Context, key string, limit governor.Limit, now time.Time) (time.Duration, error) {
+	ms, err := takeScript.Run(ctx, s.client, []string{key}, limit.Rate, limit.Burst).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
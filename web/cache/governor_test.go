@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common/governor"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGovernorStore_SharedAcrossReplicas(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过 Redis 集成测试")
+	}
+	config := governor.Config{
+		Prefix: "governor-test:" + uuid.NewString() + ":",
+		Limits: map[string]governor.Limit{"partnerX": {Rate: 1, Burst: 5}},
+	}
+	var replicas []*governor.Governor
+	for range 2 {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		t.Cleanup(func() { client.Close() })
+		require.NoError(t, client.Ping(context.Background()).Err())
+		replicas = append(replicas, governor.New(config, governor.WithStore(NewGovernorStore(client))))
+	}
+
+	allowed := 0
+	for range 10 {
+		for _, g := range replicas {
+			if g.Allow("partnerX") {
+				allowed++
+			}
+		}
+	}
+	assert.Equal(t, 5, allowed, "两个实例合计不超过桶容量")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, replicas[0].Wait(ctx, "partnerX"))
+	assert.Greater(t, time.Since(start), 500*time.Millisecond, "桶已空时等待补充")
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationStore 支持失效序号的存储（RedisStore 实现）
+//
+// 失效时递增键的失效序号并删除缓存值；GetOrLoad 加载前读取序号，写入时序号已变化说明
+// 加载期间发生了失效，放弃写入，避免把失效前读到的旧数据写回缓存
+type InvalidationStore interface {
+	Store
+	// Invalidate 递增 key 的失效序号（guard 后过期）并删除缓存值及其元数据
+	Invalidate(ctx context.Context, key string, guard time.Duration) error
+	// Seq 读取 key 当前的失效序号（不存在时为 0）
+	Seq(ctx context.Context, key string) (int64, error)
+	// SetIfSeq 失效序号仍为 seq 时写入，返回是否写入
+	SetIfSeq(ctx context.Context, key string, value []byte, ttl time.Duration, seq int64) (bool, error)
+}
+
+// invalidationKey 失效序号与缓存值相邻存放
+func invalidationKey(key string) string { return "cache:inv:" + key }
+
+var invalidateScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[1])
+redis.call('DEL', KEYS[1], KEYS[3])
+return seq`)
+
+var setIfSeqScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[2]) or '0'
+if cur ~= ARGV[3] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1`)
+
+// Invalidate 实现 InvalidationStore 接口
+func (RedisStore) Invalidate(ctx context.Context, key string, guard time.Duration) error {
+	return invalidateScript.Run(ctx, Client, []string{key, invalidationKey(key), metaKey(key)}, max(guard.Milliseconds(), 1)).Err()
+}
+
+// Seq 实现 InvalidationStore 接口
+func (RedisStore) Seq(ctx context.Context, key string) (int64, error) {
+	seq, err := Client.Get(ctx, invalidationKey(key)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// SetIfSeq 实现 InvalidationStore 接口
+func (RedisStore) SetIfSeq(ctx context.Context, key string, value []byte, ttl time.Duration, seq int64) (bool, error) {
+	n, err := setIfSeqScript.Run(ctx, Client, []string{key, invalidationKey(key)},
+		value, max(ttl.Milliseconds(), 1), strconv.FormatInt(seq, 10)).Int()
+	return n == 1, err
+}
+
+// InvalidateAfterCommit 在 ctx 所属事务提交成功后删除缓存键（使用默认加载器）
+//
+// 事务由 database.DBMiddleware、database.WithTx 或消息消费者的 WithMessageTx 开启；
+// 回滚时不删除，ctx 不在事务中时立即删除。删除的同时递增键的失效序号，
+// 提交前已开始的 GetOrLoad 不会再把读到的旧数据写回缓存（见 InvalidationStore）。
+// Redis 降级或删除失败时记下待失效的键并在后台重试，重试成功前本实例读取这些键时绕过缓存
+//
+// 使用方式：
+//
+//	if err := q.UpdateUser(ctx, params); err != nil {
+//	    return err
+//	}
+//	cache.InvalidateAfterCommit(ctx, "user:"+id, "user:profile:"+id)
+func InvalidateAfterCommit(ctx context.Context, keys ...string) {
+	defaultLoaderOnce.Do(func() { defaultLoader = NewLoader(nil, nil) })
+	defaultLoader.InvalidateAfterCommit(ctx, keys...)
+}
+
+// InvalidateAfterCommit 使用该加载器的 InvalidateAfterCommit
+func (l *Loader) InvalidateAfterCommit(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	database.AfterCommit(ctx, func() {
+		// 请求可能已经结束，失效不跟随请求取消
+		ictx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		l.Invalidate(ictx, keys...)
+	})
+}
+
+// Invalidate 立即删除缓存键并递增失效序号（启用 DoubleDeleteLag 时延迟后再删一次）
+//
+// 失败的键记为待失效并在后台重试，返回第一个错误
+func (l *Loader) Invalidate(ctx context.Context, keys ...string) error {
+	var firstErr error
+	for _, key := range keys {
+		if err := l.invalidate(ctx, key); err != nil {
+			logger.Warnf("[Cache] 删除缓存 %s 失败，稍后重试: %v", key, err)
+			l.markPending(key)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if l.DoubleDeleteLag > 0 {
+		l.after(l.DoubleDeleteLag, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for _, key := range keys {
+				if err := l.invalidate(ctx, key); err != nil {
+					l.markPending(key)
+				}
+			}
+		})
+	}
+	return firstErr
+}
+
+// invalidate 删除一个键（Redis 降级时直接失败，交给后台重试）
+func (l *Loader) invalidate(ctx context.Context, key string) error {
+	if IsDegraded(DependencyRedis) {
+		return errors.New("Redis 处于降级状态")
+	}
+	var err error
+	if s, ok := l.store.(InvalidationStore); ok {
+		err = s.Invalidate(ctx, key, l.InvalidationGuard)
+	} else {
+		err = fmt.Errorf("存储 %T 不支持失效", l.store)
+	}
+	if err != nil {
+		metrics.GetCounter("cache_invalidation_failed_total").Inc()
+		return err
+	}
+	metrics.GetCounter("cache_invalidations_total").Inc()
+	return nil
+}
+
+// markPending 记录待失效的键并启动后台重试
+func (l *Loader) markPending(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending[key] = true
+	metrics.GetGauge("cache_invalidation_pending").Set(float64(len(l.pending)))
+	if !l.retrying {
+		l.retrying = true
+		l.after(l.InvalidationRetry, l.retryPending)
+	}
+}
+
+// invalidationPending key 是否有尚未执行的失效
+func (l *Loader) invalidationPending(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending[key]
+}
+
+// retryPending 重试待失效的键，仍有失败时继续定时重试
+func (l *Loader) retryPending() {
+	l.mu.Lock()
+	keys := make([]string, 0, len(l.pending))
+	for key := range l.pending {
+		keys = append(keys, key)
+	}
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var done []string
+	for _, key := range keys {
+		if l.invalidate(ctx, key) == nil {
+			done = append(done, key)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range done {
+		delete(l.pending, key)
+	}
+	metrics.GetGauge("cache_invalidation_pending").Set(float64(len(l.pending)))
+	if len(l.pending) == 0 {
+		l.retrying = false
+		return
+	}
+	l.after(l.InvalidationRetry, l.retryPending)
+}
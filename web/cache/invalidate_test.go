@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seqStore 内存中的 InvalidationStore（每个操作原子执行，与 Redis 脚本语义一致）
+type seqStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	seqs    map[string]int64
+}
+
+func newSeqStore() *seqStore {
+	return &seqStore{entries: make(map[string][]byte), seqs: make(map[string]int64)}
+}
+
+func (s *seqStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.entries[key]
+	return v, ok, nil
+}
+
+func (s *seqStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = value
+	return nil
+}
+
+func (s *seqStore) Del(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *seqStore) Invalidate(ctx context.Context, key string, guard time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqs[key]++
+	delete(s.entries, key)
+	delete(s.entries, metaKey(key))
+	return nil
+}
+
+func (s *seqStore) Seq(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seqs[key], nil
+}
+
+func (s *seqStore) SetIfSeq(ctx context.Context, key string, value []byte, ttl time.Duration, seq int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seqs[key] != seq {
+		return false, nil
+	}
+	s.entries[key] = value
+	return true, nil
+}
+
+// plainStore 只暴露 Store 接口：之前「提交后直接删除」的做法
+type plainStore struct{ *seqStore }
+
+// fakeRow 模拟数据库中的一行
+type fakeRow struct {
+	mu    sync.Mutex
+	value int
+}
+
+func (r *fakeRow) read() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value
+}
+
+func (r *fakeRow) write(v int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.value = v
+}
+
+// raceLoad 读取者在提交前读到旧值，等到写入者提交并失效之后才写缓存
+func raceLoad(t *testing.T, l *Loader, row *fakeRow, commit func()) {
+	t.Helper()
+	loaded, resume := make(chan struct{}), make(chan struct{})
+	done := make(chan int)
+	go func() {
+		v, err := LoadWith(context.Background(), l, "user:1", time.Minute, func(ctx context.Context) (int, error) {
+			v := row.read()
+			close(loaded)
+			<-resume
+			return v, nil
+		})
+		assert.NoError(t, err)
+		done <- v
+	}()
+	<-loaded
+	commit()
+	close(resume)
+	assert.Equal(t, 1, <-done, "读取者返回的是提交前读到的值")
+}
+
+func readUser(t *testing.T, l *Loader, row *fakeRow) int {
+	t.Helper()
+	v, err := LoadWith(context.Background(), l, "user:1", time.Minute, func(ctx context.Context) (int, error) {
+		return row.read(), nil
+	})
+	require.NoError(t, err)
+	return v
+}
+
+func TestInvalidate_StaleRepopulationRace(t *testing.T) {
+	t.Run("提交后直接删除：旧值被写回", func(t *testing.T) {
+		store := newSeqStore()
+		l := NewLoader(plainStore{store}, nil)
+		row := &fakeRow{value: 1}
+		raceLoad(t, l, row, func() {
+			row.write(2)
+			store.Del("user:1")
+		})
+		assert.Equal(t, 1, readUser(t, l, row), "缓存中留下了旧值，直到 ttl 过期")
+	})
+
+	t.Run("InvalidateAfterCommit：旧值不会写回", func(t *testing.T) {
+		l := NewLoader(newSeqStore(), nil)
+		row := &fakeRow{value: 1}
+		raceLoad(t, l, row, func() {
+			ctx, hooks := database.WithCommitHooks(context.Background())
+			row.write(2)
+			l.InvalidateAfterCommit(ctx, "user:1")
+			hooks.Committed()
+		})
+		assert.Equal(t, 2, readUser(t, l, row))
+	})
+}
+
+func TestInvalidate_ConcurrentWritersAndReaders(t *testing.T) {
+	store := newSeqStore()
+	l := NewLoader(store, nil)
+	row := &fakeRow{}
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for range 200 {
+				ctx, hooks := database.WithCommitHooks(context.Background())
+				row.write(r.Int())
+				l.InvalidateAfterCommit(ctx, "user:1")
+				time.Sleep(time.Duration(r.Intn(50)) * time.Microsecond)
+				hooks.Committed()
+			}
+		}()
+	}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 400 {
+				_, err := LoadWith(context.Background(), l, "user:1", time.Minute, func(ctx context.Context) (int, error) {
+					v := row.read()
+					time.Sleep(20 * time.Microsecond) // 拉长读取与写缓存之间的窗口
+					return v, nil
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if cached, ok, _ := store.Get(context.Background(), "user:1"); ok {
+		assert.Equal(t, strconv.Itoa(row.read()), string(cached), "缓存中只可能是最后提交的值")
+	}
+	assert.Equal(t, row.read(), readUser(t, l, row))
+}
+
+func TestInvalidateAfterCommit_Rollback(t *testing.T) {
+	store := newSeqStore()
+	l := NewLoader(store, nil)
+	require.NoError(t, store.Set(context.Background(), "user:1", []byte("1"), time.Minute))
+
+	ctx, _ := database.WithCommitHooks(context.Background())
+	l.InvalidateAfterCommit(ctx, "user:1")
+	// 事务回滚：不调用 Committed
+	_, ok, _ := store.Get(context.Background(), "user:1")
+	assert.True(t, ok)
+
+	l.InvalidateAfterCommit(context.Background(), "user:1")
+	_, ok, _ = store.Get(context.Background(), "user:1")
+	assert.False(t, ok, "不在事务中时立即删除")
+}
+
+func TestInvalidate_DoubleDelete(t *testing.T) {
+	store := newSeqStore()
+	l := NewLoader(store, nil)
+	l.DoubleDeleteLag = 20 * time.Millisecond
+
+	require.NoError(t, l.Invalidate(context.Background(), "user:1"))
+	// 绕过 GetOrLoad 的写入（如其他服务直接写缓存）在第一次删除后写回旧值
+	require.NoError(t, store.Set(context.Background(), "user:1", []byte("1"), time.Minute))
+	require.Eventually(t, func() bool {
+		_, ok, _ := store.Get(context.Background(), "user:1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestInvalidate_RedisDegraded(t *testing.T) {
+	store := newSeqStore()
+	l := NewLoader(store, nil)
+	l.InvalidationRetry = 10 * time.Millisecond
+	row := &fakeRow{value: 1}
+	assert.Equal(t, 1, readUser(t, l, row))
+
+	SetDegraded(DependencyRedis, true)
+	t.Cleanup(func() { SetDegraded(DependencyRedis, false) })
+	row.write(2)
+	assert.Error(t, l.Invalidate(context.Background(), "user:1"))
+	assert.Equal(t, 2, readUser(t, l, row), "失效未执行前绕过缓存")
+	cached, _, _ := store.Get(context.Background(), "user:1")
+	assert.Equal(t, "1", string(cached), "降级期间不写缓存")
+
+	SetDegraded(DependencyRedis, false)
+	require.Eventually(t, func() bool { return !l.invalidationPending("user:1") }, time.Second, 5*time.Millisecond)
+	_, ok, _ := store.Get(context.Background(), "user:1")
+	assert.False(t, ok, "恢复后补上失效")
+	assert.Equal(t, 2, readUser(t, l, row))
+}
+
+// TestInvalidate_Redis 需要真实 Redis：TEST_REDIS_ADDR
+func TestInvalidate_Redis(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过 Redis 集成测试")
+	}
+	old := Client
+	Client = redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		Client.Close()
+		Client = old
+	})
+	ctx := context.Background()
+	key := "invalidate-test:" + uuid.NewString()
+	t.Cleanup(func() { Client.Del(ctx, key, invalidationKey(key)) })
+
+	store := RedisStore{}
+	seq, err := store.Seq(ctx, key)
+	require.NoError(t, err)
+	assert.Zero(t, seq)
+	ok, err := store.SetIfSeq(ctx, key, []byte("1"), time.Minute, seq)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, store.Invalidate(ctx, key, time.Minute))
+	_, found, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, found)
+	ok, err = store.SetIfSeq(ctx, key, []byte("1"), time.Minute, seq)
+	require.NoError(t, err)
+	assert.False(t, ok, "失效之前开始的加载不能写入")
+
+	seq, err = store.Seq(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), seq)
+	pttl, err := Client.PTTL(ctx, invalidationKey(key)).Result()
+	require.NoError(t, err)
+	assert.Greater(t, pttl, time.Duration(0), "失效序号短期保留")
+}
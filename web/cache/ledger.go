@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/redis/go-redis/v9"
+)
+
+// ledgerHook 把 Redis 命令的次数与耗时记入请求账本（管道按命令数计）
+type ledgerHook struct{}
+
+func (ledgerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (ledgerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		l := ledger.From(ctx)
+		if !l.Active() {
+			return next(ctx, cmd)
+		}
+		start := time.Now()
+		err := next(ctx, cmd)
+		l.Add(ledger.Redis, 1, time.Since(start))
+		return err
+	}
+}
+
+func (ledgerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		l := ledger.From(ctx)
+		if !l.Active() {
+			return next(ctx, cmds)
+		}
+		start := time.Now()
+		err := next(ctx, cmds)
+		l.Add(ledger.Redis, int64(len(cmds)), time.Since(start))
+		return err
+	}
+}
+
+var _ redis.Hook = ledgerHook{}
@@ -0,0 +1,419 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultBeta XFetch 默认系数（1.0 为论文推荐值，越大越早刷新）
+const DefaultBeta = 1.0
+
+// metaVersion 元数据格式版本
+//
+// 缓存值本身始终是普通 JSON（旧版本代码直接 Get + json.Unmarshal 也能读），
+// 加载耗时与过期时间写在相邻的 metaKey 中；元数据缺失、版本未知或与缓存值对不上时按普通值处理
+const metaVersion = 1
+
+// metaKey 元数据与缓存值相邻存放
+func metaKey(key string) string { return key + ":meta" }
+
+// entryMeta v1：计算耗时 + 逻辑过期时间
+type entryMeta struct {
+	Version int    `json:"v"`
+	Sum     uint32 `json:"s"`           // 缓存值的 CRC32（缓存值被不写元数据的调用覆盖后不再匹配）
+	CostMs  int64  `json:"c"`           // 加载耗时（毫秒）
+	Expiry  int64  `json:"e"`           // 逻辑过期时间（Unix 毫秒）
+	Hard    int64  `json:"h,omitempty"` // 硬过期时间（Unix 毫秒，仅 WithStale 写入）
+}
+
+// Store 缓存存储（默认使用 Redis）
+type Store interface {
+	// Get 读取值，不存在时 ok = false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set 写入值，ttl 后过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// RedisStore 使用全局 Redis 客户端的存储
+type RedisStore struct{}
+
+// Get 实现 Store 接口
+func (RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := Client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Set 实现 Store 接口
+func (RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return Client.Set(ctx, key, value, ttl).Err()
+}
+
+// LoadOption GetOrLoad 选项
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	early   bool
+	beta    float64
+	hardTTL time.Duration // WithStale：最长保留时间
+	budget  time.Duration // WithStale：有旧值时回源的时间预算
+}
+
+// withMeta 是否写入带元数据的缓存值
+func (o loadOptions) withMeta() bool { return o.early || o.hardTTL > 0 }
+
+// storeTTL 缓存值在存储中的保留时间
+func (o loadOptions) storeTTL(ttl time.Duration) time.Duration { return max(ttl, o.hardTTL) }
+
+// WithEarlyRefresh 启用 XFetch 概率提前刷新（beta <= 0 时使用 DefaultBeta）
+//
+// 每次命中时以与「加载耗时 × beta」成正比的概率在后台提前重新加载，同时继续返回当前值，
+// 让多个实例的重新加载在时间上错开，避免过期瞬间同时回源
+func WithEarlyRefresh(beta float64) LoadOption {
+	return func(o *loadOptions) {
+		o.early = true
+		o.beta = beta
+		if o.beta <= 0 {
+			o.beta = DefaultBeta
+		}
+	}
+}
+
+// Loader 带单飞和提前刷新的缓存加载器
+type Loader struct {
+	store Store
+	clock common.Clock
+
+	// RefreshInterval 同一个键两次后台刷新的最小间隔（默认 1 秒）
+	RefreshInterval time.Duration
+	// DoubleDeleteLag 失效后再删除一次的延迟（0 为不启用），覆盖绕过 GetOrLoad 写回的旧值
+	DoubleDeleteLag time.Duration
+	// InvalidationGuard 失效序号的保留时间（默认 1 分钟），应长于最慢的一次加载
+	InvalidationGuard time.Duration
+	// InvalidationRetry 失效失败（Redis 降级等）后的重试间隔（默认 1 秒）
+	InvalidationRetry time.Duration
+
+	mu          sync.Mutex
+	rand        func() float64
+	calls       map[string]*loadCall // 前台加载（单飞）
+	refreshing  map[string]bool      // 进行中的后台刷新（单飞）
+	lastRefresh map[string]time.Time // 最近一次后台刷新（限频）
+	sem         chan struct{}        // 后台刷新并发上限
+	async       func(func())         // 后台执行方式（测试中可改为同步）
+	after       func(time.Duration, func())
+	pending     map[string]bool // 待失效的键（重试成功前本实例绕过缓存）
+	retrying    bool
+}
+
+// errLoadPanicked 单飞加载 panic 时等待者收到的错误
+var errLoadPanicked = errors.New("缓存加载 panic")
+
+// loadCall 进行中的加载
+type loadCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// NewLoader 创建加载器（store 为 nil 时使用 Redis；clock 为 nil 时使用系统时钟）
+func NewLoader(store Store, clock common.Clock) *Loader {
+	if store == nil {
+		store = RedisStore{}
+	}
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &Loader{
+		store:             store,
+		clock:             clock,
+		RefreshInterval:   time.Second,
+		InvalidationGuard: time.Minute,
+		InvalidationRetry: time.Second,
+		rand:              r.Float64,
+		calls:             make(map[string]*loadCall),
+		refreshing:        make(map[string]bool),
+		lastRefresh:       make(map[string]time.Time),
+		sem:               make(chan struct{}, 8),
+		async:             func(f func()) { go f() },
+		after:             func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+		pending:           make(map[string]bool),
+	}
+}
+
+var (
+	defaultLoader     *Loader
+	defaultLoaderOnce sync.Once
+)
+
+// GetOrLoad 读取缓存，未命中时调用 load 加载并写入（同一实例内同一个键只加载一次）
+//
+// ttl 是硬过期时间：任何情况下都不会返回超过 ttl 的值。
+// 传入 WithEarlyRefresh 后启用概率提前刷新：缓存值仍是普通 JSON，加载耗时与过期时间写在
+// 相邻的 key+":meta" 中（没有元数据的旧数据按普通值读取）。
+// 传入 WithStale 后 ttl 只是新鲜期，允许旧值的请求在回源失败时拿到 hardTTL 内的旧值（见 WithStale）。
+//
+// 使用方式：
+//
+//	report, err := cache.GetOrLoad(ctx, "report:daily", 10*time.Minute, func(ctx context.Context) (Report, error) {
+//	    return buildDailyReport(ctx)   // 昂贵的查询
+//	}, cache.WithEarlyRefresh(0))
+func GetOrLoad[T any](ctx context.Context, key string, ttl time.Duration, load func(ctx context.Context) (T, error), opts ...LoadOption) (T, error) {
+	defaultLoaderOnce.Do(func() { defaultLoader = NewLoader(nil, nil) })
+	return LoadWith(ctx, defaultLoader, key, ttl, load, opts...)
+}
+
+// LoadWith 使用指定加载器的 GetOrLoad
+func LoadWith[T any](ctx context.Context, l *Loader, key string, ttl time.Duration, load func(ctx context.Context) (T, error), opts ...LoadOption) (T, error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var zero T
+
+	raw := func(ctx context.Context) ([]byte, error) {
+		v, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+
+	if o.hardTTL > 0 && IsDegraded(DependencyRedis) {
+		// Redis 降级：不读写缓存，直接回源
+		ledger.From(ctx).Add(ledger.CacheMiss, 1, 0)
+		v, err := load(ctx)
+		if err != nil {
+			return zero, err
+		}
+		return v, nil
+	}
+	if l.invalidationPending(key) {
+		// 失效尚未执行：缓存中可能是旧值，直接回源且不写入
+		ledger.From(ctx).Add(ledger.CacheMiss, 1, 0)
+		return load(ctx)
+	}
+
+	data, ok, err := l.store.Get(ctx, key)
+	if err != nil {
+		return zero, fmt.Errorf("读取缓存 %s 失败: %w", key, err)
+	}
+	var stale T
+	hasStale := false
+	if ok {
+		var out T
+		if err := json.Unmarshal(data, &out); err == nil {
+			var meta *entryMeta
+			if o.withMeta() {
+				if meta, err = l.readMeta(ctx, key, data); err != nil {
+					return zero, err
+				}
+			}
+			now := l.clock.Now().UnixMilli()
+			if o.hardTTL == 0 || meta == nil || now < meta.Expiry {
+				if o.early && meta != nil && l.shouldRefresh(meta, o.beta) {
+					l.refreshInBackground(key, ttl, o, raw)
+				}
+				ledger.From(ctx).Add(ledger.CacheHit, 1, 0)
+				return out, nil
+			}
+			// 超过新鲜期：允许旧值的请求在 hardTTL 内用作兜底
+			if staleAllowed(ctx) && now < meta.Hard {
+				stale, hasStale = out, true
+			}
+		}
+	}
+	ledger.From(ctx).Add(ledger.CacheMiss, 1, 0)
+
+	if hasStale && IsDegraded(DependencyDB) {
+		markStale(ctx, StaleReasonDegraded)
+		return stale, nil
+	}
+	var payload []byte
+	if hasStale {
+		payload, err = l.loadWithBudget(ctx, key, ttl, o, raw)
+		if err != nil {
+			if errors.Is(err, errStaleBudget) {
+				// 加载仍在后台进行，完成后写入缓存
+				markStale(ctx, StaleReasonTimeout)
+			} else {
+				markStale(ctx, StaleReasonError)
+				l.refreshInBackground(key, ttl, o, raw)
+			}
+			return stale, nil
+		}
+	} else {
+		payload, err = l.loadOnce(ctx, key, ttl, o, raw)
+	}
+	if err != nil {
+		return zero, err
+	}
+	var out T
+	if err := json.Unmarshal(payload, &out); err != nil {
+		return zero, fmt.Errorf("解析缓存 %s 失败: %w", key, err)
+	}
+	return out, nil
+}
+
+// readMeta 读取 payload 对应的元数据（缺失、版本未知或与 payload 不匹配时为 nil）
+func (l *Loader) readMeta(ctx context.Context, key string, payload []byte) (*entryMeta, error) {
+	data, ok, err := l.store.Get(ctx, metaKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存 %s 的元数据失败: %w", key, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	var meta entryMeta
+	if json.Unmarshal(data, &meta) != nil || meta.Version != metaVersion || meta.Sum != crc32.ChecksumIEEE(payload) {
+		return nil, nil
+	}
+	return &meta, nil
+}
+
+// encodeMeta 生成 payload 的元数据
+func encodeMeta(payload []byte, cost time.Duration, expiry, hard time.Time) []byte {
+	meta := entryMeta{Version: metaVersion, Sum: crc32.ChecksumIEEE(payload), CostMs: cost.Milliseconds(), Expiry: expiry.UnixMilli()}
+	if !hard.IsZero() {
+		meta.Hard = hard.UnixMilli()
+	}
+	out, _ := json.Marshal(meta)
+	return out
+}
+
+// shouldRefresh XFetch 判定：now - cost × beta × ln(rand) >= expiry
+func (l *Loader) shouldRefresh(meta *entryMeta, beta float64) bool {
+	l.mu.Lock()
+	r := l.rand()
+	l.mu.Unlock()
+	if r <= 0 {
+		r = math.SmallestNonzeroFloat64
+	}
+	now := float64(l.clock.Now().UnixMilli())
+	return now-float64(meta.CostMs)*beta*math.Log(r) >= float64(meta.Expiry)
+}
+
+// loadOnce 单飞加载并写入缓存
+func (l *Loader) loadOnce(ctx context.Context, key string, ttl time.Duration, o loadOptions, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	l.mu.Lock()
+	if call, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := &loadCall{}
+	call.wg.Add(1)
+	l.calls[key] = call
+	l.mu.Unlock()
+
+	// load panic 时同样要放行等待者并移除记录，否则该键之后的加载全部卡住
+	defer func() {
+		l.mu.Lock()
+		delete(l.calls, key)
+		l.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.err = errLoadPanicked
+	call.data, call.err = l.loadAndStore(ctx, key, ttl, o, load)
+	return call.data, call.err
+}
+
+// loadAndStore 加载并写入缓存
+//
+// 存储支持失效序号时先读取序号，写入时序号已变化（加载期间发生了失效）则放弃写入
+func (l *Loader) loadAndStore(ctx context.Context, key string, ttl time.Duration, o loadOptions, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	guard, guarded := l.store.(InvalidationStore)
+	var seq int64
+	if guarded {
+		var err error
+		if seq, err = guard.Seq(ctx, key); err != nil {
+			return nil, fmt.Errorf("读取缓存 %s 的失效序号失败: %w", key, err)
+		}
+	}
+	start := l.clock.Now()
+	payload, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if guarded {
+		ok, err := guard.SetIfSeq(ctx, key, payload, o.storeTTL(ttl), seq)
+		if err != nil {
+			return nil, fmt.Errorf("写入缓存 %s 失败: %w", key, err)
+		}
+		if !ok {
+			metrics.GetCounter("cache_stale_write_skipped_total").Inc()
+			return payload, nil
+		}
+	} else if err := l.store.Set(ctx, key, payload, o.storeTTL(ttl)); err != nil {
+		return nil, fmt.Errorf("写入缓存 %s 失败: %w", key, err)
+	}
+	if o.withMeta() {
+		// 元数据在缓存值之后写入：两次写入之间的读取因校验和不匹配按普通值处理
+		now := l.clock.Now()
+		var hard time.Time
+		if o.hardTTL > 0 {
+			hard = now.Add(o.storeTTL(ttl))
+		}
+		meta := encodeMeta(payload, now.Sub(start), now.Add(ttl), hard)
+		if err := l.store.Set(ctx, metaKey(key), meta, o.storeTTL(ttl)); err != nil {
+			return nil, fmt.Errorf("写入缓存 %s 的元数据失败: %w", key, err)
+		}
+	}
+	return payload, nil
+}
+
+// refreshInBackground 后台提前刷新（同一个键单飞，且受最小间隔和并发上限限制）
+func (l *Loader) refreshInBackground(key string, ttl time.Duration, o loadOptions, load func(ctx context.Context) ([]byte, error)) {
+	now := l.clock.Now()
+	l.mu.Lock()
+	if l.refreshing[key] || now.Sub(l.lastRefresh[key]) < l.RefreshInterval {
+		l.mu.Unlock()
+		return
+	}
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		l.mu.Unlock()
+		return
+	}
+	l.refreshing[key] = true
+	if len(l.lastRefresh) > 10000 {
+		for k, t := range l.lastRefresh {
+			if now.Sub(t) >= l.RefreshInterval {
+				delete(l.lastRefresh, k)
+			}
+		}
+	}
+	l.lastRefresh[key] = now
+	l.mu.Unlock()
+
+	l.async(func() {
+		defer func() {
+			<-l.sem
+			l.mu.Lock()
+			delete(l.refreshing, key)
+			l.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), ttl)
+		defer cancel()
+		// 刷新失败不影响当前值，过期后由前台加载重试
+		_, _ = l.loadAndStore(ctx, key, ttl, o, load)
+	})
+}
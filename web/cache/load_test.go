@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simStore 共享存储：写入在 delay 之后才可见，模拟加载耗时期间其他实例仍看到旧状态
+type simStore struct {
+	mu      sync.Mutex
+	clock   common.Clock
+	delay   time.Duration
+	entries map[string]simEntry
+	pending []simWrite
+}
+
+type simEntry struct {
+	value  []byte
+	expire time.Time
+}
+
+type simWrite struct {
+	key     string
+	entry   simEntry
+	visible time.Time
+}
+
+func newSimStore(clock common.Clock, delay time.Duration) *simStore {
+	return &simStore{clock: clock, delay: delay, entries: make(map[string]simEntry)}
+}
+
+func (s *simStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	kept := s.pending[:0]
+	for _, w := range s.pending {
+		if !w.visible.After(now) {
+			s.entries[w.key] = w.entry
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	s.pending = kept
+	e, ok := s.entries[key]
+	if !ok || !e.expire.After(now) {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (s *simStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visible := s.clock.Now().Add(s.delay)
+	s.pending = append(s.pending, simWrite{key: key, entry: simEntry{value: value, expire: visible.Add(ttl)}, visible: visible})
+	return nil
+}
+
+// simulate 多个实例每秒读取同一个键，返回每次回源的时间（秒）
+func simulate(t *testing.T, replicas int, duration, ttl, cost time.Duration, opts ...LoadOption) []int {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	global := common.NewFakeClock(start)
+	store := newSimStore(global, cost)
+
+	var loads []int
+	loaders := make([]*Loader, replicas)
+	clocks := make([]*common.FakeClock, replicas)
+	for i := range loaders {
+		clocks[i] = common.NewFakeClock(start)
+		loaders[i] = NewLoader(store, clocks[i])
+		loaders[i].rand = rand.New(rand.NewSource(int64(i + 1))).Float64
+		loaders[i].async = func(f func()) { f() }
+	}
+
+	for sec := 0; sec < int(duration/time.Second); sec++ {
+		now := start.Add(time.Duration(sec) * time.Second)
+		global.Set(now)
+		for i, l := range loaders {
+			clocks[i].Set(now)
+			clock := clocks[i]
+			_, err := LoadWith(context.Background(), l, "report", ttl, func(ctx context.Context) (int, error) {
+				loads = append(loads, sec)
+				clock.Advance(cost) // 加载耗时
+				return sec, nil
+			}, opts...)
+			require.NoError(t, err)
+		}
+	}
+	return loads
+}
+
+// maxBurst 任意 window 秒内的最大回源次数
+func maxBurst(loads []int, window int) int {
+	best := 0
+	for i := range loads {
+		n := 0
+		for j := i; j < len(loads) && loads[j]-loads[i] < window; j++ {
+			n++
+		}
+		if n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+func TestGetOrLoad_EarlyRefreshDesynchronizesReplicas(t *testing.T) {
+	const replicas = 12
+	ttl, cost := 10*time.Minute, 2*time.Second
+	duration := time.Hour
+
+	baseline := simulate(t, replicas, duration, ttl, cost)
+	xfetch := simulate(t, replicas, duration, ttl, cost, WithEarlyRefresh(0))
+
+	// 启动时所有实例都会回源，跳过第一个窗口只看稳态
+	steady := func(loads []int) []int {
+		var out []int
+		for _, s := range loads {
+			if s >= 5 {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	baselineBurst := maxBurst(steady(baseline), int(cost/time.Second))
+	xfetchBurst := maxBurst(steady(xfetch), int(cost/time.Second))
+	t.Logf("baseline: %d loads, max burst %d; xfetch: %d loads, max burst %d",
+		len(steady(baseline)), baselineBurst, len(steady(xfetch)), xfetchBurst)
+
+	// 当前行为：过期瞬间所有实例同时回源
+	assert.GreaterOrEqual(t, baselineBurst, replicas)
+	// 提前刷新：回源分散，且不会比当前行为更频繁
+	assert.LessOrEqual(t, xfetchBurst, replicas/3)
+	assert.Less(t, len(steady(xfetch)), len(steady(baseline)))
+	assert.NotEmpty(t, steady(xfetch), "entries must still be regenerated before the hard TTL")
+}
+
+func TestGetOrLoad_MetaCompatibility(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := newSimStore(clock, 0)
+	l := NewLoader(store, clock)
+	ctx := context.Background()
+	loads := 0
+	load := func(ctx context.Context) (map[string]int, error) {
+		loads++
+		return map[string]int{"n": loads}, nil
+	}
+
+	// 没有元数据的旧数据按普通值读取
+	require.NoError(t, store.Set(ctx, "legacy", []byte(`{"n":42}`), time.Minute))
+	v, err := LoadWith(ctx, l, "legacy", time.Minute, load, WithEarlyRefresh(0))
+	require.NoError(t, err)
+	assert.Equal(t, 42, v["n"])
+	assert.Equal(t, 0, loads)
+
+	// 启用提前刷新时缓存值仍是普通 JSON，旧版本代码直接 json.Unmarshal 也能读
+	_, err = LoadWith(ctx, l, "new", time.Minute, load, WithEarlyRefresh(0))
+	require.NoError(t, err)
+	raw, _, _ := store.Get(ctx, "new")
+	var plain map[string]int
+	require.NoError(t, json.Unmarshal(raw, &plain))
+	assert.Equal(t, 1, plain["n"])
+	meta, ok, _ := store.Get(ctx, "new:meta")
+	require.True(t, ok)
+	assert.Contains(t, string(meta), `"v":1`)
+	v, err = LoadWith(ctx, l, "new", time.Minute, load)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v["n"])
+
+	// 元数据版本未知或与缓存值不匹配时忽略元数据，缓存值照常命中
+	require.NoError(t, store.Set(ctx, "future", []byte(`{"n":7}`), time.Minute))
+	require.NoError(t, store.Set(ctx, "future:meta", []byte(`{"v":9,"e":0}`), time.Minute))
+	require.NoError(t, store.Set(ctx, "overwritten", []byte(`{"n":8}`), time.Minute))
+	require.NoError(t, store.Set(ctx, "overwritten:meta", encodeMeta([]byte(`{"n":0}`), 0, clock.Now(), clock.Now()), time.Minute))
+	for key, want := range map[string]int{"future": 7, "overwritten": 8} {
+		v, err = LoadWith(ctx, l, key, time.Minute, load, WithStale(time.Hour, time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, want, v["n"], key)
+	}
+	assert.Equal(t, 1, loads)
+
+	// 硬过期：ttl 之后必须重新加载
+	clock.Advance(time.Minute)
+	v, err = LoadWith(ctx, l, "new", time.Minute, load, WithEarlyRefresh(100))
+	require.NoError(t, err)
+	assert.Equal(t, 2, v["n"])
+}
+
+func TestGetOrLoad_PanickingLoadReleasesWaiters(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLoader(newSimStore(clock, 0), clock)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		_, _ = LoadWith(context.Background(), l, "k", time.Minute, func(ctx context.Context) (int, error) {
+			close(entered)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-entered
+
+	errLate := errors.New("late")
+	done := make(chan error, 1)
+	go func() {
+		_, err := LoadWith(context.Background(), l, "k", time.Minute, func(ctx context.Context) (int, error) { return 0, errLate })
+		done <- err
+	}()
+	close(release)
+
+	// 已加入单飞的等待者收到 errLoadPanicked，来晚的自己加载；两种情况都不能卡住
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, errLoadPanicked) || errors.Is(err, errLate), "%v", err)
+	case <-time.After(time.Second):
+		t.Fatal("加载 panic 后等待者一直阻塞")
+	}
+	v, err := LoadWith(context.Background(), l, "k", time.Minute, func(ctx context.Context) (int, error) { return 2, nil })
+	require.NoError(t, err)
+	assert.Equal(t, 2, v, "panic 后的单飞记录已移除，之后的加载照常执行")
+}
+
+func TestGetOrLoad_SingleflightAndRefreshLimit(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLoader(newSimStore(clock, 0), clock)
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		loads.Add(1)
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := LoadWith(context.Background(), l, "k", time.Minute, load)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, v)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load())
+
+	// 后台刷新：每次命中都判定需要刷新，但同一间隔内只刷新一次
+	l.rand = func() float64 { return 1e-300 }
+	l.async = func(f func()) { f() }
+	l.RefreshInterval = 10 * time.Second
+	_, err := LoadWith(context.Background(), l, "hot", time.Minute, func(ctx context.Context) (int, error) {
+		loads.Add(1)
+		clock.Advance(time.Second)
+		return 1, nil
+	}, WithEarlyRefresh(0))
+	require.NoError(t, err)
+	before := loads.Load()
+	for i := 0; i < 5; i++ {
+		_, err := LoadWith(context.Background(), l, "hot", time.Minute, func(ctx context.Context) (int, error) {
+			loads.Add(1)
+			return 1, nil
+		}, WithEarlyRefresh(0))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, before+1, loads.Load())
+}
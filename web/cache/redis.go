@@ -10,9 +10,9 @@ import (
 
 // RedisConfig Redis 配置
 type RedisConfig struct {
-	Address  string `toml:"address"`  // Redis 地址
-	Password string `toml:"password"` // Redis 密码
-	DB       int    `toml:"db"`       // Redis DB
+	Address  string `toml:"address"`                   // Redis 地址
+	Password string `toml:"password" sensitive:"true"` // Redis 密码
+	DB       int    `toml:"db"`                        // Redis DB
 }
 
 // Client Redis 客户端（全局使用）
@@ -26,27 +26,39 @@ var Client *redis.Client
 //	    logger.Errorf("Failed to init redis: %v", err)
 //	}
 func InitRedis(cfg RedisConfig) error {
+	client, err := NewClient(cfg)
+	if err != nil || client == nil {
+		return err
+	}
+	Client = client
+	return nil
+}
+
+// NewClient 创建一个独立的 Redis 客户端并 Ping（不影响全局的 Client，未配置地址时返回 nil, nil）
+//
+// 同一进程中的多个服务各自持有客户端时使用（见 web.NewApp），调用方负责 Close
+func NewClient(cfg RedisConfig) (*redis.Client, error) {
 	if cfg.Address == "" {
-		return nil // 未配置，跳过
+		return nil, nil // 未配置，跳过
 	}
 
-	Client = redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.Address,
 		Password: cfg.Password,
 		DB:       cfg.DB,
 		PoolSize: 100,
 	})
+	client.AddHook(ledgerHook{})
 
 	// 测试连接
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := Client.Ping(ctx).Result()
-	if err != nil {
-		return fmt.Errorf("failed to ping redis: %w", err)
+	if _, err := client.Ping(ctx).Result(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
 	}
-
-	return nil
+	return client, nil
 }
 
 // Get 获取缓存
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+)
+
+// 依赖名称（SetDegraded 使用）
+const (
+	DependencyRedis = "redis"
+	DependencyDB    = "db"
+)
+
+// 返回旧值的原因（cache_stale_served_total 的 reason 标签）
+const (
+	StaleReasonError    = "error"    // 加载失败
+	StaleReasonTimeout  = "timeout"  // 加载超出时间预算
+	StaleReasonDegraded = "degraded" // 数据库处于降级状态，不再尝试加载
+)
+
+// errStaleBudget 加载超出时间预算
+var errStaleBudget = errors.New("加载超出时间预算")
+
+var degraded sync.Map // 依赖名称 -> struct{}
+
+// SetDegraded 标记依赖是否处于降级状态（熔断器打开、健康检查失败时调用）
+//
+// 数据库降级时，启用 WithStale 的读取直接返回旧值而不再回源；
+// Redis 降级时跳过缓存读写，直接回源
+//
+// 使用方式：
+//
+//	cache.SetDegraded(cache.DependencyDB, true)
+func SetDegraded(dependency string, isDegraded bool) {
+	if isDegraded {
+		degraded.Store(dependency, struct{}{})
+	} else {
+		degraded.Delete(dependency)
+	}
+}
+
+// IsDegraded 依赖是否处于降级状态
+func IsDegraded(dependency string) bool {
+	_, ok := degraded.Load(dependency)
+	return ok
+}
+
+// WithStale 启用旧值兜底：ttl 为新鲜期，hardTTL 为最长保留时间
+//
+// 超过新鲜期的值在回源失败或超出 budget（<= 0 表示只受请求 deadline 限制）时仍可返回，
+// 同时在后台刷新；只有请求通过 AllowStale 声明允许旧值时才生效，否则超过新鲜期即重新加载。
+// 没有旧值（或已超过 hardTTL）时错误照常返回
+func WithStale(hardTTL, budget time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.hardTTL = hardTTL
+		o.budget = budget
+	}
+}
+
+type staleMarker struct {
+	served atomic.Bool
+}
+
+type staleMarkerKey struct{}
+
+// AllowStale 声明当前请求接受旧值（web.ServeStale 中间件按路由调用）
+func AllowStale(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(staleMarkerKey{}).(*staleMarker); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, staleMarkerKey{}, &staleMarker{})
+}
+
+// ServedStale 当前请求是否返回过旧值
+func ServedStale(ctx context.Context) bool {
+	m, ok := ctx.Value(staleMarkerKey{}).(*staleMarker)
+	return ok && m.served.Load()
+}
+
+func staleAllowed(ctx context.Context) bool {
+	_, ok := ctx.Value(staleMarkerKey{}).(*staleMarker)
+	return ok
+}
+
+// markStale 记录返回了旧值
+func markStale(ctx context.Context, reason string) {
+	if m, ok := ctx.Value(staleMarkerKey{}).(*staleMarker); ok {
+		m.served.Store(true)
+	}
+	metrics.GetCounter("cache_stale_served_total", "reason", reason).Inc()
+}
+
+// loadWithBudget 在时间预算内回源；超时后加载继续在后台完成并写入缓存
+func (l *Loader) loadWithBudget(ctx context.Context, key string, ttl time.Duration, o loadOptions, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	budget := o.budget
+	if deadline, ok := ctx.Deadline(); ok {
+		if remain := time.Until(deadline); budget <= 0 || remain < budget {
+			budget = remain
+		}
+	} else if budget <= 0 {
+		return l.loadOnce(ctx, key, ttl, o, load)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		bg, cancel := context.WithTimeout(context.WithoutCancel(ctx), ttl)
+		defer cancel()
+		data, err := l.loadOnce(bg, key, ttl, o, load)
+		done <- result{data, err}
+	}()
+	timer := time.NewTimer(max(budget, 0))
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-timer.C:
+		return nil, errStaleBudget
+	}
+}
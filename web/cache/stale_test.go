@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDBDown = errors.New("db down")
+
+// newStaleLoader 后台刷新排队，由测试手动执行
+func newStaleLoader() (*Loader, *common.FakeClock, *[]func()) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLoader(newSimStore(clock, 0), clock)
+	queued := &[]func(){}
+	l.async = func(f func()) { *queued = append(*queued, f) }
+	return l, clock, queued
+}
+
+func TestGetOrLoad_StaleOnLoaderFailure(t *testing.T) {
+	l, clock, queued := newStaleLoader()
+	stale := WithStale(time.Hour, 0)
+	var fail bool
+	version := 0
+	load := func(ctx context.Context) (int, error) {
+		if fail {
+			return 0, errDBDown
+		}
+		version++
+		return version, nil
+	}
+
+	v, err := LoadWith(context.Background(), l, "catalog", time.Minute, load, stale)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// 超过新鲜期，回源失败：允许旧值的请求拿到旧值并被标记
+	clock.Advance(2 * time.Minute)
+	fail = true
+	before := metrics.GetCounter("cache_stale_served_total", "reason", StaleReasonError).Value()
+	ctx := AllowStale(context.Background())
+	v, err = LoadWith(ctx, l, "catalog", time.Minute, load, stale)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.True(t, ServedStale(ctx))
+	assert.Equal(t, before+1, metrics.GetCounter("cache_stale_served_total", "reason", StaleReasonError).Value())
+	require.Len(t, *queued, 1, "返回旧值时安排后台刷新")
+
+	// 未声明接受旧值的请求照常返回错误
+	_, err = LoadWith(context.Background(), l, "catalog", time.Minute, load, stale)
+	assert.ErrorIs(t, err, errDBDown)
+
+	// 后台刷新成功后恢复新鲜
+	fail = false
+	(*queued)[0]()
+	fail = true
+	ctx = AllowStale(context.Background())
+	v, err = LoadWith(ctx, l, "catalog", time.Minute, load, stale)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.False(t, ServedStale(ctx))
+}
+
+func TestGetOrLoad_StaleBeyondHardTTL(t *testing.T) {
+	l, clock, _ := newStaleLoader()
+	stale := WithStale(time.Hour, 0)
+	_, err := LoadWith(context.Background(), l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+		return 1, nil
+	}, stale)
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour + time.Second)
+	ctx := AllowStale(context.Background())
+	_, err = LoadWith(ctx, l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+		return 0, errDBDown
+	}, stale)
+	assert.ErrorIs(t, err, errDBDown, "超过 hardTTL 的值不再返回")
+	assert.False(t, ServedStale(ctx))
+}
+
+func TestGetOrLoad_StaleBudgetAndDegraded(t *testing.T) {
+	l, clock, _ := newStaleLoader()
+	stale := WithStale(time.Hour, 20*time.Millisecond)
+	_, err := LoadWith(context.Background(), l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+		return 1, nil
+	}, stale)
+	require.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+
+	// 回源超出预算：先返回旧值，加载完成后写入缓存
+	release := make(chan struct{})
+	done := make(chan struct{})
+	ctx := AllowStale(context.Background())
+	v, err := LoadWith(ctx, l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+		defer close(done)
+		<-release
+		return 2, nil
+	}, stale)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.True(t, ServedStale(ctx))
+	close(release)
+	<-done
+	require.Eventually(t, func() bool {
+		v, err := LoadWith(context.Background(), l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+			return 0, errDBDown
+		}, stale)
+		return err == nil && v == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// 数据库降级：不再回源，直接返回旧值
+	clock.Advance(2 * time.Minute)
+	SetDegraded(DependencyDB, true)
+	t.Cleanup(func() { SetDegraded(DependencyDB, false) })
+	ctx = AllowStale(context.Background())
+	v, err = LoadWith(ctx, l, "catalog", time.Minute, func(ctx context.Context) (int, error) {
+		t.Fatal("数据库降级时不应回源")
+		return 0, nil
+	}, stale)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.True(t, ServedStale(ctx))
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common/task"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskStore_ExactlyOnceAcrossReplicas(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过 Redis 集成测试")
+	}
+	ctx := context.Background()
+	prefix := "task-test:" + uuid.NewString() + ":"
+
+	var mu sync.Mutex
+	runs := make(map[int64]int)
+	var replicas []*task.Scheduler
+	for _, name := range []string{"a", "b"} {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		t.Cleanup(func() { client.Close() })
+		require.NoError(t, client.Ping(ctx).Err())
+		s := task.New(task.Config{Prefix: prefix, Instance: name, LockTTL: 3, Retain: 10},
+			task.WithStore(NewTaskStore(client)), task.WithTick(50*time.Millisecond))
+		require.NoError(t, s.Register("tick", task.Every(time.Second), func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[time.Now().Unix()]++
+			return nil
+		}))
+		replicas = append(replicas, s)
+	}
+	for _, s := range replicas {
+		s.Start()
+	}
+	time.Sleep(3500 * time.Millisecond)
+	for _, s := range replicas {
+		require.NoError(t, s.Stop(ctx))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(t, len(runs), 3)
+	for second, n := range runs {
+		assert.Equal(t, 1, n, "每个时间点只执行一次: %d", second)
+	}
+	statuses, err := replicas[0].Statuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, task.OutcomeOK, statuses[0].Outcome)
+
+	// 续期只对持有者生效
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		client.Del(ctx, prefix+"status")
+		client.Close()
+	})
+	store := NewTaskStore(client)
+	key := prefix + "lock:manual"
+	ok, err := store.Acquire(ctx, key, "a", time.Second, time.Now())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.Renew(ctx, key, "b", time.Second, time.Now())
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = store.Renew(ctx, key, "a", time.Second, time.Now())
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,358 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 灰度变体（X-Variant 响应头、访问日志与指标标签的取值）
+const (
+	VariantOld      = "old"      // 旧实现
+	VariantNew      = "new"      // 新实现
+	VariantFallback = "fallback" // 新实现出错，本次请求回退到旧实现
+)
+
+// canaryVariantKey 请求上下文中保存所选变体的 key
+const canaryVariantKey = "web_canary_variant"
+
+// CanaryConfig 灰度发布配置
+//
+// routes 按灰度名覆盖 web.NewCanarySelector 的默认规则，支持热更新；
+// 也可以通过 CanaryAdminHandler 在运行时修改（下次配置文件变更时以配置为准）
+//
+// Example:
+//
+//	[web.canary.routes.orders]
+//	percent = 10                  # 新实现的流量比例（0-100），按用户 ID 稳定哈希
+//	allow = ["u1001"]             # 始终使用新实现的用户
+//	deny = ["u2002"]              # 始终使用旧实现的用户
+//	headerOptIn = true            # 允许 X-Canary: always / never 请求头指定变体
+//	fallback = true               # 新实现返回 5xx 或 panic 时回退到旧实现
+//	killed = false                # 紧急开关：true 时全部走旧实现
+type CanaryConfig struct {
+	Routes map[string]CanaryRule `toml:"routes"`
+}
+
+// CanaryRule 单个灰度的选择规则
+type CanaryRule struct {
+	Percent     float64  `toml:"percent" json:"percent"`
+	Allow       []string `toml:"allow" json:"allow"`
+	Deny        []string `toml:"deny" json:"deny"`
+	HeaderOptIn bool     `toml:"headerOptIn" json:"headerOptIn"`
+	Fallback    bool     `toml:"fallback" json:"fallback"`
+	Killed      bool     `toml:"killed" json:"killed"`
+}
+
+// compiledCanaryRule 预处理后的规则（热路径只读）
+type compiledCanaryRule struct {
+	rule      CanaryRule
+	threshold uint32 // 哈希桶（0-9999）小于此值的用户使用新实现
+	allow     map[string]struct{}
+	deny      map[string]struct{}
+}
+
+func compileCanaryRule(rule CanaryRule) *compiledCanaryRule {
+	if rule.Percent < 0 {
+		rule.Percent = 0
+	}
+	if rule.Percent > 100 {
+		rule.Percent = 100
+	}
+	r := &compiledCanaryRule{
+		rule:      rule,
+		threshold: uint32(rule.Percent * 100),
+		allow:     make(map[string]struct{}, len(rule.Allow)),
+		deny:      make(map[string]struct{}, len(rule.Deny)),
+	}
+	for _, id := range rule.Allow {
+		r.allow[id] = struct{}{}
+	}
+	for _, id := range rule.Deny {
+		r.deny[id] = struct{}{}
+	}
+	return r
+}
+
+// CanarySelector 灰度选择器：按规则为每个请求选择新旧实现
+//
+// 选择顺序：紧急开关 → deny → allow → X-Canary 请求头 → 按用户 ID 哈希的比例。
+// 哈希以灰度名加用户 ID 为输入，同一用户在同一灰度中的分配稳定，比例调大时已分到新实现的用户不变；
+// 未登录请求按客户端 IP 哈希
+type CanarySelector struct {
+	name string
+	rule atomic.Pointer[compiledCanaryRule]
+
+	requests map[string]*metrics.Counter
+	errors   map[string]*metrics.Counter
+	duration map[string]*metrics.Counter
+	fallback *metrics.Counter
+}
+
+var (
+	canaryMu        sync.Mutex
+	canarySelectors = make(map[string]*CanarySelector)
+	canaryDefaults  = make(map[string]CanaryRule)
+	canaryConfig    CanaryConfig
+)
+
+// NewCanarySelector 创建（或获取已注册的）灰度选择器
+//
+// rule 为默认规则，配置中同名的 routes 会覆盖它
+//
+// 使用方式：
+//
+//	selector := web.NewCanarySelector("orders", web.CanaryRule{Percent: 1, Fallback: true})
+//	api.GET("/orders/:id", web.Canary(getOrderV1, getOrderV2, selector))
+func NewCanarySelector(name string, rule CanaryRule) *CanarySelector {
+	canaryMu.Lock()
+	defer canaryMu.Unlock()
+	if s, ok := canarySelectors[name]; ok {
+		return s
+	}
+
+	s := &CanarySelector{
+		name:     name,
+		requests: make(map[string]*metrics.Counter),
+		errors:   make(map[string]*metrics.Counter),
+		duration: make(map[string]*metrics.Counter),
+		fallback: metrics.GetCounter("web_canary_fallback_total", "canary", name),
+	}
+	for _, variant := range []string{VariantOld, VariantNew, VariantFallback} {
+		s.requests[variant] = metrics.GetCounter("web_canary_requests_total", "canary", name, "variant", variant)
+		s.errors[variant] = metrics.GetCounter("web_canary_errors_total", "canary", name, "variant", variant)
+		s.duration[variant] = metrics.GetCounter("web_canary_duration_ms_total", "canary", name, "variant", variant)
+	}
+	canaryDefaults[name] = rule
+	if override, ok := canaryConfig.Routes[name]; ok {
+		rule = override
+	}
+	s.rule.Store(compileCanaryRule(rule))
+	canarySelectors[name] = s
+	return s
+}
+
+// Name 灰度名
+func (s *CanarySelector) Name() string {
+	return s.name
+}
+
+// Rule 当前生效的规则
+func (s *CanarySelector) Rule() CanaryRule {
+	return s.rule.Load().rule
+}
+
+// SetRule 替换规则（立即生效，不影响进行中的请求）
+func (s *CanarySelector) SetRule(rule CanaryRule) {
+	s.rule.Store(compileCanaryRule(rule))
+}
+
+// SetKilled 紧急开关：全部回到旧实现 / 恢复灰度
+func (s *CanarySelector) SetKilled(killed bool) {
+	rule := s.Rule()
+	rule.Killed = killed
+	s.SetRule(rule)
+}
+
+// Select 为请求选择变体（VariantOld 或 VariantNew）
+func (s *CanarySelector) Select(c *app.RequestContext) string {
+	key := jwt.GetUserID(c)
+	if key == "" {
+		key = c.ClientIP()
+	}
+	return s.choose(key, string(c.GetHeader("X-Canary")))
+}
+
+// choose 按用户标识和 X-Canary 请求头选择变体
+func (s *CanarySelector) choose(userID, header string) string {
+	r := s.rule.Load()
+	if r.rule.Killed {
+		return VariantOld
+	}
+	if _, ok := r.deny[userID]; ok {
+		return VariantOld
+	}
+	if _, ok := r.allow[userID]; ok {
+		return VariantNew
+	}
+	if r.rule.HeaderOptIn {
+		switch strings.ToLower(header) {
+		case "always":
+			return VariantNew
+		case "never":
+			return VariantOld
+		}
+	}
+	if canaryBucket(s.name, userID) < r.threshold {
+		return VariantNew
+	}
+	return VariantOld
+}
+
+// canaryBucket 用户在灰度中的哈希桶（0-9999）
+func canaryBucket(name, userID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return h.Sum32() % 10000
+}
+
+// Canary 灰度路由：按选择器把请求交给旧实现或新实现
+//
+// 所选变体写入 X-Variant 响应头和访问日志，并按变体记录请求数、5xx 错误数和累计耗时
+// （web_canary_requests_total / web_canary_errors_total / web_canary_duration_ms_total）。
+// 规则开启 fallback 时，新实现返回 5xx 或 panic 会丢弃其响应并改由旧实现处理本次请求；
+// 4xx 属于客户端错误，不回退
+//
+// 使用方式：
+//
+//	selector := web.NewCanarySelector("orders", web.CanaryRule{Percent: 10, Fallback: true})
+//	api.GET("/orders/:id", web.Canary(getOrderV1, getOrderV2, selector))
+func Canary(oldHandler, newHandler app.HandlerFunc, selector *CanarySelector) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		variant := selector.Select(c)
+		if variant == VariantNew && selector.Rule().Fallback {
+			if selector.serve(ctx, c, VariantNew, newHandler, true) {
+				return
+			}
+			selector.fallback.Inc()
+			log.Warnf("[Canary] %s 新实现出错，回退到旧实现: %s %s", selector.name, c.Method(), c.Path())
+			c.Response.ResetBody()
+			c.Response.SetStatusCode(consts.StatusOK)
+			selector.serve(ctx, c, VariantFallback, oldHandler, false)
+			return
+		}
+
+		handler := oldHandler
+		if variant == VariantNew {
+			handler = newHandler
+		}
+		selector.serve(ctx, c, variant, handler, false)
+	}
+}
+
+// serve 执行一个变体并记录指标，返回是否成功（非 5xx 且未 panic）
+//
+// recoverServerErr 为 true 时吞掉服务端错误类 panic（交给调用方回退），否则继续向上抛出
+func (s *CanarySelector) serve(ctx context.Context, c *app.RequestContext, variant string, h app.HandlerFunc, recoverServerErr bool) (ok bool) {
+	c.Set(canaryVariantKey, variant)
+	c.Response.Header.Set("X-Variant", variant)
+	start := time.Now()
+	defer func() {
+		r := recover()
+		failed := c.Response.StatusCode() >= 500
+		if r != nil {
+			failed = !isClientErrorPanic(r)
+		}
+		if middleware.IsClientAborted(c) {
+			failed = false // 客户端中途断开不计入变体的错误率，也不回退
+		}
+		s.requests[variant].Inc()
+		s.duration[variant].Add(time.Since(start).Milliseconds())
+		if failed {
+			s.errors[variant].Inc()
+		}
+		ok = !failed
+		if r != nil && (!recoverServerErr || !failed) {
+			panic(r)
+		}
+		if r != nil {
+			if common.IsControlFlow(r) {
+				log.Warnf("[Canary] %s 新实现返回服务端错误: %v", s.name, r)
+			} else {
+				reportPanic(ctx, c, r)
+			}
+		}
+	}()
+	h(ctx, c)
+	return
+}
+
+// isClientErrorPanic panic 值是否为 4xx 业务异常
+func isClientErrorPanic(r any) bool {
+	status, _, ok := controlFlowResult(r)
+	return ok && status < 500
+}
+
+// GetCanaryVariant 获取本次请求的灰度变体（未经过 Canary 时返回空字符串）
+func GetCanaryVariant(c *app.RequestContext) string {
+	return c.GetString(canaryVariantKey)
+}
+
+// SetCanaryConfig 应用灰度配置（启动时和配置热更新时调用）
+//
+// 配置中没有的灰度恢复为代码中的默认规则
+func SetCanaryConfig(config CanaryConfig) {
+	canaryMu.Lock()
+	defer canaryMu.Unlock()
+	canaryConfig = config
+	for name, s := range canarySelectors {
+		rule, ok := config.Routes[name]
+		if !ok {
+			rule = canaryDefaults[name]
+		}
+		s.SetRule(rule)
+	}
+}
+
+// CanarySelectors 已注册的灰度选择器（按名称排序）
+func CanarySelectors() []*CanarySelector {
+	canaryMu.Lock()
+	defer canaryMu.Unlock()
+	list := make([]*CanarySelector, 0, len(canarySelectors))
+	for _, s := range canarySelectors {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// CanaryAdminHandler 灰度管理接口
+//
+// GET 返回全部灰度的当前规则；POST/PUT ?name=orders 提交规则中需要修改的字段
+// （如 {"percent": 50} 或 {"killed": true}），未提交的字段保持不变。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/canary", web.CanaryAdminHandler())
+func CanaryAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			name := c.Query("name")
+			canaryMu.Lock()
+			s, ok := canarySelectors[name]
+			canaryMu.Unlock()
+			if !ok {
+				panic(NewHTTPException(404, 404, "灰度不存在: "+name))
+			}
+			rule := s.Rule()
+			if err := json.Unmarshal(c.Request.Body(), &rule); err != nil {
+				panic(BadRequestHTTP("灰度规则格式错误"))
+			}
+			s.SetRule(rule)
+			log.Warnf("[Canary] %s 规则已修改: percent=%v killed=%v fallback=%v", name, rule.Percent, rule.Killed, rule.Fallback)
+			c.JSON(consts.StatusOK, Success(s.Rule()))
+		default:
+			rules := make(map[string]CanaryRule)
+			for _, s := range CanarySelectors() {
+				rules[s.name] = s.Rule()
+			}
+			c.JSON(consts.StatusOK, Success(rules))
+		}
+	}
+}
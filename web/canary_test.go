@@ -0,0 +1,179 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCanaryEngine(t *testing.T, name string, rule CanaryRule, newHandler app.HandlerFunc) (*route.Engine, *CanarySelector) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "canary-secret"
+	require.NoError(t, jwt.Init(conf))
+	t.Cleanup(func() { SetCanaryConfig(CanaryConfig{}) })
+
+	selector := NewCanarySelector(name, rule)
+	oldHandler := func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success("v1"))
+	}
+	if newHandler == nil {
+		newHandler = func(ctx context.Context, c *app.RequestContext) {
+			c.JSON(200, Success("v2"))
+		}
+	}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.GET("/orders", jwt.Middleware(), Canary(oldHandler, newHandler, selector))
+	return engine, selector
+}
+
+func TestCanary_StableAssignment(t *testing.T) {
+	engine, selector := newCanaryEngine(t, "canary-stable", CanaryRule{Percent: 50}, nil)
+
+	for i := 0; i < 20; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		want := selector.choose(user, "")
+		token := ownershipToken(t, user)
+		for j := 0; j < 5; j++ {
+			w := ut.PerformRequest(engine, "GET", "/orders", nil, token)
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, want, w.Header().Get("X-Variant"), user)
+		}
+	}
+
+	// 比例调大时，已在新实现中的用户不会被换回旧实现
+	var before []string
+	for i := 0; i < 1000; i++ {
+		if user := fmt.Sprintf("user-%d", i); selector.choose(user, "") == VariantNew {
+			before = append(before, user)
+		}
+	}
+	selector.SetRule(CanaryRule{Percent: 80})
+	for _, user := range before {
+		assert.Equal(t, VariantNew, selector.choose(user, ""), user)
+	}
+}
+
+func TestCanary_PercentageAccuracy(t *testing.T) {
+	for _, percent := range []float64{1, 10, 50} {
+		selector := NewCanarySelector(fmt.Sprintf("canary-percent-%v", percent), CanaryRule{Percent: percent})
+		const sample = 100000
+		hits := 0
+		for i := 0; i < sample; i++ {
+			if selector.choose(fmt.Sprintf("u%d", i), "") == VariantNew {
+				hits++
+			}
+		}
+		got := float64(hits) / sample * 100
+		assert.InDelta(t, percent, got, 0.5, "percent=%v", percent)
+	}
+}
+
+func TestCanary_ListsAndHeader(t *testing.T) {
+	selector := NewCanarySelector("canary-lists", CanaryRule{
+		Percent:     100,
+		Allow:       []string{"vip"},
+		Deny:        []string{"legacy"},
+		HeaderOptIn: true,
+	})
+	assert.Equal(t, VariantOld, selector.choose("legacy", "always"))
+	assert.Equal(t, VariantNew, selector.choose("someone", ""))
+	assert.Equal(t, VariantOld, selector.choose("someone", "never"))
+
+	selector.SetRule(CanaryRule{Allow: []string{"vip"}, HeaderOptIn: true})
+	assert.Equal(t, VariantNew, selector.choose("vip", ""))
+	assert.Equal(t, VariantNew, selector.choose("someone", "Always"))
+	assert.Equal(t, VariantOld, selector.choose("someone", ""))
+
+	// 未开启 headerOptIn 时忽略请求头
+	selector.SetRule(CanaryRule{})
+	assert.Equal(t, VariantOld, selector.choose("someone", "always"))
+}
+
+func TestCanary_KillSwitch(t *testing.T) {
+	engine, selector := newCanaryEngine(t, "canary-kill", CanaryRule{Percent: 100}, nil)
+	token := ownershipToken(t, "alice")
+
+	w := ut.PerformRequest(engine, "GET", "/orders", nil, token)
+	assert.Equal(t, VariantNew, w.Header().Get("X-Variant"))
+	assert.Contains(t, w.Body.String(), "v2")
+
+	// 配置热更新
+	SetCanaryConfig(CanaryConfig{Routes: map[string]CanaryRule{"canary-kill": {Percent: 100, Killed: true}}})
+	w = ut.PerformRequest(engine, "GET", "/orders", nil, token)
+	assert.Equal(t, VariantOld, w.Header().Get("X-Variant"))
+	assert.Contains(t, w.Body.String(), "v1")
+
+	// 配置中移除后恢复代码中的默认规则
+	SetCanaryConfig(CanaryConfig{})
+	assert.Equal(t, VariantNew, ut.PerformRequest(engine, "GET", "/orders", nil, token).Header().Get("X-Variant"))
+
+	// 管理接口
+	admin := route.NewEngine(config.NewOptions(nil))
+	admin.Use(ExceptionHandler())
+	admin.Any("/admin/canary", CanaryAdminHandler())
+	w = ut.PerformRequest(admin, "POST", "/admin/canary?name=canary-kill",
+		&ut.Body{Body: strings.NewReader(`{"killed": true}`), Len: 16})
+	assert.Equal(t, 200, w.Code)
+	assert.True(t, selector.Rule().Killed)
+	assert.Equal(t, 100.0, selector.Rule().Percent)
+	assert.Equal(t, VariantOld, ut.PerformRequest(engine, "GET", "/orders", nil, token).Header().Get("X-Variant"))
+
+	w = ut.PerformRequest(admin, "GET", "/admin/canary", nil)
+	assert.Contains(t, w.Body.String(), `"canary-kill":{"percent":100`)
+	w = ut.PerformRequest(admin, "POST", "/admin/canary?name=missing", &ut.Body{Body: strings.NewReader(`{}`), Len: 2})
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestCanary_Fallback(t *testing.T) {
+	calls := 0
+	failing := func(ctx context.Context, c *app.RequestContext) {
+		calls++
+		if c.Query("mode") == "panic" {
+			panic(fmt.Errorf("boom"))
+		}
+		if c.Query("mode") == "bad" {
+			panic(BadRequestHTTP("bad input"))
+		}
+		c.JSON(500, Fail(500, "v2 broken"))
+	}
+	engine, selector := newCanaryEngine(t, "canary-fallback", CanaryRule{Percent: 100, Fallback: true}, failing)
+	token := ownershipToken(t, "alice")
+	fallbacks := selector.fallback.Value()
+	newErrors := selector.errors[VariantNew].Value()
+
+	w := ut.PerformRequest(engine, "GET", "/orders", nil, token)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, VariantFallback, w.Header().Get("X-Variant"))
+	assert.Contains(t, w.Body.String(), "v1")
+	assert.NotContains(t, w.Body.String(), "v2")
+
+	w = ut.PerformRequest(engine, "GET", "/orders?mode=panic", nil, token)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "v1")
+	assert.Equal(t, fallbacks+2, selector.fallback.Value())
+	assert.Equal(t, newErrors+2, selector.errors[VariantNew].Value())
+
+	// 4xx 是客户端错误，不回退
+	w = ut.PerformRequest(engine, "GET", "/orders?mode=bad", nil, token)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, VariantNew, w.Header().Get("X-Variant"))
+	assert.Equal(t, fallbacks+2, selector.fallback.Value())
+
+	// 关闭回退后直接返回新实现的错误
+	selector.SetRule(CanaryRule{Percent: 100})
+	w = ut.PerformRequest(engine, "GET", "/orders", nil, token)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, VariantNew, w.Header().Get("X-Variant"))
+	assert.Equal(t, 4, calls)
+}
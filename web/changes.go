@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// ChangeEvent 实体变更事件（"entity changed"），携带结构化差异
+type ChangeEvent struct {
+	Time      time.Time     `json:"time"`
+	Entity    string        `json:"entity"`              // 实体类型，如 order
+	EntityID  string        `json:"entityId"`            // 实体 ID
+	Actor     string        `json:"actor,omitempty"`     // 实际操作人（代理登录时为管理员）
+	Subject   string        `json:"subject,omitempty"`   // 代理登录时被代理的用户
+	RequestID string        `json:"requestId,omitempty"` // 请求 ID
+	Changes   []FieldChange `json:"changes"`             // 字段变更（敏感字段不含新旧值）
+}
+
+// ChangePublisher 变更事件发布（写 outbox 表、消息队列等）
+type ChangePublisher func(ctx context.Context, event ChangeEvent) error
+
+var changePublisher atomic.Pointer[ChangePublisher]
+
+// SetChangePublisher 设置变更事件发布（nil 表示只写审计，不发布事件）
+//
+// 使用方式：
+//
+//	web.SetChangePublisher(func(ctx context.Context, e web.ChangeEvent) error {
+//	    payload, _ := json.Marshal(e)
+//	    return queries.InsertOutbox(ctx, e.Entity+".changed", payload)
+//	})
+func SetChangePublisher(p ChangePublisher) {
+	if p == nil {
+		changePublisher.Store(nil)
+		return
+	}
+	changePublisher.Store(&p)
+}
+
+// RecordChanges 记录实体变更：写入审计（类型 entity.change）并发布 ChangeEvent
+//
+// changes 为空时不做任何事。审计和发布失败只记录错误日志，不影响业务流程
+//
+// 使用方式：
+//
+//	old, _ := queries.GetOrder(ctx, id)
+//	updated, _ := queries.UpdateOrder(ctx, params)
+//	changes, err := web.Diff(old, updated)
+//	if err == nil {
+//	    web.RecordChanges(c, "order", id, changes)
+//	}
+func RecordChanges(c *app.RequestContext, entityType, entityID string, changes []FieldChange) {
+	if len(changes) == 0 {
+		return
+	}
+	event := ChangeEvent{
+		Time:      time.Now(),
+		Entity:    entityType,
+		EntityID:  entityID,
+		Actor:     jwt.GetActor(c),
+		RequestID: middleware.GetRequestID(c),
+		Changes:   changes,
+	}
+	if jwt.IsImpersonating(c) {
+		event.Subject = jwt.GetUserID(c)
+	}
+
+	audit.Emit(context.Background(), audit.Event{
+		Time:         event.Time,
+		Type:         "entity.change",
+		Actor:        event.Actor,
+		Subject:      event.Subject,
+		Impersonated: event.Subject != "",
+		RequestID:    event.RequestID,
+		Method:       string(c.Method()),
+		Path:         string(c.Path()),
+		Data:         map[string]any{"entity": entityType, "entityId": entityID, "changes": changes},
+	})
+
+	if p := changePublisher.Load(); p != nil {
+		if err := (*p)(context.Background(), event); err != nil {
+			log.Errorf("[Changes] 发布 %s/%s 变更事件失败: %v", entityType, entityID, err)
+		}
+	}
+}
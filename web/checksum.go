@@ -0,0 +1,429 @@
+package web
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderContentSHA256 客户端声明的文件 SHA-256（十六进制或 base64），与实际内容不一致时拒绝上传
+const HeaderContentSHA256 = "X-Content-SHA256"
+
+// 下载时输出摘要的方式（ChecksumConfig.DownloadHeader）
+const (
+	DigestHeaderETag          = "etag"           // ETag: "<sha256 十六进制>"
+	DigestHeaderContentDigest = "content-digest" // Content-Digest: sha-256=:<base64>:（RFC 9530，Range 响应为 Repr-Digest）
+	DigestHeaderBoth          = "both"           // 同时输出
+)
+
+// ChecksumConfig 上传文件校验和配置
+//
+// SHA-256 总是在写入时同步计算；摘要通过 SetChecksumStore 注册的 DigestStore 持久化，
+// 供下载时输出与 VerifyStoredFile 定期巡检使用
+//
+// Example:
+//
+//	[web.upload.checksum]
+//	md5 = true                       # 同时计算 MD5（兼容只认 MD5 的旧系统）
+//	downloadHeader = "content-digest" # etag / content-digest / both，为空不输出
+type ChecksumConfig struct {
+	MD5            bool   `toml:"md5"`            // 是否同时计算 MD5
+	DownloadHeader string `toml:"downloadHeader"` // 下载时输出摘要的方式
+}
+
+// Digests 文件摘要（十六进制小写）
+type Digests struct {
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// ErrChecksumMismatch 文件内容与声明或记录的摘要不一致
+type ErrChecksumMismatch struct {
+	Key      string // 文件（上传时为目标路径，巡检时为存储 key）
+	Expected string // 期望的 SHA-256
+	Actual   string // 实际的 SHA-256
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("Checksum mismatch: expected sha256 %s, got %s", e.Expected, e.Actual)
+}
+
+// ErrDigestNotFound 没有记录文件的摘要
+var ErrDigestNotFound = errors.New("web: digest not recorded")
+
+// DigestStore 文件摘要的持久化（元数据钩子），key 为本地路径或存储 key
+type DigestStore interface {
+	SaveDigests(ctx context.Context, key string, d Digests) error
+	// LoadDigests 没有记录时返回 ErrDigestNotFound
+	LoadDigests(ctx context.Context, key string) (Digests, error)
+}
+
+// ObjectStorage 校验和需要的存储能力（storage.Storage 满足此接口）
+type ObjectStorage interface {
+	Append(ctx context.Context, key string, offset int64) (io.WriteCloser, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryDigestStore 内存摘要存储（单实例或测试使用）
+type MemoryDigestStore struct {
+	mu      sync.RWMutex
+	digests map[string]Digests
+}
+
+// NewMemoryDigestStore 创建内存摘要存储
+func NewMemoryDigestStore() *MemoryDigestStore {
+	return &MemoryDigestStore{digests: make(map[string]Digests)}
+}
+
+// SaveDigests 实现 DigestStore 接口
+func (s *MemoryDigestStore) SaveDigests(ctx context.Context, key string, d Digests) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digests[key] = d
+	return nil
+}
+
+// LoadDigests 实现 DigestStore 接口
+func (s *MemoryDigestStore) LoadDigests(ctx context.Context, key string) (Digests, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.digests[key]
+	if !ok {
+		return Digests{}, ErrDigestNotFound
+	}
+	return d, nil
+}
+
+// checksumState 全局校验和配置
+type checksumState struct {
+	config ChecksumConfig
+	files  ObjectStorage
+	meta   DigestStore
+}
+
+var globalChecksum atomic.Pointer[checksumState]
+
+var checksumMismatches = map[string]*metrics.Counter{
+	"upload": metrics.GetCounter("web_checksum_mismatch_total", "stage", "upload"),
+	"audit":  metrics.GetCounter("web_checksum_mismatch_total", "stage", "audit"),
+}
+
+// InitChecksums 根据配置设置校验和选项（保留已注册的存储与摘要存储）
+func InitChecksums(config ChecksumConfig) {
+	s := loadChecksums()
+	globalChecksum.Store(&checksumState{config: config, files: s.files, meta: s.meta})
+}
+
+// SetChecksumStore 注册文件存储与摘要存储
+//
+// files 为 nil 时 VerifyStoredFile 把 key 当作本地路径读取；meta 为 nil 时摘要只返回、不持久化
+//
+// 使用方式：
+//
+//	store := storage.NewLocal("data/files", baseURL, secret, nil)
+//	web.SetChecksumStore(store, digestRepo) // digestRepo 实现 web.DigestStore，如写入文件表的 sha256 列
+func SetChecksumStore(files ObjectStorage, meta DigestStore) {
+	s := loadChecksums()
+	globalChecksum.Store(&checksumState{config: s.config, files: files, meta: meta})
+}
+
+func loadChecksums() checksumState {
+	if s := globalChecksum.Load(); s != nil {
+		return *s
+	}
+	return checksumState{}
+}
+
+// digester 写入时同步计算摘要（io.Writer，配合 io.MultiWriter 单次读取）
+type digester struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+	size   int64
+}
+
+func newDigester() *digester {
+	d := &digester{sha256: sha256.New()}
+	if loadChecksums().config.MD5 {
+		d.md5 = md5.New()
+	}
+	return d
+}
+
+func (d *digester) Write(p []byte) (int, error) {
+	d.sha256.Write(p)
+	if d.md5 != nil {
+		d.md5.Write(p)
+	}
+	d.size += int64(len(p))
+	return len(p), nil
+}
+
+func (d *digester) digests() Digests {
+	out := Digests{SHA256: hex.EncodeToString(d.sha256.Sum(nil)), Size: d.size}
+	if d.md5 != nil {
+		out.MD5 = hex.EncodeToString(d.md5.Sum(nil))
+	}
+	return out
+}
+
+// normalizeSHA256 把十六进制或 base64 形式的 SHA-256 转为十六进制小写，格式无效返回空
+func normalizeSHA256(v string) string {
+	v = strings.TrimSpace(v)
+	if b, err := hex.DecodeString(v); err == nil && len(b) == sha256.Size {
+		return strings.ToLower(v)
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(v); err == nil && len(b) == sha256.Size {
+			return hex.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// verifyExpected 校验客户端声明的摘要（expected 为空时不校验）
+func verifyExpected(key, expected string, d Digests) error {
+	if expected == "" {
+		return nil
+	}
+	want := normalizeSHA256(expected)
+	if want == "" {
+		return BadRequestHTTP(HeaderContentSHA256 + " 格式无效")
+	}
+	if want != d.SHA256 {
+		checksumMismatches["upload"].Inc()
+		log.Warnf("[Checksum] 拒绝上传 %s: 期望 %s，实际 %s", key, want, d.SHA256)
+		return &ErrChecksumMismatch{Key: key, Expected: want, Actual: d.SHA256}
+	}
+	return nil
+}
+
+// recordDigests 通过摘要存储持久化（未注册时跳过）
+func recordDigests(ctx context.Context, key string, d Digests) error {
+	if meta := loadChecksums().meta; meta != nil {
+		if err := meta.SaveDigests(ctx, key, d); err != nil {
+			return fmt.Errorf("保存文件摘要失败: %w", err)
+		}
+	}
+	return nil
+}
+
+type expectedSHA256Key struct{}
+
+// WithExpectedSHA256 把期望的 SHA-256 放入 ctx，之后 SaveUploadedFileContext 等保存时校验
+//
+// UploadMiddleware 会自动读取 X-Content-SHA256 请求头
+func WithExpectedSHA256(ctx context.Context, sha256 string) context.Context {
+	return context.WithValue(ctx, expectedSHA256Key{}, sha256)
+}
+
+// expectedSHA256 ctx 中期望的 SHA-256
+func expectedSHA256(ctx context.Context) string {
+	v, _ := ctx.Value(expectedSHA256Key{}).(string)
+	return v
+}
+
+// CompleteChunkedUpload 把已上传的分片按顺序合并为 key，并计算整个文件的摘要
+//
+// 分片与最终文件都在 SetChecksumStore 注册的存储中；摘要在合并时同步计算，与一次性上传同一文件的结果一致。
+// expectedSHA256 不为空且不一致时删除合并结果并返回 *ErrChecksumMismatch（摘要不会被记录，分片保留以便重试）；
+// 成功后记录摘要并删除分片
+//
+// 使用方式：
+//
+//	digests, err := web.CompleteChunkedUpload(ctx, "firmware/v2.bin", partKeys, string(c.GetHeader(web.HeaderContentSHA256)))
+func CompleteChunkedUpload(ctx context.Context, key string, parts []string, expectedSHA256 string) (Digests, error) {
+	files := loadChecksums().files
+	if files == nil {
+		return Digests{}, errors.New("web: 未注册文件存储（SetChecksumStore）")
+	}
+
+	w, err := files.Append(ctx, key, 0)
+	if err != nil {
+		return Digests{}, fmt.Errorf("创建合并文件失败: %w", err)
+	}
+	d := newDigester()
+	dst := io.MultiWriter(w, d)
+	for _, part := range parts {
+		if err = copyObject(ctx, files, part, dst); err != nil {
+			break
+		}
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = verifyExpected(key, expectedSHA256, d.digests())
+	}
+	if err == nil {
+		err = recordDigests(ctx, key, d.digests())
+	}
+	if err != nil {
+		files.Delete(ctx, key)
+		return Digests{}, err
+	}
+
+	for _, part := range parts {
+		if err := files.Delete(ctx, part); err != nil {
+			log.Warnf("[Checksum] 删除分片 %s 失败: %v", part, err)
+		}
+	}
+	return d.digests(), nil
+}
+
+// copyObject 把存储中的 key 复制到 dst
+func copyObject(ctx context.Context, files ObjectStorage, key string, dst io.Writer) error {
+	r, err := files.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("读取分片 %s 失败: %w", key, err)
+	}
+	defer r.Close()
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("读取分片 %s 失败: %w", key, err)
+	}
+	return nil
+}
+
+// VerifyStoredFile 重新计算已存储文件的摘要并与记录比对（定期完整性巡检）
+//
+// 从 SetChecksumStore 注册的存储读取 key（未注册存储时 key 为本地路径）；
+// 不一致时返回 *ErrChecksumMismatch，没有记录时返回 ErrDigestNotFound
+//
+// 使用方式：
+//
+//	if _, err := web.VerifyStoredFile(ctx, "firmware/v2.bin"); err != nil {
+//	    var mismatch *web.ErrChecksumMismatch
+//	    if errors.As(err, &mismatch) { ... }
+//	}
+func VerifyStoredFile(ctx context.Context, key string) (Digests, error) {
+	s := loadChecksums()
+	if s.meta == nil {
+		return Digests{}, errors.New("web: 未注册摘要存储（SetChecksumStore）")
+	}
+	recorded, err := s.meta.LoadDigests(ctx, key)
+	if err != nil {
+		return Digests{}, err
+	}
+
+	var r io.ReadCloser
+	if s.files != nil {
+		r, err = s.files.Open(ctx, key)
+	} else {
+		r, err = os.Open(key)
+	}
+	if err != nil {
+		return Digests{}, fmt.Errorf("读取文件 %s 失败: %w", key, err)
+	}
+	defer r.Close()
+
+	d := &digester{sha256: sha256.New()}
+	if recorded.MD5 != "" {
+		d.md5 = md5.New()
+	}
+	if _, err := io.Copy(d, r); err != nil {
+		return Digests{}, fmt.Errorf("读取文件 %s 失败: %w", key, err)
+	}
+	actual := d.digests()
+	if actual.SHA256 != recorded.SHA256 || actual.Size != recorded.Size || (recorded.MD5 != "" && actual.MD5 != recorded.MD5) {
+		return actual, &ErrChecksumMismatch{Key: key, Expected: recorded.SHA256, Actual: actual.SHA256}
+	}
+	return actual, nil
+}
+
+// ChecksumAuditTask 返回逐个校验 keys 列出的文件的巡检任务（可直接注册到 task.Scheduler）
+//
+// 损坏的文件记录错误日志与审计事件 file.corrupted；有文件损坏或无法读取时任务返回错误
+//
+// 使用方式：
+//
+//	scheduler.Register("file-audit", task.Daily(3, 0, time.Local), web.ChecksumAuditTask(repo.AllFileKeys))
+func ChecksumAuditTask(keys func(ctx context.Context) ([]string, error)) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		list, err := keys(ctx)
+		if err != nil {
+			return fmt.Errorf("获取待巡检文件失败: %w", err)
+		}
+		var corrupted, failed int
+		for _, key := range list {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			_, err := VerifyStoredFile(ctx, key)
+			var mismatch *ErrChecksumMismatch
+			switch {
+			case err == nil:
+			case errors.As(err, &mismatch):
+				corrupted++
+				checksumMismatches["audit"].Inc()
+				log.Errorf("[Checksum] 文件已损坏 %s: 记录 %s，实际 %s", key, mismatch.Expected, mismatch.Actual)
+				audit.Emit(ctx, audit.Event{Type: "file.corrupted", Data: map[string]any{
+					"key": key, "expected": mismatch.Expected, "actual": mismatch.Actual,
+				}})
+			default:
+				failed++
+				log.Warnf("[Checksum] 巡检 %s 失败: %v", key, err)
+			}
+		}
+		if corrupted > 0 || failed > 0 {
+			return fmt.Errorf("文件巡检: %d 个损坏，%d 个无法校验（共 %d 个）", corrupted, failed, len(list))
+		}
+		return nil
+	}
+}
+
+// SetDigestHeaders 按 ChecksumConfig.DownloadHeader 输出文件摘要（partial 为 Range 响应）
+//
+// Range 响应只输出 Repr-Digest：Content-Digest 描述的是本次响应体，而记录的是整个文件的摘要
+func SetDigestHeaders(c *app.RequestContext, d Digests, partial bool) {
+	mode := loadChecksums().config.DownloadHeader
+	if d.SHA256 == "" || mode == "" {
+		return
+	}
+	if mode == DigestHeaderETag || mode == DigestHeaderBoth {
+		c.Header("ETag", `"`+d.SHA256+`"`)
+	}
+	if mode == DigestHeaderContentDigest || mode == DigestHeaderBoth {
+		raw, err := hex.DecodeString(d.SHA256)
+		if err != nil {
+			return
+		}
+		value := "sha-256=:" + base64.StdEncoding.EncodeToString(raw) + ":"
+		if partial {
+			c.Header("Repr-Digest", value)
+		} else {
+			c.Header("Content-Digest", value)
+		}
+	}
+}
+
+// DigestsFor 查询 key 记录的摘要，供自定义下载处理在调用 DownloadWithRange 之前输出（未开启输出时返回零值）
+//
+// 使用方式：
+//
+//	web.SetDigestHeaders(c, web.DigestsFor(ctx, key), len(c.GetHeader("Range")) > 0)
+func DigestsFor(ctx context.Context, key string) Digests {
+	s := loadChecksums()
+	if s.config.DownloadHeader == "" || s.meta == nil {
+		return Digests{}
+	}
+	d, err := s.meta.LoadDigests(ctx, key)
+	if err != nil {
+		return Digests{}
+	}
+	return d
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diskStore 以目录模拟 storage.Local（storage 包依赖 web，测试中不能直接使用）
+type diskStore struct{ dir string }
+
+func (s diskStore) Append(ctx context.Context, key string, offset int64) (io.WriteCloser, error) {
+	p := filepath.Join(s.dir, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	return f, err
+}
+
+func (s diskStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, key))
+}
+
+func (s diskStore) Delete(ctx context.Context, key string) error {
+	return os.Remove(filepath.Join(s.dir, key))
+}
+
+func (s diskStore) put(t *testing.T, key, content string) {
+	w, err := s.Append(context.Background(), key, 0)
+	require.NoError(t, err)
+	io.WriteString(w, content)
+	require.NoError(t, w.Close())
+}
+
+func setChecksums(t *testing.T, config ChecksumConfig, files ObjectStorage, meta DigestStore) {
+	InitChecksums(config)
+	SetChecksumStore(files, meta)
+	t.Cleanup(func() {
+		InitChecksums(ChecksumConfig{})
+		SetChecksumStore(nil, nil)
+	})
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChecksum_MismatchRejectedBeforeCommit(t *testing.T) {
+	dir := t.TempDir()
+	meta := NewMemoryDigestStore()
+	setChecksums(t, ChecksumConfig{MD5: true}, nil, meta)
+	content := "firmware v2"
+
+	// 声明的摘要正确：返回摘要并记录
+	dst := filepath.Join(dir, "ok.bin")
+	saved, err := SaveUploadedFileInfo(WithExpectedSHA256(context.Background(), strings.ToUpper(sha256Hex(content))), newFileHeader(t, "ok.bin", content), dst)
+	require.NoError(t, err)
+	assert.Equal(t, sha256Hex(content), saved.Digests.SHA256)
+	md5sum := md5.Sum([]byte(content))
+	assert.Equal(t, hex.EncodeToString(md5sum[:]), saved.Digests.MD5)
+	assert.Equal(t, int64(len(content)), saved.Digests.Size)
+	recorded, err := meta.LoadDigests(context.Background(), dst)
+	require.NoError(t, err)
+	assert.Equal(t, saved.Digests, recorded)
+
+	// base64 形式同样接受
+	sum := sha256.Sum256([]byte(content))
+	_, err = SaveUploadedFileInfo(WithExpectedSHA256(context.Background(), base64.StdEncoding.EncodeToString(sum[:])), newFileHeader(t, "b64.bin", content), filepath.Join(dir, "b64.bin"))
+	require.NoError(t, err)
+
+	// 不一致：文件不落到最终位置、不记录摘要、不留临时文件
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.PUT("/files/:name", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		saved, err := SaveRequestBodyStreamInfo(ctx, c, filepath.Join(dir, "put", c.Param("name")))
+		if err != nil {
+			return err
+		}
+		c.JSON(200, Success(saved))
+		return nil
+	}))
+	w := ut.PerformRequest(engine, "PUT", "/files/bad.bin", &ut.Body{Body: strings.NewReader(content), Len: len(content)},
+		ut.Header{Key: HeaderContentSHA256, Value: sha256Hex("something else")})
+	assert.Equal(t, 422, w.Code)
+	var result Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, int(ChecksumMismatch), result.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "put", "bad.bin"))
+	entries, _ := os.ReadDir(filepath.Join(dir, "put"))
+	assert.Empty(t, entries)
+	_, err = meta.LoadDigests(context.Background(), filepath.Join(dir, "put", "bad.bin"))
+	assert.ErrorIs(t, err, ErrDigestNotFound)
+
+	w = ut.PerformRequest(engine, "PUT", "/files/good.bin", &ut.Body{Body: strings.NewReader(content), Len: len(content)},
+		ut.Header{Key: HeaderContentSHA256, Value: sha256Hex(content)})
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), sha256Hex(content))
+
+	w = ut.PerformRequest(engine, "PUT", "/files/x.bin", &ut.Body{Body: strings.NewReader(content), Len: len(content)},
+		ut.Header{Key: HeaderContentSHA256, Value: "not-a-digest"})
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestChecksum_ChunkedUploadMatchesSinglePass(t *testing.T) {
+	dir := t.TempDir()
+	files := diskStore{dir: filepath.Join(dir, "store")}
+	meta := NewMemoryDigestStore()
+	setChecksums(t, ChecksumConfig{MD5: true}, files, meta)
+	ctx := context.Background()
+	content := strings.Repeat("0123456789abcdef", 4096) + "tail"
+
+	single, err := SaveUploadedFileInfo(ctx, newFileHeader(t, "fw.bin", content), filepath.Join(dir, "single.bin"))
+	require.NoError(t, err)
+
+	parts := []string{"parts/fw/0", "parts/fw/1", "parts/fw/2"}
+	files.put(t, parts[0], content[:10000])
+	files.put(t, parts[1], content[10000:50000])
+	files.put(t, parts[2], content[50000:])
+
+	// 整体摘要不一致：合并结果被删除，分片保留以便重试
+	_, err = CompleteChunkedUpload(ctx, "fw.bin", parts, sha256Hex("other"))
+	var mismatch *ErrChecksumMismatch
+	require.True(t, errors.As(err, &mismatch))
+	assert.NoFileExists(t, filepath.Join(dir, "store", "fw.bin"))
+	assert.FileExists(t, filepath.Join(dir, "store", parts[0]))
+
+	chunked, err := CompleteChunkedUpload(ctx, "fw.bin", parts, single.Digests.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, single.Digests, chunked)
+	assert.Equal(t, sha256Hex(content), chunked.SHA256)
+	assert.NoFileExists(t, filepath.Join(dir, "store", parts[0]))
+	recorded, err := meta.LoadDigests(ctx, "fw.bin")
+	require.NoError(t, err)
+	assert.Equal(t, chunked, recorded)
+}
+
+func TestChecksum_AuditFlagsCorruptedFile(t *testing.T) {
+	dir := t.TempDir()
+	files := diskStore{dir: dir}
+	meta := NewMemoryDigestStore()
+	setChecksums(t, ChecksumConfig{}, files, meta)
+	ctx := context.Background()
+
+	for _, key := range []string{"a.bin", "b.bin"} {
+		files.put(t, "parts/"+key, "artifact "+key)
+		_, err := CompleteChunkedUpload(ctx, key, []string{"parts/" + key}, "")
+		require.NoError(t, err)
+	}
+	audit := ChecksumAuditTask(func(ctx context.Context) ([]string, error) {
+		return []string{"a.bin", "b.bin"}, nil
+	})
+	require.NoError(t, audit(ctx))
+
+	// 同样长度的内容被篡改
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.bin"), []byte("artifact B.bin"), 0o644))
+	_, err := VerifyStoredFile(ctx, "a.bin")
+	require.NoError(t, err)
+	_, err = VerifyStoredFile(ctx, "b.bin")
+	var mismatch *ErrChecksumMismatch
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "b.bin", mismatch.Key)
+	assert.Equal(t, sha256Hex("artifact b.bin"), mismatch.Expected)
+
+	before := checksumMismatches["audit"].Value()
+	err = audit(ctx)
+	assert.ErrorContains(t, err, "1 个损坏")
+	assert.Equal(t, int64(1), checksumMismatches["audit"].Value()-before)
+
+	_, err = VerifyStoredFile(ctx, "missing.bin")
+	assert.ErrorIs(t, err, ErrDigestNotFound)
+}
+
+func TestChecksum_DownloadDigestHeaders(t *testing.T) {
+	dir := t.TempDir()
+	meta := NewMemoryDigestStore()
+	setChecksums(t, ChecksumConfig{DownloadHeader: DigestHeaderBoth}, nil, meta)
+	path := filepath.Join(dir, "report.bin")
+	content := "hello digest"
+	_, err := SaveUploadedFileInfo(context.Background(), newFileHeader(t, "report.bin", content), path)
+	require.NoError(t, err)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/download", func(ctx context.Context, c *app.RequestContext) {
+		DownloadWithRange(c, path, "report.bin")
+	})
+	sum := sha256.Sum256([]byte(content))
+	want := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	w := ut.PerformRequest(engine, "GET", "/download", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"`+sha256Hex(content)+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, want, w.Header().Get("Content-Digest"))
+
+	// Range 响应体不是完整文件：只输出 Repr-Digest
+	w = ut.PerformRequest(engine, "GET", "/download", nil, ut.Header{Key: "Range", Value: "bytes=0-4"})
+	assert.Equal(t, 206, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Digest"))
+	assert.Equal(t, want, w.Header().Get("Repr-Digest"))
+}
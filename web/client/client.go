@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/common/governor"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/CenJIl/base/web/signing"
+	"github.com/google/uuid"
+)
+
+// RequestHook 请求发出前的钩子（签名、追加请求头等）
+//
+// body 为已序列化的请求体，钩子不应再修改它
+type RequestHook func(req *http.Request, body []byte) error
+
+// Client 调用其他服务的 JSON 客户端
+//
+// 按 web.Result 的统一响应格式解析：code != 0 时返回 *APIError，否则把 data 解码到结果中
+type Client struct {
+	baseURL string
+	http    *http.Client
+	hooks   []RequestHook
+
+	governor string // 出站限速器名（空为不限速）
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithTimeout 设置请求超时（默认 10 秒）
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.http.Timeout = d }
+}
+
+// WithHTTPClient 使用自定义的 http.Client（代理、测试用 Transport；连接池调优见 WithTransportConfig）
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRequestHook 追加请求钩子
+func WithRequestHook(hook RequestHook) Option {
+	return func(c *Client) { c.hooks = append(c.hooks, hook) }
+}
+
+// WithGovernor 每个请求先在全局出站限速器组中等待 name 的令牌；
+// 对端返回 429 时按 Retry-After 暂停该限速器（adaptive 限速器同时收紧速率）
+//
+// 使用方式：
+//
+//	partner := client.New("https://api.partner-x.com", client.WithGovernor("partnerX"))
+func WithGovernor(name string) Option {
+	return func(c *Client) { c.governor = name }
+}
+
+// WithServiceSigner 对所有请求做服务间签名（配合 web.ServiceAuthMiddleware 使用）
+//
+// 使用方式：
+//
+//	orders := client.New("http://orders.internal", client.WithServiceSigner("billing", []byte(secret)))
+func WithServiceSigner(serviceID string, key []byte) Option {
+	return WithRequestHook(func(req *http.Request, body []byte) error {
+		signRequest(req, body, serviceID, key)
+		return nil
+	})
+}
+
+// WithKeyringSigner 用默认密钥环中 keyName 的当前版本做服务间签名（密钥轮换后自动使用新版本）
+//
+// 使用方式：
+//
+//	orders := client.New("http://orders.internal", client.WithKeyringSigner("billing", "svc-billing"))
+func WithKeyringSigner(serviceID, keyName string) Option {
+	return WithRequestHook(func(req *http.Request, body []byte) error {
+		kr := keyring.Default()
+		if kr == nil {
+			return fmt.Errorf("%w: %s", keyring.ErrUnknownKey, keyName)
+		}
+		key, err := kr.Secret(keyName)
+		if err != nil {
+			return err
+		}
+		signRequest(req, body, serviceID, key)
+		return nil
+	})
+}
+
+func signRequest(req *http.Request, body []byte, serviceID string, key []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.New().String()
+	req.Header.Set(signing.HeaderServiceID, serviceID)
+	req.Header.Set(signing.HeaderTimestamp, timestamp)
+	req.Header.Set(signing.HeaderNonce, nonce)
+	req.Header.Set(signing.HeaderSignature, signing.Sign(key, req.Method, req.URL.RequestURI(), body, timestamp, nonce))
+}
+
+// New 创建客户端
+//
+// 使用方式：
+//
+//	users := client.New("http://user-service:8080", client.WithTimeout(3*time.Second))
+//	user, err := client.Get[User](ctx, users, "/api/users/1")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second, Transport: DefaultTransport()},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError 对端返回的业务错误（HTTP 状态码非 2xx 或 code != 0）
+type APIError struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+	TraceID    string
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("[HTTP %d] [%d] %s", e.HTTPStatus, e.Code, e.Message)
+}
+
+// envelope 对端的统一响应格式
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	TraceID string          `json:"traceId"`
+}
+
+// Do 发送请求并把响应中的 data 解码到 out（out 为 nil 时忽略 data）
+func (c *Client) Do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		body = data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, hook := range c.hooks {
+		if err := hook(req, body); err != nil {
+			return fmt.Errorf("请求钩子失败: %w", err)
+		}
+	}
+
+	if c.governor != "" {
+		if err := governor.Wait(ctx, c.governor); err != nil {
+			return fmt.Errorf("等待出站限速 %s 失败: %w", c.governor, err)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	ledger.From(ctx).Add(ledger.HTTP, 1, time.Since(start))
+	if err != nil {
+		return fmt.Errorf("请求 %s %s 失败: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests && c.governor != "" {
+		governor.Throttled(c.governor, governor.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		if resp.StatusCode >= 300 {
+			return &APIError{HTTPStatus: resp.StatusCode, Code: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		}
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if resp.StatusCode >= 300 || env.Code != 0 {
+		return &APIError{HTTPStatus: resp.StatusCode, Code: env.Code, Message: env.Message, TraceID: env.TraceID}
+	}
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return fmt.Errorf("解析响应数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get 发送 GET 请求并解码 data
+func Get[T any](ctx context.Context, c *Client, path string) (T, error) {
+	var out T
+	err := c.Do(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// Post 发送 POST 请求并解码 data
+func Post[T any](ctx context.Context, c *Client, path string, in any) (T, error) {
+	var out T
+	err := c.Do(ctx, http.MethodPost, path, in, &out)
+	return out, err
+}
+
+// Put 发送 PUT 请求并解码 data
+func Put[T any](ctx context.Context, c *Client, path string, in any) (T, error) {
+	var out T
+	err := c.Do(ctx, http.MethodPut, path, in, &out)
+	return out, err
+}
+
+// Delete 发送 DELETE 请求
+func Delete(ctx context.Context, c *Client, path string) error {
+	return c.Do(ctx, http.MethodDelete, path, nil, nil)
+}
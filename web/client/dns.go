@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+)
+
+// Resolver 域名解析接口（*net.Resolver 满足此接口，服务发现集成可替换）
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ResolverFunc 函数适配器
+type ResolverFunc func(ctx context.Context, host string) ([]string, error)
+
+// LookupHost 实现 Resolver 接口
+func (f ResolverFunc) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return f(ctx, host)
+}
+
+// dnsLookupTimeout 单次解析的超时（解析不绑定调用方的 ctx，避免一个请求取消后其他等待者拿到取消错误）
+const dnsLookupTimeout = 5 * time.Second
+
+// DNSCache 带 TTL 的 DNS 缓存
+//
+// 同一个域名同时只解析一次；解析失败的结果缓存 negativeTTL；
+// 临近过期（剩余不足 TTL 的 1/5）时被访问的热点域名在后台提前刷新，调用方继续使用旧结果
+type DNSCache struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       common.Clock
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	lookups atomic.Int64
+}
+
+type dnsEntry struct {
+	addrs      []string
+	err        error
+	expires    time.Time
+	ready      chan struct{} // 首次解析完成后关闭
+	refreshing bool
+}
+
+// NewDNSCache 创建 DNS 缓存（resolver 为 nil 时使用 net.DefaultResolver）
+func NewDNSCache(resolver Resolver, ttl, negativeTTL time.Duration, clock common.Clock) *DNSCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &DNSCache{
+		resolver:    resolver,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		clock:       clock,
+		entries:     make(map[string]*dnsEntry),
+	}
+}
+
+// LookupHost 解析域名（IP 直接返回）；等待解析时响应 ctx 取消
+func (d *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	now := d.clock.Now()
+	d.mu.Lock()
+	e, ok := d.entries[host]
+	if ok {
+		select {
+		case <-e.ready:
+			if now.Before(e.expires) {
+				if e.err == nil && !e.refreshing && e.expires.Sub(now) < d.ttl/5 {
+					e.refreshing = true
+					go d.refresh(host, e)
+				}
+				d.mu.Unlock()
+				d.hits.Add(1)
+				return e.addrs, e.err
+			}
+			// 已过期：重新解析
+			ok = false
+		default:
+			// 解析中：等待同一次结果
+		}
+	}
+	if !ok {
+		e = &dnsEntry{ready: make(chan struct{})}
+		d.entries[host] = e
+		go d.resolve(host, e)
+	}
+	d.mu.Unlock()
+	d.misses.Add(1)
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve 首次解析
+func (d *DNSCache) resolve(host string, e *dnsEntry) {
+	addrs, err := d.lookup(host)
+	d.mu.Lock()
+	e.addrs, e.err = addrs, err
+	e.expires = d.expiry(err)
+	d.mu.Unlock()
+	close(e.ready)
+}
+
+// refresh 后台提前刷新；失败时保留旧结果直到过期
+func (d *DNSCache) refresh(host string, e *dnsEntry) {
+	addrs, err := d.lookup(host)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e.refreshing = false
+	if err != nil {
+		return
+	}
+	fresh := &dnsEntry{addrs: addrs, expires: d.expiry(nil), ready: make(chan struct{})}
+	close(fresh.ready)
+	if d.entries[host] == e {
+		d.entries[host] = fresh
+	}
+}
+
+func (d *DNSCache) lookup(host string) ([]string, error) {
+	d.lookups.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	return d.resolver.LookupHost(ctx, host)
+}
+
+func (d *DNSCache) expiry(err error) time.Time {
+	if err != nil {
+		return d.clock.Now().Add(d.negativeTTL)
+	}
+	return d.clock.Now().Add(d.ttl)
+}
+
+// DNSStats DNS 缓存统计
+type DNSStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Lookups int64   `json:"lookups"` // 实际发往解析器的次数（含后台刷新）
+	HitRate float64 `json:"hitRate"`
+}
+
+// Stats 当前统计
+func (d *DNSCache) Stats() DNSStats {
+	s := DNSStats{Hits: d.hits.Load(), Misses: d.misses.Load(), Lookups: d.lookups.Load()}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits) / float64(total)
+	}
+	return s
+}
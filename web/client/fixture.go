@@ -0,0 +1,536 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/signing"
+)
+
+// FixtureMode 录制/回放模式
+type FixtureMode string
+
+const (
+	ModeReplay FixtureMode = "replay" // 从 fixture 文件回放（默认）
+	ModeRecord FixtureMode = "record" // 调用真实服务并录制到 fixture 文件
+	ModeOff    FixtureMode = "off"    // 直连真实服务，不读写 fixture
+)
+
+// EnvFixtureMode 选择模式的环境变量，同一份测试代码可在录制和回放之间切换
+//
+//	CLIENT_FIXTURE_MODE=record go test ./...
+const EnvFixtureMode = "CLIENT_FIXTURE_MODE"
+
+// Redacted 脱敏后的占位值
+const Redacted = "[REDACTED]"
+
+// RedactRules 脱敏规则（录制时生效，匹配时同样作用于实际请求，保证两边一致）
+type RedactRules struct {
+	Headers     []string // 值替换为 [REDACTED] 的请求/响应头
+	DropHeaders []string // 不写入 fixture 的易变头
+	BodyFields  []string // JSON 请求/响应体中值替换为 [REDACTED] 的字段（任意层级，不区分大小写）
+	QueryParams []string // 值替换为 [REDACTED] 的查询参数
+}
+
+// DefaultRedactRules 默认脱敏规则：认证信息、签名和常见的密钥字段
+func DefaultRedactRules() RedactRules {
+	return RedactRules{
+		Headers:     []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", signing.HeaderSignature},
+		DropHeaders: []string{"Date", "Content-Length", "X-Request-Id", signing.HeaderTimestamp, signing.HeaderNonce},
+		BodyFields:  []string{"password", "token", "accessToken", "refreshToken", "secret", "apiKey"},
+		QueryParams: []string{"token", "access_token", "api_key"},
+	}
+}
+
+// FixtureConfig 录制/回放配置
+type FixtureConfig struct {
+	Dir            string            // fixture 目录，如 "testdata/fixtures"
+	Name           string            // fixture 文件名（不含扩展名）
+	Mode           FixtureMode       // 为空时读取 CLIENT_FIXTURE_MODE，仍为空则回放
+	Strict         bool              // 回放结束时存在未使用的 fixture 视为失败
+	Redact         *RedactRules      // 为 nil 时使用 DefaultRedactRules
+	VolatileFields []string          // 匹配时忽略的 JSON 字段和查询参数（时间戳、请求 ID 等）
+	Upstream       http.RoundTripper // 录制/直连时使用的真实 Transport，默认 http.DefaultTransport
+}
+
+// Fixture 一次录制的请求与响应
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest 录制的请求
+type FixtureRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   map[string][]string `json:"query,omitempty"`
+	Headers map[string]string   `json:"headers,omitempty"`
+	Body    json.RawMessage     `json:"body,omitempty"`    // JSON 请求体（格式化保存）
+	Text    string              `json:"text,omitempty"`    // 非 JSON 请求体
+	AnyBody bool                `json:"anyBody,omitempty"` // 手工标记：忽略请求体，作为同路径请求的兜底
+}
+
+// FixtureResponse 录制的响应
+type FixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Text    string            `json:"text,omitempty"`
+}
+
+// matchKey 请求匹配键：method + path + 排序后的查询参数 + 请求体哈希
+type matchKey struct {
+	method string
+	path   string
+	query  string
+	body   string
+}
+
+// loadedFixture 回放中的 fixture 及使用次数
+type loadedFixture struct {
+	Fixture
+	key  matchKey
+	used int
+}
+
+// Recorder 录制/回放 Transport
+//
+// 匹配优先级：
+//  1. method、path、查询参数、请求体完全一致且未使用过的 fixture（按录制顺序）
+//  2. 完全一致但已使用过的 fixture（重复返回最后一条）
+//  3. 标记 anyBody 的同 method、path、查询参数的 fixture
+//
+// 使用方式：
+//
+//	rec, err := client.NewRecorder(client.FixtureConfig{Dir: "testdata/fixtures", Name: "orders"})
+//	orders := client.New("http://orders.internal", client.WithFixtures(rec))
+//	defer rec.Close()
+type Recorder struct {
+	cfg    FixtureConfig
+	mode   FixtureMode
+	redact RedactRules
+	path   string
+
+	mu       sync.Mutex
+	fixtures []*loadedFixture
+	recorded []Fixture
+}
+
+// NewRecorder 创建录制/回放 Transport（回放模式下 fixture 文件必须存在）
+func NewRecorder(cfg FixtureConfig) (*Recorder, error) {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = FixtureMode(os.Getenv(EnvFixtureMode))
+	}
+	if mode == "" {
+		mode = ModeReplay
+	}
+	if mode != ModeReplay && mode != ModeRecord && mode != ModeOff {
+		return nil, fmt.Errorf("未知的 fixture 模式: %q", mode)
+	}
+	if cfg.Upstream == nil {
+		cfg.Upstream = http.DefaultTransport
+	}
+	redact := DefaultRedactRules()
+	if cfg.Redact != nil {
+		redact = *cfg.Redact
+	}
+
+	r := &Recorder{
+		cfg:    cfg,
+		mode:   mode,
+		redact: redact,
+		path:   filepath.Join(cfg.Dir, cfg.Name+".json"),
+	}
+	if mode != ModeReplay {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 fixture 失败（先用 %s=record 录制）: %w", EnvFixtureMode, err)
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("解析 fixture %s 失败: %w", r.path, err)
+	}
+	for _, f := range fixtures {
+		body := []byte(f.Request.Body)
+		if f.Request.Text != "" {
+			body = []byte(f.Request.Text)
+		}
+		r.fixtures = append(r.fixtures, &loadedFixture{
+			Fixture: f,
+			key:     r.key(f.Request.Method, f.Request.Path, url.Values(f.Request.Query), body),
+		})
+	}
+	return r, nil
+}
+
+// Mode 当前模式
+func (r *Recorder) Mode() FixtureMode {
+	return r.mode
+}
+
+// WithFixtures 通过录制/回放 Transport 发送请求
+func WithFixtures(r *Recorder) Option {
+	return func(c *Client) {
+		c.http = &http.Client{Timeout: c.http.Timeout, Transport: r}
+	}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	switch r.mode {
+	case ModeOff:
+		return r.cfg.Upstream.RoundTrip(req)
+	case ModeRecord:
+		return r.record(req, body)
+	default:
+		return r.replay(req, body)
+	}
+}
+
+// record 调用真实服务并记录（脱敏后）
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.cfg.Upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	f := Fixture{
+		Request: FixtureRequest{
+			Method:  req.Method,
+			Path:    req.URL.Path,
+			Query:   r.redactQuery(req.URL.Query()),
+			Headers: r.redactHeaders(req.Header),
+		},
+		Response: FixtureResponse{
+			Status:  resp.StatusCode,
+			Headers: r.redactHeaders(resp.Header),
+		},
+	}
+	f.Request.Body, f.Request.Text = r.redactBody(body)
+	f.Response.Body, f.Response.Text = r.redactBody(respBody)
+
+	r.mu.Lock()
+	r.recorded = append(r.recorded, f)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay 按匹配优先级返回 fixture 中的响应
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	key := r.key(req.Method, req.URL.Path, req.URL.Query(), body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var repeat, fallback *loadedFixture
+	for _, f := range r.fixtures {
+		switch {
+		case f.key == key && f.used == 0:
+			return r.respond(req, f), nil
+		case f.key == key:
+			repeat = f
+		case fallback == nil && f.Request.AnyBody && f.key.method == key.method && f.key.path == key.path && f.key.query == key.query:
+			fallback = f
+		}
+	}
+	if repeat != nil {
+		return r.respond(req, repeat), nil
+	}
+	if fallback != nil {
+		return r.respond(req, fallback), nil
+	}
+	return nil, r.noMatch(key)
+}
+
+// respond 生成回放响应（调用方持锁）
+func (r *Recorder) respond(req *http.Request, f *loadedFixture) *http.Response {
+	f.used++
+	header := http.Header{}
+	for k, v := range f.Response.Headers {
+		header.Set(k, v)
+	}
+	body := []byte(f.Response.Body)
+	if f.Response.Text != "" {
+		body = []byte(f.Response.Text)
+	}
+	return &http.Response{
+		StatusCode:    f.Response.Status,
+		Status:        fmt.Sprintf("%d %s", f.Response.Status, http.StatusText(f.Response.Status)),
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// noMatch 构造未命中的错误，附带最接近的 fixture 及差异（调用方持锁）
+func (r *Recorder) noMatch(key matchKey) error {
+	var closest *loadedFixture
+	best := -1
+	for _, f := range r.fixtures {
+		score := 0
+		if f.key.path == key.path {
+			score += 8
+		}
+		if f.key.method == key.method {
+			score += 4
+		}
+		if f.key.query == key.query {
+			score += 2
+		}
+		if f.key.body == key.body {
+			score++
+		}
+		if score > best {
+			best, closest = score, f
+		}
+	}
+
+	msg := fmt.Sprintf("fixture %s 中没有匹配 %s %s?%s (body %s) 的记录", r.path, key.method, key.path, key.query, key.body)
+	if closest == nil {
+		return fmt.Errorf("%s；fixture 为空", msg)
+	}
+	var diffs []string
+	if closest.key.method != key.method {
+		diffs = append(diffs, "method "+closest.key.method)
+	}
+	if closest.key.path != key.path {
+		diffs = append(diffs, "path "+closest.key.path)
+	}
+	if closest.key.query != key.query {
+		diffs = append(diffs, "query "+closest.key.query)
+	}
+	if closest.key.body != key.body {
+		diffs = append(diffs, "body "+closest.key.body)
+	}
+	return fmt.Errorf("%s；最接近的是 %s %s（不同之处: %s）", msg, closest.key.method, closest.key.path, strings.Join(diffs, ", "))
+}
+
+// Unused 未被使用的 fixture（method path?query）
+func (r *Recorder) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []string
+	for _, f := range r.fixtures {
+		if f.used == 0 {
+			unused = append(unused, fmt.Sprintf("%s %s?%s", f.key.method, f.key.path, f.key.query))
+		}
+	}
+	return unused
+}
+
+// Close 结束录制/回放：录制模式写入 fixture 文件；严格回放模式检查未使用的 fixture
+func (r *Recorder) Close() error {
+	switch r.mode {
+	case ModeRecord:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		data, err := json.MarshalIndent(r.recorded, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化 fixture 失败: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+			return fmt.Errorf("创建 fixture 目录失败: %w", err)
+		}
+		return os.WriteFile(r.path, append(data, '\n'), 0644)
+	case ModeReplay:
+		if unused := r.Unused(); r.cfg.Strict && len(unused) > 0 {
+			return fmt.Errorf("fixture %s 中有 %d 条记录未被使用（已失效的桩）: %s", r.path, len(unused), strings.Join(unused, "; "))
+		}
+	}
+	return nil
+}
+
+// key 计算匹配键：先脱敏，再去掉易变字段
+func (r *Recorder) key(method, path string, query url.Values, body []byte) matchKey {
+	q := url.Values{}
+	for k, v := range r.redactQuery(query) {
+		if !r.volatile(k) {
+			q[k] = v
+		}
+	}
+
+	var bodyHash string
+	if len(bytes.TrimSpace(body)) > 0 {
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err == nil {
+			v = redactJSON(v, r.redact.BodyFields)
+			v = dropJSON(v, r.cfg.VolatileFields)
+			body, _ = json.Marshal(v)
+		}
+		sum := sha256.Sum256(body)
+		bodyHash = hex.EncodeToString(sum[:8])
+	}
+	return matchKey{method: strings.ToUpper(method), path: path, query: q.Encode(), body: bodyHash}
+}
+
+func (r *Recorder) volatile(name string) bool {
+	for _, f := range r.cfg.VolatileFields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) redactHeaders(h http.Header) map[string]string {
+	out := map[string]string{}
+	for k := range h {
+		switch {
+		case containsFold(r.redact.DropHeaders, k):
+		case containsFold(r.redact.Headers, k):
+			out[http.CanonicalHeaderKey(k)] = Redacted
+		default:
+			out[http.CanonicalHeaderKey(k)] = h.Get(k)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func (r *Recorder) redactQuery(q url.Values) url.Values {
+	if len(q) == 0 {
+		return nil
+	}
+	out := url.Values{}
+	for k, v := range q {
+		if containsFold(r.redact.QueryParams, k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redactBody 脱敏后的请求/响应体：JSON 格式化保存，其它按文本保存
+func (r *Recorder) redactBody(body []byte) (json.RawMessage, string) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, ""
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, string(body)
+	}
+	data, _ := json.Marshal(redactJSON(v, r.redact.BodyFields))
+	return data, ""
+}
+
+// redactJSON 把任意层级的指定字段替换为 [REDACTED]
+func redactJSON(v any, fields []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if containsFold(fields, k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = redactJSON(child, fields)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = redactJSON(child, fields)
+		}
+	}
+	return v
+}
+
+// dropJSON 删除任意层级的易变字段
+func dropJSON(v any, fields []string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if containsFold(fields, k) {
+				delete(t, k)
+				continue
+			}
+			t[k] = dropJSON(child, fields)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = dropJSON(child, fields)
+		}
+	}
+	return v
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// testingT testing.TB 的子集（避免在非测试代码中引入 testing 包）
+type testingT interface {
+	Helper()
+	Name() string
+	Cleanup(func())
+	Fatalf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// UseFixtures 在测试中启用录制/回放：fixture 文件名取自测试名，测试结束时自动 Close
+//
+// 使用方式：
+//
+//	func TestCreateInvoice(t *testing.T) {
+//	    orders := client.New("http://orders.internal",
+//	        client.UseFixtures(t, client.FixtureConfig{Dir: "testdata/fixtures", Strict: true}))
+//	    ...
+//	}
+func UseFixtures(t testingT, cfg FixtureConfig) Option {
+	t.Helper()
+	if cfg.Name == "" {
+		cfg.Name = strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	}
+	rec, err := NewRecorder(cfg)
+	if err != nil {
+		t.Fatalf("client fixtures: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := rec.Close(); err != nil {
+			t.Errorf("client fixtures: %v", err)
+		}
+	})
+	return WithFixtures(rec)
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtures_RecordThenReplay(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Write([]byte(`{"code":0,"message":"success","data":{"id":7,"token":"issued-secret","name":"Alice"}}`))
+	}))
+	dir := t.TempDir()
+	auth := WithRequestHook(func(req *http.Request, body []byte) error {
+		req.Header.Set("Authorization", "Bearer live-credential")
+		return nil
+	})
+	type login struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+		SentAt   int64  `json:"sentAt"`
+	}
+	cfg := FixtureConfig{Dir: dir, Name: "users", VolatileFields: []string{"sentAt", "ts"}}
+
+	// 录制
+	cfg.Mode = ModeRecord
+	rec, err := NewRecorder(cfg)
+	require.NoError(t, err)
+	c := New(upstream.URL, WithFixtures(rec), auth)
+	got, err := Post[map[string]any](context.Background(), c, "/api/login?ts=1&token=abc", login{"alice", "hunter2", 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", got["name"])
+	require.NoError(t, rec.Close())
+	upstream.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "users.json"))
+	require.NoError(t, err)
+	content := string(data)
+	for _, secret := range []string{"hunter2", "live-credential", "issued-secret", "abc", "req-123"} {
+		assert.NotContains(t, content, secret)
+	}
+	assert.Contains(t, content, "\n      \"method\": \"POST\"") // 格式化保存
+	assert.Contains(t, content, `"password": "[REDACTED]"`)
+
+	// 回放：上游已关闭，易变字段不同也能命中
+	cfg.Mode = ModeReplay
+	cfg.Strict = true
+	rec, err = NewRecorder(cfg)
+	require.NoError(t, err)
+	c = New(upstream.URL, WithFixtures(rec), auth)
+	got, err = Post[map[string]any](context.Background(), c, "/api/login?ts=99&token=other", login{"alice", "different", time.Now().Unix()})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", got["name"])
+	assert.Equal(t, "[REDACTED]", got["token"])
+	assert.NoError(t, rec.Close())
+	assert.Equal(t, 1, calls)
+
+	// 非易变字段不同则不命中
+	rec, err = NewRecorder(cfg)
+	require.NoError(t, err)
+	c = New(upstream.URL, WithFixtures(rec))
+	_, err = Post[map[string]any](context.Background(), c, "/api/login", login{"bob", "x", 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "最接近的是 POST /api/login")
+	assert.Contains(t, err.Error(), "body ")
+}
+
+// writeFixtures 手工编写 fixture 文件
+func writeFixtures(t *testing.T, fixtures []Fixture) string {
+	t.Helper()
+	dir := t.TempDir()
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manual.json"), data, 0644))
+	return dir
+}
+
+func okResponse(v string) FixtureResponse {
+	return FixtureResponse{Status: 200, Body: json.RawMessage(`{"code":0,"data":"` + v + `"}`)}
+}
+
+func TestFixtures_MatcherPrecedence(t *testing.T) {
+	dir := writeFixtures(t, []Fixture{
+		{Request: FixtureRequest{Method: "GET", Path: "/items", Query: map[string][]string{"b": {"2"}, "a": {"1"}}}, Response: okResponse("first")},
+		{Request: FixtureRequest{Method: "GET", Path: "/items", Query: map[string][]string{"a": {"1"}, "b": {"2"}}}, Response: okResponse("second")},
+		{Request: FixtureRequest{Method: "POST", Path: "/items", AnyBody: true}, Response: okResponse("fallback")},
+		{Request: FixtureRequest{Method: "POST", Path: "/items", Body: json.RawMessage(`{"name":"exact"}`)}, Response: okResponse("exact")},
+	})
+	rec, err := NewRecorder(FixtureConfig{Dir: dir, Name: "manual", Mode: ModeReplay})
+	require.NoError(t, err)
+	c := New("http://inventory", WithFixtures(rec))
+	ctx := context.Background()
+
+	// 查询参数顺序无关；同一请求按录制顺序返回，用完后重复最后一条
+	for _, want := range []string{"first", "second", "second"} {
+		got, err := Get[string](ctx, c, "/items?b=2&a=1")
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// 完全匹配优先于 anyBody 兜底，兜底只在请求体不同的时候生效
+	got, err := Post[string](ctx, c, "/items", map[string]string{"name": "exact"})
+	require.NoError(t, err)
+	assert.Equal(t, "exact", got)
+	got, err = Post[string](ctx, c, "/items", map[string]string{"name": "other"})
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", got)
+
+	// 查询参数不同不会落到兜底
+	_, err = Get[string](ctx, c, "/items?a=1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "query a=1&b=2")
+	assert.Empty(t, rec.Unused())
+}
+
+func TestFixtures_StrictUnused(t *testing.T) {
+	dir := writeFixtures(t, []Fixture{
+		{Request: FixtureRequest{Method: "GET", Path: "/used"}, Response: okResponse("ok")},
+		{Request: FixtureRequest{Method: "DELETE", Path: "/dead"}, Response: okResponse("ok")},
+	})
+	for _, strict := range []bool{false, true} {
+		rec, err := NewRecorder(FixtureConfig{Dir: dir, Name: "manual", Mode: ModeReplay, Strict: strict})
+		require.NoError(t, err)
+		_, err = Get[string](context.Background(), New("http://svc", WithFixtures(rec)), "/used")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"DELETE /dead?"}, rec.Unused())
+		if strict {
+			err = rec.Close()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "DELETE /dead")
+		} else {
+			assert.NoError(t, rec.Close())
+		}
+	}
+}
+
+func TestFixtures_Sanitization(t *testing.T) {
+	rules := RedactRules{
+		Headers:     []string{"X-Tenant-Key"},
+		DropHeaders: []string{"X-Trace"},
+		BodyFields:  []string{"ssn"},
+		QueryParams: []string{"sig"},
+	}
+	r, err := NewRecorder(FixtureConfig{Mode: ModeOff, Redact: &rules})
+	require.NoError(t, err)
+
+	headers := r.redactHeaders(http.Header{"X-Tenant-Key": {"k"}, "X-Trace": {"t"}, "Accept": {"application/json"}})
+	assert.Equal(t, map[string]string{"X-Tenant-Key": Redacted, "Accept": "application/json"}, headers)
+
+	query := r.redactQuery(map[string][]string{"sig": {"s"}, "page": {"2"}})
+	assert.Equal(t, []string{Redacted}, query["sig"])
+	assert.Equal(t, []string{"2"}, query["page"])
+
+	// 任意层级、不区分大小写；自定义规则替换默认规则
+	body, text := r.redactBody([]byte(`{"user":{"SSN":"123","password":"kept"},"list":[{"ssn":"456"}]}`))
+	assert.Empty(t, text)
+	assert.JSONEq(t, `{"user":{"SSN":"[REDACTED]","password":"kept"},"list":[{"ssn":"[REDACTED]"}]}`, string(body))
+
+	body, text = r.redactBody([]byte("plain text"))
+	assert.Nil(t, body)
+	assert.Equal(t, "plain text", text)
+
+	// 脱敏字段不影响匹配：真实值与 fixture 中的 [REDACTED] 得到相同的键
+	assert.Equal(t, r.key("POST", "/p", nil, []byte(`{"ssn":"123"}`)), r.key("post", "/p", nil, []byte(`{"ssn":"[REDACTED]"}`)))
+	assert.NotEqual(t, r.key("POST", "/p", nil, []byte(`{"a":1}`)), r.key("POST", "/p", nil, []byte(`{"a":2}`)))
+}
+
+func TestFixtures_ModeFromEnv(t *testing.T) {
+	t.Setenv(EnvFixtureMode, "record")
+	rec, err := NewRecorder(FixtureConfig{Dir: t.TempDir(), Name: "env"})
+	require.NoError(t, err)
+	assert.Equal(t, ModeRecord, rec.Mode())
+
+	t.Setenv(EnvFixtureMode, "bogus")
+	_, err = NewRecorder(FixtureConfig{})
+	assert.Error(t, err)
+
+	t.Setenv(EnvFixtureMode, "")
+	_, err = NewRecorder(FixtureConfig{Dir: t.TempDir(), Name: "missing"})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), EnvFixtureMode))
+}
@@ -0,0 +1,372 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+)
+
+// TransportConfig 连接池与 DNS 缓存配置（零值字段使用默认值）
+//
+// Example:
+//
+//	[client]
+//	maxIdleConnsPerHost = 32   # 每个主机保留的空闲连接，默认 32
+//	maxConnsPerHost = 0        # 每个主机的最大连接数，0 表示不限
+//	idleTimeout = 90           # 空闲连接超时（秒），默认 90
+//	tlsSessionCacheSize = 64   # TLS 会话缓存条目数，默认 64
+//	dialTimeoutMs = 3000       # 建连超时（毫秒），默认 3000
+//	fallbackDelayMs = 300      # Happy Eyeballs 备用地址族的启动延迟（毫秒），默认 300，负数表示禁用
+//	[client.dns]
+//	disabled = false
+//	ttl = 30                   # 覆盖解析结果的 TTL（秒），默认 30
+//	negativeTtl = 5            # 解析失败的缓存时间（秒），默认 5
+type TransportConfig struct {
+	MaxIdleConnsPerHost int       `toml:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int       `toml:"maxConnsPerHost"`
+	IdleTimeout         int       `toml:"idleTimeout"`
+	TLSSessionCacheSize int       `toml:"tlsSessionCacheSize"`
+	DialTimeoutMs       int       `toml:"dialTimeoutMs"`
+	FallbackDelayMs     int       `toml:"fallbackDelayMs"`
+	DNS                 DNSConfig `toml:"dns"`
+
+	// Resolver 自定义解析器（服务发现集成），为 nil 时使用系统解析
+	Resolver Resolver `toml:"-"`
+}
+
+// DNSConfig DNS 缓存配置
+type DNSConfig struct {
+	Disabled    bool `toml:"disabled"`    // 关闭缓存，每次建连都解析
+	TTL         int  `toml:"ttl"`         // 秒
+	NegativeTTL int  `toml:"negativeTtl"` // 秒
+}
+
+func (c TransportConfig) withDefaults() TransportConfig {
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = 32
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 90
+	}
+	if c.TLSSessionCacheSize <= 0 {
+		c.TLSSessionCacheSize = 64
+	}
+	if c.DialTimeoutMs <= 0 {
+		c.DialTimeoutMs = 3000
+	}
+	if c.FallbackDelayMs == 0 {
+		c.FallbackDelayMs = 300
+	}
+	if c.DNS.TTL <= 0 {
+		c.DNS.TTL = 30
+	}
+	if c.DNS.NegativeTTL <= 0 {
+		c.DNS.NegativeTTL = 5
+	}
+	return c
+}
+
+// PoolStats 连接池统计
+type PoolStats struct {
+	Open       int64    `json:"open"`  // 当前打开的连接
+	InUse      int64    `json:"inUse"` // 正在处理请求的连接（按在途请求数估算）
+	Idle       int64    `json:"idle"`  // 空闲连接（open - inUse）
+	Dials      int64    `json:"dials"` // 累计建连次数
+	DialErrors int64    `json:"dialErrors"`
+	DNS        DNSStats `json:"dns"`
+}
+
+// Transport 调优后的 http.RoundTripper（连接池 + DNS 缓存 + Happy Eyeballs）
+type Transport struct {
+	name   string
+	config TransportConfig
+	http   *http.Transport
+	dns    *DNSCache // 关闭缓存时为 nil
+	dialer net.Dialer
+
+	open       atomic.Int64
+	inflight   atomic.Int64
+	dials      *metrics.Counter
+	dialErrors *metrics.Counter
+	gauges     [4]*metrics.Gauge // open, inUse, idle, dnsHitRate
+}
+
+// NewTransport 创建 Transport（name 用作指标标签）
+//
+// 使用方式：
+//
+//	t := client.NewTransport("orders", client.TransportConfig{MaxIdleConnsPerHost: 64})
+//	orders := client.New("http://orders.internal", client.WithTransport(t))
+func NewTransport(name string, config TransportConfig) *Transport {
+	config = config.withDefaults()
+	t := &Transport{
+		name:   name,
+		config: config,
+		dialer: net.Dialer{
+			Timeout:       time.Duration(config.DialTimeoutMs) * time.Millisecond,
+			FallbackDelay: time.Duration(config.FallbackDelayMs) * time.Millisecond,
+			KeepAlive:     30 * time.Second,
+		},
+		dials:      metrics.GetCounter("client_dials_total", "pool", name),
+		dialErrors: metrics.GetCounter("client_dial_errors_total", "pool", name),
+		gauges: [4]*metrics.Gauge{
+			metrics.GetGauge("client_pool_open", "pool", name),
+			metrics.GetGauge("client_pool_in_use", "pool", name),
+			metrics.GetGauge("client_pool_idle", "pool", name),
+			metrics.GetGauge("client_dns_hit_rate", "pool", name),
+		},
+	}
+	if !config.DNS.Disabled {
+		t.dns = NewDNSCache(config.Resolver, time.Duration(config.DNS.TTL)*time.Second, time.Duration(config.DNS.NegativeTTL)*time.Second, nil)
+	}
+	t.http = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           t.dialContext,
+		MaxIdleConns:          0, // 只按主机限制
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(config.IdleTimeout) * time.Second,
+		TLSClientConfig:       &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(config.TLSSessionCacheSize)},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	return t
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.inflight.Add(1)
+	t.publish()
+	resp, err := t.http.RoundTrip(req)
+	if err != nil {
+		t.inflight.Add(-1)
+		t.publish()
+		return nil, err
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, done: func() {
+		t.inflight.Add(-1)
+		t.publish()
+	}}
+	return resp, nil
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (t *Transport) CloseIdleConnections() {
+	t.http.CloseIdleConnections()
+}
+
+// Stats 连接池统计
+func (t *Transport) Stats() PoolStats {
+	s := PoolStats{
+		Open:       t.open.Load(),
+		InUse:      t.inflight.Load(),
+		Dials:      t.dials.Value(),
+		DialErrors: t.dialErrors.Value(),
+	}
+	s.Idle = max(s.Open-s.InUse, 0)
+	if t.dns != nil {
+		s.DNS = t.dns.Stats()
+	}
+	return s
+}
+
+// publish 连接或在途请求数变化时更新指标（client_pool_*、client_dns_hit_rate）
+func (t *Transport) publish() {
+	s := t.Stats()
+	t.gauges[0].Set(float64(s.Open))
+	t.gauges[1].Set(float64(s.InUse))
+	t.gauges[2].Set(float64(s.Idle))
+	t.gauges[3].Set(s.DNS.HitRate)
+}
+
+// trackedBody 响应体关闭时结束在途计数（只计一次）
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// trackedConn 关闭时减少打开连接数
+type trackedConn struct {
+	net.Conn
+	once sync.Once
+	t    *Transport
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.t.open.Add(-1)
+		c.t.publish()
+	})
+	return c.Conn.Close()
+}
+
+// dialContext 经 DNS 缓存解析后建连
+func (t *Transport) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.dials.Inc()
+	conn, err := t.dial(ctx, network, addr)
+	if err != nil {
+		t.dialErrors.Inc()
+		return nil, err
+	}
+	t.open.Add(1)
+	t.publish()
+	return &trackedConn{Conn: conn, t: t}, nil
+}
+
+func (t *Transport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if t.dns == nil {
+		if t.config.Resolver == nil {
+			return t.dialer.DialContext(ctx, network, addr)
+		}
+		addrs, err := t.config.Resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		return t.dialAddrs(ctx, network, addrs, port)
+	}
+	addrs, err := t.dns.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return t.dialAddrs(ctx, network, addrs, port)
+}
+
+// dialAddrs 按 Happy Eyeballs（RFC 8305 简化版）连接已解析的地址：
+// 首选地址族按顺序尝试，fallbackDelay 后（或首选全部失败时）并行尝试另一地址族，先成功者胜出
+func (t *Transport) dialAddrs(ctx context.Context, network string, addrs []string, port string) (net.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no addresses")
+	}
+	var primary, fallback []string
+	primaryV4 := net.ParseIP(addrs[0]).To4() != nil
+	for _, a := range addrs {
+		if (net.ParseIP(a).To4() != nil) == primaryV4 {
+			primary = append(primary, a)
+		} else {
+			fallback = append(fallback, a)
+		}
+	}
+	if len(fallback) == 0 || t.config.FallbackDelayMs < 0 {
+		return t.dialSerial(ctx, network, append(primary, fallback...), port)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, 2)
+	race := func(list []string) {
+		conn, err := t.dialSerial(ctx, network, list, port)
+		results <- result{conn, err}
+	}
+
+	go race(primary)
+	timer := time.NewTimer(time.Duration(t.config.FallbackDelayMs) * time.Millisecond)
+	defer timer.Stop()
+
+	started, pending := false, 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !started {
+				started, pending = true, pending+1
+				go race(fallback)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// 关闭另一路稍后成功的连接
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !started {
+				started, pending = true, pending+1
+				go race(fallback)
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func (t *Transport) dialSerial(ctx context.Context, network string, addrs []string, port string) (net.Conn, error) {
+	var firstErr error
+	for _, a := range addrs {
+		conn, err := t.dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+var defaultTransport atomic.Pointer[Transport]
+
+// InitTransport 设置所有客户端默认共享的 Transport（通常由 web.NewServer 按 [client] 配置调用）
+func InitTransport(config TransportConfig) {
+	defaultTransport.Store(NewTransport("default", config))
+}
+
+// DefaultTransport 默认共享的 Transport（未初始化时使用默认配置）
+func DefaultTransport() *Transport {
+	if t := defaultTransport.Load(); t != nil {
+		return t
+	}
+	defaultTransport.CompareAndSwap(nil, NewTransport("default", TransportConfig{}))
+	return defaultTransport.Load()
+}
+
+// WithTransport 使用指定的 Transport（独立的连接池与 DNS 缓存）
+func WithTransport(t *Transport) Option {
+	return func(c *Client) { c.http.Transport = t }
+}
+
+// WithTransportConfig 按配置为该客户端创建独立的 Transport
+//
+// 使用方式：
+//
+//	search := client.New("http://search.internal", client.WithTransportConfig("search", client.TransportConfig{
+//	    MaxIdleConnsPerHost: 128,
+//	    Resolver:            consulResolver,
+//	}))
+func WithTransportConfig(name string, config TransportConfig) Option {
+	return WithTransport(NewTransport(name, config))
+}
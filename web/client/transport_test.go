@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingResolver 把所有域名解析到固定地址并计数
+type countingResolver struct {
+	addrs []string
+	err   error
+	calls atomic.Int64
+	block chan struct{} // 非 nil 时解析阻塞到关闭
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.calls.Add(1)
+	if r.block != nil {
+		<-r.block
+	}
+	return r.addrs, r.err
+}
+
+func TestDNSCache_TTLAndRefreshAhead(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := &countingResolver{addrs: []string{"10.0.0.1"}}
+	d := NewDNSCache(r, 30*time.Second, 5*time.Second, clock)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		addrs, err := d.LookupHost(ctx, "orders.internal")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	}
+	assert.Equal(t, int64(1), r.calls.Load())
+
+	// 剩余不足 1/5 TTL 时命中：继续返回旧结果并在后台刷新
+	clock.Advance(25 * time.Second)
+	r.addrs = []string{"10.0.0.2"}
+	addrs, err := d.LookupHost(ctx, "orders.internal")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, addrs)
+	require.Eventually(t, func() bool {
+		addrs, _ := d.LookupHost(ctx, "orders.internal")
+		return len(addrs) == 1 && addrs[0] == "10.0.0.2"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(2), r.calls.Load())
+
+	// 刷新后的条目在原过期时间之后仍然有效
+	clock.Advance(10 * time.Second)
+	_, err = d.LookupHost(ctx, "orders.internal")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), r.calls.Load())
+
+	// IP 不经过解析
+	addrs, err = d.LookupHost(ctx, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1"}, addrs)
+
+	s := d.Stats()
+	assert.Equal(t, int64(2), s.Lookups)
+	assert.Greater(t, s.HitRate, 0.5)
+}
+
+func TestDNSCache_NegativeCaching(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := &countingResolver{err: errors.New("no such host")}
+	d := NewDNSCache(r, 30*time.Second, 5*time.Second, clock)
+
+	for i := 0; i < 3; i++ {
+		_, err := d.LookupHost(context.Background(), "missing.internal")
+		assert.EqualError(t, err, "no such host")
+	}
+	assert.Equal(t, int64(1), r.calls.Load())
+
+	clock.Advance(6 * time.Second)
+	r.err, r.addrs = nil, []string{"10.0.0.9"}
+	addrs, err := d.LookupHost(context.Background(), "missing.internal")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.9"}, addrs)
+	assert.Equal(t, int64(2), r.calls.Load())
+}
+
+func TestDNSCache_ContextCancellation(t *testing.T) {
+	r := &countingResolver{addrs: []string{"10.0.0.1"}, block: make(chan struct{})}
+	d := NewDNSCache(r, 30*time.Second, 5*time.Second, nil)
+
+	// 等待者取消不影响同一次解析的其他等待者
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	var waited []string
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		waited, _ = d.LookupHost(context.Background(), "slow.internal")
+	}()
+
+	start := time.Now()
+	_, err := d.LookupHost(ctx, "slow.internal")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(r.block)
+	wg.Wait()
+	assert.Equal(t, []string{"10.0.0.1"}, waited)
+	assert.Equal(t, int64(1), r.calls.Load())
+}
+
+// newLoadServer 本地测试服务，返回服务端口
+func newLoadServer(t testing.TB) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code":0,"data":"ok"}`))
+	}))
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return port
+}
+
+// sustainedLoad 并发 workers 个请求方，每个发送 n 个请求
+func sustainedLoad(t testing.TB, rt http.RoundTripper, url string, workers, n int) {
+	hc := &http.Client{Transport: rt, Timeout: 5 * time.Second}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				resp, err := hc.Get(url)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// baselineTransport 不复用连接的 Transport（每个请求都建连、都解析，次数确定，不受调度影响）
+func baselineTransport(r Resolver, dials *atomic.Int64) *http.Transport {
+	return &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			host, port, _ := net.SplitHostPort(addr)
+			addrs, err := r.LookupHost(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, net.JoinHostPort(addrs[0], port))
+		},
+	}
+}
+
+func TestTransport_SustainedLoadReusesConnections(t *testing.T) {
+	url := "http://svc.internal:" + newLoadServer(t) + "/"
+	const workers, n = 16, 50
+
+	baseResolver := &countingResolver{addrs: []string{"127.0.0.1"}}
+	var baseDials atomic.Int64
+	base := baselineTransport(baseResolver, &baseDials)
+	sustainedLoad(t, base, url, workers, n)
+	base.CloseIdleConnections()
+
+	r := &countingResolver{addrs: []string{"127.0.0.1"}}
+	tuned := NewTransport("test-load", TransportConfig{Resolver: r})
+	before := tuned.Stats().Dials // 指标是全局的（-count 多次运行时累计），比较增量
+	sustainedLoad(t, tuned, url, workers, n)
+
+	s := tuned.Stats()
+	s.Dials -= before
+	t.Logf("baseline: %d dials, %d lookups; tuned: %d dials, %d lookups (hit rate %.2f)",
+		baseDials.Load(), baseResolver.calls.Load(), s.Dials, r.calls.Load(), s.DNS.HitRate)
+	assert.Equal(t, int64(workers*n), baseDials.Load())
+	assert.LessOrEqual(t, s.Dials, int64(workers))
+	assert.Equal(t, int64(1), r.calls.Load())
+	assert.Less(t, r.calls.Load(), baseResolver.calls.Load())
+	assert.Equal(t, int64(0), s.InUse)
+	assert.Equal(t, s.Open, s.Idle)
+
+	tuned.CloseIdleConnections()
+	require.Eventually(t, func() bool { return tuned.Stats().Open == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestTransport_HappyEyeballsFallback(t *testing.T) {
+	port := newLoadServer(t)
+	// 首选地址族（IPv6 保留地址）不可达，备用地址族成功
+	r := &countingResolver{addrs: []string{"100::1", "127.0.0.1"}}
+	tr := NewTransport("test-fallback", TransportConfig{Resolver: r, FallbackDelayMs: 50, DialTimeoutMs: 2000})
+
+	start := time.Now()
+	c := New("http://dual.internal:"+port, WithTransport(tr))
+	got, err := Get[string](context.Background(), c, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", got)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestTransport_DefaultAndConfig(t *testing.T) {
+	c := New("http://svc")
+	assert.Same(t, DefaultTransport(), c.http.Transport)
+
+	InitTransport(TransportConfig{MaxIdleConnsPerHost: 7, DNS: DNSConfig{Disabled: true}})
+	t.Cleanup(func() { defaultTransport.Store(nil) })
+	tr := DefaultTransport()
+	assert.Equal(t, 7, tr.http.MaxIdleConnsPerHost)
+	assert.Nil(t, tr.dns)
+	assert.NotNil(t, tr.http.TLSClientConfig.ClientSessionCache)
+}
+
+func BenchmarkTransport_SustainedLoad(b *testing.B) {
+	url := "http://svc.internal:" + newLoadServer(b) + "/"
+	const workers, n = 16, 20
+
+	b.Run("baseline", func(b *testing.B) {
+		r := &countingResolver{addrs: []string{"127.0.0.1"}}
+		var dials atomic.Int64
+		tr := baselineTransport(r, &dials)
+		defer tr.CloseIdleConnections()
+		for i := 0; i < b.N; i++ {
+			sustainedLoad(b, tr, url, workers, n)
+		}
+		b.ReportMetric(float64(dials.Load())/float64(b.N), "dials/op")
+		b.ReportMetric(float64(r.calls.Load())/float64(b.N), "dns/op")
+	})
+	b.Run("tuned", func(b *testing.B) {
+		r := &countingResolver{addrs: []string{"127.0.0.1"}}
+		tr := NewTransport("bench", TransportConfig{Resolver: r})
+		defer tr.CloseIdleConnections()
+		for i := 0; i < b.N; i++ {
+			sustainedLoad(b, tr, url, workers, n)
+		}
+		b.ReportMetric(float64(tr.Stats().Dials)/float64(b.N), "dials/op")
+		b.ReportMetric(float64(r.calls.Load())/float64(b.N), "dns/op")
+	})
+}
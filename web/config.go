@@ -3,8 +3,13 @@ package web
 import (
 	"reflect"
 
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/logger"
 	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/client"
 	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/fieldcrypt"
+	"github.com/CenJIl/base/web/mask"
 )
 
 // DatabaseConfig 数据库配置（类型别名）
@@ -13,6 +18,21 @@ type DatabaseConfig = database.DatabaseConfig
 // RedisConfig Redis 配置（类型别名）
 type RedisConfig = cache.RedisConfig
 
+// ClientConfig HTTP 客户端连接池配置（类型别名）
+type ClientConfig = client.TransportConfig
+
+// ErrorsConfig 错误指纹统计配置（类型别名）
+type ErrorsConfig = logger.ErrorTrackingConfig
+
+// LogConfig 日志输出配置（类型别名）
+type LogConfig = logger.Options
+
+// FieldCryptConfig 字段加密密钥配置（类型别名）
+type FieldCryptConfig = fieldcrypt.Config
+
+// MaskConfig 脱敏配置（类型别名）
+type MaskConfig = mask.Config
+
 // Config Web 基础配置
 //
 // 使用者必须在配置结构体中内嵌此配置
@@ -25,21 +45,80 @@ type RedisConfig = cache.RedisConfig
 //	    web.Config  // 必须内嵌
 //	}
 type Config struct {
-	LocalePath  string         `toml:"localePath"`  // 本地化文件路径
-	DefaultLang string         `toml:"defaultLang"` // 默认语言
-	LogLevel    string         `toml:"logLevel"`    // 日志级别
-	Port        int            `toml:"port"`        // HTTP 监听端口
-	Upload      UploadConfig   `toml:"upload"`      // 文件上传配置
-	Database    DatabaseConfig `toml:"database"`    // 数据库配置（可选）
-	Redis       RedisConfig    `toml:"redis"`       // Redis 配置（可选）
+	Environment     string             `toml:"environment" reload:"restart"`                              // 运行环境，production 启用生产严格模式（环境变量 APP_ENV 优先）
+	LocalePath      string             `toml:"localePath" reload:"restart"`                               // 本地化文件路径
+	DefaultLang     string             `toml:"defaultLang" reload:"restart"`                              // 默认语言
+	DefaultTimezone string             `toml:"defaultTimezone" reload:"restart"`                          // 默认时区（IANA 名称，如 Asia/Shanghai），默认为本地时区
+	LogLevel        string             `toml:"logLevel" reload:"restart"`                                 // 日志级别
+	Log             LogConfig          `toml:"log" reload:"restart"`                                      // 日志文件输出配置（可选，默认只在 Windows 服务中输出到文件）
+	Port            int                `toml:"port" reload:"restart" validate:"required,min=1,max=65535"` // HTTP 监听端口
+	Upload          UploadConfig       `toml:"upload" reload:"restart"`                                   // 文件上传配置
+	Bandwidth       BandwidthConfig    `toml:"bandwidth" reload:"restart"`                                // 上传/下载带宽限制（可选）
+	Path            PathConfig         `toml:"path" reload:"restart"`                                     // 路径规范化配置（可选，默认关闭）
+	Decompress      DecompressConfig   `toml:"decompress" reload:"restart"`                               // 请求体解压配置（可选，默认关闭）
+	Routes          RoutesConfig       `toml:"routes" reload:"restart"`                                   // 路由表校验配置（可选）
+	Shedding        SheddingConfig     `toml:"shedding" reload:"restart"`                                 // 过载保护配置（可选）
+	SlowStart       SlowStartConfig    `toml:"slowStart" reload:"restart"`                                // 新实例慢启动配置（可选）
+	Ingest          IngestConfig       `toml:"ingest" reload:"restart"`                                   // 高频写入的采集缓冲配置（可选）
+	Maintenance     MaintenanceConfig  `toml:"maintenance" reload:"restart"`                              // 维护模式配置（可选）
+	ServiceAuth     ServiceAuthConfig  `toml:"serviceAuth" reload:"restart"`                              // 服务间签名认证配置（可选）
+	Ownership       OwnershipConfig    `toml:"ownership" reload:"restart"`                                // 资源归属校验配置（可选）
+	Metrics         MetricsConfig      `toml:"metrics" reload:"restart"`                                  // 指标配置（可选）
+	Client          ClientConfig       `toml:"client" reload:"restart"`                                   // 服务间 HTTP 客户端连接池配置（可选）
+	SLO             SLOConfig          `toml:"slo" reload:"restart"`                                      // 路由 SLO 配置（可选）
+	Canary          CanaryConfig       `toml:"canary"`                                                    // 灰度发布配置（可选）
+	Experiment      ExperimentConfig   `toml:"experiment"`                                                // A/B 实验配置（可选，支持热更新）
+	Ack             AckConfig          `toml:"acknowledgements"`                                          // 条款确认门禁配置（可选，支持热更新）
+	Errors          ErrorsConfig       `toml:"errors" reload:"restart"`                                   // 错误指纹统计配置（可选）
+	FieldCrypt      FieldCryptConfig   `toml:"fieldCrypt" reload:"restart"`                               // 字段加密密钥（可选）
+	Keyring         KeyringConfig      `toml:"keyring"`                                                   // 命名密钥与轮换（可选，支持热更新）
+	Mask            MaskConfig         `toml:"mask" reload:"restart"`                                     // 敏感数据脱敏配置（可选）
+	Ledger          LedgerConfig       `toml:"ledger" reload:"restart"`                                   // 请求资源账本配置（可选）
+	Outbound        OutboundConfig     `toml:"outbound" reload:"restart"`                                 // 出站调用限速配置（可选）
+	Cursor          CursorConfig       `toml:"cursor" reload:"restart"`                                   // 分页游标签名密钥（可选）
+	Diagnostics     DiagnosticsConfig  `toml:"diagnostics" reload:"restart"`                              // 运行时诊断包配置（可选）
+	Database        DatabaseConfig     `toml:"database" reload:"restart"`                                 // 数据库配置（可选）
+	Redis           RedisConfig        `toml:"redis" reload:"restart"`                                    // Redis 配置（可选）
+	CORS            CORSConfig         `toml:"cors" reload:"restart"`                                     // 跨域配置（可选，默认允许所有来源）
+	ErrorPages      ErrorPageConfig    `toml:"errorPages" reload:"restart"`                               // 浏览器 HTML 错误页配置（可选）
+	HeaderBudget    HeaderBudgetConfig `toml:"headerBudget" reload:"restart"`                             // 响应头大小预算（可选，默认启用）
+	Proxy           ProxyConfig        `toml:"proxy" reload:"restart"`                                    // 反向代理与客户端 IP 配置（可选）
+	Production      ProductionConfig   `toml:"production" reload:"restart"`                               // 生产严格模式配置（可选）
 }
 
 // UploadConfig 上传配置
 type UploadConfig struct {
-	MaxFileSize int64    `toml:"maxFileSize"` // 单文件最大大小（字节）
-	AllowedExts []string `toml:"allowedExts"` // 允许的扩展名
-	UploadPath  string   `toml:"uploadPath"`  // 上传保存路径
-	URLPrefix   string   `toml:"urlPrefix"`   // 访问 URL 前缀
+	MaxFileSize cfg.ByteSize `toml:"maxFileSize"` // 单文件最大大小（"10MiB" 或字节数）
+	AllowedExts []string     `toml:"allowedExts"` // 允许的扩展名
+	UploadPath  string       `toml:"uploadPath"`  // 上传保存路径
+	URLPrefix   string       `toml:"urlPrefix"`   // 访问 URL 前缀
+
+	Scan     ScanConfig     `toml:"scan"`     // 病毒扫描配置（可选）
+	Checksum ChecksumConfig `toml:"checksum"` // 校验和配置（可选）
+}
+
+// MetricsConfig 指标配置
+type MetricsConfig struct {
+	Enabled bool   `toml:"enabled"` // 是否暴露指标接口
+	Path    string `toml:"path"`    // 指标路径，默认 /metrics
+}
+
+// CORSConfig 跨域配置
+//
+// 未配置 allowOrigins 时允许所有来源并携带凭证（开发环境的默认行为，生产严格模式下不允许）
+type CORSConfig struct {
+	AllowOrigins     []string `toml:"allowOrigins"`     // 允许的来源，默认 ["*"]
+	AllowMethods     []string `toml:"allowMethods"`     // 允许的方法，默认 GET/POST/PUT/DELETE/OPTIONS
+	AllowHeaders     []string `toml:"allowHeaders"`     // 允许的请求头，默认 Content-Type/Authorization
+	AllowCredentials *bool    `toml:"allowCredentials"` // 是否允许携带凭证，默认 true
+}
+
+// ProxyConfig 反向代理配置（决定 X-Forwarded-For / X-Real-IP 是否可信）
+//
+// 未配置时信任任何来源的转发头（Hertz 默认行为），客户端可以伪造 IP
+type ProxyConfig struct {
+	TrustedProxies  []string `toml:"trustedProxies"`  // 可信代理的 IP 或 CIDR，只有来自这些地址的转发头生效
+	IgnoreForwarded bool     `toml:"ignoreForwarded"` // 不读取转发头，始终使用连接地址
 }
 
 // extractWebConfig 从用户配置中提取内嵌的 web.Config
@@ -76,3 +155,19 @@ func extractWebConfig(userCfg any) Config {
 	// 没找到内嵌 Config，返回零值
 	return Config{}
 }
+
+// webConfigOf 用户配置中内嵌的 Config 的指针（修改配置副本时使用），未内嵌时返回 nil
+func webConfigOf(userCfg any) *Config {
+	val := reflect.ValueOf(userCfg)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	val = val.Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if field.Anonymous && field.Type == reflect.TypeOf(Config{}) && field.IsExported() {
+			return val.Field(i).Addr().Interface().(*Config)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 内置的配置试运行校验（只检查候选配置中内嵌的 web.Config）
+func init() {
+	cfg.RegisterDryRunCheck("web.database", func(ctx context.Context, candidate any) error {
+		return database.CheckConnection(ctx, extractWebConfig(candidate).Database)
+	})
+	cfg.RegisterDryRunCheck("web.maintenance", func(ctx context.Context, candidate any) error {
+		return checkMaintenanceConfig(extractWebConfig(candidate).Maintenance)
+	})
+	cfg.RegisterDryRunCheck("web.limits", func(ctx context.Context, candidate any) error {
+		c := extractWebConfig(candidate)
+		return checkLimits(c.Shedding, c.Bandwidth)
+	})
+}
+
+// CheckTLSFiles 检查证书和私钥文件存在、可以解析且相互匹配（供应用注册自己的 TLS 试运行校验）
+//
+// 使用方式：
+//
+//	cfg.RegisterDryRunCheck("tls", func(ctx context.Context, candidate any) error {
+//	    c := candidate.(*AppConfig)
+//	    return web.CheckTLSFiles(c.TLS.CertFile, c.TLS.KeyFile)
+//	})
+func CheckTLSFiles(certFile, keyFile string) error {
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return fmt.Errorf("TLS 证书无效: %w", err)
+	}
+	return nil
+}
+
+// checkMaintenanceConfig 放行地址必须是 IP 或 CIDR，重复窗口必须可以解析
+func checkMaintenanceConfig(config MaintenanceConfig) error {
+	var errs []error
+	for _, entry := range config.Allowlist {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			errs = append(errs, fmt.Errorf("无效的维护放行地址: %s", entry))
+		}
+	}
+	for _, spec := range config.Recurring {
+		if _, err := parseWeeklyWindow(spec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkLimits 过载保护与带宽限制的取值范围
+func checkLimits(shedding SheddingConfig, bandwidth BandwidthConfig) error {
+	var errs []error
+	if shedding.Capacity < 0 || shedding.LatencyTargetMs < 0 || shedding.RetryAfter < 0 {
+		errs = append(errs, errors.New("shedding.capacity / latencyTargetMs / retryAfter 不能为负数"))
+	}
+	if shedding.Hysteresis < 0 || shedding.Hysteresis >= 1 {
+		errs = append(errs, errors.New("shedding.hysteresis 必须在 [0, 1) 之间"))
+	}
+	if shedding.BackgroundThreshold > 0 && shedding.NormalThreshold > 0 && shedding.BackgroundThreshold > shedding.NormalThreshold {
+		errs = append(errs, errors.New("shedding.backgroundThreshold 不能大于 normalThreshold"))
+	}
+	if bandwidth.DownloadPerConn < 0 || bandwidth.UploadPerConn < 0 || bandwidth.Burst < 0 {
+		errs = append(errs, errors.New("bandwidth 的速率和突发量不能为负数"))
+	}
+	return errors.Join(errs...)
+}
+
+// ConfigDryRunHandler 配置试运行接口
+//
+// 请求体为完整的候选配置文档（TOML）。严格解析（拒绝未知键）、执行 Validate 与各子系统的试运行校验
+// （数据库连接、维护放行地址、限流取值等），返回与当前配置的逐项差异（含是否需要重启），不应用任何修改。
+// 校验通过时响应 200 并返回短期有效的确认令牌，否则响应 422 并在 data 中返回错误和差异。
+// 每次调用都记录审计事件。需要自行挂在有权限控制的路由组下
+//
+// 使用方式：
+//
+//	stager := cfg.NewStager[AppConfig](cfg.StageConfig{})
+//	admin.POST("/config/dry-run", web.ConfigDryRunHandler(stager))
+//	admin.POST("/config/apply", web.ConfigApplyHandler(stager))
+func ConfigDryRunHandler[T any](stager *cfg.Stager[T]) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		operator := jwt.GetActor(c)
+		if operator == "" {
+			panic(UnauthorizedHTTP("需要登录"))
+		}
+
+		result := stager.DryRun(ctx, c.Request.Body(), operator)
+		emitConfigAudit(c, "config.dry_run", operator, map[string]any{
+			"valid":           result.Valid,
+			"errors":          result.Errors,
+			"changes":         result.Changes,
+			"restartRequired": result.RestartRequired,
+		})
+		if !result.Valid {
+			c.JSON(consts.StatusUnprocessableEntity, FailWithData(consts.StatusUnprocessableEntity, "候选配置校验失败", result))
+			return
+		}
+		c.JSON(consts.StatusOK, Success(result))
+	}
+}
+
+// ConfigApplyHandler 配置确认应用接口
+//
+// 请求体为 {"token": "..."}（试运行返回的令牌），只能由试运行的操作人确认。
+// 通过 cfg.Update 原子写回配置文件并立即生效，返回应用的变更。
+// 令牌无效 400、过期 410、操作人不符 403、试运行之后配置已被修改 409。
+// 无论成功与否都记录审计事件。需要自行挂在有权限控制的路由组下
+func ConfigApplyHandler[T any](stager *cfg.Stager[T]) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		operator := jwt.GetActor(c)
+		if operator == "" {
+			panic(UnauthorizedHTTP("需要登录"))
+		}
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(c.Request.Body(), &req); err != nil || req.Token == "" {
+			panic(BadRequestHTTP("缺少确认令牌"))
+		}
+
+		changes, err := stager.Apply(req.Token, operator)
+		data := map[string]any{"applied": err == nil, "changes": changes}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		emitConfigAudit(c, "config.apply", operator, data)
+
+		switch {
+		case errors.Is(err, cfg.ErrTokenInvalid):
+			panic(BadRequestHTTP(err.Error()))
+		case errors.Is(err, cfg.ErrTokenExpired):
+			panic(NewHTTPException(consts.StatusGone, consts.StatusGone, err.Error()))
+		case errors.Is(err, cfg.ErrTokenOperator):
+			panic(ForbiddenHTTP(err.Error()))
+		case errors.Is(err, cfg.ErrConfigChanged):
+			panic(ConflictHTTP(err.Error()))
+		case err != nil:
+			log.Errorf("[Config] %s 应用配置失败: %v", operator, err)
+			panic(InternalHTTP("应用配置失败: " + err.Error()))
+		}
+		log.Warnf("[Config] %s 已应用配置变更: %d 项", operator, len(changes))
+		c.JSON(consts.StatusOK, Success(map[string]any{"changes": changes}))
+	}
+}
+
+// ConfigDescribeHandler 配置说明接口：每个配置项的路径、类型、说明、默认值、校验规则、当前值与来源（见 cfg.Describe）
+//
+// 默认返回 JSON；?format=text 返回纯文本表格，便于在终端查看。敏感配置项已脱敏。
+// 需要自行挂在有权限控制的路由组下
+//
+// 使用方式：
+//
+//	admin.GET("/config/describe", web.ConfigDescribeHandler())
+//
+//	curl -H "Authorization: Bearer $TOKEN" "https://api.example.com/admin/config/describe?format=text"
+func ConfigDescribeHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		doc := cfg.DescribeCurrent()
+		if c.Query("format") == "text" {
+			c.Data(consts.StatusOK, "text/plain; charset=utf-8", []byte(doc.Table()))
+			return
+		}
+		c.JSON(consts.StatusOK, Success(doc))
+	}
+}
+
+func emitConfigAudit(c *app.RequestContext, typ, operator string, data map[string]any) {
+	audit.Emit(context.Background(), audit.Event{
+		Type:      typ,
+		Actor:     operator,
+		RequestID: middleware.GetRequestID(c),
+		Method:    string(c.Method()),
+		Path:      string(c.Path()),
+		Data:      data,
+	})
+}
@@ -0,0 +1,201 @@
+package web
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stageAppConfig struct {
+	AppName string `toml:"appName"`
+	Config
+}
+
+const stageRunning = `appName = "orders"
+port = 8080
+
+[shedding]
+capacity = 100
+
+[maintenance]
+allowlist = ["10.0.0.0/8"]
+`
+
+type stageResponse struct {
+	Code int             `json:"code"`
+	Data cfg.StageResult `json:"data"`
+}
+
+func newConfigStageEngine(t *testing.T, stageConfig cfg.StageConfig) (*route.Engine, string, *ownershipAudit) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "config-stage-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	t.Cleanup(func() { audit.SetSink(nil) })
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte(stageRunning), 0644))
+	require.NoError(t, cfg.LoadConfig[stageAppConfig](path))
+
+	stager := cfg.NewStager[stageAppConfig](stageConfig)
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler(), jwt.Middleware())
+	engine.POST("/admin/config/dry-run", ConfigDryRunHandler(stager))
+	engine.POST("/admin/config/apply", ConfigApplyHandler(stager))
+	engine.GET("/admin/config/describe", ConfigDescribeHandler())
+	return engine, path, rec
+}
+
+func dryRun(t *testing.T, engine *route.Engine, user, doc string) (int, cfg.StageResult) {
+	t.Helper()
+	w := ut.PerformRequest(engine, "POST", "/admin/config/dry-run",
+		&ut.Body{Body: strings.NewReader(doc), Len: len(doc)}, ownershipToken(t, user, "admin"))
+	var resp stageResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w.Code, resp.Data
+}
+
+func applyStaged(t *testing.T, engine *route.Engine, user, token string) int {
+	t.Helper()
+	body := `{"token":"` + token + `"}`
+	w := ut.PerformRequest(engine, "POST", "/admin/config/apply",
+		&ut.Body{Body: strings.NewReader(body), Len: len(body)}, ownershipToken(t, user, "admin"))
+	return w.Code
+}
+
+func TestConfigStage_InvalidCandidate(t *testing.T) {
+	engine, path, rec := newConfigStageEngine(t, cfg.StageConfig{CheckTimeout: time.Second})
+
+	code, result := dryRun(t, engine, "alice", stageRunning+"\n[shedding]\nburst = 1\n")
+	assert.Equal(t, 422, code)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "parse", result.Errors[0].Check)
+
+	candidate := `appName = "orders"
+port = 8080
+
+[shedding]
+capacity = 100
+hysteresis = 1.5
+
+[maintenance]
+allowlist = ["10.0.0.0/33"]
+
+[database]
+driver = "postgres"
+host = "127.0.0.1"
+port = 1
+password = "s3cret"
+`
+	code, result = dryRun(t, engine, "alice", candidate)
+	assert.Equal(t, 422, code)
+	assert.False(t, result.Valid)
+	assert.Empty(t, result.Token)
+	var checks []string
+	for _, e := range result.Errors {
+		checks = append(checks, e.Check)
+	}
+	assert.Equal(t, []string{"web.database", "web.limits", "web.maintenance"}, checks)
+	assert.Contains(t, result.Errors[2].Message, "10.0.0.0/33")
+	for _, c := range result.Changes {
+		if c.Path == "database.password" {
+			assert.True(t, c.Sensitive)
+			assert.Nil(t, c.New, "敏感配置项不返回值")
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, stageRunning, string(data))
+	assert.Equal(t, []string{"config.dry_run", "config.dry_run"}, rec.types())
+	assert.Equal(t, "alice", rec.events[1].Actor)
+}
+
+func TestConfigStage_ValidApply(t *testing.T) {
+	engine, path, rec := newConfigStageEngine(t, cfg.StageConfig{})
+	candidate := `appName = "orders-v2"
+port = 9090
+
+[shedding]
+capacity = 100
+disabled = true
+
+[maintenance]
+allowlist = ["10.0.0.0/8", "192.168.1.7"]
+`
+	code, result := dryRun(t, engine, "alice", candidate)
+	require.Equal(t, 200, code, result.Errors)
+	assert.True(t, result.Valid)
+	assert.True(t, result.RestartRequired)
+	assert.Equal(t, []cfg.Change{
+		{Path: "appName", Old: "orders", New: "orders-v2"},
+		{Path: "port", Old: float64(8080), New: float64(9090), RestartRequired: true},
+		{Path: "shedding.disabled", Old: false, New: true},
+		{Path: "maintenance.allowlist", Old: []any{"10.0.0.0/8"}, New: []any{"10.0.0.0/8", "192.168.1.7"}, RestartRequired: true},
+	}, result.Changes)
+	assert.Equal(t, 8080, extractWebConfig(cfg.GetCfg[stageAppConfig]()).Port, "试运行不应用")
+
+	assert.Equal(t, 403, applyStaged(t, engine, "bob", result.Token), "只能由试运行的操作人确认")
+	assert.Equal(t, 200, applyStaged(t, engine, "alice", result.Token))
+	assert.Equal(t, 400, applyStaged(t, engine, "alice", result.Token), "令牌一次性")
+	current := cfg.GetCfg[stageAppConfig]()
+	assert.Equal(t, "orders-v2", current.AppName)
+	assert.True(t, current.Shedding.Disabled)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, candidate, string(data))
+
+	assert.Equal(t, []string{"config.dry_run", "config.apply", "config.apply", "config.apply"}, rec.types())
+	applied := rec.events[2]
+	assert.Equal(t, "alice", applied.Actor)
+	assert.Equal(t, true, applied.Data["applied"])
+}
+
+func TestConfigStage_TokenExpiry(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC))
+	engine, _, rec := newConfigStageEngine(t, cfg.StageConfig{TokenTTL: 2 * time.Minute, Clock: clock})
+
+	_, result := dryRun(t, engine, "alice", strings.Replace(stageRunning, "8080", "9090", 1))
+	require.True(t, result.Valid)
+	clock.Advance(3 * time.Minute)
+	assert.Equal(t, 410, applyStaged(t, engine, "alice", result.Token))
+	assert.Equal(t, 8080, cfg.GetCfg[stageAppConfig]().Port)
+	assert.Equal(t, false, rec.events[len(rec.events)-1].Data["applied"])
+}
+
+func TestConfigDescribe_JSONAndText(t *testing.T) {
+	engine, path, _ := newConfigStageEngine(t, cfg.StageConfig{})
+
+	w := ut.PerformRequest(engine, "GET", "/admin/config/describe", nil, ownershipToken(t, "alice", "admin"))
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var resp struct {
+		Data cfg.ConfigDoc `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	fields := make(map[string]cfg.FieldDoc)
+	for _, f := range resp.Data.Fields {
+		fields[f.Path] = f
+	}
+	assert.Equal(t, cfg.Source{Kind: cfg.SourceFile, Name: path}, fields["appName"].Source)
+	assert.Equal(t, "orders", fields["appName"].Value)
+	assert.Contains(t, fields, "shedding.capacity", "内嵌的 web.Config 的配置项提升到顶层")
+
+	w = ut.PerformRequest(engine, "GET", "/admin/config/describe?format=text", nil, ownershipToken(t, "alice", "admin"))
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", string(w.Header().ContentType()))
+	assert.Regexp(t, `appName\s+"orders"\s+file \(app\.toml\)`, w.Body.String())
+}
@@ -0,0 +1,395 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/CenJIl/base/web/queue"
+	"github.com/google/uuid"
+)
+
+// MessageHandler 消息处理函数（返回错误时按退避重试，处理函数需要幂等）
+type MessageHandler func(ctx context.Context, msg queue.Message) error
+
+// ConsumerOption 消费者选项
+type ConsumerOption func(*Consumer)
+
+// WithConsumerName 消费者名称（指标标签和生命周期组件名，默认 "default"）
+func WithConsumerName(name string) ConsumerOption {
+	return func(c *Consumer) { c.name = name }
+}
+
+// WithConcurrency 并发处理数（默认 4）
+//
+// 同一个顺序键的消息总是交给同一个 worker，按到达顺序串行处理
+func WithConcurrency(n int) ConsumerOption {
+	return func(c *Consumer) { c.concurrency = n }
+}
+
+// WithPrefetch 已取出但未确认的消息数上限（默认并发数的 2 倍）
+func WithPrefetch(n int) ConsumerOption {
+	return func(c *Consumer) { c.prefetch = n }
+}
+
+// WithRetry 处理失败的重试策略：最多处理 maxAttempts 次，间隔从 base 开始逐次翻倍，不超过 max
+//
+// 默认 5 次、1 秒、1 分钟。重试在原 worker 上进行，同一个键后面的消息等待重试结束，保证顺序
+func WithRetry(maxAttempts int, base, max time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.maxAttempts, c.baseBackoff, c.maxBackoff = maxAttempts, base, max
+	}
+}
+
+// WithMessageTx 每条消息在一个数据库事务中处理（db 为 nil 时使用 database.DB）
+//
+// 事务通过 database.Conn(ctx) 取得；处理成功提交（随后执行 database.AfterCommit 的回调），失败回滚后再重试
+func WithMessageTx(db *sql.DB) ConsumerOption {
+	return func(c *Consumer) {
+		c.tx = true
+		c.db = db
+	}
+}
+
+// Consumer 外部队列消费者：在受监督的 worker 池中执行处理函数
+//
+// 与 HTTP 处理函数享有相同的保障：
+//   - 每条消息的 ctx 带请求 ID（取消息头 request_id，没有时生成），日志和下游调用可以串起来
+//   - 处理函数 panic 按失败处理，取消息失败时退避后继续，不会让进程退出
+//   - 可选的消息级事务（WithMessageTx），以及基于消费记录表的去重（WithIdempotency）
+//   - 指标：consumer_processed_total / consumer_failed_total / consumer_retried_total /
+//     consumer_dead_total / consumer_duplicate_total{consumer}，来源支持时 consumer_lag{consumer}
+//
+// 重试耗尽的消息写入死信（来源实现 queue.DeadLetterer 时）后确认。
+// 停止时不再取新消息，等待正在处理的消息完成；已取出未开始的消息和等待重试的消息 Nack 交还队列
+//
+// 使用方式：
+//
+//	source := queue.NewRedisStream(cache.Client, config.Orders.Stream)
+//	consumer := web.NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+//	    return billing.HandleOrderEvent(ctx, msg.Body)
+//	}, web.WithConsumerName("orders"), web.WithConcurrency(8), web.WithMessageTx(nil))
+//	web.RegisterComponent(consumer.Component(web.ComponentDatabase, web.ComponentRedis))
+type Consumer struct {
+	source  queue.Source
+	handler MessageHandler
+
+	name            string
+	concurrency     int
+	prefetch        int
+	maxAttempts     int
+	baseBackoff     time.Duration
+	maxBackoff      time.Duration
+	tx              bool
+	db              *sql.DB
+	ledger          *consumerLedger // WithIdempotency
+	ledgerRetention time.Duration
+
+	stopping chan struct{} // 关闭后 worker 不再开始新消息
+	next     atomic.Uint64 // 没有顺序键的消息轮流分配
+}
+
+// NewConsumer 创建消费者
+func NewConsumer(source queue.Source, handler MessageHandler, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{source: source, handler: handler, name: "default", concurrency: 4}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency <= 0 {
+		c.concurrency = 4
+	}
+	if c.prefetch <= 0 {
+		c.prefetch = 2 * c.concurrency
+	}
+	if c.maxAttempts <= 0 {
+		c.maxAttempts = 5
+	}
+	if c.baseBackoff <= 0 {
+		c.baseBackoff = time.Second
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = time.Minute
+	}
+	if c.ledger != nil {
+		c.ledger.retention = c.ledgerRetention
+		if c.ledger.retention <= 0 {
+			c.ledger.retention = 7 * 24 * time.Hour
+		}
+	}
+	return c
+}
+
+// Run 消费直到 ctx 取消，然后排空：正在处理的消息完成后返回
+func (c *Consumer) Run(ctx context.Context) {
+	c.stopping = make(chan struct{})
+	slots := make(chan struct{}, c.prefetch)
+	lanes := make([]chan queue.Message, c.concurrency)
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan queue.Message, c.prefetch)
+		wg.Add(1)
+		go func(lane chan queue.Message) {
+			defer wg.Done()
+			for msg := range lane {
+				c.dispatch(msg)
+				<-slots
+			}
+		}(lanes[i])
+	}
+
+	if reporter, ok := c.source.(queue.LagReporter); ok {
+		go c.reportLag(ctx, reporter)
+	}
+	if c.ledger != nil {
+		go c.purgeLoop(ctx)
+	}
+
+	c.fetchLoop(ctx, slots, lanes)
+	close(c.stopping)
+	for _, lane := range lanes {
+		close(lane)
+	}
+	wg.Wait()
+}
+
+// fetchLoop 取消息并按顺序键分配给 worker；取消息失败时退避后继续
+func (c *Consumer) fetchLoop(ctx context.Context, slots chan struct{}, lanes []chan queue.Message) {
+	backoff := 100 * time.Millisecond
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		msg, err := c.fetch(ctx)
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("[Consumer] %s 取消息失败，%v 后重试: %v", c.name, backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff = min(2*backoff, 30*time.Second)
+			continue
+		}
+		backoff = 100 * time.Millisecond
+		lanes[c.lane(msg)] <- msg
+	}
+}
+
+// fetch 调用来源的 Fetch，来源 panic 按错误处理
+func (c *Consumer) fetch(ctx context.Context) (msg queue.Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("来源 panic: %v", r)
+		}
+	}()
+	return c.source.Fetch(ctx)
+}
+
+// lane 顺序键对应的 worker
+func (c *Consumer) lane(msg queue.Message) int {
+	if msg.Key == "" {
+		return int(c.next.Add(1) % uint64(c.concurrency))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(msg.Key))
+	return int(h.Sum32() % uint32(c.concurrency))
+}
+
+// dispatch 处理一条消息直到成功、进入死信或消费者停止
+func (c *Consumer) dispatch(msg queue.Message) {
+	ctx := context.Background()
+	select {
+	case <-c.stopping:
+		// 已取出但还没开始处理：交还队列
+		c.nack(ctx, msg)
+		return
+	default:
+	}
+
+	requestID := msg.Headers["request_id"]
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	ctx = context.WithValue(ctx, middleware.RequestIDKey{}, requestID)
+
+	attempts := msg.Attempts
+	for {
+		attempts++
+		err := c.process(ctx, msg)
+		if err == nil || errors.Is(err, errAlreadyProcessed) {
+			if err := c.source.Ack(ctx, msg); err != nil {
+				log.Errorf("[Consumer] %s 确认消息 %s 失败: %v", c.name, msg.ID, err)
+			}
+			if err != nil {
+				log.Infof("[Consumer] %s 消息 %s 已处理过，跳过并确认", c.name, msg.ID)
+				metrics.GetCounter("consumer_duplicate_total", "consumer", c.name).Inc()
+				return
+			}
+			metrics.GetCounter("consumer_processed_total", "consumer", c.name).Inc()
+			return
+		}
+		metrics.GetCounter("consumer_failed_total", "consumer", c.name).Inc()
+
+		if attempts >= c.maxAttempts {
+			c.deadLetter(ctx, msg, err, attempts)
+			return
+		}
+		delay := c.backoff(attempts)
+		log.Warnf("[Consumer] %s 消息 %s 处理失败（第 %d 次），%v 后重试: %v", c.name, msg.ID, attempts, delay, err)
+		metrics.GetCounter("consumer_retried_total", "consumer", c.name).Inc()
+		select {
+		case <-time.After(delay):
+		case <-c.stopping:
+			c.nack(ctx, msg)
+			return
+		}
+	}
+}
+
+// process 执行一次处理函数（可选事务），panic 按失败处理
+func (c *Consumer) process(ctx context.Context, msg queue.Message) (err error) {
+	var tx *sql.Tx
+	var hooks *database.CommitHooks
+	if c.tx {
+		db := c.database()
+		if db == nil {
+			return errors.New("未配置数据库，无法开启消息事务")
+		}
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return fmt.Errorf("开启事务失败: %w", err)
+		}
+		ctx, hooks = database.WithCommitHooks(database.WithDBTX(ctx, tx))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if !common.IsControlFlow(r) {
+				logger.ErrorPanic(r, "[Consumer] %s 消息 %s 处理函数 panic: %v", c.name, msg.ID, r)
+			}
+			err = fmt.Errorf("处理函数 panic: %v", r)
+		}
+		if tx == nil {
+			return
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		} else if cerr := tx.Commit(); cerr != nil {
+			err = fmt.Errorf("提交事务失败: %w", cerr)
+		} else {
+			hooks.Committed()
+		}
+	}()
+	if c.ledger != nil {
+		fresh, err := c.ledger.record(ctx, tx, msg.ID)
+		if err != nil {
+			return fmt.Errorf("写入消费记录失败: %w", err)
+		}
+		if !fresh {
+			return errAlreadyProcessed
+		}
+	}
+	return c.handler(ctx, msg)
+}
+
+// database 消息事务使用的数据库
+func (c *Consumer) database() *sql.DB {
+	if c.db != nil {
+		return c.db
+	}
+	return database.DB
+}
+
+// deadLetter 重试耗尽：写入死信后确认（死信写入失败时不确认，留给队列重新投递）
+func (c *Consumer) deadLetter(ctx context.Context, msg queue.Message, cause error, attempts int) {
+	metrics.GetCounter("consumer_dead_total", "consumer", c.name).Inc()
+	log.Errorf("[Consumer] %s 消息 %s 进入死信（第 %d 次处理）: %v", c.name, msg.ID, attempts, cause)
+	if dl, ok := c.source.(queue.DeadLetterer); ok {
+		if err := dl.DeadLetter(ctx, msg, cause); err != nil {
+			log.Errorf("[Consumer] %s 写入死信失败: %v", c.name, err)
+			c.nack(ctx, msg)
+			return
+		}
+	}
+	if err := c.source.Ack(ctx, msg); err != nil {
+		log.Errorf("[Consumer] %s 确认消息 %s 失败: %v", c.name, msg.ID, err)
+	}
+}
+
+func (c *Consumer) nack(ctx context.Context, msg queue.Message) {
+	if err := c.source.Nack(ctx, msg); err != nil {
+		log.Errorf("[Consumer] %s 交还消息 %s 失败: %v", c.name, msg.ID, err)
+	}
+}
+
+// backoff 第 attempts 次失败后的重试间隔
+func (c *Consumer) backoff(attempts int) time.Duration {
+	return common.Backoff(c.baseBackoff, c.maxBackoff, attempts)
+}
+
+// reportLag 每 10 秒记录一次积压量
+func (c *Consumer) reportLag(ctx context.Context, reporter queue.LagReporter) {
+	gauge := metrics.GetGauge("consumer_lag", "consumer", c.name)
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		if lag, err := reporter.Lag(ctx); err == nil {
+			gauge.Set(float64(lag))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Component 消费者的生命周期组件
+//
+// dependsOn 声明处理函数用到的连接（通常是 ComponentDatabase / ComponentRedis）：
+// 优雅关闭时消费者先排空，之后才关闭这些连接。排空超过组件的停止预算时放弃等待
+func (c *Consumer) Component(dependsOn ...string) Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return Component{
+		Name:      "consumer:" + c.name,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				c.Run(runCtx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
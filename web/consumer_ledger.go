@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/database"
+)
+
+// ConsumerLedgerMigrationMySQL 消费记录表结构（MySQL）
+const ConsumerLedgerMigrationMySQL = `CREATE TABLE IF NOT EXISTS consumer_processed (
+    consumer_group VARCHAR(128) NOT NULL,
+    message_id VARCHAR(64) NOT NULL,
+    processed_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (consumer_group, message_id),
+    INDEX idx_consumer_processed_group_at (consumer_group, processed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// ConsumerLedgerMigrationPostgres 消费记录表结构（PostgreSQL 9.5+）
+const ConsumerLedgerMigrationPostgres = `CREATE TABLE IF NOT EXISTS consumer_processed (
+    consumer_group VARCHAR(128) NOT NULL,
+    message_id VARCHAR(64) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (consumer_group, message_id)
+);
+CREATE INDEX IF NOT EXISTS idx_consumer_processed_group_at ON consumer_processed (consumer_group, processed_at)`
+
+// MigrateConsumerLedger 创建消费记录表（WithIdempotency 使用）
+//
+// 使用方式：
+//
+//	if err := web.MigrateConsumerLedger(ctx, database.DB, config.Database.Driver); err != nil {
+//	    panic(err)
+//	}
+func MigrateConsumerLedger(ctx context.Context, db *sql.DB, driver string) error {
+	migration := ConsumerLedgerMigrationMySQL
+	if driver == database.DriverPostgreSQL {
+		migration = ConsumerLedgerMigrationPostgres
+	}
+	for _, stmt := range strings.Split(migration, ";\n") {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("创建消费记录表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// errAlreadyProcessed 消费记录中已有该消息（之前的处理已提交，确认前进程退出）
+var errAlreadyProcessed = errors.New("消息已处理")
+
+// WithIdempotency 按消费记录表去重，保证已提交的消息重新投递时不会再次产生效果
+//
+// 每条消息在事务中先写入 (group, 消息 ID) 的消费记录，再执行处理函数，两者一起提交或回滚
+// （隐含 WithMessageTx，数据库由 WithMessageTx 指定，默认 database.DB）。
+// 提交后、确认前进程退出时，消息会被重新投递，此时写入遇到主键冲突，直接确认、不再调用处理函数。
+// 写入本身就是检查：每条消息只增加一次主键上的插入，不需要先查询。
+// 多个副本同时处理同一条消息（XAUTOCLAIM 接手了仍在处理的消息）时，后到的插入等待先到的事务结束，
+// 先到的提交后判定为重复，先到的回滚后正常处理
+//
+// group 在同一个库内标识一个消费方，通常用 stream 与消费组拼接（不同 stream 的消息 ID 可能相同）；
+// driver 为 database.DriverMySQL 或 database.DriverPostgreSQL。表结构见 MigrateConsumerLedger
+//
+// 使用方式：
+//
+//	consumer := web.NewConsumer(source, handlePayment,
+//	    web.WithConsumerName("payments"),
+//	    web.WithIdempotency(config.Payments.Stream.Stream+"/"+config.Payments.Stream.Group, config.Database.Driver),
+//	    web.WithLedgerRetention(14*24*time.Hour))
+func WithIdempotency(group, driver string) ConsumerOption {
+	return func(c *Consumer) {
+		c.tx = true
+		c.ledger = &consumerLedger{group: group, driver: driver}
+	}
+}
+
+// WithLedgerRetention 消费记录的保留期（默认 7 天，需要 WithIdempotency）
+//
+// 消费者运行期间每小时删除一次过期记录。保留期必须长于消息可能被重新投递的最长时间
+// （消费者停机时长加上 claimIdleMs），过期后同一条消息的投递会被当作新消息
+func WithLedgerRetention(d time.Duration) ConsumerOption {
+	return func(c *Consumer) { c.ledgerRetention = d }
+}
+
+// consumerLedger 消费记录表的读写
+type consumerLedger struct {
+	group     string
+	driver    string
+	retention time.Duration
+}
+
+// record 在消息事务中写入消费记录，返回 false 表示已有记录（消息已处理）
+func (l *consumerLedger) record(ctx context.Context, tx *sql.Tx, messageID string) (bool, error) {
+	query := `INSERT IGNORE INTO consumer_processed (consumer_group, message_id, processed_at) VALUES (?, ?, ?)`
+	if l.driver == database.DriverPostgreSQL {
+		query = `INSERT INTO consumer_processed (consumer_group, message_id, processed_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	}
+	res, err := tx.ExecContext(ctx, query, l.group, messageID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// purge 删除超过保留期的消费记录
+func (l *consumerLedger) purge(ctx context.Context, db *sql.DB) (int64, error) {
+	query := `DELETE FROM consumer_processed WHERE consumer_group = ? AND processed_at < ?`
+	if l.driver == database.DriverPostgreSQL {
+		query = `DELETE FROM consumer_processed WHERE consumer_group = $1 AND processed_at < $2`
+	}
+	res, err := db.ExecContext(ctx, query, l.group, time.Now().Add(-l.retention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeLedger 删除超过保留期的消费记录（没有启用 WithIdempotency 时什么都不做）
+func (c *Consumer) PurgeLedger(ctx context.Context) (int64, error) {
+	if c.ledger == nil {
+		return 0, nil
+	}
+	db := c.database()
+	if db == nil {
+		return 0, errors.New("未配置数据库，无法清理消费记录")
+	}
+	return c.ledger.purge(ctx, db)
+}
+
+// purgeLoop 每小时清理一次过期的消费记录
+func (c *Consumer) purgeLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := c.PurgeLedger(ctx); err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("[Consumer] %s 清理过期消费记录失败: %v", c.name, err)
+			}
+		} else if n > 0 {
+			log.Infof("[Consumer] %s 清理过期消费记录 %d 条", c.name, n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ledgerDB 内存数据库：消费记录按主键去重，处理函数的效果（INSERT INTO payments）随事务提交
+type ledgerDB struct {
+	*memSQL
+	inserts int   // 消费记录的插入次数
+	purges  []any // DELETE 的参数
+}
+
+func newLedgerDB(t *testing.T) (*ledgerDB, *sql.DB) {
+	f := &ledgerDB{memSQL: &memSQL{}}
+	f.exec = f.execLedger
+	return f, newMemSQL(t, f.memSQL)
+}
+
+// paid 已提交的扣款次数（消息体 → 次数）
+func (f *ledgerDB) paid() map[string]int {
+	out := map[string]int{}
+	for _, row := range f.rows("payments") {
+		out[row["order_id"].(string)]++
+	}
+	return out
+}
+
+// processed 已提交的消费记录（consumer_group/message_id → processed_at）
+func (f *ledgerDB) processed() map[string]time.Time {
+	out := map[string]time.Time{}
+	for _, row := range f.rows("consumer_processed") {
+		out[row["key"].(string)] = row["at"].(time.Time)
+	}
+	return out
+}
+
+func (f *ledgerDB) record(key string, at time.Time) {
+	f.insert("consumer_processed", map[string]any{"key": key, "at": at})
+}
+
+func (f *ledgerDB) execLedger(c *memConn, query string, args []any) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "INTO consumer_processed"):
+		f.inserts++
+		key := fmt.Sprint(args[0], "/", args[1])
+		for _, row := range c.rows("consumer_processed") {
+			if row["key"] == key {
+				return driver.RowsAffected(0), nil
+			}
+		}
+		c.insert("consumer_processed", map[string]any{"key": key, "at": args[2].(time.Time)})
+		return driver.RowsAffected(1), nil
+	case strings.Contains(query, "INTO payments"):
+		c.insert("payments", map[string]any{"order_id": args[0].(string)})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM consumer_processed"):
+		f.purges = append(f.purges, args[0], args[1])
+		before := len(f.tables["consumer_processed"])
+		f.tables["consumer_processed"] = slices.DeleteFunc(f.tables["consumer_processed"], func(row map[string]any) bool {
+			return strings.HasPrefix(row["key"].(string), args[0].(string)+"/") && row["at"].(time.Time).Before(args[1].(time.Time))
+		})
+		return driver.RowsAffected(int64(before - len(f.tables["consumer_processed"]))), nil
+	}
+	return nil, fmt.Errorf("unexpected statement: %s", query)
+}
+
+// crashingSource 模拟事务提交之后、确认之前进程被杀：Ack 不生效，消息留在待确认中
+type crashingSource struct {
+	*queue.MemorySource
+	mu      sync.Mutex
+	crashed bool
+	lost    []queue.Message
+}
+
+func (s *crashingSource) Ack(ctx context.Context, msg queue.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.crashed {
+		s.lost = append(s.lost, msg)
+		return errors.New("进程已退出")
+	}
+	return s.MemorySource.Ack(ctx, msg)
+}
+
+func TestConsumer_IdempotencyCrashBetweenCommitAndAck(t *testing.T) {
+	ledger, db := newLedgerDB(t)
+	source := &crashingSource{MemorySource: queue.NewMemorySource(), crashed: true}
+	var mu sync.Mutex
+	calls := map[string]int{}
+	pay := func(ctx context.Context, msg queue.Message) error {
+		mu.Lock()
+		calls[string(msg.Body)]++
+		mu.Unlock()
+		_, err := database.Conn(ctx).ExecContext(ctx, "INSERT INTO payments (order_id) VALUES (?)", string(msg.Body))
+		return err
+	}
+	newConsumer := func() *Consumer {
+		return NewConsumer(source, pay, WithConsumerName("payments"), WithConcurrency(2), WithMessageTx(db),
+			WithIdempotency("orders/billing", database.DriverMySQL), WithRetry(3, time.Millisecond, time.Millisecond))
+	}
+	for i := 1; i <= 4; i++ {
+		source.Publish(fmt.Sprintf("order-%d", i), []byte(fmt.Sprintf("order-%d", i)))
+	}
+
+	// 第一个进程：全部提交，但确认之前被杀
+	stop := runConsumer(t, newConsumer())
+	require.Eventually(t, func() bool {
+		source.mu.Lock()
+		defer source.mu.Unlock()
+		return len(source.lost) == 4
+	}, 5*time.Second, 5*time.Millisecond)
+	stop()
+	assert.Equal(t, 4, source.Pending(), "未确认的消息留在待确认中")
+
+	// 重启：待确认的消息重新投递
+	source.mu.Lock()
+	source.crashed = false
+	lost := source.lost
+	source.mu.Unlock()
+	for _, msg := range lost {
+		require.NoError(t, source.Nack(context.Background(), msg))
+	}
+	source.Publish("order-5", []byte("order-5"))
+	runConsumer(t, newConsumer())
+	require.Eventually(t, func() bool { return len(source.Acked()) == 5 }, 5*time.Second, 5*time.Millisecond)
+
+	want := map[string]int{"order-1": 1, "order-2": 1, "order-3": 1, "order-4": 1, "order-5": 1}
+	assert.Equal(t, want, ledger.paid(), "重新投递的消息没有重复扣款")
+	mu.Lock()
+	assert.Equal(t, want, calls, "已记录的消息不再调用处理函数")
+	mu.Unlock()
+	assert.Zero(t, source.Pending())
+	assert.Equal(t, 9, ledger.inserts, "每次投递只有一次消费记录的写入")
+}
+
+func TestConsumer_IdempotencyRollbackKeepsMessageRetryable(t *testing.T) {
+	ledger, db := newLedgerDB(t)
+	source := queue.NewMemorySource()
+	failed := false
+	consumer := NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+		if _, err := database.Conn(ctx).ExecContext(ctx, "INSERT INTO payments (order_id) VALUES (?)", string(msg.Body)); err != nil {
+			return err
+		}
+		if !failed {
+			failed = true
+			return errors.New("下游超时")
+		}
+		return nil
+	}, WithConcurrency(1), WithMessageTx(db), WithIdempotency("orders/billing", database.DriverPostgreSQL),
+		WithRetry(3, time.Millisecond, time.Millisecond))
+
+	source.Publish("", []byte("order-1"))
+	runConsumer(t, consumer)
+	require.Eventually(t, func() bool { return len(source.Acked()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]int{"order-1": 1}, ledger.paid(), "失败回滚连同消费记录一起撤销，重试正常处理")
+	assert.Empty(t, source.Dead())
+}
+
+func TestConsumer_PurgeLedger(t *testing.T) {
+	ledger, db := newLedgerDB(t)
+	ledger.record("orders/billing/1-0", time.Now().Add(-48*time.Hour))
+	ledger.record("orders/billing/2-0", time.Now())
+	ledger.record("other/g/1-0", time.Now().Add(-48*time.Hour))
+
+	consumer := NewConsumer(queue.NewMemorySource(), nil, WithMessageTx(db),
+		WithLedgerRetention(24*time.Hour), WithIdempotency("orders/billing", database.DriverMySQL))
+	n, err := consumer.PurgeLedger(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "只删除本消费方超过保留期的记录")
+	assert.Contains(t, ledger.processed(), "orders/billing/2-0")
+	assert.Contains(t, ledger.processed(), "other/g/1-0")
+	require.Len(t, ledger.purges, 2)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), ledger.purges[1].(time.Time), time.Minute, "选项顺序不影响保留期")
+
+	n, err = NewConsumer(queue.NewMemorySource(), nil).PurgeLedger(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
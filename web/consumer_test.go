@@ -0,0 +1,272 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/CenJIl/base/web/queue"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runConsumer 以生命周期组件的方式启动消费者，返回停止函数
+func runConsumer(t *testing.T, consumer *Consumer) func() {
+	t.Helper()
+	component := consumer.Component()
+	require.NoError(t, component.Start(context.Background()))
+	stopped := false
+	stop := func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, component.Stop(ctx), "消费者应在预算内排空")
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestConsumer_OrderWithinKeyAndRetry(t *testing.T) {
+	source := queue.NewMemorySource()
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	failures := map[string]int{}
+	requestIDs := map[string]bool{}
+	consumer := NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		requestIDs[middleware.GetRequestIDFromContext(ctx)] = true
+		// a-2 前两次失败、b-1 第一次 panic：重试期间同一个键后面的消息必须等待
+		switch body := string(msg.Body); {
+		case body == "a-2" && failures[body] < 2:
+			failures[body]++
+			return errors.New("暂时失败")
+		case body == "b-1" && failures[body] < 1:
+			failures[body]++
+			panic("boom")
+		}
+		handled[msg.Key] = append(handled[msg.Key], string(msg.Body))
+		return nil
+	}, WithConsumerName("order-test"), WithConcurrency(3), WithRetry(5, time.Millisecond, 5*time.Millisecond))
+
+	var want = map[string][]string{}
+	for i := 1; i <= 5; i++ {
+		for _, key := range []string{"a", "b", "c"} {
+			body := fmt.Sprintf("%s-%d", key, i)
+			source.Publish(key, []byte(body))
+			want[key] = append(want[key], body)
+		}
+	}
+	stop := runConsumer(t, consumer)
+
+	require.Eventually(t, func() bool { return len(source.Acked()) == 15 }, 5*time.Second, 5*time.Millisecond)
+	stop()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, want, handled, "同一个键按发布顺序处理")
+	assert.Equal(t, 2, failures["a-2"])
+	assert.Equal(t, 1, failures["b-1"], "panic 按失败重试")
+	assert.Len(t, requestIDs, 15, "每条消息有自己的请求 ID")
+	assert.NotContains(t, requestIDs, "")
+	assert.Empty(t, source.Dead())
+	assert.Zero(t, source.Pending())
+}
+
+func TestConsumer_DeadLetterAfterMaxAttempts(t *testing.T) {
+	source := queue.NewMemorySource()
+	var mu sync.Mutex
+	var handled []string
+	attempts := 0
+	consumer := NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(msg.Body) == "poison" {
+			attempts++
+			return errors.New("无法解析")
+		}
+		handled = append(handled, string(msg.Body))
+		return nil
+	}, WithConcurrency(1), WithRetry(3, time.Millisecond, time.Millisecond))
+
+	source.Publish("k", []byte("poison"))
+	source.Publish("k", []byte("after"))
+	runConsumer(t, consumer)
+
+	require.Eventually(t, func() bool { return len(source.Acked()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	dead := source.Dead()
+	require.Len(t, dead, 1)
+	assert.Equal(t, "poison", string(dead[0].Body))
+	assert.Equal(t, "无法解析", dead[0].Error)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []string{"after"}, handled, "死信之后继续处理同一个键的后续消息")
+}
+
+func TestConsumer_CleanDrain(t *testing.T) {
+	source := queue.NewMemorySource()
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	consumer := NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+		started <- string(msg.Body)
+		<-release
+		return nil
+	}, WithConcurrency(1), WithPrefetch(3))
+
+	for i := 1; i <= 5; i++ {
+		source.Publish("k", []byte(fmt.Sprintf("m-%d", i)))
+	}
+	stop := runConsumer(t, consumer)
+	assert.Equal(t, "m-1", <-started)
+	require.Eventually(t, func() bool { return source.Queued() == 2 }, time.Second, time.Millisecond, "预取上限 3")
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("正在处理的消息完成之前不应停止")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+
+	acked := source.Acked()
+	require.Len(t, acked, 1, "只完成正在处理的消息，预取的消息不再开始")
+	assert.Equal(t, "m-1", string(acked[0].Body))
+	assert.Zero(t, source.Pending(), "预取未处理的消息交还队列")
+	assert.Equal(t, 4, source.Queued())
+	assert.Empty(t, started)
+}
+
+func TestConsumer_MessageTxWithoutDatabase(t *testing.T) {
+	source := queue.NewMemorySource()
+	called := false
+	consumer := NewConsumer(source, func(ctx context.Context, msg queue.Message) error {
+		called = true
+		return nil
+	}, WithMessageTx(nil), WithRetry(1, time.Millisecond, time.Millisecond))
+
+	source.Publish("", []byte("x"))
+	runConsumer(t, consumer)
+	require.Eventually(t, func() bool { return len(source.Dead()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.False(t, called, "无法开启事务时不调用处理函数")
+}
+
+func TestConsumer_RedisStream(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过 Redis 集成测试")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(ctx).Err())
+	config := queue.RedisStreamConfig{Stream: "consumer-test:" + uuid.NewString(), Group: "g", Consumer: "c1", BlockMs: 100}
+	t.Cleanup(func() { client.Del(ctx, config.Stream, config.Stream+":dead") })
+
+	var mu sync.Mutex
+	var handled []string
+	failed := false
+	handler := func(ctx context.Context, msg queue.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(msg.Body) == "k-2" && !failed {
+			failed = true
+			return errors.New("暂时失败")
+		}
+		if string(msg.Body) == "poison" {
+			return errors.New("无法解析")
+		}
+		handled = append(handled, string(msg.Body))
+		return nil
+	}
+	for _, body := range []string{"k-1", "k-2", "k-3", "poison"} {
+		_, err := queue.Publish(ctx, client, config.Stream, "k", []byte(body), map[string]string{"request_id": "req-" + body})
+		require.NoError(t, err)
+	}
+
+	stop := runConsumer(t, NewConsumer(queue.NewRedisStream(client, config), handler,
+		WithConcurrency(2), WithRetry(2, time.Millisecond, time.Millisecond)))
+	require.Eventually(t, func() bool {
+		n, _ := client.XLen(ctx, config.Stream+":dead").Result()
+		return n == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stop()
+
+	mu.Lock()
+	assert.Equal(t, []string{"k-1", "k-2", "k-3"}, handled)
+	mu.Unlock()
+	pending, err := client.XPending(ctx, config.Stream, config.Group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "全部确认")
+}
+
+func TestConsumer_RedisStreamMaxDeliveries(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 TEST_REDIS_ADDR，跳过 Redis 集成测试")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping(ctx).Err())
+	config := queue.RedisStreamConfig{Stream: "consumer-test:" + uuid.NewString(), Group: "g", Consumer: "c2",
+		BlockMs: 100, ClaimIdleMs: 1, MaxDeliveries: 3}
+	t.Cleanup(func() { client.Del(ctx, config.Stream, config.Stream+":dead") })
+
+	// 毒消息让 c1 反复崩溃：投递给 c1 后又被接手两次，一直没有确认
+	require.NoError(t, client.XGroupCreateMkStream(ctx, config.Stream, config.Group, "0").Err())
+	poison, err := queue.Publish(ctx, client, config.Stream, "k", []byte("poison"), nil)
+	require.NoError(t, err)
+	require.NoError(t, client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: config.Group, Consumer: "c1",
+		Streams: []string{config.Stream, ">"}, Count: 1}).Err())
+	for range 2 {
+		require.NoError(t, client.XClaim(ctx, &redis.XClaimArgs{Stream: config.Stream, Group: config.Group,
+			Consumer: "c1", Messages: []string{poison}}).Err())
+	}
+	_, err = queue.Publish(ctx, client, config.Stream, "k", []byte("ok"), nil)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var handled []string
+	time.Sleep(5 * time.Millisecond)
+	stop := runConsumer(t, NewConsumer(queue.NewRedisStream(client, config), func(ctx context.Context, msg queue.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, string(msg.Body))
+		return nil
+	}))
+	require.Eventually(t, func() bool {
+		n, _ := client.XLen(ctx, config.Stream+":dead").Result()
+		return n == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stop()
+
+	mu.Lock()
+	assert.Equal(t, []string{"ok"}, handled, "超过投递上限的消息不交给处理函数")
+	mu.Unlock()
+	dead, err := client.XRange(ctx, config.Stream+":dead", "-", "+").Result()
+	require.NoError(t, err)
+	assert.Equal(t, poison, dead[0].Values["source_id"])
+	pending, err := client.XPending(ctx, config.Stream, config.Group).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "死信消息已确认")
+}
@@ -0,0 +1,195 @@
+package contract
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// 数值比较：$>=1、$<100
+	numericRule = regexp.MustCompile(`^\$\s*(>=|>|<=|<)\s*(-?[0-9]+(?:\.[0-9]+)?)$`)
+	// 长度比较：len($)>0、len($)<=64
+	lengthRule = regexp.MustCompile(`^len\(\$\)\s*(>=|>|<=|<)\s*([0-9]+)$`)
+)
+
+// boundary 一条边界用例
+type boundary struct {
+	rule   string // 如 "quantity>=1"
+	value  string // 边界值的 JSON 表示
+	inside bool
+	body   []byte
+}
+
+// boundaries 根据请求类型的 vd 标签生成边界用例
+//
+// 只处理顶层 JSON 字段上由 && 连接的简单比较，其他表达式忽略
+func boundaries(request any) []boundary {
+	rv := reflect.ValueOf(request)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var out []boundary
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("vd")
+		name := jsonName(field)
+		if tag == "" || name == "" || !field.IsExported() {
+			continue
+		}
+		for _, expr := range strings.Split(tag, "&&") {
+			expr = strings.TrimSpace(expr)
+			for _, v := range boundaryValues(expr, rv.Field(i)) {
+				out = append(out, v.withField(request, name))
+			}
+		}
+	}
+	return out
+}
+
+// boundaryValue 边界值
+type boundaryValue struct {
+	rule   string
+	value  any
+	inside bool
+}
+
+// withField 把示例请求中的字段替换为边界值
+func (v boundaryValue) withField(request any, name string) boundary {
+	raw, _ := json.Marshal(request)
+	fields := map[string]json.RawMessage{}
+	_ = json.Unmarshal(raw, &fields)
+	value, _ := json.Marshal(v.value)
+	fields[name] = value
+	body, _ := json.Marshal(fields)
+	return boundary{rule: name + v.rule, value: string(value), inside: v.inside, body: body}
+}
+
+// boundaryValues 单个比较表达式的边界内外取值
+func boundaryValues(expr string, field reflect.Value) []boundaryValue {
+	for field.Kind() == reflect.Pointer {
+		field = reflect.New(field.Type().Elem()).Elem()
+	}
+	if m := numericRule.FindStringSubmatch(expr); m != nil {
+		n, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return nil
+		}
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n != math.Trunc(n) {
+				return nil
+			}
+			inside, outside := intBounds(m[1], int64(n))
+			if field.Kind() >= reflect.Uint && (inside < 0 || outside < 0) {
+				return nil
+			}
+			return pair(m[1]+m[2], inside, outside)
+		case reflect.Float32, reflect.Float64:
+			inside, outside := floatBounds(m[1], n)
+			return pair(m[1]+m[2], inside, outside)
+		}
+		return nil
+	}
+	if m := lengthRule.FindStringSubmatch(expr); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil
+		}
+		in, out := intBounds(m[1], int64(n))
+		rule := " len" + m[1] + m[2]
+		var values []boundaryValue
+		for _, length := range []struct {
+			n      int64
+			inside bool
+		}{{in, true}, {out, false}} {
+			if length.n < 0 {
+				continue
+			}
+			value, ok := withLength(field, int(length.n))
+			if !ok {
+				return nil
+			}
+			values = append(values, boundaryValue{rule: rule, value: value, inside: length.inside})
+		}
+		return values
+	}
+	return nil
+}
+
+func pair(rule string, inside, outside any) []boundaryValue {
+	return []boundaryValue{{rule: rule, value: inside, inside: true}, {rule: rule, value: outside}}
+}
+
+// intBounds 整数比较的边界内外取值
+func intBounds(op string, n int64) (inside, outside int64) {
+	switch op {
+	case ">=":
+		return n, n - 1
+	case ">":
+		return n + 1, n
+	case "<=":
+		return n, n + 1
+	default: // <
+		return n - 1, n
+	}
+}
+
+// floatBounds 浮点数比较的边界内外取值（相邻的可表示值）
+func floatBounds(op string, n float64) (inside, outside float64) {
+	switch op {
+	case ">=":
+		return n, math.Nextafter(n, math.Inf(-1))
+	case ">":
+		return math.Nextafter(n, math.Inf(1)), n
+	case "<=":
+		return n, math.Nextafter(n, math.Inf(1))
+	default: // <
+		return math.Nextafter(n, math.Inf(-1)), n
+	}
+}
+
+// withLength 构造指定长度的字符串或切片（切片重复示例中的第一个元素）
+func withLength(field reflect.Value, n int) (any, bool) {
+	switch field.Kind() {
+	case reflect.String:
+		return strings.Repeat("a", n), true
+	case reflect.Slice:
+		if field.Len() == 0 {
+			return nil, false
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = field.Index(0).Interface()
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// jsonName 字段的 JSON 名（json:"-" 或非 JSON 字段返回空）
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	if field.Tag.Get("query") != "" || field.Tag.Get("path") != "" || field.Tag.Get("header") != "" {
+		return ""
+	}
+	return field.Name
+}
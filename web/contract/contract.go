@@ -0,0 +1,249 @@
+// Package contract 根据路由表上声明的请求示例生成并执行 API 契约测试
+//
+// 每条带 web.Example 的路由生成以下用例：
+//   - 示例请求：状态码符合预期，响应是统一格式（code/message/data），data 能严格解码为声明的类型
+//   - 需要认证的路由不带凭证：401 统一格式
+//   - 请求体格式错误：400 统一格式
+//   - 请求类型上的 vd 标签（$>=N、len($)<=N 等）：边界内不被校验拒绝，边界外 400
+//
+// 另外对未注册的路径生成一条 404 用例；没有示例的路由列为未覆盖。
+// 原始路由（web.RawHandler）不使用统一格式，接口文档由外部维护，不生成用例，单独列出
+//
+// 使用方式（三行 TestMain，CONTRACT_JUNIT 指定 JUnit XML 输出路径）：
+//
+//	func TestMain(m *testing.M) {
+//	    contract.Main(m, newTestEngine, contract.WithAuth(testToken))
+//	}
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/CenJIl/base/web"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 用例类型
+const (
+	KindSuccess      = "success"      // 示例请求
+	KindUnauthorized = "unauthorized" // 缺少认证
+	KindMalformed    = "malformed"    // 请求体格式错误
+	KindBoundary     = "boundary"     // 校验边界
+	KindNotFound     = "not_found"    // 未注册的路由
+)
+
+// 响应格式
+const (
+	EnvelopeSuccess = "success" // code = 0
+	EnvelopeError   = "error"   // code != 0 且 message 非空
+)
+
+// notFoundPath 404 用例请求的路径
+const notFoundPath = "/__contract__/not-found"
+
+// Expect 用例的断言
+type Expect struct {
+	Status    int          // 期望的状态码（0 表示不校验）
+	NotStatus int          // 不允许的状态码（0 表示不校验）
+	Envelope  string       // 期望的响应格式（空表示只校验是统一格式）
+	Data      reflect.Type // data 需要严格解码成的类型（nil 表示不校验）
+}
+
+// Case 一条契约测试用例
+type Case struct {
+	Route  string // 路由，如 "POST /api/orders/:id"
+	Name   string
+	Kind   string
+	Method string
+	Path   string // 实际请求路径（含查询字符串）
+	Body   []byte
+	Auth   bool // 是否携带认证
+	Expect Expect
+}
+
+// Suite 生成的契约测试
+type Suite struct {
+	Cases     []Case
+	Uncovered []string // 没有示例的路由
+	External  []string // 原始路由（文档由外部维护，不校验响应格式）
+}
+
+// GenerateTests 根据路由表生成契约测试用例
+func GenerateTests(routes []web.RouteInfo) *Suite {
+	suite := &Suite{}
+	for _, r := range routes {
+		name := r.Method + " " + r.Path
+		if r.Raw != nil {
+			suite.External = append(suite.External, name)
+			continue
+		}
+		if len(r.Examples) == 0 {
+			suite.Uncovered = append(suite.Uncovered, name)
+			continue
+		}
+		protected := r.Auth == web.AuthRequired || (r.GroupAuth == web.AuthRequired && r.Auth != web.AuthPublic)
+		for i, ex := range r.Examples {
+			suite.Cases = append(suite.Cases, exampleCases(name, r, ex, i, protected)...)
+		}
+	}
+	sort.Strings(suite.Uncovered)
+	sort.Strings(suite.External)
+	if len(routes) > 0 {
+		suite.Cases = append(suite.Cases, Case{
+			Route:  "GET " + notFoundPath,
+			Name:   "unknown route",
+			Kind:   KindNotFound,
+			Method: consts.MethodGet,
+			Path:   notFoundPath,
+			Expect: Expect{Status: consts.StatusNotFound, Envelope: EnvelopeError},
+		})
+	}
+	return suite
+}
+
+// exampleCases 一个示例生成的全部用例
+func exampleCases(route string, r web.RouteInfo, ex web.Example, index int, protected bool) []Case {
+	name := ex.Name
+	if name == "" {
+		name = fmt.Sprintf("example %d", index+1)
+	}
+	reqPath := ex.Path
+	if reqPath == "" {
+		reqPath = fillParams(r.Path)
+	}
+	if ex.Query != "" {
+		reqPath += "?" + ex.Query
+	}
+	status := ex.Status
+	if status == 0 {
+		status = consts.StatusOK
+	}
+
+	base := Case{Route: route, Method: r.Method, Path: reqPath, Auth: protected}
+	var body []byte
+	if ex.Request != nil {
+		body, _ = json.Marshal(ex.Request)
+	}
+
+	success := base
+	success.Name, success.Kind, success.Body = name, KindSuccess, body
+	success.Expect = Expect{Status: status}
+	if status < consts.StatusBadRequest {
+		success.Expect.Envelope = EnvelopeSuccess
+		if ex.Response != nil {
+			success.Expect.Data = reflect.TypeOf(ex.Response)
+		}
+	} else {
+		success.Expect.Envelope = EnvelopeError
+	}
+	cases := []Case{success}
+
+	if protected {
+		c := base
+		c.Name, c.Kind, c.Body, c.Auth = name+": missing auth", KindUnauthorized, body, false
+		c.Expect = Expect{Status: consts.StatusUnauthorized, Envelope: EnvelopeError}
+		cases = append(cases, c)
+	}
+	if ex.Request == nil {
+		return cases
+	}
+
+	malformed := base
+	malformed.Name, malformed.Kind, malformed.Body = name+": malformed body", KindMalformed, []byte(`{"`)
+	malformed.Expect = Expect{Status: consts.StatusBadRequest, Envelope: EnvelopeError}
+	cases = append(cases, malformed)
+
+	for _, b := range boundaries(ex.Request) {
+		c := base
+		c.Kind = KindBoundary
+		c.Body = b.body
+		if b.inside {
+			c.Name = fmt.Sprintf("%s: %s inside (%s)", name, b.rule, b.value)
+			c.Expect = Expect{NotStatus: consts.StatusBadRequest}
+		} else {
+			c.Name = fmt.Sprintf("%s: %s outside (%s)", name, b.rule, b.value)
+			c.Expect = Expect{Status: consts.StatusBadRequest, Envelope: EnvelopeError}
+		}
+		cases = append(cases, c)
+	}
+	return cases
+}
+
+// fillParams 把路径参数替换为示例值：/users/:id/*rest -> /users/1/x
+func fillParams(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "1"
+		} else if strings.HasPrefix(s, "*") {
+			segments[i] = "x"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// checkResponse 按断言校验响应
+func checkResponse(expect Expect, status int, body []byte) error {
+	if expect.Status != 0 && status != expect.Status {
+		return fmt.Errorf("状态码期望 %d，实际 %d: %s", expect.Status, status, abbreviate(body))
+	}
+	if expect.NotStatus != 0 && status == expect.NotStatus {
+		return fmt.Errorf("状态码不应为 %d: %s", expect.NotStatus, abbreviate(body))
+	}
+	if expect.Status == 0 && expect.Envelope == "" {
+		return nil
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("响应不是 JSON 对象: %s", abbreviate(body))
+	}
+	for _, field := range []string{"code", "message"} {
+		if _, ok := envelope[field]; !ok {
+			return fmt.Errorf("响应缺少 %s 字段: %s", field, abbreviate(body))
+		}
+	}
+	var code int
+	var message string
+	if err := json.Unmarshal(envelope["code"], &code); err != nil {
+		return fmt.Errorf("code 不是整数: %s", envelope["code"])
+	}
+	_ = json.Unmarshal(envelope["message"], &message)
+
+	switch expect.Envelope {
+	case EnvelopeSuccess:
+		if code != 0 {
+			return fmt.Errorf("期望 code = 0，实际 %d（%s）", code, message)
+		}
+		if _, ok := envelope["data"]; !ok {
+			return fmt.Errorf("响应缺少 data 字段: %s", abbreviate(body))
+		}
+	case EnvelopeError:
+		if code == 0 || message == "" {
+			return fmt.Errorf("期望错误响应（code != 0 且 message 非空），实际 code = %d, message = %q", code, message)
+		}
+	}
+
+	if expect.Data != nil {
+		decoder := json.NewDecoder(bytes.NewReader(envelope["data"]))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(reflect.New(expect.Data).Interface()); err != nil {
+			return fmt.Errorf("data 无法解码为 %s: %v", expect.Data, err)
+		}
+	}
+	return nil
+}
+
+// abbreviate 截断过长的响应体
+func abbreviate(body []byte) string {
+	const limit = 200
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,185 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type createOrderReq struct {
+	ProductID int64    `json:"productId" vd:"$>0"`
+	Quantity  int      `json:"quantity" vd:"$>=1 && $<=100"`
+	Price     float64  `json:"price" vd:"$>=0.5"`
+	Note      string   `json:"note" vd:"len($)<=10"`
+	Tags      []string `json:"tags" vd:"len($)>0"`
+}
+
+type orderResp struct {
+	ID       int64 `json:"id"`
+	Quantity int   `json:"quantity"`
+}
+
+func newContractEngine(t *testing.T, drift bool) *route.Engine {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "contract-secret"
+	require.NoError(t, jwt.Init(conf))
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.NoRoute(web.NotFoundHandler())
+	r := web.NewRouter(engine)
+	api := r.Group("/api", jwt.Middleware()).RequireAuth()
+
+	api.WithExamples(web.Example{
+		Name:     "create order",
+		Request:  createOrderReq{ProductID: 1, Quantity: 2, Price: 9.9, Note: "gift", Tags: []string{"new"}},
+		Response: orderResp{},
+	}).POST("/orders", web.WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var req createOrderReq
+		if err := web.Bind(c, &req); err != nil {
+			return err
+		}
+		c.JSON(200, web.Success(orderResp{ID: 7, Quantity: req.Quantity}))
+		return nil
+	}))
+
+	api.WithExamples(web.Example{Response: orderResp{}}).GET("/orders/:id", func(ctx context.Context, c *app.RequestContext) {
+		if drift {
+			c.JSON(200, web.Success(map[string]any{"id": 1, "quantity": 2, "qty": 2}))
+			return
+		}
+		c.JSON(200, web.Success(orderResp{ID: 1, Quantity: 2}))
+	})
+
+	r.Public().GET("/ping", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, web.Success("pong"))
+	})
+	r.Public().GET("/feed.xml", web.RawHandler(func(ctx context.Context, c *app.RequestContext) {
+		c.Data(200, "application/rss+xml", []byte("<rss/>"))
+	}, web.RawReason("RSS 阅读器需要 XML")))
+	return engine
+}
+
+func contractAuth(t *testing.T) Option {
+	return WithAuth(func() ut.Header {
+		token, _, err := jwt.GenerateToken(map[string]interface{}{"identity": "contract"})
+		require.NoError(t, err)
+		return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+	})
+}
+
+func caseNames(cases []Case) []string {
+	names := make([]string, 0, len(cases))
+	for _, c := range cases {
+		names = append(names, c.Kind+" "+c.Name)
+	}
+	return names
+}
+
+func TestGenerateTests(t *testing.T) {
+	engine := newContractEngine(t, false)
+	routes, _ := web.ValidateRoutes(engine)
+	suite := GenerateTests(routes)
+
+	assert.Equal(t, []string{"GET /ping"}, suite.Uncovered)
+	assert.Equal(t, []string{"GET /feed.xml"}, suite.External)
+	names := caseNames(suite.Cases)
+	for _, want := range []string{
+		"success create order",
+		"unauthorized create order: missing auth",
+		"malformed create order: malformed body",
+		"boundary create order: productId>0 inside (1)",
+		"boundary create order: productId>0 outside (0)",
+		"boundary create order: quantity<=100 outside (101)",
+		"boundary create order: price>=0.5 outside (0.49999999999999994)",
+		"boundary create order: note len<=10 outside (\"aaaaaaaaaaa\")",
+		"boundary create order: tags len>0 outside ([])",
+		"success example 1",
+		"unauthorized example 1: missing auth",
+		"not_found unknown route",
+	} {
+		assert.Contains(t, names, want)
+	}
+	// 没有请求体的示例不生成格式错误和边界用例
+	for _, c := range suite.Cases {
+		if c.Route == "GET /api/orders/:id" {
+			assert.Contains(t, []string{KindSuccess, KindUnauthorized}, c.Kind)
+			assert.Equal(t, "/api/orders/1", c.Path)
+		}
+	}
+}
+
+func TestVerify_Passes(t *testing.T) {
+	engine := newContractEngine(t, false)
+	report := Verify(engine, contractAuth(t))
+	assert.Empty(t, report.Failures(), report.String())
+	assert.Greater(t, len(report.Results), 15)
+
+	routes, _ := web.ValidateRoutes(engine)
+	GenerateTests(routes).Run(t, engine, contractAuth(t))
+}
+
+func TestVerify_ReportsFailures(t *testing.T) {
+	engine := newContractEngine(t, true)
+
+	// 未提供凭证
+	report := Verify(engine)
+	require.NotEmpty(t, report.Failures())
+	assert.Contains(t, report.String(), "WithAuth")
+
+	// 响应与声明的类型不一致
+	report = Verify(engine, contractAuth(t))
+	failed := report.Failures()
+	require.Len(t, failed, 1, report.String())
+	assert.Equal(t, "GET /api/orders/:id", failed[0].Case.Route)
+	assert.Contains(t, failed[0].Err.Error(), `unknown field "qty"`)
+	assert.Contains(t, report.String(), "FAIL GET /api/orders/:id [example 1]")
+	assert.Contains(t, report.String(), "UNCOVERED GET /ping")
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJUnit(&buf))
+	xml := buf.String()
+	assert.True(t, strings.HasPrefix(xml, "<?xml"))
+	assert.Contains(t, xml, `<testsuite name="contract"`)
+	assert.Contains(t, xml, `failures="1"`)
+	assert.Contains(t, xml, `skipped="1"`)
+	assert.Contains(t, xml, `<testcase classname="GET /api/orders/:id" name="example 1"`)
+	assert.Contains(t, xml, `<failure message="data 无法解码为 contract.orderResp`)
+	assert.Contains(t, xml, `<testcase classname="GET /ping" name="uncovered"`)
+}
+
+func TestCheckResponse(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect Expect
+		status int
+		body   string
+		err    string
+	}{
+		{"ok", Expect{Status: 200, Envelope: EnvelopeSuccess}, 200, `{"code":0,"message":"success","data":null}`, ""},
+		{"wrong status", Expect{Status: 400}, 200, `{}`, "状态码期望 400"},
+		{"not envelope", Expect{Status: 404, Envelope: EnvelopeError}, 404, `404 page not found`, "不是 JSON"},
+		{"missing message", Expect{Status: 401, Envelope: EnvelopeError}, 401, `{"code":401}`, "缺少 message"},
+		{"error with code 0", Expect{Status: 400, Envelope: EnvelopeError}, 400, `{"code":0,"message":"x"}`, "期望错误响应"},
+		{"rejected inside bound", Expect{NotStatus: 400}, 400, `{"code":400,"message":"bad"}`, "不应为 400"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkResponse(tc.expect, tc.status, []byte(tc.body))
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
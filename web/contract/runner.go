@@ -0,0 +1,243 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// EnvJUnit Main 输出 JUnit XML 的路径（为空时不输出）
+const EnvJUnit = "CONTRACT_JUNIT"
+
+// Option 执行选项
+type Option func(*options)
+
+type options struct {
+	auth func() ut.Header
+}
+
+// WithAuth 需要认证的用例携带的请求头（在执行时调用，可在构建引擎后再签发令牌）
+//
+// 使用方式：
+//
+//	contract.WithAuth(func() ut.Header {
+//	    token, _, _ := jwt.GenerateToken(map[string]interface{}{"identity": "contract"})
+//	    return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+//	})
+func WithAuth(fn func() ut.Header) Option {
+	return func(o *options) {
+		o.auth = fn
+	}
+}
+
+// Result 单条用例的执行结果
+type Result struct {
+	Case     Case
+	Duration time.Duration
+	Err      error
+}
+
+// Report 执行报告
+type Report struct {
+	Results   []Result
+	Uncovered []string
+}
+
+// Failures 失败的用例
+func (r *Report) Failures() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// String 失败用例与未覆盖路由的摘要
+func (r *Report) String() string {
+	var b bytes.Buffer
+	failed := r.Failures()
+	fmt.Fprintf(&b, "contract: %d 条用例，%d 条失败，%d 条路由未覆盖\n", len(r.Results), len(failed), len(r.Uncovered))
+	for _, res := range failed {
+		fmt.Fprintf(&b, "  FAIL %s [%s]: %v\n", res.Case.Route, res.Case.Name, res.Err)
+	}
+	for _, route := range r.Uncovered {
+		fmt.Fprintf(&b, "  UNCOVERED %s\n", route)
+	}
+	return b.String()
+}
+
+// Execute 在引擎上进程内执行全部用例
+func (s *Suite) Execute(engine *route.Engine, opts ...Option) *Report {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var auth *ut.Header
+	report := &Report{Uncovered: s.Uncovered}
+	for _, c := range s.Cases {
+		if c.Auth && auth == nil && o.auth != nil {
+			h := o.auth()
+			auth = &h
+		}
+		report.Results = append(report.Results, runCase(engine, c, auth))
+	}
+	return report
+}
+
+// Run 以子测试的形式执行全部用例
+func (s *Suite) Run(t *testing.T, engine *route.Engine, opts ...Option) {
+	report := s.Execute(engine, opts...)
+	for _, res := range report.Results {
+		res := res
+		t.Run(res.Case.Route+"/"+res.Case.Name, func(t *testing.T) {
+			if res.Err != nil {
+				t.Errorf("%s [%s]: %v", res.Case.Route, res.Case.Name, res.Err)
+			}
+		})
+	}
+	for _, r := range report.Uncovered {
+		t.Logf("contract: 未覆盖的路由 %s", r)
+	}
+	for _, r := range s.External {
+		t.Logf("contract: 原始路由 %s（文档由外部维护）", r)
+	}
+}
+
+// Verify 生成并执行引擎路由表的契约测试
+func Verify(engine *route.Engine, opts ...Option) *Report {
+	routes, _ := web.ValidateRoutes(engine)
+	return GenerateTests(routes).Execute(engine, opts...)
+}
+
+// Main 在 TestMain 中执行包内测试后再执行契约测试，任一失败时以非零状态退出
+func Main(m *testing.M, build func() *route.Engine, opts ...Option) {
+	code := m.Run()
+
+	report := Verify(build(), opts...)
+	fmt.Print(report.String())
+	if path := os.Getenv(EnvJUnit); path != "" {
+		if err := writeJUnitFile(path, report); err != nil {
+			fmt.Fprintf(os.Stderr, "contract: 写入 JUnit 报告失败: %v\n", err)
+			code = 1
+		}
+	}
+	if len(report.Failures()) > 0 && code == 0 {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func writeJUnitFile(path string, report *Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJUnit(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runCase 执行单条用例
+func runCase(engine *route.Engine, c Case, auth *ut.Header) Result {
+	var headers []ut.Header
+	var body *ut.Body
+	if c.Body != nil {
+		headers = append(headers, ut.Header{Key: "Content-Type", Value: "application/json"})
+		body = &ut.Body{Body: bytes.NewReader(c.Body), Len: len(c.Body)}
+	}
+	if c.Auth {
+		if auth == nil {
+			return Result{Case: c, Err: fmt.Errorf("路由需要认证，但未通过 WithAuth 提供凭证")}
+		}
+		headers = append(headers, *auth)
+	}
+
+	start := time.Now()
+	w := ut.PerformRequest(engine, c.Method, c.Path, body, headers...)
+	resp := w.Result()
+	return Result{
+		Case:     c,
+		Duration: time.Since(start),
+		Err:      checkResponse(c.Expect, resp.StatusCode(), resp.Body()),
+	}
+}
+
+// JUnit XML 结构
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit 输出 JUnit XML（未覆盖的路由记为 skipped）
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitSuite{Name: "contract"}
+	var total time.Duration
+	for _, res := range r.Results {
+		jc := junitCase{ClassName: res.Case.Route, Name: res.Case.Name, Time: seconds(res.Duration)}
+		if res.Err != nil {
+			jc.Failure = &junitMessage{Message: res.Err.Error()}
+			suite.Failures++
+		}
+		total += res.Duration
+		suite.Cases = append(suite.Cases, jc)
+	}
+	for _, route := range r.Uncovered {
+		suite.Cases = append(suite.Cases, junitCase{
+			ClassName: route,
+			Name:      "uncovered",
+			Time:      seconds(0),
+			Skipped:   &junitMessage{Message: "路由没有声明请求示例"},
+		})
+		suite.Skipped++
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
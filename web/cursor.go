@@ -0,0 +1,359 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// cursorVersion 游标格式版本，格式变化时递增，旧版本游标被拒绝
+const cursorVersion byte = 1
+
+// cursorMACSize 游标中 HMAC-SHA256 截断后的长度
+const cursorMACSize = 16
+
+// 分页参数名
+const (
+	CursorParamAfter  = "after"  // 从该游标之后取一页（nextCursor）
+	CursorParamBefore = "before" // 从该游标之前取一页（prevCursor）
+	CursorParamLimit  = "limit"  // 每页大小
+)
+
+// CursorConfig 分页游标配置
+//
+// 多实例部署时必须配置相同的密钥，否则一个实例签发的游标在另一个实例上校验失败；
+// 未配置时每次启动随机生成（只适合单实例和开发环境）
+//
+// Example:
+//
+//	[web.cursor]
+//	key = "env:CURSOR_KEY"   # 从环境变量读取（推荐），或直接写至少 16 字节的随机字符串
+//	keyring = "cursor"       # 或使用密钥环中的密钥（支持轮换，优先于 key）
+type CursorConfig struct {
+	Key     string `toml:"key" sensitive:"true"` // HMAC 密钥
+	Keyring string `toml:"keyring"`              // 密钥环中的密钥名
+}
+
+var (
+	cursorKey         atomic.Pointer[[]byte]
+	cursorKeyring     atomic.Pointer[string] // 使用密钥环时的密钥名
+	cursorDefaultOnce sync.Once
+)
+
+// InitCursor 设置分页游标的签名密钥
+//
+// 使用密钥环时用当前版本签名，校验接受密钥环中未退役的版本（轮换后旧游标在退役前仍然有效）
+func InitCursor(config CursorConfig) error {
+	cursorKeyring.Store(nil)
+	if config.Keyring != "" {
+		if kr := keyring.Default(); kr == nil || !kr.Has(config.Keyring) {
+			return fmt.Errorf("cursor.keyring 引用的密钥 %s 不在密钥环中", config.Keyring)
+		}
+		name := config.Keyring
+		cursorKeyring.Store(&name)
+		return nil
+	}
+	key := config.Key
+	if env, ok := strings.CutPrefix(key, "env:"); ok {
+		key = os.Getenv(env)
+		if key == "" {
+			return fmt.Errorf("环境变量 %s 未设置", env)
+		}
+	}
+	if key == "" {
+		log.Warn("[Cursor] 未配置 cursor.key，使用随机密钥（重启或跨实例后旧游标失效）")
+		secret := make([]byte, 32)
+		_, _ = rand.Read(secret)
+		cursorKey.Store(&secret)
+		return nil
+	}
+	if len(key) < 16 {
+		return errors.New("cursor.key 至少需要 16 字节")
+	}
+	secret := []byte(key)
+	cursorKey.Store(&secret)
+	return nil
+}
+
+// cursorSecret 当前签名密钥（未调用 InitCursor 时使用随机密钥）
+func cursorSecret() []byte {
+	cursorDefaultOnce.Do(func() {
+		if cursorKey.Load() == nil {
+			secret := make([]byte, 32)
+			_, _ = rand.Read(secret)
+			cursorKey.CompareAndSwap(nil, &secret)
+		}
+	})
+	return *cursorKey.Load()
+}
+
+// cursorMAC 用当前签名密钥计算 HMAC
+func cursorMAC(data []byte) ([]byte, error) {
+	if name := cursorKeyring.Load(); name != nil {
+		secret, err := keyring.Default().Secret(*name)
+		if err != nil {
+			return nil, err
+		}
+		return cursorMACWith(secret, data), nil
+	}
+	return cursorMACWith(cursorSecret(), data), nil
+}
+
+func cursorMACWith(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:cursorMACSize]
+}
+
+// cursorVerify 校验 HMAC（使用密钥环时接受未退役的版本）
+func cursorVerify(mac, body []byte) bool {
+	if name := cursorKeyring.Load(); name != nil {
+		_, ok := keyring.Default().Verify(*name, func(secret []byte) bool {
+			return hmac.Equal(mac, cursorMACWith(secret, body))
+		})
+		return ok
+	}
+	return hmac.Equal(mac, cursorMACWith(cursorSecret(), body))
+}
+
+// EncodeCursor 把排序键的值编码为不透明的游标：base64url(版本 || JSON 值列表 || HMAC)
+//
+// 值按 JSON 编码（time.Time 保留纳秒），客户端无法修改游标而不被发现
+//
+// 使用方式：
+//
+//	next, err := web.EncodeCursor(last.CreatedAt, last.ID)
+func EncodeCursor(values ...any) (string, error) {
+	payload, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("编码游标失败: %w", err)
+	}
+	data := make([]byte, 0, 1+len(payload)+cursorMACSize)
+	data = append(data, cursorVersion)
+	data = append(data, payload...)
+	mac, err := cursorMAC(data)
+	if err != nil {
+		return "", fmt.Errorf("编码游标失败: %w", err)
+	}
+	data = append(data, mac...)
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor 校验游标并把排序键的值解码到 dest（顺序与 EncodeCursor 一致）
+//
+// 游标格式错误、被篡改或版本已过期时返回业务码为 InvalidCursor 的 400 异常
+//
+// 使用方式：
+//
+//	var createdAt time.Time
+//	var id int64
+//	if err := web.DecodeCursor(c.Query("after"), &createdAt, &id); err != nil {
+//	    return err
+//	}
+func DecodeCursor(token string, dest ...any) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < 1+cursorMACSize {
+		return invalidCursor("游标格式错误")
+	}
+	if data[0] != cursorVersion {
+		return invalidCursor("游标版本已过期，请从第一页重新开始")
+	}
+	body, mac := data[:len(data)-cursorMACSize], data[len(data)-cursorMACSize:]
+	if !cursorVerify(mac, body) {
+		return invalidCursor("游标校验失败")
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(body[1:], &values); err != nil || len(values) != len(dest) {
+		return invalidCursor("游标格式错误")
+	}
+	for i, v := range values {
+		if err := json.Unmarshal(v, dest[i]); err != nil {
+			return invalidCursor("游标格式错误")
+		}
+	}
+	return nil
+}
+
+func invalidCursor(msg string) *HTTPException {
+	return NewHTTPException(consts.StatusBadRequest, int(InvalidCursor), msg)
+}
+
+// Keyset 键集（seek）分页声明
+//
+// Columns 是排序列，最后一列必须唯一（通常是主键），所有列同向排序，
+// 需要有与之顺序一致的联合索引（如 INDEX(created_at, id)）
+//
+// 使用方式：
+//
+//	var eventsKeyset = web.Keyset{Columns: []string{"created_at", "id"}, Desc: true}
+//
+//	func listEvents(ctx context.Context, c *app.RequestContext) error {
+//	    var createdAt time.Time
+//	    var id int64
+//	    page, err := eventsKeyset.Parse(c, &createdAt, &id)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    query, args := "SELECT id, created_at, type FROM events", []any{}
+//	    if where, whereArgs := page.Predicate(); where != "" {
+//	        query += " WHERE " + where
+//	        args = append(args, whereArgs...)
+//	    }
+//	    query += " ORDER BY " + page.OrderBy() + " LIMIT ?"
+//	    rows, err := queryEvents(ctx, query, append(args, page.FetchLimit())...)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    c.JSON(200, web.CursorPagedSuccess(page, rows, func(e Event) []any { return []any{e.CreatedAt, e.ID} }))
+//	    return nil
+//	}
+type Keyset struct {
+	Columns      []string // 排序列
+	Desc         bool     // 是否倒序
+	DefaultLimit int      // 默认每页大小（默认 20）
+	MaxLimit     int      // 每页大小上限（默认 100）
+}
+
+// KeysetPage 一次分页请求
+type KeysetPage struct {
+	keyset   Keyset
+	limit    int
+	backward bool  // 按 before 游标向前翻页
+	values   []any // 游标中的排序键（第一页为空）
+}
+
+// Parse 读取 limit / after / before 参数，游标中的排序键解码到 dest
+func (k Keyset) Parse(c *app.RequestContext, dest ...any) (*KeysetPage, error) {
+	if len(dest) != len(k.Columns) {
+		panic(fmt.Sprintf("web.Keyset: 排序列 %d 个，解码目标 %d 个", len(k.Columns), len(dest)))
+	}
+	if k.DefaultLimit <= 0 {
+		k.DefaultLimit = 20
+	}
+	if k.MaxLimit <= 0 {
+		k.MaxLimit = 100
+	}
+	page := &KeysetPage{keyset: k, limit: k.DefaultLimit}
+	if s := c.Query(CursorParamLimit); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, BadRequestHTTP("limit 必须是正整数")
+		}
+		page.limit = min(n, k.MaxLimit)
+	}
+
+	after, before := c.Query(CursorParamAfter), c.Query(CursorParamBefore)
+	token := after
+	switch {
+	case after != "" && before != "":
+		return nil, BadRequestHTTP("after 与 before 不能同时使用")
+	case before != "":
+		token, page.backward = before, true
+	}
+	if token == "" {
+		return page, nil
+	}
+	if err := DecodeCursor(token, dest...); err != nil {
+		return nil, err
+	}
+	for _, d := range dest {
+		page.values = append(page.values, reflect.ValueOf(d).Elem().Interface())
+	}
+	return page, nil
+}
+
+// Limit 每页大小
+func (p *KeysetPage) Limit() int { return p.limit }
+
+// FetchLimit 查询的 LIMIT：多取一行用于判断是否还有更多
+func (p *KeysetPage) FetchLimit() int { return p.limit + 1 }
+
+// Backward 是否按 before 游标向前翻页
+func (p *KeysetPage) Backward() bool { return p.backward }
+
+// Predicate 游标条件，如 "(created_at, id) > (?, ?)"；第一页返回空字符串
+func (p *KeysetPage) Predicate() (string, []any) {
+	if len(p.values) == 0 {
+		return "", nil
+	}
+	op := ">"
+	if p.keyset.Desc != p.backward {
+		op = "<"
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.values)), ", ")
+	return fmt.Sprintf("(%s) %s (%s)", strings.Join(p.keyset.Columns, ", "), op, placeholders), p.values
+}
+
+// OrderBy 查询的排序（向前翻页时与展示顺序相反，CursorPagedSuccess 负责翻转回来）
+func (p *KeysetPage) OrderBy() string {
+	dir := "ASC"
+	if p.keyset.Desc != p.backward {
+		dir = "DESC"
+	}
+	parts := make([]string, len(p.keyset.Columns))
+	for i, col := range p.keyset.Columns {
+		parts[i] = col + " " + dir
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CursorPagedData 游标分页数据
+type CursorPagedData struct {
+	Items      any    `json:"items"`                // 数据列表
+	NextCursor string `json:"nextCursor,omitempty"` // 下一页（?after=）
+	PrevCursor string `json:"prevCursor,omitempty"` // 上一页（?before=）
+	HasMore    bool   `json:"hasMore"`              // 翻页方向上是否还有更多
+}
+
+// CursorPagedSuccess 游标分页成功响应（PagedSuccess 的键集分页版本）
+//
+// rows 是按 page.OrderBy() 查询、LIMIT page.FetchLimit() 的结果，key 返回一行的排序键（顺序与 Keyset.Columns 一致）
+func CursorPagedSuccess[T any](page *KeysetPage, rows []T, key func(T) []any) Result {
+	hasMore := len(rows) > page.limit
+	if hasMore {
+		rows = rows[:page.limit]
+	}
+	if page.backward {
+		rows = slices.Clone(rows)
+		slices.Reverse(rows)
+	}
+	if rows == nil {
+		rows = []T{}
+	}
+
+	data := CursorPagedData{Items: rows, HasMore: hasMore}
+	if len(rows) > 0 {
+		first, last := key(rows[0]), key(rows[len(rows)-1])
+		// 向后翻页时前面有数据当且仅当带了游标；向前翻页时后面一定有数据（游标本身所在的行）
+		if page.backward || hasMore {
+			data.NextCursor = mustEncodeCursor(last)
+		}
+		if (page.backward && hasMore) || (!page.backward && page.values != nil) {
+			data.PrevCursor = mustEncodeCursor(first)
+		}
+	}
+	return Success(data)
+}
+
+func mustEncodeCursor(values []any) string {
+	token, err := EncodeCursor(values...)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
@@ -0,0 +1,258 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cursorEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// eventTable 内存中的 events 表，按 Predicate / OrderBy / FetchLimit 的语义执行查询
+type eventTable struct {
+	mu     sync.Mutex
+	rows   []cursorEvent
+	nextID int64
+}
+
+func (t *eventTable) insert(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	t.rows = append(t.rows, cursorEvent{ID: t.nextID, CreatedAt: at})
+}
+
+func compareEvent(a cursorEvent, createdAt time.Time, id int64) int {
+	if c := a.CreatedAt.Compare(createdAt); c != 0 {
+		return c
+	}
+	return int(a.ID - id)
+}
+
+func (t *eventTable) query(page *KeysetPage) []cursorEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	where, args := page.Predicate()
+	var out []cursorEvent
+	for _, e := range t.rows {
+		if where != "" {
+			c := compareEvent(e, args[0].(time.Time), args[1].(int64))
+			if strings.Contains(where, ">") && c <= 0 || strings.Contains(where, "<") && c >= 0 {
+				continue
+			}
+		}
+		out = append(out, e)
+	}
+	desc := strings.Contains(page.OrderBy(), "DESC")
+	slices.SortFunc(out, func(a, b cursorEvent) int {
+		c := compareEvent(a, b.CreatedAt, b.ID)
+		if desc {
+			return -c
+		}
+		return c
+	})
+	return out[:min(len(out), page.FetchLimit())]
+}
+
+type cursorResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		Items      []cursorEvent `json:"items"`
+		NextCursor string        `json:"nextCursor"`
+		PrevCursor string        `json:"prevCursor"`
+		HasMore    bool          `json:"hasMore"`
+	} `json:"data"`
+}
+
+func newCursorEngine(table *eventTable) *route.Engine {
+	keyset := Keyset{Columns: []string{"created_at", "id"}, Desc: true}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/events", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var createdAt time.Time
+		var id int64
+		page, err := keyset.Parse(c, &createdAt, &id)
+		if err != nil {
+			return err
+		}
+		c.JSON(200, CursorPagedSuccess(page, table.query(page), func(e cursorEvent) []any {
+			return []any{e.CreatedAt, e.ID}
+		}))
+		return nil
+	}))
+	return engine
+}
+
+func getEvents(t *testing.T, engine *route.Engine, query string) (int, cursorResponse) {
+	t.Helper()
+	w := ut.PerformRequest(engine, "GET", "/events?"+query, nil)
+	var resp cursorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w.Code, resp
+}
+
+func TestCursor_TraversalWithConcurrentInserts(t *testing.T) {
+	require.NoError(t, InitCursor(CursorConfig{Key: "cursor-test-key-0123456789"}))
+	base := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	table := &eventTable{}
+	for i := range 25 {
+		// 每个时间戳两行，id 决定同一时刻内的顺序
+		table.insert(base.Add(time.Duration(i/2) * time.Second))
+	}
+	original := map[int64]bool{}
+	for _, e := range table.rows {
+		original[e.ID] = true
+	}
+	engine := newCursorEngine(table)
+
+	inOrder := func(items []cursorEvent) bool {
+		for i := 1; i < len(items); i++ {
+			if compareEvent(items[i-1], items[i].CreatedAt, items[i].ID) <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// 向后翻页，每翻一页在最新和最旧两端各插入新行
+	var forward []cursorEvent
+	var pages [][]cursorEvent
+	query := "limit=4"
+	var last cursorResponse
+	for n := 0; ; n++ {
+		code, resp := getEvents(t, engine, query)
+		require.Equal(t, 200, code)
+		pages = append(pages, resp.Data.Items)
+		forward = append(forward, resp.Data.Items...)
+		if n == 0 {
+			assert.Empty(t, resp.Data.PrevCursor, "第一页没有上一页")
+		} else {
+			assert.NotEmpty(t, resp.Data.PrevCursor)
+		}
+		table.insert(base.Add(time.Hour + time.Duration(n)*time.Second))
+		table.insert(base.Add(-time.Hour - time.Duration(n)*time.Second))
+		last = resp
+		if !resp.Data.HasMore {
+			assert.Empty(t, resp.Data.NextCursor)
+			break
+		}
+		query = "limit=4&after=" + url.QueryEscape(resp.Data.NextCursor)
+	}
+	assert.True(t, inOrder(forward), "向后翻页保持排序、没有重复")
+	seen := map[int64]bool{}
+	for _, e := range forward {
+		seen[e.ID] = true
+	}
+	for id := range original {
+		assert.True(t, seen[id], "原有的行 %d 不应被跳过", id)
+	}
+
+	// 从最后一页向前翻页：原有行的分页与向后翻页时一致
+	var backward []cursorEvent
+	query = "limit=4&before=" + url.QueryEscape(last.Data.PrevCursor)
+	for i := len(pages) - 2; ; i-- {
+		code, resp := getEvents(t, engine, query)
+		require.Equal(t, 200, code)
+		assert.NotEmpty(t, resp.Data.NextCursor)
+		if i >= 0 {
+			assert.Equal(t, pages[i], resp.Data.Items, "第 %d 页", i+1)
+		}
+		backward = append(slices.Clone(resp.Data.Items), backward...)
+		if !resp.Data.HasMore {
+			break
+		}
+		query = "limit=4&before=" + url.QueryEscape(resp.Data.PrevCursor)
+	}
+	assert.True(t, inOrder(backward), "向前翻页保持排序、没有重复")
+	assert.Equal(t, forward[:len(forward)-len(last.Data.Items)], backward[len(backward)-(len(forward)-len(last.Data.Items)):],
+		"向前翻页看到的原有行与向后翻页一致")
+	assert.Greater(t, backward[0].CreatedAt, base, "向前翻页能看到翻页期间插入的更新的行")
+}
+
+func TestCursor_TamperRejected(t *testing.T) {
+	require.NoError(t, InitCursor(CursorConfig{Key: "cursor-test-key-0123456789"}))
+	at := time.Date(2026, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	token, err := EncodeCursor(at, int64(42))
+	require.NoError(t, err)
+	assert.NotContains(t, token, "42", "游标不透明")
+
+	var createdAt time.Time
+	var id int64
+	require.NoError(t, DecodeCursor(token, &createdAt, &id))
+	assert.True(t, at.Equal(createdAt))
+	assert.Equal(t, int64(42), id)
+
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	forged := strings.Replace(string(raw), "42", "43", 1)
+	staleVersion := append([]byte{0}, raw[1:]...)
+	for name, bad := range map[string]string{
+		"tampered":      base64.RawURLEncoding.EncodeToString([]byte(forged)),
+		"stale version": base64.RawURLEncoding.EncodeToString(staleVersion),
+		"garbage":       "not-a-cursor!",
+		"truncated":     token[:10],
+	} {
+		err := DecodeCursor(bad, &createdAt, &id)
+		var httpErr *HTTPException
+		require.ErrorAs(t, err, &httpErr, name)
+		assert.Equal(t, int(InvalidCursor), httpErr.Code, name)
+		assert.Equal(t, 400, httpErr.HTTPStatus, name)
+	}
+	assert.Error(t, DecodeCursor(token, &createdAt), "值的个数不符")
+
+	// 换了密钥之后旧游标失效
+	require.NoError(t, InitCursor(CursorConfig{Key: "another-cursor-key-0123456789"}))
+	assert.Error(t, DecodeCursor(token, &createdAt, &id))
+
+	engine := newCursorEngine(&eventTable{})
+	code, resp := getEvents(t, engine, "after="+url.QueryEscape(token))
+	assert.Equal(t, 400, code)
+	assert.Equal(t, int(InvalidCursor), resp.Code)
+
+	assert.Error(t, InitCursor(CursorConfig{Key: "short"}))
+}
+
+func TestCursor_KeyringRotation(t *testing.T) {
+	kr, err := keyring.New(keyring.Config{Keys: map[string]keyring.KeyConfig{"cursor": {Accept: 2}}})
+	require.NoError(t, err)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+	keyring.SetDefault(kr)
+	t.Cleanup(func() {
+		keyring.SetDefault(nil)
+		_ = InitCursor(CursorConfig{Key: "cursor-test-key-0123456789"})
+	})
+	require.NoError(t, InitCursor(CursorConfig{Keyring: "cursor"}))
+
+	var id int64
+	before, err := EncodeCursor(int64(1))
+	require.NoError(t, err)
+	_, err = kr.Rotate(context.Background(), "cursor")
+	require.NoError(t, err)
+	after, err := EncodeCursor(int64(1))
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+	assert.NoError(t, DecodeCursor(before, &id), "轮换窗口内旧游标仍然有效")
+	assert.NoError(t, DecodeCursor(after, &id))
+
+	_, err = kr.Rotate(context.Background(), "cursor")
+	require.NoError(t, err)
+	assert.Error(t, DecodeCursor(before, &id), "签名版本退役后失效")
+	assert.NoError(t, DecodeCursor(after, &id))
+
+	assert.Error(t, InitCursor(CursorConfig{Keyring: "missing"}))
+}
@@ -0,0 +1,576 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// maxBindParams MySQL 与 PostgreSQL 单条语句的参数个数上限
+const maxBindParams = 65535
+
+// AnonymizeRules 数据匿名化规则（把生产数据导出到预发环境时使用）
+//
+// 只处理 tables 中列出的表；列出的表中没有配置规则的列原样复制。列规则：
+//
+//	keep            原样复制
+//	null            置为 NULL
+//	mask:<策略>      mask 包的脱敏方式：phone / idcard / email / auto
+//	fake:<类型>      确定性假数据：name / phone / email / idcard / digits / string / uuid
+//
+// fake 由种子派生：同一次运行（或相同的 seed）中，同一类型的同一输入总是得到同一输出，
+// 因此不同表中同类型的关联列仍然可以 JOIN；phone / idcard / digits 保持长度且一一对应，原本唯一的值仍然唯一。
+// seed 为空时每次运行随机生成，不要把 seed 提交到代码仓库（知道 seed 可以枚举还原手机号之类的短值）
+//
+// Example:
+//
+//	batchSize = 2000                   # 每批读取与写入的行数（默认 1000）
+//
+//	[tables.users]
+//	[tables.users.columns]
+//	name = "fake:name"
+//	phone = "fake:phone"
+//	email = "fake:email"
+//	id_card = "mask:idcard"
+//	password = "null"
+//
+//	[tables.orders]
+//	key = ["id"]                       # 分批读取的排序键（默认主键）
+//
+//	[tables.login_logs]
+//	drop = true                        # 敏感表：目标库中清空，不复制数据
+//
+//	[[foreignKeys]]                    # 关联列：引用方使用被引用列的规则，保持关联
+//	column = "orders.buyer_phone"
+//	references = "users.phone"
+type AnonymizeRules struct {
+	Seed        string                    `toml:"seed" sensitive:"true"`
+	BatchSize   int                       `toml:"batchSize"`
+	Tables      map[string]AnonymizeTable `toml:"tables"`
+	ForeignKeys []AnonymizeForeignKey     `toml:"foreignKeys"`
+}
+
+// AnonymizeTable 一张表的匿名化规则
+type AnonymizeTable struct {
+	Drop    bool              `toml:"drop"`    // 敏感表：清空，不复制
+	Key     []string          `toml:"key"`     // 分批读取的排序键（唯一），默认主键
+	Columns map[string]string `toml:"columns"` // 列名 → 规则
+}
+
+// AnonymizeForeignKey 关联列（表.列）：引用方没有配置规则时使用被引用列的规则，配置了不同的规则时报错
+type AnonymizeForeignKey struct {
+	Column     string `toml:"column"`
+	References string `toml:"references"`
+}
+
+// LoadAnonymizeRules 从 TOML 文件读取匿名化规则
+func LoadAnonymizeRules(path string) (*AnonymizeRules, error) {
+	var rules AnonymizeRules
+	meta, err := toml.DecodeFile(path, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("anonymize: 解析 %s 失败: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("anonymize: %s 中有未知的配置项 %s", path, undecoded[0])
+	}
+	return &rules, nil
+}
+
+// AnonymizeProgress 匿名化进度（每批写入后报告一次，表完成时 Done 为 true）
+type AnonymizeProgress struct {
+	Table string
+	Rows  int64
+	Done  bool
+}
+
+// anonymizeConfig Anonymize 的可选配置
+type anonymizeConfig struct {
+	driver   string
+	progress func(AnonymizeProgress)
+}
+
+// AnonymizeOption Anonymize 的可选配置项
+type AnonymizeOption func(*anonymizeConfig)
+
+// WithAnonymizeDriver 指定 SQL 方言（DriverMySQL / DriverPostgreSQL），默认按源库连接池识别
+func WithAnonymizeDriver(driver string) AnonymizeOption {
+	return func(c *anonymizeConfig) { c.driver = driver }
+}
+
+// WithAnonymizeProgress 进度回调（与写入在同一个 goroutine 中调用，不要阻塞）
+func WithAnonymizeProgress(fn func(AnonymizeProgress)) AnonymizeOption {
+	return func(c *anonymizeConfig) { c.progress = fn }
+}
+
+// anonymizeTable 校验后的一张表
+type anonymizeTable struct {
+	name    string
+	drop    bool
+	columns []string     // 源库中的全部列（按列顺序）
+	rules   []columnRule // 与 columns 对应
+	key     []int        // 排序键在 columns 中的下标
+}
+
+// Anonymize 把 src 中的数据按规则匿名化后写入 dst（src 与 dst 为同一个连接池时原地改写）
+//
+// 开始前按 information_schema 校验规则：表、列、排序键不存在，规则无效，关联列规则不一致时直接返回错误，不做任何修改。
+// 复制模式下 dst 中已有同名表（结构由迁移创建），规则中的表先按外键逆序清空，再按外键顺序逐表复制：
+// 按排序键分批读取（keyset 分页，不用 OFFSET），每批在一个事务中多行 INSERT 写入；
+// PostgreSQL 写入后同步 id 序列。drop 的表只清空
+//
+// 原地模式只能在数据库的副本上使用：每批在一个事务中按排序键逐行 UPDATE 转换的列，drop 的表直接删除全部行；
+// 排序键本身不能转换
+//
+// 使用方式：
+//
+//	rules, err := database.LoadAnonymizeRules("anonymize.toml")
+//	if err != nil {
+//	    panic(err)
+//	}
+//	err = database.Anonymize(ctx, prodReplica, staging, rules,
+//	    database.WithAnonymizeProgress(func(p database.AnonymizeProgress) {
+//	        fmt.Printf("%s: %d\n", p.Table, p.Rows)
+//	    }))
+func Anonymize(ctx context.Context, src, dst *sql.DB, rules *AnonymizeRules, opts ...AnonymizeOption) error {
+	cfg := anonymizeConfig{driver: detectDriver(src)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.driver != DriverMySQL && cfg.driver != DriverPostgreSQL {
+		return fmt.Errorf("anonymize: 不支持的驱动 %q", cfg.driver)
+	}
+	inPlace := src == dst
+
+	tables, err := planAnonymize(ctx, src, cfg.driver, rules, inPlace)
+	if err != nil {
+		return err
+	}
+	if !inPlace {
+		if err := checkTarget(ctx, dst, cfg.driver, tables); err != nil {
+			return err
+		}
+	}
+	deps, err := foreignKeys(ctx, src, cfg.driver)
+	if err != nil {
+		return err
+	}
+	for _, fk := range rules.ForeignKeys {
+		from, _, _ := strings.Cut(fk.Column, ".")
+		to, _, _ := strings.Cut(fk.References, ".")
+		deps[from] = append(deps[from], to)
+	}
+	order, cycle := sortTables(tables, deps)
+	if cycle != nil {
+		return fmt.Errorf("anonymize: 表 %s 存在循环外键", strings.Join(cycle, ", "))
+	}
+
+	gen, err := newFakeGen(rules.Seed)
+	if err != nil {
+		return err
+	}
+	a := &anonymizer{src: src, dst: dst, driver: cfg.driver, gen: gen, batch: rules.BatchSize, progress: cfg.progress}
+	if a.batch <= 0 {
+		a.batch = 1000
+	}
+
+	// 被引用的表最后清空
+	for _, name := range slices.Backward(order) {
+		t := tables[name]
+		if inPlace && !t.drop {
+			continue
+		}
+		if _, err := dst.ExecContext(ctx, "DELETE FROM "+quoteIdent(a.driver, name)); err != nil {
+			return fmt.Errorf("anonymize: 清空表 %s 失败: %w", name, err)
+		}
+		if t.drop {
+			log.Infof("[Anonymize] %s 已清空", name)
+		}
+	}
+	for _, name := range order {
+		if t := tables[name]; !t.drop {
+			if err := a.run(ctx, t, inPlace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// planAnonymize 按源库的 information_schema 校验规则，返回表名 → 表
+func planAnonymize(ctx context.Context, db *sql.DB, driver string, rules *AnonymizeRules, inPlace bool) (map[string]*anonymizeTable, error) {
+	if len(rules.Tables) == 0 {
+		return nil, fmt.Errorf("anonymize: 没有配置任何表")
+	}
+	columns, err := tableColumns(ctx, db, driver)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := primaryKeys(ctx, db, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	// 显式配置的列规则，外键引用方没有配置时沿用被引用列的规则
+	explicit := map[string]columnRule{}
+	for _, name := range sortedKeys(rules.Tables) {
+		if columns[name] == nil {
+			return nil, fmt.Errorf("anonymize: 表 %s 不存在", name)
+		}
+		tr := rules.Tables[name]
+		for _, column := range sortedKeys(tr.Columns) {
+			if !slices.Contains(columns[name], column) {
+				return nil, fmt.Errorf("anonymize: 列 %s.%s 不存在", name, column)
+			}
+			r, err := parseColumnRule(tr.Columns[column])
+			if err != nil {
+				return nil, fmt.Errorf("anonymize: %s.%s: %w", name, column, err)
+			}
+			explicit[name+"."+column] = r
+		}
+	}
+	references := map[string]string{}
+	for _, fk := range rules.ForeignKeys {
+		for _, ref := range []string{fk.Column, fk.References} {
+			table, column, _ := strings.Cut(ref, ".")
+			if _, ok := rules.Tables[table]; !ok {
+				return nil, fmt.Errorf("anonymize: 外键 %s 的表未在 tables 中配置", ref)
+			}
+			if !slices.Contains(columns[table], column) {
+				return nil, fmt.Errorf("anonymize: 外键 %s 的列不存在", ref)
+			}
+		}
+		references[fk.Column] = fk.References
+	}
+	var effective func(ref string, depth int) (columnRule, error)
+	effective = func(ref string, depth int) (columnRule, error) {
+		if r, ok := explicit[ref]; ok {
+			return r, nil
+		}
+		parent, ok := references[ref]
+		if !ok {
+			return columnRule{kind: ruleKeep}, nil
+		}
+		if depth > len(references) {
+			return columnRule{}, fmt.Errorf("anonymize: 外键 %s 存在循环引用", ref)
+		}
+		return effective(parent, depth+1)
+	}
+	for _, fk := range rules.ForeignKeys {
+		want, err := effective(fk.References, 0)
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := explicit[fk.Column]; ok && r != want {
+			return nil, fmt.Errorf("anonymize: 外键 %s 的规则 %s 与 %s 的规则 %s 不一致", fk.Column, r, fk.References, want)
+		}
+	}
+
+	tables := make(map[string]*anonymizeTable, len(rules.Tables))
+	for _, name := range sortedKeys(rules.Tables) {
+		tr := rules.Tables[name]
+		t := &anonymizeTable{name: name, drop: tr.Drop, columns: columns[name]}
+		tables[name] = t
+		if t.drop {
+			continue
+		}
+		for _, column := range t.columns {
+			r, err := effective(name+"."+column, 0)
+			if err != nil {
+				return nil, err
+			}
+			t.rules = append(t.rules, r)
+		}
+		key := tr.Key
+		if len(key) == 0 {
+			key = primary[name]
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("anonymize: 表 %s 没有主键，请配置 key", name)
+		}
+		for _, column := range key {
+			i := slices.Index(t.columns, column)
+			if i < 0 {
+				return nil, fmt.Errorf("anonymize: 表 %s 的排序键 %s 不存在", name, column)
+			}
+			if inPlace && t.rules[i].kind != ruleKeep {
+				return nil, fmt.Errorf("anonymize: 原地模式不能转换排序键 %s.%s", name, column)
+			}
+			t.key = append(t.key, i)
+		}
+	}
+	return tables, nil
+}
+
+// checkTarget 校验目标库中有规则中的表和源表的全部列
+func checkTarget(ctx context.Context, db *sql.DB, driver string, tables map[string]*anonymizeTable) error {
+	columns, err := tableColumns(ctx, db, driver)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(tables) {
+		t := tables[name]
+		if columns[name] == nil {
+			return fmt.Errorf("anonymize: 目标库中表 %s 不存在", name)
+		}
+		for _, column := range t.columns {
+			if !t.drop && !slices.Contains(columns[name], column) {
+				return fmt.Errorf("anonymize: 目标库中列 %s.%s 不存在", name, column)
+			}
+		}
+	}
+	return nil
+}
+
+// tableColumns 当前库中每张表的列（按列顺序）
+func tableColumns(ctx context.Context, db *sql.DB, driver string) (map[string][]string, error) {
+	query := "SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION"
+	if driver == DriverPostgreSQL {
+		query = "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema() ORDER BY table_name, ordinal_position"
+	}
+	return queryColumns(ctx, db, query)
+}
+
+// primaryKeys 当前库中每张表的主键列
+func primaryKeys(ctx context.Context, db *sql.DB, driver string) (map[string][]string, error) {
+	query := "SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY TABLE_NAME, ORDINAL_POSITION"
+	if driver == DriverPostgreSQL {
+		query = "SELECT kcu.table_name, kcu.column_name FROM information_schema.table_constraints tc " +
+			"JOIN information_schema.key_column_usage kcu " +
+			"ON kcu.constraint_name = tc.constraint_name AND kcu.constraint_schema = tc.constraint_schema " +
+			"WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema() " +
+			"ORDER BY kcu.table_name, kcu.ordinal_position"
+	}
+	return queryColumns(ctx, db, query)
+}
+
+// queryColumns 执行「表名, 列名」查询
+func queryColumns(ctx context.Context, db *sql.DB, query string) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("anonymize: 查询表结构失败: %w", err)
+	}
+	defer rows.Close()
+	out := map[string][]string{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("anonymize: 查询表结构失败: %w", err)
+		}
+		out[table] = append(out[table], column)
+	}
+	return out, rows.Err()
+}
+
+// anonymizer 一次匿名化的运行状态
+type anonymizer struct {
+	src, dst *sql.DB
+	driver   string
+	gen      *fakeGen
+	batch    int
+	progress func(AnonymizeProgress)
+}
+
+// run 分批读取一张表，转换后写入目标库（或原地改写）
+func (a *anonymizer) run(ctx context.Context, t *anonymizeTable, inPlace bool) error {
+	var after []any
+	var total int64
+	for {
+		rows, err := a.readBatch(ctx, t, after)
+		if err != nil {
+			return fmt.Errorf("anonymize: 读取 %s 失败: %w", t.name, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		last := rows[len(rows)-1]
+		after = after[:0]
+		for _, i := range t.key {
+			after = append(after, last[i])
+		}
+
+		if inPlace {
+			err = a.update(ctx, t, rows)
+		} else {
+			err = a.insert(ctx, t, rows)
+		}
+		if err != nil {
+			return fmt.Errorf("anonymize: 写入 %s 失败: %w", t.name, err)
+		}
+		total += int64(len(rows))
+		if a.progress != nil {
+			a.progress(AnonymizeProgress{Table: t.name, Rows: total})
+		}
+		if len(rows) < a.batch {
+			break
+		}
+	}
+
+	if !inPlace && a.driver == DriverPostgreSQL && slices.Contains(t.columns, "id") && total > 0 {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence($1, 'id'), MAX(id)) FROM %s", quoteIdent(a.driver, t.name))
+		if _, err := a.dst.ExecContext(ctx, query, t.name); err != nil {
+			return fmt.Errorf("anonymize: 同步 %s 序列失败: %w", t.name, err)
+		}
+	}
+	if a.progress != nil {
+		a.progress(AnonymizeProgress{Table: t.name, Rows: total, Done: true})
+	}
+	log.Infof("[Anonymize] %s 完成，%d 行", t.name, total)
+	return nil
+}
+
+// readBatch 按排序键读取 after 之后的一批行（keyset 分页）
+func (a *anonymizer) readBatch(ctx context.Context, t *anonymizeTable, after []any) ([][]any, error) {
+	quoted := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		quoted[i] = quoteIdent(a.driver, column)
+	}
+	keys := make([]string, len(t.key))
+	for i, k := range t.key {
+		keys[i] = quoted[k]
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(a.driver, t.name))
+	if len(after) > 0 {
+		holders := make([]string, len(after))
+		for i := range after {
+			holders[i] = placeholder(a.driver, i+1)
+		}
+		if len(keys) == 1 {
+			query += fmt.Sprintf(" WHERE %s > %s", keys[0], holders[0])
+		} else {
+			query += fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(keys, ", "), strings.Join(holders, ", "))
+		}
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), a.batch)
+
+	rows, err := a.src.QueryContext(ctx, query, after...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]any
+	for rows.Next() {
+		values := make([]any, len(t.columns))
+		dest := make([]any, len(t.columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		// 驱动复用 []byte 缓冲，复制一份
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = slices.Clone(b)
+			}
+		}
+		out = append(out, values)
+	}
+	return out, rows.Err()
+}
+
+// insert 在一个事务中多行 INSERT 写入转换后的行（按参数上限拆分语句）
+func (a *anonymizer) insert(ctx context.Context, t *anonymizeTable, rows [][]any) error {
+	quoted := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		quoted[i] = quoteIdent(a.driver, column)
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", quoteIdent(a.driver, t.name), strings.Join(quoted, ", "))
+	perStmt := max(maxBindParams/len(t.columns), 1)
+
+	return a.inTx(ctx, a.dst, func(tx *sql.Tx) error {
+		for chunk := range slices.Chunk(rows, perStmt) {
+			var b strings.Builder
+			b.WriteString(prefix)
+			args := make([]any, 0, len(chunk)*len(t.columns))
+			for i, row := range chunk {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteByte('(')
+				for j, v := range row {
+					if j > 0 {
+						b.WriteString(", ")
+					}
+					args = append(args, t.rules[j].apply(a.gen, v))
+					b.WriteString(placeholder(a.driver, len(args)))
+				}
+				b.WriteByte(')')
+			}
+			if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update 在一个事务中按排序键逐行 UPDATE 转换的列（原地模式）
+func (a *anonymizer) update(ctx context.Context, t *anonymizeTable, rows [][]any) error {
+	var changed []int
+	for i, r := range t.rules {
+		if r.kind != ruleKeep {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sets := make([]string, len(changed))
+	for i, c := range changed {
+		sets[i] = quoteIdent(a.driver, t.columns[c]) + " = " + placeholder(a.driver, i+1)
+	}
+	conds := make([]string, len(t.key))
+	for i, k := range t.key {
+		conds[i] = quoteIdent(a.driver, t.columns[k]) + " = " + placeholder(a.driver, len(changed)+i+1)
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdent(a.driver, t.name), strings.Join(sets, ", "), strings.Join(conds, " AND "))
+
+	return a.inTx(ctx, a.src, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, row := range rows {
+			args := make([]any, 0, len(changed)+len(t.key))
+			for _, c := range changed {
+				args = append(args, t.rules[c].apply(a.gen, row[c]))
+			}
+			for _, k := range t.key {
+				args = append(args, row[k])
+			}
+			if _, err := stmt.ExecContext(ctx, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *anonymizer) inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sortedKeys 按名称排序的键（校验按固定顺序进行，错误信息稳定）
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/mask"
+)
+
+// 列规则
+const (
+	ruleKeep = "keep" // 原样复制
+	ruleNull = "null" // 置为 NULL
+	ruleMask = "mask" // 用 mask 包的策略脱敏（mask:phone）
+	ruleFake = "fake" // 确定性假数据（fake:name）
+)
+
+// maskStrategies mask:<策略> 可用的脱敏方式
+var maskStrategies = map[string]func(string) string{
+	"phone":  mask.Phone,
+	"idcard": mask.IDCard,
+	"email":  mask.Email,
+	"auto":   mask.Auto,
+}
+
+// fakeKinds fake:<类型> 可用的假数据
+var fakeKinds = map[string]bool{
+	"name": true, "phone": true, "email": true, "idcard": true, "digits": true, "string": true, "uuid": true,
+}
+
+// columnRule 解析后的列规则
+type columnRule struct {
+	kind string
+	arg  string
+}
+
+func (r columnRule) String() string {
+	if r.arg == "" {
+		return r.kind
+	}
+	return r.kind + ":" + r.arg
+}
+
+// parseColumnRule 解析列规则字符串（keep / null / mask:<策略> / fake:<类型>）
+func parseColumnRule(s string) (columnRule, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(s), ":")
+	r := columnRule{kind: kind, arg: arg}
+	switch kind {
+	case ruleKeep, ruleNull:
+		if arg == "" {
+			return r, nil
+		}
+	case ruleMask:
+		if maskStrategies[arg] != nil {
+			return r, nil
+		}
+	case ruleFake:
+		if fakeKinds[arg] {
+			return r, nil
+		}
+	}
+	return r, fmt.Errorf("无效的列规则 %q", s)
+}
+
+// fakeGen 确定性假数据生成器：同一个种子下，同一类型的同一输入总是得到同一输出（跨表一致，关联列仍然可以 JOIN）
+//
+// 输出由 HMAC-SHA256(种子, 类型, 输入) 派生，不知道种子时无法从假数据反推原值。
+// digits / phone / idcard 使用保持长度的数字置换（一一对应），原本唯一的值替换后仍然唯一
+type fakeGen struct {
+	mac hash.Hash
+}
+
+// newFakeGen 按种子创建生成器，种子为空时随机生成（只在本次运行内保持一致）
+func newFakeGen(seed string) (*fakeGen, error) {
+	key := make([]byte, 32)
+	if seed == "" {
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	} else {
+		sum := sha256.Sum256([]byte(seed))
+		key = sum[:]
+	}
+	return &fakeGen{mac: hmac.New(sha256.New, key)}, nil
+}
+
+// sum HMAC(种子, 各部分以 0 分隔)
+func (g *fakeGen) sum(parts ...string) []byte {
+	g.mac.Reset()
+	for _, p := range parts {
+		g.mac.Write([]byte(p))
+		g.mac.Write([]byte{0})
+	}
+	return g.mac.Sum(nil)
+}
+
+// apply 按规则转换一个值（NULL 保持 NULL）
+func (r columnRule) apply(g *fakeGen, v any) any {
+	if r.kind == ruleKeep {
+		return v
+	}
+	if r.kind == ruleNull || v == nil {
+		return nil
+	}
+	s := valueString(v)
+	if r.kind == ruleMask {
+		return maskStrategies[r.arg](s)
+	}
+	out := g.fake(r.arg, s)
+	if _, ok := v.(int64); ok {
+		if n, err := strconv.ParseInt(out, 10, 64); err == nil {
+			return n
+		}
+	}
+	return out
+}
+
+// valueString 列值的字符串形式（整数列按十进制处理）
+func valueString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+var (
+	fakeSurnames = []string{"王", "李", "张", "刘", "陈", "杨", "黄", "赵", "吴", "周", "徐", "孙", "马", "朱", "胡", "郭", "何", "高", "林", "罗"}
+	fakeGiven    = []rune("伟芳娜敏静丽强磊军洋勇艳杰娟涛明超秀霞平刚桂英华玉兰晨宇浩然")
+)
+
+// fake 生成一个假数据
+func (g *fakeGen) fake(kind, s string) string {
+	switch kind {
+	case "name":
+		h := g.sum(kind, s)
+		name := fakeSurnames[int(h[0])%len(fakeSurnames)] + string(fakeGiven[int(h[1])%len(fakeGiven)])
+		if h[2]%2 == 0 {
+			name += string(fakeGiven[int(h[3])%len(fakeGiven)])
+		}
+		return name
+	case "email":
+		return "user_" + hex.EncodeToString(g.sum(kind, s)[:8]) + "@example.com"
+	case "string":
+		return hex.EncodeToString(g.sum(kind, s)[:8])
+	case "uuid":
+		h := g.sum(kind, s)
+		h[6] = h[6]&0x0f | 0x40
+		h[8] = h[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+	case "phone":
+		if len(s) == 11 && s[0] == '1' && mask.IsDigits(s) {
+			return "1" + g.permuteDigits(kind, s[1:])
+		}
+	case "idcard":
+		if len(s) == 18 && mask.IsDigits(s[:17]) {
+			body := g.permuteDigits(kind, s[:17])
+			return body + idCardCheckDigit(body)
+		}
+	}
+	// digits，以及格式不符合的手机号、身份证号：数字置换，非数字的值退化为哈希
+	if s != "" && mask.IsDigits(s) {
+		return g.permuteDigits("digits", s)
+	}
+	return hex.EncodeToString(g.sum(kind, s)[:8])
+}
+
+// permuteDigits 保持长度的数字串置换（同一长度内一一对应）
+//
+// 每 18 位一段，在 [0, 10^n) 上用 4 轮 Feistel 网络加 cycle walking 做置换
+func (g *fakeGen) permuteDigits(kind, s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i += 18 {
+		chunk := s[i:min(i+18, len(s))]
+		n, _ := strconv.ParseUint(chunk, 10, 64)
+		domain := uint64(1)
+		for range len(chunk) {
+			domain *= 10
+		}
+		bits := 2
+		for uint64(1)<<bits < domain {
+			bits += 2
+		}
+		tweak := fmt.Sprintf("%s/%d/%d", kind, len(chunk), i)
+		for {
+			n = g.feistel(n, bits, tweak)
+			if n < domain {
+				break
+			}
+		}
+		fmt.Fprintf(&b, "%0*d", len(chunk), n)
+	}
+	return b.String()
+}
+
+// feistel [0, 2^bits) 上的置换（bits 为偶数）
+func (g *fakeGen) feistel(x uint64, bits int, tweak string) uint64 {
+	half := bits / 2
+	mask := uint64(1)<<half - 1
+	l, r := x>>half, x&mask
+	var buf [8]byte
+	for round := range 4 {
+		binary.BigEndian.PutUint64(buf[:], r)
+		f := binary.BigEndian.Uint64(g.sum(tweak, strconv.Itoa(round), string(buf[:]))) & mask
+		l, r = r, l^f
+	}
+	return l<<half | r
+}
+
+// idCardCheckDigit 18 位身份证号的校验码（GB 11643）
+func idCardCheckDigit(body string) string {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(body[i]-'0') * w
+	}
+	return string("10X98765432"[sum%11])
+}
@@ -0,0 +1,364 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memSchema 内存库的表结构：列（第一列为主键 id）与外键（表 → 被引用的表）
+var memSchema = map[string][]string{
+	"users":      {"id", "name", "phone", "email", "id_card", "password"},
+	"orders":     {"id", "user_id", "buyer_phone", "amount"},
+	"login_logs": {"id", "user_id", "ip"},
+}
+
+var memForeignKeys = [][2]string{{"orders", "users"}, {"login_logs", "users"}}
+
+// memDB fakeDB 上按 MySQL 方言执行匿名化语句的内存表（只支持 Anonymize 生成的语句）
+type memDB struct {
+	*fakeDB
+	mu     sync.Mutex
+	tables map[string][]map[string]driver.Value
+}
+
+func newMemDB(t *testing.T) (*memDB, *sql.DB) {
+	f, db := newFakeDB(t)
+	m := &memDB{fakeDB: f, tables: map[string][]map[string]driver.Value{}}
+	for name := range memSchema {
+		m.tables[name] = nil
+	}
+	f.onExec = func(_ int, query string, args []any) (driver.Result, error) { return m.exec(query, args) }
+	f.onQuery = func(_ int, query string, args []any) ([][]driver.Value, error) { return m.query(query, args) }
+	return m, db
+}
+
+// newShopDB 源库：5 个用户、4 个订单、登录日志
+func newShopDB(t *testing.T) (*memDB, *sql.DB) {
+	m, db := newMemDB(t)
+	for i := int64(1); i <= 5; i++ {
+		m.tables["users"] = append(m.tables["users"], map[string]driver.Value{
+			"id": i, "name": []byte(fmt.Sprintf("user-%d", i)), "phone": []byte(fmt.Sprintf("1381234000%d", i)),
+			"email": []byte(fmt.Sprintf("user%d@corp.com", i)), "id_card": []byte(fmt.Sprintf("11010119900101%04d", i)),
+			"password": []byte("$2a$10$hash"),
+		})
+		m.tables["login_logs"] = append(m.tables["login_logs"], map[string]driver.Value{"id": i, "user_id": i, "ip": []byte("10.0.0.1")})
+	}
+	for i, user := range []int64{1, 1, 3, 5} {
+		m.tables["orders"] = append(m.tables["orders"], map[string]driver.Value{
+			"id": int64(i + 1), "user_id": user, "buyer_phone": []byte(fmt.Sprintf("1381234000%d", user)), "amount": int64(100 * (i + 1)),
+		})
+	}
+	return m, db
+}
+
+func (m *memDB) rows(table string) []map[string]driver.Value {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []map[string]driver.Value
+	for _, row := range m.tables[table] {
+		out = append(out, maps.Clone(row))
+	}
+	return out
+}
+
+var (
+	memInsert = regexp.MustCompile("^INSERT INTO `(\\w+)` \\(([^)]*)\\) VALUES ")
+	memUpdate = regexp.MustCompile("^UPDATE `(\\w+)` SET (.*) WHERE `id` = \\?$")
+	memSelect = regexp.MustCompile("^SELECT (.*) FROM `(\\w+)`(?: WHERE `id` > \\?)? ORDER BY `id` LIMIT (\\d+)$")
+	memIdent  = regexp.MustCompile("`(\\w+)`")
+)
+
+func (m *memDB) exec(query string, args []any) (driver.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "DELETE FROM "):
+		table := memIdent.FindStringSubmatch(query)[1]
+		n := len(m.tables[table])
+		m.tables[table] = nil
+		return driver.RowsAffected(n), nil
+	case memInsert.MatchString(query):
+		g := memInsert.FindStringSubmatch(query)
+		var columns []string
+		for _, id := range memIdent.FindAllStringSubmatch(g[2], -1) {
+			columns = append(columns, id[1])
+		}
+		for i := 0; i < len(args); i += len(columns) {
+			row := map[string]driver.Value{}
+			for j, column := range columns {
+				row[column] = args[i+j]
+			}
+			m.tables[g[1]] = append(m.tables[g[1]], row)
+		}
+		return driver.RowsAffected(len(args) / len(columns)), nil
+	case memUpdate.MatchString(query):
+		g := memUpdate.FindStringSubmatch(query)
+		sets := memIdent.FindAllStringSubmatch(g[2], -1)
+		id := args[len(args)-1]
+		for _, row := range m.tables[g[1]] {
+			if row["id"] == id {
+				for i, set := range sets {
+					row[set[1]] = args[i]
+				}
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("unexpected statement: %s", query)
+}
+
+func (m *memDB) query(query string, args []any) ([][]driver.Value, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out [][]driver.Value
+	switch {
+	case strings.Contains(query, "information_schema.COLUMNS"):
+		for table, columns := range memSchema {
+			if _, ok := m.tables[table]; ok {
+				for _, column := range columns {
+					out = append(out, []driver.Value{table, column})
+				}
+			}
+		}
+	case strings.Contains(query, "CONSTRAINT_NAME = 'PRIMARY'"):
+		for table := range memSchema {
+			out = append(out, []driver.Value{table, "id"})
+		}
+	case strings.Contains(query, "REFERENCED_TABLE_NAME"):
+		for _, fk := range memForeignKeys {
+			out = append(out, []driver.Value{fk[0], fk[1]})
+		}
+	case memSelect.MatchString(query):
+		g := memSelect.FindStringSubmatch(query)
+		var columns []string
+		for _, id := range memIdent.FindAllStringSubmatch(g[1], -1) {
+			columns = append(columns, id[1])
+		}
+		rows := slices.Clone(m.tables[g[2]])
+		slices.SortFunc(rows, func(a, b map[string]driver.Value) int { return int(a["id"].(int64) - b["id"].(int64)) })
+		var limit int
+		fmt.Sscan(g[3], &limit)
+		for _, row := range rows {
+			if len(args) > 0 && row["id"].(int64) <= args[0].(int64) {
+				continue
+			}
+			if len(out) == limit {
+				break
+			}
+			values := make([]driver.Value, len(columns))
+			for i, column := range columns {
+				values[i] = row[column]
+			}
+			out = append(out, values)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	return out, nil
+}
+
+// shopRules 用户信息脱敏，订单的下单手机号与用户手机号关联，登录日志清空
+func shopRules() *AnonymizeRules {
+	return &AnonymizeRules{
+		Seed:      "staging",
+		BatchSize: 2,
+		Tables: map[string]AnonymizeTable{
+			"users": {Columns: map[string]string{
+				"name": "fake:name", "phone": "fake:phone", "email": "fake:email", "id_card": "mask:idcard", "password": "null",
+			}},
+			"orders":     {},
+			"login_logs": {Drop: true},
+		},
+		ForeignKeys: []AnonymizeForeignKey{{Column: "orders.buyer_phone", References: "users.phone"}},
+	}
+}
+
+func str(v driver.Value) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func TestAnonymize_CopyTransformsAndKeepsForeignKeys(t *testing.T) {
+	src, srcDB := newShopDB(t)
+	dst, dstDB := newMemDB(t)
+	dst.tables["users"] = []map[string]driver.Value{{"id": int64(99)}}
+	dst.tables["login_logs"] = []map[string]driver.Value{{"id": int64(99)}}
+
+	var progress []AnonymizeProgress
+	err := Anonymize(context.Background(), srcDB, dstDB, shopRules(), WithAnonymizeDriver(DriverMySQL),
+		WithAnonymizeProgress(func(p AnonymizeProgress) { progress = append(progress, p) }))
+	require.NoError(t, err)
+
+	users := dst.rows("users")
+	require.Len(t, users, 5, "目标表先清空再复制")
+	assert.Empty(t, dst.rows("login_logs"), "drop 的表只清空")
+	phones := map[string]string{} // 原手机号 → 假手机号
+	for i, u := range users {
+		orig := src.rows("users")[i]
+		assert.Equal(t, orig["id"], u["id"])
+		for _, column := range []string{"name", "phone", "email", "id_card"} {
+			assert.NotEqual(t, str(orig[column]), str(u[column]), "%s 不能保留原值", column)
+		}
+		assert.Nil(t, u["password"])
+		assert.Regexp(t, `^1\d{10}$`, u["phone"])
+		assert.Regexp(t, `^user_[0-9a-f]{16}@example\.com$`, u["email"])
+		assert.Equal(t, "110***********"+str(orig["id_card"])[14:], u["id_card"])
+		phones[str(orig["phone"])] = str(u["phone"])
+	}
+	assert.Len(t, slices.Compact(slices.Sorted(maps.Values(phones))), 5, "唯一的手机号替换后仍然唯一")
+
+	orders := dst.rows("orders")
+	require.Len(t, orders, 4)
+	for i, o := range orders {
+		orig := src.rows("orders")[i]
+		assert.Equal(t, phones[str(orig["buyer_phone"])], o["buyer_phone"], "关联列与被引用列得到同样的假数据")
+		assert.Equal(t, orig["amount"], o["amount"], "没有配置规则的列原样复制")
+	}
+
+	assert.Len(t, dst.statementsWith("INSERT INTO `users`"), 3, "5 行按每批 2 行写入")
+	inserts := dst.statementsWith("INSERT")
+	assert.True(t, strings.HasPrefix(inserts[0], "INSERT INTO `users`"), "被引用的表先写入")
+	deletes := dst.statementsWith("DELETE")
+	assert.Equal(t, "DELETE FROM `users`", deletes[len(deletes)-1], "被引用的表最后清空")
+	assert.Contains(t, progress, AnonymizeProgress{Table: "users", Rows: 5, Done: true})
+	assert.Contains(t, progress, AnonymizeProgress{Table: "users", Rows: 4})
+	assert.Empty(t, src.statementsWith("INSERT"), "源库只读")
+	assert.Empty(t, src.statementsWith("DELETE"))
+
+	// 相同的种子结果相同，不同的种子结果不同
+	again, againDB := newMemDB(t)
+	require.NoError(t, Anonymize(context.Background(), srcDB, againDB, shopRules(), WithAnonymizeDriver(DriverMySQL)))
+	assert.Equal(t, users, again.rows("users"))
+	other, otherDB := newMemDB(t)
+	rules := shopRules()
+	rules.Seed = "another"
+	require.NoError(t, Anonymize(context.Background(), srcDB, otherDB, rules, WithAnonymizeDriver(DriverMySQL)))
+	assert.NotEqual(t, users[0]["phone"], other.rows("users")[0]["phone"])
+}
+
+func TestAnonymize_ValidationFailsBeforeAnyWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *AnonymizeRules)
+		want   string
+	}{
+		{"未知的表", func(r *AnonymizeRules) { r.Tables["payments"] = AnonymizeTable{} }, "表 payments 不存在"},
+		{"未知的列", func(r *AnonymizeRules) { r.Tables["users"].Columns["nickname"] = "fake:name" }, "列 users.nickname 不存在"},
+		{"无效的规则", func(r *AnonymizeRules) { r.Tables["users"].Columns["id_card"] = "mask:ssn" }, `无效的列规则 "mask:ssn"`},
+		{"排序键不存在", func(r *AnonymizeRules) { r.Tables["orders"] = AnonymizeTable{Key: []string{"uuid"}} }, "排序键 uuid 不存在"},
+		{"关联列规则不一致", func(r *AnonymizeRules) {
+			r.Tables["orders"] = AnonymizeTable{Columns: map[string]string{"buyer_phone": "keep"}}
+		},
+			"外键 orders.buyer_phone 的规则 keep 与 users.phone 的规则 fake:phone 不一致"},
+		{"外键的表未配置", func(r *AnonymizeRules) { delete(r.Tables, "orders") }, "外键 orders.buyer_phone 的表未在 tables 中配置"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srcDB := newShopDB(t)
+			dst, dstDB := newMemDB(t)
+			rules := shopRules()
+			tt.modify(rules)
+			err := Anonymize(context.Background(), srcDB, dstDB, rules, WithAnonymizeDriver(DriverMySQL))
+			assert.ErrorContains(t, err, tt.want)
+			assert.Empty(t, dst.statementsWith("DELETE"))
+			assert.Empty(t, dst.statementsWith("INSERT"))
+		})
+	}
+
+	// 目标库缺少表
+	_, srcDB := newShopDB(t)
+	dst, dstDB := newMemDB(t)
+	delete(dst.tables, "orders")
+	err := Anonymize(context.Background(), srcDB, dstDB, shopRules(), WithAnonymizeDriver(DriverMySQL))
+	assert.ErrorContains(t, err, "目标库中表 orders 不存在")
+	assert.Empty(t, dst.statementsWith("DELETE"))
+}
+
+func TestAnonymize_InPlace(t *testing.T) {
+	m, db := newShopDB(t)
+	before := m.rows("users")
+	rules := shopRules()
+	rules.Tables["users"].Columns["id"] = "fake:digits"
+	assert.ErrorContains(t, Anonymize(context.Background(), db, db, rules, WithAnonymizeDriver(DriverMySQL)),
+		"原地模式不能转换排序键 users.id")
+
+	require.NoError(t, Anonymize(context.Background(), db, db, shopRules(), WithAnonymizeDriver(DriverMySQL)))
+	after := m.rows("users")
+	require.Len(t, after, 5)
+	for i := range after {
+		assert.Equal(t, before[i]["id"], after[i]["id"])
+		assert.NotEqual(t, str(before[i]["phone"]), str(after[i]["phone"]))
+		assert.Nil(t, after[i]["password"])
+	}
+	for _, o := range m.rows("orders") {
+		assert.True(t, slices.ContainsFunc(after, func(u map[string]driver.Value) bool { return u["phone"] == o["buyer_phone"] }),
+			"订单的下单手机号对应改写后的用户手机号")
+	}
+	assert.Empty(t, m.rows("login_logs"))
+	assert.Len(t, m.statementsWith("DELETE"), 1, "原地模式只清空 drop 的表")
+}
+
+func TestFakeGen_DigitsArePermuted(t *testing.T) {
+	g, err := newFakeGen("seed")
+	require.NoError(t, err)
+	seen := map[string]bool{}
+	for i := range 1000 {
+		out := g.fake("digits", fmt.Sprintf("%03d", i))
+		assert.Len(t, out, 3)
+		seen[out] = true
+	}
+	assert.Len(t, seen, 1000, "同一长度内一一对应")
+
+	card := g.fake("idcard", "110101199001011234")
+	assert.Len(t, card, 18)
+	assert.Equal(t, idCardCheckDigit(card[:17]), card[17:], "校验码有效")
+	assert.NotEqual(t, "110101199001011234", card)
+	assert.Len(t, g.fake("digits", "62220212345678901234"), 20, "超过 18 位分段置换")
+
+	same, _ := newFakeGen("seed")
+	assert.Equal(t, g.fake("phone", "13812345678"), same.fake("phone", "13812345678"))
+	assert.IsType(t, int64(0), columnRule{kind: ruleFake, arg: "digits"}.apply(g, int64(4821)), "整数列仍然写入整数")
+}
+
+func TestLoadAnonymizeRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anonymize.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+batchSize = 500
+
+[tables.users.columns]
+phone = "fake:phone"
+
+[tables.login_logs]
+drop = true
+
+[[foreignKeys]]
+column = "orders.buyer_phone"
+references = "users.phone"
+`), 0644))
+	rules, err := LoadAnonymizeRules(path)
+	require.NoError(t, err)
+	assert.Equal(t, 500, rules.BatchSize)
+	assert.Equal(t, "fake:phone", rules.Tables["users"].Columns["phone"])
+	assert.True(t, rules.Tables["login_logs"].Drop)
+	assert.Equal(t, []AnonymizeForeignKey{{Column: "orders.buyer_phone", References: "users.phone"}}, rules.ForeignKeys)
+
+	require.NoError(t, os.WriteFile(path, []byte("[tables.users]\ndorp = true\n"), 0644))
+	_, err = LoadAnonymizeRules(path)
+	assert.ErrorContains(t, err, "未知的配置项 tables.users.dorp")
+}
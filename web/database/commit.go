@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/CenJIl/base/logger"
+)
+
+// CommitHooks 事务提交成功后执行的回调（回滚时丢弃）
+type CommitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+type commitHooksKey struct{}
+
+// WithCommitHooks 为即将提交的事务创建回调列表并绑定到 ctx
+//
+// 开启事务的一方（DBMiddleware、WithTx、消息消费者）在提交成功后调用 Committed
+func WithCommitHooks(ctx context.Context) (context.Context, *CommitHooks) {
+	hooks := &CommitHooks{}
+	return context.WithValue(ctx, commitHooksKey{}, hooks), hooks
+}
+
+// Committed 事务已提交：按注册顺序执行回调（回调 panic 只记录日志）
+func (h *CommitHooks) Committed() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+	for _, fn := range fns {
+		runCommitHook(fn)
+	}
+}
+
+func runCommitHook(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.ErrorPanic(r, "[DB] 提交后回调 panic: %v", r)
+		}
+	}()
+	fn()
+}
+
+// AfterCommit 在 ctx 所属事务提交成功后执行 fn；ctx 不在事务中时立即执行
+//
+// 用于缓存失效、消息发送等必须在数据对其他请求可见之后才能做的事
+//
+// 使用方式：
+//
+//	database.AfterCommit(ctx, func() { notifyOrderPaid(orderID) })
+func AfterCommit(ctx context.Context, fn func()) {
+	if h, ok := ctx.Value(commitHooksKey{}).(*CommitHooks); ok {
+		h.mu.Lock()
+		h.fns = append(h.fns, fn)
+		h.mu.Unlock()
+		return
+	}
+	fn()
+}
+
+// WithTx 在一个事务中执行 fn（事务通过 Conn(ctx) 取得），fn 返回错误或 panic 时回滚
+//
+// 提交成功后执行 AfterCommit 注册的回调；开启了行级安全（EnableRLS）时按 ctx 绑定的身份
+// （请求中由 DBMiddleware 绑定，或 WithRLSIdentity）设置会话变量，没有身份时为 rlsSentinel
+//
+// 使用方式：
+//
+//	err := database.WithTx(ctx, func(ctx context.Context) error {
+//	    if err := sqlc.New(database.Conn(ctx)).UpdateUser(ctx, params); err != nil {
+//	        return err
+//	    }
+//	    cache.InvalidateAfterCommit(ctx, "user:"+id)
+//	    return nil
+//	})
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if DB == nil {
+		return errors.New("数据库未初始化")
+	}
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	endRLS, err := beginRLS(ctx, tx, DB, func(*rlsSession) map[string]string {
+		identity, _ := rlsIdentityFrom(ctx)
+		return identity
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	txCtx, hooks := WithCommitHooks(WithDBTX(ctx, tx))
+	defer func() {
+		if r := recover(); r != nil {
+			endRLS()
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(txCtx); err != nil {
+		endRLS()
+		_ = tx.Rollback()
+		return err
+	}
+	endRLS()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	hooks.Committed()
+	return nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAfterCommit_WithoutTxRunsImmediately(t *testing.T) {
+	ran := false
+	AfterCommit(context.Background(), func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestAfterCommit_DeferredUntilCommitted(t *testing.T) {
+	ctx, hooks := WithCommitHooks(context.Background())
+	var order []int
+	AfterCommit(ctx, func() { order = append(order, 1) })
+	AfterCommit(ctx, func() { panic("boom") })
+	AfterCommit(ctx, func() { order = append(order, 2) })
+	assert.Empty(t, order, "提交前不执行")
+
+	hooks.Committed()
+	assert.Equal(t, []int{1, 2}, order, "按注册顺序执行，panic 不影响后续回调")
+	hooks.Committed()
+	assert.Equal(t, []int{1, 2}, order, "只执行一次")
+}
+
+func TestWithTx(t *testing.T) {
+	old := DB
+	t.Cleanup(func() { DB = old })
+	f, db := newFakeDB(t)
+	DB = db
+
+	ran := 0
+	err := WithTx(context.Background(), func(ctx context.Context) error {
+		_, err := Conn(ctx).ExecContext(ctx, "UPDATE users SET name = 'a'")
+		AfterCommit(ctx, func() { ran++ })
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, []string{"BEGIN", "UPDATE users SET name = 'a'", "COMMIT"}, f.statements())
+
+	boom := errors.New("boom")
+	err = WithTx(context.Background(), func(ctx context.Context) error {
+		AfterCommit(ctx, func() { ran++ })
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 1, ran, "回滚时不执行提交后回调")
+	assert.Equal(t, "ROLLBACK", f.statements()[len(f.statements())-1])
+
+	assert.Panics(t, func() {
+		_ = WithTx(context.Background(), func(ctx context.Context) error { panic("boom") })
+	})
+	assert.Equal(t, "ROLLBACK", f.statements()[len(f.statements())-1])
+}
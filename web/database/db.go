@@ -1,29 +1,44 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/CenJIl/base/logger"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Driver   string `toml:"driver"`   // 数据库驱动：mysql, postgres
-	Host     string `toml:"host"`     // 数据库主机
-	Port     int    `toml:"port"`     // 数据库端口
-	User     string `toml:"user"`     // 数据库用户
-	Password string `toml:"password"` // 数据库密码
-	DBName   string `toml:"dbname"`   // 数据库名称
-	MaxOpen  int    `toml:"maxOpen"`  // 最大连接数
-	MaxIdle  int    `toml:"maxIdle"`  // 最大空闲连接
+	Driver   string `toml:"driver"`                    // 数据库驱动：mysql, postgres
+	Host     string `toml:"host"`                      // 数据库主机
+	Port     int    `toml:"port"`                      // 数据库端口
+	User     string `toml:"user"`                      // 数据库用户
+	Password string `toml:"password" sensitive:"true"` // 数据库密码
+	DBName   string `toml:"dbname"`                    // 数据库名称
+	MaxOpen  int    `toml:"maxOpen"`                   // 最大连接数
+	MaxIdle  int    `toml:"maxIdle"`                   // 最大空闲连接
+
+	Replica ReplicaConfig `toml:"replica"` // 只读副本（可选，配置 host 后 Replica() 使用副本）
+
+	RLSVariables map[string]string `toml:"rlsVariables"` // 行级安全会话变量：变量名 -> 身份来源（user / tenant / claim:<name>），见 EnableRLS
+	RLSSentinel  string            `toml:"rlsSentinel"`  // 身份缺失时会话变量的值（默认空字符串）
+
+	OnClientAbort AbortPolicy `toml:"onClientAbort" validate:"omitempty,oneof=commit rollback"` // 客户端中途断开时 DBMiddleware 的事务处理，默认 commit
 }
 
+// log database 模块的日志记录器
+var log = logger.Named("database")
+
 // DB 数据库连接池（供 sqlc 生成的代码使用）
 var DB *sql.DB
 
+// driverName InitDB 使用的驱动（LoadFixtures / TruncateAll 据此选择 SQL 方言）
+var driverName string
+
 // Drivers 支持的数据库驱动
 const (
 	DriverMySQL      = "mysql"
@@ -35,17 +50,43 @@ const (
 // 使用方式：
 //
 //	if err := web.InitDB(config.Database); err != nil {
-//	    logger.Errorf("Failed to init database: %v", err)
+//	    log.Errorf("Failed to init database: %v", err)
 //	}
 func InitDB(cfg DatabaseConfig) error {
+	db, err := Open(cfg)
+	if err != nil || db == nil {
+		return err
+	}
+
+	DB = db
+	driverName = cfg.Driver
+	onClientAbort = cfg.OnClientAbort
+
+	replica, err := OpenReplica(cfg)
+	if err != nil {
+		return err
+	}
+	ReplicaDB = replica
+	return nil
+}
+
+// Open 打开一个独立的连接池并 Ping（不影响全局的 DB，未配置驱动时返回 nil, nil）
+//
+// 同一进程中的多个服务各自持有连接池时使用（见 web.NewApp），调用方负责 Close
+//
+// 使用方式：
+//
+//	db, err := database.Open(config.Database)
+//	h.Use(database.TxMiddleware(db, config.Database.OnClientAbort))
+func Open(cfg DatabaseConfig) (*sql.DB, error) {
 	if cfg.Driver == "" {
-		return nil // 未配置，跳过
+		return nil, nil // 未配置，跳过
 	}
 
 	dsn := buildDSN(cfg)
 	db, err := sql.Open(cfg.Driver, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// 设置连接池
@@ -53,12 +94,35 @@ func InitDB(cfg DatabaseConfig) error {
 	db.SetMaxIdleConns(cfg.MaxIdle)
 	db.SetConnMaxLifetime(time.Hour) // 连接最大生存时间1小时
 
+	if err := EnableRLS(db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	// 测试连接
 	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+		rlsPools.Delete(db)
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
+	return db, nil
+}
 
-	DB = db
+// CheckConnection 用配置建立一个临时连接并 Ping（不影响全局连接池，用于配置试运行）
+//
+// 未配置驱动时直接返回 nil；连接超时由 ctx 控制
+func CheckConnection(ctx context.Context, cfg DatabaseConfig) error {
+	if cfg.Driver == "" {
+		return nil
+	}
+	db, err := sql.Open(cfg.Driver, buildDSN(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
 	return nil
 }
 
@@ -94,7 +158,11 @@ func buildDSN(cfg DatabaseConfig) string {
 //
 //	defer database.Close()
 func Close() error {
+	if ReplicaDB != nil {
+		ReplicaDB.Close()
+	}
 	if DB != nil {
+		rlsPools.Delete(DB)
 		return DB.Close()
 	}
 	return nil
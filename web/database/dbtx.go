@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX *sql.DB 与 *sql.Tx 的公共接口（与 sqlc 生成代码的 DBTX 一致）
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type dbtxKey struct{}
+
+// WithDBTX 把事务（或连接池）绑定到 context
+func WithDBTX(ctx context.Context, db DBTX) context.Context {
+	return context.WithValue(ctx, dbtxKey{}, db)
+}
+
+// Conn 取 context 绑定的 DBTX，未绑定时返回全局连接池 DB
+//
+// DBMiddleware 开启的请求事务与 LoadFixturesTx 开启的测试事务都通过 context 传递，
+// 业务代码统一用 Conn(ctx) 取连接即可在两种场景下复用
+//
+// 使用方式：
+//
+//	q := sqlc.New(database.Conn(ctx))
+func Conn(ctx context.Context) DBTX {
+	if db, ok := ctx.Value(dbtxKey{}).(DBTX); ok {
+		return db
+	}
+	if DB == nil {
+		return nil
+	}
+	return DB
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ExistsQuery 一次存在性检查：Table 中是否有 Column = Value 的行
+//
+// ExcludeColumn 不为空时排除 ExcludeColumn = Exclude 的行（更新时排除当前记录）
+type ExistsQuery struct {
+	Table         string
+	Column        string
+	Value         any
+	ExcludeColumn string
+	Exclude       any
+}
+
+// identPattern 允许出现在存在性检查中的标识符（只允许字母、数字、下划线）
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Exists 在一次往返中执行多条存在性检查（各条之间 UNION），返回与 queries 一一对应的结果
+//
+// 值全部参数化；表名与列名必须是合法标识符并按方言加引号，不合法时返回错误而不是拼进 SQL。
+// 通过 db 执行，传入 Conn(ctx) 即可在请求事务中看到尚未提交的数据
+//
+// 使用方式：
+//
+//	found, err := database.Exists(ctx, database.Conn(ctx), []database.ExistsQuery{
+//	    {Table: "users", Column: "email", Value: req.Email},
+//	    {Table: "categories", Column: "id", Value: req.CategoryID},
+//	})
+func Exists(ctx context.Context, db DBTX, queries []ExistsQuery) ([]bool, error) {
+	found := make([]bool, len(queries))
+	if len(queries) == 0 {
+		return found, nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("exists: 数据库未初始化")
+	}
+	driver := detectDriver(db)
+	parts := make([]string, 0, len(queries))
+	args := make([]any, 0, len(queries))
+	for i, q := range queries {
+		for _, ident := range []string{q.Table, q.Column, q.ExcludeColumn} {
+			if ident != "" && !identPattern.MatchString(ident) {
+				return nil, fmt.Errorf("exists: 非法标识符 %q", ident)
+			}
+		}
+		if q.Table == "" || q.Column == "" {
+			return nil, fmt.Errorf("exists: 缺少表名或列名")
+		}
+		args = append(args, q.Value)
+		part := fmt.Sprintf("SELECT %d AS k FROM %s WHERE %s = %s", i, quoteIdent(driver, q.Table),
+			quoteIdent(driver, q.Column), placeholder(driver, len(args)))
+		if q.ExcludeColumn != "" {
+			args = append(args, q.Exclude)
+			part += fmt.Sprintf(" AND %s <> %s", quoteIdent(driver, q.ExcludeColumn), placeholder(driver, len(args)))
+		}
+		parts = append(parts, part)
+	}
+
+	rows, err := db.QueryContext(ctx, strings.Join(parts, " UNION "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i int
+		if err := rows.Scan(&i); err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= len(found) {
+			return nil, fmt.Errorf("exists: 意外的结果 %d", i)
+		}
+		found[i] = true
+	}
+	return found, rows.Err()
+}
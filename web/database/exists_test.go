@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExists(t *testing.T) {
+	old := driverName
+	t.Cleanup(func() { driverName = old })
+	queries := []ExistsQuery{
+		{Table: "users", Column: "email", Value: "a@example.com", ExcludeColumn: "id", Exclude: int64(7)},
+		{Table: "categories", Column: "id", Value: int64(3)},
+	}
+
+	t.Run("mysql", func(t *testing.T) {
+		driverName = DriverMySQL
+		f, db := newFakeDB(t)
+		f.results["UNION"] = [][]driver.Value{{int64(1)}}
+
+		found, err := Exists(context.Background(), db, queries)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, found)
+		require.Len(t, f.execs, 1, "多条检查一次往返")
+		assert.Equal(t, "SELECT 0 AS k FROM `users` WHERE `email` = ? AND `id` <> ? UNION SELECT 1 AS k FROM `categories` WHERE `id` = ?", f.execs[0].query)
+		assert.Equal(t, []any{"a@example.com", int64(7), int64(3)}, f.execs[0].args)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		driverName = DriverPostgreSQL
+		f, db := newFakeDB(t)
+
+		found, err := Exists(context.Background(), db, queries)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, false}, found)
+		assert.Equal(t, `SELECT 0 AS k FROM "users" WHERE "email" = $1 AND "id" <> $2 UNION SELECT 1 AS k FROM "categories" WHERE "id" = $3`, f.execs[0].query)
+	})
+
+	t.Run("rejects identifiers", func(t *testing.T) {
+		f, db := newFakeDB(t)
+		_, err := Exists(context.Background(), db, []ExistsQuery{{Table: "users; DROP TABLE users", Column: "id", Value: 1}})
+		assert.Error(t, err)
+		assert.Empty(t, f.execs, "非法标识符不会执行")
+	})
+}
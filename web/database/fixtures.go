@@ -0,0 +1,542 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// orderFiles 显式指定插入顺序的文件（YAML/JSON 表名列表），存在时不再查询外键元数据
+var orderFiles = []string{"_order.yml", "_order.yaml", "_order.json"}
+
+// fixtureConfig LoadFixtures 的可选配置
+type fixtureConfig struct {
+	driver string
+	clock  common.Clock
+}
+
+// FixtureOption LoadFixtures 的可选配置项
+type FixtureOption func(*fixtureConfig)
+
+// WithDriver 指定 SQL 方言（DriverMySQL / DriverPostgreSQL），默认取 InitDB 配置的驱动
+func WithDriver(driver string) FixtureOption {
+	return func(c *fixtureConfig) { c.driver = driver }
+}
+
+// WithClock 指定 {{ now }} 使用的时钟（测试中注入 FakeClock 保证时间可预期）
+func WithClock(clock common.Clock) FixtureOption {
+	return func(c *fixtureConfig) { c.clock = clock }
+}
+
+// fixtureTable 一张表的测试数据
+type fixtureTable struct {
+	name string
+	rows []map[string]any
+}
+
+// LoadFixtures 加载测试数据（仅用于集成测试）
+//
+// dir 下每个 .yml/.yaml/.json 文件是「表名 → 行列表」的映射，多个文件中的同名表按文件名顺序合并。
+// 插入顺序由 information_schema 中的外键关系推导（被引用的表先插入）；
+// dir 下存在 _order.yml 时按其中列出的表名顺序插入。
+//
+// 字符串值支持模板：
+//
+//	{{ now }}              当前时间（WithClock 可注入时钟）
+//	{{ now "-2h" }}        相对时间，支持 time.ParseDuration 格式与天数（"+3d"、"-1d12h"）
+//	{{ bcrypt "secret" }}  密码哈希（bcrypt.MinCost，可用 common.CheckPassword 校验）
+//
+// 只插入数据，不清空表；需要干净的库时先调用 TruncateAll，或使用 LoadFixturesTx 在事务中加载。
+//
+// 使用方式：
+//
+//	//go:embed testdata/fixtures
+//	var fixtures embed.FS
+//
+//	err := database.LoadFixtures(ctx, database.DB, fixtures, "testdata/fixtures")
+//
+// testdata/fixtures/users.yml：
+//
+//	users:
+//	  - id: 1
+//	    name: alice
+//	    password: '{{ bcrypt "secret" }}'
+//	    created_at: '{{ now "-24h" }}'
+func LoadFixtures(ctx context.Context, db DBTX, fsys fs.FS, dir string, opts ...FixtureOption) error {
+	cfg := fixtureConfig{driver: detectDriver(db), clock: common.SystemClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.driver != DriverMySQL && cfg.driver != DriverPostgreSQL {
+		return fmt.Errorf("fixtures: 不支持的驱动 %q", cfg.driver)
+	}
+
+	tables, order, err := readFixtures(fsys, dir)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		deps, err := foreignKeys(ctx, db, cfg.driver)
+		if err != nil {
+			return err
+		}
+		var cycle []string
+		if order, cycle = sortTables(tables, deps); cycle != nil {
+			return fmt.Errorf("fixtures: 表 %s 存在循环外键，请用 %s 指定插入顺序", strings.Join(cycle, ", "), orderFiles[0])
+		}
+	} else if err := checkOrder(tables, order); err != nil {
+		return err
+	}
+
+	r := resolver{clock: cfg.clock, hashes: map[string]string{}}
+	for _, name := range order {
+		if err := insertTable(ctx, db, cfg.driver, tables[name], &r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFixturesTx 在事务中加载测试数据，测试结束时回滚
+//
+// 返回的 context 绑定了该事务，被测代码通过 Conn(ctx) 取连接即可看到测试数据，
+// 测试之间互不影响，无需清库
+//
+// 使用方式：
+//
+//	func TestOrderService(t *testing.T) {
+//	    ctx := database.LoadFixturesTx(t, database.DB, fixtures, "testdata/fixtures")
+//	    order, err := service.GetOrder(ctx, 10)
+//	    ...
+//	}
+func LoadFixturesTx(t testing.TB, db *sql.DB, fsys fs.FS, dir string, opts ...FixtureOption) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("fixtures: 开启事务失败: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	opts = append([]FixtureOption{WithDriver(detectDriver(db))}, opts...)
+	if err := LoadFixtures(ctx, tx, fsys, dir, opts...); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return WithDBTX(ctx, tx)
+}
+
+// TruncateAll 清空当前库（schema）中除 except 外的所有表（仅用于集成测试）
+//
+// MySQL 在同一连接上关闭外键检查后逐表 TRUNCATE，完成后恢复；
+// PostgreSQL 一条 TRUNCATE ... RESTART IDENTITY CASCADE 清空所有表并重置自增序列
+//
+// 使用方式：
+//
+//	err := database.TruncateAll(ctx, database.DB, "schema_migrations")
+func TruncateAll(ctx context.Context, db *sql.DB, except ...string) error {
+	driver := detectDriver(db)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var query string
+	switch driver {
+	case DriverMySQL:
+		query = "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'"
+	case DriverPostgreSQL:
+		query = "SELECT tablename FROM pg_tables WHERE schemaname = current_schema()"
+	default:
+		return fmt.Errorf("fixtures: 不支持的驱动 %q", driver)
+	}
+	names, err := queryStrings(ctx, conn, query)
+	if err != nil {
+		return fmt.Errorf("fixtures: 查询表失败: %w", err)
+	}
+	skip := make(map[string]bool, len(except))
+	for _, name := range except {
+		skip[name] = true
+	}
+	var tables []string
+	for _, name := range names {
+		if !skip[name] {
+			tables = append(tables, name)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	sort.Strings(tables)
+
+	if driver == DriverPostgreSQL {
+		quoted := make([]string, len(tables))
+		for i, name := range tables {
+			quoted[i] = quoteIdent(driver, name)
+		}
+		_, err := conn.ExecContext(ctx, "TRUNCATE TABLE "+strings.Join(quoted, ", ")+" RESTART IDENTITY CASCADE")
+		return err
+	}
+
+	// FOREIGN_KEY_CHECKS 是会话变量，必须与 TRUNCATE 在同一连接上执行
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SET FOREIGN_KEY_CHECKS = 1")
+	for _, name := range tables {
+		if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE "+quoteIdent(driver, name)); err != nil {
+			return fmt.Errorf("fixtures: 清空表 %s 失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// detectDriver 根据连接池的驱动类型识别方言，无法识别时使用 InitDB 配置的驱动
+func detectDriver(db any) string {
+	if sqlDB, ok := db.(*sql.DB); ok {
+		switch sqlDB.Driver().(type) {
+		case *mysql.MySQLDriver:
+			return DriverMySQL
+		case *pq.Driver:
+			return DriverPostgreSQL
+		}
+	}
+	return driverName
+}
+
+// readFixtures 读取 dir 下的测试数据文件与显式顺序文件（不存在时 order 为 nil）
+func readFixtures(fsys fs.FS, dir string) (map[string]*fixtureTable, []string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fixtures: 读取目录 %s 失败: %w", dir, err)
+	}
+
+	tables := make(map[string]*fixtureTable)
+	var order []string
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := path.Ext(name)
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("fixtures: 读取 %s 失败: %w", name, err)
+		}
+
+		if isOrderFile(name) {
+			if err := yaml.Unmarshal(data, &order); err != nil {
+				return nil, nil, fmt.Errorf("fixtures: 解析 %s 失败: %w", name, err)
+			}
+			if order == nil {
+				order = []string{}
+			}
+			continue
+		}
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		// JSON 是 YAML 的子集，统一按 YAML 解析
+		var content map[string][]map[string]any
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return nil, nil, fmt.Errorf("fixtures: 解析 %s 失败: %w", name, err)
+		}
+		for table, rows := range content {
+			t, ok := tables[table]
+			if !ok {
+				t = &fixtureTable{name: table}
+				tables[table] = t
+			}
+			t.rows = append(t.rows, rows...)
+		}
+	}
+	return tables, order, nil
+}
+
+func isOrderFile(name string) bool {
+	for _, f := range orderFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrder 校验显式顺序覆盖了所有测试数据表
+func checkOrder(tables map[string]*fixtureTable, order []string) error {
+	listed := make(map[string]bool, len(order))
+	for _, name := range order {
+		listed[name] = true
+	}
+	for name := range tables {
+		if !listed[name] {
+			return fmt.Errorf("fixtures: 表 %s 未在 %s 中列出", name, orderFiles[0])
+		}
+	}
+	return nil
+}
+
+// foreignKeys 查询当前库的外键关系（表 → 其引用的表）
+func foreignKeys(ctx context.Context, db DBTX, driver string) (map[string][]string, error) {
+	var query string
+	switch driver {
+	case DriverMySQL:
+		query = "SELECT TABLE_NAME, REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE " +
+			"WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL"
+	case DriverPostgreSQL:
+		query = "SELECT tc.table_name, ccu.table_name FROM information_schema.table_constraints tc " +
+			"JOIN information_schema.constraint_column_usage ccu " +
+			"ON ccu.constraint_name = tc.constraint_name AND ccu.constraint_schema = tc.constraint_schema " +
+			"WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema()"
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("fixtures: 查询外键失败: %w", err)
+	}
+	defer rows.Close()
+
+	deps := make(map[string][]string)
+	for rows.Next() {
+		var table, referenced string
+		if err := rows.Scan(&table, &referenced); err != nil {
+			return nil, fmt.Errorf("fixtures: 查询外键失败: %w", err)
+		}
+		deps[table] = append(deps[table], referenced)
+	}
+	return deps, rows.Err()
+}
+
+// sortTables 按外键依赖拓扑排序（被引用的表在前，同层按表名排序保证顺序稳定）
+//
+// 自引用外键忽略（同一表内的行按文件中的顺序插入）；存在循环依赖时返回循环中的表（按表名排序）
+func sortTables[T any](tables map[string]T, deps map[string][]string) (order, cycle []string) {
+	pending := make(map[string]map[string]bool, len(tables))
+	for name := range tables {
+		pending[name] = map[string]bool{}
+		for _, ref := range deps[name] {
+			if _, ok := tables[ref]; ok && ref != name {
+				pending[name][ref] = true
+			}
+		}
+	}
+
+	order = make([]string, 0, len(tables))
+	for len(pending) > 0 {
+		var ready []string
+		for name, refs := range pending {
+			if len(refs) == 0 {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			for name := range pending {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, cycle
+		}
+		sort.Strings(ready)
+		for _, name := range ready {
+			order = append(order, name)
+			delete(pending, name)
+			for _, refs := range pending {
+				delete(refs, name)
+			}
+		}
+	}
+	return order, nil
+}
+
+// insertTable 逐行插入一张表的数据（列按名称排序，各行的列可以不同）
+func insertTable(ctx context.Context, db DBTX, driver string, table *fixtureTable, r *resolver) error {
+	hasID := false
+	for i, row := range table.rows {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		quoted := make([]string, len(columns))
+		holders := make([]string, len(columns))
+		args := make([]any, len(columns))
+		for j, column := range columns {
+			value, err := r.resolve(row[column])
+			if err != nil {
+				return fmt.Errorf("fixtures: %s 第 %d 行 %s: %w", table.name, i+1, column, err)
+			}
+			quoted[j] = quoteIdent(driver, column)
+			holders[j] = placeholder(driver, j+1)
+			args[j] = value
+			hasID = hasID || column == "id"
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quoteIdent(driver, table.name), strings.Join(quoted, ", "), strings.Join(holders, ", "))
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("fixtures: 插入 %s 第 %d 行失败: %w", table.name, i+1, err)
+		}
+	}
+
+	// PostgreSQL 显式写入 id 不会推进序列，同步到最大 id，避免被测代码插入时主键冲突
+	if driver == DriverPostgreSQL && hasID {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence($1, 'id'), MAX(id)) FROM %s", quoteIdent(driver, table.name))
+		if _, err := db.ExecContext(ctx, query, table.name); err != nil {
+			return fmt.Errorf("fixtures: 同步 %s 序列失败: %w", table.name, err)
+		}
+	}
+	return nil
+}
+
+// templatePattern 模板值：{{ 函数 }} 或 {{ 函数 "参数" }}
+var templatePattern = regexp.MustCompile(`^\{\{\s*(\w+)(?:\s+"([^"]*)")?\s*\}\}$`)
+
+// resolver 解析测试数据中的模板值（同一密码的哈希在一次加载内复用）
+type resolver struct {
+	clock  common.Clock
+	hashes map[string]string
+}
+
+func (r *resolver) resolve(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, "{{") || !strings.HasSuffix(v, "}}") {
+			return v, nil
+		}
+		m := templatePattern.FindStringSubmatch(v)
+		if m == nil {
+			return nil, fmt.Errorf("无法解析模板 %s", v)
+		}
+		switch m[1] {
+		case "now":
+			if m[2] == "" {
+				return r.clock.Now(), nil
+			}
+			offset, err := parseOffset(m[2])
+			if err != nil {
+				return nil, err
+			}
+			return r.clock.Now().Add(offset), nil
+		case "bcrypt":
+			if hash, ok := r.hashes[m[2]]; ok {
+				return hash, nil
+			}
+			hash, err := common.HashPasswordWithCost(m[2], bcrypt.MinCost)
+			if err != nil {
+				return nil, err
+			}
+			r.hashes[m[2]] = hash
+			return hash, nil
+		default:
+			return nil, fmt.Errorf("未知的模板函数 %s", m[1])
+		}
+	case map[string]any, []any:
+		// 嵌套结构按 JSON 写入（JSON 列）
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return v, nil
+	}
+}
+
+// parseOffset 解析相对时间：time.ParseDuration 格式，另支持以天数开头（"+3d"、"-1d12h"）
+func parseOffset(s string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(s, "d")
+	if !ok {
+		return time.ParseDuration(s)
+	}
+	sign := time.Duration(1)
+	if strings.HasPrefix(days, "-") {
+		sign = -1
+	}
+	n, err := strconv.Atoi(strings.TrimLeft(days, "+-"))
+	if err != nil {
+		return 0, fmt.Errorf("无效的相对时间 %q", s)
+	}
+	offset := time.Duration(n) * 24 * time.Hour
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("无效的相对时间 %q", s)
+		}
+		offset += d
+	}
+	return sign * offset, nil
+}
+
+// quoteIdent 按方言引用标识符
+func quoteIdent(driver, name string) string {
+	if driver == DriverMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// placeholder 按方言生成第 n 个参数占位符
+func placeholder(driver string, n int) string {
+	if driver == DriverPostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Rebind 把 ? 占位符转换为驱动的格式（PostgreSQL 使用 $1, $2, ...，其他驱动原样返回）
+//
+// 手写 SQL 的存储（如 notify、webhook）统一用 ? 编写，执行前按驱动转换
+//
+// 使用方式：
+//
+//	rows, err := db.QueryContext(ctx, database.Rebind(s.driver, "SELECT id FROM jobs WHERE status = ? LIMIT ?"), status, limit)
+func Rebind(driver, query string) string {
+	if driver != DriverPostgreSQL {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(placeholder(driver, n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// queryStrings 执行单列查询
+func queryStrings(ctx context.Context, conn *sql.Conn, query string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
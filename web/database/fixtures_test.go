@@ -0,0 +1,450 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB 记录执行语句的内存驱动（仓库不引入 SQLite / sqlmock，方言 SQL 以语句断言）
+//
+// 需要模拟数据的测试设置 onExec / onQuery 按语句返回结果（语句先记录再调用，调用时不持有锁）
+type fakeDB struct {
+	mu      sync.Mutex
+	conns   int
+	execs   []fakeExec
+	results map[string][][]driver.Value // 查询语句片段 → 结果行
+
+	beginErr error // 非 nil 时开启事务失败
+	onExec   func(conn int, query string, args []any) (driver.Result, error)
+	onQuery  func(conn int, query string, args []any) ([][]driver.Value, error)
+}
+
+type fakeExec struct {
+	conn  int
+	query string
+	args  []any
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	f := &fakeDB{results: map[string][][]driver.Value{}}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return f, db
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns++
+	return &fakeConn{db: f, id: f.conns}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver { return nil }
+
+func (f *fakeDB) record(conn int, query string, args []driver.NamedValue) []any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := fakeExec{conn: conn, query: query}
+	for _, a := range args {
+		e.args = append(e.args, a.Value)
+	}
+	f.execs = append(f.execs, e)
+	return e.args
+}
+
+func (f *fakeDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, e := range f.execs {
+		out = append(out, e.query)
+	}
+	return out
+}
+
+// statementsWith 以 prefix 开头的语句
+func (f *fakeDB) statementsWith(prefix string) []string {
+	var out []string
+	for _, q := range f.statements() {
+		if strings.HasPrefix(q, prefix) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// visible 已提交的语句（事务外执行的与已提交事务中的，不含 BEGIN / COMMIT / ROLLBACK），
+// conn 不为 0 时加上该连接上尚未结束的事务中的语句
+func (f *fakeDB) visible(conn int) []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeExec
+	open := map[int][]fakeExec{}
+	for _, e := range f.execs {
+		pending, inTx := open[e.conn]
+		switch {
+		case e.query == "BEGIN":
+			open[e.conn] = []fakeExec{}
+		case e.query == "COMMIT":
+			out = append(out, pending...)
+			delete(open, e.conn)
+		case e.query == "ROLLBACK":
+			delete(open, e.conn)
+		case inTx:
+			open[e.conn] = append(pending, e)
+		default:
+			out = append(out, e)
+		}
+	}
+	return append(out, open[conn]...)
+}
+
+func (f *fakeDB) inserts() []fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeExec
+	for _, e := range f.execs {
+		if strings.HasPrefix(e.query, "INSERT") {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+type fakeConn struct {
+	db *fakeDB
+	id int
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if c.db.beginErr != nil {
+		return nil, c.db.beginErr
+	}
+	c.db.record(c.id, "BEGIN", nil)
+	return fakeTx{c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := c.db.record(c.id, query, args)
+	if c.db.onExec != nil {
+		return c.db.onExec(c.id, query, values)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := c.db.record(c.id, query, args)
+	if c.db.onQuery != nil {
+		rows, err := c.db.onQuery(c.id, query, values)
+		if err != nil {
+			return nil, err
+		}
+		return &fakeRows{rows: rows}, nil
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for fragment, rows := range c.db.results {
+		if strings.Contains(query, fragment) {
+			return &fakeRows{rows: rows}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+// fakeStmt 预编译语句（执行时与直接执行相同）
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, namedValues(args))
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (t fakeTx) Commit() error   { t.c.db.record(t.c.id, "COMMIT", nil); return nil }
+func (t fakeTx) Rollback() error { t.c.db.record(t.c.id, "ROLLBACK", nil); return nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{"a", "b"}
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+var shopFixtures = fstest.MapFS{
+	"fixtures/users.yml": {Data: []byte(`
+users:
+  - id: 1
+    name: alice
+    password: '{{ bcrypt "secret" }}'
+    created_at: '{{ now "-1d" }}'
+  - id: 2
+    name: bob
+    invited_by: 1
+    password: '{{ bcrypt "secret" }}'
+    created_at: '{{ now }}'
+`)},
+	"fixtures/orders.json": {Data: []byte(`{
+  "order_items": [{"order_id": 10, "sku": "A-1", "qty": 2}],
+  "orders": [{"id": 10, "user_id": 1, "paid_at": "{{ now \"-2h30m\" }}", "meta": {"channel": "web"}}]
+}`)},
+	"fixtures/README.txt": {Data: []byte("ignored")},
+}
+
+var fixtureNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLoadFixtures_ForeignKeyOrderAndTemplates(t *testing.T) {
+	f, db := newFakeDB(t)
+	f.results["information_schema.table_constraints"] = [][]driver.Value{
+		{"order_items", "orders"},
+		{"orders", "users"},
+		{"users", "users"}, // 自引用不影响排序
+		{"audit_logs", "users"},
+	}
+
+	err := LoadFixtures(context.Background(), db, shopFixtures, "fixtures",
+		WithDriver(DriverPostgreSQL), WithClock(common.NewFakeClock(fixtureNow)))
+	require.NoError(t, err)
+
+	inserts := f.inserts()
+	require.Len(t, inserts, 4)
+	assert.Equal(t, `INSERT INTO "users" ("created_at", "id", "name", "password") VALUES ($1, $2, $3, $4)`, inserts[0].query)
+	assert.Equal(t, `INSERT INTO "users" ("created_at", "id", "invited_by", "name", "password") VALUES ($1, $2, $3, $4, $5)`, inserts[1].query)
+	assert.Equal(t, `INSERT INTO "orders" ("id", "meta", "paid_at", "user_id") VALUES ($1, $2, $3, $4)`, inserts[2].query)
+	assert.Equal(t, `INSERT INTO "order_items" ("order_id", "qty", "sku") VALUES ($1, $2, $3)`, inserts[3].query)
+
+	assert.Equal(t, fixtureNow.Add(-24*time.Hour), inserts[0].args[0])
+	assert.Equal(t, fixtureNow, inserts[1].args[0])
+	assert.Equal(t, fixtureNow.Add(-150*time.Minute), inserts[2].args[2])
+	assert.Equal(t, `{"channel":"web"}`, inserts[2].args[1], "嵌套结构按 JSON 写入")
+
+	hash := inserts[0].args[3].(string)
+	assert.True(t, common.CheckPassword(hash, "secret"))
+	assert.Equal(t, hash, inserts[1].args[4], "同一密码只计算一次哈希")
+
+	// 显式写入 id 的表同步序列
+	var setvals []string
+	for _, q := range f.statements() {
+		if strings.Contains(q, "setval") {
+			setvals = append(setvals, q)
+		}
+	}
+	assert.Equal(t, []string{
+		`SELECT setval(pg_get_serial_sequence($1, 'id'), MAX(id)) FROM "users"`,
+		`SELECT setval(pg_get_serial_sequence($1, 'id'), MAX(id)) FROM "orders"`,
+	}, setvals)
+}
+
+func TestLoadFixtures_OrderFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fx/_order.yml": {Data: []byte("[b, a]\n")},
+		"fx/a.yml":      {Data: []byte("a:\n  - id: 1\n")},
+		"fx/b.yml":      {Data: []byte("b:\n  - id: 2\n    a_id: 1\n")},
+	}
+	f, db := newFakeDB(t)
+	require.NoError(t, LoadFixtures(context.Background(), db, fsys, "fx", WithDriver(DriverMySQL)))
+
+	assert.Equal(t, []string{
+		"INSERT INTO `b` (`a_id`, `id`) VALUES (?, ?)",
+		"INSERT INTO `a` (`id`) VALUES (?)",
+	}, f.statements(), "显式顺序时不查询外键，MySQL 不同步序列")
+
+	fsys["fx/c.yml"] = &fstest.MapFile{Data: []byte("c:\n  - id: 3\n")}
+	err := LoadFixtures(context.Background(), db, fsys, "fx", WithDriver(DriverMySQL))
+	assert.ErrorContains(t, err, "表 c 未在 _order.yml 中列出")
+}
+
+func TestLoadFixtures_Errors(t *testing.T) {
+	ctx := context.Background()
+	load := func(fsys fstest.MapFS, setup func(f *fakeDB)) error {
+		f, db := newFakeDB(t)
+		if setup != nil {
+			setup(f)
+		}
+		return LoadFixtures(ctx, db, fsys, "fx", WithDriver(DriverMySQL))
+	}
+
+	err := load(fstest.MapFS{
+		"fx/a.yml": {Data: []byte("a: [{id: 1}]\nb: [{id: 1}]\n")},
+	}, func(f *fakeDB) {
+		f.results["KEY_COLUMN_USAGE"] = [][]driver.Value{{"a", "b"}, {"b", "a"}}
+	})
+	assert.ErrorContains(t, err, "表 a, b 存在循环外键")
+
+	err = load(fstest.MapFS{"fx/a.yml": {Data: []byte(`a: [{name: "{{ uuid }}"}]`)}}, nil)
+	assert.ErrorContains(t, err, "a 第 1 行 name: 未知的模板函数 uuid")
+
+	err = load(fstest.MapFS{"fx/a.yml": {Data: []byte(`a: [{at: "{{ now -2h }}"}]`)}}, nil)
+	assert.ErrorContains(t, err, "无法解析模板")
+
+	err = load(fstest.MapFS{"fx/a.yml": {Data: []byte("a: not-a-list\n")}}, nil)
+	assert.ErrorContains(t, err, "解析 a.yml 失败")
+
+	_, db := newFakeDB(t)
+	err = LoadFixtures(ctx, db, fstest.MapFS{}, "missing", WithDriver(DriverMySQL))
+	assert.ErrorContains(t, err, "读取目录 missing 失败")
+	err = LoadFixtures(ctx, db, fstest.MapFS{}, "missing", WithDriver("sqlite"))
+	assert.ErrorContains(t, err, `不支持的驱动 "sqlite"`)
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"-2h", -2 * time.Hour},
+		{"+90m", 90 * time.Minute},
+		{"3d", 72 * time.Hour},
+		{"+3d", 72 * time.Hour},
+		{"-1d12h", -36 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseOffset(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+	for _, bad := range []string{"xd", "1d-2h", "soon"} {
+		_, err := parseOffset(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTruncateAll(t *testing.T) {
+	old := driverName
+	t.Cleanup(func() { driverName = old })
+
+	t.Run("mysql", func(t *testing.T) {
+		driverName = DriverMySQL
+		f, db := newFakeDB(t)
+		f.results["information_schema.TABLES"] = [][]driver.Value{{"users"}, {"schema_migrations"}, {"orders"}}
+
+		require.NoError(t, TruncateAll(context.Background(), db, "schema_migrations"))
+		assert.Equal(t, []string{
+			"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'",
+			"SET FOREIGN_KEY_CHECKS = 0",
+			"TRUNCATE TABLE `orders`",
+			"TRUNCATE TABLE `users`",
+			"SET FOREIGN_KEY_CHECKS = 1",
+		}, f.statements())
+		for _, e := range f.execs {
+			assert.Equal(t, f.execs[0].conn, e.conn, "会话变量与 TRUNCATE 在同一连接上执行")
+		}
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		driverName = DriverPostgreSQL
+		f, db := newFakeDB(t)
+		f.results["pg_tables"] = [][]driver.Value{{"users"}, {"orders"}}
+
+		require.NoError(t, TruncateAll(context.Background(), db))
+		assert.Equal(t, `TRUNCATE TABLE "orders", "users" RESTART IDENTITY CASCADE`, f.statements()[1])
+	})
+
+	t.Run("nothing to truncate", func(t *testing.T) {
+		driverName = DriverPostgreSQL
+		f, db := newFakeDB(t)
+		f.results["pg_tables"] = [][]driver.Value{{"schema_migrations"}}
+
+		require.NoError(t, TruncateAll(context.Background(), db, "schema_migrations"))
+		assert.Len(t, f.statements(), 1)
+	})
+}
+
+func TestLoadFixturesTx(t *testing.T) {
+	old := driverName
+	driverName = DriverMySQL
+	t.Cleanup(func() { driverName = old })
+
+	f, db := newFakeDB(t)
+	fsys := fstest.MapFS{
+		"fx/_order.yml": {Data: []byte("[users]\n")},
+		"fx/users.yml":  {Data: []byte("users: [{id: 1, name: alice}]\n")},
+	}
+
+	t.Run("test body", func(t *testing.T) {
+		ctx := LoadFixturesTx(t, db, fsys, "fx")
+		_, ok := Conn(ctx).(*sql.Tx)
+		require.True(t, ok, "被测代码通过 Conn(ctx) 取到测试事务")
+		_, err := Conn(ctx).ExecContext(ctx, "UPDATE users SET name = ?", "bob")
+		require.NoError(t, err)
+	})
+
+	assert.Equal(t, []string{
+		"BEGIN",
+		"INSERT INTO `users` (`id`, `name`) VALUES (?, ?)",
+		"UPDATE users SET name = ?",
+		"ROLLBACK",
+	}, f.statements(), "测试结束后回滚")
+}
+
+func TestConn(t *testing.T) {
+	old := DB
+	t.Cleanup(func() { DB = old })
+
+	DB = nil
+	assert.Nil(t, Conn(context.Background()))
+
+	_, db := newFakeDB(t)
+	DB = db
+	assert.Same(t, db, Conn(context.Background()))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	assert.Same(t, tx, Conn(WithDBTX(context.Background(), tx)))
+}
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "UPDATE t SET a = $1 WHERE id IN ($2, $3)", Rebind(DriverPostgreSQL, "UPDATE t SET a = ? WHERE id IN (?, ?)"))
+	assert.Equal(t, "SELECT ?", Rebind(DriverMySQL, "SELECT ?"))
+}
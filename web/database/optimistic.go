@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrVersionConflict 乐观锁冲突：UPDATE 没有命中任何行（版本号已被其他请求修改，或行已不存在）
+//
+// web.ExceptionHandler / web.WrapHandler 把它映射为 409 + VersionConflict 业务码，客户端据此重新获取后重试
+var ErrVersionConflict = errors.New("version conflict")
+
+// CheckOptimisticUpdate 检查乐观锁 UPDATE 的执行结果，影响 0 行时返回 ErrVersionConflict
+//
+// 约定：更新语句带上读取时的版本号并递增，即 SET ..., version = version + 1 WHERE id = ? AND version = ?。
+// 由于 version 每次都会变化，MySQL 默认的「实际修改行数」语义下命中即影响 1 行，不需要开启 clientFoundRows
+//
+// 使用方式（sqlc 查询声明为 :execresult）：
+//
+//	err := database.CheckOptimisticUpdate(q.UpdateUserName(ctx, sqlc.UpdateUserNameParams{
+//	    Name: req.Name, ID: id, Version: version,
+//	}))
+//	if err != nil {
+//	    return err // 冲突时为 ErrVersionConflict
+//	}
+func CheckOptimisticUpdate(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("读取影响行数失败: %w", err)
+	}
+	if n == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// RetryOnConflict 执行 fn，返回 ErrVersionConflict 时重试，最多执行 attempts 次
+//
+// 用于服务端合并策略（如累加计数、合并标签）：fn 每次都要重新读取最新版本再更新，
+// 且应在自己的事务中执行（如 WithTx）——REPEATABLE READ 下同一事务内重读只能看到旧快照。
+// 两次尝试之间随机等待几毫秒以错开竞争者；其他错误与 ctx 取消立即返回。
+// 次数用尽后返回最后一次的 ErrVersionConflict
+//
+// 使用方式：
+//
+//	err := database.RetryOnConflict(ctx, 3, func(ctx context.Context) error {
+//	    return database.WithTx(ctx, func(ctx context.Context) error {
+//	        q := sqlc.New(database.Conn(ctx))
+//	        user, err := q.GetUser(ctx, id)
+//	        if err != nil {
+//	            return err
+//	        }
+//	        return database.CheckOptimisticUpdate(q.UpdateUserName(ctx, sqlc.UpdateUserNameParams{
+//	            Name: merge(user.Name, req.Name), ID: id, Version: user.Version,
+//	        }))
+//	    })
+//	})
+func RetryOnConflict(ctx context.Context, attempts int, fn func(ctx context.Context) error) error {
+	var err error
+	for i := range max(attempts, 1) {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(1+rand.IntN(5*i)) * time.Millisecond):
+			}
+		}
+		if err = fn(ctx); !errors.Is(err, ErrVersionConflict) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOptimisticUpdate(t *testing.T) {
+	assert.NoError(t, CheckOptimisticUpdate(driver.RowsAffected(1), nil))
+	assert.ErrorIs(t, CheckOptimisticUpdate(driver.RowsAffected(0), nil), ErrVersionConflict)
+
+	boom := errors.New("boom")
+	assert.ErrorIs(t, CheckOptimisticUpdate(nil, boom), boom, "执行错误原样返回")
+	assert.Error(t, CheckOptimisticUpdate(driver.ResultNoRows, nil), "驱动不支持影响行数")
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	calls := 0
+	err := RetryOnConflict(context.Background(), 3, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = RetryOnConflict(context.Background(), 2, func(ctx context.Context) error {
+		calls++
+		return ErrVersionConflict
+	})
+	assert.ErrorIs(t, err, ErrVersionConflict, "次数用尽后返回冲突")
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	boom := errors.New("boom")
+	err = RetryOnConflict(context.Background(), 3, func(ctx context.Context) error {
+		calls++
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 1, calls, "其他错误不重试")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = RetryOnConflict(ctx, 3, func(ctx context.Context) error {
+		cancel()
+		return ErrVersionConflict
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
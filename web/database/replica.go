@@ -0,0 +1,396 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/ledger"
+)
+
+// ReplicaConfig 只读副本配置（嵌在 DatabaseConfig 中，未配置的连接参数沿用主库）
+//
+// Example:
+//
+//	[web.database.replica]
+//	host = "db-replica.internal"
+//	user = "app_ro"
+//	password = "env:DB_REPLICA_PASSWORD"
+//	strict = true   # 启动时检查副本用户没有写权限，有则告警
+type ReplicaConfig struct {
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	User     string `toml:"user"`
+	Password string `toml:"password" sensitive:"true"`
+	Strict   bool   `toml:"strict"`
+}
+
+// ReplicaDB 只读副本连接池（未配置副本时为 nil，Replica() 回退到主库）
+var ReplicaDB *sql.DB
+
+// ErrReplicaWrite 通过 Replica() 执行了写语句
+var ErrReplicaWrite = errors.New("只读副本连接不能执行写语句")
+
+// StatementKind 语句分类
+type StatementKind int
+
+const (
+	StatementRead        StatementKind = iota // 只读查询
+	StatementWrite                            // 写入 / DDL / 其他有副作用的语句
+	StatementLockingRead                      // SELECT ... FOR UPDATE 等加锁读
+)
+
+// ClassifyStatement 轻量的语句分类（关键字检查，不是完整的 SQL 解析）
+//
+// 忽略注释、字符串和带引号的标识符；WITH 开头的 CTE 按其中的语句判断
+// （PostgreSQL 的 WITH ... AS (DELETE ...) 视为写入），SELECT ... INTO 视为写入
+func ClassifyStatement(query string) StatementKind {
+	tokens := sqlTokens(query)
+	if len(tokens) == 0 {
+		return StatementWrite
+	}
+	switch tokens[0].word {
+	case "SELECT", "WITH", "VALUES", "TABLE":
+	case "SHOW", "DESCRIBE", "DESC":
+		return StatementRead
+	case "EXPLAIN":
+		// EXPLAIN ANALYZE 会真正执行语句
+		if len(tokens) > 1 && tokens[1].word == "ANALYZE" {
+			return ClassifyStatement(query[tokens[1].end:])
+		}
+		return StatementRead
+	default:
+		return StatementWrite
+	}
+
+	locking := false
+	for i, t := range tokens {
+		switch t.word {
+		case "INSERT", "DELETE", "MERGE", "REPLACE", "TRUNCATE":
+			// MySQL 的 REPLACE() / INSERT() / TRUNCATE() 是字符串和数值函数
+			if !t.call {
+				return StatementWrite
+			}
+		case "UPDATE":
+			// FOR UPDATE / FOR NO KEY UPDATE 是加锁读
+			if i > 0 && (tokens[i-1].word == "FOR" || tokens[i-1].word == "KEY") {
+				locking = true
+				continue
+			}
+			return StatementWrite
+		case "SHARE":
+			if i > 0 && (tokens[i-1].word == "FOR" || tokens[i-1].word == "KEY" || tokens[i-1].word == "IN") {
+				locking = true
+			}
+		case "INTO":
+			if t.depth == 0 {
+				return StatementWrite
+			}
+		}
+	}
+	if locking {
+		return StatementLockingRead
+	}
+	return StatementRead
+}
+
+type sqlToken struct {
+	word  string // 大写关键字 / 标识符
+	depth int    // 括号深度
+	end   int    // 在原语句中的结束位置
+	call  bool   // 后面紧跟左括号（函数调用）
+}
+
+// sqlTokens 提取语句中的单词（跳过注释、字符串、带引号的标识符和 $$ 字符串）
+func sqlTokens(query string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == '-' && strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(query)
+			}
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(query)
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			i = skipQuoted(query, i)
+		case ch == '$' && i+1 < len(query) && !isDigit(query[i+1]):
+			// $tag$ ... $tag$（$1 等占位符不是）
+			j := i + 1
+			for j < len(query) && isWordChar(query[j]) {
+				j++
+			}
+			if j < len(query) && query[j] == '$' {
+				tag := query[i : j+1]
+				if k := strings.Index(query[j+1:], tag); k >= 0 {
+					i = j + 1 + k + len(tag)
+				} else {
+					i = len(query)
+				}
+			} else {
+				i = j
+			}
+		case ch == '(':
+			depth++
+			i++
+		case ch == ')':
+			depth--
+			i++
+		case isWordChar(ch) && !isDigit(ch):
+			j := i
+			for j < len(query) && isWordChar(query[j]) {
+				j++
+			}
+			rest := strings.TrimLeft(query[j:], " \t\r\n")
+			tokens = append(tokens, sqlToken{word: strings.ToUpper(query[i:j]), depth: depth, end: j, call: strings.HasPrefix(rest, "(")})
+			i = j
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// skipQuoted 跳过引号包围的内容（重复引号和反斜杠转义）
+func skipQuoted(query string, i int) int {
+	quote := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			j++
+		case quote:
+			if j+1 < len(query) && query[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+func isDigit(ch byte) bool { return ch >= '0' && ch <= '9' }
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || isDigit(ch) || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= 0x80
+}
+
+// checkReadOnly 拒绝写语句和加锁读
+func checkReadOnly(query string) error {
+	switch ClassifyStatement(query) {
+	case StatementWrite:
+		return fmt.Errorf("%w，请通过 database.Conn(ctx) 在主库执行: %s", ErrReplicaWrite, abbreviate(query))
+	case StatementLockingRead:
+		return fmt.Errorf("%w：加锁读（FOR UPDATE / FOR SHARE）必须在主库执行，请使用 database.Conn(ctx): %s", ErrReplicaWrite, abbreviate(query))
+	}
+	return nil
+}
+
+func abbreviate(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > 120 {
+		return query[:120] + "..."
+	}
+	return query
+}
+
+// readOnlyDB 只读 DBTX：执行前检查语句，查询记入账本的 db_replica 类别
+type readOnlyDB struct {
+	db DBTX
+}
+
+// Replica 只读副本的 DBTX（未配置副本时使用主库连接池，仍然执行只读检查）
+//
+// INSERT / UPDATE / DELETE / DDL 等在到达驱动之前返回 ErrReplicaWrite；
+// SELECT ... FOR UPDATE 同样被拒绝并提示改用主库
+//
+// 使用方式：
+//
+//	q := sqlc.New(database.Replica())
+//	report, err := q.MonthlyReport(ctx, month)
+func Replica() DBTX {
+	if ReplicaDB != nil {
+		return ReadOnly(ReplicaDB)
+	}
+	return ReadOnly(DB)
+}
+
+// ReadOnly 给连接池加上与 Replica() 相同的只读检查（db 为 nil 时返回 nil）
+//
+// 自行持有副本连接池时使用（见 OpenReplica 与 web.App.Replica）
+func ReadOnly(db *sql.DB) DBTX {
+	if db == nil {
+		return nil
+	}
+	return readOnlyDB{db: db}
+}
+
+func (r readOnlyDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := checkReadOnly(query); err != nil {
+		return nil, err
+	}
+	defer recordReplica(ctx, time.Now())
+	return r.db.ExecContext(ctx, query, args...)
+}
+
+func (r readOnlyDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if err := checkReadOnly(query); err != nil {
+		return nil, err
+	}
+	return r.db.PrepareContext(ctx, query)
+}
+
+func (r readOnlyDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := checkReadOnly(query); err != nil {
+		return nil, err
+	}
+	defer recordReplica(ctx, time.Now())
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+func (r readOnlyDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if err := checkReadOnly(query); err != nil {
+		// *sql.Row 无法直接构造，借助一个总是返回该错误的连接池，错误在 Scan 时返回
+		return rejectDB.QueryRowContext(context.WithValue(ctx, rejectErrKey{}, err), query)
+	}
+	defer recordReplica(ctx, time.Now())
+	return r.db.QueryRowContext(ctx, query, args...)
+}
+
+func recordReplica(ctx context.Context, start time.Time) {
+	ledger.From(ctx).Add(ledger.DBReplica, 1, time.Since(start))
+}
+
+// rejectDB 所有查询都返回 ctx 中错误的连接池（用于构造带错误的 *sql.Row）
+var rejectDB = sql.OpenDB(rejectConnector{})
+
+type rejectErrKey struct{}
+
+type rejectConnector struct{}
+
+func (rejectConnector) Connect(context.Context) (driver.Conn, error) { return rejectConn{}, nil }
+func (rejectConnector) Driver() driver.Driver                        { return nil }
+
+type rejectConn struct{}
+
+func (rejectConn) Prepare(string) (driver.Stmt, error) { return nil, ErrReplicaWrite }
+func (rejectConn) Close() error                        { return nil }
+func (rejectConn) Begin() (driver.Tx, error)           { return nil, ErrReplicaWrite }
+
+func (rejectConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err, ok := ctx.Value(rejectErrKey{}).(error); ok {
+		return nil, err
+	}
+	return nil, ErrReplicaWrite
+}
+
+// replicaConfig 副本的完整连接配置（未配置的参数沿用主库）
+func replicaConfig(cfg DatabaseConfig) DatabaseConfig {
+	r := cfg
+	r.Host = cfg.Replica.Host
+	if cfg.Replica.Port != 0 {
+		r.Port = cfg.Replica.Port
+	}
+	if cfg.Replica.User != "" {
+		r.User, r.Password = cfg.Replica.User, cfg.Replica.Password
+	}
+	return r
+}
+
+// readOnlyDSN 在连接字符串中把会话设为只读（驱动支持时）
+func readOnlyDSN(driverName, dsn string) string {
+	switch driverName {
+	case DriverMySQL:
+		// go-sql-driver 把未知参数作为会话变量 SET
+		if strings.Contains(dsn, "?") {
+			return dsn + "&transaction_read_only=1"
+		}
+		return dsn + "?transaction_read_only=1"
+	case DriverPostgreSQL:
+		// lib/pq 把未知参数作为运行时参数发送
+		return dsn + " default_transaction_read_only=on"
+	}
+	return dsn
+}
+
+// OpenReplica 打开一个独立的只读副本连接池（不影响全局的 ReplicaDB，副本未配置 host 时返回 nil, nil）
+//
+// 同一进程中的多个服务各自持有副本时使用（见 web.NewApp），调用方负责 Close
+func OpenReplica(cfg DatabaseConfig) (*sql.DB, error) {
+	if cfg.Replica.Host == "" {
+		return nil, nil
+	}
+	rc := replicaConfig(cfg)
+	db, err := sql.Open(rc.Driver, readOnlyDSN(rc.Driver, buildDSN(rc)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpen)
+	db.SetMaxIdleConns(cfg.MaxIdle)
+	db.SetConnMaxLifetime(time.Hour)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping replica: %w", err)
+	}
+	if cfg.Replica.Strict {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		grants, err := WriteGrants(ctx, db, rc.Driver)
+		switch {
+		case err != nil:
+			log.Warnf("[Database] 无法检查副本用户 %s 的权限: %v", rc.User, err)
+		case len(grants) > 0:
+			log.Warnf("[Database] 副本用户 %s 拥有写权限 %v，建议改用只读账号（应用层仍会拒绝写语句）", rc.User, grants)
+		}
+	}
+	return db, nil
+}
+
+// WriteGrants 查询当前用户的写权限（information_schema），返回权限名列表，没有写权限时为空
+func WriteGrants(ctx context.Context, db *sql.DB, driverName string) ([]string, error) {
+	var query string
+	switch driverName {
+	case DriverMySQL:
+		query = `SELECT DISTINCT PRIVILEGE_TYPE FROM (
+  SELECT GRANTEE, PRIVILEGE_TYPE FROM information_schema.USER_PRIVILEGES
+  UNION ALL SELECT GRANTEE, PRIVILEGE_TYPE FROM information_schema.SCHEMA_PRIVILEGES WHERE TABLE_SCHEMA = DATABASE()
+  UNION ALL SELECT GRANTEE, PRIVILEGE_TYPE FROM information_schema.TABLE_PRIVILEGES WHERE TABLE_SCHEMA = DATABASE()
+) p
+WHERE GRANTEE = CONCAT('''', SUBSTRING_INDEX(CURRENT_USER(), '@', 1), '''@''', SUBSTRING_INDEX(CURRENT_USER(), '@', -1), '''')
+  AND PRIVILEGE_TYPE IN ('INSERT', 'UPDATE', 'DELETE', 'CREATE', 'DROP', 'ALTER')
+ORDER BY PRIVILEGE_TYPE`
+	case DriverPostgreSQL:
+		query = `SELECT DISTINCT privilege_type FROM information_schema.table_privileges
+WHERE grantee IN (current_user, 'PUBLIC') AND table_schema NOT IN ('pg_catalog', 'information_schema')
+  AND privilege_type IN ('INSERT', 'UPDATE', 'DELETE', 'TRUNCATE')
+ORDER BY privilege_type`
+	default:
+		return nil, fmt.Errorf("不支持的驱动: %s", driverName)
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var grants []string
+	for rows.Next() {
+		var g string
+		if err := rows.Scan(&g); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  StatementKind
+	}{
+		{"SELECT id, name FROM users WHERE id = ?", StatementRead},
+		{"  select * from orders", StatementRead},
+		{"/* report */ SELECT count(*) FROM orders", StatementRead},
+		{"-- by user\nSELECT * FROM orders WHERE user_id = $1", StatementRead},
+		{"(SELECT 1) UNION (SELECT 2)", StatementRead},
+		{"WITH recent AS (SELECT * FROM orders WHERE created_at > $1) SELECT * FROM recent", StatementRead},
+		{"WITH RECURSIVE tree AS (SELECT id FROM nodes UNION ALL SELECT n.id FROM nodes n JOIN tree t ON n.parent = t.id) SELECT * FROM tree", StatementRead},
+		{"SELECT * FROM audit WHERE action = 'DELETE' OR note = 'insert; update'", StatementRead},
+		{`SELECT "update", "delete" FROM "insert"`, StatementRead},
+		{"SELECT `delete` FROM `update`", StatementRead},
+		{"SELECT REPLACE(name, 'a', 'b'), TRUNCATE(price, 2), INSERT(code, 1, 2, 'x') FROM items", StatementRead},
+		{"SELECT $$DROP TABLE users$$ AS text", StatementRead},
+		{"SELECT updated_at, deleted FROM users", StatementRead},
+		{"SHOW TABLES", StatementRead},
+		{"EXPLAIN SELECT * FROM users", StatementRead},
+		{"EXPLAIN DELETE FROM users", StatementRead},
+		{"VALUES (1), (2)", StatementRead},
+
+		{"INSERT INTO users (name) VALUES (?)", StatementWrite},
+		{"update users set name = ?", StatementWrite},
+		{"DELETE FROM sessions WHERE expires_at < now()", StatementWrite},
+		{"REPLACE INTO kv (k, v) VALUES (?, ?)", StatementWrite},
+		{"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET v = s.v", StatementWrite},
+		{"CREATE TABLE t (id int)", StatementWrite},
+		{"ALTER TABLE users ADD COLUMN age int", StatementWrite},
+		{"DROP TABLE users", StatementWrite},
+		{"TRUNCATE users", StatementWrite},
+		{"GRANT SELECT ON users TO app", StatementWrite},
+		{"CALL refresh_stats()", StatementWrite},
+		{"SET SESSION sql_mode = ''", StatementWrite},
+		{"", StatementWrite},
+		{"WITH gone AS (DELETE FROM sessions RETURNING id) SELECT count(*) FROM gone", StatementWrite},
+		{"WITH x AS (SELECT 1) UPDATE users SET active = false", StatementWrite},
+		{"WITH x AS (SELECT 1) INSERT INTO log SELECT * FROM x", StatementWrite},
+		{"SELECT * INTO backup_users FROM users", StatementWrite},
+		{"SELECT * FROM users INTO OUTFILE '/tmp/u.csv'", StatementWrite},
+		{"EXPLAIN ANALYZE DELETE FROM users", StatementWrite},
+		{"EXPLAIN ANALYZE SELECT * FROM users", StatementRead},
+
+		{"SELECT * FROM accounts WHERE id = ? FOR UPDATE", StatementLockingRead},
+		{"select * from accounts where id = $1 for no key update skip locked", StatementLockingRead},
+		{"SELECT * FROM accounts FOR SHARE", StatementLockingRead},
+		{"SELECT * FROM accounts LOCK IN SHARE MODE", StatementLockingRead},
+		{"WITH a AS (SELECT * FROM accounts WHERE id = $1 FOR UPDATE) SELECT * FROM a", StatementLockingRead},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ClassifyStatement(tt.query), tt.query)
+	}
+}
+
+func TestReplica_RejectsWritesBeforeDriver(t *testing.T) {
+	f, db := newFakeDB(t)
+	ReplicaDB = db
+	t.Cleanup(func() { ReplicaDB = nil })
+	ctx, l := ledger.Open(context.Background())
+	defer l.Close()
+
+	replica := Replica()
+	_, err := replica.ExecContext(ctx, "UPDATE users SET name = ?", "x")
+	assert.ErrorIs(t, err, ErrReplicaWrite)
+	assert.Contains(t, err.Error(), "UPDATE users SET name = ?")
+	_, err = replica.PrepareContext(ctx, "DELETE FROM users")
+	assert.ErrorIs(t, err, ErrReplicaWrite)
+	_, err = replica.QueryContext(ctx, "SELECT * FROM accounts WHERE id = ? FOR UPDATE", 1)
+	assert.ErrorIs(t, err, ErrReplicaWrite)
+	assert.Contains(t, err.Error(), "主库")
+
+	var id int
+	err = replica.QueryRowContext(ctx, "INSERT INTO users (name) VALUES ('a') RETURNING id").Scan(&id)
+	assert.ErrorIs(t, err, ErrReplicaWrite)
+	assert.Empty(t, f.statements(), "写语句不会到达驱动")
+
+	rows, err := replica.QueryContext(ctx, "SELECT id FROM users")
+	require.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, []string{"SELECT id FROM users"}, f.statements())
+	assert.Equal(t, int64(1), l.Snapshot().Get(ledger.DBReplica).Count, "副本查询单独记账")
+	assert.Zero(t, l.Snapshot().Get(ledger.DB).Count)
+}
+
+func TestReplica_FallsBackToPrimary(t *testing.T) {
+	f, db := newFakeDB(t)
+	DB = db
+	t.Cleanup(func() { DB = nil })
+
+	_, err := Replica().ExecContext(context.Background(), "DROP TABLE users")
+	assert.ErrorIs(t, err, ErrReplicaWrite, "未配置副本时同样拒绝写语句")
+	rows, err := Replica().QueryContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, []string{"SELECT 1"}, f.statements())
+
+	DB = nil
+	assert.Nil(t, Replica())
+}
+
+func TestReplicaConfig(t *testing.T) {
+	cfg := DatabaseConfig{Driver: DriverPostgreSQL, Host: "primary", Port: 5432, User: "app", Password: "pw", DBName: "shop",
+		Replica: ReplicaConfig{Host: "replica", User: "app_ro", Password: "ro"}}
+	rc := replicaConfig(cfg)
+	assert.Equal(t, "replica", rc.Host)
+	assert.Equal(t, 5432, rc.Port)
+	assert.Equal(t, "app_ro", rc.User)
+	assert.Equal(t, "ro", rc.Password)
+	assert.Contains(t, readOnlyDSN(DriverPostgreSQL, buildDSN(rc)), "default_transaction_read_only=on")
+
+	rc.Driver = DriverMySQL
+	assert.Contains(t, readOnlyDSN(DriverMySQL, buildDSN(rc)), "&transaction_read_only=1")
+	assert.Equal(t, "u@tcp(h)/db?transaction_read_only=1", readOnlyDSN(DriverMySQL, "u@tcp(h)/db"))
+}
+
+// TestReplica_Integration 需要真实数据库：TEST_POSTGRES_DSN（key=value 格式）或 TEST_MYSQL_DSN
+func TestReplica_Integration(t *testing.T) {
+	dsns := map[string]string{DriverPostgreSQL: os.Getenv("TEST_POSTGRES_DSN"), DriverMySQL: os.Getenv("TEST_MYSQL_DSN")}
+	ran := false
+	for driverName, dsn := range dsns {
+		if dsn == "" {
+			continue
+		}
+		ran = true
+		t.Run(driverName, func(t *testing.T) {
+			ctx := context.Background()
+			primary, err := sql.Open(driverName, dsn)
+			require.NoError(t, err)
+			t.Cleanup(func() { primary.Close() })
+			_, err = primary.ExecContext(ctx, "CREATE TABLE replica_guard_probe (id INT)")
+			require.NoError(t, err)
+			t.Cleanup(func() { primary.ExecContext(ctx, "DROP TABLE replica_guard_probe") })
+
+			replica, err := sql.Open(driverName, readOnlyDSN(driverName, dsn))
+			require.NoError(t, err)
+			t.Cleanup(func() { replica.Close() })
+			ReplicaDB = replica
+			t.Cleanup(func() { ReplicaDB = nil })
+
+			var n int
+			require.NoError(t, Replica().QueryRowContext(ctx, "SELECT count(*) FROM replica_guard_probe").Scan(&n))
+			_, err = Replica().ExecContext(ctx, "INSERT INTO replica_guard_probe (id) VALUES (1)")
+			assert.ErrorIs(t, err, ErrReplicaWrite)
+
+			// 绕过应用层检查时，会话级只读同样拒绝写入
+			_, err = replica.ExecContext(ctx, "INSERT INTO replica_guard_probe (id) VALUES (1)")
+			assert.Error(t, err, "只读会话拒绝写入")
+
+			grants, err := WriteGrants(ctx, primary, driverName)
+			require.NoError(t, err)
+			t.Logf("测试账号的写权限: %v", grants)
+		})
+	}
+	if !ran {
+		t.Skip("未设置 TEST_POSTGRES_DSN / TEST_MYSQL_DSN，跳过数据库集成测试")
+	}
+}
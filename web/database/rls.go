@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// 行级安全（RLS）身份来源（DatabaseConfig.RLSVariables 的值）
+const (
+	RLSSourceUser   = "user"   // JWT 用户（jwt.GetUserID）
+	RLSSourceTenant = "tenant" // 租户（JWT 的 tenant 声明）
+	RLSClaimPrefix  = "claim:" // 其他 JWT 声明，如 "claim:org"
+)
+
+// rlsNamePattern 会话变量名（名称直接拼入 SQL，只允许标识符）
+var rlsNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// rlsVar 一个会话变量与它的身份来源
+type rlsVar struct {
+	name   string
+	source string
+}
+
+// rlsSession 连接池的 RLS 配置
+type rlsSession struct {
+	driver   string
+	vars     []rlsVar
+	sentinel string
+}
+
+// rlsPools *sql.DB -> *rlsSession（Open / EnableRLS 注册，Close 移除）
+var rlsPools sync.Map
+
+// EnableRLS 为连接池开启行级安全会话变量（Open 按 cfg.RLSVariables 自动调用，自行创建的连接池手动调用）
+//
+// 开启后 DBMiddleware / TxMiddleware / WithTx 在 BEGIN 之后立即按身份设置会话变量：
+//   - PostgreSQL：select set_config(name, value, true)，等同于 SET LOCAL，值作为参数传递（不拼接 SQL），
+//     只在当前事务内有效，提交或回滚后自动失效，不会残留在连接池的连接上
+//   - MySQL：SET @`name` = ?（用户变量没有事务级作用域），提交或回滚之前重置为 sentinel，
+//     之后复用该连接的请求看不到上一个请求的值；MySQL 没有 RLS 策略，变量供视图、存储过程或查询条件使用
+//
+// 身份缺失（未登录、token 中没有 tenant 声明）时设置为 cfg.RLSSentinel，不会沿用旧值。
+// 会话变量只在事务中设置：不经过事务直接使用连接池（Conn(ctx) 未绑定事务、Replica()）的查询看不到变量，
+// PostgreSQL 中 current_setting(name, true) 为 NULL，策略按拒绝处理；需要 RLS 的查询应放在事务中执行。
+// cfg.RLSVariables 为空时关闭
+//
+// 使用方式：
+//
+//	db, _ := sql.Open("postgres", dsn)
+//	err := database.EnableRLS(db, database.DatabaseConfig{
+//	    Driver:       database.DriverPostgreSQL,
+//	    RLSVariables: map[string]string{"app.user_id": "user", "app.tenant_id": "tenant"},
+//	    RLSSentinel:  "-1",
+//	})
+func EnableRLS(db *sql.DB, cfg DatabaseConfig) error {
+	if len(cfg.RLSVariables) == 0 {
+		rlsPools.Delete(db)
+		return nil
+	}
+	s := &rlsSession{driver: cfg.Driver, sentinel: cfg.RLSSentinel}
+	for name, source := range cfg.RLSVariables {
+		if !rlsNamePattern.MatchString(name) {
+			return fmt.Errorf("rls: 非法的会话变量名 %q", name)
+		}
+		if cfg.Driver == DriverPostgreSQL && !strings.Contains(name, ".") {
+			return fmt.Errorf("rls: PostgreSQL 自定义会话变量需要带前缀（如 app.%s）", name)
+		}
+		if source != RLSSourceUser && source != RLSSourceTenant &&
+			(!strings.HasPrefix(source, RLSClaimPrefix) || source == RLSClaimPrefix) {
+			return fmt.Errorf("rls: %s 的身份来源 %q 无效（user / tenant / claim:<name>）", name, source)
+		}
+		s.vars = append(s.vars, rlsVar{name: name, source: source})
+	}
+	slices.SortFunc(s.vars, func(a, b rlsVar) int { return strings.Compare(a.name, b.name) })
+	rlsPools.Store(db, s)
+	return nil
+}
+
+// rlsFor 连接池的 RLS 配置（未开启时为 nil）
+func rlsFor(db *sql.DB) *rlsSession {
+	if s, ok := rlsPools.Load(db); ok {
+		return s.(*rlsSession)
+	}
+	return nil
+}
+
+type rlsIdentityKey struct{}
+
+// WithRLSIdentity 把 RLS 身份绑定到 ctx（键为身份来源：user / tenant / claim:<name>）
+//
+// DBMiddleware 从 JWT 取身份后自动绑定，请求中嵌套的 WithTx 沿用；
+// 没有请求的场景（后台任务、消息消费者）用它指定以谁的身份访问数据
+//
+// 使用方式：
+//
+//	ctx = database.WithRLSIdentity(ctx, map[string]string{"user": job.UserID, "tenant": job.TenantID})
+//	err := database.WithTx(ctx, func(ctx context.Context) error { ... })
+func WithRLSIdentity(ctx context.Context, identity map[string]string) context.Context {
+	return context.WithValue(ctx, rlsIdentityKey{}, identity)
+}
+
+// rlsIdentityFrom ctx 绑定的 RLS 身份
+func rlsIdentityFrom(ctx context.Context) (map[string]string, bool) {
+	identity, ok := ctx.Value(rlsIdentityKey{}).(map[string]string)
+	return identity, ok
+}
+
+// requestIdentity 从请求的 JWT 中取各变量的身份（ctx 已绑定身份时优先）
+func (s *rlsSession) requestIdentity(ctx context.Context, c *app.RequestContext) map[string]string {
+	if identity, ok := rlsIdentityFrom(ctx); ok {
+		return identity
+	}
+	identity := make(map[string]string, len(s.vars))
+	var claims map[string]any
+	for _, v := range s.vars {
+		switch {
+		case v.source == RLSSourceUser:
+			identity[v.source] = jwt.GetUserID(c)
+		default:
+			if claims == nil {
+				claims = jwt.GetClaims(c)
+			}
+			name := strings.TrimPrefix(v.source, RLSClaimPrefix)
+			if claim, ok := claims[name]; ok && claim != nil {
+				identity[v.source] = fmt.Sprint(claim)
+			}
+		}
+	}
+	return identity
+}
+
+// apply 在事务开始后设置会话变量（身份缺失时为 sentinel）
+func (s *rlsSession) apply(ctx context.Context, tx *sql.Tx, identity map[string]string) error {
+	values := make([]string, len(s.vars))
+	for i, v := range s.vars {
+		values[i] = identity[v.source]
+		if values[i] == "" {
+			values[i] = s.sentinel
+		}
+	}
+	return s.set(ctx, tx, values)
+}
+
+// reset 提交或回滚之前把 MySQL 用户变量重置为 sentinel（PostgreSQL 的 set_config(..., true) 随事务结束失效）
+func (s *rlsSession) reset(ctx context.Context, tx *sql.Tx) error {
+	if s.driver == DriverPostgreSQL {
+		return nil
+	}
+	values := make([]string, len(s.vars))
+	for i := range values {
+		values[i] = s.sentinel
+	}
+	return s.set(ctx, tx, values)
+}
+
+func (s *rlsSession) set(ctx context.Context, tx *sql.Tx, values []string) error {
+	var query strings.Builder
+	args := make([]any, 0, 2*len(s.vars))
+	if s.driver == DriverPostgreSQL {
+		query.WriteString("SELECT ")
+		for i, v := range s.vars {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "set_config($%d, $%d, true)", 2*i+1, 2*i+2)
+			args = append(args, v.name, values[i])
+		}
+	} else {
+		query.WriteString("SET ")
+		for i, v := range s.vars {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "@`%s` = ?", v.name)
+			args = append(args, values[i])
+		}
+	}
+	if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+		return fmt.Errorf("rls: 设置会话变量失败: %w", err)
+	}
+	return nil
+}
+
+// beginRLS 开启事务后设置会话变量（连接池未开启 RLS 时只开启事务）
+//
+// 返回的 end 在提交或回滚之前调用（MySQL 重置用户变量）
+func beginRLS(ctx context.Context, tx *sql.Tx, db *sql.DB, identity func(*rlsSession) map[string]string) (end func(), err error) {
+	s := rlsFor(db)
+	if s == nil {
+		return func() {}, nil
+	}
+	if err := s.apply(ctx, tx, identity(s)); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := s.reset(ctx, tx); err != nil {
+			log.Warnf("[DB] %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rlsConfig = DatabaseConfig{
+	RLSVariables: map[string]string{"app.user_id": "user", "app.tenant_id": "tenant"},
+	RLSSentinel:  "-1",
+}
+
+// newRLSEngine JWT 认证之后开启事务的引擎：/notes 需要登录，/public 不经过认证
+func newRLSEngine(t *testing.T, db *sql.DB, handler app.HandlerFunc) (*route.Engine, func(claims map[string]any) ut.Header) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "rls-secret"
+	auth, err := jwt.New(conf)
+	require.NoError(t, err)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/notes", auth.Middleware(), TxMiddleware(db, ""), handler)
+	engine.GET("/public", TxMiddleware(db, ""), handler)
+	return engine, func(claims map[string]any) ut.Header {
+		token, _, err := auth.GenerateToken(claims)
+		require.NoError(t, err)
+		return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+	}
+}
+
+func listNotes(ctx context.Context, c *app.RequestContext) {
+	rows, err := Conn(ctx).QueryContext(ctx, "SELECT body FROM notes")
+	if err == nil {
+		rows.Close()
+	}
+	c.String(200, "ok")
+}
+
+func TestRLS_PostgresSetLocal(t *testing.T) {
+	f, db := newFakeDB(t)
+	conf := rlsConfig
+	conf.Driver = DriverPostgreSQL
+	require.NoError(t, EnableRLS(db, conf))
+	engine, bearer := newRLSEngine(t, db, listNotes)
+
+	w := ut.PerformRequest(engine, "GET", "/notes", nil, bearer(map[string]any{"identity": "alice", "tenant": "t1"}))
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SELECT set_config($1, $2, true), set_config($3, $4, true)",
+		"SELECT body FROM notes",
+		"COMMIT",
+	}, f.statements(), "BEGIN 之后立即设置，随事务结束失效，无需重置")
+	assert.Equal(t, []any{"app.tenant_id", "t1", "app.user_id", "alice"}, f.execs[1].args, "值作为参数传递")
+
+	// 值中的引号不会拼入 SQL
+	f.execs = nil
+	w = ut.PerformRequest(engine, "GET", "/notes", nil, bearer(map[string]any{"identity": "bob'; DROP TABLE notes; --"}))
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "SELECT set_config($1, $2, true), set_config($3, $4, true)", f.execs[1].query)
+	assert.Equal(t, []any{"app.tenant_id", "-1", "app.user_id", "bob'; DROP TABLE notes; --"}, f.execs[1].args, "缺少 tenant 声明时为 sentinel")
+
+	// 未登录的请求不沿用上一个请求的身份
+	f.execs = nil
+	w = ut.PerformRequest(engine, "GET", "/public", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, []any{"app.tenant_id", "-1", "app.user_id", "-1"}, f.execs[1].args)
+}
+
+func TestRLS_SetupFailureAbortsRequest(t *testing.T) {
+	f, db := newFakeDB(t)
+	f.onExec = func(_ int, query string, _ []any) (driver.Result, error) {
+		if strings.Contains(query, "set_config") {
+			return nil, errors.New("permission denied")
+		}
+		return driver.RowsAffected(1), nil
+	}
+	conf := rlsConfig
+	conf.Driver = DriverPostgreSQL
+	require.NoError(t, EnableRLS(db, conf))
+	ran := false
+	engine, bearer := newRLSEngine(t, db, func(ctx context.Context, c *app.RequestContext) {
+		ran = true
+		listNotes(ctx, c)
+	})
+
+	w := ut.PerformRequest(engine, "GET", "/notes", nil, bearer(map[string]any{"identity": "alice"}))
+	assert.Equal(t, 500, w.Code)
+	assert.False(t, ran, "设置会话变量失败时不执行处理器")
+	assert.Equal(t, []string{"BEGIN", "SELECT set_config($1, $2, true), set_config($3, $4, true)", "ROLLBACK"}, f.statements())
+
+	// 开启事务失败时同样不执行（处理器不能退回到没有会话变量的连接池）
+	f.execs, f.beginErr = nil, errors.New("too many connections")
+	w = ut.PerformRequest(engine, "GET", "/notes", nil, bearer(map[string]any{"identity": "alice"}))
+	assert.Equal(t, 500, w.Code)
+	assert.False(t, ran)
+	assert.Empty(t, f.statements())
+}
+
+func TestRLS_MySQLResetsBeforeRelease(t *testing.T) {
+	f, db := newFakeDB(t)
+	conf := rlsConfig
+	conf.Driver = DriverMySQL
+	require.NoError(t, EnableRLS(db, conf))
+	engine, bearer := newRLSEngine(t, db, func(ctx context.Context, c *app.RequestContext) {
+		if c.Query("fail") != "" {
+			c.Set("tx_error", errors.New("boom"))
+		}
+		listNotes(ctx, c)
+	})
+
+	for _, path := range []string{"/notes", "/notes?fail=1"} {
+		f.execs = nil
+		w := ut.PerformRequest(engine, "GET", path, nil, bearer(map[string]any{"identity": "alice", "tenant": "t1"}))
+		require.Equal(t, 200, w.Code)
+		end := "COMMIT"
+		if path != "/notes" {
+			end = "ROLLBACK"
+		}
+		assert.Equal(t, []string{
+			"BEGIN",
+			"SET @`app.tenant_id` = ?, @`app.user_id` = ?",
+			"SELECT body FROM notes",
+			"SET @`app.tenant_id` = ?, @`app.user_id` = ?",
+			end,
+		}, f.statements(), path)
+		assert.Equal(t, []any{"t1", "alice"}, f.execs[1].args)
+		assert.Equal(t, []any{"-1", "-1"}, f.execs[3].args, "连接归还连接池之前重置用户变量")
+	}
+}
+
+func TestRLS_WithTxUsesBoundIdentity(t *testing.T) {
+	old := DB
+	t.Cleanup(func() { DB = old })
+	f, db := newFakeDB(t)
+	DB = db
+	conf := rlsConfig
+	conf.Driver = DriverPostgreSQL
+	require.NoError(t, EnableRLS(db, conf))
+
+	ctx := WithRLSIdentity(context.Background(), map[string]string{"user": "job-owner"})
+	require.NoError(t, WithTx(ctx, func(ctx context.Context) error {
+		_, err := Conn(ctx).ExecContext(ctx, "UPDATE notes SET body = ''")
+		return err
+	}))
+	assert.Equal(t, []any{"app.tenant_id", "-1", "app.user_id", "job-owner"}, f.execs[1].args)
+
+	// 请求中嵌套的 WithTx 沿用 DBMiddleware 取到的身份
+	f.execs = nil
+	engine, bearer := newRLSEngine(t, db, func(ctx context.Context, c *app.RequestContext) {
+		assert.NoError(t, WithTx(ctx, func(context.Context) error { return nil }))
+		c.String(200, "ok")
+	})
+	w := ut.PerformRequest(engine, "GET", "/notes", nil, bearer(map[string]any{"identity": "alice", "tenant": "t1"}))
+	require.Equal(t, 200, w.Code)
+	var nested []any
+	for _, e := range f.execs {
+		if e.query != "BEGIN" && e.query != "COMMIT" {
+			nested = e.args
+		}
+	}
+	assert.Equal(t, []any{"app.tenant_id", "t1", "app.user_id", "alice"}, nested)
+
+	require.NoError(t, EnableRLS(db, DatabaseConfig{Driver: DriverPostgreSQL}))
+	f.execs = nil
+	require.NoError(t, WithTx(ctx, func(context.Context) error { return nil }))
+	assert.Equal(t, []string{"BEGIN", "COMMIT"}, f.statements(), "未开启时不设置")
+}
+
+func TestEnableRLS_InvalidConfig(t *testing.T) {
+	_, db := newFakeDB(t)
+	for name, vars := range map[string]map[string]string{
+		"注入":   {"app.user_id'; --": "user"},
+		"缺少前缀": {"user_id": "user"},
+		"未知来源": {"app.user_id": "session"},
+		"空声明名": {"app.org": "claim:"},
+	} {
+		err := EnableRLS(db, DatabaseConfig{Driver: DriverPostgreSQL, RLSVariables: vars})
+		assert.Error(t, err, name)
+	}
+	assert.NoError(t, EnableRLS(db, DatabaseConfig{Driver: DriverMySQL, RLSVariables: map[string]string{"user_id": "claim:sub"}}))
+	assert.Nil(t, rlsFor(sql.OpenDB(&fakeDB{})))
+}
+
+// TestRLS_PostgresIntegration 需要真实的 PostgreSQL：TEST_POSTGRES_DSN（key=value 格式），
+// 账号不能是超级用户或带 BYPASSRLS（否则策略不生效）
+func TestRLS_PostgresIntegration(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("未设置 TEST_POSTGRES_DSN，跳过数据库集成测试")
+	}
+	ctx := context.Background()
+	db, err := sql.Open(DriverPostgreSQL, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1) // 所有请求复用同一个连接，验证变量不会残留
+
+	var bypass bool
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&bypass))
+	if bypass {
+		t.Skip("测试账号绕过 RLS（超级用户或 BYPASSRLS），跳过")
+	}
+
+	for _, stmt := range []string{
+		"CREATE TABLE rls_notes (owner TEXT NOT NULL, body TEXT NOT NULL)",
+		"INSERT INTO rls_notes (owner, body) VALUES ('alice', 'alice-note'), ('bob', 'bob-note')",
+		"ALTER TABLE rls_notes ENABLE ROW LEVEL SECURITY",
+		"ALTER TABLE rls_notes FORCE ROW LEVEL SECURITY",
+		"CREATE POLICY rls_notes_owner ON rls_notes USING (owner = current_setting('app.user_id', true))",
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err, stmt)
+	}
+	t.Cleanup(func() { db.ExecContext(ctx, "DROP TABLE rls_notes") })
+	require.NoError(t, EnableRLS(db, DatabaseConfig{Driver: DriverPostgreSQL, RLSVariables: map[string]string{"app.user_id": "user"}}))
+
+	engine, bearer := newRLSEngine(t, db, func(ctx context.Context, c *app.RequestContext) {
+		var bodies []string
+		rows, err := Conn(ctx).QueryContext(ctx, "SELECT body FROM rls_notes WHERE owner = $1 OR $1 = '*' ORDER BY body", c.Query("owner"))
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var body string
+			require.NoError(t, rows.Scan(&body))
+			bodies = append(bodies, body)
+		}
+		c.JSON(200, bodies)
+	})
+	alice := bearer(map[string]any{"identity": "alice"})
+
+	w := ut.PerformRequest(engine, "GET", "/notes?owner=*", nil, alice)
+	assert.JSONEq(t, `["alice-note"]`, w.Body.String(), "只能看到自己的数据")
+	w = ut.PerformRequest(engine, "GET", "/notes?owner=bob", nil, alice)
+	assert.JSONEq(t, `null`, w.Body.String(), "读不到 bob 的数据")
+	w = ut.PerformRequest(engine, "GET", "/notes?owner=*", nil, bearer(map[string]any{"identity": "bob"}))
+	assert.JSONEq(t, `["bob-note"]`, w.Body.String())
+
+	// 同一个连接上：未登录的请求与事务外的查询看不到之前设置的身份
+	w = ut.PerformRequest(engine, "GET", "/public?owner=*", nil)
+	assert.JSONEq(t, `null`, w.Body.String(), "身份缺失时不沿用上一个请求的值")
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM rls_notes").Scan(&n))
+	assert.Zero(t, n, "SET LOCAL 随事务结束失效，不残留在连接池的连接上")
+}
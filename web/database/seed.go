@@ -0,0 +1,308 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ErrSeedInProduction 生产环境拒绝写入演示数据（确认需要时使用 SeedForce）
+var ErrSeedInProduction = errors.New("seed: 生产环境禁止写入演示数据，确认需要时使用 --force")
+
+// SeedHistoryMigrationMySQL 已执行的种子数据记录表（MySQL，Seed 自动创建）
+const SeedHistoryMigrationMySQL = `CREATE TABLE IF NOT EXISTS seed_history (
+    name VARCHAR(191) NOT NULL PRIMARY KEY,
+    applied_at DATETIME(3) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// SeedHistoryMigrationPostgres 已执行的种子数据记录表（PostgreSQL，Seed 自动创建）
+const SeedHistoryMigrationPostgres = `CREATE TABLE IF NOT EXISTS seed_history (
+    name VARCHAR(191) NOT NULL PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL
+)`
+
+// SeedFunc 用 Go 编写的种子数据（需要计算的数据：密码哈希、上传文件、按语言生成的内容等）
+//
+// db 为本条种子数据所在的事务，写入通过 db 进行
+type SeedFunc func(ctx context.Context, db DBTX) error
+
+var (
+	seedersMu sync.Mutex
+	seeders   = map[string]SeedFunc{}
+)
+
+// RegisterSeeder 注册 Go 种子数据（同名时替换）
+//
+// 与 Seed 目录中的 .sql 文件一起按名称排序执行，名称用数字前缀控制顺序（如 "010_users"）；
+// --only 可以只写不带前缀的名称（"users"）
+//
+// 使用方式：
+//
+//	func init() {
+//	    database.RegisterSeeder("010_users", seedUsers)
+//	}
+func RegisterSeeder(name string, fn SeedFunc) {
+	seedersMu.Lock()
+	defer seedersMu.Unlock()
+	seeders[name] = fn
+}
+
+// seedConfig Seed 的可选配置
+type seedConfig struct {
+	driver     string
+	only       []string
+	force      bool
+	production bool
+}
+
+// SeedOption Seed 的可选配置项
+type SeedOption func(*seedConfig)
+
+// SeedOnly 只执行指定的种子数据（名称可以省略数字前缀）
+func SeedOnly(names ...string) SeedOption {
+	return func(c *seedConfig) { c.only = append(c.only, names...) }
+}
+
+// SeedForce 在生产环境中也执行（默认拒绝）
+func SeedForce() SeedOption {
+	return func(c *seedConfig) { c.force = true }
+}
+
+// SeedProduction 指定是否为生产环境（默认按环境变量 APP_ENV 判断；web.RunSeedCommand 按 [web] environment 判断）
+func SeedProduction(production bool) SeedOption {
+	return func(c *seedConfig) { c.production = production }
+}
+
+// seedDriver 指定数据库类型（db 为事务时无法从连接池推断）
+func seedDriver(driver string) SeedOption {
+	return func(c *seedConfig) { c.driver = driver }
+}
+
+// SeedReport Seed 的执行结果
+type SeedReport struct {
+	Applied []string // 本次执行的种子数据
+	Skipped []string // 之前已经执行过的种子数据
+}
+
+// seedEntry 一条种子数据（SQL 文件或 Go 函数）
+type seedEntry struct {
+	name string
+	run  SeedFunc
+}
+
+// Seed 写入演示数据：dir 下的 .sql 文件与 RegisterSeeder 注册的函数按名称顺序执行
+//
+// 每条种子数据执行一次，执行记录保存在 seed_history 表中（与迁移记录分开），重复执行时跳过已执行的；
+// 每条种子数据与它的执行记录在同一个事务中提交，失败时回滚并停止，之前成功的保留。
+// db 为事务时（如 SeedTx 的测试事务）直接在该事务中执行。
+// 生产环境返回 ErrSeedInProduction，除非使用 SeedForce。
+// fsys 为 nil 时只执行 Go 种子数据；.sql 文件中的语句以 ";\n" 分隔
+//
+// 使用方式：
+//
+//	//go:embed db/seeds
+//	var seeds embed.FS
+//
+//	report, err := database.Seed(ctx, database.DB, seeds, "db/seeds", database.SeedOnly("users"))
+func Seed(ctx context.Context, db DBTX, fsys fs.FS, dir string, opts ...SeedOption) (SeedReport, error) {
+	cfg := seedConfig{driver: detectDriver(db), production: strings.EqualFold(os.Getenv("APP_ENV"), "production")}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var report SeedReport
+	if cfg.production && !cfg.force {
+		return report, ErrSeedInProduction
+	}
+
+	entries, err := seedEntries(fsys, dir)
+	if err != nil {
+		return report, err
+	}
+	if entries, err = filterSeeds(entries, cfg.only); err != nil {
+		return report, err
+	}
+
+	migration := SeedHistoryMigrationMySQL
+	if cfg.driver == DriverPostgreSQL {
+		migration = SeedHistoryMigrationPostgres
+	}
+	if _, err := db.ExecContext(ctx, migration); err != nil {
+		return report, fmt.Errorf("seed: 创建 seed_history 表失败: %w", err)
+	}
+	applied, err := appliedSeeds(ctx, db)
+	if err != nil {
+		return report, err
+	}
+
+	insert := "INSERT INTO seed_history (name, applied_at) VALUES (?, ?)"
+	if cfg.driver == DriverPostgreSQL {
+		insert = "INSERT INTO seed_history (name, applied_at) VALUES ($1, $2)"
+	}
+	for _, e := range entries {
+		if applied[e.name] {
+			report.Skipped = append(report.Skipped, e.name)
+			continue
+		}
+		err := seedTx(ctx, db, func(tx DBTX) error {
+			if err := e.run(ctx, tx); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, insert, e.name, time.Now())
+			return err
+		})
+		if err != nil {
+			return report, fmt.Errorf("seed: %s 执行失败: %w", e.name, err)
+		}
+		log.Infof("[Seed] 已写入 %s", e.name)
+		report.Applied = append(report.Applied, e.name)
+	}
+	return report, nil
+}
+
+// SeedTx 在事务中写入种子数据，测试结束时回滚（测试中使用，与 LoadFixturesTx 相同）
+//
+// 不检查生产环境，每次都完整执行（事务回滚后 seed_history 中没有记录）；
+// 返回的 context 绑定了该事务，被测代码通过 Conn(ctx) 取连接即可看到演示数据
+//
+// 使用方式：
+//
+//	func TestAnnouncements(t *testing.T) {
+//	    ctx := database.SeedTx(t, database.DB, seeds, "db/seeds", database.SeedOnly("users"))
+//	    list, err := service.ListAnnouncements(ctx, "zh-CN")
+//	    ...
+//	}
+func SeedTx(t testing.TB, db *sql.DB, fsys fs.FS, dir string, opts ...SeedOption) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("seed: 开启事务失败: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	opts = append([]SeedOption{seedDriver(detectDriver(db)), SeedProduction(false)}, opts...)
+	if _, err := Seed(ctx, tx, fsys, dir, opts...); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return WithDBTX(ctx, tx)
+}
+
+// seedEntries dir 下的 .sql 文件与注册的 Go 种子数据，按名称排序（同名时报错）
+func seedEntries(fsys fs.FS, dir string) ([]seedEntry, error) {
+	seedersMu.Lock()
+	entries := make([]seedEntry, 0, len(seeders))
+	for name, fn := range seeders {
+		entries = append(entries, seedEntry{name: name, run: fn})
+	}
+	seedersMu.Unlock()
+
+	if fsys != nil {
+		files, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("seed: 读取目录 %s 失败: %w", dir, err)
+		}
+		for _, f := range files {
+			if f.IsDir() || path.Ext(f.Name()) != ".sql" {
+				continue
+			}
+			name := strings.TrimSuffix(f.Name(), ".sql")
+			if slices.ContainsFunc(entries, func(e seedEntry) bool { return e.name == name }) {
+				return nil, fmt.Errorf("seed: %s 同时存在 SQL 文件与 Go 种子数据", name)
+			}
+			data, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("seed: 读取 %s 失败: %w", f.Name(), err)
+			}
+			entries = append(entries, seedEntry{name: name, run: sqlSeed(string(data))})
+		}
+	}
+	slices.SortFunc(entries, func(a, b seedEntry) int { return strings.Compare(a.name, b.name) })
+	return entries, nil
+}
+
+// sqlSeed 执行 SQL 文件中的语句
+func sqlSeed(script string) SeedFunc {
+	return func(ctx context.Context, db DBTX) error {
+		for _, stmt := range strings.Split(script, ";\n") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// filterSeeds 按 SeedOnly 过滤（名称可以省略数字前缀），未知的名称报错
+func filterSeeds(entries []seedEntry, only []string) ([]seedEntry, error) {
+	if len(only) == 0 {
+		return entries, nil
+	}
+	var out []seedEntry
+	for _, name := range only {
+		i := slices.IndexFunc(entries, func(e seedEntry) bool { return e.name == name || seedBaseName(e.name) == name })
+		if i < 0 {
+			return nil, fmt.Errorf("seed: 未知的种子数据 %q", name)
+		}
+		if !slices.ContainsFunc(out, func(e seedEntry) bool { return e.name == entries[i].name }) {
+			out = append(out, entries[i])
+		}
+	}
+	slices.SortFunc(out, func(a, b seedEntry) int { return strings.Compare(a.name, b.name) })
+	return out, nil
+}
+
+// seedBaseName 去掉数字前缀的名称（"010_users" -> "users"）
+func seedBaseName(name string) string {
+	trimmed := strings.TrimLeft(name, "0123456789")
+	if trimmed != name && (strings.HasPrefix(trimmed, "_") || strings.HasPrefix(trimmed, "-")) {
+		return trimmed[1:]
+	}
+	return name
+}
+
+// appliedSeeds 已执行的种子数据
+func appliedSeeds(ctx context.Context, db DBTX) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM seed_history")
+	if err != nil {
+		return nil, fmt.Errorf("seed: 查询 seed_history 失败: %w", err)
+	}
+	defer rows.Close()
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+	return applied, rows.Err()
+}
+
+// seedTx db 为连接池时开启事务执行 fn，已经是事务时直接执行
+func seedTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	pool, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSeedDB fakeDB 上模拟 seed_history：含 "boom" 的语句执行失败，查询返回已提交（或当前事务中）的执行记录
+func newSeedDB(t *testing.T) (*fakeDB, *sql.DB) {
+	f, db := newFakeDB(t)
+	f.onExec = func(conn int, query string, args []any) (driver.Result, error) {
+		if strings.Contains(query, "boom") {
+			return nil, errors.New("syntax error")
+		}
+		return driver.RowsAffected(1), nil
+	}
+	f.onQuery = func(conn int, query string, args []any) ([][]driver.Value, error) {
+		if query != "SELECT name FROM seed_history" {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		var rows [][]driver.Value
+		for _, name := range seedHistory(f, conn) {
+			rows = append(rows, []driver.Value{name})
+		}
+		return rows, nil
+	}
+	return f, db
+}
+
+// seedHistory 已提交的执行记录（conn 不为 0 时包括该连接当前事务中的）
+func seedHistory(f *fakeDB, conn int) []string {
+	var names []string
+	for _, e := range f.visible(conn) {
+		if strings.HasPrefix(e.query, "INSERT INTO seed_history") {
+			names = append(names, e.args[0].(string))
+		}
+	}
+	return names
+}
+
+// seedInserts 已提交的业务写入（不含建表与执行记录）
+func seedInserts(f *fakeDB) []string {
+	var out []string
+	for _, e := range f.visible(0) {
+		if !strings.Contains(e.query, "seed_history") {
+			out = append(out, strings.TrimSpace(e.query))
+		}
+	}
+	return out
+}
+
+var demoSeeds = fstest.MapFS{
+	"seeds/020_products.sql": {Data: []byte("INSERT INTO products (name) VALUES ('键盘');\nINSERT INTO products (name) VALUES ('鼠标');\n")},
+	"seeds/040_orders.sql":   {Data: []byte("INSERT INTO orders (product_id) VALUES (1)")},
+	"seeds/readme.txt":       {Data: []byte("不是 SQL")},
+}
+
+func registerTestSeeder(t *testing.T, name string, fn SeedFunc) {
+	RegisterSeeder(name, fn)
+	t.Cleanup(func() {
+		seedersMu.Lock()
+		delete(seeders, name)
+		seedersMu.Unlock()
+	})
+}
+
+func TestSeed_OrderAndIdempotency(t *testing.T) {
+	ctx := context.Background()
+	store, db := newSeedDB(t)
+	registerTestSeeder(t, "010_users", func(ctx context.Context, db DBTX) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES ('demo')")
+		return err
+	})
+	registerTestSeeder(t, "030_uploads", func(ctx context.Context, db DBTX) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO uploads (key) VALUES ('demo.png')")
+		return err
+	})
+
+	report, err := Seed(ctx, db, demoSeeds, "seeds", SeedProduction(false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"010_users", "020_products", "030_uploads", "040_orders"}, report.Applied, "SQL 与 Go 种子数据按名称交错执行")
+	assert.Equal(t, []string{
+		"INSERT INTO users (name) VALUES ('demo')",
+		"INSERT INTO products (name) VALUES ('键盘')",
+		"INSERT INTO products (name) VALUES ('鼠标')",
+		"INSERT INTO uploads (key) VALUES ('demo.png')",
+		"INSERT INTO orders (product_id) VALUES (1)",
+	}, seedInserts(store))
+
+	report, err = Seed(ctx, db, demoSeeds, "seeds", SeedProduction(false))
+	require.NoError(t, err)
+	assert.Empty(t, report.Applied, "重复执行时跳过")
+	assert.Len(t, report.Skipped, 4)
+	assert.Len(t, seedInserts(store), 5)
+}
+
+func TestSeed_OnlyAndFailure(t *testing.T) {
+	ctx := context.Background()
+	store, db := newSeedDB(t)
+	registerTestSeeder(t, "010_users", func(ctx context.Context, db DBTX) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES ('demo')")
+		return err
+	})
+
+	report, err := Seed(ctx, db, demoSeeds, "seeds", SeedProduction(false), SeedOnly("orders", "010_users"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"010_users", "040_orders"}, report.Applied, "--only 可以省略数字前缀，按名称顺序执行")
+
+	_, err = Seed(ctx, db, demoSeeds, "seeds", SeedProduction(false), SeedOnly("payments"))
+	assert.ErrorContains(t, err, "payments")
+
+	failing := fstest.MapFS{"seeds/050_broken.sql": {Data: []byte("INSERT INTO a VALUES (1);\nboom")}}
+	report, err = Seed(ctx, db, failing, "seeds", SeedProduction(false))
+	assert.ErrorContains(t, err, "050_broken")
+	assert.Empty(t, report.Applied)
+	assert.NotContains(t, seedInserts(store), "INSERT INTO a VALUES (1)", "失败的种子数据整体回滚")
+	assert.NotContains(t, seedHistory(store, 0), "050_broken")
+
+	report, err = Seed(ctx, db, demoSeeds, "seeds", SeedProduction(false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"020_products"}, report.Applied, "之后的完整执行只补上缺少的")
+}
+
+func TestSeed_ProductionGuard(t *testing.T) {
+	ctx := context.Background()
+	store, db := newSeedDB(t)
+
+	_, err := Seed(ctx, db, demoSeeds, "seeds", SeedProduction(true))
+	assert.ErrorIs(t, err, ErrSeedInProduction)
+	t.Setenv("APP_ENV", "production")
+	_, err = Seed(ctx, db, demoSeeds, "seeds")
+	assert.ErrorIs(t, err, ErrSeedInProduction)
+	assert.Empty(t, store.statements(), "拒绝时不访问数据库")
+
+	report, err := Seed(ctx, db, demoSeeds, "seeds", SeedForce())
+	require.NoError(t, err)
+	assert.Len(t, report.Applied, 2)
+}
+
+func TestSeed_InCallerTransaction(t *testing.T) {
+	ctx := context.Background()
+	store, db := newSeedDB(t)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	report, err := Seed(ctx, tx, demoSeeds, "seeds", SeedProduction(false))
+	require.NoError(t, err)
+	assert.Len(t, report.Applied, 2)
+	require.NoError(t, tx.Rollback())
+	assert.Empty(t, seedInserts(store), "在调用方的事务中执行，随事务回滚")
+}
+
+func TestSeedTx(t *testing.T) {
+	store, db := newSeedDB(t)
+	t.Run("seeded", func(t *testing.T) {
+		t.Setenv("APP_ENV", "production")
+		ctx := SeedTx(t, db, demoSeeds, "seeds", SeedOnly("products"))
+		_, ok := Conn(ctx).(*sql.Tx)
+		assert.True(t, ok, "context 绑定测试事务")
+		assert.Empty(t, seedInserts(store), "事务尚未提交")
+	})
+	assert.Empty(t, seedInserts(store), "测试结束时回滚")
+	assert.Empty(t, seedHistory(store, 0))
+}
@@ -5,8 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 
-	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/middleware"
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
 )
 
 // Transaction 事务辅助函数
@@ -33,46 +34,126 @@ func Transaction(ctx context.Context, c *app.RequestContext, fn func(*sql.Tx) (a
 	return result, nil
 }
 
+// AbortPolicy 客户端中途断开（见 middleware.ClientAbortMiddleware）时 DBMiddleware 的事务处理策略
+type AbortPolicy string
+
+const (
+	// AbortCommitIfFinished 处理器正常结束（没有 panic、没有 tx_error）时照常提交：工作已经完成，只是响应送不到（默认）
+	AbortCommitIfFinished AbortPolicy = "commit"
+	// AbortRollback 客户端断开时一律回滚（适合客户端会带幂等键重试的接口）
+	AbortRollback AbortPolicy = "rollback"
+)
+
+// onClientAbort 当前的断开处理策略（InitDB 时从配置读取，空值等同于 AbortCommitIfFinished）
+var onClientAbort AbortPolicy
+
 // DBMiddleware 数据库事务中间件
 //
-// 每个请求自动开启事务，提交或回滚
+// 每个请求自动开启事务，提交或回滚；提交成功后执行 AfterCommit 注册的回调。
+// 处理器 panic 时回滚后继续向上抛出；客户端中途断开时按 database.onClientAbort 配置处理。
+// 配置了 rlsVariables 时开启事务后按 JWT 身份设置行级安全会话变量（见 EnableRLS），
+// 此时应注册在 JWT 认证中间件之后，否则取不到用户，变量为 rlsSentinel；开启事务或设置失败时响应 500，不执行处理器
 //
 // 使用方式：
 //
 //	h.Use(database.DBMiddleware())
 func DBMiddleware() app.HandlerFunc {
+	return txMiddleware(func() (*sql.DB, AbortPolicy) { return DB, onClientAbort })
+}
+
+// TxMiddleware 使用指定连接池的事务中间件（行为与 DBMiddleware 相同，db 为 nil 时不开启事务）
+//
+// 使用方式：
+//
+//	db, err := database.Open(config.Database)
+//	h.Use(database.TxMiddleware(db, config.Database.OnClientAbort))
+func TxMiddleware(db *sql.DB, policy AbortPolicy) app.HandlerFunc {
+	return txMiddleware(func() (*sql.DB, AbortPolicy) { return db, policy })
+}
+
+// txMiddleware 请求时取连接池（DBMiddleware 注册时 InitDB 可能尚未调用）
+func txMiddleware(pool func() (*sql.DB, AbortPolicy)) app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
-		if DB == nil {
+		db, policy := pool()
+		if db == nil {
 			c.Next(ctx)
 			return
 		}
 
 		// 开启事务
-		tx, err := DB.Begin()
+		tx, err := db.Begin()
 		if err != nil {
-			logger.Errorf("[DB] Failed to begin transaction: %v", err)
+			log.Errorf("[DB] Failed to begin transaction: %v", err)
+			if rlsFor(db) != nil {
+				abortRLS(c)
+				return
+			}
 			c.Set("tx_error", err)
 			c.Next(ctx)
 			return
 		}
 
-		// 存储到上下文
+		// 行级安全：BEGIN 之后立即按请求身份设置会话变量（见 EnableRLS）
+		var identity map[string]string
+		endRLS, err := beginRLS(ctx, tx, db, func(s *rlsSession) map[string]string {
+			identity = s.requestIdentity(ctx, c)
+			return identity
+		})
+		if err != nil {
+			log.Errorf("[DB] %v", err)
+			tx.Rollback()
+			abortRLS(c)
+			return
+		}
+
+		// 存储到上下文（同时绑定到 ctx，供 Conn(ctx) 使用）
 		c.Set("tx", tx)
+		if identity != nil {
+			ctx = WithRLSIdentity(ctx, identity)
+		}
+		txCtx, hooks := WithCommitHooks(WithDBTX(ctx, tx))
 
-		// 处理请求
-		c.Next(ctx)
+		// 处理请求（panic 时处理器没有完成，回滚后交给外层的异常处理）
+		finished := false
+		defer func() {
+			if !finished {
+				endRLS()
+				tx.Rollback()
+			}
+		}()
+		c.Next(txCtx)
+		finished = true
 
 		// 检查是否有错误，决定提交或回滚
+		endRLS()
 		if err, ok := c.Get("tx_error"); ok && err != nil {
-			logger.Warnf("[DB] Rolling back transaction due to error: %v", err)
+			log.Warnf("[DB] Rolling back transaction due to error: %v", err)
+			tx.Rollback()
+		} else if policy == AbortRollback && middleware.IsClientAborted(c) {
+			log.Infof("[DB] Rolling back transaction: client aborted")
 			tx.Rollback()
 		} else {
-			logger.Debug("[DB] Committing transaction")
-			tx.Commit()
+			log.Debug("[DB] Committing transaction")
+			if err := tx.Commit(); err != nil {
+				log.Errorf("[DB] Failed to commit transaction: %v", err)
+				return
+			}
+			// 提交成功后执行 AfterCommit 注册的回调（缓存失效等）
+			hooks.Committed()
 		}
 	}
 }
 
+// abortRLS 开启了行级安全但无法建立带会话变量的事务：响应 500，不执行处理器
+// （处理器改用连接池查询会绕过行级安全）
+func abortRLS(c *app.RequestContext) {
+	c.AbortWithStatusJSON(consts.StatusInternalServerError, map[string]any{
+		"code":    consts.StatusInternalServerError,
+		"message": "Internal server error",
+		"data":    nil,
+	})
+}
+
 // GetTx 从上下文获取事务
 //
 // 使用方式：
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveAbortedRequest 启动真实服务（客户端断开需要真实连接），处理器执行 UPDATE 后客户端断开，
+// 返回请求结束后的语句记录。panics 为 true 时处理器以 context.Canceled panic 结束（没有完成）
+func serveAbortedRequest(t *testing.T, policy AbortPolicy, panics bool) []string {
+	old, oldPolicy := DB, onClientAbort
+	t.Cleanup(func() { DB, onClientAbort = old, oldPolicy })
+	f, db := newFakeDB(t)
+	DB, onClientAbort = db, policy
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	h := server.New(server.WithListener(ln), server.WithSenseClientDisconnection(true), server.WithExitWaitTime(0))
+	h.Use(middleware.ClientAbortMiddleware())
+	h.Use(func(ctx context.Context, c *app.RequestContext) {
+		defer close(done)
+		defer func() { recover() }()
+		c.Next(ctx)
+	})
+	h.Use(DBMiddleware())
+	h.GET("/orders", func(ctx context.Context, c *app.RequestContext) {
+		_, err := Conn(ctx).ExecContext(ctx, "UPDATE orders SET paid = 1")
+		assert.NoError(t, err)
+		close(started)
+		<-ctx.Done()
+		if panics {
+			panic(ctx.Err())
+		}
+		c.String(200, "ok")
+	})
+	go h.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		h.Shutdown(ctx)
+	})
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "GET /orders HTTP/1.1\r\nHost: test\r\n\r\n")
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("处理器没有开始执行")
+	}
+	conn.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("请求没有结束")
+	}
+	return f.statements()
+}
+
+func TestDBMiddleware_ClientAbort(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AbortPolicy
+		panics bool
+		want   string
+	}{
+		{"默认策略：处理器正常结束则提交", "", false, "COMMIT"},
+		{"commit：处理器正常结束则提交", AbortCommitIfFinished, false, "COMMIT"},
+		{"commit：处理器没有完成则回滚", AbortCommitIfFinished, true, "ROLLBACK"},
+		{"rollback：一律回滚", AbortRollback, false, "ROLLBACK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := serveAbortedRequest(t, tt.policy, tt.panics)
+			assert.Equal(t, []string{"BEGIN", "UPDATE orders SET paid = 1", tt.want}, statements)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// DecompressConfig 请求体解压配置
+//
+// 默认关闭。开启后 Content-Encoding 为 gzip / deflate 的请求体在进入业务之前解压，
+// 绑定、审计、原始请求体读取看到的都是解压后的内容，Content-Encoding 请求头被删除。
+// maxSize 限制解压后的大小（与服务器对压缩后请求体的大小限制相互独立），超出时响应 413，
+// 防止很小的压缩包解压出巨大的内容（zip bomb）；不支持的编码响应 415
+//
+// Example:
+//
+//	[web.decompress]
+//	enabled = true
+//	encodings = ["gzip", "deflate"]   # 允许的编码（默认 gzip 与 deflate）
+//	maxSize = "1MiB"                   # 解压后的大小上限（默认 1MiB）
+//	paths = ["/api/iot/"]              # 只对这些路径前缀生效（默认全部路径）
+type DecompressConfig struct {
+	Enabled   bool         `toml:"enabled"`
+	Encodings []string     `toml:"encodings"`
+	MaxSize   cfg.ByteSize `toml:"maxSize"`
+	Paths     []string     `toml:"paths"`
+}
+
+// decompressors 支持的编码（deflate 按 HTTP 规范为 zlib 格式）
+var decompressors = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip":    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	"deflate": func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+}
+
+// errBodyTooLarge 解压后超出 maxSize
+var errBodyTooLarge = errors.New("decompressed body exceeds limit")
+
+// DecompressMiddleware 请求体解压中间件
+//
+// 注册在全局中间件靠前的位置（NewServer 在配置开启时自动注册），也可以只挂在需要的路由组上。
+// 解压以流的方式进行，最多读取 maxSize+1 字节即停止，内存占用不超过上限；
+// 没有 Content-Encoding 或为 identity 的请求直接放行。
+//
+// 解压结果整体写回请求体，而不是用 SetBodyStream 交给下游边读边解压：绑定、审计、签名校验、
+// 原始请求体读取都通过 Request.Body() 一次读入完整请求体，流式交付不会减少内存占用；
+// 而 Body() 会丢弃流的读取错误，超限只会表现为下游看到的空请求体，无法在这里统一响应 413。
+// 因此每个请求最多占用 maxSize 的内存，默认值取得较小（1MiB），需要更大的请求体时按路径单独放宽。
+// 指标：http_request_decompressed_total{encoding}、http_request_decompress_rejected_total{reason}
+// （reason 为 too_large / unsupported / malformed）
+//
+// 使用方式：
+//
+//	iot := r.Group("/api/iot", web.DecompressMiddleware(web.DecompressConfig{Enabled: true, MaxSize: 2 * cfg.MiB}))
+func DecompressMiddleware(config DecompressConfig) app.HandlerFunc {
+	encodings := config.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{"gzip", "deflate"}
+	}
+	limit := config.MaxSize.Bytes()
+	if limit <= 0 {
+		limit = cfg.MiB.Bytes()
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		encoding := strings.ToLower(strings.TrimSpace(string(c.Request.Header.Peek("Content-Encoding"))))
+		if encoding == "" || encoding == "identity" || !isDecompressPath(string(c.Request.URI().Path()), config.Paths) {
+			c.Next(ctx)
+			return
+		}
+		open, ok := decompressors[encoding]
+		if !ok || !slices.Contains(encodings, encoding) {
+			metrics.GetCounter("http_request_decompress_rejected_total", "reason", "unsupported").Inc()
+			c.Header("Accept-Encoding", strings.Join(encodings, ", "))
+			c.AbortWithStatusJSON(consts.StatusUnsupportedMediaType, Fail(consts.StatusUnsupportedMediaType, MsgUnsupportedEncoding))
+			return
+		}
+
+		body, err := decompressBody(c, open, limit)
+		switch {
+		case errors.Is(err, errBodyTooLarge):
+			metrics.GetCounter("http_request_decompress_rejected_total", "reason", "too_large").Inc()
+			log.Warnf("[Decompress] 解压后的请求体超过 %d 字节，已拒绝: %s %s", limit, c.Method(), c.Path())
+			c.AbortWithStatusJSON(consts.StatusRequestEntityTooLarge, Fail(consts.StatusRequestEntityTooLarge, MsgBodyTooLarge))
+			return
+		case err != nil:
+			metrics.GetCounter("http_request_decompress_rejected_total", "reason", "malformed").Inc()
+			c.AbortWithStatusJSON(consts.StatusBadRequest, Fail(consts.StatusBadRequest, MsgMalformedBody))
+			return
+		}
+
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.SetBody(body)
+		c.Request.Header.SetContentLength(len(body))
+		metrics.GetCounter("http_request_decompressed_total", "encoding", encoding).Inc()
+		c.Next(ctx)
+	}
+}
+
+// decompressBody 流式解压请求体，超过 limit 字节时返回 errBodyTooLarge
+func decompressBody(c *app.RequestContext, open func(io.Reader) (io.ReadCloser, error), limit int64) ([]byte, error) {
+	var src io.Reader
+	if c.Request.IsBodyStream() {
+		src = c.Request.BodyStream()
+	} else {
+		src = bytes.NewReader(c.Request.Body())
+	}
+	r, err := open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, errBodyTooLarge
+	}
+	return buf.Bytes(), nil
+}
+
+// isDecompressPath 路径是否在生效范围内（未配置前缀时全部生效）
+func isDecompressPath(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type telemetry struct {
+	Device string    `json:"device"`
+	Values []float64 `json:"values"`
+}
+
+func newDecompressEngine(conf DecompressConfig) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler(), DecompressMiddleware(conf))
+	engine.POST("/api/iot/telemetry", func(ctx context.Context, c *app.RequestContext) {
+		var req telemetry
+		if err := BindContext(ctx, c, &req); err != nil {
+			panic(err)
+		}
+		c.JSON(200, Success(map[string]any{
+			"device":   req.Device,
+			"count":    len(req.Values),
+			"encoding": string(c.Request.Header.Peek("Content-Encoding")),
+		}))
+	})
+	return engine
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	switch encoding {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case "deflate":
+		w := zlib.NewWriter(&buf)
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	return buf.Bytes()
+}
+
+func postEncoded(engine *route.Engine, encoding string, body []byte) *ut.ResponseRecorder {
+	return ut.PerformRequest(engine, "POST", "/api/iot/telemetry", &ut.Body{Body: bytes.NewReader(body), Len: len(body)},
+		ut.Header{Key: "Content-Type", Value: "application/json"}, ut.Header{Key: "Content-Encoding", Value: encoding})
+}
+
+func TestDecompressMiddleware_Gzip(t *testing.T) {
+	engine := newDecompressEngine(DecompressConfig{Enabled: true})
+	payload, _ := json.Marshal(telemetry{Device: "sensor-7", Values: []float64{21.5, 21.7, 22}})
+	before := metrics.GetCounter("http_request_decompressed_total", "encoding", "gzip").Value()
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		w := postEncoded(engine, encoding, compress(t, encoding, payload))
+		require.Equal(t, 200, w.Code, w.Body.String())
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "sensor-7", resp.Data["device"])
+		assert.Equal(t, float64(3), resp.Data["count"])
+		assert.Empty(t, resp.Data["encoding"], "下游看不到 Content-Encoding")
+	}
+	assert.Equal(t, before+1, metrics.GetCounter("http_request_decompressed_total", "encoding", "gzip").Value())
+
+	w := postEncoded(engine, "identity", payload)
+	assert.Equal(t, 200, w.Code, "未压缩的请求直接放行")
+}
+
+func TestDecompressMiddleware_ZipBomb(t *testing.T) {
+	engine := newDecompressEngine(DecompressConfig{Enabled: true, MaxSize: 64 * cfg.KiB})
+	// 10MiB 的 0 压缩后只有约 10KB
+	bomb := compress(t, "gzip", make([]byte, 10*1024*1024))
+	require.Less(t, len(bomb), 64*1024)
+	before := metrics.GetCounter("http_request_decompress_rejected_total", "reason", "too_large").Value()
+
+	w := postEncoded(engine, "gzip", bomb)
+	assert.Equal(t, 413, w.Code)
+	assert.Contains(t, w.Body.String(), MsgBodyTooLarge)
+	assert.Equal(t, before+1, metrics.GetCounter("http_request_decompress_rejected_total", "reason", "too_large").Value())
+
+	// 默认上限 1MiB
+	w = postEncoded(newDecompressEngine(DecompressConfig{Enabled: true}), "gzip", compress(t, "gzip", make([]byte, 2*1024*1024)))
+	assert.Equal(t, 413, w.Code)
+
+	w = postEncoded(engine, "gzip", []byte(`{"device":"not gzip"}`))
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), MsgMalformedBody)
+}
+
+func TestDecompressMiddleware_UnsupportedEncoding(t *testing.T) {
+	engine := newDecompressEngine(DecompressConfig{Enabled: true, Encodings: []string{"gzip"}})
+	payload := []byte(`{"device":"sensor-7"}`)
+
+	for _, encoding := range []string{"br", "deflate"} {
+		w := postEncoded(engine, encoding, payload)
+		assert.Equal(t, 415, w.Code, encoding)
+		var resp Result
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 415, resp.Code)
+		assert.Equal(t, MsgUnsupportedEncoding, resp.Message)
+		assert.Equal(t, "gzip", w.Header().Get("Accept-Encoding"))
+	}
+
+	scoped := newDecompressEngine(DecompressConfig{Enabled: true, Paths: []string{"/api/other/"}})
+	w := postEncoded(scoped, "br", payload)
+	assert.Equal(t, 200, w.Code, "不在生效路径内的请求不处理")
+}
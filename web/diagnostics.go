@@ -0,0 +1,554 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// DiagnosticsConfig 诊断包配置
+//
+// Example:
+//
+//	[web.diagnostics]
+//	timeoutSeconds = 30         # 生成诊断包的总时限，超时的分段只记录错误，默认 30
+//	maxSectionBytes = 8388608   # 单个分段的大小上限，超出时截断，默认 8MB
+//	logRecords = 1000           # 内存中保留的最近日志条数，默认 1000，负数表示不保留
+type DiagnosticsConfig struct {
+	TimeoutSeconds  int `toml:"timeoutSeconds"`
+	MaxSectionBytes int `toml:"maxSectionBytes"`
+	LogRecords      int `toml:"logRecords"`
+}
+
+// 诊断包默认限制
+const (
+	defaultDiagnosticsTimeout    = 30 * time.Second
+	defaultDiagnosticsMaxSection = 8 << 20
+)
+
+var diagnosticsConfig = DiagnosticsConfig{
+	TimeoutSeconds:  int(defaultDiagnosticsTimeout / time.Second),
+	MaxSectionBytes: defaultDiagnosticsMaxSection,
+	LogRecords:      logger.DefaultRecentLogSize,
+}
+
+// InitDiagnostics 设置诊断包的时限、分段大小上限与内存中保留的日志条数（零值字段使用默认值）
+func InitDiagnostics(config DiagnosticsConfig) {
+	if config.TimeoutSeconds <= 0 {
+		config.TimeoutSeconds = int(defaultDiagnosticsTimeout / time.Second)
+	}
+	if config.MaxSectionBytes <= 0 {
+		config.MaxSectionBytes = defaultDiagnosticsMaxSection
+	}
+	if config.LogRecords == 0 {
+		config.LogRecords = logger.DefaultRecentLogSize
+	}
+	diagnosticsConfig = config
+	logger.SetRecentLogSize(config.LogRecords)
+}
+
+// DiagnosticsFunc 应用自定义的诊断分段，返回值以 JSON 写入诊断包
+type DiagnosticsFunc func(ctx context.Context) (any, error)
+
+var customDiagnostics struct {
+	sync.Mutex
+	names []string
+	fns   map[string]DiagnosticsFunc
+}
+
+// RegisterDiagnostics 注册应用自定义的诊断分段，写入诊断包的 <name>.json（名称重复时 panic）
+//
+// 使用方式：
+//
+//	hub := ws.NewHub()
+//	web.RegisterDiagnostics("ws", func(ctx context.Context) (any, error) {
+//	    return hub.Stats(), nil
+//	})
+func RegisterDiagnostics(name string, fn DiagnosticsFunc) {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		panic(fmt.Errorf("诊断分段名 %q 无效", name))
+	}
+	customDiagnostics.Lock()
+	defer customDiagnostics.Unlock()
+	if _, ok := customDiagnostics.fns[name]; ok || isBuiltinDiagnostics(name+".json") {
+		panic(fmt.Errorf("诊断分段 %s 重复注册", name))
+	}
+	if customDiagnostics.fns == nil {
+		customDiagnostics.fns = make(map[string]DiagnosticsFunc)
+	}
+	customDiagnostics.names = append(customDiagnostics.names, name)
+	customDiagnostics.fns[name] = fn
+}
+
+// DiagnosticsBundleHandler 生成运行时诊断包（流式 zip 下载）
+//
+// 诊断包包含：manifest.json（分段清单）、goroutines.txt、heap.pb.gz、allocs.pb.gz、
+// runtime.json（内存与 GC 统计）、startup.json（组件启动报告）、config.json（生效配置，敏感项已脱敏）、
+// routes.json（校验后的路由表）、limits.json（过载保护、慢启动、采集缓冲、限流与出站限速）、
+// errors.json（错误指纹）、logs.jsonl（内存中最近的日志），以及 RegisterDiagnostics 注册的分段。
+//
+// 生成受 [web.diagnostics] 限制：总时限内未完成的分段只在 manifest 中记录错误；
+// 超出大小上限的分段被截断（文本末尾追加截断标记，JSON 改为 {"truncated": true, "preview": ...}，
+// 日志保留最新的记录，pprof 改为 .truncated.txt 说明）。
+// 同时到达的请求共享同一次生成的结果；每次下载都以 debug.bundle 事件审计，记录操作人。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.POST("/debug/bundle", web.DiagnosticsBundleHandler())
+//
+//	# 命令行下载
+//	curl -X POST -H "Authorization: Bearer $TOKEN" -o bundle.zip https://api.example.com/admin/debug/bundle
+//	unzip -l bundle.zip
+//	go tool pprof -top heap.pb.gz
+func DiagnosticsBundleHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		bundle, coalesced, err := collectDiagnostics(middleware.ClientContext(c), diagnosticsConfig)
+		if err != nil {
+			// 等待共享结果期间客户端已断开
+			return
+		}
+
+		var size int
+		var truncated []string
+		for _, s := range bundle.Sections {
+			size += s.Bytes
+			if s.Truncated || s.Error != "" {
+				truncated = append(truncated, s.File)
+			}
+		}
+		actor := jwt.GetActor(c)
+		log.Warnf("[Diagnostics] %s 下载诊断包（%d 字节，共享生成结果: %v）", actor, size, coalesced)
+		audit.Emit(ctx, audit.Event{
+			Type:      "debug.bundle",
+			Actor:     actor,
+			RequestID: middleware.GetRequestID(c),
+			Method:    string(c.Method()),
+			Path:      string(c.Path()),
+			Status:    consts.StatusOK,
+			Data: map[string]any{
+				"coalesced": coalesced,
+				"sections":  len(bundle.Sections),
+				"bytes":     size,
+				"truncated": truncated,
+			},
+		})
+
+		filename := fmt.Sprintf("diagnostics-%s.zip", bundle.GeneratedAt.Format("20060102-150405"))
+		StreamZip(c, filename, func(ctx context.Context, zw *zip.Writer) error {
+			for _, f := range bundle.files() {
+				w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: bundle.GeneratedAt})
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(f.data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// diagFormat 分段格式（决定截断方式）
+type diagFormat int
+
+const (
+	diagText diagFormat = iota
+	diagJSON
+	diagBinary
+	diagLines // 每行一条 JSON，由生成器自行截断（保留最新的记录）
+)
+
+// diagSection 诊断分段生成器
+type diagSection struct {
+	file   string
+	format diagFormat
+	write  func(ctx context.Context, w *cappedBuffer) error
+}
+
+// diagnosticsBundle 一次生成的诊断包（同时到达的请求共享）
+type diagnosticsBundle struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Hostname    string          `json:"hostname"`
+	PID         int             `json:"pid"`
+	GoVersion   string          `json:"goVersion"`
+	DurationMs  int64           `json:"durationMs"`
+	Sections    []bundleSection `json:"sections"`
+}
+
+// bundleSection 分段的生成结果
+type bundleSection struct {
+	File       string `json:"file"`  // 包内文件名
+	Bytes      int    `json:"bytes"` // 写入的字节数
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"` // 生成失败或超时
+	DurationMs int64  `json:"durationMs"`
+	data       []byte
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// files 包内文件（manifest.json 在最前）
+func (b *diagnosticsBundle) files() []bundleFile {
+	manifest, _ := json.MarshalIndent(b, "", "  ")
+	files := []bundleFile{{name: "manifest.json", data: manifest}}
+	for _, s := range b.Sections {
+		files = append(files, bundleFile{name: s.File, data: s.data})
+	}
+	return files
+}
+
+// errSectionFull 分段超出大小上限
+var errSectionFull = errors.New("超出分段大小上限")
+
+// cappedBuffer 有大小上限的缓冲，写满后返回 errSectionFull 让生成器尽早停止
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return max(room, 0), errSectionFull
+	}
+	return b.buf.Write(p)
+}
+
+// remaining 剩余可写的字节数
+func (b *cappedBuffer) remaining() int {
+	return b.max - b.buf.Len()
+}
+
+// diagnosticsFlight 进行中的生成（同时到达的请求等待同一次结果）
+var diagnosticsFlight struct {
+	sync.Mutex
+	call *diagnosticsCall
+}
+
+type diagnosticsCall struct {
+	done   chan struct{}
+	bundle *diagnosticsBundle
+}
+
+// collectDiagnostics 生成诊断包；已有进行中的生成时等待并共享其结果（coalesced 为 true）
+//
+// 生成不受单个请求取消的影响（其他请求可能在等待同一结果），只受总时限约束；
+// ctx 只用于等待，取消时返回 ctx.Err()
+func collectDiagnostics(ctx context.Context, config DiagnosticsConfig) (bundle *diagnosticsBundle, coalesced bool, err error) {
+	diagnosticsFlight.Lock()
+	call := diagnosticsFlight.call
+	if call == nil {
+		call = &diagnosticsCall{done: make(chan struct{})}
+		diagnosticsFlight.call = call
+		diagnosticsFlight.Unlock()
+
+		call.bundle = generateDiagnostics(config)
+		diagnosticsFlight.Lock()
+		diagnosticsFlight.call = nil
+		diagnosticsFlight.Unlock()
+		close(call.done)
+		return call.bundle, false, nil
+	}
+	diagnosticsFlight.Unlock()
+
+	select {
+	case <-call.done:
+		return call.bundle, true, nil
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	}
+}
+
+// generateDiagnostics 并发生成全部分段，总时限内未完成的分段记录为超时
+func generateDiagnostics(config DiagnosticsConfig) *diagnosticsBundle {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	sections := diagnosticsSections(config)
+	results := make([]chan bundleSection, len(sections))
+	for i, s := range sections {
+		results[i] = make(chan bundleSection, 1)
+		go func() { results[i] <- runDiagnosticsSection(ctx, s, config.MaxSectionBytes) }()
+	}
+
+	hostname, _ := os.Hostname()
+	bundle := &diagnosticsBundle{GeneratedAt: start, Hostname: hostname, PID: os.Getpid(), GoVersion: runtime.Version()}
+	for i, s := range sections {
+		var r bundleSection
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			select {
+			case r = <-results[i]:
+			default:
+				// 未完成的生成器继续在后台运行，结果丢弃
+				r = failedSection(s.file, start, fmt.Errorf("%d 秒内未完成", config.TimeoutSeconds))
+			}
+		}
+		bundle.Sections = append(bundle.Sections, r)
+	}
+	bundle.DurationMs = time.Since(start).Milliseconds()
+	return bundle
+}
+
+// runDiagnosticsSection 生成单个分段并按格式处理截断与失败
+func runDiagnosticsSection(ctx context.Context, s diagSection, limit int) (out bundleSection) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			out = failedSection(s.file, start, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	buf := &cappedBuffer{max: limit}
+	err := s.write(ctx, buf)
+	if errors.Is(err, errSectionFull) {
+		err = nil
+	}
+	if err != nil {
+		return failedSection(s.file, start, err)
+	}
+
+	out = bundleSection{File: s.file, Truncated: buf.truncated, data: buf.buf.Bytes()}
+	if buf.truncated {
+		switch s.format {
+		case diagText:
+			out.data = fmt.Appendf(out.data, "\n--- truncated: 超出 %d 字节上限 ---\n", limit)
+		case diagJSON:
+			out.data, _ = json.Marshal(map[string]any{"truncated": true, "limit": limit, "preview": buf.buf.String()})
+		case diagBinary:
+			out.File = s.file + ".truncated.txt"
+			out.data = fmt.Appendf(nil, "truncated: %s 超出 %d 字节上限，已丢弃\n", s.file, limit)
+		}
+	}
+	out.Bytes = len(out.data)
+	out.DurationMs = time.Since(start).Milliseconds()
+	return out
+}
+
+// failedSection 生成失败的分段：manifest 记录错误，包内写入 .error.txt 说明
+func failedSection(file string, start time.Time, err error) bundleSection {
+	data := fmt.Appendf(nil, "error: %v\n", err)
+	return bundleSection{
+		File:       file + ".error.txt",
+		Bytes:      len(data),
+		Error:      err.Error(),
+		DurationMs: time.Since(start).Milliseconds(),
+		data:       data,
+	}
+}
+
+// builtinDiagnostics 内置分段（不含 manifest.json）
+var builtinDiagnostics = []diagSection{
+	{file: "goroutines.txt", format: diagText, write: profileSection("goroutine", 2)},
+	{file: "heap.pb.gz", format: diagBinary, write: profileSection("heap", 0)},
+	{file: "allocs.pb.gz", format: diagBinary, write: profileSection("allocs", 0)},
+	{file: "runtime.json", format: diagJSON, write: jsonSection(runtimeDiagnostics)},
+	{file: "startup.json", format: diagJSON, write: jsonSection(startupDiagnostics)},
+	{file: "config.json", format: diagJSON, write: jsonSection(func(context.Context) (any, error) {
+		return cfg.Redact(cfg.Current()), nil
+	})},
+	{file: "routes.json", format: diagJSON, write: jsonSection(func(context.Context) (any, error) {
+		var routes []RouteInfo
+		if p := validatedRoutes.Load(); p != nil {
+			routes = *p
+		}
+		return routes, nil
+	})},
+	{file: "limits.json", format: diagJSON, write: jsonSection(limitsDiagnostics)},
+	{file: "errors.json", format: diagJSON, write: jsonSection(func(context.Context) (any, error) {
+		return logger.ErrorFingerprints(), nil
+	})},
+	{file: "logs.jsonl", format: diagLines, write: logsSection},
+}
+
+func isBuiltinDiagnostics(file string) bool {
+	if file == "manifest.json" {
+		return true
+	}
+	for _, s := range builtinDiagnostics {
+		if s.file == file {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnosticsSections 内置分段 + 应用注册的分段
+func diagnosticsSections(config DiagnosticsConfig) []diagSection {
+	sections := append([]diagSection(nil), builtinDiagnostics...)
+	customDiagnostics.Lock()
+	defer customDiagnostics.Unlock()
+	for _, name := range customDiagnostics.names {
+		sections = append(sections, diagSection{file: name + ".json", format: diagJSON, write: jsonSection(customDiagnostics.fns[name])})
+	}
+	return sections
+}
+
+// profileSection pprof 分段（debug = 0 为 gzip 压缩的 protobuf，2 为 goroutine 文本堆栈）
+func profileSection(name string, debugLevel int) func(context.Context, *cappedBuffer) error {
+	return func(ctx context.Context, w *cappedBuffer) error {
+		p := pprof.Lookup(name)
+		if p == nil {
+			return fmt.Errorf("pprof profile %s 不存在", name)
+		}
+		return p.WriteTo(w, debugLevel)
+	}
+}
+
+// jsonSection 以缩进 JSON 写入 fn 的返回值
+func jsonSection(fn DiagnosticsFunc) func(context.Context, *cappedBuffer) error {
+	return func(ctx context.Context, w *cappedBuffer) error {
+		v, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+}
+
+func runtimeDiagnostics(context.Context) (any, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	recent := gc.Pause[:min(len(gc.Pause), 20)]
+	return map[string]any{
+		"goVersion":    runtime.Version(),
+		"goos":         runtime.GOOS,
+		"goarch":       runtime.GOARCH,
+		"numCPU":       runtime.NumCPU(),
+		"gomaxprocs":   runtime.GOMAXPROCS(0),
+		"numGoroutine": runtime.NumGoroutine(),
+		"numCgoCall":   runtime.NumCgoCall(),
+		"memStats":     mem,
+		"gc": map[string]any{
+			"numGC":        gc.NumGC,
+			"lastGC":       gc.LastGC,
+			"pauseTotalNs": gc.PauseTotal.Nanoseconds(),
+			"recentPauses": recent, // 最近的停顿（纳秒，最新在前）
+		},
+	}, nil
+}
+
+func startupDiagnostics(context.Context) (any, error) {
+	type component struct {
+		Name       string `json:"name"`
+		DurationMs int64  `json:"durationMs"`
+		Error      string `json:"error,omitempty"`
+	}
+	reports := StartupReport()
+	out := make([]component, 0, len(reports))
+	for _, r := range reports {
+		c := component{Name: r.Name, DurationMs: r.Duration.Milliseconds()}
+		if r.Err != nil {
+			c.Error = r.Err.Error()
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// limitsMetricPrefixes limits.json 中附带的限流相关指标
+var limitsMetricPrefixes = []string{"web_shed", "web_inflight", "web_adaptive", "web_slowstart", "web_bandwidth", "web_ingest", "outbound_"}
+
+func limitsDiagnostics(context.Context) (any, error) {
+	data := make(map[string]any)
+	if s := globalShedder; s != nil {
+		data["shedding"] = map[string]any{
+			"capacity":   s.config.Capacity,
+			"inflight":   s.inflight.Load(),
+			"saturation": s.Saturation(),
+			"disabled":   s.disabled.Load(),
+		}
+		if adaptive := s.AdaptiveStatus(); adaptive != nil {
+			data["adaptiveLimit"] = adaptive
+		}
+	}
+	if status := CurrentSlowStart(); status != nil {
+		data["slowStart"] = status
+	}
+	if i := globalIngester.Load(); i != nil {
+		data["ingest"] = i.Stats()
+	}
+	if rl := defaultApp.limiter; rl != nil {
+		rl.mu.RLock()
+		data["rateLimit"] = map[string]any{
+			"requestsPerSecond": rl.config.RequestsPerSecond,
+			"burstSize":         rl.config.BurstSize,
+			"trackedClients":    len(rl.limiters),
+		}
+		rl.mu.RUnlock()
+	}
+	values := make(map[string]float64)
+	for key, v := range metrics.Snapshot() {
+		for _, prefix := range limitsMetricPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				values[key] = v
+				break
+			}
+		}
+	}
+	data["metrics"] = values
+	return data, nil
+}
+
+// logsSection 最近的日志（每行一条 JSON）；超出上限时保留最新的记录，首行为截断标记
+func logsSection(ctx context.Context, w *cappedBuffer) error {
+	records := logger.RecentLogs(0)
+	const markerReserve = 128
+	budget := w.remaining() - markerReserve
+	lines := make([][]byte, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			continue
+		}
+		if budget -= len(line) + 1; budget < 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if dropped := len(records) - len(lines); dropped > 0 {
+		marker, _ := json.Marshal(map[string]any{"truncated": true, "dropped": dropped, "kept": len(lines)})
+		if _, err := fmt.Fprintf(w, "%s\n", marker); err != nil {
+			return err
+		}
+		w.truncated = true
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if _, err := fmt.Fprintf(w, "%s\n", lines[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/ws"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerTestDiagnostics 注册自定义分段，测试结束后移除
+func registerTestDiagnostics(t *testing.T, name string, fn DiagnosticsFunc) {
+	RegisterDiagnostics(name, fn)
+	t.Cleanup(func() {
+		customDiagnostics.Lock()
+		defer customDiagnostics.Unlock()
+		delete(customDiagnostics.fns, name)
+		for i, n := range customDiagnostics.names {
+			if n == name {
+				customDiagnostics.names = append(customDiagnostics.names[:i], customDiagnostics.names[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func TestDiagnosticsBundle_SectionsParseable(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "diagnostics-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	t.Cleanup(func() { audit.SetSink(nil) })
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"orders\"\nport = 8080\n\n[database]\nhost = \"db.internal\"\npassword = \"p@ssw0rd\"\n"), 0644))
+	require.NoError(t, cfg.LoadConfig[stageAppConfig](path))
+
+	hub := ws.NewHub()
+	registerTestDiagnostics(t, "ws", func(ctx context.Context) (any, error) { return hub.Stats(), nil })
+	logger.UpdateLogLevel("info")
+	logger.Infof("diagnostics-test marker")
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler(), jwt.Middleware())
+	engine.POST("/admin/debug/bundle", DiagnosticsBundleHandler())
+	w := ut.PerformRequest(engine, "POST", "/admin/debug/bundle", nil, ownershipToken(t, "ops"))
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/zip", string(w.Header().ContentType()))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "diagnostics-")
+
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+	}
+
+	var manifest diagnosticsBundle
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	var names []string
+	for _, s := range manifest.Sections {
+		assert.Empty(t, s.Error, s.File)
+		assert.False(t, s.Truncated, s.File)
+		names = append(names, s.File)
+	}
+	assert.Equal(t, []string{"goroutines.txt", "heap.pb.gz", "allocs.pb.gz", "runtime.json", "startup.json",
+		"config.json", "routes.json", "limits.json", "errors.json", "logs.jsonl", "ws.json"}, names)
+
+	for _, name := range names {
+		data, ok := files[name]
+		require.True(t, ok, "缺少分段 %s", name)
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			assert.True(t, json.Valid(data), name)
+		case strings.HasSuffix(name, ".pb.gz"):
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			require.NoError(t, err, name)
+			_, err = io.ReadAll(gz)
+			assert.NoError(t, err, name)
+		}
+	}
+	assert.Contains(t, string(files["goroutines.txt"]), "goroutine ")
+
+	var config map[string]any
+	require.NoError(t, json.Unmarshal(files["config.json"], &config))
+	assert.Equal(t, "orders", config["appName"])
+	database := config["database"].(map[string]any)
+	assert.Equal(t, "db.internal", database["host"])
+	assert.Equal(t, cfg.RedactedValue, database["password"])
+	assert.NotContains(t, string(body), "p@ssw0rd")
+
+	var stats ws.HubStats
+	require.NoError(t, json.Unmarshal(files["ws.json"], &stats))
+
+	var found bool
+	scanner := bufio.NewScanner(bytes.NewReader(files["logs.jsonl"]))
+	for scanner.Scan() {
+		var record logger.LogRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		found = found || record.Message == "diagnostics-test marker"
+	}
+	assert.True(t, found, "日志分段应包含最近的日志")
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	require.Len(t, rec.events, 1)
+	assert.Equal(t, "debug.bundle", rec.events[0].Type)
+	assert.Equal(t, "ops", rec.events[0].Actor)
+	assert.Equal(t, false, rec.events[0].Data["coalesced"])
+}
+
+func TestDiagnosticsSection_Truncation(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	write := func(ctx context.Context, w *cappedBuffer) error {
+		_, err := w.Write([]byte(big))
+		return err
+	}
+
+	text := runDiagnosticsSection(context.Background(), diagSection{file: "a.txt", format: diagText, write: write}, 10)
+	assert.True(t, text.Truncated)
+	assert.True(t, strings.HasPrefix(string(text.data), "xxxxxxxxxx\n--- truncated"))
+
+	js := runDiagnosticsSection(context.Background(), diagSection{file: "a.json", format: diagJSON, write: jsonSection(
+		func(context.Context) (any, error) { return []string{big}, nil })}, 10)
+	var preview map[string]any
+	require.NoError(t, json.Unmarshal(js.data, &preview))
+	assert.Equal(t, true, preview["truncated"])
+
+	bin := runDiagnosticsSection(context.Background(), diagSection{file: "heap.pb.gz", format: diagBinary, write: write}, 10)
+	assert.Equal(t, "heap.pb.gz.truncated.txt", bin.File)
+
+	failed := runDiagnosticsSection(context.Background(), diagSection{file: "a.json", format: diagJSON, write: func(context.Context, *cappedBuffer) error {
+		panic("boom")
+	}}, 10)
+	assert.Equal(t, "a.json.error.txt", failed.File)
+	assert.Contains(t, failed.Error, "boom")
+}
+
+func TestDiagnosticsLogs_KeepsNewest(t *testing.T) {
+	logger.UpdateLogLevel("info")
+	for i := range 50 {
+		logger.Infof("diagnostics-log-%02d", i)
+	}
+
+	out := runDiagnosticsSection(context.Background(), diagSection{file: "logs.jsonl", format: diagLines, write: logsSection}, 1024)
+	assert.True(t, out.Truncated)
+	assert.LessOrEqual(t, len(out.data), 1024)
+	lines := strings.Split(strings.TrimSpace(string(out.data)), "\n")
+	var marker map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &marker))
+	assert.Equal(t, true, marker["truncated"])
+	var last logger.LogRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(t, "diagnostics-log-49", last.Message)
+}
+
+func TestCollectDiagnostics_CoalescesAndTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	var calls sync.WaitGroup
+	calls.Add(1)
+	var once sync.Once
+	registerTestDiagnostics(t, "slow", func(ctx context.Context) (any, error) {
+		once.Do(calls.Done)
+		select {
+		case <-release:
+			return "done", nil
+		case <-time.After(5 * time.Second):
+			return "late", nil
+		}
+	})
+
+	config := DiagnosticsConfig{TimeoutSeconds: 1, MaxSectionBytes: 1 << 20}
+	type result struct {
+		bundle    *diagnosticsBundle
+		coalesced bool
+	}
+	results := make(chan result, 2)
+	go func() {
+		b, coalesced, _ := collectDiagnostics(context.Background(), config)
+		results <- result{b, coalesced}
+	}()
+	calls.Wait()
+	go func() {
+		b, coalesced, _ := collectDiagnostics(context.Background(), config)
+		results <- result{b, coalesced}
+	}()
+
+	first, second := <-results, <-results
+	close(release)
+	assert.Same(t, first.bundle, second.bundle)
+	assert.NotEqual(t, first.coalesced, second.coalesced)
+
+	// 总时限内未完成的分段记录为错误，其他分段不受影响
+	last := first.bundle.Sections[len(first.bundle.Sections)-1]
+	assert.Equal(t, "slow.json.error.txt", last.File)
+	assert.Contains(t, last.Error, "未完成")
+	assert.Empty(t, first.bundle.Sections[0].Error)
+
+	// 等待中的请求取消时不影响进行中的生成
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	diagnosticsFlight.Lock()
+	diagnosticsFlight.call = &diagnosticsCall{done: make(chan struct{})}
+	diagnosticsFlight.Unlock()
+	t.Cleanup(func() {
+		diagnosticsFlight.Lock()
+		diagnosticsFlight.call = nil
+		diagnosticsFlight.Unlock()
+	})
+	_, _, err := collectDiagnostics(ctx, config)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,460 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/fieldcrypt"
+)
+
+// FieldChange 一个字段的变更
+//
+// Path 为 JSON 字段名组成的路径：嵌套结构体用点分隔（address.city），
+// 按下标比较的切片为 items[2].qty，按键比较的切片为 items[id=7].qty，map 为 attrs.color。
+// 切片元素或 map 键的新增/删除记录为整个元素，Old 或 New 为 nil。
+// 敏感字段只记录发生了变更（Sensitive 为 true），不记录 Old / New
+type FieldChange struct {
+	Path      string `json:"path"`
+	Old       any    `json:"old,omitempty"`
+	New       any    `json:"new,omitempty"`
+	Sensitive bool   `json:"sensitive,omitempty"`
+}
+
+// String 便于日志和变更历史展示，如 "status: pending → paid"
+func (fc FieldChange) String() string {
+	if fc.Sensitive {
+		return fc.Path + ": (已修改)"
+	}
+	return fmt.Sprintf("%s: %v → %v", fc.Path, fc.Old, fc.New)
+}
+
+// maxDiffDepth 最大嵌套深度（防止循环引用导致无限递归）
+const maxDiffDepth = 32
+
+// Diff 计算两个结构体之间的字段级差异（用于变更历史和审计）
+//
+// old / new 为结构体或结构体指针，类型可以不同（按 JSON 字段名对齐，只比较双方都有的字段）；
+// 一侧为 nil 指针时按零值比较（创建 / 删除时记录所有非零字段）。
+// 字段标签：
+//
+//	json:"name"          路径中的字段名（未设置时使用 Go 字段名，json:"-" 的字段不比较）
+//	diff:"-"             不比较该字段
+//	diff:"key=id"        切片按元素的 id 字段对齐（只调整顺序不算变更），默认按下标对齐
+//	sensitive:"true"     只记录变更，不记录新旧值（嵌套结构体不再展开）
+//
+// fieldcrypt.EncryptedString / SearchableString 字段自动视为敏感字段。
+// 实现 Equal 方法的类型（如 time.Time）用 Equal 比较。每对类型的比较计划只计算一次
+//
+// 使用方式：
+//
+//	changes, err := web.Diff(oldOrder, newOrder)
+//	web.RecordChanges(c, "order", orderID, changes)
+func Diff(old, new any) ([]FieldChange, error) {
+	ov, ot, err := diffRoot(old)
+	if err != nil {
+		return nil, err
+	}
+	nv, nt, err := diffRoot(new)
+	if err != nil {
+		return nil, err
+	}
+	if ot == nil && nt == nil {
+		return nil, errors.New("diff: old 和 new 不能都为 nil")
+	}
+	if ot == nil {
+		ot, ov = nt, reflect.Zero(nt)
+	}
+	if nt == nil {
+		nt, nv = ot, reflect.Zero(ot)
+	}
+	d := differ{}
+	if err := d.structs("", ov, nv, 0); err != nil {
+		return nil, err
+	}
+	return d.changes, nil
+}
+
+// diffRoot 取得根结构体（nil 返回空类型）
+func diffRoot(v any) (reflect.Value, reflect.Type, error) {
+	if v == nil {
+		return reflect.Value{}, nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			t := rv.Type()
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			if t.Kind() != reflect.Struct {
+				return reflect.Value{}, nil, fmt.Errorf("diff: 不支持 %T，需要结构体或结构体指针", v)
+			}
+			return reflect.Value{}, nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("diff: 不支持 %T，需要结构体或结构体指针", v)
+	}
+	return rv, rv.Type(), nil
+}
+
+// diffField 比较计划中的一个字段
+type diffField struct {
+	name      string
+	oldIndex  []int
+	newIndex  []int
+	sensitive bool
+	key       string // 切片按该 JSON 字段对齐（空为按下标）
+}
+
+type diffPlanKey struct{ old, new reflect.Type }
+
+var diffPlans sync.Map // diffPlanKey -> []diffField
+
+// diffTaggedField 结构体中参与比较的字段
+type diffTaggedField struct {
+	index     []int
+	sensitive bool
+	key       string
+}
+
+// planFor 返回一对结构体类型的比较计划（缓存）
+func planFor(ot, nt reflect.Type) []diffField {
+	k := diffPlanKey{ot, nt}
+	if p, ok := diffPlans.Load(k); ok {
+		return p.([]diffField)
+	}
+	oldFields, order := diffFields(ot)
+	newFields, _ := diffFields(nt)
+	if ot == nt {
+		newFields = oldFields
+	}
+	plan := make([]diffField, 0, len(order))
+	for _, name := range order {
+		of := oldFields[name]
+		nf, ok := newFields[name]
+		if !ok {
+			continue
+		}
+		plan = append(plan, diffField{
+			name:      name,
+			oldIndex:  of.index,
+			newIndex:  nf.index,
+			sensitive: of.sensitive || nf.sensitive,
+			key:       of.key,
+		})
+	}
+	p, _ := diffPlans.LoadOrStore(k, plan)
+	return p.([]diffField)
+}
+
+var (
+	encryptedStringType  = reflect.TypeOf(fieldcrypt.EncryptedString(""))
+	searchableStringType = reflect.TypeOf(fieldcrypt.SearchableString(""))
+)
+
+// diffFields 解析结构体字段（含嵌入结构体提升的字段），返回 JSON 名 -> 字段及字段顺序
+func diffFields(t reflect.Type) (map[string]diffTaggedField, []string) {
+	fields := make(map[string]diffTaggedField)
+	var order []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if jsonName == "-" || f.Tag.Get("diff") == "-" {
+			continue
+		}
+		if f.Anonymous && jsonName == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			continue // 提升的字段已单独列出
+		}
+		name := jsonName
+		if name == "" {
+			name = f.Name
+		}
+		if _, dup := fields[name]; dup {
+			continue // 外层字段优先（与 encoding/json 一致）
+		}
+		ft := indirectType(f.Type)
+		tf := diffTaggedField{
+			index:     f.Index,
+			sensitive: f.Tag.Get("sensitive") == "true" || ft == encryptedStringType || ft == searchableStringType,
+		}
+		if key, ok := strings.CutPrefix(f.Tag.Get("diff"), "key="); ok {
+			tf.key = key
+		}
+		fields[name] = tf
+		order = append(order, name)
+	}
+	return fields, order
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+type differ struct {
+	changes []FieldChange
+}
+
+func (d *differ) add(path string, old, new reflect.Value, sensitive bool) {
+	if sensitive {
+		d.changes = append(d.changes, FieldChange{Path: path, Sensitive: true})
+		return
+	}
+	d.changes = append(d.changes, FieldChange{Path: path, Old: diffValue(old), New: diffValue(new)})
+}
+
+// diffValue 记录到变更中的值（nil 指针与不存在记为 nil）
+func diffValue(v reflect.Value) any {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// indirect 解开指针和接口（nil 返回无效值）
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// structs 按比较计划逐字段比较
+func (d *differ) structs(path string, ov, nv reflect.Value, depth int) error {
+	if depth > maxDiffDepth {
+		return fmt.Errorf("diff: %s 嵌套超过 %d 层（可能存在循环引用）", path, maxDiffDepth)
+	}
+	for _, f := range planFor(ov.Type(), nv.Type()) {
+		of, _ := ov.FieldByIndexErr(f.oldIndex)
+		nf, _ := nv.FieldByIndexErr(f.newIndex)
+		p := joinPath(path, f.name)
+		if f.sensitive {
+			if !d.equal(of, nf) {
+				d.add(p, of, nf, true)
+			}
+			continue
+		}
+		if err := d.value(p, of, nf, f.key, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value 比较任意值：结构体递归、切片按下标或键、map 按键，其余按值
+func (d *differ) value(path string, ov, nv reflect.Value, key string, depth int) error {
+	ov, nv = indirect(ov), indirect(nv)
+	if !ov.IsValid() && !nv.IsValid() {
+		return nil
+	}
+	// 一侧为 nil 的结构体按零值展开，记录各字段
+	if !ov.IsValid() && nv.Kind() == reflect.Struct && !hasEqual(nv.Type()) {
+		ov = reflect.Zero(nv.Type())
+	}
+	if !nv.IsValid() && ov.Kind() == reflect.Struct && !hasEqual(ov.Type()) {
+		nv = reflect.Zero(ov.Type())
+	}
+	if !ov.IsValid() || !nv.IsValid() {
+		d.add(path, ov, nv, false)
+		return nil
+	}
+
+	switch {
+	case ov.Kind() == reflect.Struct && nv.Kind() == reflect.Struct && !hasEqual(ov.Type()) && !hasEqual(nv.Type()) &&
+		len(planFor(ov.Type(), nv.Type())) > 0:
+		return d.structs(path, ov, nv, depth)
+	case isList(ov) && isList(nv) && !isBytes(ov):
+		if key != "" {
+			return d.keyedSlices(path, ov, nv, key, depth)
+		}
+		return d.indexedSlices(path, ov, nv, depth)
+	case ov.Kind() == reflect.Map && nv.Kind() == reflect.Map:
+		return d.maps(path, ov, nv, depth)
+	}
+	if !d.equal(ov, nv) {
+		d.add(path, ov, nv, false)
+	}
+	return nil
+}
+
+func isList(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
+
+func isBytes(v reflect.Value) bool {
+	return v.Type().Elem().Kind() == reflect.Uint8
+}
+
+// indexedSlices 按下标对齐：调整顺序会记录为各下标的内容变更
+func (d *differ) indexedSlices(path string, ov, nv reflect.Value, depth int) error {
+	n := max(ov.Len(), nv.Len())
+	for i := 0; i < n; i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= ov.Len():
+			d.add(p, reflect.Value{}, nv.Index(i), false)
+			continue
+		case i >= nv.Len():
+			d.add(p, ov.Index(i), reflect.Value{}, false)
+			continue
+		}
+		if err := d.value(p, ov.Index(i), nv.Index(i), "", depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyedSlices 按元素的键字段对齐：只调整顺序不算变更，新增/删除记录整个元素
+func (d *differ) keyedSlices(path string, ov, nv reflect.Value, key string, depth int) error {
+	oldKeys, oldByKey, err := indexByKey(path, ov, key)
+	if err != nil {
+		return err
+	}
+	newKeys, newByKey, err := indexByKey(path, nv, key)
+	if err != nil {
+		return err
+	}
+	for _, k := range oldKeys {
+		p := path + "[" + key + "=" + k + "]"
+		ne, ok := newByKey[k]
+		if !ok {
+			d.add(p, oldByKey[k], reflect.Value{}, false)
+			continue
+		}
+		if err := d.value(p, oldByKey[k], ne, "", depth+1); err != nil {
+			return err
+		}
+	}
+	for _, k := range newKeys {
+		if _, ok := oldByKey[k]; !ok {
+			d.add(path+"["+key+"="+k+"]", reflect.Value{}, newByKey[k], false)
+		}
+	}
+	return nil
+}
+
+// indexByKey 按键字段索引切片元素（元素须为结构体，键重复时报错）
+func indexByKey(path string, list reflect.Value, key string) ([]string, map[string]reflect.Value, error) {
+	keys := make([]string, 0, list.Len())
+	byKey := make(map[string]reflect.Value, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		elem := indirect(list.Index(i))
+		if !elem.IsValid() {
+			continue
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil, nil, fmt.Errorf("diff: %s 按键 %q 比较，但元素类型 %s 不是结构体", path, key, elem.Type())
+		}
+		fields, _ := diffFields(elem.Type())
+		f, ok := fields[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("diff: %s 的元素类型 %s 没有字段 %q", path, elem.Type(), key)
+		}
+		kv, err := elem.FieldByIndexErr(f.index)
+		if err != nil {
+			return nil, nil, fmt.Errorf("diff: %s 读取键 %q 失败: %w", path, key, err)
+		}
+		k := fmt.Sprint(diffValue(kv))
+		if _, dup := byKey[k]; dup {
+			return nil, nil, fmt.Errorf("diff: %s 中 %s=%s 重复", path, key, k)
+		}
+		keys = append(keys, k)
+		byKey[k] = elem
+	}
+	return keys, byKey, nil
+}
+
+// maps 按键比较（键排序后输出，结果稳定）
+func (d *differ) maps(path string, ov, nv reflect.Value, depth int) error {
+	keys := make(map[string]reflect.Value)
+	for _, k := range ov.MapKeys() {
+		keys[fmt.Sprint(k.Interface())] = k
+	}
+	for _, k := range nv.MapKeys() {
+		keys[fmt.Sprint(k.Interface())] = k
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k := keys[name]
+		var oe, ne reflect.Value
+		if k.Type().AssignableTo(ov.Type().Key()) {
+			oe = ov.MapIndex(k)
+		}
+		if k.Type().AssignableTo(nv.Type().Key()) {
+			ne = nv.MapIndex(k)
+		}
+		p := joinPath(path, name)
+		if !oe.IsValid() || !ne.IsValid() {
+			d.add(p, oe, ne, false)
+			continue
+		}
+		if err := d.value(p, oe, ne, "", depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// equal 比较叶子值：基本类型直接比较，实现 Equal 方法的类型用 Equal，其余用 reflect.DeepEqual
+func (d *differ) equal(ov, nv reflect.Value) bool {
+	ov, nv = indirect(ov), indirect(nv)
+	if !ov.IsValid() || !nv.IsValid() {
+		return ov.IsValid() == nv.IsValid()
+	}
+	if ov.Type() != nv.Type() {
+		return reflect.DeepEqual(ov.Interface(), nv.Interface())
+	}
+	switch ov.Kind() {
+	case reflect.String:
+		return ov.String() == nv.String()
+	case reflect.Bool:
+		return ov.Bool() == nv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ov.Int() == nv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ov.Uint() == nv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return ov.Float() == nv.Float()
+	}
+	if m, ok := ov.Type().MethodByName("Equal"); ok && isEqualMethod(m.Type, ov.Type()) {
+		return m.Func.Call([]reflect.Value{ov, nv})[0].Bool()
+	}
+	return reflect.DeepEqual(ov.Interface(), nv.Interface())
+}
+
+// hasEqual 类型是否有 func (T) Equal(T) bool 方法（如 time.Time），有则作为整体比较
+func hasEqual(t reflect.Type) bool {
+	m, ok := t.MethodByName("Equal")
+	return ok && isEqualMethod(m.Type, t)
+}
+
+func isEqualMethod(mt, t reflect.Type) bool {
+	return mt.NumIn() == 2 && mt.In(1) == t && mt.NumOut() == 1 && mt.Out(0).Kind() == reflect.Bool
+}
@@ -0,0 +1,271 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/fieldcrypt"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type diffAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+type diffItem struct {
+	ID  int    `json:"id"`
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type diffAudited struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type diffOrder struct {
+	diffAudited
+	ID       int                        `json:"id"`
+	Status   string                     `json:"status"`
+	Note     *string                    `json:"note,omitempty"`
+	Address  diffAddress                `json:"address"`
+	Billing  *diffAddress               `json:"billing"`
+	Items    []diffItem                 `json:"items" diff:"key=id"`
+	Tags     []string                   `json:"tags"`
+	Attrs    map[string]string          `json:"attrs"`
+	Phone    string                     `json:"phone" sensitive:"true"`
+	Card     diffAddress                `json:"card" sensitive:"true"`
+	IDCard   fieldcrypt.EncryptedString `json:"idCard"`
+	Version  int                        `json:"version" diff:"-"`
+	Internal string                     `json:"-"`
+	secret   string
+}
+
+func strPtr(s string) *string { return &s }
+
+func changePaths(changes []FieldChange) []string {
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	return paths
+}
+
+func TestDiff_Nested(t *testing.T) {
+	ts := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	old := diffOrder{
+		diffAudited: diffAudited{UpdatedAt: ts},
+		ID:          7,
+		Status:      "pending",
+		Address:     diffAddress{City: "上海", Street: "南京西路"},
+		Attrs:       map[string]string{"color": "red", "size": "L"},
+		Version:     1,
+		Internal:    "a",
+		secret:      "a",
+	}
+	updated := old
+	updated.UpdatedAt = ts.In(time.FixedZone("CST", 8*3600)) // 同一时刻，不算变更
+	updated.Status = "paid"
+	updated.Note = strPtr("加急")
+	updated.Address.City = "杭州"
+	updated.Billing = &diffAddress{City: "杭州"}
+	updated.Attrs = map[string]string{"color": "blue", "weight": "2kg"}
+	updated.Version = 2
+	updated.Internal = "b"
+	updated.secret = "b"
+
+	changes, err := Diff(old, &updated)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "status", Old: "pending", New: "paid"},
+		{Path: "note", Old: nil, New: "加急"},
+		{Path: "address.city", Old: "上海", New: "杭州"},
+		{Path: "billing.city", Old: "", New: "杭州"},
+		{Path: "attrs.color", Old: "red", New: "blue"},
+		{Path: "attrs.size", Old: "L", New: nil},
+		{Path: "attrs.weight", Old: nil, New: "2kg"},
+	}, changes)
+	assert.Equal(t, "status: pending → paid", changes[0].String())
+
+	changes, err = Diff(old, old)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	updated.UpdatedAt = ts.Add(time.Minute)
+	changes, _ = Diff(old, updated)
+	assert.Contains(t, changePaths(changes), "updatedAt", "嵌入结构体的字段提升到顶层")
+}
+
+func TestDiff_Slices(t *testing.T) {
+	old := diffOrder{
+		Items: []diffItem{{ID: 1, SKU: "A", Qty: 1}, {ID: 2, SKU: "B", Qty: 2}, {ID: 3, SKU: "C", Qty: 3}},
+		Tags:  []string{"new", "vip"},
+	}
+
+	// 按键对齐：只调整顺序不算变更；按下标：调整顺序记为各下标的变更
+	reordered := old
+	reordered.Items = []diffItem{old.Items[2], old.Items[0], old.Items[1]}
+	reordered.Tags = []string{"vip", "new"}
+	changes, err := Diff(old, reordered)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "tags[0]", Old: "new", New: "vip"},
+		{Path: "tags[1]", Old: "vip", New: "new"},
+	}, changes)
+
+	// 内容变更、删除、新增（同时调整顺序）
+	edited := old
+	edited.Items = []diffItem{{ID: 3, SKU: "C", Qty: 5}, {ID: 1, SKU: "A", Qty: 1}, {ID: 4, SKU: "D", Qty: 1}}
+	edited.Tags = []string{"new"}
+	changes, err = Diff(old, edited)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "items[id=2]", Old: diffItem{ID: 2, SKU: "B", Qty: 2}, New: nil},
+		{Path: "items[id=3].qty", Old: 3, New: 5},
+		{Path: "items[id=4]", Old: nil, New: diffItem{ID: 4, SKU: "D", Qty: 1}},
+		{Path: "tags[1]", Old: "vip", New: nil},
+	}, changes)
+
+	dup := old
+	dup.Items = []diffItem{{ID: 1}, {ID: 1}}
+	_, err = Diff(old, dup)
+	assert.ErrorContains(t, err, "重复")
+
+	type badKey struct {
+		Items []diffItem `json:"items" diff:"key=missing"`
+	}
+	_, err = Diff(badKey{Items: []diffItem{{ID: 1}}}, badKey{})
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestDiff_Sensitive(t *testing.T) {
+	old := diffOrder{Phone: "13812345678", Card: diffAddress{City: "上海"}, IDCard: "310101199001011234"}
+	updated := old
+	updated.Phone = "13900000000"
+	updated.Card.Street = "淮海路"
+	updated.IDCard = "310101199001019999"
+
+	changes, err := Diff(old, updated)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "phone", Sensitive: true},
+		{Path: "card", Sensitive: true},
+		{Path: "idCard", Sensitive: true},
+	}, changes)
+	data, _ := json.Marshal(changes)
+	for _, leaked := range []string{"1381234", "13900000000", "淮海路", "310101"} {
+		assert.NotContains(t, string(data), leaked)
+	}
+	assert.Equal(t, "phone: (已修改)", changes[0].String())
+
+	changes, _ = Diff(old, old)
+	assert.Empty(t, changes, "未修改的敏感字段不记录")
+}
+
+func TestDiff_TypesAndErrors(t *testing.T) {
+	// 不同类型按 JSON 名对齐，只比较共有字段
+	type orderRow struct {
+		Status    string `json:"status"`
+		CreatedBy string `json:"createdBy"`
+	}
+	type orderPatch struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	changes, err := Diff(orderRow{Status: "pending", CreatedBy: "u1"}, orderPatch{Status: "cancelled", Reason: "x"})
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{{Path: "status", Old: "pending", New: "cancelled"}}, changes)
+
+	// 创建：old 为 nil 时记录所有非零字段
+	changes, err = Diff((*orderRow)(nil), &orderRow{Status: "pending"})
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{{Path: "status", Old: "", New: "pending"}}, changes)
+
+	_, err = Diff(1, 2)
+	assert.Error(t, err)
+	_, err = Diff(nil, nil)
+	assert.Error(t, err)
+
+	// 循环引用
+	type node struct {
+		Name string `json:"name"`
+		Next *node  `json:"next"`
+	}
+	a := &node{Name: "a"}
+	a.Next = a
+	b := &node{Name: "b"}
+	b.Next = b
+	_, err = Diff(a, b)
+	assert.ErrorContains(t, err, "嵌套超过")
+}
+
+func TestRecordChanges(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "changes-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	var published []ChangeEvent
+	SetChangePublisher(func(ctx context.Context, e ChangeEvent) error {
+		published = append(published, e)
+		return errors.New("outbox unavailable")
+	})
+	t.Cleanup(func() {
+		audit.SetSink(nil)
+		SetChangePublisher(nil)
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.PUT("/orders/:id", jwt.Middleware(), func(ctx context.Context, c *app.RequestContext) {
+		old := diffOrder{Status: "pending", Phone: "13812345678"}
+		updated := diffOrder{Status: "paid", Phone: "13900000000"}
+		changes, err := Diff(old, updated)
+		require.NoError(t, err)
+		RecordChanges(c, "order", c.Param("id"), changes)
+		RecordChanges(c, "order", c.Param("id"), nil)
+		c.String(200, "ok")
+	})
+	w := ut.PerformRequest(engine, "PUT", "/orders/42", nil, ownershipToken(t, "admin7", "admin"))
+	require.Equal(t, 200, w.Code, "发布失败不影响响应")
+
+	require.Equal(t, []string{"entity.change"}, rec.types())
+	event := rec.events[0]
+	assert.Equal(t, "admin7", event.Actor)
+	assert.Equal(t, "/orders/42", event.Path)
+	assert.Equal(t, "order", event.Data["entity"])
+	assert.Equal(t, "42", event.Data["entityId"])
+	assert.Equal(t, []FieldChange{
+		{Path: "status", Old: "pending", New: "paid"},
+		{Path: "phone", Sensitive: true},
+	}, event.Data["changes"])
+
+	require.Len(t, published, 1)
+	assert.Equal(t, "order", published[0].Entity)
+	assert.Equal(t, "42", published[0].EntityID)
+	assert.Equal(t, "admin7", published[0].Actor)
+	assert.Len(t, published[0].Changes, 2)
+}
+
+func BenchmarkDiff(b *testing.B) {
+	old := diffOrder{Status: "pending", Address: diffAddress{City: "上海"},
+		Items: []diffItem{{ID: 1, Qty: 1}, {ID: 2, Qty: 2}}, Tags: []string{"a", "b"}}
+	updated := old
+	updated.Status = "paid"
+	updated.Items = []diffItem{{ID: 2, Qty: 3}, {ID: 1, Qty: 1}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Diff(old, updated); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
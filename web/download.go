@@ -1,19 +1,23 @@
 package web
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/CenJIl/base/web/middleware"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 )
 
 // DownloadFile 流式下载文件
 //
-// 自动检查文件是否存在，设置 Content-Disposition 头
+// 自动检查文件是否存在，设置 Content-Disposition 头；
+// 开启 checksum.downloadHeader 且记录了该路径的摘要时输出 ETag / Content-Digest
 //
 // 使用方式：
 //
@@ -33,9 +37,10 @@ func DownloadFile(c *app.RequestContext, filePath string, filename string) {
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 	c.Header("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
 	c.Header("Content-Transfer-Encoding", "binary")
+	SetDigestHeaders(c, DigestsFor(context.Background(), filePath), false)
 
-	// 设置文件下载
-	c.File(filePath)
+	// 设置文件下载（按带宽配置限速）
+	serveFileSection(c, filePath, 0, fileInfo.Size())
 }
 
 // DownloadWithRange 断点续传下载
@@ -66,8 +71,9 @@ func DownloadWithRange(c *app.RequestContext, filePath string, filename string)
 		c.Header("Content-Length", strconv.FormatInt(fileSize, 10))
 		c.Header("Accept-Ranges", "bytes")
 		c.Header("Content-Transfer-Encoding", "binary")
+		SetDigestHeaders(c, DigestsFor(context.Background(), filePath), false)
 
-		c.File(filePath)
+		serveFileSection(c, filePath, 0, fileSize)
 		return
 	}
 
@@ -112,10 +118,32 @@ func DownloadWithRange(c *app.RequestContext, filePath string, filename string)
 	c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
 	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
 	c.Header("Accept-Ranges", "bytes")
+	SetDigestHeaders(c, DigestsFor(context.Background(), filePath), true)
 
-	// TODO: Hertz 暂不支持 Range 文件下载，需要手动实现
-	// 先使用普通下载
-	c.File(filePath)
+	// 只发送请求的区间，限速按实际发送的字节计算
+	serveFileSection(c, filePath, start, contentLength)
+}
+
+// readCloser 组合 Reader 与底层文件的 Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// serveFileSection 以流的方式发送文件的 [offset, offset+length) 区间
+//
+// 响应体由 Hertz 在写出后关闭；客户端断开时（ClientAbortMiddleware 监听的上下文取消）读取立即停止
+func serveFileSection(c *app.RequestContext, filePath string, offset, length int64) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		panic(InternalHTTP("读取文件失败"))
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		panic(InternalHTTP("读取文件失败"))
+	}
+	body := throttleDownload(middleware.ClientContext(c), c, readCloser{Reader: io.LimitReader(f, length), Closer: f})
+	c.SetBodyStream(body, int(length))
 }
 
 // FileExists 检查文件是否存在
@@ -6,12 +6,18 @@ import (
 	"time"
 
 	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common"
 	"github.com/CenJIl/base/logger"
 	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/client"
 	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/fieldcrypt"
+	"github.com/CenJIl/base/web/mask"
+	"github.com/CenJIl/base/web/metrics"
 	"github.com/CenJIl/base/web/middleware"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	corsMiddleware "github.com/hertz-contrib/cors"
@@ -20,11 +26,14 @@ import (
 	_ "github.com/hertz-contrib/swagger"
 )
 
+// log web 模块的日志记录器
+var log = logger.Named("web")
+
 // NewServer 创建 Hertz 服务器
 //
 // 配置完全由用户通过配置文件控制，此函数负责：
 // 1. 读取用户配置（从 app.toml 或指定路径）
-// 2. 初始化已启用的子模块（DB/Redis/i18n）
+// 2. 启动内置组件（DB/Redis，见 RegisterComponent）
 // 3. 集成官方中间件（CORS/JWT/Swagger/i18n）
 // 4. 返回可用的服务器实例
 //
@@ -32,7 +41,7 @@ import (
 //
 // # Parameters
 //
-//	configPath - 配置文件路径，可选。默认为 "app.toml"
+//	configPath - 配置文件路径，可选。默认为 "app.toml"；传入多个路径时按顺序合并（见 cfg.LoadConfigs）
 //
 // 注意：
 //   - 配置文件中为零值的字段会被跳过（如 Port = 0 会导致 panic）
@@ -49,6 +58,9 @@ import (
 //
 //	// 有参数 - 读取自定义路径
 //	h := web.NewServer[AppConfig]("config/app.toml")
+//
+//	// 多个文件 - 后面的文件覆盖前面的键
+//	h := web.NewServer[AppConfig]("app.toml", "app.local.toml")
 func NewServer[T any](configPath ...string) *server.Hertz {
 	// 确定配置文件路径
 	configFile := "app.toml"
@@ -56,23 +68,31 @@ func NewServer[T any](configPath ...string) *server.Hertz {
 		configFile = configPath[0]
 	}
 
-	// 加载配置
-	if err := cfg.LoadConfig[T](configFile); err != nil {
+	// 配置管理器的日志（热更新失败等）带上模块名；需要其他记录器时在 NewServer 之后调用 cfg.SetLogger
+	cfg.SetLogger(logger.Named("cfg"))
+
+	// 加载并校验配置（如 web.port 的 validate 标签，不通过时列出全部错误）
+	var err error
+	if len(configPath) > 1 {
+		err = cfg.LoadConfigs[T](configPath...)
+	} else {
+		err = cfg.LoadConfig[T](configFile)
+	}
+	if err != nil {
 		panic(fmt.Errorf("配置加载失败: %w", err))
 	}
 
 	// Load config from cfg package
-	userCfg := cfg.GetCfg[T]()
-	if userCfg == nil {
-		panic("配置未初始化，请先调用 cfg.LoadConfig 或使用 NewServer 的自动加载功能")
-	}
+	userCfg := cfg.MustGetCfg[T]()
 
 	// Extract web config from embedded Config field
 	webCfg := extractWebConfig(*userCfg)
 
-	// 验证必要配置
-	if webCfg.Port == 0 {
-		panic("配置错误: web.port 不能为 0 或空，请在 config.toml 中设置 [web] port = 8080")
+	// 日志文件输出（零值保持默认：控制台，Windows 服务时同时输出到 logs/app.log）
+	if webCfg.Log != (LogConfig{}) {
+		if err := logger.Init(webCfg.Log); err != nil {
+			panic(fmt.Errorf("日志输出初始化失败: %w", err))
+		}
 	}
 
 	// Apply log level
@@ -80,59 +100,186 @@ func NewServer[T any](configPath ...string) *server.Hertz {
 		logger.UpdateLogLevel(webCfg.LogLevel)
 	}
 
-	// Initialize database (如果配置了 driver)
-	if webCfg.Database.Driver != "" {
-		if err := database.InitDB(webCfg.Database); err != nil {
-			panic(fmt.Errorf("数据库初始化失败: %w", err))
-		}
-		logger.Infof("[DB] 已连接: %s@%s:%d/%s",
-			webCfg.Database.User, webCfg.Database.Host,
-			webCfg.Database.Port, webCfg.Database.DBName)
-	} else {
-		logger.Info("[DB] 未配置 (database.driver 为空)")
+	// 错误指纹统计（定期输出 Top N 汇总日志，告警钩子见 logger.OnErrorAlert）
+	logger.InitErrorTracking(webCfg.Errors)
+
+	// 诊断包限制与内存中保留的最近日志条数（管理接口见 DiagnosticsBundleHandler）
+	InitDiagnostics(webCfg.Diagnostics)
+
+	// 启动内置组件（数据库、Redis），应用组件在 MustRun 中按依赖顺序启动
+	defaultApp.config.Store(&webCfg)
+	registerBuiltinComponents(defaultApp)
+	startComponents()
+	// 预热完成，开始慢启动爬坡
+	beginSlowStart()
+
+	// 采集缓冲（drainer 作为应用组件在 MustRun 中启动，此时 sink 已经注册）
+	ingester, err := InitIngest(webCfg.Ingest)
+	if err != nil {
+		panic(fmt.Errorf("采集缓冲初始化失败: %w", err))
+	}
+	if ingester != nil {
+		RegisterComponent(ingester.Component(ComponentDatabase, ComponentRedis))
+	}
+
+	// Initialize upload scanner（未配置 clamavAddress 时不扫描）
+	InitScanner(webCfg.Upload.Scan)
+	InitChecksums(webCfg.Upload.Checksum)
+
+	// Initialize shared HTTP client transport（连接池 + DNS 缓存）
+	client.InitTransport(webCfg.Client)
+
+	// 出站调用限速（shared = true 时通过 Redis 在实例之间共享，需在 Redis 启动之后）
+	InitOutbound(webCfg.Outbound)
+
+	// 命名密钥环（JWT、分页游标、服务间签名等按名称引用；配置了密钥时启用，需在 Redis 启动之后）
+	kr, err := InitKeyring[T](webCfg.Keyring)
+	if err != nil {
+		panic(fmt.Errorf("密钥环配置错误: %w", err))
 	}
 
-	// Initialize Redis (如果配置了 address)
-	if webCfg.Redis.Address != "" {
-		if err := cache.InitRedis(webCfg.Redis); err != nil {
-			panic(fmt.Errorf("Redis 初始化失败: %w", err))
+	// 字段加密密钥环（配置了密钥时启用）与脱敏角色
+	if len(webCfg.FieldCrypt.Keys) > 0 {
+		if err := fieldcrypt.Init(webCfg.FieldCrypt); err != nil {
+			panic(fmt.Errorf("字段加密配置错误: %w", err))
 		}
-		logger.Infof("[Redis] 已连接: %s", webCfg.Redis.Address)
-	} else {
-		logger.Info("[Redis] 未配置 (redis.address 为空)")
 	}
+	mask.Init(webCfg.Mask)
+
+	// 分页游标签名密钥（未配置时随机生成，只适合单实例）
+	if err := InitCursor(webCfg.Cursor); err != nil {
+		panic(fmt.Errorf("分页游标配置错误: %w", err))
+	}
+
+	// Initialize ownership guards（非所有者默认响应 404）
+	InitOwnership(webCfg.Ownership)
+
+	// Initialize bandwidth limits（速率为 0 时不限速）
+	InitBandwidth(webCfg.Bandwidth)
 
 	// Create Hertz server
-	h := server.Default(
+	opts := []config.Option{
 		server.WithHostPorts(fmt.Sprintf(":%d", webCfg.Port)),
-		server.WithReadTimeout(15*time.Second),
-		server.WithWriteTimeout(15*time.Second),
-		server.WithIdleTimeout(60*time.Second),
-	)
+		server.WithReadTimeout(15 * time.Second),
+		server.WithWriteTimeout(15 * time.Second),
+		server.WithIdleTimeout(60 * time.Second),
+		server.WithHandleMethodNotAllowed(true),
+		// 客户端断开时取消请求上下文（ClientAbortMiddleware 据此识别 499）
+		server.WithSenseClientDisconnection(true),
+		// 响应写出之后执行 OnRequestDone 回调（访问日志、审计、账本日志）
+		server.WithTracer(RequestDoneTracer()),
+	}
+	if webCfg.Path.Canonicalize {
+		// 结尾斜杠交给规范化中间件统一处理
+		opts = append(opts, server.WithRedirectTrailingSlash(false))
+	}
+	h := server.Default(opts...)
+	// 客户端 IP：只信任来自可信代理的转发头（未配置时保持 Hertz 默认行为）
+	if f, err := clientIPFunc(webCfg.Proxy); err != nil {
+		panic(fmt.Errorf("代理配置错误: %w", err))
+	} else if f != nil {
+		h.SetClientIPFunc(f)
+	}
+	// 优雅关闭时按依赖逆序停止组件
+	h.OnShutdown = append(h.OnShutdown, stopComponents)
+
+	// 密钥环跟随配置热更新，并接收其他实例的轮换通知
+	if kr != nil {
+		cfg.OnConfigChange(func(newCfg *T) {
+			if err := kr.Reload(extractWebConfig(*newCfg).Keyring); err != nil {
+				log.Errorf("[Keyring] 配置热更新失败: %v", err)
+			}
+		})
+		listenCtx, stopListen := context.WithCancel(context.Background())
+		go func() {
+			if err := kr.Listen(listenCtx); err != nil {
+				log.Errorf("[Keyring] 轮换通知订阅失败: %v", err)
+			}
+		}()
+		h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) { stopListen() })
+	}
 
 	// ========== 注册全局中间件（按顺序） ==========
 
-	// 1. 请求 ID 中间件（最外层，先生成）
+	// 0. 路径规范化（必须最先执行，rewrite 模式会重新路由）
+	if webCfg.Path.Canonicalize {
+		pathCfg := webCfg.Path
+		if webCfg.Upload.URLPrefix != "" {
+			pathCfg.ExcludePrefixes = append(pathCfg.ExcludePrefixes, webCfg.Upload.URLPrefix)
+		}
+		h.Use(PathCanonicalMiddleware(pathCfg, h.Engine))
+		log.Infof("[Path] 路径规范化已启用 (mode: %s)", pathCfg.Mode)
+	}
+
+	// 0.1 客户端中途断开检测（日志、指标记为 499，不计入错误；事务按 onClientAbort 处理）
+	h.Use(middleware.ClientAbortMiddleware())
+
+	// 0.2 响应头预算（最外层：检查其他中间件追加的全部响应头，超出时删除可丢弃的头或响应 500）
+	h.Use(HeaderBudgetMiddleware(webCfg.HeaderBudget))
+
+	// 1. 请求 ID 中间件（先生成）
 	h.Use(middleware.RequestIDMiddleware())
 
+	// 1.0 请求体解压（在读取请求体的中间件之前，审计、绑定看到的都是解压后的内容）
+	if webCfg.Decompress.Enabled {
+		h.Use(DecompressMiddleware(webCfg.Decompress))
+		log.Infof("[Decompress] 请求体解压已启用 (maxSize: %s)", webCfg.Decompress.MaxSize)
+	}
+
+	// 1.1 请求资源账本（X-Debug-Cost 调试请求输出 Server-Timing）
+	InitLedger(webCfg.Ledger)
+	h.Use(LedgerMiddleware())
+
 	// 2. 安全头中间件
 	h.Use(middleware.SecurityHeadersMiddleware())
 
+	// 2.1 响应信封内置消息按请求语言翻译（在异常处理之外，panic 生成的响应也会翻译）
+	h.Use(EnvelopeMessageMiddleware())
+
 	// 3. 全局异常处理
 	h.Use(ExceptionHandler())
 
+	// 3.1 过载保护（按路由优先级丢弃请求；新实例爬坡期间同时执行慢启动的并发上限）
+	slowStartCfg := webCfg.SlowStart
+	if slowStartCfg.Capacity == 0 {
+		slowStartCfg.Capacity = webCfg.Shedding.Capacity
+	}
+	InitSlowStart(slowStartCfg, common.SystemClock{})
+	if webCfg.Shedding.Capacity > 0 || webCfg.Shedding.Adaptive.Enabled {
+		InitShedding(webCfg.Shedding)
+		h.Use(SheddingMiddleware())
+		cfg.OnConfigChange(func(newCfg *T) {
+			SetSheddingDisabled(extractWebConfig(*newCfg).Shedding.Disabled)
+		})
+	}
+
+	// 3.2 维护模式（手动开关 + 计划窗口）
+	if err := InitMaintenance(webCfg.Maintenance, common.SystemClock{}); err != nil {
+		panic(fmt.Errorf("维护模式配置错误: %w", err))
+	}
+	h.Use(MaintenanceMiddleware())
+	cfg.OnConfigChange(func(newCfg *T) {
+		SetMaintenanceEnabled(extractWebConfig(*newCfg).Maintenance.Enabled)
+	})
+
 	// 4. 官方 i18n 中间件
 	if webCfg.LocalePath != "" {
 		h.Use(hertzI18n.Localize())
+
+		// 4.0 本地化文件热更新（单个文件解析失败只影响该语言，错误见 /health 与 /debug/locales）
+		if watcher, err := WatchLocales(webCfg.LocalePath); err != nil {
+			log.Warnf("[I18n] 本地化目录监听未启用: %v", err)
+		} else {
+			h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) { _ = watcher.Close() })
+		}
 	}
 
+	// 4.1 格式化用的语言与时区（FormatTime / FormatCurrency 等）
+	InitLocale(webCfg.DefaultLang, webCfg.DefaultTimezone)
+	h.Use(LocaleMiddleware())
+
 	// 5. 官方 CORS 中间件
-	h.Use(corsMiddleware.New(corsMiddleware.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	}))
+	h.Use(corsMiddleware.New(corsConfig(webCfg.CORS)))
 
 	// 6. 官方 JWT 中间件（后续需要配置 skipPaths）
 	// h.Use(jwtMiddleware.HertzJWTMiddleware(...))
@@ -143,15 +290,79 @@ func NewServer[T any](configPath ...string) *server.Hertz {
 	// Register static file serving (如果配置了 upload 路径和 URL 前缀）
 	if webCfg.Upload.UploadPath != "" && webCfg.Upload.URLPrefix != "" {
 		h.Static(webCfg.Upload.URLPrefix, webCfg.Upload.UploadPath)
-		logger.Infof("[Static] %s -> %s", webCfg.Upload.URLPrefix, webCfg.Upload.UploadPath)
+		log.Infof("[Static] %s -> %s", webCfg.Upload.URLPrefix, webCfg.Upload.UploadPath)
+	}
+
+	// 404/405 统一响应
+	h.NoRoute(NotFoundHandler())
+	h.NoMethod(MethodNotAllowedHandler())
+
+	// Metrics endpoint
+	if webCfg.Metrics.Enabled {
+		metricsPath := webCfg.Metrics.Path
+		if metricsPath == "" {
+			metricsPath = "/metrics"
+		}
+		h.GET(metricsPath, Priority(PriorityCritical), metrics.Handler())
 	}
 
+	// Route table endpoint
+	if webCfg.Routes.Debug {
+		h.GET("/debug/routes", Priority(PriorityCritical), DebugRoutesHandler())
+		h.GET("/debug/slo", Priority(PriorityCritical), DebugSLOHandler())
+		h.GET("/debug/errors", Priority(PriorityCritical), DebugErrorsHandler())
+		h.GET("/debug/locales", Priority(PriorityCritical), DebugLocalesHandler())
+	}
+
+	// SLO 阈值热更新（计数和历史保持不变）
+	cfg.OnConfigChange(func(newCfg *T) {
+		sloTracker.SetOverrides(extractWebConfig(*newCfg).SLO.Routes)
+	})
+
+	// 灰度规则（比例、名单、紧急开关）热更新
+	SetCanaryConfig(webCfg.Canary)
+	cfg.OnConfigChange(func(newCfg *T) {
+		SetCanaryConfig(extractWebConfig(*newCfg).Canary)
+	})
+
+	// A/B 实验（开关、权重、winner）热更新
+	SetExperimentConfig(webCfg.Experiment)
+	cfg.OnConfigChange(func(newCfg *T) {
+		SetExperimentConfig(extractWebConfig(*newCfg).Experiment)
+	})
+
+	// 条款确认门禁（版本变更后用户需要重新确认）热更新
+	SetAckConfig(webCfg.Ack)
+	cfg.OnConfigChange(func(newCfg *T) {
+		SetAckConfig(extractWebConfig(*newCfg).Ack)
+	})
+
+	// Readiness endpoint（慢启动期间给出权重提示）
+	h.GET("/ready", Priority(PriorityCritical), ReadyHandler())
+
 	// Health check endpoint
-	h.GET("/health", func(ctx context.Context, c *app.RequestContext) {
+	h.GET("/health", Priority(PriorityCritical), func(ctx context.Context, c *app.RequestContext) {
+		var data any
+		health := utils.H{}
+		if w := MaintenanceSchedule(); w != nil {
+			health["maintenance"] = w
+		}
+		if status := CurrentSlowStart(); status != nil && status.Active {
+			health["slowStart"] = status
+		}
+		if status := CurrentLocaleStatus(); len(status.Errors) > 0 {
+			health["localeErrors"] = status.Errors
+		}
+		if report := CurrentProductionReport(); report != nil && len(report.Overridden) > 0 {
+			health["insecureOverrides"] = report.Overridden
+		}
+		if len(health) > 0 {
+			data = health
+		}
 		c.JSON(consts.StatusOK, utils.H{
 			"code":    0,
 			"message": "success",
-			"data":    nil,
+			"data":    data,
 		})
 	})
 
@@ -167,17 +378,30 @@ func NewServer[T any](configPath ...string) *server.Hertz {
 //	h := web.NewServer[AppConfig]()
 //	web.MustRun[AppConfig](h)
 func MustRun[T any](h *server.Hertz) {
-	userCfg := cfg.GetCfg[T]()
-	if userCfg == nil {
-		panic("配置未初始化，请先调用 web.NewServer[AppConfig]()")
+	userCfg, err := cfg.GetCfgE[T]()
+	if err != nil {
+		panic(fmt.Errorf("%w（请先调用 web.NewServer[AppConfig]()）", err))
 	}
 
 	webCfg := extractWebConfig(*userCfg)
 	addr := fmt.Sprintf(":%d", webCfg.Port)
 
-	logger.Infof("[HTTP] 服务监听: %s", addr)
+	// 监听前校验路由表
+	mustValidateRoutes(h.Engine, webCfg.Routes)
+
+	// 生产环境下检查已知的不安全默认配置，存在未豁免的问题时中止启动
+	var routes []RouteInfo
+	if r := validatedRoutes.Load(); r != nil {
+		routes = *r
+	}
+	mustPassProductionChecks(ProductionEnv{Config: webCfg, AppConfig: userCfg, Routes: routes})
+
+	// 按依赖顺序启动应用注册的组件
+	startComponents()
+
+	log.Infof("[HTTP] 服务监听: %s", addr)
 	if err := h.Run(); err != nil {
-		logger.Errorf("[HTTP] 启动失败: %v", err)
+		log.Errorf("[HTTP] 启动失败: %v", err)
 		panic(err)
 	}
 }
@@ -190,10 +414,80 @@ func MustRun[T any](h *server.Hertz) {
 //
 //	port := web.GetPort[AppConfig]()
 func GetPort[T any]() int {
-	userCfg := cfg.GetCfg[T]()
-	if userCfg == nil {
+	userCfg, err := cfg.GetCfgE[T]()
+	if err != nil {
 		return 0 // 未初始化返回 0
 	}
 	webCfg := extractWebConfig(*userCfg)
 	return webCfg.Port
 }
+
+// registerBuiltinComponents NewServer 在默认 App 上注册内置组件（未配置的组件启动时只记录日志，保证依赖声明始终有效）
+//
+// 组件在启动时才读取 App 当前的配置：重复调用 NewServer 时按最新的配置启动，不会沿用首次调用的配置
+func registerBuiltinComponents(a *App) {
+	builtins := []Component{
+		a.configuredComponent(ComponentDatabase, func(webCfg Config) Component {
+			return Component{
+				Start: func(ctx context.Context) error {
+					if webCfg.Database.Driver == "" {
+						log.Info("[DB] 未配置 (database.driver 为空)")
+						return nil
+					}
+					if err := database.InitDB(webCfg.Database); err != nil {
+						return fmt.Errorf("数据库初始化失败: %w", err)
+					}
+					log.Infof("[DB] 已连接: %s@%s:%d/%s",
+						webCfg.Database.User, webCfg.Database.Host,
+						webCfg.Database.Port, webCfg.Database.DBName)
+					return nil
+				},
+				Stop: func(ctx context.Context) error { return database.Close() },
+			}
+		}),
+		a.configuredComponent(ComponentRedis, func(webCfg Config) Component {
+			return Component{
+				Start: func(ctx context.Context) error {
+					if webCfg.Redis.Address == "" {
+						log.Info("[Redis] 未配置 (redis.address 为空)")
+						return nil
+					}
+					if err := cache.InitRedis(webCfg.Redis); err != nil {
+						return fmt.Errorf("Redis 初始化失败: %w", err)
+					}
+					log.Infof("[Redis] 已连接: %s", webCfg.Redis.Address)
+					return nil
+				},
+				Stop: func(ctx context.Context) error { return cache.Close() },
+			}
+		}),
+		a.configuredComponent(ComponentSLO, func(webCfg Config) Component { return sloComponent(webCfg.SLO) }),
+		a.configuredComponent(ComponentErrorPages, errorPagesComponent),
+		bandwidthComponent(),
+	}
+	for _, c := range builtins {
+		// 已注册过时保留原注册：组件启动时读取配置，效果相同
+		_ = a.lifecycle.Register(c)
+	}
+}
+
+// configuredComponent 启动时按 App 当前的配置创建组件（停止的是启动时创建的那一个）
+func (a *App) configuredComponent(name string, build func(Config) Component) Component {
+	var started Component
+	return Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			started = build(a.Config())
+			if started.Start == nil {
+				return nil
+			}
+			return started.Start(ctx)
+		},
+		Stop: func(ctx context.Context) error {
+			if started.Stop == nil {
+				return nil
+			}
+			return started.Stop(ctx)
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// DebugErrorsHandler 输出统计窗口内的错误指纹（按次数降序）
+//
+// 使用方式：
+//
+//	h.GET("/debug/errors", web.DebugErrorsHandler())
+func DebugErrorsHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, Success(logger.ErrorFingerprints()))
+	}
+}
+
+// EmailErrorAlert 通过邮件发送错误告警（发送失败记录为 WARN，不会再次触发告警）
+//
+// 使用方式：
+//
+//	logger.OnErrorAlert(web.EmailErrorAlert(email.NewQQMail(from, code), "oncall@example.com"))
+func EmailErrorAlert(mailer SLOMailer, to ...string) logger.ErrorAlertHook {
+	return func(alert logger.ErrorAlert) {
+		fp := alert.Fingerprint
+		subject := fmt.Sprintf("[Errors] 新错误: %s", fp.Format)
+		if alert.Kind == logger.ErrorAlertSpike {
+			subject = fmt.Sprintf("[Errors] 错误激增 (%d 次/%v): %s", fp.Count, alert.Window, fp.Format)
+		}
+		body := fmt.Sprintf("指纹: %s\n位置: %s\n消息: %s\n窗口内次数: %d\n首次出现: %s\n最近出现: %s",
+			fp.ID, fp.Frame, fp.Sample, fp.Count, fp.FirstSeen.Format(time.RFC3339), fp.LastSeen.Format(time.RFC3339))
+		if err := mailer.Send(to, subject, body); err != nil {
+			log.Warnf("[Errors] 告警邮件发送失败: %v", err)
+		}
+	}
+}
+
+// ErrorWebhookPayload 错误告警 Webhook 请求体
+type ErrorWebhookPayload struct {
+	Kind          string `json:"kind"` // new / spike
+	Fingerprint   string `json:"fingerprint"`
+	Format        string `json:"format"`
+	Frame         string `json:"frame"`
+	Sample        string `json:"sample"`
+	Count         int64  `json:"count"`
+	WindowSeconds int64  `json:"windowSeconds"`
+	FirstSeen     int64  `json:"firstSeen"` // Unix 秒
+	LastSeen      int64  `json:"lastSeen"`
+}
+
+// WebhookErrorAlert 通过 Webhook 发送错误告警
+//
+// 使用方式：
+//
+//	logger.OnErrorAlert(web.WebhookErrorAlert("https://hooks.example.com/oncall"))
+func WebhookErrorAlert(url string) logger.ErrorAlertHook {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(alert logger.ErrorAlert) {
+		fp := alert.Fingerprint
+		body, _ := json.Marshal(ErrorWebhookPayload{
+			Kind:          alert.Kind,
+			Fingerprint:   fp.ID,
+			Format:        fp.Format,
+			Frame:         fp.Frame,
+			Sample:        fp.Sample,
+			Count:         fp.Count,
+			WindowSeconds: int64(alert.Window / time.Second),
+			FirstSeen:     fp.FirstSeen.Unix(),
+			LastSeen:      fp.LastSeen.Unix(),
+		})
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Warnf("[Errors] 告警 Webhook 发送失败: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Warnf("[Errors] 告警 Webhook 返回 %d", resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// ComponentErrorPages 浏览器错误页组件名（启动时解析模板）
+const ComponentErrorPages = "errorPages"
+
+// 错误页内置模板中的文案（消息键，可通过 RegisterMessages 翻译）
+const (
+	MsgErrorPageRequestID = "Request ID"
+	MsgErrorPageBack      = "Back"
+)
+
+// ErrorPageConfig 浏览器错误页配置
+//
+// 请求的 Accept 优先 text/html（浏览器直接打开的下载链接、OAuth 回调、SPA 入口等）时，
+// ExceptionHandler / WrapHandler / 404 / 405 的错误响应渲染为 HTML 页面；
+// 偏好 JSON 或没有 Accept 的接口调用方仍然收到统一 JSON 信封
+//
+// Example:
+//
+//	[web.errorPages]
+//	enabled = true                      # 对所有非接口路由启用（否则只有 web.HTMLErrors() 标记的路由）
+//	apiPrefixes = ["/api/", "/admin/"]  # 这些前缀下始终返回 JSON，不论 Accept
+//	templateDir = "templates/errors"    # 自定义模板（404.html、4xx.html、5xx.html、error.html）
+//	backUrl = "/"
+type ErrorPageConfig struct {
+	Enabled     bool     `toml:"enabled"`     // 对所有非接口路由启用；未启用时只有 HTMLErrors() 标记的路由生效
+	APIPrefixes []string `toml:"apiPrefixes"` // 接口路径前缀（始终返回 JSON），默认 ["/api/"]
+	TemplateDir string   `toml:"templateDir"` // 自定义模板目录（代码中通过 SetErrorPageTemplates 指定时以代码为准）
+	BackURL     string   `toml:"backUrl"`     // 错误页的"返回"链接，为空时不显示
+}
+
+// ErrorPageData 错误页模板的数据
+//
+// 模板中可以用 {{.T "Back"}} 按请求语言翻译消息键
+type ErrorPageData struct {
+	Status     int    // HTTP 状态码
+	StatusText string // 状态码的标准文本（Not Found 等）
+	Code       int    // 业务码
+	Message    string // 已翻译的错误消息（生产环境 5xx 不包含内部错误信息）
+	RequestID  string // 请求 ID，供用户反馈问题时提供
+	BackURL    string // "返回"链接，可能为空
+	Lang       string // 请求语言
+
+	app *App
+}
+
+// T 按请求语言翻译消息键（未注册的键原样返回）
+func (d ErrorPageData) T(key string) string {
+	s, _ := d.app.translate(d.Lang, key)
+	return s
+}
+
+// builtinErrorPage 内置错误页（所有状态码共用）
+const builtinErrorPage = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.StatusText}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Roboto,"PingFang SC","Microsoft YaHei",sans-serif;color:#333;background:#f6f7f9;margin:0}
+main{max-width:480px;margin:12vh auto;padding:32px;background:#fff;border-radius:8px;box-shadow:0 1px 3px rgba(0,0,0,.08)}
+h1{font-size:48px;margin:0;color:#999}
+p{line-height:1.6}
+.id{font-size:12px;color:#999;word-break:break-all}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Status}}</h1>
+<p>{{.Message}}</p>
+{{if .RequestID}}<p class="id">{{.T "Request ID"}}: {{.RequestID}}</p>{{end}}
+{{if .BackURL}}<p><a href="{{.BackURL}}">{{.T "Back"}}</a></p>{{end}}
+</main>
+</body>
+</html>
+`
+
+var builtinErrorTemplate = template.Must(template.New("error.html").Parse(builtinErrorPage))
+
+// errorPages 已解析的错误页配置
+type errorPages struct {
+	config     ErrorPageConfig
+	production bool
+	custom     *template.Template // 自定义模板（没有时为 nil）
+}
+
+var (
+	errorPagesState atomic.Pointer[errorPages]
+	errorPageFS     atomic.Pointer[fs.FS]
+)
+
+// htmlErrorsKey 标记路由的错误响应可以渲染为 HTML（见 HTMLErrors）
+const htmlErrorsKey = "web_html_errors"
+
+// SetErrorPageTemplates 指定自定义错误页模板（如 embed.FS，需在 NewServer 之前调用）
+//
+// 模板文件为 fsys 根目录下的 *.html，按 "<状态码>.html" → "<N>xx.html" → "error.html" 的顺序查找，
+// 都没有时使用内置模板。模板在启动时解析，语法错误会使启动失败并记录在启动报告中
+//
+// 使用方式：
+//
+//	//go:embed errors/*.html
+//	var errorTemplates embed.FS
+//
+//	sub, _ := fs.Sub(errorTemplates, "errors")
+//	web.SetErrorPageTemplates(sub)
+func SetErrorPageTemplates(fsys fs.FS) {
+	if fsys == nil {
+		errorPageFS.Store(nil)
+		return
+	}
+	errorPageFS.Store(&fsys)
+}
+
+// InitErrorPages 解析错误页模板并启用（NewServer 在启动组件时调用）
+//
+// production 为 true 时，5xx 错误页只显示内置的通用消息，不显示 handler 返回的错误内容。
+// 解析失败时返回错误，之前的配置保持不变
+func InitErrorPages(config ErrorPageConfig, production bool) error {
+	if len(config.APIPrefixes) == 0 {
+		config.APIPrefixes = []string{"/api/"}
+	}
+	pages := &errorPages{config: config, production: production}
+
+	var fsys fs.FS
+	if p := errorPageFS.Load(); p != nil {
+		fsys = *p
+	} else if config.TemplateDir != "" {
+		if _, err := os.Stat(config.TemplateDir); err != nil {
+			return fmt.Errorf("错误页模板目录不可用: %w", err)
+		}
+		fsys = os.DirFS(config.TemplateDir)
+	}
+	if fsys != nil {
+		t, err := template.New("").ParseFS(fsys, "*.html")
+		if err != nil {
+			return fmt.Errorf("解析错误页模板失败: %w", err)
+		}
+		pages.custom = t
+	}
+	errorPagesState.Store(pages)
+	if config.Enabled || pages.custom != nil {
+		log.Infof("[ErrorPages] 浏览器错误页已启用 (global: %v, apiPrefixes: %v, custom: %v)",
+			config.Enabled, config.APIPrefixes, pages.custom != nil)
+	}
+	return nil
+}
+
+// errorPagesComponent 启动时解析错误页模板的组件
+func errorPagesComponent(config Config) Component {
+	return Component{
+		Name: ComponentErrorPages,
+		Start: func(ctx context.Context) error {
+			return InitErrorPages(config.ErrorPages, IsProduction(config))
+		},
+	}
+}
+
+// HTMLErrors 路由的错误响应在浏览器请求时渲染为 HTML 错误页（[web.errorPages] 未全局启用时使用）
+//
+// 接口路径前缀（apiPrefixes）下的路由始终返回 JSON
+//
+// 使用方式：
+//
+//	h.GET("/files/:id/download", web.HTMLErrors(), web.WrapHandler(downloadFile))
+//	h.GET("/oauth/callback", web.HTMLErrors(), web.WrapHandler(oauthCallback))
+func HTMLErrors() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Set(htmlErrorsKey, true)
+		c.Next(ctx)
+	}
+}
+
+// respondError 写错误响应：请求适用浏览器错误页时渲染 HTML，否则写统一 JSON 信封
+func respondError(c *app.RequestContext, status int, result Result) {
+	if renderErrorPage(c, status, result) {
+		return
+	}
+	c.JSON(status, result)
+}
+
+// renderErrorPage 渲染 HTML 错误页，不适用或渲染失败时返回 false
+func renderErrorPage(c *app.RequestContext, status int, result Result) bool {
+	pages := errorPagesState.Load()
+	if pages == nil || !pages.config.Enabled && !c.GetBool(htmlErrorsKey) {
+		return false
+	}
+	path := string(c.Path())
+	for _, prefix := range pages.config.APIPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	// 同一地址的响应随 Accept 变化，缓存需要区分
+	c.Response.Header.Add("Vary", "Accept")
+	if !prefersHTML(string(c.GetHeader("Accept"))) {
+		return false
+	}
+
+	a := AppOf(c)
+	lang := requestLanguage(c)
+	message := result.Message
+	switch {
+	case a.isRegisteredKey(message):
+		message, _ = a.translate(lang, message)
+	case status >= 500 && pages.production:
+		// 生产环境不向用户展示内部错误信息
+		message, _ = a.translate(lang, MsgInternalError)
+	}
+	data := ErrorPageData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Code:       result.Code,
+		Message:    message,
+		RequestID:  middleware.GetRequestID(c),
+		BackURL:    pages.config.BackURL,
+		Lang:       lang,
+		app:        a,
+	}
+	var buf bytes.Buffer
+	if err := pages.lookup(status).Execute(&buf, data); err != nil {
+		log.Warnf("[ErrorPages] 渲染错误页失败，返回 JSON: %v", err)
+		return false
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+	return true
+}
+
+// lookup 查找状态码对应的模板："<状态码>.html" → "<N>xx.html" → "error.html" → 内置模板
+func (p *errorPages) lookup(status int) *template.Template {
+	if p.custom != nil {
+		for _, name := range []string{strconv.Itoa(status) + ".html", strconv.Itoa(status/100) + "xx.html", "error.html"} {
+			if t := p.custom.Lookup(name); t != nil {
+				return t
+			}
+		}
+	}
+	return builtinErrorTemplate
+}
+
+// prefersHTML Accept 中 text/html 的权重是否高于 application/json（权重相同或没有 Accept 时为 JSON）
+func prefersHTML(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	return acceptQuality(accept, "text", "html") > acceptQuality(accept, "application", "json")
+}
+
+// acceptQuality Accept 中某个媒体类型的权重（按 type/subtype → type/* → */* 的顺序取最具体的匹配）
+func acceptQuality(accept, typ, subtype string) float64 {
+	best, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		t, s, _ := strings.Cut(strings.ToLower(strings.TrimSpace(mediaRange)), "/")
+		level := -1
+		switch {
+		case t == typ && s == subtype:
+			level = 2
+		case t == typ && s == "*":
+			level = 1
+		case t == "*" && s == "*":
+			level = 0
+		}
+		if level < specificity || level < 0 {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		best, specificity = q, level
+	}
+	return best
+}
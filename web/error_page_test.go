@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+
+func useErrorPages(t *testing.T, config ErrorPageConfig, production bool, templates fstest.MapFS) {
+	t.Helper()
+	previous, previousFS := errorPagesState.Load(), errorPageFS.Load()
+	t.Cleanup(func() {
+		errorPagesState.Store(previous)
+		errorPageFS.Store(previousFS)
+	})
+	SetErrorPageTemplates(nil)
+	if templates != nil {
+		SetErrorPageTemplates(templates)
+	}
+	require.NoError(t, InitErrorPages(config, production))
+}
+
+func newErrorPageEngine() *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(middleware.RequestIDMiddleware(), ExceptionHandler())
+	missing := WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return NotFoundHTTP(MsgResourceNotFound)
+	})
+	engine.GET("/files/:id", missing)
+	engine.GET("/download/:id", HTMLErrors(), missing)
+	engine.GET("/api/files/:id", HTMLErrors(), missing)
+	engine.GET("/broken", HTMLErrors(), WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return errors.New("dial tcp 10.0.0.5:5432: password authentication failed for user admin")
+	}))
+	engine.GET("/panic", HTMLErrors(), func(ctx context.Context, c *app.RequestContext) {
+		panic("nil map write in secretHandler")
+	})
+	engine.NoRoute(NotFoundHandler())
+	return engine
+}
+
+func getPage(engine *route.Engine, path, accept string) *ut.ResponseRecorder {
+	return ut.PerformRequest(engine, "GET", path, nil,
+		ut.Header{Key: "Accept", Value: accept}, ut.Header{Key: "Accept-Language", Value: "zh-CN"})
+}
+
+func TestErrorPages_NegotiatesOnAccept(t *testing.T) {
+	useErrorPages(t, ErrorPageConfig{BackURL: "/home"}, false, nil)
+	engine := newErrorPageEngine()
+
+	w := getPage(engine, "/download/1", browserAccept)
+	require.Equal(t, 404, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", string(w.Header().ContentType()))
+	assert.Equal(t, "Accept", string(w.Header().Peek("Vary")))
+	body := w.Body.String()
+	assert.Contains(t, body, "资源不存在", "消息按请求语言翻译")
+	assert.Contains(t, body, "请求编号: "+string(w.Header().Peek("X-Request-ID")))
+	assert.Contains(t, body, `<a href="/home">返回</a>`)
+
+	// 接口调用方（JSON、*/*、没有 Accept）收到的信封不变
+	for _, accept := range []string{"application/json", "*/*", "", "text/html;q=0.5,application/json"} {
+		w = getPage(engine, "/download/1", accept)
+		require.Equal(t, 404, w.Code, accept)
+		var result Result
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), accept)
+		assert.Equal(t, 404, result.Code)
+		assert.Equal(t, MsgResourceNotFound, result.Message)
+	}
+
+	// 未全局启用时，没有 HTMLErrors 的路由和 404 仍是 JSON
+	assert.Contains(t, string(getPage(engine, "/files/1", browserAccept).Header().ContentType()), "application/json")
+	assert.Contains(t, string(getPage(engine, "/nowhere", browserAccept).Header().ContentType()), "application/json")
+
+	useErrorPages(t, ErrorPageConfig{Enabled: true}, false, nil)
+	assert.Contains(t, string(getPage(engine, "/files/1", browserAccept).Header().ContentType()), "text/html")
+	w = getPage(engine, "/nowhere", browserAccept)
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, w.Body.String(), "请求的地址不存在")
+}
+
+func TestErrorPages_APIPrefixAlwaysJSON(t *testing.T) {
+	useErrorPages(t, ErrorPageConfig{Enabled: true}, false, nil)
+	engine := newErrorPageEngine()
+
+	for _, path := range []string{"/api/files/1", "/api/unknown"} {
+		w := getPage(engine, path, browserAccept)
+		assert.Equal(t, 404, w.Code, path)
+		assert.Contains(t, string(w.Header().ContentType()), "application/json", "接口前缀下不论 Accept 都返回 JSON: %s", path)
+	}
+
+	useErrorPages(t, ErrorPageConfig{Enabled: true, APIPrefixes: []string{"/download/"}}, false, nil)
+	assert.Contains(t, string(getPage(engine, "/download/1", browserAccept).Header().ContentType()), "application/json")
+	assert.Contains(t, string(getPage(engine, "/api/files/1", browserAccept).Header().ContentType()), "text/html", "配置后替换默认前缀")
+}
+
+func TestErrorPages_TemplateOverride(t *testing.T) {
+	templates := fstest.MapFS{
+		"404.html": {Data: []byte(`custom-404 {{.Message}} {{.RequestID}}`)},
+		"5xx.html": {Data: []byte(`custom-5xx {{.Status}} {{.T "Back"}}`)},
+	}
+	useErrorPages(t, ErrorPageConfig{}, false, templates)
+	engine := newErrorPageEngine()
+
+	w := getPage(engine, "/download/1", browserAccept)
+	assert.Equal(t, "custom-404 资源不存在 "+string(w.Header().Peek("X-Request-ID")), w.Body.String())
+	w = getPage(engine, "/panic", browserAccept)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, "custom-5xx 500 返回", w.Body.String())
+
+	// 模板错误在启动时报告，之前的配置保持不变
+	broken := fstest.MapFS{"404.html": {Data: []byte(`{{.Message`)}}
+	SetErrorPageTemplates(broken)
+	err := InitErrorPages(ErrorPageConfig{}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404.html")
+	assert.Contains(t, getPage(engine, "/download/1", browserAccept).Body.String(), "custom-404")
+
+	reports, err := func() ([]ComponentReport, error) {
+		l := NewLifecycle()
+		require.NoError(t, l.Register(errorPagesComponent(Config{})))
+		return l.Start(context.Background())
+	}()
+	require.Error(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, ComponentErrorPages, reports[0].Name)
+	assert.ErrorContains(t, reports[0].Err, "解析错误页模板失败")
+}
+
+func TestErrorPages_ProductionHidesInternalDetail(t *testing.T) {
+	useErrorPages(t, ErrorPageConfig{}, true, nil)
+	engine := newErrorPageEngine()
+
+	for _, path := range []string{"/broken", "/panic"} {
+		w := getPage(engine, path, browserAccept)
+		require.Equal(t, 500, w.Code, path)
+		body := w.Body.String()
+		assert.Contains(t, body, "服务器内部错误", path)
+		for _, leak := range []string{"password", "10.0.0.5", "secretHandler", "goroutine", ".go:"} {
+			assert.NotContains(t, body, leak, path)
+		}
+	}
+
+	// 非生产环境显示 handler 返回的错误，便于开发调试（仍然不含堆栈）
+	useErrorPages(t, ErrorPageConfig{}, false, nil)
+	body := getPage(engine, "/broken", browserAccept).Body.String()
+	assert.Contains(t, body, "password authentication failed")
+	assert.NotContains(t, getPage(engine, "/panic", browserAccept).Body.String(), "goroutine")
+}
+
+func TestPrefersHTML(t *testing.T) {
+	assert.True(t, prefersHTML(browserAccept))
+	assert.True(t, prefersHTML("text/*"))
+	assert.True(t, prefersHTML("application/json;q=0.4, text/html"))
+	assert.False(t, prefersHTML("application/json"))
+	assert.False(t, prefersHTML("*/*"))
+	assert.False(t, prefersHTML(""))
+	assert.False(t, prefersHTML("text/html;q=0, */*"))
+}
@@ -10,12 +10,17 @@ const (
 	OK ErrorCode = 0
 
 	// 客户端错误 (4xx) - 1xxxx
-	BadRequest      ErrorCode = 10001 // 参数错误
-	Unauthorized    ErrorCode = 10002 // 未授权
-	Forbidden       ErrorCode = 10003 // 禁止访问
-	NotFound        ErrorCode = 10004 // 资源不存在
-	Conflict        ErrorCode = 10009 // 资源冲突
-	TooManyRequests ErrorCode = 10020 // 请求过多
+	BadRequest       ErrorCode = 10001 // 参数错误
+	Unauthorized     ErrorCode = 10002 // 未授权
+	Forbidden        ErrorCode = 10003 // 禁止访问
+	NotFound         ErrorCode = 10004 // 资源不存在
+	Conflict         ErrorCode = 10009 // 资源冲突
+	VersionConflict  ErrorCode = 10010 // 乐观锁冲突（数据已被修改，重新获取后重试）
+	InvalidCursor    ErrorCode = 10011 // 分页游标无效（被篡改或版本已过期）
+	ValidationFailed ErrorCode = 10012 // 参数校验失败（data.fields 为字段错误列表）
+	TooManyRequests  ErrorCode = 10020 // 请求过多
+	FileInfected     ErrorCode = 10030 // 上传文件含病毒
+	ChecksumMismatch ErrorCode = 10031 // 上传文件与声明的校验和不一致
 
 	// 业务逻辑错误 (2xxxx) - 可自定义
 	UserNotFound ErrorCode = 20001 // 用户不存在
@@ -23,8 +28,9 @@ const (
 	InvalidParam ErrorCode = 20003 // 无效参数
 
 	// 服务端错误 (5xxxx) - 可自定义
-	InternalError ErrorCode = 50001 // 内部错误
-	DatabaseError ErrorCode = 50002 // 数据库错误
+	InternalError   ErrorCode = 50001 // 内部错误
+	DatabaseError   ErrorCode = 50002 // 数据库错误
+	ScanUnavailable ErrorCode = 50003 // 上传文件扫描服务不可用（failClosed 时拒绝上传）
 )
 
 // ToHTTPStatus 转换为 HTTP 状态码
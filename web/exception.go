@@ -15,6 +15,9 @@ func (e *Exception) Error() string {
 	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
 }
 
+// ControlFlowPanic 标记为控制流程 panic（见 common.IsControlFlow）
+func (e *Exception) ControlFlowPanic() {}
+
 // HTTPException HTTP 异常（带有 HTTP 状态码）
 type HTTPException struct {
 	HTTPStatus int    // HTTP 状态码
@@ -27,6 +30,9 @@ func (e *HTTPException) Error() string {
 	return fmt.Sprintf("[HTTP %d] [%d] %s", e.HTTPStatus, e.Code, e.Message)
 }
 
+// ControlFlowPanic 标记为控制流程 panic（见 common.IsControlFlow）
+func (e *HTTPException) ControlFlowPanic() {}
+
 // NewException 创建业务异常
 func NewException(code int, message string) *Exception {
 	return &Exception{Code: code, Message: message}
@@ -0,0 +1,413 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// maxExposureEntries 曝光去重表的容量，超过后清空重新记录（分析侧仍应按用户去重）
+const maxExposureEntries = 100000
+
+// ExperimentConfig A/B 实验配置
+//
+// experiments 支持热更新；也可以通过 ExperimentAdminHandler 在运行时新增或修改（下次配置文件变更时以配置为准）
+//
+// Example:
+//
+//	[web.experiment]
+//	tenantClaim = "tenant"              # token 中记录租户的声明，默认 tenant
+//
+//	[web.experiment.experiments.checkout-flow]
+//	variants = [{ name = "control", weight = 50 }, { name = "one-page", weight = 50 }]
+//	roles = ["user"]                    # 只有这些角色参与（为空不限）
+//	tenants = ["t1", "t2"]              # 只有这些租户参与（为空不限）
+//	start = 2026-03-01T00:00:00+08:00   # 开始前所有人使用对照组
+//	end = 2026-04-01T00:00:00+08:00     # 结束后所有人使用 winner
+//	winner = "one-page"                 # 为空时结束后使用对照组
+//	paused = false                      # 暂停：所有人使用对照组，不记录曝光
+type ExperimentConfig struct {
+	TenantClaim string                `toml:"tenantClaim"`
+	Experiments map[string]Experiment `toml:"experiments"`
+}
+
+// Experiment 单个实验的定义（第一个变体为对照组）
+type Experiment struct {
+	Variants []ExperimentVariant `toml:"variants" json:"variants"`
+	Roles    []string            `toml:"roles" json:"roles"`
+	Tenants  []string            `toml:"tenants" json:"tenants"`
+	Start    time.Time           `toml:"start" json:"start"`
+	End      time.Time           `toml:"end" json:"end"`
+	Winner   string              `toml:"winner" json:"winner"`
+	Paused   bool                `toml:"paused" json:"paused"`
+}
+
+// ExperimentVariant 实验变体与权重（权重为相对值，不要求合计 100）
+type ExperimentVariant struct {
+	Name   string  `toml:"name" json:"name"`
+	Weight float64 `toml:"weight" json:"weight"`
+}
+
+// ExperimentSubject 参与实验的主体
+type ExperimentSubject struct {
+	UserID    string
+	Roles     []string
+	Tenant    string
+	RequestID string
+}
+
+// compiledExperiment 预处理后的实验（热路径只读）
+type compiledExperiment struct {
+	def     Experiment
+	bounds  []uint32 // 各变体哈希桶（0-9999）的上界（不含）
+	roles   map[string]struct{}
+	tenants map[string]struct{}
+}
+
+func compileExperiment(name string, def Experiment) (*compiledExperiment, error) {
+	if len(def.Variants) == 0 {
+		return nil, fmt.Errorf("实验 %s 没有变体", name)
+	}
+	var total float64
+	for _, v := range def.Variants {
+		if v.Name == "" || v.Weight < 0 {
+			return nil, fmt.Errorf("实验 %s 的变体无效: %+v", name, v)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("实验 %s 的权重合计为 0", name)
+	}
+	if def.Winner != "" && !hasVariant(def, def.Winner) {
+		return nil, fmt.Errorf("实验 %s 的 winner %q 不是变体", name, def.Winner)
+	}
+
+	e := &compiledExperiment{def: def, bounds: make([]uint32, len(def.Variants))}
+	var cumulative float64
+	for i, v := range def.Variants {
+		cumulative += v.Weight
+		e.bounds[i] = uint32(cumulative / total * 10000)
+	}
+	e.bounds[len(e.bounds)-1] = 10000
+	if len(def.Roles) > 0 {
+		e.roles = toSet(def.Roles)
+	}
+	if len(def.Tenants) > 0 {
+		e.tenants = toSet(def.Tenants)
+	}
+	return e, nil
+}
+
+func hasVariant(def Experiment, name string) bool {
+	for _, v := range def.Variants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+// resolve 为主体选择变体；enrolled 为 false 表示主体不在实验中（对照组、winner 等），不记录曝光
+func (e *compiledExperiment) resolve(name string, s ExperimentSubject, now time.Time) (variant string, enrolled bool) {
+	control := e.def.Variants[0].Name
+	if !e.def.End.IsZero() && !now.Before(e.def.End) {
+		if e.def.Winner != "" {
+			return e.def.Winner, false
+		}
+		return control, false
+	}
+	if e.def.Paused || now.Before(e.def.Start) || s.UserID == "" {
+		return control, false
+	}
+	if e.roles != nil && !anyInSet(s.Roles, e.roles) {
+		return control, false
+	}
+	if e.tenants != nil {
+		if _, ok := e.tenants[s.Tenant]; !ok {
+			return control, false
+		}
+	}
+
+	bucket := canaryBucket("experiment:"+name, s.UserID)
+	for i, bound := range e.bounds {
+		if bucket < bound {
+			return e.def.Variants[i].Name, true
+		}
+	}
+	return control, true
+}
+
+func anyInSet(items []string, set map[string]struct{}) bool {
+	for _, item := range items {
+		if _, ok := set[item]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	experimentMu        sync.Mutex
+	experimentConfig    ExperimentConfig
+	experimentOverrides = make(map[string]Experiment) // 管理接口提交的定义
+	experiments         atomic.Pointer[map[string]*compiledExperiment]
+
+	exposureMu   sync.Mutex
+	exposureSeen = make(map[string]string) // 实验 + 用户 → 已记录曝光的变体
+)
+
+// SetExperimentConfig 应用实验配置（启动时和配置热更新时调用）
+//
+// 管理接口提交的修改被配置覆盖；定义无效的实验记录错误并跳过（Variant 对它返回空字符串）
+func SetExperimentConfig(config ExperimentConfig) {
+	experimentMu.Lock()
+	defer experimentMu.Unlock()
+	experimentConfig = config
+	experimentOverrides = make(map[string]Experiment)
+	rebuildExperiments()
+}
+
+// rebuildExperiments 合并配置与管理接口的定义（调用方持有 experimentMu）
+func rebuildExperiments() {
+	set := make(map[string]*compiledExperiment, len(experimentConfig.Experiments)+len(experimentOverrides))
+	add := func(name string, def Experiment) {
+		e, err := compileExperiment(name, def)
+		if err != nil {
+			log.Errorf("[Experiment] %v", err)
+			return
+		}
+		set[name] = e
+	}
+	for name, def := range experimentConfig.Experiments {
+		add(name, def)
+	}
+	for name, def := range experimentOverrides {
+		add(name, def)
+	}
+	experiments.Store(&set)
+}
+
+// lookupExperiment 获取实验（不存在时返回 nil）
+func lookupExperiment(name string) *compiledExperiment {
+	if p := experiments.Load(); p != nil {
+		return (*p)[name]
+	}
+	return nil
+}
+
+// PutExperiment 新增或替换一个实验的定义（立即生效，下次配置文件变更时以配置为准）
+//
+// 调整权重时按变体顺序切分哈希区间，只有落在区间边界移动部分的用户会换组，其余用户的分组保持不变
+func PutExperiment(name string, def Experiment) error {
+	if _, err := compileExperiment(name, def); err != nil {
+		return err
+	}
+	experimentMu.Lock()
+	defer experimentMu.Unlock()
+	experimentOverrides[name] = def
+	rebuildExperiments()
+	return nil
+}
+
+// Experiments 当前生效的实验定义
+func Experiments() map[string]Experiment {
+	out := make(map[string]Experiment)
+	if p := experiments.Load(); p != nil {
+		for name, e := range *p {
+			out[name] = e.def
+		}
+	}
+	return out
+}
+
+type experimentSubjectKey struct{}
+
+// WithExperimentSubject 在 ctx 中指定参与实验的主体（邮件、定时任务等没有请求的场景）
+//
+// 使用方式：
+//
+//	ctx = web.WithExperimentSubject(ctx, web.ExperimentSubject{UserID: user.ID})
+//	subject := "本周订单汇总"
+//	if web.Variant(ctx, "digest-subject") == "short" {
+//	    subject = "本周订单"
+//	}
+func WithExperimentSubject(ctx context.Context, s ExperimentSubject) context.Context {
+	return context.WithValue(ctx, experimentSubjectKey{}, s)
+}
+
+// ExperimentMiddleware 把当前用户（JWT 身份、角色、租户声明）和请求 ID 写入 ctx，供 Variant 使用
+//
+// 需要放在 JWT 中间件之后
+//
+// 使用方式：
+//
+//	api := h.Group("/api", jwt.Middleware(), web.ExperimentMiddleware())
+func ExperimentMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Next(WithExperimentSubject(ctx, subjectFromRequest(c)))
+	}
+}
+
+// subjectFromRequest 从请求中提取实验主体
+func subjectFromRequest(c *app.RequestContext) ExperimentSubject {
+	experimentMu.Lock()
+	claim := experimentConfig.TenantClaim
+	experimentMu.Unlock()
+	if claim == "" {
+		claim = "tenant"
+	}
+	tenant, _ := jwt.GetClaims(c)[claim].(string)
+	return ExperimentSubject{
+		UserID:    jwt.GetUserID(c),
+		Roles:     jwt.GetRoles(c),
+		Tenant:    tenant,
+		RequestID: middleware.GetRequestID(c),
+	}
+}
+
+// subjectFrom ctx 中的实验主体
+func subjectFrom(ctx context.Context) ExperimentSubject {
+	s, _ := ctx.Value(experimentSubjectKey{}).(ExperimentSubject)
+	return s
+}
+
+// Variant 返回当前用户在实验中的变体，在代码实际分支的位置调用
+//
+// 分组按实验名 + 用户 ID 哈希，跨请求、跨实例稳定。实验不存在时返回空字符串；
+// 未登录、不符合定向条件、暂停或未开始时返回对照组（第一个变体），结束后返回 winner。
+// 用户第一次在实验中走到分支点时记录曝光事件 experiment.exposure（经审计输出，包含实验、变体、用户与请求 ID），
+// 只分配不调用 Variant 不算曝光
+//
+// 使用方式：
+//
+//	switch web.Variant(ctx, "checkout-flow") {
+//	case "one-page":
+//	    return onePageCheckout(ctx, c)
+//	default:
+//	    return classicCheckout(ctx, c)
+//	}
+func Variant(ctx context.Context, name string) string {
+	e := lookupExperiment(name)
+	if e == nil {
+		return ""
+	}
+	s := subjectFrom(ctx)
+	variant, enrolled := e.resolve(name, s, time.Now())
+	if enrolled {
+		logExposure(ctx, name, variant, s)
+	}
+	return variant
+}
+
+// logExposure 同一用户在同一实验的同一变体只记录一次曝光（换组后重新记录）
+func logExposure(ctx context.Context, name, variant string, s ExperimentSubject) {
+	key := name + "\x00" + s.UserID
+	exposureMu.Lock()
+	if exposureSeen[key] == variant {
+		exposureMu.Unlock()
+		return
+	}
+	if len(exposureSeen) >= maxExposureEntries {
+		exposureSeen = make(map[string]string)
+	}
+	exposureSeen[key] = variant
+	exposureMu.Unlock()
+
+	metrics.GetCounter("web_experiment_exposures_total", "experiment", name, "variant", variant).Inc()
+	audit.Emit(ctx, audit.Event{
+		Type:      "experiment.exposure",
+		Actor:     s.UserID,
+		RequestID: s.RequestID,
+		Data:      map[string]any{"experiment": name, "variant": variant, "tenant": s.Tenant},
+	})
+}
+
+// ExperimentMetrics 按实验变体记录路由的请求数、5xx 错误数和累计耗时（路由按需开启）
+//
+// 只统计在实验中的用户（web_experiment_requests_total / web_experiment_errors_total / web_experiment_duration_ms_total，
+// 标签为 experiment、variant），不记录曝光
+//
+// 使用方式：
+//
+//	api.POST("/checkout", web.ExperimentMetrics("checkout-flow"), checkoutHandler)
+func ExperimentMetrics(names ...string) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		s, ok := ctx.Value(experimentSubjectKey{}).(ExperimentSubject)
+		if !ok {
+			s = subjectFromRequest(c)
+			ctx = WithExperimentSubject(ctx, s)
+		}
+		start := time.Now()
+		defer func() {
+			elapsed := time.Since(start).Milliseconds()
+			failed := c.Response.StatusCode() >= 500
+			for _, name := range names {
+				e := lookupExperiment(name)
+				if e == nil {
+					continue
+				}
+				variant, enrolled := e.resolve(name, s, start)
+				if !enrolled {
+					continue
+				}
+				metrics.GetCounter("web_experiment_requests_total", "experiment", name, "variant", variant).Inc()
+				metrics.GetCounter("web_experiment_duration_ms_total", "experiment", name, "variant", variant).Add(elapsed)
+				if failed {
+					metrics.GetCounter("web_experiment_errors_total", "experiment", name, "variant", variant).Inc()
+				}
+			}
+		}()
+		c.Next(ctx)
+	}
+}
+
+// ExperimentAdminHandler 实验管理接口
+//
+// GET 返回全部实验的当前定义；POST/PUT ?name=checkout-flow 提交需要修改的字段
+// （如 {"paused": true} 或 {"variants": [...]}），未提交的字段保持不变，实验不存在时新建。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/experiments", web.ExperimentAdminHandler())
+func ExperimentAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			name := strings.TrimSpace(c.Query("name"))
+			if name == "" {
+				panic(BadRequestHTTP("缺少实验名"))
+			}
+			def := Experiments()[name]
+			if err := json.Unmarshal(c.Request.Body(), &def); err != nil {
+				panic(BadRequestHTTP("实验定义格式错误"))
+			}
+			if err := PutExperiment(name, def); err != nil {
+				panic(BadRequestHTTP(err.Error()))
+			}
+			log.Warnf("[Experiment] %s 已修改: variants=%v paused=%v winner=%q", name, def.Variants, def.Paused, def.Winner)
+			c.JSON(consts.StatusOK, Success(def))
+		default:
+			c.JSON(consts.StatusOK, Success(Experiments()))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setExperiments(t *testing.T, experiments map[string]Experiment) *ownershipAudit {
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	SetExperimentConfig(ExperimentConfig{Experiments: experiments})
+	exposureMu.Lock()
+	exposureSeen = make(map[string]string)
+	exposureMu.Unlock()
+	t.Cleanup(func() {
+		audit.SetSink(nil)
+		SetExperimentConfig(ExperimentConfig{})
+	})
+	return rec
+}
+
+func subjectCtx(user string) context.Context {
+	return WithExperimentSubject(context.Background(), ExperimentSubject{UserID: user, RequestID: "req-" + user})
+}
+
+func TestExperiment_StableAssignmentAndDistribution(t *testing.T) {
+	setExperiments(t, map[string]Experiment{
+		"ranking": {Variants: []ExperimentVariant{{Name: "control", Weight: 70}, {Name: "ml", Weight: 30}}},
+	})
+
+	// 同一用户多次调用、配置重新加载（相当于另一个实例）后分组不变
+	first := make(map[string]string)
+	for i := range 200 {
+		user := fmt.Sprintf("u%d", i)
+		first[user] = Variant(subjectCtx(user), "ranking")
+		assert.Equal(t, first[user], Variant(subjectCtx(user), "ranking"))
+	}
+	SetExperimentConfig(ExperimentConfig{Experiments: Experiments()})
+	for user, variant := range first {
+		assert.Equal(t, variant, Variant(subjectCtx(user), "ranking"), user)
+	}
+
+	// 大样本下的比例
+	const n = 100000
+	e := lookupExperiment("ranking")
+	counts := make(map[string]int)
+	for i := range n {
+		v, enrolled := e.resolve("ranking", ExperimentSubject{UserID: fmt.Sprintf("user-%d", i)}, time.Now())
+		require.True(t, enrolled)
+		counts[v]++
+	}
+	assert.InDelta(t, 0.30, float64(counts["ml"])/n, 0.01)
+	assert.InDelta(t, 0.70, float64(counts["control"])/n, 0.01)
+
+	// 调大 ml 的权重：原本在 ml 的用户仍在 ml
+	require.NoError(t, PutExperiment("ranking", Experiment{Variants: []ExperimentVariant{{Name: "control", Weight: 50}, {Name: "ml", Weight: 50}}}))
+	moved := 0
+	for user, variant := range first {
+		now := Variant(subjectCtx(user), "ranking")
+		if variant == "ml" {
+			assert.Equal(t, "ml", now, user)
+		} else if now != variant {
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 0)
+	assert.Less(t, math.Abs(float64(moved)/200-0.2), 0.1, "约 20% 的用户从对照组换到 ml")
+
+	assert.Equal(t, "", Variant(subjectCtx("u1"), "missing"))
+}
+
+func TestExperiment_ExposureDeduplicatedPerUser(t *testing.T) {
+	rec := setExperiments(t, map[string]Experiment{
+		"checkout-flow": {Variants: []ExperimentVariant{{Name: "classic", Weight: 1}, {Name: "one-page", Weight: 1}}},
+	})
+
+	// 只分配（metrics）不算曝光
+	e := lookupExperiment("checkout-flow")
+	e.resolve("checkout-flow", ExperimentSubject{UserID: "alice"}, time.Now())
+	assert.Empty(t, rec.types())
+
+	for range 5 {
+		Variant(subjectCtx("alice"), "checkout-flow")
+	}
+	for range 3 {
+		Variant(subjectCtx("bob"), "checkout-flow")
+	}
+	Variant(context.Background(), "checkout-flow") // 未登录：对照组，不记录
+	require.Equal(t, []string{"experiment.exposure", "experiment.exposure"}, rec.types())
+	event := rec.events[0]
+	assert.Equal(t, "alice", event.Actor)
+	assert.Equal(t, "req-alice", event.RequestID)
+	assert.Equal(t, "checkout-flow", event.Data["experiment"])
+	assert.Equal(t, Variant(subjectCtx("alice"), "checkout-flow"), event.Data["variant"])
+
+	// 暂停与结束：不记录曝光，结束后所有人使用 winner
+	require.NoError(t, PutExperiment("checkout-flow", Experiment{
+		Variants: []ExperimentVariant{{Name: "classic", Weight: 1}, {Name: "one-page", Weight: 1}}, Paused: true,
+	}))
+	assert.Equal(t, "classic", Variant(subjectCtx("carol"), "checkout-flow"))
+	require.NoError(t, PutExperiment("checkout-flow", Experiment{
+		Variants: []ExperimentVariant{{Name: "classic", Weight: 1}, {Name: "one-page", Weight: 1}},
+		End:      time.Now().Add(-time.Hour), Winner: "one-page",
+	}))
+	for _, user := range []string{"alice", "bob", "carol", ""} {
+		assert.Equal(t, "one-page", Variant(subjectCtx(user), "checkout-flow"))
+	}
+	assert.Len(t, rec.types(), 2)
+
+	assert.Error(t, PutExperiment("bad", Experiment{Variants: []ExperimentVariant{{Name: "a", Weight: 1}}, Winner: "b"}))
+}
+
+func TestExperiment_MiddlewareTargetingAndMetrics(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "experiment-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := setExperiments(t, map[string]Experiment{
+		"subject-line": {Variants: []ExperimentVariant{{Name: "a", Weight: 0}, {Name: "b", Weight: 1}}, Roles: []string{"buyer"}},
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(middleware.RequestIDMiddleware())
+	engine.GET("/mail", jwt.Middleware(), ExperimentMiddleware(), ExperimentMetrics("subject-line"), func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, Variant(ctx, "subject-line"))
+	})
+
+	requests := metrics.GetCounter("web_experiment_requests_total", "experiment", "subject-line", "variant", "b")
+	before := requests.Value()
+	w := ut.PerformRequest(engine, "GET", "/mail", nil, ownershipToken(t, "dave", "buyer"))
+	assert.Equal(t, "b", w.Body.String())
+	w = ut.PerformRequest(engine, "GET", "/mail", nil, ownershipToken(t, "erin", "admin"))
+	assert.Equal(t, "a", w.Body.String(), "不符合定向条件：对照组")
+	assert.Equal(t, int64(1), requests.Value()-before)
+
+	require.Len(t, rec.events, 1)
+	assert.Equal(t, "dave", rec.events[0].Actor)
+	assert.NotEmpty(t, rec.events[0].RequestID)
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Money 金额（导出和模板中按币种与语言格式化）
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// FormatValue 按 ctx 中的语言和时区把导出值格式化为文本
+//
+// time.Time → TimeStyleDateTime；Money → FormatCurrency；浮点数 → 千位分隔（最多 6 位小数）；
+// 整数 → 千位分隔；其他类型使用 fmt.Sprint
+func FormatValue(ctx context.Context, v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return FormatTime(ctx, x, TimeStyleDateTime)
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return FormatTime(ctx, *x, TimeStyleDateTime)
+	case Money:
+		return FormatCurrency(ctx, x.Amount, x.Currency)
+	case float64:
+		return FormatNumber(ctx, x, floatDecimals(x))
+	case float32:
+		return FormatNumber(ctx, float64(x), floatDecimals(float64(x)))
+	case int, int32, int64, uint, uint32, uint64:
+		return FormatNumber(ctx, toFloat(x), 0)
+	}
+	return fmt.Sprint(v)
+}
+
+// floatDecimals 最短表示的小数位数（最多 6 位）
+func floatDecimals(v float64) int {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	_, frac, _ := strings.Cut(s, ".")
+	return min(len(frac), 6)
+}
+
+// utf8BOM Excel 据此识别 UTF-8 编码的 CSV
+const utf8BOM = "\xEF\xBB\xBF"
+
+// WriteCSV 按 ctx 中的语言和时区写出 CSV（带 UTF-8 BOM，Excel 直接打开不乱码）
+//
+// 以 = + - @ 开头的文本单元格加前缀 '，防止在 Excel 中被当作公式执行（CSV 注入）
+func WriteCSV(ctx context.Context, w io.Writer, header []string, rows [][]any) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	cw := NewCSVWriter(ctx, w)
+	if len(header) > 0 {
+		if err := cw.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	return cw.Flush()
+}
+
+// CSVWriter 逐行写出 CSV（数据量大、不能一次放进内存时使用），格式化规则同 WriteCSV
+//
+// 不写 BOM：从文件开头写入时由调用方先写入 BOM（追加写入时不能重复写）
+type CSVWriter struct {
+	ctx    context.Context
+	w      *csv.Writer
+	record []string
+}
+
+// NewCSVWriter 创建按 ctx 中的语言和时区格式化的 CSV 写入器
+func NewCSVWriter(ctx context.Context, w io.Writer) *CSVWriter {
+	return &CSVWriter{ctx: ctx, w: csv.NewWriter(w)}
+}
+
+// WriteHeader 写出表头
+func (w *CSVWriter) WriteHeader(header []string) error {
+	return w.w.Write(header)
+}
+
+// Write 写出一行（缓冲，Flush 后才保证写入底层 Writer）
+func (w *CSVWriter) Write(row []any) error {
+	w.record = w.record[:0]
+	for _, v := range row {
+		cell := FormatValue(w.ctx, v)
+		if s, ok := v.(string); ok && s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+			cell = "'" + cell
+		}
+		w.record = append(w.record, cell)
+	}
+	return w.w.Write(w.record)
+}
+
+// Flush 把缓冲的行写入底层 Writer
+func (w *CSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// ExportCSV 以附件形式返回 CSV（按请求的语言和时区格式化，需要 LocaleMiddleware）
+//
+// 使用方式：
+//
+//	rows := make([][]any, 0, len(orders))
+//	for _, o := range orders {
+//	    rows = append(rows, []any{o.ID, o.CreatedAt, web.Money{Amount: o.Total, Currency: "CNY"}})
+//	}
+//	web.ExportCSV(ctx, c, "orders.csv", []string{"订单号", "下单时间", "金额"}, rows)
+func ExportCSV(ctx context.Context, c *app.RequestContext, filename string, header []string, rows [][]any) {
+	var buf bytes.Buffer
+	if err := WriteCSV(ctx, &buf, header, rows); err != nil {
+		panic(InternalHTTP("导出失败"))
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s",
+		filename, url.PathEscape(filename)))
+	c.Data(consts.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
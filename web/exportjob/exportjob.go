@@ -0,0 +1,605 @@
+// Package exportjob 后台导出任务：把超出请求超时的大数据量导出移出请求/响应周期
+//
+// StartExport 创建任务后立即返回，后台从数据源逐行读取，写成 CSV（带 BOM，Excel 可直接打开）存入 storage，
+// 定期保存进度与游标（checkpoint）；中途失败时从最近的 checkpoint 续写，不会重复或丢失行。
+// 完成后生成带签名的限时下载链接，通过通知发送给用户，或由任务状态接口返回。
+// 每个用户同时进行的导出数受限，文件在保留期后由清理任务删除
+//
+// 使用方式：
+//
+//	exporter := exportjob.NewExporter(exportjob.NewMemoryStore(), files, config.Export, nil)
+//	exporter.RegisterSource("orders", exportjob.Source{
+//	    Header: []string{"订单号", "下单时间", "金额"},
+//	    Count:  func(ctx context.Context, job exportjob.Job) (int64, error) { return queries.CountOrders(ctx, job.UserID) },
+//	    Open: func(ctx context.Context, job exportjob.Job, cursor string) (exportjob.RowIterator, error) {
+//	        return newOrderIterator(ctx, job.UserID, cursor), nil   // 按 id > cursor 分页查询
+//	    },
+//	})
+//	exporter.UseNotifier(exportjob.NotifyWith(scheduler, notify.ChannelEmail, "exportReady"))
+//	web.RegisterComponent(exporter.Component(web.ComponentDatabase))
+//
+//	api.POST("/exports/orders", func(ctx context.Context, c *app.RequestContext) {
+//	    job, err := exporter.StartExport(ctx, exportjob.ExportSpec{Source: "orders", UserID: jwt.GetUserID(c), Filename: "订单.csv"})
+//	    ...
+//	})
+//	api.GET("/exports/:id", exporter.StatusHandler())
+package exportjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/storage"
+	"github.com/google/uuid"
+)
+
+// ComponentExport 导出任务组件名
+const ComponentExport = "export"
+
+// 任务状态
+const (
+	StatusQueued  = "queued"  // 等待执行
+	StatusRunning = "running" // 正在导出
+	StatusDone    = "done"    // 已完成，可以下载
+	StatusFailed  = "failed"  // 重试耗尽（保留 checkpoint，可以 Resume）
+	StatusExpired = "expired" // 超过保留期，文件已删除
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("export job not found")
+	// ErrTooManyExports 用户进行中的导出任务已达上限
+	ErrTooManyExports = errors.New("too many concurrent exports")
+	// ErrUnknownSource 数据源未注册
+	ErrUnknownSource = errors.New("unknown export source")
+)
+
+// Job 导出任务
+type Job struct {
+	ID         string            `json:"id"`
+	UserID     string            `json:"userId"`
+	Source     string            `json:"source"`
+	Params     map[string]string `json:"params,omitempty"`
+	Filename   string            `json:"filename"`
+	Language   string            `json:"language,omitempty"`
+	Timezone   string            `json:"timezone,omitempty"`
+	Status     string            `json:"status"`
+	Rows       int64             `json:"rows"`            // 已写入的行数（checkpoint 时更新）
+	Total      int64             `json:"total,omitempty"` // 预计总行数（数据源提供 Count 时）
+	Bytes      int64             `json:"-"`               // 已确认写入的字节数（续写时截断到此处）
+	Cursor     string            `json:"-"`               // 数据源游标（续写时从此处继续）
+	Key        string            `json:"-"`               // 存储中的文件 key
+	Attempts   int               `json:"attempts"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	FinishedAt time.Time         `json:"finishedAt,omitzero"`
+	ExpiresAt  time.Time         `json:"expiresAt,omitzero"` // 文件删除时间
+}
+
+// Active 任务是否在进行中（计入用户并发数）
+func (j Job) Active() bool {
+	return j.Status == StatusQueued || j.Status == StatusRunning
+}
+
+// Progress 进度百分比（不知道总行数时为 -1）
+func (j Job) Progress() int {
+	switch {
+	case j.Status == StatusDone:
+		return 100
+	case j.Total <= 0:
+		return -1
+	}
+	return int(min(j.Rows*100/j.Total, 99))
+}
+
+// RowIterator 数据源迭代器
+type RowIterator interface {
+	// Next 返回下一行及读完该行后的游标；没有更多行时返回 io.EOF
+	//
+	// 游标为空字符串表示不支持续读，失败后从头导出
+	Next(ctx context.Context) (row []any, cursor string, err error)
+	// Close 释放资源（数据库游标、连接等）
+	Close() error
+}
+
+// Source 导出数据源
+type Source struct {
+	Header []string
+	// Open 从 cursor 之后开始读取（cursor 为空时从头读取）
+	Open func(ctx context.Context, job Job, cursor string) (RowIterator, error)
+	// Count 预计总行数（可为 nil，只用于显示进度）
+	Count func(ctx context.Context, job Job) (int64, error)
+}
+
+// ExportSpec 导出请求
+type ExportSpec struct {
+	Source   string            // 已注册的数据源名
+	UserID   string            // 发起导出的用户（并发限制、下载权限、通知对象）
+	Params   map[string]string // 数据源参数（筛选条件等，随任务持久化）
+	Filename string            // 下载文件名，默认 <source>.csv
+	Language string            // 格式化语言，默认 web 的默认语言
+	Timezone string            // 格式化时区，默认 web 的默认时区
+}
+
+// Notifier 导出完成（或最终失败）后通知用户，url 为限时下载链接（失败时为空）
+type Notifier func(ctx context.Context, job Job, url string) error
+
+// Config 导出任务配置
+//
+// Example:
+//
+//	[export]
+//	workers = 4              # 同时执行的导出任务数
+//	maxPerUser = 2           # 每个用户同时进行的导出任务数
+//	checkpointRows = 10000   # 每写入多少行保存一次进度
+//	maxAttempts = 3          # 失败后从 checkpoint 重试的次数
+//	retryDelay = 5           # 重试间隔（秒）
+//	linkTTL = 86400          # 下载链接有效期（秒）
+//	retention = 604800       # 文件保留期（秒），过期后删除
+//	cleanupInterval = 3600   # 清理间隔（秒）
+type Config struct {
+	Workers         int `toml:"workers"`
+	MaxPerUser      int `toml:"maxPerUser"`
+	CheckpointRows  int `toml:"checkpointRows"`
+	MaxAttempts     int `toml:"maxAttempts"`
+	RetryDelay      int `toml:"retryDelay"`
+	LinkTTL         int `toml:"linkTTL"`
+	Retention       int `toml:"retention"`
+	CleanupInterval int `toml:"cleanupInterval"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.MaxPerUser <= 0 {
+		c.MaxPerUser = 2
+	}
+	if c.CheckpointRows <= 0 {
+		c.CheckpointRows = 10000
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 5
+	}
+	if c.LinkTTL <= 0 {
+		c.LinkTTL = 86400
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 86400
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = 3600
+	}
+	return c
+}
+
+// Exporter 导出任务执行器
+type Exporter struct {
+	store    Store
+	files    storage.Storage
+	config   Config
+	clock    common.Clock
+	notifier Notifier
+
+	mu      sync.RWMutex
+	sources map[string]Source
+
+	slots  chan struct{}  // 同时执行的任务数
+	wg     sync.WaitGroup // 执行中的任务
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewExporter 创建导出任务执行器（clock 为 nil 时使用系统时钟）
+func NewExporter(store Store, files storage.Storage, config Config, clock common.Clock) *Exporter {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Exporter{
+		store:   store,
+		files:   files,
+		config:  config,
+		clock:   clock,
+		sources: make(map[string]Source),
+		slots:   make(chan struct{}, config.Workers),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// RegisterSource 注册数据源（任务只保存数据源名和参数，重启后按名称找回数据源续写）
+func (e *Exporter) RegisterSource(name string, source Source) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sources[name] = source
+}
+
+// UseNotifier 设置完成通知（未设置时用户通过任务状态接口获取下载链接）
+func (e *Exporter) UseNotifier(n Notifier) {
+	e.notifier = n
+}
+
+func (e *Exporter) source(name string) (Source, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s, ok := e.sources[name]
+	return s, ok
+}
+
+// StartExport 创建导出任务并在后台执行，立即返回任务
+//
+// 用户进行中的任务已达 maxPerUser 时返回 ErrTooManyExports
+func (e *Exporter) StartExport(ctx context.Context, spec ExportSpec) (Job, error) {
+	if spec.UserID == "" {
+		return Job{}, errors.New("exportjob: UserID 不能为空")
+	}
+	if _, ok := e.source(spec.Source); !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownSource, spec.Source)
+	}
+	if spec.Filename == "" {
+		spec.Filename = spec.Source + ".csv"
+	}
+	now := e.clock.Now()
+	id := uuid.NewString()
+	job := Job{
+		ID:        id,
+		UserID:    spec.UserID,
+		Source:    spec.Source,
+		Params:    spec.Params,
+		Filename:  spec.Filename,
+		Language:  spec.Language,
+		Timezone:  spec.Timezone,
+		Status:    StatusQueued,
+		Key:       "exports/" + id + ".csv",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.store.Create(ctx, job, e.config.MaxPerUser); err != nil {
+		return Job{}, err
+	}
+	metrics.GetCounter("web_export_jobs_total", "status", StatusQueued).Inc()
+	e.launch(job.ID)
+	return job, nil
+}
+
+// Resume 从 checkpoint 继续执行失败的任务
+func (e *Exporter) Resume(ctx context.Context, id string) (Job, error) {
+	job, err := e.store.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Status != StatusFailed {
+		return job, fmt.Errorf("exportjob: 任务 %s 状态为 %s，不能续写", id, job.Status)
+	}
+	job.Status, job.Attempts, job.Error = StatusQueued, 0, ""
+	job.UpdatedAt = e.clock.Now()
+	if err := e.store.Update(ctx, job); err != nil {
+		return Job{}, err
+	}
+	e.launch(job.ID)
+	return job, nil
+}
+
+// Get 查询任务
+func (e *Exporter) Get(ctx context.Context, id string) (Job, error) {
+	return e.store.Get(ctx, id)
+}
+
+// DownloadURL 生成已完成任务的限时下载链接（有效期不超过文件保留期）
+func (e *Exporter) DownloadURL(ctx context.Context, job Job) (string, error) {
+	if job.Status != StatusDone {
+		return "", fmt.Errorf("exportjob: 任务 %s 未完成", job.ID)
+	}
+	ttl := time.Duration(e.config.LinkTTL) * time.Second
+	if remaining := job.ExpiresAt.Sub(e.clock.Now()); remaining < ttl {
+		ttl = remaining
+	}
+	return e.files.SignedURL(ctx, job.Key, job.Filename, ttl)
+}
+
+// launch 在后台执行任务（等待空闲的执行槽位）
+func (e *Exporter) launch(id string) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		select {
+		case e.slots <- struct{}{}:
+		case <-e.ctx.Done():
+			return
+		}
+		defer func() { <-e.slots }()
+		e.run(e.ctx, id)
+	}()
+}
+
+// run 执行任务，失败后从 checkpoint 重试
+func (e *Exporter) run(ctx context.Context, id string) {
+	job, err := e.store.Get(ctx, id)
+	if err != nil {
+		logger.Errorf("[Export] 读取任务 %s 失败: %v", id, err)
+		return
+	}
+	source, ok := e.source(job.Source)
+	if !ok {
+		e.fail(ctx, job, fmt.Errorf("%w: %s", ErrUnknownSource, job.Source))
+		return
+	}
+	if source.Count != nil && job.Total == 0 {
+		if total, err := source.Count(ctx, job); err == nil {
+			job.Total = total
+		}
+	}
+
+	for {
+		job.Status = StatusRunning
+		job.Attempts++
+		job.UpdatedAt = e.clock.Now()
+		if err := e.store.Update(ctx, job); err != nil {
+			logger.Errorf("[Export] 更新任务 %s 失败: %v", id, err)
+			return
+		}
+		err := e.write(ctx, &job, source)
+		if err == nil {
+			e.complete(ctx, job)
+			return
+		}
+		if ctx.Err() != nil {
+			// 进程退出：保持 running 与 checkpoint，下次启动时续写
+			return
+		}
+		job.Error = err.Error()
+		logger.Warnf("[Export] 任务 %s 第 %d 次执行失败（已写入 %d 行）: %v", id, job.Attempts, job.Rows, err)
+		if job.Attempts >= e.config.MaxAttempts {
+			e.fail(ctx, job, err)
+			return
+		}
+		if err := e.store.Update(ctx, job); err != nil {
+			logger.Errorf("[Export] 更新任务 %s 失败: %v", id, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(e.config.RetryDelay) * time.Second):
+		}
+	}
+}
+
+// countingWriter 统计写入底层存储的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// write 从 checkpoint 开始写入文件，每 checkpointRows 行保存一次进度
+func (e *Exporter) write(ctx context.Context, job *Job, source Source) error {
+	if job.Cursor == "" {
+		job.Rows, job.Bytes = 0, 0 // 数据源不支持续读或尚未开始：从头导出
+	}
+	f, err := e.files.Append(ctx, job.Key, job.Bytes)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	out := &countingWriter{w: f, n: job.Bytes}
+
+	formatCtx := web.WithLocale(ctx, web.LocaleFor(job.Language, job.Timezone))
+	w := web.NewCSVWriter(formatCtx, out)
+	if job.Bytes == 0 {
+		if _, err := io.WriteString(out, "\xEF\xBB\xBF"); err != nil {
+			return err
+		}
+		if len(source.Header) > 0 {
+			if err := w.WriteHeader(source.Header); err != nil {
+				return err
+			}
+		}
+	}
+
+	it, err := source.Open(ctx, *job, job.Cursor)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	rows, cursor := job.Rows, job.Cursor
+	checkpoint := func() error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		added := rows - job.Rows
+		job.Rows, job.Bytes, job.Cursor = rows, out.n, cursor
+		job.UpdatedAt = e.clock.Now()
+		metrics.GetCounter("web_export_rows_total", "source", job.Source).Add(added)
+		return e.store.Update(ctx, *job)
+	}
+	for {
+		row, next, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// 保存最近一次完整写入的进度，重试时从这里继续
+			if cerr := checkpoint(); cerr != nil {
+				return errors.Join(err, cerr)
+			}
+			return err
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+		rows++
+		cursor = next
+		if rows%int64(e.config.CheckpointRows) == 0 {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := checkpoint(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// complete 标记完成并通知用户
+func (e *Exporter) complete(ctx context.Context, job Job) {
+	now := e.clock.Now()
+	job.Status, job.Error = StatusDone, ""
+	job.FinishedAt, job.UpdatedAt = now, now
+	job.ExpiresAt = now.Add(time.Duration(e.config.Retention) * time.Second)
+	if err := e.store.Update(ctx, job); err != nil {
+		logger.Errorf("[Export] 更新任务 %s 失败: %v", job.ID, err)
+		return
+	}
+	metrics.GetCounter("web_export_jobs_total", "status", StatusDone).Inc()
+	logger.Infof("[Export] 任务 %s 完成：%s，%d 行", job.ID, job.Filename, job.Rows)
+	if e.notifier == nil {
+		return
+	}
+	url, err := e.DownloadURL(ctx, job)
+	if err != nil {
+		logger.Errorf("[Export] 生成任务 %s 下载链接失败: %v", job.ID, err)
+		return
+	}
+	if err := e.notifier(ctx, job, url); err != nil {
+		logger.Errorf("[Export] 通知任务 %s 完成失败: %v", job.ID, err)
+	}
+}
+
+// fail 标记最终失败（保留 checkpoint 和已写入的部分文件，可以 Resume）
+func (e *Exporter) fail(ctx context.Context, job Job, cause error) {
+	now := e.clock.Now()
+	job.Status, job.Error = StatusFailed, cause.Error()
+	job.FinishedAt, job.UpdatedAt = now, now
+	job.ExpiresAt = now.Add(time.Duration(e.config.Retention) * time.Second)
+	if err := e.store.Update(ctx, job); err != nil {
+		logger.Errorf("[Export] 更新任务 %s 失败: %v", job.ID, err)
+	}
+	metrics.GetCounter("web_export_jobs_total", "status", StatusFailed).Inc()
+	logger.Errorf("[Export] 任务 %s 失败（已写入 %d 行）: %v", job.ID, job.Rows, cause)
+	if e.notifier != nil {
+		if err := e.notifier(ctx, job, ""); err != nil {
+			logger.Errorf("[Export] 通知任务 %s 失败: %v", job.ID, err)
+		}
+	}
+}
+
+// Cleanup 删除超过保留期的文件，任务标记为 expired，返回清理的任务数
+func (e *Exporter) Cleanup(ctx context.Context) (int, error) {
+	jobs, err := e.store.ListExpired(ctx, e.clock.Now())
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, job := range jobs {
+		if err := e.files.Delete(ctx, job.Key); err != nil {
+			logger.Errorf("[Export] 删除任务 %s 的文件失败: %v", job.ID, err)
+			continue
+		}
+		job.Status, job.Cursor, job.Bytes = StatusExpired, "", 0
+		job.UpdatedAt = e.clock.Now()
+		if err := e.store.Update(ctx, job); err != nil {
+			logger.Errorf("[Export] 更新任务 %s 失败: %v", job.ID, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// resumeInterrupted 续写上次进程退出时未完成的任务
+func (e *Exporter) resumeInterrupted(ctx context.Context) error {
+	jobs, err := e.store.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		logger.Infof("[Export] 续写未完成的任务 %s（已写入 %d 行）", job.ID, job.Rows)
+		e.launch(job.ID)
+	}
+	return nil
+}
+
+// Run 按清理间隔删除过期文件，直到 ctx 取消
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(e.config.CleanupInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.Cleanup(ctx); err != nil {
+				logger.Errorf("[Export] 清理过期文件失败: %v", err)
+			}
+		}
+	}
+}
+
+// Component 导出任务的生命周期组件（dependsOn 通常为 web.ComponentDatabase）
+//
+// 启动时续写未完成的任务并开始定期清理；停止时中断执行中的任务（保留 checkpoint，下次启动续写）
+func (e *Exporter) Component(dependsOn ...string) web.Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return web.Component{
+		Name:      ComponentExport,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			if err := e.resumeInterrupted(ctx); err != nil {
+				return err
+			}
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				e.Run(runCtx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel != nil {
+				cancel()
+				<-done
+			}
+			return e.Stop(ctx)
+		},
+	}
+}
+
+// Stop 中断执行中的任务并等待退出
+func (e *Exporter) Stop(ctx context.Context) error {
+	e.cancel()
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,333 @@
+package exportjob
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/storage"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticIterator 生成 id 从 cursor+1 到 total 的行；failAt 行读取失败一次
+type syntheticIterator struct {
+	next, total int
+	failAt      *int
+	resumable   bool
+	block       chan struct{}
+}
+
+func (it *syntheticIterator) Next(ctx context.Context) ([]any, string, error) {
+	if it.block != nil {
+		select {
+		case <-it.block:
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	}
+	if it.next > it.total {
+		return nil, "", io.EOF
+	}
+	if it.failAt != nil && it.next == *it.failAt {
+		*it.failAt = -1
+		return nil, "", errors.New("connection reset")
+	}
+	id := it.next
+	it.next++
+	cursor := ""
+	if it.resumable {
+		cursor = strconv.Itoa(id)
+	}
+	return []any{id, fmt.Sprintf("user-%d", id), web.Money{Amount: float64(id) / 100, Currency: "CNY"}}, cursor, nil
+}
+
+func (it *syntheticIterator) Close() error { return nil }
+
+type syntheticSource struct {
+	mu        sync.Mutex
+	total     int
+	failAt    int
+	resumable bool
+	block     chan struct{}
+	opens     []string
+}
+
+func (s *syntheticSource) source() Source {
+	return Source{
+		Header: []string{"id", "name", "amount"},
+		Count:  func(ctx context.Context, job Job) (int64, error) { return int64(s.total), nil },
+		Open: func(ctx context.Context, job Job, cursor string) (RowIterator, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.opens = append(s.opens, cursor)
+			start := 0
+			if cursor != "" {
+				start, _ = strconv.Atoi(cursor)
+			}
+			return &syntheticIterator{next: start + 1, total: s.total, failAt: &s.failAt, resumable: s.resumable, block: s.block}, nil
+		},
+	}
+}
+
+func (s *syntheticSource) openCursors() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.opens...)
+}
+
+type exportEnv struct {
+	exporter *Exporter
+	files    *storage.Local
+	clock    *common.FakeClock
+	engine   *route.Engine
+}
+
+func newExportEnv(t *testing.T, cfg Config) *exportEnv {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "export-secret"
+	require.NoError(t, jwt.Init(conf))
+
+	clock := common.NewFakeClock(time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC))
+	files := storage.NewLocal(t.TempDir(), "/files", []byte("file-secret"), clock)
+	exporter := NewExporter(NewMemoryStore(), files, cfg, clock)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		exporter.Stop(ctx)
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(web.ExceptionHandler())
+	engine.GET("/files/*key", files.Handler())
+	engine.GET("/exports/:id", jwt.Middleware(), exporter.StatusHandler())
+	return &exportEnv{exporter: exporter, files: files, clock: clock, engine: engine}
+}
+
+func (env *exportEnv) token(t *testing.T, user string) ut.Header {
+	token, _, err := jwt.GenerateToken(map[string]interface{}{"identity": user})
+	require.NoError(t, err)
+	return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+}
+
+// status 通过状态接口查询任务
+func (env *exportEnv) status(t *testing.T, id, user string) (int, Status) {
+	w := ut.PerformRequest(env.engine, "GET", "/exports/"+id, nil, env.token(t, user))
+	var resp struct {
+		Data Status `json:"data"`
+	}
+	if w.Code == 200 {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w.Code, resp.Data
+}
+
+// waitFor 轮询状态接口直到任务进入 status
+func (env *exportEnv) waitFor(t *testing.T, id, user, status string) Status {
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		code, s := env.status(t, id, user)
+		require.Equal(t, 200, code)
+		if s.Status == status {
+			return s
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("任务 %s 未进入 %s 状态", id, status)
+	return Status{}
+}
+
+// download 通过签名链接下载，返回数据行（不含表头）
+func (env *exportEnv) download(t *testing.T, link string) []string {
+	w := ut.PerformRequest(env.engine, "GET", link, nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, "\xEF\xBB\xBF"))
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimPrefix(body, "\xEF\xBB\xBF")))
+	require.True(t, scanner.Scan())
+	assert.Equal(t, "id,name,amount", scanner.Text())
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// assertSequential 每一行的 id 依次递增，没有重复或缺失
+func assertSequential(t *testing.T, lines []string, total int) {
+	require.Len(t, lines, total)
+	for i, line := range lines {
+		record, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil || record[0] != web.FormatNumber(context.Background(), float64(i+1), 0) {
+			t.Fatalf("第 %d 行: %q", i+1, line)
+		}
+	}
+}
+
+func TestExport_LargeDatasetWithSignedDownload(t *testing.T) {
+	const total = 200000
+	env := newExportEnv(t, Config{CheckpointRows: 5000})
+	src := &syntheticSource{total: total, resumable: true}
+	env.exporter.RegisterSource("users", src.source())
+
+	notified := make(chan string, 1)
+	env.exporter.UseNotifier(func(ctx context.Context, job Job, link string) error {
+		notified <- link
+		return nil
+	})
+
+	job, err := env.exporter.StartExport(context.Background(), ExportSpec{Source: "users", UserID: "alice", Filename: "用户.csv", Language: "zh-CN"})
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	code, _ := env.status(t, job.ID, "bob")
+	assert.Equal(t, 404, code, "其他用户看不到任务")
+
+	s := env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Equal(t, int64(total), s.Rows)
+	assert.Equal(t, int64(total), s.Total)
+	assert.Equal(t, 100, s.Progress)
+	require.NotEmpty(t, s.DownloadURL)
+	assert.True(t, strings.HasPrefix(<-notified, "/files/exports/"+job.ID+".csv?"))
+
+	lines := env.download(t, s.DownloadURL)
+	assertSequential(t, lines, total)
+	assert.Equal(t, `"200,000",user-200000,"¥2,000.00"`, lines[total-1])
+
+	// 篡改与过期的链接
+	u, _ := url.Parse(s.DownloadURL)
+	q := u.Query()
+	q.Set("filename", "other.csv")
+	u.RawQuery = q.Encode()
+	assert.Equal(t, 403, ut.PerformRequest(env.engine, "GET", u.String(), nil).Code)
+	env.clock.Advance(25 * time.Hour)
+	assert.Equal(t, 403, ut.PerformRequest(env.engine, "GET", s.DownloadURL, nil).Code)
+
+	// 重新查询得到新的链接
+	s = env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Len(t, env.download(t, s.DownloadURL), total)
+}
+
+func TestExport_ResumeFromCheckpoint(t *testing.T) {
+	const total = 30000
+	env := newExportEnv(t, Config{CheckpointRows: 10000, RetryDelay: 1})
+	src := &syntheticSource{total: total, resumable: true, failAt: 25001}
+	env.exporter.RegisterSource("users", src.source())
+
+	job, err := env.exporter.StartExport(context.Background(), ExportSpec{Source: "users", UserID: "alice"})
+	require.NoError(t, err)
+	s := env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Equal(t, 2, s.Attempts)
+	assert.Equal(t, []string{"", "25000"}, src.openCursors(), "失败时保存进度，从第 25000 行之后续写")
+	assertSequential(t, env.download(t, s.DownloadURL), total)
+
+	// 不支持续读的数据源从头导出
+	src = &syntheticSource{total: total, failAt: 15000}
+	env.exporter.RegisterSource("plain", src.source())
+	job, err = env.exporter.StartExport(context.Background(), ExportSpec{Source: "plain", UserID: "alice"})
+	require.NoError(t, err)
+	s = env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Equal(t, []string{"", ""}, src.openCursors())
+	assertSequential(t, env.download(t, s.DownloadURL), total)
+}
+
+func TestExport_FailureAndManualResume(t *testing.T) {
+	env := newExportEnv(t, Config{CheckpointRows: 100, MaxAttempts: 1})
+	src := &syntheticSource{total: 1000, resumable: true, failAt: 450}
+	env.exporter.RegisterSource("users", src.source())
+	links := make(chan string, 2)
+	env.exporter.UseNotifier(func(ctx context.Context, job Job, link string) error {
+		links <- job.Status + ":" + link
+		return nil
+	})
+
+	job, err := env.exporter.StartExport(context.Background(), ExportSpec{Source: "users", UserID: "alice"})
+	require.NoError(t, err)
+	s := env.waitFor(t, job.ID, "alice", StatusFailed)
+	assert.Equal(t, int64(449), s.Rows, "保留部分进度")
+	assert.Equal(t, 44, s.Progress)
+	assert.Contains(t, s.Error, "connection reset")
+	assert.Empty(t, s.DownloadURL)
+
+	_, err = env.exporter.Resume(context.Background(), job.ID)
+	require.NoError(t, err)
+	s = env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Equal(t, []string{"", "449"}, src.openCursors())
+	assertSequential(t, env.download(t, s.DownloadURL), 1000)
+	assert.Equal(t, "failed:", <-links)
+	assert.True(t, strings.HasPrefix(<-links, "done:/files/exports/"))
+}
+
+func TestExport_PerUserLimit(t *testing.T) {
+	env := newExportEnv(t, Config{MaxPerUser: 2})
+	block := make(chan struct{})
+	src := &syntheticSource{total: 10, resumable: true, block: block}
+	env.exporter.RegisterSource("users", src.source())
+	ctx := context.Background()
+
+	first, err := env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "alice"})
+	require.NoError(t, err)
+	_, err = env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "alice"})
+	require.NoError(t, err)
+	_, err = env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "alice"})
+	assert.ErrorIs(t, err, ErrTooManyExports)
+	_, err = env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "bob"})
+	assert.NoError(t, err, "其他用户不受影响")
+	_, err = env.exporter.StartExport(ctx, ExportSpec{Source: "missing", UserID: "bob"})
+	assert.ErrorIs(t, err, ErrUnknownSource)
+
+	close(block)
+	env.waitFor(t, first.ID, "alice", StatusDone)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err = env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "alice"}); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.NoError(t, err, "任务完成后可以再次导出")
+}
+
+func TestExport_Cleanup(t *testing.T) {
+	env := newExportEnv(t, Config{Retention: 3600})
+	src := &syntheticSource{total: 10, resumable: true}
+	env.exporter.RegisterSource("users", src.source())
+	ctx := context.Background()
+
+	job, err := env.exporter.StartExport(ctx, ExportSpec{Source: "users", UserID: "alice"})
+	require.NoError(t, err)
+	s := env.waitFor(t, job.ID, "alice", StatusDone)
+	assert.Equal(t, env.clock.Now().Add(time.Hour), s.ExpiresAt)
+
+	n, err := env.exporter.Cleanup(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	env.clock.Advance(time.Hour + time.Second)
+	n, err = env.exporter.Cleanup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = env.files.Open(ctx, job.Key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	s = env.waitFor(t, job.ID, "alice", StatusExpired)
+	assert.Empty(t, s.DownloadURL)
+	link, err := env.files.SignedURL(ctx, job.Key, "a.csv", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 404, ut.PerformRequest(env.engine, "GET", link, nil).Code)
+}
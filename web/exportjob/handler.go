@@ -0,0 +1,62 @@
+package exportjob
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/notify"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Status 任务状态接口的响应
+type Status struct {
+	Job
+	Progress    int    `json:"progress"`              // 进度百分比，未知时为 -1
+	DownloadURL string `json:"downloadUrl,omitempty"` // 已完成时的限时下载链接
+}
+
+// StatusHandler 任务状态接口（路由需要 :id 参数，放在 JWT 中间件之后）
+//
+// 只有发起导出的用户可以查询，其他用户得到 404；已完成的任务每次查询生成新的下载链接
+func (e *Exporter) StatusHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		job, err := e.store.Get(ctx, c.Param("id"))
+		if errors.Is(err, ErrNotFound) || (err == nil && job.UserID != jwt.GetUserID(c)) {
+			panic(web.NotFoundHTTP("导出任务不存在"))
+		}
+		if err != nil {
+			logger.Errorf("[Export] 查询任务失败: %v", err)
+			panic(web.InternalHTTP("查询导出任务失败"))
+		}
+		status := Status{Job: job, Progress: job.Progress()}
+		if job.Status == StatusDone {
+			if status.DownloadURL, err = e.DownloadURL(ctx, job); err != nil {
+				logger.Errorf("[Export] 生成任务 %s 下载链接失败: %v", job.ID, err)
+				panic(web.InternalHTTP("生成下载链接失败"))
+			}
+		}
+		c.JSON(consts.StatusOK, web.Success(status))
+	}
+}
+
+// NotifyWith 通过通知模块发送导出结果（模板数据：Filename、Rows、URL，失败时 URL 为空）
+//
+// 使用方式：
+//
+//	exporter.UseNotifier(exportjob.NotifyWith(scheduler, notify.ChannelEmail, "exportReady"))
+func NotifyWith(scheduler *notify.Scheduler, channel, templateName string) Notifier {
+	return func(ctx context.Context, job Job, url string) error {
+		_, err := scheduler.Schedule(ctx, notify.Notification{
+			UserID:       job.UserID,
+			Channel:      channel,
+			TemplateName: templateName,
+			Data:         map[string]any{"Filename": job.Filename, "Rows": job.Rows, "URL": url},
+			DedupeKey:    "export-" + job.ID + "-" + job.Status,
+		})
+		return err
+	}
+}
@@ -0,0 +1,101 @@
+package exportjob
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store 导出任务存储
+//
+// 进度和游标随任务保存，进程重启后从 checkpoint 续写。多实例部署时用数据库实现，
+// Create 需要在同一事务中检查用户进行中的任务数
+type Store interface {
+	// Create 写入新任务；用户进行中的任务已达 maxActive 时返回 ErrTooManyExports
+	Create(ctx context.Context, job Job, maxActive int) error
+	// Get 查询任务，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id string) (Job, error)
+	// Update 保存任务状态与进度
+	Update(ctx context.Context, job Job) error
+	// ListActive 查询进行中（queued / running）的任务
+	ListActive(ctx context.Context) ([]Job, error)
+	// ListExpired 查询已完成或失败、ExpiresAt 早于 now 的任务
+	ListExpired(ctx context.Context, now time.Time) ([]Job, error)
+}
+
+// MemoryStore 进程内任务存储（单实例部署与测试使用，重启后丢失）
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job)}
+}
+
+// Create 实现 Store 接口
+func (s *MemoryStore) Create(ctx context.Context, job Job, maxActive int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := 0
+	for _, j := range s.jobs {
+		if j.UserID == job.UserID && j.Active() {
+			active++
+		}
+	}
+	if maxActive > 0 && active >= maxActive {
+		return ErrTooManyExports
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// Get 实现 Store 接口
+func (s *MemoryStore) Get(ctx context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// Update 实现 Store 接口
+func (s *MemoryStore) Update(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return ErrNotFound
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+// ListActive 实现 Store 接口
+func (s *MemoryStore) ListActive(ctx context.Context) ([]Job, error) {
+	return s.list(func(j Job) bool { return j.Active() }), nil
+}
+
+// ListExpired 实现 Store 接口
+func (s *MemoryStore) ListExpired(ctx context.Context, now time.Time) ([]Job, error) {
+	return s.list(func(j Job) bool {
+		return (j.Status == StatusDone || j.Status == StatusFailed) && !j.ExpiresAt.IsZero() && j.ExpiresAt.Before(now)
+	}), nil
+}
+
+// list 按创建时间排序返回符合条件的任务
+func (s *MemoryStore) list(match func(Job) bool) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Job
+	for _, j := range s.jobs {
+		if match(j) {
+			out = append(out, j)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out
+}
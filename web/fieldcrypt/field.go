@@ -0,0 +1,118 @@
+package fieldcrypt
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/CenJIl/base/web/mask"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// EncryptedString 随机模式加密的字符串字段
+//
+// 写库时自动加密、读库时自动解密，程序中持有的是明文。
+// 为避免意外泄露，JSON 序列化和 %v 打印输出的是脱敏值（mask.Auto）；
+// 需要原文时用 Plain()，按调用方权限返回时用 Reveal(c)。
+// 空字符串不加密，按空字符串存储；NULL 读出为空字符串
+//
+// 在 sqlc.yaml 中覆盖列类型即可直接用于生成的模型（列长度建议 VARCHAR(255)）：
+//
+//	overrides:
+//	  - column: "users.phone"
+//	    go_type: "github.com/CenJIl/base/web/fieldcrypt.EncryptedString"
+type EncryptedString string
+
+// Plain 明文
+func (s EncryptedString) Plain() string {
+	return string(s)
+}
+
+// Reveal 有查看权限（mask.CanReveal）时返回明文，否则返回脱敏值
+func (s EncryptedString) Reveal(c *app.RequestContext) string {
+	return mask.Reveal(c, string(s))
+}
+
+// String 脱敏值（防止日志打印出明文）
+func (s EncryptedString) String() string {
+	return mask.Auto(string(s))
+}
+
+// MarshalJSON 输出脱敏值
+func (s EncryptedString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mask.Auto(string(s)))
+}
+
+// Value 实现 driver.Valuer：加密后写库
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return EncryptField(string(s))
+}
+
+// Scan 实现 sql.Scanner：读库后解密
+func (s *EncryptedString) Scan(src any) error {
+	plain, err := scanEncrypted(src)
+	*s = EncryptedString(plain)
+	return err
+}
+
+// SearchableString 确定性模式加密的字符串字段（可用于等值查询和唯一索引）
+//
+// 与 EncryptedString 的区别只在加密模式，取舍见包文档。
+// 作为查询参数时只生成最新密钥下的密文，密钥轮换后、ReencryptTable 完成前需改用 SearchValues
+type SearchableString string
+
+// Plain 明文
+func (s SearchableString) Plain() string {
+	return string(s)
+}
+
+// Reveal 有查看权限（mask.CanReveal）时返回明文，否则返回脱敏值
+func (s SearchableString) Reveal(c *app.RequestContext) string {
+	return mask.Reveal(c, string(s))
+}
+
+// String 脱敏值（防止日志打印出明文）
+func (s SearchableString) String() string {
+	return mask.Auto(string(s))
+}
+
+// MarshalJSON 输出脱敏值
+func (s SearchableString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mask.Auto(string(s)))
+}
+
+// Value 实现 driver.Valuer：确定性加密后写库
+func (s SearchableString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return EncryptDeterministic(string(s))
+}
+
+// Scan 实现 sql.Scanner：读库后解密
+func (s *SearchableString) Scan(src any) error {
+	plain, err := scanEncrypted(src)
+	*s = SearchableString(plain)
+	return err
+}
+
+func scanEncrypted(src any) (string, error) {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+		return "", nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return "", fmt.Errorf("fieldcrypt: 不支持从 %T 读取加密字段", src)
+	}
+	if raw == "" {
+		return "", nil
+	}
+	return DecryptField(raw)
+}
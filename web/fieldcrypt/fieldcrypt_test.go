@@ -0,0 +1,368 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func mustRing(t *testing.T, keys map[uint32][]byte) *KeyRing {
+	r, err := NewKeyRing(keys)
+	require.NoError(t, err)
+	return r
+}
+
+func TestKeyRing_RoundTrip(t *testing.T) {
+	r := mustRing(t, map[uint32][]byte{1: testKey(1)})
+
+	a, err := r.Encrypt("13812345678")
+	require.NoError(t, err)
+	b, err := r.Encrypt("13812345678")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b, "随机模式相同明文的密文不同")
+	assert.True(t, strings.HasPrefix(a, "fc1:r:1:"))
+	assert.NotContains(t, a, "13812345678")
+
+	for _, c := range []string{a, b} {
+		plain, err := r.Decrypt(c)
+		require.NoError(t, err)
+		assert.Equal(t, "13812345678", plain)
+	}
+
+	empty, err := r.Encrypt("")
+	require.NoError(t, err)
+	plain, err := r.Decrypt(empty)
+	require.NoError(t, err)
+	assert.Equal(t, "", plain)
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	old := mustRing(t, map[uint32][]byte{1: testKey(1)})
+	c1, err := old.Encrypt("110101199001011234")
+	require.NoError(t, err)
+
+	// 追加新密钥后：新加密使用 2，旧密文仍可解密
+	rotated := mustRing(t, map[uint32][]byte{1: testKey(1), 2: testKey(2)})
+	assert.Equal(t, uint32(2), rotated.NewestKeyID())
+	c2, err := rotated.Encrypt("110101199001011234")
+	require.NoError(t, err)
+	id, err := KeyIDOf(c2)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), id)
+
+	plain, err := rotated.Decrypt(c1)
+	require.NoError(t, err)
+	assert.Equal(t, "110101199001011234", plain)
+
+	// 升级旧密文
+	up, changed, err := rotated.Reencrypt(c1)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	id, _ = KeyIDOf(up)
+	assert.Equal(t, uint32(2), id)
+	_, changed, err = rotated.Reencrypt(up)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// 移除旧密钥后，未升级的密文无法解密
+	retired := mustRing(t, map[uint32][]byte{2: testKey(2)})
+	_, err = retired.Decrypt(c1)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	plain, err = retired.Decrypt(up)
+	require.NoError(t, err)
+	assert.Equal(t, "110101199001011234", plain)
+}
+
+func TestKeyRing_TamperDetection(t *testing.T) {
+	r := mustRing(t, map[uint32][]byte{1: testKey(1), 2: testKey(2)})
+	c, err := r.Encrypt("13812345678")
+	require.NoError(t, err)
+
+	// 翻转密文中的一个比特
+	i := strings.LastIndex(c, ":") + 1
+	payload, err := base64.RawURLEncoding.DecodeString(c[i:])
+	require.NoError(t, err)
+	payload[len(payload)/2] ^= 0x01
+	flipped := c[:i] + base64.RawURLEncoding.EncodeToString(payload)
+	_, err = r.Decrypt(flipped)
+	assert.ErrorIs(t, err, ErrTampered)
+
+	// 修改信封头中的密钥编号或模式
+	_, err = r.Decrypt(strings.Replace(c, "fc1:r:2:", "fc1:r:1:", 1))
+	assert.ErrorIs(t, err, ErrTampered)
+	_, err = r.Decrypt(strings.Replace(c, "fc1:r:2:", "fc1:d:2:", 1))
+	assert.ErrorIs(t, err, ErrTampered)
+
+	// 截断与格式错误
+	_, err = r.Decrypt(c[:i+4])
+	assert.ErrorIs(t, err, ErrMalformed)
+	for _, bad := range []string{"", "13812345678", "fc1:x:1:AAAA", "fc1:r:abc:AAAA", "fc1:r:1:!!!"} {
+		_, err = r.Decrypt(bad)
+		assert.ErrorIs(t, err, ErrMalformed, bad)
+		assert.False(t, IsEncrypted(bad))
+	}
+
+	// 其他密钥环的密文（编号相同、密钥不同）
+	other := mustRing(t, map[uint32][]byte{2: testKey(9)})
+	foreign, err := other.Encrypt("13812345678")
+	require.NoError(t, err)
+	_, err = r.Decrypt(foreign)
+	assert.ErrorIs(t, err, ErrTampered)
+}
+
+func TestKeyRing_DeterministicEquality(t *testing.T) {
+	r := mustRing(t, map[uint32][]byte{1: testKey(1)})
+
+	a, err := r.EncryptDeterministic("13812345678")
+	require.NoError(t, err)
+	b, err := r.EncryptDeterministic("13812345678")
+	require.NoError(t, err)
+	c, err := r.EncryptDeterministic("13812345679")
+	require.NoError(t, err)
+	assert.Equal(t, a, b, "相同明文得到相同密文")
+	assert.NotEqual(t, a, c)
+	assert.True(t, strings.HasPrefix(a, "fc1:d:1:"))
+
+	plain, err := r.Decrypt(a)
+	require.NoError(t, err)
+	assert.Equal(t, "13812345678", plain)
+
+	// 与随机模式密文不同
+	random, _ := r.Encrypt("13812345678")
+	assert.NotEqual(t, a, random)
+
+	// 轮换后：新密钥下密文不同，SearchValues 覆盖所有密钥
+	rotated := mustRing(t, map[uint32][]byte{1: testKey(1), 2: testKey(2)})
+	d, err := rotated.EncryptDeterministic("13812345678")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, d)
+	values, err := rotated.SearchValues("13812345678")
+	require.NoError(t, err)
+	assert.Equal(t, []string{d, a}, values)
+
+	// 确定性模式升级后仍是确定性密文
+	up, changed, err := rotated.Reencrypt(a)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, d, up)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	t.Setenv("FIELDCRYPT_TEST_KEY", base64.StdEncoding.EncodeToString(testKey(3)))
+	r, err := LoadKeyRing(Config{Keys: map[string]string{
+		"1": base64.StdEncoding.EncodeToString(testKey(1)),
+		"3": "env:FIELDCRYPT_TEST_KEY",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1, 3}, r.KeyIDs())
+	assert.Equal(t, uint32(3), r.NewestKeyID())
+
+	for _, keys := range []map[string]string{
+		{},
+		{"a": base64.StdEncoding.EncodeToString(testKey(1))},
+		{"1": "not base64!"},
+		{"1": base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"1": "env:FIELDCRYPT_TEST_MISSING"},
+	} {
+		_, err := LoadKeyRing(Config{Keys: keys})
+		assert.Error(t, err, "%v", keys)
+	}
+}
+
+func TestEncryptedString_SQLAndMasking(t *testing.T) {
+	SetKeyRing(nil)
+	_, err := EncryptedString("13812345678").Value()
+	assert.ErrorIs(t, err, ErrNotInitialized)
+
+	SetKeyRing(mustRing(t, map[uint32][]byte{1: testKey(1)}))
+	t.Cleanup(func() { SetKeyRing(nil) })
+
+	v, err := EncryptedString("13812345678").Value()
+	require.NoError(t, err)
+	stored := v.(string)
+	assert.True(t, IsEncrypted(stored))
+
+	var s EncryptedString
+	require.NoError(t, s.Scan([]byte(stored)))
+	assert.Equal(t, "13812345678", s.Plain())
+	require.NoError(t, s.Scan(nil))
+	assert.Equal(t, "", s.Plain())
+	assert.ErrorIs(t, s.Scan("fc1:r:1:AAAA"), ErrMalformed)
+
+	// 空字符串按空字符串存储
+	v, err = EncryptedString("").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "", v)
+
+	// 序列化与打印都是脱敏值，未登录的调用方也只能看到脱敏值
+	s = "13812345678"
+	out, err := json.Marshal(struct {
+		Phone EncryptedString `json:"phone"`
+	}{s})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"phone":"138****5678"}`, string(out))
+	assert.Equal(t, "138****5678", fmt.Sprintf("%v", s))
+	assert.Equal(t, "138****5678", s.Reveal(nil))
+
+	// 确定性字段：相同明文写库的值相同，可直接作为查询参数
+	v1, err := SearchableString("110101199001011234").Value()
+	require.NoError(t, err)
+	v2, err := SearchableString("110101199001011234").Value()
+	require.NoError(t, err)
+	assert.Equal(t, v1, v2)
+	var id SearchableString
+	require.NoError(t, id.Scan(v1))
+	assert.Equal(t, "110101199001011234", id.Plain())
+	assert.Equal(t, "110***********1234", id.String())
+}
+
+// memoryTable 内存表（模拟 SQLTable 的语义）
+type memoryTable struct {
+	mu      sync.Mutex
+	rows    map[int64]map[string]string
+	batches int
+	failAt  int // 第 failAt 次 Batch 返回错误（模拟中断）
+	onBatch func()
+}
+
+func (m *memoryTable) Batch(ctx context.Context, afterID int64, limit int) ([]Row, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches++
+	if m.failAt > 0 && m.batches == m.failAt {
+		return nil, errors.New("connection lost")
+	}
+	var ids []int64
+	for id := range m.rows {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	batch := make([]Row, 0, len(ids))
+	for _, id := range ids {
+		values := make(map[string]string)
+		for k, v := range m.rows[id] {
+			if v != "" {
+				values[k] = v
+			}
+		}
+		batch = append(batch, Row{ID: id, Values: values})
+	}
+	if m.onBatch != nil {
+		m.onBatch()
+	}
+	return batch, nil
+}
+
+func (m *memoryTable) Update(ctx context.Context, id int64, column, old, new string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rows[id][column] != old {
+		return false, nil
+	}
+	m.rows[id][column] = new
+	return true, nil
+}
+
+func TestReencryptTable(t *testing.T) {
+	old := mustRing(t, map[uint32][]byte{1: testKey(1)})
+	rotated := mustRing(t, map[uint32][]byte{1: testKey(1), 2: testKey(2)})
+
+	table := &memoryTable{rows: make(map[int64]map[string]string)}
+	for id := int64(1); id <= 25; id++ {
+		phone, _ := old.Encrypt(fmt.Sprintf("138%08d", id))
+		idCard, _ := old.EncryptDeterministic(fmt.Sprintf("1101011990%08d", id))
+		table.rows[id] = map[string]string{"phone": phone, "id_card": idCard}
+	}
+	// 已是新密钥的行、空值和损坏的数据
+	table.rows[5]["phone"], _ = rotated.Encrypt("13800000005")
+	table.rows[6]["phone"] = ""
+	table.rows[7]["id_card"] = "fc1:d:9:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+	// 第 3 批时中断
+	checkpoint := FileCheckpoint(filepath.Join(t.TempDir(), "users.reencrypt"))
+	table.failAt = 3
+	stats, err := ReencryptTable(context.Background(), rotated, table, ReencryptOptions{BatchSize: 10, Checkpoint: checkpoint})
+	require.Error(t, err)
+	assert.Equal(t, int64(20), stats.LastID)
+	saved, err := checkpoint.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), saved)
+
+	// 从断点继续，只扫描剩余的行
+	table.failAt = 0
+	stats, err = ReencryptTable(context.Background(), rotated, table, ReencryptOptions{BatchSize: 10, Checkpoint: checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.Scanned)
+	assert.Equal(t, 10, stats.Upgraded)
+	assert.Equal(t, int64(25), stats.LastID)
+
+	for id, row := range table.rows {
+		for column, value := range row {
+			if value == "" || (id == 7 && column == "id_card") {
+				continue
+			}
+			kid, err := KeyIDOf(value)
+			require.NoError(t, err)
+			assert.Equal(t, uint32(2), kid, "row %d %s", id, column)
+			_, err = rotated.Decrypt(value)
+			assert.NoError(t, err)
+		}
+	}
+	plain, err := rotated.Decrypt(table.rows[12]["phone"])
+	require.NoError(t, err)
+	assert.Equal(t, "13800000012", plain)
+	det, _ := rotated.EncryptDeterministic("110101199000000012")
+	assert.Equal(t, det, table.rows[12]["id_card"], "确定性字段升级后仍可等值查询")
+
+	// 再跑一遍（无断点）：全部已是新密钥，只有损坏的一列失败
+	stats, err = ReencryptTable(context.Background(), rotated, table, ReencryptOptions{BatchSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 25, stats.Scanned)
+	assert.Equal(t, 0, stats.Upgraded)
+	assert.Equal(t, 1, stats.Failed)
+}
+
+func TestReencryptTable_ConcurrentWriteAndRateLimit(t *testing.T) {
+	old := mustRing(t, map[uint32][]byte{1: testKey(1)})
+	rotated := mustRing(t, map[uint32][]byte{1: testKey(1), 2: testKey(2)})
+
+	table := &memoryTable{rows: make(map[int64]map[string]string)}
+	for id := int64(1); id <= 4; id++ {
+		phone, _ := old.Encrypt("13800000000")
+		table.rows[id] = map[string]string{"phone": phone}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ReencryptTable(ctx, rotated, table, ReencryptOptions{BatchSize: 2, RowsPerSecond: 1})
+	assert.ErrorIs(t, err, context.Canceled, "限速等待响应取消")
+
+	// 扫描之后、更新之前，应用写入了新值：不能被覆盖
+	fresh, _ := rotated.Encrypt("13900000000")
+	table.onBatch = func() { table.rows[2]["phone"] = fresh }
+
+	stats, err := ReencryptTable(context.Background(), rotated, table, ReencryptOptions{BatchSize: 2, RowsPerSecond: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Skipped)
+	assert.Equal(t, 3, stats.Upgraded)
+	assert.Equal(t, fresh, table.rows[2]["phone"])
+}
@@ -0,0 +1,349 @@
+// Package fieldcrypt 字段级加密：手机号、身份证号等敏感字段加密后落库
+//
+// 使用 AES-256-GCM，密钥环中的每个密钥有编号，加密总是使用编号最大的密钥，
+// 密文信封中带有密钥编号，解密时按编号选择密钥。轮换密钥时只需追加新密钥，
+// 旧数据仍可解密，再用 ReencryptTable 分批升级到新密钥即可，不需要一次性改写全表。
+//
+// 两种模式：
+//
+//   - 随机模式（EncryptField / EncryptedString）：每次加密使用随机 nonce，相同明文得到不同密文。
+//     不泄露任何信息，但无法在数据库中按值查询。默认使用此模式。
+//   - 确定性模式（EncryptDeterministic / SearchableString）：nonce 由明文的 HMAC 派生（SIV 结构），
+//     相同明文在同一密钥下总是得到相同密文，可以用 WHERE phone = ? 等值查询和建唯一索引。
+//     代价是泄露"两行的值是否相等"：攻击者能看出哪些行的手机号相同，并可对取值空间小的字段
+//     （如性别、省份）做频率分析。只对必须等值查询的字段使用，不支持范围查询和模糊查询。
+//     轮换期间同一明文在新旧密钥下的密文不同，查询需用 SearchValues 生成所有密钥的密文（IN 查询），
+//     或者在 ReencryptTable 完成后再只按新密钥查询。
+//
+// 使用方式：
+//
+//	[web.fieldCrypt.keys]
+//	1 = "env:FIELD_KEY_1"     # 从环境变量读取（推荐）
+//	2 = "<base64>"            # base64 编码的 32 字节密钥，可用 openssl rand -base64 32 生成
+//
+//	if err := fieldcrypt.Init(config.FieldCrypt); err != nil {
+//	    panic(err)
+//	}
+//	cipher, _ := fieldcrypt.EncryptField("13812345678")
+//	plain, _ := fieldcrypt.DecryptField(cipher)
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// ErrNotInitialized 未调用 Init
+	ErrNotInitialized = errors.New("fieldcrypt: not initialized")
+	// ErrUnknownKey 密文使用的密钥不在密钥环中
+	ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+	// ErrMalformed 不是合法的密文信封
+	ErrMalformed = errors.New("fieldcrypt: malformed ciphertext")
+	// ErrTampered 密文被篡改或密钥不匹配（认证失败）
+	ErrTampered = errors.New("fieldcrypt: ciphertext authentication failed")
+)
+
+// 密文信封：fc1:<模式>:<密钥编号>:<base64(nonce || 密文 || tag)>
+//
+// 信封头作为 GCM 的附加数据参与认证，修改模式或密钥编号同样会导致解密失败
+const envelopePrefix = "fc1"
+
+// 加密模式
+const (
+	ModeRandom        = "r" // 随机 nonce
+	ModeDeterministic = "d" // 明文派生 nonce
+)
+
+// Config 字段加密配置
+type Config struct {
+	// Keys 密钥编号 -> base64 编码的 32 字节密钥；值为 "env:NAME" 时从环境变量读取
+	Keys map[string]string `toml:"keys" sensitive:"true"`
+}
+
+// key 密钥环中的一个密钥（由主密钥派生出各用途的子密钥）
+type key struct {
+	id       uint32
+	random   cipher.AEAD // 随机模式
+	det      cipher.AEAD // 确定性模式
+	nonceKey []byte      // 确定性模式的 nonce 派生密钥
+}
+
+// KeyRing 密钥环
+type KeyRing struct {
+	keys   map[uint32]*key
+	newest *key
+}
+
+// NewKeyRing 由编号 -> 32 字节主密钥创建密钥环
+func NewKeyRing(keys map[uint32][]byte) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("fieldcrypt: 至少需要一个密钥")
+	}
+	r := &KeyRing{keys: make(map[uint32]*key, len(keys))}
+	for id, master := range keys {
+		if len(master) != 32 {
+			return nil, fmt.Errorf("fieldcrypt: 密钥 %d 长度为 %d 字节，需要 32 字节", id, len(master))
+		}
+		k := &key{id: id, nonceKey: derive(master, "nonce")}
+		var err error
+		if k.random, err = newGCM(derive(master, "random")); err != nil {
+			return nil, err
+		}
+		if k.det, err = newGCM(derive(master, "deterministic")); err != nil {
+			return nil, err
+		}
+		r.keys[id] = k
+		if r.newest == nil || id > r.newest.id {
+			r.newest = k
+		}
+	}
+	return r, nil
+}
+
+// LoadKeyRing 按配置创建密钥环
+func LoadKeyRing(config Config) (*KeyRing, error) {
+	keys := make(map[uint32][]byte, len(config.Keys))
+	for name, value := range config.Keys {
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: 密钥编号 %q 必须是数字", name)
+		}
+		if env, ok := strings.CutPrefix(value, "env:"); ok {
+			value = os.Getenv(env)
+			if value == "" {
+				return nil, fmt.Errorf("fieldcrypt: 环境变量 %s 未设置", env)
+			}
+		}
+		master, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: 密钥 %s 不是合法的 base64: %w", name, err)
+		}
+		keys[uint32(id)] = master
+	}
+	return NewKeyRing(keys)
+}
+
+func derive(master []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("fieldcrypt:" + purpose))
+	return mac.Sum(nil)
+}
+
+func newGCM(k []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewestKeyID 加密使用的密钥编号
+func (r *KeyRing) NewestKeyID() uint32 {
+	return r.newest.id
+}
+
+// KeyIDs 全部密钥编号（升序）
+func (r *KeyRing) KeyIDs() []uint32 {
+	ids := make([]uint32, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Encrypt 随机模式加密（使用最新密钥）
+func (r *KeyRing) Encrypt(plaintext string) (string, error) {
+	return r.seal(r.newest, ModeRandom, plaintext)
+}
+
+// EncryptDeterministic 确定性模式加密（使用最新密钥）
+func (r *KeyRing) EncryptDeterministic(plaintext string) (string, error) {
+	return r.seal(r.newest, ModeDeterministic, plaintext)
+}
+
+// SearchValues 明文在每个密钥下的确定性密文（轮换期间用于 IN 查询）
+func (r *KeyRing) SearchValues(plaintext string) ([]string, error) {
+	ids := r.KeyIDs()
+	values := make([]string, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		v, err := r.seal(r.keys[ids[i]], ModeDeterministic, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Decrypt 解密（按信封中的模式和密钥编号）
+func (r *KeyRing) Decrypt(ciphertext string) (string, error) {
+	mode, id, payload, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	k, ok := r.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+	aead := k.random
+	if mode == ModeDeterministic {
+		aead = k.det
+	}
+	ns := aead.NonceSize()
+	if len(payload) < ns+aead.Overhead() {
+		return "", ErrMalformed
+	}
+	plain, err := aead.Open(nil, payload[:ns], payload[ns:], []byte(header(mode, id)))
+	if err != nil {
+		return "", ErrTampered
+	}
+	return string(plain), nil
+}
+
+// Reencrypt 用最新密钥重新加密（保持原模式），已经是最新密钥时原样返回、changed 为 false
+func (r *KeyRing) Reencrypt(ciphertext string) (result string, changed bool, err error) {
+	mode, id, _, err := parseEnvelope(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	if id == r.newest.id {
+		return ciphertext, false, nil
+	}
+	plain, err := r.Decrypt(ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	result, err = r.seal(r.newest, mode, plain)
+	return result, err == nil, err
+}
+
+func (r *KeyRing) seal(k *key, mode, plaintext string) (string, error) {
+	aead := k.random
+	nonce := make([]byte, aead.NonceSize())
+	if mode == ModeDeterministic {
+		aead = k.det
+		mac := hmac.New(sha256.New, k.nonceKey)
+		mac.Write([]byte(plaintext))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	h := header(mode, k.id)
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(h))
+	return h + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func header(mode string, id uint32) string {
+	return envelopePrefix + ":" + mode + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+func parseEnvelope(s string) (mode string, id uint32, payload []byte, err error) {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 || parts[0] != envelopePrefix || (parts[1] != ModeRandom && parts[1] != ModeDeterministic) {
+		return "", 0, nil, ErrMalformed
+	}
+	n, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return "", 0, nil, ErrMalformed
+	}
+	payload, err = base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", 0, nil, ErrMalformed
+	}
+	return parts[1], uint32(n), payload, nil
+}
+
+// IsEncrypted 是否为本包生成的密文（用于识别尚未加密的历史明文）
+func IsEncrypted(s string) bool {
+	_, _, _, err := parseEnvelope(s)
+	return err == nil
+}
+
+// KeyIDOf 密文使用的密钥编号
+func KeyIDOf(ciphertext string) (uint32, error) {
+	_, id, _, err := parseEnvelope(ciphertext)
+	return id, err
+}
+
+var defaultRing atomic.Pointer[KeyRing]
+
+// Init 按配置初始化全局密钥环（EncryptField / EncryptedString 等使用）
+func Init(config Config) error {
+	r, err := LoadKeyRing(config)
+	if err != nil {
+		return err
+	}
+	SetKeyRing(r)
+	return nil
+}
+
+// SetKeyRing 设置全局密钥环
+func SetKeyRing(r *KeyRing) {
+	defaultRing.Store(r)
+}
+
+// Default 全局密钥环（未初始化时为 nil）
+func Default() *KeyRing {
+	return defaultRing.Load()
+}
+
+func ring() (*KeyRing, error) {
+	if r := defaultRing.Load(); r != nil {
+		return r, nil
+	}
+	return nil, ErrNotInitialized
+}
+
+// EncryptField 随机模式加密
+func EncryptField(plaintext string) (string, error) {
+	r, err := ring()
+	if err != nil {
+		return "", err
+	}
+	return r.Encrypt(plaintext)
+}
+
+// EncryptDeterministic 确定性模式加密（可等值查询，见包文档中的取舍说明）
+func EncryptDeterministic(plaintext string) (string, error) {
+	r, err := ring()
+	if err != nil {
+		return "", err
+	}
+	return r.EncryptDeterministic(plaintext)
+}
+
+// SearchValues 明文在全部密钥下的确定性密文
+//
+// 使用方式：
+//
+//	values, _ := fieldcrypt.SearchValues(phone)
+//	user, err := queries.GetUserByPhones(ctx, values)   // WHERE phone IN (sqlc.slice('phones'))
+func SearchValues(plaintext string) ([]string, error) {
+	r, err := ring()
+	if err != nil {
+		return nil, err
+	}
+	return r.SearchValues(plaintext)
+}
+
+// DecryptField 解密（两种模式通用）
+func DecryptField(ciphertext string) (string, error) {
+	r, err := ring()
+	if err != nil {
+		return "", err
+	}
+	return r.Decrypt(ciphertext)
+}
@@ -0,0 +1,219 @@
+package fieldcrypt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/database"
+	"golang.org/x/time/rate"
+)
+
+// Row 待升级的一行（只包含加密列）
+type Row struct {
+	ID     int64
+	Values map[string]string // 列名 -> 密文（NULL 或空字符串不出现）
+}
+
+// Table 可分批扫描并逐列更新的表
+type Table interface {
+	// Batch 按 ID 升序返回 ID 大于 afterID 的最多 limit 行
+	Batch(ctx context.Context, afterID int64, limit int) ([]Row, error)
+	// Update 把一列从 old 改为 new；值已被其他写入修改时返回 false（乐观并发，不覆盖新数据）
+	Update(ctx context.Context, id int64, column, old, new string) (bool, error)
+}
+
+// Checkpoint 保存扫描进度，中断后从上次位置继续
+type Checkpoint interface {
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, lastID int64) error
+}
+
+// FileCheckpoint 把进度保存在本地文件中
+type FileCheckpoint string
+
+// Load 实现 Checkpoint 接口（文件不存在时从头开始）
+func (f FileCheckpoint) Load(ctx context.Context) (int64, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Save 实现 Checkpoint 接口
+func (f FileCheckpoint) Save(ctx context.Context, lastID int64) error {
+	return os.WriteFile(string(f), []byte(strconv.FormatInt(lastID, 10)), 0o644)
+}
+
+// ReencryptOptions 批量升级选项
+type ReencryptOptions struct {
+	BatchSize     int        // 每批行数，默认 500
+	RowsPerSecond int        // 限速（行/秒），0 表示不限速
+	Checkpoint    Checkpoint // 为 nil 时每次从头扫描（已是最新密钥的行会被跳过）
+}
+
+// ReencryptStats 升级结果
+type ReencryptStats struct {
+	Scanned  int   // 扫描的行数
+	Upgraded int   // 重新加密的列数
+	Skipped  int   // 扫描期间被其他写入修改、未覆盖的列数
+	Failed   int   // 无法解密的列数（密钥缺失或数据损坏，记录日志后跳过）
+	LastID   int64 // 最后处理的行 ID
+}
+
+// ReencryptTable 把表中旧密钥加密的值分批升级到最新密钥
+//
+// 轮换流程：在密钥环中追加新密钥并发布（新写入立即使用新密钥）→ 运行 ReencryptTable →
+// 全部完成后才能从密钥环中移除旧密钥。任务可以随时中断，配置 Checkpoint 后从上次位置继续
+//
+// 使用方式：
+//
+//	stats, err := fieldcrypt.ReencryptTable(ctx, fieldcrypt.Default(),
+//	    fieldcrypt.NewSQLTable(nil, config.Database.Driver, "users", "id", "phone", "id_card"),
+//	    fieldcrypt.ReencryptOptions{RowsPerSecond: 1000, Checkpoint: fieldcrypt.FileCheckpoint("users.reencrypt")})
+func ReencryptTable(ctx context.Context, ring *KeyRing, table Table, opts ReencryptOptions) (ReencryptStats, error) {
+	var stats ReencryptStats
+	if ring == nil {
+		return stats, ErrNotInitialized
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	var limiter *rate.Limiter
+	if opts.RowsPerSecond > 0 {
+		burst := opts.BatchSize
+		if burst < opts.RowsPerSecond {
+			burst = opts.RowsPerSecond
+		}
+		limiter = rate.NewLimiter(rate.Limit(opts.RowsPerSecond), burst)
+	}
+
+	if opts.Checkpoint != nil {
+		lastID, err := opts.Checkpoint.Load(ctx)
+		if err != nil {
+			return stats, fmt.Errorf("读取进度失败: %w", err)
+		}
+		stats.LastID = lastID
+	}
+
+	for {
+		rows, err := table.Batch(ctx, stats.LastID, opts.BatchSize)
+		if err != nil {
+			return stats, err
+		}
+		if len(rows) == 0 {
+			return stats, nil
+		}
+		if limiter != nil {
+			if err := limiter.WaitN(ctx, len(rows)); err != nil {
+				return stats, err
+			}
+		}
+
+		for _, row := range rows {
+			for column, old := range row.Values {
+				upgraded, changed, err := ring.Reencrypt(old)
+				if err != nil {
+					stats.Failed++
+					logger.Warnf("[FieldCrypt] 行 %d 列 %s 无法升级: %v", row.ID, column, err)
+					continue
+				}
+				if !changed {
+					continue
+				}
+				ok, err := table.Update(ctx, row.ID, column, old, upgraded)
+				if err != nil {
+					return stats, err
+				}
+				if ok {
+					stats.Upgraded++
+				} else {
+					stats.Skipped++
+				}
+			}
+			stats.Scanned++
+			stats.LastID = row.ID
+		}
+
+		if opts.Checkpoint != nil {
+			if err := opts.Checkpoint.Save(ctx, stats.LastID); err != nil {
+				return stats, fmt.Errorf("保存进度失败: %w", err)
+			}
+		}
+		if len(rows) < opts.BatchSize {
+			return stats, nil
+		}
+	}
+}
+
+// SQLTable 数据库表（MySQL / PostgreSQL），ID 列需为递增整数
+type SQLTable struct {
+	db       *sql.DB
+	driver   string
+	name     string
+	idColumn string
+	columns  []string
+}
+
+// NewSQLTable 创建数据库表扫描器（db 为 nil 时使用 database.DB）
+//
+// 表名和列名直接拼接进 SQL，只能传入代码中的常量
+func NewSQLTable(db *sql.DB, driver, name, idColumn string, columns ...string) *SQLTable {
+	if db == nil {
+		db = database.DB
+	}
+	return &SQLTable{db: db, driver: driver, name: name, idColumn: idColumn, columns: columns}
+}
+
+// Batch 实现 Table 接口
+func (t *SQLTable) Batch(ctx context.Context, afterID int64, limit int) ([]Row, error) {
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > ? ORDER BY %s LIMIT ?",
+		t.idColumn, strings.Join(t.columns, ", "), t.name, t.idColumn, t.idColumn)
+	rows, err := t.db.QueryContext(ctx, database.Rebind(t.driver, query), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []Row
+	values := make([]sql.NullString, len(t.columns))
+	dest := make([]any, len(t.columns)+1)
+	for i := range values {
+		dest[i+1] = &values[i]
+	}
+	for rows.Next() {
+		var row Row
+		dest[0] = &row.ID
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row.Values = make(map[string]string, len(t.columns))
+		for i, column := range t.columns {
+			if values[i].Valid && values[i].String != "" {
+				row.Values[column] = values[i].String
+			}
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// Update 实现 Table 接口
+func (t *SQLTable) Update(ctx context.Context, id int64, column, old, new string) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ? AND %s = ?", t.name, column, t.idColumn, column)
+	res, err := t.db.ExecContext(ctx, database.Rebind(t.driver, query), new, id, old)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
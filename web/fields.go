@@ -0,0 +1,469 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 字段选择的请求参数（查询参数优先）
+const (
+	FieldsQueryParam = "fields"
+	HeaderFields     = "X-Fields"
+)
+
+// maxFieldPlans 缓存的序列化计划上限（字段组合由客户端决定，超过后不再缓存）
+const maxFieldPlans = 4096
+
+const fieldsetKey = "web.fieldset"
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// fieldSel 一层字段选择：all 表示选择整个字段，否则只选择 sub 中的子字段
+type fieldSel struct {
+	all bool
+	sub map[string]*fieldSel
+}
+
+// fieldset 解析后的字段选择（key 为规范化后的字符串，用作计划缓存键）
+type fieldset struct {
+	sel map[string]*fieldSel
+	key string
+}
+
+// parseFieldset 解析 "id,name,items.product.name"；同时选择整个字段和它的子字段时以整个字段为准
+func parseFieldset(raw string) (fieldset, error) {
+	root := make(map[string]*fieldSel)
+	for _, path := range strings.Split(raw, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		level := root
+		parts := strings.Split(path, ".")
+		for i, name := range parts {
+			if name == "" {
+				return fieldset{}, fmt.Errorf("无效的字段: %q", path)
+			}
+			s := level[name]
+			if s == nil {
+				s = &fieldSel{}
+				level[name] = s
+			}
+			if i == len(parts)-1 {
+				s.all, s.sub = true, nil
+				break
+			}
+			if s.all {
+				break
+			}
+			if s.sub == nil {
+				s.sub = make(map[string]*fieldSel)
+			}
+			level = s.sub
+		}
+	}
+	var b strings.Builder
+	writeFieldsetKey(&b, root)
+	return fieldset{sel: root, key: b.String()}, nil
+}
+
+func writeFieldsetKey(b *strings.Builder, sel map[string]*fieldSel) {
+	names := make([]string, 0, len(sel))
+	for name := range sel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		if s := sel[name]; !s.all {
+			b.WriteByte('(')
+			writeFieldsetKey(b, s.sub)
+			b.WriteByte(')')
+		}
+	}
+}
+
+// fieldPlan 某个结构体类型在某个字段选择下的序列化计划
+type fieldPlan struct {
+	fields []plannedField
+}
+
+type plannedField struct {
+	index     []int
+	key       []byte // 已编码的 "name":
+	omitEmpty bool
+	sub       *fieldPlan // nil 时整个字段按 encoding/json 序列化
+}
+
+// compiledPlan 计划与编译时发现的未知字段
+type compiledPlan struct {
+	plan    *fieldPlan
+	unknown []string
+}
+
+type planKey struct {
+	t      reflect.Type
+	fields string
+}
+
+var (
+	fieldPlans     sync.Map // planKey -> *compiledPlan
+	fieldPlanCount atomic.Int64
+)
+
+// fieldPlanFor 取得（或编译并缓存）类型 t 在字段选择 fs 下的序列化计划
+func fieldPlanFor(t reflect.Type, fs fieldset) *compiledPlan {
+	key := planKey{t: t, fields: fs.key}
+	if p, ok := fieldPlans.Load(key); ok {
+		return p.(*compiledPlan)
+	}
+	cp := &compiledPlan{}
+	cp.plan = compileFieldPlan(t, fs.sel, "", &cp.unknown)
+	sort.Strings(cp.unknown)
+	if fieldPlanCount.Load() < maxFieldPlans {
+		if _, loaded := fieldPlans.LoadOrStore(key, cp); !loaded {
+			fieldPlanCount.Add(1)
+		}
+	}
+	return cp
+}
+
+// jsonField 结构体中按 encoding/json 规则序列化的字段
+type jsonField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	always    bool
+	depth     int
+}
+
+// jsonFieldsOf 按 json 标签列出结构体的字段（展开内嵌结构体，浅层字段优先）
+func jsonFieldsOf(t reflect.Type) []jsonField {
+	var fields []jsonField
+	var collect func(t reflect.Type, index []int, depth int)
+	collect = func(t reflect.Type, index []int, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			idx := append(append([]int(nil), index...), i)
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					collect(ft, idx, depth+1)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fields = append(fields, jsonField{
+				name:      name,
+				index:     idx,
+				typ:       f.Type,
+				omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+				always:    f.Tag.Get("alwaysinclude") == "true",
+				depth:     depth,
+			})
+		}
+	}
+	collect(t, nil, 0)
+
+	// 同名字段只保留最浅的一个（与 encoding/json 一致）
+	shallowest := make(map[string]int, len(fields))
+	for _, f := range fields {
+		if d, ok := shallowest[f.name]; !ok || f.depth < d {
+			shallowest[f.name] = f.depth
+		}
+	}
+	out := fields[:0]
+	for _, f := range fields {
+		if f.depth == shallowest[f.name] {
+			out = append(out, f)
+			shallowest[f.name] = -1
+		}
+	}
+	return out
+}
+
+// selectableStruct 可以继续选择子字段的结构体类型（穿过指针、切片、数组）
+//
+// 自定义了 JSON 序列化的类型（如脱敏的 fieldcrypt.EncryptedString）不能选择子字段，
+// 只能整体输出，选择结果不会绕过它们的脱敏
+func selectableStruct(t reflect.Type) (reflect.Type, bool) {
+	for {
+		if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+			t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+			return nil, false
+		}
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		case reflect.Struct:
+			return t, true
+		default:
+			return nil, false
+		}
+	}
+}
+
+func compileFieldPlan(t reflect.Type, sel map[string]*fieldSel, prefix string, unknown *[]string) *fieldPlan {
+	st, ok := selectableStruct(t)
+	if !ok {
+		for name := range sel {
+			*unknown = append(*unknown, prefix+name)
+		}
+		return nil
+	}
+
+	plan := &fieldPlan{}
+	known := make(map[string]bool)
+	for _, f := range jsonFieldsOf(st) {
+		known[f.name] = true
+		s := sel[f.name]
+		if s == nil && !f.always {
+			continue
+		}
+		key, _ := json.Marshal(f.name)
+		pf := plannedField{index: f.index, key: append(key, ':'), omitEmpty: f.omitEmpty}
+		if s != nil && !s.all {
+			pf.sub = compileFieldPlan(f.typ, s.sub, prefix+f.name+".", unknown)
+		}
+		plan.fields = append(plan.fields, pf)
+	}
+	for name := range sel {
+		if !known[name] {
+			*unknown = append(*unknown, prefix+name)
+		}
+	}
+	return plan
+}
+
+// encode 按计划序列化 v（v 可以是结构体或其指针、切片）
+func (p *fieldPlan) encode(buf *bytes.Buffer, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := p.encode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case reflect.Struct:
+		buf.WriteByte('{')
+		first := true
+		for _, f := range p.fields {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.Write(f.key)
+			if f.sub != nil {
+				if err := f.sub.encode(buf, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := encodeJSONValue(buf, fv); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	return encodeJSONValue(buf, v)
+}
+
+// isEmptyValue omitempty 的判断规则（与 encoding/json 一致）
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// encodeJSONValue 按 encoding/json 序列化一个字段（MarshalJSON 等自定义序列化照常生效）
+func encodeJSONValue(buf *bytes.Buffer, v reflect.Value) error {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// fieldByIndex 与 reflect.Value.FieldByIndex 相同，经过 nil 的内嵌指针时返回 false
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// selectedFields 按计划序列化的响应数据
+type selectedFields struct {
+	value any
+	plan  *fieldPlan
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (s selectedFields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(256)
+	if err := s.plan.encode(&buf, reflect.ValueOf(s.value)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SparseFields 为路由开启字段选择（类似 JSON:API sparse fieldsets），T 为响应 data 的类型（列表接口为元素类型）
+//
+// 客户端通过 ?fields=id,name,items.product.name 或 X-Fields 请求头选择需要的字段（查询参数优先），
+// 支持用点号选择嵌套字段。请求了 T 中不存在的字段时返回 400，data 中列出全部未知字段。
+// 响应信封（code、message 等）、PagedData 的分页字段以及标记 alwaysinclude:"true" 的字段始终保留。
+// 自定义了 JSON 序列化的字段（如脱敏字段）只能整体选择，选择子字段视为未知字段。
+//
+// 处理函数通过 SelectFields 应用选择；没有传 fields 时按完整结构输出。
+// 序列化计划按（类型，字段组合）缓存，每个请求只做一次反射遍历
+//
+// 使用方式：
+//
+//	type OrderResp struct {
+//	    ID     int64       `json:"id" alwaysinclude:"true"`
+//	    Status string      `json:"status"`
+//	    Items  []OrderItem `json:"items"`
+//	}
+//
+//	api.GET("/orders", web.SparseFields[OrderResp](), web.WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+//	    orders, total := listOrders(ctx)
+//	    c.JSON(200, web.PagedSuccess(web.SelectFields(c, orders), page, size, total))
+//	    return nil
+//	}))
+func SparseFields[T any]() app.HandlerFunc {
+	declared := reflect.TypeFor[T]()
+	return func(ctx context.Context, c *app.RequestContext) {
+		raw := c.Query(FieldsQueryParam)
+		if raw == "" {
+			raw = string(c.GetHeader(HeaderFields))
+		}
+		if strings.TrimSpace(raw) == "" {
+			c.Next(ctx)
+			return
+		}
+
+		fs, err := parseFieldset(raw)
+		if err != nil {
+			abortFields(c, FailWithData(consts.StatusBadRequest, err.Error(), nil))
+			return
+		}
+		if cp := fieldPlanFor(declared, fs); len(cp.unknown) > 0 {
+			abortFields(c, FailWithData(consts.StatusBadRequest, MsgUnknownFields, utils.H{"unknownFields": cp.unknown}))
+			return
+		}
+		c.Set(fieldsetKey, fs)
+		c.Next(ctx)
+	}
+}
+
+func abortFields(c *app.RequestContext, result Result) {
+	result.TraceID = middleware.GetRequestID(c)
+	result.Impersonating = jwt.IsImpersonating(c)
+	c.AbortWithStatusJSON(consts.StatusBadRequest, result)
+}
+
+// SelectFields 按请求的字段选择裁剪响应数据（路由没有开启 SparseFields 或请求没有选择字段时原样返回）
+//
+// data 可以是结构体、指针、切片或 PagedData（裁剪 items，分页字段保留）
+func SelectFields(c *app.RequestContext, data any) any {
+	v, ok := c.Get(fieldsetKey)
+	if !ok || data == nil {
+		return data
+	}
+	fs := v.(fieldset)
+	switch d := data.(type) {
+	case PagedData:
+		d.Items = SelectFields(c, d.Items)
+		return d
+	case *PagedData:
+		paged := *d
+		paged.Items = SelectFields(c, d.Items)
+		return paged
+	case Result:
+		d.Data = SelectFields(c, d.Data)
+		return d
+	}
+	if _, ok := data.(json.Marshaler); ok {
+		return data
+	}
+	cp := fieldPlanFor(reflect.TypeOf(data), fs)
+	if cp.plan == nil {
+		return data
+	}
+	return selectedFields{value: data, plan: cp.plan}
+}
@@ -0,0 +1,170 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/CenJIl/base/web/fieldcrypt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fieldsProduct struct {
+	SKU   string  `json:"sku"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+type fieldsItem struct {
+	Product  fieldsProduct `json:"product"`
+	Quantity int           `json:"quantity"`
+}
+
+type fieldsAudit struct {
+	CreatedBy string `json:"createdBy"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+}
+
+type fieldsOrder struct {
+	ID     int64                      `json:"id" alwaysinclude:"true"`
+	Status string                     `json:"status"`
+	Buyer  string                     `json:"buyer"`
+	Phone  fieldcrypt.EncryptedString `json:"phone"`
+	Items  []fieldsItem               `json:"items"`
+	Note   *string                    `json:"note,omitempty"`
+	fieldsAudit
+	internal string
+}
+
+func sampleOrders(n int) []fieldsOrder {
+	orders := make([]fieldsOrder, n)
+	for i := range orders {
+		orders[i] = fieldsOrder{
+			ID: int64(i + 1), Status: "paid", Buyer: fmt.Sprintf("buyer-%d", i), Phone: "13812345678",
+			Items: []fieldsItem{
+				{Product: fieldsProduct{SKU: "A-1", Name: "Keyboard", Price: 99.5}, Quantity: 1},
+				{Product: fieldsProduct{SKU: "B-2", Name: "Mouse", Price: 25}, Quantity: 2},
+			},
+			fieldsAudit: fieldsAudit{CreatedBy: "system"},
+		}
+	}
+	return orders
+}
+
+func fieldsEngine() *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/orders", SparseFields[fieldsOrder](), WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		c.JSON(200, PagedSuccess(SelectFields(c, sampleOrders(2)), 1, 2, 10))
+		return nil
+	}))
+	engine.GET("/orders/1", SparseFields[fieldsOrder](), WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		order := sampleOrders(1)[0]
+		c.JSON(200, Success(SelectFields(c, &order)))
+		return nil
+	}))
+	return engine
+}
+
+func TestSparseFields_PrunesNestedSelection(t *testing.T) {
+	engine := fieldsEngine()
+
+	w := ut.PerformRequest(engine, "GET", "/orders?fields=status,items.product.name,createdBy", nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"code":0,"message":"success","data":{
+		"items":[
+			{"id":1,"status":"paid","items":[{"product":{"name":"Keyboard"}},{"product":{"name":"Mouse"}}],"createdBy":"system"},
+			{"id":2,"status":"paid","items":[{"product":{"name":"Keyboard"}},{"product":{"name":"Mouse"}}],"createdBy":"system"}
+		],
+		"page":1,"pageSize":2,"total":10,"totalPage":5}}`, w.Body.String())
+
+	// 请求头；选择整个字段时子字段的选择被忽略
+	w = ut.PerformRequest(engine, "GET", "/orders/1", nil, ut.Header{Key: HeaderFields, Value: "items.product.sku, items ,note,updatedBy"})
+	require.Equal(t, 200, w.Code)
+	var full struct{ Data fieldsOrder }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &full))
+	assert.Len(t, full.Data.Items, 2)
+	assert.Equal(t, 25.0, full.Data.Items[1].Product.Price)
+	assert.NotContains(t, w.Body.String(), "note", "omitempty 照常生效")
+	assert.NotContains(t, w.Body.String(), "buyer")
+
+	// 不选择字段时输出完整结构
+	w = ut.PerformRequest(engine, "GET", "/orders/1", nil)
+	expected, _ := json.Marshal(Success(&sampleOrders(1)[0]))
+	assert.JSONEq(t, string(expected), w.Body.String())
+}
+
+func TestSparseFields_UnknownFieldsRejected(t *testing.T) {
+	engine := fieldsEngine()
+
+	w := ut.PerformRequest(engine, "GET", "/orders?fields=status,items.product.colour,buyer.name,statsu", nil)
+	assert.Equal(t, 400, w.Code)
+	var result struct {
+		Code    int
+		Message string
+		Data    struct{ UnknownFields []string }
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, MsgUnknownFields, result.Message)
+	assert.Equal(t, []string{"buyer.name", "items.product.colour", "statsu"}, result.Data.UnknownFields)
+
+	w = ut.PerformRequest(engine, "GET", "/orders?fields=items..name", nil)
+	assert.Equal(t, 400, w.Code)
+
+	// 不导出的字段不能被选择
+	w = ut.PerformRequest(engine, "GET", "/orders?fields=internal", nil)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestSparseFields_SelectionCannotBypassMasking(t *testing.T) {
+	engine := fieldsEngine()
+
+	// 脱敏字段只能整体选择，输出的仍是脱敏值
+	w := ut.PerformRequest(engine, "GET", "/orders/1?fields=phone", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"phone":"138****5678"`)
+	assert.NotContains(t, w.Body.String(), "13812345678")
+
+	w = ut.PerformRequest(engine, "GET", "/orders/1?fields=phone.raw", nil)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "phone.raw")
+}
+
+func TestSparseFields_PlanCachedPerTypeAndFieldset(t *testing.T) {
+	a, err := parseFieldset("status, items.product.name,id")
+	require.NoError(t, err)
+	b, err := parseFieldset("id,items.product.name,status")
+	require.NoError(t, err)
+	assert.Equal(t, a.key, b.key)
+
+	first := fieldPlanFor(reflect.TypeFor[fieldsOrder](), a)
+	assert.Same(t, first, fieldPlanFor(reflect.TypeFor[fieldsOrder](), b))
+	assert.NotSame(t, first, fieldPlanFor(reflect.TypeFor[[]fieldsOrder](), a))
+}
+
+// BenchmarkSparseFields 1000 条数据的分页响应：完整序列化与按字段选择裁剪后序列化的开销对比
+func BenchmarkSparseFields(b *testing.B) {
+	orders := sampleOrders(1000)
+	fs, _ := parseFieldset("status,items.product.name")
+
+	b.Run("full", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(PagedSuccess(orders, 1, 1000, 1000))
+		}
+	})
+	b.Run("pruned", func(b *testing.B) {
+		c := app.NewContext(0)
+		c.Set(fieldsetKey, fs)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(PagedSuccess(SelectFields(c, orders), 1, 1000, 1000))
+		}
+	})
+}
@@ -0,0 +1,282 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	// ErrAmbiguousTime 本地时间在夏令时回拨时出现两次（见 AmbiguousTimeError）
+	ErrAmbiguousTime = errors.New("ambiguous local time")
+	// ErrNonexistentTime 本地时间落在夏令时跳过的区间内，不存在
+	ErrNonexistentTime = errors.New("nonexistent local time")
+)
+
+// AmbiguousTimeError 夏令时回拨导致的歧义时间
+//
+// errors.Is(err, ErrAmbiguousTime) 为 true，调用方可以从 Earlier / Later 中明确选择一个
+type AmbiguousTimeError struct {
+	Input   string
+	Earlier time.Time // 回拨前（夏令时）
+	Later   time.Time // 回拨后（标准时间）
+}
+
+func (e *AmbiguousTimeError) Error() string {
+	return fmt.Sprintf("%q 在 %s 时区对应两个时刻: %s / %s", e.Input, e.Earlier.Location(),
+		e.Earlier.Format(time.RFC3339), e.Later.Format(time.RFC3339))
+}
+
+// Unwrap 支持 errors.Is(err, ErrAmbiguousTime)
+func (e *AmbiguousTimeError) Unwrap() error { return ErrAmbiguousTime }
+
+// currencyDecimals 非 2 位小数的货币（ISO 4217）
+var currencyDecimals = map[string]int{"JPY": 0, "KRW": 0, "VND": 0, "BHD": 3, "KWD": 3}
+
+// FormatTime 按 ctx 中的语言和时区格式化时间（零值返回空字符串）
+//
+// 使用方式：
+//
+//	web.FormatTime(ctx, order.CreatedAt, web.TimeStyleDateTime)   // zh-CN: 2026/10/15 14:30，en-US: 10/15/2026 2:30 PM
+func FormatTime(ctx context.Context, t time.Time, style TimeStyle) string {
+	if t.IsZero() {
+		return ""
+	}
+	l := LocaleFrom(ctx)
+	t = t.In(l.Location)
+	if style == TimeStyleISO {
+		return t.Format(time.RFC3339)
+	}
+	layout, ok := dataFor(l.Language).Layouts[style]
+	if !ok {
+		layout = dataFor(l.Language).Layouts[TimeStyleDateTime]
+	}
+	return t.Format(layout)
+}
+
+// FormatNumber 按 ctx 中的语言格式化数字（千位分隔符、小数点），decimals 为保留的小数位数
+func FormatNumber(ctx context.Context, v float64, decimals int) string {
+	return formatNumber(dataFor(LocaleFrom(ctx).Language), v, decimals)
+}
+
+// FormatCurrency 按 ctx 中的语言格式化金额（货币符号与小数位数按币种）
+//
+// 使用方式：
+//
+//	web.FormatCurrency(ctx, 1234.5, "CNY")   // zh-CN: ¥1,234.50，en-US: CN¥1,234.50
+func FormatCurrency(ctx context.Context, amount float64, currency string) string {
+	data := dataFor(LocaleFrom(ctx).Language)
+	currency = strings.ToUpper(currency)
+	decimals, ok := currencyDecimals[currency]
+	if !ok {
+		decimals = 2
+	}
+	symbol, ok := data.CurrencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	pattern := data.CurrencyFormat
+	if pattern == "" {
+		pattern = "{symbol}{number}"
+	}
+	s := strings.NewReplacer("{symbol}", symbol, "{number}", formatNumber(data, amount, decimals)).Replace(pattern)
+	return sign + strings.TrimSpace(s)
+}
+
+func formatNumber(data LocaleData, v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(data.Group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(data.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// isoInputLayouts 任何语言都接受的 ISO 8601 输入
+var isoInputLayouts = []string{"2006-1-2 15:04:05", "2006-1-2 15:04", "2006-1-2T15:04:05", "2006-1-2T15:04", "2006-1-2"}
+
+// ParseTime 解析用户输入的本地时间（表单提交），按 ctx 中的时区解释
+//
+// 接受该语言 style 对应的格式和 ISO 8601（2026-10-15 14:30）。数字日期只按语言自身的顺序解释
+// （en-US 的 3/4/2026 是 3 月 4 日），不猜测日月顺序。带时区偏移的 RFC3339 直接按偏移解析。
+// 本地时间落在夏令时跳过的区间返回 ErrNonexistentTime；回拨导致出现两次时返回 *AmbiguousTimeError，
+// 由调用方明确选择，不会静默取其中一个
+func ParseTime(ctx context.Context, s string, style TimeStyle) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	l := LocaleFrom(ctx)
+	layouts := isoInputLayouts
+	if layout, ok := dataFor(l.Language).Layouts[style]; ok && style != TimeStyleISO {
+		layouts = append([]string{layout}, isoInputLayouts...)
+	}
+	for _, layout := range layouts {
+		wall, err := time.Parse(layout, s)
+		if err == nil {
+			return resolveWallTime(s, wall, l.Location)
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", s)
+}
+
+// resolveWallTime 把 UTC 表示的墙上时间放到 loc 中，检查夏令时导致的不存在与歧义
+func resolveWallTime(input string, wall time.Time, loc *time.Location) (time.Time, error) {
+	var candidates []time.Time
+	for _, probe := range []time.Time{wall.Add(-24 * time.Hour), wall.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if !sameWallClock(t, wall) {
+			continue
+		}
+		if len(candidates) == 0 || !candidates[0].Equal(t) {
+			candidates = append(candidates, t)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return time.Time{}, fmt.Errorf("%w: %q 在 %s 时区不存在", ErrNonexistentTime, input, loc)
+	case 1:
+		return candidates[0], nil
+	}
+	earlier, later := candidates[0], candidates[1]
+	if later.Before(earlier) {
+		earlier, later = later, earlier
+	}
+	return time.Time{}, &AmbiguousTimeError{Input: input, Earlier: earlier, Later: later}
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := wall.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 && t.Hour() == wall.Hour() && t.Minute() == wall.Minute() && t.Second() == wall.Second()
+}
+
+// ParseNumber 解析用户输入的本地化数字
+//
+// 千位分隔符必须在三位一组的位置上（en-US 的 "1,23" 会被拒绝，而不是猜成 1.23 或 123）
+func ParseNumber(ctx context.Context, s string) (float64, error) {
+	data := dataFor(LocaleFrom(ctx).Language)
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac, hasFrac := strings.Cut(s, data.Decimal)
+	if data.Group != "" && strings.Contains(intPart, data.Group) {
+		groups := strings.Split(intPart, data.Group)
+		for i, g := range groups {
+			if (i == 0 && (len(g) == 0 || len(g) > 3)) || (i > 0 && len(g) != 3) {
+				return 0, fmt.Errorf("无法解析数字 %q: 千位分隔符位置错误", s)
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+	normalized := intPart
+	if hasFrac {
+		normalized += "." + frac
+	}
+	if neg {
+		normalized = "-" + normalized
+	}
+	v, err := strconv.ParseFloat(normalized, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析数字 %q", s)
+	}
+	return v, nil
+}
+
+// TemplateFuncs 邮件/导出模板使用的格式化函数（按 ctx 中的语言和时区）
+//
+// formatTime 接受 time.Time、RFC3339 字符串或 Unix 秒；formatNumber / formatCurrency 接受任意数字类型：
+//
+//	{{formatTime .StartAt "long"}}  {{formatNumber .Count 0}}  {{formatCurrency .Amount "CNY"}}
+func TemplateFuncs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"formatTime": func(v any, style ...string) string {
+			s := TimeStyleDateTime
+			if len(style) > 0 {
+				s = TimeStyle(style[0])
+			}
+			return FormatTime(ctx, toTime(v), s)
+		},
+		"formatNumber": func(v any, decimals int) string {
+			return FormatNumber(ctx, toFloat(v), decimals)
+		},
+		"formatCurrency": func(v any, currency string) string {
+			return FormatCurrency(ctx, toFloat(v), currency)
+		},
+	}
+}
+
+func toTime(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return parsed
+		}
+	case int64:
+		return time.Unix(t, 0)
+	case int:
+		return time.Unix(int64(t), 0)
+	case float64:
+		return time.Unix(int64(t), 0)
+	}
+	return time.Time{}
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,275 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestFormatTime(t *testing.T) {
+	instant := time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC)
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	newYork := mustLocation(t, "America/New_York")
+
+	tests := []struct {
+		lang  string
+		loc   *time.Location
+		style TimeStyle
+		want  string
+	}{
+		{"zh-CN", shanghai, TimeStyleDate, "2026/10/15"},
+		{"zh-CN", shanghai, TimeStyleTime, "14:30"},
+		{"zh-CN", shanghai, TimeStyleDateTime, "2026/10/15 14:30"},
+		{"zh-CN", shanghai, TimeStyleLong, "2026年10月15日 14:30 CST"},
+		{"zh-CN", shanghai, TimeStyleISO, "2026-10-15T14:30:00+08:00"},
+		{"en-US", newYork, TimeStyleDate, "10/15/2026"},
+		{"en-US", newYork, TimeStyleTime, "2:30 AM"},
+		{"en-US", newYork, TimeStyleDateTime, "10/15/2026 2:30 AM"},
+		{"en-US", newYork, TimeStyleLong, "October 15, 2026 2:30 AM EDT"},
+		{"en-US", shanghai, TimeStyleDateTime, "10/15/2026 2:30 PM"},
+		{"zh-CN", newYork, TimeStyleDateTime, "2026/10/15 02:30"},
+		// 主语言匹配与未知语言
+		{"zh", shanghai, TimeStyleDate, "2026/10/15"},
+		{"en-GB", shanghai, TimeStyleDate, "10/15/2026"},
+		{"fr-FR", shanghai, TimeStyleDate, "2026/10/15"},
+		// 未知风格按 datetime
+		{"zh-CN", shanghai, TimeStyle("weird"), "2026/10/15 14:30"},
+	}
+	for _, tt := range tests {
+		ctx := WithLocale(context.Background(), Locale{Language: tt.lang, Location: tt.loc})
+		assert.Equal(t, tt.want, FormatTime(ctx, instant, tt.style), "%s %s %s", tt.lang, tt.loc, tt.style)
+	}
+
+	assert.Equal(t, "", FormatTime(context.Background(), time.Time{}, TimeStyleLong), "零值返回空字符串")
+
+	// 跨夏令时：同一时区不同季节的偏移
+	ctx := WithLocale(context.Background(), Locale{Language: "en-US", Location: newYork})
+	assert.Equal(t, "March 8, 2026 1:59 AM EST", FormatTime(ctx, time.Date(2026, 3, 8, 6, 59, 0, 0, time.UTC), TimeStyleLong))
+	assert.Equal(t, "March 8, 2026 3:00 AM EDT", FormatTime(ctx, time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), TimeStyleLong))
+}
+
+func TestFormatNumberAndCurrency(t *testing.T) {
+	RegisterLocaleData("de-DE", LocaleData{
+		Decimal:         ",",
+		Group:           ".",
+		Layouts:         map[TimeStyle]string{TimeStyleDate: "02.01.2006"},
+		CurrencyFormat:  "{number} {symbol}",
+		CurrencySymbols: map[string]string{"EUR": "€"},
+	})
+
+	tests := []struct {
+		lang     string
+		amount   float64
+		currency string
+		number   string // FormatNumber(amount, 2)
+		money    string
+	}{
+		{"zh-CN", 1234567.891, "CNY", "1,234,567.89", "¥1,234,567.89"},
+		{"zh-CN", 1234.5, "USD", "1,234.50", "US$1,234.50"},
+		{"en-US", 1234.5, "USD", "1,234.50", "$1,234.50"},
+		{"en-US", 1234.5, "CNY", "1,234.50", "CN¥1,234.50"},
+		{"en-US", 1234.56, "JPY", "1,234.56", "¥1,235"},
+		{"en-US", -1234.5, "usd", "-1,234.50", "-$1,234.50"},
+		{"en-US", 999.999, "USD", "1,000.00", "$1,000.00"},
+		{"en-US", 0, "USD", "0.00", "$0.00"},
+		{"en-US", -0.001, "USD", "0.00", "-$0.00"},
+		{"en-US", 12, "XYZ", "12.00", "XYZ\u00a012.00"},
+		{"de-DE", 1234567.891, "EUR", "1.234.567,89", "1.234.567,89 €"},
+	}
+	for _, tt := range tests {
+		ctx := WithLocale(context.Background(), Locale{Language: tt.lang})
+		assert.Equal(t, tt.number, FormatNumber(ctx, tt.amount, 2), "%s %v", tt.lang, tt.amount)
+		assert.Equal(t, tt.money, FormatCurrency(ctx, tt.amount, tt.currency), "%s %v %s", tt.lang, tt.amount, tt.currency)
+	}
+
+	ctx := WithLocale(context.Background(), Locale{Language: "en-US"})
+	assert.Equal(t, "123", FormatNumber(ctx, 123, 0))
+	assert.Equal(t, "-1,000", FormatNumber(ctx, -1000, 0))
+	assert.Equal(t, "NaN", FormatNumber(ctx, nanValue(), 2))
+}
+
+func nanValue() float64 {
+	zero := 0.0
+	return zero / zero
+}
+
+func TestParseTime(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	newYork := mustLocation(t, "America/New_York")
+	zh := WithLocale(context.Background(), Locale{Language: "zh-CN", Location: shanghai})
+	en := WithLocale(context.Background(), Locale{Language: "en-US", Location: newYork})
+
+	tests := []struct {
+		ctx   context.Context
+		input string
+		style TimeStyle
+		want  time.Time
+	}{
+		{zh, "2026/10/15 14:30", TimeStyleDateTime, time.Date(2026, 10, 15, 14, 30, 0, 0, shanghai)},
+		{zh, "2026-10-15 14:30", TimeStyleDateTime, time.Date(2026, 10, 15, 14, 30, 0, 0, shanghai)},
+		{zh, "2026/3/4", TimeStyleDate, time.Date(2026, 3, 4, 0, 0, 0, 0, shanghai)},
+		{en, "3/4/2026", TimeStyleDate, time.Date(2026, 3, 4, 0, 0, 0, 0, newYork)},
+		{en, "10/15/2026 2:30 PM", TimeStyleDateTime, time.Date(2026, 10, 15, 14, 30, 0, 0, newYork)},
+		{en, "2026-10-15", TimeStyleDate, time.Date(2026, 10, 15, 0, 0, 0, 0, newYork)},
+		{en, "2026-10-15T14:30:00+08:00", TimeStyleDateTime, time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.ctx, tt.input, tt.style)
+		require.NoError(t, err, tt.input)
+		assert.True(t, tt.want.Equal(got), "%s: want %s got %s", tt.input, tt.want, got)
+	}
+
+	// 语言的日期顺序不会被猜测：en-US 没有 15 月
+	_, err := ParseTime(en, "15/10/2026", TimeStyleDate)
+	assert.Error(t, err)
+	_, err = ParseTime(zh, "not a date", TimeStyleDate)
+	assert.Error(t, err)
+
+	// 夏令时开始：02:30 不存在
+	_, err = ParseTime(en, "3/8/2026 2:30 AM", TimeStyleDateTime)
+	assert.ErrorIs(t, err, ErrNonexistentTime)
+
+	// 夏令时结束：01:30 出现两次，返回两个候选由调用方选择
+	_, err = ParseTime(en, "11/1/2026 1:30 AM", TimeStyleDateTime)
+	require.ErrorIs(t, err, ErrAmbiguousTime)
+	var amb *AmbiguousTimeError
+	require.True(t, errors.As(err, &amb))
+	assert.Equal(t, time.Hour, amb.Later.Sub(amb.Earlier))
+	assert.Equal(t, "2026-11-01T01:30:00-04:00", amb.Earlier.Format(time.RFC3339))
+	assert.Equal(t, "2026-11-01T01:30:00-05:00", amb.Later.Format(time.RFC3339))
+
+	// 没有夏令时的时区不受影响
+	_, err = ParseTime(zh, "2026-11-01 01:30", TimeStyleDateTime)
+	assert.NoError(t, err)
+}
+
+func TestParseNumber(t *testing.T) {
+	en := WithLocale(context.Background(), Locale{Language: "en-US"})
+	tests := []struct {
+		input string
+		want  float64
+		ok    bool
+	}{
+		{"1,234.5", 1234.5, true},
+		{"1234.5", 1234.5, true},
+		{"-1,234,567", -1234567, true},
+		{" 12 ", 12, true},
+		{"1,23", 0, false},
+		{"1,2345", 0, false},
+		{",123", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseNumber(en, tt.input)
+		if !tt.ok {
+			assert.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	InitLocale("zh-CN", "Asia/Shanghai")
+	t.Cleanup(func() {
+		InitLocale("", "")
+		SetTimezoneResolver(nil)
+	})
+	lookups := 0
+	SetTimezoneResolver(func(ctx context.Context, c *app.RequestContext) string {
+		lookups++
+		return c.Query("userTz")
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(LocaleMiddleware())
+	instant := time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC)
+	engine.GET("/t", func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, FormatTime(ctx, instant, TimeStyleLong)+" | "+FormatCurrency(ctx, 1234.5, "USD"))
+	})
+	engine.GET("/noop", func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, "ok")
+	})
+
+	tests := []struct {
+		path    string
+		headers []ut.Header
+		want    string
+	}{
+		{"/t", nil, "2026年10月15日 14:30 CST | US$1,234.50"},
+		{"/t", []ut.Header{{Key: "Accept-Language", Value: "en-US,en;q=0.9"}}, "October 15, 2026 2:30 PM CST | $1,234.50"},
+		{"/t", []ut.Header{{Key: "Accept-Language", Value: "fr-FR, en;q=0.5"}}, "October 15, 2026 2:30 PM CST | $1,234.50"},
+		{"/t?lang=en-US", []ut.Header{{Key: "X-Timezone", Value: "America/New_York"}}, "October 15, 2026 2:30 AM EDT | $1,234.50"},
+		// 用户设置优先于请求头
+		{"/t?userTz=Europe/London", []ut.Header{{Key: "X-Timezone", Value: "America/New_York"}}, "2026年10月15日 07:30 BST | US$1,234.50"},
+		// 无效时区回退，不会 panic
+		{"/t?userTz=Mars/Olympus", []ut.Header{{Key: "X-Timezone", Value: "Not/AZone"}}, "2026年10月15日 14:30 CST | US$1,234.50"},
+	}
+	for _, tt := range tests {
+		w := ut.PerformRequest(engine, "GET", tt.path, nil, tt.headers...)
+		assert.Equal(t, tt.want, w.Body.String(), tt.path)
+	}
+	assert.Equal(t, len(tests), lookups)
+
+	// 没有格式化时间的请求不查询用户时区
+	ut.PerformRequest(engine, "GET", "/noop", nil)
+	assert.Equal(t, len(tests), lookups)
+
+	// 无效的默认时区
+	InitLocale("en-US", "Invalid/Zone")
+	assert.Equal(t, time.Local, DefaultLocale().Location)
+}
+
+func TestWriteCSV(t *testing.T) {
+	ctx := WithLocale(context.Background(), Locale{Language: "en-US", Location: mustLocation(t, "Asia/Shanghai")})
+	var buf bytes.Buffer
+	err := WriteCSV(ctx, &buf, []string{"id", "created", "total", "qty", "note"}, [][]any{
+		{1, time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC), Money{Amount: 1234.5, Currency: "CNY"}, 12000, "=SUM(A1)"},
+		{2, time.Time{}, Money{Amount: -3, Currency: "USD"}, 0.125, nil},
+	})
+	require.NoError(t, err)
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "\xEF\xBB\xBF"))
+	assert.Equal(t, "id,created,total,qty,note\n"+
+		"1,10/15/2026 2:30 PM,\"CN¥1,234.50\",\"12,000\",'=SUM(A1)\n"+
+		"2,,-$3.00,0.125,\n", strings.TrimPrefix(out, "\xEF\xBB\xBF"))
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(LocaleMiddleware())
+	engine.GET("/export", func(ctx context.Context, c *app.RequestContext) {
+		ExportCSV(ctx, c, "订单.csv", []string{"金额"}, [][]any{{Money{Amount: 8, Currency: "CNY"}}})
+	})
+	w := ut.PerformRequest(engine, "GET", "/export", nil)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "filename*=UTF-8''%E8%AE%A2%E5%8D%95.csv")
+	assert.Equal(t, "\xEF\xBB\xBF金额\n¥8.00\n", w.Body.String())
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	ctx := WithLocale(context.Background(), Locale{Language: "en-US", Location: time.UTC})
+	funcs := TemplateFuncs(ctx)
+	formatTime := funcs["formatTime"].(func(any, ...string) string)
+	assert.Equal(t, "10/15/2026", formatTime("2026-10-15T06:30:00Z", "date"))
+	assert.Equal(t, "10/15/2026 6:30 AM", formatTime(time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC).Unix()))
+	assert.Equal(t, "", formatTime("garbage"))
+	assert.Equal(t, "1,234", funcs["formatNumber"].(func(any, int) string)(1234, 0))
+	assert.Equal(t, "$12.00", funcs["formatCurrency"].(func(any, string) string)(float32(12), "USD"))
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderBudgetConfig 响应头预算配置
+//
+// 反向代理对上游响应头有大小限制（nginx 的 proxy_buffer_size 默认 4–8KB），超出时客户端收到 502。
+// 中间件在响应写出之前检查响应头总字节数、同名头个数和 Cookie，超出时删除可丢弃的头并告警，
+// strict 模式直接响应 500（测试、开发环境尽早暴露重复追加响应头之类的 bug）
+//
+// Example:
+//
+//	[web.headerBudget]
+//	maxBytes = 8192          # 响应头总字节数上限（默认 8192）
+//	maxPerName = 16          # 同名响应头个数上限（Set-Cookie 除外，默认 16）
+//	maxCookies = 20          # 每个响应的 Set-Cookie 个数上限（默认 20）
+//	maxCookieBytes = 4096    # 单个 Cookie 的字节数上限（默认 4096，浏览器会丢弃更大的 Cookie）
+//	strict = false           # 超出时响应 500 而不是删除响应头
+//	essential = ["X-Tenant-ID"]          # 追加的必需响应头，永不删除
+//	expendable = ["X-Cache-*", "Link"]   # 追加的可丢弃响应头（排在内置列表之后，按顺序删除）
+type HeaderBudgetConfig struct {
+	Disabled       bool     `toml:"disabled"`
+	MaxBytes       int      `toml:"maxBytes"`
+	MaxPerName     int      `toml:"maxPerName"`
+	MaxCookies     int      `toml:"maxCookies"`
+	MaxCookieBytes int      `toml:"maxCookieBytes"`
+	Strict         bool     `toml:"strict"`
+	Essential      []string `toml:"essential"`
+	Expendable     []string `toml:"expendable"`
+}
+
+// essentialHeaders 永不删除的响应头：内容协商与长度、重定向、认证、请求关联
+var essentialHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding", "Connection", "Location",
+	"Set-Cookie", "WWW-Authenticate", "Proxy-Authenticate", "X-Request-ID", "Traceparent", "Tracestate",
+}
+
+// expendableHeaders 超出预算时最先删除的响应头（按顺序，结尾的 * 表示前缀匹配）
+var expendableHeaders = []string{"X-Debug-*", "Server-Timing", "Deprecation", "Sunset", "X-Powered-By", "Server"}
+
+// headerBudget 生效的预算
+type headerBudget struct {
+	HeaderBudgetConfig
+	essential  []string
+	expendable []string
+}
+
+func newHeaderBudget(config HeaderBudgetConfig) *headerBudget {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = 8192
+	}
+	if config.MaxPerName <= 0 {
+		config.MaxPerName = 16
+	}
+	if config.MaxCookies <= 0 {
+		config.MaxCookies = 20
+	}
+	if config.MaxCookieBytes <= 0 {
+		config.MaxCookieBytes = 4096
+	}
+	return &headerBudget{
+		HeaderBudgetConfig: config,
+		essential:          append(slices.Clone(essentialHeaders), config.Essential...),
+		expendable:         append(slices.Clone(expendableHeaders), config.Expendable...),
+	}
+}
+
+// HeaderBudgetMiddleware 响应头预算中间件（注册在其他中间件之前，检查它们追加的全部响应头）
+//
+// 未超出预算时只遍历一次响应头，不分配内存。超出时：
+//   - 同名头超过 maxPerName：可丢弃的头只保留前 maxPerName 个
+//   - 总字节数超过 maxBytes：先按可丢弃列表的顺序整体删除，仍然超出时从最大的非必需头开始删除
+//   - Cookie 超过个数或单个大小：只告警，Cookie 不会被删除
+//
+// 必需的响应头（Content-Type、Content-Length、认证、请求 ID 与追踪头等）永不删除。每次超出记录一条
+// 警告和 web_header_budget_exceeded_total{kind} 指标（kind 为 bytes / per_name / cookie_count / cookie_bytes），
+// 删除的头计入 web_header_budget_dropped_total。strict 模式不删除，直接把响应替换为 500。
+// 已经开始流式写出的响应（响应头已经发出）不检查
+//
+// 使用方式：
+//
+//	h.Use(web.HeaderBudgetMiddleware(web.HeaderBudgetConfig{Strict: true}))
+func HeaderBudgetMiddleware(config HeaderBudgetConfig) app.HandlerFunc {
+	b := newHeaderBudget(config)
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Next(ctx)
+		if b.Disabled || c.Response.GetHijackWriter() != nil {
+			return
+		}
+		if b.within(c) {
+			return
+		}
+		b.enforce(c)
+	}
+}
+
+// within 快速检查：一次遍历统计总字节数与个数（总个数不超过 maxPerName 时同名头不可能超出）
+func (b *headerBudget) within(c *app.RequestContext) bool {
+	total, count := 0, 0
+	c.Response.Header.VisitAll(func(key, value []byte) {
+		total += len(key) + len(value) + 4 // "key: value\r\n"
+		count++
+	})
+	if total > b.MaxBytes || count > b.MaxPerName && b.perNameExceeded(c) {
+		return false
+	}
+	return b.cookiesWithin(c)
+}
+
+// cookiesWithin Cookie 个数与大小是否在预算内
+func (b *headerBudget) cookiesWithin(c *app.RequestContext) bool {
+	cookies, oversized := 0, false
+	c.Response.Header.VisitAllCookie(func(key, value []byte) {
+		cookies++
+		oversized = oversized || len(value) > b.MaxCookieBytes
+	})
+	return cookies <= b.MaxCookies && !oversized
+}
+
+// perNameExceeded 是否有同名头超过 maxPerName
+func (b *headerBudget) perNameExceeded(c *app.RequestContext) bool {
+	for _, name := range b.names(c) {
+		if len(c.Response.Header.PeekAll(name)) > b.MaxPerName {
+			return true
+		}
+	}
+	return false
+}
+
+// names 响应头名称（去重，不含 Set-Cookie）
+func (b *headerBudget) names(c *app.RequestContext) []string {
+	var names []string
+	c.Response.Header.VisitAll(func(key, value []byte) {
+		name := http.CanonicalHeaderKey(string(key))
+		if name != "Set-Cookie" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+// headerBytes 响应头总字节数
+func headerBytes(c *app.RequestContext) int {
+	total := 0
+	c.Response.Header.VisitAll(func(key, value []byte) {
+		total += len(key) + len(value) + 4
+	})
+	return total
+}
+
+// enforce 超出预算：记录违规，strict 模式替换为 500，否则删除可丢弃的头
+func (b *headerBudget) enforce(c *app.RequestContext) {
+	var violations []string
+	exceeded := func(kind, detail string) {
+		violations = append(violations, detail)
+		metrics.GetCounter("web_header_budget_exceeded_total", "kind", kind).Inc()
+	}
+
+	cookies, oversized := 0, []string{}
+	c.Response.Header.VisitAllCookie(func(key, value []byte) {
+		cookies++
+		if len(value) > b.MaxCookieBytes {
+			oversized = append(oversized, fmt.Sprintf("%s(%dB)", key, len(value)))
+		}
+	})
+	if cookies > b.MaxCookies {
+		exceeded("cookie_count", fmt.Sprintf("Set-Cookie %d 个，上限 %d", cookies, b.MaxCookies))
+	}
+	if len(oversized) > 0 {
+		exceeded("cookie_bytes", fmt.Sprintf("Cookie 超过 %dB: %s", b.MaxCookieBytes, strings.Join(oversized, ", ")))
+	}
+
+	var dropped []string
+	for _, name := range b.names(c) {
+		values := c.Response.Header.PeekAll(name)
+		if len(values) <= b.MaxPerName {
+			continue
+		}
+		exceeded("per_name", fmt.Sprintf("%s 出现 %d 次，上限 %d", name, len(values), b.MaxPerName))
+		if b.Strict || b.isEssential(name) {
+			continue
+		}
+		keep := make([]string, b.MaxPerName)
+		for i := range keep {
+			keep[i] = string(values[i])
+		}
+		c.Response.Header.Del(name)
+		for _, v := range keep {
+			c.Response.Header.Add(name, v)
+		}
+		dropped = append(dropped, fmt.Sprintf("%s×%d", name, len(values)-b.MaxPerName))
+	}
+
+	if total := headerBytes(c); total > b.MaxBytes {
+		exceeded("bytes", fmt.Sprintf("响应头 %dB，上限 %dB", total, b.MaxBytes))
+		if !b.Strict {
+			for _, name := range b.dropOrder(c) {
+				c.Response.Header.Del(name)
+				dropped = append(dropped, name)
+				if total = headerBytes(c); total <= b.MaxBytes {
+					break
+				}
+			}
+		}
+	}
+
+	method, path := string(c.Method()), string(c.Path())
+	if b.Strict {
+		log.Errorf("[HeaderBudget] %s %s 响应头超出预算，响应 500: %s", method, path, strings.Join(violations, "; "))
+		err := fmt.Errorf("响应头超出预算: %s", strings.Join(violations, "; "))
+		requestID := middleware.GetRequestID(c)
+		c.Response.Reset()
+		if requestID != "" {
+			c.Header("X-Request-ID", requestID)
+		}
+		recordRequestError(c, err)
+		result := Fail(500, MsgInternalError)
+		result.TraceID = requestID
+		respondError(c, http.StatusInternalServerError, result)
+		return
+	}
+	if len(dropped) == 0 {
+		log.Warnf("[HeaderBudget] %s %s 响应头超出预算: %s", method, path, strings.Join(violations, "; "))
+		return
+	}
+	metrics.GetCounter("web_header_budget_dropped_total").Add(int64(len(dropped)))
+	log.Warnf("[HeaderBudget] %s %s 响应头超出预算: %s；已删除: %s", method, path, strings.Join(violations, "; "), strings.Join(dropped, ", "))
+}
+
+// dropOrder 超出总字节数时的删除顺序：可丢弃列表的顺序，然后其余非必需头从大到小
+func (b *headerBudget) dropOrder(c *app.RequestContext) []string {
+	names := b.names(c)
+	var order []string
+	for _, pattern := range b.expendable {
+		for _, name := range names {
+			if !slices.Contains(order, name) && !b.isEssential(name) && headerMatches(pattern, name) {
+				order = append(order, name)
+			}
+		}
+	}
+	var rest []string
+	size := map[string]int{}
+	for _, name := range names {
+		if slices.Contains(order, name) || b.isEssential(name) {
+			continue
+		}
+		rest = append(rest, name)
+		for _, v := range c.Response.Header.PeekAll(name) {
+			size[name] += len(name) + len(v) + 4
+		}
+	}
+	slices.SortStableFunc(rest, func(x, y string) int { return size[y] - size[x] })
+	return append(order, rest...)
+}
+
+func (b *headerBudget) isEssential(name string) bool {
+	for _, pattern := range b.essential {
+		if headerMatches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerMatches 不区分大小写匹配响应头名称（pattern 结尾的 * 表示前缀匹配）
+func headerMatches(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix)
+	}
+	return strings.EqualFold(pattern, name)
+}
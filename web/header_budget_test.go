@@ -0,0 +1,192 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHeaderBudgetEngine(budget HeaderBudgetConfig, handler app.HandlerFunc) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(HeaderBudgetMiddleware(budget), middleware.RequestIDMiddleware(), ExceptionHandler())
+	engine.GET("/bloat", handler)
+	return engine
+}
+
+// headerValues 同名响应头的全部值
+func headerValues(h *protocol.ResponseHeader, name string) []string {
+	var values []string
+	for _, v := range h.PeekAll(name) {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+func TestHeaderBudget_TruncatesExpendableFirstAndKeepsEssential(t *testing.T) {
+	engine := newHeaderBudgetEngine(HeaderBudgetConfig{MaxBytes: 600, Expendable: []string{"X-Cache-*"}},
+		func(ctx context.Context, c *app.RequestContext) {
+			c.Header("Authorization-Info", "nextnonce=abc")
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			c.Header("X-Cache-Trace", strings.Repeat("c", 100))
+			c.Header("Server-Timing", "db;dur=12, "+strings.Repeat("t", 100))
+			c.Header("X-Debug-Query", strings.Repeat("q", 100))
+			c.Header("X-Feature-Flags", strings.Repeat("f", 150))
+			c.Header("X-Small", "s")
+			c.JSON(200, Success("ok"))
+		})
+	dropped := metrics.GetCounter("web_header_budget_dropped_total").Value()
+
+	w := ut.PerformRequest(engine, "GET", "/bloat", nil)
+	require.Equal(t, 200, w.Code)
+	h := w.Header()
+	assert.Empty(t, h.Get("X-Debug-Query"), "内置可丢弃列表最先删除")
+	assert.Empty(t, h.Get("Server-Timing"))
+	assert.NotEmpty(t, h.Get("X-Cache-Trace"), "删除到预算以内就停止，追加的可丢弃头排在内置列表之后")
+	assert.NotEmpty(t, h.Get("X-Feature-Flags"))
+	assert.Equal(t, "application/json; charset=utf-8", h.Get("Content-Type"))
+	assert.NotEmpty(t, h.Get("X-Request-ID"))
+	assert.NotEmpty(t, h.Get("WWW-Authenticate"))
+	assert.Equal(t, int64(2), metrics.GetCounter("web_header_budget_dropped_total").Value()-dropped)
+
+	// 预算更紧时继续删除：追加的可丢弃头，然后其余非必需头从大到小
+	engine = newHeaderBudgetEngine(HeaderBudgetConfig{MaxBytes: 250, Expendable: []string{"X-Cache-*"}},
+		func(ctx context.Context, c *app.RequestContext) {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			c.Header("X-Cache-Trace", strings.Repeat("c", 100))
+			c.Header("X-Feature-Flags", strings.Repeat("f", 150))
+			c.Header("X-Tenant-Region", strings.Repeat("r", 60))
+			c.Header("X-Small", "s")
+			c.JSON(200, Success("ok"))
+		})
+	w = ut.PerformRequest(engine, "GET", "/bloat", nil)
+	h = w.Header()
+	assert.Empty(t, h.Get("X-Cache-Trace"))
+	assert.Empty(t, h.Get("X-Feature-Flags"), "最大的非必需头先删除")
+	assert.Equal(t, "s", h.Get("X-Small"), "删除到预算以内就停止")
+	assert.NotEmpty(t, h.Get("WWW-Authenticate"))
+	assert.NotEmpty(t, h.Get("X-Request-ID"))
+}
+
+func TestHeaderBudget_EssentialNeverDropped(t *testing.T) {
+	engine := newHeaderBudgetEngine(HeaderBudgetConfig{MaxBytes: 64, MaxPerName: 2, Essential: []string{"X-Tenant-ID"}},
+		func(ctx context.Context, c *app.RequestContext) {
+			c.Header("Location", "/orders/"+strings.Repeat("1", 80))
+			c.Header("X-Tenant-ID", strings.Repeat("t", 80))
+			for i := range 4 {
+				c.Response.Header.Add("Traceparent", fmt.Sprintf("00-%032d-%016d-01", i, i))
+			}
+			c.String(302, "")
+		})
+	w := ut.PerformRequest(engine, "GET", "/bloat", nil)
+	assert.Equal(t, 302, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Location"))
+	assert.NotEmpty(t, w.Header().Get("X-Tenant-ID"))
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	assert.Len(t, headerValues(w.Header(), "Traceparent"), 4, "必需头超出个数只告警")
+}
+
+func TestHeaderBudget_PerNameCountKeepsFirstValues(t *testing.T) {
+	engine := newHeaderBudgetEngine(HeaderBudgetConfig{MaxPerName: 3},
+		func(ctx context.Context, c *app.RequestContext) {
+			// 中间件 bug：每次迭代追加一个头
+			for i := range 50 {
+				c.Response.Header.Add("X-Retry-Hint", fmt.Sprintf("attempt-%d", i))
+			}
+			c.JSON(200, Success("ok"))
+		})
+	exceeded := metrics.GetCounter("web_header_budget_exceeded_total", "kind", "per_name").Value()
+	w := ut.PerformRequest(engine, "GET", "/bloat", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, []string{"attempt-0", "attempt-1", "attempt-2"}, headerValues(w.Header(), "X-Retry-Hint"))
+	assert.Equal(t, int64(1), metrics.GetCounter("web_header_budget_exceeded_total", "kind", "per_name").Value()-exceeded)
+}
+
+func TestHeaderBudget_StrictFailsWith500(t *testing.T) {
+	engine := newHeaderBudgetEngine(HeaderBudgetConfig{Strict: true, MaxPerName: 3},
+		func(ctx context.Context, c *app.RequestContext) {
+			for i := range 10 {
+				c.Response.Header.Add("X-Retry-Hint", fmt.Sprintf("attempt-%d", i))
+			}
+			c.JSON(200, Success("secret payload"))
+		})
+	w := ut.PerformRequest(engine, "GET", "/bloat", nil)
+	assert.Equal(t, 500, w.Code)
+	assert.Empty(t, headerValues(w.Header(), "X-Retry-Hint"))
+	assert.NotContains(t, w.Body.String(), "secret payload")
+	requestID := w.Header().Get("X-Request-ID")
+	assert.NotEmpty(t, requestID)
+	assert.Contains(t, w.Body.String(), requestID, "错误信封带追踪 ID")
+}
+
+func TestHeaderBudget_Cookies(t *testing.T) {
+	setCookies := func(n, size int) app.HandlerFunc {
+		return func(ctx context.Context, c *app.RequestContext) {
+			for i := range n {
+				var cookie protocol.Cookie
+				cookie.SetKey(fmt.Sprintf("c%d", i))
+				cookie.SetValue(strings.Repeat("v", size))
+				c.Response.Header.SetCookie(&cookie)
+			}
+			c.JSON(200, Success("ok"))
+		}
+	}
+	count := metrics.GetCounter("web_header_budget_exceeded_total", "kind", "cookie_count").Value()
+	size := metrics.GetCounter("web_header_budget_exceeded_total", "kind", "cookie_bytes").Value()
+
+	w := ut.PerformRequest(newHeaderBudgetEngine(HeaderBudgetConfig{MaxCookies: 2, MaxBytes: 1 << 20}, setCookies(3, 10)), "GET", "/bloat", nil)
+	assert.Equal(t, 200, w.Code)
+	cookies := 0
+	w.Header().VisitAllCookie(func(key, value []byte) { cookies++ })
+	assert.Equal(t, 3, cookies, "Cookie 只告警不删除")
+	assert.Equal(t, int64(1), metrics.GetCounter("web_header_budget_exceeded_total", "kind", "cookie_count").Value()-count)
+
+	w = ut.PerformRequest(newHeaderBudgetEngine(HeaderBudgetConfig{MaxBytes: 1 << 20}, setCookies(1, 5000)), "GET", "/bloat", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, int64(1), metrics.GetCounter("web_header_budget_exceeded_total", "kind", "cookie_bytes").Value()-size)
+
+	w = ut.PerformRequest(newHeaderBudgetEngine(HeaderBudgetConfig{Strict: true, MaxBytes: 1 << 20}, setCookies(1, 5000)), "GET", "/bloat", nil)
+	assert.Equal(t, 500, w.Code, "strict 模式下超大 Cookie 同样失败")
+}
+
+func TestHeaderBudget_WithinBudgetDoesNotAllocate(t *testing.T) {
+	b := newHeaderBudget(HeaderBudgetConfig{})
+	c := app.NewContext(0)
+	c.Response.Header.SetContentType("application/json")
+	c.Response.Header.Set("X-Request-ID", "5f1c")
+	c.Response.Header.Set("Cache-Control", "no-store")
+	var cookie protocol.Cookie
+	cookie.SetKey("session")
+	cookie.SetValue("abc")
+	c.Response.Header.SetCookie(&cookie)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if !b.within(c) {
+			t.Fatal("应在预算内")
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkHeaderBudget_Within(b *testing.B) {
+	budget := newHeaderBudget(HeaderBudgetConfig{})
+	c := app.NewContext(0)
+	c.Response.Header.SetContentType("application/json")
+	for i := range 12 {
+		c.Response.Header.Set(fmt.Sprintf("X-Header-%d", i), strings.Repeat("v", 40))
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		budget.within(c)
+	}
+}
@@ -3,6 +3,8 @@ package web
 import (
 	"github.com/CenJIl/base/web/cache"
 	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
 )
 
 // InitDB 初始化数据库（便捷函数）
@@ -10,7 +12,7 @@ import (
 // 使用方式：
 //
 //	if err := web.InitDB(config.Database); err != nil {
-//	    logger.Errorf("Failed to init database: %v", err)
+//	    log.Errorf("Failed to init database: %v", err)
 //	}
 func InitDB(cfg DatabaseConfig) error {
 	return database.InitDB(cfg)
@@ -21,8 +23,19 @@ func InitDB(cfg DatabaseConfig) error {
 // 使用方式：
 //
 //	if err := web.InitRedis(config.Redis); err != nil {
-//	    logger.Errorf("Failed to init redis: %v", err)
+//	    log.Errorf("Failed to init redis: %v", err)
 //	}
 func InitRedis(cfg RedisConfig) error {
 	return cache.InitRedis(cfg)
 }
+
+// GetActor 获取实际操作人（便捷函数）
+//
+// 代理登录时返回管理员身份，jwt.GetUserID 返回被代理的用户
+//
+// 使用方式：
+//
+//	operator := web.GetActor(c)
+func GetActor(c *app.RequestContext) string {
+	return jwt.GetActor(c)
+}
@@ -0,0 +1,433 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 解码 GIF 原图（取第一帧）
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/signing"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// 派生图片的裁剪方式
+const (
+	ImageFitCover   = "cover"   // 等比缩放到覆盖 w×h 后居中裁剪，输出恰好 w×h（默认）
+	ImageFitContain = "contain" // 等比缩放到 w×h 以内，不裁剪
+)
+
+// ImageSignatureParam 签名模式的签名参数
+const ImageSignatureParam = "sig"
+
+// imageCacheControl 派生图片由原图和参数唯一确定，可以长期缓存
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// ImageEncoder 额外的输出格式编码器（如 WebP，标准库没有 WebP 编码器，由应用注入）
+type ImageEncoder func(w io.Writer, img image.Image) error
+
+// ImageConfig 图片服务配置
+//
+// 只允许请求 sizes 中列出的尺寸（防止任意尺寸绕过缓存、消耗 CPU）；配置了 signingKeys 时，
+// 带有效签名（SignImageURL 生成）的请求可以使用不超过 maxWidth × maxHeight 的任意尺寸。
+//
+// Example:
+//
+//	[images]
+//	sizes = ["64x64", "256x256", "1024x0"]   # 宽x高，0 表示按比例
+//	signingKeys = ["${IMAGE_SIGNING_KEY}"]   # 可选，第一个用于签名，其余只用于验证（轮换）
+//	maxSourcePixels = 40000000               # 原图像素上限（宽×高），超过时拒绝解码
+//	cacheDir = "data/image-cache"            # 可选，派生图片缓存到本地磁盘（默认缓存在存储的 _derived/images/ 下）
+//	cacheMaxMB = 512
+type ImageConfig struct {
+	Sizes           []string `toml:"sizes"`           // 允许的尺寸
+	SigningKeys     []string `toml:"signingKeys"`     // 签名模式的密钥
+	MaxWidth        int      `toml:"maxWidth"`        // 签名模式的最大宽度，默认 2048
+	MaxHeight       int      `toml:"maxHeight"`       // 签名模式的最大高度，默认 2048
+	MaxSourcePixels int      `toml:"maxSourcePixels"` // 原图像素上限，默认 4000 万
+	MaxSourceBytes  int64    `toml:"maxSourceBytes"`  // 原图字节上限，默认 20 MB
+	Workers         int      `toml:"workers"`         // 同时进行的缩放数，默认 CPU 数
+	Quality         int      `toml:"quality"`         // JPEG 质量，默认 85
+	CacheDir        string   `toml:"cacheDir"`        // 本地磁盘缓存目录
+	CacheMaxMB      int      `toml:"cacheMaxMB"`      // 本地磁盘缓存上限，默认 512
+
+	Cache    ImageCache              `toml:"-"` // 自定义派生图片缓存（优先于 cacheDir）
+	Encoders map[string]ImageEncoder `toml:"-"` // 额外的输出格式（MIME 类型 → 编码器），请求的 Accept 允许时使用
+}
+
+func (cfg ImageConfig) withDefaults() ImageConfig {
+	if cfg.MaxWidth <= 0 {
+		cfg.MaxWidth = 2048
+	}
+	if cfg.MaxHeight <= 0 {
+		cfg.MaxHeight = 2048
+	}
+	if cfg.MaxSourcePixels <= 0 {
+		cfg.MaxSourcePixels = 40_000_000
+	}
+	if cfg.MaxSourceBytes <= 0 {
+		cfg.MaxSourceBytes = 20 << 20
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.Quality <= 0 || cfg.Quality > 100 {
+		cfg.Quality = 85
+	}
+	if cfg.CacheMaxMB <= 0 {
+		cfg.CacheMaxMB = 512
+	}
+	return cfg
+}
+
+// imageSpec 派生参数（w、h 都为 0 表示原图）
+type imageSpec struct {
+	w, h int
+	fit  string
+}
+
+func (s imageSpec) original() bool { return s.w == 0 && s.h == 0 }
+
+// imageCall 正在计算的派生图片（同一派生图片的并发请求只计算一次）
+type imageCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// imageServer 图片服务
+type imageServer struct {
+	config  ImageConfig
+	path    string // 路由前缀（签名覆盖完整路径）
+	store   ObjectStorage
+	cache   ImageCache
+	sizes   map[[2]int]bool
+	keys    [][]byte
+	workers chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]*imageCall
+
+	hits, misses *metrics.Counter
+}
+
+// RegisterImageRoute 注册图片服务路由 GET <path>/*key
+//
+// 从存储读取原图，按 ?w=&h=&fit= 缩放裁剪后返回：
+//   - 不带参数时返回原图；只给出 w 或 h 时等比缩放；fit 为 cover（默认）或 contain
+//   - 尺寸必须在 sizes 白名单中，或带有 SignImageURL 生成的有效签名，否则返回 403
+//   - 原图超过 maxSourceBytes / maxSourcePixels 时拒绝解码（413 / 422），防止解压炸弹
+//   - 按 JPEG 的 EXIF 方向旋转；JPEG 原图输出 JPEG，其余输出 PNG，Accept 允许时使用 Encoders 中的格式（如 WebP）
+//   - 派生图片写入缓存（存储的 _derived/images/ 下或本地磁盘），之后的请求不再解码缩放；
+//     缩放在 workers 个并发内进行，同一派生图片的并发请求只计算一次
+//   - 响应带 ETag 与一年的 immutable 缓存头，If-None-Match 匹配时返回 304。
+//     原图 key 对应的内容不能被覆盖（更新头像时使用新的 key），否则客户端与 CDN 会继续使用旧图
+//
+// store 可以是 storage.Storage 或任何实现 ObjectStorage 的存储（原图不存在时 Open 返回的错误需满足
+// errors.Is(err, fs.ErrNotExist)，storage.ErrNotFound 满足）。配置错误（尺寸格式、缓存目录）时返回错误
+//
+// 使用方式：
+//
+//	if err := web.RegisterImageRoute(h, "/images", store, appCfg.Images); err != nil {
+//	    panic(err)
+//	}
+//	// <img src="/images/avatars/u1.jpg?w=64&h=64">
+func RegisterImageRoute(r route.IRoutes, path string, store ObjectStorage, cfg ImageConfig) error {
+	cfg = cfg.withDefaults()
+	s := &imageServer{
+		config:  cfg,
+		path:    strings.TrimSuffix(path, "/"),
+		store:   store,
+		cache:   cfg.Cache,
+		sizes:   make(map[[2]int]bool),
+		workers: make(chan struct{}, cfg.Workers),
+		calls:   make(map[string]*imageCall),
+		hits:    metrics.GetCounter("web_image_derived_total", "cache", "hit"),
+		misses:  metrics.GetCounter("web_image_derived_total", "cache", "miss"),
+	}
+	for _, size := range cfg.Sizes {
+		w, h, err := parseImageSize(size)
+		if err != nil {
+			return err
+		}
+		s.sizes[[2]int{w, h}] = true
+	}
+	for _, k := range cfg.SigningKeys {
+		s.keys = append(s.keys, []byte(k))
+	}
+	if s.cache == nil {
+		if cfg.CacheDir != "" {
+			cache, err := NewDiskImageCache(cfg.CacheDir, int64(cfg.CacheMaxMB)<<20)
+			if err != nil {
+				return err
+			}
+			s.cache = cache
+		} else {
+			s.cache = NewStorageImageCache(store, "")
+		}
+	}
+	r.GET(s.path+"/*key", WrapHandler(s.handle))
+	return nil
+}
+
+// parseImageSize 解析 "宽x高"（0 表示按比例，不能都为 0）
+func parseImageSize(size string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	w, errW := strconv.Atoi(ws)
+	h, errH := strconv.Atoi(hs)
+	if !ok || errW != nil || errH != nil || w < 0 || h < 0 || w+h == 0 {
+		return 0, 0, fmt.Errorf("图片尺寸格式错误: %q（应为 宽x高，如 256x256 或 1024x0）", size)
+	}
+	return w, h, nil
+}
+
+// SignImageURL 生成签名模式的图片地址：path 为 RegisterImageRoute 的路由前缀，key 为原图 key
+//
+// 使用方式：
+//
+//	src := web.SignImageURL([]byte(key), "/images", "products/p1.jpg", 640, 0, web.ImageFitCover)
+func SignImageURL(signingKey []byte, path, key string, w, h int, fit string) string {
+	spec := imageSpec{w: w, h: h, fit: fit}
+	full := strings.TrimSuffix(path, "/") + "/" + strings.TrimPrefix(key, "/")
+	sig := signing.Sign(signingKey, http.MethodGet, imageCanonicalURI(full, spec), nil, "", "")
+	// 签名覆盖解码后的路径，返回的地址中路径按段转义
+	escaped := (&url.URL{Path: full}).EscapedPath()
+	_, query, _ := strings.Cut(imageCanonicalURI("", spec), "?")
+	return escaped + "?" + query + "&" + ImageSignatureParam + "=" + sig
+}
+
+// imageCanonicalURI 签名覆盖的路径与参数（参数按名称排序）
+func imageCanonicalURI(path string, spec imageSpec) string {
+	q := url.Values{}
+	q.Set("w", strconv.Itoa(spec.w))
+	q.Set("h", strconv.Itoa(spec.h))
+	if spec.fit != "" {
+		q.Set("fit", spec.fit)
+	}
+	return path + "?" + q.Encode()
+}
+
+// parseSpec 解析并校验派生参数
+func (s *imageServer) parseSpec(c *app.RequestContext, key string) (imageSpec, error) {
+	var spec imageSpec
+	for name, dst := range map[string]*int{"w": &spec.w, "h": &spec.h} {
+		if v := c.Query(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return spec, BadRequestHTTP("invalid " + name)
+			}
+			*dst = n
+		}
+	}
+	spec.fit = c.Query("fit")
+	if spec.original() {
+		if spec.fit != "" {
+			return spec, BadRequestHTTP("fit requires w or h")
+		}
+		return spec, nil
+	}
+	switch spec.fit {
+	case "", ImageFitCover, ImageFitContain:
+	default:
+		return spec, BadRequestHTTP("invalid fit")
+	}
+
+	if sig := c.Query(ImageSignatureParam); sig != "" {
+		uri := imageCanonicalURI(s.path+"/"+key, spec)
+		if len(s.keys) == 0 || !signing.Verify(s.keys, sig, http.MethodGet, uri, nil, "", "") {
+			return spec, ForbiddenHTTP("invalid image signature")
+		}
+		if spec.w > s.config.MaxWidth || spec.h > s.config.MaxHeight {
+			return spec, BadRequestHTTP("image size too large")
+		}
+	} else if !s.sizes[[2]int{spec.w, spec.h}] {
+		return spec, ForbiddenHTTP("image size not allowed")
+	}
+	if spec.fit == "" {
+		spec.fit = ImageFitCover
+	}
+	return spec, nil
+}
+
+// negotiate 选择输出格式：Accept 允许的额外格式（按 MIME 类型排序后的第一个），否则为空（按原图格式）
+func (s *imageServer) negotiate(accept string) string {
+	if len(s.config.Encoders) == 0 {
+		return ""
+	}
+	var best string
+	for mime := range s.config.Encoders {
+		if strings.Contains(accept, mime) && (best == "" || mime < best) {
+			best = mime
+		}
+	}
+	return best
+}
+
+// derivedKey 派生图片的缓存 key（原图 key 取哈希，避免特殊字符）
+func derivedKey(key string, spec imageSpec, format string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := fmt.Sprintf("%dx%d-%s", spec.w, spec.h, spec.fit)
+	if format != "" {
+		name += "." + strings.TrimPrefix(format, "image/")
+	}
+	return hex.EncodeToString(sum[:]) + "/" + name
+}
+
+func (s *imageServer) handle(ctx context.Context, c *app.RequestContext) error {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" {
+		return NotFoundHTTP(MsgResourceNotFound)
+	}
+	spec, err := s.parseSpec(c, key)
+	if err != nil {
+		return err
+	}
+	if spec.original() {
+		data, err := s.readOriginal(ctx, key)
+		if err != nil {
+			return err
+		}
+		s.serve(c, data)
+		return nil
+	}
+
+	format := s.negotiate(string(c.GetHeader("Accept")))
+	if len(s.config.Encoders) > 0 {
+		c.Header("Vary", "Accept")
+	}
+	dk := derivedKey(key, spec, format)
+	if data, ok := s.cache.Get(ctx, dk); ok {
+		s.hits.Inc()
+		s.serve(c, data)
+		return nil
+	}
+	s.misses.Inc()
+	data, err := s.derive(ctx, dk, key, spec, format)
+	if err != nil {
+		return err
+	}
+	s.serve(c, data)
+	return nil
+}
+
+// derive 生成派生图片并写入缓存（同一派生图片的并发请求共享一次计算）
+func (s *imageServer) derive(ctx context.Context, dk, key string, spec imageSpec, format string) ([]byte, error) {
+	s.mu.Lock()
+	if call, ok := s.calls[dk]; ok {
+		s.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.data, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &imageCall{done: make(chan struct{})}
+	s.calls[dk] = call
+	s.mu.Unlock()
+
+	call.data, call.err = s.render(ctx, key, spec, format)
+	if call.err == nil {
+		if err := s.cache.Put(ctx, dk, call.data); err != nil {
+			log.Warnf("[Image] 写入派生图片缓存 %s 失败: %v", dk, err)
+		}
+	}
+	s.mu.Lock()
+	delete(s.calls, dk)
+	s.mu.Unlock()
+	close(call.done)
+	return call.data, call.err
+}
+
+// render 解码原图、按 EXIF 方向旋转、缩放裁剪并编码（在有界的并发内执行）
+func (s *imageServer) render(ctx context.Context, key string, spec imageSpec, format string) ([]byte, error) {
+	select {
+	case s.workers <- struct{}{}:
+		defer func() { <-s.workers }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	data, err := s.readOriginal(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	conf, kind, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewHTTPException(http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, "unsupported image")
+	}
+	if int64(conf.Width)*int64(conf.Height) > int64(s.config.MaxSourcePixels) {
+		return nil, NewHTTPException(http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, "image dimensions too large")
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewHTTPException(http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, "unsupported image")
+	}
+	img := toNRGBA(src)
+	if kind == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	out := fitImage(img, spec)
+
+	var buf bytes.Buffer
+	switch {
+	case format != "":
+		err = s.config.Encoders[format](&buf, out)
+	case kind == "jpeg":
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: s.config.Quality})
+	default:
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码图片失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readOriginal 读取原图（不存在返回 404，超过 maxSourceBytes 返回 413）
+func (s *imageServer) readOriginal(ctx context.Context, key string) ([]byte, error) {
+	rc, err := s.store.Open(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NotFoundHTTP(MsgResourceNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, s.config.MaxSourceBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.config.MaxSourceBytes {
+		return nil, NewHTTPException(http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "image too large")
+	}
+	return data, nil
+}
+
+// serve 写入图片响应（ETag 为内容哈希，If-None-Match 匹配时返回 304）
+func (s *imageServer) serve(c *app.RequestContext, data []byte) {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", imageCacheControl)
+	if match := string(c.GetHeader("If-None-Match")); match != "" && (match == etag || match == "*" || strings.Contains(match, etag)) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+}
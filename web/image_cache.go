@@ -0,0 +1,213 @@
+package web
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImageCache 派生图片缓存（key 由原图 key 与派生参数确定，内容不会变化）
+type ImageCache interface {
+	// Get 读取缓存，不存在或已损坏时 ok 为 false
+	Get(ctx context.Context, key string) (data []byte, ok bool)
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// StorageImageCache 把派生图片保存在存储中（默认前缀 _derived/images/，与原图放在同一存储）
+//
+// 内容后附 SHA-256，读取时校验：其他实例正在写入的半个文件按未命中处理，不会返回给客户端
+type StorageImageCache struct {
+	store  ObjectStorage
+	prefix string
+}
+
+// NewStorageImageCache 创建存储缓存（prefix 为空时使用 _derived/images/）
+func NewStorageImageCache(store ObjectStorage, prefix string) *StorageImageCache {
+	if prefix == "" {
+		prefix = "_derived/images/"
+	}
+	return &StorageImageCache{store: store, prefix: strings.TrimSuffix(prefix, "/") + "/"}
+}
+
+// Get 实现 ImageCache 接口
+func (s *StorageImageCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	rc, err := s.store.Open(ctx, s.prefix+key)
+	if err != nil {
+		return nil, false
+	}
+	defer rc.Close()
+	raw, err := io.ReadAll(rc)
+	if err != nil || len(raw) < sha256.Size {
+		return nil, false
+	}
+	data, sum := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+	if want := sha256.Sum256(data); !bytes.Equal(sum, want[:]) {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put 实现 ImageCache 接口
+func (s *StorageImageCache) Put(ctx context.Context, key string, data []byte) error {
+	w, err := s.store.Append(ctx, s.prefix+key, 0)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if _, err := w.Write(append(append([]byte(nil), data...), sum[:]...)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DiskImageCache 本地磁盘上的派生图片缓存，总大小超过上限时淘汰最久未使用的文件
+type DiskImageCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List               // 最近使用的在前，元素为 *diskImageEntry
+	index map[string]*list.Element // key → lru 元素
+}
+
+type diskImageEntry struct {
+	key  string
+	size int64
+}
+
+// NewDiskImageCache 创建磁盘缓存（目录中已有的文件按修改时间纳入淘汰顺序）
+func NewDiskImageCache(dir string, maxBytes int64) (*DiskImageCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建图片缓存目录失败: %w", err)
+	}
+	d := &DiskImageCache{dir: dir, maxBytes: maxBytes, lru: list.New(), index: make(map[string]*list.Element)}
+
+	type existing struct {
+		entry *diskImageEntry
+		mtime int64
+	}
+	var files []existing
+	err := filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+		if err != nil || e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			return err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, existing{&diskImageEntry{key: filepath.ToSlash(rel), size: info.Size()}, info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取图片缓存目录失败: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime > files[j].mtime })
+	for _, f := range files {
+		d.index[f.entry.key] = d.lru.PushBack(f.entry)
+		d.size += f.entry.size
+	}
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+	return d, nil
+}
+
+func (d *DiskImageCache) path(key string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(key))
+}
+
+// Get 实现 ImageCache 接口
+func (d *DiskImageCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	d.mu.Lock()
+	e, ok := d.index[key]
+	if ok {
+		d.lru.MoveToFront(e)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		d.remove(key)
+		return nil, false
+	}
+	return data, true
+}
+
+// Put 实现 ImageCache 接口（写临时文件后 rename，读取方不会看到写了一半的文件）
+func (d *DiskImageCache) Put(ctx context.Context, key string, data []byte) error {
+	p := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.index[key]; ok {
+		d.size -= e.Value.(*diskImageEntry).size
+		d.lru.Remove(e)
+	}
+	d.index[key] = d.lru.PushFront(&diskImageEntry{key: key, size: int64(len(data))})
+	d.size += int64(len(data))
+	d.evict()
+	return nil
+}
+
+// Size 缓存文件的总大小
+func (d *DiskImageCache) Size() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+// evict 淘汰最久未使用的文件直到不超过上限（调用方持有 mu）
+func (d *DiskImageCache) evict() {
+	for d.size > d.maxBytes && d.lru.Len() > 0 {
+		e := d.lru.Back()
+		entry := e.Value.(*diskImageEntry)
+		d.lru.Remove(e)
+		delete(d.index, entry.key)
+		d.size -= entry.size
+		if err := os.Remove(d.path(entry.key)); err != nil && !os.IsNotExist(err) {
+			log.Warnf("[Image] 删除缓存文件 %s 失败: %v", entry.key, err)
+		}
+	}
+}
+
+// remove 文件已被外部删除，移出索引
+func (d *DiskImageCache) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.index[key]; ok {
+		d.size -= e.Value.(*diskImageEntry).size
+		d.lru.Remove(e)
+		delete(d.index, key)
+	}
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore 记录每个 key 被读取的次数
+type countingStore struct {
+	diskStore
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (s *countingStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	s.opens[key]++
+	s.mu.Unlock()
+	return s.diskStore.Open(ctx, key)
+}
+
+func (s *countingStore) count(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opens[key]
+}
+
+// twoColorImage 左半部分红色、右半部分蓝色
+func twoColorImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation 在 JPEG 的 SOI 之后插入只包含 Orientation 的 EXIF 段
+func withOrientation(jpegData []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(segment)+2))
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(append(out, app1...), segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func newImageEngine(t *testing.T, cfg ImageConfig) (*route.Engine, *countingStore) {
+	store := &countingStore{diskStore: diskStore{dir: t.TempDir()}, opens: make(map[string]int)}
+	engine := route.NewEngine(config.NewOptions(nil))
+	require.NoError(t, RegisterImageRoute(engine, "/images", store, cfg))
+	return engine, store
+}
+
+func putImage(t *testing.T, store *countingStore, key string, data []byte) {
+	w, err := store.Append(context.Background(), key, 0)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageRoute_DerivedCacheAndETag(t *testing.T) {
+	engine, store := newImageEngine(t, ImageConfig{Sizes: []string{"64x64", "50x0"}})
+	original := encodePNG(t, twoColorImage(200, 100))
+	putImage(t, store, "avatars/u1.png", original)
+
+	w := ut.PerformRequest(engine, "GET", "/images/avatars/u1.png?w=64&h=64", nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, "image/png", string(w.Header().ContentType()))
+	assert.Equal(t, "public, max-age=31536000, immutable", string(w.Header().Peek("Cache-Control")))
+	etag := string(w.Header().Peek("ETag"))
+	assert.NotEmpty(t, etag)
+	derived := append([]byte(nil), w.Body.Bytes()...)
+	img, err := png.Decode(bytes.NewReader(derived))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), img.Bounds(), "cover 裁剪为恰好 64x64")
+	assert.Equal(t, 1, store.count("avatars/u1.png"))
+
+	// 缓存命中：不再读取原图、不再缩放，内容与 ETag 相同
+	hits := metrics.GetCounter("web_image_derived_total", "cache", "hit").Value()
+	w = ut.PerformRequest(engine, "GET", "/images/avatars/u1.png?w=64&h=64", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, derived, w.Body.Bytes())
+	assert.Equal(t, etag, string(w.Header().Peek("ETag")))
+	assert.Equal(t, 1, store.count("avatars/u1.png"))
+	assert.Equal(t, hits+1, metrics.GetCounter("web_image_derived_total", "cache", "hit").Value())
+
+	w = ut.PerformRequest(engine, "GET", "/images/avatars/u1.png?w=64&h=64", nil, ut.Header{Key: "If-None-Match", Value: etag})
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	w = ut.PerformRequest(engine, "GET", "/images/avatars/u1.png?w=50", nil)
+	require.Equal(t, 200, w.Code)
+	img, err = png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 50, 25), img.Bounds(), "只给出宽度时等比缩放")
+
+	w = ut.PerformRequest(engine, "GET", "/images/avatars/u1.png", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, original, w.Body.Bytes(), "不带参数返回原图")
+
+	assert.Equal(t, 404, ut.PerformRequest(engine, "GET", "/images/avatars/missing.png?w=64&h=64", nil).Code)
+}
+
+func TestImageRoute_RejectsUnlistedUnsignedAndOversized(t *testing.T) {
+	engine, store := newImageEngine(t, ImageConfig{
+		Sizes:           []string{"64x64"},
+		SigningKeys:     []string{"new-key", "old-key"},
+		MaxWidth:        400,
+		MaxSourcePixels: 100 * 100,
+	})
+	putImage(t, store, "p/1.png", encodePNG(t, twoColorImage(80, 40)))
+	putImage(t, store, "p/huge.png", encodePNG(t, twoColorImage(200, 200)))
+
+	assert.Equal(t, 403, ut.PerformRequest(engine, "GET", "/images/p/1.png?w=65&h=64", nil).Code, "不在白名单中")
+	assert.Equal(t, 400, ut.PerformRequest(engine, "GET", "/images/p/1.png?w=-1", nil).Code)
+	assert.Equal(t, 400, ut.PerformRequest(engine, "GET", "/images/p/1.png?w=64&h=64&fit=stretch", nil).Code)
+
+	signed := SignImageURL([]byte("old-key"), "/images", "p/1.png", 120, 0, ImageFitContain)
+	w := ut.PerformRequest(engine, "GET", signed, nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 120, img.Bounds().Dx())
+
+	tampered := strings.Replace(signed, "w=120", "w=121", 1)
+	assert.Equal(t, 403, ut.PerformRequest(engine, "GET", tampered, nil).Code, "签名不覆盖修改后的尺寸")
+	oversized := SignImageURL([]byte("new-key"), "/images", "p/1.png", 4000, 0, "")
+	assert.Equal(t, 400, ut.PerformRequest(engine, "GET", oversized, nil).Code, "超过 maxWidth")
+
+	w = ut.PerformRequest(engine, "GET", "/images/p/huge.png?w=64&h=64", nil)
+	assert.Equal(t, 422, w.Code, "原图像素超过上限时不解码")
+	_, err = store.diskStore.Open(context.Background(), "_derived/images/"+derivedKey("p/huge.png", imageSpec{64, 64, ImageFitCover}, ""))
+	assert.Error(t, err, "拒绝的请求不写入缓存")
+}
+
+func TestImageRoute_EXIFOrientationAndFormats(t *testing.T) {
+	engine, store := newImageEngine(t, ImageConfig{
+		Sizes:    []string{"20x0"},
+		Encoders: map[string]ImageEncoder{"image/gif": func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }},
+	})
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, twoColorImage(40, 20), &jpeg.Options{Quality: 95}))
+	assert.Equal(t, 1, jpegOrientation(buf.Bytes()))
+	rotated := withOrientation(buf.Bytes(), 6)
+	require.Equal(t, 6, jpegOrientation(rotated))
+	putImage(t, store, "photo.jpg", rotated)
+
+	w := ut.PerformRequest(engine, "GET", "/images/photo.jpg?w=20", nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Equal(t, "image/jpeg", string(w.Header().ContentType()), "JPEG 原图输出 JPEG")
+	assert.Equal(t, "Accept", string(w.Header().Peek("Vary")))
+	img, err := jpeg.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 20, 40), img.Bounds(), "顺时针旋转 90° 后为竖图")
+	// 原图左侧（红）旋转到上方
+	r, _, b, _ := img.At(10, 5).RGBA()
+	assert.Greater(t, r, b)
+	r, _, b, _ = img.At(10, 35).RGBA()
+	assert.Greater(t, b, r)
+
+	w = ut.PerformRequest(engine, "GET", "/images/photo.jpg?w=20", nil, ut.Header{Key: "Accept", Value: "image/gif,image/*"})
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "image/gif", string(w.Header().ContentType()))
+}
+
+func TestDiskImageCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache, err := NewDiskImageCache(dir, 10)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(ctx, "k/a", []byte("aaaa")))
+	require.NoError(t, cache.Put(ctx, "k/b", []byte("bbbb")))
+	_, ok := cache.Get(ctx, "k/a")
+	require.True(t, ok)
+	require.NoError(t, cache.Put(ctx, "k/c", []byte("cccc")))
+
+	_, ok = cache.Get(ctx, "k/b")
+	assert.False(t, ok, "最久未使用的被淘汰")
+	data, ok := cache.Get(ctx, "k/a")
+	assert.True(t, ok)
+	assert.Equal(t, "aaaa", string(data))
+	assert.Equal(t, int64(8), cache.Size())
+
+	reopened, err := NewDiskImageCache(dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), reopened.Size(), "重启后纳入已有文件")
+	_, ok = reopened.Get(ctx, "k/c")
+	assert.True(t, ok)
+}
+
+func TestStorageImageCache_RejectsPartialWrites(t *testing.T) {
+	ctx := context.Background()
+	store := diskStore{dir: t.TempDir()}
+	cache := NewStorageImageCache(store, "")
+	require.NoError(t, cache.Put(ctx, "x/64x64-cover", []byte("derived")))
+	data, ok := cache.Get(ctx, "x/64x64-cover")
+	require.True(t, ok)
+	assert.Equal(t, "derived", string(data))
+
+	w, err := store.Append(ctx, "_derived/images/x/64x64-cover", 3)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, ok = cache.Get(ctx, "x/64x64-cover")
+	assert.False(t, ok, "截断的文件按未命中处理")
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// toNRGBA 转换为 NRGBA（缩放与旋转都在 NRGBA 上进行）
+func toNRGBA(src image.Image) *image.NRGBA {
+	if img, ok := src.(*image.NRGBA); ok && img.Rect.Min == (image.Point{}) {
+		return img
+	}
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// fitImage 按派生参数缩放裁剪
+func fitImage(src *image.NRGBA, spec imageSpec) *image.NRGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	w, h := spec.w, spec.h
+	switch {
+	case h == 0:
+		h = max(int(math.Round(float64(sh)*float64(w)/float64(sw))), 1)
+	case w == 0:
+		w = max(int(math.Round(float64(sw)*float64(h)/float64(sh))), 1)
+	case spec.fit == ImageFitContain:
+		scale := math.Min(float64(w)/float64(sw), float64(h)/float64(sh))
+		w = max(int(math.Round(float64(sw)*scale)), 1)
+		h = max(int(math.Round(float64(sh)*scale)), 1)
+	default:
+		// cover：取与目标宽高比相同、尽可能大的居中区域
+		crop := src.Rect
+		if sw*h > sh*w {
+			cw := max(sh*w/h, 1)
+			crop.Min.X = (sw - cw) / 2
+			crop.Max.X = crop.Min.X + cw
+		} else {
+			ch := max(sw*h/w, 1)
+			crop.Min.Y = (sh - ch) / 2
+			crop.Max.Y = crop.Min.Y + ch
+		}
+		return resample(src, crop, w, h)
+	}
+	return resample(src, src.Rect, w, h)
+}
+
+// resample 把 src 的 area 区域缩放为 w×h（区域平均，按 alpha 加权避免透明边缘发黑；放大时退化为最近邻）
+func resample(src *image.NRGBA, area image.Rectangle, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	aw, ah := area.Dx(), area.Dy()
+	for y := range h {
+		y0 := area.Min.Y + y*ah/h
+		y1 := max(area.Min.Y+(y+1)*ah/h, y0+1)
+		for x := range w {
+			x0 := area.Min.X + x*aw/w
+			x1 := max(area.Min.X+(x+1)*aw/w, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				off := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					p := src.Pix[off : off+4 : off+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					b += uint64(p[2]) * pa
+					a += pa
+					n++
+					off += 4
+				}
+			}
+			d := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[d] = uint8(r / a)
+				dst.Pix[d+1] = uint8(g / a)
+				dst.Pix[d+2] = uint8(b / a)
+			}
+			dst.Pix[d+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// orient 按 EXIF 方向（1-8）旋转/翻转为正常显示的方向
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := sw, sh
+	if orientation >= 5 {
+		dw, dh = sh, sw
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = sw-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = sw-1-x, sh-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, sh-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, sh-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = sw-1-y, sh-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = sw-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation 读取 JPEG 的 EXIF 方向（没有或无法解析时为 1）
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			i++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7: // 没有长度的标记
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9: // 图像数据开始：EXIF 只出现在它之前
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation 从 TIFF 结构的 IFD0 中读取 Orientation（0x0112）
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for k := range entries {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,569 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// ComponentIngest 采集缓冲组件名
+const ComponentIngest = "ingest"
+
+var (
+	// ErrIngestDisabled 未启用采集缓冲（未配置 ingest.enabled）
+	ErrIngestDisabled = errors.New("ingest disabled")
+	// ErrIngestUnknownTopic topic 没有注册 sink
+	ErrIngestUnknownTopic = errors.New("ingest topic has no sink")
+	// ErrIngestClosed 采集缓冲已关闭（优雅关闭中）
+	ErrIngestClosed = errors.New("ingest closed")
+)
+
+// IngestConfig 采集缓冲配置（遥测等允许少量丢失的高频写入）
+//
+// 记录先进入有界内存队列，由后台 drainer 按批写入 sink；内存满时溢写到磁盘段文件，
+// 内存积压清空后再消化磁盘；磁盘也达到上限时淘汰最旧的段（计入丢弃）。sink 故障期间接入不受影响，
+// 内存占用始终有界
+//
+// Example:
+//
+//	[web.ingest]
+//	enabled = true
+//	memoryRecords = 65536       # 内存队列容量（条）
+//	memoryBytes = 67108864      # 内存队列 payload 总字节上限
+//	batchSize = 500             # 每批最多写入的记录数
+//	interval = 200              # 不足一批时最长等待（毫秒）
+//	spillDir = "data/ingest"    # 溢写目录，为空时内存满直接丢弃
+//	segmentBytes = 67108864     # 单个段文件大小
+//	diskBytes = 1073741824      # 溢写总大小上限
+//	retryBackoff = 5000         # sink 失败后重试间隔上限（毫秒）
+type IngestConfig struct {
+	Enabled       bool   `toml:"enabled"`
+	MemoryRecords int    `toml:"memoryRecords"` // 默认 65536
+	MemoryBytes   int64  `toml:"memoryBytes"`   // 默认 64MB
+	BatchSize     int    `toml:"batchSize"`     // 默认 500
+	Interval      int    `toml:"interval"`      // 毫秒，默认 200
+	SpillDir      string `toml:"spillDir"`      // 溢写目录（可选）
+	SegmentBytes  int64  `toml:"segmentBytes"`  // 默认 64MB（不超过 diskBytes 的 1/4）
+	DiskBytes     int64  `toml:"diskBytes"`     // 默认 1GB
+	RetryBackoff  int    `toml:"retryBackoff"`  // 毫秒，默认 5000
+}
+
+func (c IngestConfig) withDefaults() IngestConfig {
+	if c.MemoryRecords <= 0 {
+		c.MemoryRecords = 65536
+	}
+	if c.MemoryBytes <= 0 {
+		c.MemoryBytes = 64 << 20
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.Interval <= 0 {
+		c.Interval = 200
+	}
+	if c.DiskBytes <= 0 {
+		c.DiskBytes = 1 << 30
+	}
+	if c.SegmentBytes <= 0 {
+		c.SegmentBytes = 64 << 20
+	}
+	c.SegmentBytes = min(c.SegmentBytes, max(c.DiskBytes/4, 1))
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 5000
+	}
+	return c
+}
+
+// IngestRecord 一条采集记录
+type IngestRecord struct {
+	Topic   string
+	Payload []byte
+	Time    time.Time // 接入时间
+}
+
+// IngestSink 批量写入一个 topic 的记录（DB 批量插入、outbox、外部队列等）
+//
+// 返回错误时整批按退避重试（至少一次投递），sink 需要容忍重复
+type IngestSink func(ctx context.Context, records []IngestRecord) error
+
+// IngestStats 采集缓冲状态
+type IngestStats struct {
+	Depth        int           `json:"depth"`        // 内存队列中的记录数
+	MemoryBytes  int64         `json:"memoryBytes"`  // 内存队列 payload 字节数
+	SpillRecords int           `json:"spillRecords"` // 磁盘中待消化的记录数
+	SpillBytes   int64         `json:"spillBytes"`   // 段文件总字节数
+	Accepted     int64         `json:"accepted"`     // 接入的记录数
+	Spilled      int64         `json:"spilled"`      // 溢写到磁盘的记录数
+	Delivered    int64         `json:"delivered"`    // 写入 sink 的记录数
+	Dropped      int64         `json:"dropped"`      // 丢弃的记录数
+	Lag          time.Duration `json:"lag"`          // 最近一批记录从接入到写入 sink 的时间
+}
+
+// Ingester 有界内存队列 + 磁盘溢写的采集缓冲
+type Ingester struct {
+	config   IngestConfig
+	interval time.Duration
+	sinks    atomic.Pointer[map[string]IngestSink] // 写时复制，接入路径无锁读取
+
+	mu       sync.Mutex
+	ring     []IngestRecord
+	head     int
+	n        int
+	memBytes int64
+	closed   bool
+	notify   chan struct{}
+
+	spill *spillLog // 未配置 spillDir 时为 nil
+
+	// drainer 状态
+	pending      []IngestRecord
+	pendingSpill bool // pending 来自磁盘（仍留在段文件中，关闭时不需要重新溢写）
+
+	accepted, spilled, delivered, dropped atomic.Int64
+	lag                                   atomic.Int64
+
+	depthGauge    *metrics.Gauge
+	memGauge      *metrics.Gauge
+	spillGauge    *metrics.Gauge
+	spillRecGauge *metrics.Gauge
+	lagGauge      *metrics.Gauge
+	acceptedTotal *metrics.Counter
+	spilledTotal  *metrics.Counter
+	deliverTotal  *metrics.Counter
+	sinkErrors    *metrics.Counter
+}
+
+// NewIngester 创建采集缓冲；配置了 spillDir 时打开溢写目录，上次运行留下的段文件会在内存积压清空后继续投递
+func NewIngester(config IngestConfig) (*Ingester, error) {
+	config = config.withDefaults()
+	i := &Ingester{
+		config:        config,
+		interval:      time.Duration(config.Interval) * time.Millisecond,
+		ring:          make([]IngestRecord, config.MemoryRecords),
+		notify:        make(chan struct{}, 1),
+		depthGauge:    metrics.GetGauge("web_ingest_depth"),
+		memGauge:      metrics.GetGauge("web_ingest_memory_bytes"),
+		spillGauge:    metrics.GetGauge("web_ingest_spill_bytes"),
+		spillRecGauge: metrics.GetGauge("web_ingest_spill_records"),
+		lagGauge:      metrics.GetGauge("web_ingest_drain_lag_seconds"),
+		acceptedTotal: metrics.GetCounter("web_ingest_accepted_total"),
+		spilledTotal:  metrics.GetCounter("web_ingest_spilled_total"),
+		deliverTotal:  metrics.GetCounter("web_ingest_delivered_total"),
+		sinkErrors:    metrics.GetCounter("web_ingest_sink_errors_total"),
+	}
+	i.sinks.Store(&map[string]IngestSink{})
+	if config.SpillDir != "" {
+		spill, err := openSpillLog(config.SpillDir, config.SegmentBytes, config.DiskBytes)
+		if err != nil {
+			return nil, err
+		}
+		spill.onDrop = func(records int) { i.drop("disk_full", records) }
+		spill.onCorrupt = func() { metrics.GetCounter("web_ingest_corrupt_segments_total").Inc() }
+		i.spill = spill
+		if size, records := spill.stats(); records > 0 {
+			log.Infof("[Ingest] 恢复溢写记录 %d 条（%d 字节）", records, size)
+		}
+		i.updateSpillGauges()
+	}
+	return i, nil
+}
+
+// RegisterSink 注册 topic 的 sink（同名覆盖）
+func (i *Ingester) RegisterSink(topic string, sink IngestSink) {
+	if topic == "" || len(topic) > 0xFFFF {
+		panic(fmt.Sprintf("ingest topic 长度无效: %q", topic))
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	old := *i.sinks.Load()
+	sinks := make(map[string]IngestSink, len(old)+1)
+	for k, v := range old {
+		sinks[k] = v
+	}
+	sinks[topic] = sink
+	i.sinks.Store(&sinks)
+}
+
+// Ingest 接入一条记录（payload 会被复制，调用返回后可以复用）
+//
+// 内存队列有空间时直接入队；内存满时溢写到磁盘，磁盘也满时淘汰最旧的数据。
+// 丢弃只计入 web_ingest_dropped_total，不返回错误；只有 topic 未注册和已关闭时返回错误
+func (i *Ingester) Ingest(ctx context.Context, topic string, payload []byte) error {
+	if _, ok := (*i.sinks.Load())[topic]; !ok {
+		return ErrIngestUnknownTopic
+	}
+	rec := IngestRecord{Topic: topic, Time: time.Now()}
+	size := int64(len(payload))
+
+	i.mu.Lock()
+	if i.closed {
+		i.mu.Unlock()
+		return ErrIngestClosed
+	}
+	if i.n < len(i.ring) && i.memBytes+size <= i.config.MemoryBytes {
+		rec.Payload = bytes.Clone(payload)
+		i.ring[(i.head+i.n)%len(i.ring)] = rec
+		i.n++
+		i.memBytes += size
+		n, memBytes := i.n, i.memBytes
+		i.mu.Unlock()
+		i.accepted.Add(1)
+		i.acceptedTotal.Inc()
+		i.depthGauge.Set(float64(n))
+		i.memGauge.Set(float64(memBytes))
+		if n == i.config.BatchSize {
+			select {
+			case i.notify <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	}
+	// 内存已满：持锁溢写，保证关闭时不会漏掉正在写入的记录
+	rec.Payload = payload
+	i.spillLocked(&rec, "memory_full")
+	i.mu.Unlock()
+	i.accepted.Add(1)
+	i.acceptedTotal.Inc()
+	return nil
+}
+
+// spillLocked 把一条记录写入磁盘，失败时丢弃（调用方持有 i.mu）
+func (i *Ingester) spillLocked(rec *IngestRecord, reason string) {
+	if i.spill == nil {
+		i.drop(reason, 1)
+		return
+	}
+	if err := i.spill.append(rec); err != nil {
+		if errors.Is(err, errSpillFull) {
+			i.drop("disk_full", 1)
+		} else {
+			log.Errorf("[Ingest] 溢写失败: %v", err)
+			i.drop("spill_error", 1)
+		}
+		return
+	}
+	i.spilled.Add(1)
+	i.spilledTotal.Inc()
+	i.updateSpillGauges()
+}
+
+func (i *Ingester) drop(reason string, records int) {
+	i.dropped.Add(int64(records))
+	metrics.GetCounter("web_ingest_dropped_total", "reason", reason).Add(int64(records))
+}
+
+func (i *Ingester) updateSpillGauges() {
+	size, records := i.spill.stats()
+	i.spillGauge.Set(float64(size))
+	i.spillRecGauge.Set(float64(records))
+}
+
+// popLocked 从内存队列取出至多 n 条记录（调用方持有 i.mu）
+func (i *Ingester) popLocked(n int) []IngestRecord {
+	n = min(n, i.n)
+	batch := make([]IngestRecord, n)
+	for k := range batch {
+		idx := (i.head + k) % len(i.ring)
+		batch[k] = i.ring[idx]
+		i.memBytes -= int64(len(batch[k].Payload))
+		i.ring[idx] = IngestRecord{}
+	}
+	i.head = (i.head + n) % len(i.ring)
+	i.n -= n
+	i.depthGauge.Set(float64(i.n))
+	i.memGauge.Set(float64(i.memBytes))
+	return batch
+}
+
+// next 取下一批：内存满一批（或 flush 时有任何记录）先取内存，内存为空时消化磁盘
+func (i *Ingester) next(flush bool) (batch []IngestRecord, fromSpill bool) {
+	i.mu.Lock()
+	if i.n >= i.config.BatchSize || (flush && i.n > 0) {
+		batch = i.popLocked(i.config.BatchSize)
+		i.mu.Unlock()
+		return batch, false
+	}
+	empty := i.n == 0
+	i.mu.Unlock()
+	if !empty || i.spill == nil {
+		return nil, false
+	}
+	batch, err := i.spill.read(i.config.BatchSize, true)
+	if err != nil {
+		log.Errorf("[Ingest] 读取溢写段失败: %v", err)
+	}
+	i.updateSpillGauges()
+	return batch, len(batch) > 0
+}
+
+// Run 持续把记录批量写入 sink，直到 ctx 取消（之后由 Close 处理剩余记录）
+func (i *Ingester) Run(ctx context.Context) {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	maxBackoff := time.Duration(i.config.RetryBackoff) * time.Millisecond
+	var backoff time.Duration
+	for {
+		if len(i.pending) == 0 {
+			batch, fromSpill := i.next(false)
+			if batch == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-i.notify:
+				case <-ticker.C:
+					batch, fromSpill = i.next(true)
+				}
+			}
+			if batch == nil {
+				i.lag.Store(0)
+				i.lagGauge.Set(0)
+				continue
+			}
+			i.pending, i.pendingSpill = batch, fromSpill
+		}
+		if i.deliver(ctx) {
+			backoff = 0
+			continue
+		}
+		backoff = min(max(2*backoff, 100*time.Millisecond), maxBackoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// deliver 按 topic 分组写入 pending，失败的 topic 留在 pending 中等待重试；全部成功时返回 true
+func (i *Ingester) deliver(ctx context.Context) bool {
+	sinks := *i.sinks.Load()
+	var failed []IngestRecord
+	for len(i.pending) > 0 {
+		topic := i.pending[0].Topic
+		var group, rest []IngestRecord
+		for _, rec := range i.pending {
+			if rec.Topic == topic {
+				group = append(group, rec)
+			} else {
+				rest = append(rest, rec)
+			}
+		}
+		i.pending = rest
+
+		sink, ok := sinks[topic]
+		if !ok {
+			log.Warnf("[Ingest] topic %s 没有注册 sink，丢弃 %d 条记录", topic, len(group))
+			i.drop("no_sink", len(group))
+			continue
+		}
+		if err := callSink(ctx, sink, group); err != nil {
+			i.sinkErrors.Inc()
+			log.Warnf("[Ingest] 写入 %s 失败（%d 条，稍后重试）: %v", topic, len(group), err)
+			failed = append(failed, group...)
+			continue
+		}
+		i.delivered.Add(int64(len(group)))
+		i.deliverTotal.Add(int64(len(group)))
+		lag := time.Since(group[0].Time)
+		i.lag.Store(int64(lag))
+		i.lagGauge.Set(lag.Seconds())
+	}
+	i.pending = failed
+	if len(failed) > 0 {
+		return false
+	}
+	if i.pendingSpill {
+		i.spill.ack()
+		i.updateSpillGauges()
+		i.pendingSpill = false
+	}
+	return true
+}
+
+// callSink 调用 sink（panic 视为失败）
+func callSink(ctx context.Context, sink IngestSink, records []IngestRecord) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panic: %v", r)
+		}
+	}()
+	return sink(ctx, records)
+}
+
+// Close 停止接入并处理剩余记录（Run 返回后调用）
+//
+// 在 ctx 的一半预算内继续把内存中的记录写入 sink，之后仍未写入的记录溢写到磁盘（未配置溢写时丢弃），
+// 最后 fsync 并关闭段文件。下次启动时从段文件继续投递
+func (i *Ingester) Close(ctx context.Context) error {
+	i.mu.Lock()
+	i.closed = true
+	i.mu.Unlock()
+
+	budget := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		budget = time.Until(deadline) / 2
+	}
+	flushCtx, cancel := context.WithTimeout(ctx, budget)
+	for flushCtx.Err() == nil {
+		if len(i.pending) == 0 {
+			i.mu.Lock()
+			i.pending, i.pendingSpill = i.popLocked(i.config.BatchSize), false
+			i.mu.Unlock()
+			if len(i.pending) == 0 {
+				break
+			}
+		}
+		if !i.deliver(flushCtx) {
+			break
+		}
+	}
+	cancel()
+
+	i.mu.Lock()
+	rest := i.popLocked(i.n)
+	if !i.pendingSpill {
+		rest = append(i.pending, rest...)
+	}
+	i.pending = nil
+	for k := range rest {
+		i.spillLocked(&rest[k], "shutdown")
+	}
+	i.mu.Unlock()
+	if len(rest) > 0 {
+		log.Infof("[Ingest] 关闭时 %d 条记录未写入 sink，已溢写到磁盘", len(rest))
+	}
+	if i.spill == nil {
+		return nil
+	}
+	return i.spill.close()
+}
+
+// Stats 当前状态
+func (i *Ingester) Stats() IngestStats {
+	i.mu.Lock()
+	stats := IngestStats{Depth: i.n, MemoryBytes: i.memBytes}
+	i.mu.Unlock()
+	if i.spill != nil {
+		stats.SpillBytes, stats.SpillRecords = i.spill.stats()
+	}
+	stats.Accepted = i.accepted.Load()
+	stats.Spilled = i.spilled.Load()
+	stats.Delivered = i.delivered.Load()
+	stats.Dropped = i.dropped.Load()
+	stats.Lag = time.Duration(i.lag.Load())
+	return stats
+}
+
+// Component 生命周期组件：启动 drainer，优雅关闭时处理剩余记录并 fsync 段文件
+//
+// dependsOn 为 sink 依赖的组件（如 web.ComponentDatabase），保证 sink 在依赖关闭之前完成最后的写入
+func (i *Ingester) Component(dependsOn ...string) Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return Component{
+		Name:      ComponentIngest,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				i.Run(runCtx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return i.Close(ctx)
+			}
+			cancel()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return i.Close(ctx)
+		},
+	}
+}
+
+var globalIngester atomic.Pointer[Ingester]
+
+// InitIngest 初始化全局采集缓冲（config.enabled 为 false 时关闭）
+//
+// NewServer 根据 [web.ingest] 自动调用并注册生命周期组件，drainer 在 MustRun 中启动
+func InitIngest(config IngestConfig) (*Ingester, error) {
+	if !config.Enabled {
+		globalIngester.Store(nil)
+		return nil, nil
+	}
+	i, err := NewIngester(config)
+	if err != nil {
+		return nil, err
+	}
+	globalIngester.Store(i)
+	return i, nil
+}
+
+// RegisterIngestSink 为全局采集缓冲注册 topic 的 sink（未启用时 panic）
+//
+// 使用方式：
+//
+//	web.RegisterIngestSink("telemetry", func(ctx context.Context, records []web.IngestRecord) error {
+//	    return queries.BulkInsertEvents(ctx, records)
+//	})
+func RegisterIngestSink(topic string, sink IngestSink) {
+	i := globalIngester.Load()
+	if i == nil {
+		panic("采集缓冲未启用，请配置 [web.ingest] enabled = true")
+	}
+	i.RegisterSink(topic, sink)
+}
+
+// Ingest 写入全局采集缓冲（未启用时返回 ErrIngestDisabled）
+//
+// 使用方式：
+//
+//	if err := web.Ingest(ctx, "telemetry", body); err != nil {
+//	    panic(web.BadRequestHTTP(err.Error()))
+//	}
+//	c.JSON(202, web.Success(nil))
+func Ingest(ctx context.Context, topic string, payload []byte) error {
+	i := globalIngester.Load()
+	if i == nil {
+		return ErrIngestDisabled
+	}
+	return i.Ingest(ctx, topic, payload)
+}
+
+// IngestHandler 把请求体原样写入采集缓冲的处理函数，接入后立即响应 202
+//
+// 使用方式：
+//
+//	h.POST("/telemetry", web.IngestHandler("telemetry"))
+func IngestHandler(topic string) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		body, err := c.Body()
+		if err != nil && err != io.EOF {
+			panic(BadRequestHTTP("Invalid request body"))
+		}
+		switch err := Ingest(ctx, topic, body); {
+		case errors.Is(err, ErrIngestClosed), errors.Is(err, ErrIngestDisabled):
+			panic(NewHTTPException(consts.StatusServiceUnavailable, consts.StatusServiceUnavailable, MsgOverloaded))
+		case err != nil:
+			panic(NotFoundHTTP(err.Error()))
+		}
+		c.JSON(consts.StatusAccepted, Success(nil))
+	}
+}
@@ -0,0 +1,372 @@
+package web
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 溢写段文件格式
+//
+//	文件头  8 字节 segmentMagic
+//	记录    4 字节 body 长度 | 4 字节 body 的 CRC32C | body
+//	body    2 字节 topic 长度 | topic | 8 字节 UnixNano | payload
+//
+// 整数均为小端序。读取时遇到长度越界或 CRC 不匹配（如崩溃时写了一半的尾部）即视为段结束
+const (
+	segmentMagic     = "INGSEG1\n"
+	segmentExt       = ".seg"
+	recordHeaderSize = 8
+	maxSpillRecord   = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errSpillFull 溢写已达磁盘上限且没有可以淘汰的段
+var errSpillFull = errors.New("ingest spill full")
+
+// spillSegment 一个段文件
+type spillSegment struct {
+	seq     uint64
+	path    string
+	size    int64
+	records int
+}
+
+// spillLog 按序号命名的追加写段文件（写入方为 Ingest，读取方只有 drainer 一个 goroutine）
+type spillLog struct {
+	dir          string
+	segmentBytes int64
+	maxBytes     int64
+
+	mu       sync.Mutex
+	segments []*spillSegment // 按序号排列，写入中的段在最后
+	active   *os.File        // 写入中的段（nil 表示没有）
+	w        *bufio.Writer
+	nextSeq  uint64
+	bytes    int64 // 全部段的字节数
+	records  int   // 全部段中未读取的记录数
+	reading  *spillSegment
+
+	// 读取状态（只由 drainer 访问）
+	rf     *os.File
+	r      *bufio.Reader
+	rDone  bool // 当前段已读完，等待投递确认后删除
+	rCount int  // 当前段已读出的记录数
+
+	onDrop    func(records int) // 淘汰段时回调
+	onCorrupt func()
+}
+
+// openSpillLog 打开溢写目录，已有的段文件（上次运行留下的）全部作为待读取的段
+func openSpillLog(dir string, segmentBytes, maxBytes int64) (*spillLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建溢写目录失败: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取溢写目录失败: %w", err)
+	}
+	l := &spillLog{dir: dir, segmentBytes: segmentBytes, maxBytes: maxBytes, nextSeq: 1}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := &spillSegment{seq: seq, path: filepath.Join(dir, name)}
+		if seg.size, seg.records, err = scanSegment(seg.path); err != nil {
+			return nil, err
+		}
+		l.segments = append(l.segments, seg)
+		l.bytes += seg.size
+		l.records += seg.records
+		l.nextSeq = max(l.nextSeq, seq+1)
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].seq < l.segments[j].seq })
+	return l, nil
+}
+
+// scanSegment 统计段文件中完整的记录数
+func scanSegment(path string) (size int64, records int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("打开溢写段失败: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	r := bufio.NewReader(f)
+	if !readMagic(r) {
+		return info.Size(), 0, nil
+	}
+	var rec IngestRecord
+	for {
+		if err := readSpillRecord(r, &rec); err != nil {
+			return info.Size(), records, nil
+		}
+		records++
+	}
+}
+
+func readMagic(r *bufio.Reader) bool {
+	var magic [len(segmentMagic)]byte
+	_, err := io.ReadFull(r, magic[:])
+	return err == nil && string(magic[:]) == segmentMagic
+}
+
+// append 追加一条记录（超过磁盘上限时淘汰最旧的段）
+func (l *spillLog) append(rec *IngestRecord) error {
+	bodyLen := 2 + len(rec.Topic) + 8 + len(rec.Payload)
+	size := int64(recordHeaderSize + bodyLen)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.bytes+size > l.maxBytes {
+		if !l.evictLocked() {
+			return errSpillFull
+		}
+	}
+	if l.active == nil {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	var head [recordHeaderSize + 2]byte
+	binary.LittleEndian.PutUint32(head[0:], uint32(bodyLen))
+	binary.LittleEndian.PutUint16(head[8:], uint16(len(rec.Topic)))
+	var ts [8]byte
+	binary.LittleEndian.PutUint64(ts[:], uint64(rec.Time.UnixNano()))
+	crc := crc32.Update(0, crcTable, head[8:])
+	crc = crc32.Update(crc, crcTable, []byte(rec.Topic))
+	crc = crc32.Update(crc, crcTable, ts[:])
+	crc = crc32.Update(crc, crcTable, rec.Payload)
+	binary.LittleEndian.PutUint32(head[4:], crc)
+
+	l.w.Write(head[:])
+	l.w.WriteString(rec.Topic)
+	l.w.Write(ts[:])
+	if _, err := l.w.Write(rec.Payload); err != nil {
+		return fmt.Errorf("写入溢写段失败: %w", err)
+	}
+	seg := l.segments[len(l.segments)-1]
+	seg.size += size
+	seg.records++
+	l.bytes += size
+	l.records++
+	if seg.size >= l.segmentBytes {
+		return l.sealLocked()
+	}
+	return nil
+}
+
+// rotateLocked 创建新的写入段
+func (l *spillLog) rotateLocked() error {
+	seg := &spillSegment{seq: l.nextSeq, path: filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.nextSeq, segmentExt))}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("创建溢写段失败: %w", err)
+	}
+	l.nextSeq++
+	l.active, l.w = f, bufio.NewWriterSize(f, 64<<10)
+	l.w.WriteString(segmentMagic)
+	seg.size = int64(len(segmentMagic))
+	l.bytes += seg.size
+	l.segments = append(l.segments, seg)
+	return nil
+}
+
+// sealLocked 封存写入中的段：刷盘并 fsync 后关闭，之后可以被读取
+func (l *spillLog) sealLocked() error {
+	if l.active == nil {
+		return nil
+	}
+	f, w := l.active, l.w
+	l.active, l.w = nil, nil
+	err := w.Flush()
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("封存溢写段失败: %w", err)
+	}
+	return nil
+}
+
+// evictLocked 删除最旧的、未在读取也未在写入的段，计入丢弃；没有可删除的段时返回 false
+func (l *spillLog) evictLocked() bool {
+	for i, seg := range l.segments {
+		if seg == l.reading || (l.active != nil && i == len(l.segments)-1) {
+			continue
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return false
+		}
+		l.segments = append(l.segments[:i], l.segments[i+1:]...)
+		l.bytes -= seg.size
+		l.records -= seg.records
+		if l.onDrop != nil {
+			l.onDrop(seg.records)
+		}
+		return true
+	}
+	return false
+}
+
+// read 读取至多 n 条记录（drainer 调用）
+//
+// 没有封存的段时，sealActive 为 true 则先封存写入中的段（内存积压已清空，开始消化磁盘）。
+// 段读完后等 ack 确认投递再删除，投递前崩溃时重启后重新投递（至少一次）
+func (l *spillLog) read(n int, sealActive bool) ([]IngestRecord, error) {
+	for {
+		if l.rf == nil {
+			if err := l.openNext(sealActive); err != nil || l.rf == nil {
+				return nil, err
+			}
+		}
+		var batch []IngestRecord
+		for len(batch) < n && !l.rDone {
+			var rec IngestRecord
+			err := readSpillRecord(l.r, &rec)
+			if err == io.EOF {
+				l.rDone = true
+				break
+			}
+			if err != nil {
+				if l.onCorrupt != nil {
+					l.onCorrupt()
+				}
+				l.rDone = true
+				break
+			}
+			batch = append(batch, rec)
+		}
+		l.rCount += len(batch)
+		l.mu.Lock()
+		l.records -= len(batch)
+		l.mu.Unlock()
+		if len(batch) > 0 || !l.rDone {
+			return batch, nil
+		}
+		// 段中没有剩余记录（空段或尾部损坏），直接删除后读下一个段
+		l.ack()
+	}
+}
+
+// openNext 打开最旧的封存段
+func (l *spillLog) openNext(sealActive bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.segments) == 0 {
+		return nil
+	}
+	if l.active != nil && len(l.segments) == 1 {
+		if !sealActive || l.segments[0].records == 0 {
+			return nil
+		}
+		if err := l.sealLocked(); err != nil {
+			return err
+		}
+	}
+	seg := l.segments[0]
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return fmt.Errorf("打开溢写段失败: %w", err)
+	}
+	l.rf, l.r, l.rDone, l.rCount, l.reading = f, bufio.NewReaderSize(f, 64<<10), false, 0, seg
+	if !readMagic(l.r) {
+		l.rDone = true
+	}
+	return nil
+}
+
+// ack 确认已读出的记录投递成功；当前段已读完时删除
+func (l *spillLog) ack() {
+	if l.rf == nil || !l.rDone {
+		return
+	}
+	l.rf.Close()
+	l.rf, l.r = nil, nil
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seg := l.reading
+	l.reading = nil
+	for i, s := range l.segments {
+		if s == seg {
+			l.segments = append(l.segments[:i], l.segments[i+1:]...)
+			break
+		}
+	}
+	l.bytes -= seg.size
+	// 损坏段中未读出的记录不再计入积压
+	l.records -= seg.records - l.rCount
+	os.Remove(seg.path)
+}
+
+// stats 段文件占用的字节数与未读取的记录数
+func (l *spillLog) stats() (bytes int64, records int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bytes, l.records
+}
+
+// close 封存写入中的段（fsync），关闭读取中的段（未确认的记录重启后重新投递）
+func (l *spillLog) close() error {
+	if l.rf != nil {
+		l.rf.Close()
+		l.rf, l.r = nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reading = nil
+	return l.sealLocked()
+}
+
+// readSpillRecord 读取一条记录（payload 为新分配的切片）
+func readSpillRecord(r *bufio.Reader, rec *IngestRecord) error {
+	var head [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return err
+		}
+		return io.EOF
+	}
+	bodyLen := binary.LittleEndian.Uint32(head[0:])
+	if bodyLen < 10 || bodyLen > maxSpillRecord {
+		return fmt.Errorf("溢写记录长度无效: %d", bodyLen)
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(head[4:]) {
+		return errors.New("溢写记录 CRC 不匹配")
+	}
+	topicLen := int(binary.LittleEndian.Uint16(body))
+	if 2+topicLen+8 > len(body) {
+		return fmt.Errorf("溢写记录 topic 长度无效: %d", topicLen)
+	}
+	rec.Topic = string(body[2 : 2+topicLen])
+	rec.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(body[2+topicLen:])))
+	rec.Payload = body[2+topicLen+8:]
+	return nil
+}
@@ -0,0 +1,358 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink 记录写入的 payload，down 为 true 时模拟 sink 故障
+type recordingSink struct {
+	mu       sync.Mutex
+	payloads []string
+	batches  int
+	down     atomic.Bool
+}
+
+func (s *recordingSink) write(ctx context.Context, records []IngestRecord) error {
+	if s.down.Load() {
+		return errors.New("sink unavailable")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches++
+	for _, r := range records {
+		s.payloads = append(s.payloads, string(r.Payload))
+	}
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.payloads)
+}
+
+func (s *recordingSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.payloads...)
+}
+
+// runIngester 启动 drainer，测试结束时停止并关闭
+func runIngester(t *testing.T, i *Ingester) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		i.Run(ctx)
+	}()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			assert.NoError(t, i.Close(ctx))
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func ingestN(t *testing.T, i *Ingester, topic string, from, n int) {
+	t.Helper()
+	for k := from; k < from+n; k++ {
+		require.NoError(t, i.Ingest(context.Background(), topic, []byte(strconv.Itoa(k))))
+	}
+}
+
+func TestIngester_BatchesByTopic(t *testing.T) {
+	i, err := NewIngester(IngestConfig{BatchSize: 10, Interval: 20})
+	require.NoError(t, err)
+	events, clicks := &recordingSink{}, &recordingSink{}
+	i.RegisterSink("events", events.write)
+	i.RegisterSink("clicks", clicks.write)
+	runIngester(t, i)
+
+	assert.ErrorIs(t, i.Ingest(context.Background(), "unknown", nil), ErrIngestUnknownTopic)
+	payload := []byte("0")
+	require.NoError(t, i.Ingest(context.Background(), "events", payload))
+	payload[0] = 'x' // payload 被复制，调用方可以复用
+	ingestN(t, i, "events", 1, 24)
+	ingestN(t, i, "clicks", 0, 3)
+
+	require.Eventually(t, func() bool { return events.count() == 25 && clicks.count() == 3 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "0", events.received()[0])
+	assert.GreaterOrEqual(t, events.batches, 3, "按 batchSize 分批")
+	stats := i.Stats()
+	assert.Equal(t, int64(28), stats.Accepted)
+	assert.Equal(t, int64(28), stats.Delivered)
+	assert.Zero(t, stats.Depth)
+}
+
+func TestIngester_SpillsWhenMemoryFull(t *testing.T) {
+	dir := t.TempDir()
+	i, err := NewIngester(IngestConfig{MemoryRecords: 8, BatchSize: 5, Interval: 10, SpillDir: dir})
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	i.RegisterSink("events", sink.write)
+
+	ingestN(t, i, "events", 0, 100)
+	stats := i.Stats()
+	assert.Equal(t, 8, stats.Depth, "内存队列有界")
+	assert.Equal(t, int64(92), stats.Spilled)
+	assert.Equal(t, 92, stats.SpillRecords)
+	assert.Positive(t, stats.SpillBytes)
+
+	runIngester(t, i)
+	require.Eventually(t, func() bool { return sink.count() == 100 }, 2*time.Second, 5*time.Millisecond)
+	want := make([]string, 100)
+	for k := range want {
+		want[k] = strconv.Itoa(k)
+	}
+	assert.Equal(t, want, sink.received(), "先消化内存，再按写入顺序消化磁盘")
+	require.Eventually(t, func() bool { return i.Stats().SpillBytes == 0 }, time.Second, 5*time.Millisecond)
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	assert.Empty(t, files, "投递完成的段被删除")
+}
+
+func TestIngester_DiskCapDropsOldest(t *testing.T) {
+	dir := t.TempDir()
+	i, err := NewIngester(IngestConfig{MemoryRecords: 1, BatchSize: 100, Interval: 10, SpillDir: dir, SegmentBytes: 256, DiskBytes: 1024})
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	i.RegisterSink("events", sink.write)
+
+	ingestN(t, i, "events", 0, 500)
+	stats := i.Stats()
+	assert.LessOrEqual(t, stats.SpillBytes, int64(1024), "磁盘占用不超过上限")
+	assert.Positive(t, stats.Dropped)
+	assert.Equal(t, int64(500), stats.Accepted)
+
+	runIngester(t, i)
+	require.Eventually(t, func() bool {
+		s := i.Stats()
+		return s.Depth == 0 && s.SpillRecords == 0 && s.Delivered+s.Dropped == 500
+	}, 2*time.Second, 5*time.Millisecond)
+	got := sink.received()
+	assert.Equal(t, "0", got[0], "内存中的记录不受影响")
+	assert.Equal(t, "499", got[len(got)-1], "保留最新的数据")
+	assert.NotContains(t, got, "1", "淘汰的是最旧的段")
+}
+
+func TestIngester_ShutdownSpillsAndRestartResumes(t *testing.T) {
+	dir := t.TempDir()
+	conf := IngestConfig{MemoryRecords: 1000, BatchSize: 10, Interval: 10, RetryBackoff: 20, SpillDir: dir}
+	i, err := NewIngester(conf)
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	sink.down.Store(true)
+	i.RegisterSink("events", sink.write)
+	stop := runIngester(t, i)
+
+	ingestN(t, i, "events", 0, 50)
+	time.Sleep(50 * time.Millisecond) // drainer 已取出一批并在重试
+	stop()
+	assert.ErrorIs(t, i.Ingest(context.Background(), "events", []byte("late")), ErrIngestClosed)
+	assert.Equal(t, 50, i.Stats().SpillRecords, "关闭时未写入的记录（含重试中的一批）溢写到磁盘")
+
+	// 重启：从段文件继续投递
+	restarted, err := NewIngester(conf)
+	require.NoError(t, err)
+	assert.Equal(t, 50, restarted.Stats().SpillRecords)
+	sink.down.Store(false)
+	restarted.RegisterSink("events", sink.write)
+	runIngester(t, restarted)
+	require.Eventually(t, func() bool { return sink.count() == 50 }, 2*time.Second, 5*time.Millisecond)
+	assert.ElementsMatch(t, func() []string {
+		var want []string
+		for k := range 50 {
+			want = append(want, strconv.Itoa(k))
+		}
+		return want
+	}(), sink.received())
+}
+
+func TestIngester_CloseFlushesMemoryToSink(t *testing.T) {
+	i, err := NewIngester(IngestConfig{BatchSize: 1000, Interval: 10000})
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	i.RegisterSink("events", sink.write)
+	stop := runIngester(t, i)
+
+	ingestN(t, i, "events", 0, 30)
+	stop()
+	assert.Equal(t, 30, sink.count(), "关闭时先把内存中的记录写入 sink")
+	assert.Zero(t, i.Stats().Dropped)
+}
+
+func TestSpillLog_CorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	l, err := openSpillLog(dir, 1<<20, 1<<30)
+	require.NoError(t, err)
+	for k := range 3 {
+		require.NoError(t, l.append(&IngestRecord{Topic: "events", Payload: []byte(strconv.Itoa(k)), Time: time.Now()}))
+	}
+	require.NoError(t, l.close())
+
+	// 崩溃时写了一半的记录
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.Len(t, files, 1)
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, _ = f.Write([]byte{20, 0, 0, 0, 1, 2, 3, 4, 5})
+	require.NoError(t, f.Close())
+
+	l, err = openSpillLog(dir, 1<<20, 1<<30)
+	require.NoError(t, err)
+	_, records := l.stats()
+	assert.Equal(t, 3, records, "只统计完整的记录")
+	corrupt := 0
+	l.onCorrupt = func() { corrupt++ }
+	batch, err := l.read(10, true)
+	require.NoError(t, err)
+	require.Len(t, batch, 3)
+	assert.Equal(t, "events", batch[2].Topic)
+	assert.Equal(t, "2", string(batch[2].Payload))
+	assert.Equal(t, 1, corrupt)
+	l.ack()
+	size, records := l.stats()
+	assert.Zero(t, size)
+	assert.Zero(t, records)
+}
+
+func TestIngestHandler(t *testing.T) {
+	i, err := InitIngest(IngestConfig{Enabled: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = InitIngest(IngestConfig{}) })
+	RegisterIngestSink("telemetry", (&recordingSink{}).write)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.POST("/telemetry", IngestHandler("telemetry"))
+	engine.POST("/unknown", IngestHandler("unknown"))
+
+	body := []byte(`{"event":"click"}`)
+	w := ut.PerformRequest(engine, "POST", "/telemetry", &ut.Body{Body: bytes.NewReader(body), Len: len(body)})
+	assert.Equal(t, 202, w.Code)
+	assert.Equal(t, 1, i.Stats().Depth)
+	assert.Equal(t, 404, ut.PerformRequest(engine, "POST", "/unknown", nil).Code)
+}
+
+// TestIngester_SinkOutageLoad sink 故障期间持续接入：内存有界、超出部分溢写，恢复后全部投递或计入丢弃
+//
+// 默认模拟 2 秒故障，TEST_INGEST_OUTAGE=30s 可以模拟更长的故障
+func TestIngester_SinkOutageLoad(t *testing.T) {
+	outage := 2 * time.Second
+	if v := os.Getenv("TEST_INGEST_OUTAGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		require.NoError(t, err)
+		outage = d
+	}
+	conf := IngestConfig{
+		MemoryRecords: 4096, MemoryBytes: 1 << 20, BatchSize: 500, Interval: 20, RetryBackoff: 100,
+		SpillDir: t.TempDir(), SegmentBytes: 1 << 20, DiskBytes: 8 << 20,
+	}
+	i, err := NewIngester(conf)
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	sink.down.Store(true)
+	i.RegisterSink("telemetry", func(ctx context.Context, records []IngestRecord) error {
+		return sink.write(ctx, records)
+	})
+	runIngester(t, i)
+
+	var base runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&base)
+
+	ctx, cancel := context.WithTimeout(context.Background(), outage)
+	defer cancel()
+	var wg sync.WaitGroup
+	var maxDepth atomic.Int64
+	var maxHeap atomic.Uint64
+	payload := []byte(`{"device":"d-0001","metric":"cpu","value":0.42}`)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				for range 50 {
+					if err := i.Ingest(ctx, "telemetry", payload); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var ms runtime.MemStats
+		for ctx.Err() == nil {
+			s := i.Stats()
+			if int64(s.Depth) > maxDepth.Load() {
+				maxDepth.Store(int64(s.Depth))
+			}
+			assert.LessOrEqual(t, s.MemoryBytes, conf.MemoryBytes)
+			assert.LessOrEqual(t, s.SpillBytes, conf.DiskBytes)
+			runtime.ReadMemStats(&ms)
+			if ms.HeapInuse > maxHeap.Load() {
+				maxHeap.Store(ms.HeapInuse)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	stats := i.Stats()
+	t.Logf("故障 %v：接入 %d 条，溢写 %d 条，丢弃 %d 条，内存峰值 %d 条，堆增长 %d KB",
+		outage, stats.Accepted, stats.Spilled, stats.Dropped, maxDepth.Load(), (maxHeap.Load()-min(maxHeap.Load(), base.HeapInuse))/1024)
+	assert.Zero(t, stats.Delivered, "故障期间没有写入")
+	assert.LessOrEqual(t, maxDepth.Load(), int64(conf.MemoryRecords))
+	assert.Positive(t, stats.Spilled, "内存满后溢写")
+	assert.Less(t, maxHeap.Load()-min(maxHeap.Load(), base.HeapInuse), uint64(64<<20), "内存占用有界")
+
+	sink.down.Store(false)
+	require.Eventually(t, func() bool {
+		s := i.Stats()
+		return s.Depth == 0 && s.SpillRecords == 0 && s.Delivered+s.Dropped == s.Accepted
+	}, 30*time.Second, 20*time.Millisecond, fmt.Sprintf("%+v", i.Stats()))
+	assert.Equal(t, int(i.Stats().Delivered), sink.count())
+}
+
+func BenchmarkIngest(b *testing.B) {
+	i, err := NewIngester(IngestConfig{MemoryRecords: 1 << 16})
+	require.NoError(b, err)
+	i.RegisterSink("telemetry", func(ctx context.Context, records []IngestRecord) error { return nil })
+	payload := []byte(`{"device":"d-0001","metric":"cpu","value":0.42}`)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for n := 0; b.Loop(); n++ {
+		if n%(1<<15) == 0 {
+			i.mu.Lock()
+			i.popLocked(i.n)
+			i.mu.Unlock()
+		}
+		_ = i.Ingest(ctx, "telemetry", payload)
+	}
+}
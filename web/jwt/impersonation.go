@@ -0,0 +1,288 @@
+package jwt
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	jwtMiddleware "github.com/hertz-contrib/jwt"
+)
+
+// ActClaim 代理登录 token 中记录实际操作人的声明
+const ActClaim = "act"
+
+const denyReasonKey = "jwt_deny_reason"
+
+var (
+	ErrImpersonationDisabled = &JWTError{Message: "JWT is not initialized"}
+	ErrNestedImpersonation   = &JWTError{Message: "cannot impersonate while impersonating"}
+	ErrEmptyImpersonation    = &JWTError{Message: "impersonation target is required"}
+)
+
+// IssueImpersonationToken 以 actorID 的身份签发代理 targetID 的 token
+//
+// token 的身份标识为 targetID（GetUserID 返回目标用户），act 声明记录实际操作人，
+// 过期时间固定为 impersonationTimeout，且不允许嵌套代理。
+// 调用方负责校验 actorID 的管理员权限，推荐直接使用 ImpersonateHandler。
+//
+// 使用方式：
+//
+//	token, expire, err := jwt.IssueImpersonationToken(adminID, "user-42")
+func IssueImpersonationToken(actorID, targetID string) (string, time.Time, error) {
+	if defaultAuth == nil {
+		return "", time.Time{}, ErrImpersonationDisabled
+	}
+	return defaultAuth.IssueImpersonationToken(actorID, targetID)
+}
+
+// IssueImpersonationToken 以 actorID 的身份签发代理 targetID 的 token（见包级 IssueImpersonationToken）
+func (a *Auth) IssueImpersonationToken(actorID, targetID string) (string, time.Time, error) {
+	if targetID == "" {
+		return "", time.Time{}, ErrEmptyImpersonation
+	}
+	return a.middleware().TokenGenerator(jwtMiddleware.MapClaims{
+		a.config.IdentityKey: targetID,
+		ActClaim:             actorID,
+	})
+}
+
+// ImpersonateHandler 代理登录接口（仅管理员可用）
+//
+// 从 query/form 的 userId 读取目标用户；allow 返回 false 时响应 403。
+// 签发与拒绝都会写入审计事件。
+//
+// 使用方式：
+//
+//	admin := h.Group("/admin", jwt.Middleware())
+//	admin.POST("/impersonate", jwt.ImpersonateHandler(func(ctx context.Context, c *app.RequestContext, actorID, targetID string) bool {
+//	    return isAdmin(actorID)
+//	}))
+func ImpersonateHandler(allow func(ctx context.Context, c *app.RequestContext, actorID, targetID string) bool) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		actorID := GetUserID(c)
+		targetID := c.Query("userId")
+		if targetID == "" {
+			targetID = c.PostForm("userId")
+		}
+
+		event := audit.Event{
+			Type:      "impersonation.start",
+			Actor:     actorID,
+			Subject:   targetID,
+			RequestID: middleware.GetRequestID(c),
+			Method:    string(c.Method()),
+			Path:      string(c.Path()),
+		}
+
+		if actorID == "" || IsImpersonating(c) || allow == nil || !allow(ctx, c, actorID, targetID) {
+			event.Type = "impersonation.denied"
+			event.Status = consts.StatusForbidden
+			audit.Emit(ctx, event)
+			c.AbortWithStatusJSON(consts.StatusForbidden, map[string]any{
+				"code":    403,
+				"message": "Impersonation not allowed",
+				"data":    nil,
+			})
+			return
+		}
+
+		// actorID 非空说明请求已通过某个实例的认证
+		token, expire, err := authFor(c).IssueImpersonationToken(actorID, targetID)
+		if err != nil {
+			c.AbortWithStatusJSON(consts.StatusBadRequest, map[string]any{
+				"code":    400,
+				"message": err.Error(),
+				"data":    nil,
+			})
+			return
+		}
+
+		event.Status = consts.StatusOK
+		event.Data = map[string]any{"expire": expire}
+		audit.Emit(ctx, event)
+		c.JSON(consts.StatusOK, map[string]any{
+			"code":    0,
+			"message": "success",
+			"data": map[string]any{
+				"token":  token,
+				"expire": expire.Format(time.RFC3339),
+			},
+		})
+	}
+}
+
+// RevokeImpersonation 使 actorID 此前通过默认实例签发的所有代理 token 失效（管理员注销时调用）
+//
+// 粒度为秒：同一秒内注销后再签发的 token 也会被拒绝。
+// 仅保存在进程内存中，多实例部署时需要在每个实例上调用
+func RevokeImpersonation(actorID string) {
+	if defaultAuth != nil {
+		defaultAuth.RevokeImpersonation(actorID)
+	}
+}
+
+// RevokeImpersonation 使 actorID 此前通过该实例签发的所有代理 token 失效
+func (a *Auth) RevokeImpersonation(actorID string) {
+	if actorID == "" {
+		return
+	}
+	a.revoked.Store(actorID, time.Now().Unix())
+	audit.Emit(context.Background(), audit.Event{Type: "impersonation.revoke", Actor: actorID})
+}
+
+// LogoutHandler 注销接口：清除 cookie，并撤销当前管理员签发的代理 token
+//
+// 在代理登录状态下调用时，撤销的是实际操作人的代理 token
+func LogoutHandler() app.HandlerFunc {
+	if defaultAuth == nil {
+		return nil
+	}
+	return defaultAuth.LogoutHandler()
+}
+
+// LogoutHandler 注销接口（见包级 LogoutHandler）
+func (a *Auth) LogoutHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if actor := GetActor(c); actor != "" {
+			a.RevokeImpersonation(actor)
+		}
+		a.middleware().LogoutHandler(ctx, c)
+	}
+}
+
+// GetActor 获取实际操作人
+//
+// 代理登录时返回管理员身份，否则与 GetUserID 相同
+func GetActor(c *app.RequestContext) string {
+	if authFor(c) == nil {
+		return ""
+	}
+	if act, ok := GetClaims(c)[ActClaim].(string); ok && act != "" {
+		return act
+	}
+	return GetUserID(c)
+}
+
+// IsImpersonating 当前请求是否处于代理登录状态
+func IsImpersonating(c *app.RequestContext) bool {
+	if authFor(c) == nil {
+		return false
+	}
+	act, ok := GetClaims(c)[ActClaim].(string)
+	return ok && act != ""
+}
+
+// noImpersonationMarker 禁止代理登录的路由标记，通过函数指针识别
+func noImpersonationMarker(ctx context.Context, c *app.RequestContext) { c.Next(ctx) }
+
+var noImpersonationMarkerPtr = reflect.ValueOf(app.HandlerFunc(noImpersonationMarker)).Pointer()
+
+// NoImpersonation 声明路由或路由组禁止代理登录访问（如修改密码、支付）
+//
+// 代理 token 访问带此标记的路由时，JWT 中间件直接返回 403
+//
+// 使用方式：
+//
+//	h.POST("/api/password", jwt.NoImpersonation(), changePassword)
+//	pay := h.Group("/api/payment", jwt.NoImpersonation())
+func NoImpersonation() app.HandlerFunc {
+	return noImpersonationMarker
+}
+
+// impersonationTimeoutFunc 代理 token 使用较短的过期时间
+func impersonationTimeoutFunc(config Config, timeout time.Duration) func(claims gojwt.MapClaims) time.Duration {
+	short := time.Duration(config.ImpersonationTimeout) * time.Second
+	if short <= 0 {
+		short = 15 * time.Minute
+	}
+	if short > timeout {
+		short = timeout
+	}
+	return func(claims gojwt.MapClaims) time.Duration {
+		if act, ok := claims[ActClaim].(string); ok && act != "" {
+			return short
+		}
+		return timeout
+	}
+}
+
+// impersonationAuthorizator 校验代理 token：未被撤销，且目标路由允许代理访问
+func (a *Auth) impersonationAuthorizator(data interface{}, ctx context.Context, c *app.RequestContext) bool {
+	claims := jwtMiddleware.ExtractClaims(ctx, c)
+	act, ok := claims[ActClaim].(string)
+	if !ok || act == "" {
+		return true
+	}
+
+	if revokedAt, ok := a.revoked.Load(act); ok {
+		if iat, _ := claims["orig_iat"].(float64); int64(iat) <= revokedAt.(int64) {
+			c.Set(denyReasonKey, "Impersonation session revoked")
+			return false
+		}
+	}
+
+	if a.impersonationDenied(c) {
+		c.Set(denyReasonKey, "Route not allowed under impersonation")
+		return false
+	}
+	return true
+}
+
+// impersonationDenied 路由带 NoImpersonation 标记或命中 impersonationDenyPaths
+func (a *Auth) impersonationDenied(c *app.RequestContext) bool {
+	for _, h := range c.Handlers() {
+		if reflect.ValueOf(h).Pointer() == noImpersonationMarkerPtr {
+			return true
+		}
+	}
+	path := string(c.Path())
+	for _, prefix := range a.config.ImpersonationDenyPaths {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// denyMessage 优先返回代理校验的拒绝原因
+func denyMessage(e error, ctx context.Context, c *app.RequestContext) string {
+	if reason, ok := c.Get(denyReasonKey); ok {
+		return reason.(string)
+	}
+	return e.Error()
+}
+
+// impersonationAudit 代理登录状态下的每个请求都写入审计事件（包括被拒绝的请求）
+func (a *Auth) impersonationAudit(next app.HandlerFunc) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		next(ctx, c)
+
+		claims := jwtMiddleware.ExtractClaims(ctx, c)
+		act, ok := claims[ActClaim].(string)
+		if !ok || act == "" {
+			return
+		}
+		subject, _ := claims[a.config.IdentityKey].(string)
+		event := audit.Event{
+			Type:         "impersonation.request",
+			Actor:        act,
+			Subject:      subject,
+			Impersonated: true,
+			RequestID:    middleware.GetRequestID(c),
+			Method:       string(c.Method()),
+			Path:         string(c.Path()),
+			Status:       c.Response.StatusCode(),
+		}
+		if reason, ok := c.Get(denyReasonKey); ok {
+			event.Type = "impersonation.denied"
+			event.Data = map[string]any{"reason": reason}
+		}
+		audit.Emit(ctx, event)
+	}
+}
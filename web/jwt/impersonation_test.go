@@ -0,0 +1,158 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	jwtMiddleware "github.com/hertz-contrib/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *auditRecorder) Write(ctx context.Context, e audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *auditRecorder) last() audit.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+func newImpersonationEngine(t *testing.T) (*route.Engine, *auditRecorder) {
+	conf := DefaultConfig()
+	conf.Secret = "test-secret"
+	conf.ImpersonationDenyPaths = []string{"/api/payment"}
+	require.NoError(t, Init(conf))
+
+	rec := &auditRecorder{}
+	audit.SetSink(rec)
+	t.Cleanup(func() { audit.SetSink(nil) })
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(Middleware())
+	whoami := func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, map[string]string{"user": GetUserID(c), "actor": GetActor(c)})
+	}
+	engine.GET("/api/me", whoami)
+	engine.POST("/api/password", NoImpersonation(), whoami)
+	engine.POST("/api/payment/charge", whoami)
+	engine.POST("/admin/impersonate", ImpersonateHandler(func(ctx context.Context, c *app.RequestContext, actorID, targetID string) bool {
+		return actorID == "admin"
+	}))
+	engine.POST("/logout", LogoutHandler())
+	return engine, rec
+}
+
+func bearer(token string) ut.Header {
+	return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+}
+
+func TestImpersonation_IdentitiesAndAudit(t *testing.T) {
+	engine, rec := newImpersonationEngine(t)
+
+	adminToken, _, err := defaultAuth.middleware().TokenGenerator(jwtMiddleware.MapClaims{"identity": "admin"})
+	require.NoError(t, err)
+
+	w := ut.PerformRequest(engine, "POST", "/admin/impersonate?userId=user-42", nil, bearer(adminToken))
+	require.Equal(t, 200, w.Result().StatusCode())
+	var issued struct {
+		Data struct {
+			Token  string `json:"token"`
+			Expire string `json:"expire"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Result().Body(), &issued))
+	assert.Equal(t, "impersonation.start", rec.last().Type)
+
+	// 代理 token 强制使用短过期时间
+	expire, err := time.Parse(time.RFC3339, issued.Data.Expire)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), expire, time.Minute)
+
+	w = ut.PerformRequest(engine, "GET", "/api/me", nil, bearer(issued.Data.Token))
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.JSONEq(t, `{"user":"user-42","actor":"admin"}`, string(w.Result().Body()))
+
+	event := rec.last()
+	assert.Equal(t, "impersonation.request", event.Type)
+	assert.True(t, event.Impersonated)
+	assert.Equal(t, "admin", event.Actor)
+	assert.Equal(t, "user-42", event.Subject)
+	assert.Equal(t, "/api/me", event.Path)
+
+	// 普通 token 不产生代理审计
+	count := len(rec.events)
+	ut.PerformRequest(engine, "GET", "/api/me", nil, bearer(adminToken))
+	assert.Len(t, rec.events, count)
+
+	// 不允许嵌套代理
+	w = ut.PerformRequest(engine, "POST", "/admin/impersonate?userId=user-7", nil, bearer(issued.Data.Token))
+	assert.Equal(t, 403, w.Result().StatusCode())
+}
+
+func TestImpersonation_Restrictions(t *testing.T) {
+	engine, rec := newImpersonationEngine(t)
+
+	token, _, err := IssueImpersonationToken("admin", "user-42")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"route tag", "/api/password"},
+		{"configured prefix", "/api/payment/charge"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ut.PerformRequest(engine, "POST", tc.path, nil, bearer(token))
+			assert.Equal(t, 403, w.Result().StatusCode())
+
+			event := rec.last()
+			assert.Equal(t, "impersonation.denied", event.Type)
+			assert.Equal(t, "admin", event.Actor)
+			assert.Equal(t, "user-42", event.Subject)
+			assert.Equal(t, tc.path, event.Path)
+		})
+	}
+
+	// 普通用户不受限制
+	userToken, _, err := defaultAuth.middleware().TokenGenerator(jwtMiddleware.MapClaims{"identity": "user-42"})
+	require.NoError(t, err)
+	w := ut.PerformRequest(engine, "POST", "/api/password", nil, bearer(userToken))
+	assert.Equal(t, 200, w.Result().StatusCode())
+}
+
+func TestImpersonation_RevokedOnLogout(t *testing.T) {
+	engine, _ := newImpersonationEngine(t)
+	t.Cleanup(func() { defaultAuth.revoked.Delete("admin") })
+
+	token, _, err := IssueImpersonationToken("admin", "user-42")
+	require.NoError(t, err)
+	adminToken, _, err := defaultAuth.middleware().TokenGenerator(jwtMiddleware.MapClaims{"identity": "admin"})
+	require.NoError(t, err)
+
+	w := ut.PerformRequest(engine, "POST", "/logout", nil, bearer(adminToken))
+	assert.Equal(t, 200, w.Result().StatusCode())
+
+	w = ut.PerformRequest(engine, "GET", "/api/me", nil, bearer(token))
+	assert.Equal(t, 403, w.Result().StatusCode())
+	assert.Contains(t, string(w.Result().Body()), "revoked")
+}
@@ -2,30 +2,90 @@ package jwt
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/CenJIl/base/web/keyring"
 	"github.com/cloudwego/hertz/pkg/app"
+	jwtv4 "github.com/golang-jwt/jwt/v4"
 	jwtMiddleware "github.com/hertz-contrib/jwt"
 )
 
-var (
-	authMiddleware *jwtMiddleware.HertzJWTMiddleware
-	cfg            Config
-	initialized    bool
-)
+// Auth JWT 认证实例
+//
+// 包级函数（Init、Middleware、GenerateToken 等）使用 Init 创建的默认实例；
+// 同一进程中运行多个服务（密钥不同）时，每个服务通过 New 持有自己的实例。
+// GetUserID、GetClaims 等读取请求的函数自动使用验证该请求的实例。
+// 配置 secretKey 时密钥来自 keyring.Default()：轮换后立即用新版本签发，旧版本签发的 token 在退役前仍然有效
+type Auth struct {
+	mw      atomic.Pointer[jwtMiddleware.HertzJWTMiddleware] // 密钥轮换时整体替换
+	config  Config
+	revoked sync.Map // 管理员注销时间（actor -> unix 秒），早于该时间签发的代理 token 全部失效
+}
 
-func Init(config Config) error {
-	if config.Secret == "" {
-		return ErrSecretRequired
+// defaultAuth Init 创建的默认实例（未初始化时为 nil）
+var defaultAuth *Auth
+
+// authKey 请求上验证 token 的实例
+const authKey = "jwt_auth"
+
+// New 创建 JWT 认证实例（不影响包级的默认实例）
+//
+// 使用方式：
+//
+//	auth, err := jwt.New(conf)
+//	admin := h.Group("/admin", auth.Middleware())
+func New(config Config) (*Auth, error) {
+	if config.Secret == "" && config.SecretKey == "" {
+		return nil, ErrSecretRequired
+	}
+
+	a := &Auth{config: config}
+	key := []byte(config.Secret)
+	var kr *keyring.Keyring
+	if config.SecretKey != "" {
+		if kr = keyring.Default(); kr == nil || !kr.Has(config.SecretKey) {
+			return nil, ErrSecretKeyUnknown
+		}
+		var err error
+		if key, err = kr.Secret(config.SecretKey); err != nil {
+			return nil, err
+		}
+	}
+	mw, err := a.build(key, kr)
+	if err != nil {
+		return nil, err
 	}
+	a.mw.Store(mw)
+
+	if kr != nil {
+		kr.OnChange(func(name string) {
+			if name != config.SecretKey {
+				return
+			}
+			secret, err := kr.Secret(name)
+			if err != nil {
+				return
+			}
+			if mw, err := a.build(secret, kr); err == nil {
+				a.mw.Store(mw)
+			}
+		})
+	}
+	return a, nil
+}
 
+// build 用签名密钥 key 创建中间件；kr 不为 nil 时校验依次尝试密钥环中接受的版本
+func (a *Auth) build(key []byte, kr *keyring.Keyring) (*jwtMiddleware.HertzJWTMiddleware, error) {
+	config := a.config
 	timeout := time.Duration(config.Timeout) * time.Second
 	maxRefresh := time.Duration(config.MaxRefresh) * time.Second
 
-	var err error
-	authMiddleware, err = jwtMiddleware.New(&jwtMiddleware.HertzJWTMiddleware{
+	mw := &jwtMiddleware.HertzJWTMiddleware{
 		Realm:         config.Realm,
-		Key:           []byte(config.Secret),
+		Key:           key,
 		Timeout:       timeout,
 		MaxRefresh:    maxRefresh,
 		IdentityKey:   config.IdentityKey,
@@ -34,61 +94,188 @@ func Init(config Config) error {
 		SendCookie:    true,
 		CookieName:    "token",
 		CookieMaxAge:  timeout,
-	})
+		PayloadFunc: func(data interface{}) jwtMiddleware.MapClaims {
+			if claims, ok := data.(jwtMiddleware.MapClaims); ok {
+				return claims
+			}
+			return jwtMiddleware.MapClaims{}
+		},
+		TimeoutFunc:           impersonationTimeoutFunc(config, timeout),
+		Authorizator:          a.impersonationAuthorizator,
+		HTTPStatusMessageFunc: denyMessage,
+	}
+	if kr != nil {
+		mw.KeyFunc = keyringKeyFunc(kr, config.SecretKey)
+	}
+	return jwtMiddleware.New(mw)
+}
+
+// keyringKeyFunc 按签名找到密钥环中签发该 token 的版本（只接受 HS256）
+func keyringKeyFunc(kr *keyring.Keyring, name string) func(*jwtv4.Token) (interface{}, error) {
+	return func(t *jwtv4.Token) (interface{}, error) {
+		if t.Method != jwtv4.SigningMethodHS256 {
+			return nil, jwtMiddleware.ErrInvalidSigningAlgorithm
+		}
+		i := strings.LastIndex(t.Raw, ".")
+		if i < 0 {
+			return nil, jwtv4.ErrSignatureInvalid
+		}
+		signingString, signature := t.Raw[:i], t.Raw[i+1:]
+		v, ok := kr.Verify(name, func(secret []byte) bool {
+			return t.Method.Verify(signingString, signature, secret) == nil
+		})
+		if !ok {
+			return nil, jwtv4.ErrSignatureInvalid
+		}
+		return []byte(v.Secret), nil
+	}
+}
 
+// middleware 当前的中间件（密钥轮换后为新实例）
+func (a *Auth) middleware() *jwtMiddleware.HertzJWTMiddleware {
+	return a.mw.Load()
+}
+
+// Init 初始化包级的默认实例
+func Init(config Config) error {
+	a, err := New(config)
 	if err != nil {
 		return err
 	}
-
-	cfg = config
-	initialized = true
+	defaultAuth = a
 	return nil
 }
 
+// Default Init 创建的默认实例（未初始化时为 nil）
+func Default() *Auth {
+	return defaultAuth
+}
+
+// authFor 验证当前请求的实例（未经过任何实例的中间件时为默认实例）
+func authFor(c *app.RequestContext) *Auth {
+	if v, ok := c.Get(authKey); ok {
+		return v.(*Auth)
+	}
+	return defaultAuth
+}
+
+// Middleware 认证中间件
+func (a *Auth) Middleware() app.HandlerFunc {
+	next := a.impersonationAudit(func(ctx context.Context, c *app.RequestContext) {
+		a.middleware().MiddlewareFunc()(ctx, c)
+	})
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Set(authKey, a)
+		next(ctx, c)
+	}
+}
+
+// LoginHandler 登录接口
+func (a *Auth) LoginHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		a.middleware().LoginHandler(ctx, c)
+	}
+}
+
+// GenerateToken 签发包含指定声明的 token（claims 中应包含 identityKey）
+func (a *Auth) GenerateToken(claims map[string]interface{}) (string, time.Time, error) {
+	return a.middleware().TokenGenerator(jwtMiddleware.MapClaims(claims))
+}
+
+// Config 实例的配置
+func (a *Auth) Config() Config {
+	return a.config
+}
+
 func Middleware() app.HandlerFunc {
-	if !initialized {
+	if defaultAuth == nil {
 		return func(ctx context.Context, c *app.RequestContext) {
 			c.Next(ctx)
 		}
 	}
-	return authMiddleware.MiddlewareFunc()
+	return defaultAuth.Middleware()
 }
 
 func LoginHandler() app.HandlerFunc {
-	if !initialized {
+	if defaultAuth == nil {
 		return nil
 	}
-	return authMiddleware.LoginHandler
+	return defaultAuth.LoginHandler()
 }
 
 func GetUserID(c *app.RequestContext) string {
-	if !initialized {
+	a := authFor(c)
+	if a == nil {
 		return ""
 	}
 	claims := jwtMiddleware.ExtractClaims(context.Background(), c)
-	if id, ok := claims[cfg.IdentityKey].(string); ok {
+	if id, ok := claims[a.config.IdentityKey].(string); ok {
 		return id
 	}
 	return ""
 }
 
 func GetClaims(c *app.RequestContext) map[string]interface{} {
-	if !initialized {
+	if authFor(c) == nil {
 		return nil
 	}
 	return jwtMiddleware.ExtractClaims(context.Background(), c)
 }
 
+// RolesClaim token 中记录用户角色的声明（字符串数组或逗号分隔的字符串）
+const RolesClaim = "roles"
+
+// GetRoles 获取当前用户的角色
+func GetRoles(c *app.RequestContext) []string {
+	switch v := GetClaims(c)[RolesClaim].(type) {
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			if s, ok := r.(string); ok && s != "" {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	case string:
+		var roles []string
+		for _, r := range strings.Split(v, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				roles = append(roles, r)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// GenerateToken 签发包含指定声明的 token（claims 中应包含 identityKey）
+//
+// 使用方式：
+//
+//	token, expire, err := jwt.GenerateToken(map[string]interface{}{"identity": userID, jwt.RolesClaim: []string{"admin"}})
+func GenerateToken(claims map[string]interface{}) (string, time.Time, error) {
+	if defaultAuth == nil {
+		return "", time.Time{}, ErrImpersonationDisabled
+	}
+	return defaultAuth.GenerateToken(claims)
+}
+
 func IsEnabled() bool {
-	return initialized
+	return defaultAuth != nil
 }
 
 func GetConfig() Config {
-	return cfg
+	if defaultAuth == nil {
+		return Config{}
+	}
+	return defaultAuth.config
 }
 
 var ErrSecretRequired = &JWTError{Message: "JWT secret is required"}
 
+// ErrSecretKeyUnknown secretKey 引用的密钥不在默认密钥环中（需在 web.NewServer 之后调用 Init）
+var ErrSecretKeyUnknown = &JWTError{Message: "JWT secretKey not found in keyring"}
+
 type JWTError struct {
 	Message string
 }
@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth_KeyringRotation(t *testing.T) {
+	kr, err := keyring.New(keyring.Config{Keys: map[string]keyring.KeyConfig{"jwt": {Accept: 2}}})
+	require.NoError(t, err)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+	keyring.SetDefault(kr)
+	t.Cleanup(func() { keyring.SetDefault(nil) })
+
+	conf := DefaultConfig()
+	conf.SecretKey = "jwt"
+	a, err := New(conf)
+	require.NoError(t, err)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(a.Middleware())
+	engine.GET("/api/me", func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, GetUserID(c))
+	})
+	status := func(token string) int {
+		return ut.PerformRequest(engine, "GET", "/api/me", nil, bearer(token)).Result().StatusCode()
+	}
+
+	before, _, err := a.GenerateToken(map[string]interface{}{"identity": "user-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 200, status(before))
+
+	_, err = kr.Rotate(context.Background(), "jwt")
+	require.NoError(t, err)
+	after, _, err := a.GenerateToken(map[string]interface{}{"identity": "user-1"})
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after, "轮换后使用新版本签发")
+	assert.Equal(t, 200, status(after))
+	assert.Equal(t, 200, status(before), "轮换窗口内旧 token 仍然有效")
+
+	_, err = kr.Rotate(context.Background(), "jwt")
+	require.NoError(t, err)
+	assert.Equal(t, 401, status(before), "签发版本退役后失效")
+	assert.Equal(t, 200, status(after))
+
+	_, err = New(Config{SecretKey: "missing"})
+	assert.ErrorIs(t, err, ErrSecretKeyUnknown)
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common/task"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// KeyringConfig 命名密钥配置（类型别名）
+type KeyringConfig = keyring.Config
+
+// InitKeyring 按配置创建默认密钥环（没有配置密钥时不启用，返回 nil）
+//
+// 版本保存在配置文件中（轮换时通过 cfg.UpdateCfg 写回，密钥用 cfg.Encrypt 加密为 ENC(...)）；
+// 配置了 file 时保存在外部密钥文件中。Redis 已启动时通过 Redis 频道把轮换广播给其他实例。
+// 没有版本的密钥立即生成第一个版本
+func InitKeyring[T any](config KeyringConfig) (*keyring.Keyring, error) {
+	if len(config.Keys) == 0 {
+		return nil, nil
+	}
+	opts := []keyring.Option{keyring.WithStore(configKeyStore[T]{})}
+	if config.File != "" {
+		opts[0] = keyring.WithStore(keyring.NewFileStore(config.File))
+	}
+	if cache.Client != nil {
+		opts = append(opts, keyring.WithNotifier(keyring.NewRedisNotifier(cache.Client, "")))
+	}
+	kr, err := keyring.New(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := kr.Bootstrap(context.Background()); err != nil {
+		return nil, err
+	}
+	keyring.SetDefault(kr)
+	return kr, nil
+}
+
+// RegisterKeyRotation 在调度器上注册自动轮换（每小时检查一次，轮换超过 rotateEvery 的密钥）
+//
+// 调度器使用共享存储（如 cache.NewTaskStore）时只有一个实例执行轮换，其他实例通过广播同步。
+// 没有启用密钥环时不注册
+//
+// 使用方式：
+//
+//	scheduler := task.New(config.Task, task.WithStore(cache.NewTaskStore(cache.Client)))
+//	web.RegisterKeyRotation(scheduler)
+//	scheduler.Start()
+func RegisterKeyRotation(s *task.Scheduler) error {
+	kr := keyring.Default()
+	if kr == nil {
+		return nil
+	}
+	return s.Register("keyring.rotate", task.Every(time.Hour), kr.RotateDue)
+}
+
+// configKeyStore 把轮换后的版本写回配置文件（web.keyring.keys.<name>.versions）
+type configKeyStore[T any] struct{}
+
+func (configKeyStore[T]) Save(ctx context.Context, name string, versions []keyring.Version) error {
+	sealed := make([]keyring.Version, len(versions))
+	for i, v := range versions {
+		sealed[i] = v
+		if enc, err := cfg.Encrypt(v.Secret); err == nil {
+			sealed[i].Secret = enc
+		} else if errors.Is(err, cfg.ErrDecryptionKeyMissing) {
+			log.Warnf("[Keyring] 未配置配置加密密钥，密钥 %s 以明文写入配置文件", name)
+		} else {
+			return err
+		}
+	}
+	return cfg.UpdateCfg(func(c *T) {
+		wc := webConfigOf(c)
+		if wc == nil {
+			return
+		}
+		if wc.Keyring.Keys == nil {
+			wc.Keyring.Keys = map[string]keyring.KeyConfig{}
+		}
+		kc := wc.Keyring.Keys[name]
+		kc.Versions = sealed
+		wc.Keyring.Keys[name] = kc
+	})
+}
+
+// KeyringAdminHandler 密钥环管理接口
+//
+// GET 返回各密钥的版本、年龄与下次自动轮换时间（不包含密钥）；POST ?key=jwt 立即轮换该密钥
+// （怀疑泄露时使用，旧版本在 accept 个版本之内仍然有效）。需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/keyring", web.KeyringAdminHandler())
+func KeyringAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		kr := keyring.Default()
+		if kr == nil {
+			panic(NewHTTPException(404, 404, "Keyring not enabled"))
+		}
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			name := c.Query("key")
+			if _, err := kr.Rotate(ctx, name); errors.Is(err, keyring.ErrUnknownKey) {
+				panic(NotFoundHTTP("没有该密钥: " + name))
+			} else if err != nil {
+				panic(err)
+			}
+			log.Warnf("[Keyring] 管理接口轮换密钥: %s", name)
+		}
+		c.JSON(consts.StatusOK, Success(kr.Status()))
+	}
+}
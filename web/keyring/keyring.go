@@ -0,0 +1,613 @@
+// Package keyring 命名密钥的多版本管理：JWT、分页游标、服务间签名、入站 Webhook 等按名称引用密钥，
+// 不再各自在配置中写死密钥
+//
+// 每个密钥有多个带编号的版本，签名总是使用最新版本，校验依次尝试最新的 accept 个版本。
+// 轮换（Rotate）生成新版本并保存（配置文件或外部密钥文件），超出 accept 的旧版本随之退役：
+// 退役之前用旧版本签名的 token、游标仍然有效，之后校验失败。
+// 校验命中非最新版本时计入 keyring_verify_total{version="previous"}，该计数长期为 0 时说明可以安全退役。
+//
+// 多实例部署时，执行轮换的实例通过 Notifier（Redis 频道）广播新的版本列表（用配置加密密钥加密，
+// 见 cfg.Encrypt），其他实例收到后立即生效并保存到各自的存储；自动轮换由 task.Scheduler 调度，
+// 同一时间点只有一个实例执行。
+//
+// 使用方式：
+//
+//	[web.keyring.keys.jwt]
+//	accept = 2            # 校验接受最新的 2 个版本（当前 + 上一个）
+//	rotateEvery = 720     # 每 30 天自动轮换（小时，0 表示只手动轮换）
+//
+//	// 首次启动时没有版本的密钥自动生成并写回配置文件
+//	scheduler.Register("keyring.rotate", task.Every(time.Hour), keyring.Default().RotateDue)
+//
+//	conf.SecretKey = "jwt"   // jwt.Config 引用密钥名，不再配置 secret
+package keyring
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/google/uuid"
+)
+
+var log = logger.Named("keyring")
+
+var (
+	// ErrUnknownKey 密钥环中没有该名称的密钥
+	ErrUnknownKey = errors.New("keyring: unknown key")
+	// ErrNoVersions 密钥还没有任何版本（等待首次轮换）
+	ErrNoVersions = errors.New("keyring: key has no versions")
+)
+
+// DefaultAccept 默认校验接受的版本数（当前 + 上一个）
+const DefaultAccept = 2
+
+// secretSize 生成的密钥长度（字节，base64url 编码后保存）
+const secretSize = 32
+
+// Version 密钥的一个版本
+type Version struct {
+	ID      int       `toml:"id" json:"id"`
+	Secret  string    `toml:"secret" json:"secret" sensitive:"true"` // 值为 "env:NAME" 时从环境变量读取
+	Created time.Time `toml:"created" json:"created"`
+}
+
+// KeyConfig 单个密钥的配置
+type KeyConfig struct {
+	Accept      int       `toml:"accept"`      // 校验接受的最新版本数，默认 2
+	RotateEvery int       `toml:"rotateEvery"` // 自动轮换间隔（小时），0 表示只手动轮换
+	Versions    []Version `toml:"versions"`    // 版本（轮换时自动维护，使用外部密钥文件时为空）
+}
+
+// Config 密钥环配置
+//
+// Example:
+//
+//	[web.keyring]
+//	file = "/etc/myapp/keys.json"   # 版本保存在外部文件（JSON），不配置时保存在配置文件中
+//	[web.keyring.keys.cursor]
+//	accept = 3
+//	rotateEvery = 168
+type Config struct {
+	File string               `toml:"file"`
+	Keys map[string]KeyConfig `toml:"keys"`
+}
+
+// Store 版本的持久化存储（轮换后保存）
+type Store interface {
+	// Save 保存 name 的全部有效版本（已退役的版本不再包含）
+	Save(ctx context.Context, name string, versions []Version) error
+}
+
+// Loader 可以重新读取全部版本的存储（收到不含密钥的轮换通知时使用）
+type Loader interface {
+	Load(ctx context.Context) (map[string][]Version, error)
+}
+
+// keyState 密钥的当前状态（版本按编号升序）
+type keyState struct {
+	versions    []Version
+	accept      int
+	rotateEvery time.Duration
+}
+
+// Keyring 密钥环
+type Keyring struct {
+	mu        sync.RWMutex
+	keys      map[string]*keyState
+	listeners []func(name string)
+
+	rotateMu sync.Mutex // 串行执行轮换（保存与生效之间不交错）
+	store    Store
+	notifier Notifier
+	clock    common.Clock
+	origin   string // 本实例的标识（忽略自己发出的广播）
+}
+
+// Option 密钥环选项
+type Option func(*Keyring)
+
+// WithStore 轮换后保存版本（不设置时只在内存中生效，重启后丢失）
+func WithStore(store Store) Option {
+	return func(k *Keyring) { k.store = store }
+}
+
+// WithNotifier 轮换后广播给其他实例（Listen 接收）
+func WithNotifier(notifier Notifier) Option {
+	return func(k *Keyring) { k.notifier = notifier }
+}
+
+// WithClock 使用指定时钟（测试用）
+func WithClock(clock common.Clock) Option {
+	return func(k *Keyring) { k.clock = clock }
+}
+
+// New 按配置创建密钥环
+//
+// 没有版本的密钥允许存在（Current 返回 ErrNoVersions），由 Bootstrap 或第一次 Rotate 生成；
+// 存储实现了 Loader 时（外部密钥文件），版本从存储读取
+func New(config Config, opts ...Option) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]*keyState, len(config.Keys)), clock: common.SystemClock{}, origin: uuid.NewString()}
+	for _, opt := range opts {
+		opt(k)
+	}
+	stored := map[string][]Version{}
+	if loader, ok := k.store.(Loader); ok {
+		var err error
+		if stored, err = loader.Load(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	for name, kc := range config.Keys {
+		if name == "" {
+			return nil, errors.New("keyring: 密钥名不能为空")
+		}
+		versions := kc.Versions
+		if v, ok := stored[name]; ok {
+			versions = v
+		}
+		state, err := newKeyState(name, kc, versions)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[name] = state
+	}
+	return k, nil
+}
+
+func newKeyState(name string, kc KeyConfig, versions []Version) (*keyState, error) {
+	if kc.Accept <= 0 {
+		kc.Accept = DefaultAccept
+	}
+	resolved, err := resolveVersions(name, versions)
+	if err != nil {
+		return nil, err
+	}
+	return &keyState{versions: resolved, accept: kc.Accept, rotateEvery: time.Duration(kc.RotateEvery) * time.Hour}, nil
+}
+
+// resolveVersions 校验版本并读取 env: 引用，按编号升序返回副本
+func resolveVersions(name string, versions []Version) ([]Version, error) {
+	out := slices.Clone(versions)
+	seen := make(map[int]bool, len(out))
+	for i, v := range out {
+		if v.ID <= 0 || seen[v.ID] {
+			return nil, fmt.Errorf("keyring: 密钥 %s 的版本编号 %d 无效或重复", name, v.ID)
+		}
+		seen[v.ID] = true
+		if env, ok := strings.CutPrefix(v.Secret, "env:"); ok {
+			out[i].Secret = os.Getenv(env)
+			if out[i].Secret == "" {
+				return nil, fmt.Errorf("keyring: 环境变量 %s 未设置（密钥 %s 版本 %d）", env, name, v.ID)
+			}
+		}
+		if len(out[i].Secret) < 16 {
+			return nil, fmt.Errorf("keyring: 密钥 %s 版本 %d 至少需要 16 字节", name, v.ID)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+var defaultKeyring atomic.Pointer[Keyring]
+
+// SetDefault 设置包级的默认密钥环（web.NewServer 按配置创建）
+func SetDefault(k *Keyring) {
+	defaultKeyring.Store(k)
+}
+
+// Default 默认密钥环（未设置时为 nil）
+func Default() *Keyring {
+	return defaultKeyring.Load()
+}
+
+// Has 是否有该名称的密钥
+func (k *Keyring) Has(name string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	_, ok := k.keys[name]
+	return ok
+}
+
+// Current 最新版本（签名使用）
+func (k *Keyring) Current(name string) (Version, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	state, ok := k.keys[name]
+	if !ok {
+		return Version{}, fmt.Errorf("%w: %s", ErrUnknownKey, name)
+	}
+	if len(state.versions) == 0 {
+		return Version{}, fmt.Errorf("%w: %s", ErrNoVersions, name)
+	}
+	return state.versions[len(state.versions)-1], nil
+}
+
+// Secret 最新版本的密钥
+func (k *Keyring) Secret(name string) ([]byte, error) {
+	v, err := k.Current(name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(v.Secret), nil
+}
+
+// Accepted 校验接受的版本（从新到旧，最多 accept 个）
+func (k *Keyring) Accepted(name string) []Version {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	state, ok := k.keys[name]
+	if !ok {
+		return nil
+	}
+	n := min(state.accept, len(state.versions))
+	out := make([]Version, 0, n)
+	for i := len(state.versions) - 1; i >= len(state.versions)-n; i-- {
+		out = append(out, state.versions[i])
+	}
+	return out
+}
+
+// Verify 依次用接受的版本（从新到旧）调用 check，返回第一个通过的版本
+//
+// 命中非最新版本时计入 keyring_verify_total{version="previous"}
+//
+// 使用方式：
+//
+//	_, ok := kr.Verify("cursor", func(secret []byte) bool {
+//	    return hmac.Equal(mac, sign(secret, body))
+//	})
+func (k *Keyring) Verify(name string, check func(secret []byte) bool) (Version, bool) {
+	for i, v := range k.Accepted(name) {
+		if check([]byte(v.Secret)) {
+			which := "current"
+			if i > 0 {
+				which = "previous"
+			}
+			metrics.GetCounter("keyring_verify_total", "key", name, "version", which).Inc()
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// OnChange 注册版本变化回调（轮换、配置热更新、收到其他实例的广播），参数为密钥名
+func (k *Keyring) OnChange(fn func(name string)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.listeners = append(k.listeners, fn)
+}
+
+// apply 替换版本，有变化时通知回调
+func (k *Keyring) apply(name string, versions []Version) {
+	k.mu.Lock()
+	state, ok := k.keys[name]
+	if !ok || slices.Equal(state.versions, versions) {
+		k.mu.Unlock()
+		return
+	}
+	state.versions = versions
+	listeners := slices.Clone(k.listeners)
+	k.mu.Unlock()
+	for _, fn := range listeners {
+		fn(name)
+	}
+}
+
+// Reload 按新的配置更新设置与版本（配置热更新时调用）
+//
+// 配置中没有版本的密钥（版本保存在外部文件）保留当前版本；配置中新增的密钥不会加入
+func (k *Keyring) Reload(config Config) error {
+	for name, kc := range config.Keys {
+		if !k.Has(name) {
+			continue
+		}
+		state, err := newKeyState(name, kc, kc.Versions)
+		if err != nil {
+			return err
+		}
+		k.mu.Lock()
+		k.keys[name].accept, k.keys[name].rotateEvery = state.accept, state.rotateEvery
+		k.mu.Unlock()
+		if len(state.versions) > 0 {
+			k.apply(name, state.versions)
+		}
+	}
+	return nil
+}
+
+// Rotate 生成新版本并保存、生效、广播，超出 accept 的旧版本退役
+//
+// 保存失败时不生效；广播失败只记录日志（其他实例在下次重启或配置热更新时同步）
+func (k *Keyring) Rotate(ctx context.Context, name string) (Version, error) {
+	k.rotateMu.Lock()
+	defer k.rotateMu.Unlock()
+
+	k.mu.RLock()
+	state, ok := k.keys[name]
+	var versions []Version
+	accept := 0
+	if ok {
+		versions, accept = slices.Clone(state.versions), state.accept
+	}
+	k.mu.RUnlock()
+	if !ok {
+		return Version{}, fmt.Errorf("%w: %s", ErrUnknownKey, name)
+	}
+
+	next := Version{ID: 1, Secret: generateSecret(), Created: k.clock.Now().UTC().Truncate(time.Second)}
+	if len(versions) > 0 {
+		next.ID = versions[len(versions)-1].ID + 1
+	}
+	versions = append(versions, next)
+	if len(versions) > accept {
+		versions = versions[len(versions)-accept:]
+	}
+
+	if k.store != nil {
+		if err := k.store.Save(ctx, name, versions); err != nil {
+			return Version{}, fmt.Errorf("keyring: 保存密钥 %s 失败: %w", name, err)
+		}
+	}
+	k.apply(name, versions)
+	log.Infof("密钥 %s 已轮换到版本 %d（接受最新 %d 个版本）", name, next.ID, accept)
+
+	if k.notifier != nil {
+		if err := k.notifier.Publish(ctx, k.event(name, versions)); err != nil {
+			log.Warnf("密钥 %s 的轮换通知发送失败: %v", name, err)
+		}
+	}
+	return next, nil
+}
+
+// Bootstrap 为没有版本的密钥生成第一个版本（首次部署时调用）
+func (k *Keyring) Bootstrap(ctx context.Context) error {
+	var errs []error
+	for _, name := range k.names() {
+		if _, err := k.Current(name); errors.Is(err, ErrNoVersions) {
+			if _, err := k.Rotate(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RotateDue 轮换所有到期的密钥（最新版本创建已超过 rotateEvery），可直接注册为 task.Func
+//
+// 使用方式：
+//
+//	scheduler.Register("keyring.rotate", task.Every(time.Hour), kr.RotateDue)
+func (k *Keyring) RotateDue(ctx context.Context) error {
+	now := k.clock.Now()
+	var errs []error
+	for _, name := range k.names() {
+		k.mu.RLock()
+		state := k.keys[name]
+		due := state.rotateEvery > 0 && (len(state.versions) == 0 || !now.Before(state.versions[len(state.versions)-1].Created.Add(state.rotateEvery)))
+		k.mu.RUnlock()
+		if due {
+			if _, err := k.Rotate(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (k *Keyring) names() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	names := make([]string, 0, len(k.keys))
+	for name := range k.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// VersionStatus 版本状态（不包含密钥）
+type VersionStatus struct {
+	ID      int       `json:"id"`
+	Created time.Time `json:"created"`
+	Age     string    `json:"age"`
+	Current bool      `json:"current,omitempty"`
+}
+
+// KeyStatus 密钥状态
+type KeyStatus struct {
+	Name         string          `json:"name"`
+	Accept       int             `json:"accept"`
+	RotateEvery  string          `json:"rotateEvery,omitempty"`
+	NextRotation *time.Time      `json:"nextRotation,omitempty"`
+	Versions     []VersionStatus `json:"versions"` // 从新到旧
+}
+
+// Status 全部密钥的版本与年龄（按名称排序，管理接口使用）
+func (k *Keyring) Status() []KeyStatus {
+	now := k.clock.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	out := make([]KeyStatus, 0, len(k.keys))
+	for name, state := range k.keys {
+		s := KeyStatus{Name: name, Accept: state.accept, Versions: []VersionStatus{}}
+		for i := len(state.versions) - 1; i >= 0; i-- {
+			v := state.versions[i]
+			s.Versions = append(s.Versions, VersionStatus{
+				ID:      v.ID,
+				Created: v.Created,
+				Age:     now.Sub(v.Created).Truncate(time.Second).String(),
+				Current: i == len(state.versions)-1,
+			})
+		}
+		if state.rotateEvery > 0 {
+			s.RotateEvery = state.rotateEvery.String()
+			if len(state.versions) > 0 {
+				next := state.versions[len(state.versions)-1].Created.Add(state.rotateEvery)
+				s.NextRotation = &next
+			}
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func generateSecret() string {
+	b := make([]byte, secretSize)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// FileStore 外部密钥文件（JSON：密钥名 -> 版本列表），原子写入
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore 创建文件存储（文件不存在时视为空）
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load 实现 Loader 接口
+func (s *FileStore) Load(ctx context.Context) (map[string][]Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *FileStore) read() (map[string][]Version, error) {
+	keys := map[string][]Version{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keyring: 读取密钥文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("keyring: 解析密钥文件 %s 失败: %w", s.path, err)
+	}
+	return keys, nil
+}
+
+// Save 实现 Store 接口
+func (s *FileStore) Save(ctx context.Context, name string, versions []Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	keys[name] = versions
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Event 轮换通知
+//
+// Versions 是用配置加密密钥（cfg.SetDecryptionKey / CONFIG_DECRYPTION_KEY）加密的版本列表；
+// 没有配置加密密钥时为空，接收方从共享存储（Loader）重新读取
+type Event struct {
+	Key      string `json:"key"`
+	Origin   string `json:"origin"`
+	Versions string `json:"versions,omitempty"`
+}
+
+// Notifier 在实例之间广播轮换通知
+type Notifier interface {
+	Publish(ctx context.Context, event Event) error
+	// Subscribe 接收通知直到 ctx 取消
+	Subscribe(ctx context.Context, handle func(Event)) error
+}
+
+func (k *Keyring) event(name string, versions []Version) Event {
+	e := Event{Key: name, Origin: k.origin}
+	data, _ := json.Marshal(versions)
+	if sealed, err := cfg.Encrypt(string(data)); err == nil {
+		e.Versions = sealed
+	} else {
+		log.Warnf("密钥 %s 的轮换通知不携带密钥（%v），其他实例从共享存储重新读取", name, err)
+	}
+	return e
+}
+
+// Listen 接收其他实例的轮换通知并生效（阻塞直到 ctx 取消）
+//
+// 通知携带版本时同时保存到本实例的存储，实例重启后仍使用新版本
+func (k *Keyring) Listen(ctx context.Context) error {
+	if k.notifier == nil {
+		return nil
+	}
+	return k.notifier.Subscribe(ctx, func(e Event) {
+		if err := k.handle(ctx, e); err != nil {
+			log.Errorf("处理密钥 %s 的轮换通知失败: %v", e.Key, err)
+		}
+	})
+}
+
+func (k *Keyring) handle(ctx context.Context, e Event) error {
+	if e.Origin == k.origin || !k.Has(e.Key) {
+		return nil
+	}
+	if e.Versions == "" {
+		loader, ok := k.store.(Loader)
+		if !ok {
+			return errors.New("通知不携带密钥且存储不支持重新读取")
+		}
+		keys, err := loader.Load(ctx)
+		if err != nil {
+			return err
+		}
+		versions, err := resolveVersions(e.Key, keys[e.Key])
+		if err != nil {
+			return err
+		}
+		k.apply(e.Key, versions)
+		return nil
+	}
+
+	plain, err := cfg.Decrypt(e.Versions)
+	if err != nil {
+		return err
+	}
+	var versions []Version
+	if err := json.Unmarshal([]byte(plain), &versions); err != nil {
+		return err
+	}
+	if versions, err = resolveVersions(e.Key, versions); err != nil {
+		return err
+	}
+	k.rotateMu.Lock()
+	defer k.rotateMu.Unlock()
+	if k.store != nil {
+		if err := k.store.Save(ctx, e.Key, versions); err != nil {
+			return err
+		}
+	}
+	k.apply(e.Key, versions)
+	log.Infof("已应用其他实例轮换的密钥 %s（当前版本 %d）", e.Key, versions[len(versions)-1].ID)
+	return nil
+}
@@ -0,0 +1,195 @@
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 记录保存的版本
+type memoryStore struct {
+	mu    sync.Mutex
+	saved map[string][]Version
+}
+
+func (s *memoryStore) Save(ctx context.Context, name string, versions []Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saved == nil {
+		s.saved = map[string][]Version{}
+	}
+	s.saved[name] = versions
+	return nil
+}
+
+// memoryNotifier 进程内广播（所有订阅者共享）
+type memoryNotifier struct {
+	mu       sync.Mutex
+	handlers []func(Event)
+	ready    chan struct{}
+}
+
+func newMemoryNotifier(subscribers int) *memoryNotifier {
+	return &memoryNotifier{ready: make(chan struct{}, subscribers)}
+}
+
+func (n *memoryNotifier) Publish(ctx context.Context, event Event) error {
+	n.mu.Lock()
+	handlers := append([]func(Event){}, n.handlers...)
+	n.mu.Unlock()
+	for _, h := range handlers {
+		h(event)
+	}
+	return nil
+}
+
+func (n *memoryNotifier) Subscribe(ctx context.Context, handle func(Event)) error {
+	n.mu.Lock()
+	n.handlers = append(n.handlers, handle)
+	n.mu.Unlock()
+	n.ready <- struct{}{}
+	<-ctx.Done()
+	return nil
+}
+
+func testConfig(accept, rotateEvery int) Config {
+	return Config{Keys: map[string]KeyConfig{"jwt": {Accept: accept, RotateEvery: rotateEvery}}}
+}
+
+func TestKeyring_RotateRetiresOldVersions(t *testing.T) {
+	store := &memoryStore{}
+	kr, err := New(testConfig(2, 0), WithStore(store))
+	require.NoError(t, err)
+
+	_, err = kr.Current("jwt")
+	assert.ErrorIs(t, err, ErrNoVersions)
+	_, err = kr.Current("missing")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+
+	var changed []string
+	kr.OnChange(func(name string) { changed = append(changed, name) })
+	first, _ := kr.Current("jwt")
+	second, err := kr.Rotate(context.Background(), "jwt")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID+1, second.ID)
+	assert.NotEqual(t, first.Secret, second.Secret)
+	assert.Equal(t, []string{"jwt"}, changed)
+
+	signedWith := func(secret string) func([]byte) bool {
+		return func(s []byte) bool { return bytes.Equal(s, []byte(secret)) }
+	}
+	previous := metrics.GetCounter("keyring_verify_total", "key", "jwt", "version", "previous")
+	current := metrics.GetCounter("keyring_verify_total", "key", "jwt", "version", "current")
+	prevBefore, curBefore := previous.Value(), current.Value()
+
+	v, ok := kr.Verify("jwt", signedWith(first.Secret))
+	assert.True(t, ok, "轮换窗口内旧版本仍然有效")
+	assert.Equal(t, first.ID, v.ID)
+	_, ok = kr.Verify("jwt", signedWith(second.Secret))
+	assert.True(t, ok)
+	assert.Equal(t, prevBefore+1, previous.Value())
+	assert.Equal(t, curBefore+1, current.Value())
+
+	_, err = kr.Rotate(context.Background(), "jwt")
+	require.NoError(t, err)
+	_, ok = kr.Verify("jwt", signedWith(first.Secret))
+	assert.False(t, ok, "超出 accept 的版本已退役")
+	assert.Len(t, store.saved["jwt"], 2)
+	assert.Equal(t, second.ID, store.saved["jwt"][0].ID)
+}
+
+func TestKeyring_RotateDue(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	kr, err := New(testConfig(0, 24), WithClock(clock))
+	require.NoError(t, err)
+
+	require.NoError(t, kr.RotateDue(context.Background()), "没有版本的密钥立即轮换")
+	v, err := kr.Current("jwt")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.ID)
+
+	clock.Advance(23 * time.Hour)
+	require.NoError(t, kr.RotateDue(context.Background()))
+	v, _ = kr.Current("jwt")
+	assert.Equal(t, 1, v.ID)
+
+	clock.Advance(time.Hour)
+	require.NoError(t, kr.RotateDue(context.Background()))
+	v, _ = kr.Current("jwt")
+	assert.Equal(t, 2, v.ID)
+
+	status := kr.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, DefaultAccept, status[0].Accept)
+	assert.Equal(t, "24h0m0s", status[0].RotateEvery)
+	assert.Equal(t, clock.Now().Add(24*time.Hour), *status[0].NextRotation)
+	require.Len(t, status[0].Versions, 2)
+	assert.True(t, status[0].Versions[0].Current)
+	assert.Equal(t, "24h0m0s", status[0].Versions[1].Age)
+}
+
+func TestKeyring_ConfigValidation(t *testing.T) {
+	t.Setenv("KEYRING_TEST_SECRET", "0123456789abcdef-from-env")
+	kr, err := New(Config{Keys: map[string]KeyConfig{"jwt": {Versions: []Version{
+		{ID: 2, Secret: "env:KEYRING_TEST_SECRET"},
+		{ID: 1, Secret: "0123456789abcdef-old"},
+	}}}})
+	require.NoError(t, err)
+	v, _ := kr.Current("jwt")
+	assert.Equal(t, "0123456789abcdef-from-env", v.Secret)
+
+	_, err = New(Config{Keys: map[string]KeyConfig{"jwt": {Versions: []Version{{ID: 1, Secret: "short"}}}}})
+	assert.Error(t, err)
+	_, err = New(Config{Keys: map[string]KeyConfig{"jwt": {Versions: []Version{
+		{ID: 1, Secret: "0123456789abcdef"}, {ID: 1, Secret: "0123456789abcdef"},
+	}}}})
+	assert.Error(t, err)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	kr, err := New(testConfig(2, 0), WithStore(NewFileStore(path)))
+	require.NoError(t, err)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+	want, _ := kr.Current("jwt")
+
+	reopened, err := New(testConfig(2, 0), WithStore(NewFileStore(path)))
+	require.NoError(t, err)
+	got, err := reopened.Current("jwt")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestKeyring_ListenAppliesRotationFromOtherReplica(t *testing.T) {
+	require.NoError(t, cfg.SetDecryptionKey(bytes.Repeat([]byte{7}, 32)))
+	t.Cleanup(func() { _ = cfg.SetDecryptionKey(nil) })
+
+	notifier := newMemoryNotifier(1)
+	leader, err := New(testConfig(2, 0), WithNotifier(notifier))
+	require.NoError(t, err)
+	replicaStore := &memoryStore{}
+	replica, err := New(testConfig(2, 0), WithNotifier(notifier), WithStore(replicaStore))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = replica.Listen(ctx) }()
+	<-notifier.ready
+
+	rotated, err := leader.Rotate(context.Background(), "jwt")
+	require.NoError(t, err)
+	got, err := replica.Current("jwt")
+	require.NoError(t, err)
+	assert.Equal(t, rotated, got)
+	assert.Equal(t, []Version{rotated}, replicaStore.saved["jwt"])
+}
@@ -0,0 +1,67 @@
+package keyring
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultChannel 轮换通知的默认 Redis 频道
+const DefaultChannel = "keyring:rotated"
+
+// RedisNotifier 通过 Redis Pub/Sub 广播轮换通知
+//
+// Pub/Sub 不保留消息：订阅断开期间的通知会丢失，实例在下次重启或配置热更新时同步
+//
+// 使用方式：
+//
+//	kr, _ := keyring.New(conf.Keyring, keyring.WithNotifier(keyring.NewRedisNotifier(cache.Client, "")))
+//	go kr.Listen(ctx)
+type RedisNotifier struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisNotifier 创建 Redis 通知（channel 为空时使用 DefaultChannel）
+func NewRedisNotifier(client redis.UniversalClient, channel string) *RedisNotifier {
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	return &RedisNotifier{client: client, channel: channel}
+}
+
+// Publish 实现 Notifier 接口
+func (n *RedisNotifier) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return n.client.Publish(ctx, n.channel, data).Err()
+}
+
+// Subscribe 实现 Notifier 接口
+func (n *RedisNotifier) Subscribe(ctx context.Context, handle func(Event)) error {
+	sub := n.client.Subscribe(ctx, n.channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Warnf("忽略无法解析的轮换通知: %v", err)
+				continue
+			}
+			handle(event)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitKeyring_PersistsEncryptedVersions(t *testing.T) {
+	require.NoError(t, cfg.SetDecryptionKey(bytes.Repeat([]byte{3}, 32)))
+	t.Cleanup(func() { _ = cfg.SetDecryptionKey(nil) })
+	t.Cleanup(func() { keyring.SetDefault(nil) })
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`appName = "orders"
+port = 8080
+
+[keyring.keys.jwt]
+accept = 2
+rotateEvery = 720
+`), 0644))
+	require.NoError(t, cfg.LoadConfig[stageAppConfig](path))
+
+	kr, err := InitKeyring[stageAppConfig](cfg.MustGetCfg[stageAppConfig]().Keyring)
+	require.NoError(t, err)
+	require.Same(t, kr, keyring.Default())
+	first, err := kr.Current("jwt")
+	require.NoError(t, err, "没有版本的密钥启动时生成")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "ENC(", "写回配置文件的密钥已加密")
+	assert.NotContains(t, string(data), first.Secret)
+	saved := cfg.MustGetCfg[stageAppConfig]().Keyring.Keys["jwt"]
+	require.Len(t, saved.Versions, 1)
+	assert.Equal(t, first.Secret, saved.Versions[0].Secret)
+	assert.Equal(t, 720, saved.RotateEvery)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.Any("/admin/keyring", KeyringAdminHandler())
+	var resp struct {
+		Data []keyring.KeyStatus `json:"data"`
+	}
+
+	w := ut.PerformRequest(engine, "POST", "/admin/keyring?key=jwt", nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), first.Secret, "管理接口不输出密钥")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Len(t, resp.Data[0].Versions, 2)
+	assert.Equal(t, 2, resp.Data[0].Versions[0].ID)
+	assert.True(t, resp.Data[0].Versions[0].Current)
+	assert.Len(t, cfg.MustGetCfg[stageAppConfig]().Keyring.Keys["jwt"].Versions, 2)
+
+	w = ut.PerformRequest(engine, "POST", "/admin/keyring?key=missing", nil)
+	assert.Equal(t, 404, w.Code)
+	w = ut.PerformRequest(engine, "GET", "/admin/keyring", nil)
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderDebugCost 请求携带此头（且调用方有调试角色）时输出资源消耗
+const HeaderDebugCost = "X-Debug-Cost"
+
+// LedgerConfig 请求资源账本配置
+//
+// Example:
+//
+//	[web.ledger]
+//	roles = ["debug", "admin"]   # 可以查看资源消耗的角色，默认 ["debug"]
+type LedgerConfig struct {
+	Roles []string `toml:"roles"`
+}
+
+var ledgerRoles = []string{"debug"}
+
+// InitLedger 设置可以查看资源消耗的角色
+func InitLedger(cfg LedgerConfig) {
+	if len(cfg.Roles) > 0 {
+		ledgerRoles = cfg.Roles
+	}
+}
+
+// Ledger 取当前请求的资源账本（ctx 不属于请求时记账为空操作）
+//
+// 使用方式：
+//
+//	start := time.Now()
+//	rows, err := db.QueryContext(ctx, query, args...)
+//	web.Ledger(ctx).Add(ledger.DB, 1, time.Since(start))
+func Ledger(ctx context.Context) ledger.Ledger {
+	return ledger.From(ctx)
+}
+
+// LedgerMiddleware 请求资源账本中间件
+//
+// 每个请求打开一个池化账本，数据库、Redis、缓存、外部 HTTP 等层通过 web.Ledger(ctx) 记账；
+// 请求带 X-Debug-Cost 头且调用方有调试角色时，汇总以 Server-Timing 响应头输出（浏览器开发者工具可直接展示），
+// 同时记录一条结构化日志。其他请求只付出记账的几次原子加法
+//
+// 使用方式：
+//
+//	h.Use(web.LedgerMiddleware())
+func LedgerMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ctx, l := ledger.Open(ctx)
+		start := time.Now()
+		if n := c.Request.Header.ContentLength(); n > 0 {
+			l.Add(ledger.BytesRead, int64(n), 0)
+		}
+
+		c.Next(ctx)
+
+		total := time.Since(start)
+		if n := responseBytes(c); n > 0 {
+			l.Add(ledger.BytesWritten, n, 0)
+		}
+		snapshot := l.Close()
+
+		if len(c.GetHeader(HeaderDebugCost)) == 0 || !hasLedgerRole(c) {
+			return
+		}
+		// Server-Timing 必须在响应写出之前设置；日志在响应写出之后记录
+		c.Response.Header.Set("Server-Timing", serverTiming(snapshot, total))
+		OnRequestDone(c, func(ctx context.Context, s RequestSummary) {
+			logLedger(s, snapshot, total)
+		})
+	}
+}
+
+func hasLedgerRole(c *app.RequestContext) bool {
+	for _, role := range jwt.GetRoles(c) {
+		if slices.Contains(ledgerRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverTiming 按 Server-Timing 格式汇总账本
+//
+// app 为总耗时减去数据库（含只读副本）、Redis、外部 HTTP 的耗时，近似处理器自身的 CPU 时间
+func serverTiming(s ledger.Snapshot, total time.Duration) string {
+	var parts []string
+	timed := func(name string, kind ledger.Kind, unit string) {
+		if e := s.Get(kind); e.Count > 0 {
+			parts = append(parts, fmt.Sprintf(`%s;desc="%d %s";dur=%s`, name, e.Count, unit, millis(e.Duration)))
+		}
+	}
+	timed("db", ledger.DB, "queries")
+	timed("db-replica", ledger.DBReplica, "queries")
+	timed("redis", ledger.Redis, "commands")
+	timed("http", ledger.HTTP, "calls")
+	if hit, miss := s.Get(ledger.CacheHit).Count, s.Get(ledger.CacheMiss).Count; hit+miss > 0 {
+		parts = append(parts, fmt.Sprintf(`cache;desc="hit=%d miss=%d"`, hit, miss))
+	}
+	parts = append(parts, fmt.Sprintf(`bytes;desc="in=%d out=%d"`, s.Get(ledger.BytesRead).Count, s.Get(ledger.BytesWritten).Count))
+	parts = append(parts, "app;dur="+millis(max(total-s.Tracked(), 0)), "total;dur="+millis(total))
+	return strings.Join(parts, ", ")
+}
+
+func millis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+}
+
+// logLedger 输出一条资源消耗日志
+func logLedger(req RequestSummary, s ledger.Snapshot, total time.Duration) {
+	type entry struct {
+		Count int64   `json:"count"`
+		Ms    float64 `json:"ms,omitempty"`
+	}
+	record := map[string]any{
+		"requestId": req.RequestID,
+		"method":    req.Method,
+		"path":      req.Path,
+		"status":    req.Status,
+		"totalMs":   float64(total) / float64(time.Millisecond),
+		"appMs":     float64(max(total-s.Tracked(), 0)) / float64(time.Millisecond),
+	}
+	for _, kind := range ledger.Kinds() {
+		if e := s.Get(kind); e.Count > 0 {
+			record[kind.String()] = entry{Count: e.Count, Ms: float64(e.Duration) / float64(time.Millisecond)}
+		}
+	}
+	data, _ := json.Marshal(record)
+	log.Infof("[Cost] %s", data)
+}
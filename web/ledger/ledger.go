@@ -0,0 +1,148 @@
+// Package ledger 单个请求的资源消耗账本（数据库、Redis、缓存、外部 HTTP、读写字节）
+//
+// 各数据访问层通过 ledger.From(ctx).Add(...) 记账，web.LedgerMiddleware 在请求开始时打开账本、
+// 结束时汇总（调试请求以 Server-Timing 响应头输出）。账本在请求之间复用，
+// 记账只是几次原子加法，没有账本的 ctx（后台任务等）记账为空操作
+package ledger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind 记账类别
+type Kind uint8
+
+// 记账类别
+const (
+	DB           Kind = iota // 数据库查询
+	Redis                    // Redis 命令
+	CacheHit                 // 缓存命中
+	CacheMiss                // 缓存未命中
+	HTTP                     // 外部 HTTP 调用
+	BytesRead                // 读取的字节（请求体）
+	BytesWritten             // 写出的字节（响应体）
+	DBReplica                // 只读副本查询（database.Replica）
+	numKinds
+)
+
+var kindNames = [numKinds]string{"db", "redis", "cache_hit", "cache_miss", "http", "bytes_read", "bytes_written", "db_replica"}
+
+// String 类别名（同时用作 Server-Timing 与日志的字段名）
+func (k Kind) String() string {
+	if k < numKinds {
+		return kindNames[k]
+	}
+	return "unknown"
+}
+
+// Kinds 全部记账类别
+func Kinds() []Kind {
+	out := make([]Kind, numKinds)
+	for i := range out {
+		out[i] = Kind(i)
+	}
+	return out
+}
+
+// book 账本存储（池化复用）
+type book struct {
+	gen    atomic.Uint64 // 每次归还时递增，使旧请求残留的句柄失效
+	counts [numKinds]atomic.Int64
+	nanos  [numKinds]atomic.Int64
+}
+
+var pool = sync.Pool{New: func() any { return new(book) }}
+
+// Ledger 账本句柄（零值可用，记账为空操作）
+type Ledger struct {
+	b   *book
+	gen uint64
+}
+
+type ctxKey struct{}
+
+// Open 打开一个账本并绑定到 ctx（请求结束时必须调用 Close）
+func Open(ctx context.Context) (context.Context, Ledger) {
+	b := pool.Get().(*book)
+	l := Ledger{b: b, gen: b.gen.Load()}
+	return context.WithValue(ctx, ctxKey{}, l), l
+}
+
+// From 取 ctx 绑定的账本（未绑定时返回零值）
+func From(ctx context.Context) Ledger {
+	if ctx == nil {
+		return Ledger{}
+	}
+	l, _ := ctx.Value(ctxKey{}).(Ledger)
+	return l
+}
+
+// Add 记一笔：count 次、共耗时 d（不计时的类别传 0）
+//
+// 账本已关闭（请求结束后仍在运行的 goroutine）时丢弃
+func (l Ledger) Add(kind Kind, count int64, d time.Duration) {
+	if l.b == nil || kind >= numKinds || l.b.gen.Load() != l.gen {
+		return
+	}
+	l.b.counts[kind].Add(count)
+	if d > 0 {
+		l.b.nanos[kind].Add(int64(d))
+	}
+}
+
+// Active 账本是否打开（可用于跳过昂贵的统计）
+func (l Ledger) Active() bool {
+	return l.b != nil && l.b.gen.Load() == l.gen
+}
+
+// Snapshot 当前汇总（账本已关闭时为空）
+func (l Ledger) Snapshot() Snapshot {
+	var s Snapshot
+	if !l.Active() {
+		return s
+	}
+	for i := range s.entries {
+		s.entries[i] = Entry{Count: l.b.counts[i].Load(), Duration: time.Duration(l.b.nanos[i].Load())}
+	}
+	return s
+}
+
+// Close 关闭账本并归还到池中，返回最终汇总（重复调用返回空汇总）
+func (l Ledger) Close() Snapshot {
+	if l.b == nil || !l.b.gen.CompareAndSwap(l.gen, l.gen+1) {
+		return Snapshot{}
+	}
+	var s Snapshot
+	for i := range s.entries {
+		s.entries[i] = Entry{Count: l.b.counts[i].Swap(0), Duration: time.Duration(l.b.nanos[i].Swap(0))}
+	}
+	pool.Put(l.b)
+	return s
+}
+
+// Entry 一个类别的汇总
+type Entry struct {
+	Count    int64
+	Duration time.Duration
+}
+
+// Snapshot 账本汇总
+type Snapshot struct {
+	entries [numKinds]Entry
+}
+
+// Get 取一个类别的汇总
+func (s Snapshot) Get(kind Kind) Entry {
+	if kind >= numKinds {
+		return Entry{}
+	}
+	return s.entries[kind]
+}
+
+// Tracked 计时类别（数据库、只读副本、Redis、外部 HTTP）的总耗时
+func (s Snapshot) Tracked() time.Duration {
+	return s.entries[DB].Duration + s.entries[DBReplica].Duration + s.entries[Redis].Duration + s.entries[HTTP].Duration
+}
@@ -0,0 +1,47 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedger_CloseResetsAndDetaches(t *testing.T) {
+	ctx, l := Open(context.Background())
+	From(ctx).Add(DB, 2, 5*time.Millisecond)
+	From(ctx).Add(CacheHit, 1, 0)
+	assert.Equal(t, Entry{Count: 2, Duration: 5 * time.Millisecond}, l.Snapshot().Get(DB))
+
+	s := l.Close()
+	assert.Equal(t, int64(2), s.Get(DB).Count)
+	assert.Equal(t, int64(1), s.Get(CacheHit).Count)
+	assert.Equal(t, Snapshot{}, l.Close(), "重复关闭返回空汇总")
+
+	// 请求结束后仍在运行的 goroutine 记账被丢弃，不计入复用同一存储的下一个请求
+	From(ctx).Add(DB, 100, time.Second)
+	assert.False(t, From(ctx).Active())
+	_, next := Open(context.Background())
+	defer next.Close()
+	assert.Equal(t, Entry{}, next.Snapshot().Get(DB))
+}
+
+func TestLedger_NoLedgerIsNoop(t *testing.T) {
+	l := From(context.Background())
+	assert.False(t, l.Active())
+	l.Add(Redis, 1, time.Millisecond)
+	assert.Equal(t, Snapshot{}, l.Snapshot())
+	assert.Equal(t, Snapshot{}, l.Close())
+	assert.Equal(t, "bytes_written", BytesWritten.String())
+	assert.Len(t, Kinds(), 8)
+}
+
+func BenchmarkLedger_Add(b *testing.B) {
+	ctx, l := Open(context.Background())
+	defer l.Close()
+	b.ReportAllocs()
+	for b.Loop() {
+		From(ctx).Add(DB, 1, time.Millisecond)
+	}
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/client"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/ledger"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapStore 进程内缓存存储
+type mapStore struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (s *mapStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[key]
+	return v, ok, nil
+}
+
+func (s *mapStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = value
+	return nil
+}
+
+func newLedgerEngine(t *testing.T) *route.Engine {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "ledger-secret"
+	require.NoError(t, jwt.Init(conf))
+
+	upstream := route.NewEngine(config.NewOptions(nil))
+	upstream.GET("/rates", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(map[string]float64{"usd": 7.1}))
+	})
+	rates := client.New("http://rates", client.WithHTTPClient(&http.Client{Transport: engineTransport{upstream}}))
+	loader := cache.NewLoader(&mapStore{m: map[string][]byte{}}, nil)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(LedgerMiddleware(), jwt.Middleware())
+	engine.POST("/report", func(ctx context.Context, c *app.RequestContext) {
+		Ledger(ctx).Add(ledger.DB, 3, 12*time.Millisecond)
+		Ledger(ctx).Add(ledger.Redis, 2, 1500*time.Microsecond)
+		for range 3 {
+			_, err := cache.LoadWith(ctx, loader, "rates", time.Minute, func(ctx context.Context) (map[string]float64, error) {
+				return client.Get[map[string]float64](ctx, rates, "/rates")
+			})
+			require.NoError(t, err)
+		}
+		c.String(200, "ok")
+	})
+	return engine
+}
+
+func TestLedgerMiddleware_ServerTiming(t *testing.T) {
+	engine := newLedgerEngine(t)
+	body := &ut.Body{Body: strings.NewReader(`{"month":"2026-05"}`), Len: 19}
+	w := ut.PerformRequest(engine, "POST", "/report", body,
+		ownershipToken(t, "alice", "debug"), ut.Header{Key: HeaderDebugCost, Value: "1"})
+	require.Equal(t, 200, w.Code)
+
+	timing := string(w.Header().Peek("Server-Timing"))
+	parts := strings.Split(timing, ", ")
+	require.Len(t, parts, 7, timing)
+	assert.Equal(t, `db;desc="3 queries";dur=12.00`, parts[0])
+	assert.Equal(t, `redis;desc="2 commands";dur=1.50`, parts[1])
+	assert.Regexp(t, `^http;desc="1 calls";dur=\d+\.\d\d$`, parts[2])
+	assert.Equal(t, `cache;desc="hit=2 miss=1"`, parts[3])
+	assert.Equal(t, `bytes;desc="in=19 out=2"`, parts[4])
+	assert.Regexp(t, `^app;dur=\d+\.\d\d$`, parts[5])
+	assert.Regexp(t, `^total;dur=\d+\.\d\d$`, parts[6])
+}
+
+func TestLedgerMiddleware_Gated(t *testing.T) {
+	engine := newLedgerEngine(t)
+
+	// 没有调试头
+	w := ut.PerformRequest(engine, "POST", "/report", nil, ownershipToken(t, "alice", "debug"))
+	assert.Empty(t, w.Header().Peek("Server-Timing"))
+
+	// 没有调试角色
+	w = ut.PerformRequest(engine, "POST", "/report", nil, ownershipToken(t, "bob", "user"), ut.Header{Key: HeaderDebugCost, Value: "1"})
+	assert.Empty(t, w.Header().Peek("Server-Timing"))
+
+	// 配置的其他角色
+	InitLedger(LedgerConfig{Roles: []string{"sre"}})
+	t.Cleanup(func() { ledgerRoles = []string{"debug"} })
+	w = ut.PerformRequest(engine, "POST", "/report", nil, ownershipToken(t, "carol", "sre"), ut.Header{Key: HeaderDebugCost, Value: "1"})
+	assert.NotEmpty(t, w.Header().Peek("Server-Timing"))
+}
+
+func TestLedgerMiddleware_NoLeakAcrossConcurrentRequests(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "ledger-secret"
+	require.NoError(t, jwt.Init(conf))
+	token := ownershipToken(t, "alice", "debug")
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(LedgerMiddleware(), jwt.Middleware())
+	engine.GET("/work/:n", func(ctx context.Context, c *app.RequestContext) {
+		var n int
+		fmt.Sscan(c.Param("n"), &n)
+		for range n {
+			Ledger(ctx).Add(ledger.DB, 1, time.Millisecond)
+			time.Sleep(time.Duration(n%3) * 100 * time.Microsecond)
+		}
+		c.String(200, "")
+	})
+
+	dbPart := regexp.MustCompile(`db;desc="(\d+) queries";dur=(\d+)\.00`)
+	var wg sync.WaitGroup
+	for i := range 64 {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for range 5 {
+				w := ut.PerformRequest(engine, "GET", fmt.Sprintf("/work/%d", n), nil, token, ut.Header{Key: HeaderDebugCost, Value: "1"})
+				m := dbPart.FindStringSubmatch(string(w.Header().Peek("Server-Timing")))
+				if n == 0 {
+					assert.Nil(t, m, "没有查询的请求不输出 db")
+					continue
+				}
+				if assert.NotNil(t, m) {
+					assert.Equal(t, fmt.Sprint(n), m[1], "请求 %d 的查询数", n)
+					assert.Equal(t, fmt.Sprint(n), m[2], "请求 %d 的查询耗时", n)
+				}
+			}
+		}(i % 16)
+	}
+	wg.Wait()
+}
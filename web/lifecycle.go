@@ -0,0 +1,279 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内置组件名（应用组件可通过 DependsOn 声明对它们的依赖）
+const (
+	ComponentDatabase = "database" // 数据库连接
+	ComponentRedis    = "redis"    // Redis 连接
+)
+
+// 组件默认超时
+const (
+	defaultStartTimeout = 30 * time.Second
+	defaultStopTimeout  = 10 * time.Second
+)
+
+// Component 生命周期组件
+//
+// 按 DependsOn 拓扑排序后依次启动，优雅关闭时逆序停止
+type Component struct {
+	Name         string                          // 组件名（唯一）
+	DependsOn    []string                        // 依赖的组件名
+	Start        func(ctx context.Context) error // 启动（可为 nil）
+	Stop         func(ctx context.Context) error // 停止（可为 nil）
+	StartTimeout time.Duration                   // 启动超时，默认 30 秒
+	StopTimeout  time.Duration                   // 停止预算，默认 10 秒
+}
+
+// ComponentReport 单个组件的启动/停止结果
+type ComponentReport struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// Lifecycle 组件注册表
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []*Component          // 注册顺序
+	byName     map[string]*Component // 名称索引
+	started    []*Component          // 已启动（按启动顺序）
+}
+
+// NewLifecycle 创建组件注册表
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{byName: make(map[string]*Component)}
+}
+
+// Register 注册组件（名称重复时返回错误）
+func (l *Lifecycle) Register(c Component) error {
+	if c.Name == "" {
+		return fmt.Errorf("组件名不能为空")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.byName[c.Name]; ok {
+		return fmt.Errorf("组件 %s 重复注册", c.Name)
+	}
+	l.components = append(l.components, &c)
+	l.byName[c.Name] = &c
+	return nil
+}
+
+// Order 按依赖关系排序（依赖在前）；存在环或未知依赖时返回错误
+func (l *Lifecycle) Order() ([]*Component, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.orderLocked()
+}
+
+func (l *Lifecycle) orderLocked() ([]*Component, error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(l.components))
+	order := make([]*Component, 0, len(l.components))
+	var path []string
+
+	var visit func(c *Component) error
+	visit = func(c *Component) error {
+		switch state[c.Name] {
+		case done:
+			return nil
+		case visiting:
+			// 从环的起点截取路径
+			for i, name := range path {
+				if name == c.Name {
+					cycle := append(append([]string{}, path[i:]...), c.Name)
+					return fmt.Errorf("组件依赖存在环: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		state[c.Name] = visiting
+		path = append(path, c.Name)
+		for _, dep := range c.DependsOn {
+			d, ok := l.byName[dep]
+			if !ok {
+				return fmt.Errorf("组件 %s 依赖未注册的组件 %s", c.Name, dep)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[c.Name] = done
+		order = append(order, c)
+		return nil
+	}
+
+	for _, c := range l.components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Start 按依赖顺序启动尚未启动的组件
+//
+// 任一组件失败或超时时，已启动的组件按逆序停止后返回错误
+func (l *Lifecycle) Start(ctx context.Context) ([]ComponentReport, error) {
+	l.mu.Lock()
+	order, err := l.orderLocked()
+	if err != nil {
+		l.mu.Unlock()
+		return nil, err
+	}
+	running := make(map[*Component]bool, len(l.started))
+	for _, c := range l.started {
+		running[c] = true
+	}
+	l.mu.Unlock()
+
+	var reports []ComponentReport
+	for _, c := range order {
+		if running[c] {
+			continue
+		}
+		timeout := c.StartTimeout
+		if timeout <= 0 {
+			timeout = defaultStartTimeout
+		}
+		start := time.Now()
+		err := callWithTimeout(ctx, c.Start, timeout)
+		reports = append(reports, ComponentReport{Name: c.Name, Duration: time.Since(start), Err: err})
+		if err != nil {
+			log.Errorf("[Lifecycle] 组件 %s 启动失败: %v，回滚已启动的组件", c.Name, err)
+			l.Stop(ctx)
+			return reports, fmt.Errorf("组件 %s 启动失败: %w", c.Name, err)
+		}
+
+		l.mu.Lock()
+		l.started = append(l.started, c)
+		l.mu.Unlock()
+	}
+	return reports, nil
+}
+
+// Stop 按启动的逆序停止全部已启动组件
+//
+// 每个组件最多等待其停止预算，超出时记录并继续停止下一个
+func (l *Lifecycle) Stop(ctx context.Context) []ComponentReport {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	reports := make([]ComponentReport, 0, len(started))
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		budget := c.StopTimeout
+		if budget <= 0 {
+			budget = defaultStopTimeout
+		}
+		start := time.Now()
+		err := callWithTimeout(ctx, c.Stop, budget)
+		elapsed := time.Since(start)
+		switch {
+		case err == context.DeadlineExceeded:
+			log.Warnf("[Lifecycle] 组件 %s 停止超出预算 %v，继续关闭其余组件", c.Name, budget)
+		case err != nil:
+			log.Errorf("[Lifecycle] 组件 %s 停止失败: %v", c.Name, err)
+		default:
+			log.Infof("[Lifecycle] 组件 %s 已停止 (%v)", c.Name, elapsed)
+		}
+		reports = append(reports, ComponentReport{Name: c.Name, Duration: elapsed, Err: err})
+	}
+	return reports
+}
+
+// callWithTimeout 在超时内执行 fn（fn 不响应 ctx 时也按时返回）
+func callWithTimeout(parent context.Context, fn func(ctx context.Context) error, timeout time.Duration) error {
+	if fn == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logStartupReport 输出启动报告
+func logStartupReport(reports []ComponentReport) {
+	for _, r := range reports {
+		status := "ok"
+		if r.Err != nil {
+			status = r.Err.Error()
+		}
+		log.Infof("[Lifecycle] 启动 %-16s %8v  %s", r.Name, r.Duration.Round(time.Millisecond), status)
+	}
+}
+
+// RegisterComponent 在默认 App 上注册应用组件（名称重复时 panic）
+//
+// 在 MustRun 之前注册；启动顺序由依赖决定，优雅关闭时逆序停止
+//
+// 使用方式：
+//
+//	web.RegisterComponent(web.Component{
+//	    Name:      "outbox",
+//	    DependsOn: []string{web.ComponentDatabase},
+//	    Start:     dispatcher.Start,
+//	    Stop:      dispatcher.Stop,   // 在数据库关闭之前停止
+//	})
+func RegisterComponent(c Component) {
+	defaultApp.RegisterComponent(c)
+}
+
+// startupReports 已输出的启动报告（NewServer 与 MustRun 两次启动的结果依次追加）
+var startupReports struct {
+	sync.Mutex
+	reports []ComponentReport
+}
+
+// StartupReport 组件启动报告（按启动顺序，诊断包等排障场景使用）
+func StartupReport() []ComponentReport {
+	startupReports.Lock()
+	defer startupReports.Unlock()
+	return append([]ComponentReport(nil), startupReports.reports...)
+}
+
+// startComponents 启动默认 App 中尚未启动的组件（失败时 panic）
+func startComponents() {
+	reports, err := defaultApp.lifecycle.Start(context.Background())
+	logStartupReport(reports)
+	startupReports.Lock()
+	startupReports.reports = append(startupReports.reports, reports...)
+	startupReports.Unlock()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// stopComponents 优雅关闭时停止全部组件
+func stopComponents(ctx context.Context) {
+	defaultApp.lifecycle.Stop(ctx)
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callLog 记录组件启动/停止顺序
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, s)
+}
+
+func (l *callLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+func testComponent(log *callLog, name string, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Start:     func(ctx context.Context) error { log.add("start " + name); return nil },
+		Stop:      func(ctx context.Context) error { log.add("stop " + name); return nil },
+	}
+}
+
+func TestLifecycle_DependencyOrder(t *testing.T) {
+	log := &callLog{}
+	l := NewLifecycle()
+	// 注册顺序与依赖顺序相反
+	require.NoError(t, l.Register(testComponent(log, "outbox", ComponentDatabase)))
+	require.NoError(t, l.Register(testComponent(log, "ws", ComponentRedis)))
+	require.NoError(t, l.Register(testComponent(log, ComponentRedis)))
+	require.NoError(t, l.Register(testComponent(log, ComponentDatabase)))
+	assert.Error(t, l.Register(testComponent(log, "ws")))
+
+	reports, err := l.Start(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, reports, 4)
+	l.Stop(context.Background())
+
+	assert.Equal(t, []string{
+		"start database", "start outbox", "start redis", "start ws",
+		"stop ws", "stop redis", "stop outbox", "stop database",
+	}, log.list())
+}
+
+func TestLifecycle_IncrementalStart(t *testing.T) {
+	log := &callLog{}
+	l := NewLifecycle()
+	require.NoError(t, l.Register(testComponent(log, ComponentDatabase)))
+	_, err := l.Start(context.Background())
+	require.NoError(t, err)
+
+	// 后注册的应用组件再次 Start 时启动，已启动的不重复
+	require.NoError(t, l.Register(testComponent(log, "app", ComponentDatabase)))
+	reports, err := l.Start(context.Background())
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "app", reports[0].Name)
+	l.Stop(context.Background())
+
+	assert.Equal(t, []string{"start database", "start app", "stop app", "stop database"}, log.list())
+}
+
+func TestLifecycle_CycleDetection(t *testing.T) {
+	l := NewLifecycle()
+	log := &callLog{}
+	require.NoError(t, l.Register(testComponent(log, "a", "b")))
+	require.NoError(t, l.Register(testComponent(log, "b", "c")))
+	require.NoError(t, l.Register(testComponent(log, "c", "a")))
+
+	_, err := l.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a -> b -> c -> a")
+	assert.Empty(t, log.list(), "nothing may start when the graph is invalid")
+
+	l = NewLifecycle()
+	require.NoError(t, l.Register(testComponent(log, "a", "missing")))
+	_, err = l.Order()
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestLifecycle_StartFailureRollsBack(t *testing.T) {
+	log := &callLog{}
+	l := NewLifecycle()
+	require.NoError(t, l.Register(testComponent(log, ComponentDatabase)))
+	require.NoError(t, l.Register(testComponent(log, ComponentRedis, ComponentDatabase)))
+	require.NoError(t, l.Register(Component{
+		Name:      "broken",
+		DependsOn: []string{ComponentRedis},
+		Start:     func(ctx context.Context) error { return errors.New("boom") },
+		Stop:      func(ctx context.Context) error { log.add("stop broken"); return nil },
+	}))
+	require.NoError(t, l.Register(testComponent(log, "after", "broken")))
+
+	reports, err := l.Start(context.Background())
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "broken")
+	require.Len(t, reports, 3)
+	assert.Error(t, reports[2].Err)
+
+	// 失败组件和其后的组件不会被启动或停止；之前的组件逆序停止
+	assert.Equal(t, []string{"start database", "start redis", "stop redis", "stop database"}, log.list())
+}
+
+func TestLifecycle_Timeouts(t *testing.T) {
+	log := &callLog{}
+	l := NewLifecycle()
+	require.NoError(t, l.Register(testComponent(log, ComponentDatabase)))
+	require.NoError(t, l.Register(Component{
+		Name:         "slow",
+		DependsOn:    []string{ComponentDatabase},
+		Start:        func(ctx context.Context) error { time.Sleep(time.Second); return nil },
+		StartTimeout: 20 * time.Millisecond,
+	}))
+
+	start := time.Now()
+	_, err := l.Start(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, []string{"start database", "stop database"}, log.list())
+
+	// 停止超出预算：记录后继续停止依赖的组件
+	log = &callLog{}
+	l = NewLifecycle()
+	require.NoError(t, l.Register(testComponent(log, ComponentDatabase)))
+	require.NoError(t, l.Register(Component{
+		Name:        "stuck",
+		DependsOn:   []string{ComponentDatabase},
+		Stop:        func(ctx context.Context) error { time.Sleep(time.Second); return nil },
+		StopTimeout: 20 * time.Millisecond,
+	}))
+	_, err = l.Start(context.Background())
+	require.NoError(t, err)
+
+	start = time.Now()
+	reports := l.Stop(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "stuck", reports[0].Name)
+	assert.ErrorIs(t, reports[0].Err, context.DeadlineExceeded)
+	assert.NoError(t, reports[1].Err)
+	assert.Equal(t, []string{"start database", "stop database"}, log.list())
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// Locale 格式化使用的语言和时区
+type Locale struct {
+	Language string         // 如 "zh-CN"、"en-US"
+	Location *time.Location // 为 nil 时按默认时区
+}
+
+// TimeStyle 时间格式风格
+type TimeStyle string
+
+const (
+	TimeStyleDate     TimeStyle = "date"     // 短日期：2026/10/15、10/15/2026
+	TimeStyleTime     TimeStyle = "time"     // 时间：14:30、2:30 PM
+	TimeStyleDateTime TimeStyle = "datetime" // 短日期 + 时间
+	TimeStyleLong     TimeStyle = "long"     // 长日期 + 时间 + 时区：2026年10月15日 14:30 CST
+	TimeStyleISO      TimeStyle = "iso"      // RFC3339（带时区偏移，与语言无关）
+)
+
+// LocaleData 一种语言的格式化数据（参照 CLDR）
+//
+// 内置 zh-CN 与 en-US，其他语言用 RegisterLocaleData 注册
+type LocaleData struct {
+	Decimal         string               // 小数点
+	Group           string               // 千位分隔符
+	Layouts         map[TimeStyle]string // 各风格的时间布局（Go layout）
+	CurrencyFormat  string               // 货币格式，{symbol} 与 {number} 为占位符
+	CurrencySymbols map[string]string    // 货币代码 -> 符号（未列出的货币显示代码）
+}
+
+var localeData = map[string]LocaleData{
+	"zh-CN": {
+		Decimal: ".",
+		Group:   ",",
+		Layouts: map[TimeStyle]string{
+			TimeStyleDate:     "2006/1/2",
+			TimeStyleTime:     "15:04",
+			TimeStyleDateTime: "2006/1/2 15:04",
+			TimeStyleLong:     "2006年1月2日 15:04 MST",
+		},
+		CurrencyFormat:  "{symbol}{number}",
+		CurrencySymbols: map[string]string{"CNY": "¥", "USD": "US$", "EUR": "€", "GBP": "£", "JPY": "JP¥", "HKD": "HK$"},
+	},
+	"en-US": {
+		Decimal: ".",
+		Group:   ",",
+		Layouts: map[TimeStyle]string{
+			TimeStyleDate:     "1/2/2006",
+			TimeStyleTime:     "3:04 PM",
+			TimeStyleDateTime: "1/2/2006 3:04 PM",
+			TimeStyleLong:     "January 2, 2006 3:04 PM MST",
+		},
+		CurrencyFormat:  "{symbol}{number}",
+		CurrencySymbols: map[string]string{"USD": "$", "CNY": "CN¥", "EUR": "€", "GBP": "£", "JPY": "¥", "HKD": "HK$"},
+	},
+}
+
+var localeDataMu sync.RWMutex
+
+// RegisterLocaleData 注册（或覆盖）一种语言的格式化数据
+//
+// 使用方式：
+//
+//	web.RegisterLocaleData("de-DE", web.LocaleData{
+//	    Decimal: ",", Group: ".",
+//	    Layouts: map[web.TimeStyle]string{web.TimeStyleDate: "02.01.2006", ...},
+//	    CurrencyFormat: "{number} {symbol}",
+//	    CurrencySymbols: map[string]string{"EUR": "€"},
+//	})
+func RegisterLocaleData(lang string, data LocaleData) {
+	localeDataMu.Lock()
+	defer localeDataMu.Unlock()
+	localeData[lang] = data
+}
+
+// dataFor 按语言查找格式化数据：完全匹配 → 同一主语言 → 默认语言 → en-US
+func dataFor(lang string) LocaleData {
+	localeDataMu.RLock()
+	defer localeDataMu.RUnlock()
+	if name := matchLocale(lang); name != "" {
+		return localeData[name]
+	}
+	if name := matchLocale(defaultLocale.Load().Language); name != "" {
+		return localeData[name]
+	}
+	return localeData["en-US"]
+}
+
+// matchLocale 在已注册的语言中查找匹配项（调用方持有读锁）
+func matchLocale(lang string) string {
+	if lang == "" {
+		return ""
+	}
+	lang = strings.ReplaceAll(lang, "_", "-")
+	best := ""
+	base, _, _ := strings.Cut(lang, "-")
+	for name := range localeData {
+		if strings.EqualFold(name, lang) {
+			return name
+		}
+		if nb, _, _ := strings.Cut(name, "-"); strings.EqualFold(nb, base) && (best == "" || name < best) {
+			best = name
+		}
+	}
+	return best
+}
+
+// SupportedLanguage 请求语言是否有格式化数据（完全匹配或主语言匹配）
+func SupportedLanguage(lang string) bool {
+	localeDataMu.RLock()
+	defer localeDataMu.RUnlock()
+	return matchLocale(lang) != ""
+}
+
+var defaultLocale atomic.Pointer[Locale]
+
+func init() {
+	defaultLocale.Store(&Locale{Language: "zh-CN", Location: time.Local})
+}
+
+// InitLocale 设置默认语言和时区（NewServer 按 defaultLang / defaultTimezone 调用）
+//
+// 时区无效时记录警告并保留本地时区，不会 panic
+func InitLocale(lang, timezone string) {
+	l := Locale{Language: lang, Location: time.Local}
+	if l.Language == "" {
+		l.Language = "zh-CN"
+	}
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			l.Location = loc
+		} else {
+			log.Warnf("[Locale] 无效的默认时区 %q，使用本地时区: %v", timezone, err)
+		}
+	}
+	defaultLocale.Store(&l)
+}
+
+// DefaultLocale 默认语言和时区
+func DefaultLocale() Locale {
+	return *defaultLocale.Load()
+}
+
+// TimezoneResolver 查询用户设置的时区（IANA 名称，如 "Asia/Shanghai"；未设置时返回空字符串）
+type TimezoneResolver func(ctx context.Context, c *app.RequestContext) string
+
+var timezoneResolver atomic.Pointer[TimezoneResolver]
+
+// SetTimezoneResolver 设置用户时区查询（优先于 X-Timezone 请求头）
+//
+// 查询在请求中第一次格式化时间时执行（此时 JWT 中间件已经运行），每个请求最多一次
+//
+// 使用方式：
+//
+//	web.SetTimezoneResolver(func(ctx context.Context, c *app.RequestContext) string {
+//	    tz, _ := queries.GetUserTimezone(ctx, jwt.GetUserID(c))
+//	    return tz
+//	})
+func SetTimezoneResolver(r TimezoneResolver) {
+	if r == nil {
+		timezoneResolver.Store(nil)
+		return
+	}
+	timezoneResolver.Store(&r)
+}
+
+type localeKey struct{}
+
+// requestLocale 请求的区域设置（用户时区延迟查询）
+type requestLocale struct {
+	once     sync.Once
+	ctx      context.Context
+	c        *app.RequestContext
+	language string
+	header   string // X-Timezone 请求头
+	locale   Locale
+}
+
+// resolve 第一次使用时查询用户时区
+func (r *requestLocale) resolve() Locale {
+	r.once.Do(func() {
+		var user string
+		if p := timezoneResolver.Load(); p != nil {
+			user = (*p)(r.ctx, r.c)
+		}
+		r.locale = Locale{Language: r.language, Location: loadTimezone(user, r.header)}
+		r.ctx, r.c = nil, nil
+	})
+	return r.locale
+}
+
+// finish 请求结束：未使用过时区时不再查询用户设置，之后 ctx 被异步任务持有时也不会访问已回收的 RequestContext
+func (r *requestLocale) finish() {
+	r.once.Do(func() {
+		r.locale = Locale{Language: r.language, Location: loadTimezone(r.header)}
+		r.ctx, r.c = nil, nil
+	})
+}
+
+// loadTimezone 返回第一个有效的时区，都无效时返回默认时区
+func loadTimezone(names ...string) *time.Location {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+		log.Debugf("[Locale] 忽略无效时区 %q", name)
+	}
+	return defaultLocale.Load().Location
+}
+
+// requestLanguage ?lang 参数 → Accept-Language 中第一个支持的语言（有格式化数据或请求所属 App 的翻译）→ 默认语言
+func requestLanguage(c *app.RequestContext) string {
+	a := AppOf(c)
+	if lang := c.Query("lang"); lang != "" && (SupportedLanguage(lang) || a.messages.has(lang)) {
+		return lang
+	}
+	for _, part := range strings.Split(string(c.GetHeader("Accept-Language")), ",") {
+		lang, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang != "" && lang != "*" && (SupportedLanguage(lang) || a.messages.has(lang)) {
+			return lang
+		}
+	}
+	return a.defaultLanguage()
+}
+
+// LocaleMiddleware 解析请求的语言和时区，写入 ctx 供 FormatTime 等函数使用
+//
+// 语言：?lang 参数 → Accept-Language → 默认语言；
+// 时区：SetTimezoneResolver 的用户设置 → X-Timezone 请求头 → 默认时区
+func LocaleMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		state := &requestLocale{ctx: ctx, c: c, language: requestLanguage(c), header: string(c.GetHeader("X-Timezone"))}
+		defer state.finish()
+		c.Next(context.WithValue(ctx, localeKey{}, state))
+	}
+}
+
+// WithLocale 在 ctx 中指定语言和时区（邮件、导出等没有请求的场景）
+func WithLocale(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, localeKey{}, l)
+}
+
+// LocaleFrom 获取 ctx 中的语言和时区（未设置的部分使用默认值）
+func LocaleFrom(ctx context.Context) Locale {
+	var l Locale
+	switch v := ctx.Value(localeKey{}).(type) {
+	case Locale:
+		l = v
+	case *requestLocale:
+		l = v.resolve()
+	}
+	def := defaultLocale.Load()
+	if l.Language == "" {
+		l.Language = def.Language
+	}
+	if l.Location == nil {
+		l.Location = def.Location
+	}
+	return l
+}
+
+// LocaleFor 按语言和时区名创建 Locale（时区无效时使用默认时区）
+func LocaleFor(lang, timezone string) Locale {
+	return Locale{Language: lang, Location: loadTimezone(timezone)}
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/fsnotify/fsnotify"
+)
+
+// LocaleFileError 本地化文件加载失败（该语言继续使用之前的翻译）
+type LocaleFileError struct {
+	File  string    `json:"file"`
+	Line  int       `json:"line,omitempty"` // 语法错误所在行（无法确定时为 0）
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// LocaleStatus 本地化文件的加载状态
+type LocaleStatus struct {
+	Dir       string            `json:"dir"`
+	Languages []string          `json:"languages"`        // 已从文件加载的语言
+	Errors    []LocaleFileError `json:"errors,omitempty"` // 当前加载失败的文件
+}
+
+var (
+	localeStatusMu  sync.Mutex
+	localeDir       string
+	localeFileErrs  = map[string]LocaleFileError{} // 文件名 -> 最近一次加载错误
+	localeFileLangs = map[string]string{}          // 文件名 -> 语言
+)
+
+// CurrentLocaleStatus 本地化文件的加载状态（/health 在有错误时附带，/debug/locales 完整输出）
+func CurrentLocaleStatus() LocaleStatus {
+	localeStatusMu.Lock()
+	status := LocaleStatus{Dir: localeDir}
+	for _, e := range localeFileErrs {
+		status.Errors = append(status.Errors, e)
+	}
+	localeStatusMu.Unlock()
+	slices.SortFunc(status.Errors, func(a, b LocaleFileError) int { return strings.Compare(a.File, b.File) })
+
+	status.Languages = defaultApp.messages.fileLanguages()
+	return status
+}
+
+// DebugLocalesHandler 本地化文件加载状态（注册到 /debug/locales）
+func DebugLocalesHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, Success(CurrentLocaleStatus()))
+	}
+}
+
+// loadLocaleFile 解析一个 <语言>.toml 并原子替换该语言的文件翻译
+//
+// 解析失败时保留该语言之前的翻译，记录文件、行号和错误
+func loadLocaleFile(path string) error {
+	name := filepath.Base(path)
+	lang := strings.TrimSuffix(name, ".toml")
+	var raw map[string]any
+	_, err := toml.DecodeFile(path, &raw)
+	if err != nil {
+		fe := LocaleFileError{File: name, Error: err.Error(), Time: time.Now()}
+		var pe toml.ParseError
+		if errors.As(err, &pe) {
+			fe.Line = pe.Position.Line
+			fe.Error = pe.Message
+		}
+		localeStatusMu.Lock()
+		localeFileErrs[name] = fe
+		localeStatusMu.Unlock()
+		log.Errorf("[I18n] 本地化文件 %s 第 %d 行解析失败，%s 继续使用之前的翻译: %s", path, fe.Line, lang, fe.Error)
+		return fmt.Errorf("%s:%d: %s", name, fe.Line, fe.Error)
+	}
+
+	translations := make(map[string]string)
+	flattenLocale("", raw, translations)
+	defaultApp.messages.setFile(lang, translations)
+
+	localeStatusMu.Lock()
+	delete(localeFileErrs, name)
+	localeFileLangs[name] = lang
+	localeStatusMu.Unlock()
+	return nil
+}
+
+// flattenLocale 展开嵌套表：[order] paid = "…" 的键为 order.paid
+func flattenLocale(prefix string, raw map[string]any, out map[string]string) {
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			out[prefix+k] = v
+		case map[string]any:
+			flattenLocale(prefix+k+".", v, out)
+		}
+	}
+}
+
+// removeLocaleFile 文件被删除：移除该语言的文件翻译
+func removeLocaleFile(path string) {
+	name := filepath.Base(path)
+	localeStatusMu.Lock()
+	lang, ok := localeFileLangs[name]
+	delete(localeFileLangs, name)
+	delete(localeFileErrs, name)
+	localeStatusMu.Unlock()
+	if !ok {
+		return
+	}
+	defaultApp.messages.setFile(lang, nil)
+	log.Warnf("[I18n] 本地化文件 %s 已删除，移除语言 %s 的文件翻译", path, lang)
+}
+
+// LocaleWatcher 本地化目录监听
+type LocaleWatcher struct {
+	dir     string
+	watcher *fsnotify.Watcher
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer // 每个文件单独去抖
+	closed bool
+}
+
+// WatchLocales 加载本地化目录下的全部 <语言>.toml 并监听变化（NewServer 按 localePath 调用）
+//
+// 修改的文件单独重新解析后原子替换该语言的翻译；解析失败时保留之前的翻译并记录到
+// CurrentLocaleStatus（一个文件的错误不影响其他语言）。新增的文件立即可用，
+// 删除的文件移除对应语言。SetMessageOverlay 的运行时覆盖始终优先于文件内容。
+// 文件翻译加载到默认 App，NewApp 创建的 App 不受影响
+//
+// 使用方式：
+//
+//	watcher, err := web.WatchLocales("locales")
+//	defer watcher.Close()
+//	web.LocalizeMessage(ctx, "welcome")
+func WatchLocales(dir string) (*LocaleWatcher, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("本地化目录不存在: %s", dir)
+	}
+	localeStatusMu.Lock()
+	localeDir = dir
+	localeStatusMu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		_ = loadLocaleFile(file) // 错误已记录，其他文件继续加载
+	}
+
+	w := &LocaleWatcher{dir: dir, timers: make(map[string]*time.Timer)}
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	if err := w.watcher.Add(dir); err != nil {
+		w.watcher.Close()
+		return nil, fmt.Errorf("添加目录监听失败: %w", err)
+	}
+	go w.watch()
+	log.Infof("[I18n] 已加载本地化目录 %s（%d 个文件），监听变化", dir, len(files))
+	return w, nil
+}
+
+// Close 停止监听（已加载的翻译保留）
+func (w *LocaleWatcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	for _, t := range w.timers {
+		t.Stop()
+	}
+	w.mu.Unlock()
+	return w.watcher.Close()
+}
+
+func (w *LocaleWatcher) watch() {
+	const debounce = 100 * time.Millisecond
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Ext(event.Name) != ".toml" || event.Op == fsnotify.Chmod {
+				continue
+			}
+			path := event.Name
+			w.mu.Lock()
+			if t := w.timers[path]; t != nil {
+				t.Stop()
+			}
+			w.timers[path] = time.AfterFunc(debounce, func() { w.reload(path) })
+			w.mu.Unlock()
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("[I18n] 本地化目录监听错误: %v", err)
+		}
+	}
+}
+
+// reload 按文件当前状态重新加载或移除
+func (w *LocaleWatcher) reload(path string) {
+	w.mu.Lock()
+	delete(w.timers, path)
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		removeLocaleFile(path)
+		return
+	}
+	if loadLocaleFile(path) == nil {
+		log.Infof("[I18n] 本地化文件 %s 已重新加载", path)
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watchTestLocales 在临时目录中写入本地化文件并开始监听，测试结束时清理文件翻译
+func watchTestLocales(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	watcher, err := WatchLocales(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		watcher.Close()
+		for _, lang := range defaultApp.messages.fileLanguages() {
+			defaultApp.messages.setFile(lang, nil)
+		}
+		localeStatusMu.Lock()
+		localeFileErrs = map[string]LocaleFileError{}
+		localeFileLangs = map[string]string{}
+		localeStatusMu.Unlock()
+	})
+	return dir
+}
+
+func localized(lang, key string) string {
+	return LocalizeMessage(WithLocale(context.Background(), Locale{Language: lang}), key)
+}
+
+func TestLocaleWatch_EditReload(t *testing.T) {
+	dir := watchTestLocales(t, map[string]string{
+		"zh-CN.toml": "welcome = \"欢迎\"\n[order]\npaid = \"已支付\"\n",
+	})
+	assert.Equal(t, "欢迎", localized("zh-CN", "welcome"))
+	assert.Equal(t, "已支付", localized("zh-CN", "order.paid"))
+	assert.Equal(t, "成功", localized("zh-CN", MsgSuccess), "文件之外的内置翻译仍然可用")
+
+	SetMessageOverlay("zh-CN", "order.paid", "付款成功")
+	t.Cleanup(func() { ClearMessageOverlay("zh-CN", "order.paid") })
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zh-CN.toml"), []byte("welcome = \"欢迎回来\"\n[order]\npaid = \"支付完成\"\n"), 0o644))
+	require.Eventually(t, func() bool { return localized("zh-CN", "welcome") == "欢迎回来" }, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "付款成功", localized("zh-CN", "order.paid"), "运行时覆盖优先于重新加载的文件")
+
+	ClearMessageOverlay("zh-CN", "order.paid")
+	assert.Equal(t, "支付完成", localized("zh-CN", "order.paid"))
+}
+
+func TestLocaleWatch_BadFileIsolated(t *testing.T) {
+	dir := watchTestLocales(t, map[string]string{
+		"zh-CN.toml": "welcome = \"欢迎\"\n",
+		"en-US.toml": "welcome = \"Welcome\"\n",
+		"fr-FR.toml": "welcome = \"Bienvenue\"\nbroken = \n",
+	})
+	assert.Equal(t, "Welcome", localized("en-US", "welcome"), "一个文件有错不影响其他语言")
+	status := CurrentLocaleStatus()
+	require.Len(t, status.Errors, 1)
+	assert.Equal(t, "fr-FR.toml", status.Errors[0].File)
+	assert.Equal(t, 2, status.Errors[0].Line)
+	assert.Equal(t, []string{"en-US", "zh-CN"}, status.Languages)
+
+	// 改坏已加载的文件：保留之前的翻译
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zh-CN.toml"), []byte("welcome = \"欢迎回来\n"), 0o644))
+	require.Eventually(t, func() bool { return len(CurrentLocaleStatus().Errors) == 2 }, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "欢迎", localized("zh-CN", "welcome"))
+
+	// 修好之后错误清除
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr-FR.toml"), []byte("welcome = \"Bienvenue\"\n"), 0o644))
+	require.Eventually(t, func() bool { return localized("fr-FR", "welcome") == "Bienvenue" }, 3*time.Second, 20*time.Millisecond)
+	status = CurrentLocaleStatus()
+	require.Len(t, status.Errors, 1)
+	assert.Equal(t, "zh-CN.toml", status.Errors[0].File)
+}
+
+func TestLocaleWatch_AddRemoveLanguage(t *testing.T) {
+	dir := watchTestLocales(t, map[string]string{"zh-CN.toml": "welcome = \"欢迎\"\n"})
+	assert.False(t, defaultApp.messages.has("ja-JP"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ja-JP.toml"), []byte("welcome = \"ようこそ\"\n"), 0o644))
+	require.Eventually(t, func() bool { return localized("ja-JP", "welcome") == "ようこそ" }, 3*time.Second, 20*time.Millisecond)
+	assert.True(t, defaultApp.messages.has("ja"), "新语言参与请求语言协商")
+	assert.Contains(t, CurrentLocaleStatus().Languages, "ja-JP")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "ja-JP.toml")))
+	require.Eventually(t, func() bool { return !defaultApp.messages.has("ja-JP") }, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "欢迎", localized("ja-JP", "welcome"), "删除后回退到默认语言")
+	assert.NotContains(t, CurrentLocaleStatus().Languages, "ja-JP")
+}
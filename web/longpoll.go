@@ -0,0 +1,115 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/ws"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// LongPollSinceParam 长轮询请求中上次收到的序号参数
+const LongPollSinceParam = "since"
+
+// LongPollConfig 长轮询端点配置
+type LongPollConfig struct {
+	Timeout         time.Duration                                             // 没有消息时最长等待时间，默认 10 秒（需小于服务端写超时）
+	MaxBatch        int                                                       // 一次最多返回的消息数，默认 100
+	MaxPollsPerUser int                                                       // 同一用户并发长轮询上限，默认 2，负数表示不限制
+	ReplaySize      int                                                       // 重放缓冲大小（Hub 尚未启用重放时生效），默认 1024
+	Rooms           func(ctx context.Context, c *app.RequestContext) []string // 当前用户所在的房间（可选），房间消息只投递给这些房间的成员
+}
+
+func (cfg LongPollConfig) withDefaults() LongPollConfig {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 100
+	}
+	if cfg.MaxPollsPerUser == 0 {
+		cfg.MaxPollsPerUser = 2
+	}
+	return cfg
+}
+
+// RegisterLongPoll 为 WebSocket Hub 注册长轮询端点，供无法建立 WebSocket 的客户端（代理、防火墙拦截）使用
+//
+//   - GET path?since=<seq>：等待 since 之后发给当前用户（其所在房间、广播）的消息，有消息立即返回，
+//     超时返回空列表；响应 data 为 ws.PollResult，客户端下次轮询传入其中的 seq（首次轮询不传 since）。
+//     gap 为 true 表示部分消息已经不在重放缓冲中，客户端应重新拉取完整状态
+//   - POST path：请求体作为一条消息交给 hub.OnMessage 处理，与 WebSocket 消息走同一路径；
+//     处理中 conn.Send 的回复由下一次 GET 取回
+//
+// 两个端点都要求已认证（jwt.GetUserID 为空时返回 401），需挂在 JWT 中间件之后；
+// 同一用户并发的 GET 超过 MaxPollsPerUser 时返回 429
+//
+// 使用方式：
+//
+//	hub := ws.NewHub()
+//	go hub.Run()
+//	api := h.Group("/api", jwt.Middleware())
+//	web.RegisterLongPoll(api, hub, "/events/poll", web.LongPollConfig{
+//	    Rooms: func(ctx context.Context, c *app.RequestContext) []string {
+//	        return teamRooms(jwt.GetUserID(c))
+//	    },
+//	})
+func RegisterLongPoll(r route.IRoutes, hub *ws.Hub, path string, cfg LongPollConfig) {
+	cfg = cfg.withDefaults()
+	hub.EnableReplay(cfg.ReplaySize)
+
+	r.GET(path, WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		userID := jwt.GetUserID(c)
+		if userID == "" {
+			return UnauthorizedHTTP(MsgUnauthorized)
+		}
+		since := hub.LastSeq() // 首次轮询只等待之后的新消息
+		if s := c.Query(LongPollSinceParam); s != "" {
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return BadRequestHTTP("invalid " + LongPollSinceParam)
+			}
+			since = v
+		}
+		opts := ws.PollOptions{
+			UserID:   userID,
+			Since:    since,
+			Timeout:  cfg.Timeout,
+			MaxBatch: cfg.MaxBatch,
+			MaxPolls: max(cfg.MaxPollsPerUser, 0),
+		}
+		if cfg.Rooms != nil {
+			opts.Rooms = cfg.Rooms(ctx, c)
+		}
+
+		result, err := hub.Poll(ctx, opts)
+		if errors.Is(err, ws.ErrTooManyPolls) {
+			c.JSON(http.StatusTooManyRequests, Fail(http.StatusTooManyRequests, MsgRateLimited))
+			c.Abort()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if result.Messages == nil {
+			result.Messages = []ws.Message{}
+		}
+		c.JSON(http.StatusOK, Success(result))
+		return nil
+	}))
+
+	r.POST(path, WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		userID := jwt.GetUserID(c)
+		if userID == "" {
+			return UnauthorizedHTTP(MsgUnauthorized)
+		}
+		hub.HandleMessage(ws.NewPollConnection(hub, userID), append([]byte(nil), c.Request.Body()...))
+		c.JSON(http.StatusOK, Success(nil))
+		return nil
+	}))
+}
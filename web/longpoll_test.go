@@ -0,0 +1,105 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/ws"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLongPollEngine(t *testing.T, hub *ws.Hub, cfg LongPollConfig) *route.Engine {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "longpoll-secret"
+	require.NoError(t, jwt.Init(conf))
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	RegisterLongPoll(engine.Group("/api", jwt.Middleware()), hub, "/events", cfg)
+	return engine
+}
+
+func decodePoll(t *testing.T, body []byte) ws.PollResult {
+	var resp struct {
+		Code int
+		Data ws.PollResult
+	}
+	require.NoError(t, json.Unmarshal(body, &resp))
+	require.Equal(t, 0, resp.Code)
+	return resp.Data
+}
+
+func TestLongPoll_ReceiveAndSend(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	hub.OnMessage(func(conn *ws.Connection, msg []byte) {
+		conn.Send(append([]byte(`{"echo":`), append(msg, '}')...))
+	})
+	engine := newLongPollEngine(t, hub, LongPollConfig{
+		Timeout: 5 * time.Second,
+		Rooms: func(ctx context.Context, c *app.RequestContext) []string {
+			return []string{"team"}
+		},
+	})
+	token := ownershipToken(t, "alice")
+
+	done := make(chan []byte, 1)
+	go func() {
+		done <- ut.PerformRequest(engine, "GET", "/api/events?since=0", nil, token).Body.Bytes()
+	}()
+	hub.BroadcastRoom("team", []byte(`{"type":"hello"}`))
+
+	result := decodePoll(t, <-done)
+	require.Len(t, result.Messages, 1)
+	assert.JSONEq(t, `{"type":"hello"}`, string(result.Messages[0].Data))
+	assert.Equal(t, uint64(1), result.Seq)
+
+	// 上行消息交给 OnMessage，回复由下一次轮询取回
+	w := ut.PerformRequest(engine, "POST", "/api/events", &ut.Body{Body: bytes.NewBufferString(`"ping"`), Len: 6}, token)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	w = ut.PerformRequest(engine, "GET", "/api/events?since=1", nil, token)
+	result = decodePoll(t, w.Body.Bytes())
+	require.Len(t, result.Messages, 1)
+	assert.JSONEq(t, `{"echo":"ping"}`, string(result.Messages[0].Data))
+	assert.Equal(t, uint64(2), result.Seq)
+}
+
+func TestLongPoll_TimeoutAndErrors(t *testing.T) {
+	hub := ws.NewHub()
+	engine := newLongPollEngine(t, hub, LongPollConfig{Timeout: 30 * time.Millisecond, MaxPollsPerUser: 1})
+	token := ownershipToken(t, "alice")
+
+	w := ut.PerformRequest(engine, "GET", "/api/events", nil, token)
+	require.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"messages":[],"seq":0}`, string(mustField(t, w.Body.Bytes(), "data")))
+
+	w = ut.PerformRequest(engine, "GET", "/api/events?since=abc", nil, token)
+	assert.Equal(t, 400, w.Code)
+
+	w = ut.PerformRequest(engine, "GET", "/api/events", nil)
+	assert.Equal(t, 401, w.Code)
+
+	// 同一用户并发轮询超过上限
+	slow := newLongPollEngine(t, hub, LongPollConfig{Timeout: time.Second, MaxPollsPerUser: 1})
+	codes := make(chan int, 2)
+	for range 2 {
+		go func() { codes <- ut.PerformRequest(slow, "GET", "/api/events", nil, token).Code }()
+	}
+	assert.Equal(t, 429, <-codes)
+	hub.SendToUser("alice", []byte("x"))
+	assert.Equal(t, 200, <-codes)
+}
+
+func mustField(t *testing.T, body []byte, key string) json.RawMessage {
+	var m map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &m))
+	return m[key]
+}
@@ -0,0 +1,436 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// maintenanceRedisKey 通过管理接口设置的计划窗口保存在 Redis 中，重启后恢复
+const maintenanceRedisKey = "web:maintenance:window"
+
+// MaintenanceConfig 维护模式配置
+//
+// 手动模式（enabled = true）立即生效；计划窗口到点自动进入维护，结束后自动恢复。
+// 窗口开始前 announceBefore 秒内，所有响应带 X-Maintenance-Scheduled 头（窗口开始时间）。
+// 维护期间 allowlist 中的 IP、携带正确 X-Maintenance-Bypass 头的请求以及
+// PriorityCritical 路由（健康检查/指标）照常放行，其余请求返回 503 + Retry-After。
+//
+// Example:
+//
+//	[web.maintenance]
+//	message = "系统升级中"
+//	allowlist = ["10.0.0.0/8", "127.0.0.1"]
+//	bypassToken = "ops-secret"
+//	start = "2026-10-20T02:00:00+08:00"     # 一次性窗口（RFC3339）
+//	end = "2026-10-20T04:00:00+08:00"
+//	recurring = ["Sun 02:00-04:00"]         # 每周重复窗口，可用 "Sat,Sun 23:00-01:00" 跨午夜
+//	announceBefore = 86400                  # 提前公告（秒）
+type MaintenanceConfig struct {
+	Enabled        bool     `toml:"enabled" reload:"hot"`         // 手动开启维护模式（支持热更新）
+	Message        string   `toml:"message"`                      // 维护提示信息
+	RetryAfter     int      `toml:"retryAfter"`                   // 手动模式的 Retry-After（秒），默认 300
+	Allowlist      []string `toml:"allowlist"`                    // 维护期间放行的 IP 或 CIDR
+	BypassToken    string   `toml:"bypassToken" sensitive:"true"` // X-Maintenance-Bypass 请求头等于此值时放行
+	Start          string   `toml:"start"`                        // 一次性窗口开始时间（RFC3339）
+	End            string   `toml:"end"`                          // 一次性窗口结束时间（RFC3339）
+	Recurring      []string `toml:"recurring"`                    // 每周重复窗口
+	AnnounceBefore int      `toml:"announceBefore"`               // 提前公告时间（秒），默认 86400
+}
+
+// MaintenanceWindow 维护窗口
+type MaintenanceWindow struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Message string    `json:"message,omitempty"`
+}
+
+// weeklyWindow 每周重复窗口（相对当天零点的偏移）
+type weeklyWindow struct {
+	days     [7]bool
+	start    time.Duration
+	duration time.Duration
+}
+
+// Maintenance 维护模式控制器
+type Maintenance struct {
+	config    MaintenanceConfig
+	clock     common.Clock
+	announce  time.Duration
+	allowIPs  []net.IP
+	allowNets []*net.IPNet
+	recurring []weeklyWindow
+	manual    atomic.Bool
+	window    atomic.Pointer[MaintenanceWindow] // 一次性窗口
+}
+
+// NewMaintenance 创建维护模式控制器
+//
+// clock 为 nil 时使用系统时钟；测试中注入 common.FakeClock 可快进完整的窗口生命周期
+func NewMaintenance(config MaintenanceConfig, clock common.Clock) (*Maintenance, error) {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 300
+	}
+	if config.AnnounceBefore <= 0 {
+		config.AnnounceBefore = 86400
+	}
+
+	m := &Maintenance{
+		config:   config,
+		clock:    clock,
+		announce: time.Duration(config.AnnounceBefore) * time.Second,
+	}
+	m.manual.Store(config.Enabled)
+
+	for _, entry := range config.Allowlist {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			m.allowNets = append(m.allowNets, ipNet)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			m.allowIPs = append(m.allowIPs, ip)
+		} else {
+			return nil, fmt.Errorf("无效的维护放行地址: %s", entry)
+		}
+	}
+
+	for _, spec := range config.Recurring {
+		w, err := parseWeeklyWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		m.recurring = append(m.recurring, w)
+	}
+
+	if config.Start != "" || config.End != "" {
+		start, err := time.Parse(time.RFC3339, config.Start)
+		if err != nil {
+			return nil, fmt.Errorf("无效的维护开始时间: %w", err)
+		}
+		end, err := time.Parse(time.RFC3339, config.End)
+		if err != nil {
+			return nil, fmt.Errorf("无效的维护结束时间: %w", err)
+		}
+		if err := m.setWindow(MaintenanceWindow{Start: start, End: end, Message: config.Message}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// parseWeeklyWindow 解析 "Sun 02:00-04:00"、"Mon,Thu 23:00-01:00" 形式的每周窗口
+func parseWeeklyWindow(spec string) (weeklyWindow, error) {
+	var w weeklyWindow
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return w, fmt.Errorf("无效的每周维护窗口: %q", spec)
+	}
+
+	for _, day := range strings.Split(fields[0], ",") {
+		idx := -1
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(day, d.String()[:3]) {
+				idx = int(d)
+			}
+		}
+		if idx < 0 {
+			return w, fmt.Errorf("无效的每周维护窗口: %q（未知的星期 %s）", spec, day)
+		}
+		w.days[idx] = true
+	}
+
+	bounds := strings.Split(fields[1], "-")
+	if len(bounds) != 2 {
+		return w, fmt.Errorf("无效的每周维护窗口: %q", spec)
+	}
+	start, err := parseClock(bounds[0])
+	if err != nil {
+		return w, fmt.Errorf("无效的每周维护窗口: %q: %w", spec, err)
+	}
+	end, err := parseClock(bounds[1])
+	if err != nil {
+		return w, fmt.Errorf("无效的每周维护窗口: %q: %w", spec, err)
+	}
+	if end <= start {
+		end += 24 * time.Hour // 跨午夜
+	}
+	w.start = start
+	w.duration = end - start
+	return w, nil
+}
+
+// parseClock 解析 HH:MM
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// next 返回 now 时仍未结束的最近一次出现
+func (w weeklyWindow) next(now time.Time) (time.Time, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// 从前一天开始查找，覆盖跨午夜的窗口
+	for i := -1; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		start := day.Add(w.start)
+		end := start.Add(w.duration)
+		if end.After(now) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// SetEnabled 手动开启/关闭维护模式
+func (m *Maintenance) SetEnabled(enabled bool) {
+	m.manual.Store(enabled)
+}
+
+// Schedule 设置一次性维护窗口（覆盖已有窗口），配置了 Redis 时持久化
+func (m *Maintenance) Schedule(ctx context.Context, w MaintenanceWindow) error {
+	if !w.End.After(m.clock.Now()) {
+		return fmt.Errorf("维护窗口已结束")
+	}
+	if err := m.setWindow(w); err != nil {
+		return err
+	}
+	if cache.Client != nil {
+		data, _ := json.Marshal(w)
+		if err := cache.Client.Set(ctx, maintenanceRedisKey, data, w.End.Sub(m.clock.Now())).Err(); err != nil {
+			return fmt.Errorf("保存维护窗口失败: %w", err)
+		}
+	}
+	log.Infof("[Maintenance] 已计划维护窗口: %s ~ %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+	return nil
+}
+
+// ClearSchedule 清除一次性维护窗口
+func (m *Maintenance) ClearSchedule(ctx context.Context) {
+	m.window.Store(nil)
+	m.unpersist(ctx)
+}
+
+// unpersist 删除 Redis 中保存的窗口
+func (m *Maintenance) unpersist(ctx context.Context) {
+	if cache.Client != nil {
+		if err := cache.Client.Del(ctx, maintenanceRedisKey).Err(); err != nil {
+			log.Errorf("[Maintenance] 删除维护窗口失败: %v", err)
+		}
+	}
+}
+
+// restore 从 Redis 恢复通过管理接口设置的窗口
+func (m *Maintenance) restore(ctx context.Context) {
+	if cache.Client == nil {
+		return
+	}
+	data, err := cache.Client.Get(ctx, maintenanceRedisKey).Bytes()
+	if err != nil {
+		return
+	}
+	var w MaintenanceWindow
+	if err := json.Unmarshal(data, &w); err != nil {
+		log.Errorf("[Maintenance] 维护窗口数据无效: %v", err)
+		return
+	}
+	if err := m.setWindow(w); err == nil {
+		log.Infof("[Maintenance] 已恢复维护窗口: %s ~ %s", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+	}
+}
+
+func (m *Maintenance) setWindow(w MaintenanceWindow) error {
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("维护结束时间必须晚于开始时间")
+	}
+	m.window.Store(&w)
+	return nil
+}
+
+// Next 返回当前生效或即将开始的最近一个窗口
+//
+// 已结束的一次性窗口在此被清除
+func (m *Maintenance) Next() (MaintenanceWindow, bool) {
+	now := m.clock.Now()
+	var (
+		best  MaintenanceWindow
+		found bool
+	)
+	if w := m.window.Load(); w != nil {
+		if !w.End.After(now) {
+			if m.window.CompareAndSwap(w, nil) {
+				m.unpersist(context.Background())
+				log.Infof("[Maintenance] 维护窗口已结束，恢复正常服务")
+			}
+		} else {
+			best, found = *w, true
+		}
+	}
+	for _, r := range m.recurring {
+		start, end := r.next(now)
+		if end.IsZero() {
+			continue
+		}
+		if !found || start.Before(best.Start) {
+			best, found = MaintenanceWindow{Start: start, End: end, Message: m.config.Message}, true
+		}
+	}
+	return best, found
+}
+
+// bypass 维护期间是否放行
+func (m *Maintenance) bypass(c *app.RequestContext) bool {
+	if routePriority(c) == PriorityCritical {
+		return true
+	}
+	if m.config.BypassToken != "" && string(c.GetHeader("X-Maintenance-Bypass")) == m.config.BypassToken {
+		return true
+	}
+	if len(m.allowIPs) == 0 && len(m.allowNets) == 0 {
+		return false
+	}
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range m.allowIPs {
+		if allowed.Equal(ip) {
+			return true
+		}
+	}
+	for _, ipNet := range m.allowNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware 返回维护模式中间件
+func (m *Maintenance) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		now := m.clock.Now()
+		message := m.config.Message
+		retryAfter := -1
+
+		if m.manual.Load() {
+			retryAfter = m.config.RetryAfter
+		} else if w, ok := m.Next(); ok {
+			if !now.Before(w.Start) {
+				retryAfter = int(math.Ceil(w.End.Sub(now).Seconds()))
+				if w.Message != "" {
+					message = w.Message
+				}
+			} else if w.Start.Sub(now) <= m.announce {
+				c.Header("X-Maintenance-Scheduled", w.Start.Format(time.RFC3339))
+			}
+		}
+
+		if retryAfter < 0 || m.bypass(c) {
+			c.Next(ctx)
+			return
+		}
+
+		if message == "" {
+			message = "Service under maintenance"
+		}
+		result := Fail(503, message)
+		result.TraceID = middleware.GetRequestID(c)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(consts.StatusServiceUnavailable, result)
+	}
+}
+
+var (
+	globalMaintenance *Maintenance
+)
+
+// InitMaintenance 初始化全局维护模式控制器，并从 Redis 恢复计划窗口
+func InitMaintenance(config MaintenanceConfig, clock common.Clock) error {
+	m, err := NewMaintenance(config, clock)
+	if err != nil {
+		return err
+	}
+	m.restore(context.Background())
+	globalMaintenance = m
+	return nil
+}
+
+// SetMaintenanceEnabled 全局维护模式开关
+func SetMaintenanceEnabled(enabled bool) {
+	if globalMaintenance != nil {
+		globalMaintenance.SetEnabled(enabled)
+		log.Warnf("[Maintenance] 手动维护模式: enabled=%v", enabled)
+	}
+}
+
+// MaintenanceSchedule 当前生效或即将开始的维护窗口（未计划时返回 nil）
+func MaintenanceSchedule() *MaintenanceWindow {
+	if globalMaintenance == nil {
+		return nil
+	}
+	if w, ok := globalMaintenance.Next(); ok {
+		return &w
+	}
+	return nil
+}
+
+// MaintenanceMiddleware 全局维护模式中间件（未初始化时直接放行）
+func MaintenanceMiddleware() app.HandlerFunc {
+	if globalMaintenance == nil {
+		return func(ctx context.Context, c *app.RequestContext) {
+			c.Next(ctx)
+		}
+	}
+	return globalMaintenance.Middleware()
+}
+
+// MaintenanceAdminHandler 维护窗口管理接口
+//
+// POST 提交 {"start": "...", "end": "...", "message": "..."} 设置窗口，DELETE 清除窗口。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/maintenance", web.MaintenanceAdminHandler())
+func MaintenanceAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if globalMaintenance == nil {
+			panic(NewHTTPException(503, 503, "Maintenance not initialized"))
+		}
+
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut:
+			var w MaintenanceWindow
+			if err := json.Unmarshal(c.Request.Body(), &w); err != nil {
+				panic(BadRequestHTTP("维护窗口格式错误"))
+			}
+			if err := globalMaintenance.Schedule(ctx, w); err != nil {
+				panic(BadRequestHTTP(err.Error()))
+			}
+			c.JSON(consts.StatusOK, Success(w))
+		case consts.MethodDelete:
+			globalMaintenance.ClearSchedule(ctx)
+			c.JSON(consts.StatusOK, Success(nil))
+		default:
+			c.JSON(consts.StatusOK, Success(MaintenanceSchedule()))
+		}
+	}
+}
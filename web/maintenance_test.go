@@ -0,0 +1,102 @@
+package web
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMaintenanceEngine(m *Maintenance) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(m.Middleware())
+	ok := func(ctx context.Context, c *app.RequestContext) { c.JSON(200, Success(nil)) }
+	engine.GET("/api", ok)
+	engine.GET("/health", Priority(PriorityCritical), ok)
+	return engine
+}
+
+func TestMaintenance_ScheduleLifecycle(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC))
+	m, err := NewMaintenance(MaintenanceConfig{BypassToken: "ops", AnnounceBefore: 7200}, clock)
+	require.NoError(t, err)
+	engine := newMaintenanceEngine(m)
+
+	start := clock.Now().Add(3 * time.Hour)
+	require.NoError(t, m.Schedule(context.Background(), MaintenanceWindow{Start: start, End: start.Add(2 * time.Hour), Message: "升级中"}))
+
+	// 公告期之前：无任何标记
+	w := ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Empty(t, w.Result().Header.Peek("X-Maintenance-Scheduled"))
+
+	// 公告期内：带开始时间头
+	clock.Advance(time.Hour)
+	w = ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Equal(t, start.Format(time.RFC3339), string(w.Result().Header.Peek("X-Maintenance-Scheduled")))
+
+	// 窗口内：503，Retry-After 取窗口剩余时间
+	clock.Advance(2*time.Hour + 30*time.Minute)
+	w = ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 503, w.Result().StatusCode())
+	assert.Equal(t, "5400", string(w.Result().Header.Peek("Retry-After")))
+	assert.Contains(t, string(w.Result().Body()), "升级中")
+
+	// 放行：健康检查与 bypass 头
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/health", nil).Result().StatusCode())
+	w = ut.PerformRequest(engine, "GET", "/api", nil, ut.Header{Key: "X-Maintenance-Bypass", Value: "ops"})
+	assert.Equal(t, 200, w.Result().StatusCode())
+
+	// 窗口结束：恢复服务并清除计划
+	clock.Advance(2 * time.Hour)
+	w = ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Empty(t, w.Result().Header.Peek("X-Maintenance-Scheduled"))
+	_, ok := m.Next()
+	assert.False(t, ok)
+}
+
+func TestMaintenance_Recurring(t *testing.T) {
+	// 2026-10-17 是周六
+	clock := common.NewFakeClock(time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC))
+	m, err := NewMaintenance(MaintenanceConfig{Recurring: []string{"Sat 23:00-01:00"}}, clock)
+	require.NoError(t, err)
+	engine := newMaintenanceEngine(m)
+
+	next, ok := m.Next()
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC), next.Start)
+	assert.Equal(t, time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC), next.End)
+
+	// 跨午夜的窗口仍然生效
+	clock.Advance(2*time.Hour + 30*time.Minute)
+	w := ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 503, w.Result().StatusCode())
+	assert.Equal(t, "1800", string(w.Result().Header.Peek("Retry-After")))
+
+	// 结束后计划顺延到下周
+	clock.Advance(time.Hour)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api", nil).Result().StatusCode())
+	next, _ = m.Next()
+	assert.Equal(t, time.Date(2026, 10, 24, 23, 0, 0, 0, time.UTC), next.Start)
+}
+
+func TestMaintenance_InvalidConfig(t *testing.T) {
+	for _, conf := range []MaintenanceConfig{
+		{Recurring: []string{"Funday 02:00-04:00"}},
+		{Recurring: []string{"Sun 2am"}},
+		{Allowlist: []string{"not-an-ip"}},
+		{Start: "2026-10-20T04:00:00Z", End: "2026-10-20T02:00:00Z"},
+	} {
+		_, err := NewMaintenance(conf, nil)
+		assert.Error(t, err, "%+v", conf)
+	}
+}
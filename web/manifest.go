@@ -0,0 +1,253 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CenJIl/base/web/apischema"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// RouteManifestFormat 路由清单的格式版本（格式不兼容地变化时递增，读取更高版本的清单时报错）
+const RouteManifestFormat = 1
+
+// EnvManifestUpdate 设置为 1 时，CheckAPIChanges 在检查通过后把当前清单写入基线文件（发布时更新基线）
+const EnvManifestUpdate = "API_MANIFEST_UPDATE"
+
+// RouteManifest 路由清单：对外 API 的规范化描述，用于比较两个版本之间的变化
+//
+// 只包含通过 web.Router 注册的路由。请求与响应类型来自路由上声明的 web.Example（与契约测试、
+// TypeScript 客户端共用），按 apischema.SchemaOf 展开；没有声明示例的路由不比较请求与响应。
+// 路由按路径与方法排序，对象字段按名称排序，同一份路由表导出的 JSON 完全一致，适合提交到代码仓库
+type RouteManifest struct {
+	Format     int             `json:"format"`
+	APIVersion string          `json:"apiVersion,omitempty"` // 应用的 API 版本（主版本号变化时允许不兼容变更）
+	Routes     []ManifestRoute `json:"routes"`
+}
+
+// ManifestRoute 清单中的一条路由
+type ManifestRoute struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Auth       string            `json:"auth,omitempty"`       // 生效的认证声明（required / public）
+	Deprecated string            `json:"deprecated,omitempty"` // 废弃说明
+	Raw        bool              `json:"raw,omitempty"`        // 原始路由（不使用统一响应格式）
+	Request    *apischema.Schema `json:"request,omitempty"`    // 请求参数（请求体或查询参数）
+	Response   *apischema.Schema `json:"response,omitempty"`   // 成功响应的 data
+}
+
+// name 路由描述，如 "GET /api/orders/:id"
+func (r ManifestRoute) name() string {
+	return r.Method + " " + r.Path
+}
+
+// ExportRouteManifest 根据路由表导出路由清单
+//
+// 使用方式（在 CI 执行的测试中，见 CheckAPIChanges）：
+//
+//	routes, _ := web.ValidateRoutes(newServer().Engine)
+//	manifest := web.ExportRouteManifest(routes, "2.3.0")
+//	os.WriteFile("api/manifest.json", manifest.JSON(), 0644)
+func ExportRouteManifest(routes []RouteInfo, apiVersion string) *RouteManifest {
+	m := &RouteManifest{Format: RouteManifestFormat, APIVersion: apiVersion, Routes: []ManifestRoute{}}
+	for _, r := range routes {
+		if r.Direct {
+			continue
+		}
+		mr := ManifestRoute{Method: r.Method, Path: r.Path, Auth: r.Auth, Deprecated: r.Deprecated, Raw: r.Raw != nil}
+		if mr.Auth == AuthUnspecified {
+			mr.Auth = r.GroupAuth
+		}
+		for _, ex := range r.Examples {
+			if mr.Request == nil && ex.Request != nil {
+				mr.Request = apischema.SchemaOf(reflect.TypeOf(ex.Request))
+				if r.strictBinding() {
+					apischema.Close(mr.Request)
+				}
+			}
+			if mr.Response == nil && ex.Response != nil && (ex.Status == 0 || ex.Status < 400) {
+				mr.Response = responseSchema(ex.Response)
+			}
+		}
+		m.Routes = append(m.Routes, mr)
+	}
+	sort.SliceStable(m.Routes, func(i, j int) bool {
+		if m.Routes[i].Path != m.Routes[j].Path {
+			return m.Routes[i].Path < m.Routes[j].Path
+		}
+		return m.Routes[i].Method < m.Routes[j].Method
+	})
+	return m
+}
+
+// responseSchema 示例响应 data 的结构；分页数据的 items 按示例中的元素类型展开
+func responseSchema(v any) *apischema.Schema {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	s := apischema.SchemaOf(rv.Type())
+	var items any
+	switch data := rv.Interface().(type) {
+	case PagedData:
+		items = data.Items
+	case CursorPagedData:
+		items = data.Items
+	default:
+		return s
+	}
+	if items != nil {
+		s.Properties["items"] = apischema.SchemaOf(reflect.TypeOf(items))
+	}
+	return s
+}
+
+// JSON 规范化的 JSON 编码（两空格缩进，结尾换行）
+func (m *RouteManifest) JSON() []byte {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return append(data, '\n')
+}
+
+// ParseRouteManifest 解析路由清单（格式版本高于当前支持的版本时报错）
+func ParseRouteManifest(data []byte) (*RouteManifest, error) {
+	var m RouteManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("解析路由清单失败: %w", err)
+	}
+	if m.Format <= 0 || m.Format > RouteManifestFormat {
+		return nil, fmt.Errorf("不支持的路由清单格式版本 %d（当前支持 %d）", m.Format, RouteManifestFormat)
+	}
+	return &m, nil
+}
+
+// CheckAPIChanges 比较当前路由清单与基线文件，存在未确认的不兼容变更时返回错误（CI 中执行）
+//
+// 不兼容变更在以下情况下放行：
+//   - 当前清单的 apiVersion 主版本号与基线不同（已升级主版本）
+//   - 确认文件 ackPath 中逐行列出了该变更的 ID（检查失败时输出需要添加的行，# 开头的行为注释）
+//
+// 环境变量 API_MANIFEST_UPDATE=1 时，检查通过后把当前清单写入基线文件（发布时执行一次并提交）。
+// 基线文件不存在时返回错误，首次使用同样通过 API_MANIFEST_UPDATE=1 生成
+//
+// 使用方式：
+//
+//	func TestAPIChanges(t *testing.T) {
+//	    routes, _ := web.ValidateRoutes(newServer().Engine)
+//	    changelog, err := web.CheckAPIChanges(web.ExportRouteManifest(routes, version.API),
+//	        "api/manifest.json", "api/breaking.ack")
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//	    t.Log(changelog)
+//	}
+func CheckAPIChanges(current *RouteManifest, baselinePath, ackPath string) (*APIChangelog, error) {
+	update := os.Getenv(EnvManifestUpdate) == "1"
+	data, err := os.ReadFile(baselinePath)
+	if errors.Is(err, fs.ErrNotExist) && update {
+		return &APIChangelog{To: current.APIVersion, Changes: []RouteChange{}}, writeManifest(baselinePath, current)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取基线路由清单失败（首次使用时设置 %s=1 生成）: %w", EnvManifestUpdate, err)
+	}
+	baseline, err := ParseRouteManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", baselinePath, err)
+	}
+
+	changelog := DiffRouteManifests(baseline, current)
+	if changelog.Breaking > 0 && !majorBumped(baseline.APIVersion, current.APIVersion) {
+		acked, err := readAcknowledged(ackPath)
+		if err != nil {
+			return changelog, err
+		}
+		var missing []string
+		for _, c := range changelog.Changes {
+			if c.Breaking && !acked[c.ID()] {
+				missing = append(missing, c.ID())
+			}
+		}
+		if len(missing) > 0 {
+			return changelog, fmt.Errorf("发现 %d 处未确认的不兼容 API 变更，请升级 apiVersion 的主版本号，或确认后把以下行加入 %s：\n%s",
+				len(missing), ackPath, strings.Join(missing, "\n"))
+		}
+	}
+	if update {
+		return changelog, writeManifest(baselinePath, current)
+	}
+	return changelog, nil
+}
+
+func writeManifest(path string, m *RouteManifest) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, m.JSON(), 0644)
+}
+
+// majorBumped 主版本号是否变化（任一版本为空时视为未变化）
+func majorBumped(from, to string) bool {
+	major := func(v string) string {
+		major, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), ".")
+		return major
+	}
+	return from != "" && to != "" && major(from) != major(to)
+}
+
+// readAcknowledged 确认文件中的变更 ID（文件不存在时为空）
+func readAcknowledged(path string) (map[string]bool, error) {
+	acked := map[string]bool{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return acked, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取变更确认文件失败: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			acked[line] = true
+		}
+	}
+	return acked, scanner.Err()
+}
+
+// DebugChangelogHandler 输出当前路由表相对基线清单的变更（内部调用方查看本次发布的 API 变化）
+//
+// baseline 通常是嵌入的上一个发布版本的清单，apiVersion 为当前的 API 版本；
+// 变更在第一次请求时根据启动时校验后的路由表计算。baseline 无法解析时 panic
+//
+// 使用方式：
+//
+//	//go:embed api/manifest.json
+//	var apiBaseline []byte
+//
+//	h.GET("/debug/changelog", web.DebugChangelogHandler(apiBaseline, version.API))
+func DebugChangelogHandler(baseline []byte, apiVersion string) app.HandlerFunc {
+	previous, err := ParseRouteManifest(baseline)
+	if err != nil {
+		panic(fmt.Errorf("DebugChangelogHandler: %w", err))
+	}
+	changelog := sync.OnceValue(func() *APIChangelog {
+		var routes []RouteInfo
+		if p := validatedRoutes.Load(); p != nil {
+			routes = *p
+		}
+		return DiffRouteManifests(previous, ExportRouteManifest(routes, apiVersion))
+	})
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, Success(changelog()))
+	}
+}
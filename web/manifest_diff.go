@@ -0,0 +1,257 @@
+package web
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/CenJIl/base/web/apischema"
+)
+
+// API 变更类型
+const (
+	ChangeRouteAdded   = "route_added"    // 新增路由
+	ChangeRouteRemoved = "route_removed"  // 删除路由（不兼容）
+	ChangeAuthTighten  = "auth_tightened" // 改为需要认证（不兼容）
+	ChangeAuthRelax    = "auth_relaxed"   // 改为公开访问
+	ChangeDeprecated   = "deprecated"     // 标记为废弃
+	ChangeRawChanged   = "raw_changed"    // 在统一响应格式与原始路由之间切换（不兼容）
+
+	ChangeRequestOptionalAdded = "request_optional_field_added" // 请求新增可选字段
+	ChangeRequestRequiredAdded = "request_required_field_added" // 请求新增必填字段（不兼容）
+	ChangeRequestFieldRemoved  = "request_field_removed"        // 请求删除字段（服务端不再读取）
+	ChangeRequestNowRequired   = "request_field_now_required"   // 请求字段改为必填（不兼容）
+	ChangeRequestNowOptional   = "request_field_now_optional"   // 请求字段改为可选
+	ChangeRequestTypeChanged   = "request_type_changed"         // 请求字段类型变化（不兼容）
+	ChangeRequestEnumAdded     = "request_enum_value_added"     // 请求枚举新增取值
+	ChangeRequestEnumRemoved   = "request_enum_value_removed"   // 请求枚举删除取值（不兼容）
+
+	ChangeResponseFieldAdded   = "response_field_added"        // 响应新增字段
+	ChangeResponseFieldRemoved = "response_field_removed"      // 响应删除字段（不兼容）
+	ChangeResponseNowOptional  = "response_field_now_optional" // 响应字段可能缺失或为 null（不兼容）
+	ChangeResponseNowRequired  = "response_field_now_required" // 响应字段总会出现
+	ChangeResponseTypeChanged  = "response_type_changed"       // 响应字段类型变化（不兼容）
+	ChangeResponseEnumAdded    = "response_enum_value_added"   // 响应枚举新增取值（不兼容，客户端可能无法处理）
+	ChangeResponseEnumRemoved  = "response_enum_value_removed" // 响应枚举删除取值
+)
+
+// breakingChanges 不兼容的变更类型：请求收窄、响应放宽都会让已有客户端出错
+var breakingChanges = map[string]bool{
+	ChangeRouteRemoved:         true,
+	ChangeAuthTighten:          true,
+	ChangeRawChanged:           true,
+	ChangeRequestRequiredAdded: true,
+	ChangeRequestNowRequired:   true,
+	ChangeRequestTypeChanged:   true,
+	ChangeRequestEnumRemoved:   true,
+	ChangeResponseFieldRemoved: true,
+	ChangeResponseNowOptional:  true,
+	ChangeResponseTypeChanged:  true,
+	ChangeResponseEnumAdded:    true,
+}
+
+// RouteChange 一处 API 变更
+type RouteChange struct {
+	Kind     string `json:"kind"`
+	Breaking bool   `json:"breaking"`
+	Route    string `json:"route"`           // 如 "GET /api/orders/:id"
+	Field    string `json:"field,omitempty"` // 字段路径，如 items[].sku（请求/响应整体为空）
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ID 变更的标识（写入确认文件）：类型 方法 路径 [字段]
+func (c RouteChange) ID() string {
+	id := c.Kind + " " + c.Route
+	if c.Field != "" {
+		id += " " + c.Field
+	}
+	return id
+}
+
+// APIChangelog 两个路由清单之间的变更（不兼容的变更在前，其余按路由排序）
+type APIChangelog struct {
+	From     string        `json:"from,omitempty"` // 基线的 apiVersion
+	To       string        `json:"to,omitempty"`   // 当前的 apiVersion
+	Breaking int           `json:"breaking"`       // 不兼容变更的数量
+	Changes  []RouteChange `json:"changes"`
+}
+
+// String 变更列表（每行一处，不兼容的变更标注 [breaking]）
+func (c *APIChangelog) String() string {
+	if len(c.Changes) == 0 {
+		return "API 没有变化"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "API 变更 %s -> %s：%d 处，其中不兼容 %d 处", c.From, c.To, len(c.Changes), c.Breaking)
+	for _, change := range c.Changes {
+		mark := ""
+		if change.Breaking {
+			mark = "[breaking] "
+		}
+		fmt.Fprintf(&b, "\n  %s%s", mark, change.ID())
+		if change.Detail != "" {
+			fmt.Fprintf(&b, "（%s）", change.Detail)
+		}
+	}
+	return b.String()
+}
+
+// DiffRouteManifests 比较两个路由清单，按兼容性分类变更
+//
+// 路由按方法与路径形状匹配（只改路径参数名不算变更）。请求与响应只在两边都声明了示例时比较：
+// 请求收窄（新增必填字段、字段改为必填、类型变化、删除枚举取值）与响应放宽（删除字段、字段可能缺失、
+// 类型变化、新增枚举取值）是不兼容的
+func DiffRouteManifests(old, current *RouteManifest) *APIChangelog {
+	changelog := &APIChangelog{From: old.APIVersion, To: current.APIVersion, Changes: []RouteChange{}}
+	add := func(kind, route, field, detail string) {
+		changelog.Changes = append(changelog.Changes, RouteChange{Kind: kind, Breaking: breakingChanges[kind], Route: route, Field: field, Detail: detail})
+	}
+
+	previous := map[string]ManifestRoute{}
+	for _, r := range old.Routes {
+		previous[r.Method+" "+routeShape(r.Path)] = r
+	}
+	seen := map[string]bool{}
+	for _, r := range current.Routes {
+		key := r.Method + " " + routeShape(r.Path)
+		seen[key] = true
+		before, ok := previous[key]
+		if !ok {
+			add(ChangeRouteAdded, r.name(), "", "")
+			continue
+		}
+		route := r.name()
+		switch {
+		case before.Auth != AuthRequired && r.Auth == AuthRequired:
+			add(ChangeAuthTighten, route, "", "")
+		case before.Auth == AuthRequired && r.Auth != AuthRequired:
+			add(ChangeAuthRelax, route, "", "")
+		}
+		if before.Deprecated == "" && r.Deprecated != "" {
+			add(ChangeDeprecated, route, "", r.Deprecated)
+		}
+		if before.Raw != r.Raw {
+			add(ChangeRawChanged, route, "", "")
+		}
+		if before.Request != nil && r.Request != nil {
+			diffSchema(requestSide, route, "", before.Request, r.Request, add)
+		}
+		if before.Response != nil && r.Response != nil {
+			diffSchema(responseSide, route, "", before.Response, r.Response, add)
+		}
+	}
+	for _, r := range old.Routes {
+		if !seen[r.Method+" "+routeShape(r.Path)] {
+			add(ChangeRouteRemoved, r.name(), "", "")
+		}
+	}
+
+	sort.SliceStable(changelog.Changes, func(i, j int) bool {
+		a, b := changelog.Changes[i], changelog.Changes[j]
+		if a.Breaking != b.Breaking {
+			return a.Breaking
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		return a.Field < b.Field
+	})
+	for _, c := range changelog.Changes {
+		if c.Breaking {
+			changelog.Breaking++
+		}
+	}
+	return changelog
+}
+
+// schemaSide 请求或响应一侧的变更类型
+type schemaSide struct {
+	added, requiredAdded, removed, nowRequired, nowOptional, typeChanged, enumAdded, enumRemoved string
+}
+
+var (
+	requestSide = schemaSide{
+		added: ChangeRequestOptionalAdded, requiredAdded: ChangeRequestRequiredAdded, removed: ChangeRequestFieldRemoved,
+		nowRequired: ChangeRequestNowRequired, nowOptional: ChangeRequestNowOptional, typeChanged: ChangeRequestTypeChanged,
+		enumAdded: ChangeRequestEnumAdded, enumRemoved: ChangeRequestEnumRemoved,
+	}
+	responseSide = schemaSide{
+		added: ChangeResponseFieldAdded, requiredAdded: ChangeResponseFieldAdded, removed: ChangeResponseFieldRemoved,
+		nowRequired: ChangeResponseNowRequired, nowOptional: ChangeResponseNowOptional, typeChanged: ChangeResponseTypeChanged,
+		enumAdded: ChangeResponseEnumAdded, enumRemoved: ChangeResponseEnumRemoved,
+	}
+)
+
+// diffSchema 递归比较同一位置的两个结构
+func diffSchema(side schemaSide, route, field string, old, cur *apischema.Schema, add func(kind, route, field, detail string)) {
+	if old.Type != cur.Type || old.Format != cur.Format || old.Ref != cur.Ref {
+		add(side.typeChanged, route, field, describeSchema(old)+" -> "+describeSchema(cur))
+		return
+	}
+	if old.Enum != nil || cur.Enum != nil {
+		for _, v := range cur.Enum {
+			if !slices.Contains(old.Enum, v) {
+				add(side.enumAdded, route, field, v)
+			}
+		}
+		for _, v := range old.Enum {
+			if !slices.Contains(cur.Enum, v) {
+				add(side.enumRemoved, route, field, v)
+			}
+		}
+	}
+	if old.Items != nil && cur.Items != nil {
+		diffSchema(side, route, field+"[]", old.Items, cur.Items, add)
+	}
+	if old.AdditionalProperties != nil && cur.AdditionalProperties != nil {
+		diffSchema(side, route, field+"{}", old.AdditionalProperties, cur.AdditionalProperties, add)
+	}
+
+	names := make([]string, 0, len(old.Properties)+len(cur.Properties))
+	for name := range old.Properties {
+		names = append(names, name)
+	}
+	for name := range cur.Properties {
+		if _, ok := old.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := name
+		if field != "" {
+			path = field + "." + name
+		}
+		before, after := old.Properties[name], cur.Properties[name]
+		wasRequired, isRequired := slices.Contains(old.Required, name), slices.Contains(cur.Required, name)
+		switch {
+		case before == nil && isRequired:
+			add(side.requiredAdded, route, path, describeSchema(after))
+		case before == nil:
+			add(side.added, route, path, describeSchema(after))
+		case after == nil:
+			add(side.removed, route, path, "")
+		default:
+			if !wasRequired && isRequired {
+				add(side.nowRequired, route, path, "")
+			} else if wasRequired && !isRequired {
+				add(side.nowOptional, route, path, "")
+			}
+			diffSchema(side, route, path, before, after, add)
+		}
+	}
+}
+
+// describeSchema 变更说明中的类型描述
+func describeSchema(s *apischema.Schema) string {
+	switch {
+	case s.Ref != "":
+		return s.Ref
+	case s.Format != "":
+		return s.Type + "(" + s.Format + ")"
+	case s.Items != nil:
+		return describeSchema(s.Items) + "[]"
+	}
+	return s.Type
+}
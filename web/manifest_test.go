@@ -0,0 +1,355 @@
+package web
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CenJIl/base/web/apischema"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type manifestStatus string
+
+const (
+	manifestPending manifestStatus = "pending"
+	manifestPaid    manifestStatus = "paid"
+)
+
+func init() {
+	apischema.RegisterEnum(manifestPending, manifestPaid)
+}
+
+type manifestOrderReq struct {
+	ProductID int64  `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	Note      string `json:"note,omitempty"`
+}
+
+type manifestOrderResp struct {
+	ID     int64          `json:"id"`
+	Status manifestStatus `json:"status"`
+	Items  []struct {
+		SKU string `json:"sku"`
+	} `json:"items"`
+	Coupon *string `json:"coupon"`
+}
+
+func TestExportRouteManifest(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := NewRouter(engine)
+	api := r.Group("/api").RequireAuth()
+	api.WithExamples(Example{Request: manifestOrderReq{}, Response: manifestOrderResp{}}).POST("/orders", noopHandler)
+	api.WithExamples(Example{Response: PagedData{Items: []manifestOrderResp{}}}).GET("/orders", noopHandler)
+	api.Deprecated("use /api/orders").GET("/order-list", noopHandler)
+	r.Group("/api").Public().GET("/health", noopHandler)
+	engine.GET("/metrics", noopHandler)
+
+	routes, conflicts := ValidateRoutes(engine)
+	require.Empty(t, conflicts)
+	m := ExportRouteManifest(routes, "1.2.0")
+
+	assert.Equal(t, RouteManifestFormat, m.Format)
+	names := make([]string, 0, len(m.Routes))
+	for _, r := range m.Routes {
+		names = append(names, r.name())
+	}
+	assert.Equal(t, []string{"GET /api/health", "GET /api/order-list", "GET /api/orders", "POST /api/orders"}, names)
+
+	create := m.Routes[3]
+	assert.Equal(t, AuthRequired, create.Auth)
+	assert.Equal(t, []string{"productId", "quantity"}, create.Request.Required)
+	assert.Equal(t, []string{`"pending"`, `"paid"`}, create.Response.Properties["status"].Enum)
+	assert.Equal(t, "string", create.Response.Properties["items"].Items.Properties["sku"].Type)
+	assert.True(t, create.Response.Properties["coupon"].Nullable)
+	assert.NotContains(t, create.Response.Required, "coupon")
+
+	list := m.Routes[2]
+	assert.Nil(t, list.Request)
+	assert.Equal(t, "integer", list.Response.Properties["total"].Type)
+	assert.Equal(t, "object", list.Response.Properties["items"].Items.Type, "分页数据按示例展开 items")
+
+	assert.Equal(t, "use /api/orders", m.Routes[1].Deprecated)
+	assert.Equal(t, AuthPublic, m.Routes[0].Auth)
+
+	// 同一份路由表导出的 JSON 完全一致，且可以解析回来
+	assert.Equal(t, string(m.JSON()), string(ExportRouteManifest(routes, "1.2.0").JSON()))
+	parsed, err := ParseRouteManifest(m.JSON())
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+
+	_, err = ParseRouteManifest([]byte(`{"format":99,"routes":[]}`))
+	assert.ErrorContains(t, err, "格式版本")
+}
+
+func TestDiffRouteManifests(t *testing.T) {
+	obj := func(required []string, props map[string]*apischema.Schema) *apischema.Schema {
+		return &apischema.Schema{Type: "object", Properties: props, Required: required}
+	}
+	str := func() *apischema.Schema { return &apischema.Schema{Type: "string"} }
+	integer := func() *apischema.Schema { return &apischema.Schema{Type: "integer"} }
+	enum := func(values ...string) *apischema.Schema { return &apischema.Schema{Type: "string", Enum: values} }
+	route := func(method, path string, mutate func(r *ManifestRoute)) ManifestRoute {
+		r := ManifestRoute{Method: method, Path: path, Auth: AuthRequired}
+		if mutate != nil {
+			mutate(&r)
+		}
+		return r
+	}
+	withRequest := func(s *apischema.Schema) func(r *ManifestRoute) {
+		return func(r *ManifestRoute) { r.Request = s }
+	}
+	withResponse := func(s *apischema.Schema) func(r *ManifestRoute) {
+		return func(r *ManifestRoute) { r.Response = s }
+	}
+
+	testCases := []struct {
+		name     string
+		old, new ManifestRoute
+		changes  []string // 变更 ID
+		breaking bool
+	}{
+		{"no change",
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id"}, map[string]*apischema.Schema{"id": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id"}, map[string]*apischema.Schema{"id": integer()}))),
+			nil, false},
+		{"path param renamed",
+			route("GET", "/api/orders/:id", nil),
+			route("GET", "/api/orders/:orderId", nil),
+			nil, false},
+		{"auth tightened",
+			route("GET", "/api/stats", func(r *ManifestRoute) { r.Auth = AuthPublic }),
+			route("GET", "/api/stats", nil),
+			[]string{"auth_tightened GET /api/stats"}, true},
+		{"auth relaxed",
+			route("GET", "/api/stats", nil),
+			route("GET", "/api/stats", func(r *ManifestRoute) { r.Auth = "" }),
+			[]string{"auth_relaxed GET /api/stats"}, false},
+		{"deprecated",
+			route("GET", "/api/stats", nil),
+			route("GET", "/api/stats", func(r *ManifestRoute) { r.Deprecated = "use /api/v2/stats" }),
+			[]string{"deprecated GET /api/stats"}, false},
+		{"raw changed",
+			route("GET", "/api/export", nil),
+			route("GET", "/api/export", func(r *ManifestRoute) { r.Raw = true }),
+			[]string{"raw_changed GET /api/export"}, true},
+		{"request optional field added",
+			route("POST", "/api/orders", withRequest(obj([]string{"productId"}, map[string]*apischema.Schema{"productId": integer()}))),
+			route("POST", "/api/orders", withRequest(obj([]string{"productId"}, map[string]*apischema.Schema{"productId": integer(), "note": str()}))),
+			[]string{"request_optional_field_added POST /api/orders note"}, false},
+		{"request required field added",
+			route("POST", "/api/orders", withRequest(obj([]string{"productId"}, map[string]*apischema.Schema{"productId": integer()}))),
+			route("POST", "/api/orders", withRequest(obj([]string{"productId", "quantity"}, map[string]*apischema.Schema{"productId": integer(), "quantity": integer()}))),
+			[]string{"request_required_field_added POST /api/orders quantity"}, true},
+		{"request field removed",
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"note": str()}))),
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{}))),
+			[]string{"request_field_removed POST /api/orders note"}, false},
+		{"request field now required",
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"note": str()}))),
+			route("POST", "/api/orders", withRequest(obj([]string{"note"}, map[string]*apischema.Schema{"note": str()}))),
+			[]string{"request_field_now_required POST /api/orders note"}, true},
+		{"request field now optional",
+			route("POST", "/api/orders", withRequest(obj([]string{"note"}, map[string]*apischema.Schema{"note": str()}))),
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"note": str()}))),
+			[]string{"request_field_now_optional POST /api/orders note"}, false},
+		{"request type changed",
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"productId": integer()}))),
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"productId": str()}))),
+			[]string{"request_type_changed POST /api/orders productId"}, true},
+		{"request enum value added",
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`)}))),
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`, `"b"`)}))),
+			[]string{"request_enum_value_added POST /api/orders status"}, false},
+		{"request enum value removed",
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`, `"b"`)}))),
+			route("POST", "/api/orders", withRequest(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`)}))),
+			[]string{"request_enum_value_removed POST /api/orders status"}, true},
+		{"response field added",
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id"}, map[string]*apischema.Schema{"id": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id", "total"}, map[string]*apischema.Schema{"id": integer(), "total": integer()}))),
+			[]string{"response_field_added GET /api/orders/:id total"}, false},
+		{"response field removed",
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id", "total"}, map[string]*apischema.Schema{"id": integer(), "total": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"id"}, map[string]*apischema.Schema{"id": integer()}))),
+			[]string{"response_field_removed GET /api/orders/:id total"}, true},
+		{"response field now optional",
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"total"}, map[string]*apischema.Schema{"total": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"total": integer()}))),
+			[]string{"response_field_now_optional GET /api/orders/:id total"}, true},
+		{"response field now required",
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"total": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj([]string{"total"}, map[string]*apischema.Schema{"total": integer()}))),
+			[]string{"response_field_now_required GET /api/orders/:id total"}, false},
+		{"response type changed",
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"total": integer()}))),
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"total": {Type: "number"}}))),
+			[]string{"response_type_changed GET /api/orders/:id total"}, true},
+		{"response enum value added",
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`)}))),
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`, `"b"`)}))),
+			[]string{"response_enum_value_added GET /api/orders/:id status"}, true},
+		{"response enum value removed",
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`, `"b"`)}))),
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{"status": enum(`"a"`)}))),
+			[]string{"response_enum_value_removed GET /api/orders/:id status"}, false},
+		{"nested array field removed",
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{
+				"items": {Type: "array", Items: obj([]string{"sku"}, map[string]*apischema.Schema{"sku": str()})},
+			}))),
+			route("GET", "/api/orders/:id", withResponse(obj(nil, map[string]*apischema.Schema{
+				"items": {Type: "array", Items: obj(nil, map[string]*apischema.Schema{})},
+			}))),
+			[]string{"response_field_removed GET /api/orders/:id items[].sku"}, true},
+		{"map value type changed",
+			route("GET", "/api/stats", withResponse(obj(nil, map[string]*apischema.Schema{
+				"counts": {Type: "object", AdditionalProperties: integer()},
+			}))),
+			route("GET", "/api/stats", withResponse(obj(nil, map[string]*apischema.Schema{
+				"counts": {Type: "object", AdditionalProperties: str()},
+			}))),
+			[]string{"response_type_changed GET /api/stats counts{}"}, true},
+		{"example added is not a change",
+			route("GET", "/api/stats", nil),
+			route("GET", "/api/stats", withResponse(obj(nil, map[string]*apischema.Schema{"total": integer()}))),
+			nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			old := &RouteManifest{Format: RouteManifestFormat, Routes: []ManifestRoute{tc.old}}
+			current := &RouteManifest{Format: RouteManifestFormat, Routes: []ManifestRoute{tc.new}}
+			changelog := DiffRouteManifests(old, current)
+
+			ids := []string{}
+			for _, c := range changelog.Changes {
+				ids = append(ids, c.ID())
+			}
+			if tc.changes == nil {
+				tc.changes = []string{}
+			}
+			assert.Equal(t, tc.changes, ids)
+			assert.Equal(t, tc.breaking, changelog.Breaking > 0)
+		})
+	}
+}
+
+func TestDiffRouteManifests_RoutesAddedAndRemoved(t *testing.T) {
+	old := &RouteManifest{APIVersion: "1.0.0", Routes: []ManifestRoute{
+		{Method: "GET", Path: "/api/a"},
+		{Method: "GET", Path: "/api/b"},
+	}}
+	current := &RouteManifest{APIVersion: "1.1.0", Routes: []ManifestRoute{
+		{Method: "GET", Path: "/api/b"},
+		{Method: "POST", Path: "/api/b"},
+	}}
+	changelog := DiffRouteManifests(old, current)
+
+	require.Len(t, changelog.Changes, 2)
+	assert.Equal(t, RouteChange{Kind: ChangeRouteRemoved, Breaking: true, Route: "GET /api/a"}, changelog.Changes[0], "不兼容的变更在前")
+	assert.Equal(t, RouteChange{Kind: ChangeRouteAdded, Route: "POST /api/b"}, changelog.Changes[1])
+	assert.Equal(t, 1, changelog.Breaking)
+	assert.Contains(t, changelog.String(), "[breaking] route_removed GET /api/a")
+	assert.Contains(t, changelog.String(), "1.0.0 -> 1.1.0")
+}
+
+func TestCheckAPIChanges(t *testing.T) {
+	v1 := &RouteManifest{Format: RouteManifestFormat, APIVersion: "1.0.0", Routes: []ManifestRoute{
+		{Method: "GET", Path: "/api/a"},
+		{Method: "GET", Path: "/api/b"},
+	}}
+	removed := func(version string) *RouteManifest {
+		return &RouteManifest{Format: RouteManifestFormat, APIVersion: version, Routes: []ManifestRoute{{Method: "GET", Path: "/api/b"}}}
+	}
+
+	t.Run("baseline missing", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := CheckAPIChanges(v1, filepath.Join(dir, "manifest.json"), filepath.Join(dir, "breaking.ack"))
+		assert.ErrorContains(t, err, EnvManifestUpdate)
+
+		t.Setenv(EnvManifestUpdate, "1")
+		_, err = CheckAPIChanges(v1, filepath.Join(dir, "api", "manifest.json"), filepath.Join(dir, "breaking.ack"))
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, "api", "manifest.json"))
+		require.NoError(t, err)
+		assert.Equal(t, string(v1.JSON()), string(data))
+	})
+
+	t.Run("unacknowledged breaking change fails", func(t *testing.T) {
+		dir := t.TempDir()
+		baseline := filepath.Join(dir, "manifest.json")
+		require.NoError(t, os.WriteFile(baseline, v1.JSON(), 0644))
+
+		changelog, err := CheckAPIChanges(removed("1.1.0"), baseline, filepath.Join(dir, "breaking.ack"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "route_removed GET /api/a")
+		assert.Equal(t, 1, changelog.Breaking)
+	})
+
+	t.Run("acknowledged breaking change passes", func(t *testing.T) {
+		dir := t.TempDir()
+		baseline, ack := filepath.Join(dir, "manifest.json"), filepath.Join(dir, "breaking.ack")
+		require.NoError(t, os.WriteFile(baseline, v1.JSON(), 0644))
+		require.NoError(t, os.WriteFile(ack, []byte("# 已通知所有调用方\nroute_removed GET /api/a\n"), 0644))
+
+		_, err := CheckAPIChanges(removed("1.1.0"), baseline, ack)
+		assert.NoError(t, err)
+	})
+
+	t.Run("major version bump passes", func(t *testing.T) {
+		dir := t.TempDir()
+		baseline := filepath.Join(dir, "manifest.json")
+		require.NoError(t, os.WriteFile(baseline, v1.JSON(), 0644))
+
+		_, err := CheckAPIChanges(removed("v2.0.0"), baseline, filepath.Join(dir, "breaking.ack"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("update writes baseline only after check passes", func(t *testing.T) {
+		dir := t.TempDir()
+		baseline := filepath.Join(dir, "manifest.json")
+		require.NoError(t, os.WriteFile(baseline, v1.JSON(), 0644))
+		t.Setenv(EnvManifestUpdate, "1")
+
+		_, err := CheckAPIChanges(removed("1.1.0"), baseline, filepath.Join(dir, "breaking.ack"))
+		require.Error(t, err)
+		data, _ := os.ReadFile(baseline)
+		assert.Equal(t, string(v1.JSON()), string(data))
+
+		_, err = CheckAPIChanges(removed("2.0.0"), baseline, filepath.Join(dir, "breaking.ack"))
+		require.NoError(t, err)
+		data, _ = os.ReadFile(baseline)
+		assert.Equal(t, string(removed("2.0.0").JSON()), string(data))
+	})
+}
+
+func TestDebugChangelogHandler(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	NewRouter(engine).GET("/api/b", noopHandler)
+	routes, _ := ValidateRoutes(engine)
+	baseline := &RouteManifest{Format: RouteManifestFormat, APIVersion: "1.0.0", Routes: []ManifestRoute{{Method: "GET", Path: "/api/a"}}}
+	previous := validatedRoutes.Load()
+	validatedRoutes.Store(&routes)
+	t.Cleanup(func() { validatedRoutes.Store(previous) })
+
+	h := route.NewEngine(config.NewOptions(nil))
+	h.GET("/debug/changelog", DebugChangelogHandler(baseline.JSON(), "1.1.0"))
+	w := ut.PerformRequest(h, "GET", "/debug/changelog", nil)
+	assert.Equal(t, 200, w.Code)
+
+	var resp struct {
+		Data APIChangelog `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "1.0.0", resp.Data.From)
+	assert.Equal(t, "1.1.0", resp.Data.To)
+	assert.Equal(t, 1, resp.Data.Breaking)
+	assert.Len(t, resp.Data.Changes, 2)
+
+	assert.Panics(t, func() { DebugChangelogHandler([]byte("{"), "1.1.0") })
+}
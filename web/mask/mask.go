@@ -0,0 +1,154 @@
+// Package mask 敏感数据脱敏（手机号、身份证号、邮箱等）
+//
+// 没有查看权限的调用方只能看到脱敏后的值；拥有 revealRoles 中任一角色的用户看到原文
+//
+// 使用方式：
+//
+//	mask.Phone("13812345678")          // 138****5678
+//	mask.Reveal(c, user.Phone)          // 管理员看到原文，其他人看到脱敏值
+package mask
+
+import (
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// Config 脱敏配置
+//
+// Example:
+//
+//	[web.mask]
+//	revealRoles = ["admin", "support"]   # 可以查看原文的角色，默认 ["admin"]
+type Config struct {
+	RevealRoles []string `toml:"revealRoles"`
+}
+
+var revealRoles atomic.Pointer[[]string]
+
+func init() {
+	Init(Config{})
+}
+
+// Init 设置脱敏配置（零值字段使用默认值）
+func Init(config Config) {
+	roles := config.RevealRoles
+	if len(roles) == 0 {
+		roles = []string{"admin"}
+	}
+	revealRoles.Store(&roles)
+}
+
+// CanReveal 当前用户是否可以查看原文
+func CanReveal(c *app.RequestContext) bool {
+	if c == nil {
+		return false
+	}
+	allowed := *revealRoles.Load()
+	for _, role := range jwt.GetRoles(c) {
+		for _, r := range allowed {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reveal 有权限时返回原文，否则返回 Auto 脱敏后的值
+func Reveal(c *app.RequestContext, value string) string {
+	if CanReveal(c) {
+		return value
+	}
+	return Auto(value)
+}
+
+// Phone 手机号脱敏：保留前 3 位和后 4 位（138****5678）
+func Phone(s string) string {
+	return Middle(s, 3, 4)
+}
+
+// IDCard 身份证号脱敏：保留前 3 位和后 4 位（110***********1234）
+func IDCard(s string) string {
+	return Middle(s, 3, 4)
+}
+
+// Email 邮箱脱敏：用户名只保留首字符（a***@example.com）
+func Email(s string) string {
+	name, domain, ok := strings.Cut(s, "@")
+	if !ok {
+		return Middle(s, 1, 0)
+	}
+	return Middle(name, 1, 0) + "@" + domain
+}
+
+// Middle 保留前 head 个和后 tail 个字符，中间替换为 *
+//
+// 字符数不足时至少遮盖一半，避免短值被完整暴露
+func Middle(s string, head, tail int) string {
+	runes := []rune(s)
+	n := len(runes)
+	if n == 0 {
+		return s
+	}
+	if head+tail >= n {
+		head, tail = n/4, n/4
+		if head+tail == 0 && n > 1 {
+			head = 1
+		}
+	}
+	masked := make([]rune, n)
+	copy(masked, runes)
+	for i := head; i < n-tail; i++ {
+		masked[i] = '*'
+	}
+	return string(masked)
+}
+
+// Auto 按值的格式选择脱敏方式（手机号 / 身份证号 / 邮箱 / 其他）
+func Auto(s string) string {
+	switch {
+	case s == "":
+		return s
+	case strings.Contains(s, "@"):
+		return Email(s)
+	case isPhone(s):
+		return Phone(s)
+	case isIDCard(s):
+		return IDCard(s)
+	default:
+		// 其他值保留首尾各四分之一（至少保留首字符，如姓名 张*）
+		n := utf8.RuneCountInString(s)
+		return Middle(s, max(n/4, 1), n/4)
+	}
+}
+
+// isPhone 中国大陆手机号（11 位，1 开头）
+func isPhone(s string) bool {
+	if len(s) != 11 || s[0] != '1' {
+		return false
+	}
+	return IsDigits(s)
+}
+
+// isIDCard 18 位身份证号（最后一位可以是 X）
+func isIDCard(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	last := s[17]
+	return IsDigits(s[:17]) && (last >= '0' && last <= '9' || last == 'X' || last == 'x')
+}
+
+// IsDigits s 是否只由 ASCII 数字组成（空串为 true）
+func IsDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package mask
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskFormats(t *testing.T) {
+	assert.Equal(t, "138****5678", Phone("13812345678"))
+	assert.Equal(t, "110***********1234", IDCard("110101199001011234"))
+	assert.Equal(t, "a****@example.com", Email("alice@example.com"))
+
+	assert.Equal(t, "138****5678", Auto("13812345678"))
+	assert.Equal(t, "110***********123X", Auto("11010119900101123X"))
+	assert.Equal(t, "b**@example.com", Auto("bob@example.com"))
+	assert.Equal(t, "张*", Auto("张三"))
+	assert.Equal(t, "622*********123", Auto("622212345670123"))
+	assert.Equal(t, "", Auto(""))
+
+	// 字符数不足时仍遮盖一部分
+	assert.Equal(t, "1*", Middle("12", 3, 4))
+	assert.Equal(t, "*", Middle("1", 3, 4))
+}
+
+func TestReveal(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "mask-secret"
+	require.NoError(t, jwt.Init(conf))
+	Init(Config{RevealRoles: []string{"support"}})
+	t.Cleanup(func() { Init(Config{}) })
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/phone", jwt.Middleware(), func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, Reveal(c, "13812345678"))
+	})
+	token := func(roles ...string) ut.Header {
+		tok, _, err := jwt.GenerateToken(map[string]interface{}{"identity": "u1", jwt.RolesClaim: roles})
+		require.NoError(t, err)
+		return ut.Header{Key: "Authorization", Value: "Bearer " + tok}
+	}
+
+	assert.Equal(t, "138****5678", ut.PerformRequest(engine, "GET", "/phone", nil, token("user")).Body.String())
+	assert.Equal(t, "13812345678", ut.PerformRequest(engine, "GET", "/phone", nil, token("support")).Body.String())
+	assert.Equal(t, "138****5678", Reveal(nil, "13812345678"))
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// memSQL 包内测试共用的内存数据库驱动：表为行的列表，事务内写入的行提交前只对本事务可见、回滚时丢弃；
+// 语句的含义由测试提供的 exec / query 解释（调用时持有 mu，可直接使用 memConn 的 rows / insert 与 tables）
+type memSQL struct {
+	mu      sync.Mutex
+	tables  map[string][]map[string]any
+	queries []string // 执行过的查询
+
+	exec  func(c *memConn, query string, args []any) (driver.Result, error)
+	query func(c *memConn, query string, args []any) (columns []string, rows [][]driver.Value, err error)
+}
+
+func newMemSQL(t *testing.T, m *memSQL) *sql.DB {
+	m.tables = map[string][]map[string]any{}
+	db := sql.OpenDB(m)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (m *memSQL) Connect(context.Context) (driver.Conn, error) { return &memConn{db: m}, nil }
+func (m *memSQL) Driver() driver.Driver                        { return m }
+func (m *memSQL) Open(string) (driver.Conn, error)             { return &memConn{db: m}, nil }
+
+// memSQLDrivers 已注册的驱动数（sql.Register 的名称不能重复）
+var memSQLDrivers atomic.Int64
+
+// registerMemSQL 把内存数据库注册为驱动，返回驱动名（经过 database.Open 等按驱动名打开的场景使用）
+func registerMemSQL(m *memSQL) string {
+	m.tables = map[string][]map[string]any{}
+	name := fmt.Sprintf("memsql-%d", memSQLDrivers.Add(1))
+	sql.Register(name, m)
+	return name
+}
+
+// insert 写入一行已提交的数据
+func (m *memSQL) insert(table string, row map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tables[table] = append(m.tables[table], row)
+}
+
+// rows 已提交的行
+func (m *memSQL) rows(table string) []map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]map[string]any, 0, len(m.tables[table]))
+	for _, row := range m.tables[table] {
+		out = append(out, maps.Clone(row))
+	}
+	return out
+}
+
+func (m *memSQL) queryCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queries)
+}
+
+type memConn struct {
+	db     *memSQL
+	staged map[string][]map[string]any // 未提交的写入（nil 表示不在事务中）
+}
+
+// rows 本连接可见的行：已提交的加上本事务未提交的
+func (c *memConn) rows(table string) []map[string]any {
+	return append(append([]map[string]any{}, c.db.tables[table]...), c.staged[table]...)
+}
+
+// insert 事务中暂存到提交，否则直接写入
+func (c *memConn) insert(table string, row map[string]any) {
+	if c.staged != nil {
+		c.staged[table] = append(c.staged[table], row)
+		return
+	}
+	c.db.tables[table] = append(c.db.tables[table], row)
+}
+
+func (c *memConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *memConn) Close() error                        { return nil }
+func (c *memConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *memConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.staged = map[string][]map[string]any{}
+	return memTx{c}, nil
+}
+
+func (c *memConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	m := c.db
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exec == nil {
+		return nil, fmt.Errorf("unexpected exec: %s", query)
+	}
+	return m.exec(c, query, memArgs(args))
+}
+
+func (c *memConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	m := c.db
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = append(m.queries, query)
+	if m.query == nil {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	columns, rows, err := m.query(c, query, memArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return &memRows{columns: columns, rows: rows}, nil
+}
+
+func memArgs(named []driver.NamedValue) []any {
+	args := make([]any, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+	return args
+}
+
+type memTx struct{ c *memConn }
+
+func (t memTx) Commit() error {
+	m := t.c.db
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for table, rows := range t.c.staged {
+		m.tables[table] = append(m.tables[table], rows...)
+	}
+	t.c.staged = nil
+	return nil
+}
+
+func (t memTx) Rollback() error {
+	m := t.c.db
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t.c.staged = nil
+	return nil
+}
+
+type memRows struct {
+	columns []string
+	rows    [][]driver.Value
+	i       int
+}
+
+func (r *memRows) Columns() []string { return r.columns }
+func (r *memRows) Close() error      { return nil }
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
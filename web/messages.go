@@ -0,0 +1,319 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// 响应信封的内置消息键（键本身就是英文文案）
+//
+// 响应中 message 等于这些键时，EnvelopeMessageMiddleware 按请求语言翻译；其他消息原样输出
+const (
+	MsgSuccess          = "success"
+	MsgUnauthorized     = "Unauthorized"
+	MsgForbidden        = "Forbidden"
+	MsgNotFound         = "Not found"
+	MsgResourceNotFound = "Resource not found"
+	MsgMethodNotAllowed = "Method not allowed"
+	MsgRateLimited      = "Rate limit exceeded"
+	MsgOverloaded       = "Service overloaded"
+	MsgInternalError    = "Internal server error"
+
+	MsgAcknowledgementRequired = "Acknowledgement required"
+	MsgVersionConflict         = "Version conflict"
+	MsgUnknownFields           = "Unknown fields"
+	MsgValidationFailed        = "Validation failed"
+	MsgUnknownField            = "Unknown field"
+	MsgUnsupportedEncoding     = "Unsupported content encoding"
+	MsgBodyTooLarge            = "Request body too large"
+	MsgMalformedBody           = "Malformed compressed body"
+	MsgScanUnavailable         = "File scanning unavailable"
+)
+
+// builtinMessages 内置消息的翻译（每个 App 的翻译都以此为初始内容）
+var builtinMessages = map[string]map[string]string{
+	"zh-CN": {
+		MsgSuccess:          "成功",
+		MsgUnauthorized:     "未登录或登录已过期",
+		MsgForbidden:        "没有权限",
+		MsgNotFound:         "请求的地址不存在",
+		MsgResourceNotFound: "资源不存在",
+		MsgMethodNotAllowed: "不支持的请求方法",
+		MsgRateLimited:      "请求过于频繁，请稍后再试",
+		MsgOverloaded:       "服务繁忙，请稍后再试",
+		MsgInternalError:    "服务器内部错误",
+
+		MsgAcknowledgementRequired: "请先阅读并同意最新条款",
+		MsgVersionConflict:         "数据已被修改，请刷新后重试",
+		MsgUnknownFields:           "请求了不存在的字段",
+		MsgValidationFailed:        "参数校验失败",
+		MsgUnknownField:            "不支持的字段",
+		MsgUnsupportedEncoding:     "不支持的请求体编码",
+		MsgBodyTooLarge:            "请求体过大",
+		MsgMalformedBody:           "压缩的请求体无法解压",
+		MsgScanUnavailable:         "文件安全扫描暂不可用，请稍后重试",
+
+		MsgErrorPageRequestID: "请求编号",
+		MsgErrorPageBack:      "返回",
+	},
+	"en-US": {
+		MsgSuccess:          "success",
+		MsgUnauthorized:     "Unauthorized",
+		MsgForbidden:        "Forbidden",
+		MsgNotFound:         "Not found",
+		MsgResourceNotFound: "Resource not found",
+		MsgMethodNotAllowed: "Method not allowed",
+		MsgRateLimited:      "Rate limit exceeded",
+		MsgOverloaded:       "Service overloaded",
+		MsgInternalError:    "Internal server error",
+
+		MsgAcknowledgementRequired: "Please review and accept the updated terms",
+		MsgVersionConflict:         "The resource was modified, please refresh and try again",
+		MsgUnknownFields:           "Unknown fields requested",
+		MsgValidationFailed:        "Validation failed",
+		MsgUnknownField:            "Unknown field",
+		MsgUnsupportedEncoding:     "Unsupported content encoding",
+		MsgBodyTooLarge:            "Request body too large",
+		MsgMalformedBody:           "Malformed compressed body",
+		MsgScanUnavailable:         "File scanning is temporarily unavailable, please try again later",
+
+		MsgErrorPageRequestID: "Request ID",
+		MsgErrorPageBack:      "Back",
+	},
+}
+
+// RegisterMessages 注册（或覆盖）一种语言的消息翻译，与已有翻译合并
+//
+// 也可以注册业务自己的消息键，供 SuccessMsg / LocalizeMessage 使用。
+// 注册到默认 App；NewApp 创建的 App 有自己的翻译，使用 App.RegisterMessages
+//
+// 使用方式：
+//
+//	web.RegisterMessages("zh-CN", map[string]string{web.MsgSuccess: "操作成功", "order.paid": "支付成功"})
+func RegisterMessages(lang string, translations map[string]string) {
+	defaultApp.RegisterMessages(lang, translations)
+}
+
+// messageStore 一个 App 的翻译，按查找顺序分为三层：运行时覆盖 → 本地化文件 → 代码注册（含内置翻译）
+type messageStore struct {
+	mu      sync.RWMutex
+	overlay map[string]map[string]string // 运行时覆盖
+	files   map[string]map[string]string // 本地化文件（WatchLocales 加载）
+	code    map[string]map[string]string // 代码注册（含内置翻译）
+}
+
+func newMessageStore() *messageStore {
+	s := &messageStore{
+		overlay: map[string]map[string]string{},
+		files:   map[string]map[string]string{},
+		code:    make(map[string]map[string]string, len(builtinMessages)),
+	}
+	for lang, m := range builtinMessages {
+		s.code[lang] = maps.Clone(m)
+	}
+	return s
+}
+
+// register 合并代码注册的翻译
+func (s *messageStore) register(lang string, translations map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.code[lang]
+	if m == nil {
+		m = make(map[string]string, len(translations))
+		s.code[lang] = m
+	}
+	for k, v := range translations {
+		m[k] = v
+	}
+}
+
+// layers 翻译的查找顺序（调用方持有读锁）
+func (s *messageStore) layers() []map[string]map[string]string {
+	return []map[string]map[string]string{s.overlay, s.files, s.code}
+}
+
+// translate 查找消息键的翻译：完全匹配 → 同一主语言 → fallback 语言；ok 表示 key 是已注册的消息键
+func (s *messageStore) translate(lang, fallback, key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, l := range []string{lang, fallback} {
+		name := s.match(l)
+		if name == "" {
+			continue
+		}
+		for _, layer := range s.layers() {
+			if v, ok := layer[name][key]; ok {
+				return v, true
+			}
+		}
+	}
+	return key, false
+}
+
+// match 在有翻译的语言中查找匹配项（调用方持有读锁）
+func (s *messageStore) match(lang string) string {
+	if lang == "" {
+		return ""
+	}
+	lang = strings.ReplaceAll(lang, "_", "-")
+	best := ""
+	base, _, _ := strings.Cut(lang, "-")
+	for _, layer := range s.layers() {
+		for name := range layer {
+			if strings.EqualFold(name, lang) {
+				return name
+			}
+			if nb, _, _ := strings.Cut(name, "-"); strings.EqualFold(nb, base) && (best == "" || name < best) {
+				best = name
+			}
+		}
+	}
+	return best
+}
+
+// isRegisteredKey 是否是代码中注册的消息键（本地化文件中的键不参与信封翻译，避免误改业务消息）
+func (s *messageStore) isRegisteredKey(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.code {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// has 请求语言是否有翻译（完全匹配或主语言匹配）
+func (s *messageStore) has(lang string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.match(lang) != ""
+}
+
+// setFile 原子替换一种语言的文件翻译（translations 为 nil 时移除）
+func (s *messageStore) setFile(lang string, translations map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if translations == nil {
+		delete(s.files, lang)
+		return
+	}
+	s.files[lang] = translations
+}
+
+// fileLanguages 有文件翻译的语言
+func (s *messageStore) fileLanguages() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Sorted(maps.Keys(s.files))
+}
+
+// SetMessageOverlay 运行时覆盖一条翻译（优先于本地化文件和代码注册的翻译，文件重新加载后仍然生效）
+//
+// 使用方式：
+//
+//	web.SetMessageOverlay("zh-CN", "order.paid", "付款成功")
+func SetMessageOverlay(lang, key, text string) {
+	s := defaultApp.messages
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.overlay[lang] == nil {
+		s.overlay[lang] = make(map[string]string)
+	}
+	s.overlay[lang][key] = text
+}
+
+// ClearMessageOverlay 移除运行时覆盖，恢复文件或代码中的翻译
+func ClearMessageOverlay(lang, key string) {
+	s := defaultApp.messages
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overlay[lang], key)
+	if len(s.overlay[lang]) == 0 {
+		delete(s.overlay, lang)
+	}
+}
+
+// LocalizeMessage 按 ctx 的语言翻译消息键（没有请求时使用默认语言，未注册的键原样返回）
+func LocalizeMessage(ctx context.Context, key string) string {
+	s, _ := AppFrom(ctx).translate(LocaleFrom(ctx).Language, key)
+	return s
+}
+
+// SuccessMsg 带翻译消息的成功响应
+//
+// 请求之外（后台任务生成 webhook 载荷等）按默认语言翻译
+//
+// 使用方式：
+//
+//	c.JSON(200, web.SuccessMsg(ctx, "order.paid", order))
+func SuccessMsg(ctx context.Context, msgKey string, data any) Result {
+	result := Success(data)
+	result.Message = LocalizeMessage(ctx, msgKey)
+	return result
+}
+
+// envelopePrefix 统一响应 JSON 的开头（Result 的字段顺序固定为 code、message）
+var envelopePrefix = []byte(`{"code":`)
+
+// EnvelopeMessageMiddleware 按请求语言翻译响应信封中的内置消息
+//
+// 在响应写完之后执行：JSON 响应的 message 是已注册的消息键（Success 的 "success"、
+// 404 / 500 / 限流等中间件的内置文案）时替换为翻译，业务显式给出的消息原样保留。
+// 语言的确定方式与 LocaleMiddleware 一致（?lang → Accept-Language → 默认语言），
+// 需要注册在 ExceptionHandler 之前，panic 生成的 500 响应也会被翻译。RawHandler 路由不处理
+func EnvelopeMessageMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.Next(ctx)
+		if c.GetBool(rawRouteKey) || c.Response.IsBodyStream() ||
+			!bytes.HasPrefix(c.Response.Header.ContentType(), []byte("application/json")) {
+			return
+		}
+		if body, ok := localizeEnvelope(AppOf(c), c.Response.Body(), requestLanguage(c)); ok {
+			c.Response.SetBody(body)
+		}
+	}
+}
+
+// localizeEnvelope 替换 {"code":N,"message":"<键>" 中的消息键（先查请求所属 App 的翻译），不是代码注册的键时返回 false
+func localizeEnvelope(a *App, body []byte, lang string) ([]byte, bool) {
+	if !bytes.HasPrefix(body, envelopePrefix) {
+		return nil, false
+	}
+	rest := body[len(envelopePrefix):]
+	i := 0
+	for i < len(rest) && (rest[i] == '-' || rest[i] >= '0' && rest[i] <= '9') {
+		i++
+	}
+	rest, ok := bytes.CutPrefix(rest[i:], []byte(`,"message":"`))
+	if !ok {
+		return nil, false
+	}
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 || bytes.IndexByte(rest[:end], '\\') >= 0 {
+		return nil, false
+	}
+	key := string(rest[:end])
+	if !a.isRegisteredKey(key) {
+		return nil, false
+	}
+	translated, ok := a.translate(lang, key)
+	if !ok || translated == key {
+		return nil, false
+	}
+	quoted, _ := json.Marshal(translated)
+	start := len(body) - len(rest) - 1 // 消息开头的引号
+	out := make([]byte, 0, len(body)+len(quoted))
+	out = append(out, body[:start]...)
+	out = append(out, quoted...)
+	out = append(out, rest[end+1:]...)
+	return out, true
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMessagesEngine() *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(EnvelopeMessageMiddleware(), ExceptionHandler(), LocaleMiddleware())
+	engine.GET("/ok", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		c.JSON(200, Success(nil))
+		return nil
+	}))
+	engine.GET("/data", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(map[string]string{"message": "success"}))
+	})
+	engine.GET("/custom", func(ctx context.Context, c *app.RequestContext) {
+		result := Success(nil)
+		result.Message = "已保存"
+		c.JSON(200, result)
+	})
+	engine.GET("/bad", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return BadRequestHTTP("email is invalid")
+	}))
+	engine.GET("/paid", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		c.JSON(200, SuccessMsg(ctx, "order.paid", nil))
+		return nil
+	}))
+	engine.GET("/panic", func(ctx context.Context, c *app.RequestContext) {
+		panic("boom")
+	})
+	engine.GET("/raw", RawHandler(func(ctx context.Context, c *app.RequestContext) {
+		c.Data(200, "application/json", []byte(`{"code":0,"message":"success"}`))
+	}, RawReason("上游约定的固定格式")))
+	engine.NoRoute(NotFoundHandler())
+	return engine
+}
+
+func envelopeMessage(t *testing.T, engine *route.Engine, path, lang string) string {
+	t.Helper()
+	var headers []ut.Header
+	if lang != "" {
+		headers = append(headers, ut.Header{Key: "Accept-Language", Value: lang})
+	}
+	w := ut.PerformRequest(engine, "GET", path, nil, headers...)
+	var result Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), w.Body.String())
+	return result.Message
+}
+
+func TestEnvelopeMessages_AcceptLanguage(t *testing.T) {
+	RegisterMessages("zh-CN", map[string]string{"order.paid": "支付成功"})
+	RegisterMessages("en-US", map[string]string{"order.paid": "Payment received"})
+	engine := newMessagesEngine()
+
+	assert.Equal(t, "成功", envelopeMessage(t, engine, "/ok", "zh-CN"))
+	assert.Equal(t, "success", envelopeMessage(t, engine, "/ok", "en-US,en;q=0.9"))
+	assert.Equal(t, "成功", envelopeMessage(t, engine, "/ok", ""), "默认语言")
+	assert.Equal(t, "success", envelopeMessage(t, engine, "/ok?lang=en", "zh-CN"))
+
+	assert.Equal(t, "请求的地址不存在", envelopeMessage(t, engine, "/missing", "zh"))
+	assert.Equal(t, "Not found", envelopeMessage(t, engine, "/missing", "en-US"))
+	assert.Equal(t, "服务器内部错误", envelopeMessage(t, engine, "/panic", "zh-CN"))
+	assert.Equal(t, "Internal server error", envelopeMessage(t, engine, "/panic", "en-US"))
+
+	assert.Equal(t, "支付成功", envelopeMessage(t, engine, "/paid", "zh-CN"))
+	assert.Equal(t, "Payment received", envelopeMessage(t, engine, "/paid", "en-US"))
+
+	// 只翻译信封的 message，数据中的同名字段不变
+	w := ut.PerformRequest(engine, "GET", "/data", nil, ut.Header{Key: "Accept-Language", Value: "zh-CN"})
+	assert.JSONEq(t, `{"code":0,"message":"成功","data":{"message":"success"}}`, w.Body.String())
+}
+
+func TestEnvelopeMessages_CustomVerbatim(t *testing.T) {
+	engine := newMessagesEngine()
+	for _, lang := range []string{"zh-CN", "en-US"} {
+		assert.Equal(t, "已保存", envelopeMessage(t, engine, "/custom", lang))
+		assert.Equal(t, "email is invalid", envelopeMessage(t, engine, "/bad", lang))
+	}
+
+	w := ut.PerformRequest(engine, "GET", "/raw", nil, ut.Header{Key: "Accept-Language", Value: "zh-CN"})
+	assert.Equal(t, `{"code":0,"message":"success"}`, w.Body.String(), "原始路由不改写")
+}
+
+func TestEnvelopeMessages_OverrideAndBackground(t *testing.T) {
+	RegisterMessages("zh-CN", map[string]string{MsgSuccess: "操作成功"})
+	t.Cleanup(func() { RegisterMessages("zh-CN", map[string]string{MsgSuccess: "成功"}) })
+
+	assert.Equal(t, "操作成功", envelopeMessage(t, newMessagesEngine(), "/ok", "zh-CN"))
+
+	// 请求之外按默认语言
+	assert.Equal(t, "操作成功", SuccessMsg(context.Background(), MsgSuccess, nil).Message)
+	InitLocale("en-US", "")
+	t.Cleanup(func() { InitLocale("", "") })
+	assert.Equal(t, "success", SuccessMsg(context.Background(), MsgSuccess, nil).Message)
+	assert.Equal(t, "unknown.key", LocalizeMessage(context.Background(), "unknown.key"))
+}
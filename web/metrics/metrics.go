@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Counter 单调递增计数器（无锁）
+type Counter struct {
+	v atomic.Int64
+}
+
+// Inc 计数加 1
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Add 计数加 n
+func (c *Counter) Add(n int64) { c.v.Add(n) }
+
+// Value 当前计数
+func (c *Counter) Value() int64 { return c.v.Load() }
+
+// Gauge 可增减的瞬时值（无锁，float64）
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Add 在当前值上累加（CAS 循环）
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// Value 当前值
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+var (
+	counters sync.Map // key -> *Counter
+	gauges   sync.Map // key -> *Gauge
+)
+
+// GetCounter 获取（不存在则创建）指定名称和标签的计数器
+//
+// labels 为 key/value 交替的列表，热路径上应缓存返回的指针
+//
+// 使用方式：
+//
+//	shed := metrics.GetCounter("web_shed_total", "class", "background")
+//	shed.Inc()
+func GetCounter(name string, labels ...string) *Counter {
+	key := metricKey(name, labels)
+	if v, ok := counters.Load(key); ok {
+		return v.(*Counter)
+	}
+	v, _ := counters.LoadOrStore(key, &Counter{})
+	return v.(*Counter)
+}
+
+// GetGauge 获取（不存在则创建）指定名称和标签的瞬时值
+//
+// 使用方式：
+//
+//	metrics.GetGauge("web_inflight_requests").Set(12)
+func GetGauge(name string, labels ...string) *Gauge {
+	key := metricKey(name, labels)
+	if v, ok := gauges.Load(key); ok {
+		return v.(*Gauge)
+	}
+	v, _ := gauges.LoadOrStore(key, &Gauge{})
+	return v.(*Gauge)
+}
+
+// Snapshot 返回所有指标的当前值（key 为 Prometheus 格式的 name{labels}）
+func Snapshot() map[string]float64 {
+	out := make(map[string]float64)
+	counters.Range(func(k, v any) bool {
+		out[k.(string)] = float64(v.(*Counter).Value())
+		return true
+	})
+	gauges.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*Gauge).Value()
+		return true
+	})
+	return out
+}
+
+// Handler 以 Prometheus 文本格式输出所有指标
+//
+// 使用方式：
+//
+//	h.GET("/metrics", metrics.Handler())
+func Handler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		snap := Snapshot()
+		keys := make([]string, 0, len(snap))
+		for k := range snap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var b strings.Builder
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s %v\n", k, snap[k])
+		}
+		c.Data(consts.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	}
+}
+
+// metricKey 生成 name{k1="v1",k2="v2"} 形式的键
+func metricKey(name string, labels []string) string {
+	if len(labels) < 2 {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
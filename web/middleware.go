@@ -4,23 +4,18 @@ import (
 	"context"
 	"time"
 
-	"github.com/CenJIl/base/logger"
 	"github.com/CenJIl/base/web/middleware"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
 // RecoveryMiddleware 恢复中间件
 //
-// 捕获 panic 并转换为统一的错误响应
+// 捕获 panic 并转换为统一的错误响应（与 ExceptionHandler、WrapHandler 使用相同的渲染规则）
 func RecoveryMiddleware() app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
 		defer func() {
 			if r := recover(); r != nil {
-				logger.Errorf("[PANIC] %v", r)
-				result := Fail(500, "Internal server error")
-				result.TraceID = middleware.GetRequestID(c)
-				c.JSON(500, result)
-				c.Abort()
+				renderRecovered(ctx, c, r)
 			}
 		}()
 		c.Next(ctx)
@@ -29,22 +24,30 @@ func RecoveryMiddleware() app.HandlerFunc {
 
 // LoggerMiddleware 日志中间件
 //
-// 记录每个请求的详细信息
+// 记录每个请求的详细信息（客户端中途断开的请求状态记为 499）。
+// 响应日志在响应写出之后记录（见 OnRequestDone），耗时包含写出响应的时间
 func LoggerMiddleware() app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
 		start := time.Now()
-		path := c.Path()
+		path := string(c.Path())
 		method := string(c.Method())
 		clientIP := c.ClientIP()
 
-		logger.Debugf("[Request] %s %s from %s", method, path, clientIP)
+		log.Debugf("[Request] %s %s from %s", method, path, clientIP)
 
 		c.Next(ctx)
 
-		latency := time.Since(start)
-		status := c.Response.StatusCode()
-		logger.Debugf("[Response] %s %s -> %d (Latency: %v)",
-			method, path, status, latency)
+		extra := ""
+		if service := GetCallingService(c); service != "" {
+			extra += ", Service: " + service
+		}
+		if variant := GetCanaryVariant(c); variant != "" {
+			extra += ", Variant: " + variant
+		}
+		OnRequestDone(c, func(ctx context.Context, s RequestSummary) {
+			log.Debugf("[Response] %s %s -> %d (Latency: %v, Bytes: %d%s)",
+				method, path, s.Status, time.Since(start), s.BytesWritten, extra)
+		})
 	}
 }
 
@@ -64,36 +67,32 @@ func getHTTPStatus(code int) int {
 	}
 }
 
+// NotFoundHandler 404 统一响应（注册到 h.NoRoute）
+func NotFoundHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		result := Fail(404, MsgNotFound)
+		result.TraceID = middleware.GetRequestID(c)
+		respondError(c, 404, result)
+	}
+}
+
+// MethodNotAllowedHandler 405 统一响应（注册到 h.NoMethod，需开启 WithHandleMethodNotAllowed）
+func MethodNotAllowedHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		result := Fail(405, MsgMethodNotAllowed)
+		result.TraceID = middleware.GetRequestID(c)
+		respondError(c, 405, result)
+	}
+}
+
 // ExceptionHandler 全局异常处理器（类似 Spring Boot 的 @RestControllerAdvice）
+//
+// HTTPException / Exception 按其状态码响应，其他 panic 视为 bug 上报后响应 500
 func ExceptionHandler() app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
 		defer func() {
 			if r := recover(); r != nil {
-				result := Result{}
-				switch err := r.(type) {
-				case *HTTPException:
-					// HTTP 异常
-					result = Fail(err.Code, err.Message)
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(err.HTTPStatus, result)
-					c.Abort()
-					return
-
-				case *Exception:
-					// 业务异常
-					result = Fail(err.Code, err.Message)
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(getHTTPStatus(err.Code), result)
-					c.Abort()
-					return
-
-				default:
-					logger.Errorf("[PANIC] Unhandled error: %v", err)
-					result = Fail(500, "Internal server error")
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(500, result)
-					c.Abort()
-				}
+				renderRecovered(ctx, c, r)
 			}
 		}()
 		c.Next(ctx)
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// StatusClientClosedRequest 客户端在响应写出前断开连接时记录的伪状态码（与 nginx 的 499 一致）
+//
+// 只用于访问日志和指标标签，连接已经断开，不会发送给客户端
+const StatusClientClosedRequest = 499
+
+const clientAbortKey = "client_abort"
+
+// clientAbort 单个请求的断开检测状态
+type clientAbort struct {
+	ctx      context.Context
+	finished atomic.Bool // 处理器链已结束（之后的断开不算中途断开）
+	aborted  atomic.Bool
+}
+
+// mark 请求上下文因客户端断开而取消且处理器链尚未结束时，标记为客户端中途断开
+func (s *clientAbort) mark() {
+	if !s.finished.Load() && errors.Is(s.ctx.Err(), context.Canceled) {
+		s.aborted.Store(true)
+	}
+}
+
+// ClientAbortMiddleware 客户端中途断开检测中间件
+//
+// 监听请求上下文，处理器链结束（响应写出）之前连接关闭时把请求标记为客户端中途断开：
+// 访问日志和指标记为 499，ExceptionHandler / WrapHandler 不把由此产生的 context.Canceled 当作错误，
+// 事务中间件按 onClientAbort 策略提交或回滚。需要开启 server.WithSenseClientDisconnection，
+// 否则连接关闭时请求上下文不会取消
+//
+// Example:
+//
+//	h := server.Default(server.WithSenseClientDisconnection(true))
+//	h.Use(middleware.ClientAbortMiddleware())
+func ClientAbortMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		s := &clientAbort{ctx: ctx}
+		stop := context.AfterFunc(ctx, s.mark)
+		c.Set(clientAbortKey, s)
+
+		c.Next(ctx)
+
+		stop()
+		s.mark() // AfterFunc 在单独的 goroutine 中执行，结束前同步确认一次
+		s.finished.Store(true)
+		if s.aborted.Load() {
+			metrics.GetCounter("web_client_aborts_total", "route", c.FullPath()).Inc()
+		}
+	}
+}
+
+// IsClientAborted 请求是否因客户端在响应写出前断开而中止（未启用 ClientAbortMiddleware 时为 false）
+func IsClientAborted(c *app.RequestContext) bool {
+	s, ok := c.Value(clientAbortKey).(*clientAbort)
+	if !ok {
+		return false
+	}
+	s.mark()
+	return s.aborted.Load()
+}
+
+// IsClientAbortError err 是否为客户端中途断开导致的 context.Canceled（不是服务端错误）
+//
+// Example:
+//
+//	if err != nil && !middleware.IsClientAbortError(c, err) {
+//	    logger.Errorf("查询失败: %v", err)
+//	}
+func IsClientAbortError(c *app.RequestContext, err error) bool {
+	return errors.Is(err, context.Canceled) && IsClientAborted(c)
+}
+
+// ResponseStatus 用于日志和指标的响应状态码：客户端中途断开时为 StatusClientClosedRequest
+func ResponseStatus(c *app.RequestContext) int {
+	if IsClientAborted(c) {
+		return StatusClientClosedRequest
+	}
+	return c.Response.StatusCode()
+}
+
+// ClientContext 客户端断开时取消的上下文（未启用 ClientAbortMiddleware 时为 context.Background()）
+//
+// 用于处理器返回后才开始发送的流式响应（下载、SSE 等），客户端断开后及时停止读取和生成
+//
+// Example:
+//
+//	c.SetBodyStream(web.NewThrottledReader(middleware.ClientContext(c), f, nil), -1)
+func ClientContext(c *app.RequestContext) context.Context {
+	if s, ok := c.Value(clientAbortKey).(*clientAbort); ok {
+		return s.ctx
+	}
+	return context.Background()
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 
+	"github.com/CenJIl/base/logger"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/google/uuid"
 )
@@ -14,7 +15,7 @@ import (
 // 功能：
 //   - 生成 UUID 作为请求 ID
 //   - 设置到响应头 X-Request-ID
-//   - 存储到上下文供后续使用
+//   - 存储到 RequestContext 与传给后续处理函数的 context.Context（logger.Ctx(ctx) 的日志带上 reqId）
 //
 // Example:
 //
@@ -30,7 +31,7 @@ func RequestIDMiddleware() app.HandlerFunc {
 		// 存储到上下文（Handler 可以使用）
 		c.Set("request_id", requestID)
 
-		c.Next(ctx)
+		c.Next(context.WithValue(ctx, RequestIDKey{}, requestID))
 	}
 }
 
@@ -48,8 +49,8 @@ func GetRequestID(c *app.RequestContext) string {
 	return ""
 }
 
-// RequestIDKey 是 context.Context 中存储请求 ID 的键
-type RequestIDKey struct{}
+// RequestIDKey 是 context.Context 中存储请求 ID 的键（与 logger.Ctx 读取的键相同）
+type RequestIDKey = logger.RequestIDKey
 
 // GetRequestIDFromContext 从 context.Context 获取请求 ID
 // 用于 Success/Fail 等响应函数中无法直接访问 RequestContext 的场景
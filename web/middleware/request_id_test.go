@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDMiddleware_ContextCarriesID(t *testing.T) {
+	logger.UpdateLogLevel("info")
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(RequestIDMiddleware())
+	var fromCtx string
+	engine.GET("/orders", func(ctx context.Context, c *app.RequestContext) {
+		fromCtx = GetRequestIDFromContext(ctx)
+		logger.Ctx(ctx).Info("request-id-test")
+		c.String(200, GetRequestID(c))
+	})
+
+	w := ut.PerformRequest(engine, "GET", "/orders", nil)
+	id := w.Header().Get("X-Request-ID")
+	require.NotEmpty(t, id)
+	assert.Equal(t, id, w.Body.String())
+	assert.Equal(t, id, fromCtx)
+
+	record := logger.RecentLogs(1)[0]
+	assert.Equal(t, "request-id-test", record.Message)
+	assert.Equal(t, id, record.Fields["reqId"])
+}
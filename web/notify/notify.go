@@ -0,0 +1,401 @@
+// Package notify 定时通知：持久化待发送通知，到期后按通道（邮件 / WebSocket / Webhook）投递
+//
+// 投递失败按指数退避重试，超过次数进入死信；投递前可以取消或改期。
+// 多实例部署时每个实例运行一个调度器，由存储保证同一条通知只被一个实例投递
+//
+// 使用方式：
+//
+//	templates, _ := notify.LoadTemplates("locales", "zh-CN")
+//	scheduler := notify.NewScheduler(notify.NewSQLStore(nil, config.Database.Driver), config.Notify, nil)
+//	scheduler.UseTemplates(templates)
+//	scheduler.UseLanguageResolver(queries.GetUserLanguage)
+//	scheduler.RegisterSender(notify.ChannelEmail, notify.EmailSender{Mailer: mail, Address: queries.GetUserEmail})
+//	scheduler.RegisterSender(notify.ChannelWS, notify.WSSender{Hub: hub})
+//	web.RegisterComponent(scheduler.Component(web.ComponentDatabase))
+//
+//	id, err := scheduler.Schedule(ctx, notify.Notification{
+//	    UserID:       userID,
+//	    Channel:      notify.ChannelEmail,
+//	    TemplateName: "appointmentReminder",
+//	    Data:         map[string]any{"Name": user.Name, "Time": "10:00"},
+//	    DeliverAt:    appointment.StartAt.Add(-24 * time.Hour),
+//	    DedupeKey:    "appointment-reminder-" + appointment.ID,
+//	})
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/google/uuid"
+)
+
+// ComponentNotify 通知调度组件名
+const ComponentNotify = "notify"
+
+// 投递通道
+const (
+	ChannelEmail   = "email"
+	ChannelWS      = "ws"
+	ChannelWebhook = "webhook"
+)
+
+// 通知状态
+const (
+	StatusPending    = "pending"    // 等待投递（含等待重试）
+	StatusSending    = "sending"    // 已被某个实例领取
+	StatusSent       = "sent"       // 投递成功
+	StatusCancelled  = "cancelled"  // 已取消
+	StatusDead       = "dead"       // 重试耗尽或不可重试（死信）
+	StatusSuppressed = "suppressed" // 用户通知偏好不接收，未投递
+)
+
+var (
+	// ErrNotFound 通知不存在
+	ErrNotFound = errors.New("notification not found")
+	// ErrNotPending 通知已被领取、投递或取消，不能再取消或改期
+	ErrNotPending = errors.New("notification is not pending")
+)
+
+// Notification 定时通知
+type Notification struct {
+	ID           int64
+	UserID       string
+	Channel      string
+	Category     string // 通知类别（web.NotifyTransactional / NotifyMarketing / NotifySecurity），为空时按交易通知
+	TemplateName string
+	Data         map[string]any
+	DeliverAt    time.Time
+	DedupeKey    string // 非空时同一个 key 只调度一次（重复调用 Schedule 返回已有通知）
+
+	Status    string
+	Attempts  int
+	LastError string
+}
+
+// Config 通知调度配置
+//
+// Example:
+//
+//	[notify]
+//	pollInterval = 5     # 轮询间隔（秒）
+//	batchSize = 100      # 每次领取的最大条数
+//	maxAttempts = 5      # 最大投递次数，超过后进入死信
+//	baseBackoff = 30     # 首次重试间隔（秒），之后逐次翻倍
+//	maxBackoff = 3600    # 最大重试间隔（秒）
+//	lease = 300          # 领取租约（秒），实例崩溃后其他实例在租约到期后接手
+type Config struct {
+	PollInterval int `toml:"pollInterval"`
+	BatchSize    int `toml:"batchSize"`
+	MaxAttempts  int `toml:"maxAttempts"`
+	BaseBackoff  int `toml:"baseBackoff"`
+	MaxBackoff   int `toml:"maxBackoff"`
+	Lease        int `toml:"lease"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.PollInterval <= 0 {
+		c.PollInterval = 5
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = 30
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 3600
+	}
+	if c.Lease <= 0 {
+		c.Lease = 300
+	}
+	return c
+}
+
+// Scheduler 通知调度器
+type Scheduler struct {
+	store     Store
+	config    Config
+	clock     common.Clock
+	owner     string // 实例标识（领取租约的持有者）
+	templates *Templates
+	language  LanguageResolver
+	timezone  TimezoneResolver
+	senders   map[string]Sender
+}
+
+// NewScheduler 创建调度器（clock 为 nil 时使用系统时钟）
+func NewScheduler(store Store, config Config, clock common.Clock) *Scheduler {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		store:   store,
+		config:  config.withDefaults(),
+		clock:   clock,
+		owner:   fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8]),
+		senders: make(map[string]Sender),
+	}
+}
+
+// UseTemplates 设置通知模板（未设置或 TemplateName 为空时不渲染）
+func (s *Scheduler) UseTemplates(t *Templates) {
+	s.templates = t
+}
+
+// UseLanguageResolver 设置用户语言查询（未设置时使用模板的 fallback 语言）
+func (s *Scheduler) UseLanguageResolver(r LanguageResolver) {
+	s.language = r
+}
+
+// UseTimezoneResolver 设置用户时区查询（模板中 formatTime 使用；未设置时使用 web 的默认时区）
+func (s *Scheduler) UseTimezoneResolver(r TimezoneResolver) {
+	s.timezone = r
+}
+
+// RegisterSender 注册通道发送器（在 Start 之前调用）
+func (s *Scheduler) RegisterSender(channel string, sender Sender) {
+	s.senders[channel] = sender
+}
+
+// Schedule 调度一条通知，返回通知 ID（DeliverAt 为零值时立即投递）
+func (s *Scheduler) Schedule(ctx context.Context, n Notification) (int64, error) {
+	if n.UserID == "" {
+		return 0, errors.New("notify: UserID 不能为空")
+	}
+	if _, ok := s.senders[n.Channel]; !ok {
+		return 0, fmt.Errorf("notify: 通道 %q 未注册发送器", n.Channel)
+	}
+	if n.Category == "" {
+		n.Category = web.NotifyTransactional
+	}
+	if n.DeliverAt.IsZero() {
+		n.DeliverAt = s.clock.Now()
+	}
+	id, created, err := s.store.Insert(ctx, &n)
+	if err != nil {
+		return 0, fmt.Errorf("notify: 保存通知失败: %w", err)
+	}
+	if created {
+		metrics.GetCounter("notify_scheduled_total", "channel", n.Channel).Inc()
+	}
+	return id, nil
+}
+
+// ScheduleMany 向多个用户调度同一条通知（n.UserID 被忽略），返回通知 ID（按用户顺序）
+//
+// 调度前按用户通知偏好一次性过滤，不接收的用户不写入（记录为被拦截的投递）；
+// DedupeKey 非空时按用户追加 ":<userID>" 后缀
+//
+// 使用方式：
+//
+//	ids, err := scheduler.ScheduleMany(ctx, subscriberIDs, notify.Notification{
+//	    Channel:      notify.ChannelEmail,
+//	    Category:     web.NotifyMarketing,
+//	    TemplateName: "springSale",
+//	    DedupeKey:    "spring-sale-2026",
+//	})
+func (s *Scheduler) ScheduleMany(ctx context.Context, userIDs []string, n Notification) ([]int64, error) {
+	if n.Category == "" {
+		n.Category = web.NotifyTransactional
+	}
+	recipients, err := web.FilterNotifyRecipients(ctx, userIDs, n.Category, n.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("notify: %w", err)
+	}
+	dedupe := n.DedupeKey
+	ids := make([]int64, 0, len(recipients))
+	for _, userID := range recipients {
+		n.UserID = userID
+		if dedupe != "" {
+			n.DedupeKey = dedupe + ":" + userID
+		}
+		id, err := s.Schedule(ctx, n)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Cancel 取消尚未投递的通知（已领取或已投递时返回 ErrNotPending）
+func (s *Scheduler) Cancel(ctx context.Context, id int64) error {
+	if err := s.store.Cancel(ctx, id); err != nil {
+		return err
+	}
+	metrics.GetCounter("notify_cancelled_total").Inc()
+	return nil
+}
+
+// Reschedule 修改尚未投递的通知的投递时间
+func (s *Scheduler) Reschedule(ctx context.Context, id int64, at time.Time) error {
+	return s.store.Reschedule(ctx, id, at)
+}
+
+// Get 查询通知状态
+func (s *Scheduler) Get(ctx context.Context, id int64) (Notification, error) {
+	return s.store.Get(ctx, id)
+}
+
+// DispatchDue 领取并投递全部到期通知，返回处理的条数
+func (s *Scheduler) DispatchDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := s.clock.Now()
+		lease := time.Duration(s.config.Lease) * time.Second
+		batch, err := s.store.Claim(ctx, now, s.config.BatchSize, s.owner, now.Add(lease))
+		if err != nil {
+			return total, fmt.Errorf("notify: 领取通知失败: %w", err)
+		}
+		for _, n := range batch {
+			s.deliver(ctx, n)
+		}
+		total += len(batch)
+		if len(batch) < s.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// deliver 投递一条已领取的通知并记录结果
+func (s *Scheduler) deliver(ctx context.Context, n Notification) {
+	// 投递时再检查一次用户通知偏好（调度之后用户可能已经关闭）；查询失败按投递失败稍后重试
+	allowed, err := web.EnforceNotify(ctx, n.UserID, notificationCategory(n), n.Channel)
+	if err == nil && allowed {
+		// 单次投递不超过租约的一半，避免租约过期后被其他实例重复投递
+		sendCtx, cancel := context.WithTimeout(web.NotifyPrechecked(ctx), time.Duration(s.config.Lease)*time.Second/2)
+		err = s.send(sendCtx, n)
+		cancel()
+	}
+
+	outcome := Outcome{Attempts: n.Attempts + 1}
+	switch {
+	case err == nil && !allowed:
+		outcome.Status = StatusSuppressed
+	case err == nil:
+		outcome.Status = StatusSent
+		metrics.GetCounter("notify_sent_total", "channel", n.Channel).Inc()
+	case IsPermanent(err) || outcome.Attempts >= s.config.MaxAttempts:
+		outcome.Status, outcome.Error = StatusDead, err.Error()
+		metrics.GetCounter("notify_failed_total", "channel", n.Channel).Inc()
+		metrics.GetCounter("notify_dead_total", "channel", n.Channel).Inc()
+		logger.Errorf("[Notify] 通知 %d 进入死信（第 %d 次投递）: %v", n.ID, outcome.Attempts, err)
+	default:
+		outcome.Status, outcome.Error = StatusPending, err.Error()
+		outcome.NextAttempt = s.clock.Now().Add(s.backoff(outcome.Attempts))
+		metrics.GetCounter("notify_failed_total", "channel", n.Channel).Inc()
+		logger.Warnf("[Notify] 通知 %d 投递失败（第 %d 次），%v 后重试: %v", n.ID, outcome.Attempts, outcome.NextAttempt.Sub(s.clock.Now()), err)
+	}
+
+	ok, err := s.store.Complete(context.Background(), n.ID, s.owner, outcome)
+	if err != nil {
+		logger.Errorf("[Notify] 记录通知 %d 投递结果失败: %v", n.ID, err)
+	} else if !ok {
+		logger.Warnf("[Notify] 通知 %d 的租约已被其他实例接手，丢弃本次结果", n.ID)
+	}
+}
+
+// send 按用户语言渲染并交给通道发送器
+func (s *Scheduler) send(ctx context.Context, n Notification) error {
+	sender, ok := s.senders[n.Channel]
+	if !ok {
+		return Permanent(fmt.Errorf("通道 %q 未注册发送器", n.Channel))
+	}
+	msg := Message{Notification: n}
+	if s.language != nil {
+		lang, err := s.language(ctx, n.UserID)
+		if err != nil {
+			logger.Warnf("[Notify] 查询用户 %s 的语言失败，使用默认语言: %v", n.UserID, err)
+		}
+		msg.Language = lang
+	}
+	if s.templates != nil && n.TemplateName != "" {
+		renderCtx := ctx
+		if s.timezone != nil {
+			tz, err := s.timezone(ctx, n.UserID)
+			if err != nil {
+				logger.Warnf("[Notify] 查询用户 %s 的时区失败，使用默认时区: %v", n.UserID, err)
+			}
+			renderCtx = web.WithLocale(ctx, web.LocaleFor(msg.Language, tz))
+		}
+		subject, body, used, err := s.templates.RenderContext(renderCtx, n.TemplateName, msg.Language, n.Data)
+		if err != nil {
+			return Permanent(err)
+		}
+		msg.Subject, msg.Body, msg.Language = subject, body, used
+	}
+	return sender.Send(ctx, msg)
+}
+
+// notificationCategory 通知类别（升级前写入的通知没有类别，按交易通知）
+func notificationCategory(n Notification) string {
+	if n.Category == "" {
+		return web.NotifyTransactional
+	}
+	return n.Category
+}
+
+// backoff 第 attempts 次失败后的重试间隔
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	return common.Backoff(time.Duration(s.config.BaseBackoff)*time.Second, time.Duration(s.config.MaxBackoff)*time.Second, attempts)
+}
+
+// Run 按轮询间隔投递到期通知，直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.config.PollInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DispatchDue(ctx); err != nil {
+				logger.Errorf("[Notify] %v", err)
+			}
+		}
+	}
+}
+
+// Component 调度器的生命周期组件（dependsOn 通常为 web.ComponentDatabase）
+func (s *Scheduler) Component(dependsOn ...string) web.Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return web.Component{
+		Name:      ComponentNotify,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				s.Run(runCtx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,453 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/common/governor"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t0 = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+type sentMail struct {
+	to      []string
+	subject string
+	body    string
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *fakeMailer) Send(to []string, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
+
+type fakeHub struct {
+	mu       sync.Mutex
+	online   map[string]bool
+	messages map[string][][]byte
+}
+
+func (h *fakeHub) SendToUser(userID string, message []byte) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.online[userID] {
+		return 0
+	}
+	h.messages[userID] = append(h.messages[userID], message)
+	return 1
+}
+
+func newTestScheduler(t *testing.T, store Store, clock common.Clock) *Scheduler {
+	templates, err := LoadTemplates("../../locales", "zh-CN")
+	require.NoError(t, err)
+	s := NewScheduler(store, Config{MaxAttempts: 3, BaseBackoff: 60}, clock)
+	s.UseTemplates(templates)
+	s.UseLanguageResolver(func(ctx context.Context, userID string) (string, error) {
+		switch userID {
+		case "bob":
+			return "en-GB", nil
+		case "broken":
+			return "", errors.New("db down")
+		}
+		return "zh-CN", nil
+	})
+	return s
+}
+
+var reminderData = map[string]any{"Name": "Alice", "Service": "体检", "Time": "10:00"}
+
+func TestScheduler_EmailFastForward(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	store := NewMemoryStore()
+	s := newTestScheduler(t, store, clock)
+	mailer := &fakeMailer{}
+	s.RegisterSender(ChannelEmail, EmailSender{Mailer: mailer, Address: func(ctx context.Context, userID string) (string, error) {
+		return userID + "@example.com", nil
+	}})
+	ctx := context.Background()
+	scheduledBefore := metrics.GetCounter("notify_scheduled_total", "channel", ChannelEmail).Value()
+	sentBefore := metrics.GetCounter("notify_sent_total", "channel", ChannelEmail).Value()
+
+	alice, err := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelEmail, TemplateName: "appointmentReminder", Data: reminderData, DeliverAt: t0.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	_, err = s.Schedule(ctx, Notification{UserID: "bob", Channel: ChannelEmail, TemplateName: "appointmentReminder", Data: reminderData, DeliverAt: t0.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	_, err = s.Schedule(ctx, Notification{UserID: "broken", Channel: ChannelEmail, TemplateName: "appointmentReminder", Data: reminderData, DeliverAt: t0.Add(24 * time.Hour)})
+	require.NoError(t, err)
+
+	n, err := s.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	clock.Advance(24 * time.Hour)
+	n, err = s.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	require.Len(t, mailer.sent, 3)
+	assert.Equal(t, []string{"alice@example.com"}, mailer.sent[0].to)
+	assert.Equal(t, "预约提醒", mailer.sent[0].subject)
+	assert.Equal(t, "Alice，您预约的 体检 将于明天 10:00 开始。", mailer.sent[0].body)
+	// en-GB 匹配到 en-US；语言查询失败时使用默认语言
+	assert.Equal(t, "Appointment reminder", mailer.sent[1].subject)
+	assert.Equal(t, "预约提醒", mailer.sent[2].subject)
+
+	got, err := s.Get(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSent, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, scheduledBefore+3, metrics.GetCounter("notify_scheduled_total", "channel", ChannelEmail).Value())
+	assert.Equal(t, sentBefore+3, metrics.GetCounter("notify_sent_total", "channel", ChannelEmail).Value())
+
+	// 已投递的通知不会再次投递
+	clock.Advance(time.Hour)
+	n, _ = s.DispatchDue(ctx)
+	assert.Zero(t, n)
+}
+
+func TestScheduler_WSRetryAndDeadLetter(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	s := newTestScheduler(t, NewMemoryStore(), clock)
+	hub := &fakeHub{online: map[string]bool{}, messages: map[string][][]byte{}}
+	s.RegisterSender(ChannelWS, WSSender{Hub: hub})
+	ctx := context.Background()
+	failedBefore := metrics.GetCounter("notify_failed_total", "channel", ChannelWS).Value()
+
+	id, err := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, TemplateName: "appointmentReminder", Data: reminderData, DeliverAt: t0.Add(time.Hour)})
+	require.NoError(t, err)
+
+	// 用户离线：60 秒后重试
+	clock.Advance(time.Hour)
+	s.DispatchDue(ctx)
+	got, _ := s.Get(ctx, id)
+	assert.Equal(t, StatusPending, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+	assert.Equal(t, ErrUserOffline.Error(), got.LastError)
+	assert.Equal(t, clock.Now().Add(60*time.Second), got.DeliverAt)
+
+	// 退避未到不投递
+	clock.Advance(59 * time.Second)
+	n, _ := s.DispatchDue(ctx)
+	assert.Zero(t, n)
+
+	// 上线后投递成功
+	hub.mu.Lock()
+	hub.online["alice"] = true
+	hub.mu.Unlock()
+	clock.Advance(time.Second)
+	s.DispatchDue(ctx)
+	got, _ = s.Get(ctx, id)
+	assert.Equal(t, StatusSent, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	require.Len(t, hub.messages["alice"], 1)
+	var payload WSPayload
+	require.NoError(t, json.Unmarshal(hub.messages["alice"][0], &payload))
+	assert.Equal(t, WSPayload{Type: "notification", ID: id, Template: "appointmentReminder", Subject: "预约提醒",
+		Body: "Alice，您预约的 体检 将于明天 10:00 开始。", Data: reminderData}, payload)
+
+	// 一直离线：第 3 次失败后进入死信，退避逐次翻倍
+	dead, _ := s.Schedule(ctx, Notification{UserID: "carol", Channel: ChannelWS, TemplateName: "appointmentReminder"})
+	for _, wait := range []time.Duration{0, 60 * time.Second, 120 * time.Second} {
+		clock.Advance(wait)
+		n, _ := s.DispatchDue(ctx)
+		assert.Equal(t, 1, n)
+	}
+	got, _ = s.Get(ctx, dead)
+	assert.Equal(t, StatusDead, got.Status)
+	assert.Equal(t, 3, got.Attempts)
+	assert.Equal(t, failedBefore+4, metrics.GetCounter("notify_failed_total", "channel", ChannelWS).Value())
+
+	// 模板不存在：不重试
+	missing, _ := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, TemplateName: "nope"})
+	s.DispatchDue(ctx)
+	got, _ = s.Get(ctx, missing)
+	assert.Equal(t, StatusDead, got.Status)
+	assert.Equal(t, 1, got.Attempts)
+}
+
+func TestScheduler_Webhook(t *testing.T) {
+	var calls atomic.Int64
+	var mu sync.Mutex
+	var keys []string
+	var last WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		last = payload
+		mu.Unlock()
+		switch {
+		case payload.UserID == "gone":
+			w.WriteHeader(http.StatusGone)
+		case calls.Add(1) == 1:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	clock := common.NewFakeClock(t0)
+	s := newTestScheduler(t, NewMemoryStore(), clock)
+	s.RegisterSender(ChannelWebhook, WebhookSender{URL: server.URL})
+	ctx := context.Background()
+
+	id, err := s.Schedule(ctx, Notification{UserID: "bob", Channel: ChannelWebhook, TemplateName: "appointmentReminder", Data: reminderData, DeliverAt: t0.Add(time.Minute)})
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	s.DispatchDue(ctx)
+	clock.Advance(time.Minute)
+	s.DispatchDue(ctx)
+
+	got, _ := s.Get(ctx, id)
+	assert.Equal(t, StatusSent, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	// 重试使用相同的幂等键
+	assert.Equal(t, []string{"notification-1", "notification-1"}, keys)
+	assert.Equal(t, "en-US", last.Language)
+	assert.Equal(t, "Appointment reminder", last.Subject)
+	assert.Equal(t, t0.Add(2*time.Minute).Unix(), last.DeliverAt)
+
+	// 4xx 不重试
+	gone, _ := s.Schedule(ctx, Notification{UserID: "gone", Channel: ChannelWebhook})
+	s.DispatchDue(ctx)
+	got, _ = s.Get(ctx, gone)
+	assert.Equal(t, StatusDead, got.Status)
+	assert.Contains(t, got.LastError, "410")
+}
+
+func TestScheduler_CancelAndReschedule(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	s := newTestScheduler(t, NewMemoryStore(), clock)
+	var delivered []int64
+	s.RegisterSender(ChannelWS, SenderFunc(func(ctx context.Context, msg Message) error {
+		delivered = append(delivered, msg.ID)
+		return nil
+	}))
+	ctx := context.Background()
+
+	cancelled, _ := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, DeliverAt: t0.Add(time.Hour)})
+	moved, _ := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, DeliverAt: t0.Add(time.Hour)})
+	require.NoError(t, s.Cancel(ctx, cancelled))
+	require.NoError(t, s.Reschedule(ctx, moved, t0.Add(3*time.Hour)))
+
+	clock.Advance(time.Hour)
+	s.DispatchDue(ctx)
+	assert.Empty(t, delivered)
+
+	clock.Advance(2 * time.Hour)
+	s.DispatchDue(ctx)
+	assert.Equal(t, []int64{moved}, delivered)
+
+	// 已投递或已取消的通知不能再修改
+	assert.ErrorIs(t, s.Cancel(ctx, moved), ErrNotPending)
+	assert.ErrorIs(t, s.Reschedule(ctx, cancelled, t0), ErrNotPending)
+	assert.ErrorIs(t, s.Cancel(ctx, 999), ErrNotFound)
+
+	got, _ := s.Get(ctx, cancelled)
+	assert.Equal(t, StatusCancelled, got.Status)
+
+	// 未注册的通道与缺少用户在调度时拒绝
+	_, err := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelEmail})
+	assert.Error(t, err)
+	_, err = s.Schedule(ctx, Notification{Channel: ChannelWS})
+	assert.Error(t, err)
+}
+
+func TestScheduler_MultiReplica(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	store := NewMemoryStore()
+	var mu sync.Mutex
+	counts := map[int64]int{}
+	sender := SenderFunc(func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		counts[msg.ID]++
+		mu.Unlock()
+		return nil
+	})
+
+	replicas := make([]*Scheduler, 3)
+	for i := range replicas {
+		replicas[i] = NewScheduler(store, Config{BatchSize: 7}, clock)
+		replicas[i].RegisterSender(ChannelWS, sender)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		_, err := replicas[i%3].Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, DeliverAt: t0.Add(time.Minute)})
+		require.NoError(t, err)
+	}
+	// 各实例重复调度同一条提醒：DedupeKey 保证只保存一条
+	var ids []int64
+	for _, r := range replicas {
+		id, err := r.Schedule(ctx, Notification{UserID: "bob", Channel: ChannelWS, DedupeKey: "reminder-42"})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	assert.Equal(t, ids[0], ids[1])
+	assert.Equal(t, ids[0], ids[2])
+
+	clock.Advance(time.Minute)
+	var wg sync.WaitGroup
+	for _, r := range replicas {
+		wg.Add(1)
+		go func(r *Scheduler) {
+			defer wg.Done()
+			_, err := r.DispatchDue(ctx)
+			assert.NoError(t, err)
+		}(r)
+	}
+	wg.Wait()
+
+	assert.Len(t, counts, 101)
+	for id, c := range counts {
+		assert.Equal(t, 1, c, "notification %d", id)
+	}
+}
+
+func TestScheduler_CrashedReplicaLease(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	store := NewMemoryStore()
+	s := NewScheduler(store, Config{Lease: 60}, clock)
+	var delivered atomic.Int64
+	s.RegisterSender(ChannelWS, SenderFunc(func(ctx context.Context, msg Message) error {
+		delivered.Add(1)
+		return nil
+	}))
+	ctx := context.Background()
+
+	id, _ := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS})
+	// 另一个实例领取后崩溃
+	claimed, err := store.Claim(ctx, clock.Now(), 10, "crashed", clock.Now().Add(60*time.Second))
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	n, _ := s.DispatchDue(ctx)
+	assert.Zero(t, n)
+	clock.Advance(60 * time.Second)
+	n, _ = s.DispatchDue(ctx)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(1), delivered.Load())
+
+	// 崩溃实例恢复后提交的结果被忽略
+	ok, err := store.Complete(ctx, id, "crashed", Outcome{Status: StatusDead, Attempts: 1})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	got, _ := s.Get(ctx, id)
+	assert.Equal(t, StatusSent, got.Status)
+}
+
+func TestTemplates_RenderLocalized(t *testing.T) {
+	templates := NewTemplates("zh-CN")
+	tpl := Template{Subject: "{{formatTime .StartAt \"date\"}}", Body: "{{formatTime .StartAt \"long\"}} {{formatCurrency .Fee \"CNY\"}}"}
+	require.NoError(t, templates.Add("booking", "zh-CN", tpl))
+	require.NoError(t, templates.Add("booking", "en-US", tpl))
+	data := map[string]any{"StartAt": time.Date(2026, 10, 15, 6, 30, 0, 0, time.UTC), "Fee": 1280}
+
+	ctx := web.WithLocale(context.Background(), web.LocaleFor("", "Asia/Shanghai"))
+	subject, body, used, err := templates.RenderContext(ctx, "booking", "zh", data)
+	require.NoError(t, err)
+	assert.Equal(t, "zh-CN", used)
+	assert.Equal(t, "2026/10/15", subject)
+	assert.Equal(t, "2026年10月15日 14:30 CST ¥1,280.00", body)
+
+	ctx = web.WithLocale(context.Background(), web.LocaleFor("", "America/New_York"))
+	subject, body, used, err = templates.RenderContext(ctx, "booking", "en-GB", data)
+	require.NoError(t, err)
+	assert.Equal(t, "en-US", used)
+	assert.Equal(t, "10/15/2026", subject)
+	assert.Equal(t, "October 15, 2026 2:30 AM EDT CN¥1,280.00", body)
+}
+
+func TestWebhookSender_GovernorBacksOffOn429(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	governor.Init(governor.Config{Limits: map[string]governor.Limit{"partnerX": {Rate: 100, Burst: 100, Adaptive: true}}})
+	t.Cleanup(func() { governor.Init(governor.Config{}) })
+
+	sender := WebhookSender{URL: server.URL, Governor: "partnerX"}
+	err := sender.Send(context.Background(), Message{Notification: Notification{ID: 1, UserID: "bob"}})
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err), "429 稍后重试")
+	assert.Equal(t, 0.5, governor.Default().Factor("partnerX"))
+
+	// Retry-After 期间不再发出请求
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sender.Send(ctx, Message{Notification: Notification{ID: 2, UserID: "bob"}}), context.DeadlineExceeded)
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestScheduler_RespectsNotifyPreferences(t *testing.T) {
+	clock := common.NewFakeClock(t0)
+	s := newTestScheduler(t, NewMemoryStore(), clock)
+	mailer := &fakeMailer{}
+	email := EmailSender{Mailer: mailer, Address: func(ctx context.Context, userID string) (string, error) {
+		return userID + "@example.com", nil
+	}}
+	s.RegisterSender(ChannelEmail, email)
+	hub := &fakeHub{online: map[string]bool{"alice": true}, messages: map[string][][]byte{}}
+	s.RegisterSender(ChannelWS, WSSender{Hub: hub})
+	prefs := web.NewMemoryNotifyPrefStore()
+	web.SetNotifyPrefStore(prefs, nil)
+	t.Cleanup(func() { web.SetNotifyPrefStore(nil, nil) })
+	ctx := context.Background()
+	require.NoError(t, prefs.Save(ctx, "alice", []web.NotifyPreference{{Category: web.NotifyMarketing, Channel: ChannelEmail, Enabled: true, Source: web.NotifySourceUser}}))
+
+	// 批量调度时没有开启营销通知的用户不写入
+	ids, err := s.ScheduleMany(ctx, []string{"alice", "bob"}, Notification{Channel: ChannelEmail, Category: web.NotifyMarketing, TemplateName: "appointmentReminder", Data: reminderData, DedupeKey: "sale"})
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+	got, err := s.Get(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.UserID)
+	assert.Equal(t, "sale:alice", got.DedupeKey)
+
+	// 调度后用户关闭：投递时拦截，不重试
+	transactional, err := s.Schedule(ctx, Notification{UserID: "alice", Channel: ChannelWS, TemplateName: "appointmentReminder", Data: reminderData})
+	require.NoError(t, err)
+	_, err = web.UpdateNotifyPreferences(ctx, "alice", []web.NotifyPreferenceChange{{Category: web.NotifyTransactional, Channel: ChannelWS, Enabled: false}})
+	require.NoError(t, err)
+
+	n, err := s.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, mailer.sent, 1)
+	assert.Empty(t, hub.messages["alice"])
+	got, err = s.Get(ctx, transactional)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuppressed, got.Status)
+	assert.Equal(t, web.NotifyTransactional, got.Category, "未指定类别时按交易通知")
+
+	// 直接使用发送器同样检查偏好
+	err = email.Send(ctx, Message{Notification: Notification{UserID: "bob", Category: web.NotifyMarketing}})
+	assert.ErrorIs(t, err, ErrSuppressed)
+	assert.True(t, IsPermanent(err))
+	assert.Len(t, mailer.sent, 1)
+}
@@ -0,0 +1,392 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/CenJIl/base/common/governor"
+	"github.com/CenJIl/base/web"
+)
+
+// ErrUserOffline 用户没有在线连接（WebSocket 通道按失败处理，稍后重试）
+var ErrUserOffline = errors.New("user offline")
+
+// Message 交给发送器的通知（已按用户语言渲染）
+type Message struct {
+	Notification
+	Language string
+	Subject  string
+	Body     string
+}
+
+// Sender 通道发送器
+//
+// 返回 Permanent 包装的错误时不再重试，直接进入死信
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SenderFunc 函数适配器
+type SenderFunc func(ctx context.Context, msg Message) error
+
+// Send 实现 Sender 接口
+func (f SenderFunc) Send(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// permanentError 不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记不可重试的错误（收件地址无效、模板不存在等）
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 是否为不可重试的错误
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// ErrSuppressed 用户通知偏好不接收该类别的通知（发送器直接使用时返回，不重试）
+var ErrSuppressed = errors.New("notification suppressed by user preference")
+
+// checkPreference 发送前检查用户通知偏好（调度器已检查过时直接通过）
+//
+// 发送器也可以脱离调度器直接调用，所以每个通道在发送前都要检查
+func checkPreference(ctx context.Context, msg Message, channel string) error {
+	ok, err := web.EnforceNotify(ctx, msg.UserID, notificationCategory(msg.Notification), channel)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return Permanent(ErrSuppressed)
+	}
+	return nil
+}
+
+// Mailer 邮件发送接口（*email.QQMail 满足此接口）
+type Mailer interface {
+	Send(to []string, subject, body string) error
+}
+
+// EmailSender 邮件通道
+type EmailSender struct {
+	Mailer   Mailer
+	Address  func(ctx context.Context, userID string) (string, error) // 查询用户邮箱
+	Governor string                                                   // 出站限速器名（如 "sendgrid"），发送前等待令牌
+}
+
+// Send 实现 Sender 接口
+func (s EmailSender) Send(ctx context.Context, msg Message) error {
+	if err := checkPreference(ctx, msg, ChannelEmail); err != nil {
+		return err
+	}
+	addr, err := s.Address(ctx, msg.UserID)
+	if err != nil {
+		return fmt.Errorf("查询用户邮箱失败: %w", err)
+	}
+	if addr == "" {
+		return Permanent(fmt.Errorf("用户 %s 没有邮箱", msg.UserID))
+	}
+	if err := governor.Wait(ctx, s.Governor); err != nil {
+		return err
+	}
+	return s.Mailer.Send([]string{addr}, msg.Subject, msg.Body)
+}
+
+// UserHub 按用户推送消息（*ws.Hub 满足此接口）
+type UserHub interface {
+	SendToUser(userID string, message []byte) int
+}
+
+// WSPayload WebSocket 通道推送的消息
+type WSPayload struct {
+	Type     string         `json:"type"` // 固定为 "notification"
+	ID       int64          `json:"id"`
+	Template string         `json:"template"`
+	Subject  string         `json:"subject,omitempty"`
+	Body     string         `json:"body,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// WSSender WebSocket 通道（只推送到本实例上的连接；多实例部署时配合房间广播或粘性会话使用）
+type WSSender struct {
+	Hub UserHub
+}
+
+// Send 实现 Sender 接口
+func (s WSSender) Send(ctx context.Context, msg Message) error {
+	if err := checkPreference(ctx, msg, ChannelWS); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(WSPayload{
+		Type:     "notification",
+		ID:       msg.ID,
+		Template: msg.TemplateName,
+		Subject:  msg.Subject,
+		Body:     msg.Body,
+		Data:     msg.Data,
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+	if s.Hub.SendToUser(msg.UserID, payload) == 0 {
+		return ErrUserOffline
+	}
+	return nil
+}
+
+// WebhookPayload Webhook 通道的请求体
+type WebhookPayload struct {
+	ID        int64          `json:"id"`
+	UserID    string         `json:"userId"`
+	Template  string         `json:"template"`
+	Language  string         `json:"language"`
+	Subject   string         `json:"subject,omitempty"`
+	Body      string         `json:"body,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	DeliverAt int64          `json:"deliverAt"` // 本次投递的计划时间（重试时为重试时间）
+}
+
+// WebhookSender Webhook 通道
+//
+// 请求带 Idempotency-Key 头（同一条通知的重试相同），接收方据此去重；
+// 4xx 视为不可重试，5xx、429 与网络错误稍后重试。
+// 设置 Governor 时发送前等待出站限速令牌，429 时按 Retry-After 暂停该限速器
+type WebhookSender struct {
+	URL      string
+	Client   *http.Client // 为 nil 时使用 5 秒超时的默认客户端
+	Governor string       // 出站限速器名（可选）
+}
+
+var defaultWebhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// Send 实现 Sender 接口
+func (s WebhookSender) Send(ctx context.Context, msg Message) error {
+	if err := checkPreference(ctx, msg, ChannelWebhook); err != nil {
+		return err
+	}
+	body, err := json.Marshal(WebhookPayload{
+		ID:        msg.ID,
+		UserID:    msg.UserID,
+		Template:  msg.TemplateName,
+		Language:  msg.Language,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+		Data:      msg.Data,
+		DeliverAt: msg.DeliverAt.Unix(),
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "notification-"+strconv.FormatInt(msg.ID, 10))
+
+	if err := governor.Wait(ctx, s.Governor); err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		governor.Throttled(s.Governor, governor.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		return fmt.Errorf("webhook 返回 %d", resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("webhook 返回 %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return Permanent(fmt.Errorf("webhook 返回 %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// LanguageResolver 查询用户的首选语言（如 "zh-CN"）
+type LanguageResolver func(ctx context.Context, userID string) (string, error)
+
+// TimezoneResolver 查询用户的时区（IANA 名称，如 "Asia/Shanghai"）
+type TimezoneResolver func(ctx context.Context, userID string) (string, error)
+
+// Template 通知模板（text/template 语法，数据为 Notification.Data）
+type Template struct {
+	Subject string `toml:"subject"`
+	Body    string `toml:"body"`
+}
+
+type parsedTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// Templates 多语言通知模板
+type Templates struct {
+	fallback string
+	mu       sync.RWMutex
+	byName   map[string]map[string]parsedTemplate // 模板名 -> 语言 -> 模板
+}
+
+// NewTemplates 创建模板集（fallback 为用户语言没有对应模板时使用的语言）
+func NewTemplates(fallback string) *Templates {
+	return &Templates{fallback: fallback, byName: make(map[string]map[string]parsedTemplate)}
+}
+
+// LoadTemplates 从本地化目录加载模板（与 i18n 共用 locales/<语言>.toml）
+//
+// 包含 body 字段的表视为通知模板，表名为模板名：
+//
+//	[appointmentReminder]
+//	subject = "预约提醒"
+//	body = "{{.Name}}，您预约的 {{.Service}} 将于明天 {{.Time}} 开始"
+func LoadTemplates(dir, fallback string) (*Templates, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	t := NewTemplates(fallback)
+	for _, file := range files {
+		lang := strings.TrimSuffix(filepath.Base(file), ".toml")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var entries map[string]any
+		if err := toml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", file, err)
+		}
+		for name, v := range entries {
+			table, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			body, ok := table["body"].(string)
+			if !ok {
+				continue
+			}
+			subject, _ := table["subject"].(string)
+			if err := t.Add(name, lang, Template{Subject: subject, Body: body}); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// Add 添加模板
+func (t *Templates) Add(name, lang string, tpl Template) error {
+	funcs := web.TemplateFuncs(context.Background())
+	subject, err := template.New(name + ".subject").Option("missingkey=zero").Funcs(funcs).Parse(tpl.Subject)
+	if err != nil {
+		return fmt.Errorf("模板 %s(%s) 标题解析失败: %w", name, lang, err)
+	}
+	body, err := template.New(name + ".body").Option("missingkey=zero").Funcs(funcs).Parse(tpl.Body)
+	if err != nil {
+		return fmt.Errorf("模板 %s(%s) 正文解析失败: %w", name, lang, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byName[name] == nil {
+		t.byName[name] = make(map[string]parsedTemplate)
+	}
+	t.byName[name][lang] = parsedTemplate{subject: subject, body: body}
+	return nil
+}
+
+// Render 按语言渲染模板，返回实际使用的语言
+//
+// 语言匹配顺序：完全匹配（zh-CN）→ 同一主语言（zh、zh-TW）→ fallback
+func (t *Templates) Render(name, lang string, data map[string]any) (subject, body, used string, err error) {
+	return t.RenderContext(context.Background(), name, lang, data)
+}
+
+// RenderContext 同 Render，模板中的 formatTime / formatNumber / formatCurrency 使用模板语言和 ctx 中的时区
+//
+//	body = '您预约的服务将于 {{formatTime .StartAt "long"}} 开始，费用 {{formatCurrency .Fee "CNY"}}'
+func (t *Templates) RenderContext(ctx context.Context, name, lang string, data map[string]any) (subject, body, used string, err error) {
+	t.mu.RLock()
+	langs := t.byName[name]
+	t.mu.RUnlock()
+	if len(langs) == 0 {
+		return "", "", "", fmt.Errorf("模板 %s 不存在", name)
+	}
+
+	used = matchLanguage(langs, lang)
+	if used == "" {
+		used = t.fallback
+	}
+	tpl, ok := langs[used]
+	if !ok {
+		return "", "", "", fmt.Errorf("模板 %s 没有 %s 或 %s 版本", name, lang, t.fallback)
+	}
+
+	locale := web.LocaleFrom(ctx)
+	locale.Language = used
+	funcs := web.TemplateFuncs(web.WithLocale(ctx, locale))
+
+	var b bytes.Buffer
+	if err := execute(tpl.subject, funcs, &b, data); err != nil {
+		return "", "", "", err
+	}
+	subject = b.String()
+	b.Reset()
+	if err := execute(tpl.body, funcs, &b, data); err != nil {
+		return "", "", "", err
+	}
+	return subject, b.String(), used, nil
+}
+
+// execute 以本次渲染的格式化函数执行模板（克隆后替换，不影响并发渲染）
+func execute(tpl *template.Template, funcs template.FuncMap, w *bytes.Buffer, data map[string]any) error {
+	clone, err := tpl.Clone()
+	if err != nil {
+		return err
+	}
+	return clone.Funcs(funcs).Execute(w, data)
+}
+
+// matchLanguage 在可用语言中查找最匹配的一个
+func matchLanguage(langs map[string]parsedTemplate, lang string) string {
+	if lang == "" {
+		return ""
+	}
+	if _, ok := langs[lang]; ok {
+		return lang
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	best := ""
+	for candidate := range langs {
+		if cb, _, _ := strings.Cut(candidate, "-"); strings.EqualFold(cb, base) && (best == "" || candidate < best) {
+			best = candidate
+		}
+	}
+	return best
+}
@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/database"
+)
+
+// MigrationMySQL 通知表结构（MySQL 8.0+，领取依赖 SKIP LOCKED）
+const MigrationMySQL = `CREATE TABLE IF NOT EXISTS notifications (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    category VARCHAR(32) NOT NULL DEFAULT 'transactional',
+    template_name VARCHAR(128) NOT NULL DEFAULT '',
+    data TEXT,
+    deliver_at DATETIME(3) NOT NULL,
+    dedupe_key VARCHAR(191) NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+    lease_until DATETIME(3) NULL,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+    UNIQUE KEY uk_dedupe_key (dedupe_key),
+    INDEX idx_status_deliver_at (status, deliver_at),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// MigrationPostgres 通知表结构（PostgreSQL 9.5+）
+const MigrationPostgres = `CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    category VARCHAR(32) NOT NULL DEFAULT 'transactional',
+    template_name VARCHAR(128) NOT NULL DEFAULT '',
+    data TEXT,
+    deliver_at TIMESTAMPTZ NOT NULL,
+    dedupe_key VARCHAR(191) UNIQUE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_status_deliver_at ON notifications (status, deliver_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id)`
+
+// MigrationAddCategory 为已有的通知表增加类别列（MySQL 与 PostgreSQL 通用；新建的表已包含该列）
+const MigrationAddCategory = `ALTER TABLE notifications ADD COLUMN category VARCHAR(32) NOT NULL DEFAULT 'transactional'`
+
+// Migrate 创建通知表
+//
+// 使用方式：
+//
+//	if err := notify.Migrate(ctx, database.DB, config.Database.Driver); err != nil {
+//	    panic(err)
+//	}
+func Migrate(ctx context.Context, db *sql.DB, driver string) error {
+	migration := MigrationMySQL
+	if driver == database.DriverPostgreSQL {
+		migration = MigrationPostgres
+	}
+	for _, stmt := range strings.Split(migration, ";\n") {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("创建通知表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SQLStore 基于数据库的通知存储（MySQL / PostgreSQL）
+//
+// 领取通过 SELECT ... FOR UPDATE SKIP LOCKED 完成，多个实例并发领取时互不阻塞、不会拿到同一条通知
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSQLStore 创建数据库存储（db 为 nil 时使用 database.DB）
+func NewSQLStore(db *sql.DB, driver string) *SQLStore {
+	if db == nil {
+		db = database.DB
+	}
+	return &SQLStore{db: db, driver: driver}
+}
+
+const notificationColumns = "id, user_id, channel, category, template_name, data, deliver_at, dedupe_key, status, attempts, last_error"
+
+// Insert 实现 Store 接口
+func (s *SQLStore) Insert(ctx context.Context, n *Notification) (int64, bool, error) {
+	data, err := json.Marshal(n.Data)
+	if err != nil {
+		return 0, false, fmt.Errorf("序列化通知数据失败: %w", err)
+	}
+	var dedupe any
+	if n.DedupeKey != "" {
+		dedupe = n.DedupeKey
+	}
+	args := []any{n.UserID, n.Channel, n.Category, n.TemplateName, string(data), n.DeliverAt, dedupe}
+
+	var id int64
+	if s.driver == database.DriverPostgreSQL {
+		err = s.db.QueryRowContext(ctx, database.Rebind(s.driver, `INSERT INTO notifications (user_id, channel, category, template_name, data, deliver_at, dedupe_key)
+			VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (dedupe_key) DO NOTHING RETURNING id`), args...).Scan(&id)
+		if err == nil {
+			return id, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, false, err
+		}
+	} else {
+		res, err := s.db.ExecContext(ctx, `INSERT IGNORE INTO notifications (user_id, channel, category, template_name, data, deliver_at, dedupe_key)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, args...)
+		if err != nil {
+			return 0, false, err
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			id, err = res.LastInsertId()
+			return id, true, err
+		}
+	}
+
+	// DedupeKey 冲突：返回已有通知
+	err = s.db.QueryRowContext(ctx, database.Rebind(s.driver, `SELECT id FROM notifications WHERE dedupe_key = ?`), n.DedupeKey).Scan(&id)
+	return id, false, err
+}
+
+// Claim 实现 Store 接口
+func (s *SQLStore) Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Notification, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, database.Rebind(s.driver, `SELECT `+notificationColumns+` FROM notifications
+		WHERE (status = 'pending' AND deliver_at <= ?) OR (status = 'sending' AND lease_until <= ?)
+		ORDER BY deliver_at, id LIMIT ? FOR UPDATE SKIP LOCKED`), now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	var claimed []Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		claimed = append(claimed, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	args := []any{owner, leaseUntil}
+	marks := make([]string, len(claimed))
+	for i := range claimed {
+		marks[i] = "?"
+		args = append(args, claimed[i].ID)
+		claimed[i].Status = StatusSending
+	}
+	if _, err := tx.ExecContext(ctx, database.Rebind(s.driver, `UPDATE notifications SET status = 'sending', lease_owner = ?, lease_until = ?
+		WHERE id IN (`+strings.Join(marks, ", ")+`)`), args...); err != nil {
+		return nil, err
+	}
+	return claimed, tx.Commit()
+}
+
+// Complete 实现 Store 接口
+func (s *SQLStore) Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error) {
+	query := `UPDATE notifications SET status = ?, attempts = ?, last_error = ?, lease_owner = '', lease_until = NULL`
+	args := []any{outcome.Status, outcome.Attempts, outcome.Error}
+	if outcome.Status == StatusPending {
+		query += `, deliver_at = ?`
+		args = append(args, outcome.NextAttempt)
+	}
+	query += ` WHERE id = ? AND status = 'sending' AND lease_owner = ?`
+	args = append(args, id, owner)
+	res, err := s.db.ExecContext(ctx, database.Rebind(s.driver, query), args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Cancel 实现 Store 接口
+func (s *SQLStore) Cancel(ctx context.Context, id int64) error {
+	return s.updatePending(ctx, id, `UPDATE notifications SET status = 'cancelled' WHERE id = ? AND status = 'pending'`, id)
+}
+
+// Reschedule 实现 Store 接口
+func (s *SQLStore) Reschedule(ctx context.Context, id int64, at time.Time) error {
+	return s.updatePending(ctx, id, `UPDATE notifications SET deliver_at = ? WHERE id = ? AND status = 'pending'`, at, id)
+}
+
+// Get 实现 Store 接口
+func (s *SQLStore) Get(ctx context.Context, id int64) (Notification, error) {
+	row := s.db.QueryRowContext(ctx, database.Rebind(s.driver, `SELECT `+notificationColumns+` FROM notifications WHERE id = ?`), id)
+	n, err := scanNotification(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Notification{}, ErrNotFound
+	}
+	return n, err
+}
+
+// updatePending 只更新待发送的通知；未更新时区分不存在与已投递
+//
+// MySQL 的影响行数不包含值未变化的行，所以未更新时再按状态判断
+func (s *SQLStore) updatePending(ctx context.Context, id int64, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, database.Rebind(s.driver, query), args...)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		return nil
+	}
+	n, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n.Status != StatusPending {
+		return ErrNotPending
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNotification(row rowScanner) (Notification, error) {
+	var n Notification
+	var data, dedupe, lastError sql.NullString
+	if err := row.Scan(&n.ID, &n.UserID, &n.Channel, &n.Category, &n.TemplateName, &data, &n.DeliverAt,
+		&dedupe, &n.Status, &n.Attempts, &lastError); err != nil {
+		return Notification{}, err
+	}
+	n.DedupeKey, n.LastError = dedupe.String, lastError.String
+	if data.String != "" {
+		if err := json.Unmarshal([]byte(data.String), &n.Data); err != nil {
+			return Notification{}, fmt.Errorf("解析通知数据失败: %w", err)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome 一次投递的结果
+type Outcome struct {
+	Status      string    // StatusSent / StatusPending（稍后重试）/ StatusDead / StatusSuppressed
+	Attempts    int       // 累计投递次数
+	NextAttempt time.Time // 重试时间（Status 为 StatusPending 时有效）
+	Error       string    // 最近一次失败原因
+}
+
+// Store 通知存储
+//
+// 多实例部署时由存储保证同一条通知同一时刻只被一个实例领取：
+// Claim 把到期通知置为 sending 并写入租约（实例 + 到期时间），租约过期视为实例崩溃，通知可被重新领取；
+// Complete 只接受当前租约持有者的结果，被其他实例重新领取的通知不会被旧实例覆盖
+type Store interface {
+	// Insert 写入待发送通知；DedupeKey 已存在时不重复写入，返回已有通知的 ID 与 false
+	Insert(ctx context.Context, n *Notification) (id int64, created bool, err error)
+	// Claim 领取最多 limit 条到期通知
+	Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Notification, error)
+	// Complete 记录投递结果，租约已不属于 owner 时返回 false
+	Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error)
+	// Cancel 取消待发送的通知
+	Cancel(ctx context.Context, id int64) error
+	// Reschedule 修改待发送通知的投递时间
+	Reschedule(ctx context.Context, id int64, at time.Time) error
+	// Get 查询通知
+	Get(ctx context.Context, id int64) (Notification, error)
+}
+
+// MemoryStore 进程内通知存储（单实例部署与测试使用，重启后丢失）
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	items  map[int64]*memoryItem
+	dedupe map[string]int64
+}
+
+type memoryItem struct {
+	n          Notification
+	owner      string
+	leaseUntil time.Time
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[int64]*memoryItem), dedupe: make(map[string]int64)}
+}
+
+// Insert 实现 Store 接口
+func (s *MemoryStore) Insert(ctx context.Context, n *Notification) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n.DedupeKey != "" {
+		if id, ok := s.dedupe[n.DedupeKey]; ok {
+			return id, false, nil
+		}
+	}
+	s.nextID++
+	item := *n
+	item.ID = s.nextID
+	item.Status = StatusPending
+	s.items[item.ID] = &memoryItem{n: item}
+	if n.DedupeKey != "" {
+		s.dedupe[n.DedupeKey] = item.ID
+	}
+	return item.ID, true, nil
+}
+
+// Claim 实现 Store 接口
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*memoryItem
+	for _, item := range s.items {
+		switch item.n.Status {
+		case StatusPending:
+			if !item.n.DeliverAt.After(now) {
+				due = append(due, item)
+			}
+		case StatusSending:
+			if !item.leaseUntil.After(now) {
+				due = append(due, item)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].n.DeliverAt.Equal(due[j].n.DeliverAt) {
+			return due[i].n.DeliverAt.Before(due[j].n.DeliverAt)
+		}
+		return due[i].n.ID < due[j].n.ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]Notification, 0, len(due))
+	for _, item := range due {
+		item.n.Status = StatusSending
+		item.owner, item.leaseUntil = owner, leaseUntil
+		claimed = append(claimed, item.n)
+	}
+	return claimed, nil
+}
+
+// Complete 实现 Store 接口
+func (s *MemoryStore) Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok || item.n.Status != StatusSending || item.owner != owner {
+		return false, nil
+	}
+	item.n.Status = outcome.Status
+	item.n.Attempts = outcome.Attempts
+	item.n.LastError = outcome.Error
+	if outcome.Status == StatusPending {
+		item.n.DeliverAt = outcome.NextAttempt
+	}
+	item.owner, item.leaseUntil = "", time.Time{}
+	return true, nil
+}
+
+// Cancel 实现 Store 接口
+func (s *MemoryStore) Cancel(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, err := s.pendingLocked(id)
+	if err != nil {
+		return err
+	}
+	item.n.Status = StatusCancelled
+	return nil
+}
+
+// Reschedule 实现 Store 接口
+func (s *MemoryStore) Reschedule(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, err := s.pendingLocked(id)
+	if err != nil {
+		return err
+	}
+	item.n.DeliverAt = at
+	return nil
+}
+
+// Get 实现 Store 接口
+func (s *MemoryStore) Get(ctx context.Context, id int64) (Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return Notification{}, ErrNotFound
+	}
+	return item.n, nil
+}
+
+func (s *MemoryStore) pendingLocked(id int64) (*memoryItem, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if item.n.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+	return item, nil
+}
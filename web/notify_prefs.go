@@ -0,0 +1,580 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/CenJIl/base/web/ws"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 通知类别
+const (
+	NotifyTransactional = "transactional" // 交易通知（订单、预约等），默认开启
+	NotifyMarketing     = "marketing"     // 营销通知，默认关闭，需要用户主动开启
+	NotifySecurity      = "security"      // 安全通知（登录提醒、密码修改等），不能关闭
+)
+
+// 通知通道（与 notify 包的 Channel* 取值相同）
+const (
+	NotifyChannelEmail   = "email"
+	NotifyChannelWS      = "ws"
+	NotifyChannelWebhook = "webhook"
+)
+
+// 偏好来源
+const (
+	NotifySourceDefault = "default" // 未设置，使用默认值
+	NotifySourceUser    = "user"    // 用户在设置页修改
+)
+
+// 通知被拦截的原因（审计事件与指标中的 reason）
+const (
+	NotifyReasonOptedOut   = "opted_out"    // 用户关闭了该类别在该通道上的通知
+	NotifyReasonNotOptedIn = "not_opted_in" // 默认关闭且用户没有开启（营销通知）
+)
+
+var (
+	notifyCategories = []string{NotifyTransactional, NotifyMarketing, NotifySecurity}
+	notifyChannels   = []string{NotifyChannelEmail, NotifyChannelWS, NotifyChannelWebhook}
+)
+
+// NotifyPreference 用户在某个类别、某个通道上的通知偏好
+type NotifyPreference struct {
+	Category  string    `json:"category"`
+	Channel   string    `json:"channel"`
+	Enabled   bool      `json:"enabled"`
+	Source    string    `json:"source"`              // user / default
+	Mandatory bool      `json:"mandatory,omitempty"` // 不能关闭（安全通知）
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`  // 用户修改的时间（默认值为零值）
+}
+
+// NotifyPreferenceChange 一项偏好修改
+type NotifyPreferenceChange struct {
+	Category string `json:"category"`
+	Channel  string `json:"channel"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// notifyDefault 类别的默认值：营销通知需要用户主动开启（明确同意），其他默认开启
+func notifyDefault(category string) bool {
+	return category != NotifyMarketing
+}
+
+// notifyMandatory 不能关闭的类别
+func notifyMandatory(category string) bool {
+	return category == NotifySecurity
+}
+
+// NotifyPrefsMigrationMySQL 通知偏好表结构（MySQL）
+const NotifyPrefsMigrationMySQL = `CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(64) NOT NULL,
+    category VARCHAR(32) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    source VARCHAR(16) NOT NULL DEFAULT 'user',
+    updated_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (user_id, category, channel),
+    INDEX idx_notification_preferences_category_channel (category, channel)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// NotifyPrefsMigrationPostgres 通知偏好表结构（PostgreSQL 9.5+）
+const NotifyPrefsMigrationPostgres = `CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(64) NOT NULL,
+    category VARCHAR(32) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    source VARCHAR(16) NOT NULL DEFAULT 'user',
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, category, channel)
+);
+CREATE INDEX IF NOT EXISTS idx_notification_preferences_category_channel ON notification_preferences (category, channel)`
+
+// MigrateNotifyPrefs 创建通知偏好表（SQLNotifyPrefStore 使用）
+//
+// 使用方式：
+//
+//	if err := web.MigrateNotifyPrefs(ctx, database.DB, config.Database.Driver); err != nil {
+//	    panic(err)
+//	}
+func MigrateNotifyPrefs(ctx context.Context, db *sql.DB, driver string) error {
+	migration := NotifyPrefsMigrationMySQL
+	if driver == database.DriverPostgreSQL {
+		migration = NotifyPrefsMigrationPostgres
+	}
+	for _, stmt := range strings.Split(migration, ";\n") {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("创建通知偏好表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// NotifyPrefStore 通知偏好存储（只保存用户修改过的组合，其余按默认值）
+type NotifyPrefStore interface {
+	// Load 用户保存过的偏好
+	Load(ctx context.Context, userID string) ([]NotifyPreference, error)
+	// Save 保存用户的偏好（按 category + channel 覆盖）
+	Save(ctx context.Context, userID string, prefs []NotifyPreference) error
+	// LoadMany 一次查询多个用户在某个类别、通道上保存过的开关（批量发送预过滤，没有保存过的用户不返回）
+	LoadMany(ctx context.Context, userIDs []string, category, channel string) (map[string]bool, error)
+}
+
+// SQLNotifyPrefStore 基于数据库的通知偏好存储（表结构见 MigrateNotifyPrefs）
+type SQLNotifyPrefStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSQLNotifyPrefStore 创建数据库存储（db 为 nil 时使用 database.DB）
+//
+// Save 使用 ctx 绑定的事务（DBMiddleware），偏好与业务数据一起提交
+func NewSQLNotifyPrefStore(db *sql.DB, driver string) *SQLNotifyPrefStore {
+	if db == nil {
+		db = database.DB
+	}
+	return &SQLNotifyPrefStore{db: db, driver: driver}
+}
+
+// Load 实现 NotifyPrefStore 接口
+func (s *SQLNotifyPrefStore) Load(ctx context.Context, userID string) ([]NotifyPreference, error) {
+	rows, err := s.db.QueryContext(ctx, database.Rebind(s.driver, `SELECT category, channel, enabled, source, updated_at
+		FROM notification_preferences WHERE user_id = ?`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prefs []NotifyPreference
+	for rows.Next() {
+		var p NotifyPreference
+		if err := rows.Scan(&p.Category, &p.Channel, &p.Enabled, &p.Source, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// Save 实现 NotifyPrefStore 接口
+func (s *SQLNotifyPrefStore) Save(ctx context.Context, userID string, prefs []NotifyPreference) error {
+	query := `INSERT INTO notification_preferences (user_id, category, channel, enabled, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE enabled = VALUES(enabled), source = VALUES(source), updated_at = VALUES(updated_at)`
+	if s.driver == database.DriverPostgreSQL {
+		query = `INSERT INTO notification_preferences (user_id, category, channel, enabled, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, category, channel) DO UPDATE SET enabled = EXCLUDED.enabled, source = EXCLUDED.source, updated_at = EXCLUDED.updated_at`
+	}
+	conn := database.Conn(ctx)
+	if conn == nil || conn == database.DBTX(database.DB) {
+		conn = s.db
+	}
+	for _, p := range prefs {
+		if _, err := conn.ExecContext(ctx, database.Rebind(s.driver, query), userID, p.Category, p.Channel, p.Enabled, p.Source, p.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadMany 实现 NotifyPrefStore 接口（一条 IN 查询）
+func (s *SQLNotifyPrefStore) LoadMany(ctx context.Context, userIDs []string, category, channel string) (map[string]bool, error) {
+	out := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	args := []any{category, channel}
+	marks := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		marks[i] = "?"
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(ctx, database.Rebind(s.driver, `SELECT user_id, enabled FROM notification_preferences
+		WHERE category = ? AND channel = ? AND user_id IN (`+strings.Join(marks, ", ")+`)`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var enabled bool
+		if err := rows.Scan(&id, &enabled); err != nil {
+			return nil, err
+		}
+		out[id] = enabled
+	}
+	return out, rows.Err()
+}
+
+// MemoryNotifyPrefStore 进程内通知偏好存储（测试和单实例使用）
+type MemoryNotifyPrefStore struct {
+	mu    sync.Mutex
+	prefs map[string]map[string]NotifyPreference // 用户 -> category/channel -> 偏好
+}
+
+// NewMemoryNotifyPrefStore 创建进程内存储
+func NewMemoryNotifyPrefStore() *MemoryNotifyPrefStore {
+	return &MemoryNotifyPrefStore{prefs: make(map[string]map[string]NotifyPreference)}
+}
+
+// Load 实现 NotifyPrefStore 接口
+func (s *MemoryNotifyPrefStore) Load(ctx context.Context, userID string) ([]NotifyPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var prefs []NotifyPreference
+	for _, p := range s.prefs[userID] {
+		prefs = append(prefs, p)
+	}
+	return prefs, nil
+}
+
+// Save 实现 NotifyPrefStore 接口
+func (s *MemoryNotifyPrefStore) Save(ctx context.Context, userID string, prefs []NotifyPreference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prefs[userID] == nil {
+		s.prefs[userID] = make(map[string]NotifyPreference)
+	}
+	for _, p := range prefs {
+		s.prefs[userID][p.Category+"/"+p.Channel] = p
+	}
+	return nil
+}
+
+// LoadMany 实现 NotifyPrefStore 接口
+func (s *MemoryNotifyPrefStore) LoadMany(ctx context.Context, userIDs []string, category, channel string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]bool)
+	for _, id := range userIDs {
+		if p, ok := s.prefs[id][category+"/"+channel]; ok {
+			out[id] = p.Enabled
+		}
+	}
+	return out, nil
+}
+
+// notifyPrefs 当前的偏好存储与缓存
+type notifyPrefs struct {
+	store  NotifyPrefStore
+	loader *cache.Loader // nil 时不缓存
+}
+
+var (
+	notifyPrefsMu sync.RWMutex
+	notifyPrefsV  *notifyPrefs
+)
+
+// notifyPrefsTTL 缓存时间（修改时主动失效，过期只是兜底）
+const notifyPrefsTTL = 10 * time.Minute
+
+// SetNotifyPrefStore 启用通知偏好（nil 关闭，所有通知照常发送）
+//
+// 读取按用户缓存（loader 为 nil 时使用默认的 Redis 加载器，Redis 未初始化时不缓存）。
+// 缓存在 Redis 中由所有实例共享，修改后在事务提交时失效，其他实例的下一次读取即回源，不需要额外广播。
+// 启用后 ws.Hub.SendNotification 按 ws 通道的偏好拦截；notify 包的调度器与发送器通过 EnforceNotify 检查
+//
+// 使用方式：
+//
+//	web.SetNotifyPrefStore(web.NewSQLNotifyPrefStore(nil, config.Database.Driver), nil)
+//	api.Handle("GET", "/me/notifications", web.NotifyPreferencesHandler())
+//	api.Handle("PUT", "/me/notifications", web.NotifyPreferencesHandler())
+func SetNotifyPrefStore(store NotifyPrefStore, loader *cache.Loader) {
+	notifyPrefsMu.Lock()
+	defer notifyPrefsMu.Unlock()
+	if store == nil {
+		notifyPrefsV = nil
+		ws.SetNotifyGuard(nil)
+		return
+	}
+	if loader == nil && cache.Client != nil {
+		loader = cache.NewLoader(nil, nil)
+	}
+	notifyPrefsV = &notifyPrefs{store: store, loader: loader}
+	ws.SetNotifyGuard(func(ctx context.Context, userID, category string) (bool, error) {
+		return EnforceNotify(ctx, userID, category, NotifyChannelWS)
+	})
+}
+
+func currentNotifyPrefs() *notifyPrefs {
+	notifyPrefsMu.RLock()
+	defer notifyPrefsMu.RUnlock()
+	return notifyPrefsV
+}
+
+func notifyPrefsKey(userID string) string {
+	return "web:notify:prefs:" + userID
+}
+
+// saved 用户保存过的偏好（经缓存）
+func (p *notifyPrefs) saved(ctx context.Context, userID string) ([]NotifyPreference, error) {
+	load := func(ctx context.Context) ([]NotifyPreference, error) {
+		return p.store.Load(ctx, userID)
+	}
+	if p.loader == nil {
+		return load(ctx)
+	}
+	return cache.LoadWith(ctx, p.loader, notifyPrefsKey(userID), notifyPrefsTTL, load)
+}
+
+// resolve 合并默认值后的完整偏好（类别 × 通道，顺序固定）
+func resolveNotifyPrefs(saved []NotifyPreference) []NotifyPreference {
+	out := make([]NotifyPreference, 0, len(notifyCategories)*len(notifyChannels))
+	for _, category := range notifyCategories {
+		for _, channel := range notifyChannels {
+			pref := NotifyPreference{Category: category, Channel: channel, Enabled: notifyDefault(category), Source: NotifySourceDefault}
+			for _, s := range saved {
+				if s.Category == category && s.Channel == channel {
+					pref = s
+				}
+			}
+			// 安全通知不能关闭：即使存储中有关闭的记录（绕过接口写入）也按开启处理
+			if notifyMandatory(category) {
+				pref.Enabled, pref.Mandatory = true, true
+			}
+			out = append(out, pref)
+		}
+	}
+	return out
+}
+
+// decide 是否允许发送以及拦截原因
+func notifyDecision(enabled, saved bool, category string) (bool, string) {
+	switch {
+	case notifyMandatory(category) || enabled:
+		return true, ""
+	case saved:
+		return false, NotifyReasonOptedOut
+	default:
+		return false, NotifyReasonNotOptedIn
+	}
+}
+
+// allowed 单个用户的检查
+func (p *notifyPrefs) allowed(ctx context.Context, userID, category, channel string) (bool, string, error) {
+	saved, err := p.saved(ctx, userID)
+	if err != nil {
+		return false, "", err
+	}
+	for _, s := range saved {
+		if s.Category == category && s.Channel == channel {
+			ok, reason := notifyDecision(s.Enabled, true, category)
+			return ok, reason, nil
+		}
+	}
+	ok, reason := notifyDecision(notifyDefault(category), false, category)
+	return ok, reason, nil
+}
+
+// NotifyAllowed 用户是否接收该类别在该通道上的通知（未启用通知偏好时始终为 true）
+//
+// 只查询不记录；实际投递前使用 EnforceNotify，被拦截的投递会留下指标与审计记录
+func NotifyAllowed(ctx context.Context, userID, category, channel string) (bool, error) {
+	p := currentNotifyPrefs()
+	if p == nil {
+		return true, nil
+	}
+	ok, _, err := p.allowed(ctx, userID, category, channel)
+	return ok, err
+}
+
+type notifyCheckedKey struct{}
+
+// NotifyPrechecked 标记 ctx 中的投递已经检查过偏好（调度器检查后交给发送器，发送器不再重复检查与记录）
+func NotifyPrechecked(ctx context.Context) context.Context {
+	return context.WithValue(ctx, notifyCheckedKey{}, true)
+}
+
+// EnforceNotify 投递前检查偏好，被拦截时记录指标 notify_suppressed_total 与审计事件 notify.suppressed
+//
+// 返回 false 时调用方不得发送；查询失败时返回错误（调用方稍后重试，不要当作允许发送）
+//
+// 使用方式：
+//
+//	if ok, err := web.EnforceNotify(ctx, userID, web.NotifyMarketing, web.NotifyChannelEmail); err != nil || !ok {
+//	    return err
+//	}
+//	mailer.Send(...)
+func EnforceNotify(ctx context.Context, userID, category, channel string) (bool, error) {
+	p := currentNotifyPrefs()
+	if p == nil || ctx.Value(notifyCheckedKey{}) != nil {
+		return true, nil
+	}
+	ok, reason, err := p.allowed(ctx, userID, category, channel)
+	if err != nil {
+		return false, fmt.Errorf("查询用户 %s 的通知偏好失败: %w", userID, err)
+	}
+	if !ok {
+		recordNotifySuppressed(ctx, userID, category, channel, reason)
+	}
+	return ok, nil
+}
+
+// FilterNotifyRecipients 批量发送前过滤收件人，返回允许接收的用户（保持原顺序），被过滤的用户逐个记录
+//
+// 所有用户的偏好一次查询（不经过单用户缓存）
+//
+// 使用方式：
+//
+//	recipients, err := web.FilterNotifyRecipients(ctx, subscriberIDs, web.NotifyMarketing, web.NotifyChannelEmail)
+func FilterNotifyRecipients(ctx context.Context, userIDs []string, category, channel string) ([]string, error) {
+	p := currentNotifyPrefs()
+	if p == nil || notifyMandatory(category) {
+		return userIDs, nil
+	}
+	saved, err := p.store.LoadMany(ctx, userIDs, category, channel)
+	if err != nil {
+		return nil, fmt.Errorf("查询通知偏好失败: %w", err)
+	}
+	allowed := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		enabled, ok := saved[id]
+		if !ok {
+			enabled = notifyDefault(category)
+		}
+		if allow, reason := notifyDecision(enabled, ok, category); allow {
+			allowed = append(allowed, id)
+		} else {
+			recordNotifySuppressed(ctx, id, category, channel, reason)
+		}
+	}
+	return allowed, nil
+}
+
+// recordNotifySuppressed 记录被拦截的投递
+func recordNotifySuppressed(ctx context.Context, userID, category, channel, reason string) {
+	metrics.GetCounter("notify_suppressed_total", "category", category, "channel", channel, "reason", reason).Inc()
+	audit.Emit(ctx, audit.Event{
+		Type:    "notify.suppressed",
+		Subject: userID,
+		Data:    map[string]any{"category": category, "channel": channel, "reason": reason},
+	})
+}
+
+// NotifyPreferences 用户的完整通知偏好（类别 × 通道，未修改的为默认值）
+func NotifyPreferences(ctx context.Context, userID string) ([]NotifyPreference, error) {
+	p := currentNotifyPrefs()
+	if p == nil {
+		return resolveNotifyPrefs(nil), nil
+	}
+	saved, err := p.saved(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return resolveNotifyPrefs(saved), nil
+}
+
+// UpdateNotifyPreferences 保存用户的偏好修改，返回修改后的完整偏好
+//
+// 未知的类别或通道、关闭安全通知时返回 *ValidationError，不保存任何修改。
+// 保存后在 ctx 所属事务提交时使缓存失效（所有实例共享）
+func UpdateNotifyPreferences(ctx context.Context, userID string, changes []NotifyPreferenceChange) ([]NotifyPreference, error) {
+	p := currentNotifyPrefs()
+	if p == nil {
+		return nil, errors.New("未启用通知偏好（见 web.SetNotifyPrefStore）")
+	}
+	var invalid []FieldError
+	now := time.Now()
+	prefs := make([]NotifyPreference, 0, len(changes))
+	for i, ch := range changes {
+		field := fmt.Sprintf("preferences[%d]", i)
+		switch {
+		case !slices.Contains(notifyCategories, ch.Category):
+			invalid = append(invalid, FieldError{Field: field + ".category", Rule: "oneof", Message: "未知的通知类别: " + ch.Category})
+		case !slices.Contains(notifyChannels, ch.Channel):
+			invalid = append(invalid, FieldError{Field: field + ".channel", Rule: "oneof", Message: "未知的通知通道: " + ch.Channel})
+		case notifyMandatory(ch.Category) && !ch.Enabled:
+			invalid = append(invalid, FieldError{Field: field + ".enabled", Rule: "mandatory", Message: "安全通知不能关闭"})
+		default:
+			prefs = append(prefs, NotifyPreference{Category: ch.Category, Channel: ch.Channel, Enabled: ch.Enabled, Source: NotifySourceUser, UpdatedAt: now})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &ValidationError{Fields: invalid}
+	}
+	if err := p.store.Save(ctx, userID, prefs); err != nil {
+		return nil, err
+	}
+	if p.loader != nil {
+		p.loader.InvalidateAfterCommit(ctx, notifyPrefsKey(userID))
+	}
+	saved, err := p.store.Load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return resolveNotifyPrefs(saved), nil
+}
+
+// notifyPrefsRequest 修改偏好的请求体
+type notifyPrefsRequest struct {
+	Preferences []NotifyPreferenceChange `json:"preferences"`
+}
+
+// NotifyPreferencesHandler 通知偏好设置接口：GET 返回完整偏好，PUT / PATCH 保存修改
+//
+// 请求体：{"preferences":[{"category":"marketing","channel":"email","enabled":true}]}。
+// 关闭安全通知或使用未知类别时返回 400（data.fields）；代理登录时不能修改（同意必须由用户本人作出）。
+// 每次修改记录审计事件 notify.preferences.update
+//
+// 使用方式：
+//
+//	api.GET("/me/notifications", web.NotifyPreferencesHandler())
+//	api.PUT("/me/notifications", web.NotifyPreferencesHandler())
+func NotifyPreferencesHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		userID := jwt.GetUserID(c)
+		if userID == "" {
+			panic(UnauthorizedHTTP(MsgUnauthorized))
+		}
+		switch string(c.Method()) {
+		case consts.MethodPut, consts.MethodPatch, consts.MethodPost:
+			if jwt.IsImpersonating(c) {
+				panic(ForbiddenHTTP("代理登录时不能代替用户修改通知偏好"))
+			}
+			var body notifyPrefsRequest
+			if err := json.Unmarshal(c.Request.Body(), &body); err != nil {
+				panic(BadRequestHTTP("通知偏好格式错误"))
+			}
+			prefs, err := UpdateNotifyPreferences(ctx, userID, body.Preferences)
+			if err != nil {
+				var invalid *ValidationError
+				if errors.As(err, &invalid) {
+					panic(invalid)
+				}
+				log.Errorf("[Notify] 保存用户 %s 的通知偏好失败: %v", userID, err)
+				panic(InternalHTTP(MsgInternalError))
+			}
+			audit.Emit(ctx, audit.Event{
+				Type:      "notify.preferences.update",
+				Actor:     userID,
+				Subject:   userID,
+				RequestID: middleware.GetRequestID(c),
+				Method:    string(c.Method()),
+				Path:      string(c.Path()),
+				Data:      map[string]any{"changes": body.Preferences},
+			})
+			c.JSON(consts.StatusOK, Success(prefs))
+		default:
+			prefs, err := NotifyPreferences(ctx, userID)
+			if err != nil {
+				log.Errorf("[Notify] 查询用户 %s 的通知偏好失败: %v", userID, err)
+				panic(InternalHTTP(MsgInternalError))
+			}
+			c.JSON(consts.StatusOK, Success(prefs))
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/ws"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedCacheStore 多个实例共享的缓存（模拟 Redis），实现 InvalidationStore
+type sharedCacheStore struct {
+	mapStore
+	seqs map[string]int64
+}
+
+func (s *sharedCacheStore) Invalidate(ctx context.Context, key string, guard time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqs[key]++
+	delete(s.m, key)
+	return nil
+}
+
+func (s *sharedCacheStore) Seq(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seqs[key], nil
+}
+
+func (s *sharedCacheStore) SetIfSeq(ctx context.Context, key string, value []byte, ttl time.Duration, seq int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seqs[key] != seq {
+		return false, nil
+	}
+	s.m[key] = value
+	return true, nil
+}
+
+// countingPrefStore 统计 Load / LoadMany 的调用次数
+type countingPrefStore struct {
+	*MemoryNotifyPrefStore
+	mu       sync.Mutex
+	loads    int
+	loadMany int
+}
+
+func (s *countingPrefStore) Load(ctx context.Context, userID string) ([]NotifyPreference, error) {
+	s.mu.Lock()
+	s.loads++
+	s.mu.Unlock()
+	return s.MemoryNotifyPrefStore.Load(ctx, userID)
+}
+
+func (s *countingPrefStore) LoadMany(ctx context.Context, userIDs []string, category, channel string) (map[string]bool, error) {
+	s.mu.Lock()
+	s.loadMany++
+	s.mu.Unlock()
+	return s.MemoryNotifyPrefStore.LoadMany(ctx, userIDs, category, channel)
+}
+
+func newNotifyPrefs(t *testing.T) (*countingPrefStore, *sharedCacheStore, *ownershipAudit) {
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	store := &countingPrefStore{MemoryNotifyPrefStore: NewMemoryNotifyPrefStore()}
+	shared := &sharedCacheStore{mapStore: mapStore{m: map[string][]byte{}}, seqs: map[string]int64{}}
+	SetNotifyPrefStore(store, cache.NewLoader(shared, nil))
+	t.Cleanup(func() {
+		audit.SetSink(nil)
+		SetNotifyPrefStore(nil, nil)
+	})
+	return store, shared, rec
+}
+
+func suppressedCount(category, channel, reason string) int64 {
+	return metrics.GetCounter("notify_suppressed_total", "category", category, "channel", channel, "reason", reason).Value()
+}
+
+func TestNotifyPreferences_Defaults(t *testing.T) {
+	ctx := context.Background()
+	newNotifyPrefs(t)
+
+	prefs, err := NotifyPreferences(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, prefs, 9)
+	for _, p := range prefs {
+		assert.Equal(t, NotifySourceDefault, p.Source)
+		assert.Equal(t, p.Category != NotifyMarketing, p.Enabled, "%s/%s", p.Category, p.Channel)
+		assert.Equal(t, p.Category == NotifySecurity, p.Mandatory)
+	}
+
+	before := suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonNotOptedIn)
+	ok, err := EnforceNotify(ctx, "alice", NotifyMarketing, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.False(t, ok, "营销通知需要用户主动开启")
+	assert.Equal(t, before+1, suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonNotOptedIn))
+
+	ok, err = EnforceNotify(NotifyPrechecked(ctx), "alice", NotifyMarketing, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.True(t, ok, "已检查过的投递不重复检查")
+	assert.Equal(t, before+1, suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonNotOptedIn))
+
+	SetNotifyPrefStore(nil, nil)
+	ok, err = NotifyAllowed(ctx, "alice", NotifyMarketing, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.True(t, ok, "未启用通知偏好时全部允许")
+}
+
+func TestNotifyPreferencesHandler_UpdateInvalidatesSharedCache(t *testing.T) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "notify-secret"
+	require.NoError(t, jwt.Init(conf))
+	store, shared, rec := newNotifyPrefs(t)
+	ctx := context.Background()
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	api := NewRouter(engine).Group("/api", jwt.Middleware()).RequireAuth()
+	api.GET("/me/notifications", NotifyPreferencesHandler())
+	api.PUT("/me/notifications", NotifyPreferencesHandler())
+	put := func(body string) *ut.ResponseRecorder {
+		return ut.PerformRequest(engine, "PUT", "/api/me/notifications", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)},
+			ownershipToken(t, "alice"), ut.Header{Key: "Content-Type", Value: "application/json"})
+	}
+
+	// 另一个实例（同一个 Redis）先读取并缓存
+	replica := cache.NewLoader(shared, nil)
+	other := &notifyPrefs{store: store, loader: replica}
+	ok, _, err := other.allowed(ctx, "alice", NotifyTransactional, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, _ = other.allowed(ctx, "alice", NotifyTransactional, NotifyChannelEmail)
+	assert.True(t, ok)
+	assert.Equal(t, 1, store.loads, "第二次读取命中缓存")
+
+	w := put(`{"preferences":[{"category":"transactional","channel":"email","enabled":false},{"category":"marketing","channel":"ws","enabled":true}]}`)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var resp struct {
+		Data []NotifyPreference `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 9)
+	assert.Equal(t, NotifyPreference{Category: NotifyTransactional, Channel: NotifyChannelEmail, Enabled: false, Source: NotifySourceUser},
+		NotifyPreference{Category: resp.Data[0].Category, Channel: resp.Data[0].Channel, Enabled: resp.Data[0].Enabled, Source: resp.Data[0].Source})
+	assert.False(t, resp.Data[0].UpdatedAt.IsZero())
+	assert.Contains(t, rec.types(), "notify.preferences.update")
+
+	ok, _, err = other.allowed(ctx, "alice", NotifyTransactional, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.False(t, ok, "修改后其他实例不再读到旧的缓存")
+	ok, err = NotifyAllowed(ctx, "alice", NotifyMarketing, NotifyChannelWS)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	before := suppressedCount(NotifyTransactional, NotifyChannelEmail, NotifyReasonOptedOut)
+	ok, err = EnforceNotify(ctx, "alice", NotifyTransactional, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, before+1, suppressedCount(NotifyTransactional, NotifyChannelEmail, NotifyReasonOptedOut))
+	assert.Contains(t, rec.types(), "notify.suppressed")
+
+	w = ut.PerformRequest(engine, "GET", "/api/me/notifications", nil, ownershipToken(t, "alice"))
+	require.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"source":"user"`)
+}
+
+func TestUpdateNotifyPreferences_SecurityIsMandatory(t *testing.T) {
+	ctx := context.Background()
+	store, _, _ := newNotifyPrefs(t)
+
+	_, err := UpdateNotifyPreferences(ctx, "alice", []NotifyPreferenceChange{
+		{Category: NotifyMarketing, Channel: NotifyChannelEmail, Enabled: true},
+		{Category: NotifySecurity, Channel: NotifyChannelEmail, Enabled: false},
+		{Category: "digest", Channel: NotifyChannelEmail, Enabled: true},
+	})
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Fields, 2)
+	assert.Equal(t, "preferences[1].enabled", invalid.Fields[0].Field)
+	assert.Equal(t, "mandatory", invalid.Fields[0].Rule)
+	assert.Equal(t, "preferences[2].category", invalid.Fields[1].Field)
+	saved, _ := store.Load(ctx, "alice")
+	assert.Empty(t, saved, "有错误时不保存任何修改")
+
+	// 绕过接口直接写入存储的关闭记录也不生效
+	require.NoError(t, store.Save(ctx, "alice", []NotifyPreference{{Category: NotifySecurity, Channel: NotifyChannelWS, Enabled: false, Source: NotifySourceUser}}))
+	ok, err := EnforceNotify(ctx, "alice", NotifySecurity, NotifyChannelWS)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	allowed, err := FilterNotifyRecipients(ctx, []string{"alice"}, NotifySecurity, NotifyChannelWS)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, allowed)
+}
+
+func TestFilterNotifyRecipients_SingleQuery(t *testing.T) {
+	ctx := context.Background()
+	store, _, rec := newNotifyPrefs(t)
+	require.NoError(t, store.Save(ctx, "alice", []NotifyPreference{{Category: NotifyMarketing, Channel: NotifyChannelEmail, Enabled: true, Source: NotifySourceUser}}))
+	require.NoError(t, store.Save(ctx, "bob", []NotifyPreference{{Category: NotifyMarketing, Channel: NotifyChannelEmail, Enabled: false, Source: NotifySourceUser}}))
+	require.NoError(t, store.Save(ctx, "dave", []NotifyPreference{{Category: NotifyMarketing, Channel: NotifyChannelWS, Enabled: true, Source: NotifySourceUser}}))
+	optedOut := suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonOptedOut)
+	notOptedIn := suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonNotOptedIn)
+
+	allowed, err := FilterNotifyRecipients(ctx, []string{"alice", "bob", "carol", "dave"}, NotifyMarketing, NotifyChannelEmail)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, allowed)
+	assert.Equal(t, 1, store.loadMany)
+	assert.Zero(t, store.loads, "批量过滤不逐个查询")
+	assert.Equal(t, optedOut+1, suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonOptedOut))
+	assert.Equal(t, notOptedIn+2, suppressedCount(NotifyMarketing, NotifyChannelEmail, NotifyReasonNotOptedIn))
+	assert.Len(t, rec.types(), 3, "每个被过滤的用户一条审计记录")
+}
+
+func TestNotifyPrefs_WSGuard(t *testing.T) {
+	ctx := context.Background()
+	newNotifyPrefs(t)
+	hub := ws.NewHub()
+
+	_, err := hub.SendNotification(ctx, "alice", NotifyMarketing, []byte(`{"type":"notification"}`))
+	assert.ErrorIs(t, err, ws.ErrNotificationSuppressed)
+	sent, err := hub.SendNotification(ctx, "alice", NotifyTransactional, []byte(`{"type":"notification"}`))
+	require.NoError(t, err)
+	assert.Zero(t, sent, "允许发送，只是没有连接")
+
+	SetNotifyPrefStore(nil, nil)
+	_, err = hub.SendNotification(ctx, "alice", NotifyMarketing, []byte(`{"type":"notification"}`))
+	assert.NoError(t, err)
+}
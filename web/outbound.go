@@ -0,0 +1,59 @@
+package web
+
+import (
+	"time"
+
+	"github.com/CenJIl/base/common/governor"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/metrics"
+)
+
+// OutboundConfig 出站限速配置（类型别名）
+type OutboundConfig = governor.Config
+
+// InitOutbound 初始化全局出站限速器组
+//
+// shared = true 且已连接 Redis 时通过 Redis 令牌桶在实例之间共享速率，否则只在本实例内限速。
+// 限速事件记录为指标：
+//
+//	outbound_acquired_total{limiter}   取得令牌次数
+//	outbound_wait_ms_total{limiter}    取得令牌前等待的总毫秒数（除以 acquired 即平均等待）
+//	outbound_throttled_total{limiter}  没有令牌的次数（Allow 被拒绝或 Wait 需要等待）
+//	outbound_penalized_total{limiter}  对端返回 429 的次数
+//	outbound_rate_factor{limiter}      当前速率占配置速率的比例
+//
+// 使用方式：
+//
+//	web.InitOutbound(config.Outbound)
+//	mailer := notify.EmailSender{Mailer: mail, Address: queries.GetUserEmail, Governor: "sendgrid"}
+//	partner := client.New("https://api.partner-x.com", client.WithGovernor("partnerX"))
+func InitOutbound(config OutboundConfig) {
+	opts := []governor.Option{governor.WithObserver(recordOutbound), governor.WithLogger(logger.GetLogger())}
+	if config.Shared {
+		if cache.Client != nil {
+			opts = append(opts, governor.WithStore(cache.NewGovernorStore(cache.Client)))
+		} else {
+			log.Warnf("[Outbound] shared = true 但未配置 Redis，只在本实例内限速")
+		}
+	}
+	for name := range config.Limits {
+		metrics.GetGauge("outbound_rate_factor", "limiter", name).Set(1)
+	}
+	governor.Init(config, opts...)
+}
+
+func recordOutbound(e governor.Event) {
+	switch e.Type {
+	case governor.EventAcquired:
+		metrics.GetCounter("outbound_acquired_total", "limiter", e.Limiter).Inc()
+		if e.Wait > 0 {
+			metrics.GetCounter("outbound_wait_ms_total", "limiter", e.Limiter).Add(int64(e.Wait / time.Millisecond))
+		}
+	case governor.EventThrottled:
+		metrics.GetCounter("outbound_throttled_total", "limiter", e.Limiter).Inc()
+	case governor.EventPenalized:
+		metrics.GetCounter("outbound_penalized_total", "limiter", e.Limiter).Inc()
+		metrics.GetGauge("outbound_rate_factor", "limiter", e.Limiter).Set(e.Factor)
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/CenJIl/base/common/governor"
+	"github.com/CenJIl/base/web/client"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbound_ClientAdaptsTo429(t *testing.T) {
+	InitOutbound(OutboundConfig{
+		Limits: map[string]governor.Limit{
+			"partnerX": {Rate: 1000, Burst: 2, Adaptive: true},
+		},
+	})
+	t.Cleanup(func() { governor.Init(governor.Config{}) })
+	penalized := metrics.GetCounter("outbound_penalized_total", "limiter", "partnerX")
+	acquired := metrics.GetCounter("outbound_acquired_total", "limiter", "partnerX")
+	penalizedBefore, acquiredBefore := penalized.Value(), acquired.Value()
+
+	var throttle bool
+	upstream := route.NewEngine(config.NewOptions(nil))
+	upstream.GET("/quote", func(ctx context.Context, c *app.RequestContext) {
+		if throttle {
+			c.Header("Retry-After", "3600")
+			c.JSON(http.StatusTooManyRequests, Fail(429, "slow down"))
+			return
+		}
+		c.JSON(http.StatusOK, Success(42))
+	})
+	partner := client.New("http://partner", client.WithHTTPClient(&http.Client{Transport: engineTransport{upstream}}), client.WithGovernor("partnerX"))
+
+	quote, err := client.Get[int](context.Background(), partner, "/quote")
+	require.NoError(t, err)
+	assert.Equal(t, 42, quote)
+	assert.Equal(t, 1.0, governor.Default().Factor("partnerX"))
+
+	throttle = true
+	_, err = client.Get[int](context.Background(), partner, "/quote")
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.HTTPStatus)
+
+	assert.Equal(t, 0.5, governor.Default().Factor("partnerX"), "429 后速率减半")
+	assert.False(t, governor.Allow("partnerX"), "Retry-After 期间暂停")
+	assert.Equal(t, int64(1), penalized.Value()-penalizedBefore)
+	assert.Equal(t, int64(2), acquired.Value()-acquiredBefore)
+	assert.Equal(t, 0.5, metrics.GetGauge("outbound_rate_factor", "limiter", "partnerX").Value())
+}
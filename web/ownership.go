@@ -0,0 +1,164 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// ErrResourceNotFound 资源不存在（OwnerLoader 返回此错误或 sql.ErrNoRows 时响应 404）
+var ErrResourceNotFound = errors.New("resource not found")
+
+// resourceOwnerKey 请求上下文中缓存的资源所有者
+const resourceOwnerKey = "resource_owner"
+
+// OwnershipConfig 资源归属校验配置
+//
+// Example:
+//
+//	[web.ownership]
+//	overrideRoles = ["admin", "support"]   # 可访问任意用户资源的角色，默认 ["admin"]
+//	denyStatus = 404                       # 非所有者的响应状态码：404（默认，不暴露资源是否存在）或 403
+type OwnershipConfig struct {
+	OverrideRoles []string `toml:"overrideRoles"` // 越权访问角色
+	DenyStatus    int      `toml:"denyStatus"`    // 拒绝时的状态码
+}
+
+// OwnerLoader 查询资源所有者（通常按路径参数执行一条轻量查询）
+type OwnerLoader func(ctx context.Context, c *app.RequestContext) (ownerID string, err error)
+
+var ownershipConfig = OwnershipConfig{OverrideRoles: []string{"admin"}, DenyStatus: consts.StatusNotFound}
+
+// InitOwnership 设置资源归属校验配置（零值字段使用默认值）
+func InitOwnership(config OwnershipConfig) {
+	if len(config.OverrideRoles) == 0 {
+		config.OverrideRoles = []string{"admin"}
+	}
+	if config.DenyStatus != consts.StatusForbidden {
+		config.DenyStatus = consts.StatusNotFound
+	}
+	ownershipConfig = config
+}
+
+// RequireOwnership 资源归属校验中间件（放在 JWT 中间件之后）
+//
+// 调用 loader 查询资源所有者，当前用户不是所有者且没有越权角色时拒绝访问：
+// 默认响应 404，避免通过 403 暴露资源是否存在。查询结果缓存在请求上下文中，
+// 处理函数通过 GetResourceOwner 读取，无需再次查询。拒绝事件写入审计
+//
+// 使用方式：
+//
+//	api.GET("/orders/:id", web.RequireOwnership(func(ctx context.Context, c *app.RequestContext) (string, error) {
+//	    return queries.GetOrderOwner(ctx, c.Param("id"))   // 不存在时返回 sql.ErrNoRows
+//	}), getOrder)
+func RequireOwnership(loader OwnerLoader) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ownerID, ok := GetResourceOwner(c)
+		if !ok {
+			var err error
+			ownerID, err = loader(ctx, c)
+			if errors.Is(err, ErrResourceNotFound) || errors.Is(err, sql.ErrNoRows) {
+				abortOwnership(c, consts.StatusNotFound, MsgResourceNotFound)
+				return
+			}
+			if err != nil {
+				log.Errorf("[Ownership] 查询资源所有者失败: %v", err)
+				abortOwnership(c, consts.StatusInternalServerError, MsgInternalError)
+				return
+			}
+			c.Set(resourceOwnerKey, ownerID)
+		}
+		if !CheckOwnership(c, ownerID) {
+			return
+		}
+		c.Next(ctx)
+	}
+}
+
+// ownershipGuardPtr 归属校验中间件的函数指针（用于在路由表中标记受保护的路由）
+var ownershipGuardPtr = reflect.ValueOf(RequireOwnership(nil)).Pointer()
+
+// hasOwnershipGuard 处理器链中是否包含归属校验
+func hasOwnershipGuard(handlers []app.HandlerFunc) bool {
+	for _, h := range handlers {
+		if reflect.ValueOf(h).Pointer() == ownershipGuardPtr {
+			return true
+		}
+	}
+	return false
+}
+
+// GetResourceOwner 获取 RequireOwnership 缓存的资源所有者
+func GetResourceOwner(c *app.RequestContext) (string, bool) {
+	if v, ok := c.Get(resourceOwnerKey); ok {
+		return v.(string), true
+	}
+	return "", false
+}
+
+// CheckOwnership 校验当前用户是否可以访问 ownerID 的资源（资源已加载时在处理函数中使用）
+//
+// 不允许时写入拒绝响应并返回 false
+//
+// 使用方式：
+//
+//	order, err := queries.GetOrder(ctx, id)
+//	if !web.CheckOwnership(c, order.UserID) {
+//	    return
+//	}
+func CheckOwnership(c *app.RequestContext, ownerID string) bool {
+	userID := jwt.GetUserID(c)
+	if userID == "" {
+		abortOwnership(c, consts.StatusUnauthorized, MsgUnauthorized)
+		return false
+	}
+	if userID == ownerID {
+		return true
+	}
+
+	config := ownershipConfig
+	event := audit.Event{
+		Actor:        jwt.GetActor(c),
+		Subject:      userID,
+		Impersonated: jwt.IsImpersonating(c),
+		RequestID:    middleware.GetRequestID(c),
+		Method:       string(c.Method()),
+		Path:         string(c.Path()),
+		Data:         map[string]any{"owner": ownerID},
+	}
+	for _, role := range jwt.GetRoles(c) {
+		for _, override := range config.OverrideRoles {
+			if role == override {
+				event.Type = "ownership.override"
+				event.Data["role"] = role
+				audit.Emit(context.Background(), event)
+				return true
+			}
+		}
+	}
+
+	event.Type = "ownership.denied"
+	event.Status = config.DenyStatus
+	audit.Emit(context.Background(), event)
+	if config.DenyStatus == consts.StatusForbidden {
+		abortOwnership(c, consts.StatusForbidden, MsgForbidden)
+	} else {
+		abortOwnership(c, consts.StatusNotFound, MsgResourceNotFound)
+	}
+	return false
+}
+
+// abortOwnership 写入统一格式的拒绝响应
+func abortOwnership(c *app.RequestContext, status int, message string) {
+	result := Fail(status, message)
+	result.TraceID = middleware.GetRequestID(c)
+	result.Impersonating = jwt.IsImpersonating(c)
+	c.AbortWithStatusJSON(status, result)
+}
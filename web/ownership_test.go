@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ownershipAudit struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *ownershipAudit) Write(ctx context.Context, e audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *ownershipAudit) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func newOwnershipEngine(t *testing.T) (*route.Engine, *ownershipAudit, *int) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "ownership-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	t.Cleanup(func() {
+		audit.SetSink(nil)
+		InitOwnership(OwnershipConfig{})
+	})
+
+	orders := map[string]string{"1": "alice", "2": "bob"}
+	loads := 0
+	loader := func(ctx context.Context, c *app.RequestContext) (string, error) {
+		loads++
+		switch c.Param("id") {
+		case "broken":
+			return "", errors.New("db down")
+		}
+		owner, ok := orders[c.Param("id")]
+		if !ok {
+			return "", sql.ErrNoRows
+		}
+		return owner, nil
+	}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := NewRouter(engine)
+	api := r.Group("/api", jwt.Middleware()).RequireAuth()
+	api.GET("/orders/:id", RequireOwnership(loader), func(ctx context.Context, c *app.RequestContext) {
+		owner, _ := GetResourceOwner(c)
+		c.JSON(200, Success(owner))
+	})
+	api.GET("/invoices/:id", func(ctx context.Context, c *app.RequestContext) {
+		if !CheckOwnership(c, orders[c.Param("id")]) {
+			return
+		}
+		c.JSON(200, Success(nil))
+	})
+	return engine, rec, &loads
+}
+
+func ownershipToken(t *testing.T, user string, roles ...string) ut.Header {
+	claims := map[string]interface{}{"identity": user}
+	if len(roles) > 0 {
+		claims[jwt.RolesClaim] = roles
+	}
+	token, _, err := jwt.GenerateToken(claims)
+	require.NoError(t, err)
+	return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+}
+
+func TestOwnership_Guard(t *testing.T) {
+	engine, rec, loads := newOwnershipEngine(t)
+
+	// 所有者访问；处理函数读取缓存的所有者，不再查询
+	w := ut.PerformRequest(engine, "GET", "/api/orders/1", nil, ownershipToken(t, "alice"))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":"alice"`)
+	assert.Equal(t, 1, *loads)
+
+	// 非所有者：与资源不存在的响应完全相同
+	denied := ut.PerformRequest(engine, "GET", "/api/orders/2", nil, ownershipToken(t, "alice"))
+	missing := ut.PerformRequest(engine, "GET", "/api/orders/404", nil, ownershipToken(t, "alice"))
+	assert.Equal(t, 404, denied.Code)
+	assert.Equal(t, 404, missing.Code)
+	assert.Equal(t, missing.Body.String(), denied.Body.String())
+
+	// 越权角色
+	w = ut.PerformRequest(engine, "GET", "/api/orders/2", nil, ownershipToken(t, "carol", "support", "admin"))
+	assert.Equal(t, 200, w.Code)
+
+	// 查询失败
+	w = ut.PerformRequest(engine, "GET", "/api/orders/broken", nil, ownershipToken(t, "alice"))
+	assert.Equal(t, 500, w.Code)
+
+	assert.Equal(t, []string{"ownership.denied", "ownership.override"}, rec.types())
+	rec.mu.Lock()
+	assert.Equal(t, "alice", rec.events[0].Subject)
+	assert.Equal(t, "bob", rec.events[0].Data["owner"])
+	assert.Equal(t, "/api/orders/2", rec.events[0].Path)
+	rec.mu.Unlock()
+}
+
+func TestOwnership_CheckInHandler(t *testing.T) {
+	engine, rec, _ := newOwnershipEngine(t)
+	InitOwnership(OwnershipConfig{OverrideRoles: []string{"support"}, DenyStatus: 403})
+
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/invoices/2", nil, ownershipToken(t, "bob")).Code)
+	assert.Equal(t, 403, ut.PerformRequest(engine, "GET", "/api/invoices/2", nil, ownershipToken(t, "alice")).Code)
+	// admin 不在配置的越权角色中
+	assert.Equal(t, 403, ut.PerformRequest(engine, "GET", "/api/invoices/2", nil, ownershipToken(t, "root", "admin")).Code)
+	assert.Equal(t, 200, ut.PerformRequest(engine, "GET", "/api/invoices/2", nil, ownershipToken(t, "carol", "support")).Code)
+	assert.Equal(t, []string{"ownership.denied", "ownership.denied", "ownership.override"}, rec.types())
+}
+
+func TestOwnership_RouteMetadata(t *testing.T) {
+	engine, _, _ := newOwnershipEngine(t)
+	routes, _ := ValidateRoutes(engine)
+	guarded := map[string]bool{}
+	for _, r := range routes {
+		guarded[r.Path] = r.Ownership
+	}
+	assert.True(t, guarded["/api/orders/:id"])
+	assert.False(t, guarded["/api/invoices/:id"])
+}
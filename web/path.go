@@ -0,0 +1,149 @@
+package web
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// 路径规范化模式
+const (
+	PathModeRedirect = "redirect" // 重定向到规范路径（默认）
+	PathModeRewrite  = "rewrite"  // 内部改写后重新路由
+)
+
+// PathConfig 路径规范化配置
+//
+// 默认关闭，开启后：
+//   - 合并重复斜杠（//api//users -> /api/users）
+//   - 去掉结尾斜杠（/api/users/ -> /api/users），ExcludePrefixes 及上传 URL 前缀除外
+//   - 拒绝编码后的路径穿越序列（%2e%2e、%2f、%5c、%00），返回 400 而不是规范化
+//
+// Example:
+//
+//	[web.path]
+//	canonicalize = true
+//	mode = "redirect"        # redirect | rewrite
+//	redirectCode = 308       # 301（仅 GET/HEAD 使用，其余方法自动用 308）| 308
+//	excludePrefixes = ["/static/"]
+type PathConfig struct {
+	Canonicalize    bool     `toml:"canonicalize"`    // 是否启用路径规范化
+	Mode            string   `toml:"mode"`            // redirect 或 rewrite
+	RedirectCode    int      `toml:"redirectCode"`    // 重定向状态码，默认 308
+	ExcludePrefixes []string `toml:"excludePrefixes"` // 结尾斜杠有意义的路径前缀
+}
+
+// encodedTraversal 编码后的危险序列（小写比较）
+var encodedTraversal = []string{"%2e%2e", "%2e.", ".%2e", "%2f", "%5c", "%00"}
+
+// PathCanonicalMiddleware 路径规范化中间件
+//
+// 必须作为第一个全局中间件注册：rewrite 模式下会用规范路径重新执行整条路由，
+// 因此访问日志、路由元数据（c.FullPath()）看到的都是规范路径。
+//
+// 已匹配的路由只处理重复斜杠；未匹配的路由（404/405 链路）才尝试去掉结尾斜杠，
+// 规范路径仍无法匹配时交给 NoRoute/NoMethod 处理器输出统一的错误响应。
+//
+// 使用方式：
+//
+//	h := server.Default(server.WithRedirectTrailingSlash(false))
+//	h.Use(web.PathCanonicalMiddleware(config.Path, h.Engine))
+func PathCanonicalMiddleware(config PathConfig, engine *route.Engine) app.HandlerFunc {
+	redirectCode := config.RedirectCode
+	if redirectCode != consts.StatusMovedPermanently {
+		redirectCode = consts.StatusPermanentRedirect
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		raw := string(c.Request.URI().PathOriginal())
+		if hasEncodedTraversal(raw) {
+			log.Warnf("[Path] 拒绝可疑路径: %s", raw)
+			c.AbortWithStatusJSON(consts.StatusBadRequest, Fail(400, "Invalid request path"))
+			return
+		}
+
+		matched := c.FullPath() != ""
+		canonical := collapseSlashes(raw)
+		if !matched && !isExcludedPath(string(c.Request.URI().Path()), config.ExcludePrefixes) {
+			canonical = trimTrailingSlash(canonical)
+		}
+
+		if canonical == raw {
+			c.Next(ctx)
+			return
+		}
+
+		if config.Mode == PathModeRewrite {
+			if matched {
+				// Hertz 已按合并斜杠后的路径完成路由，c.Path() 即规范路径
+				c.Next(ctx)
+				return
+			}
+			c.Request.URI().SetPath(canonical)
+			c.SetIndex(-1)
+			engine.ServeHTTP(ctx, c)
+			c.Abort()
+			return
+		}
+
+		location := canonical
+		if query := c.Request.URI().QueryString(); len(query) > 0 {
+			location += "?" + string(query)
+		}
+		code := redirectCode
+		if method := string(c.Method()); method != consts.MethodGet && method != consts.MethodHead {
+			// 301 会被多数客户端改写为 GET，非 GET 请求一律使用 308 保留方法和请求体
+			code = consts.StatusPermanentRedirect
+		}
+		c.Redirect(code, []byte(location))
+		c.Abort()
+	}
+}
+
+// hasEncodedTraversal 检查原始路径中是否包含编码后的穿越序列
+func hasEncodedTraversal(raw string) bool {
+	lower := strings.ToLower(raw)
+	for _, seq := range encodedTraversal {
+		if strings.Contains(lower, seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapseSlashes 合并重复斜杠
+func collapseSlashes(p string) string {
+	if !strings.Contains(p, "//") {
+		return p
+	}
+	var b strings.Builder
+	b.Grow(len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '/' && i > 0 && p[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+// trimTrailingSlash 去掉结尾斜杠（根路径除外）
+func trimTrailingSlash(p string) string {
+	for len(p) > 1 && strings.HasSuffix(p, "/") {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// isExcludedPath 检查路径是否在排除前缀内
+func isExcludedPath(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
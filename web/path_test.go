@@ -0,0 +1,111 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPathTestEngine(pathCfg PathConfig) *route.Engine {
+	engine := route.NewEngine(config.NewOptions([]config.Option{
+		server.WithHandleMethodNotAllowed(true),
+		server.WithRedirectTrailingSlash(false),
+	}))
+	engine.Use(PathCanonicalMiddleware(pathCfg, engine))
+	engine.NoRoute(NotFoundHandler())
+	engine.NoMethod(MethodNotAllowedHandler())
+
+	handler := func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(map[string]string{"path": string(c.Path()), "route": c.FullPath()}))
+	}
+	engine.GET("/api/users", handler)
+	engine.POST("/api/users", handler)
+	engine.GET("/static/", handler)
+	return engine
+}
+
+func TestPathCanonical_Redirect(t *testing.T) {
+	engine := newPathTestEngine(PathConfig{Canonicalize: true, Mode: PathModeRedirect, RedirectCode: 301, ExcludePrefixes: []string{"/static/"}})
+
+	testCases := []struct {
+		name     string
+		method   string
+		url      string
+		status   int
+		location string
+	}{
+		{"canonical", "GET", "/api/users", 200, ""},
+		{"trailing slash GET uses 301", "GET", "/api/users/", 301, "/api/users"},
+		{"trailing slash POST keeps method with 308", "POST", "/api/users/", 308, "/api/users"},
+		{"duplicate slashes", "GET", "//api//users", 301, "/api/users"},
+		{"query preserved", "GET", "/api/users/?page=2", 301, "/api/users?page=2"},
+		{"excluded prefix keeps trailing slash", "GET", "/static/", 200, ""},
+		{"unknown route falls through to 404", "GET", "/api/unknown", 404, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ut.PerformRequest(engine, tc.method, tc.url, nil)
+			resp := w.Result()
+			assert.Equal(t, tc.status, resp.StatusCode())
+			if tc.location != "" {
+				assert.Equal(t, tc.location, string(resp.Header.Peek("Location")))
+			}
+		})
+	}
+}
+
+func TestPathCanonical_Rewrite(t *testing.T) {
+	engine := newPathTestEngine(PathConfig{Canonicalize: true, Mode: PathModeRewrite})
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		status int
+		code   int
+	}{
+		{"trailing slash rewritten", "GET", "/api/users/", 200, 0},
+		{"post rewritten", "POST", "/api/users/", 200, 0},
+		{"duplicate slashes", "GET", "//api//users//", 200, 0},
+		{"still unknown after rewrite", "GET", "/api/unknown/", 404, 404},
+		{"method not allowed after rewrite", "DELETE", "/api/users/", 405, 405},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := ut.PerformRequest(engine, tc.method, tc.url, nil)
+			resp := w.Result()
+			assert.Equal(t, tc.status, resp.StatusCode())
+
+			var result struct {
+				Code int               `json:"code"`
+				Data map[string]string `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(resp.Body(), &result))
+			assert.Equal(t, tc.code, result.Code)
+			if tc.status == 200 {
+				assert.Equal(t, "/api/users", result.Data["path"])
+				assert.Equal(t, "/api/users", result.Data["route"])
+			}
+		})
+	}
+}
+
+func TestPathCanonical_RejectEncodedTraversal(t *testing.T) {
+	engine := newPathTestEngine(PathConfig{Canonicalize: true})
+
+	for _, url := range []string{"/api/%2e%2e/users", "/api/.%2E/users", "/api%2fusers", "/api/users%5c", "/api/users%00"} {
+		t.Run(url, func(t *testing.T) {
+			w := ut.PerformRequest(engine, "GET", url, nil)
+			assert.Equal(t, 400, w.Result().StatusCode())
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// VersionETag 行版本号对应的 ETag（强校验）：7 → "v7"
+func VersionETag(version int64) string {
+	return `"v` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseVersionETag 解析 VersionETag 生成的 ETag（兼容 W/ 前缀），格式不符时 ok 为 false
+func ParseVersionETag(tag string) (version int64, ok bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 4 || tag[0] != '"' || tag[1] != 'v' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[2:len(tag)-1], 10, 64)
+	return version, err == nil && version >= 0
+}
+
+// SetVersionETag 设置 ETag 响应头，客户端更新时通过 If-Match 带回
+//
+// 使用方式：
+//
+//	user, _ := q.GetUser(ctx, id)
+//	web.SetVersionETag(c, user.Version)
+//	c.JSON(200, web.Success(user))
+func SetVersionETag(c *app.RequestContext, version int64) {
+	c.Header("ETag", VersionETag(version))
+}
+
+// IfMatchVersion 从 If-Match 请求头取出版本号（If-Match: "v7" → 7）
+//
+// 没有该请求头或为 * 时 ok 为 false；格式不是 VersionETag 或带了多个 ETag 时 panic 400
+func IfMatchVersion(c *app.RequestContext) (version int64, ok bool) {
+	header := strings.TrimSpace(string(c.GetHeader("If-Match")))
+	if header == "" || header == "*" {
+		return 0, false
+	}
+	version, ok = ParseVersionETag(header)
+	if !ok {
+		panic(BadRequestHTTP("Invalid If-Match header"))
+	}
+	return version, true
+}
+
+// RequireIfMatchVersion 条件更新：要求 If-Match 带上版本号，缺少时 panic 428 Precondition Required
+//
+// 取到的版本号直接作为乐观锁 UPDATE 的 version 参数，版本不一致时
+// database.CheckOptimisticUpdate 返回 ErrVersionConflict，由 ExceptionHandler 响应 409
+//
+// 使用方式：
+//
+//	h.PUT("/users/:id", func(ctx context.Context, c *app.RequestContext) {
+//	    version := web.RequireIfMatchVersion(c)
+//	    err := database.CheckOptimisticUpdate(q.UpdateUserName(ctx, sqlc.UpdateUserNameParams{
+//	        Name: req.Name, ID: id, Version: version,
+//	    }))
+//	    if err != nil {
+//	        panic(err)
+//	    }
+//	    web.SetVersionETag(c, version+1)
+//	    c.JSON(200, web.Success(nil))
+//	})
+func RequireIfMatchVersion(c *app.RequestContext) int64 {
+	version, ok := IfMatchVersion(c)
+	if !ok {
+		panic(NewHTTPException(http.StatusPreconditionRequired, http.StatusPreconditionRequired, "If-Match header required"))
+	}
+	return version
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionETag(t *testing.T) {
+	assert.Equal(t, `"v7"`, VersionETag(7))
+	for tag, want := range map[string]int64{`"v7"`: 7, `W/"v7"`: 7, ` "v0" `: 0} {
+		v, ok := ParseVersionETag(tag)
+		assert.True(t, ok, tag)
+		assert.Equal(t, want, v, tag)
+	}
+	for _, tag := range []string{`v7`, `"7"`, `"v"`, `"v-1"`, `"v7", "v8"`, `"abc"`} {
+		_, ok := ParseVersionETag(tag)
+		assert.False(t, ok, tag)
+	}
+}
+
+// versionedRow 模拟带 version 列的一行：update 与 UPDATE ... WHERE version = ? 语义一致
+type versionedRow struct {
+	mu      sync.Mutex
+	name    string
+	version int64
+}
+
+func (r *versionedRow) update(name string, version int64) (sql.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.version != version {
+		return driver.RowsAffected(0), nil
+	}
+	r.name, r.version = name, r.version+1
+	return driver.RowsAffected(1), nil
+}
+
+func newVersionedEngine(row *versionedRow) *route.Engine {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.GET("/users/1", func(ctx context.Context, c *app.RequestContext) {
+		row.mu.Lock()
+		defer row.mu.Unlock()
+		SetVersionETag(c, row.version)
+		c.JSON(200, Success(row.name))
+	})
+	engine.PUT("/users/1", func(ctx context.Context, c *app.RequestContext) {
+		version := RequireIfMatchVersion(c)
+		if err := database.CheckOptimisticUpdate(row.update(string(c.Query("name")), version)); err != nil {
+			panic(err)
+		}
+		SetVersionETag(c, version+1)
+		c.JSON(200, Success(nil))
+	})
+	engine.PUT("/wrapped/users/1", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return database.CheckOptimisticUpdate(row.update(string(c.Query("name")), RequireIfMatchVersion(c)))
+	}))
+	return engine
+}
+
+func TestOptimisticUpdate_ConcurrentWritersOneWins(t *testing.T) {
+	row := &versionedRow{name: "张三", version: 1}
+	engine := newVersionedEngine(row)
+
+	etag := ut.PerformRequest(engine, "GET", "/users/1", nil).Header().Get("ETag")
+	require.Equal(t, `"v1"`, etag)
+
+	// 两个客户端读到同一版本后同时更新
+	var wg sync.WaitGroup
+	responses := make([]*ut.ResponseRecorder, 2)
+	start := make(chan struct{})
+	for i, name := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			responses[i] = ut.PerformRequest(engine, "PUT", "/users/1?name="+name, nil, ut.Header{Key: "If-Match", Value: etag})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	codes := []int{responses[0].Code, responses[1].Code}
+	assert.ElementsMatch(t, []int{200, 409}, codes, "恰好一个成功")
+	winner, loser := responses[0], responses[1]
+	if winner.Code != 200 {
+		winner, loser = loser, winner
+	}
+	assert.Equal(t, `"v2"`, winner.Header().Get("ETag"))
+
+	var resp Result
+	require.NoError(t, json.Unmarshal(loser.Body.Bytes(), &resp))
+	assert.Equal(t, int(VersionConflict), resp.Code)
+	assert.Equal(t, MsgVersionConflict, resp.Message)
+	assert.Equal(t, int64(2), row.version, "只更新了一次")
+
+	// 失败方重新获取后带新版本重试
+	etag = ut.PerformRequest(engine, "GET", "/users/1", nil).Header().Get("ETag")
+	w := ut.PerformRequest(engine, "PUT", "/users/1?name=carol", nil, ut.Header{Key: "If-Match", Value: etag})
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "carol", row.name)
+}
+
+func TestOptimisticUpdate_Preconditions(t *testing.T) {
+	row := &versionedRow{name: "张三", version: 3}
+	engine := newVersionedEngine(row)
+
+	assert.Equal(t, 428, ut.PerformRequest(engine, "PUT", "/users/1?name=a", nil).Code, "缺少 If-Match")
+	assert.Equal(t, 400, ut.PerformRequest(engine, "PUT", "/users/1?name=a", nil, ut.Header{Key: "If-Match", Value: "abc"}).Code)
+
+	w := ut.PerformRequest(engine, "PUT", "/wrapped/users/1?name=a", nil, ut.Header{Key: "If-Match", Value: `"v2"`})
+	assert.Equal(t, 409, w.Code, "WrapHandler 返回的冲突同样映射为 409")
+	assert.Contains(t, w.Body.String(), `"code":10010`)
+	w = ut.PerformRequest(engine, "PUT", "/wrapped/users/1?name=a", nil, ut.Header{Key: "If-Match", Value: `W/"v3"`})
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "a", row.name)
+}
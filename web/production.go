@@ -0,0 +1,447 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	corsMiddleware "github.com/hertz-contrib/cors"
+)
+
+// EnvProduction 生产环境名称（[web] environment 或环境变量 APP_ENV）
+const EnvProduction = "production"
+
+// EnvVarEnvironment 覆盖 [web] environment 的环境变量
+const EnvVarEnvironment = "APP_ENV"
+
+// 内置的生产检查项（allowInsecure 中使用的名称）
+const (
+	CheckWeakSecrets             = "weakSecrets"             // JWT 密钥等过短或仍是示例值
+	CheckCORSWildcardCredentials = "corsWildcardCredentials" // CORS 允许任意来源且携带凭证
+	CheckDebugEndpoints          = "debugEndpoints"          // /debug、/swagger、/mock 等接口未要求认证
+	CheckTLSDisabled             = "tlsDisabled"             // 直接对外暴露但没有 TLS
+	CheckDefaultCredentials      = "defaultCredentials"      // 数据库、Redis 等使用默认密码
+	CheckTrustedProxies          = "trustedProxies"          // 信任任何来源的 X-Forwarded-For
+)
+
+// minSecretLength 生产环境密钥的最小长度
+const minSecretLength = 32
+
+// ProductionConfig 生产严格模式配置
+//
+// 配置示例：
+//
+//	[web]
+//	environment = "production"
+//
+//	[web.production]
+//	tlsTerminated = true              # TLS 由负载均衡终止
+//
+//	[web.production.allowInsecure]
+//	debugEndpoints = true             # 明确豁免某项检查（启动时醒目告警，并出现在 /health 中）
+type ProductionConfig struct {
+	Public        bool            `toml:"public"`        // 服务直接对外暴露（没有前置网关）
+	TLSTerminated bool            `toml:"tlsTerminated"` // TLS 已由前置负载均衡 / 网关终止
+	AllowInsecure map[string]bool `toml:"allowInsecure"` // 豁免的检查项
+}
+
+// ProductionEnv 生产检查的输入
+type ProductionEnv struct {
+	Config    Config      // web 配置
+	AppConfig any         // 应用的完整配置（为 nil 时只检查 web 配置）
+	Routes    []RouteInfo // 已注册的路由
+}
+
+// ProductionCheck 一项生产检查，返回发现的问题（为空表示通过）
+type ProductionCheck func(env ProductionEnv) []string
+
+// ProductionFinding 一项未通过的检查
+type ProductionFinding struct {
+	Check    string   `json:"check"`
+	Problems []string `json:"problems"`
+}
+
+// ProductionReport 生产检查报告
+type ProductionReport struct {
+	Environment string              `json:"environment"`
+	Passed      []string            `json:"passed"`
+	Failed      []ProductionFinding `json:"failed,omitempty"`
+	Overridden  []ProductionFinding `json:"overridden,omitempty"` // 未通过但被 allowInsecure 豁免
+}
+
+// OK 没有未豁免的问题
+func (r ProductionReport) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// String 汇总报告（启动失败时作为 panic 信息）
+func (r ProductionReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "生产环境检查: %d 项通过, %d 项未通过, %d 项豁免", len(r.Passed), len(r.Failed), len(r.Overridden))
+	write := func(tag string, findings []ProductionFinding) {
+		for _, f := range findings {
+			for _, p := range f.Problems {
+				fmt.Fprintf(&b, "\n  [%s] %s: %s", tag, f.Check, p)
+			}
+		}
+	}
+	write("FAIL", r.Failed)
+	write("ALLOW", r.Overridden)
+	if len(r.Failed) > 0 {
+		b.WriteString("\n修复以上问题，或在 [web.production.allowInsecure] 中明确豁免对应检查项")
+	}
+	return b.String()
+}
+
+type namedCheck struct {
+	name  string
+	check ProductionCheck
+}
+
+var (
+	productionMu     sync.Mutex
+	productionChecks = []namedCheck{
+		{CheckWeakSecrets, checkWeakSecrets},
+		{CheckCORSWildcardCredentials, checkCORSWildcardCredentials},
+		{CheckDebugEndpoints, checkDebugEndpoints},
+		{CheckTLSDisabled, checkTLSDisabled},
+		{CheckDefaultCredentials, checkDefaultCredentials},
+		{CheckTrustedProxies, checkTrustedProxies},
+	}
+	productionReport atomic.Pointer[ProductionReport]
+)
+
+// RegisterProductionCheck 注册应用自己的生产检查（同名时替换）
+//
+// 检查在 MustRun 监听前执行，与内置检查一样可以通过 allowInsecure.<name> 豁免
+//
+// 使用方式：
+//
+//	web.RegisterProductionCheck("paymentSandbox", func(env web.ProductionEnv) []string {
+//	    if cfg.GetCfg[AppConfig]().Payment.Sandbox {
+//	        return []string{"支付仍在使用沙箱环境 (payment.sandbox)"}
+//	    }
+//	    return nil
+//	})
+func RegisterProductionCheck(name string, check ProductionCheck) {
+	productionMu.Lock()
+	defer productionMu.Unlock()
+	for i, c := range productionChecks {
+		if c.name == name {
+			productionChecks[i].check = check
+			return
+		}
+	}
+	productionChecks = append(productionChecks, namedCheck{name: name, check: check})
+}
+
+// Environment 当前运行环境（环境变量 APP_ENV 优先于 [web] environment）
+func Environment(config Config) string {
+	if env := os.Getenv(EnvVarEnvironment); env != "" {
+		return env
+	}
+	return config.Environment
+}
+
+// IsProduction 是否运行在生产环境
+func IsProduction(config Config) bool {
+	return strings.EqualFold(Environment(config), EnvProduction)
+}
+
+// RunProductionChecks 执行全部生产检查（不论当前环境），豁免的检查项记录在 Overridden 中
+func RunProductionChecks(env ProductionEnv) ProductionReport {
+	productionMu.Lock()
+	checks := slices.Clone(productionChecks)
+	productionMu.Unlock()
+
+	report := ProductionReport{Environment: Environment(env.Config)}
+	for _, c := range checks {
+		problems := c.check(env)
+		switch {
+		case len(problems) == 0:
+			report.Passed = append(report.Passed, c.name)
+		case env.Config.Production.AllowInsecure[c.name]:
+			report.Overridden = append(report.Overridden, ProductionFinding{Check: c.name, Problems: problems})
+		default:
+			report.Failed = append(report.Failed, ProductionFinding{Check: c.name, Problems: problems})
+		}
+	}
+	return report
+}
+
+// CurrentProductionReport 启动时的生产检查报告（非生产环境为 nil）
+func CurrentProductionReport() *ProductionReport {
+	return productionReport.Load()
+}
+
+// mustPassProductionChecks 生产环境下执行启动检查：输出报告，豁免项醒目告警，存在未豁免的问题时中止启动
+func mustPassProductionChecks(env ProductionEnv) {
+	if !IsProduction(env.Config) {
+		return
+	}
+	report := RunProductionChecks(env)
+	productionReport.Store(&report)
+
+	for _, name := range report.Passed {
+		log.Infof("[Production] 检查通过: %s", name)
+	}
+	for _, f := range report.Overridden {
+		for _, p := range f.Problems {
+			log.Warnf("[Production] !!! 不安全配置已被豁免 (allowInsecure.%s): %s", f.Check, p)
+		}
+	}
+	for _, f := range report.Failed {
+		for _, p := range f.Problems {
+			log.Errorf("[Production] 检查未通过 %s: %s", f.Check, p)
+		}
+	}
+	if !report.OK() {
+		panic(report.String())
+	}
+}
+
+// ========== 内置检查 ==========
+
+// placeholderMarkers 示例配置中常见的占位值
+var placeholderMarkers = []string{"change-this", "change-me", "changeme", "change_me", "your-", "your_", "example", "placeholder", "default", "todo", "xxx"}
+
+// defaultPasswords 常见的默认口令
+var defaultPasswords = []string{"admin", "password", "123456", "12345678", "root", "changeme", "secret", "admin123", "test", "guest"}
+
+func checkWeakSecrets(env ProductionEnv) []string {
+	var problems []string
+	check := func(path, value string) {
+		lower := strings.ToLower(value)
+		for _, marker := range placeholderMarkers {
+			if strings.Contains(lower, marker) {
+				problems = append(problems, fmt.Sprintf("%s 仍是示例值", path))
+				return
+			}
+		}
+		if len(value) < minSecretLength {
+			problems = append(problems, fmt.Sprintf("%s 长度 %d，至少需要 %d", path, len(value), minSecretLength))
+		}
+	}
+
+	if a := jwt.Default(); a != nil && a.Config().SecretKey == "" {
+		check("jwt.secret", a.Config().Secret)
+	}
+	for _, v := range configStrings(env) {
+		if v.value == "" || strings.HasPrefix(v.value, "env:") || isPasswordKey(v.path) {
+			continue
+		}
+		name := strings.ToLower(v.path[strings.LastIndex(v.path, ".")+1:])
+		if v.sensitive || strings.Contains(name, "secret") || strings.Contains(name, "token") {
+			check(v.path, v.value)
+		}
+	}
+	return problems
+}
+
+func checkCORSWildcardCredentials(env ProductionEnv) []string {
+	cors := corsConfig(env.Config.CORS)
+	if cors.AllowCredentials && (cors.AllowAllOrigins || slices.Contains(cors.AllowOrigins, "*")) {
+		return []string{"CORS 允许任意来源 (*) 且携带凭证，请配置 [web.cors] allowOrigins"}
+	}
+	return nil
+}
+
+// debugPrefixes 只应在开发环境开放或必须认证的路径
+var debugPrefixes = []string{"/debug", "/swagger", "/mock"}
+
+func checkDebugEndpoints(env ProductionEnv) []string {
+	var problems []string
+	for _, r := range env.Routes {
+		if !hasDebugPrefix(r.Path) {
+			continue
+		}
+		auth := r.Auth
+		if auth == AuthUnspecified {
+			auth = r.GroupAuth
+		}
+		if auth != AuthRequired {
+			problems = append(problems, fmt.Sprintf("%s %s 未要求认证", r.Method, r.Path))
+		}
+	}
+	if len(env.Routes) == 0 && env.Config.Routes.Debug {
+		problems = append(problems, "已开启 /debug/* 路由 (web.routes.debug)")
+	}
+	return problems
+}
+
+func hasDebugPrefix(path string) bool {
+	for _, prefix := range debugPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTLSDisabled(env ProductionEnv) []string {
+	p := env.Config.Production
+	if p.Public && !p.TLSTerminated {
+		return []string{"服务直接对外暴露 (production.public) 但没有 TLS，请在前置负载均衡终止 TLS 并设置 production.tlsTerminated"}
+	}
+	return nil
+}
+
+func checkDefaultCredentials(env ProductionEnv) []string {
+	var problems []string
+	for _, v := range configStrings(env) {
+		if v.value == "" || !isPasswordKey(v.path) {
+			continue
+		}
+		if slices.Contains(defaultPasswords, strings.ToLower(v.value)) {
+			problems = append(problems, fmt.Sprintf("%s 使用默认口令", v.path))
+		}
+	}
+	return problems
+}
+
+func checkTrustedProxies(env ProductionEnv) []string {
+	p := env.Config.Proxy
+	if !p.IgnoreForwarded && len(p.TrustedProxies) == 0 {
+		return []string{"信任任何来源的 X-Forwarded-For / X-Real-IP，请配置 [web.proxy] trustedProxies 或 ignoreForwarded"}
+	}
+	return nil
+}
+
+func isPasswordKey(path string) bool {
+	name := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	return strings.Contains(name, "password") || strings.Contains(name, "passwd")
+}
+
+// ========== CORS 与客户端 IP ==========
+
+// corsConfig 把 [web.cors] 转换为 CORS 中间件配置（未配置的项使用默认值）
+func corsConfig(c CORSConfig) corsMiddleware.Config {
+	conf := corsMiddleware.Config{
+		AllowOrigins:     c.AllowOrigins,
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		AllowCredentials: true,
+	}
+	if len(conf.AllowOrigins) == 0 {
+		conf.AllowOrigins = []string{"*"}
+	}
+	if len(conf.AllowMethods) == 0 {
+		conf.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	}
+	if len(conf.AllowHeaders) == 0 {
+		conf.AllowHeaders = []string{"Content-Type", "Authorization"}
+	}
+	if c.AllowCredentials != nil {
+		conf.AllowCredentials = *c.AllowCredentials
+	}
+	return conf
+}
+
+// clientIPFunc 按 [web.proxy] 生成客户端 IP 解析函数（未配置时返回 nil，使用 Hertz 默认行为）
+func clientIPFunc(p ProxyConfig) (app.ClientIP, error) {
+	if p.IgnoreForwarded {
+		return app.ClientIPWithOption(app.ClientIPOptions{}), nil
+	}
+	if len(p.TrustedProxies) == 0 {
+		return nil, nil
+	}
+	cidrs := make([]*net.IPNet, 0, len(p.TrustedProxies))
+	for _, s := range p.TrustedProxies {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的可信代理地址: %s", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理网段: %s", s)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return app.ClientIPWithOption(app.ClientIPOptions{
+		RemoteIPHeaders: []string{"X-Forwarded-For", "X-Real-IP"},
+		TrustedCIDRs:    cidrs,
+	}), nil
+}
+
+// ========== 配置遍历 ==========
+
+type configString struct {
+	path      string
+	value     string
+	sensitive bool
+}
+
+// configStrings 按 toml 键名列出配置中的全部字符串值（包括 map 和切片中的）
+func configStrings(env ProductionEnv) []configString {
+	var root any = env.Config
+	if env.AppConfig != nil {
+		root = env.AppConfig
+	}
+	var out []configString
+	var walk func(path string, v reflect.Value, sensitive bool)
+	walk = func(path string, v reflect.Value, sensitive bool) {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.String:
+			out = append(out, configString{path: path, value: v.String(), sensitive: sensitive})
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(path, v.Index(i), sensitive)
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				walk(joinConfigPath(path, fmt.Sprint(iter.Key().Interface())), iter.Value(), sensitive)
+			}
+		case reflect.Struct:
+			t := v.Type()
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if !f.IsExported() {
+					continue
+				}
+				name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+				if name == "-" {
+					continue
+				}
+				if f.Anonymous && name == "" {
+					walk(path, v.Field(i), sensitive)
+					continue
+				}
+				if name == "" {
+					name = f.Name
+				}
+				walk(joinConfigPath(path, name), v.Field(i), sensitive || f.Tag.Get("sensitive") == "true")
+			}
+		}
+	}
+	walk("", reflect.ValueOf(root), false)
+	return out
+}
+
+func joinConfigPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,168 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type productionApp struct {
+	Config
+	Legacy struct {
+		User     string `toml:"user"`
+		Password string `toml:"password"`
+	} `toml:"legacy"`
+	WebhookSecret string `toml:"webhookSecret"`
+}
+
+// secureProductionEnv 全部内置检查都能通过的配置
+func secureProductionEnv() ProductionEnv {
+	appCfg := &productionApp{}
+	appCfg.Environment = EnvProduction
+	appCfg.CORS.AllowOrigins = []string{"https://app.example.com"}
+	appCfg.Proxy.TrustedProxies = []string{"10.0.0.0/8"}
+	appCfg.Database.Password = "s3cure-db-password-from-vault"
+	appCfg.Maintenance.BypassToken = "0f8e1c2d3b4a59687766554433221100ffee"
+	appCfg.WebhookSecret = "whsec_9c2f7e4b1a6d3f8e0b5c2a7d4e1f6a3b"
+	return ProductionEnv{
+		Config:    appCfg.Config,
+		AppConfig: appCfg,
+		Routes: []RouteInfo{
+			{Method: "GET", Path: "/api/orders", Auth: AuthRequired},
+			{Method: "GET", Path: "/debug/routes", GroupAuth: AuthRequired},
+		},
+	}
+}
+
+func findingFor(findings []ProductionFinding, check string) *ProductionFinding {
+	for i := range findings {
+		if findings[i].Check == check {
+			return &findings[i]
+		}
+	}
+	return nil
+}
+
+func TestProductionChecks_TriggerAndOverride(t *testing.T) {
+	require.NoError(t, jwt.Init(jwt.Config{Secret: "7c1d9e4f2a8b6c3d0e5f1a7b9c2d4e6f8a0b"}))
+
+	cases := []struct {
+		check  string
+		mutate func(t *testing.T, env *ProductionEnv)
+		want   string
+	}{
+		{CheckWeakSecrets, func(t *testing.T, env *ProductionEnv) {
+			env.AppConfig.(*productionApp).WebhookSecret = "short"
+		}, "webhookSecret 长度 5"},
+		{CheckWeakSecrets, func(t *testing.T, env *ProductionEnv) {
+			env.AppConfig.(*productionApp).Maintenance.BypassToken = "change-this-token-before-going-live-please"
+		}, "maintenance.bypassToken 仍是示例值"},
+		{CheckWeakSecrets, func(t *testing.T, env *ProductionEnv) {
+			require.NoError(t, jwt.Init(jwt.Config{Secret: "your-secret-key-change-in-production"}))
+			t.Cleanup(func() { _ = jwt.Init(jwt.Config{Secret: "7c1d9e4f2a8b6c3d0e5f1a7b9c2d4e6f8a0b"}) })
+		}, "jwt.secret 仍是示例值"},
+		{CheckCORSWildcardCredentials, func(t *testing.T, env *ProductionEnv) {
+			env.Config.CORS = CORSConfig{}
+		}, "CORS 允许任意来源"},
+		{CheckDebugEndpoints, func(t *testing.T, env *ProductionEnv) {
+			env.Routes = append(env.Routes, RouteInfo{Method: "GET", Path: "/swagger/*any", Direct: true})
+		}, "GET /swagger/*any 未要求认证"},
+		{CheckTLSDisabled, func(t *testing.T, env *ProductionEnv) {
+			env.Config.Production.Public = true
+		}, "没有 TLS"},
+		{CheckDefaultCredentials, func(t *testing.T, env *ProductionEnv) {
+			env.AppConfig.(*productionApp).Legacy.Password = "Admin"
+		}, "legacy.password 使用默认口令"},
+		{CheckTrustedProxies, func(t *testing.T, env *ProductionEnv) {
+			env.Config.Proxy = ProxyConfig{}
+		}, "X-Forwarded-For"},
+	}
+
+	report := RunProductionChecks(secureProductionEnv())
+	require.True(t, report.OK(), report.String())
+	assert.Len(t, report.Passed, 6)
+
+	for _, tc := range cases {
+		t.Run(tc.check, func(t *testing.T) {
+			env := secureProductionEnv()
+			tc.mutate(t, &env)
+
+			report := RunProductionChecks(env)
+			failed := findingFor(report.Failed, tc.check)
+			require.NotNil(t, failed, report.String())
+			assert.Contains(t, failed.Problems[0], tc.want)
+			assert.Len(t, report.Failed, 1, "只有被修改的检查项未通过")
+			assert.Contains(t, report.String(), "[FAIL] "+tc.check)
+
+			env.Config.Production.AllowInsecure = map[string]bool{tc.check: true}
+			report = RunProductionChecks(env)
+			assert.True(t, report.OK())
+			overridden := findingFor(report.Overridden, tc.check)
+			require.NotNil(t, overridden)
+			assert.Contains(t, overridden.Problems[0], tc.want)
+			assert.Contains(t, report.String(), "[ALLOW] "+tc.check)
+		})
+	}
+}
+
+func TestProductionChecks_StartupGateAndCustomChecks(t *testing.T) {
+	require.NoError(t, jwt.Init(jwt.Config{Secret: "7c1d9e4f2a8b6c3d0e5f1a7b9c2d4e6f8a0b"}))
+	t.Cleanup(func() { productionReport.Store(nil) })
+
+	sandbox := true
+	RegisterProductionCheck("paymentSandbox", func(env ProductionEnv) []string {
+		if sandbox {
+			return []string{"支付仍在使用沙箱环境"}
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		productionMu.Lock()
+		productionChecks = productionChecks[:len(productionChecks)-1]
+		productionMu.Unlock()
+	})
+
+	// 非生产环境不检查
+	env := secureProductionEnv()
+	env.Config.Environment = "staging"
+	assert.NotPanics(t, func() { mustPassProductionChecks(env) })
+	assert.Nil(t, CurrentProductionReport())
+
+	// APP_ENV 优先于配置文件
+	t.Setenv(EnvVarEnvironment, "production")
+	assert.PanicsWithValue(t, RunProductionChecks(env).String(), func() { mustPassProductionChecks(env) })
+	assert.Contains(t, CurrentProductionReport().String(), "paymentSandbox: 支付仍在使用沙箱环境")
+
+	env.Config.Production.AllowInsecure = map[string]bool{"paymentSandbox": true}
+	assert.NotPanics(t, func() { mustPassProductionChecks(env) })
+	require.NotNil(t, CurrentProductionReport())
+	assert.Equal(t, "paymentSandbox", CurrentProductionReport().Overridden[0].Check)
+
+	sandbox = false
+	assert.NotPanics(t, func() { mustPassProductionChecks(env) })
+	assert.Empty(t, CurrentProductionReport().Overridden)
+	assert.Contains(t, CurrentProductionReport().Passed, "paymentSandbox")
+}
+
+func TestClientIPFunc_TrustedProxies(t *testing.T) {
+	f, err := clientIPFunc(ProxyConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, f, "未配置时保持默认行为")
+	_, err = clientIPFunc(ProxyConfig{TrustedProxies: []string{"not-an-ip"}})
+	assert.Error(t, err)
+
+	clientIP := func(p ProxyConfig) string {
+		f, err := clientIPFunc(p)
+		require.NoError(t, err)
+		c := app.NewContext(0) // 没有连接时远端地址为 0.0.0.0
+		c.Request.Header.Set("X-Forwarded-For", "203.0.113.7")
+		return f(c)
+	}
+
+	assert.Equal(t, "0.0.0.0", clientIP(ProxyConfig{IgnoreForwarded: true}))
+	assert.Equal(t, "203.0.113.7", clientIP(ProxyConfig{TrustedProxies: []string{"0.0.0.0"}}))
+	assert.Equal(t, "0.0.0.0", clientIP(ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}}), "不可信来源的转发头被忽略")
+}
@@ -0,0 +1,178 @@
+// Package queue 外部消息队列的统一抽象，供 web.NewConsumer 消费
+//
+// Source 只需要实现取消息、确认和放回三个操作；死信和积压量是可选能力
+// （分别实现 DeadLetterer / LagReporter）。内置 Redis Streams 实现（RedisStream）
+// 和内存实现（MemorySource，用于测试和本地开发），Kafka / RabbitMQ 等按同样的接口接入
+package queue
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Message 一条消息
+type Message struct {
+	ID       string            // 队列内的消息 ID
+	Key      string            // 顺序键：同一个键的消息按到达顺序串行处理（为空时不保证顺序）
+	Body     []byte            // 消息体
+	Headers  map[string]string // 附加字段（request_id 等）
+	Attempts int               // 之前已经投递过的次数（队列能提供时）
+}
+
+// Source 消息来源
+type Source interface {
+	// Fetch 取下一条消息，没有消息时阻塞直到有消息或 ctx 取消
+	Fetch(ctx context.Context) (Message, error)
+	// Ack 确认消息已处理完成
+	Ack(ctx context.Context, msg Message) error
+	// Nack 消息未处理完成（如消费者停止），交还给队列以便重新投递
+	Nack(ctx context.Context, msg Message) error
+}
+
+// DeadLetterer 支持死信的来源：重试耗尽的消息写入死信后再 Ack
+type DeadLetterer interface {
+	DeadLetter(ctx context.Context, msg Message, cause error) error
+}
+
+// LagReporter 能报告积压量的来源
+type LagReporter interface {
+	// Lag 尚未投递给本消费组的消息数
+	Lag(ctx context.Context) (int64, error)
+}
+
+// DeadMessage 内存来源中的死信
+type DeadMessage struct {
+	Message
+	Error string
+}
+
+// MemorySource 内存消息来源（测试和本地开发使用）
+//
+// 使用方式：
+//
+//	source := queue.NewMemorySource()
+//	source.Publish("order-1", []byte(`{"event":"paid"}`))
+type MemorySource struct {
+	mu      sync.Mutex
+	cond    chan struct{} // 有新消息时关闭并替换
+	queue   []Message
+	pending map[string]Message // 已取出未确认
+	acked   []Message
+	dead    []DeadMessage
+	seq     int
+}
+
+// NewMemorySource 创建内存消息来源
+func NewMemorySource() *MemorySource {
+	return &MemorySource{cond: make(chan struct{}), pending: make(map[string]Message)}
+}
+
+// Publish 追加一条消息，返回消息 ID
+func (s *MemorySource) Publish(key string, body []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg := Message{ID: strconv.Itoa(s.seq), Key: key, Body: body}
+	s.queue = append(s.queue, msg)
+	s.signalLocked()
+	return msg.ID
+}
+
+func (s *MemorySource) signalLocked() {
+	close(s.cond)
+	s.cond = make(chan struct{})
+}
+
+// Fetch 实现 Source 接口
+func (s *MemorySource) Fetch(ctx context.Context) (Message, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			msg := s.queue[0]
+			s.queue = s.queue[1:]
+			s.pending[msg.ID] = msg
+			s.mu.Unlock()
+			return msg, nil
+		}
+		wait := s.cond
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Ack 实现 Source 接口
+func (s *MemorySource) Ack(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, msg.ID)
+	s.acked = append(s.acked, msg)
+	return nil
+}
+
+// Nack 实现 Source 接口：消息按原来的顺序放回队列，投递次数加一
+func (s *MemorySource) Nack(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, msg.ID)
+	msg.Attempts++
+	seq, _ := strconv.Atoi(msg.ID)
+	i := 0
+	for i < len(s.queue) {
+		if n, _ := strconv.Atoi(s.queue[i].ID); n > seq {
+			break
+		}
+		i++
+	}
+	s.queue = slices.Insert(s.queue, i, msg)
+	s.signalLocked()
+	return nil
+}
+
+// DeadLetter 实现 DeadLetterer 接口
+func (s *MemorySource) DeadLetter(ctx context.Context, msg Message, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dead = append(s.dead, DeadMessage{Message: msg, Error: cause.Error()})
+	return nil
+}
+
+// Lag 实现 LagReporter 接口
+func (s *MemorySource) Lag(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.queue)), nil
+}
+
+// Acked 已确认的消息（按确认顺序）
+func (s *MemorySource) Acked() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.acked...)
+}
+
+// Dead 死信
+func (s *MemorySource) Dead() []DeadMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadMessage(nil), s.dead...)
+}
+
+// Pending 已取出但未确认的消息数
+func (s *MemorySource) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Queued 尚未取出的消息数
+func (s *MemorySource) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
@@ -0,0 +1,303 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis Streams 消息中的保留字段
+const (
+	FieldKey  = "key"  // 顺序键
+	FieldBody = "body" // 消息体
+)
+
+// RedisStreamConfig Redis Streams 消费配置
+//
+// Example:
+//
+//	[orders.stream]
+//	stream = "orders"
+//	group = "billing"
+//	deadLetter = "orders:dead"   # 默认 <stream>:dead
+//	count = 10                   # 每次读取条数
+//	blockMs = 2000               # 没有消息时阻塞等待的毫秒数（也是停止时的最大等待）
+//	claimIdleMs = 60000          # 接手其他消费者超过该时长未确认的消息（0 表示不接手）
+//	maxDeliveries = 10           # 重新投递的消息超过该投递次数时直接写入死信（0 表示不限制）
+type RedisStreamConfig struct {
+	Stream        string `toml:"stream"`
+	Group         string `toml:"group"`
+	Consumer      string `toml:"consumer"` // 消费者名，默认 主机名-进程号
+	DeadLetter    string `toml:"deadLetter"`
+	Count         int    `toml:"count"`
+	BlockMs       int    `toml:"blockMs"`
+	ClaimIdleMs   int    `toml:"claimIdleMs"`
+	MaxDeliveries int    `toml:"maxDeliveries"`
+}
+
+func (c RedisStreamConfig) withDefaults() RedisStreamConfig {
+	if c.Consumer == "" {
+		hostname, _ := os.Hostname()
+		c.Consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if c.DeadLetter == "" {
+		c.DeadLetter = c.Stream + ":dead"
+	}
+	if c.Count <= 0 {
+		c.Count = 10
+	}
+	if c.BlockMs <= 0 {
+		c.BlockMs = 2000
+	}
+	return c
+}
+
+// RedisStream 基于 Redis Streams 消费组的消息来源
+//
+// 启动时先重新投递本消费者名下未确认的消息（上次退出时处理到一半的），再读取新消息；
+// Nack 的消息留在待确认列表中，由重启后的本消费者或 claimIdleMs 到期后的其他消费者接手。
+// 重新投递的消息按 XPENDING 的投递次数设置 Attempts；超过 maxDeliveries 的消息（每次处理都让进程崩溃的
+// 毒消息）不再交给处理函数，直接写入死信并确认
+//
+// 同一消费组的多个副本各自使用不同的消费者名：新消息由消费组分配给其中一个，崩溃副本名下的消息
+// 由其他副本通过 XAUTOCLAIM 接手。接手与原消费者的处理可能重叠（处理时间超过 claimIdleMs 时），
+// 需要恰好一次效果时配合 web.WithIdempotency 使用
+//
+// 使用方式：
+//
+//	source := queue.NewRedisStream(cache.Client, config.Orders.Stream)
+//	consumer := web.NewConsumer(source, handleOrderEvent, web.WithConsumerName("orders"))
+type RedisStream struct {
+	client redis.UniversalClient
+	config RedisStreamConfig
+
+	mu          sync.Mutex
+	buffer      []Message
+	groupReady  bool
+	pendingDone bool   // 本消费者名下的旧消息已重新投递完
+	pendingFrom string // 读取旧消息的游标
+	claimCursor string // XAUTOCLAIM 的游标
+}
+
+// NewRedisStream 创建 Redis Streams 消息来源
+func NewRedisStream(client redis.UniversalClient, config RedisStreamConfig) *RedisStream {
+	return &RedisStream{client: client, config: config.withDefaults(), pendingFrom: "0", claimCursor: "0-0"}
+}
+
+// Publish 向 stream 追加一条消息
+func Publish(ctx context.Context, client redis.UniversalClient, stream, key string, body []byte, headers map[string]string) (string, error) {
+	values := make([]any, 0, 4+2*len(headers))
+	values = append(values, FieldKey, key, FieldBody, body)
+	for k, v := range headers {
+		values = append(values, k, v)
+	}
+	return client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+// Fetch 实现 Source 接口
+func (s *RedisStream) Fetch(ctx context.Context) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buffer) == 0 {
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+		if err := s.fill(ctx); err != nil {
+			return Message{}, err
+		}
+	}
+	msg := s.buffer[0]
+	s.buffer = s.buffer[1:]
+	return msg, nil
+}
+
+// fill 读取一批消息：先本消费者的旧消息，再接手超时的消息，最后读新消息
+func (s *RedisStream) fill(ctx context.Context) error {
+	if !s.groupReady {
+		err := s.client.XGroupCreateMkStream(ctx, s.config.Stream, s.config.Group, "0").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("创建消费组 %s/%s 失败: %w", s.config.Stream, s.config.Group, err)
+		}
+		s.groupReady = true
+	}
+
+	if !s.pendingDone {
+		streams, err := s.read(ctx, s.pendingFrom, -1)
+		if err != nil {
+			return err
+		}
+		if len(streams) == 0 {
+			s.pendingDone = true
+		} else {
+			s.pendingFrom = streams[len(streams)-1].ID
+		}
+		redelivered, err := s.redelivered(ctx, streams)
+		if err != nil {
+			return err
+		}
+		s.buffer = append(s.buffer, redelivered...)
+		if len(s.buffer) > 0 || !s.pendingDone {
+			return nil
+		}
+	}
+
+	if s.config.ClaimIdleMs > 0 {
+		messages, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.config.Stream,
+			Group:    s.config.Group,
+			Consumer: s.config.Consumer,
+			MinIdle:  time.Duration(s.config.ClaimIdleMs) * time.Millisecond,
+			Start:    s.claimCursor,
+			Count:    int64(s.config.Count),
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("接手超时消息失败: %w", err)
+		}
+		s.claimCursor = next
+		claimed := make([]Message, 0, len(messages))
+		for _, m := range messages {
+			claimed = append(claimed, toMessage(m))
+		}
+		redelivered, err := s.redelivered(ctx, claimed)
+		if err != nil {
+			return err
+		}
+		s.buffer = append(s.buffer, redelivered...)
+		if len(s.buffer) > 0 {
+			return nil
+		}
+	}
+
+	streams, err := s.read(ctx, ">", time.Duration(s.config.BlockMs)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	s.buffer = append(s.buffer, streams...)
+	return nil
+}
+
+// read XREADGROUP（id 为 ">" 读新消息，否则读本消费者 ID 大于 id 的待确认消息）
+func (s *RedisStream) read(ctx context.Context, id string, block time.Duration) ([]Message, error) {
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.config.Group,
+		Consumer: s.config.Consumer,
+		Streams:  []string{s.config.Stream, id},
+		Count:    int64(s.config.Count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 stream %s 失败: %w", s.config.Stream, err)
+	}
+	var out []Message
+	for _, stream := range streams {
+		for _, m := range stream.Messages {
+			out = append(out, toMessage(m))
+		}
+	}
+	return out, nil
+}
+
+// redelivered 按待确认列表中的投递次数设置 Attempts，超过 maxDeliveries 的消息写入死信并确认
+//
+// 每条消息一次 XPENDING（同一个 pipeline），只对重新投递的消息执行，新消息不受影响
+func (s *RedisStream) redelivered(ctx context.Context, msgs []Message) ([]Message, error) {
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.XPendingExtCmd, len(msgs))
+	for i, msg := range msgs {
+		cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: s.config.Stream,
+			Group:  s.config.Group,
+			Start:  msg.ID,
+			End:    msg.ID,
+			Count:  1,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("读取投递次数失败: %w", err)
+	}
+
+	out := msgs[:0]
+	for i, msg := range msgs {
+		deliveries := int64(1)
+		if pending, err := cmds[i].Result(); err == nil && len(pending) == 1 {
+			deliveries = max(pending[0].RetryCount, 1)
+		}
+		msg.Attempts = int(deliveries) - 1
+		if s.config.MaxDeliveries > 0 && deliveries > int64(s.config.MaxDeliveries) {
+			cause := fmt.Errorf("投递 %d 次仍未确认，超过上限 %d", deliveries, s.config.MaxDeliveries)
+			if err := s.DeadLetter(ctx, msg, cause); err != nil {
+				return nil, fmt.Errorf("写入死信失败: %w", err)
+			}
+			if err := s.Ack(ctx, msg); err != nil {
+				return nil, fmt.Errorf("确认死信消息失败: %w", err)
+			}
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+func toMessage(m redis.XMessage) Message {
+	msg := Message{ID: m.ID, Headers: map[string]string{}}
+	for k, v := range m.Values {
+		s := fmt.Sprint(v)
+		switch k {
+		case FieldKey:
+			msg.Key = s
+		case FieldBody:
+			msg.Body = []byte(s)
+		default:
+			msg.Headers[k] = s
+		}
+	}
+	return msg
+}
+
+// Ack 实现 Source 接口
+func (s *RedisStream) Ack(ctx context.Context, msg Message) error {
+	return s.client.XAck(ctx, s.config.Stream, s.config.Group, msg.ID).Err()
+}
+
+// Nack 实现 Source 接口：消息留在待确认列表中等待重新投递
+func (s *RedisStream) Nack(ctx context.Context, msg Message) error {
+	return nil
+}
+
+// DeadLetter 实现 DeadLetterer 接口：写入死信 stream（保留原消息 ID 和错误）
+func (s *RedisStream) DeadLetter(ctx context.Context, msg Message, cause error) error {
+	values := []any{FieldKey, msg.Key, FieldBody, msg.Body, "source_id", msg.ID, "error", cause.Error()}
+	for k, v := range msg.Headers {
+		values = append(values, k, v)
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{Stream: s.config.DeadLetter, Values: values}).Err()
+}
+
+// Lag 实现 LagReporter 接口（需要 Redis 7+，无法确定时返回错误）
+func (s *RedisStream) Lag(ctx context.Context) (int64, error) {
+	groups, err := s.client.XInfoGroups(ctx, s.config.Stream).Result()
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range groups {
+		if g.Name == s.config.Group {
+			if g.Lag < 0 {
+				return 0, errors.New("积压量无法确定")
+			}
+			return g.Lag, nil
+		}
+	}
+	return 0, fmt.Errorf("消费组 %s 不存在", s.config.Group)
+}
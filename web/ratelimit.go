@@ -6,7 +6,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/CenJIl/base/logger"
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"golang.org/x/time/rate"
@@ -24,6 +23,7 @@ type IPRateLimiter struct {
 	limiters map[string]*rate.Limiter
 	mu       sync.RWMutex
 	config   *RateLimiterConfig
+	stop     chan struct{} // 停止定期清理（Cleanup 启动后非空）
 }
 
 // NewIPRateLimiter creates a new IP-based rate limiter
@@ -52,48 +52,69 @@ func (rl *IPRateLimiter) Allow(ip string) bool {
 	return limiter.Allow()
 }
 
-// Cleanup removes stale limiters
+// Cleanup removes stale limiters（定期清理，Stop 停止）
 func (rl *IPRateLimiter) Cleanup() {
 	ticker := time.NewTicker(rl.config.CleanupInterval)
+	stop := make(chan struct{})
+	rl.mu.Lock()
+	rl.stop = stop
+	rl.mu.Unlock()
 	go func() {
-		for range ticker.C {
-			rl.mu.Lock()
-			rl.limiters = make(map[string]*rate.Limiter)
-			rl.mu.Unlock()
-			logger.Debugf("Rate limiter cleanup completed")
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rl.mu.Lock()
+				rl.limiters = make(map[string]*rate.Limiter)
+				rl.mu.Unlock()
+				log.Debugf("Rate limiter cleanup completed")
+			case <-stop:
+				return
+			}
 		}
 	}()
 }
 
-var (
-	globalIPRateLimiter *IPRateLimiter
-)
+// Stop 停止 Cleanup 启动的定期清理（可重复调用）
+func (rl *IPRateLimiter) Stop() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.stop != nil {
+		close(rl.stop)
+		rl.stop = nil
+	}
+}
 
-// InitRateLimiter initializes global rate limiter
+// InitRateLimiter initializes the default App's rate limiter（替换并停止之前的限流器）
 func InitRateLimiter(rps float64, burst int) {
-	globalIPRateLimiter = NewIPRateLimiter(rps, burst)
-	globalIPRateLimiter.Cleanup()
-	logger.Infof("Rate limiter initialized: %v req/s, burst %d", rps, burst)
+	if defaultApp.limiter != nil {
+		defaultApp.limiter.Stop()
+	}
+	defaultApp.limiter = NewIPRateLimiter(rps, burst)
+	defaultApp.limiter.Cleanup()
+	log.Infof("Rate limiter initialized: %v req/s, burst %d", rps, burst)
 }
 
-// RateLimitMiddleware creates rate limiting middleware
+// RateLimitMiddleware creates rate limiting middleware（使用 InitRateLimiter 初始化的默认 App 限流器）
 func RateLimitMiddleware() app.HandlerFunc {
+	return defaultApp.RateLimitMiddleware()
+}
+
+// RateLimitMiddleware App 的 IP 限流中间件（未配置限流时放行）
+func (a *App) RateLimitMiddleware() app.HandlerFunc {
 	return func(ctx context.Context, c *app.RequestContext) {
-		if globalIPRateLimiter == nil {
+		rl := a.limiter
+		if rl == nil {
 			c.Next(ctx)
 			return
 		}
 
 		clientIP := c.ClientIP()
-		if !globalIPRateLimiter.Allow(clientIP) {
-			logger.Warnf("Rate limit exceeded for IP: %s", clientIP)
-			c.JSON(consts.StatusTooManyRequests, map[string]any{
-				"code":    429,
-				"message": "Rate limit exceeded",
-				"data": map[string]any{
-					"limit": fmt.Sprintf("%.0f req/s", globalIPRateLimiter.config.RequestsPerSecond),
-				},
-			})
+		if !rl.Allow(clientIP) {
+			log.Warnf("Rate limit exceeded for IP: %s", clientIP)
+			c.JSON(consts.StatusTooManyRequests, FailWithData(429, MsgRateLimited, map[string]any{
+				"limit": fmt.Sprintf("%.0f req/s", rl.config.RequestsPerSecond),
+			}))
 			c.Abort()
 			return
 		}
@@ -0,0 +1,168 @@
+package web
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/google/uuid"
+)
+
+// RawAuditEvent 原始路由默认的审计事件类型
+const RawAuditEvent = "http.raw"
+
+// rawRouteKey 标记当前请求由 RawHandler 处理（EnvelopeMessageMiddleware 等不改写其响应）
+const rawRouteKey = "web_raw_route"
+
+// RawRoute 原始路由的豁免声明（记录在路由表中，启动报告和 /debug/routes 可见）
+type RawRoute struct {
+	Reason       string `json:"reason"`                 // 豁免原因（必填）
+	ExternalDocs string `json:"externalDocs,omitempty"` // 外部接口文档（生成 API 文档时标记为外部维护）
+}
+
+// RawOption 原始路由选项
+type RawOption func(*rawSpec)
+
+type rawSpec struct {
+	RawRoute
+	handler     string
+	auditEvent  string
+	captureBody int
+}
+
+// RawReason 声明绕过统一响应格式的原因（必填）
+func RawReason(reason string) RawOption {
+	return func(s *rawSpec) { s.Reason = reason }
+}
+
+// RawExternalDocs 声明接口文档的外部地址（如第三方规范）
+func RawExternalDocs(url string) RawOption {
+	return func(s *rawSpec) { s.ExternalDocs = url }
+}
+
+// RawAudit 设置审计事件类型（默认 http.raw，空字符串不写审计）
+func RawAudit(eventType string) RawOption {
+	return func(s *rawSpec) { s.auditEvent = eventType }
+}
+
+// RawCaptureBody 在审计事件中记录请求体（最多 limit 字节，默认不记录）
+func RawCaptureBody(limit int) RawOption {
+	return func(s *rawSpec) { s.captureBody = limit }
+}
+
+// RawHandler 原始处理函数：不套统一响应格式，契约测试也不校验响应格式
+//
+// 用于必须直接控制响应的接口（自定义 Content-Type、流式输出、第三方规范要求的响应体）。
+// 与 WrapHandler 相比只是不改写响应，其余保证不变：
+//   - panic 恢复为统一错误响应（响应已经开始流式写出时无法改写）
+//   - 保证有请求 ID（没有 RequestIDMiddleware 时自动生成并写入 X-Request-ID）
+//   - 按路由记录 web_raw_requests_total{route,status} / web_raw_panics_total{route}
+//   - 每个请求写一条审计事件（默认不记录请求体）
+//
+// 豁免原因必须通过 RawReason 声明，缺失时注册阶段 panic；原因记录在路由表中。
+// 分组上的中间件（如 jwt.Middleware）照常生效
+//
+// 使用方式：
+//
+//	api.POST("/scim/v2/Users", web.RawHandler(scimCreateUser,
+//	    web.RawReason("SCIM 2.0 规范要求 application/scim+json 响应体"),
+//	    web.RawExternalDocs("https://www.rfc-editor.org/rfc/rfc7644")))
+func RawHandler(h app.HandlerFunc, opts ...RawOption) app.HandlerFunc {
+	spec := &rawSpec{handler: handlerName(h), auditEvent: RawAuditEvent}
+	for _, opt := range opts {
+		opt(spec)
+	}
+	if spec.Reason == "" {
+		panic("web.RawHandler: 必须通过 web.RawReason 声明绕过统一响应格式的原因 (" + spec.handler + ")")
+	}
+	return func(ctx context.Context, c *app.RequestContext) {
+		if probe, ok := ctx.Value(rawProbeKey{}).(**rawSpec); ok {
+			*probe = spec
+			return
+		}
+		serveRaw(ctx, c, h, spec)
+	}
+}
+
+func serveRaw(ctx context.Context, c *app.RequestContext, h app.HandlerFunc, spec *rawSpec) {
+	requestID := ensureRequestID(c)
+	c.Set(rawRouteKey, true)
+	route := c.FullPath()
+	start := time.Now()
+	var body []byte
+	if spec.captureBody > 0 {
+		body = c.Request.Body()
+		if len(body) > spec.captureBody {
+			body = body[:spec.captureBody]
+		}
+		body = append([]byte(nil), body...)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.GetCounter("web_raw_panics_total", "route", route).Inc()
+			// 丢弃处理函数已写入的部分响应体，只返回统一错误响应
+			c.Response.ResetBody()
+			renderRecovered(ctx, c, r)
+		}
+		status := middleware.ResponseStatus(c)
+		metrics.GetCounter("web_raw_requests_total", "route", route, "status", strconv.Itoa(status)).Inc()
+		if spec.auditEvent == "" {
+			return
+		}
+		data := map[string]any{"handler": spec.handler, "durationMs": time.Since(start).Milliseconds()}
+		if body != nil {
+			data["requestBody"] = string(body)
+		}
+		event := audit.Event{
+			Type:         spec.auditEvent,
+			Actor:        jwt.GetActor(c),
+			Impersonated: jwt.IsImpersonating(c),
+			RequestID:    requestID,
+			Method:       string(c.Method()),
+			Path:         string(c.Path()),
+			Data:         data,
+		}
+		// 审计在响应写出之后写入，状态码以最终结果为准（客户端断开为 499）
+		OnRequestDone(c, func(_ context.Context, s RequestSummary) {
+			event.Status = s.Status
+			audit.Emit(context.WithoutCancel(ctx), event)
+		})
+	}()
+	h(ctx, c)
+}
+
+// ensureRequestID 返回请求 ID，没有时生成（与 RequestIDMiddleware 一致）
+func ensureRequestID(c *app.RequestContext) string {
+	if id, ok := c.Get("request_id"); ok {
+		if s, ok := id.(string); ok && s != "" {
+			return s
+		}
+	}
+	id := uuid.New().String()
+	c.Header("X-Request-ID", id)
+	c.Set("request_id", id)
+	return id
+}
+
+// rawProbeKey 注册阶段读取 RawHandler 声明时使用的 context 键
+type rawProbeKey struct{}
+
+// rawHandlerPtr RawHandler 返回的函数的代码指针（所有原始处理函数共用）
+var rawHandlerPtr = reflect.ValueOf(RawHandler(nil, RawReason("probe"))).Pointer()
+
+// rawSpecOf 处理函数是 RawHandler 时返回其声明
+func rawSpecOf(h app.HandlerFunc) *rawSpec {
+	if h == nil || reflect.ValueOf(h).Pointer() != rawHandlerPtr {
+		return nil
+	}
+	var spec *rawSpec
+	h(context.WithValue(context.Background(), rawProbeKey{}, &spec), nil)
+	return spec
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scimUser(ctx context.Context, c *app.RequestContext) {
+	c.Data(201, "application/scim+json", []byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"]}`))
+}
+
+func newRawEngine(t *testing.T) (*route.Engine, *ownershipAudit) {
+	conf := jwt.DefaultConfig()
+	conf.Secret = "raw-secret"
+	require.NoError(t, jwt.Init(conf))
+	rec := &ownershipAudit{}
+	audit.SetSink(rec)
+	t.Cleanup(func() { audit.SetSink(nil) })
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	r := NewRouter(engine)
+	scim := r.Group("/scim/v2", jwt.Middleware()).RequireAuth()
+	scim.POST("/Users", RawHandler(scimUser,
+		RawReason("SCIM 2.0 响应体"),
+		RawExternalDocs("https://www.rfc-editor.org/rfc/rfc7644"),
+		RawCaptureBody(8)))
+	r.Public().GET("/raw/panic", RawHandler(func(ctx context.Context, c *app.RequestContext) {
+		c.Data(200, "text/plain", []byte("partial"))
+		panic("boom")
+	}, RawReason("测试")))
+	engine.GET("/raw/direct", RawHandler(func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, "direct")
+	}, RawReason("直接注册"), RawAudit("")))
+	return engine, rec
+}
+
+func TestRawHandler_SuccessWritesExactBytes(t *testing.T) {
+	engine, rec := newRawEngine(t)
+	body := `{"userName":"alice"}`
+
+	w := ut.PerformRequest(engine, "POST", "/scim/v2/Users", &ut.Body{Body: strings.NewReader(body), Len: len(body)})
+	assert.Equal(t, 401, w.Code, "分组上的认证中间件照常生效")
+
+	w = ut.PerformRequest(engine, "POST", "/scim/v2/Users", &ut.Body{Body: strings.NewReader(body), Len: len(body)},
+		ownershipToken(t, "alice"))
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"]}`, w.Body.String(), "不套统一响应格式")
+	assert.Equal(t, "application/scim+json", string(w.Header().ContentType()))
+	requestID := string(w.Header().Peek("X-Request-ID"))
+	assert.NotEmpty(t, requestID)
+
+	require.Equal(t, []string{RawAuditEvent}, rec.types())
+	event := rec.events[0]
+	assert.Equal(t, "alice", event.Actor)
+	assert.Equal(t, requestID, event.RequestID)
+	assert.Equal(t, 201, event.Status)
+	assert.Equal(t, `{"userNa`, event.Data["requestBody"])
+	assert.Equal(t, int64(1), metrics.GetCounter("web_raw_requests_total", "route", "/scim/v2/Users", "status", "201").Value())
+
+	w = ut.PerformRequest(engine, "GET", "/raw/direct", nil)
+	assert.Equal(t, "direct", w.Body.String())
+	assert.Len(t, rec.events, 1, "RawAudit(\"\") 不写审计")
+}
+
+func TestRawHandler_PanicRecovered(t *testing.T) {
+	engine, rec := newRawEngine(t)
+
+	w := ut.PerformRequest(engine, "GET", "/raw/panic", nil)
+	assert.Equal(t, 500, w.Code)
+	var result Result
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), w.Body.String())
+	assert.Equal(t, 500, result.Code)
+	assert.NotEmpty(t, result.TraceID)
+	assert.Equal(t, result.TraceID, string(w.Header().Peek("X-Request-ID")))
+
+	require.Len(t, rec.events, 1)
+	assert.Equal(t, 500, rec.events[0].Status)
+	assert.Nil(t, rec.events[0].Data["requestBody"], "默认不记录请求体")
+	assert.Equal(t, int64(1), metrics.GetCounter("web_raw_panics_total", "route", "/raw/panic").Value())
+}
+
+func TestRawHandler_RouteMetadata(t *testing.T) {
+	engine, _ := newRawEngine(t)
+	routes, conflicts := ValidateRoutes(engine)
+	assert.Empty(t, conflicts)
+
+	raw := map[string]RouteInfo{}
+	for _, r := range routes {
+		if r.Raw != nil {
+			raw[r.Method+" "+r.Path] = r
+		}
+	}
+	require.Len(t, raw, 3)
+	scim := raw["POST /scim/v2/Users"]
+	assert.Equal(t, RawRoute{Reason: "SCIM 2.0 响应体", ExternalDocs: "https://www.rfc-editor.org/rfc/rfc7644"}, *scim.Raw)
+	assert.True(t, strings.HasSuffix(scim.Handler, ".scimUser"), scim.Handler)
+	assert.Equal(t, "直接注册", raw["GET /raw/direct"].Raw.Reason, "直接通过 Hertz 注册的原始路由同样标记")
+
+	assert.Panics(t, func() { RawHandler(scimUser) }, "必须声明原因")
+}
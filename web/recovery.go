@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+)
+
+// PanicReport 一次真正的 panic（bug）的上报信息
+type PanicReport struct {
+	Value     any    // recover 到的值
+	Stack     []byte // 触发 panic 的 goroutine 堆栈
+	RequestID string // 请求 ID（web.Go 中为空）
+	Method    string // HTTP 方法（web.Go 中为空）
+	Path      string // 请求路径（web.Go 中为空）
+}
+
+// PanicHook panic 上报回调（接入 Sentry 等崩溃上报）
+type PanicHook func(ctx context.Context, report PanicReport)
+
+var panicHook atomic.Pointer[PanicHook]
+
+// OnPanic 设置 panic 上报回调（nil 取消）
+//
+// 只有真正的 bug 会触发；panic(web.BadRequestHTTP(...)) 等控制流程 panic 不会触发
+//
+// 使用方式：
+//
+//	web.OnPanic(func(ctx context.Context, r web.PanicReport) {
+//	    sentry.CaptureException(fmt.Errorf("%v\n%s", r.Value, r.Stack))
+//	})
+func OnPanic(hook PanicHook) {
+	if hook == nil {
+		panicHook.Store(nil)
+		return
+	}
+	panicHook.Store(&hook)
+}
+
+// controlFlowResult 控制流程 panic 对应的响应状态码与响应体（不是控制流程 panic 时 ok 为 false）
+func controlFlowResult(recovered any) (status int, result Result, ok bool) {
+	if !common.IsControlFlow(recovered) {
+		return 0, Result{}, false
+	}
+	var httpErr *HTTPException
+	var bizErr *Exception
+	switch {
+	case asPanic(recovered, &httpErr):
+		return httpErr.HTTPStatus, Fail(httpErr.Code, httpErr.Message), true
+	case asPanic(recovered, &bizErr):
+		return getHTTPStatus(bizErr.Code), Fail(bizErr.Code, bizErr.Message), true
+	}
+	// 其他实现 common.ControlFlowPanic 的类型：不上报，按内部错误响应
+	return http.StatusInternalServerError, Fail(500, MsgInternalError), true
+}
+
+// knownErrorResult 可直接映射为客户端错误的 error（panic(err) 或 WrapHandler 返回的 err），不视为 bug
+func knownErrorResult(err error) (status int, result Result, ok bool) {
+	if errors.Is(err, database.ErrVersionConflict) {
+		return http.StatusConflict, Fail(int(VersionConflict), MsgVersionConflict), true
+	}
+	var mismatch *ErrChecksumMismatch
+	if errors.As(err, &mismatch) {
+		return http.StatusUnprocessableEntity, Fail(int(ChecksumMismatch), mismatch.Error()), true
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest, FailWithData(int(ValidationFailed), MsgValidationFailed, utils.H{"fields": invalid.Fields}), true
+	}
+	var infected *ErrFileInfected
+	if errors.As(err, &infected) {
+		return http.StatusUnprocessableEntity, Fail(int(FileInfected), infected.Error()), true
+	}
+	var unavailable *ErrScanUnavailable
+	if errors.As(err, &unavailable) {
+		// 原始错误含扫描服务的内部地址，只记录日志，不返回给客户端
+		log.Warnf("[Upload] 扫描服务不可用，拒绝上传: %v", unavailable.Err)
+		return http.StatusServiceUnavailable, Fail(int(ScanUnavailable), MsgScanUnavailable), true
+	}
+	return 0, Result{}, false
+}
+
+// asPanic 对 recover 到的值执行 errors.As
+func asPanic[T error](recovered any, target *T) bool {
+	err, ok := recovered.(error)
+	return ok && errors.As(err, target)
+}
+
+// reportPanic 采集堆栈、记录错误日志（计入错误指纹）并触发 OnPanic（在 recover 所在的 defer 中调用）
+func reportPanic(ctx context.Context, c *app.RequestContext, recovered any) {
+	stack := debug.Stack()
+	logger.ErrorPanic(recovered, "[PANIC] %v\n%s", recovered, stack)
+	p := panicHook.Load()
+	if p == nil {
+		return
+	}
+	report := PanicReport{Value: recovered, Stack: stack}
+	if c != nil {
+		report.RequestID = middleware.GetRequestID(c)
+		report.Method = string(c.Method())
+		report.Path = string(c.Path())
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("[PANIC] OnPanic 回调 panic: %v", r)
+			}
+		}()
+		(*p)(ctx, report)
+	}()
+}
+
+// renderClientAbort 客户端中途断开导致的 context.Canceled 不视为错误（不上报、不计入错误指纹），
+// 只把状态记为 499；连接已断开，不再写响应体。不是这种情况时返回 false
+func renderClientAbort(c *app.RequestContext, err error) bool {
+	if !middleware.IsClientAbortError(c, err) {
+		return false
+	}
+	log.Debugf("[Abort] %s %s: 客户端已断开 (%v)", c.Method(), c.Path(), err)
+	c.Response.ResetBody()
+	c.SetStatusCode(middleware.StatusClientClosedRequest)
+	c.Abort()
+	return true
+}
+
+// renderRecovered 把 recover 到的值渲染为统一错误响应（所有恢复层共用，保证响应一致）
+//
+// 控制流程 panic（HTTPException / Exception）按其状态码和业务码响应，
+// database.ErrVersionConflict 响应 409 + VersionConflict，上传扫描的拒绝见 knownErrorResult；客户端中途断开导致的 context.Canceled 记为 499；
+// 其他值视为 bug：上报后响应 500，不向客户端暴露内部错误信息
+func renderRecovered(ctx context.Context, c *app.RequestContext, recovered any) {
+	if err, isErr := recovered.(error); isErr && renderClientAbort(c, err) {
+		return
+	}
+	status, result, ok := controlFlowResult(recovered)
+	if err, isErr := recovered.(error); !ok && isErr {
+		status, result, ok = knownErrorResult(err)
+	}
+	if ok {
+		recordRequestError(c, panicError(recovered))
+	} else {
+		recordRequestPanic(c, recovered)
+		reportPanic(ctx, c, recovered)
+		status, result = http.StatusInternalServerError, Fail(500, MsgInternalError)
+	}
+	result.TraceID = middleware.GetRequestID(c)
+	result.Impersonating = jwt.IsImpersonating(c)
+	respondError(c, status, result)
+	c.Abort()
+}
+
+// Go 在新的 goroutine 中执行 fn，panic 时按恢复层的规则处理而不是让进程崩溃
+//
+// 真正的 bug 采集堆栈并触发 OnPanic；控制流程 panic 只记录警告（没有响应可写）
+//
+// 使用方式：
+//
+//	web.Go(ctx, func(ctx context.Context) {
+//	    sendWelcomeEmail(ctx, user)
+//	})
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if common.IsControlFlow(r) {
+					log.Warnf("[Go] 后台任务以控制流程 panic 结束: %v", r)
+					return
+				}
+				reportPanic(ctx, nil, r)
+			}
+		}()
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panicRecorder struct {
+	mu      sync.Mutex
+	reports []PanicReport
+}
+
+func (r *panicRecorder) hook(ctx context.Context, report PanicReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+func (r *panicRecorder) take() []PanicReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.reports
+	r.reports = nil
+	return out
+}
+
+type customControlFlow struct{}
+
+func (customControlFlow) ControlFlowPanic() {}
+
+var panicCategories = []struct {
+	name       string
+	value      func() any
+	status     int
+	body       string
+	controlled bool
+}{
+	{"HTTPException", func() any { return NotFoundHTTP("订单不存在") }, 404, `{"code":404,"message":"订单不存在","data":null}`, true},
+	{"Exception", func() any { return NewException(409, "余额不足") }, 409, `{"code":409,"message":"余额不足","data":null}`, true},
+	{"5xx HTTPException", func() any { return InternalHTTP("上游不可用") }, 500, `{"code":500,"message":"上游不可用","data":null}`, true},
+	{"wrapped HTTPException", func() any { return fmt.Errorf("load: %w", ConflictHTTP("版本冲突")) }, 409, `{"code":409,"message":"版本冲突","data":null}`, true},
+	{"custom control flow", func() any { return customControlFlow{} }, 500, `{"code":500,"message":"Internal server error","data":null}`, true},
+	{"plain error", func() any { return errors.New("dial tcp 10.0.0.1:5432: refused") }, 500, `{"code":500,"message":"Internal server error","data":null}`, false},
+	{"string", func() any { return "boom" }, 500, `{"code":500,"message":"Internal server error","data":null}`, false},
+	{"nil pointer", func() any {
+		var m map[string]*Result
+		return func() (r any) {
+			defer func() { r = recover() }()
+			_ = m["x"].Code
+			return nil
+		}()
+	}, 500, `{"code":500,"message":"Internal server error","data":null}`, false},
+}
+
+func TestIsControlFlow(t *testing.T) {
+	for _, tt := range panicCategories {
+		assert.Equal(t, tt.controlled, common.IsControlFlow(tt.value()), tt.name)
+	}
+	assert.False(t, common.IsControlFlow(nil))
+}
+
+func TestRecovery_SitesRenderIdentically(t *testing.T) {
+	rec := &panicRecorder{}
+	OnPanic(rec.hook)
+	t.Cleanup(func() { OnPanic(nil) })
+
+	sites := map[string]func(v any) *route.Engine{
+		"ExceptionHandler": func(v any) *route.Engine {
+			engine := route.NewEngine(config.NewOptions(nil))
+			engine.Use(ExceptionHandler())
+			engine.GET("/p", func(ctx context.Context, c *app.RequestContext) { panic(v) })
+			return engine
+		},
+		"RecoveryMiddleware": func(v any) *route.Engine {
+			engine := route.NewEngine(config.NewOptions(nil))
+			engine.Use(RecoveryMiddleware())
+			engine.GET("/p", func(ctx context.Context, c *app.RequestContext) { panic(v) })
+			return engine
+		},
+		"WrapHandler": func(v any) *route.Engine {
+			engine := route.NewEngine(config.NewOptions(nil))
+			engine.GET("/p", WrapHandler(func(ctx context.Context, c *app.RequestContext) error { panic(v) }))
+			return engine
+		},
+	}
+
+	for _, tt := range panicCategories {
+		for site, build := range sites {
+			w := ut.PerformRequest(build(tt.value()), "GET", "/p", nil)
+			assert.Equal(t, tt.status, w.Code, "%s / %s", tt.name, site)
+			assert.JSONEq(t, tt.body, w.Body.String(), "%s / %s", tt.name, site)
+
+			reports := rec.take()
+			if tt.controlled {
+				assert.Empty(t, reports, "%s / %s: 控制流程 panic 不触发 OnPanic", tt.name, site)
+				continue
+			}
+			require.Len(t, reports, 1, "%s / %s", tt.name, site)
+			assert.Equal(t, "/p", reports[0].Path)
+			assert.Contains(t, string(reports[0].Stack), "recovery_test.go", "%s / %s: 堆栈包含触发位置", tt.name, site)
+		}
+	}
+}
+
+func TestGo_Recovery(t *testing.T) {
+	rec := &panicRecorder{}
+	OnPanic(rec.hook)
+	t.Cleanup(func() { OnPanic(nil) })
+
+	for _, tt := range panicCategories {
+		done := make(chan struct{})
+		Go(context.Background(), func(ctx context.Context) {
+			defer close(done)
+			panic(tt.value())
+		})
+		<-done
+		// 上报在 fn 的 defer 之后执行，等待上报完成
+		time.Sleep(20 * time.Millisecond)
+		reports := rec.take()
+		if tt.controlled {
+			assert.Empty(t, reports, tt.name)
+			continue
+		}
+		require.Len(t, reports, 1, tt.name)
+		assert.Empty(t, reports[0].Path)
+	}
+
+	// 回调自身 panic 不影响恢复
+	OnPanic(func(ctx context.Context, report PanicReport) { panic("hook bug") })
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.GET("/p", func(ctx context.Context, c *app.RequestContext) { panic("boom") })
+	assert.Equal(t, 500, ut.PerformRequest(engine, "GET", "/p", nil).Code)
+}
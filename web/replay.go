@@ -0,0 +1,81 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/cache"
+)
+
+// ReplayCache 防重放缓存：记录一次性标识（nonce、事件 ID 等）在 ttl 内是否出现过
+type ReplayCache interface {
+	// Seen 首次出现返回 false 并记录；ttl 内再次出现返回 true
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryReplayCache 进程内防重放缓存（单实例部署或测试使用）
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	clock     common.Clock
+	entries   map[string]time.Time // key -> 过期时间
+	lastSweep time.Time
+}
+
+// NewMemoryReplayCache 创建进程内防重放缓存（clock 为 nil 时使用系统时钟）
+func NewMemoryReplayCache(clock common.Clock) *MemoryReplayCache {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &MemoryReplayCache{clock: clock, entries: make(map[string]time.Time)}
+}
+
+// Seen 实现 ReplayCache 接口
+func (r *MemoryReplayCache) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 每分钟顺带清理一次过期记录
+	if now.Sub(r.lastSweep) > time.Minute {
+		for k, expire := range r.entries {
+			if !expire.After(now) {
+				delete(r.entries, k)
+			}
+		}
+		r.lastSweep = now
+	}
+
+	if expire, ok := r.entries[key]; ok && expire.After(now) {
+		return true, nil
+	}
+	r.entries[key] = now.Add(ttl)
+	return false, nil
+}
+
+// RedisReplayCache 基于 Redis SETNX 的防重放缓存（多实例共享）
+type RedisReplayCache struct {
+	Prefix string // 键前缀，默认 "replay:"
+}
+
+// Seen 实现 ReplayCache 接口
+func (r RedisReplayCache) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	prefix := r.Prefix
+	if prefix == "" {
+		prefix = "replay:"
+	}
+	ok, err := cache.Client.SetNX(ctx, prefix+key, 1, ttl).Result()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// DefaultReplayCache 配置了 Redis 时使用 Redis，否则使用进程内缓存
+func DefaultReplayCache() ReplayCache {
+	if cache.Client != nil {
+		return RedisReplayCache{}
+	}
+	return NewMemoryReplayCache(nil)
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/tracer"
+)
+
+// RequestOutcome 请求的结束方式
+type RequestOutcome string
+
+const (
+	OutcomeOK      RequestOutcome = "ok"      // 正常完成（包括处理器主动写出的 4xx 响应）
+	OutcomeError   RequestOutcome = "error"   // 处理器返回 error、控制流程 panic（HTTPException 等）或 5xx 响应
+	OutcomePanic   RequestOutcome = "panic"   // 未预期的 panic（已通过 OnPanic 上报）
+	OutcomeAborted RequestOutcome = "aborted" // 客户端在响应写完之前断开
+	OutcomeTimeout RequestOutcome = "timeout" // 处理器的错误是 context.DeadlineExceeded
+)
+
+// RequestSummary 请求结束时的汇总（OnRequestDone 回调的参数）
+type RequestSummary struct {
+	RequestID    string
+	Method       string
+	Path         string
+	Route        string         // 路由模板（/users/:id），未匹配路由时为空
+	Status       int            // 响应状态码，客户端断开时为 499
+	BytesWritten int64          // 响应体字节数（流式响应未知长度时为 0）
+	Latency      time.Duration  // 从开始读取请求到响应写出（未启用 RequestDoneTracer 时为处理器链耗时）
+	Aborted      bool           // 客户端在响应写完之前断开
+	Outcome      RequestOutcome // 结束方式
+	Err          error          // 处理器返回的错误或 panic 的值（正常完成时为 nil）
+}
+
+const requestDoneKey = "web_request_done"
+
+// requestDone 单个请求的结束回调
+type requestDone struct {
+	start time.Time
+
+	mu        sync.Mutex
+	callbacks []func(ctx context.Context, s RequestSummary)
+	done      bool
+	outcome   RequestOutcome
+	err       error
+}
+
+// OnRequestDone 注册请求结束后执行的回调（类似 defer）
+//
+// 回调在响应写出（或客户端断开）之后执行，每个请求恰好执行一次，不增加客户端看到的延迟；
+// 多个回调按注册的逆序执行，单个回调 panic 只记录日志，不影响其他回调。
+// 回调执行时请求上下文已不可用，需要的数据从 summary 取或在注册时捕获；
+// 耗时的工作（写远程审计、上报）应自行异步，避免占用连接
+//
+// 需要 NewServer 注册的 RequestDoneTracer（或 RequestDoneMiddleware）；都没有启用，
+// 或请求已经结束时，回调立即执行
+//
+// 使用方式：
+//
+//	web.OnRequestDone(c, func(ctx context.Context, s web.RequestSummary) {
+//	    log.Infof("%s %s -> %d (%v)", s.Method, s.Path, s.Status, s.Latency)
+//	})
+func OnRequestDone(c *app.RequestContext, fn func(ctx context.Context, s RequestSummary)) {
+	d := requestDoneOf(c)
+	if d != nil {
+		d.mu.Lock()
+		if !d.done {
+			d.callbacks = append(d.callbacks, fn)
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+	}
+	runRequestDone(context.Background(), []func(context.Context, RequestSummary){fn}, summarize(c, d, false))
+}
+
+// RequestDoneTracer 在响应写出之后执行 OnRequestDone 回调的 tracer（NewServer 已注册）
+//
+// 自行创建 server.Hertz 时需要注册：
+//
+//	h := server.Default(server.WithTracer(web.RequestDoneTracer()))
+func RequestDoneTracer() tracer.Tracer {
+	return requestDoneTracer{}
+}
+
+type requestDoneTracer struct{}
+
+func (requestDoneTracer) Start(ctx context.Context, c *app.RequestContext) context.Context {
+	c.Set(requestDoneKey, &requestDone{start: time.Now()})
+	return ctx
+}
+
+// Finish 响应已经写出（写失败时为连接关闭前），RequestContext 在返回后才会重置
+func (requestDoneTracer) Finish(ctx context.Context, c *app.RequestContext) {
+	if d := requestDoneOf(c); d != nil {
+		d.finish(ctx, c, c.GetTraceInfo().Stats().Error() != nil)
+	}
+}
+
+// RequestDoneMiddleware 没有 RequestDoneTracer 时在处理器链结束后执行 OnRequestDone 回调
+//
+// 用于测试引擎（ut.PerformRequest 不经过 tracer）等无法注册 tracer 的场景；
+// 回调在响应写出之前执行。已启用 RequestDoneTracer 时不做任何事
+//
+// 使用方式：
+//
+//	engine := route.NewEngine(config.NewOptions(nil))
+//	engine.Use(web.RequestDoneMiddleware())
+func RequestDoneMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if requestDoneOf(c) != nil {
+			c.Next(ctx)
+			return
+		}
+		d := &requestDone{start: time.Now()}
+		c.Set(requestDoneKey, d)
+		defer func() {
+			if r := recover(); r != nil {
+				d.record(OutcomePanic, panicError(r))
+				d.finish(ctx, c, false)
+				panic(r)
+			}
+		}()
+		c.Next(ctx)
+		d.finish(ctx, c, false)
+	}
+}
+
+func requestDoneOf(c *app.RequestContext) *requestDone {
+	d, _ := c.Value(requestDoneKey).(*requestDone)
+	return d
+}
+
+// recordRequestError 记录处理器返回的错误（WrapHandler / 恢复层调用，先记录的为准）
+func recordRequestError(c *app.RequestContext, err error) {
+	if d := requestDoneOf(c); d != nil {
+		outcome := OutcomeError
+		if errors.Is(err, context.DeadlineExceeded) {
+			outcome = OutcomeTimeout
+		}
+		d.record(outcome, err)
+	}
+}
+
+// recordRequestPanic 记录未预期的 panic
+func recordRequestPanic(c *app.RequestContext, recovered any) {
+	if d := requestDoneOf(c); d != nil {
+		d.record(OutcomePanic, panicError(recovered))
+	}
+}
+
+func (d *requestDone) record(outcome RequestOutcome, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.outcome == "" {
+		d.outcome, d.err = outcome, err
+	}
+}
+
+// finish 取出回调并执行（只有第一次调用生效）
+func (d *requestDone) finish(ctx context.Context, c *app.RequestContext, writeFailed bool) {
+	d.mu.Lock()
+	if d.done {
+		d.mu.Unlock()
+		return
+	}
+	d.done = true
+	callbacks := d.callbacks
+	d.callbacks = nil
+	d.mu.Unlock()
+	if len(callbacks) == 0 {
+		return
+	}
+	runRequestDone(context.WithoutCancel(ctx), callbacks, summarize(c, d, writeFailed))
+}
+
+// summarize 汇总请求（d 为 nil 时没有耗时与错误分类）
+func summarize(c *app.RequestContext, d *requestDone, writeFailed bool) RequestSummary {
+	s := RequestSummary{
+		RequestID:    middleware.GetRequestID(c),
+		Method:       string(c.Method()),
+		Path:         string(c.Path()),
+		Route:        c.FullPath(),
+		Status:       middleware.ResponseStatus(c),
+		BytesWritten: responseBytes(c),
+		Outcome:      OutcomeOK,
+	}
+	if d != nil {
+		s.Latency = time.Since(d.start)
+		d.mu.Lock()
+		if d.outcome != "" {
+			s.Outcome, s.Err = d.outcome, d.err
+		}
+		d.mu.Unlock()
+	}
+	switch {
+	case writeFailed || s.Status == middleware.StatusClientClosedRequest:
+		s.Status, s.Aborted, s.Outcome = middleware.StatusClientClosedRequest, true, OutcomeAborted
+	case s.Outcome == OutcomeOK && s.Status >= 500:
+		s.Outcome = OutcomeError
+	}
+	return s
+}
+
+// responseBytes 响应体字节数（流式响应取 Content-Length，未知时为 0）
+func responseBytes(c *app.RequestContext) int64 {
+	if c.Response.IsBodyStream() {
+		return int64(max(c.Response.Header.ContentLength(), 0))
+	}
+	return int64(len(c.Response.BodyBytes()))
+}
+
+// runRequestDone 按注册的逆序执行回调，每个回调单独恢复 panic
+func runRequestDone(ctx context.Context, callbacks []func(context.Context, RequestSummary), s RequestSummary) {
+	for i := len(callbacks) - 1; i >= 0; i-- {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					metrics.GetCounter("web_request_done_panics_total", "route", s.Route).Inc()
+					log.Errorf("[RequestDone] %s %s 的结束回调 panic: %v\n%s", s.Method, s.Path, r, debug.Stack())
+				}
+			}()
+			callbacks[i](ctx, s)
+		}()
+	}
+}
+
+func panicError(recovered any) error {
+	if err, ok := recovered.(error); ok {
+		return err
+	}
+	return fmt.Errorf("panic: %v", recovered)
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doneRecorder 记录结束回调的执行顺序与汇总
+type doneRecorder struct {
+	mu        sync.Mutex
+	calls     []string
+	summaries []RequestSummary
+}
+
+func (r *doneRecorder) callback(name string) func(ctx context.Context, s RequestSummary) {
+	return func(ctx context.Context, s RequestSummary) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.calls = append(r.calls, name)
+		r.summaries = append(r.summaries, s)
+	}
+}
+
+func (r *doneRecorder) reset() ([]string, []RequestSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls, summaries := r.calls, r.summaries
+	r.calls, r.summaries = nil, nil
+	return calls, summaries
+}
+
+func TestOnRequestDone_ReverseOrderAndOutcomes(t *testing.T) {
+	OnPanic(func(ctx context.Context, r PanicReport) {})
+	t.Cleanup(func() { OnPanic(nil) })
+
+	rec := &doneRecorder{}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(RequestDoneMiddleware(), middleware.RequestIDMiddleware(), ExceptionHandler())
+	engine.Use(func(ctx context.Context, c *app.RequestContext) {
+		OnRequestDone(c, rec.callback("first"))
+		OnRequestDone(c, func(ctx context.Context, s RequestSummary) { panic("broken callback") })
+		OnRequestDone(c, rec.callback("last"))
+		c.Next(ctx)
+	})
+	engine.GET("/ok/:id", func(ctx context.Context, c *app.RequestContext) {
+		c.String(200, "hello")
+	})
+	engine.GET("/error", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return BadRequestHTTP("invalid report range")
+	}))
+	engine.GET("/panic", func(ctx context.Context, c *app.RequestContext) {
+		panic("nil map write")
+	})
+	engine.GET("/timeout", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		return fmt.Errorf("query report: %w", ctx.Err())
+	}))
+
+	cases := []struct {
+		path    string
+		status  int
+		outcome RequestOutcome
+	}{
+		{"/ok/1", 200, OutcomeOK},
+		{"/error", 400, OutcomeError},
+		{"/panic", 500, OutcomePanic},
+		{"/timeout", 500, OutcomeTimeout},
+	}
+	for _, tc := range cases {
+		w := ut.PerformRequest(engine, "GET", tc.path, nil)
+		require.Equal(t, tc.status, w.Code, tc.path)
+
+		calls, summaries := rec.reset()
+		assert.Equal(t, []string{"last", "first"}, calls, "逆序执行，每个回调恰好一次，panic 的回调不影响其他回调: %s", tc.path)
+		s := summaries[0]
+		assert.Equal(t, tc.status, s.Status, tc.path)
+		assert.Equal(t, tc.outcome, s.Outcome, tc.path)
+		assert.Equal(t, tc.outcome != OutcomeOK, s.Err != nil, tc.path)
+		assert.Equal(t, string(w.Header().Peek("X-Request-ID")), s.RequestID, tc.path)
+		assert.Equal(t, int64(w.Body.Len()), s.BytesWritten, tc.path)
+		assert.Positive(t, s.Latency, tc.path)
+		assert.False(t, s.Aborted, tc.path)
+	}
+	ut.PerformRequest(engine, "GET", "/ok/7", nil)
+	_, summaries := rec.reset()
+	assert.Equal(t, "/ok/:id", summaries[0].Route)
+	assert.Equal(t, "/ok/7", summaries[0].Path)
+}
+
+func TestOnRequestDone_WithoutTrackingRunsImmediately(t *testing.T) {
+	rec := &doneRecorder{}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/plain", func(ctx context.Context, c *app.RequestContext) {
+		c.String(201, "created")
+		OnRequestDone(c, rec.callback("plain"))
+		calls, summaries := rec.reset()
+		assert.Equal(t, []string{"plain"}, calls, "没有 tracer 与中间件时立即执行")
+		assert.Equal(t, 201, summaries[0].Status)
+	})
+	ut.PerformRequest(engine, "GET", "/plain", nil)
+	calls, _ := rec.reset()
+	assert.Empty(t, calls)
+}
+
+func TestRequestDoneTracer_RunsAfterResponseWritten(t *testing.T) {
+	rec := &doneRecorder{}
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	addr := startAbortServer(t, func(h *server.Hertz) {
+		h.Use(middleware.ClientAbortMiddleware(), ExceptionHandler())
+		h.GET("/slow-callback", func(ctx context.Context, c *app.RequestContext) {
+			OnRequestDone(c, func(ctx context.Context, s RequestSummary) {
+				<-release // 回调阻塞不影响客户端收到响应
+			})
+			OnRequestDone(c, rec.callback("slow"))
+			c.String(200, "done")
+		})
+		h.GET("/fast", func(ctx context.Context, c *app.RequestContext) {
+			OnRequestDone(c, rec.callback("fast"))
+			c.String(200, "ok")
+		})
+		h.GET("/abort", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+			OnRequestDone(c, rec.callback("abort"))
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+	}, server.WithTracer(RequestDoneTracer()))
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get("http://" + addr + "/slow-callback")
+	require.NoError(t, err, "回调在响应写出之后执行")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "done", string(body))
+	close(release)
+
+	// 同一个长连接上的后续请求各自执行一次
+	for range 2 {
+		resp, err := client.Get("http://" + addr + "/fast")
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.calls) == 3
+	}, time.Second, 10*time.Millisecond)
+	calls, summaries := rec.reset()
+	assert.Equal(t, []string{"slow", "fast", "fast"}, calls)
+	assert.Equal(t, int64(len("done")), summaries[0].BytesWritten)
+
+	dropMidRequest(t, addr, "/abort", started)
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.calls) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	calls, summaries = rec.reset()
+	require.Equal(t, []string{"abort"}, calls, "客户端断开时恰好执行一次")
+	assert.True(t, summaries[0].Aborted)
+	assert.Equal(t, middleware.StatusClientClosedRequest, summaries[0].Status)
+	assert.Equal(t, OutcomeAborted, summaries[0].Outcome)
+}
@@ -6,6 +6,8 @@ type Result struct {
 	Message string `json:"message"`           // 响应消息
 	Data    any    `json:"data"`              // 响应数据
 	TraceID string `json:"traceId,omitempty"` // 链路追踪 ID（由 WrapHandler 或用户设置）
+
+	Impersonating bool `json:"impersonating,omitempty"` // 是否处于代理登录状态（由 WrapHandler 设置，前端据此显示提示横幅）
 }
 
 // PagedData 分页数据
@@ -21,7 +23,7 @@ type PagedData struct {
 func Success(data any) Result {
 	return Result{
 		Code:    0,
-		Message: "success",
+		Message: MsgSuccess,
 		Data:    data,
 	}
 }
@@ -53,7 +55,7 @@ func PagedSuccess(items any, page, pageSize int, total int64) Result {
 
 	return Result{
 		Code:    0,
-		Message: "success",
+		Message: MsgSuccess,
 		Data: PagedData{
 			Items:     items,
 			Page:      page,
@@ -0,0 +1,494 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// 路由认证声明
+const (
+	AuthUnspecified = ""         // 未声明
+	AuthRequired    = "required" // 需要认证
+	AuthPublic      = "public"   // 公开访问
+)
+
+// 路由冲突类型
+const (
+	ConflictDuplicate      = "duplicate"       // 同一方法 + 路径重复注册
+	ConflictParamMismatch  = "param_mismatch"  // 同一路径形状上参数名不一致（/users/:id 与 /users/:userId）
+	ConflictWildcardShadow = "wildcard_shadow" // 通配路由与同前缀的其他路由重叠
+	ConflictAuthMismatch   = "auth_mismatch"   // 路由的认证声明与所在分组不一致
+)
+
+// RoutesConfig 路由表校验配置
+//
+// Example:
+//
+//	[web.routes]
+//	permissive = false   # true 时发现冲突只记录日志，不阻止启动
+//	debug = true         # 暴露 GET /debug/routes
+type RoutesConfig struct {
+	Permissive bool `toml:"permissive"` // 宽松模式
+	Debug      bool `toml:"debug"`      // 是否暴露 /debug/routes
+}
+
+// RouteInfo 路由注册信息
+type RouteInfo struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Handler    string    `json:"handler"`
+	File       string    `json:"file,omitempty"` // 注册位置（通过 Router 注册时记录）
+	Line       int       `json:"line,omitempty"`
+	Auth       string    `json:"auth,omitempty"` // 路由自身的认证声明
+	GroupAuth  string    `json:"-"`              // 所在分组的认证声明
+	Direct     bool      `json:"direct,omitempty"`
+	Ownership  bool      `json:"ownership,omitempty"`  // 带资源归属校验（RequireOwnership）
+	Raw        *RawRoute `json:"raw,omitempty"`        // 原始路由（RawHandler）的豁免声明
+	Deprecated string    `json:"deprecated,omitempty"` // 废弃说明（通过 Router.Deprecated 声明）
+	Binding    string    `json:"binding,omitempty"`    // 请求绑定模式（通过 Router.Strict / Router.Lenient 声明）
+
+	Examples []Example `json:"-"` // 请求示例（契约测试用）
+}
+
+// Example 路由的请求示例，契约测试（web/contract）据此生成用例
+//
+// Request 同时声明请求类型，其中的 vd 标签用于生成边界用例；Response 声明 data 的类型（零值即可）
+//
+// 使用方式：
+//
+//	api.WithExamples(web.Example{
+//	    Name:     "create order",
+//	    Request:  CreateOrderReq{ProductID: 1, Quantity: 2},
+//	    Response: OrderResp{},
+//	}).POST("/orders", web.WrapHandler(createOrder))
+type Example struct {
+	Name     string
+	Path     string // 实际请求路径（路径参数已替换），为空时把参数替换为 1
+	Query    string // 查询字符串（不含 ?）
+	Request  any    // 请求体，nil 表示无请求体
+	Response any    // 期望的 data 类型，nil 表示不校验 data
+	Status   int    // 期望的状态码，默认 200
+}
+
+// markRaw 处理函数是 RawHandler 时记录豁免声明（Handler 改为被包装的处理函数名）
+func (r *RouteInfo) markRaw(h app.HandlerFunc) {
+	if spec := rawSpecOf(h); spec != nil {
+		raw := spec.RawRoute
+		r.Raw = &raw
+		r.Handler = spec.handler
+	}
+}
+
+// location 注册位置描述
+func (r RouteInfo) location() string {
+	if r.File == "" {
+		return "未知位置（直接通过 Hertz 注册）"
+	}
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// RouteConflict 路由冲突
+type RouteConflict struct {
+	Kind    string      `json:"kind"`
+	Message string      `json:"message"`
+	Routes  []RouteInfo `json:"routes"`
+}
+
+// String 冲突描述（包含每条路由的注册位置）
+func (c RouteConflict) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", c.Kind, c.Message)
+	for _, r := range c.Routes {
+		fmt.Fprintf(&b, "\n    %s %s -> %s (%s)", r.Method, r.Path, r.Handler, r.location())
+	}
+	return b.String()
+}
+
+// routeRegistry 通过 Router 注册的路由
+type routeRegistry struct {
+	mu     sync.Mutex
+	routes []RouteInfo
+	// 与已注册路由同方法同形状、未交给 Hertz 注册（否则 Hertz 会 panic）的路由
+	rejected []RouteInfo
+}
+
+// registries 每个引擎一份注册表（*route.Engine -> *routeRegistry）
+var registries sync.Map
+
+func registryFor(engine *route.Engine) *routeRegistry {
+	v, _ := registries.LoadOrStore(engine, &routeRegistry{})
+	return v.(*routeRegistry)
+}
+
+// Router 记录注册位置与认证声明的路由助手
+//
+// 通过 Router 注册的路由在 MustRun 启动前统一校验，所有冲突一次性报告；
+// 会导致 Hertz panic 的重复注册会被记录而不是立即中断启动
+//
+// 使用方式：
+//
+//	r := web.NewRouter(h.Engine)
+//	api := r.Group("/api", jwt.Middleware()).RequireAuth()
+//	api.GET("/users/:id", getUser)
+//	r.Public().POST("/login", login)
+type Router struct {
+	group      *route.RouterGroup
+	registry   *routeRegistry
+	groupAuth  string
+	routeAuth  string
+	examples   []Example
+	deprecated string
+	binding    string
+}
+
+// NewRouter 创建路由助手
+func NewRouter(engine *route.Engine) *Router {
+	return &Router{group: &engine.RouterGroup, registry: registryFor(engine)}
+}
+
+// Group 创建子分组（继承分组的认证声明）
+func (r *Router) Group(relativePath string, handlers ...app.HandlerFunc) *Router {
+	return &Router{group: r.group.Group(relativePath, handlers...), registry: r.registry, groupAuth: r.groupAuth, binding: r.binding}
+}
+
+// RequireAuth 声明分组内的路由都需要认证
+func (r *Router) RequireAuth() *Router {
+	clone := *r
+	clone.groupAuth = AuthRequired
+	clone.routeAuth = AuthRequired
+	return &clone
+}
+
+// Public 声明后续注册的路由为公开访问
+func (r *Router) Public() *Router {
+	clone := *r
+	clone.routeAuth = AuthPublic
+	return &clone
+}
+
+// WithExamples 为后续注册的路由声明请求示例
+func (r *Router) WithExamples(examples ...Example) *Router {
+	clone := *r
+	clone.examples = examples
+	return &clone
+}
+
+// Deprecated 声明后续注册的路由已废弃（note 说明替代接口，生成的 TypeScript 客户端据此标注 @deprecated）
+//
+// 使用方式：
+//
+//	api.Deprecated("改用 GET /api/v2/orders").GET("/orders", web.WrapHandler(listOrdersV1))
+func (r *Router) Deprecated(note string) *Router {
+	clone := *r
+	clone.deprecated = note
+	return &clone
+}
+
+// Strict 声明后续注册的路由使用严格绑定：请求体中有结构体未声明的 JSON 字段时 Bind 返回 400（见 SetStrictBinding）
+//
+// 使用方式：
+//
+//	admin := api.Group("/admin").Strict()
+//	admin.POST("/roles/:id/assign", web.WrapHandler(assignRole))
+func (r *Router) Strict() *Router {
+	clone := *r
+	clone.binding = BindingStrict
+	return &clone
+}
+
+// Lenient 声明后续注册的路由忽略未声明的 JSON 字段（全局默认开启严格绑定时逐个路由豁免）
+func (r *Router) Lenient() *Router {
+	clone := *r
+	clone.binding = BindingLenient
+	return &clone
+}
+
+// GET 注册 GET 路由
+func (r *Router) GET(relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(consts.MethodGet, relativePath, handlers)
+}
+
+// POST 注册 POST 路由
+func (r *Router) POST(relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(consts.MethodPost, relativePath, handlers)
+}
+
+// PUT 注册 PUT 路由
+func (r *Router) PUT(relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(consts.MethodPut, relativePath, handlers)
+}
+
+// PATCH 注册 PATCH 路由
+func (r *Router) PATCH(relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(consts.MethodPatch, relativePath, handlers)
+}
+
+// DELETE 注册 DELETE 路由
+func (r *Router) DELETE(relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(consts.MethodDelete, relativePath, handlers)
+}
+
+// Handle 注册任意方法的路由
+func (r *Router) Handle(method, relativePath string, handlers ...app.HandlerFunc) {
+	r.handle(method, relativePath, handlers)
+}
+
+// handle 记录注册信息；调用栈：业务代码 -> GET/POST/... -> handle
+func (r *Router) handle(method, relativePath string, handlers []app.HandlerFunc) {
+	info := RouteInfo{
+		Method:     method,
+		Path:       joinRoutePath(r.group.BasePath(), relativePath),
+		Auth:       r.routeAuth,
+		GroupAuth:  r.groupAuth,
+		Ownership:  hasOwnershipGuard(r.group.Handlers) || hasOwnershipGuard(handlers),
+		Examples:   r.examples,
+		Deprecated: r.deprecated,
+		Binding:    r.binding,
+	}
+	if len(handlers) > 0 {
+		info.Handler = handlerName(handlers[len(handlers)-1])
+		info.markRaw(handlers[len(handlers)-1])
+	}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		info.File, info.Line = file, line
+	}
+
+	r.registry.mu.Lock()
+	shape := routeShape(info.Path)
+	for _, existing := range r.registry.routes {
+		if existing.Method == method && routeShape(existing.Path) == shape {
+			r.registry.rejected = append(r.registry.rejected, info)
+			r.registry.mu.Unlock()
+			return
+		}
+	}
+	r.registry.routes = append(r.registry.routes, info)
+	r.registry.mu.Unlock()
+
+	if r.binding != "" {
+		handlers = append([]app.HandlerFunc{bindingMode(r.binding == BindingStrict)}, handlers...)
+	}
+	r.group.Handle(method, relativePath, handlers...)
+}
+
+// joinRoutePath 拼接分组路径（保留结尾斜杠）
+func joinRoutePath(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// handlerName 处理函数名
+func handlerName(h app.HandlerFunc) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
+
+// routeShape 去掉参数名后的路径形状：/users/:id/*rest -> /users/:/*
+func routeShape(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = ":"
+		} else if strings.HasPrefix(s, "*") {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// ValidateRoutes 校验路由表，返回完整路由表与全部冲突
+//
+// 通过 Router 注册的路由带注册位置；直接通过 Hertz 注册的路由从 engine.Routes() 补充（尽力而为）
+func ValidateRoutes(engine *route.Engine) ([]RouteInfo, []RouteConflict) {
+	registry := registryFor(engine)
+	registry.mu.Lock()
+	routes := append([]RouteInfo(nil), registry.routes...)
+	rejected := append([]RouteInfo(nil), registry.rejected...)
+	registry.mu.Unlock()
+
+	known := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		known[r.Method+" "+r.Path] = true
+	}
+	for _, r := range engine.Routes() {
+		if !known[r.Method+" "+r.Path] {
+			info := RouteInfo{Method: r.Method, Path: r.Path, Handler: r.Handler, Direct: true}
+			info.markRaw(r.HandlerFunc)
+			routes = append(routes, info)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	var conflicts []RouteConflict
+
+	// 1. 重复注册（被拒绝的路由与已注册的同形状路由）
+	for _, r := range rejected {
+		for _, existing := range routes {
+			if existing.Method != r.Method || routeShape(existing.Path) != routeShape(r.Path) {
+				continue
+			}
+			kind, msg := ConflictDuplicate, fmt.Sprintf("%s %s 重复注册", r.Method, r.Path)
+			if existing.Path != r.Path {
+				kind, msg = ConflictParamMismatch, fmt.Sprintf("%s %s 与 %s 形状相同但参数名不同", r.Method, existing.Path, r.Path)
+			}
+			conflicts = append(conflicts, RouteConflict{Kind: kind, Message: msg, Routes: []RouteInfo{existing, r}})
+			break
+		}
+	}
+
+	// 2. 同一前缀形状上的参数名不一致（不区分方法）
+	type paramUse struct {
+		name  string
+		route RouteInfo
+	}
+	params := map[string][]paramUse{}
+	var prefixes []string
+	for _, r := range routes {
+		segments := strings.Split(r.Path, "/")
+		for i, s := range segments {
+			if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+				continue
+			}
+			key := routeShape(strings.Join(segments[:i+1], "/"))
+			if _, ok := params[key]; !ok {
+				prefixes = append(prefixes, key)
+			}
+			params[key] = append(params[key], paramUse{name: s[1:], route: r})
+		}
+	}
+	for _, key := range prefixes {
+		uses := params[key]
+		names := map[string]RouteInfo{}
+		var order []string
+		for _, u := range uses {
+			if _, ok := names[u.name]; !ok {
+				names[u.name] = u.route
+				order = append(order, u.name)
+			}
+		}
+		if len(order) < 2 {
+			continue
+		}
+		var involved []RouteInfo
+		for _, name := range order {
+			involved = append(involved, names[name])
+		}
+		conflicts = append(conflicts, RouteConflict{
+			Kind:    ConflictParamMismatch,
+			Message: fmt.Sprintf("路径 %s 上的参数名不一致: %s", key, strings.Join(order, ", ")),
+			Routes:  involved,
+		})
+	}
+
+	// 3. 通配路由与同方法、同前缀的其他路由重叠
+	for _, wild := range routes {
+		idx := strings.Index(wild.Path, "/*")
+		if idx < 0 {
+			continue
+		}
+		prefix := routeShape(wild.Path[:idx+1])
+		for _, other := range routes {
+			if other.Method != wild.Method || other.Path == wild.Path {
+				continue
+			}
+			if strings.HasPrefix(routeShape(other.Path), prefix) {
+				conflicts = append(conflicts, RouteConflict{
+					Kind:    ConflictWildcardShadow,
+					Message: fmt.Sprintf("%s %s 与通配路由 %s 重叠", other.Method, other.Path, wild.Path),
+					Routes:  []RouteInfo{wild, other},
+				})
+			}
+		}
+	}
+
+	// 4. 认证声明与分组不一致
+	for _, r := range routes {
+		if r.GroupAuth == AuthRequired && r.Auth == AuthPublic {
+			conflicts = append(conflicts, RouteConflict{
+				Kind:    ConflictAuthMismatch,
+				Message: fmt.Sprintf("%s %s 声明为公开访问，但所在分组要求认证", r.Method, r.Path),
+				Routes:  []RouteInfo{r},
+			})
+		}
+	}
+
+	return routes, conflicts
+}
+
+// validatedRoutes 启动时校验后的路由表（供 /debug/routes 使用）
+var validatedRoutes atomic.Pointer[[]RouteInfo]
+
+// mustValidateRoutes 启动前校验路由表：输出路由表，报告全部冲突，非宽松模式下中止启动
+func mustValidateRoutes(engine *route.Engine, config RoutesConfig) {
+	routes, conflicts := ValidateRoutes(engine)
+	validatedRoutes.Store(&routes)
+
+	log.Infof("[Routes] 共 %d 条路由", len(routes))
+	raw := 0
+	for _, r := range routes {
+		if r.Raw != nil {
+			raw++
+			log.Infof("[Routes] %-7s %-40s -> %s [raw: %s]", r.Method, r.Path, r.Handler, r.Raw.Reason)
+			continue
+		}
+		if r.Deprecated != "" {
+			log.Infof("[Routes] %-7s %-40s -> %s [deprecated: %s]", r.Method, r.Path, r.Handler, r.Deprecated)
+			continue
+		}
+		log.Infof("[Routes] %-7s %-40s -> %s", r.Method, r.Path, r.Handler)
+	}
+	if raw > 0 {
+		log.Infof("[Routes] %d 条原始路由不使用统一响应格式", raw)
+	}
+	if len(conflicts) == 0 {
+		return
+	}
+
+	for _, conflict := range conflicts {
+		log.Errorf("[Routes] 路由冲突 %s", conflict)
+	}
+	if config.Permissive {
+		log.Warnf("[Routes] 发现 %d 处路由冲突，宽松模式下继续启动", len(conflicts))
+		return
+	}
+	panic(fmt.Errorf("发现 %d 处路由冲突，请修复后重试（或设置 [web.routes] permissive = true）", len(conflicts)))
+}
+
+// DebugRoutesHandler 输出启动时校验后的路由表
+//
+// 使用方式：
+//
+//	h.GET("/debug/routes", web.DebugRoutesHandler())
+func DebugRoutesHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		var routes []RouteInfo
+		if p := validatedRoutes.Load(); p != nil {
+			routes = *p
+		}
+		c.JSON(consts.StatusOK, Success(routes))
+	}
+}
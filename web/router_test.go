@@ -0,0 +1,113 @@
+package web
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noopHandler(ctx context.Context, c *app.RequestContext) {}
+
+func conflictKinds(conflicts []RouteConflict) []string {
+	kinds := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		kinds = append(kinds, c.Kind)
+	}
+	return kinds
+}
+
+func TestValidateRoutes_Conflicts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		register func(r *Router, engine *route.Engine)
+		kind     string
+	}{
+		{"exact duplicate", func(r *Router, engine *route.Engine) {
+			r.GET("/api/users/:id", noopHandler)
+			r.Group("/api").GET("/users/:id", noopHandler)
+		}, ConflictDuplicate},
+		{"same shape different param", func(r *Router, engine *route.Engine) {
+			r.GET("/api/users/:id", noopHandler)
+			r.GET("/api/users/:userId", noopHandler)
+		}, ConflictParamMismatch},
+		{"param mismatch across methods", func(r *Router, engine *route.Engine) {
+			r.GET("/api/users/:id", noopHandler)
+			r.PUT("/api/users/:userId", noopHandler)
+		}, ConflictParamMismatch},
+		{"param mismatch in nested route", func(r *Router, engine *route.Engine) {
+			r.GET("/api/users/:id", noopHandler)
+			engine.GET("/api/users/:uid/orders", noopHandler)
+		}, ConflictParamMismatch},
+		{"wildcard shadow", func(r *Router, engine *route.Engine) {
+			r.GET("/files/*path", noopHandler)
+			r.GET("/files/list", noopHandler)
+		}, ConflictWildcardShadow},
+		{"public route in auth group", func(r *Router, engine *route.Engine) {
+			r.Group("/admin").RequireAuth().Public().GET("/stats", noopHandler)
+		}, ConflictAuthMismatch},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := route.NewEngine(config.NewOptions(nil))
+			assert.NotPanics(t, func() { tc.register(NewRouter(engine), engine) })
+
+			_, conflicts := ValidateRoutes(engine)
+			require.Len(t, conflicts, 1, "%v", conflicts)
+			assert.Equal(t, tc.kind, conflicts[0].Kind)
+			// 通过 Router 注册的路由带注册位置
+			assert.Contains(t, conflicts[0].String(), "router_test.go:")
+		})
+	}
+}
+
+func TestValidateRoutes_NoFalsePositives(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := NewRouter(engine)
+
+	api := r.Group("/api")
+	api.GET("/users", noopHandler)
+	api.POST("/users", noopHandler)
+	api.GET("/users/:id", noopHandler)
+	api.PUT("/users/:id", noopHandler)
+	api.GET("/users/me", noopHandler)
+	api.GET("/users/:id/orders/:orderId", noopHandler)
+	api.GET("/orders/:id", noopHandler)
+	r.GET("/static/*filepath", noopHandler)
+	r.POST("/static/upload", noopHandler)
+	admin := r.Group("/admin").RequireAuth()
+	admin.GET("/stats", noopHandler)
+	r.Public().POST("/login", noopHandler)
+	engine.GET("/health", noopHandler)
+
+	routes, conflicts := ValidateRoutes(engine)
+	assert.Empty(t, conflicts, "%v", conflictKinds(conflicts))
+	assert.Len(t, routes, 12)
+
+	for _, route := range routes {
+		if route.Path == "/health" {
+			assert.True(t, route.Direct)
+		} else {
+			assert.NotEmpty(t, route.File)
+		}
+		if route.Path == "/admin/stats" {
+			assert.Equal(t, AuthRequired, route.Auth)
+		}
+	}
+}
+
+func TestMustValidateRoutes(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := NewRouter(engine)
+	r.GET("/a/:id", noopHandler)
+	r.GET("/a/:name", noopHandler)
+
+	assert.Panics(t, func() { mustValidateRoutes(engine, RoutesConfig{}) })
+	assert.NotPanics(t, func() { mustValidateRoutes(engine, RoutesConfig{Permissive: true}) })
+	assert.Len(t, *validatedRoutes.Load(), 1)
+}
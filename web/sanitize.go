@@ -0,0 +1,375 @@
+package web
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sanitizeMaxDepth 超过此嵌套深度的元素只保留文本，避免恶意深层嵌套拖垮渲染
+const sanitizeMaxDepth = 100
+
+// dangerousElements 任何策略都不允许的元素，连同内容一起丢弃
+//
+// 包括脚本/样式、原始文本元素（序列化后再解析结构会变，是 mutation XSS 的来源）以及 svg/math 外部内容
+var dangerousElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "base": true, "meta": true, "link": true,
+	"svg": true, "math": true, "template": true, "noscript": true, "noembed": true, "noframes": true,
+	"textarea": true, "title": true, "xmp": true, "plaintext": true, "head": true,
+}
+
+// urlAttributes 值为 URL 的属性，需要校验协议
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "poster": true, "background": true,
+	"longdesc": true, "action": true, "formaction": true,
+}
+
+// voidElements 没有结束标签的元素
+var voidElements = map[string]bool{
+	"br": true, "hr": true, "img": true, "wbr": true, "col": true, "area": true,
+	"input": true, "source": true, "track": true,
+}
+
+// SanitizePolicy HTML 清洗策略（不可变，可并发使用）
+//
+// 内置策略：StrictText（去掉全部标签）、BasicFormatting（基础格式 + 链接）、Relaxed（再加标题、表格、图片）
+// 自定义策略使用 NewSanitizePolicy 或 Extend 构建
+type SanitizePolicy struct {
+	name            string
+	elements        map[string]map[string]bool // 元素 -> 允许的属性
+	schemes         map[string]bool            // 允许的 URL 协议
+	imageHosts      map[string]bool            // 允许的图片域名（为空不限制）
+	allowRelative   bool                       // 是否允许相对 URL
+	requireNoopener bool                       // 链接强制 rel="noopener noreferrer"
+}
+
+// Name 策略名（用于 sanitize 标签）
+func (p SanitizePolicy) Name() string {
+	return p.name
+}
+
+// Extend 以当前策略为基础构建新策略
+//
+// 使用方式：
+//
+//	policy := web.BasicFormatting.Extend("comment").AllowElements("code", "pre").Build()
+func (p SanitizePolicy) Extend(name string) *SanitizePolicyBuilder {
+	b := NewSanitizePolicy(name)
+	for el, attrs := range p.elements {
+		b.policy.elements[el] = copySet(attrs)
+	}
+	b.policy.schemes = copySet(p.schemes)
+	b.policy.imageHosts = copySet(p.imageHosts)
+	b.policy.allowRelative = p.allowRelative
+	b.policy.requireNoopener = p.requireNoopener
+	return b
+}
+
+// SanitizePolicyBuilder 自定义策略构建器
+//
+// 使用方式：
+//
+//	policy := web.NewSanitizePolicy("wiki").
+//	    AllowElements("p", "h2", "h3", "code").
+//	    AllowAttributes("a", "href", "title").
+//	    AllowURLSchemes("https", "mailto").
+//	    RequireNoopener().
+//	    Build()
+//	web.RegisterSanitizePolicy(policy)   // 之后可用 `sanitize:"wiki"`
+type SanitizePolicyBuilder struct {
+	policy SanitizePolicy
+}
+
+// NewSanitizePolicy 创建空策略构建器（默认只允许 http/https 协议）
+func NewSanitizePolicy(name string) *SanitizePolicyBuilder {
+	return &SanitizePolicyBuilder{policy: SanitizePolicy{
+		name:       name,
+		elements:   map[string]map[string]bool{},
+		schemes:    map[string]bool{"http": true, "https": true},
+		imageHosts: map[string]bool{},
+	}}
+}
+
+// AllowElements 允许元素（不带属性）
+func (b *SanitizePolicyBuilder) AllowElements(elements ...string) *SanitizePolicyBuilder {
+	for _, el := range elements {
+		el = strings.ToLower(el)
+		if b.policy.elements[el] == nil {
+			b.policy.elements[el] = map[string]bool{}
+		}
+	}
+	return b
+}
+
+// AllowAttributes 允许元素及其属性（事件属性 on* 和 srcset 始终被拒绝）
+func (b *SanitizePolicyBuilder) AllowAttributes(element string, attrs ...string) *SanitizePolicyBuilder {
+	b.AllowElements(element)
+	element = strings.ToLower(element)
+	for _, attr := range attrs {
+		b.policy.elements[element][strings.ToLower(attr)] = true
+	}
+	return b
+}
+
+// AllowURLSchemes 设置允许的 URL 协议（覆盖默认的 http/https）
+func (b *SanitizePolicyBuilder) AllowURLSchemes(schemes ...string) *SanitizePolicyBuilder {
+	b.policy.schemes = map[string]bool{}
+	for _, s := range schemes {
+		b.policy.schemes[strings.ToLower(s)] = true
+	}
+	return b
+}
+
+// AllowRelativeURLs 允许相对 URL（如 /docs/intro）
+func (b *SanitizePolicyBuilder) AllowRelativeURLs() *SanitizePolicyBuilder {
+	b.policy.allowRelative = true
+	return b
+}
+
+// AllowImageHosts 限制图片只能来自指定域名
+func (b *SanitizePolicyBuilder) AllowImageHosts(hosts ...string) *SanitizePolicyBuilder {
+	for _, h := range hosts {
+		b.policy.imageHosts[strings.ToLower(h)] = true
+	}
+	return b
+}
+
+// RequireNoopener 为所有链接强制添加 rel="noopener noreferrer"
+func (b *SanitizePolicyBuilder) RequireNoopener() *SanitizePolicyBuilder {
+	b.policy.requireNoopener = true
+	return b
+}
+
+// Build 生成策略
+func (b *SanitizePolicyBuilder) Build() SanitizePolicy {
+	p := b.policy
+	p.elements = make(map[string]map[string]bool, len(b.policy.elements))
+	for el, attrs := range b.policy.elements {
+		p.elements[el] = copySet(attrs)
+	}
+	p.schemes = copySet(b.policy.schemes)
+	p.imageHosts = copySet(b.policy.imageHosts)
+	return p
+}
+
+func copySet(m map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// 内置策略
+var (
+	// StrictText 去掉所有标签，只保留（转义后的）文本
+	StrictText = NewSanitizePolicy("strict").Build()
+
+	// BasicFormatting 段落、粗体、斜体、列表和链接；链接只允许 http/https 并强制 rel=noopener
+	BasicFormatting = NewSanitizePolicy("basic").
+			AllowElements("p", "br", "b", "strong", "i", "em", "u", "ul", "ol", "li").
+			AllowAttributes("a", "href", "title", "target").
+			RequireNoopener().
+			Build()
+
+	// Relaxed 在 BasicFormatting 基础上增加标题、引用、代码、表格和图片（src 只允许 http/https）
+	Relaxed = BasicFormatting.Extend("relaxed").
+		AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "code", "hr", "span", "div",
+			"table", "thead", "tbody", "tr", "th", "td").
+		AllowAttributes("img", "src", "alt", "title", "width", "height").
+		Build()
+)
+
+var sanitizePolicies = sync.Map{}
+
+func init() {
+	for _, p := range []SanitizePolicy{StrictText, BasicFormatting, Relaxed} {
+		RegisterSanitizePolicy(p)
+	}
+}
+
+// RegisterSanitizePolicy 注册策略，之后可在 sanitize 结构体标签中按名称引用
+func RegisterSanitizePolicy(policy SanitizePolicy) {
+	if policy.name == "" {
+		panic("sanitize: policy name is required")
+	}
+	sanitizePolicies.Store(policy.name, policy)
+}
+
+// lookupSanitizePolicy 按名称查找已注册的策略
+func lookupSanitizePolicy(name string) (SanitizePolicy, bool) {
+	v, ok := sanitizePolicies.Load(name)
+	if !ok {
+		return SanitizePolicy{}, false
+	}
+	return v.(SanitizePolicy), true
+}
+
+// SanitizeReport 清洗结果报告
+type SanitizeReport struct {
+	Stripped bool     // 是否移除了任何内容
+	Removed  []string // 被移除的元素和属性，如 "script"、"img@onerror"、"a@href"
+}
+
+// SanitizeHTML 按策略清洗 HTML（解析为 DOM 后按白名单重新序列化，不使用正则）
+//
+// 使用方式：
+//
+//	clean := web.SanitizeHTML(input, web.BasicFormatting)
+func SanitizeHTML(input string, policy SanitizePolicy) string {
+	out, _ := SanitizeHTMLReport(input, policy)
+	return out
+}
+
+// SanitizeHTMLReport 清洗 HTML 并报告移除了哪些内容（可用于提示客户端）
+//
+// 使用方式：
+//
+//	clean, report := web.SanitizeHTMLReport(input, web.Relaxed)
+//	if report.Stripped {
+//	    log.Infof("内容已清洗: %v", report.Removed)
+//	}
+func SanitizeHTMLReport(input string, policy SanitizePolicy) (string, SanitizeReport) {
+	if input == "" {
+		return "", SanitizeReport{}
+	}
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(input), context)
+	if err != nil {
+		// 解析失败时退化为纯文本，保证不输出任何标签
+		return html.EscapeString(input), SanitizeReport{Stripped: true, Removed: []string{"#unparsable"}}
+	}
+
+	s := &sanitizer{policy: policy, removed: map[string]bool{}}
+	s.out.Grow(len(input))
+	for _, n := range nodes {
+		s.render(n, 0)
+	}
+
+	report := SanitizeReport{Stripped: len(s.removed) > 0}
+	for r := range s.removed {
+		report.Removed = append(report.Removed, r)
+	}
+	sort.Strings(report.Removed)
+	return s.out.String(), report
+}
+
+// sanitizer 单次清洗的状态
+type sanitizer struct {
+	policy  SanitizePolicy
+	out     strings.Builder
+	removed map[string]bool
+}
+
+func (s *sanitizer) render(n *html.Node, depth int) {
+	switch n.Type {
+	case html.TextNode:
+		s.out.WriteString(html.EscapeString(n.Data))
+	case html.ElementNode:
+		s.renderElement(n, depth)
+	case html.CommentNode:
+		s.removed["#comment"] = true
+	case html.DoctypeNode:
+		s.removed["#doctype"] = true
+	case html.DocumentNode:
+		s.renderChildren(n, depth)
+	}
+}
+
+func (s *sanitizer) renderChildren(n *html.Node, depth int) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.render(c, depth)
+	}
+}
+
+func (s *sanitizer) renderElement(n *html.Node, depth int) {
+	name := n.Data
+	if n.Namespace != "" || dangerousElements[name] {
+		// 危险元素连同内容一起丢弃
+		s.removed[name] = true
+		return
+	}
+
+	allowed, ok := s.policy.elements[name]
+	if !ok || depth >= sanitizeMaxDepth {
+		// 不允许的元素去掉标签，保留内容
+		s.removed[name] = true
+		s.renderChildren(n, depth)
+		return
+	}
+
+	s.out.WriteByte('<')
+	s.out.WriteString(name)
+	for _, attr := range n.Attr {
+		key := attr.Key
+		if attr.Namespace != "" || !allowed[key] || strings.HasPrefix(key, "on") || key == "srcset" ||
+			(name == "a" && key == "rel" && s.policy.requireNoopener) {
+			s.removed[name+"@"+key] = true
+			continue
+		}
+		val := attr.Val
+		if urlAttributes[key] {
+			cleaned, ok := s.policy.checkURL(val, name == "img")
+			if !ok {
+				s.removed[name+"@"+key] = true
+				continue
+			}
+			val = cleaned
+		}
+		s.out.WriteByte(' ')
+		s.out.WriteString(key)
+		s.out.WriteString(`="`)
+		s.out.WriteString(html.EscapeString(val))
+		s.out.WriteByte('"')
+	}
+	if name == "a" && s.policy.requireNoopener {
+		s.out.WriteString(` rel="noopener noreferrer"`)
+	}
+	s.out.WriteByte('>')
+
+	if voidElements[name] {
+		return
+	}
+	s.renderChildren(n, depth+1)
+	s.out.WriteString("</")
+	s.out.WriteString(name)
+	s.out.WriteByte('>')
+}
+
+// checkURL 校验 URL 协议（与浏览器一致：去掉首尾空白和控制字符、去掉内部的制表符和换行）
+func (p SanitizePolicy) checkURL(raw string, image bool) (string, bool) {
+	v := strings.TrimFunc(raw, func(r rune) bool { return r <= 0x20 || r == 0x7f })
+	v = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, v)
+	if v == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme == "" {
+		// 带冒号却解析不出协议的值一律拒绝，避免与浏览器解析产生分歧
+		if i := strings.IndexByte(v, ':'); i >= 0 && !strings.ContainsAny(v[:i], "/?#") {
+			return "", false
+		}
+		return v, p.allowRelative && (!image || len(p.imageHosts) == 0 || u.Host == "")
+	}
+	if !p.schemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	if image && len(p.imageHosts) > 0 && !p.imageHosts[strings.ToLower(u.Hostname())] {
+		return "", false
+	}
+	return v, true
+}
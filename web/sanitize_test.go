@@ -0,0 +1,257 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// xssCorpus 已知的 XSS 向量（OWASP 备忘单、mutation XSS 与畸形标记）
+var xssCorpus = []string{
+	`<script>alert(1)</script>`,
+	`<SCRIPT SRC=//evil.example/xss.js></SCRIPT>`,
+	`<img src=x onerror=alert(1)>`,
+	`<IMG SRC="javascript:alert('XSS');">`,
+	`<IMG SRC=JaVaScRiPt:alert('XSS')>`,
+	`<IMG SRC="jav&#x09;ascript:alert('XSS');">`,
+	`<IMG SRC="jav&#x0A;ascript:alert('XSS');">`,
+	`<IMG SRC=" &#14;  javascript:alert('XSS');">`,
+	`<IMG SRC=&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;&#97;&#108;&#101;&#114;&#116;&#40;&#39;&#88;&#83;&#83;&#39;&#41;>`,
+	`<a href="javascript:alert(1)">x</a>`,
+	`<a href="  JAVASCRIPT:alert(1)">x</a>`,
+	`<a href="vbscript:msgbox(1)">x</a>`,
+	`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+	`<a href="java&#0000115cript:alert(1)">x</a>`,
+	`<a href=javascript&colon;alert(1)>x</a>`,
+	`<a onmouseover="alert(1)" href="https://ok.example">x</a>`,
+	`<body onload=alert(1)>`,
+	`<svg onload=alert(1)>`,
+	`<svg><script>alert(1)</script></svg>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`<svg></p><style><a id="</style><img src=1 onerror=alert(1)>">`,
+	`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+	`<form><math><mtext></form><form><mglyph><style></math><img src onerror=alert(1)>`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`<iframe srcdoc="<script>alert(1)</script>"></iframe>`,
+	`<object data="javascript:alert(1)"></object>`,
+	`<embed src="javascript:alert(1)">`,
+	`<style>@import 'javascript:alert(1)';</style>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	`<p style="x:expression(alert(1))">x</p>`,
+	`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+	`<base href="javascript:alert(1)//">`,
+	`<link rel=stylesheet href="javascript:alert(1)">`,
+	`<table background="javascript:alert(1)"><tr><td>x</td></tr></table>`,
+	`<input onfocus=alert(1) autofocus>`,
+	`<details open ontoggle=alert(1)>`,
+	`<video><source onerror="alert(1)"></video>`,
+	`<xmp><img src=x onerror=alert(1)></xmp>`,
+	`<textarea><img src=x onerror=alert(1)></textarea>`,
+	`<title><img src=x onerror=alert(1)></title>`,
+	`<template><img src=x onerror=alert(1)></template>`,
+	`<plaintext><img src=x onerror=alert(1)>`,
+	`<!--<img src="--><img src=x onerror=alert(1)//">`,
+	`<![CDATA[<script>alert(1)</script>]]>`,
+	`<scr<script>ipt>alert(1)</scr</script>ipt>`,
+	`<<script>alert(1)//<</script>`,
+	`<img """><script>alert(1)</script>">`,
+	`<p><b><i>unclosed <a href="https://ok.example">nested`,
+	`</p></b></i>stray end tags<p>`,
+	`<img src="https://ok.example/a.png" srcset="javascript:alert(1) 1x">`,
+	`<a href="https://ok.example" rel="opener">x</a>`,
+	`<p id=a title="x" onclick=alert(1)>x</p>`,
+	"<a href=\"java\x00script:alert(1)\">x</a>",
+	`<img src=x:alert(alt) onerror=eval(src) alt=0>`,
+	`<a href="//evil.example">protocol relative</a>`,
+}
+
+// assertNeutralized 重新解析输出，确认只剩策略允许的元素和属性
+func assertNeutralized(t *testing.T, policy SanitizePolicy, input, out string) {
+	t.Helper()
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(out), context)
+	require.NoError(t, err)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs, ok := policy.elements[n.Data]
+			assert.True(t, ok, "input %q: element <%s> survived in %q", input, n.Data, out)
+			for _, a := range n.Attr {
+				if n.Data == "a" && a.Key == "rel" && policy.requireNoopener {
+					assert.Equal(t, "noopener noreferrer", a.Val)
+					continue
+				}
+				assert.True(t, attrs[a.Key], "input %q: attribute %s@%s survived in %q", input, n.Data, a.Key, out)
+				if urlAttributes[a.Key] {
+					lower := strings.ToLower(a.Val)
+					assert.True(t, strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"),
+						"input %q: unsafe url %q", input, a.Val)
+				}
+			}
+		}
+		assert.NotEqual(t, html.CommentNode, n.Type, "input %q: comment survived", input)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+}
+
+func TestSanitizeHTML_XSSCorpus(t *testing.T) {
+	for _, policy := range []SanitizePolicy{StrictText, BasicFormatting, Relaxed} {
+		for _, input := range xssCorpus {
+			out := SanitizeHTML(input, policy)
+			assertNeutralized(t, policy, input, out)
+			// 序列化结果再清洗一次不应变化，否则说明存在解析歧义（mutation XSS）
+			assert.Equal(t, out, SanitizeHTML(out, policy), "policy %s not idempotent for %q", policy.Name(), input)
+			lower := strings.ToLower(out)
+			assert.NotContains(t, lower, "<script", input)
+			assert.NotContains(t, lower, "onerror=", input)
+		}
+	}
+}
+
+func TestSanitizeHTML_Policies(t *testing.T) {
+	input := `<p>Hello <b>world</b> <a href="https://example.com" target="_blank">link</a><img src="https://cdn.example/a.png" alt="a"></p>`
+
+	assert.Equal(t, `Hello world link`, SanitizeHTML(input, StrictText))
+	assert.Equal(t, `<p>Hello <b>world</b> <a href="https://example.com" target="_blank" rel="noopener noreferrer">link</a></p>`,
+		SanitizeHTML(input, BasicFormatting))
+	assert.Equal(t, `<p>Hello <b>world</b> <a href="https://example.com" target="_blank" rel="noopener noreferrer">link</a><img src="https://cdn.example/a.png" alt="a"></p>`,
+		SanitizeHTML(input, Relaxed))
+
+	// 文本被转义而不是删除
+	assert.Equal(t, `1 &lt; 2 &amp;&amp; 3 &gt; 2`, SanitizeHTML(`1 < 2 && 3 > 2`, StrictText))
+}
+
+func TestSanitizeHTML_Report(t *testing.T) {
+	out, report := SanitizeHTMLReport(`<p onclick="x()">hi</p><script>bad()</script>`, BasicFormatting)
+	assert.Equal(t, `<p>hi</p>`, out)
+	assert.True(t, report.Stripped)
+	assert.Equal(t, []string{"p@onclick", "script"}, report.Removed)
+
+	_, report = SanitizeHTMLReport(`<p>fine &amp; dandy</p>`, BasicFormatting)
+	assert.False(t, report.Stripped)
+}
+
+func TestSanitizeHTML_CustomPolicy(t *testing.T) {
+	policy := NewSanitizePolicy("docs").
+		AllowElements("p", "code").
+		AllowAttributes("a", "href").
+		AllowAttributes("img", "src").
+		AllowURLSchemes("https", "mailto").
+		AllowRelativeURLs().
+		AllowImageHosts("cdn.example").
+		Build()
+
+	assert.Equal(t, `<a href="mailto:a@example.com">m</a>`, SanitizeHTML(`<a href="mailto:a@example.com">m</a>`, policy))
+	assert.Equal(t, `<a href="/docs/intro">r</a>`, SanitizeHTML(`<a href="/docs/intro">r</a>`, policy))
+	assert.Equal(t, `<a>h</a>`, SanitizeHTML(`<a href="http://plain.example">h</a>`, policy))
+	assert.Equal(t, `<img src="https://cdn.example/x.png">`, SanitizeHTML(`<img src="https://cdn.example/x.png">`, policy))
+	assert.Equal(t, `<img>`, SanitizeHTML(`<img src="https://tracker.example/p.gif">`, policy))
+	assert.Equal(t, `<img>`, SanitizeHTML(`<img src="//tracker.example/p.gif">`, policy))
+
+	// 自定义策略也不能放行危险元素
+	unsafe := NewSanitizePolicy("unsafe").AllowElements("script", "style").AllowAttributes("p", "onclick").Build()
+	assert.Equal(t, `<p>x</p>`, SanitizeHTML(`<script>a()</script><p onclick="b()">x</p>`, unsafe))
+
+	// Extend 不影响原策略
+	withCode := BasicFormatting.Extend("basic+code").AllowElements("code").Build()
+	assert.Equal(t, `<code>x</code>`, SanitizeHTML(`<code>x</code>`, withCode))
+	assert.Equal(t, `x`, SanitizeHTML(`<code>x</code>`, BasicFormatting))
+}
+
+func TestSanitizeHTML_LargeDocument(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 20000; i++ {
+		sb.WriteString(`<p>Paragraph <b>bold</b> <a href="https://example.com/` + "x" + `" onclick="x()">link</a><script>bad()</script></p>`)
+	}
+	input := sb.String() // 约 2MB
+
+	start := time.Now()
+	out, report := SanitizeHTMLReport(input, BasicFormatting)
+	elapsed := time.Since(start)
+
+	assert.True(t, report.Stripped)
+	assert.NotContains(t, out, "<script")
+	assert.Less(t, elapsed, 5*time.Second, "sanitizing %d bytes took %v", len(input), elapsed)
+
+	// 深层嵌套只保留文本，不会产生超深的输出结构
+	deep := strings.Repeat("<b>", 10000) + "deep" + strings.Repeat("</b>", 10000)
+	out = SanitizeHTML(deep, BasicFormatting)
+	assert.Contains(t, out, "deep")
+	assert.LessOrEqual(t, strings.Count(out, "<b>"), sanitizeMaxDepth)
+}
+
+func BenchmarkSanitizeHTML(b *testing.B) {
+	input := strings.Repeat(`<p>Some <b>rich</b> text with <a href="https://example.com">a link</a> and <img src=x onerror=alert(1)></p>`, 1000)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		SanitizeHTML(input, Relaxed)
+	}
+}
+
+func TestBind_SanitizeTag(t *testing.T) {
+	type profile struct {
+		Bio string `json:"bio" sanitize:"relaxed"`
+	}
+	type commentReq struct {
+		PostID  int64    `json:"postId" vd:"$>0"`
+		Content string   `json:"content" sanitize:"basic"`
+		Title   *string  `json:"title" sanitize:"strict"`
+		Tags    []string `json:"tags" sanitize:"strict"`
+		Raw     string   `json:"raw"`
+		Profile profile  `json:"profile"`
+	}
+
+	var got commentReq
+	var sanitized []string
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.POST("/comments", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		var req commentReq
+		if err := Bind(c, &req); err != nil {
+			return err
+		}
+		got, sanitized = req, GetSanitizedFields(c)
+		return nil
+	}))
+
+	body := `{"postId":1,"content":"<p>hi<script>x()</script></p>","title":"<b>T</b>","tags":["ok","<i>x</i>"],"raw":"<b>raw</b>","profile":{"bio":"<img src=x onerror=y()>me"}}`
+	w := ut.PerformRequest(engine, "POST", "/comments", &ut.Body{Body: strings.NewReader(body), Len: len(body)},
+		ut.Header{Key: "Content-Type", Value: "application/json"})
+	resp := w.Result()
+	require.Equal(t, 200, resp.StatusCode(), string(resp.Body()))
+	assert.Equal(t, "content,title,tags,profile.bio", resp.Header.Get(HeaderContentSanitized))
+	assert.Equal(t, []string{"content", "title", "tags", "profile.bio"}, sanitized)
+	assert.Equal(t, "<p>hi</p>", got.Content)
+	assert.Equal(t, "T", *got.Title)
+	assert.Equal(t, []string{"ok", "x"}, got.Tags)
+	assert.Equal(t, "<b>raw</b>", got.Raw)
+	assert.Equal(t, "<img>me", got.Profile.Bio)
+
+	// 校验失败时不进入清洗，返回 400
+	body = `{"postId":0,"content":"x"}`
+	w = ut.PerformRequest(engine, "POST", "/comments", &ut.Body{Body: strings.NewReader(body), Len: len(body)},
+		ut.Header{Key: "Content-Type", Value: "application/json"})
+	assert.Equal(t, 400, w.Result().StatusCode())
+
+	// 引用未注册的策略属于编码错误
+	var bad struct {
+		X string `sanitize:"nope"`
+	}
+	_, err := SanitizeStruct(&bad)
+	assert.Error(t, err)
+}
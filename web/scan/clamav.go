@@ -0,0 +1,122 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrStreamTooLarge 文件超过 clamd 的 StreamMaxLength
+var ErrStreamTooLarge = errors.New("clamd: INSTREAM size limit exceeded")
+
+// ClamAV 通过 clamd INSTREAM 协议扫描的扫描器
+//
+// 使用方式：
+//
+//	scanner := &scan.ClamAV{Address: "tcp://127.0.0.1:3310", Timeout: 30 * time.Second}
+//	result, err := scanner.Scan(ctx, file)
+type ClamAV struct {
+	Address       string        // tcp://host:port 或 unix:///path/to/clamd.sock（省略协议时按 tcp 处理）
+	Timeout       time.Duration // 单次扫描超时，默认 30 秒
+	MaxStreamSize int64         // 最大发送字节数，需不大于 clamd 的 StreamMaxLength，默认 25MB
+	ChunkSize     int           // 分块大小，默认 64KB
+}
+
+// Scan 实现 Scanner 接口
+func (s *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	start := time.Now()
+	result := Result{Scanner: "clamav"}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	maxSize := s.MaxStreamSize
+	if maxSize <= 0 {
+		maxSize = 25 << 20
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 64 << 10
+	}
+
+	network, addr := "tcp", s.Address
+	if rest, ok := strings.CutPrefix(addr, "unix://"); ok {
+		network, addr = "unix", rest
+	} else {
+		addr = strings.TrimPrefix(addr, "tcp://")
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return result, fmt.Errorf("连接 clamd 失败: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return result, fmt.Errorf("发送 INSTREAM 命令失败: %w", err)
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	var sent int64
+	for {
+		n, readErr := r.Read(buf[4:])
+		if n > 0 {
+			sent += int64(n)
+			if sent > maxSize {
+				return result, ErrStreamTooLarge
+			}
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return result, fmt.Errorf("发送数据失败: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return result, fmt.Errorf("读取文件失败: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return result, fmt.Errorf("发送结束标记失败: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return result, fmt.Errorf("读取 clamd 响应失败: %w", err)
+	}
+	result.Duration = time.Since(start)
+	return parseReply(strings.TrimRight(reply, "\x00\n"), result)
+}
+
+// parseReply 解析 "stream: OK" / "stream: <name> FOUND" / "... ERROR"
+func parseReply(reply string, result Result) (Result, error) {
+	body := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case body == "OK":
+		result.Verdict = VerdictClean
+		return result, nil
+	case strings.HasSuffix(body, " FOUND"):
+		result.Verdict = VerdictInfected
+		result.Signature = strings.TrimSuffix(body, " FOUND")
+		return result, nil
+	case strings.Contains(body, "size limit exceeded"):
+		return result, ErrStreamTooLarge
+	default:
+		return result, fmt.Errorf("clamd 返回错误: %s", reply)
+	}
+}
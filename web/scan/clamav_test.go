@@ -0,0 +1,98 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar 标准的反病毒测试特征串
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd 实现 INSTREAM 协议的最小 clamd
+func fakeClamd(t *testing.T, maxStream int) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				cmd, err := r.ReadString(0)
+				if err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if data.Len()+int(size) > maxStream {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(data.String(), eicar) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func TestClamAV_Scan(t *testing.T) {
+	addr := fakeClamd(t, 1<<20)
+	scanner := &ClamAV{Address: addr, Timeout: 2 * time.Second, ChunkSize: 16}
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader("hello world, nothing to see here"))
+	require.NoError(t, err)
+	assert.Equal(t, VerdictClean, result.Verdict)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader("prefix "+eicar+" suffix"))
+	require.NoError(t, err)
+	assert.True(t, result.Infected())
+	assert.Equal(t, "Eicar-Test-Signature", result.Signature)
+	assert.Equal(t, "Eicar-Test-Signature", result.AuditData()["scanSignature"])
+}
+
+func TestClamAV_Limits(t *testing.T) {
+	addr := fakeClamd(t, 32)
+
+	// 服务端限制
+	_, err := (&ClamAV{Address: addr}).Scan(context.Background(), strings.NewReader(strings.Repeat("a", 64)))
+	assert.ErrorIs(t, err, ErrStreamTooLarge)
+
+	// 客户端限制
+	_, err = (&ClamAV{Address: addr, MaxStreamSize: 8}).Scan(context.Background(), strings.NewReader(strings.Repeat("a", 16)))
+	assert.ErrorIs(t, err, ErrStreamTooLarge)
+
+	// 服务不可用
+	_, err = (&ClamAV{Address: "tcp://127.0.0.1:1", Timeout: time.Second}).Scan(context.Background(), strings.NewReader("x"))
+	assert.Error(t, err)
+}
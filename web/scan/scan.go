@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// 扫描结论
+const (
+	VerdictClean    = "clean"    // 未发现威胁
+	VerdictInfected = "infected" // 发现威胁
+	VerdictError    = "error"    // 扫描服务不可用或扫描失败
+	VerdictSkipped  = "skipped"  // 未配置扫描器
+)
+
+// Result 扫描结果
+type Result struct {
+	Verdict   string        `json:"verdict"`             // 扫描结论
+	Signature string        `json:"signature,omitempty"` // 命中的病毒特征名
+	Scanner   string        `json:"scanner"`             // 扫描器名称
+	Duration  time.Duration `json:"duration"`            // 扫描耗时
+}
+
+// Infected 是否发现威胁
+func (r Result) Infected() bool {
+	return r.Verdict == VerdictInfected
+}
+
+// AuditData 转换为审计事件的附加数据
+//
+// 使用方式：
+//
+//	audit.Emit(ctx, audit.Event{Type: "upload.create", Actor: userID, Data: result.AuditData()})
+func (r Result) AuditData() map[string]any {
+	data := map[string]any{
+		"scanVerdict":    r.Verdict,
+		"scanner":        r.Scanner,
+		"scanDurationMs": r.Duration.Milliseconds(),
+	}
+	if r.Signature != "" {
+		data["scanSignature"] = r.Signature
+	}
+	return data
+}
+
+// Scanner 文件扫描器
+//
+// 发现威胁时返回 Verdict = VerdictInfected 且 error 为 nil；
+// error 非 nil 表示扫描器本身不可用，由调用方决定放行还是拒绝
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Noop 不做任何扫描的默认实现
+type Noop struct{}
+
+// Scan 实现 Scanner 接口
+func (Noop) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{Verdict: VerdictSkipped, Scanner: "noop"}, nil
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/CenJIl/base/cfg"
+	"github.com/CenJIl/base/web/database"
+)
+
+// RunSeedCommand 处理 seed 子命令：按配置连接数据库写入演示数据，不启动服务
+//
+//	seed                        执行全部尚未执行过的种子数据（见 database.Seed）
+//	seed --only=users,orders    只执行指定的种子数据（名称可以省略数字前缀）
+//	seed --force                生产环境中也执行
+//
+// args 不是 seed 子命令时返回 false，由调用方继续正常启动；是时输出执行结果到 out 并返回 true（err 为执行错误）。
+// [web] environment（或 APP_ENV）为 production 时拒绝执行，除非指定 --force，此时不会连接数据库。
+// paths 为配置文件，规则与 LoadConfigs 相同
+//
+// 使用方式：
+//
+//	//go:embed db/seeds
+//	var seeds embed.FS
+//
+//	if ok, err := web.RunSeedCommand[AppConfig](os.Args[1:], os.Stdout, seeds, "db/seeds", "app.toml"); ok {
+//	    if err != nil {
+//	        fmt.Fprintln(os.Stderr, err)
+//	        os.Exit(1)
+//	    }
+//	    return
+//	}
+//
+//	// ./app seed
+//	// ./app seed --only=users --force
+func RunSeedCommand[T any](args []string, out io.Writer, fsys fs.FS, dir string, paths ...string) (bool, error) {
+	if len(args) == 0 || args[0] != "seed" {
+		return false, nil
+	}
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(out)
+	only := flags.String("only", "", "只执行指定的种子数据（逗号分隔）")
+	force := flags.Bool("force", false, "生产环境中也执行")
+	if err := flags.Parse(args[1:]); err != nil {
+		return true, err
+	}
+
+	if len(paths) == 0 {
+		paths = []string{"app.toml"}
+	}
+	if err := cfg.LoadConfigs[T](paths...); err != nil {
+		return true, fmt.Errorf("配置加载失败: %w", err)
+	}
+	webCfg := extractWebConfig(*cfg.MustGetCfg[T]())
+	production := IsProduction(webCfg)
+	if production && !*force {
+		return true, database.ErrSeedInProduction
+	}
+
+	opts := []database.SeedOption{database.SeedProduction(production)}
+	if *force {
+		opts = append(opts, database.SeedForce())
+	}
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts = append(opts, database.SeedOnly(name))
+		}
+	}
+
+	if webCfg.Database.Driver == "" {
+		return true, errors.New("未配置数据库 [web.database]")
+	}
+	if err := database.InitDB(webCfg.Database); err != nil {
+		return true, fmt.Errorf("连接数据库失败: %w", err)
+	}
+	defer database.Close()
+
+	report, err := database.Seed(context.Background(), database.DB, fsys, dir, opts...)
+	for _, name := range report.Applied {
+		fmt.Fprintf(out, "applied  %s\n", name)
+	}
+	for _, name := range report.Skipped {
+		fmt.Fprintf(out, "skipped  %s (已执行过)\n", name)
+	}
+	return true, err
+}
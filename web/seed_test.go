@@ -0,0 +1,44 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CenJIl/base/web/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type seedAppConfig struct {
+	AppName string `toml:"appName"`
+	Config
+}
+
+func TestRunSeedCommand(t *testing.T) {
+	seeds := fstest.MapFS{"db/seeds/010_users.sql": {Data: []byte("INSERT INTO users (name) VALUES ('demo')")}}
+	path := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(path, []byte("appName = \"shop\"\nport = 8080\n"), 0o644))
+
+	ok, err := RunSeedCommand[seedAppConfig]([]string{"config", "describe"}, io.Discard, seeds, "db/seeds", path)
+	assert.False(t, ok, "不是 seed 子命令时交给调用方")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	ok, err = RunSeedCommand[seedAppConfig]([]string{"seed", "--unknown"}, &out, seeds, "db/seeds", path)
+	assert.True(t, ok)
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), "-only")
+
+	t.Setenv("APP_ENV", "production")
+	ok, err = RunSeedCommand[seedAppConfig]([]string{"seed"}, io.Discard, seeds, "db/seeds", path)
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, database.ErrSeedInProduction, "生产环境拒绝，且不连接数据库")
+
+	ok, err = RunSeedCommand[seedAppConfig]([]string{"seed", "--force", "--only=users"}, io.Discard, seeds, "db/seeds", path)
+	assert.True(t, ok)
+	assert.ErrorContains(t, err, "未配置数据库")
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/client"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/CenJIl/base/web/signing"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 服务间认证失败原因（同时作为指标标签）
+const (
+	ServiceAuthMissing        = "missing"         // 缺少签名头
+	ServiceAuthUnknownService = "unknown_service" // 未知的调用方
+	ServiceAuthExpired        = "expired"         // 时间戳超出窗口
+	ServiceAuthBadSignature   = "bad_signature"   // 签名不匹配
+	ServiceAuthReplay         = "replay"          // nonce 重放
+)
+
+// ServiceAuthConfig 服务间认证配置
+//
+// Example:
+//
+//	[web.serviceAuth]
+//	serviceId = "billing"
+//	key = "current-secret"            # 出站签名使用的密钥
+//	window = 300                      # 时间戳允许偏差（秒）
+//	[web.serviceAuth.peers]
+//	orders = ["new-secret", "old-secret"]   # 轮换期间同时接受两个密钥
+//	[web.serviceAuth.peerKeys]
+//	inventory = "svc-inventory"             # 或引用密钥环中的密钥（支持轮换，优先于 peers）
+type ServiceAuthConfig struct {
+	ServiceID string              `toml:"serviceId"`              // 本服务 ID
+	Key       string              `toml:"key" sensitive:"true"`   // 本服务签名密钥
+	KeyName   string              `toml:"keyName"`                // 本服务签名使用的密钥环密钥名（见 client.WithKeyringSigner）
+	Window    int                 `toml:"window"`                 // 时间戳窗口（秒），默认 300
+	Peers     map[string][]string `toml:"peers" sensitive:"true"` // 允许调用本服务的服务及其有效密钥
+	PeerKeys  map[string]string   `toml:"peerKeys"`               // 允许调用本服务的服务及其密钥环密钥名
+}
+
+// KeyResolver 根据服务 ID 返回当前有效的密钥（轮换期间最多两个）
+type KeyResolver func(serviceID string) ([][]byte, bool)
+
+// StaticKeyResolver 使用配置中的 peers 作为密钥来源
+func StaticKeyResolver(peers map[string][]string) KeyResolver {
+	keys := make(map[string][][]byte, len(peers))
+	for id, secrets := range peers {
+		for _, secret := range secrets {
+			keys[id] = append(keys[id], []byte(secret))
+		}
+	}
+	return func(serviceID string) ([][]byte, bool) {
+		k, ok := keys[serviceID]
+		return k, ok
+	}
+}
+
+// ServiceAuth 服务间请求签名校验器
+type ServiceAuth struct {
+	resolver KeyResolver
+	keyring  *keyring.Keyring
+	peerKeys map[string]string // 服务 ID -> 密钥环密钥名
+	window   time.Duration
+	replay   ReplayCache
+	clock    common.Clock
+	okTotal  *metrics.Counter
+}
+
+// NewServiceAuth 创建签名校验器
+//
+// window <= 0 时默认 5 分钟；replay 为 nil 时使用 DefaultReplayCache；clock 为 nil 时使用系统时钟
+func NewServiceAuth(resolver KeyResolver, window time.Duration, replay ReplayCache, clock common.Clock) *ServiceAuth {
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	if replay == nil {
+		replay = DefaultReplayCache()
+	}
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &ServiceAuth{
+		resolver: resolver,
+		window:   window,
+		replay:   replay,
+		clock:    clock,
+		okTotal:  metrics.GetCounter("web_service_auth_total", "result", "ok"),
+	}
+}
+
+// NewServiceAuthFromConfig 按 [web.serviceAuth] 创建签名校验器
+//
+// peers 为静态密钥，peerKeys 中的调用方按默认密钥环校验（在 NewServer 初始化密钥环之后调用），
+// window 为时间戳窗口（秒，未配置时 5 分钟）；出站签名见 ServiceSigner
+//
+// 使用方式：
+//
+//	conf := cfg.MustGetCfg[AppConfig]().ServiceAuth
+//	internal := h.Group("/internal", web.NewServiceAuthFromConfig(conf).Middleware())
+func NewServiceAuthFromConfig(conf ServiceAuthConfig) *ServiceAuth {
+	s := NewServiceAuth(StaticKeyResolver(conf.Peers), time.Duration(conf.Window)*time.Second, nil, nil)
+	if len(conf.PeerKeys) > 0 {
+		s.WithKeyring(keyring.Default(), conf.PeerKeys)
+	}
+	return s
+}
+
+// ServiceSigner 按 [web.serviceAuth] 的 serviceId 为出站请求签名：配置了 keyName 时使用默认密钥环
+// （密钥轮换后自动使用新版本），否则使用 key；未配置 serviceId 时不签名
+//
+// 使用方式：
+//
+//	conf := cfg.MustGetCfg[AppConfig]().ServiceAuth
+//	orders := client.New("http://orders.internal", web.ServiceSigner(conf))
+func ServiceSigner(conf ServiceAuthConfig) client.Option {
+	switch {
+	case conf.ServiceID == "":
+		return func(*client.Client) {}
+	case conf.KeyName != "":
+		return client.WithKeyringSigner(conf.ServiceID, conf.KeyName)
+	default:
+		return client.WithServiceSigner(conf.ServiceID, []byte(conf.Key))
+	}
+}
+
+// WithKeyring 按密钥环校验 peerKeys 中的调用方（接受未退役的版本），其余调用方仍使用 KeyResolver
+//
+// 使用方式：
+//
+//	auth := web.NewServiceAuth(web.StaticKeyResolver(conf.Peers), 0, nil, nil).WithKeyring(keyring.Default(), conf.PeerKeys)
+func (s *ServiceAuth) WithKeyring(kr *keyring.Keyring, peerKeys map[string]string) *ServiceAuth {
+	s.keyring, s.peerKeys = kr, peerKeys
+	return s
+}
+
+// verify 校验签名，返回调用方服务 ID 或失败原因
+func (s *ServiceAuth) verify(ctx context.Context, c *app.RequestContext) (string, string) {
+	serviceID := string(c.GetHeader(signing.HeaderServiceID))
+	timestamp := string(c.GetHeader(signing.HeaderTimestamp))
+	nonce := string(c.GetHeader(signing.HeaderNonce))
+	signature := string(c.GetHeader(signing.HeaderSignature))
+	if serviceID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return "", ServiceAuthMissing
+	}
+
+	sign := func(keys [][]byte) bool {
+		return signing.Verify(keys, signature, string(c.Method()), string(c.Request.URI().RequestURI()), c.Request.Body(), timestamp, nonce)
+	}
+	var signed func() bool
+	if name, ok := s.peerKeys[serviceID]; ok && s.keyring != nil {
+		signed = func() bool {
+			_, ok := s.keyring.Verify(name, func(secret []byte) bool { return sign([][]byte{secret}) })
+			return ok
+		}
+	} else {
+		var keys [][]byte
+		if s.resolver != nil {
+			keys, _ = s.resolver(serviceID)
+		}
+		if len(keys) == 0 {
+			return "", ServiceAuthUnknownService
+		}
+		signed = func() bool { return sign(keys) }
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ServiceAuthExpired
+	}
+	if skew := s.clock.Now().Sub(time.Unix(ts, 0)); skew > s.window || skew < -s.window {
+		return "", ServiceAuthExpired
+	}
+
+	if !signed() {
+		return "", ServiceAuthBadSignature
+	}
+
+	// 签名通过后再记录 nonce，避免伪造请求占用 nonce
+	seen, err := s.replay.Seen(ctx, "svc:"+serviceID+":"+nonce, 2*s.window)
+	if err != nil {
+		log.Errorf("[ServiceAuth] 防重放缓存不可用: %v", err)
+		return "", ServiceAuthReplay
+	}
+	if seen {
+		return "", ServiceAuthReplay
+	}
+	return serviceID, ""
+}
+
+// Middleware 返回签名校验中间件
+func (s *ServiceAuth) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		serviceID, reason := s.verify(ctx, c)
+		if reason != "" {
+			metrics.GetCounter("web_service_auth_total", "result", reason).Inc()
+			log.Warnf("[ServiceAuth] 拒绝 %s %s: %s (service=%s)",
+				c.Method(), c.Path(), reason, c.GetHeader(signing.HeaderServiceID))
+			result := Fail(401, "Service authentication failed: "+reason)
+			result.TraceID = middleware.GetRequestID(c)
+			c.AbortWithStatusJSON(consts.StatusUnauthorized, result)
+			return
+		}
+
+		s.okTotal.Inc()
+		c.Set("calling_service", serviceID)
+		c.Next(ctx)
+	}
+}
+
+// ServiceAuthMiddleware 服务间签名校验中间件（使用默认窗口和防重放缓存；按配置创建见 NewServiceAuthFromConfig）
+//
+// 通过后可用 GetCallingService 获取调用方，用于授权判断和审计
+//
+// 使用方式：
+//
+//	internal := h.Group("/internal", web.ServiceAuthMiddleware(resolveServiceKeys))
+func ServiceAuthMiddleware(keyResolver KeyResolver) app.HandlerFunc {
+	return NewServiceAuth(keyResolver, 0, nil, nil).Middleware()
+}
+
+// GetCallingService 获取已通过签名校验的调用方服务 ID（未经服务间认证时为空）
+func GetCallingService(c *app.RequestContext) string {
+	if v, ok := c.Get("calling_service"); ok {
+		if id, ok := v.(string); ok {
+			return id
+		}
+	}
+	return ""
+}
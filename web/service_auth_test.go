@@ -0,0 +1,197 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web/client"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/signing"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// engineTransport 把 net/http 请求直接送进进程内的 Hertz 引擎
+type engineTransport struct {
+	engine *route.Engine
+}
+
+func (t engineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	var headers []ut.Header
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			headers = append(headers, ut.Header{Key: k, Value: v})
+		}
+	}
+	resp := ut.PerformRequest(t.engine, req.Method, req.URL.RequestURI(), &ut.Body{Body: bytes.NewReader(body), Len: len(body)}, headers...).Result()
+	return &http.Response{
+		StatusCode: resp.StatusCode(),
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(resp.Body())),
+		Request:    req,
+	}, nil
+}
+
+func TestServiceAuth_EndToEnd(t *testing.T) {
+	orders := route.NewEngine(config.NewOptions(nil))
+	billing := route.NewEngine(config.NewOptions(nil))
+
+	// orders 正在轮换 billing 的密钥：新旧两个都接受
+	orders.Use(ServiceAuthMiddleware(StaticKeyResolver(map[string][]string{"billing": {"billing-new", "billing-old"}})))
+	billing.Use(ServiceAuthMiddleware(StaticKeyResolver(map[string][]string{"orders": {"orders-key"}})))
+
+	orders.GET("/internal/orders/:id", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(map[string]string{"id": c.Param("id"), "caller": GetCallingService(c)}))
+	})
+
+	billingToOrders := client.New("http://orders", client.WithHTTPClient(&http.Client{Transport: engineTransport{orders}}),
+		client.WithServiceSigner("billing", []byte("billing-old")))
+	billing.POST("/internal/invoices", func(ctx context.Context, c *app.RequestContext) {
+		order, err := client.Get[map[string]string](ctx, billingToOrders, "/internal/orders/42?expand=items")
+		if err != nil {
+			c.JSON(500, Fail(500, err.Error()))
+			return
+		}
+		c.JSON(200, Success(map[string]any{"order": order, "caller": GetCallingService(c)}))
+	})
+
+	ordersToBilling := client.New("http://billing", client.WithHTTPClient(&http.Client{Transport: engineTransport{billing}}),
+		client.WithServiceSigner("orders", []byte("orders-key")))
+	got, err := client.Post[map[string]any](context.Background(), ordersToBilling, "/internal/invoices", map[string]int{"orderId": 42})
+	require.NoError(t, err)
+	assert.Equal(t, "orders", got["caller"])
+	assert.Equal(t, map[string]any{"id": "42", "caller": "billing"}, got["order"])
+
+	// 未签名的调用被拒绝
+	plain := client.New("http://billing", client.WithHTTPClient(&http.Client{Transport: engineTransport{billing}}))
+	_, err = client.Post[map[string]any](context.Background(), plain, "/internal/invoices", nil)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 401, apiErr.HTTPStatus)
+}
+
+func TestServiceAuth_FromConfig(t *testing.T) {
+	orders := route.NewEngine(config.NewOptions(nil))
+	orders.Use(NewServiceAuthFromConfig(ServiceAuthConfig{
+		Window: 60,
+		Peers:  map[string][]string{"billing": {"billing-key"}},
+	}).Middleware())
+	orders.GET("/internal/orders/:id", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(GetCallingService(c)))
+	})
+	transport := client.WithHTTPClient(&http.Client{Transport: engineTransport{orders}})
+
+	signed := client.New("http://orders", transport, ServiceSigner(ServiceAuthConfig{ServiceID: "billing", Key: "billing-key"}))
+	caller, err := client.Get[string](context.Background(), signed, "/internal/orders/42")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", caller)
+
+	var apiErr *client.APIError
+	unsigned := client.New("http://orders", transport, ServiceSigner(ServiceAuthConfig{Key: "billing-key"}))
+	_, err = client.Get[string](context.Background(), unsigned, "/internal/orders/42")
+	require.ErrorAs(t, err, &apiErr, "未配置 serviceId 时不签名")
+	assert.Equal(t, 401, apiErr.HTTPStatus)
+
+	// window = 60：两分钟前的签名超出窗口（默认 5 分钟时会通过）
+	timestamp := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	w := ut.PerformRequest(orders, "GET", "/internal/orders/42", nil,
+		ut.Header{Key: signing.HeaderServiceID, Value: "billing"},
+		ut.Header{Key: signing.HeaderTimestamp, Value: timestamp},
+		ut.Header{Key: signing.HeaderNonce, Value: "n-old"},
+		ut.Header{Key: signing.HeaderSignature, Value: signing.Sign([]byte("billing-key"), "GET", "/internal/orders/42", nil, timestamp, "n-old")},
+	)
+	assert.Equal(t, 401, w.Code)
+	assert.Contains(t, w.Body.String(), ServiceAuthExpired)
+}
+
+func TestServiceAuth_FailureReasons(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(NewServiceAuth(StaticKeyResolver(map[string][]string{"orders": {"k1"}}), time.Minute, NewMemoryReplayCache(nil), nil).Middleware())
+	engine.POST("/internal/ping", func(ctx context.Context, c *app.RequestContext) { c.JSON(200, Success(nil)) })
+
+	send := func(serviceID, key, nonce string, ts time.Time) int {
+		body := []byte(`{"ping":1}`)
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		sig := signing.Sign([]byte(key), "POST", "/internal/ping", body, timestamp, nonce)
+		return ut.PerformRequest(engine, "POST", "/internal/ping", &ut.Body{Body: bytes.NewReader(body), Len: len(body)},
+			ut.Header{Key: signing.HeaderServiceID, Value: serviceID},
+			ut.Header{Key: signing.HeaderTimestamp, Value: timestamp},
+			ut.Header{Key: signing.HeaderNonce, Value: nonce},
+			ut.Header{Key: signing.HeaderSignature, Value: sig},
+		).Result().StatusCode()
+	}
+
+	counter := func(reason string) int64 {
+		return metrics.GetCounter("web_service_auth_total", "result", reason).Value()
+	}
+	before := map[string]int64{}
+	for _, r := range []string{ServiceAuthUnknownService, ServiceAuthBadSignature, ServiceAuthReplay, ServiceAuthExpired} {
+		before[r] = counter(r)
+	}
+
+	now := time.Now()
+	assert.Equal(t, 200, send("orders", "k1", "n1", now))
+	assert.Equal(t, 401, send("orders", "k1", "n1", now))
+	assert.Equal(t, 401, send("ghost", "k1", "n2", now))
+	assert.Equal(t, 401, send("orders", "wrong", "n3", now))
+	assert.Equal(t, 401, send("orders", "k1", "n4", now.Add(-2*time.Minute)))
+
+	for _, r := range []string{ServiceAuthUnknownService, ServiceAuthBadSignature, ServiceAuthReplay, ServiceAuthExpired} {
+		assert.Equal(t, int64(1), counter(r)-before[r], r)
+	}
+}
+
+func TestServiceAuth_KeyringRotation(t *testing.T) {
+	kr, err := keyring.New(keyring.Config{Keys: map[string]keyring.KeyConfig{"svc-billing": {Accept: 2}}})
+	require.NoError(t, err)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+	keyring.SetDefault(kr)
+	t.Cleanup(func() { keyring.SetDefault(nil) })
+
+	orders := route.NewEngine(config.NewOptions(nil))
+	orders.Use(NewServiceAuth(nil, 0, NewMemoryReplayCache(nil), nil).WithKeyring(kr, map[string]string{"billing": "svc-billing"}).Middleware())
+	orders.GET("/internal/orders/:id", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(GetCallingService(c)))
+	})
+	transport := client.WithHTTPClient(&http.Client{Transport: engineTransport{orders}})
+	call := func(c *client.Client) error {
+		_, err := client.Get[string](context.Background(), c, "/internal/orders/42")
+		return err
+	}
+
+	// 还没有收到轮换通知的实例继续用旧版本签名
+	first, err := kr.Current("svc-billing")
+	require.NoError(t, err)
+	stale := client.New("http://orders", transport, client.WithServiceSigner("billing", []byte(first.Secret)))
+	current := client.New("http://orders", transport, client.WithKeyringSigner("billing", "svc-billing"))
+	require.NoError(t, call(stale))
+
+	previous := metrics.GetCounter("keyring_verify_total", "key", "svc-billing", "version", "previous")
+	before := previous.Value()
+	_, err = kr.Rotate(context.Background(), "svc-billing")
+	require.NoError(t, err)
+	require.NoError(t, call(current))
+	require.NoError(t, call(stale), "轮换窗口内旧版本仍然有效")
+	assert.Equal(t, before+1, previous.Value())
+
+	_, err = kr.Rotate(context.Background(), "svc-billing")
+	require.NoError(t, err)
+	var apiErr *client.APIError
+	require.ErrorAs(t, call(stale), &apiErr, "旧版本退役后失效")
+	assert.Equal(t, 401, apiErr.HTTPStatus)
+	require.NoError(t, call(current))
+}
@@ -0,0 +1,313 @@
+package web
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// PriorityClass 路由优先级（过载时按优先级丢弃请求）
+type PriorityClass int
+
+const (
+	PriorityNormal     PriorityClass = iota // 默认优先级
+	PriorityCritical                        // 健康检查/指标/管理接口，永不丢弃
+	PriorityBackground                      // 批处理/后台任务，最先丢弃
+)
+
+// String 返回优先级名称（用于指标标签）
+func (p PriorityClass) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "normal"
+	}
+}
+
+// SheddingConfig 过载保护配置
+//
+// capacity > 0 时启用。饱和度 = max(在途请求数/capacity, 平均延迟/latencyTarget)，
+// 饱和度达到某个优先级的阈值后开始丢弃该优先级的请求（503 + Retry-After），
+// 降到 阈值-hysteresis 以下才恢复，避免在阈值附近反复切换。
+//
+// Example:
+//
+//	[web.shedding]
+//	capacity = 200               # 最大在途请求数
+//	latencyTargetMs = 300        # 平均延迟目标（可选）
+//	backgroundThreshold = 0.8    # background 开始丢弃的饱和度
+//	normalThreshold = 1.0        # normal 开始丢弃的饱和度
+//	hysteresis = 0.1
+//	retryAfter = 1               # 秒
+//	disabled = false             # 紧急开关：true 时完全关闭丢弃，支持热更新
+//
+//	[web.shedding.adaptive]      # 按延迟自适应调整的并发上限（可选，见 AdaptiveLimitConfig）
+//	enabled = true
+type SheddingConfig struct {
+	Capacity            int     `toml:"capacity"`              // 最大在途请求数，0 表示不启用
+	LatencyTargetMs     int     `toml:"latencyTargetMs"`       // 平均延迟目标（毫秒），0 表示只看并发
+	BackgroundThreshold float64 `toml:"backgroundThreshold"`   // 默认 0.8
+	NormalThreshold     float64 `toml:"normalThreshold"`       // 默认 1.0
+	Hysteresis          float64 `toml:"hysteresis"`            // 默认 0.1
+	RetryAfter          int     `toml:"retryAfter"`            // Retry-After 秒数，默认 1
+	Disabled            bool    `toml:"disabled" reload:"hot"` // 紧急开关（支持热更新）
+
+	Adaptive AdaptiveLimitConfig `toml:"adaptive"` // 自适应并发上限（capacity 为 0 时也可单独启用）
+}
+
+// Shedder 过载保护器
+//
+// 热路径只有原子操作，不加锁
+type Shedder struct {
+	config        SheddingConfig
+	thresholds    [3]float64
+	minThreshold  float64 // 低于 minThreshold-hysteresis 时无需识别优先级
+	disabled      atomic.Bool
+	inflight      atomic.Int64
+	latencyBits   atomic.Uint64 // 延迟 EWMA（毫秒，float64 bits）
+	shedding      [3]atomic.Bool
+	shedCounters  [3]*metrics.Counter
+	inflightGauge *metrics.Gauge
+	slowStart     atomic.Pointer[SlowStart] // 新实例爬坡期间的并发上限（可选）
+	adaptive      [3]*adaptiveLimiter       // 按优先级的自适应并发上限（可选，critical 为 nil）
+}
+
+// NewShedder 创建过载保护器（零值字段使用默认值）
+func NewShedder(config SheddingConfig) *Shedder {
+	if config.BackgroundThreshold <= 0 {
+		config.BackgroundThreshold = 0.8
+	}
+	if config.NormalThreshold <= 0 {
+		config.NormalThreshold = 1.0
+	}
+	if config.Hysteresis <= 0 {
+		config.Hysteresis = 0.1
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 1
+	}
+
+	s := &Shedder{config: config, inflightGauge: metrics.GetGauge("web_inflight_requests")}
+	s.thresholds[PriorityNormal] = config.NormalThreshold
+	s.thresholds[PriorityCritical] = math.Inf(1)
+	s.thresholds[PriorityBackground] = config.BackgroundThreshold
+	for _, class := range []PriorityClass{PriorityNormal, PriorityCritical, PriorityBackground} {
+		s.shedCounters[class] = metrics.GetCounter("web_shed_total", "class", class.String())
+	}
+	s.minThreshold = math.Min(config.BackgroundThreshold, config.NormalThreshold)
+	s.disabled.Store(config.Disabled)
+	if config.Adaptive.Enabled {
+		adaptive := withAdaptiveDefaults(config.Adaptive, config.Capacity)
+		s.adaptive[PriorityNormal] = newAdaptiveLimiter(PriorityNormal, adaptive)
+		s.adaptive[PriorityBackground] = newAdaptiveLimiter(PriorityBackground, adaptive)
+	}
+	return s
+}
+
+// SetDisabled 紧急开关：关闭/恢复请求丢弃
+func (s *Shedder) SetDisabled(disabled bool) {
+	s.disabled.Store(disabled)
+}
+
+// SetSlowStart 爬坡期间把在途请求上限限制为慢启动给出的值（critical 路由不受限制）
+func (s *Shedder) SetSlowStart(slowStart *SlowStart) {
+	s.slowStart.Store(slowStart)
+}
+
+// Saturation 当前饱和度（只启用自适应并发上限、没有配置 capacity 时为 0）
+func (s *Shedder) Saturation() float64 {
+	var util float64
+	if s.config.Capacity > 0 {
+		util = float64(s.inflight.Load()) / float64(s.config.Capacity)
+	}
+	if s.config.LatencyTargetMs > 0 {
+		if lat := math.Float64frombits(s.latencyBits.Load()) / float64(s.config.LatencyTargetMs); lat > util {
+			util = lat
+		}
+	}
+	return util
+}
+
+// shouldShed 判断是否丢弃该优先级的请求（带滞后）
+func (s *Shedder) shouldShed(class PriorityClass, util float64) bool {
+	threshold := s.thresholds[class]
+	state := &s.shedding[class]
+	if util >= threshold {
+		state.Store(true)
+		return true
+	}
+	if util < threshold-s.config.Hysteresis {
+		state.Store(false)
+		return false
+	}
+	return state.Load()
+}
+
+// resetShedding 饱和度已回落，清除所有优先级的丢弃状态（先读后写，避免热路径上的写竞争）
+func (s *Shedder) resetShedding() {
+	for i := range s.shedding {
+		if s.shedding[i].Load() {
+			s.shedding[i].Store(false)
+		}
+	}
+}
+
+// observeLatency 更新延迟 EWMA（alpha = 0.1）
+func (s *Shedder) observeLatency(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	for {
+		old := s.latencyBits.Load()
+		prev := math.Float64frombits(old)
+		next := ms
+		if old != 0 {
+			next = prev*0.9 + ms*0.1
+		}
+		if s.latencyBits.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// Middleware 返回过载保护中间件
+func (s *Shedder) Middleware() app.HandlerFunc {
+	retryAfter := strconv.Itoa(s.config.RetryAfter)
+	return func(ctx context.Context, c *app.RequestContext) {
+		if ss := s.slowStart.Load(); ss != nil && !s.disabled.Load() {
+			if limit, ramping := ss.Limit(); ramping && s.inflight.Load() >= int64(limit) && routePriority(c) != PriorityCritical {
+				ss.rejected.Inc()
+				rejectOverloaded(c, strconv.Itoa(ss.config.RetryAfter))
+				return
+			}
+		}
+		if util := s.Saturation(); !s.disabled.Load() && util >= s.minThreshold-s.config.Hysteresis {
+			class := routePriority(c)
+			if s.shouldShed(class, util) {
+				s.shedCounters[class].Inc()
+				rejectOverloaded(c, retryAfter)
+				return
+			}
+		} else {
+			s.resetShedding()
+		}
+		var limiter *adaptiveLimiter
+		if s.adaptive[PriorityNormal] != nil && !s.disabled.Load() {
+			if limiter = s.adaptive[routePriority(c)]; limiter != nil && !limiter.acquire() {
+				rejectOverloaded(c, retryAfter)
+				return
+			}
+		}
+
+		s.inflightGauge.Set(float64(s.inflight.Add(1)))
+		start := time.Now()
+		var classInflight int64
+		if limiter != nil {
+			classInflight = limiter.inflight.Load()
+		}
+		defer func() {
+			elapsed := time.Since(start)
+			s.observeLatency(elapsed)
+			s.inflightGauge.Set(float64(s.inflight.Add(-1)))
+			if limiter != nil {
+				limiter.release(elapsed, classInflight)
+			}
+		}()
+		c.Next(ctx)
+	}
+}
+
+// rejectOverloaded 写入 503 + Retry-After 响应
+func rejectOverloaded(c *app.RequestContext, retryAfter string) {
+	result := Fail(503, MsgOverloaded)
+	result.TraceID = middleware.GetRequestID(c)
+	c.Header("Retry-After", retryAfter)
+	c.AbortWithStatusJSON(consts.StatusServiceUnavailable, result)
+}
+
+// 优先级标记处理器：各自独立的函数，通过函数指针识别路由优先级
+func priorityCriticalMarker(ctx context.Context, c *app.RequestContext)   { c.Next(ctx) }
+func priorityBackgroundMarker(ctx context.Context, c *app.RequestContext) { c.Next(ctx) }
+
+var (
+	criticalMarkerPtr   = reflect.ValueOf(app.HandlerFunc(priorityCriticalMarker)).Pointer()
+	backgroundMarkerPtr = reflect.ValueOf(app.HandlerFunc(priorityBackgroundMarker)).Pointer()
+)
+
+// Priority 声明路由或路由组的优先级
+//
+// 放在路由的处理器链中，过载保护中间件在执行前通过它识别优先级；
+// 未声明的路由为 PriorityNormal
+//
+// 使用方式：
+//
+//	h.GET("/health", web.Priority(web.PriorityCritical), healthHandler)
+//	batch := h.Group("/batch", web.Priority(web.PriorityBackground))
+func Priority(class PriorityClass) app.HandlerFunc {
+	switch class {
+	case PriorityCritical:
+		return priorityCriticalMarker
+	case PriorityBackground:
+		return priorityBackgroundMarker
+	default:
+		return func(ctx context.Context, c *app.RequestContext) { c.Next(ctx) }
+	}
+}
+
+// routePriority 从处理器链中查找优先级标记
+func routePriority(c *app.RequestContext) PriorityClass {
+	for _, h := range c.Handlers() {
+		switch reflect.ValueOf(h).Pointer() {
+		case criticalMarkerPtr:
+			return PriorityCritical
+		case backgroundMarkerPtr:
+			return PriorityBackground
+		}
+	}
+	return PriorityNormal
+}
+
+var (
+	globalShedder *Shedder
+)
+
+// InitShedding 初始化全局过载保护器
+func InitShedding(config SheddingConfig) {
+	globalShedder = NewShedder(config)
+	if s := globalSlowStart.Load(); s != nil {
+		globalShedder.SetSlowStart(s)
+	}
+	log.Infof("[Shedding] 过载保护已启用: capacity %d, background %.2f, normal %.2f",
+		config.Capacity, globalShedder.config.BackgroundThreshold, globalShedder.config.NormalThreshold)
+	if l := globalShedder.adaptive[PriorityNormal]; l != nil {
+		log.Infof("[Shedding] 自适应并发上限已启用: 初始 %d, 范围 [%d, %d]",
+			l.config.InitialLimit, l.config.MinLimit, l.config.MaxLimit)
+	}
+}
+
+// SetSheddingDisabled 全局过载保护紧急开关
+func SetSheddingDisabled(disabled bool) {
+	if globalShedder != nil {
+		globalShedder.SetDisabled(disabled)
+		log.Warnf("[Shedding] 紧急开关: disabled=%v", disabled)
+	}
+}
+
+// SheddingMiddleware 全局过载保护中间件（未初始化时直接放行）
+func SheddingMiddleware() app.HandlerFunc {
+	if globalShedder == nil {
+		return func(ctx context.Context, c *app.RequestContext) {
+			c.Next(ctx)
+		}
+	}
+	return globalShedder.Middleware()
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShedder_Hysteresis(t *testing.T) {
+	s := NewShedder(SheddingConfig{Capacity: 10, BackgroundThreshold: 0.5, NormalThreshold: 1.0, Hysteresis: 0.2})
+
+	assert.False(t, s.shouldShed(PriorityBackground, 0.4))
+	assert.True(t, s.shouldShed(PriorityBackground, 0.5))
+	// 仍在滞后区间内，保持丢弃状态
+	assert.True(t, s.shouldShed(PriorityBackground, 0.35))
+	assert.False(t, s.shouldShed(PriorityBackground, 0.29))
+
+	assert.False(t, s.shouldShed(PriorityNormal, 0.9))
+	assert.True(t, s.shouldShed(PriorityNormal, 1.2))
+	assert.False(t, s.shouldShed(PriorityCritical, 100))
+}
+
+func TestShedder_Priority(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	var got PriorityClass
+	probe := func(ctx context.Context, c *app.RequestContext) { got = routePriority(c) }
+
+	engine.GET("/health", Priority(PriorityCritical), probe)
+	engine.Group("/batch", Priority(PriorityBackground)).GET("/job", probe)
+	engine.GET("/api", probe)
+
+	ut.PerformRequest(engine, "GET", "/health", nil)
+	assert.Equal(t, PriorityCritical, got)
+	ut.PerformRequest(engine, "GET", "/batch/job", nil)
+	assert.Equal(t, PriorityBackground, got)
+	ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, PriorityNormal, got)
+}
+
+// TestShedder_LoadDoubleCapacity 2 倍容量的并发下，critical 全部成功，丢弃全部落在 background
+func TestShedder_LoadDoubleCapacity(t *testing.T) {
+	const capacity = 10
+	s := NewShedder(SheddingConfig{Capacity: capacity, BackgroundThreshold: 0.5, NormalThreshold: 1.0})
+	shedBefore := s.shedCounters[PriorityBackground].Value()
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(s.Middleware())
+
+	release := make(chan struct{})
+	var entered atomic.Int64
+	block := func(ctx context.Context, c *app.RequestContext) {
+		entered.Add(1)
+		<-release
+		c.JSON(200, Success(nil))
+	}
+	engine.GET("/health", Priority(PriorityCritical), block)
+	engine.GET("/batch", Priority(PriorityBackground), block)
+
+	var (
+		wg       sync.WaitGroup
+		returned atomic.Int64
+		mu       sync.Mutex
+		status   = map[string][]int{}
+	)
+	for i := 0; i < capacity*2; i++ {
+		path := "/batch"
+		if i%2 == 0 {
+			path = "/health"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := ut.PerformRequest(engine, "GET", path, nil).Result().StatusCode()
+			returned.Add(1)
+			mu.Lock()
+			status[path] = append(status[path], code)
+			mu.Unlock()
+		}()
+	}
+
+	// 所有请求要么进入处理器，要么已被拒绝
+	assert.Eventually(t, func() bool { return entered.Load()+returned.Load() == capacity*2 }, 2*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, code := range status["/health"] {
+		assert.Equal(t, 200, code)
+	}
+	rejected := 0
+	for _, code := range status["/batch"] {
+		if code == 503 {
+			rejected++
+		}
+	}
+	assert.GreaterOrEqual(t, rejected, capacity/2)
+	assert.Equal(t, int64(rejected), s.shedCounters[PriorityBackground].Value()-shedBefore)
+}
+
+func TestShedder_EmergencySwitch(t *testing.T) {
+	s := NewShedder(SheddingConfig{Capacity: 1, BackgroundThreshold: 0.1})
+	s.inflight.Store(10)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(s.Middleware())
+	engine.GET("/batch", Priority(PriorityBackground), func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, Success(nil))
+	})
+
+	w := ut.PerformRequest(engine, "GET", "/batch", nil)
+	assert.Equal(t, 503, w.Result().StatusCode())
+	assert.Equal(t, "1", string(w.Result().Header.Peek("Retry-After")))
+
+	s.SetDisabled(true)
+	w = ut.PerformRequest(engine, "GET", "/batch", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+}
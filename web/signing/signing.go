@@ -0,0 +1,58 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// 服务间调用签名请求头
+const (
+	HeaderServiceID = "X-Service-ID"        // 调用方服务 ID
+	HeaderTimestamp = "X-Service-Timestamp" // unix 秒
+	HeaderNonce     = "X-Service-Nonce"     // 一次性随机串
+	HeaderSignature = "X-Service-Signature" // hex(HMAC-SHA256)
+)
+
+// StringToSign 生成待签名字符串
+//
+// 格式：METHOD\nREQUEST_URI\nhex(sha256(body))\nTIMESTAMP\nNONCE
+func StringToSign(method, requestURI string, body []byte, timestamp, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		hex.EncodeToString(bodyHash[:]),
+		timestamp,
+		nonce,
+	}, "\n")
+}
+
+// Sign 计算请求签名
+//
+// 使用方式：
+//
+//	sig := signing.Sign(key, "POST", "/internal/orders?id=1", body, ts, nonce)
+func Sign(key []byte, method, requestURI string, body []byte, timestamp, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(StringToSign(method, requestURI, body, timestamp, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 常量时间比较签名，依次尝试每个有效密钥（支持密钥轮换）
+func Verify(keys [][]byte, signature, method, requestURI string, body []byte, timestamp, nonce string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	payload := []byte(StringToSign(method, requestURI, body, timestamp, nonce))
+	for _, key := range keys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(payload)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,445 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// ComponentSLO SLO 评估组件名
+const ComponentSLO = "slo"
+
+// SLOConfig SLO 配置
+//
+// 路由上用 web.SLO 声明默认目标，这里的 routes 按 "METHOD /path" 覆盖阈值和目标，支持热更新
+// （只替换阈值，已累计的计数不会清零）
+//
+// Example:
+//
+//	[web.slo]
+//	evalInterval = 10                     # 评估间隔（秒），默认 10
+//	[web.slo.routes."GET /api/orders"]
+//	thresholdMs = 300
+//	objective = 0.99
+type SLOConfig struct {
+	EvalInterval int                       `toml:"evalInterval"`        // 评估间隔（秒）
+	Routes       map[string]SLORouteConfig `toml:"routes" reload:"hot"` // 按路由覆盖（支持热更新）
+}
+
+// SLORouteConfig 单条路由的 SLO 覆盖
+type SLORouteConfig struct {
+	ThresholdMs int     `toml:"thresholdMs"` // 响应时间阈值（毫秒），0 表示不覆盖
+	Objective   float64 `toml:"objective"`   // 达标比例（如 0.99），0 表示不覆盖
+}
+
+// BurnRateRule 多窗口燃烧率告警规则
+//
+// 长窗口和短窗口的燃烧率都达到 Factor 时触发：长窗口保证消耗足够显著，短窗口保证问题仍在发生
+type BurnRateRule struct {
+	Long   time.Duration
+	Short  time.Duration
+	Factor float64
+}
+
+// DefaultBurnRateRules 默认告警规则（1 小时消耗 2% 预算 / 6 小时消耗 5% 预算）
+var DefaultBurnRateRules = []BurnRateRule{
+	{Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4},
+	{Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 6},
+}
+
+// SLOAlertHook 燃烧率告警回调（window 为触发规则的长窗口）
+type SLOAlertHook func(route string, burnRate float64, window time.Duration)
+
+// SLOStatus 路由的当前 SLO 状态
+type SLOStatus struct {
+	Route       string             `json:"route"`
+	ThresholdMs int64              `json:"thresholdMs"`
+	Objective   float64            `json:"objective"`
+	Good        int64              `json:"good"`
+	Bad         int64              `json:"bad"`
+	BurnRates   map[string]float64 `json:"burnRates"` // 窗口 -> 燃烧率
+	Alerting    bool               `json:"alerting"`
+}
+
+// sloSample 某一时刻的累计计数
+type sloSample struct {
+	at        time.Time
+	good, bad int64
+}
+
+// sloRoute 单条路由的 SLO 状态（热路径只读阈值并递增计数）
+type sloRoute struct {
+	method, path string
+	key          string
+	threshold    atomic.Int64  // 纳秒
+	objective    atomic.Uint64 // float64 bits
+	good, bad    *metrics.Counter
+
+	// 以下字段只在评估时访问（由 SLOTracker.mu 保护）
+	samples []sloSample
+	firing  []bool
+	burn    map[string]float64
+}
+
+func (r *sloRoute) objectiveValue() float64 {
+	return math.Float64frombits(r.objective.Load())
+}
+
+// SLOTracker 路由 SLO 统计与燃烧率评估
+type SLOTracker struct {
+	clock common.Clock
+	rules []BurnRateRule
+
+	mu        sync.Mutex
+	routes    map[string]*sloRoute
+	order     []*sloRoute
+	overrides map[string]SLORouteConfig
+	hooks     []SLOAlertHook
+}
+
+// NewSLOTracker 创建 SLO 统计器（rules 为空时使用 DefaultBurnRateRules；clock 为 nil 时使用系统时钟）
+func NewSLOTracker(clock common.Clock, rules ...BurnRateRule) *SLOTracker {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	if len(rules) == 0 {
+		rules = DefaultBurnRateRules
+	}
+	return &SLOTracker{clock: clock, rules: rules, routes: make(map[string]*sloRoute)}
+}
+
+// OnAlert 注册告警回调
+func (t *SLOTracker) OnAlert(hook SLOAlertHook) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hooks = append(t.hooks, hook)
+}
+
+// SetOverrides 替换按路由的阈值覆盖（热更新；计数和历史保持不变）
+func (t *SLOTracker) SetOverrides(overrides map[string]SLORouteConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.overrides = overrides
+	for _, r := range t.order {
+		if o, ok := overrides[r.key]; ok {
+			applySLOOverride(r, o)
+		}
+	}
+}
+
+func applySLOOverride(r *sloRoute, o SLORouteConfig) {
+	if o.ThresholdMs > 0 {
+		r.threshold.Store(int64(time.Duration(o.ThresholdMs) * time.Millisecond))
+	}
+	if o.Objective > 0 && o.Objective < 1 {
+		r.objective.Store(math.Float64bits(o.Objective))
+	}
+}
+
+// route 获取（不存在则创建）路由状态
+func (t *SLOTracker) route(method, path string, threshold time.Duration, objective float64) *sloRoute {
+	key := method + " " + path
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.routes[key]; ok {
+		return r
+	}
+	r := &sloRoute{
+		method: method,
+		path:   path,
+		key:    key,
+		good:   metrics.GetCounter("web_slo_requests_total", "route", key, "result", "good"),
+		bad:    metrics.GetCounter("web_slo_requests_total", "route", key, "result", "bad"),
+		firing: make([]bool, len(t.rules)),
+	}
+	r.samples = []sloSample{{at: t.clock.Now(), good: r.good.Value(), bad: r.bad.Value()}}
+	r.threshold.Store(int64(threshold))
+	r.objective.Store(math.Float64bits(objective))
+	if o, ok := t.overrides[key]; ok {
+		applySLOOverride(r, o)
+	}
+	t.routes[key] = r
+	t.order = append(t.order, r)
+	return r
+}
+
+// Middleware 返回声明路由 SLO 的处理器（objective 取值 (0, 1)，如 0.99）
+func (t *SLOTracker) Middleware(threshold time.Duration, objective float64) app.HandlerFunc {
+	if objective <= 0 || objective >= 1 {
+		panic(fmt.Errorf("SLO 目标必须在 (0, 1) 之间: %v", objective))
+	}
+	var last atomic.Pointer[sloRoute]
+	return func(ctx context.Context, c *app.RequestContext) {
+		r := last.Load()
+		if r == nil || r.path != c.FullPath() || r.method != string(c.Method()) {
+			r = t.route(string(c.Method()), c.FullPath(), threshold, objective)
+			last.Store(r)
+		}
+		start := t.clock.Now()
+		c.Next(ctx)
+		if middleware.IsClientAborted(c) {
+			return // 客户端中途断开（499）不计入达标与否
+		}
+		if t.clock.Now().Sub(start) <= time.Duration(r.threshold.Load()) && c.Response.StatusCode() < consts.StatusInternalServerError {
+			r.good.Inc()
+		} else {
+			r.bad.Inc()
+		}
+	}
+}
+
+// Evaluate 记录当前计数并计算燃烧率，满足多窗口条件时调用告警回调
+//
+// 同一条规则持续满足条件时只告警一次，恢复后再次满足才会重新告警
+func (t *SLOTracker) Evaluate() {
+	now := t.clock.Now()
+	var longest time.Duration
+	for _, rule := range t.rules {
+		longest = max(longest, rule.Long)
+	}
+
+	type alert struct {
+		route  string
+		burn   float64
+		window time.Duration
+	}
+	var alerts []alert
+
+	t.mu.Lock()
+	hooks := t.hooks
+	for _, r := range t.order {
+		r.samples = append(r.samples, sloSample{at: now, good: r.good.Value(), bad: r.bad.Value()})
+		// 保留覆盖最长窗口所需的样本（窗口起点之前的最后一个样本作为基线）
+		for len(r.samples) > 2 && !r.samples[1].at.After(now.Add(-longest)) {
+			r.samples = r.samples[1:]
+		}
+
+		objective := r.objectiveValue()
+		r.burn = make(map[string]float64, 2*len(t.rules))
+		for i, rule := range t.rules {
+			long := burnRate(r.samples, now, rule.Long, objective)
+			short := burnRate(r.samples, now, rule.Short, objective)
+			r.burn[rule.Long.String()] = long
+			r.burn[rule.Short.String()] = short
+			metrics.GetGauge("web_slo_burn_rate", "route", r.key, "window", rule.Long.String()).Set(long)
+			metrics.GetGauge("web_slo_burn_rate", "route", r.key, "window", rule.Short.String()).Set(short)
+
+			triggered := long >= rule.Factor && short >= rule.Factor
+			if triggered && !r.firing[i] {
+				alerts = append(alerts, alert{route: r.key, burn: long, window: rule.Long})
+			}
+			r.firing[i] = triggered
+		}
+	}
+	t.mu.Unlock()
+
+	for _, a := range alerts {
+		log.Warnf("[SLO] %s 燃烧率 %.1f（%v 窗口），错误预算消耗过快", a.route, a.burn, a.window)
+		for _, hook := range hooks {
+			hook(a.route, a.burn, a.window)
+		}
+	}
+}
+
+// burnRate 窗口内的错误率 / 错误预算（历史不足窗口长度时使用已有的全部样本）
+func burnRate(samples []sloSample, now time.Time, window time.Duration, objective float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	end := samples[len(samples)-1]
+	base := samples[0]
+	cutoff := now.Add(-window)
+	for _, s := range samples {
+		if s.at.After(cutoff) {
+			break
+		}
+		base = s
+	}
+	total := (end.good - base.good) + (end.bad - base.bad)
+	if total <= 0 {
+		return 0
+	}
+	return float64(end.bad-base.bad) / float64(total) / (1 - objective)
+}
+
+// Status 返回所有路由的当前 SLO 状态（按路由排序）
+func (t *SLOTracker) Status() []SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]SLOStatus, 0, len(t.order))
+	for _, r := range t.order {
+		s := SLOStatus{
+			Route:       r.key,
+			ThresholdMs: time.Duration(r.threshold.Load()).Milliseconds(),
+			Objective:   r.objectiveValue(),
+			Good:        r.good.Value(),
+			Bad:         r.bad.Value(),
+			BurnRates:   r.burn,
+		}
+		for _, f := range r.firing {
+			s.Alerting = s.Alerting || f
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// Run 按间隔执行评估，直到 ctx 取消
+func (t *SLOTracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Evaluate()
+		}
+	}
+}
+
+// sloTracker 全局 SLO 统计器
+var sloTracker = NewSLOTracker(nil)
+
+// SLO 声明路由的响应时间目标（objective 比例的请求在 threshold 内完成且不返回 5xx）
+//
+// 放在路由的处理器链中；每个请求只有一次比较和一次计数递增。
+// 阈值可通过 [web.slo.routes] 覆盖并热更新，燃烧率告警见 OnSLOAlert
+//
+// 使用方式：
+//
+//	h.GET("/api/orders", web.SLO(300*time.Millisecond, 0.99), listOrders)
+//	web.OnSLOAlert(web.EmailSLOAlert(mail, "oncall@example.com"))
+func SLO(threshold time.Duration, objective float64) app.HandlerFunc {
+	return sloTracker.Middleware(threshold, objective)
+}
+
+// OnSLOAlert 注册全局燃烧率告警回调
+func OnSLOAlert(hook SLOAlertHook) {
+	sloTracker.OnAlert(hook)
+}
+
+// SLOStatuses 全局 SLO 状态
+func SLOStatuses() []SLOStatus {
+	return sloTracker.Status()
+}
+
+// DebugSLOHandler 输出所有路由的 SLO 状态
+//
+// 使用方式：
+//
+//	h.GET("/debug/slo", web.DebugSLOHandler())
+func DebugSLOHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, Success(SLOStatuses()))
+	}
+}
+
+// sloComponent 评估循环组件（间隔默认 10 秒）
+func sloComponent(config SLOConfig) Component {
+	interval := time.Duration(config.EvalInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return Component{
+		Name: ComponentSLO,
+		Start: func(ctx context.Context) error {
+			sloTracker.SetOverrides(config.Routes)
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				sloTracker.Run(runCtx, interval)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// SLOMailer 邮件发送接口（email.QQMail 满足此接口）
+type SLOMailer interface {
+	Send(to []string, subject, body string) error
+}
+
+// EmailSLOAlert 通过邮件发送燃烧率告警（异步发送，失败只记录日志）
+//
+// 使用方式：
+//
+//	web.OnSLOAlert(web.EmailSLOAlert(email.NewQQMail(from, code), "oncall@example.com"))
+func EmailSLOAlert(mailer SLOMailer, to ...string) SLOAlertHook {
+	return func(route string, burnRate float64, window time.Duration) {
+		subject := fmt.Sprintf("[SLO] %s 错误预算消耗过快", route)
+		body := fmt.Sprintf("路由: %s\n燃烧率: %.2f\n窗口: %v\n时间: %s",
+			route, burnRate, window, time.Now().Format(time.RFC3339))
+		go func() {
+			if err := mailer.Send(to, subject, body); err != nil {
+				log.Errorf("[SLO] 告警邮件发送失败: %v", err)
+			}
+		}()
+	}
+}
+
+// SLOWebhookPayload Webhook 告警请求体
+type SLOWebhookPayload struct {
+	Route         string  `json:"route"`
+	BurnRate      float64 `json:"burnRate"`
+	WindowSeconds int64   `json:"windowSeconds"`
+	Time          int64   `json:"time"` // Unix 秒
+}
+
+// WebhookSLOAlert 以 JSON POST 到 url 发送燃烧率告警（异步发送，5 秒超时，失败只记录日志）
+//
+// 使用方式：
+//
+//	web.OnSLOAlert(web.WebhookSLOAlert("https://hooks.example.com/slo"))
+func WebhookSLOAlert(url string) SLOAlertHook {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(route string, burnRate float64, window time.Duration) {
+		body, _ := json.Marshal(SLOWebhookPayload{
+			Route:         route,
+			BurnRate:      burnRate,
+			WindowSeconds: int64(window / time.Second),
+			Time:          time.Now().Unix(),
+		})
+		go func() {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				log.Errorf("[SLO] 告警 Webhook 发送失败: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Errorf("[SLO] 告警 Webhook 返回 %d", resp.StatusCode)
+			}
+		}()
+	}
+}
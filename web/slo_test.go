@@ -0,0 +1,192 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sloAlert 记录的告警
+type sloAlert struct {
+	route  string
+	burn   float64
+	window time.Duration
+}
+
+// sloHarness 用假时钟驱动的 SLO 流量模拟
+type sloHarness struct {
+	clock   *common.FakeClock
+	tracker *SLOTracker
+	engine  *route.Engine
+	latency time.Duration
+	status  int
+	alerts  []sloAlert
+}
+
+func newSLOHarness(path string) *sloHarness {
+	h := &sloHarness{clock: common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)), status: 200}
+	h.tracker = NewSLOTracker(h.clock)
+	h.tracker.OnAlert(func(route string, burnRate float64, window time.Duration) {
+		h.alerts = append(h.alerts, sloAlert{route, burnRate, window})
+	})
+	h.engine = route.NewEngine(config.NewOptions(nil))
+	h.engine.GET(path, h.tracker.Middleware(300*time.Millisecond, 0.99), func(ctx context.Context, c *app.RequestContext) {
+		h.clock.Advance(h.latency)
+		c.SetStatusCode(h.status)
+	})
+	return h
+}
+
+// run 以每分钟 perMinute 个请求运行 minutes 分钟，其中 slowEvery 个请求中有一个超出阈值（0 表示全部达标）
+func (h *sloHarness) run(path string, minutes, perMinute, slowEvery int) {
+	n := 0
+	for m := 0; m < minutes; m++ {
+		for i := 0; i < perMinute; i++ {
+			n++
+			h.latency = 50 * time.Millisecond
+			if slowEvery > 0 && n%slowEvery == 0 {
+				h.latency = time.Second
+			}
+			ut.PerformRequest(h.engine, "GET", path, nil)
+		}
+		h.clock.Advance(time.Minute)
+		h.tracker.Evaluate()
+	}
+}
+
+func TestSLO_HealthyTrafficDoesNotAlert(t *testing.T) {
+	h := newSLOHarness("/slo/healthy")
+	// 0.5% 超时：错误预算消耗速度为 0.5，低于任何告警阈值
+	h.run("/slo/healthy", 7*60, 20, 200)
+	assert.Empty(t, h.alerts)
+
+	status := h.tracker.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, "GET /slo/healthy", status[0].Route)
+	assert.Equal(t, int64(300), status[0].ThresholdMs)
+	assert.InDelta(t, 0.5, status[0].BurnRates["1h0m0s"], 0.05)
+	assert.False(t, status[0].Alerting)
+}
+
+func TestSLO_BreachAlertsOnceAndRecovers(t *testing.T) {
+	h := newSLOHarness("/slo/breach")
+	h.run("/slo/breach", 60, 20, 0)
+	assert.Empty(t, h.alerts)
+
+	// 短暂尖峰：短窗口燃烧率很高，但长窗口消耗不足，不告警
+	h.run("/slo/breach", 3, 20, 2)
+	assert.Empty(t, h.alerts)
+
+	// 持续 50% 超时：两个窗口都超过 14.4 后告警，持续期间不重复告警
+	h.run("/slo/breach", 40, 20, 2)
+	require.NotEmpty(t, h.alerts)
+	fast := 0
+	for _, a := range h.alerts {
+		assert.Equal(t, "GET /slo/breach", a.route)
+		if a.window == time.Hour {
+			fast++
+			assert.GreaterOrEqual(t, a.burn, 14.4)
+		}
+	}
+	assert.Equal(t, 1, fast)
+	assert.True(t, h.tracker.Status()[0].Alerting)
+
+	// 恢复：所有规则的短窗口回落后解除，再次违约时重新告警
+	h.run("/slo/breach", 35, 20, 0)
+	assert.False(t, h.tracker.Status()[0].Alerting)
+	before := len(h.alerts)
+	h.run("/slo/breach", 10, 20, 2)
+	assert.Greater(t, len(h.alerts), before)
+}
+
+func TestSLO_ServerErrorsCountAsBad(t *testing.T) {
+	h := newSLOHarness("/slo/errors")
+	h.status = 500
+	ut.PerformRequest(h.engine, "GET", "/slo/errors", nil)
+	h.status = 404
+	ut.PerformRequest(h.engine, "GET", "/slo/errors", nil)
+
+	status := h.tracker.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, int64(1), status[0].Good)
+	assert.Equal(t, int64(1), status[0].Bad)
+}
+
+func TestSLO_OverrideKeepsHistory(t *testing.T) {
+	h := newSLOHarness("/slo/reload")
+	h.latency = 500 * time.Millisecond
+	ut.PerformRequest(h.engine, "GET", "/slo/reload", nil)
+	assert.Equal(t, int64(1), h.tracker.Status()[0].Bad)
+
+	// 热更新放宽阈值：之前的计数保留，之后的 500ms 请求达标
+	h.tracker.SetOverrides(map[string]SLORouteConfig{"GET /slo/reload": {ThresholdMs: 1000, Objective: 0.95}})
+	ut.PerformRequest(h.engine, "GET", "/slo/reload", nil)
+	status := h.tracker.Status()[0]
+	assert.Equal(t, int64(1000), status.ThresholdMs)
+	assert.Equal(t, 0.95, status.Objective)
+	assert.Equal(t, int64(1), status.Good)
+	assert.Equal(t, int64(1), status.Bad)
+
+	// 覆盖对之后才出现流量的路由同样生效
+	h.engine.GET("/slo/later", h.tracker.Middleware(time.Second, 0.99), func(ctx context.Context, c *app.RequestContext) {})
+	h.tracker.SetOverrides(map[string]SLORouteConfig{"GET /slo/later": {ThresholdMs: 5}})
+	ut.PerformRequest(h.engine, "GET", "/slo/later", nil)
+	for _, s := range h.tracker.Status() {
+		if s.Route == "GET /slo/later" {
+			assert.Equal(t, int64(5), s.ThresholdMs)
+		}
+	}
+
+	assert.Panics(t, func() { h.tracker.Middleware(time.Second, 1) })
+}
+
+// fakeMailer 记录发送的邮件
+type fakeMailer struct {
+	sent chan string
+}
+
+func (m *fakeMailer) Send(to []string, subject, body string) error {
+	m.sent <- to[0] + "|" + subject
+	return nil
+}
+
+func TestSLO_BuiltinHooks(t *testing.T) {
+	mailer := &fakeMailer{sent: make(chan string, 1)}
+	EmailSLOAlert(mailer, "oncall@example.com")("GET /api/orders", 20, time.Hour)
+	select {
+	case got := <-mailer.sent:
+		assert.Equal(t, "oncall@example.com|[SLO] GET /api/orders 错误预算消耗过快", got)
+	case <-time.After(time.Second):
+		t.Fatal("mail not sent")
+	}
+
+	received := make(chan SLOWebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p SLOWebhookPayload
+		_ = json.Unmarshal(body, &p)
+		received <- p
+	}))
+	defer server.Close()
+	WebhookSLOAlert(server.URL)("GET /api/orders", 20, time.Hour)
+	select {
+	case p := <-received:
+		assert.Equal(t, "GET /api/orders", p.Route)
+		assert.Equal(t, 20.0, p.BurnRate)
+		assert.Equal(t, int64(3600), p.WindowSeconds)
+	case <-time.After(time.Second):
+		t.Fatal("webhook not called")
+	}
+}
@@ -0,0 +1,263 @@
+package web
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// 慢启动爬坡曲线
+const (
+	SlowStartLinear      = "linear"      // 权重随时间线性增长
+	SlowStartExponential = "exponential" // 权重按指数增长：前期增长慢，后期快
+)
+
+// SlowStartConfig 新实例慢启动配置
+//
+// duration > 0 时启用。组件启动完成（预热结束）后开始爬坡：权重从 minWeight 增长到 1，
+// /ready 的 X-Weight-Hint 响应头和 weight 字段给出当前权重（百分比），支持按权重分配流量的负载均衡器据此逐步加量；
+// 同时过载保护中间件把在途请求上限限制为 capacity × 权重，超出的请求直接返回 503 + Retry-After，
+// 不在冷缓存、冷连接池上排队。爬坡结束时恰好恢复完整容量
+//
+// Example:
+//
+//	[web.slowStart]
+//	duration = 60          # 爬坡时长（秒）
+//	curve = "linear"       # linear（默认）或 exponential
+//	minWeight = 0.1        # 初始权重，默认 0.1
+//	capacity = 200         # 完整的在途请求上限，默认使用 shedding.capacity（由过载保护中间件执行，需启用 shedding）
+//	retryAfter = 1         # 秒
+type SlowStartConfig struct {
+	Duration   int     `toml:"duration"`   // 爬坡时长（秒），0 表示不启用
+	Curve      string  `toml:"curve"`      // 爬坡曲线
+	MinWeight  float64 `toml:"minWeight"`  // 初始权重（0-1）
+	Capacity   int     `toml:"capacity"`   // 完整的在途请求上限（0 时只提供权重提示，不限流）
+	RetryAfter int     `toml:"retryAfter"` // Retry-After 秒数，默认 1
+}
+
+// SlowStartStatus 慢启动状态（/ready、/health 和管理接口输出）
+type SlowStartStatus struct {
+	Active      bool    `json:"active"`          // 是否仍在爬坡
+	Progress    float64 `json:"progress"`        // 爬坡进度（0-1）
+	Weight      int     `json:"weight"`          // 当前权重（百分比）
+	Limit       int     `json:"limit,omitempty"` // 当前在途请求上限
+	Curve       string  `json:"curve"`
+	RemainingMs int64   `json:"remainingMs"` // 距爬坡结束的剩余时间
+}
+
+// SlowStart 慢启动控制器
+type SlowStart struct {
+	config   SlowStartConfig
+	clock    common.Clock
+	duration time.Duration
+
+	begun    atomic.Int64 // 爬坡开始时间（Unix 纳秒，0 表示尚未开始）
+	finished atomic.Bool  // 爬坡已结束（到期或被提前结束）
+
+	progressGauge *metrics.Gauge
+	limitGauge    *metrics.Gauge
+	rejected      *metrics.Counter
+}
+
+// NewSlowStart 创建慢启动控制器（零值字段使用默认值）
+//
+// clock 为 nil 时使用系统时钟；测试中注入 common.FakeClock 可逐步推进爬坡
+func NewSlowStart(config SlowStartConfig, clock common.Clock) *SlowStart {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	if config.Curve != SlowStartExponential {
+		config.Curve = SlowStartLinear
+	}
+	if config.MinWeight <= 0 || config.MinWeight > 1 {
+		config.MinWeight = 0.1
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 1
+	}
+	s := &SlowStart{
+		config:        config,
+		clock:         clock,
+		duration:      time.Duration(config.Duration) * time.Second,
+		progressGauge: metrics.GetGauge("web_slowstart_progress"),
+		limitGauge:    metrics.GetGauge("web_slowstart_limit"),
+		rejected:      metrics.GetCounter("web_slowstart_rejected_total"),
+	}
+	if s.duration <= 0 {
+		s.finished.Store(true)
+	}
+	return s
+}
+
+// Begin 开始爬坡（预热完成后调用；重复调用无效）
+func (s *SlowStart) Begin() {
+	if s.begun.CompareAndSwap(0, s.clock.Now().UnixNano()) && !s.finished.Load() {
+		log.Infof("[SlowStart] 开始爬坡: %v, %s, 初始权重 %.0f%%", s.duration, s.config.Curve, s.config.MinWeight*100)
+	}
+}
+
+// Finish 提前结束爬坡，立即恢复完整容量
+func (s *SlowStart) Finish() {
+	if !s.finished.Swap(true) {
+		s.progressGauge.Set(1)
+		s.limitGauge.Set(float64(s.config.Capacity))
+		log.Infof("[SlowStart] 爬坡结束，恢复完整容量")
+	}
+}
+
+// Progress 爬坡进度（尚未开始为 0，结束后为 1）
+func (s *SlowStart) Progress() float64 {
+	if s.finished.Load() {
+		return 1
+	}
+	begun := s.begun.Load()
+	if begun == 0 {
+		return 0
+	}
+	elapsed := s.clock.Now().Sub(time.Unix(0, begun))
+	if elapsed >= s.duration {
+		s.Finish()
+		return 1
+	}
+	return float64(elapsed) / float64(s.duration)
+}
+
+// weight 进度对应的权重（0-1）
+func (s *SlowStart) weight(progress float64) float64 {
+	if progress >= 1 {
+		return 1
+	}
+	lo := s.config.MinWeight
+	if s.config.Curve == SlowStartExponential {
+		return lo * math.Pow(1/lo, progress)
+	}
+	return lo + (1-lo)*progress
+}
+
+// Limit 当前在途请求上限；爬坡已结束或未配置容量时 ramping = false
+func (s *SlowStart) Limit() (limit int, ramping bool) {
+	progress := s.Progress()
+	s.progressGauge.Set(progress)
+	if progress >= 1 || s.config.Capacity <= 0 {
+		return s.config.Capacity, false
+	}
+	limit = max(int(math.Floor(float64(s.config.Capacity)*s.weight(progress))), 1)
+	s.limitGauge.Set(float64(limit))
+	return limit, true
+}
+
+// Status 当前状态
+func (s *SlowStart) Status() SlowStartStatus {
+	progress := s.Progress()
+	status := SlowStartStatus{
+		Active:   progress < 1,
+		Progress: progress,
+		Weight:   max(int(math.Floor(s.weight(progress)*100)), 1),
+		Curve:    s.config.Curve,
+	}
+	if limit, ramping := s.Limit(); ramping {
+		status.Limit = limit
+	}
+	if status.Active {
+		status.RemainingMs = int64(float64(s.duration) * (1 - progress) / float64(time.Millisecond))
+	}
+	return status
+}
+
+var globalSlowStart atomic.Pointer[SlowStart]
+
+// InitSlowStart 初始化全局慢启动控制器（config.duration <= 0 时不启用）
+//
+// 已初始化过载保护时，爬坡期间的并发上限由过载保护中间件执行
+func InitSlowStart(config SlowStartConfig, clock common.Clock) {
+	if config.Duration <= 0 {
+		globalSlowStart.Store(nil)
+		return
+	}
+	s := NewSlowStart(config, clock)
+	globalSlowStart.Store(s)
+	if globalShedder != nil {
+		globalShedder.SetSlowStart(s)
+	}
+}
+
+// beginSlowStart 预热完成，开始爬坡（MustRun 在组件启动后调用）
+func beginSlowStart() {
+	s := globalSlowStart.Load()
+	if s == nil {
+		return
+	}
+	s.Begin()
+	logSlowStartProgress(s)
+}
+
+// logSlowStartProgress 按 25% 的间隔输出爬坡进度（启动报告的一部分）
+func logSlowStartProgress(s *SlowStart) {
+	for _, p := range []float64{0.25, 0.5, 0.75, 1} {
+		time.AfterFunc(time.Duration(float64(s.duration)*p), func() {
+			if status := s.Status(); status.Active {
+				log.Infof("[SlowStart] 爬坡 %3.0f%%: 权重 %d%%, 并发上限 %d", status.Progress*100, status.Weight, status.Limit)
+			}
+		})
+	}
+}
+
+// CurrentSlowStart 全局慢启动状态（未启用时返回 nil）
+func CurrentSlowStart() *SlowStartStatus {
+	s := globalSlowStart.Load()
+	if s == nil {
+		return nil
+	}
+	status := s.Status()
+	return &status
+}
+
+// ReadyHandler 就绪检查（注册到 /ready）
+//
+// 慢启动期间响应头 X-Weight-Hint 为当前权重百分比（爬坡结束后为 100），
+// 按权重分配流量的负载均衡器据此逐步加量；data 中同时给出爬坡状态
+func ReadyHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		weight := 100
+		data := map[string]any{"ready": true}
+		if status := CurrentSlowStart(); status != nil {
+			weight = status.Weight
+			if status.Active {
+				data["slowStart"] = status
+			}
+		}
+		data["weight"] = weight
+		c.Header("X-Weight-Hint", strconv.Itoa(weight))
+		c.JSON(consts.StatusOK, Success(data))
+	}
+}
+
+// SlowStartAdminHandler 慢启动管理接口
+//
+// GET 返回爬坡状态；POST 或 DELETE 提前结束爬坡，立即恢复完整容量。
+// 需要自行挂在有权限控制的路由组下。
+//
+// 使用方式：
+//
+//	admin.Any("/slow-start", web.SlowStartAdminHandler())
+func SlowStartAdminHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		s := globalSlowStart.Load()
+		if s == nil {
+			panic(NewHTTPException(404, 404, "Slow start not enabled"))
+		}
+		switch string(c.Method()) {
+		case consts.MethodPost, consts.MethodPut, consts.MethodDelete:
+			log.Warnf("[SlowStart] 管理接口提前结束爬坡")
+			s.Finish()
+		}
+		c.JSON(consts.StatusOK, Success(s.Status()))
+	}
+}
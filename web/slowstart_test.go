@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSlowStart(curve string) (*SlowStart, *common.FakeClock) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	return NewSlowStart(SlowStartConfig{Duration: 100, Curve: curve, MinWeight: 0.1, Capacity: 200}, clock), clock
+}
+
+func TestSlowStart_LinearSchedule(t *testing.T) {
+	s, clock := newTestSlowStart(SlowStartLinear)
+
+	limit, ramping := s.Limit()
+	assert.True(t, ramping)
+	assert.Equal(t, 20, limit, "预热完成前保持初始权重")
+	clock.Advance(time.Hour)
+	limit, _ = s.Limit()
+	assert.Equal(t, 20, limit, "爬坡从 Begin 开始计时")
+
+	s.Begin()
+	want := map[time.Duration]int{0: 20, 25 * time.Second: 65, 50 * time.Second: 110, 75 * time.Second: 155, 99 * time.Second: 198}
+	var elapsed time.Duration
+	for _, at := range []time.Duration{0, 25 * time.Second, 50 * time.Second, 75 * time.Second, 99 * time.Second} {
+		clock.Advance(at - elapsed)
+		elapsed = at
+		limit, ramping := s.Limit()
+		assert.True(t, ramping, at)
+		assert.Equal(t, want[at], limit, at)
+	}
+
+	clock.Advance(time.Second - time.Nanosecond)
+	limit, ramping = s.Limit()
+	assert.True(t, ramping)
+	assert.Less(t, limit, 200, "结束前一刻仍未达到完整容量")
+
+	clock.Advance(time.Nanosecond)
+	limit, ramping = s.Limit()
+	assert.False(t, ramping)
+	assert.Equal(t, 200, limit, "恰好在爬坡结束时恢复完整容量")
+	assert.Equal(t, float64(1), s.progressGauge.Value())
+	assert.False(t, s.Status().Active)
+	assert.Equal(t, 100, s.Status().Weight)
+}
+
+func TestSlowStart_ExponentialSchedule(t *testing.T) {
+	s, clock := newTestSlowStart(SlowStartExponential)
+	s.Begin()
+
+	var limits []int
+	for range 4 {
+		limit, _ := s.Limit()
+		limits = append(limits, limit)
+		clock.Advance(25 * time.Second)
+	}
+	// 0.1 × 10^p：20、35、63、112
+	assert.Equal(t, []int{20, 35, 63, 112}, limits)
+	limit, ramping := s.Limit()
+	assert.False(t, ramping)
+	assert.Equal(t, 200, limit)
+}
+
+func TestSlowStart_FinishEarly(t *testing.T) {
+	s, clock := newTestSlowStart(SlowStartLinear)
+	s.Begin()
+	clock.Advance(10 * time.Second)
+	status := s.Status()
+	assert.True(t, status.Active)
+	assert.Equal(t, 19, status.Weight)
+	assert.Equal(t, int64(90000), status.RemainingMs)
+
+	s.Finish()
+	limit, ramping := s.Limit()
+	assert.False(t, ramping)
+	assert.Equal(t, 200, limit)
+}
+
+func TestShedder_SlowStartCap(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	ss := NewSlowStart(SlowStartConfig{Duration: 10, MinWeight: 0.1, Capacity: 20, RetryAfter: 3}, clock)
+	ss.Begin()
+	s := NewShedder(SheddingConfig{Capacity: 20})
+	s.SetSlowStart(ss)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(s.Middleware())
+	release := make(chan struct{})
+	entered := make(chan struct{}, 10)
+	block := func(ctx context.Context, c *app.RequestContext) {
+		entered <- struct{}{}
+		<-release
+		c.JSON(200, Success(nil))
+	}
+	engine.GET("/api", block)
+	engine.GET("/health", Priority(PriorityCritical), block)
+
+	// 初始上限 2：两个请求占满
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ut.PerformRequest(engine, "GET", "/api", nil)
+		}()
+		<-entered
+	}
+	w := ut.PerformRequest(engine, "GET", "/api", nil)
+	assert.Equal(t, 503, w.Code, "超出爬坡上限的请求直接拒绝")
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+
+	// critical 路由不受爬坡上限限制
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ut.PerformRequest(engine, "GET", "/health", nil)
+	}()
+	<-entered
+
+	// 推进到一半：上限 11
+	clock.Advance(5 * time.Second)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ut.PerformRequest(engine, "GET", "/api", nil)
+	}()
+	<-entered
+	close(release)
+	wg.Wait()
+}
+
+func TestReadyHandler_WeightHint(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	InitSlowStart(SlowStartConfig{Duration: 100, MinWeight: 0.1, Capacity: 200}, clock)
+	t.Cleanup(func() { InitSlowStart(SlowStartConfig{}, nil) })
+	beginSlowStart()
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/ready", ReadyHandler())
+	engine.Use(ExceptionHandler())
+	engine.Any("/admin/slow-start", SlowStartAdminHandler())
+
+	clock.Advance(50 * time.Second)
+	w := ut.PerformRequest(engine, "GET", "/ready", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "55", w.Header().Get("X-Weight-Hint"))
+	var resp struct {
+		Data struct {
+			Weight    int              `json:"weight"`
+			SlowStart *SlowStartStatus `json:"slowStart"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 55, resp.Data.Weight)
+	require.NotNil(t, resp.Data.SlowStart)
+	assert.Equal(t, 110, resp.Data.SlowStart.Limit)
+
+	// 管理接口提前结束爬坡
+	w = ut.PerformRequest(engine, "POST", "/admin/slow-start", nil)
+	assert.Equal(t, 200, w.Code)
+	w = ut.PerformRequest(engine, "GET", "/ready", nil)
+	assert.Equal(t, "100", w.Header().Get("X-Weight-Hint"))
+	assert.NotContains(t, w.Body.String(), "slowStart")
+}
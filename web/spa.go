@@ -0,0 +1,420 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// SPAConfig 单页应用（前端构建产物）托管配置
+//
+// Example:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	web.SPAConfig{
+//	    Root:        "dist",
+//	    APIPrefixes: []string{"/api/", "/admin/"},
+//	    RuntimeConfig: func(ctx context.Context, c *app.RequestContext) any {
+//	        return map[string]any{"apiBase": "/api", "flags": flags.Snapshot()}
+//	    },
+//	}
+type SPAConfig struct {
+	Root        string   // 构建产物在 fsys 中的目录（如 "dist"），为空时使用根目录
+	Index       string   // 入口页面，默认 "index.html"
+	APIPrefixes []string // 接口路径前缀：不存在的路径返回 404 统一响应，不回退到入口页面
+
+	// ImmutablePrefixes 其中的文件都视为带指纹（默认 "assets/"，即 Vite 等构建工具的输出目录）；
+	// 此外文件名带 8 位以上十六进制内容哈希的文件（app.3f2a9c1b.js）同样视为带指纹
+	ImmutablePrefixes []string
+
+	// RuntimeConfig 注入入口页面的运行时配置（每次请求调用，JSON 序列化后赋值给 ConfigVar）；
+	// 入口页面中需要有 Placeholder 占位符
+	RuntimeConfig func(ctx context.Context, c *app.RequestContext) any
+	Placeholder   string // 运行时配置占位符，默认 "<!--app-config-->"
+	ConfigVar     string // 运行时配置的全局变量，默认 "window.__APP_CONFIG__"
+}
+
+// SPA 默认值
+const (
+	defaultSPAIndex       = "index.html"
+	defaultSPAPlaceholder = "<!--app-config-->"
+	defaultSPAConfigVar   = "window.__APP_CONFIG__"
+
+	spaImmutableCache = "public, max-age=31536000, immutable"
+	spaRevalidate     = "no-cache"
+)
+
+// fingerprintPattern 文件名中的内容哈希（app.3f2a9c1b.js、chunk-0a1b2c3d4e.css）
+var fingerprintPattern = regexp.MustCompile(`[.-][0-9a-f]{8,}\.[^/.]+$`)
+
+// spaEncodings 预压缩的旁路文件（按优先级）
+var spaEncodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// SPAAsset 一个静态文件的清单条目
+type SPAAsset struct {
+	Path      string   `json:"path"`
+	ETag      string   `json:"etag"`
+	Size      int      `json:"size"`
+	Immutable bool     `json:"immutable"`
+	Encodings []string `json:"encodings,omitempty"` // 存在预压缩旁路文件的编码（br、gzip）
+}
+
+// spaAsset 已计算指纹的文件（首次请求时计算，之后复用）
+type spaAsset struct {
+	SPAAsset
+	contentType string
+	data        []byte
+	encoded     map[string][]byte
+}
+
+// SPA 单页应用托管：按内容哈希生成强 ETag，带指纹的文件长期缓存，入口页面每次验证，
+// 未知的非接口路径回退到入口页面（history 路由）
+type SPA struct {
+	fsys   fs.FS
+	config SPAConfig
+
+	// 入口页面按占位符切分（没有运行时配置时 head 为整个页面）
+	indexHead, indexTail []byte
+
+	assets sync.Map // 路径 → *spaAsset
+}
+
+// NewSPA 创建单页应用托管，入口页面不存在或缺少运行时配置占位符时返回错误
+func NewSPA(fsys fs.FS, config SPAConfig) (*SPA, error) {
+	if config.Root != "" && config.Root != "." {
+		sub, err := fs.Sub(fsys, config.Root)
+		if err != nil {
+			return nil, fmt.Errorf("spa: %w", err)
+		}
+		fsys = sub
+	}
+	if config.Index == "" {
+		config.Index = defaultSPAIndex
+	}
+	if config.ImmutablePrefixes == nil {
+		config.ImmutablePrefixes = []string{"assets/"}
+	}
+	if config.Placeholder == "" {
+		config.Placeholder = defaultSPAPlaceholder
+	}
+	if config.ConfigVar == "" {
+		config.ConfigVar = defaultSPAConfigVar
+	}
+
+	index, err := fs.ReadFile(fsys, config.Index)
+	if err != nil {
+		return nil, fmt.Errorf("spa: 读取入口页面失败: %w", err)
+	}
+	s := &SPA{fsys: fsys, config: config, indexHead: index}
+	if config.RuntimeConfig != nil {
+		head, tail, ok := bytes.Cut(index, []byte(config.Placeholder))
+		if !ok {
+			return nil, fmt.Errorf("spa: 入口页面 %s 中没有运行时配置占位符 %s", config.Index, config.Placeholder)
+		}
+		s.indexHead, s.indexTail = head, tail
+	}
+	return s, nil
+}
+
+// ServeSPA 托管单页应用：注册为 NoRoute 处理器，接口路由之外的 GET/HEAD 请求由 SPA 处理
+//
+// 带指纹的文件使用 Cache-Control: public, max-age=31536000, immutable，其他文件与入口页面使用 no-cache（按 ETag 验证）；
+// 客户端接受时优先返回预压缩的 .br / .gz 旁路文件。
+// 注入运行时配置时，内联脚本的 sha256 哈希追加到安全头中间件设置的 Content-Security-Policy 的 script-src
+//
+// 使用方式：
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	h := web.NewServer[AppConfig]()
+//	if _, err := web.ServeSPA(h, dist, web.SPAConfig{Root: "dist", APIPrefixes: []string{"/api/"}}); err != nil {
+//	    log.Fatal(err)
+//	}
+func ServeSPA(h *server.Hertz, fsys fs.FS, config SPAConfig) (*SPA, error) {
+	s, err := NewSPA(fsys, config)
+	if err != nil {
+		return nil, err
+	}
+	h.NoRoute(s.Handler())
+	return s, nil
+}
+
+// Handler SPA 处理器（注册到 NoRoute）；其他方法与接口路径返回 404 统一响应
+func (s *SPA) Handler() app.HandlerFunc {
+	notFound := NotFoundHandler()
+	return func(ctx context.Context, c *app.RequestContext) {
+		method := string(c.Method())
+		if method != consts.MethodGet && method != consts.MethodHead {
+			notFound(ctx, c)
+			return
+		}
+		urlPath := path.Clean("/" + string(c.Path()))
+		for _, prefix := range s.config.APIPrefixes {
+			if strings.HasPrefix(urlPath+"/", prefix) {
+				notFound(ctx, c)
+				return
+			}
+		}
+
+		name := strings.TrimPrefix(urlPath, "/")
+		if name == "" || name == s.config.Index {
+			s.serveIndex(ctx, c)
+			return
+		}
+		asset, err := s.asset(name)
+		if err == nil {
+			s.serveAsset(c, asset)
+			return
+		}
+		// history 路由回退到入口页面；带扩展名的路径（缺失的脚本、图片）只有浏览器导航时回退
+		if path.Ext(name) == "" || strings.Contains(string(c.GetHeader("Accept")), "text/html") {
+			s.serveIndex(ctx, c)
+			return
+		}
+		notFound(ctx, c)
+	}
+}
+
+// Manifest 全部静态文件的指纹清单（按路径排序，不含入口页面与旁路文件）
+func (s *SPA) Manifest() ([]SPAAsset, error) {
+	var manifest []SPAAsset
+	err := fs.WalkDir(s.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == s.config.Index || isSidecar(name) {
+			return err
+		}
+		asset, err := s.asset(name)
+		if err != nil {
+			return err
+		}
+		manifest = append(manifest, asset.SPAAsset)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("spa: %w", err)
+	}
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].Path < manifest[j].Path })
+	return manifest, nil
+}
+
+// isSidecar 预压缩的旁路文件（不单独出现在清单中）
+func isSidecar(name string) bool {
+	for _, enc := range spaEncodings {
+		if strings.HasSuffix(name, enc.ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// asset 读取文件并计算指纹（每个文件只计算一次）
+func (s *SPA) asset(name string) (*spaAsset, error) {
+	if v, ok := s.assets.Load(name); ok {
+		return v.(*spaAsset), nil
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &spaAsset{
+		SPAAsset: SPAAsset{
+			Path:      name,
+			ETag:      contentETag(data),
+			Size:      len(data),
+			Immutable: s.immutable(name),
+		},
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		data:        data,
+	}
+	if a.contentType == "" {
+		a.contentType = "application/octet-stream"
+	}
+	for _, enc := range spaEncodings {
+		encoded, err := fs.ReadFile(s.fsys, name+enc.ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if a.encoded == nil {
+			a.encoded = make(map[string][]byte, len(spaEncodings))
+		}
+		a.encoded[enc.name] = encoded
+		a.Encodings = append(a.Encodings, enc.name)
+	}
+
+	v, _ := s.assets.LoadOrStore(name, a)
+	return v.(*spaAsset), nil
+}
+
+// immutable 文件是否带指纹（内容变化时文件名随之变化）
+func (s *SPA) immutable(name string) bool {
+	for _, prefix := range s.config.ImmutablePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return fingerprintPattern.MatchString(name)
+}
+
+// serveAsset 输出静态文件（支持 If-None-Match 与预压缩旁路文件）
+func (s *SPA) serveAsset(c *app.RequestContext, a *spaAsset) {
+	data, etag := a.data, a.ETag
+	if len(a.encoded) > 0 {
+		c.Header("Vary", "Accept-Encoding")
+		accepted := string(c.GetHeader("Accept-Encoding"))
+		for _, enc := range a.Encodings {
+			if acceptsEncoding(accepted, enc) {
+				// 不同编码是不同的表示，强 ETag 不能相同
+				data, etag = a.encoded[enc], strings.TrimSuffix(a.ETag, `"`)+"-"+enc+`"`
+				c.Header("Content-Encoding", enc)
+				break
+			}
+		}
+	}
+
+	c.Response.Header.Del("Pragma")
+	if a.Immutable {
+		c.Header("Cache-Control", spaImmutableCache)
+	} else {
+		c.Header("Cache-Control", spaRevalidate)
+	}
+	writeWithETag(c, etag, a.contentType, data)
+}
+
+// serveIndex 输出入口页面（注入运行时配置，每次验证）
+func (s *SPA) serveIndex(ctx context.Context, c *app.RequestContext) {
+	body := s.indexHead
+	if s.config.RuntimeConfig != nil {
+		// json.Marshal 转义 <、>、&，内容不会提前结束 script 标签
+		value, err := json.Marshal(s.config.RuntimeConfig(ctx, c))
+		if err != nil {
+			panic(InternalHTTP("运行时配置序列化失败"))
+		}
+		script := s.config.ConfigVar + "=" + string(value) + ";"
+		sum := sha256.Sum256([]byte(script))
+		if csp := string(c.Response.Header.Peek("Content-Security-Policy")); csp != "" {
+			c.Header("Content-Security-Policy", cspAllowScript(csp, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'"))
+		}
+
+		var buf bytes.Buffer
+		buf.Grow(len(s.indexHead) + len(script) + len(s.indexTail) + 17)
+		buf.Write(s.indexHead)
+		buf.WriteString("<script>")
+		buf.WriteString(script)
+		buf.WriteString("</script>")
+		buf.Write(s.indexTail)
+		body = buf.Bytes()
+	}
+
+	c.Response.Header.Del("Pragma")
+	c.Header("Cache-Control", spaRevalidate)
+	writeWithETag(c, contentETag(body), "text/html; charset=utf-8", body)
+}
+
+// writeWithETag 设置 ETag；与 If-None-Match 匹配时响应 304
+func writeWithETag(c *app.RequestContext, etag, contentType string, data []byte) {
+	c.Header("ETag", etag)
+	if etagMatches(string(c.GetHeader("If-None-Match")), etag) {
+		c.Status(consts.StatusNotModified)
+		return
+	}
+	c.Data(consts.StatusOK, contentType, data)
+}
+
+// contentETag 内容哈希生成的强 ETag
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches If-None-Match 是否匹配（弱比较：忽略 W/ 前缀）
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding Accept-Encoding 是否接受该编码（q=0 表示拒绝）
+func acceptsEncoding(header, encoding string) bool {
+	for _, item := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(item, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// cspAllowScript 在 CSP 的 script-src 中追加来源；没有 script-src 时按 default-src 补一条，两者都没有时不限制脚本，原样返回
+func cspAllowScript(csp, source string) string {
+	var directives []string
+	defaultSrc := ""
+	found := false
+	for _, d := range strings.Split(csp, ";") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		name, sources, _ := strings.Cut(d, " ")
+		switch strings.ToLower(name) {
+		case "script-src":
+			d = "script-src " + addCSPSource(sources, source)
+			found = true
+		case "default-src":
+			defaultSrc = strings.TrimSpace(sources)
+		}
+		directives = append(directives, d)
+	}
+	if !found {
+		if defaultSrc == "" {
+			return csp
+		}
+		directives = append(directives, "script-src "+addCSPSource(defaultSrc, source))
+	}
+	return strings.Join(directives, "; ")
+}
+
+// addCSPSource 在来源列表中追加来源（'none' 与其他来源不能同时出现，替换掉）
+func addCSPSource(sources, source string) string {
+	sources = strings.TrimSpace(sources)
+	if sources == "" || sources == "'none'" {
+		return source
+	}
+	return sources + " " + source
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSPAEngine(t *testing.T, conf SPAConfig) (*route.Engine, *SPA) {
+	dist := fstest.MapFS{
+		"dist/index.html":                  {Data: []byte(`<html><head><!--app-config--></head><body></body></html>`)},
+		"dist/assets/index-BkF3ar2_.js":    {Data: []byte("console.log('app')")},
+		"dist/assets/index-BkF3ar2_.js.br": {Data: []byte("br-bytes")},
+		"dist/assets/index-BkF3ar2_.js.gz": {Data: []byte("gz-bytes")},
+		"dist/favicon.ico":                 {Data: []byte("icon")},
+	}
+	conf.Root = "dist"
+	spa, err := NewSPA(dist, conf)
+	require.NoError(t, err)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(middleware.SecurityHeadersMiddleware(), ExceptionHandler())
+	engine.GET("/api/ping", func(ctx context.Context, c *app.RequestContext) { c.String(200, "pong") })
+	engine.NoRoute(spa.Handler())
+	return engine, spa
+}
+
+func TestSPA_FallbackRouting(t *testing.T) {
+	engine, _ := newSPAEngine(t, SPAConfig{APIPrefixes: []string{"/api/"}})
+
+	w := ut.PerformRequest(engine, "GET", "/api/ping", nil)
+	assert.Equal(t, "pong", w.Body.String())
+
+	// history 路由回退到入口页面
+	for _, p := range []string{"/", "/orders/42", "/index.html"} {
+		w = ut.PerformRequest(engine, "GET", p, nil)
+		require.Equal(t, 200, w.Code, p)
+		assert.Contains(t, w.Body.String(), "<html>", p)
+		assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"), p)
+	}
+
+	// 接口前缀、非 GET 请求与缺失的静态文件返回 404
+	for _, p := range []string{"/api/missing", "/api"} {
+		w = ut.PerformRequest(engine, "GET", p, nil)
+		assert.Equal(t, 404, w.Code, p)
+		assert.Contains(t, w.Body.String(), `"code":404`, p)
+	}
+	w = ut.PerformRequest(engine, "POST", "/orders/42", nil)
+	assert.Equal(t, 404, w.Code)
+	w = ut.PerformRequest(engine, "GET", "/assets/missing.js", nil)
+	assert.Equal(t, 404, w.Code)
+	w = ut.PerformRequest(engine, "GET", "/docs/guide.html", nil, ut.Header{Key: "Accept", Value: "text/html,*/*"})
+	assert.Equal(t, 200, w.Code, "浏览器导航到带扩展名的路径同样回退")
+}
+
+func TestSPA_CachingAndConditionalRequests(t *testing.T) {
+	engine, spa := newSPAEngine(t, SPAConfig{})
+
+	w := ut.PerformRequest(engine, "GET", "/assets/index-BkF3ar2_.js", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, "console.log('app')", w.Body.String())
+	assert.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Pragma"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	etag := w.Header().Get("ETag")
+	require.True(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`), etag)
+
+	w = ut.PerformRequest(engine, "GET", "/assets/index-BkF3ar2_.js", nil, ut.Header{Key: "If-None-Match", Value: `"other", ` + etag})
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	// 预压缩旁路文件：按客户端接受的编码选择，ETag 随编码不同
+	w = ut.PerformRequest(engine, "GET", "/assets/index-BkF3ar2_.js", nil, ut.Header{Key: "Accept-Encoding", Value: "gzip, br"})
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "br-bytes", w.Body.String())
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	w = ut.PerformRequest(engine, "GET", "/assets/index-BkF3ar2_.js", nil, ut.Header{Key: "Accept-Encoding", Value: "gzip, br;q=0"})
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "gz-bytes", w.Body.String())
+
+	// 不带指纹的文件每次验证
+	w = ut.PerformRequest(engine, "GET", "/favicon.ico", nil)
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	w = ut.PerformRequest(engine, "GET", "/favicon.ico", nil, ut.Header{Key: "If-None-Match", Value: "W/" + w.Header().Get("ETag")})
+	assert.Equal(t, 304, w.Code)
+
+	manifest, err := spa.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest, 2)
+	assert.Equal(t, "assets/index-BkF3ar2_.js", manifest[0].Path)
+	assert.Equal(t, etag, manifest[0].ETag)
+	assert.True(t, manifest[0].Immutable)
+	assert.Equal(t, []string{"br", "gzip"}, manifest[0].Encodings)
+	assert.Equal(t, "favicon.ico", manifest[1].Path)
+	assert.False(t, manifest[1].Immutable)
+}
+
+func TestSPA_RuntimeConfigInjection(t *testing.T) {
+	engine, _ := newSPAEngine(t, SPAConfig{
+		RuntimeConfig: func(ctx context.Context, c *app.RequestContext) any {
+			return map[string]any{"apiBase": "/api", "flags": map[string]bool{"newCheckout": true}, "note": "</script>"}
+		},
+	})
+
+	w := ut.PerformRequest(engine, "GET", "/settings", nil)
+	require.Equal(t, 200, w.Code)
+	body := w.Body.String()
+	script := `window.__APP_CONFIG__={"apiBase":"/api","flags":{"newCheckout":true},"note":"\u003c/script\u003e"};`
+	assert.Contains(t, body, "<head><script>"+script+"</script></head>")
+	assert.NotContains(t, body, "<!--app-config-->")
+
+	// 内联脚本的哈希加入 CSP 的 script-src
+	sum := sha256.Sum256([]byte(script))
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src 'self' http://https: 'self' 'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	assert.Contains(t, csp, "object-src 'none'")
+
+	w = ut.PerformRequest(engine, "GET", "/settings", nil, ut.Header{Key: "If-None-Match", Value: w.Header().Get("ETag")})
+	assert.Equal(t, 304, w.Code)
+
+	_, err := NewSPA(fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}, SPAConfig{
+		RuntimeConfig: func(context.Context, *app.RequestContext) any { return nil },
+	})
+	assert.ErrorContains(t, err, "占位符")
+}
+
+func TestCSPAllowScript(t *testing.T) {
+	assert.Equal(t, "default-src 'self'; script-src 'self' cdn.example 'sha256-x'",
+		cspAllowScript("default-src 'self'; script-src 'self' cdn.example", "'sha256-x'"))
+	assert.Equal(t, "default-src 'none'; script-src 'sha256-x'",
+		cspAllowScript("default-src 'none';", "'sha256-x'"))
+	assert.Equal(t, "img-src 'self'", cspAllowScript("img-src 'self'", "'sha256-x'"))
+}
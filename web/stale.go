@@ -0,0 +1,37 @@
+package web
+
+import (
+	"context"
+
+	"github.com/CenJIl/base/web/cache"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderServedStale 响应包含旧数据时设置的响应头
+const HeaderServedStale = "X-Served-Stale"
+
+// ServeStale 按路由声明接受旧数据：数据库故障时返回缓存中的旧值而不是错误
+//
+// 只对处理函数中使用 cache.WithStale 的读取生效；返回了旧值的响应带 X-Served-Stale: true，
+// 并计入 web_stale_responses_total{route}（长期不为零说明回源一直失败，应当告警）
+//
+// 使用方式：
+//
+//	api.GET("/products", web.ServeStale(), web.WrapHandler(listProducts))
+//
+//	func listProducts(ctx context.Context, c *app.RequestContext) error {
+//	    products, err := cache.GetOrLoad(ctx, "products", time.Minute, queries.ListProducts,
+//	        cache.WithStale(time.Hour, 200*time.Millisecond))
+//	    ...
+//	}
+func ServeStale() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ctx = cache.AllowStale(ctx)
+		c.Next(ctx)
+		if cache.ServedStale(ctx) {
+			c.Response.Header.Set(HeaderServedStale, "true")
+			metrics.GetCounter("web_stale_responses_total", "route", c.FullPath()).Inc()
+		}
+	}
+}
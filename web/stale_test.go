@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staleStore 内存缓存存储
+type staleStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (s *staleStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.entries[key]
+	return v, ok, nil
+}
+
+func (s *staleStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = value
+	return nil
+}
+
+func TestServeStale(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	loader := cache.NewLoader(&staleStore{entries: map[string][]byte{}}, clock)
+	dbDown := false
+	handler := WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		products, err := cache.LoadWith(ctx, loader, "products", time.Minute, func(ctx context.Context) ([]string, error) {
+			if dbDown {
+				return nil, errors.New("connection refused")
+			}
+			return []string{"apple"}, nil
+		}, cache.WithStale(time.Hour, 0))
+		if err != nil {
+			return err
+		}
+		c.JSON(200, Success(products))
+		return nil
+	})
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/products", ServeStale(), handler)
+	engine.GET("/strict/products", handler)
+
+	w := ut.PerformRequest(engine, "GET", "/products", nil)
+	require.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Header().Peek(HeaderServedStale))
+
+	clock.Advance(2 * time.Minute)
+	dbDown = true
+	w = ut.PerformRequest(engine, "GET", "/products", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", string(w.Header().Peek(HeaderServedStale)))
+	assert.Contains(t, w.Body.String(), "apple")
+
+	w = ut.PerformRequest(engine, "GET", "/strict/products", nil)
+	assert.Equal(t, 500, w.Code, "未声明 ServeStale 的路由照常返回错误")
+	assert.Empty(t, w.Header().Peek(HeaderServedStale))
+}
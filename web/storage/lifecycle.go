@@ -0,0 +1,650 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// log storage 模块的日志记录器
+var log = logger.Named("storage")
+
+// 生命周期动作
+const (
+	ActionArchive = "archive" // 移动到归档存储
+	ActionDelete  = "delete"  // 删除（主存储或归档存储中）
+)
+
+// 归档读取方式
+const (
+	RestoreSync  = "sync"  // 温归档：直接从归档存储读取（默认）
+	RestoreAsync = "async" // 冷归档：先发起恢复，恢复完成前响应 202 + Retry-After
+)
+
+// TierArchive 索引中已归档对象的层级
+const TierArchive = "archive"
+
+var (
+	// ErrRestoring 对象在冷归档中，已发起恢复，稍后重试
+	ErrRestoring = errors.New("storage: object is being restored from archive")
+	// ErrLegalHold 对象处于法律保留，不能删除
+	ErrLegalHold = errors.New("storage: object is under legal hold")
+)
+
+// LifecycleRule 生命周期规则：前缀下最后修改超过 Days 天的对象执行 Action
+//
+// 同一对象匹配多条规则时只看前缀最长的规则，其中执行年龄已达到、Days 最大的一条（如 90 天归档、2555 天删除）
+type LifecycleRule struct {
+	Prefix string `toml:"prefix"` // key 前缀，如 uploads/
+	Days   int    `toml:"days"`   // 最后修改超过的天数
+	Action string `toml:"action"` // archive / delete
+	DryRun bool   `toml:"dryRun"` // 删除动作只生成报告不执行
+}
+
+// LifecycleConfig 上传文件生命周期配置
+//
+// Example:
+//
+//	[storage.lifecycle]
+//	index = "data/lifecycle.json"   # 索引文件（记录归档位置与法律保留，不要放在主存储目录内）
+//	restore = "async"               # 归档读取方式：sync 直接读取 / async 先恢复（冷归档）
+//	retryAfter = 3600               # async 时建议客户端重试的秒数，默认 60
+//
+//	[[storage.lifecycle.rules]]
+//	prefix = "uploads/"
+//	days = 90
+//	action = "archive"
+//
+//	[[storage.lifecycle.rules]]
+//	prefix = "uploads/tmp/"
+//	days = 30
+//	action = "delete"
+//	dryRun = true
+type LifecycleConfig struct {
+	Rules      []LifecycleRule `toml:"rules"`
+	Index      string          `toml:"index"`
+	Restore    string          `toml:"restore"`
+	RetryAfter int             `toml:"retryAfter"`
+}
+
+// Restorer 需要先恢复才能读取的归档存储（Glacier 等冷归档实现，温归档不需要）
+type Restorer interface {
+	// Restore 发起恢复（恢复中时不重复发起），返回对象是否已经可以读取
+	Restore(ctx context.Context, key string) (bool, error)
+}
+
+// IndexEntry 索引记录：对象所在层级与法律保留
+type IndexEntry struct {
+	Tier       string     `json:"tier,omitempty"` // archive 表示已归档；空表示仍在主存储
+	Size       int64      `json:"size,omitempty"`
+	ModTime    time.Time  `json:"modTime"`              // 归档前的最后修改时间（删除规则按它计算年龄）
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"` // 归档时间
+	Hold       bool       `json:"hold,omitempty"`       // 法律保留：删除规则跳过，Delete 拒绝
+	Restoring  bool       `json:"restoring,omitempty"`  // 已发起恢复，尚未完成
+}
+
+// Index 生命周期索引（记录归档对象的位置与法律保留标记）
+type Index interface {
+	Get(ctx context.Context, key string) (IndexEntry, bool, error)
+	Put(ctx context.Context, key string, entry IndexEntry) error
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context) (map[string]IndexEntry, error)
+}
+
+// FileIndex JSON 索引文件（sidecar manifest），原子写入；path 为空时只保存在内存中
+type FileIndex struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]IndexEntry
+}
+
+// NewFileIndex 创建索引（文件不存在时视为空）
+func NewFileIndex(path string) *FileIndex {
+	return &FileIndex{path: path}
+}
+
+// load 首次使用时读取索引文件（调用方持有锁）
+func (x *FileIndex) load() error {
+	if x.entries != nil {
+		return nil
+	}
+	entries := map[string]IndexEntry{}
+	if x.path != "" {
+		data, err := os.ReadFile(x.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("storage: 读取索引文件失败: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return fmt.Errorf("storage: 解析索引文件 %s 失败: %w", x.path, err)
+			}
+		}
+	}
+	x.entries = entries
+	return nil
+}
+
+// save 写回索引文件（调用方持有锁）
+func (x *FileIndex) save() error {
+	if x.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(x.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, x.path)
+}
+
+// Get 实现 Index 接口
+func (x *FileIndex) Get(ctx context.Context, key string) (IndexEntry, bool, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(); err != nil {
+		return IndexEntry{}, false, err
+	}
+	e, ok := x.entries[key]
+	return e, ok, nil
+}
+
+// Put 实现 Index 接口
+func (x *FileIndex) Put(ctx context.Context, key string, entry IndexEntry) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(); err != nil {
+		return err
+	}
+	x.entries[key] = entry
+	return x.save()
+}
+
+// Delete 实现 Index 接口
+func (x *FileIndex) Delete(ctx context.Context, key string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(); err != nil {
+		return err
+	}
+	if _, ok := x.entries[key]; !ok {
+		return nil
+	}
+	delete(x.entries, key)
+	return x.save()
+}
+
+// List 实现 Index 接口
+func (x *FileIndex) List(ctx context.Context) (map[string]IndexEntry, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err := x.load(); err != nil {
+		return nil, err
+	}
+	out := make(map[string]IndexEntry, len(x.entries))
+	for k, e := range x.entries {
+		out[k] = e
+	}
+	return out, nil
+}
+
+// LifecycleReport 一次规则评估的结果
+type LifecycleReport struct {
+	Archived    []string `json:"archived"`    // 移动到归档存储的对象
+	Deleted     []string `json:"deleted"`     // 已删除的对象
+	WouldDelete []string `json:"wouldDelete"` // dryRun 规则命中、未删除的对象
+	Held        []string `json:"held"`        // 因法律保留跳过删除的对象
+	Bytes       int64    `json:"bytes"`       // 归档与删除的字节数（不含 dryRun）
+}
+
+// Lifecycle 上传文件生命周期：按规则把旧对象从主存储移动到归档存储或删除，读取时透明地查找归档位置
+//
+// Lifecycle 本身实现 Storage 接口，业务代码把它当作存储使用即可：Open 与下载链接在对象归档后仍然有效。
+// 归档位置与法律保留记录在索引中；归档时先写入归档存储、再更新索引、最后删除主存储中的对象，
+// 中途失败时对象仍可读取
+//
+// 使用方式：
+//
+//	primary := storage.NewLocal("data/files", baseURL, secret, nil)
+//	archive := storage.NewLocal("/mnt/archive/files", baseURL, secret, nil)
+//	files, err := storage.NewLifecycle(primary, archive, nil, config.Storage.Lifecycle, nil)
+//	h.GET("/files/*key", files.Handler())
+//	scheduler.Register("storage.lifecycle", task.Daily(3, 0, time.Local), files.Run)
+//	files.OnRestored(func(ctx context.Context, key string) {
+//	    notifyOwner(ctx, key) // 冷归档恢复完成
+//	})
+type Lifecycle struct {
+	primary    Storage
+	archive    Storage
+	index      Index
+	config     LifecycleConfig
+	clock      common.Clock
+	onRestored atomic.Pointer[func(ctx context.Context, key string)]
+}
+
+// NewLifecycle 创建生命周期管理（index 为 nil 时使用 config.Index 指定的索引文件，clock 为 nil 时使用系统时钟）
+//
+// 主存储需要实现 Lister；规则或读取方式无效时返回错误
+func NewLifecycle(primary, archive Storage, index Index, config LifecycleConfig, clock common.Clock) (*Lifecycle, error) {
+	if _, ok := primary.(Lister); !ok {
+		return nil, fmt.Errorf("storage: 主存储 %T 不支持列出对象", primary)
+	}
+	if archive == nil {
+		return nil, fmt.Errorf("storage: 缺少归档存储")
+	}
+	for _, r := range config.Rules {
+		if r.Days <= 0 {
+			return nil, fmt.Errorf("storage: 规则 %q 的 days 必须大于 0", r.Prefix)
+		}
+		if r.Action != ActionArchive && r.Action != ActionDelete {
+			return nil, fmt.Errorf("storage: 规则 %q 的 action 无效: %q", r.Prefix, r.Action)
+		}
+	}
+	switch config.Restore {
+	case "":
+		config.Restore = RestoreSync
+	case RestoreSync, RestoreAsync:
+	default:
+		return nil, fmt.Errorf("storage: restore 无效: %q", config.Restore)
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = 60
+	}
+	if index == nil {
+		index = NewFileIndex(config.Index)
+	}
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &Lifecycle{primary: primary, archive: archive, index: index, config: config, clock: clock}, nil
+}
+
+// OnRestored 设置冷归档恢复完成的回调（通知等待下载的用户，nil 取消）
+func (l *Lifecycle) OnRestored(fn func(ctx context.Context, key string)) {
+	if fn == nil {
+		l.onRestored.Store(nil)
+		return
+	}
+	l.onRestored.Store(&fn)
+}
+
+// Hold 设置或解除法律保留：保留中的对象不会被删除规则或 Delete 删除（归档不受影响）
+func (l *Lifecycle) Hold(ctx context.Context, key string, hold bool) error {
+	entry, ok, err := l.index.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok && !hold {
+		return nil
+	}
+	entry.Hold = hold
+	if !hold && entry.Tier == "" {
+		return l.index.Delete(ctx, key)
+	}
+	return l.index.Put(ctx, key, entry)
+}
+
+// Append 实现 Storage 接口（写入主存储；覆盖已归档的对象时删除归档副本）
+func (l *Lifecycle) Append(ctx context.Context, key string, offset int64) (io.WriteCloser, error) {
+	entry, ok, err := l.index.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok && entry.Tier == TierArchive {
+		if err := l.archive.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		if err := l.unarchive(ctx, key, entry); err != nil {
+			return nil, err
+		}
+	}
+	return l.primary.Append(ctx, key, offset)
+}
+
+// unarchive 把索引记录恢复为主存储（只保留法律保留标记）
+func (l *Lifecycle) unarchive(ctx context.Context, key string, entry IndexEntry) error {
+	if !entry.Hold {
+		return l.index.Delete(ctx, key)
+	}
+	return l.index.Put(ctx, key, IndexEntry{Hold: true})
+}
+
+// Open 实现 Storage 接口（已归档的对象从归档存储读取；冷归档未恢复时返回 ErrRestoring）
+func (l *Lifecycle) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	entry, ok, err := l.index.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || entry.Tier != TierArchive {
+		return l.primary.Open(ctx, key)
+	}
+	return l.openArchived(ctx, key, entry)
+}
+
+func (l *Lifecycle) openArchived(ctx context.Context, key string, entry IndexEntry) (io.ReadCloser, error) {
+	if restorer, ok := l.archive.(Restorer); ok && l.config.Restore == RestoreAsync {
+		ready, err := restorer.Restore(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !ready {
+			if !entry.Restoring {
+				entry.Restoring = true
+				if err := l.index.Put(ctx, key, entry); err != nil {
+					return nil, err
+				}
+				log.Infof("[Lifecycle] 发起恢复: %s", key)
+			}
+			return nil, ErrRestoring
+		}
+		if entry.Restoring {
+			l.restored(ctx, key, entry)
+		}
+	}
+	rc, err := l.archive.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	metrics.GetCounter("storage_lifecycle_bytes_total", "action", "restored").Add(entry.Size)
+	return rc, nil
+}
+
+// restored 恢复完成：清除恢复标记并触发回调
+func (l *Lifecycle) restored(ctx context.Context, key string, entry IndexEntry) {
+	entry.Restoring = false
+	if err := l.index.Put(ctx, key, entry); err != nil {
+		log.Warnf("[Lifecycle] 更新索引失败 %s: %v", key, err)
+		return
+	}
+	log.Infof("[Lifecycle] 恢复完成: %s", key)
+	if fn := l.onRestored.Load(); fn != nil {
+		(*fn)(ctx, key)
+	}
+}
+
+// Delete 实现 Storage 接口（同时删除归档副本与索引记录；法律保留中的对象返回 ErrLegalHold）
+func (l *Lifecycle) Delete(ctx context.Context, key string) error {
+	entry, ok, err := l.index.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return l.primary.Delete(ctx, key)
+	}
+	if entry.Hold {
+		return ErrLegalHold
+	}
+	if err := l.primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := l.archive.Delete(ctx, key); err != nil {
+		return err
+	}
+	return l.index.Delete(ctx, key)
+}
+
+// SignedURL 实现 Storage 接口
+//
+// 主存储是 Local 时始终返回主存储的链接（由 Handler 透明读取归档）；否则已归档的对象返回归档存储的链接
+func (l *Lifecycle) SignedURL(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	if _, ok := l.primary.(*Local); !ok {
+		entry, ok, err := l.index.Get(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if ok && entry.Tier == TierArchive {
+			return l.archive.SignedURL(ctx, key, filename, ttl)
+		}
+	}
+	return l.primary.SignedURL(ctx, key, filename, ttl)
+}
+
+// Handler 签名下载处理函数（主存储需要是 Local，替代 Local.Handler 挂在同一路由上）
+//
+// 未归档的对象与 Local.Handler 行为相同；已归档的对象从归档存储读取，归档前签发的链接仍然有效。
+// 冷归档（restore = "async"）未恢复时响应 202 + Retry-After，恢复完成后触发 OnRestored
+func (l *Lifecycle) Handler() app.HandlerFunc {
+	local, ok := l.primary.(*Local)
+	if !ok {
+		panic("storage: Lifecycle.Handler requires a *Local primary storage")
+	}
+	return func(ctx context.Context, c *app.RequestContext) {
+		key, filename := local.verify(c)
+		entry, ok, err := l.index.Get(ctx, key)
+		if err != nil {
+			panic(err)
+		}
+		if !ok || entry.Tier != TierArchive {
+			local.serve(ctx, c, key, filename)
+			return
+		}
+		rc, err := l.openArchived(ctx, key, entry)
+		switch {
+		case errors.Is(err, ErrRestoring):
+			c.Header("Retry-After", strconv.Itoa(l.config.RetryAfter))
+			c.JSON(consts.StatusAccepted, web.Success(map[string]any{"status": "restoring", "retryAfter": l.config.RetryAfter}))
+			return
+		case errors.Is(err, ErrNotFound):
+			panic(web.NotFoundHTTP("文件不存在"))
+		case err != nil:
+			panic(err)
+		}
+		if filename == "" {
+			filename = key[strings.LastIndex(key, "/")+1:]
+		}
+		c.SetContentType("application/octet-stream")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		c.SetBodyStream(rc, int(entry.Size))
+	}
+}
+
+// Run 评估生命周期规则并检查恢复进度（可直接注册为定时任务）
+func (l *Lifecycle) Run(ctx context.Context) error {
+	report, err := l.Apply(ctx)
+	if err != nil {
+		return err
+	}
+	if len(report.Archived)+len(report.Deleted)+len(report.WouldDelete) > 0 {
+		log.Infof("[Lifecycle] 归档 %d 个，删除 %d 个（%d 字节），dryRun 待删除 %d 个，法律保留跳过 %d 个",
+			len(report.Archived), len(report.Deleted), report.Bytes, len(report.WouldDelete), len(report.Held))
+	}
+	return l.CheckRestores(ctx)
+}
+
+// Apply 评估一次生命周期规则，返回报告
+//
+// 主存储中的对象按规则归档或删除；已归档的对象只评估删除规则。法律保留中的对象不会被删除，
+// dryRun 规则只记入报告
+func (l *Lifecycle) Apply(ctx context.Context) (LifecycleReport, error) {
+	var report LifecycleReport
+	now := l.clock.Now()
+	entries, err := l.index.List(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	objects, err := l.listPrimary(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, obj := range objects {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		rule, ok := l.match(obj.Key, now.Sub(obj.ModTime))
+		if !ok {
+			continue
+		}
+		entry := entries[obj.Key]
+		if rule.Action == ActionDelete {
+			if err := l.expire(ctx, obj.Key, obj.Size, entry, rule, l.primary, &report); err != nil {
+				return report, err
+			}
+			continue
+		}
+		if entry.Tier == TierArchive {
+			continue
+		}
+		if err := l.archiveObject(ctx, obj, entry); err != nil {
+			return report, fmt.Errorf("storage: 归档 %s 失败: %w", obj.Key, err)
+		}
+		report.Archived = append(report.Archived, obj.Key)
+		report.Bytes += obj.Size
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		entry := entries[key]
+		if entry.Tier != TierArchive {
+			continue
+		}
+		if rule, ok := l.match(key, now.Sub(entry.ModTime)); ok && rule.Action == ActionDelete {
+			if err := l.expire(ctx, key, entry.Size, entry, rule, l.archive, &report); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// listPrimary 按规则前缀列出主存储中的对象（去重）
+func (l *Lifecycle) listPrimary(ctx context.Context) ([]ObjectInfo, error) {
+	lister := l.primary.(Lister)
+	seen := map[string]bool{}
+	var out []ObjectInfo
+	for _, r := range l.config.Rules {
+		if seen["prefix:"+r.Prefix] {
+			continue
+		}
+		seen["prefix:"+r.Prefix] = true
+		objects, err := lister.List(ctx, r.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if !seen[obj.Key] {
+				seen[obj.Key] = true
+				out = append(out, obj)
+			}
+		}
+	}
+	return out, nil
+}
+
+// match 对象适用的规则：只看前缀最长的规则，其中年龄达到的 Days 最大的一条
+func (l *Lifecycle) match(key string, age time.Duration) (LifecycleRule, bool) {
+	prefix := -1
+	for _, r := range l.config.Rules {
+		if strings.HasPrefix(key, r.Prefix) {
+			prefix = max(prefix, len(r.Prefix))
+		}
+	}
+	var best LifecycleRule
+	found := false
+	for _, r := range l.config.Rules {
+		if len(r.Prefix) == prefix && strings.HasPrefix(key, r.Prefix) &&
+			age >= time.Duration(r.Days)*24*time.Hour && (!found || r.Days > best.Days) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// archiveObject 复制到归档存储、记录索引后删除主存储中的对象
+func (l *Lifecycle) archiveObject(ctx context.Context, obj ObjectInfo, entry IndexEntry) error {
+	src, err := l.primary.Open(ctx, obj.Key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := l.archive.Append(ctx, obj.Key, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	now := l.clock.Now()
+	entry.Tier, entry.Size, entry.ModTime, entry.ArchivedAt = TierArchive, obj.Size, obj.ModTime, &now
+	if err := l.index.Put(ctx, obj.Key, entry); err != nil {
+		return err
+	}
+	metrics.GetCounter("storage_lifecycle_bytes_total", "action", "archived").Add(obj.Size)
+	return l.primary.Delete(ctx, obj.Key)
+}
+
+// expire 执行删除规则（法律保留跳过，dryRun 只记入报告）
+func (l *Lifecycle) expire(ctx context.Context, key string, size int64, entry IndexEntry, rule LifecycleRule, from Storage, report *LifecycleReport) error {
+	switch {
+	case entry.Hold:
+		report.Held = append(report.Held, key)
+		return nil
+	case rule.DryRun:
+		report.WouldDelete = append(report.WouldDelete, key)
+		return nil
+	}
+	if err := from.Delete(ctx, key); err != nil {
+		return fmt.Errorf("storage: 删除 %s 失败: %w", key, err)
+	}
+	if entry.Tier != "" {
+		if err := l.index.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	metrics.GetCounter("storage_lifecycle_bytes_total", "action", "deleted").Add(size)
+	report.Deleted = append(report.Deleted, key)
+	report.Bytes += size
+	return nil
+}
+
+// CheckRestores 检查正在恢复的对象，完成的清除恢复标记并触发 OnRestored（Run 中调用）
+func (l *Lifecycle) CheckRestores(ctx context.Context) error {
+	restorer, ok := l.archive.(Restorer)
+	if !ok {
+		return nil
+	}
+	entries, err := l.index.List(ctx)
+	if err != nil {
+		return err
+	}
+	for key, entry := range entries {
+		if !entry.Restoring {
+			continue
+		}
+		ready, err := restorer.Restore(ctx, key)
+		if err != nil {
+			log.Warnf("[Lifecycle] 查询恢复进度失败 %s: %v", key, err)
+			continue
+		}
+		if ready {
+			l.restored(ctx, key, entry)
+		}
+	}
+	return nil
+}
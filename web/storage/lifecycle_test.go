@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage 内存存储（写入时间取自 clock）
+type memStorage struct {
+	mu      sync.Mutex
+	clock   common.Clock
+	objects map[string]memObject
+}
+
+type memObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+func newMemStorage(clock common.Clock) *memStorage {
+	return &memStorage{clock: clock, objects: map[string]memObject{}}
+}
+
+func (m *memStorage) put(key, data string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memObject{data: []byte(data), modTime: m.clock.Now()}
+}
+
+func (m *memStorage) has(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[key]
+	return ok
+}
+
+type memWriter struct {
+	bytes.Buffer
+	m   *memStorage
+	key string
+}
+
+func (w *memWriter) Close() error {
+	w.m.put(w.key, w.String())
+	return nil
+}
+
+func (m *memStorage) Append(ctx context.Context, key string, offset int64) (io.WriteCloser, error) {
+	w := &memWriter{m: m, key: key}
+	m.mu.Lock()
+	if obj, ok := m.objects[key]; ok {
+		w.Write(obj.data[:min(offset, int64(len(obj.data)))])
+	}
+	m.mu.Unlock()
+	return w, nil
+}
+
+func (m *memStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (m *memStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memStorage) SignedURL(ctx context.Context, key, filename string, ttl time.Duration) (string, error) {
+	return "mem://" + key, nil
+}
+
+func (m *memStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []ObjectInfo
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, ObjectInfo{Key: key, Size: int64(len(obj.data)), ModTime: obj.modTime})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// coldStorage 冷归档：Restore 之后要等 ready 被设置才能读取
+type coldStorage struct {
+	*memStorage
+	requested map[string]bool
+	ready     map[string]bool
+}
+
+func (c *coldStorage) Restore(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested[key] = true
+	return c.ready[key], nil
+}
+
+func (c *coldStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	ready := c.ready[key]
+	c.mu.Unlock()
+	if !ready {
+		return nil, ErrRestoring
+	}
+	return c.memStorage.Open(ctx, key)
+}
+
+func readAll(t *testing.T, rc io.ReadCloser, err error) string {
+	t.Helper()
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func lifecycleBytes(action string) int64 {
+	return metrics.GetCounter("storage_lifecycle_bytes_total", "action", action).Value()
+}
+
+func TestLifecycle_ArchiveRetrieveAndExpire(t *testing.T) {
+	ctx := context.Background()
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	primary, archive := newMemStorage(clock), newMemStorage(clock)
+	primary.put("uploads/contract.pdf", "signed contract")
+	primary.put("uploads/evidence.pdf", "evidence")
+	primary.put("uploads/tmp/draft.txt", "draft")
+	primary.put("exports/report.csv", "a,b")
+
+	files, err := NewLifecycle(primary, archive, nil, LifecycleConfig{Rules: []LifecycleRule{
+		{Prefix: "uploads/", Days: 90, Action: ActionArchive},
+		{Prefix: "uploads/", Days: 365, Action: ActionDelete},
+		{Prefix: "uploads/tmp/", Days: 30, Action: ActionDelete, DryRun: true},
+	}}, clock)
+	require.NoError(t, err)
+	require.NoError(t, files.Hold(ctx, "uploads/evidence.pdf", true))
+	archived, restored, deleted := lifecycleBytes("archived"), lifecycleBytes("restored"), lifecycleBytes("deleted")
+
+	clock.Advance(31 * 24 * time.Hour)
+	report, err := files.Apply(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Archived)
+	assert.Equal(t, []string{"uploads/tmp/draft.txt"}, report.WouldDelete, "dryRun 只报告")
+	assert.True(t, primary.has("uploads/tmp/draft.txt"))
+
+	clock.Advance(60 * 24 * time.Hour)
+	report, err = files.Apply(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uploads/contract.pdf", "uploads/evidence.pdf"}, report.Archived, "法律保留不影响归档")
+	assert.False(t, primary.has("uploads/contract.pdf"))
+	assert.True(t, archive.has("uploads/contract.pdf"))
+	assert.True(t, primary.has("exports/report.csv"), "没有规则的前缀不处理")
+	assert.Equal(t, archived+int64(len("signed contract")+len("evidence")), lifecycleBytes("archived"))
+
+	// 归档后读取透明地走归档存储
+	rc, err := files.Open(ctx, "uploads/contract.pdf")
+	assert.Equal(t, "signed contract", readAll(t, rc, err))
+	assert.Equal(t, restored+int64(len("signed contract")), lifecycleBytes("restored"))
+	report, err = files.Apply(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Archived, "已归档的对象不重复归档")
+
+	clock.Advance(275 * 24 * time.Hour)
+	report, err = files.Apply(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uploads/contract.pdf"}, report.Deleted)
+	assert.Equal(t, []string{"uploads/evidence.pdf"}, report.Held)
+	assert.False(t, archive.has("uploads/contract.pdf"))
+	_, err = files.Open(ctx, "uploads/contract.pdf")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, deleted+int64(len("signed contract")), lifecycleBytes("deleted"))
+
+	assert.ErrorIs(t, files.Delete(ctx, "uploads/evidence.pdf"), ErrLegalHold)
+	require.NoError(t, files.Hold(ctx, "uploads/evidence.pdf", false))
+	require.NoError(t, files.Delete(ctx, "uploads/evidence.pdf"))
+	assert.False(t, archive.has("uploads/evidence.pdf"))
+}
+
+func TestLifecycle_HandlerRestoresColdArchive(t *testing.T) {
+	ctx := context.Background()
+	clock := common.NewFakeClock(time.Now())
+	dir := t.TempDir()
+	primary := NewLocal(dir, "/files", []byte("secret"), clock)
+	cold := &coldStorage{memStorage: newMemStorage(clock), requested: map[string]bool{}, ready: map[string]bool{}}
+	index := NewFileIndex(filepath.Join(t.TempDir(), "lifecycle.json"))
+
+	files, err := NewLifecycle(primary, cold, index, LifecycleConfig{
+		Rules:      []LifecycleRule{{Prefix: "uploads/", Days: 90, Action: ActionArchive}},
+		Restore:    RestoreAsync,
+		RetryAfter: 120,
+	}, clock)
+	require.NoError(t, err)
+	var notified []string
+	files.OnRestored(func(ctx context.Context, key string) { notified = append(notified, key) })
+
+	w, err := files.Append(ctx, "uploads/scan.png", 0)
+	require.NoError(t, err)
+	io.WriteString(w, "png-bytes")
+	require.NoError(t, w.Close())
+	link, err := files.SignedURL(ctx, "uploads/scan.png", "scan.png", 365*24*time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.GET("/files/*key", files.Handler())
+	download := func() *ut.ResponseRecorder {
+		return ut.PerformRequest(engine, "GET", u.Path+"?"+u.RawQuery, nil)
+	}
+	require.Equal(t, 200, download().Code)
+
+	old := clock.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "uploads", "scan.png"), old, old))
+	clock.Advance(91 * 24 * time.Hour)
+	require.NoError(t, files.Run(ctx))
+	_, err = os.Stat(filepath.Join(dir, "uploads", "scan.png"))
+	assert.True(t, os.IsNotExist(err), "已移出主存储")
+
+	// 冷归档：先发起恢复，响应 202 + Retry-After
+	resp := download()
+	assert.Equal(t, 202, resp.Code)
+	assert.Equal(t, "120", resp.Header().Get("Retry-After"))
+	assert.True(t, cold.requested["uploads/scan.png"])
+	entry, _, _ := index.Get(ctx, "uploads/scan.png")
+	assert.True(t, entry.Restoring)
+
+	require.NoError(t, files.CheckRestores(ctx))
+	assert.Empty(t, notified, "恢复未完成")
+	cold.ready["uploads/scan.png"] = true
+	require.NoError(t, files.Run(ctx))
+	assert.Equal(t, []string{"uploads/scan.png"}, notified)
+
+	// 归档前签发的链接仍然有效
+	resp = download()
+	require.Equal(t, 200, resp.Code)
+	assert.Equal(t, "png-bytes", resp.Body.String())
+	assert.Contains(t, resp.Header().Get("Content-Disposition"), "scan.png")
+
+	reopened := NewFileIndex(index.path)
+	entry, ok, err := reopened.Get(ctx, "uploads/scan.png")
+	require.NoError(t, err)
+	assert.True(t, ok, "索引持久化到文件")
+	assert.Equal(t, TierArchive, entry.Tier)
+}
+
+func TestNewLifecycle_Validation(t *testing.T) {
+	clock := common.NewFakeClock(time.Now())
+	primary, archive := newMemStorage(clock), newMemStorage(clock)
+	_, err := NewLifecycle(primary, archive, nil, LifecycleConfig{Rules: []LifecycleRule{{Prefix: "a/", Days: 0, Action: ActionDelete}}}, nil)
+	assert.Error(t, err)
+	_, err = NewLifecycle(primary, archive, nil, LifecycleConfig{Rules: []LifecycleRule{{Prefix: "a/", Days: 1, Action: "move"}}}, nil)
+	assert.Error(t, err)
+	_, err = NewLifecycle(primary, archive, nil, LifecycleConfig{Restore: "later"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal_AppendTruncates(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLocal(dir, "/files", []byte("secret"), nil)
+	ctx := context.Background()
+
+	w, err := l.Append(ctx, "a/b.csv", 0)
+	require.NoError(t, err)
+	io.WriteString(w, "header\nrow1\nrow2-partial")
+	require.NoError(t, w.Close())
+
+	// 续写：丢弃最后确认位置之后的数据
+	w, err = l.Append(ctx, "a/b.csv", int64(len("header\nrow1\n")))
+	require.NoError(t, err)
+	io.WriteString(w, "row2\n")
+	require.NoError(t, w.Close())
+
+	r, err := l.Open(ctx, "a/b.csv")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "header\nrow1\nrow2\n", string(data))
+
+	require.NoError(t, l.Delete(ctx, "a/b.csv"))
+	require.NoError(t, l.Delete(ctx, "a/b.csv"))
+	_, err = l.Open(ctx, "a/b.csv")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLocal_KeyStaysInsideDir(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLocal(filepath.Join(dir, "files"), "/files", []byte("secret"), nil)
+
+	w, err := l.Append(context.Background(), "../../escape.txt", 0)
+	require.NoError(t, err)
+	w.Close()
+	_, err = os.Stat(filepath.Join(dir, "files", "escape.txt"))
+	assert.NoError(t, err, ".. 被限制在存储目录内")
+
+	_, err = l.SignedURL(context.Background(), "/", "x", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package tsclient
+
+// header 生成文件的头部注释
+const header = "// Code generated by github.com/CenJIl/base/web/tsclient. DO NOT EDIT.\n"
+
+// typesPrelude types.ts 中与路由无关的公共类型（统一响应格式与分页）
+const typesPrelude = `
+/** 统一响应格式 */
+export interface Result<T = unknown> {
+  code: number;
+  message: string;
+  data: T;
+  traceId?: string;
+  impersonating?: boolean;
+}
+
+/** 页码分页数据（web.PagedSuccess） */
+export interface PagedData<T> {
+  items: T[];
+  page: number;
+  pageSize: number;
+  total: number;
+  totalPage: number;
+}
+
+/** 游标分页数据（web.CursorPagedSuccess） */
+export interface CursorPagedData<T> {
+  items: T[];
+  nextCursor?: string;
+  prevCursor?: string;
+  hasMore: boolean;
+}
+
+/** 游标分页参数（nextCursor 传给 after，prevCursor 传给 before） */
+export interface CursorPageQuery {
+  after?: string;
+  before?: string;
+  limit?: number;
+}
+`
+
+// clientRuntime client.ts 中的请求封装：拼接地址、序列化参数、注入认证头、拆开统一响应格式
+const clientRuntime = `
+export interface ClientOptions {
+  /** 接口地址前缀，如 "https://api.example.com"（默认同源） */
+  baseUrl?: string;
+  /** 自定义 fetch（默认全局 fetch） */
+  fetch?: typeof fetch;
+  /** 每个请求附加的请求头 */
+  headers?: Record<string, string>;
+  /** 需要认证的路由调用，返回认证请求头（如 { Authorization: "Bearer ..." }） */
+  auth?: () => Record<string, string> | Promise<Record<string, string>>;
+}
+
+let clientOptions: ClientOptions = {};
+
+/** 设置客户端选项（应用启动时调用一次） */
+export function configureClient(options: ClientOptions): void {
+  clientOptions = options;
+}
+
+/** 接口返回的错误（HTTP 状态码与统一响应格式中的业务码、消息、追踪 ID、附加数据） */
+export class ApiError<D = unknown> extends Error {
+  readonly status: number;
+  readonly code: number;
+  readonly traceId?: string;
+  readonly data?: D;
+
+  constructor(status: number, code: number, message: string, traceId?: string, data?: D) {
+    super(message);
+    this.name = "ApiError";
+    this.status = status;
+    this.code = code;
+    this.traceId = traceId;
+    this.data = data;
+  }
+}
+
+export type Params = object;
+
+export interface RequestSpec {
+  method: string;
+  path: string;
+  query?: Params;
+  body?: unknown;
+  form?: Params;
+  auth: boolean;
+}
+
+function entries(params: Params): [string, string | Blob][] {
+  const list: [string, string | Blob][] = [];
+  for (const [key, value] of Object.entries(params)) {
+    if (value === undefined || value === null) {
+      continue;
+    }
+    for (const item of Array.isArray(value) ? value : [value]) {
+      list.push([key, item instanceof Blob ? item : item instanceof Date ? item.toISOString() : String(item)]);
+    }
+  }
+  return list;
+}
+
+function queryString(query?: Params): string {
+  if (!query) {
+    return "";
+  }
+  const search = new URLSearchParams();
+  for (const [key, value] of entries(query)) {
+    search.append(key, value as string);
+  }
+  const s = search.toString();
+  return s ? "?" + s : "";
+}
+
+/** 发送请求，返回原始 Response（原始路由使用） */
+export async function send(spec: RequestSpec, init: RequestInit = {}): Promise<Response> {
+  const headers = new Headers(init.headers);
+  for (const [key, value] of Object.entries(clientOptions.headers ?? {})) {
+    headers.set(key, value);
+  }
+  if (spec.auth && clientOptions.auth) {
+    for (const [key, value] of Object.entries(await clientOptions.auth())) {
+      headers.set(key, value);
+    }
+  }
+  let body: BodyInit | undefined;
+  if (spec.form) {
+    const form = new FormData();
+    for (const [key, value] of entries(spec.form)) {
+      form.append(key, value);
+    }
+    body = form;
+  } else if (spec.body !== undefined) {
+    headers.set("Content-Type", "application/json");
+    body = JSON.stringify(spec.body);
+  }
+  const url = (clientOptions.baseUrl ?? "") + spec.path + queryString(spec.query);
+  return (clientOptions.fetch ?? fetch)(url, { ...init, method: spec.method, headers, body });
+}
+
+/** 发送请求并拆开统一响应格式：成功返回 data，失败抛出 ApiError */
+export async function request<T>(spec: RequestSpec, init?: RequestInit): Promise<T> {
+  const res = await send(spec, init);
+  const text = await res.text();
+  let result: Result<T> | undefined;
+  try {
+    result = text ? (JSON.parse(text) as Result<T>) : undefined;
+  } catch {
+    result = undefined;
+  }
+  if (!result || typeof result.code !== "number") {
+    throw new ApiError(res.status, -1, text || res.statusText);
+  }
+  if (!res.ok || result.code !== 0) {
+    throw new ApiError(res.status, result.code, result.message, result.traceId, result.data);
+  }
+  return result.data;
+}
+`
+
+// 路径参数编码（只在用到时生成，避免未使用的声明）
+const (
+	paramHelper = `
+function param(value: string | number): string {
+  return encodeURIComponent(String(value));
+}
+`
+	wildcardHelper = `
+function wildcard(value: string): string {
+  return value.split("/").map(encodeURIComponent).join("/");
+}
+`
+)
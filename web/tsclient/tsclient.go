@@ -0,0 +1,359 @@
+// Package tsclient 根据路由表生成 TypeScript 接口客户端
+//
+// 请求与响应类型来自路由上声明的 web.Example（与契约测试共用），生成两个文件：
+//   - types.ts：请求/响应类型的 interface（按 json 标签命名，time.Time 为 string，
+//     登记过的枚举为字面量联合类型）、统一响应格式与分页类型
+//   - client.ts：每条路由一个函数，负责路径参数替换、查询参数序列化、文件上传（FormData）、
+//     认证头注入，并拆开统一响应格式：成功返回 data，失败抛出带业务码的 ApiError
+//
+// 原始路由（web.RawHandler）返回 fetch 的 Response，不拆响应格式；
+// 废弃的路由（Router.Deprecated）标注 @deprecated。输出按路径与类型名排序，同一份路由表生成的结果完全一致
+//
+// 使用方式（在构建脚本或 go generate 调用的工具中）：
+//
+//	h := newServer()                      // 注册完路由，不需要启动
+//	routes, _ := web.ValidateRoutes(h.Engine)
+//	files, err := tsclient.Generate(routes, tsclient.Options{TrimPrefix: "/api"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = tsclient.Write("frontend/src/api", files)
+package tsclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/apischema"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// Options 生成选项
+type Options struct {
+	TrimPrefix string                   // 生成函数名时去掉的路径前缀（如 "/api"，GET /api/orders → getOrders）
+	Include    func(web.RouteInfo) bool // 需要生成的路由，默认为通过 web.Router 注册的全部路由
+}
+
+// File 生成的文件
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// 响应 data 的形状
+const (
+	shapePlain  = iota
+	shapePaged  // web.PagedData
+	shapeCursor // web.CursorPagedData
+)
+
+// endpoint 一条路由的生成信息
+type endpoint struct {
+	route    web.RouteInfo
+	name     string
+	request  reflect.Type // 请求类型，没有声明时为 nil
+	response reflect.Type // data 的类型（分页时为元素类型），没有声明时为 nil
+	shape    int
+	declared bool // 是否声明了示例（没有示例时参数与响应都是宽松类型）
+}
+
+// Generate 根据路由表生成 types.ts 与 client.ts
+//
+// 请求/响应类型中有不能编码为 JSON 的字段（chan、func 等）时返回错误
+func Generate(routes []web.RouteInfo, opts Options) ([]File, error) {
+	include := opts.Include
+	if include == nil {
+		include = func(r web.RouteInfo) bool { return !r.Direct }
+	}
+	var selected []web.RouteInfo
+	for _, r := range routes {
+		if include(r) {
+			selected = append(selected, r)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Path != selected[j].Path {
+			return selected[i].Path < selected[j].Path
+		}
+		return selected[i].Method < selected[j].Method
+	})
+
+	types := newTypeSet()
+	endpoints := make([]endpoint, 0, len(selected))
+	names := map[string]int{}
+	for _, r := range selected {
+		e := endpoint{route: r, declared: len(r.Examples) > 0}
+		for _, ex := range r.Examples {
+			if e.request == nil && ex.Request != nil {
+				e.request = reflect.TypeOf(ex.Request)
+			}
+			if e.response == nil && ex.Response != nil && (ex.Status == 0 || ex.Status < 400) {
+				e.response, e.shape = responseShape(ex.Response)
+			}
+		}
+		for _, t := range []reflect.Type{e.request, e.response} {
+			if t == nil {
+				continue
+			}
+			if err := types.collect(t, t.String()); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", r.Method, r.Path, err)
+			}
+		}
+		e.name = functionName(r.Method, strings.TrimPrefix(r.Path, opts.TrimPrefix))
+		if n := names[e.name]; n > 0 {
+			names[e.name] = n + 1
+			e.name = fmt.Sprintf("%s%d", e.name, n+1)
+		} else {
+			names[e.name] = 1
+		}
+		endpoints = append(endpoints, e)
+	}
+	types.assignNames()
+
+	var typesTS strings.Builder
+	typesTS.WriteString(header)
+	typesTS.WriteString(typesPrelude)
+	for _, n := range types.sorted() {
+		typesTS.WriteString("\n")
+		fmt.Fprintf(&typesTS, "/** %s */\n", n.t.String())
+		typesTS.WriteString(types.declaration(n))
+	}
+
+	var functions strings.Builder
+	for _, e := range endpoints {
+		functions.WriteString("\n")
+		functions.WriteString(renderEndpoint(types, e))
+	}
+	body := functions.String()
+	imports := []string{"Result"}
+	for _, name := range append([]string{"CursorPageQuery", "CursorPagedData", "PagedData"}, declaredNames(types)...) {
+		if regexp.MustCompile(`\b` + name + `\b`).MatchString(body) {
+			imports = append(imports, name)
+		}
+	}
+	sort.Strings(imports)
+
+	var clientTS strings.Builder
+	clientTS.WriteString(header)
+	fmt.Fprintf(&clientTS, "\nimport type { %s } from \"./types\";\n", strings.Join(imports, ", "))
+	clientTS.WriteString(clientRuntime)
+	if strings.Contains(body, "${param(") {
+		clientTS.WriteString(paramHelper)
+	}
+	if strings.Contains(body, "${wildcard(") {
+		clientTS.WriteString(wildcardHelper)
+	}
+	clientTS.WriteString(body)
+
+	return []File{
+		{Name: "types.ts", Content: []byte(typesTS.String())},
+		{Name: "client.ts", Content: []byte(clientTS.String())},
+	}, nil
+}
+
+// Write 把生成的文件写入目录（目录不存在时创建）
+func Write(dir string, files []File) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.Name), f.Content, 0644); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func declaredNames(types *typeSet) []string {
+	var names []string
+	for _, n := range types.sorted() {
+		names = append(names, n.name)
+	}
+	return names
+}
+
+// responseShape 示例响应的 data 类型；分页数据返回元素类型（Items 为 nil 时元素类型未知）
+func responseShape(v any) (reflect.Type, int) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	var items any
+	shape := shapePlain
+	switch rv.Type() {
+	case pagedDataType:
+		items, shape = rv.Interface().(web.PagedData).Items, shapePaged
+	case cursorPagedType:
+		items, shape = rv.Interface().(web.CursorPagedData).Items, shapeCursor
+	default:
+		return rv.Type(), shapePlain
+	}
+	t := reflect.TypeOf(items)
+	if t == nil {
+		return nil, shape
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t, shape
+}
+
+// renderEndpoint 一条路由的函数
+func renderEndpoint(types *typeSet, e endpoint) string {
+	r := e.route
+	var doc []string
+	doc = append(doc, r.Method+" "+r.Path)
+	if r.Raw != nil {
+		doc = append(doc, "", "原始路由（不使用统一响应格式，返回 fetch 的 Response）: "+r.Raw.Reason)
+		if r.Raw.ExternalDocs != "" {
+			doc = append(doc, "@see "+r.Raw.ExternalDocs)
+		}
+	}
+	if r.Deprecated != "" {
+		doc = append(doc, "", "@deprecated "+r.Deprecated)
+	}
+
+	var args, fields []string
+	path, params := pathTemplate(r.Path)
+	if len(params) > 0 {
+		args = append(args, "path: { "+strings.Join(params, "; ")+" }")
+	}
+	fields = append(fields, fmt.Sprintf("method: %q", r.Method), "path: "+path)
+
+	queryMethod := r.Method == consts.MethodGet || r.Method == consts.MethodHead ||
+		r.Method == consts.MethodDelete || r.Method == consts.MethodOptions
+	switch {
+	case e.request != nil && queryMethod:
+		args = append(args, "query?: "+compact(types.tsType(e.request)))
+		fields = append(fields, "query")
+	case e.request != nil && apischema.HasFile(e.request):
+		args = append(args, "form: "+compact(types.tsType(e.request)))
+		fields = append(fields, "form")
+	case e.request != nil:
+		args = append(args, "body: "+compact(types.tsType(e.request)))
+		fields = append(fields, "body")
+	case e.shape == shapeCursor && queryMethod:
+		args = append(args, "query?: CursorPageQuery")
+		fields = append(fields, "query")
+	case !e.declared && queryMethod:
+		args = append(args, "query?: Record<string, string | number | boolean>")
+		fields = append(fields, "query")
+	case !e.declared:
+		args = append(args, "body?: unknown")
+		fields = append(fields, "body")
+	}
+	args = append(args, "init?: RequestInit")
+	if r.Auth == web.AuthPublic {
+		fields = append(fields, "auth: false")
+	} else {
+		fields = append(fields, "auth: true")
+	}
+
+	var b strings.Builder
+	b.WriteString("/**\n")
+	for _, line := range doc {
+		if line == "" {
+			b.WriteString(" *\n")
+			continue
+		}
+		b.WriteString(" * " + line + "\n")
+	}
+	b.WriteString(" */\n")
+	spec := "{ " + strings.Join(fields, ", ") + " }"
+	if r.Raw != nil {
+		fmt.Fprintf(&b, "export function %s(%s): Promise<Response> {\n", e.name, strings.Join(args, ", "))
+		fmt.Fprintf(&b, "  return send(%s, init);\n}\n", spec)
+		return b.String()
+	}
+	data := "unknown"
+	if e.response != nil {
+		data = compact(types.tsType(e.response))
+	}
+	switch e.shape {
+	case shapePaged:
+		data = "PagedData<" + data + ">"
+	case shapeCursor:
+		data = "CursorPagedData<" + data + ">"
+	}
+	fmt.Fprintf(&b, "export function %s(%s): Promise<%s> {\n", e.name, strings.Join(args, ", "), data)
+	fmt.Fprintf(&b, "  return request<%s>(%s, init);\n}\n", data, spec)
+	return b.String()
+}
+
+// compact 把匿名结构体的多行类型字面量合并为一行（用于函数签名）
+func compact(expr string) string {
+	return newlines.ReplaceAllString(expr, " ")
+}
+
+var newlines = regexp.MustCompile(`\s*\n\s*`)
+
+// pathTemplate 路由路径对应的模板字符串与路径参数类型（:id → ${param(path.id)}，*rest → ${wildcard(path.rest)}）
+func pathTemplate(routePath string) (string, []string) {
+	segments := strings.Split(routePath, "/")
+	var params []string
+	for i, s := range segments {
+		switch {
+		case strings.HasPrefix(s, ":"):
+			segments[i] = "${param(" + accessor(s[1:]) + ")}"
+			params = append(params, propertyName(s[1:])+": string | number")
+		case strings.HasPrefix(s, "*"):
+			segments[i] = "${wildcard(" + accessor(s[1:]) + ")}"
+			params = append(params, propertyName(s[1:])+": string")
+		}
+	}
+	return "`" + strings.Join(segments, "/") + "`", params
+}
+
+func accessor(name string) string {
+	if isIdentifier(name) {
+		return "path." + name
+	}
+	return fmt.Sprintf("path[%q]", name)
+}
+
+// functionName 方法与路径组成的函数名：GET /orders/:id/items → getOrdersByIdItems
+func functionName(method, routePath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	empty := true
+	for _, s := range strings.Split(routePath, "/") {
+		if s == "" {
+			continue
+		}
+		empty = false
+		if s[0] == ':' || s[0] == '*' {
+			b.WriteString("By")
+			s = s[1:]
+		}
+		b.WriteString(pascal(s))
+	}
+	if empty {
+		b.WriteString("Root")
+	}
+	return b.String()
+}
+
+// pascal 按非字母数字字符分词后首字母大写拼接（order-items → OrderItems）
+func pascal(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9':
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
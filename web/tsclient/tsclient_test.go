@@ -0,0 +1,157 @@
+package tsclient
+
+import (
+	"context"
+	"flag"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/web"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+type OrderStatus string
+
+const (
+	OrderPending OrderStatus = "pending"
+	OrderPaid    OrderStatus = "paid"
+)
+
+type Audit struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type OrderItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type Order struct {
+	ID       int64             `json:"id,string"`
+	Status   OrderStatus       `json:"status"`
+	Items    []OrderItem       `json:"items"`
+	Note     *string           `json:"note"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Shipping struct {
+		City string `json:"city"`
+	} `json:"shipping"`
+	internal string
+	Audit
+}
+
+type CreateOrderReq struct {
+	Items  []OrderItem `json:"items" vd:"len($)>0"`
+	Coupon string      `json:"coupon,omitempty"`
+}
+
+type ListOrdersQuery struct {
+	Status   OrderStatus `query:"status"`
+	Page     int         `query:"page"`
+	PageSize int         `query:"pageSize"`
+}
+
+type GetOrderReq struct {
+	ID     int64 `path:"id"`
+	Expand bool  `query:"expand"`
+}
+
+type UploadAttachmentReq struct {
+	File    *multipart.FileHeader `form:"file"`
+	Comment string                `form:"comment"`
+}
+
+type Event struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+func newTestRoutes(t *testing.T) []web.RouteInfo {
+	RegisterEnum(OrderPending, OrderPaid)
+	engine := route.NewEngine(config.NewOptions(nil))
+	r := web.NewRouter(engine)
+	noop := web.WrapHandler(func(ctx context.Context, c *app.RequestContext) error { return nil })
+
+	api := r.Group("/api").RequireAuth()
+	api.WithExamples(web.Example{Request: ListOrdersQuery{}, Response: web.PagedData{Items: []Order{}}}).GET("/orders", noop)
+	api.WithExamples(web.Example{Request: CreateOrderReq{}, Response: Order{}}).POST("/orders", noop)
+	api.WithExamples(web.Example{Request: GetOrderReq{}, Response: &Order{}},
+		web.Example{Name: "missing", Path: "/api/orders/0", Status: 404}).GET("/orders/:id", noop)
+	api.WithExamples(web.Example{Request: UploadAttachmentReq{}, Response: map[string]string{}}).POST("/orders/:id/attachments", noop)
+	api.WithExamples(web.Example{Response: web.CursorPagedData{Items: []Event{}}}).GET("/events", noop)
+	api.Deprecated("改用 GET /api/orders").GET("/order-list", noop)
+	api.DELETE("/orders/:id", noop)
+	api.GET("/files/*filepath", noop)
+	r.Public().WithExamples(web.Example{Response: struct {
+		Status string `json:"status"`
+	}{}}).GET("/api/ping", noop)
+	r.Group("/scim").POST("/Users", web.RawHandler(func(ctx context.Context, c *app.RequestContext) {},
+		web.RawReason("SCIM 2.0 响应体"), web.RawExternalDocs("https://www.rfc-editor.org/rfc/rfc7644")))
+	engine.GET("/health", func(ctx context.Context, c *app.RequestContext) {})
+
+	routes, conflicts := web.ValidateRoutes(engine)
+	require.Empty(t, conflicts)
+	return routes
+}
+
+func TestGenerate_Golden(t *testing.T) {
+	files, err := Generate(newTestRoutes(t), Options{TrimPrefix: "/api"})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	for _, f := range files {
+		golden := filepath.Join("testdata", f.Name+".golden")
+		if *update {
+			require.NoError(t, os.WriteFile(golden, f.Content, 0644))
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		require.NoError(t, err, "运行 go test ./web/tsclient -update 生成 golden 文件")
+		assert.Equal(t, string(want), string(f.Content), f.Name)
+	}
+}
+
+func TestGenerate_Deterministic(t *testing.T) {
+	routes := newTestRoutes(t)
+	first, err := Generate(routes, Options{})
+	require.NoError(t, err)
+
+	reversed := make([]web.RouteInfo, len(routes))
+	for i, r := range routes {
+		reversed[len(routes)-1-i] = r
+	}
+	for range 5 {
+		again, err := Generate(reversed, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, first, again, "输出与路由顺序、map 遍历顺序无关")
+	}
+}
+
+func TestGenerate_UnsupportedType(t *testing.T) {
+	type Bad struct {
+		Callback func() `json:"callback"`
+	}
+	_, err := Generate([]web.RouteInfo{{
+		Method: "POST", Path: "/bad",
+		Examples: []web.Example{{Request: Bad{}}},
+	}}, Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "POST /bad")
+	assert.Contains(t, err.Error(), "Callback")
+}
+
+func TestFunctionName(t *testing.T) {
+	assert.Equal(t, "getOrdersByIdItems", functionName("GET", "/orders/:id/items"))
+	assert.Equal(t, "postOrderItems", functionName("POST", "/order-items"))
+	assert.Equal(t, "getFilesByFilepath", functionName("GET", "/files/*filepath"))
+	assert.Equal(t, "getRoot", functionName("GET", "/"))
+}
@@ -0,0 +1,280 @@
+package tsclient
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/apischema"
+)
+
+var (
+	pagedDataType     = reflect.TypeFor[web.PagedData]()
+	cursorPagedType   = reflect.TypeFor[web.CursorPagedData]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// RegisterEnum 登记枚举类型的全部取值，生成的 TypeScript 类型为字面量联合类型
+//
+// 与 apischema.RegisterEnum 相同（路由清单中同样记录取值）；取值按 JSON 编码
+// （字符串枚举生成 "a" | "b"，数值枚举生成 1 | 2），顺序与登记顺序一致
+//
+// 使用方式：
+//
+//	type OrderStatus string
+//
+//	const (
+//	    OrderPending OrderStatus = "pending"
+//	    OrderPaid    OrderStatus = "paid"
+//	)
+//
+//	func init() {
+//	    tsclient.RegisterEnum(OrderPending, OrderPaid)
+//	}
+func RegisterEnum[T comparable](values ...T) {
+	apischema.RegisterEnum(values...)
+}
+
+// namedType 生成为独立声明的 Go 类型（结构体与枚举）
+type namedType struct {
+	t    reflect.Type
+	name string
+	enum []string
+}
+
+// typeSet 收集路由用到的命名类型并分配 TypeScript 名称
+type typeSet struct {
+	types map[reflect.Type]*namedType
+}
+
+func newTypeSet() *typeSet {
+	return &typeSet{types: map[reflect.Type]*namedType{}}
+}
+
+// collect 登记 t 及其引用的所有命名类型（path 用于报告不支持的类型）
+func (s *typeSet) collect(t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if _, ok := s.types[t]; ok {
+		return nil
+	}
+	if values, ok := apischema.EnumValues(t); ok {
+		s.types[t] = &namedType{t: t, enum: values}
+		return nil
+	}
+	if apischema.Opaque(t) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() != "" {
+			s.types[t] = &namedType{t: t}
+		}
+		for _, f := range apischema.Fields(t) {
+			if err := s.collect(f.Type, path+"."+f.GoName); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		return s.collect(t.Elem(), path+"[]")
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			if !t.Key().Implements(textMarshalerType) {
+				return fmt.Errorf("%s: 不支持的 map 键类型 %s", path, t.Key())
+			}
+		}
+		return s.collect(t.Elem(), path+"[]")
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Errorf("%s: 类型 %s 不能编码为 JSON", path, t)
+	}
+	return nil
+}
+
+// assignNames 为命名类型分配 TypeScript 名称：同名类型加包名前缀区分，结果与收集顺序无关
+func (s *typeSet) assignNames() {
+	byName := map[string][]*namedType{}
+	for _, n := range s.types {
+		name := typeName(n.t)
+		byName[name] = append(byName[name], n)
+	}
+	used := map[string]bool{}
+	var names []string
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		group := byName[name]
+		if len(group) == 1 && !used[name] {
+			group[0].name = name
+			used[name] = true
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].t.PkgPath() < group[j].t.PkgPath() })
+		for _, n := range group {
+			candidate := pascal(lastElem(n.t.PkgPath())) + name
+			for i := 2; used[candidate]; i++ {
+				candidate = fmt.Sprintf("%s%s%d", pascal(lastElem(n.t.PkgPath())), name, i)
+			}
+			n.name = candidate
+			used[candidate] = true
+		}
+	}
+}
+
+// sorted 按 TypeScript 名称排序的命名类型
+func (s *typeSet) sorted() []*namedType {
+	list := make([]*namedType, 0, len(s.types))
+	for _, n := range s.types {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// typeName Go 类型名对应的 TypeScript 名称（泛型实例 Page[pkg.User] → PageUser）
+func typeName(t reflect.Type) string {
+	name := t.Name()
+	base, args, ok := strings.Cut(name, "[")
+	if !ok {
+		return base
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		arg = strings.TrimLeft(strings.TrimSpace(arg), "*[]")
+		if i := strings.LastIndexByte(arg, '.'); i >= 0 {
+			arg = arg[i+1:]
+		}
+		b.WriteString(pascal(arg))
+	}
+	return b.String()
+}
+
+func lastElem(pkgPath string) string {
+	if i := strings.LastIndexByte(pkgPath, '/'); i >= 0 {
+		return pkgPath[i+1:]
+	}
+	return pkgPath
+}
+
+// tsType Go 类型对应的 TypeScript 类型表达式（命名类型需已 collect 并 assignNames）
+func (s *typeSet) tsType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n, ok := s.types[t]; ok {
+		return n.name
+	}
+	switch t {
+	case apischema.TimeType:
+		return "string"
+	case apischema.FileHeaderType:
+		return "Blob"
+	case apischema.RawMessageType:
+		return "unknown"
+	}
+	if apischema.JSONMarshaler(t) {
+		return "unknown"
+	}
+	if apischema.TextMarshaler(t) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return "string" // []byte 编码为 base64 字符串
+		}
+		return arrayOf(s.tsType(t.Elem()))
+	case reflect.Map:
+		return "Record<string, " + s.tsType(t.Elem()) + ">"
+	case reflect.Struct:
+		return s.inlineStruct(t, "")
+	}
+	return "unknown"
+}
+
+func arrayOf(elem string) string {
+	if strings.ContainsAny(elem, " |") {
+		return "(" + elem + ")[]"
+	}
+	return elem + "[]"
+}
+
+// declaration 命名类型的声明
+func (s *typeSet) declaration(n *namedType) string {
+	if n.enum != nil {
+		return fmt.Sprintf("export type %s = %s;\n", n.name, strings.Join(n.enum, " | "))
+	}
+	return "export interface " + n.name + " " + s.inlineStruct(n.t, "") + "\n"
+}
+
+// inlineStruct 结构体的对象类型字面量
+func (s *typeSet) inlineStruct(t reflect.Type, indent string) string {
+	fields := apischema.Fields(t)
+	if len(fields) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, f := range fields {
+		typ := s.tsType(f.Type)
+		if f.AsString {
+			typ = "string"
+		}
+		if f.Type.Kind() == reflect.Struct && s.types[f.Type] == nil && !apischema.Opaque(f.Type) {
+			typ = s.inlineStruct(f.Type, indent+"  ")
+		}
+		optional := ""
+		switch {
+		case f.OmitEmpty:
+			optional = "?"
+		case f.Type.Kind() == reflect.Pointer && f.Type.Elem() != apischema.FileHeaderType:
+			optional = "?"
+			typ += " | null"
+		}
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, propertyName(f.Name), optional, typ)
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// propertyName 对象属性名（不是合法标识符时加引号）
+func propertyName(name string) string {
+	if isIdentifier(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
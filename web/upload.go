@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,12 +11,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CenJIl/base/web/scan"
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
 // UploadMiddleware 上传中间件（验证 + 限制）
 //
-// 检查 Content-Type 并将配置保存到上下文，供 handler 使用
+// 检查 Content-Type 并将配置保存到上下文，供 handler 使用；
+// 请求头 X-Content-SHA256 放入 ctx，SaveUploadedFileContext / SaveUploadedFiles 保存时校验
 //
 // 使用方式：
 //
@@ -30,42 +33,174 @@ func UploadMiddleware(config UploadConfig) app.HandlerFunc {
 
 		// 保存配置到上下文（handler 中使用）
 		c.Set("upload_config", config)
+
+		// 客户端声明的摘要：保存文件时校验，不一致时拒绝
+		if expected := string(c.GetHeader(HeaderContentSHA256)); expected != "" {
+			ctx = WithExpectedSHA256(ctx, expected)
+		}
 		c.Next(ctx)
 	}
 }
 
 // SaveUploadedFile 保存上传文件到指定路径
 //
-// 自动创建父目录
+// 自动创建父目录；配置了扫描器时先写入临时文件扫描，通过后再移动到 dst
 //
 // 使用方式：
 //
 //	file, _ := c.FormFile("file")
 //	err := web.SaveUploadedFile(file, "/path/to/save/filename.ext")
 func SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	_, err := SaveUploadedFileContext(context.Background(), file, dst)
+	return err
+}
+
+// SaveUploadedFileContext 保存上传文件并返回扫描结果
+//
+// 含病毒时返回 *ErrFileInfected，扫描服务不可用且 failClosed 时返回 *ErrScanUnavailable，
+// 扫描结果可通过 result.AuditData() 附加到上传的审计记录
+//
+// 使用方式：
+//
+//	result, err := web.SaveUploadedFileContext(ctx, file, dst)
+//	audit.Emit(ctx, audit.Event{Type: "upload.create", Actor: userID, Data: result.AuditData()})
+func SaveUploadedFileContext(ctx context.Context, file *multipart.FileHeader, dst string) (scan.Result, error) {
+	saved, err := SaveUploadedFileInfo(ctx, file, dst)
+	return saved.Scan, err
+}
+
+// SaveUploadedFileInfo 保存上传文件，返回扫描结果与摘要
+//
+// 写入临时文件时同步计算 SHA-256（开启 checksum.md5 时同时计算 MD5）；
+// ctx 中有期望的 SHA-256（UploadMiddleware 读取的 X-Content-SHA256）且不一致时返回 *ErrChecksumMismatch，
+// 文件不会移动到 dst。成功后摘要以 dst 为 key 写入 SetChecksumStore 注册的摘要存储
+//
+// 使用方式：
+//
+//	saved, err := web.SaveUploadedFileInfo(ctx, file, dst)
+//	return web.Success(saved) // {"filename": ..., "digests": {"sha256": ..., "size": ...}}
+func SaveUploadedFileInfo(ctx context.Context, file *multipart.FileHeader, dst string) (UploadedFile, error) {
+	saved := UploadedFile{Filename: file.Filename, Path: dst}
 	src, err := file.Open()
 	if err != nil {
-		return fmt.Errorf("打开上传文件失败: %w", err)
+		return saved, fmt.Errorf("打开上传文件失败: %w", err)
 	}
 	defer src.Close()
 
+	saved.Scan, saved.Digests, err = commitUpload(ctx, src, dst, file.Filename)
+	return saved, err
+}
+
+// commitUpload 写入 dst 同目录的临时文件并计算摘要，校验摘要与扫描通过后原子移动到 dst 并记录摘要
+func commitUpload(ctx context.Context, src io.Reader, dst, name string) (scan.Result, Digests, error) {
 	// 确保目录存在
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
+		return scan.Result{}, Digests{}, fmt.Errorf("创建目录失败: %w", err)
 	}
 
-	dstFile, err := os.Create(dst)
+	// 先写入同目录的临时文件，校验与扫描通过后再原子移动
+	tmpFile, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
 	if err != nil {
-		return fmt.Errorf("创建目标文件失败: %w", err)
+		return scan.Result{}, Digests{}, fmt.Errorf("创建目标文件失败: %w", err)
 	}
-	defer dstFile.Close()
+	tmpPath := tmpFile.Name()
 
-	_, err = io.Copy(dstFile, src)
+	d := newDigester()
+	_, err = io.Copy(io.MultiWriter(tmpFile, d), src)
+	tmpFile.Close()
+	digests := d.digests()
 	if err != nil {
-		return fmt.Errorf("写入文件失败: %w", err)
+		os.Remove(tmpPath)
+		return scan.Result{}, digests, fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := verifyExpected(dst, expectedSHA256(ctx), digests); err != nil {
+		os.Remove(tmpPath)
+		return scan.Result{}, digests, err
 	}
 
-	return nil
+	result, err := scanUploadedFile(ctx, tmpPath, name)
+	if err != nil {
+		os.Remove(tmpPath)
+		return result, digests, err
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return result, digests, fmt.Errorf("移动文件失败: %w", err)
+	}
+	if err := recordDigests(ctx, dst, digests); err != nil {
+		os.Remove(dst)
+		return result, digests, err
+	}
+	return result, digests, nil
+}
+
+// SaveRequestBodyStream 把请求体以流的方式写入 dst（大文件直传，按带宽配置限速）
+//
+// 需要服务器开启 server.WithStreamBody(true) 才能边接收边写入；
+// 同样经过临时文件 + 摘要校验 + 扫描，通过后再移动到 dst
+//
+// 使用方式：
+//
+//	h.PUT("/files/:name", func(ctx context.Context, c *app.RequestContext) {
+//	    n, err := web.SaveRequestBodyStream(ctx, c, filepath.Join(dir, c.Param("name")))
+//	})
+func SaveRequestBodyStream(ctx context.Context, c *app.RequestContext, dst string) (int64, error) {
+	saved, err := SaveRequestBodyStreamInfo(ctx, c, dst)
+	return saved.Digests.Size, err
+}
+
+// SaveRequestBodyStreamInfo 与 SaveRequestBodyStream 相同，返回扫描结果与摘要
+//
+// 请求头 X-Content-SHA256 与实际内容不一致时返回 *ErrChecksumMismatch，文件不会移动到 dst
+func SaveRequestBodyStreamInfo(ctx context.Context, c *app.RequestContext, dst string) (UploadedFile, error) {
+	saved := UploadedFile{Filename: filepath.Base(dst), Path: dst}
+	var src io.Reader = bytes.NewReader(c.Request.Body())
+	if c.Request.IsBodyStream() {
+		src = c.RequestBodyStream()
+	}
+	if expected := string(c.GetHeader(HeaderContentSHA256)); expected != "" {
+		ctx = WithExpectedSHA256(ctx, expected)
+	}
+	var err error
+	saved.Scan, saved.Digests, err = commitUpload(ctx, throttleUpload(ctx, c, src), dst, saved.Filename)
+	return saved, err
+}
+
+// UploadedFile 上传文件的保存结果
+type UploadedFile struct {
+	Filename string      `json:"filename"` // 原始文件名
+	Path     string      `json:"path"`     // 保存路径
+	Scan     scan.Result `json:"scan"`     // 扫描结果
+	Digests  Digests     `json:"digests"`  // 摘要
+}
+
+// SaveUploadedFiles 校验并保存多个上传文件到 config.UploadPath
+//
+// 任一文件校验、摘要或扫描失败时立即返回错误，已保存的文件保留在返回值中。
+// X-Content-SHA256 只描述一个文件，带该请求头上传多个文件时返回 400
+//
+// 使用方式：
+//
+//	form, _ := c.MultipartForm()
+//	saved, err := web.SaveUploadedFiles(ctx, form.File["files"], config.Upload)
+func SaveUploadedFiles(ctx context.Context, files []*multipart.FileHeader, config UploadConfig) ([]UploadedFile, error) {
+	if len(files) > 1 && expectedSHA256(ctx) != "" {
+		return nil, BadRequestHTTP(HeaderContentSHA256 + " 只能用于单个文件上传")
+	}
+	saved := make([]UploadedFile, 0, len(files))
+	for _, file := range files {
+		if err := ValidateFile(file, config); err != nil {
+			return saved, err
+		}
+		dst := filepath.Join(config.UploadPath, GenerateFilename(file.Filename))
+		uploaded, err := SaveUploadedFileInfo(ctx, file, dst)
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, uploaded)
+	}
+	return saved, nil
 }
 
 // IsAllowedExt 检查文件扩展名是否允许
@@ -100,7 +235,7 @@ func IsAllowedExt(filename string, allowedExts []string) bool {
 //	}
 func ValidateFile(file *multipart.FileHeader, config UploadConfig) error {
 	// 检查大小
-	if file.Size > config.MaxFileSize {
+	if file.Size > config.MaxFileSize.Bytes() {
 		return fmt.Errorf("文件大小超限：%.2f MB / %.2f MB",
 			float64(file.Size)/1024/1024,
 			float64(config.MaxFileSize)/1024/1024)
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/scan"
+)
+
+// ScanConfig 上传文件扫描配置
+//
+// 未配置 clamavAddress 时不扫描。扫描发生在大小/扩展名校验之后、文件移动到最终位置之前。
+//
+// Example:
+//
+//	[web.upload.scan]
+//	clamavAddress = "tcp://127.0.0.1:3310"   # 或 unix:///var/run/clamav/clamd.ctl
+//	timeout = 30                             # 秒
+//	maxStreamSize = 26214400                 # 字节，需不大于 clamd 的 StreamMaxLength
+//	failClosed = true                        # 扫描服务不可用时拒绝上传（高安全部署）
+//	quarantinePath = "./quarantine"          # 感染文件隔离目录，为空则直接删除
+type ScanConfig struct {
+	ClamAVAddress  string `toml:"clamavAddress"`  // clamd 地址
+	Timeout        int    `toml:"timeout"`        // 扫描超时（秒），默认 30
+	MaxStreamSize  int64  `toml:"maxStreamSize"`  // 最大扫描字节数，默认 25MB
+	FailClosed     bool   `toml:"failClosed"`     // 扫描器不可用时是否拒绝
+	QuarantinePath string `toml:"quarantinePath"` // 隔离目录
+}
+
+// ErrFileInfected 上传文件含病毒
+type ErrFileInfected struct {
+	Signature string // 命中的特征名
+}
+
+func (e *ErrFileInfected) Error() string {
+	return fmt.Sprintf("File rejected: malware detected (%s)", e.Signature)
+}
+
+// ErrScanUnavailable 扫描服务不可用且配置为 failClosed
+type ErrScanUnavailable struct {
+	Err error // 原始错误
+}
+
+func (e *ErrScanUnavailable) Error() string {
+	return fmt.Sprintf("File rejected: scanner unavailable: %v", e.Err)
+}
+
+// uploadScanner 全局扫描器与策略
+type uploadScanner struct {
+	scanner scan.Scanner
+	config  ScanConfig
+}
+
+var globalScanner atomic.Pointer[uploadScanner]
+
+var (
+	scanDurationMs = metrics.GetCounter("web_upload_scan_duration_ms_total")
+	scanCounters   = map[string]*metrics.Counter{
+		scan.VerdictClean:    metrics.GetCounter("web_upload_scan_total", "verdict", scan.VerdictClean),
+		scan.VerdictInfected: metrics.GetCounter("web_upload_scan_total", "verdict", scan.VerdictInfected),
+		scan.VerdictError:    metrics.GetCounter("web_upload_scan_total", "verdict", scan.VerdictError),
+	}
+)
+
+// InitScanner 根据配置初始化上传扫描（未配置 clamavAddress 时使用不扫描的默认实现）
+func InitScanner(config ScanConfig) {
+	if config.ClamAVAddress == "" {
+		SetScanner(nil, config)
+		return
+	}
+	SetScanner(&scan.ClamAV{
+		Address:       config.ClamAVAddress,
+		Timeout:       time.Duration(config.Timeout) * time.Second,
+		MaxStreamSize: config.MaxStreamSize,
+	}, config)
+	log.Infof("[Scan] 上传扫描已启用: %s (failClosed=%v)", config.ClamAVAddress, config.FailClosed)
+}
+
+// SetScanner 设置上传扫描器（nil 表示不扫描），可用于接入自定义扫描服务
+func SetScanner(scanner scan.Scanner, config ScanConfig) {
+	if scanner == nil {
+		scanner = scan.Noop{}
+	}
+	globalScanner.Store(&uploadScanner{scanner: scanner, config: config})
+}
+
+// scanUploadedFile 扫描临时文件；感染时按配置隔离或删除
+func scanUploadedFile(ctx context.Context, tmpPath, originalName string) (scan.Result, error) {
+	s := globalScanner.Load()
+	if s == nil {
+		return scan.Result{Verdict: scan.VerdictSkipped, Scanner: "noop"}, nil
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return scan.Result{}, fmt.Errorf("打开待扫描文件失败: %w", err)
+	}
+	start := time.Now()
+	result, err := s.scanner.Scan(ctx, f)
+	f.Close()
+	if result.Duration == 0 {
+		result.Duration = time.Since(start)
+	}
+	scanDurationMs.Add(result.Duration.Milliseconds())
+
+	if err != nil {
+		result.Verdict = scan.VerdictError
+		scanCounters[scan.VerdictError].Inc()
+		if s.config.FailClosed {
+			log.Errorf("[Scan] 扫描服务不可用，拒绝上传 %s: %v", originalName, err)
+			return result, &ErrScanUnavailable{Err: err}
+		}
+		log.Warnf("[Scan] !!! 扫描服务不可用，文件 %s 未经扫描即放行 (failClosed=false): %v", originalName, err)
+		return result, nil
+	}
+
+	if c, ok := scanCounters[result.Verdict]; ok {
+		c.Inc()
+	}
+	if !result.Infected() {
+		return result, nil
+	}
+
+	data := result.AuditData()
+	data["filename"] = originalName
+	if s.config.QuarantinePath != "" {
+		quarantined := filepath.Join(s.config.QuarantinePath, fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(originalName)))
+		if err := os.MkdirAll(s.config.QuarantinePath, 0700); err == nil && os.Rename(tmpPath, quarantined) == nil {
+			data["quarantine"] = quarantined
+		} else {
+			os.Remove(tmpPath)
+		}
+	} else {
+		os.Remove(tmpPath)
+	}
+	log.Warnf("[Scan] 拒绝感染文件 %s: %s", originalName, result.Signature)
+	audit.Emit(ctx, audit.Event{Type: "upload.infected", Data: data})
+	return result, &ErrFileInfected{Signature: result.Signature}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CenJIl/base/web/scan"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanner 内容包含 EICAR 特征时判定为感染
+type fakeScanner struct {
+	err error
+}
+
+func (s fakeScanner) Scan(ctx context.Context, r io.Reader) (scan.Result, error) {
+	if s.err != nil {
+		return scan.Result{Scanner: "fake"}, s.err
+	}
+	data, _ := io.ReadAll(r)
+	if strings.Contains(string(data), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+		return scan.Result{Verdict: scan.VerdictInfected, Signature: "Eicar-Test-Signature", Scanner: "fake"}, nil
+	}
+	return scan.Result{Verdict: scan.VerdictClean, Scanner: "fake"}, nil
+}
+
+func newFileHeader(t *testing.T, name, content string) *multipart.FileHeader {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", name)
+	require.NoError(t, err)
+	part.Write([]byte(content))
+	require.NoError(t, w.Close())
+
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form.File["file"][0]
+}
+
+func TestUploadScan_InfectedQuarantined(t *testing.T) {
+	dir := t.TempDir()
+	quarantine := filepath.Join(dir, "quarantine")
+	SetScanner(fakeScanner{}, ScanConfig{QuarantinePath: quarantine})
+	t.Cleanup(func() { SetScanner(nil, ScanConfig{}) })
+
+	dst := filepath.Join(dir, "files", "clean.txt")
+	result, err := SaveUploadedFileContext(context.Background(), newFileHeader(t, "clean.txt", "hello"), dst)
+	require.NoError(t, err)
+	assert.Equal(t, scan.VerdictClean, result.Verdict)
+	assert.FileExists(t, dst)
+
+	dst = filepath.Join(dir, "files", "virus.txt")
+	infectedBefore := scanCounters[scan.VerdictInfected].Value()
+	result, err = SaveUploadedFileContext(context.Background(), newFileHeader(t, "virus.txt", `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`), dst)
+	var infected *ErrFileInfected
+	require.True(t, errors.As(err, &infected))
+	assert.Equal(t, "Eicar-Test-Signature", infected.Signature)
+	assert.True(t, result.Infected())
+	assert.NoFileExists(t, dst)
+	assert.Equal(t, int64(1), scanCounters[scan.VerdictInfected].Value()-infectedBefore)
+
+	// 感染文件被隔离，上传目录中不留临时文件
+	quarantined, _ := os.ReadDir(quarantine)
+	assert.Len(t, quarantined, 1)
+	uploaded, _ := os.ReadDir(filepath.Join(dir, "files"))
+	assert.Len(t, uploaded, 1)
+}
+
+func TestUploadScan_ScannerUnavailable(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { SetScanner(nil, ScanConfig{}) })
+	down := fakeScanner{err: errors.New("connection refused")}
+
+	SetScanner(down, ScanConfig{FailClosed: true})
+	_, err := SaveUploadedFileContext(context.Background(), newFileHeader(t, "a.txt", "data"), filepath.Join(dir, "a.txt"))
+	var unavailable *ErrScanUnavailable
+	assert.True(t, errors.As(err, &unavailable))
+	assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+
+	SetScanner(down, ScanConfig{FailClosed: false})
+	result, err := SaveUploadedFileContext(context.Background(), newFileHeader(t, "b.txt", "data"), filepath.Join(dir, "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, scan.VerdictError, result.Verdict)
+	assert.FileExists(t, filepath.Join(dir, "b.txt"))
+}
+
+func TestUploadScan_ErrorResponses(t *testing.T) {
+	down := &ErrScanUnavailable{Err: errors.New("dial tcp 10.0.3.7:3310: connection refused")}
+	infected := &ErrFileInfected{Signature: "Eicar-Test-Signature"}
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(ExceptionHandler())
+	engine.GET("/returned", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return fmt.Errorf("保存头像: %w", down)
+	}))
+	engine.GET("/panicked", func(ctx context.Context, c *app.RequestContext) { panic(fmt.Errorf("保存头像: %w", down)) })
+	engine.GET("/infected", WrapHandler(func(ctx context.Context, c *app.RequestContext) error {
+		return fmt.Errorf("保存附件: %w", infected)
+	}))
+
+	for _, path := range []string{"/returned", "/panicked"} {
+		w := ut.PerformRequest(engine, "GET", path, nil)
+		assert.Equal(t, 503, w.Code, path)
+		assert.Contains(t, w.Body.String(), fmt.Sprintf(`"code":%d`, ScanUnavailable), path)
+		assert.Contains(t, w.Body.String(), MsgScanUnavailable, path)
+		assert.NotContains(t, w.Body.String(), "10.0.3.7", "%s 不暴露扫描服务地址", path)
+	}
+
+	w := ut.PerformRequest(engine, "GET", "/infected", nil)
+	assert.Equal(t, 422, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"code":%d`, FileInfected))
+	assert.Contains(t, w.Body.String(), "Eicar-Test-Signature")
+}
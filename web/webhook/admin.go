@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/audit"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// EventView 管理接口返回的事件（附带原始请求体）
+type EventView struct {
+	Event
+	Payload string `json:"payload"`
+}
+
+func viewOf(e Event) EventView {
+	return EventView{Event: e, Payload: string(e.Payload)}
+}
+
+// ListHandler 事件查询接口（需要自行挂在有权限控制的路由组下）
+//
+// 查询参数：status（默认 dead）、provider、limit（默认 100，最大 500）；status=all 查询全部状态
+//
+// 使用方式：
+//
+//	admin.GET("/webhooks", receiver.ListHandler())
+func (r *Receiver) ListHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		filter := Filter{
+			Provider: c.Query("provider"),
+			Status:   c.DefaultQuery("status", StatusDead),
+		}
+		if filter.Status == "all" {
+			filter.Status = ""
+		}
+		filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+		events, err := r.List(ctx, filter)
+		if err != nil {
+			logger.Errorf("[Webhook] 查询事件失败: %v", err)
+			panic(web.InternalHTTP("查询 Webhook 事件失败"))
+		}
+		views := make([]EventView, len(events))
+		for i, e := range events {
+			views[i] = viewOf(e)
+		}
+		c.JSON(consts.StatusOK, web.Success(views))
+	}
+}
+
+// RedriveHandler 手动重新投递死信事件（路由需要 :id 参数，需要自行挂在有权限控制的路由组下）
+//
+// 使用方式：
+//
+//	admin.POST("/webhooks/:id/redrive", receiver.RedriveHandler())
+func (r *Receiver) RedriveHandler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			panic(web.BadRequestHTTP("无效的事件 ID"))
+		}
+		switch err := r.Redrive(ctx, id); {
+		case errors.Is(err, ErrNotFound):
+			panic(web.NotFoundHTTP("Webhook 事件不存在"))
+		case errors.Is(err, ErrNotDead):
+			panic(web.ConflictHTTP("只有死信事件可以重新投递"))
+		case err != nil:
+			logger.Errorf("[Webhook] 重新投递事件 %d 失败: %v", id, err)
+			panic(web.InternalHTTP("重新投递失败"))
+		}
+
+		e, _ := r.store.Get(ctx, id)
+		audit.Emit(ctx, audit.Event{
+			Type:  "webhook.redrive",
+			Actor: jwt.GetActor(c),
+			Data:  map[string]any{"id": id, "provider": e.Provider, "eventId": e.EventID},
+		})
+		c.JSON(consts.StatusOK, web.Success(viewOf(e)))
+	}
+}
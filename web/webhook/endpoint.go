@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// DefaultSignatureHeader 默认签名请求头
+const DefaultSignatureHeader = "Webhook-Signature"
+
+// 拒绝原因（同时作为指标标签）
+const (
+	RejectMissing      = "missing"       // 缺少签名头
+	RejectExpired      = "expired"       // 时间戳超出容忍窗口
+	RejectBadSignature = "bad_signature" // 签名不匹配
+	RejectBadPayload   = "bad_payload"   // 无法解析事件 ID
+)
+
+// VerifyConfig 入站 Webhook 验签配置
+//
+// 签名头格式为 "t=<unix 秒>,v1=<hex(HMAC-SHA256(secret, t + "." + body))>"，
+// 服务商轮换密钥期间可能带多个 v1
+type VerifyConfig struct {
+	Secrets   []string      // 有效密钥（轮换期间配置新旧两个）
+	SecretKey string        // 密钥环中的密钥名（由本端生成并轮换、再同步给发送方的密钥，优先于 Secrets）
+	Header    string        // 签名请求头，默认 DefaultSignatureHeader
+	Tolerance time.Duration // 时间戳容忍窗口，默认 5 分钟
+	// Parse 从请求体取事件 ID 与类型，默认读取 JSON 的 "id" 与 "type" 字段
+	Parse func(body []byte) (eventID, eventType string, err error)
+}
+
+// Sign 生成签名头（服务商侧的实现，测试与本地联调使用）
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify 校验签名头，返回拒绝原因（通过时为空）
+func (v VerifyConfig) verify(header string, body []byte, now time.Time) string {
+	var timestamp string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+	if timestamp == "" || len(sigs) == 0 {
+		return RejectMissing
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return RejectExpired
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > v.Tolerance || skew < -v.Tolerance {
+		return RejectExpired
+	}
+
+	matches := func(secret []byte) bool {
+		want := signature(string(secret), timestamp, body)
+		for _, sig := range sigs {
+			if hmac.Equal([]byte(sig), []byte(want)) {
+				return true
+			}
+		}
+		return false
+	}
+	if v.SecretKey != "" {
+		if kr := keyring.Default(); kr != nil {
+			if _, ok := kr.Verify(v.SecretKey, matches); ok {
+				return ""
+			}
+		}
+		return RejectBadSignature
+	}
+	for _, secret := range v.Secrets {
+		if matches([]byte(secret)) {
+			return ""
+		}
+	}
+	return RejectBadSignature
+}
+
+// parseJSONEvent 默认的事件解析：JSON 的 "id" 与 "type" 字段
+func parseJSONEvent(body []byte) (string, string, error) {
+	var e struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return "", "", err
+	}
+	if e.ID == "" {
+		return "", "", errors.New("缺少事件 ID")
+	}
+	return e.ID, e.Type, nil
+}
+
+// Endpoint 注册服务商的处理器并返回接收端点
+//
+// 端点只验签和落库：落库成功（或事件 ID 已存在）立即返回 200，处理器在后台执行；
+// 落库超过 ackTimeout 或失败时返回 503 让服务商稍后重投（重投会被去重）。
+// 验签失败返回 401，无法解析事件 ID 返回 400，这两类服务商重投也不会成功
+//
+// 使用方式：
+//
+//	h.POST("/webhooks/stripe", receiver.Endpoint("stripe", webhook.VerifyConfig{Secrets: secrets}, handleStripe))
+func (r *Receiver) Endpoint(provider string, verify VerifyConfig, handler Handler) app.HandlerFunc {
+	if verify.Header == "" {
+		verify.Header = DefaultSignatureHeader
+	}
+	if verify.Tolerance <= 0 {
+		verify.Tolerance = 5 * time.Minute
+	}
+	if verify.Parse == nil {
+		verify.Parse = parseJSONEvent
+	}
+	r.mu.Lock()
+	r.handlers[provider] = handler
+	r.mu.Unlock()
+
+	ackTimeout := time.Duration(r.config.AckTimeout) * time.Millisecond
+	received := metrics.GetCounter("webhook_received_total", "provider", provider)
+	duplicate := metrics.GetCounter("webhook_duplicate_total", "provider", provider)
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		body := c.Request.Body()
+		now := r.clock.Now()
+		if reason := verify.verify(string(c.GetHeader(verify.Header)), body, now); reason != "" {
+			reject(c, provider, consts.StatusUnauthorized, reason)
+			return
+		}
+		eventID, eventType, err := verify.Parse(body)
+		if err != nil {
+			logger.Warnf("[Webhook] %s 事件解析失败: %v", provider, err)
+			reject(c, provider, consts.StatusBadRequest, RejectBadPayload)
+			return
+		}
+
+		// 请求体在请求结束后会被复用，落库前先复制
+		e := Event{
+			Provider:    provider,
+			EventID:     eventID,
+			Type:        eventType,
+			Payload:     append([]byte(nil), body...),
+			ReceivedAt:  now,
+			NextAttempt: now,
+		}
+		created, err := r.persist(ctx, &e, ackTimeout)
+		if err != nil {
+			metrics.GetCounter("webhook_persist_failed_total", "provider", provider).Inc()
+			logger.Errorf("[Webhook] %s 事件 %s 落库失败: %v", provider, eventID, err)
+			result := web.Fail(503, "Webhook temporarily unavailable")
+			result.TraceID = middleware.GetRequestID(c)
+			c.AbortWithStatusJSON(consts.StatusServiceUnavailable, result)
+			return
+		}
+
+		if created {
+			received.Inc()
+			r.notify()
+		} else {
+			duplicate.Inc()
+		}
+		c.JSON(consts.StatusOK, web.Success(map[string]any{"received": true, "duplicate": !created}))
+	}
+}
+
+// persist 在 ackTimeout 内落库；存储不响应 ctx 取消时也按时返回
+//
+// 超时后落库可能仍会完成，服务商重投时按重复事件应答，不会重复处理
+func (r *Receiver) persist(ctx context.Context, e *Event, ackTimeout time.Duration) (bool, error) {
+	ackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancel()
+
+	type result struct {
+		created bool
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, created, err := r.store.Insert(ackCtx, e)
+		done <- result{created, err}
+	}()
+	select {
+	case res := <-done:
+		return res.created, res.err
+	case <-ackCtx.Done():
+		return false, ackCtx.Err()
+	}
+}
+
+// reject 拒绝无效请求并计数
+func reject(c *app.RequestContext, provider string, status int, reason string) {
+	metrics.GetCounter("webhook_rejected_total", "provider", provider, "reason", reason).Inc()
+	logger.Warnf("[Webhook] 拒绝 %s 事件: %s", provider, reason)
+	result := web.Fail(status, "Webhook rejected: "+reason)
+	result.TraceID = middleware.GetRequestID(c)
+	c.AbortWithStatusJSON(status, result)
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CenJIl/base/web/database"
+)
+
+// MigrationMySQL 事件表结构（MySQL 8.0+，领取依赖 SKIP LOCKED）
+const MigrationMySQL = `CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    event_id VARCHAR(191) NOT NULL,
+    event_type VARCHAR(128) NOT NULL DEFAULT '',
+    payload MEDIUMBLOB NOT NULL,
+    received_at DATETIME(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt DATETIME(3) NOT NULL,
+    last_error TEXT,
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+    lease_until DATETIME(3) NULL,
+    UNIQUE KEY uk_provider_event (provider, event_id),
+    INDEX idx_status_next_attempt (status, next_attempt)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+// MigrationPostgres 事件表结构（PostgreSQL 9.5+）
+const MigrationPostgres = `CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    event_id VARCHAR(191) NOT NULL,
+    event_type VARCHAR(128) NOT NULL DEFAULT '',
+    payload BYTEA NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ,
+    UNIQUE (provider, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status_next_attempt ON webhook_events (status, next_attempt)`
+
+// Migrate 创建事件表
+//
+// 使用方式：
+//
+//	if err := webhook.Migrate(ctx, database.DB, config.Database.Driver); err != nil {
+//	    panic(err)
+//	}
+func Migrate(ctx context.Context, db *sql.DB, driver string) error {
+	migration := MigrationMySQL
+	if driver == database.DriverPostgreSQL {
+		migration = MigrationPostgres
+	}
+	for _, stmt := range strings.Split(migration, ";\n") {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("创建 Webhook 事件表失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SQLStore 基于数据库的事件存储（MySQL / PostgreSQL）
+//
+// 领取通过 SELECT ... FOR UPDATE SKIP LOCKED 完成，多个实例并发领取时互不阻塞、不会拿到同一条事件
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewSQLStore 创建数据库存储（db 为 nil 时使用 database.DB）
+func NewSQLStore(db *sql.DB, driver string) *SQLStore {
+	if db == nil {
+		db = database.DB
+	}
+	return &SQLStore{db: db, driver: driver}
+}
+
+const eventColumns = "id, provider, event_id, event_type, payload, received_at, status, attempts, next_attempt, last_error"
+
+// Insert 实现 Store 接口
+func (s *SQLStore) Insert(ctx context.Context, e *Event) (int64, bool, error) {
+	args := []any{e.Provider, e.EventID, e.Type, e.Payload, e.ReceivedAt, e.NextAttempt}
+
+	var id int64
+	if s.driver == database.DriverPostgreSQL {
+		err := s.db.QueryRowContext(ctx, database.Rebind(s.driver, `INSERT INTO webhook_events (provider, event_id, event_type, payload, received_at, next_attempt)
+			VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (provider, event_id) DO NOTHING RETURNING id`), args...).Scan(&id)
+		if err == nil {
+			return id, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, false, err
+		}
+	} else {
+		res, err := s.db.ExecContext(ctx, `INSERT IGNORE INTO webhook_events (provider, event_id, event_type, payload, received_at, next_attempt)
+			VALUES (?, ?, ?, ?, ?, ?)`, args...)
+		if err != nil {
+			return 0, false, err
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			id, err = res.LastInsertId()
+			return id, true, err
+		}
+	}
+
+	// 重复投递：返回已有事件
+	err := s.db.QueryRowContext(ctx, database.Rebind(s.driver, `SELECT id FROM webhook_events WHERE provider = ? AND event_id = ?`),
+		e.Provider, e.EventID).Scan(&id)
+	return id, false, err
+}
+
+// Claim 实现 Store 接口
+func (s *SQLStore) Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Event, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, database.Rebind(s.driver, `SELECT `+eventColumns+` FROM webhook_events
+		WHERE (status = 'pending' AND next_attempt <= ?) OR (status = 'processing' AND lease_until <= ?)
+		ORDER BY next_attempt, id LIMIT ? FOR UPDATE SKIP LOCKED`), now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	args := []any{owner, leaseUntil}
+	marks := make([]string, len(claimed))
+	for i := range claimed {
+		marks[i] = "?"
+		args = append(args, claimed[i].ID)
+		claimed[i].Status = StatusProcessing
+	}
+	if _, err := tx.ExecContext(ctx, database.Rebind(s.driver, `UPDATE webhook_events SET status = 'processing', lease_owner = ?, lease_until = ?
+		WHERE id IN (`+strings.Join(marks, ", ")+`)`), args...); err != nil {
+		return nil, err
+	}
+	return claimed, tx.Commit()
+}
+
+// Complete 实现 Store 接口
+func (s *SQLStore) Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error) {
+	query := `UPDATE webhook_events SET status = ?, attempts = ?, last_error = ?, lease_owner = '', lease_until = NULL`
+	args := []any{outcome.Status, outcome.Attempts, outcome.Error}
+	if outcome.Status == StatusPending {
+		query += `, next_attempt = ?`
+		args = append(args, outcome.NextAttempt)
+	}
+	query += ` WHERE id = ? AND status = 'processing' AND lease_owner = ?`
+	args = append(args, id, owner)
+	res, err := s.db.ExecContext(ctx, database.Rebind(s.driver, query), args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Get 实现 Store 接口
+func (s *SQLStore) Get(ctx context.Context, id int64) (Event, error) {
+	row := s.db.QueryRowContext(ctx, database.Rebind(s.driver, `SELECT `+eventColumns+` FROM webhook_events WHERE id = ?`), id)
+	e, err := scanEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Event{}, ErrNotFound
+	}
+	return e, err
+}
+
+// List 实现 Store 接口
+func (s *SQLStore) List(ctx context.Context, filter Filter) ([]Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM webhook_events WHERE 1 = 1`
+	var args []any
+	if filter.Provider != "" {
+		query += ` AND provider = ?`
+		args = append(args, filter.Provider)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+	rows, err := s.db.QueryContext(ctx, database.Rebind(s.driver, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// Redrive 实现 Store 接口
+func (s *SQLStore) Redrive(ctx context.Context, id int64, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, database.Rebind(s.driver, `UPDATE webhook_events SET status = 'pending', attempts = 0, next_attempt = ?
+		WHERE id = ? AND status = 'dead'`), at, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		return nil
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrNotDead
+}
+
+// Purge 实现 Store 接口
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, database.Rebind(s.driver, `DELETE FROM webhook_events WHERE status = 'processed' AND received_at < ?`), before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row rowScanner) (Event, error) {
+	var e Event
+	var lastError sql.NullString
+	if err := row.Scan(&e.ID, &e.Provider, &e.EventID, &e.Type, &e.Payload, &e.ReceivedAt,
+		&e.Status, &e.Attempts, &e.NextAttempt, &lastError); err != nil {
+		return Event{}, err
+	}
+	e.LastError = lastError.String
+	return e, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+	var out []Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
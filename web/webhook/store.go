@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Outcome 一次处理的结果
+type Outcome struct {
+	Status      string    // StatusProcessed / StatusPending（稍后重试）/ StatusDead
+	Attempts    int       // 累计处理次数
+	NextAttempt time.Time // 重试时间（Status 为 StatusPending 时有效）
+	Error       string    // 最近一次失败原因
+}
+
+// Filter 事件查询条件
+type Filter struct {
+	Provider string // 为空时不限服务商
+	Status   string // 为空时不限状态
+	Limit    int    // 最大条数
+}
+
+// Store 事件存储（outbox：先落库再处理）
+//
+// (Provider, EventID) 唯一，重复投递不会写入第二条；
+// Claim 与 Complete 的租约语义与 notify.Store 相同，多实例部署时同一事件同一时刻只被一个实例处理
+type Store interface {
+	// Insert 写入新事件；(Provider, EventID) 已存在时不写入，返回已有事件的 ID 与 false
+	Insert(ctx context.Context, e *Event) (id int64, created bool, err error)
+	// Claim 领取最多 limit 条到期事件
+	Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Event, error)
+	// Complete 记录处理结果，租约已不属于 owner 时返回 false
+	Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error)
+	// Get 查询事件
+	Get(ctx context.Context, id int64) (Event, error)
+	// List 按条件查询事件（按 ID 倒序）
+	List(ctx context.Context, filter Filter) ([]Event, error)
+	// Redrive 把死信事件置为待处理并重置处理次数（不是死信时返回 ErrNotDead）
+	Redrive(ctx context.Context, id int64, at time.Time) error
+	// Purge 删除 before 之前接收的已处理事件，返回删除条数
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MemoryStore 进程内事件存储（单实例部署与测试使用，重启后丢失）
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	items  map[int64]*memoryItem
+	dedupe map[string]int64 // provider + "\x00" + eventID -> id
+}
+
+type memoryItem struct {
+	e          Event
+	owner      string
+	leaseUntil time.Time
+}
+
+// NewMemoryStore 创建进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[int64]*memoryItem), dedupe: make(map[string]int64)}
+}
+
+func dedupeKey(provider, eventID string) string {
+	return provider + "\x00" + eventID
+}
+
+// Insert 实现 Store 接口
+func (s *MemoryStore) Insert(ctx context.Context, e *Event) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := dedupeKey(e.Provider, e.EventID)
+	if id, ok := s.dedupe[key]; ok {
+		return id, false, nil
+	}
+	s.nextID++
+	item := *e
+	item.ID = s.nextID
+	item.Status = StatusPending
+	s.items[item.ID] = &memoryItem{e: item}
+	s.dedupe[key] = item.ID
+	return item.ID, true, nil
+}
+
+// Claim 实现 Store 接口
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, limit int, owner string, leaseUntil time.Time) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*memoryItem
+	for _, item := range s.items {
+		switch item.e.Status {
+		case StatusPending:
+			if !item.e.NextAttempt.After(now) {
+				due = append(due, item)
+			}
+		case StatusProcessing:
+			if !item.leaseUntil.After(now) {
+				due = append(due, item)
+			}
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].e.NextAttempt.Equal(due[j].e.NextAttempt) {
+			return due[i].e.NextAttempt.Before(due[j].e.NextAttempt)
+		}
+		return due[i].e.ID < due[j].e.ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]Event, 0, len(due))
+	for _, item := range due {
+		item.e.Status = StatusProcessing
+		item.owner, item.leaseUntil = owner, leaseUntil
+		claimed = append(claimed, item.e)
+	}
+	return claimed, nil
+}
+
+// Complete 实现 Store 接口
+func (s *MemoryStore) Complete(ctx context.Context, id int64, owner string, outcome Outcome) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok || item.e.Status != StatusProcessing || item.owner != owner {
+		return false, nil
+	}
+	item.e.Status = outcome.Status
+	item.e.Attempts = outcome.Attempts
+	item.e.LastError = outcome.Error
+	if outcome.Status == StatusPending {
+		item.e.NextAttempt = outcome.NextAttempt
+	}
+	item.owner, item.leaseUntil = "", time.Time{}
+	return true, nil
+}
+
+// Get 实现 Store 接口
+func (s *MemoryStore) Get(ctx context.Context, id int64) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return Event{}, ErrNotFound
+	}
+	return item.e, nil
+}
+
+// List 实现 Store 接口
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Event
+	for _, item := range s.items {
+		if (filter.Provider == "" || item.e.Provider == filter.Provider) && (filter.Status == "" || item.e.Status == filter.Status) {
+			out = append(out, item.e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[:filter.Limit]
+	}
+	return out, nil
+}
+
+// Redrive 实现 Store 接口
+func (s *MemoryStore) Redrive(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if item.e.Status != StatusDead {
+		return ErrNotDead
+	}
+	item.e.Status = StatusPending
+	item.e.Attempts = 0
+	item.e.NextAttempt = at
+	return nil
+}
+
+// Purge 实现 Store 接口
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, item := range s.items {
+		if item.e.Status == StatusProcessed && item.e.ReceivedAt.Before(before) {
+			delete(s.items, id)
+			delete(s.dedupe, dedupeKey(item.e.Provider, item.e.EventID))
+			n++
+		}
+	}
+	return n, nil
+}
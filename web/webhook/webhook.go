@@ -0,0 +1,343 @@
+// Package webhook 入站 Webhook 接收：验签后立即持久化并应答，再异步处理
+//
+// 请求路径上只做验签和落库，业务处理器由后台调度器在独立的 goroutine 中执行，
+// 处理器再慢也不会让服务商看到超时（超时会触发服务商的重试风暴）。
+// 处理失败按指数退避重试，超过次数进入死信，可通过管理接口查看并手动重新投递；
+// 服务商重复投递的事件按事件 ID 去重，保留期内不会重复处理
+//
+// 使用方式：
+//
+//	receiver := webhook.NewReceiver(webhook.NewSQLStore(nil, config.Database.Driver), config.Webhook, nil)
+//	h.POST("/webhooks/stripe", receiver.Endpoint("stripe", webhook.VerifyConfig{
+//	    Secrets: []string{config.Stripe.WebhookSecret},
+//	}, func(ctx context.Context, e webhook.Event) error {
+//	    return billing.HandleStripeEvent(ctx, e.Type, e.Payload)
+//	}))
+//	web.RegisterComponent(receiver.Component(web.ComponentDatabase))
+//
+//	admin := h.Group("/admin/webhooks", jwt.Middleware())
+//	admin.GET("", receiver.ListHandler())
+//	admin.POST("/:id/redrive", receiver.RedriveHandler())
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/google/uuid"
+)
+
+// ComponentWebhook Webhook 处理组件名
+const ComponentWebhook = "webhook"
+
+// 事件状态
+const (
+	StatusPending    = "pending"    // 等待处理（含等待重试）
+	StatusProcessing = "processing" // 已被某个实例领取
+	StatusProcessed  = "processed"  // 处理成功
+	StatusDead       = "dead"       // 重试耗尽（死信）
+)
+
+var (
+	// ErrNotFound 事件不存在
+	ErrNotFound = errors.New("webhook event not found")
+	// ErrNotDead 只有死信事件可以手动重新投递
+	ErrNotDead = errors.New("webhook event is not dead")
+)
+
+// Event 已接收的 Webhook 事件
+type Event struct {
+	ID          int64     `json:"id"`
+	Provider    string    `json:"provider"`    // 服务商（Endpoint 的 provider 参数）
+	EventID     string    `json:"eventId"`     // 服务商的事件 ID（去重键）
+	Type        string    `json:"type"`        // 事件类型
+	Payload     []byte    `json:"-"`           // 原始请求体
+	ReceivedAt  time.Time `json:"receivedAt"`  // 首次接收时间
+	Status      string    `json:"status"`      // 处理状态
+	Attempts    int       `json:"attempts"`    // 累计处理次数
+	NextAttempt time.Time `json:"nextAttempt"` // 下次处理时间
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Handler 事件处理器（返回错误时按退避重试，处理器需要幂等）
+type Handler func(ctx context.Context, e Event) error
+
+// Config Webhook 处理配置
+//
+// Example:
+//
+//	[webhook]
+//	ackTimeout = 2000    # 请求路径上落库的时限（毫秒），超时返回 503 让服务商稍后重投
+//	pollInterval = 5     # 轮询间隔（秒），新事件落库后会立即唤醒处理，不必等待轮询
+//	batchSize = 100      # 每次领取的最大条数
+//	workers = 4          # 并发处理数
+//	maxAttempts = 8      # 最大处理次数，超过后进入死信
+//	baseBackoff = 30     # 首次重试间隔（秒），之后逐次翻倍
+//	maxBackoff = 3600    # 最大重试间隔（秒）
+//	lease = 300          # 领取租约（秒），实例崩溃后其他实例在租约到期后接手
+//	retention = 604800   # 已处理事件的保留期（秒），保留期内同一事件 ID 的重复投递被忽略
+type Config struct {
+	AckTimeout   int `toml:"ackTimeout"`
+	PollInterval int `toml:"pollInterval"`
+	BatchSize    int `toml:"batchSize"`
+	Workers      int `toml:"workers"`
+	MaxAttempts  int `toml:"maxAttempts"`
+	BaseBackoff  int `toml:"baseBackoff"`
+	MaxBackoff   int `toml:"maxBackoff"`
+	Lease        int `toml:"lease"`
+	Retention    int `toml:"retention"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = 2000
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = 5
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 8
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = 30
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 3600
+	}
+	if c.Lease <= 0 {
+		c.Lease = 300
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * 3600
+	}
+	return c
+}
+
+// Receiver Webhook 接收器
+type Receiver struct {
+	store     Store
+	config    Config
+	clock     common.Clock
+	owner     string // 实例标识（领取租约的持有者）
+	wake      chan struct{}
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	lastPurge time.Time
+}
+
+// NewReceiver 创建接收器（clock 为 nil 时使用系统时钟）
+func NewReceiver(store Store, config Config, clock common.Clock) *Receiver {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	hostname, _ := os.Hostname()
+	return &Receiver{
+		store:    store,
+		config:   config.withDefaults(),
+		clock:    clock,
+		owner:    fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8]),
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// handler 查询服务商的处理器
+func (r *Receiver) handler(provider string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[provider]
+	return h, ok
+}
+
+// notify 唤醒后台处理（不阻塞请求路径）
+func (r *Receiver) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get 查询事件
+func (r *Receiver) Get(ctx context.Context, id int64) (Event, error) {
+	return r.store.Get(ctx, id)
+}
+
+// List 按条件查询事件（用于排查失败事件）
+func (r *Receiver) List(ctx context.Context, filter Filter) ([]Event, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	return r.store.List(ctx, filter)
+}
+
+// Redrive 把死信事件重新置为待处理（重置处理次数），立即唤醒后台处理
+func (r *Receiver) Redrive(ctx context.Context, id int64) error {
+	if err := r.store.Redrive(ctx, id, r.clock.Now()); err != nil {
+		return err
+	}
+	metrics.GetCounter("webhook_redriven_total").Inc()
+	r.notify()
+	return nil
+}
+
+// DispatchDue 领取并处理全部到期事件，返回处理的条数
+func (r *Receiver) DispatchDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := r.clock.Now()
+		lease := time.Duration(r.config.Lease) * time.Second
+		batch, err := r.store.Claim(ctx, now, r.config.BatchSize, r.owner, now.Add(lease))
+		if err != nil {
+			return total, fmt.Errorf("webhook: 领取事件失败: %w", err)
+		}
+
+		sem := make(chan struct{}, r.config.Workers)
+		var wg sync.WaitGroup
+		for _, e := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(e Event) {
+				defer func() { <-sem; wg.Done() }()
+				r.process(ctx, e)
+			}(e)
+		}
+		wg.Wait()
+
+		total += len(batch)
+		if len(batch) < r.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// process 处理一条已领取的事件并记录结果
+func (r *Receiver) process(ctx context.Context, e Event) {
+	// 单次处理不超过租约的一半，避免租约过期后被其他实例重复处理
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(r.config.Lease)*time.Second/2)
+	err := r.run(runCtx, e)
+	cancel()
+
+	outcome := Outcome{Attempts: e.Attempts + 1}
+	switch {
+	case err == nil:
+		outcome.Status = StatusProcessed
+		metrics.GetCounter("webhook_processed_total", "provider", e.Provider).Inc()
+	case outcome.Attempts >= r.config.MaxAttempts:
+		outcome.Status, outcome.Error = StatusDead, err.Error()
+		metrics.GetCounter("webhook_failed_total", "provider", e.Provider).Inc()
+		metrics.GetCounter("webhook_dead_total", "provider", e.Provider).Inc()
+		logger.Errorf("[Webhook] %s 事件 %s 进入死信（第 %d 次处理）: %v", e.Provider, e.EventID, outcome.Attempts, err)
+	default:
+		outcome.Status, outcome.Error = StatusPending, err.Error()
+		outcome.NextAttempt = r.clock.Now().Add(r.backoff(outcome.Attempts))
+		metrics.GetCounter("webhook_failed_total", "provider", e.Provider).Inc()
+		logger.Warnf("[Webhook] %s 事件 %s 处理失败（第 %d 次），%v 后重试: %v",
+			e.Provider, e.EventID, outcome.Attempts, outcome.NextAttempt.Sub(r.clock.Now()), err)
+	}
+
+	ok, err := r.store.Complete(context.Background(), e.ID, r.owner, outcome)
+	if err != nil {
+		logger.Errorf("[Webhook] 记录事件 %d 处理结果失败: %v", e.ID, err)
+	} else if !ok {
+		logger.Warnf("[Webhook] 事件 %d 的租约已被其他实例接手，丢弃本次结果", e.ID)
+	}
+}
+
+// run 调用处理器，处理器 panic 按失败处理（进入重试）
+func (r *Receiver) run(ctx context.Context, e Event) (err error) {
+	h, ok := r.handler(e.Provider)
+	if !ok {
+		return fmt.Errorf("服务商 %q 未注册处理器", e.Provider)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			if !common.IsControlFlow(p) {
+				logger.ErrorPanic(p, "[Webhook] %s 事件 %s 处理器 panic: %v", e.Provider, e.EventID, p)
+			}
+			err = fmt.Errorf("处理器 panic: %v", p)
+		}
+	}()
+	return h(ctx, e)
+}
+
+// backoff 第 attempts 次失败后的重试间隔
+func (r *Receiver) backoff(attempts int) time.Duration {
+	return common.Backoff(time.Duration(r.config.BaseBackoff)*time.Second, time.Duration(r.config.MaxBackoff)*time.Second, attempts)
+}
+
+// Purge 删除超过保留期的已处理事件（之后同一事件 ID 的投递会被当作新事件）
+func (r *Receiver) Purge(ctx context.Context) (int64, error) {
+	before := r.clock.Now().Add(-time.Duration(r.config.Retention) * time.Second)
+	return r.store.Purge(ctx, before)
+}
+
+// Run 处理到期事件直到 ctx 取消：新事件落库后立即处理，失败重试按轮询间隔检查；每小时清理一次过期事件
+func (r *Receiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(r.config.PollInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+		if _, err := r.DispatchDue(ctx); err != nil {
+			logger.Errorf("[Webhook] %v", err)
+		}
+		if now := r.clock.Now(); now.Sub(r.lastPurge) >= time.Hour {
+			r.lastPurge = now
+			if n, err := r.Purge(ctx); err != nil {
+				logger.Errorf("[Webhook] 清理过期事件失败: %v", err)
+			} else if n > 0 {
+				logger.Infof("[Webhook] 清理过期事件 %d 条", n)
+			}
+		}
+	}
+}
+
+// Component 接收器的生命周期组件（dependsOn 通常为 web.ComponentDatabase）
+func (r *Receiver) Component(dependsOn ...string) web.Component {
+	var cancel context.CancelFunc
+	var done chan struct{}
+	return web.Component{
+		Name:      ComponentWebhook,
+		DependsOn: dependsOn,
+		Start: func(ctx context.Context) error {
+			var runCtx context.Context
+			runCtx, cancel = context.WithCancel(context.Background())
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				r.Run(runCtx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,352 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/keyring"
+	"github.com/CenJIl/base/web/metrics"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "whsec_test"
+
+var t0 = time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestReceiver(t *testing.T, store Store, cfg Config, provider string, handler Handler) (*Receiver, *route.Engine, *common.FakeClock) {
+	clock := common.NewFakeClock(t0)
+	r := NewReceiver(store, cfg, clock)
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(web.ExceptionHandler())
+	engine.POST("/webhooks/"+provider, r.Endpoint(provider, VerifyConfig{Secrets: []string{"whsec_old", testSecret}}, handler))
+	engine.GET("/admin/webhooks", r.ListHandler())
+	engine.POST("/admin/webhooks/:id/redrive", r.RedriveHandler())
+	return r, engine, clock
+}
+
+func deliver(engine *route.Engine, provider, signature string, body []byte) *ut.ResponseRecorder {
+	return ut.PerformRequest(engine, "POST", "/webhooks/"+provider,
+		&ut.Body{Body: bytes.NewReader(body), Len: len(body)},
+		ut.Header{Key: DefaultSignatureHeader, Value: signature},
+		ut.Header{Key: "Content-Type", Value: "application/json"})
+}
+
+// counterDelta 返回指标此后的增量（指标是进程级的，重复运行测试时仍然成立）
+func counterDelta(name, provider string) func() int64 {
+	c := metrics.GetCounter(name, "provider", provider)
+	before := c.Value()
+	return func() int64 { return c.Value() - before }
+}
+
+func eventBody(id, typ string) []byte {
+	body, _ := json.Marshal(map[string]any{"id": id, "type": typ, "data": map[string]any{"amount": 100}})
+	return body
+}
+
+func TestEndpoint_DuplicateDelivery(t *testing.T) {
+	var calls atomic.Int32
+	r, engine, clock := newTestReceiver(t, NewMemoryStore(), Config{}, "dup", func(ctx context.Context, e Event) error {
+		calls.Add(1)
+		return nil
+	})
+	body := eventBody("evt_1", "invoice.paid")
+	received := counterDelta("webhook_received_total", "dup")
+	duplicate := counterDelta("webhook_duplicate_total", "dup")
+	processed := counterDelta("webhook_processed_total", "dup")
+
+	w := deliver(engine, "dup", Sign(testSecret, clock.Now(), body), body)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"code":0,"message":"success","data":{"received":true,"duplicate":false}}`, w.Body.String())
+
+	// 服务商在处理前重投
+	w = deliver(engine, "dup", Sign(testSecret, clock.Now(), body), body)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"duplicate":true`)
+
+	n, err := r.DispatchDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// 处理完成后再次重投
+	clock.Advance(time.Hour)
+	w = deliver(engine, "dup", Sign(testSecret, clock.Now(), body), body)
+	assert.Contains(t, w.Body.String(), `"duplicate":true`)
+	n, _ = r.DispatchDue(context.Background())
+	assert.Equal(t, 0, n)
+
+	assert.Equal(t, int32(1), calls.Load(), "重复投递只处理一次")
+	assert.Equal(t, int64(1), received())
+	assert.Equal(t, int64(2), duplicate())
+	assert.Equal(t, int64(1), processed())
+}
+
+func TestReceiver_RetryThenSuccess(t *testing.T) {
+	var calls atomic.Int32
+	r, engine, clock := newTestReceiver(t, NewMemoryStore(), Config{BaseBackoff: 30}, "retry", func(ctx context.Context, e Event) error {
+		switch calls.Add(1) {
+		case 1:
+			return errors.New("下游不可用")
+		case 2:
+			panic("nil map")
+		}
+		assert.Equal(t, "charge.succeeded", e.Type)
+		assert.JSONEq(t, string(eventBody("evt_2", "charge.succeeded")), string(e.Payload))
+		return nil
+	})
+	body := eventBody("evt_2", "charge.succeeded")
+	failed := counterDelta("webhook_failed_total", "retry")
+	require.Equal(t, 200, deliver(engine, "retry", Sign(testSecret, clock.Now(), body), body).Code)
+	ctx := context.Background()
+
+	r.DispatchDue(ctx)
+	events, _ := r.List(ctx, Filter{Provider: "retry"})
+	require.Len(t, events, 1)
+	e := events[0]
+	assert.Equal(t, StatusPending, e.Status)
+	assert.Equal(t, 1, e.Attempts)
+	assert.Equal(t, "下游不可用", e.LastError)
+	assert.Equal(t, t0.Add(30*time.Second), e.NextAttempt)
+
+	// 未到重试时间
+	n, _ := r.DispatchDue(ctx)
+	assert.Equal(t, 0, n)
+
+	clock.Advance(30 * time.Second)
+	r.DispatchDue(ctx)
+	e, _ = r.Get(ctx, e.ID)
+	assert.Equal(t, 2, e.Attempts)
+	assert.Contains(t, e.LastError, "处理器 panic: nil map")
+	assert.Equal(t, clock.Now().Add(60*time.Second), e.NextAttempt, "退避翻倍")
+
+	clock.Advance(60 * time.Second)
+	r.DispatchDue(ctx)
+	e, _ = r.Get(ctx, e.ID)
+	assert.Equal(t, StatusProcessed, e.Status)
+	assert.Equal(t, 3, e.Attempts)
+	assert.Equal(t, int64(2), failed())
+}
+
+func TestReceiver_DeadLetterAndRedrive(t *testing.T) {
+	var healthy atomic.Bool
+	r, engine, clock := newTestReceiver(t, NewMemoryStore(), Config{MaxAttempts: 2, BaseBackoff: 10}, "dead", func(ctx context.Context, e Event) error {
+		if !healthy.Load() {
+			return errors.New("schema mismatch")
+		}
+		return nil
+	})
+	body := eventBody("evt_3", "customer.deleted")
+	dead := counterDelta("webhook_dead_total", "dead")
+	require.Equal(t, 200, deliver(engine, "dead", Sign(testSecret, clock.Now(), body), body).Code)
+	ctx := context.Background()
+
+	r.DispatchDue(ctx)
+	clock.Advance(10 * time.Second)
+	r.DispatchDue(ctx)
+
+	w := ut.PerformRequest(engine, "GET", "/admin/webhooks?provider=dead", nil)
+	require.Equal(t, 200, w.Code)
+	var list struct {
+		Data []EventView `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, StatusDead, list.Data[0].Status)
+	assert.Equal(t, 2, list.Data[0].Attempts)
+	assert.Equal(t, "schema mismatch", list.Data[0].LastError)
+	assert.Equal(t, string(body), list.Data[0].Payload)
+	assert.Equal(t, int64(1), dead())
+
+	// 修复后手动重新投递
+	healthy.Store(true)
+	id := list.Data[0].ID
+	w = ut.PerformRequest(engine, "POST", "/admin/webhooks/1/redrive", nil)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+	assert.Contains(t, w.Body.String(), `"attempts":0`)
+
+	n, _ := r.DispatchDue(ctx)
+	assert.Equal(t, 1, n)
+	e, _ := r.Get(ctx, id)
+	assert.Equal(t, StatusProcessed, e.Status)
+
+	assert.Equal(t, 409, ut.PerformRequest(engine, "POST", "/admin/webhooks/1/redrive", nil).Code)
+	assert.Equal(t, 404, ut.PerformRequest(engine, "POST", "/admin/webhooks/99/redrive", nil).Code)
+	assert.Equal(t, 400, ut.PerformRequest(engine, "POST", "/admin/webhooks/x/redrive", nil).Code)
+
+	w = ut.PerformRequest(engine, "GET", "/admin/webhooks", nil)
+	assert.JSONEq(t, `{"code":0,"message":"success","data":[]}`, w.Body.String(), "默认只列出死信")
+}
+
+// slowStore 模拟不响应 ctx 取消的慢存储
+type slowStore struct {
+	*MemoryStore
+	delay atomic.Int64
+}
+
+func (s *slowStore) Insert(ctx context.Context, e *Event) (int64, bool, error) {
+	time.Sleep(time.Duration(s.delay.Load()))
+	return s.MemoryStore.Insert(context.Background(), e)
+}
+
+func TestEndpoint_FastAck(t *testing.T) {
+	t.Run("slow handler never blocks the response", func(t *testing.T) {
+		started := make(chan string, 1)
+		release := make(chan struct{})
+		defer close(release)
+
+		store := NewMemoryStore()
+		clock := common.NewFakeClock(t0)
+		r := NewReceiver(store, Config{PollInterval: 3600}, clock)
+		engine := route.NewEngine(config.NewOptions(nil))
+		engine.POST("/webhooks/slow", r.Endpoint("slow", VerifyConfig{Secrets: []string{testSecret}}, func(ctx context.Context, e Event) error {
+			started <- e.EventID
+			<-release
+			return nil
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go r.Run(ctx)
+
+		body := eventBody("evt_slow", "report.ready")
+		begin := time.Now()
+		w := deliver(engine, "slow", Sign(testSecret, clock.Now(), body), body)
+		assert.Equal(t, 200, w.Code)
+		assert.Less(t, time.Since(begin), 100*time.Millisecond)
+
+		// 落库后立即唤醒后台处理，不等待轮询
+		select {
+		case id := <-started:
+			assert.Equal(t, "evt_slow", id)
+		case <-time.After(2 * time.Second):
+			t.Fatal("事件未被后台处理")
+		}
+	})
+
+	t.Run("slow store answers 503 within the deadline", func(t *testing.T) {
+		store := &slowStore{MemoryStore: NewMemoryStore()}
+		store.delay.Store(int64(300 * time.Millisecond))
+		_, engine, clock := newTestReceiver(t, store, Config{AckTimeout: 50}, "stuck", func(ctx context.Context, e Event) error { return nil })
+
+		body := eventBody("evt_4", "invoice.paid")
+		persistFailed := counterDelta("webhook_persist_failed_total", "stuck")
+		begin := time.Now()
+		w := deliver(engine, "stuck", Sign(testSecret, clock.Now(), body), body)
+		assert.Equal(t, 503, w.Code)
+		assert.Less(t, time.Since(begin), 200*time.Millisecond)
+		assert.Equal(t, int64(1), persistFailed())
+
+		// 超时后落库仍完成：服务商重投按重复事件应答，只处理一次
+		time.Sleep(350 * time.Millisecond)
+		store.delay.Store(0)
+		w = deliver(engine, "stuck", Sign(testSecret, clock.Now(), body), body)
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"duplicate":true`)
+	})
+}
+
+func TestEndpoint_Rejects(t *testing.T) {
+	var calls atomic.Int32
+	_, engine, clock := newTestReceiver(t, NewMemoryStore(), Config{}, "rej", func(ctx context.Context, e Event) error {
+		calls.Add(1)
+		return nil
+	})
+	body := eventBody("evt_5", "invoice.paid")
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		status    int
+		reason    string
+	}{
+		{"missing", "", body, 401, RejectMissing},
+		{"wrong secret", Sign("whsec_other", clock.Now(), body), body, 401, RejectBadSignature},
+		{"tampered body", Sign(testSecret, clock.Now(), body), eventBody("evt_5", "invoice.refunded"), 401, RejectBadSignature},
+		{"expired", Sign(testSecret, clock.Now().Add(-6*time.Minute), body), body, 401, RejectExpired},
+		{"no event id", Sign(testSecret, clock.Now(), []byte(`{"type":"x"}`)), []byte(`{"type":"x"}`), 400, RejectBadPayload},
+	}
+	for _, tt := range tests {
+		rejected := metrics.GetCounter("webhook_rejected_total", "provider", "rej", "reason", tt.reason)
+		before := rejected.Value()
+		w := deliver(engine, "rej", tt.signature, tt.body)
+		assert.Equal(t, tt.status, w.Code, tt.name)
+		assert.Contains(t, w.Body.String(), tt.reason, tt.name)
+		assert.Equal(t, before+1, rejected.Value(), tt.name)
+	}
+	assert.Zero(t, calls.Load())
+
+	// 轮换期间旧密钥签名仍然有效；多个 v1 时任一匹配即可
+	assert.Equal(t, 200, deliver(engine, "rej", Sign("whsec_old", clock.Now(), body), body).Code)
+	other := eventBody("evt_6", "invoice.paid")
+	sig := Sign("whsec_other", clock.Now(), other) + ",v1=" + signature(testSecret, strconv.FormatInt(clock.Now().Unix(), 10), other)
+	assert.Equal(t, 200, deliver(engine, "rej", sig, other).Code)
+}
+
+func TestReceiver_PurgeAfterRetention(t *testing.T) {
+	var calls atomic.Int32
+	r, engine, clock := newTestReceiver(t, NewMemoryStore(), Config{Retention: 3600}, "purge", func(ctx context.Context, e Event) error {
+		calls.Add(1)
+		return nil
+	})
+	ctx := context.Background()
+	body := eventBody("evt_7", "invoice.paid")
+	deliver(engine, "purge", Sign(testSecret, clock.Now(), body), body)
+	r.DispatchDue(ctx)
+
+	clock.Advance(30 * time.Minute)
+	n, err := r.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n, "保留期内不清理")
+
+	clock.Advance(31 * time.Minute)
+	n, _ = r.Purge(ctx)
+	assert.Equal(t, int64(1), n)
+
+	// 保留期之后的同一事件 ID 按新事件处理
+	w := deliver(engine, "purge", Sign(testSecret, clock.Now(), body), body)
+	assert.Contains(t, w.Body.String(), `"duplicate":false`)
+	r.DispatchDue(ctx)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestEndpoint_KeyringSecret(t *testing.T) {
+	kr, err := keyring.New(keyring.Config{Keys: map[string]keyring.KeyConfig{"webhook-partner": {Accept: 2}}})
+	require.NoError(t, err)
+	require.NoError(t, kr.Bootstrap(context.Background()))
+	keyring.SetDefault(kr)
+	t.Cleanup(func() { keyring.SetDefault(nil) })
+
+	clock := common.NewFakeClock(t0)
+	r := NewReceiver(NewMemoryStore(), Config{}, clock)
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(web.ExceptionHandler())
+	engine.POST("/webhooks/partner", r.Endpoint("partner", VerifyConfig{SecretKey: "webhook-partner"}, func(ctx context.Context, e Event) error {
+		return nil
+	}))
+
+	first, _ := kr.Current("webhook-partner")
+	_, err = kr.Rotate(context.Background(), "webhook-partner")
+	require.NoError(t, err)
+	body := eventBody("evt_k1", "invoice.paid")
+	assert.Equal(t, 200, deliver(engine, "partner", Sign(first.Secret, clock.Now(), body), body).Code, "轮换窗口内旧密钥仍然有效")
+
+	_, err = kr.Rotate(context.Background(), "webhook-partner")
+	require.NoError(t, err)
+	body = eventBody("evt_k2", "invoice.paid")
+	assert.Equal(t, 401, deliver(engine, "partner", Sign(first.Secret, clock.Now(), body), body).Code)
+	current, _ := kr.Current("webhook-partner")
+	assert.Equal(t, 200, deliver(engine, "partner", Sign(current.Secret, clock.Now(), body), body).Code)
+}
@@ -0,0 +1,57 @@
+// Package webtest 测试辅助：为每个测试创建独立的 App 与引擎，测试结束时自动释放，无需重置包级全局变量
+package webtest
+
+import (
+	"testing"
+
+	"github.com/CenJIl/base/web"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+)
+
+// NewApp 创建测试专用的 App，测试结束时自动 Close
+//
+// 使用方式：
+//
+//	a := webtest.NewApp(t, web.Config{DefaultLang: "zh-CN"}, web.WithJWT(jwtConf))
+//	engine := webtest.NewEngine(t, a, a.JWTAuthMiddleware())
+//	engine.GET("/me", handler)
+//	w := ut.PerformRequest(engine, "GET", "/me", nil, webtest.Bearer(t, a, "alice"))
+func NewApp(t testing.TB, config web.Config, opts ...web.AppOption) *web.App {
+	t.Helper()
+	a, err := web.NewApp(config, opts...)
+	if err != nil {
+		t.Fatalf("webtest: 创建 App 失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := a.Close(); err != nil {
+			t.Errorf("webtest: 关闭 App 失败: %v", err)
+		}
+	})
+	return a
+}
+
+// NewEngine 创建绑定到 App 的测试引擎：依次注册 App.Middleware、ExceptionHandler 与 middlewares
+func NewEngine(t testing.TB, a *web.App, middlewares ...app.HandlerFunc) *route.Engine {
+	t.Helper()
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(a.Middleware(), web.ExceptionHandler())
+	engine.Use(middlewares...)
+	return engine
+}
+
+// Bearer 用 App 的 JWT 认证为 user 签发令牌，返回 Authorization 请求头
+func Bearer(t testing.TB, a *web.App, user string) ut.Header {
+	t.Helper()
+	auth := a.Auth()
+	if auth == nil {
+		t.Fatalf("webtest: App %s 未启用 JWT", a.Name())
+	}
+	token, _, err := auth.GenerateToken(map[string]interface{}{auth.Config().IdentityKey: user})
+	if err != nil {
+		t.Fatalf("webtest: 签发令牌失败: %v", err)
+	}
+	return ut.Header{Key: "Authorization", Value: "Bearer " + token}
+}
@@ -0,0 +1,216 @@
+package webtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/CenJIl/base/web"
+	"github.com/CenJIl/base/web/database"
+	"github.com/CenJIl/base/web/jwt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ordersDB 内存数据库驱动：INSERT INTO orders 记录用户（事务提交后可见），SELECT COUNT(*) 返回已提交的行数
+type ordersDB struct {
+	mu    sync.Mutex
+	users []string
+}
+
+// ordersDrivers 已注册的驱动数（sql.Register 的名称不能重复）
+var ordersDrivers atomic.Int64
+
+// register 注册为驱动，返回驱动名（web.NewApp 按驱动名打开连接池）
+func (d *ordersDB) register() string {
+	name := fmt.Sprintf("webtest-orders-%d", ordersDrivers.Add(1))
+	sql.Register(name, d)
+	return name
+}
+
+func (d *ordersDB) Open(string) (driver.Conn, error) { return &ordersConn{db: d}, nil }
+
+func (d *ordersDB) committed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.users...)
+}
+
+type ordersConn struct {
+	db     *ordersDB
+	staged []string
+	inTx   bool
+}
+
+func (c *ordersConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *ordersConn) Close() error                        { return nil }
+func (c *ordersConn) Begin() (driver.Tx, error)           { c.inTx = true; return ordersTx{c}, nil }
+
+func (c *ordersConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.HasPrefix(query, "INSERT INTO orders") {
+		return nil, fmt.Errorf("unexpected exec: %s", query)
+	}
+	user := args[0].Value.(string)
+	if c.inTx {
+		c.staged = append(c.staged, user)
+	} else {
+		c.db.mu.Lock()
+		c.db.users = append(c.db.users, user)
+		c.db.mu.Unlock()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *ordersConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &countRows{n: int64(len(c.db.committed()))}, nil
+}
+
+type ordersTx struct{ c *ordersConn }
+
+func (t ordersTx) Commit() error {
+	t.c.db.mu.Lock()
+	t.c.db.users = append(t.c.db.users, t.c.staged...)
+	t.c.db.mu.Unlock()
+	t.c.staged, t.c.inTx = nil, false
+	return nil
+}
+
+func (t ordersTx) Rollback() error {
+	t.c.staged, t.c.inTx = nil, false
+	return nil
+}
+
+type countRows struct {
+	n    int64
+	done bool
+}
+
+func (r *countRows) Columns() []string { return []string{"n"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.n, true
+	return nil
+}
+
+// appFixture 使用自己的数据库、JWT 密钥、翻译与限流的 App
+type appFixture struct {
+	app    *web.App
+	db     *ordersDB
+	engine *route.Engine
+	token  ut.Header
+}
+
+func newFixture(t *testing.T, name, secret, greeting, user string, replica bool, rps float64, burst int) appFixture {
+	db := &ordersDB{}
+	dbConf := database.DatabaseConfig{Driver: db.register()}
+	if replica {
+		dbConf.Replica.Host = "replica"
+	}
+	jwtConf := jwt.DefaultConfig()
+	jwtConf.Secret = secret
+	a := NewApp(t, web.Config{DefaultLang: "zh-CN", Database: dbConf},
+		web.WithAppName(name), web.WithJWT(jwtConf), web.WithRateLimit(rps, burst))
+	a.RegisterMessages("zh-CN", map[string]string{"greeting": greeting})
+
+	engine := NewEngine(t, a, a.RateLimitMiddleware(), a.JWTAuthMiddleware(), a.DBMiddleware())
+	engine.POST("/orders", func(ctx context.Context, c *app.RequestContext) {
+		if _, err := database.Conn(ctx).ExecContext(ctx, "INSERT INTO orders (user_id) VALUES (?)", jwt.GetUserID(c)); err != nil {
+			c.JSON(500, web.Fail(500, err.Error()))
+			return
+		}
+		var n int64
+		if err := web.AppOf(c).Replica().QueryRowContext(ctx, "SELECT COUNT(*) FROM orders").Scan(&n); err != nil {
+			c.JSON(500, web.Fail(500, err.Error()))
+			return
+		}
+		c.JSON(200, map[string]string{
+			"app":      web.AppOf(c).Name(),
+			"user":     jwt.GetUserID(c),
+			"greeting": web.LocalizeMessage(ctx, "greeting"),
+		})
+	})
+	return appFixture{app: a, db: db, engine: engine, token: Bearer(t, a, user)}
+}
+
+func (f appFixture) post(token ut.Header) *ut.ResponseRecorder {
+	return ut.PerformRequest(f.engine, "POST", "/orders", nil, token)
+}
+
+func TestApps_RunConcurrentlyWithoutInterfering(t *testing.T) {
+	public := newFixture(t, "public", "public-secret", "你好", "alice", true, 1000, 1000)
+	// internal 的令牌桶几乎不补充：burst 用完后在测试期间一直限流
+	internal := newFixture(t, "internal", "internal-secret", "欢迎", "bob", false, 1e-6, 4)
+
+	// 其他 App 签发的令牌无效（同时消耗 internal 的一个令牌）
+	assert.Equal(t, 401, internal.post(public.token).Code)
+
+	const n = 20
+	var wg sync.WaitGroup
+	var internalOK, internalLimited atomic.Int32
+	for i := range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			w := public.post(public.token)
+			if assert.Equal(t, 200, w.Code, "public #%d: %s", i, w.Body.String()) {
+				assert.JSONEq(t, `{"app":"public","user":"alice","greeting":"你好"}`, w.Body.String())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w := internal.post(internal.token)
+			switch w.Code {
+			case 200:
+				internalOK.Add(1)
+				assert.JSONEq(t, `{"app":"internal","user":"bob","greeting":"欢迎"}`, w.Body.String())
+			case 429:
+				internalLimited.Add(1)
+			default:
+				t.Errorf("internal #%d: %d %s", i, w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// internal 的限流只放行剩下的 3 个令牌，public 不受影响
+	assert.Equal(t, int32(3), internalOK.Load())
+	assert.Equal(t, int32(n-3), internalLimited.Load())
+	assert.Equal(t, 200, public.post(public.token).Code)
+
+	// 每个 App 的写入只进入自己的连接池
+	assert.Len(t, public.db.committed(), n+1)
+	for _, user := range public.db.committed() {
+		assert.Equal(t, "alice", user)
+	}
+	assert.Equal(t, []string{"bob", "bob", "bob"}, internal.db.committed())
+
+	// App 的副本只读
+	_, err := public.app.Replica().ExecContext(context.Background(), "INSERT INTO orders (user_id) VALUES (?)", "mallory")
+	assert.ErrorIs(t, err, database.ErrReplicaWrite)
+	assert.NotSame(t, public.app.DB(), internal.app.DB())
+}
+
+func TestApp_CloseIsIdempotent(t *testing.T) {
+	a := NewApp(t, web.Config{}, web.WithRateLimit(10, 10))
+	require.NoError(t, a.Close())
+	require.NoError(t, a.Close())
+}
+
+func TestApp_MessagesDoNotLeakIntoDefaultApp(t *testing.T) {
+	a := NewApp(t, web.Config{})
+	a.RegisterMessages("zh-CN", map[string]string{"webtest-only": "仅测试"})
+	assert.Equal(t, "webtest-only", web.LocalizeMessage(context.Background(), "webtest-only"))
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/CenJIl/base/logger"
+	"github.com/CenJIl/base/web/jwt"
 	"github.com/CenJIl/base/web/middleware"
 	"github.com/cloudwego/hertz/pkg/app"
 )
@@ -13,7 +13,7 @@ import (
 //
 // 自动捕获 panic 和 error，转换为统一响应格式
 // 支持直接返回 error 的 handler 函数
-// 自动为响应添加 TraceID
+// 自动为响应添加 TraceID（代理登录时标记 impersonating）
 //
 // 使用方式：
 //
@@ -30,54 +30,44 @@ func WrapHandler(h func(ctx context.Context, c *app.RequestContext) error) app.H
 		// 执行 handler 并捕获 panic
 		defer func() {
 			if r := recover(); r != nil {
-				logger.Errorf("[PANIC] Handler panic: %v", r)
-
-				result := Result{}
-				switch err := r.(type) {
-				case *HTTPException:
-					result = Fail(err.Code, err.Message)
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(err.HTTPStatus, result)
-					c.Abort()
-				case *Exception:
-					result = Fail(err.Code, err.Message)
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(getHTTPStatus(err.Code), result)
-					c.Abort()
-				case error:
-					result = Fail(500, err.Error())
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(http.StatusInternalServerError, result)
-					c.Abort()
-				default:
-					result = Fail(500, "Internal server error")
-					result.TraceID = middleware.GetRequestID(c)
-					c.JSON(http.StatusInternalServerError, result)
-					c.Abort()
-				}
+				renderRecovered(ctx, c, r)
 			}
 		}()
 
 		// 调用 handler
 		if err := h(ctx, c); err != nil {
-			// 处理错误
+			recordRequestError(c, err)
+			// 处理错误（客户端中途断开导致的 context.Canceled 不视为错误）
+			if renderClientAbort(c, err) {
+				return
+			}
+			if status, result, ok := knownErrorResult(err); ok {
+				result.TraceID = middleware.GetRequestID(c)
+				result.Impersonating = jwt.IsImpersonating(c)
+				respondError(c, status, result)
+				c.Abort()
+				return
+			}
 			result := Result{}
 			switch e := err.(type) {
 			case *HTTPException:
 				result = Fail(e.Code, e.Message)
 				result.TraceID = middleware.GetRequestID(c)
-				c.JSON(e.HTTPStatus, result)
+				result.Impersonating = jwt.IsImpersonating(c)
+				respondError(c, e.HTTPStatus, result)
 				c.Abort()
 			case *Exception:
 				result = Fail(e.Code, e.Message)
 				result.TraceID = middleware.GetRequestID(c)
-				c.JSON(getHTTPStatus(e.Code), result)
+				result.Impersonating = jwt.IsImpersonating(c)
+				respondError(c, getHTTPStatus(e.Code), result)
 				c.Abort()
 			default:
-				logger.Errorf("[ERROR] Handler error: %v", err)
+				log.Errorf("[ERROR] Handler error: %v", err)
 				result = Fail(500, err.Error())
 				result.TraceID = middleware.GetRequestID(c)
-				c.JSON(http.StatusInternalServerError, result)
+				result.Impersonating = jwt.IsImpersonating(c)
+				respondError(c, http.StatusInternalServerError, result)
 				c.Abort()
 			}
 			return
@@ -87,6 +77,7 @@ func WrapHandler(h func(ctx context.Context, c *app.RequestContext) error) app.H
 		if c.Response.StatusCode() == 0 {
 			result := Success(nil)
 			result.TraceID = middleware.GetRequestID(c)
+			result.Impersonating = jwt.IsImpersonating(c)
 			c.JSON(200, result)
 		}
 	}
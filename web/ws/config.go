@@ -8,6 +8,8 @@ type Config struct {
 	PingInterval      int   `toml:"pingInterval"`      // 心跳间隔（秒）
 	PongTimeout       int   `toml:"pongTimeout"`       // Pong 超时时间（秒）
 	EnableCompression bool  `toml:"enableCompression"` // 是否启用压缩
+	PresenceLinger    int   `toml:"presenceLinger"`    // 最后一个连接断开后判定离线的延迟（秒，负数表示立即）
+	PresenceHeartbeat int   `toml:"presenceHeartbeat"` // 在线状态心跳间隔（秒），实例崩溃后约 3 个周期用户变为离线
 }
 
 // DefaultConfig 返回默认配置
@@ -19,5 +21,7 @@ func DefaultConfig() Config {
 		PingInterval:      30,         // 30秒
 		PongTimeout:       60,         // 60秒
 		EnableCompression: false,
+		PresenceLinger:    5,  // 5秒
+		PresenceHeartbeat: 15, // 15秒
 	}
 }
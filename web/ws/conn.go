@@ -3,16 +3,18 @@ package ws
 import (
 	"time"
 
-	"github.com/CenJIl/base/logger"
 	"github.com/gorilla/websocket"
 )
 
 // Connection WebSocket 连接封装
 type Connection struct {
-	hub  *Hub            // 连接池
-	ws   *websocket.Conn // WebSocket 连接
-	send chan []byte     // 发送队列
-	id   string          // 连接 ID
+	hub    *Hub            // 连接池
+	ws     *websocket.Conn // WebSocket 连接
+	send   chan []byte     // 发送队列
+	id     string          // 连接 ID
+	userID string          // 已认证用户 ID（匿名连接为空）
+	rooms  map[string]bool // 所在房间（由 hub.mu 保护）
+	poll   bool            // 长轮询虚拟连接（见 NewPollConnection）
 }
 
 // NewConnection 创建新连接
@@ -29,6 +31,21 @@ func NewConnection(wsConn *websocket.Conn, hub *Hub) *Connection {
 	}
 }
 
+// NewUserConnection 创建已认证用户的连接（userID 通常取自 jwt.GetUserID(c)）
+//
+// 带用户 ID 的连接参与在线状态统计，见 Hub.EnablePresence
+//
+// 使用方式：
+//
+//	conn := ws.NewUserConnection(wsConn, hub, jwt.GetUserID(c))
+//	hub.Join(conn, "team-42")
+//	hub.Register(conn)
+func NewUserConnection(wsConn *websocket.Conn, hub *Hub, userID string) *Connection {
+	conn := NewConnection(wsConn, hub)
+	conn.userID = userID
+	return conn
+}
+
 // ReadPump 读取协程
 //
 // 从 WebSocket 读取消息并广播到 Hub
@@ -51,7 +68,7 @@ func (c *Connection) ReadPump() {
 		_, message, err := c.ws.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				logger.Errorf("[WS] Read error: %v", err)
+				log.Errorf("[WS] Read error: %v", err)
 			}
 			break
 		}
@@ -82,7 +99,7 @@ func (c *Connection) WritePump() {
 			}
 
 			if err := c.ws.WriteMessage(websocket.TextMessage, message); err != nil {
-				logger.Errorf("[WS] Write error: %v", err)
+				log.Errorf("[WS] Write error: %v", err)
 				return
 			}
 
@@ -102,12 +119,16 @@ func (c *Connection) WritePump() {
 //
 //	conn.Send([]byte("hello"))
 func (c *Connection) Send(message []byte) {
+	if c.poll {
+		c.hub.replayBuffer().record(Message{userID: c.userID}, message)
+		return
+	}
 	select {
 	case c.send <- message:
 		// 消息已加入发送队列
 	default:
 		// 发送队列已满，关闭连接
-		logger.Warnf("[WS] Send buffer full, closing connection: %s", c.id)
+		log.Warnf("[WS] Send buffer full, closing connection: %s", c.id)
 		c.hub.Unregister(c)
 	}
 }
@@ -130,6 +151,11 @@ func (c *Connection) ID() string {
 	return c.id
 }
 
+// UserID 获取连接所属用户 ID（匿名连接为空）
+func (c *Connection) UserID() string {
+	return c.userID
+}
+
 // WebSocket 连接参数
 const (
 	// 允许等待写入的时间
@@ -2,20 +2,21 @@ package ws
 
 import (
 	"sync"
-
-	"github.com/CenJIl/base/logger"
 )
 
 // Hub WebSocket 连接池
 //
 // 管理所有 WebSocket 连接，支持广播和点对点消息
 type Hub struct {
-	connections map[string]*Connection    // 连接映射（ID -> Connection）
-	register    chan *Connection          // 注册连接
-	unregister  chan *Connection          // 注销连接
-	broadcast   chan []byte               // 广播消息
-	mu          sync.RWMutex              // 读写锁
-	onMessage   func(*Connection, []byte) // 消息处理回调
+	connections map[string]*Connection            // 连接映射（ID -> Connection）
+	register    chan *Connection                  // 注册连接
+	unregister  chan *Connection                  // 注销连接
+	broadcast   chan []byte                       // 广播消息
+	mu          sync.RWMutex                      // 读写锁
+	onMessage   func(*Connection, []byte)         // 消息处理回调
+	rooms       map[string]map[string]*Connection // 房间 -> 连接
+	presence    *presenceTracker                  // 在线状态（未启用时为 nil）
+	replay      *replayLog                        // 重放缓冲（未启用时为 nil）
 }
 
 // NewHub 创建新的连接池
@@ -30,6 +31,7 @@ func NewHub() *Hub {
 		register:    make(chan *Connection),
 		unregister:  make(chan *Connection),
 		broadcast:   make(chan []byte, 256),
+		rooms:       make(map[string]map[string]*Connection),
 	}
 }
 
@@ -45,17 +47,32 @@ func (h *Hub) Run() {
 		case conn := <-h.register:
 			h.mu.Lock()
 			h.connections[conn.ID()] = conn
+			for room := range conn.rooms {
+				h.joinLocked(conn, room)
+			}
 			h.mu.Unlock()
-			logger.Infof("[WS] Connection registered: %s (total: %d)", conn.ID(), len(h.connections))
+			log.Infof("[WS] Connection registered: %s (total: %d)", conn.ID(), len(h.connections))
+			if h.presence != nil && conn.userID != "" {
+				h.presence.connect(conn.userID)
+			}
 
 		case conn := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.connections[conn.ID()]; ok {
+			_, ok := h.connections[conn.ID()]
+			var rooms []string
+			if ok {
 				delete(h.connections, conn.ID())
+				for room := range conn.rooms {
+					rooms = append(rooms, room)
+					h.leaveLocked(conn, room)
+				}
 				conn.Close()
 			}
 			h.mu.Unlock()
-			logger.Infof("[WS] Connection unregistered: %s (total: %d)", conn.ID(), len(h.connections))
+			log.Infof("[WS] Connection unregistered: %s (total: %d)", conn.ID(), len(h.connections))
+			if ok && h.presence != nil && conn.userID != "" {
+				h.presence.disconnect(conn.userID, rooms)
+			}
 
 		case message := <-h.broadcast:
 			h.mu.RLock()
@@ -65,7 +82,7 @@ func (h *Hub) Run() {
 					// 消息已发送
 				default:
 					// 发送队列已满，关闭连接
-					logger.Warnf("[WS] Broadcast buffer full for connection: %s", conn.ID())
+					log.Warnf("[WS] Broadcast buffer full for connection: %s", conn.ID())
 					h.unregister <- conn
 				}
 			}
@@ -98,6 +115,9 @@ func (h *Hub) Unregister(conn *Connection) {
 //
 //	hub.Broadcast([]byte("system notification"))
 func (h *Hub) Broadcast(message []byte) {
+	if r := h.replayBuffer(); r != nil {
+		r.record(Message{all: true}, message)
+	}
 	h.broadcast <- message
 }
 
@@ -119,6 +139,30 @@ func (h *Hub) SendTo(connID string, message []byte) error {
 	return nil
 }
 
+// SendToUser 发送消息给用户在本实例上的全部连接，返回成功加入发送队列的连接数
+//
+// 使用方式：
+//
+//	if hub.SendToUser("user-1", msg) == 0 {
+//	    // 用户不在本实例上
+//	}
+func (h *Hub) SendToUser(userID string, message []byte) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.replay != nil {
+		h.replay.record(Message{userID: userID}, message)
+	}
+
+	sent := 0
+	for _, conn := range h.connections {
+		if conn.userID == userID && trySend(conn, message) {
+			sent++
+		}
+	}
+	return sent
+}
+
 // GetConnection 获取指定连接
 //
 // 使用方式：
@@ -158,12 +202,43 @@ func (h *Hub) GetConnections() []*Connection {
 	return conns
 }
 
+// HubStats 连接池统计
+type HubStats struct {
+	Connections int            `json:"connections"` // 连接数
+	Users       int            `json:"users"`       // 已登录用户数（同一用户多个连接只计一次）
+	Rooms       map[string]int `json:"rooms"`       // 房间 -> 连接数
+}
+
+// Stats 获取连接池统计（诊断包等排障场景使用）
+//
+// 使用方式：
+//
+//	web.RegisterDiagnostics("ws", func(ctx context.Context) (any, error) {
+//	    return hub.Stats(), nil
+//	})
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	users := make(map[string]bool)
+	for _, conn := range h.connections {
+		if conn.userID != "" {
+			users[conn.userID] = true
+		}
+	}
+	rooms := make(map[string]int, len(h.rooms))
+	for room, members := range h.rooms {
+		rooms[room] = len(members)
+	}
+	return HubStats{Connections: len(h.connections), Users: len(users), Rooms: rooms}
+}
+
 // OnMessage 设置消息处理回调
 //
 // 使用方式：
 //
 //	hub.OnMessage(func(conn *ws.Connection, msg []byte) {
-//	    logger.Infof("Received: %s", msg)
+//	    log.Infof("Received: %s", msg)
 //	})
 func (h *Hub) OnMessage(handler func(*Connection, []byte)) {
 	h.onMessage = handler
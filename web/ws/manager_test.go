@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub_SendToUser(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	phone := NewUserConnection(nil, hub, "alice")
+	laptop := NewUserConnection(nil, hub, "alice")
+	other := NewUserConnection(nil, hub, "bob")
+	for _, c := range []*Connection{phone, laptop, other} {
+		hub.Register(c)
+	}
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 3 }, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 2, hub.SendToUser("alice", []byte("hi")))
+	assert.Equal(t, []byte("hi"), <-phone.send)
+	assert.Equal(t, []byte("hi"), <-laptop.send)
+	assert.Empty(t, other.send)
+	assert.Equal(t, 0, hub.SendToUser("carol", []byte("hi")))
+}
+
+func TestHub_Stats(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	phone := NewUserConnection(nil, hub, "alice")
+	laptop := NewUserConnection(nil, hub, "alice")
+	anon := NewConnection(nil, hub)
+	for _, c := range []*Connection{phone, laptop, anon} {
+		hub.Register(c)
+	}
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 3 }, time.Second, 5*time.Millisecond)
+	hub.Join(phone, "lobby")
+	hub.Join(anon, "lobby")
+
+	assert.Equal(t, HubStats{Connections: 3, Users: 1, Rooms: map[string]int{"lobby": 2}}, hub.Stats())
+}
@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ErrNotificationSuppressed 用户关闭了该类别的通知，消息未发送
+var ErrNotificationSuppressed = &HubError{Code: 403, Message: "Notification suppressed by user preference"}
+
+// NotifyGuard 通知发送前的检查（userID 是否接收 category 类别的 WebSocket 通知）
+type NotifyGuard func(ctx context.Context, userID, category string) (bool, error)
+
+var notifyGuard atomic.Pointer[NotifyGuard]
+
+// SetNotifyGuard 设置通知检查（nil 不检查）；web.SetNotifyPrefStore 会自动设置为按用户通知偏好检查
+func SetNotifyGuard(g NotifyGuard) {
+	if g == nil {
+		notifyGuard.Store(nil)
+		return
+	}
+	notifyGuard.Store(&g)
+}
+
+// SendNotification 按用户通知偏好发送通知类消息，返回发送成功的连接数
+//
+// 与 SendToUser 不同，用户关闭了该类别时不发送并返回 ErrNotificationSuppressed；
+// 检查失败时返回错误（不发送）。聊天、在线状态等非通知消息仍使用 SendToUser
+//
+// 使用方式：
+//
+//	if _, err := hub.SendNotification(ctx, userID, web.NotifyMarketing, msg); errors.Is(err, ws.ErrNotificationSuppressed) {
+//	    // 用户未开启营销通知
+//	}
+func (h *Hub) SendNotification(ctx context.Context, userID, category string, message []byte) (int, error) {
+	if g := notifyGuard.Load(); g != nil {
+		ok, err := (*g)(ctx, userID, category)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrNotificationSuppressed
+		}
+	}
+	return h.SendToUser(userID, message), nil
+}
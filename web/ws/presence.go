@@ -0,0 +1,317 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/cache"
+)
+
+// PresenceInfo 用户在线状态
+type PresenceInfo struct {
+	UserID   string    `json:"userId"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"lastSeen"` // 最后在线时间（在线时为最近一次心跳）
+}
+
+// PresenceEvent 在线状态变化事件
+type PresenceEvent struct {
+	Type     string    `json:"type"` // 固定为 "presence"
+	UserID   string    `json:"userId"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// ErrPresenceDisabled 未启用在线状态
+var ErrPresenceDisabled = &HubError{Code: 400, Message: "Presence not enabled"}
+
+// userPresence 本实例上单个用户的连接情况
+type userPresence struct {
+	conns     int             // 本实例上的连接数
+	rooms     map[string]bool // 最后一个连接断开时所在的房间（用于发送离线事件）
+	timer     *time.Timer     // 离线延迟计时器
+	droppedAt time.Time       // 最后一个连接断开的时间
+	gen       uint64          // 防止过期的计时器误判
+}
+
+// presenceTracker 在线状态跟踪
+type presenceTracker struct {
+	hub       *Hub
+	store     PresenceStore
+	replica   string
+	linger    time.Duration
+	heartbeat time.Duration
+	clock     common.Clock
+
+	opMu     sync.Mutex // 串行化上线/离线的存储操作，避免二者交错
+	mu       sync.Mutex
+	users    map[string]*userPresence
+	onChange func(PresenceEvent)
+
+	stop     chan struct{} // 关闭后心跳协程退出
+	stopOnce sync.Once
+}
+
+// EnablePresence 启用在线状态跟踪
+//
+// 用户有至少一个连接即为在线；最后一个连接断开后等待 presenceLinger 秒再判定离线，吸收快速重连。
+// 状态变化会推送给同房间的其他连接，并回调 OnPresence。
+// store 为 nil 时：配置了 Redis 使用 RedisPresenceStore（多实例汇总），否则使用进程内存储。
+//
+// 使用方式：
+//
+//	hub := ws.NewHub()
+//	hub.EnablePresence(config.WebSocket, nil)
+//	hub.OnPresence(func(e ws.PresenceEvent) {
+//	    userRepo.UpdateLastSeen(e.UserID, e.LastSeen)
+//	})
+//	go hub.Run()
+func (h *Hub) EnablePresence(config Config, store PresenceStore) {
+	if store == nil {
+		if cache.Client != nil {
+			store = RedisPresenceStore{}
+		} else {
+			store = NewMemoryPresenceStore(nil)
+		}
+	}
+	defaults := DefaultConfig()
+	if config.PresenceLinger < 0 {
+		config.PresenceLinger = 0
+	} else if config.PresenceLinger == 0 {
+		config.PresenceLinger = defaults.PresenceLinger
+	}
+	if config.PresenceHeartbeat <= 0 {
+		config.PresenceHeartbeat = defaults.PresenceHeartbeat
+	}
+	h.enablePresence(store, time.Duration(config.PresenceLinger)*time.Second,
+		time.Duration(config.PresenceHeartbeat)*time.Second, nil)
+}
+
+// enablePresence 按时长启用在线状态（测试可注入时钟）
+func (h *Hub) enablePresence(store PresenceStore, linger, heartbeat time.Duration, clock common.Clock) *presenceTracker {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	hostname, _ := os.Hostname()
+	p := &presenceTracker{
+		hub:       h,
+		store:     store,
+		replica:   fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), randomString(6)),
+		linger:    linger,
+		heartbeat: heartbeat,
+		clock:     clock,
+		users:     make(map[string]*userPresence),
+		stop:      make(chan struct{}),
+	}
+	if h.presence != nil {
+		// 重复启用：停止旧的心跳，避免两个协程同时续期
+		h.presence.close()
+	}
+	h.presence = p
+	go p.heartbeatLoop()
+	log.Infof("[WS] Presence enabled: linger=%v heartbeat=%v store=%T", linger, heartbeat, store)
+	return p
+}
+
+// OnPresence 设置在线状态变化回调（用于持久化最后在线时间等）
+func (h *Hub) OnPresence(handler func(PresenceEvent)) {
+	if h.presence == nil {
+		return
+	}
+	h.presence.mu.Lock()
+	h.presence.onChange = handler
+	h.presence.mu.Unlock()
+}
+
+// Presence 批量查询用户在线状态（按传入顺序返回）
+//
+// 使用方式：
+//
+//	infos, err := hub.Presence("u1", "u2", "u3")
+func (h *Hub) Presence(userIDs ...string) ([]PresenceInfo, error) {
+	if h.presence == nil {
+		return nil, ErrPresenceDisabled
+	}
+	found, err := h.presence.store.Query(context.Background(), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PresenceInfo, len(userIDs))
+	for i, id := range userIDs {
+		info, ok := found[id]
+		if !ok {
+			info = PresenceInfo{UserID: id}
+		}
+		result[i] = info
+	}
+	return result, nil
+}
+
+// connect 用户在本实例新增连接
+func (p *presenceTracker) connect(userID string) {
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+	p.mu.Lock()
+	u, ok := p.users[userID]
+	if !ok {
+		u = &userPresence{}
+		p.users[userID] = u
+	}
+	u.conns++
+	if u.timer != nil {
+		// 离线延迟期内重连：状态保持在线，不发事件
+		u.timer.Stop()
+		u.timer = nil
+		u.gen++
+		p.mu.Unlock()
+		return
+	}
+	if u.conns > 1 {
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	wasOffline, err := p.store.Online(context.Background(), userID, p.replica, p.ttl())
+	if err != nil {
+		log.Errorf("[WS] Presence store online failed: %v", err)
+		return
+	}
+	if wasOffline {
+		p.emit(PresenceEvent{Type: "presence", UserID: userID, Online: true, LastSeen: p.clock.Now()}, nil)
+	}
+}
+
+// disconnect 用户在本实例的一个连接断开
+func (p *presenceTracker) disconnect(userID string, rooms []string) {
+	p.mu.Lock()
+	u, ok := p.users[userID]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	u.conns--
+	if u.rooms == nil {
+		u.rooms = make(map[string]bool)
+	}
+	for _, room := range rooms {
+		u.rooms[room] = true
+	}
+	if u.conns > 0 {
+		p.mu.Unlock()
+		return
+	}
+
+	u.droppedAt = p.clock.Now()
+	u.gen++
+	gen := u.gen
+	if p.linger <= 0 {
+		p.mu.Unlock()
+		p.expire(userID, gen)
+		return
+	}
+	u.timer = time.AfterFunc(p.linger, func() { p.expire(userID, gen) })
+	p.mu.Unlock()
+}
+
+// expire 离线延迟结束，确认离线
+func (p *presenceTracker) expire(userID string, gen uint64) {
+	p.opMu.Lock()
+	defer p.opMu.Unlock()
+	p.mu.Lock()
+	u, ok := p.users[userID]
+	if !ok || u.gen != gen || u.conns > 0 {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.users, userID)
+	p.mu.Unlock()
+
+	offline, err := p.store.Offline(context.Background(), userID, p.replica, u.droppedAt)
+	if err != nil {
+		log.Errorf("[WS] Presence store offline failed: %v", err)
+		return
+	}
+	if offline {
+		p.emit(PresenceEvent{Type: "presence", UserID: userID, Online: false, LastSeen: u.droppedAt}, u.rooms)
+	}
+}
+
+// emit 推送给同房间的其他用户并回调
+//
+// rooms 为 nil 时使用该用户当前连接所在的房间
+func (p *presenceTracker) emit(event PresenceEvent, rooms map[string]bool) {
+	message, _ := json.Marshal(event)
+	h := p.hub
+
+	h.mu.RLock()
+	if rooms == nil {
+		rooms = make(map[string]bool)
+		for _, conn := range h.connections {
+			if conn.userID == event.UserID {
+				for room := range conn.rooms {
+					rooms[room] = true
+				}
+			}
+		}
+	}
+	sent := make(map[string]bool)
+	for room := range rooms {
+		for id, conn := range h.rooms[room] {
+			if conn.userID == event.UserID || sent[id] {
+				continue
+			}
+			sent[id] = true
+			trySend(conn, message)
+		}
+	}
+	h.mu.RUnlock()
+
+	p.mu.Lock()
+	handler := p.onChange
+	p.mu.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// ttl 实例记录的过期时间：3 个心跳周期，容忍偶发的心跳失败
+func (p *presenceTracker) ttl() time.Duration {
+	return 3 * p.heartbeat
+}
+
+// refresh 为本实例上的用户（含离线延迟期内的用户）续期
+func (p *presenceTracker) refresh() {
+	p.mu.Lock()
+	users := make([]string, 0, len(p.users))
+	for id := range p.users {
+		users = append(users, id)
+	}
+	p.mu.Unlock()
+	if err := p.store.Refresh(context.Background(), p.replica, users, p.ttl()); err != nil {
+		log.Errorf("[WS] Presence heartbeat failed: %v", err)
+	}
+}
+
+func (p *presenceTracker) heartbeatLoop() {
+	ticker := time.NewTicker(p.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.refresh()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// close 停止心跳（可重复调用）
+func (p *presenceTracker) close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
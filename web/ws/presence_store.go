@@ -0,0 +1,260 @@
+package ws
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/CenJIl/base/web/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// PresenceStore 在线状态存储
+//
+// 以「用户 × 实例」为单位记录在线状态：每个实例只要有该用户的连接就写入一条带过期时间的记录，
+// 心跳续期；实例崩溃后记录不再续期，过期后该用户自然变为离线。
+type PresenceStore interface {
+	// Online 标记用户在 replica 上在线，返回此前是否全局离线
+	Online(ctx context.Context, userID, replica string, ttl time.Duration) (bool, error)
+	// Offline 移除用户在 replica 上的记录并写入最后在线时间，返回是否已全局离线
+	Offline(ctx context.Context, userID, replica string, lastSeen time.Time) (bool, error)
+	// Refresh 为 replica 上的全部在线用户续期
+	Refresh(ctx context.Context, replica string, userIDs []string, ttl time.Duration) error
+	// Query 批量查询在线状态
+	Query(ctx context.Context, userIDs []string) (map[string]PresenceInfo, error)
+}
+
+// MemoryPresenceStore 进程内在线状态存储（单实例部署默认使用）
+type MemoryPresenceStore struct {
+	mu       sync.Mutex
+	clock    common.Clock
+	replicas map[string]map[string]time.Time // 用户 -> 实例 -> 过期时间
+	lastSeen map[string]time.Time            // 用户 -> 最后在线时间
+}
+
+// NewMemoryPresenceStore 创建进程内存储（clock 为 nil 时使用系统时钟）
+func NewMemoryPresenceStore(clock common.Clock) *MemoryPresenceStore {
+	if clock == nil {
+		clock = common.SystemClock{}
+	}
+	return &MemoryPresenceStore{
+		clock:    clock,
+		replicas: make(map[string]map[string]time.Time),
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+// liveLocked 统计未过期的实例数（调用方持锁），顺带清理过期记录
+func (s *MemoryPresenceStore) liveLocked(userID string, now time.Time) int {
+	live := 0
+	for replica, expire := range s.replicas[userID] {
+		if expire.After(now) {
+			live++
+		} else {
+			delete(s.replicas[userID], replica)
+		}
+	}
+	if len(s.replicas[userID]) == 0 {
+		delete(s.replicas, userID)
+	}
+	return live
+}
+
+// Online 实现 PresenceStore 接口
+func (s *MemoryPresenceStore) Online(ctx context.Context, userID, replica string, ttl time.Duration) (bool, error) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wasOffline := s.liveLocked(userID, now) == 0
+	if s.replicas[userID] == nil {
+		s.replicas[userID] = make(map[string]time.Time)
+	}
+	s.replicas[userID][replica] = now.Add(ttl)
+	s.lastSeen[userID] = now
+	return wasOffline, nil
+}
+
+// Offline 实现 PresenceStore 接口
+func (s *MemoryPresenceStore) Offline(ctx context.Context, userID, replica string, lastSeen time.Time) (bool, error) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.replicas[userID], replica)
+	if lastSeen.After(s.lastSeen[userID]) {
+		s.lastSeen[userID] = lastSeen
+	}
+	return s.liveLocked(userID, now) == 0, nil
+}
+
+// Refresh 实现 PresenceStore 接口
+func (s *MemoryPresenceStore) Refresh(ctx context.Context, replica string, userIDs []string, ttl time.Duration) error {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, userID := range userIDs {
+		if s.replicas[userID] == nil {
+			s.replicas[userID] = make(map[string]time.Time)
+		}
+		s.replicas[userID][replica] = now.Add(ttl)
+		s.lastSeen[userID] = now
+	}
+	return nil
+}
+
+// Query 实现 PresenceStore 接口
+func (s *MemoryPresenceStore) Query(ctx context.Context, userIDs []string) (map[string]PresenceInfo, error) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]PresenceInfo, len(userIDs))
+	for _, userID := range userIDs {
+		result[userID] = PresenceInfo{
+			UserID:   userID,
+			Online:   s.liveLocked(userID, now) > 0,
+			LastSeen: s.lastSeen[userID],
+		}
+	}
+	return result, nil
+}
+
+// RedisPresenceStore 基于 Redis 的在线状态存储（多实例部署）
+//
+// 每个用户一个 Hash：{prefix}u:{userID} 字段为实例 ID、值为过期时间（毫秒）；
+// 最后在线时间统一存放在 {prefix}lastseen Hash 中
+type RedisPresenceStore struct {
+	Client *redis.Client // 为 nil 时使用 cache.Client
+	Prefix string        // 键前缀，默认 "ws:presence:"
+	Clock  common.Clock  // 为 nil 时使用系统时钟
+}
+
+// presenceOnlineScript 写入本实例记录，返回写入前其他实例的有效记录数
+var presenceOnlineScript = redis.NewScript(`
+local live = 0
+local vals = redis.call('HGETALL', KEYS[1])
+for i = 1, #vals, 2 do
+  if vals[i] ~= ARGV[1] and tonumber(vals[i + 1]) > tonumber(ARGV[2]) then live = live + 1 end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('HSET', KEYS[2], ARGV[5], ARGV[2])
+return live
+`)
+
+// presenceOfflineScript 删除本实例记录，返回剩余的有效记录数
+var presenceOfflineScript = redis.NewScript(`
+redis.call('HDEL', KEYS[1], ARGV[1])
+local current = tonumber(redis.call('HGET', KEYS[2], ARGV[3]) or '0')
+if tonumber(ARGV[4]) > current then redis.call('HSET', KEYS[2], ARGV[3], ARGV[4]) end
+local live = 0
+local vals = redis.call('HGETALL', KEYS[1])
+for i = 1, #vals, 2 do
+  if tonumber(vals[i + 1]) > tonumber(ARGV[2]) then live = live + 1 else redis.call('HDEL', KEYS[1], vals[i]) end
+end
+return live
+`)
+
+func (s RedisPresenceStore) client() *redis.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return cache.Client
+}
+
+func (s RedisPresenceStore) prefix() string {
+	if s.Prefix == "" {
+		return "ws:presence:"
+	}
+	return s.Prefix
+}
+
+func (s RedisPresenceStore) key(userID string) string {
+	return s.prefix() + "u:" + userID
+}
+
+func (s RedisPresenceStore) lastSeenKey() string {
+	return s.prefix() + "lastseen"
+}
+
+func (s RedisPresenceStore) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// Online 实现 PresenceStore 接口
+func (s RedisPresenceStore) Online(ctx context.Context, userID, replica string, ttl time.Duration) (bool, error) {
+	now := s.now()
+	live, err := presenceOnlineScript.Run(ctx, s.client(), []string{s.key(userID), s.lastSeenKey()},
+		replica, now.UnixMilli(), now.Add(ttl).UnixMilli(), ttl.Milliseconds(), userID).Int()
+	if err != nil {
+		return false, err
+	}
+	return live == 0, nil
+}
+
+// Offline 实现 PresenceStore 接口
+func (s RedisPresenceStore) Offline(ctx context.Context, userID, replica string, lastSeen time.Time) (bool, error) {
+	live, err := presenceOfflineScript.Run(ctx, s.client(), []string{s.key(userID), s.lastSeenKey()},
+		replica, s.now().UnixMilli(), userID, lastSeen.UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	return live == 0, nil
+}
+
+// Refresh 实现 PresenceStore 接口
+func (s RedisPresenceStore) Refresh(ctx context.Context, replica string, userIDs []string, ttl time.Duration) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	now := s.now()
+	pipe := s.client().Pipeline()
+	for _, userID := range userIDs {
+		pipe.HSet(ctx, s.key(userID), replica, now.Add(ttl).UnixMilli())
+		pipe.PExpire(ctx, s.key(userID), ttl)
+		pipe.HSet(ctx, s.lastSeenKey(), userID, now.UnixMilli())
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Query 实现 PresenceStore 接口
+func (s RedisPresenceStore) Query(ctx context.Context, userIDs []string) (map[string]PresenceInfo, error) {
+	result := make(map[string]PresenceInfo, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	pipe := s.client().Pipeline()
+	replicas := make([]*redis.MapStringStringCmd, len(userIDs))
+	for i, userID := range userIDs {
+		replicas[i] = pipe.HGetAll(ctx, s.key(userID))
+	}
+	seen := pipe.HMGet(ctx, s.lastSeenKey(), userIDs...)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	now := s.now().UnixMilli()
+	seenVals := seen.Val()
+	for i, userID := range userIDs {
+		info := PresenceInfo{UserID: userID}
+		for _, v := range replicas[i].Val() {
+			if expire, err := strconv.ParseInt(v, 10, 64); err == nil && expire > now {
+				info.Online = true
+				break
+			}
+		}
+		if i < len(seenVals) {
+			if v, ok := seenVals[i].(string); ok {
+				if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+					info.LastSeen = time.UnixMilli(ms)
+				}
+			}
+		}
+		result[userID] = info
+	}
+	return result, nil
+}
@@ -0,0 +1,210 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CenJIl/base/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder 记录 OnPresence 回调
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []PresenceEvent
+}
+
+func (r *eventRecorder) record(e PresenceEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) list() []PresenceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]PresenceEvent(nil), r.events...)
+}
+
+func newPresenceHub(store PresenceStore, linger time.Duration, clock common.Clock) (*Hub, *presenceTracker, *eventRecorder) {
+	hub := NewHub()
+	p := hub.enablePresence(store, linger, time.Hour, clock)
+	rec := &eventRecorder{}
+	hub.OnPresence(rec.record)
+	go hub.Run()
+	return hub, p, rec
+}
+
+func TestPresence_ReconnectWithinLinger(t *testing.T) {
+	hub, _, rec := newPresenceHub(NewMemoryPresenceStore(nil), 100*time.Millisecond, nil)
+
+	c1 := NewUserConnection(nil, hub, "alice")
+	hub.Register(c1)
+	require.Eventually(t, func() bool { return len(rec.list()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.True(t, rec.list()[0].Online)
+
+	// 断开后立刻重连：不应产生离线事件
+	hub.Unregister(c1)
+	c2 := NewUserConnection(nil, hub, "alice")
+	hub.Register(c2)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, rec.list(), 1)
+
+	infos, err := hub.Presence("alice")
+	require.NoError(t, err)
+	assert.True(t, infos[0].Online)
+
+	// 真正离开：延迟结束后离线，最后在线时间为断开时间
+	before := time.Now()
+	hub.Unregister(c2)
+	require.Eventually(t, func() bool { return len(rec.list()) == 2 }, time.Second, 5*time.Millisecond)
+	offline := rec.list()[1]
+	assert.False(t, offline.Online)
+	assert.WithinDuration(t, before, offline.LastSeen, 50*time.Millisecond)
+
+	infos, err = hub.Presence("alice")
+	require.NoError(t, err)
+	assert.False(t, infos[0].Online)
+	assert.Equal(t, offline.LastSeen, infos[0].LastSeen)
+}
+
+func TestPresence_RoomEvents(t *testing.T) {
+	hub, _, rec := newPresenceHub(NewMemoryPresenceStore(nil), 0, nil)
+
+	bob := NewUserConnection(nil, hub, "bob")
+	hub.Join(bob, "team")
+	hub.Register(bob)
+	outsider := NewUserConnection(nil, hub, "carol")
+	hub.Join(outsider, "other")
+	hub.Register(outsider)
+	require.Eventually(t, func() bool { return len(rec.list()) == 2 }, time.Second, 5*time.Millisecond)
+
+	alice := NewUserConnection(nil, hub, "alice")
+	hub.Join(alice, "team")
+	hub.Register(alice)
+
+	var event PresenceEvent
+	select {
+	case msg := <-bob.send:
+		require.NoError(t, json.Unmarshal(msg, &event))
+	case <-time.After(time.Second):
+		t.Fatal("bob did not receive presence event")
+	}
+	assert.Equal(t, PresenceEvent{Type: "presence", UserID: "alice", Online: true, LastSeen: event.LastSeen}, event)
+
+	hub.Unregister(alice)
+	select {
+	case msg := <-bob.send:
+		require.NoError(t, json.Unmarshal(msg, &event))
+		assert.Equal(t, "alice", event.UserID)
+		assert.False(t, event.Online)
+	case <-time.After(time.Second):
+		t.Fatal("bob did not receive offline event")
+	}
+
+	// 不在同一房间的连接收不到
+	assert.Len(t, outsider.send, 0)
+}
+
+func TestPresence_MultiReplicaCrashExpiry(t *testing.T) {
+	clock := common.NewFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	shared := NewMemoryPresenceStore(clock)
+	hubA, _, recA := newPresenceHub(shared, 0, clock)
+	hubB, trackerB, recB := newPresenceHub(shared, 0, clock)
+
+	// alice 同时连接两个实例：只有第一个实例产生上线事件
+	hubA.Register(NewUserConnection(nil, hubA, "alice"))
+	require.Eventually(t, func() bool { return len(recA.list()) == 1 }, time.Second, 5*time.Millisecond)
+	bobConn := NewUserConnection(nil, hubB, "bob")
+	hubB.Register(bobConn)
+	aliceOnB := NewUserConnection(nil, hubB, "alice")
+	hubB.Register(aliceOnB)
+	require.Eventually(t, func() bool { return len(recB.list()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "bob", recB.list()[0].UserID)
+
+	// alice 离开 B：A 上仍有连接，不算离线
+	hubB.Unregister(aliceOnB)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, recB.list(), 1)
+	infos, err := hubB.Presence("alice")
+	require.NoError(t, err)
+	assert.True(t, infos[0].Online)
+
+	// 实例 A 崩溃（不再心跳）：B 持续心跳，TTL 过后 alice 离线、bob 仍在线
+	crashedAt := clock.Now()
+	for i := 0; i < 4; i++ {
+		clock.Advance(time.Hour)
+		trackerB.refresh()
+	}
+	infos, err = hubB.Presence("alice", "bob")
+	require.NoError(t, err)
+	assert.False(t, infos[0].Online)
+	assert.Equal(t, crashedAt, infos[0].LastSeen)
+	assert.True(t, infos[1].Online)
+}
+
+func TestPresence_BulkQuery(t *testing.T) {
+	hub, _, rec := newPresenceHub(NewMemoryPresenceStore(nil), 0, nil)
+	for _, id := range []string{"u1", "u3"} {
+		hub.Register(NewUserConnection(nil, hub, id))
+	}
+	gone := NewUserConnection(nil, hub, "u2")
+	hub.Register(gone)
+	require.Eventually(t, func() bool { return len(rec.list()) == 3 }, time.Second, 5*time.Millisecond)
+	hub.Unregister(gone)
+	require.Eventually(t, func() bool { return len(rec.list()) == 4 }, time.Second, 5*time.Millisecond)
+
+	// 匿名连接不参与在线统计
+	hub.Register(NewConnection(nil, hub))
+
+	infos, err := hub.Presence("u1", "u2", "u3", "never")
+	require.NoError(t, err)
+	require.Len(t, infos, 4)
+	assert.Equal(t, []string{"u1", "u2", "u3", "never"}, []string{infos[0].UserID, infos[1].UserID, infos[2].UserID, infos[3].UserID})
+	assert.Equal(t, []bool{true, false, true, false}, []bool{infos[0].Online, infos[1].Online, infos[2].Online, infos[3].Online})
+	assert.False(t, infos[1].LastSeen.IsZero())
+	assert.True(t, infos[3].LastSeen.IsZero())
+
+	_, err = NewHub().Presence("u1")
+	assert.ErrorIs(t, err, ErrPresenceDisabled)
+}
+
+// refreshCounter 按实例统计心跳续期次数
+type refreshCounter struct {
+	*MemoryPresenceStore
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *refreshCounter) Refresh(ctx context.Context, replica string, userIDs []string, ttl time.Duration) error {
+	s.mu.Lock()
+	s.counts[replica]++
+	s.mu.Unlock()
+	return s.MemoryPresenceStore.Refresh(ctx, replica, userIDs, ttl)
+}
+
+func (s *refreshCounter) count(replica string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[replica]
+}
+
+func TestPresence_ReEnableStopsPreviousHeartbeat(t *testing.T) {
+	store := &refreshCounter{MemoryPresenceStore: NewMemoryPresenceStore(nil), counts: map[string]int{}}
+	hub := NewHub()
+	first := hub.enablePresence(store, 0, 5*time.Millisecond, nil)
+	require.Eventually(t, func() bool { return store.count(first.replica) > 0 }, time.Second, time.Millisecond)
+
+	second := hub.enablePresence(store, 0, 5*time.Millisecond, nil)
+	t.Cleanup(second.close)
+	require.Eventually(t, func() bool { return store.count(second.replica) > 0 }, time.Second, time.Millisecond)
+
+	// 旧的心跳协程已退出：新的继续续期，旧的计数不再增加
+	stopped := store.count(first.replica)
+	require.Eventually(t, func() bool { return store.count(second.replica) > 3 }, time.Second, time.Millisecond)
+	assert.LessOrEqual(t, store.count(first.replica), stopped+1, "停止前可能已经在执行最后一次续期")
+}
@@ -0,0 +1,254 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// defaultReplaySize 重放缓冲默认保留的消息条数
+const defaultReplaySize = 1024
+
+// ErrTooManyPolls 同一用户并发的长轮询超过上限
+var ErrTooManyPolls = &HubError{Code: 429, Message: "Too many concurrent polls"}
+
+// Message 带序号的消息（重放缓冲与长轮询使用）
+//
+// Data 与 WebSocket 连接收到的内容完全相同：合法 JSON 原样输出，其他内容编码为 JSON 字符串
+type Message struct {
+	Seq    uint64          `json:"seq"`
+	Room   string          `json:"room,omitempty"` // 房间消息的房间名
+	Data   json.RawMessage `json:"data"`
+	userID string          // 点对点消息的接收用户
+	all    bool            // 广播消息
+}
+
+// PollResult 一次长轮询的结果
+type PollResult struct {
+	Messages []Message `json:"messages"`
+	Seq      uint64    `json:"seq"`           // 下一次轮询传入的序号
+	Gap      bool      `json:"gap,omitempty"` // since 之后的部分消息已经不在重放缓冲中（或服务重启序号重置），客户端应重新同步
+}
+
+// pollWaiter 一个等待中的长轮询
+type pollWaiter struct {
+	userID string
+	rooms  map[string]bool
+	wake   chan struct{} // 容量 1，有新消息时非阻塞写入
+}
+
+// replayLog 重放缓冲：按序号保存最近的消息，唤醒等待中的长轮询
+type replayLog struct {
+	mu      sync.Mutex
+	size    int
+	entries []Message // 环形缓冲
+	next    int       // 下一条写入的位置
+	seq     uint64    // 最新消息的序号
+	waiters map[*pollWaiter]struct{}
+	polls   map[string]int // 用户 -> 进行中的长轮询数
+}
+
+// EnableReplay 为 Hub 启用重放缓冲（size <= 0 时使用默认值 1024）
+//
+// 启用后 SendToUser、BroadcastRoom、Broadcast 的消息同时写入重放缓冲并分配递增序号，
+// 长轮询（Poll，见 web.RegisterLongPoll）从中读取。重复调用只在第一次生效
+//
+// 使用方式：
+//
+//	hub := ws.NewHub()
+//	hub.EnableReplay(4096)
+//	go hub.Run()
+func (h *Hub) EnableReplay(size int) {
+	if size <= 0 {
+		size = defaultReplaySize
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.replay != nil {
+		return
+	}
+	h.replay = &replayLog{
+		size:    size,
+		entries: make([]Message, 0, size),
+		waiters: make(map[*pollWaiter]struct{}),
+		polls:   make(map[string]int),
+	}
+}
+
+// replayBuffer 当前的重放缓冲（未启用时为 nil）
+func (h *Hub) replayBuffer() *replayLog {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.replay
+}
+
+// record 写入重放缓冲并唤醒相关的长轮询
+func (r *replayLog) record(m Message, data []byte) {
+	if json.Valid(data) {
+		m.Data = append(json.RawMessage(nil), data...)
+	} else {
+		m.Data, _ = json.Marshal(string(data))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	m.Seq = r.seq
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, m)
+	} else {
+		r.entries[r.next] = m
+	}
+	r.next = (r.next + 1) % r.size
+
+	for w := range r.waiters {
+		if m.matches(w.userID, w.rooms) {
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (m Message) matches(userID string, rooms map[string]bool) bool {
+	switch {
+	case m.all:
+		return true
+	case m.userID != "":
+		return m.userID == userID
+	default:
+		return rooms[m.Room]
+	}
+}
+
+// read since 之后发给该用户的消息（最多 limit 条），调用方持有锁
+func (r *replayLog) read(userID string, rooms map[string]bool, since uint64, limit int) PollResult {
+	result := PollResult{Seq: since}
+	if since > r.seq {
+		// 序号比最新的还大：服务重启后序号重置，从当前位置重新开始
+		return PollResult{Seq: r.seq, Gap: true}
+	}
+	n := len(r.entries)
+	if n == 0 {
+		return result
+	}
+	oldest := r.seq - uint64(n) + 1
+	if since+1 < oldest {
+		result.Gap = true
+	}
+	start := 0
+	if since >= oldest {
+		start = int(since - oldest + 1)
+	}
+	first := 0
+	if n == r.size {
+		first = r.next
+	}
+	for i := start; i < n; i++ {
+		m := r.entries[(first+i)%n]
+		result.Seq = m.Seq
+		if m.matches(userID, rooms) {
+			result.Messages = append(result.Messages, m)
+			if len(result.Messages) == limit {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// PollOptions 长轮询参数
+type PollOptions struct {
+	UserID   string        // 已认证用户（必填）
+	Rooms    []string      // 用户所在的房间
+	Since    uint64        // 上次收到的序号（0 表示从头开始；首次轮询传入 LastSeq() 只等待之后的新消息）
+	Timeout  time.Duration // 没有消息时最长等待时间
+	MaxBatch int           // 一次最多返回的消息数，默认 100
+	MaxPolls int           // 同一用户并发长轮询上限，0 表示不限制
+}
+
+// Poll 长轮询：since 之后有发给该用户（或其所在房间、广播）的消息时立即返回，
+// 否则等待新消息、超时或 ctx 结束，超时返回空结果。未启用重放缓冲时自动启用
+//
+// 等待中的长轮询各自持有一个唤醒通道，只有匹配的新消息才会唤醒它，不轮询存储
+func (h *Hub) Poll(ctx context.Context, opts PollOptions) (PollResult, error) {
+	h.EnableReplay(0)
+	r := h.replayBuffer()
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 100
+	}
+	rooms := make(map[string]bool, len(opts.Rooms))
+	for _, room := range opts.Rooms {
+		rooms[room] = true
+	}
+
+	r.mu.Lock()
+	if opts.MaxPolls > 0 && r.polls[opts.UserID] >= opts.MaxPolls {
+		r.mu.Unlock()
+		return PollResult{}, ErrTooManyPolls
+	}
+	since := opts.Since
+	if result := r.read(opts.UserID, rooms, since, opts.MaxBatch); len(result.Messages) > 0 || result.Gap {
+		r.mu.Unlock()
+		return result, nil
+	}
+	w := &pollWaiter{userID: opts.UserID, rooms: rooms, wake: make(chan struct{}, 1)}
+	r.waiters[w] = struct{}{}
+	r.polls[opts.UserID]++
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.waiters, w)
+		if r.polls[opts.UserID]--; r.polls[opts.UserID] <= 0 {
+			delete(r.polls, opts.UserID)
+		}
+		r.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(opts.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.wake:
+			r.mu.Lock()
+			result := r.read(opts.UserID, rooms, since, opts.MaxBatch)
+			r.mu.Unlock()
+			if len(result.Messages) > 0 || result.Gap {
+				return result, nil
+			}
+		case <-timer.C:
+			r.mu.Lock()
+			result := r.read(opts.UserID, rooms, since, opts.MaxBatch)
+			r.mu.Unlock()
+			return result, nil
+		case <-ctx.Done():
+			return PollResult{Seq: since}, ctx.Err()
+		}
+	}
+}
+
+// LastSeq 重放缓冲中最新消息的序号（未启用重放时为 0）
+func (h *Hub) LastSeq() uint64 {
+	r := h.replayBuffer()
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.seq
+}
+
+// NewPollConnection 长轮询客户端的虚拟连接：不注册到 Hub，发给它的消息（conn.Send）进入该用户的重放缓冲，
+// 由下一次长轮询取回。用于把长轮询客户端发来的消息交给与 WebSocket 相同的 OnMessage 处理
+func NewPollConnection(hub *Hub, userID string) *Connection {
+	hub.EnableReplay(0)
+	return &Connection{hub: hub, id: "poll-" + generateConnID(), userID: userID, poll: true}
+}
+
+// HandleMessage 把一条客户端消息交给 OnMessage 回调（长轮询的上行消息与 WebSocket 消息走同一处理路径）
+func (h *Hub) HandleMessage(conn *Connection, message []byte) {
+	h.onMessageHandler(conn, message)
+}
@@ -0,0 +1,198 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pollAsync(hub *Hub, opts PollOptions) <-chan PollResult {
+	ch := make(chan PollResult, 1)
+	go func() {
+		result, _ := hub.Poll(context.Background(), opts)
+		ch <- result
+	}()
+	return ch
+}
+
+// waitPolling 等待指定数量的长轮询进入等待状态
+func waitPolling(t *testing.T, hub *Hub, n int) {
+	require.Eventually(t, func() bool {
+		r := hub.replayBuffer()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestPoll_MessageArrivesDuringPoll(t *testing.T) {
+	hub := NewHub()
+	hub.EnableReplay(16)
+	go hub.Run()
+
+	hub.SendToUser("alice", []byte("before"))
+	ch := pollAsync(hub, PollOptions{UserID: "alice", Rooms: []string{"team"}, Since: hub.LastSeq(), Timeout: 5 * time.Second})
+	waitPolling(t, hub, 1)
+
+	// 其他用户、其他房间的消息不会唤醒
+	hub.SendToUser("bob", []byte("not yours"))
+	hub.BroadcastRoom("other", []byte("not yours"))
+	select {
+	case <-ch:
+		t.Fatal("poll returned for unrelated message")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	hub.BroadcastRoom("team", []byte(`{"type":"hello"}`))
+	select {
+	case result := <-ch:
+		require.Len(t, result.Messages, 1)
+		assert.Equal(t, uint64(4), result.Messages[0].Seq)
+		assert.Equal(t, "team", result.Messages[0].Room)
+		assert.JSONEq(t, `{"type":"hello"}`, string(result.Messages[0].Data))
+		assert.Equal(t, uint64(4), result.Seq)
+		assert.False(t, result.Gap)
+	case <-time.After(time.Second):
+		t.Fatal("poll not woken")
+	}
+
+	// 带序号轮询立即取回之后的消息；非 JSON 内容编码为字符串
+	hub.SendToUser("alice", []byte("plain"))
+	result, err := hub.Poll(context.Background(), PollOptions{UserID: "alice", Since: 1, Timeout: time.Second})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1, "房间消息不投递给未声明房间的轮询")
+	assert.JSONEq(t, `"plain"`, string(result.Messages[0].Data))
+	assert.Equal(t, uint64(5), result.Seq)
+}
+
+func TestPoll_TimeoutReturnsEmpty(t *testing.T) {
+	hub := NewHub()
+	hub.EnableReplay(16)
+	hub.SendToUser("bob", []byte("x"))
+
+	start := time.Now()
+	result, err := hub.Poll(context.Background(), PollOptions{UserID: "alice", Since: 1, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Empty(t, result.Messages)
+	assert.Equal(t, uint64(1), result.Seq)
+	assert.False(t, result.Gap)
+
+	// ctx 结束时立即返回
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = hub.Poll(ctx, PollOptions{UserID: "alice", Since: 1, Timeout: time.Minute})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPoll_SequenceGaps(t *testing.T) {
+	hub := NewHub()
+	hub.EnableReplay(4)
+	for i := 1; i <= 10; i++ {
+		hub.SendToUser("alice", []byte(fmt.Sprint(i)))
+	}
+
+	// since 之后的消息已经被覆盖：返回仍保留的部分并标记 gap
+	result, err := hub.Poll(context.Background(), PollOptions{UserID: "alice", Since: 3, Timeout: time.Second})
+	require.NoError(t, err)
+	assert.True(t, result.Gap)
+	require.Len(t, result.Messages, 4)
+	assert.Equal(t, uint64(7), result.Messages[0].Seq)
+	assert.Equal(t, uint64(10), result.Seq)
+
+	// 恰好接上最旧的一条时没有缺口
+	result, err = hub.Poll(context.Background(), PollOptions{UserID: "alice", Since: 6, MaxBatch: 2, Timeout: time.Second})
+	require.NoError(t, err)
+	assert.False(t, result.Gap)
+	assert.Len(t, result.Messages, 2)
+	assert.Equal(t, uint64(8), result.Seq, "分批返回时 seq 指向本批最后一条")
+
+	// 序号超前（服务重启后序号重置）：立即返回当前序号并标记 gap
+	result, err = hub.Poll(context.Background(), PollOptions{UserID: "alice", Since: 99, Timeout: time.Minute})
+	require.NoError(t, err)
+	assert.True(t, result.Gap)
+	assert.Empty(t, result.Messages)
+	assert.Equal(t, uint64(10), result.Seq)
+}
+
+func TestPoll_ManyPollersWokenFairly(t *testing.T) {
+	hub := NewHub()
+	hub.EnableReplay(64)
+	go hub.Run()
+
+	const users = 50
+	results := make([]<-chan PollResult, users)
+	for i := range results {
+		results[i] = pollAsync(hub, PollOptions{UserID: fmt.Sprintf("user-%d", i), Timeout: 5 * time.Second})
+	}
+	waitPolling(t, hub, users)
+
+	// 只唤醒收件人
+	hub.SendToUser("user-7", []byte("direct"))
+	select {
+	case result := <-results[7]:
+		require.Len(t, result.Messages, 1)
+		assert.JSONEq(t, `"direct"`, string(result.Messages[0].Data))
+	case <-time.After(time.Second):
+		t.Fatal("recipient not woken")
+	}
+	waitPolling(t, hub, users-1)
+
+	// 广播唤醒其余全部轮询，每个都拿到同一条消息
+	hub.Broadcast([]byte(`{"type":"notice"}`))
+	var wg sync.WaitGroup
+	for i, ch := range results {
+		if i == 7 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ch <-chan PollResult) {
+			defer wg.Done()
+			select {
+			case result := <-ch:
+				assert.Len(t, result.Messages, 1, "user-%d", i)
+				assert.Equal(t, uint64(2), result.Seq)
+			case <-time.After(time.Second):
+				t.Errorf("user-%d not woken", i)
+			}
+		}(i, ch)
+	}
+	wg.Wait()
+	waitPolling(t, hub, 0)
+}
+
+func TestPoll_PerUserLimit(t *testing.T) {
+	hub := NewHub()
+	hub.EnableReplay(16)
+
+	first := pollAsync(hub, PollOptions{UserID: "alice", Timeout: time.Second, MaxPolls: 1})
+	waitPolling(t, hub, 1)
+	_, err := hub.Poll(context.Background(), PollOptions{UserID: "alice", Timeout: time.Second, MaxPolls: 1})
+	assert.ErrorIs(t, err, ErrTooManyPolls)
+
+	// 其他用户不受影响；结束后名额释放
+	other := pollAsync(hub, PollOptions{UserID: "bob", Timeout: 10 * time.Millisecond, MaxPolls: 1})
+	<-other
+	hub.SendToUser("alice", []byte("x"))
+	<-first
+	_, err = hub.Poll(context.Background(), PollOptions{UserID: "alice", Timeout: 10 * time.Millisecond, MaxPolls: 1})
+	assert.NoError(t, err)
+}
+
+func TestPollConnection_RepliesGoToReplay(t *testing.T) {
+	hub := NewHub()
+	hub.OnMessage(func(conn *Connection, msg []byte) {
+		conn.Send(append([]byte("echo:"), msg...))
+	})
+
+	hub.HandleMessage(NewPollConnection(hub, "alice"), []byte("ping"))
+	result, err := hub.Poll(context.Background(), PollOptions{UserID: "alice", Timeout: time.Second})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.JSONEq(t, `"echo:ping"`, string(result.Messages[0].Data))
+}
@@ -0,0 +1,87 @@
+package ws
+
+// Join 把连接加入房间（注册前后均可调用）
+//
+// 使用方式：
+//
+//	hub.Join(conn, "chat:42")
+func (h *Hub) Join(conn *Connection, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if conn.rooms == nil {
+		conn.rooms = make(map[string]bool)
+	}
+	conn.rooms[room] = true
+	if _, ok := h.connections[conn.ID()]; ok {
+		h.joinLocked(conn, room)
+	}
+}
+
+// Leave 把连接移出房间
+//
+// 使用方式：
+//
+//	hub.Leave(conn, "chat:42")
+func (h *Hub) Leave(conn *Connection, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(conn.rooms, room)
+	h.leaveLocked(conn, room)
+}
+
+// BroadcastRoom 向房间内所有连接发送消息（发送队列已满的连接跳过）
+//
+// 使用方式：
+//
+//	hub.BroadcastRoom("chat:42", []byte(`{"type":"message"}`))
+func (h *Hub) BroadcastRoom(room string, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.replay != nil {
+		h.replay.record(Message{Room: room}, message)
+	}
+	for _, conn := range h.rooms[room] {
+		trySend(conn, message)
+	}
+}
+
+// RoomMembers 获取房间内的连接
+func (h *Hub) RoomMembers(room string) []*Connection {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	conns := make([]*Connection, 0, len(h.rooms[room]))
+	for _, conn := range h.rooms[room] {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// joinLocked 更新房间索引（调用方持有写锁）
+func (h *Hub) joinLocked(conn *Connection, room string) {
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[string]*Connection)
+		h.rooms[room] = members
+	}
+	members[conn.ID()] = conn
+}
+
+// leaveLocked 从房间索引移除连接（调用方持有写锁）
+func (h *Hub) leaveLocked(conn *Connection, room string) {
+	if members, ok := h.rooms[room]; ok {
+		delete(members, conn.ID())
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// trySend 非阻塞发送（调用方持有读锁，保证连接未被关闭）
+func trySend(conn *Connection, message []byte) bool {
+	select {
+	case conn.send <- message:
+		return true
+	default:
+		return false
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// log ws 模块的日志记录器
+var log = logger.Named("ws")
+
 // Upgrader HTTP 升级为 WebSocket 的配置
 var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -37,7 +40,7 @@ func ConfigureUpgrader(config Config) {
 //	h.GET("/ws", func(ctx context.Context, c *app.RequestContext) {
 //	    conn, err := ws.UpgradeHTTP(c)
 //	    if err != nil {
-//	        logger.Errorf("WS upgrade failed: %v", err)
+//	        log.Errorf("WS upgrade failed: %v", err)
 //	        return
 //	    }
 //	    connection := ws.NewConnection(conn, hub)
@@ -49,6 +52,6 @@ func UpgradeHTTP(c *app.RequestContext) (*websocket.Conn, error) {
 	// 注意：Hertz 和 gorilla/websocket 的接口不完全兼容
 	// 实际使用中，建议直接使用 gorilla/websocket 的标准用法
 	// 或者使用 Hertz 内置的 WebSocket 支持（如果有的话）
-	logger.Errorf("[WS] Upgrade functionality requires manual implementation")
+	log.Errorf("[WS] Upgrade functionality requires manual implementation")
 	return nil, http.ErrNotSupported
 }
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/CenJIl/base/web/middleware"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+)
+
+// StreamZip 以流式 zip 响应下载，边生成边发送，不在内存或磁盘中拼出完整的压缩包
+//
+// write 在独立协程中执行，ctx 在客户端断开时取消；write 返回错误时连接被中断，
+// 客户端收到的是不完整的压缩包（响应头已经发出，无法再改为错误响应）
+//
+// 使用方式：
+//
+//	web.StreamZip(c, "orders.zip", func(ctx context.Context, zw *zip.Writer) error {
+//	    w, err := zw.Create("orders.csv")
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return web.WriteCSV(ctx, w, header, rows)
+//	})
+func StreamZip(c *app.RequestContext, filename string, write func(ctx context.Context, zw *zip.Writer) error) {
+	ctx := middleware.ClientContext(c)
+	pr, pw := io.Pipe()
+	// 客户端断开后没有人再读管道，关闭读端让阻塞的写入返回
+	stop := context.AfterFunc(ctx, func() { pr.CloseWithError(ctx.Err()) })
+	go func() {
+		defer stop()
+		zw := zip.NewWriter(pw)
+		err := write(ctx, zw)
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("[Zip] 生成 %s 失败: %v", filename, err)
+		}
+		pw.CloseWithError(err)
+	}()
+
+	c.SetStatusCode(consts.StatusOK)
+	c.SetContentType("application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s",
+		filename, url.PathEscape(filename)))
+	c.SetBodyStream(pr, -1)
+}